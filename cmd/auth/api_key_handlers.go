@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/auth/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/apikey"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// defaultRotationGracePeriod is how long a rotated key keeps working when
+// the caller does not say
+const defaultRotationGracePeriod = 24 * time.Hour
+
+// UpdateAPIKeyRequest defines the payload for changing a key's expiry.
+type UpdateAPIKeyRequest struct {
+	// ExpiresAt is when the key stops working; null removes its expiry
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// RotateAPIKeyRequest defines the payload for rotating a key.
+type RotateAPIKeyRequest struct {
+	// GracePeriodHours the old key keeps working, 24 by default. 0 revokes
+	// it at once.
+	GracePeriodHours *int `json:"grace_period_hours"`
+}
+
+// apiKeyOf answers the caller's key named by the path, or an error and nil
+func (h *AuthHandler) apiKeyOf(w http.ResponseWriter, r *http.Request) *domain.APIKey {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return nil
+	}
+
+	id := r.PathValue("id")
+	key, err := h.service.GetAPIKey(r.Context(), userID, id)
+	if errors.Is(err, domain.ErrAPIKeyNotFound) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "API key not found"})
+		return nil
+	}
+	if err != nil {
+		log.Printf("Failed to get api key %s: %v", id, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get API key"})
+		return nil
+	}
+	return key
+}
+
+// ListAPIKeys answers the caller's keys with when each was last used
+func (h *AuthHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	keys, err := h.service.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		log.Printf("ListAPIKeys: Failed to list api keys of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list API keys"})
+		return
+	}
+	if keys == nil {
+		keys = []domain.APIKey{}
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": keys})
+}
+
+// UpdateAPIKey sets or removes the expiry of one of the caller's keys
+func (h *AuthHandler) UpdateAPIKey(w http.ResponseWriter, r *http.Request) {
+	key := h.apiKeyOf(w, r)
+	if key == nil {
+		return
+	}
+
+	var req UpdateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+
+	err := h.service.SetAPIKeyExpiry(r.Context(), key, req.ExpiresAt)
+	if errors.Is(err, domain.ErrInvalidKeyExpiry) || errors.Is(err, domain.ErrAPIKeyRevoked) {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("UpdateAPIKey: Failed to update api key %s: %v", key.ID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update API key"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, key)
+}
+
+// RotateAPIKey issues a replacement of one of the caller's keys, with the
+// same zone, environment, type and scopes. The old key keeps working for
+// the grace period, so that it can be swapped out without downtime.
+func (h *AuthHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	old := h.apiKeyOf(w, r)
+	if old == nil {
+		return
+	}
+
+	var req RotateAPIKeyRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonutil.WriteErrorJSON(w, "Invalid request body")
+			return
+		}
+	}
+	grace := defaultRotationGracePeriod
+	if req.GracePeriodHours != nil {
+		grace = time.Duration(*req.GracePeriodHours) * time.Hour
+	}
+	if grace < 0 || grace > domain.MaxRotationGracePeriod {
+		jsonutil.WriteErrorJSON(w, fmt.Sprintf("grace_period_hours must be between 0 and %d", int(domain.MaxRotationGracePeriod.Hours())))
+		return
+	}
+
+	fullKey, hash, err := apikey.GenerateKey(old.KeyPrefix, h.hmacSecret)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, "Failed to generate key")
+		return
+	}
+	replacement := &domain.APIKey{
+		UserID:       old.UserID,
+		OrgID:        old.OrgID,
+		ZoneID:       old.ZoneID,
+		Mode:         old.Mode,
+		KeyPrefix:    old.KeyPrefix,
+		KeyHash:      hash,
+		TruncatedKey: fullKey[len(fullKey)-4:],
+		Environment:  old.Environment,
+		Type:         old.Type,
+		Scopes:       old.Scopes,
+	}
+
+	err = h.service.RotateAPIKey(r.Context(), old, replacement, grace)
+	if errors.Is(err, domain.ErrAPIKeyRevoked) {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("RotateAPIKey: Failed to rotate api key %s: %v", old.ID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to rotate API key"})
+		return
+	}
+	log.Printf("RotateAPIKey: Rotated api key %s to %s", old.ID, replacement.ID)
+
+	// Return the FULL key only once
+	jsonutil.WriteJSON(w, http.StatusCreated, GenerateAPIKeyResponse{
+		ID:           replacement.ID,
+		Key:          fullKey,
+		Environment:  replacement.Environment,
+		ZoneID:       replacement.ZoneID,
+		Mode:         replacement.Mode,
+		Type:         replacement.Type,
+		Scopes:       replacement.Scopes,
+		TruncatedKey: replacement.TruncatedKey,
+		RotatedFrom:  old.ID,
+	})
+}
+
+// RevokeAPIKey stops one of the caller's keys from working at once
+func (h *AuthHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	key := h.apiKeyOf(w, r)
+	if key == nil {
+		return
+	}
+
+	if err := h.service.RevokeAPIKey(r.Context(), key); err != nil {
+		log.Printf("RevokeAPIKey: Failed to revoke api key %s: %v", key.ID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to revoke API key"})
+		return
+	}
+
+	log.Printf("RevokeAPIKey: User %s revoked api key %s", key.UserID, key.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sapliy/fintech-ecosystem/internal/flow"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// DebugHandler serves the flow debug sessions of the legacy /debug routes
+type DebugHandler struct {
+	service  *flow.DebugService
+	upgrader websocket.Upgrader
+}
+
+func NewDebugHandler(service *flow.DebugService) *DebugHandler {
+	return &DebugHandler{
+		service: service,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return true // Allow all origins for development
+			},
+		},
+	}
+}
+
+func (h *DebugHandler) StartDebugSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FlowID string            `json:"flow_id"`
+		ZoneID string            `json:"zone_id"`
+		Level  domain.DebugLevel `json:"level"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if req.FlowID == "" || req.ZoneID == "" {
+		jsonutil.WriteErrorJSON(w, "flow_id and zone_id are required")
+		return
+	}
+
+	session, err := h.service.StartDebugSession(r.Context(), req.FlowID, req.ZoneID, req.Level)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusCreated, session)
+}
+
+func (h *DebugHandler) GetDebugSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		jsonutil.WriteErrorJSON(w, "Session ID required")
+		return
+	}
+
+	session, err := h.service.GetDebugSession(sessionID)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, session)
+}
+
+// GetDebugEvents lists the events of a session, optionally only those after
+// the RFC 3339 "since" query parameter
+func (h *DebugHandler) GetDebugEvents(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		jsonutil.WriteErrorJSON(w, "Session ID required")
+		return
+	}
+
+	var since *time.Time
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		parsed, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			jsonutil.WriteErrorJSON(w, "since must be an RFC 3339 timestamp")
+			return
+		}
+		since = &parsed
+	}
+
+	events, err := h.service.GetDebugEvents(sessionID, since)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, events)
+}
+
+func (h *DebugHandler) EndDebugSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SessionID == "" {
+		jsonutil.WriteErrorJSON(w, "Session ID required")
+		return
+	}
+
+	if err := h.service.EndDebugSession(req.SessionID); err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "ended"})
+}
+
+// ExecuteFlowWithDebug runs the session's flow in the background; its
+// progress is read from the session's events
+func (h *DebugHandler) ExecuteFlowWithDebug(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SessionID string                 `json:"session_id"`
+		Input     map[string]interface{} `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SessionID == "" {
+		jsonutil.WriteErrorJSON(w, "Session ID required")
+		return
+	}
+	if req.Input == nil {
+		req.Input = make(map[string]interface{})
+	}
+
+	if err := h.service.RunFlow(r.Context(), req.SessionID, req.Input); err != nil {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusAccepted, map[string]string{"status": "running", "session_id": req.SessionID})
+}
+
+// WebSocketDebug streams the events of the session_id query parameter's
+// session until the client disconnects
+func (h *DebugHandler) WebSocketDebug(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	if _, err := h.service.GetDebugSession(sessionID); err != nil {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade to WebSocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	sent := 0
+	for {
+		events, err := h.service.GetDebugEvents(sessionID, nil)
+		if err != nil {
+			return // The session ended
+		}
+		for _, event := range events[sent:] {
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		}
+		sent = len(events)
+
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -8,29 +8,39 @@ import (
 	"time"
 
 	"github.com/sapliy/fintech-ecosystem/internal/auth/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/zone"
 	pb "github.com/sapliy/fintech-ecosystem/proto/auth"
 )
 
 type AuthGRPCServer struct {
 	pb.UnimplementedAuthServiceServer
 	service *domain.AuthService
+	zones   *zone.Service
 }
 
-func NewAuthGRPCServer(service *domain.AuthService) *AuthGRPCServer {
-	return &AuthGRPCServer{service: service}
+func NewAuthGRPCServer(service *domain.AuthService, zones *zone.Service) *AuthGRPCServer {
+	return &AuthGRPCServer{service: service, zones: zones}
 }
 
 func (s *AuthGRPCServer) ValidateKey(ctx context.Context, req *pb.ValidateKeyRequest) (*pb.ValidateKeyResponse, error) {
-	key, err := s.service.GetAPIKeyByHash(ctx, req.KeyHash)
+	key, err := s.service.ValidateAPIKey(ctx, req.KeyHash)
 	if err != nil {
 		log.Printf("GRPC ValidateKey error: %v", err)
 		return &pb.ValidateKeyResponse{Valid: false}, nil
 	}
 
-	if key == nil || key.RevokedAt != nil {
+	if key == nil {
 		return &pb.ValidateKeyResponse{Valid: false}, nil
 	}
 
+	// Keys act for the organization of their zone, which older keys did not
+	// record
+	if key.OrgID == "" && key.ZoneID != "" && s.zones != nil {
+		if z, err := s.zones.GetZone(ctx, key.ZoneID); err == nil && z != nil {
+			key.OrgID = z.OrgID
+		}
+	}
+
 	role := ""
 	if key.OrgID != "" {
 		role = "admin" // Default for API keys
@@ -217,3 +227,35 @@ func (s *AuthGRPCServer) ListTeamMembers(ctx context.Context, req *pb.ListTeamMe
 
 	return &pb.ListTeamMembersResponse{Memberships: pbMemberships}, nil
 }
+
+// ResolveZone allows a user into a zone of an organization they belong to.
+// A session acting for an organization only reaches that organization's
+// zones.
+func (s *AuthGRPCServer) ResolveZone(ctx context.Context, req *pb.ResolveZoneRequest) (*pb.ResolveZoneResponse, error) {
+	if req.UserId == "" || req.ZoneId == "" {
+		return &pb.ResolveZoneResponse{Allowed: false}, nil
+	}
+
+	z, err := s.zones.GetZone(ctx, req.ZoneId)
+	if err != nil {
+		return nil, err
+	}
+	if z == nil || (req.OrgId != "" && z.OrgID != req.OrgId) {
+		return &pb.ResolveZoneResponse{Allowed: false}, nil
+	}
+
+	role, err := s.service.MemberRole(ctx, req.UserId, z.OrgID)
+	if err == domain.ErrNotOrgMember {
+		return &pb.ResolveZoneResponse{Allowed: false}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ResolveZoneResponse{
+		Allowed: true,
+		OrgId:   z.OrgID,
+		Mode:    string(z.Mode),
+		Role:    role,
+	}, nil
+}
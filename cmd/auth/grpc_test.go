@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sapliy/fintech-ecosystem/internal/auth/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/zone"
+	zoneDomain "github.com/sapliy/fintech-ecosystem/internal/zone/domain"
+	pb "github.com/sapliy/fintech-ecosystem/proto/auth"
+)
+
+// staticZones is a zone repository of fixed zones
+type staticZones map[string]*zoneDomain.Zone
+
+func (z staticZones) Create(ctx context.Context, zone *zoneDomain.Zone) error { return nil }
+func (z staticZones) GetByID(ctx context.Context, id string) (*zoneDomain.Zone, error) {
+	return z[id], nil
+}
+func (z staticZones) ListByOrgID(ctx context.Context, orgID string) ([]*zoneDomain.Zone, error) {
+	return nil, nil
+}
+func (z staticZones) UpdateMetadata(ctx context.Context, id string, metadata map[string]string) error {
+	return nil
+}
+func (z staticZones) Delete(ctx context.Context, id string) error { return nil }
+
+func TestAuthGRPCServer_ResolveZone(t *testing.T) {
+	zones := staticZones{
+		"zone_live":  {ID: "zone_live", OrgID: "org_1", Mode: zoneDomain.ModeLive},
+		"zone_other": {ID: "zone_other", OrgID: "org_2", Mode: zoneDomain.ModeTest},
+	}
+	repo := &domain.MockRepository{
+		GetMembershipFunc: func(ctx context.Context, userID, orgID string) (*domain.Membership, error) {
+			if userID == "user_1" && orgID == "org_1" {
+				return &domain.Membership{UserID: userID, OrgID: orgID, Role: domain.RoleDeveloper}, nil
+			}
+			return nil, nil
+		},
+	}
+	s := NewAuthGRPCServer(domain.NewAuthService(repo, nil), zone.NewService(zones, zoneDomain.TemplateProviders{}))
+
+	tests := []struct {
+		name string
+		req  *pb.ResolveZoneRequest
+		want *pb.ResolveZoneResponse
+	}{
+		{"member without an organization", &pb.ResolveZoneRequest{UserId: "user_1", ZoneId: "zone_live"},
+			&pb.ResolveZoneResponse{Allowed: true, OrgId: "org_1", Mode: "live", Role: domain.RoleDeveloper}},
+		{"member of the session's organization", &pb.ResolveZoneRequest{UserId: "user_1", OrgId: "org_1", ZoneId: "zone_live"},
+			&pb.ResolveZoneResponse{Allowed: true, OrgId: "org_1", Mode: "live", Role: domain.RoleDeveloper}},
+		{"zone of another organization than the session's", &pb.ResolveZoneRequest{UserId: "user_1", OrgId: "org_2", ZoneId: "zone_live"},
+			&pb.ResolveZoneResponse{}},
+		{"not a member", &pb.ResolveZoneRequest{UserId: "user_1", ZoneId: "zone_other"}, &pb.ResolveZoneResponse{}},
+		{"unknown zone", &pb.ResolveZoneRequest{UserId: "user_1", ZoneId: "zone_missing"}, &pb.ResolveZoneResponse{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := s.ResolveZone(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("ResolveZone failed: %v", err)
+			}
+			if res.Allowed != tt.want.Allowed || res.OrgId != tt.want.OrgId || res.Mode != tt.want.Mode || res.Role != tt.want.Role {
+				t.Errorf("Expected %v, got %v", tt.want, res)
+			}
+		})
+	}
+}
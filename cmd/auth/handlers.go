@@ -9,25 +9,36 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"github.com/sapliy/fintech-ecosystem/internal/auth/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/zone"
 	"github.com/sapliy/fintech-ecosystem/pkg/apikey"
 	"github.com/sapliy/fintech-ecosystem/pkg/bcryptutil"
 	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
 	"github.com/sapliy/fintech-ecosystem/pkg/jwtutil"
+	"github.com/sapliy/fintech-ecosystem/pkg/scopes"
 )
 
 type GenerateAPIKeyRequest struct {
 	ZoneID      string `json:"zone_id"`
 	Environment string `json:"environment"` // "test" or "live"
 	Type        string `json:"type"`        // "secret" or "publishable"
+	// Scopes limit what the key may do, e.g. ["payments:write",
+	// "ledger:read"]. A key without scopes has full access.
+	Scopes []string `json:"scopes"`
+	// ExpiresAt, when set, is when the key stops working
+	ExpiresAt *time.Time `json:"expires_at"`
 }
 
 type GenerateAPIKeyResponse struct {
-	Key          string `json:"key"` // Full key shown ONLY once
-	Environment  string `json:"environment"`
-	ZoneID       string `json:"zone_id"`
-	Mode         string `json:"mode"`
-	Type         string `json:"type"`
-	TruncatedKey string `json:"truncated_key"`
+	ID           string     `json:"id"`
+	Key          string     `json:"key"` // Full key shown ONLY once
+	Environment  string     `json:"environment"`
+	ZoneID       string     `json:"zone_id"`
+	Mode         string     `json:"mode"`
+	Type         string     `json:"type"`
+	Scopes       string     `json:"scopes"`
+	TruncatedKey string     `json:"truncated_key"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RotatedFrom  string     `json:"rotated_from,omitempty"` // The key this one replaced
 }
 
 // Helper to extract UserID from JWT
@@ -67,6 +78,38 @@ func (h *AuthHandler) GenerateAPIKey(w http.ResponseWriter, r *http.Request) {
 	if req.Type == "" {
 		req.Type = "secret"
 	}
+	if req.Environment != "test" && req.Environment != "live" {
+		jsonutil.WriteErrorJSON(w, "environment must be test or live")
+		return
+	}
+	// Test keys never reach live zones nor live keys test ones
+	orgID := ""
+	if h.zones != nil {
+		z, err := h.zones.GetZone(r.Context(), req.ZoneID)
+		if err != nil || z == nil {
+			jsonutil.WriteErrorJSON(w, "Zone not found")
+			return
+		}
+		if string(z.Mode) != req.Environment {
+			jsonutil.WriteErrorJSON(w, req.Environment+" keys cannot be created for "+string(z.Mode)+" zones")
+			return
+		}
+		orgID = z.OrgID
+	}
+
+	keyScopes := scopes.All
+	if len(req.Scopes) > 0 {
+		valid, invalid := scopes.ValidateScopes(scopes.JoinScopes(req.Scopes))
+		if len(invalid) > 0 {
+			jsonutil.WriteErrorJSON(w, "Unknown scopes: "+strings.Join(invalid, ", "))
+			return
+		}
+		keyScopes = scopes.JoinScopes(valid)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		jsonutil.WriteErrorJSON(w, domain.ErrInvalidKeyExpiry.Error())
+		return
+	}
 
 	prefix := "sk_"
 	if req.Type == "publishable" {
@@ -84,7 +127,7 @@ func (h *AuthHandler) GenerateAPIKey(w http.ResponseWriter, r *http.Request) {
 
 	key := &domain.APIKey{
 		UserID:       userID,
-		OrgID:        "", // Optional, will be linked via zone or user
+		OrgID:        orgID, // The zone's organization, which policies apply to the key
 		ZoneID:       req.ZoneID,
 		Mode:         req.Environment,
 		KeyPrefix:    prefix,
@@ -92,6 +135,8 @@ func (h *AuthHandler) GenerateAPIKey(w http.ResponseWriter, r *http.Request) {
 		TruncatedKey: truncated,
 		Environment:  req.Environment,
 		Type:         req.Type,
+		Scopes:       keyScopes,
+		ExpiresAt:    req.ExpiresAt,
 	}
 
 	if err := h.service.CreateAPIKey(r.Context(), key); err != nil {
@@ -102,12 +147,15 @@ func (h *AuthHandler) GenerateAPIKey(w http.ResponseWriter, r *http.Request) {
 
 	// Return the FULL key only once
 	jsonutil.WriteJSON(w, http.StatusCreated, GenerateAPIKeyResponse{
+		ID:           key.ID,
 		Key:          fullKey,
 		Environment:  req.Environment,
 		ZoneID:       req.ZoneID,
 		Mode:         req.Environment,
 		Type:         req.Type,
+		Scopes:       keyScopes,
 		TruncatedKey: truncated,
+		ExpiresAt:    req.ExpiresAt,
 	})
 }
 
@@ -133,14 +181,14 @@ func (h *AuthHandler) ValidateAPIKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	key, err := h.service.GetAPIKeyByHash(r.Context(), req.KeyHash)
+	key, err := h.service.ValidateAPIKey(r.Context(), req.KeyHash)
 	if err != nil {
 		log.Printf("Error validating key: %v", err)
 		jsonutil.WriteErrorJSON(w, "Validation failed")
 		return
 	}
 
-	if key == nil || key.RevokedAt != nil {
+	if key == nil {
 		jsonutil.WriteJSON(w, http.StatusOK, ValidateAPIKeyResponse{Valid: false})
 		return
 	}
@@ -180,18 +228,14 @@ func (h *AuthHandler) CreateOrganization(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	org, err := h.service.CreateOrganization(r.Context(), req.Name, req.Domain)
+	// The creator owns the organization
+	org, err := h.service.CreateOrganizationWithOwner(r.Context(), userID, req.Name, req.Domain)
 	if err != nil {
 		log.Printf("Failed to create organization: %v", err)
 		jsonutil.WriteErrorJSON(w, "Failed to create organization")
 		return
 	}
 
-	// Add creator as owner
-	if err := h.service.AddMember(r.Context(), userID, org.ID, domain.RoleOwner); err != nil {
-		log.Printf("Failed to add owner to organization: %v", err)
-	}
-
 	jsonutil.WriteJSON(w, http.StatusCreated, org)
 }
 
@@ -200,6 +244,7 @@ type AuthHandler struct {
 	service    *domain.AuthService
 	hmacSecret string
 	rdb        *redis.Client
+	zones      *zone.Service // Checks keys match the mode of their zone, if set
 }
 
 // RegisterRequest defines the payload for user registration.
@@ -214,10 +259,16 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
-// LoginResponse defines the successful response for login.
+// LoginResponse defines the successful response for login and refresh.
+// Token is a short-lived access token, renewed with the refresh token.
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  *domain.User `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refresh_token"`
+	ExpiresIn    int          `json:"expires_in"`
+	SessionID    string       `json:"session_id"`
+	OrgID        string       `json:"org_id,omitempty"` // The organization the token acts for
+	Role         string       `json:"role,omitempty"`
+	User         *domain.User `json:"user,omitempty"`
 }
 
 // Register handles user account creation.
@@ -303,20 +354,13 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := jwtutil.GenerateToken(user.ID, user.Email)
-	if err != nil {
-		jsonutil.WriteErrorJSON(w, "Failed to generate token")
+	// Users with two-factor authentication log in at /login/2fa
+	if h.challengeTwoFactor(w, r, user) {
 		return
 	}
 
-	// Hide password hash in response
-	user.Password = ""
-
 	log.Printf("Login: Success for user %s", user.Email)
-	jsonutil.WriteJSON(w, http.StatusOK, LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	h.startSession(w, r, user)
 }
 
 // OAuthTokenResponse represents the response for /oauth/token.
@@ -737,13 +781,7 @@ func (h *AuthHandler) SSOCallback(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	token, err := jwtutil.GenerateToken(user.ID, user.Email)
-	if err != nil {
-		jsonutil.WriteErrorJSON(w, "Failed to generate token")
-		return
-	}
-
-	jsonutil.WriteJSON(w, http.StatusOK, LoginResponse{Token: token})
+	h.startSession(w, r, user)
 }
 
 func writeOAuthError(w http.ResponseWriter, errorCode, description string, statusCode int) {
@@ -2,13 +2,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sapliy/fintech-ecosystem/internal/auth/domain"
 	"github.com/sapliy/fintech-ecosystem/pkg/bcryptutil"
+	"github.com/sapliy/fintech-ecosystem/pkg/jwtutil"
+	"github.com/sapliy/fintech-ecosystem/pkg/totp"
 )
 
 func TestAuthHandler_Login(t *testing.T) {
@@ -112,3 +116,397 @@ func TestAuthHandler_Register(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthHandler_RefreshToken(t *testing.T) {
+	var session *domain.Session
+	mRepo := &domain.MockRepository{
+		CreateSessionFunc: func(ctx context.Context, s *domain.Session) error {
+			session = s
+			return nil
+		},
+		GetSessionByTokenHashFunc: func(ctx context.Context, tokenHash string) (*domain.Session, error) {
+			if session != nil && (session.RefreshTokenHash == tokenHash || session.PreviousTokenHash == tokenHash) {
+				found := *session
+				return &found, nil
+			}
+			return nil, nil
+		},
+		RotateSessionTokenFunc: func(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) (bool, error) {
+			session.PreviousTokenHash, session.RefreshTokenHash, session.ExpiresAt = oldHash, newHash, expiresAt
+			return true, nil
+		},
+		RevokeSessionFunc: func(ctx context.Context, id string) error {
+			now := time.Now()
+			session.RevokedAt = &now
+			return nil
+		},
+		GetUserByIDFunc: func(ctx context.Context, id string) (*domain.User, error) {
+			return &domain.User{ID: id, Email: "test@example.com"}, nil
+		},
+	}
+	service := domain.NewAuthService(mRepo, nil)
+	h := &AuthHandler{service: service}
+
+	_, first, err := service.CreateSession(context.Background(), "user_123", "micro-cli", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	refresh := func(token string) (*httptest.ResponseRecorder, LoginResponse) {
+		req := httptest.NewRequest("POST", "/token/refresh", strings.NewReader(`{"refresh_token":"`+token+`"}`))
+		w := httptest.NewRecorder()
+		h.RefreshToken(w, req)
+		var resp LoginResponse
+		_ = json.NewDecoder(w.Body).Decode(&resp)
+		return w, resp
+	}
+
+	w, resp := refresh(first)
+	if w.Code != http.StatusOK || resp.Token == "" || resp.RefreshToken == "" || resp.RefreshToken == first {
+		t.Fatalf("Expected a new access token and a rotated refresh token, got %d %+v", w.Code, resp)
+	}
+	if resp.SessionID != session.ID {
+		t.Errorf("Expected session %s, got %s", session.ID, resp.SessionID)
+	}
+
+	// Reusing the rotated out token revokes the session
+	if w, _ := refresh(first); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for a reused token, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if session.RevokedAt == nil {
+		t.Error("Expected the session to be revoked")
+	}
+	if w, _ := refresh(resp.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for a revoked session, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestAuthHandler_APIKeyLifecycle(t *testing.T) {
+	keys := map[string]*domain.APIKey{
+		"key_1": {ID: "key_1", UserID: "user_123", KeyPrefix: "sk_test", KeyHash: "hash_1", Environment: "test", Type: "secret", Scopes: "payments:read"},
+		"key_2": {ID: "key_2", UserID: "user_456", KeyPrefix: "sk_test", KeyHash: "hash_2", Environment: "test", Type: "secret", Scopes: "*"},
+	}
+	mRepo := &domain.MockRepository{
+		CreateAPIKeyFunc: func(ctx context.Context, key *domain.APIKey) error {
+			key.ID = "key_3"
+			keys[key.ID] = key
+			return nil
+		},
+		GetAPIKeyFunc: func(ctx context.Context, id string) (*domain.APIKey, error) {
+			if key, ok := keys[id]; ok {
+				found := *key
+				return &found, nil
+			}
+			return nil, nil
+		},
+		GetAPIKeyByHashFunc: func(ctx context.Context, hash string) (*domain.APIKey, error) {
+			for _, key := range keys {
+				if key.KeyHash == hash {
+					found := *key
+					return &found, nil
+				}
+			}
+			return nil, nil
+		},
+		SetAPIKeyExpiryFunc: func(ctx context.Context, id string, expiresAt *time.Time) error {
+			keys[id].ExpiresAt = expiresAt
+			return nil
+		},
+		RevokeAPIKeyFunc: func(ctx context.Context, id string) error {
+			now := time.Now()
+			keys[id].RevokedAt = &now
+			return nil
+		},
+	}
+	service := domain.NewAuthService(mRepo, nil)
+	h := &AuthHandler{service: service, hmacSecret: "secret"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /api_keys/{id}", h.UpdateAPIKey)
+	mux.HandleFunc("POST /api_keys/{id}/rotate", h.RotateAPIKey)
+	mux.HandleFunc("DELETE /api_keys/{id}", h.RevokeAPIKey)
+
+	token, err := jwtutil.GenerateToken("user_123", "test@example.com")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := call("PATCH", "/api_keys/key_1", `{"expires_at":"2001-01-01T00:00:00Z"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an expiry in the past, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := call("DELETE", "/api_keys/key_2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for another user's key, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := call("POST", "/api_keys/key_1/rotate", `{"grace_period_hours":1000}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a grace period over the maximum, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w := call("POST", "/api_keys/key_1/rotate", `{"grace_period_hours":2}`)
+	var resp GenerateAPIKeyResponse
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusCreated || resp.Key == "" || resp.RotatedFrom != "key_1" {
+		t.Fatalf("Expected a replacement key, got %d %+v", w.Code, resp)
+	}
+	if resp.Scopes != "payments:read" {
+		t.Errorf("Expected the replacement to keep the scopes, got %q", resp.Scopes)
+	}
+	old := keys["key_1"]
+	if old.ExpiresAt == nil || old.ExpiresAt.After(time.Now().Add(2*time.Hour)) {
+		t.Errorf("Expected the old key to expire within the grace period, got %v", old.ExpiresAt)
+	}
+	if key, _ := service.ValidateAPIKey(context.Background(), "hash_1"); key == nil {
+		t.Error("Expected the old key to work during the grace period")
+	}
+
+	if w := call("DELETE", "/api_keys/key_1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if key, _ := service.ValidateAPIKey(context.Background(), "hash_1"); key != nil {
+		t.Error("Expected the revoked key to be refused")
+	}
+}
+
+func TestAuthHandler_LoginTwoFactor(t *testing.T) {
+	secret, _ := totp.GenerateSecret()
+	enabledAt := time.Now()
+	tf := &domain.TwoFactor{UserID: "user_123", Secret: secret, EnabledAt: &enabledAt}
+	hash, _ := (&bcryptutil.BcryptUtilsImpl{}).GenerateHash("password123")
+	challenges := map[string]*domain.TwoFactorChallenge{}
+	recoveryCodes := map[string]bool{}
+	mRepo := &domain.MockRepository{
+		GetUserByEmailFunc: func(ctx context.Context, email string) (*domain.User, error) {
+			return &domain.User{ID: "user_123", Email: email, Password: hash}, nil
+		},
+		GetUserByIDFunc: func(ctx context.Context, id string) (*domain.User, error) {
+			return &domain.User{ID: id, Email: "test@example.com"}, nil
+		},
+		GetTwoFactorFunc: func(ctx context.Context, userID string) (*domain.TwoFactor, error) {
+			found := *tf
+			return &found, nil
+		},
+		UseTOTPStepFunc: func(ctx context.Context, userID string, step int64) (bool, error) {
+			if step <= tf.LastUsedStep {
+				return false, nil
+			}
+			tf.LastUsedStep = step
+			return true, nil
+		},
+		UseRecoveryCodeFunc: func(ctx context.Context, userID, codeHash string) (bool, error) {
+			if recoveryCodes[codeHash] {
+				delete(recoveryCodes, codeHash)
+				return true, nil
+			}
+			return false, nil
+		},
+		ReplaceRecoveryCodesFunc: func(ctx context.Context, userID string, codeHashes []string) error {
+			for _, h := range codeHashes {
+				recoveryCodes[h] = true
+			}
+			return nil
+		},
+		CreateTwoFactorChallengeFunc: func(ctx context.Context, c *domain.TwoFactorChallenge) error {
+			challenges[c.ID] = c
+			return nil
+		},
+		GetTwoFactorChallengeFunc: func(ctx context.Context, id string) (*domain.TwoFactorChallenge, error) {
+			return challenges[id], nil
+		},
+		AttemptTwoFactorChallengeFunc: func(ctx context.Context, id string) (int, error) {
+			challenges[id].Attempts++
+			return challenges[id].Attempts, nil
+		},
+		CompleteTwoFactorChallengeFunc: func(ctx context.Context, id string) (bool, error) {
+			now := time.Now()
+			challenges[id].CompletedAt = &now
+			return true, nil
+		},
+	}
+	service := domain.NewAuthService(mRepo, nil)
+	h := &AuthHandler{service: service}
+
+	login := func() TwoFactorChallengeResponse {
+		w := httptest.NewRecorder()
+		h.Login(w, httptest.NewRequest("POST", "/login", strings.NewReader(`{"email":"test@example.com","password":"password123"}`)))
+		var resp TwoFactorChallengeResponse
+		_ = json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusOK || !resp.TwoFactorRequired || resp.Method != domain.TwoFactorMethodTOTP {
+			t.Fatalf("Expected a totp challenge, got %d %+v", w.Code, resp)
+		}
+		return resp
+	}
+	verify := func(challengeID, code string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.LoginTwoFactor(w, httptest.NewRequest("POST", "/login/2fa", strings.NewReader(`{"challenge_id":"`+challengeID+`","code":"`+code+`"}`)))
+		return w
+	}
+
+	challenge := login()
+	if w := verify(challenge.ChallengeID, "000000x"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for a wrong code, got %d", http.StatusUnauthorized, w.Code)
+	}
+	code, _ := totp.CodeAt(secret, totp.Step(time.Now()))
+	w := verify(challenge.ChallengeID, code)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "refresh_token") {
+		t.Fatalf("Expected a session, got %d %s", w.Code, w.Body.String())
+	}
+	if w := verify(challenge.ChallengeID, code); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for a passed challenge, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	// Codes work once, recovery codes too
+	challenge = login()
+	if w := verify(challenge.ChallengeID, code); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for a reused code, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if _, err := service.RegenerateRecoveryCodes(context.Background(), "user_123", "wrong"); err == nil {
+		t.Fatal("Expected regenerating recovery codes to need a valid code")
+	}
+	recoveryCodes[service.HashString("seed")] = true
+	codes, err := service.RegenerateRecoveryCodes(context.Background(), "user_123", "seed")
+	if err != nil || len(codes) != domain.RecoveryCodeCount {
+		t.Fatalf("Expected %d recovery codes, got %v (%v)", domain.RecoveryCodeCount, codes, err)
+	}
+	if w := verify(challenge.ChallengeID, strings.ToUpper(codes[0])); w.Code != http.StatusOK {
+		t.Errorf("Expected a recovery code to log in, got %d %s", w.Code, w.Body.String())
+	}
+	challenge = login()
+	if w := verify(challenge.ChallengeID, codes[0]); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for a used recovery code, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestAuthHandler_Organizations(t *testing.T) {
+	users := map[string]*domain.User{
+		"owner_1":  {ID: "owner_1", Email: "owner@example.com"},
+		"invitee":  {ID: "invitee", Email: "new@example.com"},
+		"stranger": {ID: "stranger", Email: "other@example.com"},
+	}
+	members := map[string]*domain.Membership{"owner_1": {UserID: "owner_1", OrgID: "org_1", Role: domain.RoleOwner}}
+	invitations := map[string]*domain.Invitation{}
+	sessionOrgs := map[string]string{}
+	mRepo := &domain.MockRepository{
+		GetUserByIDFunc: func(ctx context.Context, id string) (*domain.User, error) {
+			return users[id], nil
+		},
+		GetUserByEmailFunc: func(ctx context.Context, email string) (*domain.User, error) {
+			return nil, nil
+		},
+		GetOrganizationFunc: func(ctx context.Context, id string) (*domain.Organization, error) {
+			return &domain.Organization{ID: id, Name: "Acme"}, nil
+		},
+		GetMembershipFunc: func(ctx context.Context, userID, orgID string) (*domain.Membership, error) {
+			return members[userID], nil
+		},
+		ListOrgMembersFunc: func(ctx context.Context, orgID string) ([]domain.Membership, error) {
+			var list []domain.Membership
+			for _, m := range members {
+				list = append(list, *m)
+			}
+			return list, nil
+		},
+		UpdateMemberRoleFunc: func(ctx context.Context, userID, orgID, role string) error {
+			members[userID].Role = role
+			return nil
+		},
+		CreateInvitationFunc: func(ctx context.Context, invitation *domain.Invitation) error {
+			invitations[invitation.TokenHash] = invitation
+			return nil
+		},
+		GetInvitationByTokenHashFunc: func(ctx context.Context, tokenHash string) (*domain.Invitation, error) {
+			return invitations[tokenHash], nil
+		},
+		AcceptInvitationFunc: func(ctx context.Context, id, userID string) (bool, error) {
+			for _, invitation := range invitations {
+				if invitation.ID == id {
+					now := time.Now()
+					invitation.AcceptedAt = &now
+					members[userID] = &domain.Membership{UserID: userID, OrgID: invitation.OrgID, Role: invitation.Role}
+				}
+			}
+			return true, nil
+		},
+		GetSessionFunc: func(ctx context.Context, id string) (*domain.Session, error) {
+			return &domain.Session{ID: id, UserID: "invitee", ExpiresAt: time.Now().Add(time.Hour)}, nil
+		},
+		SetSessionOrgFunc: func(ctx context.Context, id, orgID string) error {
+			sessionOrgs[id] = orgID
+			return nil
+		},
+	}
+	var rawToken string
+	publisher := publisherFunc(func(ctx context.Context, topic string, event interface{}) error {
+		data := event.(map[string]interface{})["data"].(map[string]string)
+		rawToken = data["token"]
+		return nil
+	})
+	h := &AuthHandler{service: domain.NewAuthService(mRepo, publisher)}
+
+	call := func(handler http.HandlerFunc, userID, method, target, body string, pathValues ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		token, _ := jwtutil.GenerateSessionToken(userID, users[userID].Email, "sess_"+userID)
+		req.Header.Set("Authorization", "Bearer "+token)
+		for i := 0; i+1 < len(pathValues); i += 2 {
+			req.SetPathValue(pathValues[i], pathValues[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	if w := call(h.InviteMember, "stranger", "POST", "/orgs/org_1/invitations", `{"email":"x@example.com"}`, "id", "org_1"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d inviting to another organization, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := call(h.InviteMember, "owner_1", "POST", "/orgs/org_1/invitations", `{"email":"New@Example.com","role":"developer"}`, "id", "org_1"); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if rawToken == "" {
+		t.Fatal("Expected the invitation to be emailed")
+	}
+
+	accept := `{"token":"` + rawToken + `"}`
+	if w := call(h.AcceptInvitation, "stranger", "POST", "/orgs/invitations/accept", accept); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d accepting someone else's invitation, got %d", http.StatusForbidden, w.Code)
+	}
+	if w := call(h.AcceptInvitation, "invitee", "POST", "/orgs/invitations/accept", accept); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if members["invitee"] == nil || members["invitee"].Role != domain.RoleDeveloper {
+		t.Fatalf("Expected the invitee to be a developer, got %+v", members["invitee"])
+	}
+	if w := call(h.AcceptInvitation, "invitee", "POST", "/orgs/invitations/accept", accept); w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d accepting twice, got %d", http.StatusConflict, w.Code)
+	}
+
+	// Developers cannot manage roles, and the last owner stays one
+	if w := call(h.UpdateMember, "invitee", "PATCH", "/", `{"role":"admin"}`, "id", "org_1", "user_id", "invitee"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for a developer changing roles, got %d", http.StatusForbidden, w.Code)
+	}
+	if w := call(h.UpdateMember, "owner_1", "PATCH", "/", `{"role":"admin"}`, "id", "org_1", "user_id", "owner_1"); w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d demoting the last owner, got %d", http.StatusConflict, w.Code)
+	}
+
+	w := call(h.SwitchOrganization, "invitee", "POST", "/orgs/switch", `{"org_id":"org_1"}`)
+	var resp SwitchOrganizationResponse
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || sessionOrgs["sess_invitee"] != "org_1" {
+		t.Fatalf("Expected the session to switch, got %d %+v", w.Code, sessionOrgs)
+	}
+	claims, err := jwtutil.ValidateToken(resp.Token)
+	if err != nil || claims.OrgID != "org_1" || claims.Role != domain.RoleDeveloper {
+		t.Errorf("Expected a token for org_1 as developer, got %+v (%v)", claims, err)
+	}
+	if w := call(h.SwitchOrganization, "stranger", "POST", "/orgs/switch", `{"org_id":"org_1"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d switching to another organization, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+type publisherFunc func(ctx context.Context, topic string, event interface{}) error
+
+func (f publisherFunc) Publish(ctx context.Context, topic string, event interface{}) error {
+	return f(ctx, topic, event)
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/sapliy/fintech-ecosystem/internal/flow"
 	flowDomain "github.com/sapliy/fintech-ecosystem/internal/flow/domain"
 	flowInfra "github.com/sapliy/fintech-ecosystem/internal/flow/infrastructure"
+	"github.com/sapliy/fintech-ecosystem/internal/policy"
 	zone "github.com/sapliy/fintech-ecosystem/internal/zone"
 	zoneDomain "github.com/sapliy/fintech-ecosystem/internal/zone/domain"
 	zoneInfra "github.com/sapliy/fintech-ecosystem/internal/zone/infrastructure"
@@ -145,8 +146,15 @@ func main() {
 	zoneService := zone.NewService(zoneRepo, providers)
 	templateService := zone.NewTemplateService(zoneService)
 
-	handler := &AuthHandler{service: authService, hmacSecret: hmacSecret, rdb: rdb}
+	handler := &AuthHandler{service: authService, hmacSecret: hmacSecret, rdb: rdb, zones: zoneService}
 	zoneHandler := &ZoneHandler{service: zoneService, templateService: templateService}
+	auditStore := policy.NewSQLAuditStore(db)
+	policyHandler := &PolicyHandler{
+		auth:    authService,
+		service: policy.NewPolicyService(policy.NewSQLPolicyStore(db), nil),
+		audit:   auditStore,
+		zones:   zoneService,
+	}
 
 	// Initialize Tracer
 	shutdown, err := observability.InitTracer(context.Background(), observability.Config{
@@ -185,8 +193,43 @@ func main() {
 
 	mux.HandleFunc("/register", handler.Register)
 	mux.HandleFunc("/login", handler.Login)
+	mux.HandleFunc("POST /login/2fa", handler.LoginTwoFactor)
+	mux.HandleFunc("GET /2fa", handler.GetTwoFactor)
+	mux.HandleFunc("POST /2fa/enroll", handler.EnrollTwoFactor)
+	mux.HandleFunc("POST /2fa/confirm", handler.ConfirmTwoFactor)
+	mux.HandleFunc("POST /2fa/disable", handler.DisableTwoFactor)
+	mux.HandleFunc("POST /2fa/recovery_codes", handler.RegenerateRecoveryCodes)
 	mux.HandleFunc("/organizations", handler.CreateOrganization)
+	mux.HandleFunc("PUT /organizations/{id}/two_factor", handler.SetOrgTwoFactor)
+	mux.HandleFunc("POST /orgs", handler.CreateOrganization)
+	mux.HandleFunc("GET /orgs", handler.ListOrganizations)
+	mux.HandleFunc("POST /orgs/switch", handler.SwitchOrganization)
+	mux.HandleFunc("POST /orgs/invitations/accept", handler.AcceptInvitation)
+	mux.HandleFunc("GET /orgs/{id}", handler.GetOrganization)
+	mux.HandleFunc("PUT /orgs/{id}/two_factor", handler.SetOrgTwoFactor)
+	mux.HandleFunc("GET /orgs/{id}/members", handler.ListMembers)
+	mux.HandleFunc("PATCH /orgs/{id}/members/{user_id}", handler.UpdateMember)
+	mux.HandleFunc("DELETE /orgs/{id}/members/{user_id}", handler.RemoveMember)
+	mux.HandleFunc("POST /orgs/{id}/invitations", handler.InviteMember)
+	mux.HandleFunc("GET /orgs/{id}/invitations", handler.ListInvitations)
+	mux.HandleFunc("DELETE /orgs/{id}/invitations/{invitation_id}", handler.RevokeInvitation)
+	mux.HandleFunc("POST /policy/policies", policyHandler.CreatePolicy)
+	mux.HandleFunc("GET /policy/policies", policyHandler.ListPolicies)
+	mux.HandleFunc("GET /policy/policies/{id}", policyHandler.GetPolicy)
+	mux.HandleFunc("PUT /policy/policies/{id}", policyHandler.UpdatePolicy)
+	mux.HandleFunc("DELETE /policy/policies/{id}", policyHandler.DeletePolicy)
+	mux.HandleFunc("GET /policy/audit", policyHandler.ListAuditLogs)
+	mux.HandleFunc("POST /policy/zone_roles", policyHandler.GrantZoneRole)
+	mux.HandleFunc("GET /policy/zone_roles", policyHandler.ListZoneRoles)
+	mux.HandleFunc("DELETE /policy/zone_roles/{id}", policyHandler.RevokeZoneRole)
 	mux.HandleFunc("/api_keys", handler.GenerateAPIKey)
+	mux.HandleFunc("GET /api_keys", handler.ListAPIKeys)
+	mux.HandleFunc("PATCH /api_keys/{id}", handler.UpdateAPIKey)
+	mux.HandleFunc("POST /api_keys/{id}/rotate", handler.RotateAPIKey)
+	mux.HandleFunc("DELETE /api_keys/{id}", handler.RevokeAPIKey)
+	mux.HandleFunc("/token/refresh", handler.RefreshToken)
+	mux.HandleFunc("GET /sessions", handler.ListSessions)
+	mux.HandleFunc("DELETE /sessions/{id}", handler.RevokeSession)
 	mux.HandleFunc("/oauth/token", handler.OAuthTokenHandler)
 	mux.HandleFunc("/oauth/authorize", handler.AuthorizeHandler)
 	mux.HandleFunc("/oauth/clients", handler.RegisterClientHandler)
@@ -278,15 +321,18 @@ func main() {
 		case http.MethodPost:
 			debugHandler.StartDebugSession(w, r)
 		case http.MethodGet:
-			if r.URL.Query().Get("session_id") != "" {
-				debugHandler.GetDebugSession(w, r)
-			} else {
-				debugHandler.GetDebugEvents(w, r)
-			}
+			debugHandler.GetDebugSession(w, r)
 		default:
 			jsonutil.WriteErrorJSON(w, "Method not allowed")
 		}
 	})
+	mux.HandleFunc("/debug/events", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			debugHandler.GetDebugEvents(w, r)
+		} else {
+			jsonutil.WriteErrorJSON(w, "Method not allowed")
+		}
+	})
 	mux.HandleFunc("/debug/sessions/end", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
 			debugHandler.EndDebugSession(w, r)
@@ -320,6 +366,28 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	// Revoke API keys past their expiry, rotated out ones included
+	expiryInterval := time.Minute
+	if v := os.Getenv("API_KEY_EXPIRY_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			expiryInterval = d
+		} else {
+			logger.Warn("Invalid API_KEY_EXPIRY_INTERVAL, using default", "value", v)
+		}
+	}
+	go authService.RunAPIKeyExpiry(ctx, expiryInterval)
+
+	// Policy decisions are kept for POLICY_AUDIT_RETENTION, a year by default
+	auditRetention := 365 * 24 * time.Hour
+	if v := os.Getenv("POLICY_AUDIT_RETENTION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			auditRetention = d
+		} else {
+			logger.Warn("Invalid POLICY_AUDIT_RETENTION, using default", "value", v)
+		}
+	}
+	go policy.NewAuditPruner(auditStore, auditRetention, time.Hour).Start(ctx)
+
 	srv := &http.Server{
 		Addr:    ":" + port,
 		Handler: router,
@@ -341,7 +409,7 @@ func main() {
 	s := grpc.NewServer(
 		grpc.UnaryInterceptor(monitoring.UnaryServerInterceptor("auth")),
 	)
-	pb.RegisterAuthServiceServer(s, NewAuthGRPCServer(authService))
+	pb.RegisterAuthServiceServer(s, NewAuthGRPCServer(authService, zoneService))
 
 	logger.Info("Auth service gRPC starting", "port", ":50051")
 
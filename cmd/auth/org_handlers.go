@@ -0,0 +1,322 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/sapliy/fintech-ecosystem/internal/auth/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+	"github.com/sapliy/fintech-ecosystem/pkg/jwtutil"
+)
+
+// MemberRoleRequest defines the payload for changing a member's role.
+type MemberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// InviteMemberRequest defines the payload for inviting someone to an
+// organization.
+type InviteMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"` // "member" by default
+}
+
+// AcceptInvitationRequest defines the payload for accepting an invitation.
+type AcceptInvitationRequest struct {
+	Token string `json:"token"` // From the link of the invitation email
+}
+
+// SwitchOrganizationRequest defines the payload for switching the
+// organization a session acts for.
+type SwitchOrganizationRequest struct {
+	OrgID string `json:"org_id"` // Empty to act for none
+}
+
+// SwitchOrganizationResponse carries an access token acting for the
+// organization. The session's refresh token keeps working and renews
+// tokens for it too.
+type SwitchOrganizationResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int    `json:"expires_in"`
+	SessionID string `json:"session_id"`
+	OrgID     string `json:"org_id,omitempty"`
+	Role      string `json:"role,omitempty"`
+}
+
+// extractClaimsFromToken returns the claims of the caller's access token
+func extractClaimsFromToken(r *http.Request) (*jwtutil.Claims, error) {
+	tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, errors.New("missing token")
+	}
+	claims, err := jwtutil.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID == "" {
+		return nil, errors.New("token without user")
+	}
+	return claims, nil
+}
+
+// ListOrganizations answers the caller's organizations, with their role in
+// each
+func (h *AuthHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	orgs, err := h.service.ListUserOrganizations(r.Context(), userID)
+	if err != nil {
+		log.Printf("ListOrganizations: Failed to list organizations of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list organizations"})
+		return
+	}
+	if orgs == nil {
+		orgs = []domain.UserOrganization{}
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": orgs})
+}
+
+// GetOrganization answers one of the caller's organizations
+func (h *AuthHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	org, err := h.service.GetMemberOrganization(r.Context(), userID, r.PathValue("id"))
+	if h.writeOrgError(w, err) {
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, org)
+}
+
+// ListMembers answers the members of one of the caller's organizations
+func (h *AuthHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	members, err := h.service.ListMembers(r.Context(), userID, r.PathValue("id"))
+	if h.writeOrgError(w, err) {
+		return
+	}
+	if members == nil {
+		members = []domain.Membership{}
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": members})
+}
+
+// UpdateMember changes the role of a member of the organization
+func (h *AuthHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req MemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+
+	orgID, memberID := r.PathValue("id"), r.PathValue("user_id")
+	if h.writeOrgError(w, h.service.ChangeMemberRole(r.Context(), userID, orgID, memberID, req.Role)) {
+		return
+	}
+
+	log.Printf("UpdateMember: User %s made %s %s of organization %s", userID, memberID, req.Role, orgID)
+	jsonutil.WriteJSON(w, http.StatusOK, domain.Membership{UserID: memberID, OrgID: orgID, Role: req.Role})
+}
+
+// RemoveMember removes a member from the organization, or lets the caller
+// leave it
+func (h *AuthHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	orgID, memberID := r.PathValue("id"), r.PathValue("user_id")
+	if h.writeOrgError(w, h.service.RemoveOrgMember(r.Context(), userID, orgID, memberID)) {
+		return
+	}
+
+	log.Printf("RemoveMember: User %s removed %s from organization %s", userID, memberID, orgID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// InviteMember emails an invitation to join the organization
+func (h *AuthHandler) InviteMember(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	user, err := h.service.GetUserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req InviteMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if !strings.Contains(req.Email, "@") {
+		jsonutil.WriteErrorJSON(w, "A valid email is required")
+		return
+	}
+
+	orgID := r.PathValue("id")
+	invitation, err := h.service.InviteMember(r.Context(), user, orgID, req.Email, req.Role)
+	if h.writeOrgError(w, err) {
+		return
+	}
+
+	log.Printf("InviteMember: User %s invited %s to organization %s", userID, invitation.Email, orgID)
+	jsonutil.WriteJSON(w, http.StatusCreated, invitation)
+}
+
+// ListInvitations answers the organization's pending invitations
+func (h *AuthHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	invitations, err := h.service.ListInvitations(r.Context(), userID, r.PathValue("id"))
+	if h.writeOrgError(w, err) {
+		return
+	}
+	if invitations == nil {
+		invitations = []domain.Invitation{}
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": invitations})
+}
+
+// RevokeInvitation cancels a pending invitation
+func (h *AuthHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	orgID, id := r.PathValue("id"), r.PathValue("invitation_id")
+	if h.writeOrgError(w, h.service.RevokeInvitation(r.Context(), userID, orgID, id)) {
+		return
+	}
+
+	log.Printf("RevokeInvitation: User %s revoked invitation %s of organization %s", userID, id, orgID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvitation makes the caller a member of the organization that
+// invited them
+func (h *AuthHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	user, err := h.service.GetUserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if req.Token == "" {
+		jsonutil.WriteErrorJSON(w, "token is required")
+		return
+	}
+
+	membership, err := h.service.AcceptInvitation(r.Context(), user, req.Token)
+	if h.writeOrgError(w, err) {
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, membership)
+}
+
+// SwitchOrganization makes the caller's session act for one of their
+// organizations, answering an access token that carries it and their role
+func (h *AuthHandler) SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	claims, err := extractClaimsFromToken(r)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req SwitchOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+
+	membership, err := h.service.SwitchOrganization(r.Context(), claims.UserID, claims.SessionID, req.OrgID)
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	if h.writeOrgError(w, err) {
+		return
+	}
+
+	var role string
+	if membership != nil {
+		role = membership.Role
+	}
+	token, err := jwtutil.GenerateOrgSessionToken(claims.UserID, claims.Email, claims.SessionID, req.OrgID, role)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, "Failed to generate token")
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, SwitchOrganizationResponse{
+		Token:     token,
+		ExpiresIn: int(jwtutil.SessionTokenTTL.Seconds()),
+		SessionID: claims.SessionID,
+		OrgID:     req.OrgID,
+		Role:      role,
+	})
+}
+
+// writeOrgError answers a failed organization operation, reporting whether
+// there was an error
+func (h *AuthHandler) writeOrgError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, domain.ErrInvalidRole):
+		jsonutil.WriteErrorJSON(w, err.Error())
+	case errors.Is(err, domain.ErrNotOrgMember), errors.Is(err, domain.ErrInvitationNotFound):
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrNotOrgAdmin), errors.Is(err, domain.ErrRoleAboveOwn),
+		errors.Is(err, domain.ErrInvitationEmailMismatch):
+		jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrLastOwner), errors.Is(err, domain.ErrAlreadyMember),
+		errors.Is(err, domain.ErrInvitationClosed):
+		jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		log.Printf("Organization operation failed: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return true
+}
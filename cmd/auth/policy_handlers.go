@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/auth/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/policy"
+	"github.com/sapliy/fintech-ecosystem/internal/zone"
+	zoneDomain "github.com/sapliy/fintech-ecosystem/internal/zone/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// PolicyHandler manages the custom policies and zone roles of
+// organizations and answers the decisions made for them. Only their admins
+// and owners use it.
+type PolicyHandler struct {
+	auth    *domain.AuthService
+	service *policy.PolicyService
+	audit   policy.AuditStore
+	zones   *zone.Service
+}
+
+// PolicyRequest defines the payload for creating or replacing a policy.
+type PolicyRequest struct {
+	OrgID       string             `json:"org_id"`  // The token's organization by default
+	ZoneID      string             `json:"zone_id"` // Every zone if empty
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Effect      string             `json:"effect"` // "allow" or "deny"
+	Roles       []policy.Role      `json:"roles"`  // Any role if empty
+	Actions     []policy.Action    `json:"actions"`
+	Conditions  []policy.Condition `json:"conditions"`
+	Enabled     *bool              `json:"enabled"` // True by default
+}
+
+// CreatePolicy adds a policy to the organization
+func (h *PolicyHandler) CreatePolicy(w http.ResponseWriter, r *http.Request) {
+	claims, err := extractClaimsFromToken(r)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req PolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	orgID := req.OrgID
+	if orgID == "" {
+		orgID = claims.OrgID
+	}
+	if !h.requireAdmin(w, r, claims.UserID, orgID) {
+		return
+	}
+
+	p := &policy.Policy{OrgID: orgID, CreatedBy: claims.UserID, Enabled: true}
+	req.apply(p)
+	if h.writePolicyError(w, h.service.CreatePolicy(r.Context(), p)) {
+		return
+	}
+
+	log.Printf("CreatePolicy: User %s created policy %s in organization %s", claims.UserID, p.ID, orgID)
+	jsonutil.WriteJSON(w, http.StatusCreated, p)
+}
+
+// ListPolicies answers the organization's policies
+func (h *PolicyHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	claims, err := extractClaimsFromToken(r)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	orgID := r.URL.Query().Get("org_id")
+	if orgID == "" {
+		orgID = claims.OrgID
+	}
+	if !h.requireAdmin(w, r, claims.UserID, orgID) {
+		return
+	}
+
+	policies, err := h.service.ListPolicies(r.Context(), orgID)
+	if h.writePolicyError(w, err) {
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": policies})
+}
+
+// GetPolicy answers one of the organization's policies
+func (h *PolicyHandler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	claims, err := extractClaimsFromToken(r)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	p, ok := h.adminPolicy(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, p)
+}
+
+// UpdatePolicy replaces one of the organization's policies
+func (h *PolicyHandler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	claims, err := extractClaimsFromToken(r)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req PolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	p, ok := h.adminPolicy(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+
+	req.apply(p)
+	if h.writePolicyError(w, h.service.UpdatePolicy(r.Context(), p)) {
+		return
+	}
+
+	log.Printf("UpdatePolicy: User %s updated policy %s", claims.UserID, p.ID)
+	jsonutil.WriteJSON(w, http.StatusOK, p)
+}
+
+// DeletePolicy removes one of the organization's policies
+func (h *PolicyHandler) DeletePolicy(w http.ResponseWriter, r *http.Request) {
+	claims, err := extractClaimsFromToken(r)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	p, ok := h.adminPolicy(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+	if h.writePolicyError(w, h.service.DeletePolicy(r.Context(), p.ID)) {
+		return
+	}
+
+	log.Printf("DeletePolicy: User %s deleted policy %s", claims.UserID, p.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListAuditLogs answers the organization's policy decisions, newest first,
+// filtered by user_id, action and a from/to time range (RFC 3339)
+func (h *PolicyHandler) ListAuditLogs(w http.ResponseWriter, r *http.Request) {
+	claims, err := extractClaimsFromToken(r)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	query := r.URL.Query()
+	filter := policy.AuditFilter{
+		OrgID:  query.Get("org_id"),
+		UserID: query.Get("user_id"),
+		Action: policy.Action(query.Get("action")),
+	}
+	if filter.OrgID == "" {
+		filter.OrgID = claims.OrgID
+	}
+	for name, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := query.Get(name); v != "" {
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				jsonutil.WriteErrorJSON(w, name+" must be an RFC 3339 time")
+				return
+			}
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			jsonutil.WriteErrorJSON(w, "limit must be a number")
+			return
+		}
+	}
+	if !h.requireAdmin(w, r, claims.UserID, filter.OrgID) {
+		return
+	}
+
+	logs, err := policy.ListAuditLogs(r.Context(), h.audit, filter)
+	if h.writePolicyError(w, err) {
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": logs})
+}
+
+// ZoneRoleRequest defines the payload for granting a role in a zone.
+type ZoneRoleRequest struct {
+	ZoneID string      `json:"zone_id"`
+	UserID string      `json:"user_id"` // A member of the zone's organization
+	Role   policy.Role `json:"role"`    // admin, finance, developer or viewer
+}
+
+// GrantZoneRole grants a member of the organization a role in one of its
+// zones
+func (h *PolicyHandler) GrantZoneRole(w http.ResponseWriter, r *http.Request) {
+	claims, err := extractClaimsFromToken(r)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req ZoneRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	z, ok := h.adminZone(w, r, claims.UserID, req.ZoneID)
+	if !ok {
+		return
+	}
+	member, err := h.auth.HasPermission(r.Context(), req.UserID, z.OrgID, domain.RoleMember)
+	if err != nil {
+		log.Printf("GrantZoneRole: Failed to check the membership of %s in %s: %v", req.UserID, z.OrgID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return
+	}
+	if !member {
+		jsonutil.WriteErrorJSON(w, "user_id must be a member of the zone's organization")
+		return
+	}
+
+	g := &policy.ZoneRole{OrgID: z.OrgID, ZoneID: z.ID, UserID: req.UserID, Role: req.Role, GrantedBy: claims.UserID}
+	if h.writePolicyError(w, h.service.GrantZoneRole(r.Context(), g)) {
+		return
+	}
+
+	log.Printf("GrantZoneRole: User %s granted %s the role %s in zone %s", claims.UserID, g.UserID, g.Role, g.ZoneID)
+	jsonutil.WriteJSON(w, http.StatusCreated, g)
+}
+
+// ListZoneRoles answers the roles granted in the zone of zone_id
+func (h *PolicyHandler) ListZoneRoles(w http.ResponseWriter, r *http.Request) {
+	claims, err := extractClaimsFromToken(r)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	z, ok := h.adminZone(w, r, claims.UserID, r.URL.Query().Get("zone_id"))
+	if !ok {
+		return
+	}
+	grants, err := h.service.ListZoneRoles(r.Context(), z.ID)
+	if h.writePolicyError(w, err) {
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": grants})
+}
+
+// RevokeZoneRole removes a role granted in one of the organization's zones
+func (h *PolicyHandler) RevokeZoneRole(w http.ResponseWriter, r *http.Request) {
+	claims, err := extractClaimsFromToken(r)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	g, err := h.service.GetZoneRole(r.Context(), r.PathValue("id"))
+	if h.writePolicyError(w, err) {
+		return
+	}
+	if !h.requireAdmin(w, r, claims.UserID, g.OrgID) {
+		return
+	}
+	if h.writePolicyError(w, h.service.RevokeZoneRole(r.Context(), g.ID)) {
+		return
+	}
+
+	log.Printf("RevokeZoneRole: User %s revoked the role %s of %s in zone %s", claims.UserID, g.Role, g.UserID, g.ZoneID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apply sets the policy's fields from the request, keeping it enabled
+// unless asked otherwise
+func (req *PolicyRequest) apply(p *policy.Policy) {
+	p.ZoneID = req.ZoneID
+	p.Name = req.Name
+	p.Description = req.Description
+	p.Effect = req.Effect
+	p.Roles = req.Roles
+	p.Actions = req.Actions
+	p.Conditions = req.Conditions
+	if req.Enabled != nil {
+		p.Enabled = *req.Enabled
+	}
+}
+
+// requireAdmin checks the user administers the organization, answering
+// the request when they do not
+func (h *PolicyHandler) requireAdmin(w http.ResponseWriter, r *http.Request, userID, orgID string) bool {
+	if orgID == "" {
+		jsonutil.WriteErrorJSON(w, "org_id is required")
+		return false
+	}
+	allowed, err := h.auth.HasPermission(r.Context(), userID, orgID, domain.RoleAdmin)
+	if err != nil {
+		log.Printf("Policy: Failed to check the role of %s in %s: %v", userID, orgID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return false
+	}
+	if !allowed {
+		jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": domain.ErrNotOrgAdmin.Error()})
+		return false
+	}
+	return true
+}
+
+// adminPolicy loads the policy of the path, answering the request unless
+// the user administers its organization. Policies of every organization
+// are managed by operators in the database.
+func (h *PolicyHandler) adminPolicy(w http.ResponseWriter, r *http.Request, userID string) (*policy.Policy, bool) {
+	p, err := h.service.GetPolicy(r.Context(), r.PathValue("id"))
+	if err == nil && p.OrgID == "" {
+		err = policy.ErrPolicyNotFound
+	}
+	if h.writePolicyError(w, err) {
+		return nil, false
+	}
+	if !h.requireAdmin(w, r, userID, p.OrgID) {
+		return nil, false
+	}
+	return p, true
+}
+
+// adminZone loads the zone, answering the request unless the user
+// administers its organization
+func (h *PolicyHandler) adminZone(w http.ResponseWriter, r *http.Request, userID, zoneID string) (*zoneDomain.Zone, bool) {
+	if zoneID == "" {
+		jsonutil.WriteErrorJSON(w, "zone_id is required")
+		return nil, false
+	}
+	z, err := h.zones.GetZone(r.Context(), zoneID)
+	if err != nil && !errors.Is(err, zoneDomain.ErrZoneNotFound) {
+		log.Printf("Policy: Failed to get zone %s: %v", zoneID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+		return nil, false
+	}
+	if z == nil {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": zoneDomain.ErrZoneNotFound.Error()})
+		return nil, false
+	}
+	if !h.requireAdmin(w, r, userID, z.OrgID) {
+		return nil, false
+	}
+	return z, true
+}
+
+// writePolicyError answers a failed policy operation, reporting whether
+// there was an error
+func (h *PolicyHandler) writePolicyError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, policy.ErrInvalidPolicy), errors.Is(err, policy.ErrInvalidCondition),
+		errors.Is(err, policy.ErrInvalidAuditRange), errors.Is(err, policy.ErrInvalidZoneRole):
+		jsonutil.WriteErrorJSON(w, err.Error())
+	case errors.Is(err, policy.ErrPolicyNotFound), errors.Is(err, policy.ErrZoneRoleNotFound):
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, policy.ErrZoneRoleExists):
+		jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		log.Printf("Policy operation failed: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return true
+}
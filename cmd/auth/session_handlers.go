@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/sapliy/fintech-ecosystem/internal/auth/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+	"github.com/sapliy/fintech-ecosystem/pkg/jwtutil"
+)
+
+// RefreshRequest defines the payload for refreshing a session.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// clientIP returns the caller's address, as forwarded by the gateway
+func clientIP(r *http.Request) string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		return strings.TrimSpace(strings.Split(xff, ",")[0])
+	}
+	return r.RemoteAddr
+}
+
+// startSession logs the user in: it starts a session and answers its
+// access and refresh tokens.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, user *domain.User) {
+	session, refreshToken, err := h.service.CreateSession(r.Context(), user.ID, r.UserAgent(), clientIP(r))
+	if err != nil {
+		log.Printf("Failed to create session for user %s: %v", user.ID, err)
+		jsonutil.WriteErrorJSON(w, "Failed to create session")
+		return
+	}
+	token, err := jwtutil.GenerateSessionToken(user.ID, user.Email, session.ID)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, "Failed to generate token")
+		return
+	}
+
+	// Hide password hash in response
+	user.Password = ""
+
+	jsonutil.WriteJSON(w, http.StatusOK, LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(jwtutil.SessionTokenTTL.Seconds()),
+		SessionID:    session.ID,
+		User:         user,
+	})
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. The one presented is rotated out.
+func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonutil.WriteErrorJSON(w, "Method not allowed")
+		return
+	}
+
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if req.RefreshToken == "" {
+		jsonutil.WriteErrorJSON(w, "refresh_token is required")
+		return
+	}
+
+	session, refreshToken, err := h.service.RefreshSession(r.Context(), req.RefreshToken)
+	if errors.Is(err, domain.ErrInvalidRefreshToken) || errors.Is(err, domain.ErrRefreshTokenReused) {
+		if errors.Is(err, domain.ErrRefreshTokenReused) {
+			h.revokeAccessTokens(r, session)
+		}
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("RefreshToken: Failed to refresh session: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to refresh session"})
+		return
+	}
+
+	user, err := h.service.GetUserByID(r.Context(), session.UserID)
+	if err != nil || user == nil {
+		log.Printf("RefreshToken: Failed to get user %s: %v", session.UserID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to refresh session"})
+		return
+	}
+	// Tokens keep acting for the organization the session switched to
+	orgID, role, err := h.service.SessionOrgRole(r.Context(), session)
+	if err != nil {
+		log.Printf("RefreshToken: Failed to get organization of session %s: %v", session.ID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to refresh session"})
+		return
+	}
+	token, err := jwtutil.GenerateOrgSessionToken(user.ID, user.Email, session.ID, orgID, role)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, "Failed to generate token")
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(jwtutil.SessionTokenTTL.Seconds()),
+		SessionID:    session.ID,
+		OrgID:        orgID,
+		Role:         role,
+	})
+}
+
+// ListSessions answers the caller's active sessions
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	sessions, err := h.service.ListSessions(r.Context(), userID)
+	if err != nil {
+		log.Printf("ListSessions: Failed to list sessions of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list sessions"})
+		return
+	}
+	if sessions == nil {
+		sessions = []domain.Session{}
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": sessions})
+}
+
+// RevokeSession ends one of the caller's sessions, e.g. one whose tokens
+// leaked. Its refresh token stops working at once, and so do its access
+// tokens at the gateway.
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	id := r.PathValue("id")
+	session, err := h.service.GetSession(r.Context(), userID, id)
+	if err == nil {
+		err = h.service.RevokeSession(r.Context(), userID, id)
+	}
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("RevokeSession: Failed to revoke session %s: %v", id, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to revoke session"})
+		return
+	}
+	h.revokeAccessTokens(r, session)
+
+	log.Printf("RevokeSession: User %s revoked session %s", userID, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAccessTokens tells the gateway to refuse the session's access
+// tokens until they expire
+func (h *AuthHandler) revokeAccessTokens(r *http.Request, session *domain.Session) {
+	if h.rdb == nil || session == nil {
+		return
+	}
+	if err := h.rdb.Set(r.Context(), jwtutil.RevokedSessionKey(session.ID), "1", jwtutil.SessionTokenTTL).Err(); err != nil {
+		log.Printf("Failed to revoke access tokens of session %s: %v", session.ID, err)
+	}
+}
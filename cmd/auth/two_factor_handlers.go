@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/auth/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// TwoFactorChallengeResponse answers a login whose password was right but
+// that needs a second factor, to be sent to /login/2fa.
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeID       string    `json:"challenge_id"`
+	Method            string    `json:"method"` // "totp", or "email" for a security code sent by email
+	ExpiresAt         time.Time `json:"expires_at"`
+	// EnrollmentRequired is set for users whose organization requires
+	// two-factor authentication but who have not enrolled yet
+	EnrollmentRequired bool `json:"enrollment_required,omitempty"`
+}
+
+// TwoFactorLoginRequest defines the payload for completing a login.
+type TwoFactorLoginRequest struct {
+	ChallengeID string `json:"challenge_id"`
+	Code        string `json:"code"` // From the authenticator app or the email, or a recovery code
+}
+
+// TwoFactorCodeRequest defines the payload of requests confirmed with a
+// code of the user's authenticator app or a recovery code.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorEnrollmentResponse carries the secret to add to an
+// authenticator app.
+type TwoFactorEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"` // Render as a QR code
+}
+
+// RecoveryCodesResponse carries recovery codes, shown only once.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// OrgTwoFactorRequest defines the payload for an organization's two-factor
+// requirement.
+type OrgTwoFactorRequest struct {
+	Required bool `json:"required"`
+}
+
+// challengeTwoFactor answers the second factor challenge of the login, if
+// the user needs one, and reports whether it did
+func (h *AuthHandler) challengeTwoFactor(w http.ResponseWriter, r *http.Request, user *domain.User) bool {
+	challenge, err := h.service.StartTwoFactorChallenge(r.Context(), user)
+	if err != nil {
+		log.Printf("Failed to start two-factor challenge for user %s: %v", user.ID, err)
+		jsonutil.WriteErrorJSON(w, "Internal server error")
+		return true
+	}
+	if challenge == nil {
+		return false
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, TwoFactorChallengeResponse{
+		TwoFactorRequired:  true,
+		ChallengeID:        challenge.ID,
+		Method:             challenge.Method,
+		ExpiresAt:          challenge.ExpiresAt,
+		EnrollmentRequired: challenge.Method == domain.TwoFactorMethodEmail,
+	})
+	return true
+}
+
+// LoginTwoFactor completes a login with its second factor
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if req.ChallengeID == "" || req.Code == "" {
+		jsonutil.WriteErrorJSON(w, "challenge_id and code are required")
+		return
+	}
+
+	userID, err := h.service.VerifyTwoFactorChallenge(r.Context(), req.ChallengeID, req.Code)
+	if errors.Is(err, domain.ErrInvalidTwoFactorCode) || errors.Is(err, domain.ErrTwoFactorChallengeClosed) {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("LoginTwoFactor: Failed to verify challenge %s: %v", req.ChallengeID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to verify code"})
+		return
+	}
+
+	user, err := h.service.GetUserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		log.Printf("LoginTwoFactor: Failed to get user %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to log in"})
+		return
+	}
+
+	log.Printf("Login: Success for user %s with two-factor", user.Email)
+	h.startSession(w, r, user)
+}
+
+// GetTwoFactor answers the caller's two-factor status
+func (h *AuthHandler) GetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	status, err := h.service.GetTwoFactorStatus(r.Context(), userID)
+	if err != nil {
+		log.Printf("GetTwoFactor: Failed to get two-factor status of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get two-factor status"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, status)
+}
+
+// EnrollTwoFactor provisions a TOTP secret for the caller. Two-factor
+// authentication is on once a code of it is confirmed.
+func (h *AuthHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	user, err := h.service.GetUserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	secret, uri, err := h.service.BeginTwoFactorEnrollment(r.Context(), user)
+	if errors.Is(err, domain.ErrTwoFactorAlreadyEnabled) {
+		jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("EnrollTwoFactor: Failed to enroll user %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to start enrollment"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, TwoFactorEnrollmentResponse{Secret: secret, OTPAuthURI: uri})
+}
+
+// ConfirmTwoFactor enables two-factor authentication with a first code of
+// the caller's app, answering their recovery codes
+func (h *AuthHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	h.withTwoFactorCode(w, r, func(userID, code string) {
+		codes, err := h.service.ConfirmTwoFactorEnrollment(r.Context(), userID, code)
+		if h.writeTwoFactorError(w, err) {
+			return
+		}
+		log.Printf("ConfirmTwoFactor: User %s enabled two-factor", userID)
+		jsonutil.WriteJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+	})
+}
+
+// DisableTwoFactor turns the caller's two-factor authentication off
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	h.withTwoFactorCode(w, r, func(userID, code string) {
+		if h.writeTwoFactorError(w, h.service.DisableTwoFactor(r.Context(), userID, code)) {
+			return
+		}
+		log.Printf("DisableTwoFactor: User %s disabled two-factor", userID)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	h.withTwoFactorCode(w, r, func(userID, code string) {
+		codes, err := h.service.RegenerateRecoveryCodes(r.Context(), userID, code)
+		if h.writeTwoFactorError(w, err) {
+			return
+		}
+		jsonutil.WriteJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+	})
+}
+
+// SetOrgTwoFactor requires, or stops requiring, two-factor authentication
+// of the organization's members
+func (h *AuthHandler) SetOrgTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req OrgTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+
+	orgID := r.PathValue("id")
+	org, err := h.service.SetOrgTwoFactorRequired(r.Context(), userID, orgID, req.Required)
+	if errors.Is(err, domain.ErrNotOrgAdmin) {
+		jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil || org == nil {
+		log.Printf("SetOrgTwoFactor: Failed to update organization %s: %v", orgID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update organization"})
+		return
+	}
+
+	log.Printf("SetOrgTwoFactor: User %s set two-factor required=%t for organization %s", userID, req.Required, orgID)
+	jsonutil.WriteJSON(w, http.StatusOK, org)
+}
+
+// withTwoFactorCode authenticates the caller and reads the code of their
+// request
+func (h *AuthHandler) withTwoFactorCode(w http.ResponseWriter, r *http.Request, next func(userID, code string)) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil || userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if req.Code == "" {
+		jsonutil.WriteErrorJSON(w, "code is required")
+		return
+	}
+	next(userID, req.Code)
+}
+
+// writeTwoFactorError answers a failed two-factor operation, reporting
+// whether there was an error
+func (h *AuthHandler) writeTwoFactorError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, domain.ErrInvalidTwoFactorCode):
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrTwoFactorNotEnrolled), errors.Is(err, domain.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, domain.ErrTwoFactorRequiredByOrg):
+		jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		log.Printf("Two-factor operation failed: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal server error"})
+	}
+	return true
+}
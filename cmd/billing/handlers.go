@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/sapliy/fintech-ecosystem/internal/billing/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/billing/service"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// BillingHandler serves the subscription REST API. The gateway forwards
+// /v1/billing/* here with the prefix stripped and the caller in X-User-ID.
+type BillingHandler struct {
+	service *domain.BillingService
+	worker  *service.SubscriptionWorker
+}
+
+type CreateSubscriptionRequest struct {
+	PlanID          string `json:"plan_id"`
+	PaymentMethodID string `json:"payment_method_id"`
+}
+
+type ChangePlanRequest struct {
+	PlanID string `json:"plan_id"`
+}
+
+type CreatePlanRequest struct {
+	Name     string `json:"name"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Interval string `json:"interval"`
+}
+
+// SubscriptionResponse returns a subscription with the invoice the request
+// raised, if any
+type SubscriptionResponse struct {
+	Subscription *domain.Subscription `json:"subscription"`
+	Invoice      *domain.Invoice      `json:"invoice,omitempty"`
+}
+
+func requireUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+		return "", false
+	}
+	return userID, true
+}
+
+// HandlePlans lists plans, and lets organization owners and admins add them
+func (h *BillingHandler) HandlePlans(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireUser(w, r); !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		plans, err := h.service.ListPlans(r.Context())
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		if plans == nil {
+			plans = []*domain.Plan{}
+		}
+		jsonutil.WriteJSON(w, http.StatusOK, plans)
+	case http.MethodPost:
+		if role := r.Header.Get("X-Role"); role != "owner" && role != "admin" {
+			jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": "Only owners and admins can create plans"})
+			return
+		}
+		var req CreatePlanRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonutil.WriteErrorJSON(w, "Invalid request body")
+			return
+		}
+		plan := &domain.Plan{Name: req.Name, Amount: req.Amount, Currency: strings.ToUpper(req.Currency), Interval: req.Interval}
+		if err := h.service.CreatePlan(r.Context(), plan); err != nil {
+			h.writeError(w, err)
+			return
+		}
+		jsonutil.WriteJSON(w, http.StatusCreated, plan)
+	default:
+		jsonutil.WriteErrorJSON(w, "Method not allowed")
+	}
+}
+
+// HandleSubscriptions serves GET /subscriptions and POST /subscriptions
+func (h *BillingHandler) HandleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		subs, err := h.service.ListSubscriptions(r.Context(), userID)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		if subs == nil {
+			subs = []*domain.Subscription{}
+		}
+		jsonutil.WriteJSON(w, http.StatusOK, subs)
+	case http.MethodPost:
+		h.createSubscription(w, r, userID)
+	default:
+		jsonutil.WriteErrorJSON(w, "Method not allowed")
+	}
+}
+
+// createSubscription subscribes the caller to a plan and charges the first
+// invoice straight away. A declined first charge leaves the subscription
+// incomplete, retried on the dunning schedule.
+func (h *BillingHandler) createSubscription(w http.ResponseWriter, r *http.Request, userID string) {
+	var req CreateSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if req.PlanID == "" || req.PaymentMethodID == "" {
+		jsonutil.WriteErrorJSON(w, "plan_id and payment_method_id are required")
+		return
+	}
+
+	sub, inv, err := h.service.CreateSubscription(r.Context(), userID, r.Header.Get("X-Org-ID"), req.PlanID, req.PaymentMethodID)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	sub = h.charge(r, sub, inv)
+
+	jsonutil.WriteJSON(w, http.StatusCreated, SubscriptionResponse{Subscription: sub, Invoice: inv})
+}
+
+// HandleSubscription serves GET /subscriptions/{id}, GET
+// /subscriptions/{id}/invoices and the POST /subscriptions/{id}/change_plan
+// and /cancel actions
+func (h *BillingHandler) HandleSubscription(w http.ResponseWriter, r *http.Request) {
+	// parts: ["", "subscriptions", "{id}"] or ["", "subscriptions", "{id}", "{action}"]
+	pathParts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(pathParts) < 3 || len(pathParts) > 4 {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Not Found"})
+		return
+	}
+	id := pathParts[2]
+	action := ""
+	if len(pathParts) == 4 {
+		action = pathParts[3]
+	}
+
+	userID, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		sub, err := h.service.GetSubscription(r.Context(), userID, id)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		jsonutil.WriteJSON(w, http.StatusOK, sub)
+	case r.Method == http.MethodGet && action == "invoices":
+		invoices, err := h.service.ListInvoices(r.Context(), userID, id)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		if invoices == nil {
+			invoices = []*domain.Invoice{}
+		}
+		jsonutil.WriteJSON(w, http.StatusOK, invoices)
+	case r.Method == http.MethodPost && action == "change_plan":
+		var req ChangePlanRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PlanID == "" {
+			jsonutil.WriteErrorJSON(w, "plan_id is required")
+			return
+		}
+		sub, inv, err := h.service.ChangePlan(r.Context(), userID, id, req.PlanID)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		if inv != nil {
+			sub = h.charge(r, sub, inv)
+		}
+		jsonutil.WriteJSON(w, http.StatusOK, SubscriptionResponse{Subscription: sub, Invoice: inv})
+	case r.Method == http.MethodPost && action == "cancel":
+		sub, err := h.service.CancelSubscription(r.Context(), userID, id)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		jsonutil.WriteJSON(w, http.StatusOK, sub)
+	default:
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Not Found"})
+	}
+}
+
+// charge attempts an invoice right away and returns the subscription as it
+// stands afterwards. Failures are left to the worker to retry.
+func (h *BillingHandler) charge(r *http.Request, sub *domain.Subscription, inv *domain.Invoice) *domain.Subscription {
+	if err := h.worker.ChargeInvoice(r.Context(), inv); err != nil {
+		log.Printf("Failed to charge invoice %s: %v", inv.ID, err)
+		return sub
+	}
+	if updated, err := h.service.GetSubscription(r.Context(), "", sub.ID); err == nil {
+		return updated
+	}
+	return sub
+}
+
+func (h *BillingHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrSubscriptionNotFound), errors.Is(err, domain.ErrPlanNotFound):
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidPlan), errors.Is(err, domain.ErrIncompatiblePlan), errors.Is(err, domain.ErrSamePlan):
+		jsonutil.WriteErrorJSON(w, err.Error())
+	case errors.Is(err, domain.ErrSubscriptionCanceled), errors.Is(err, domain.ErrSubscriptionInactive):
+		jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		log.Printf("Billing request failed: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal Server Error"})
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"database/sql"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -14,8 +16,11 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
+	"github.com/sapliy/fintech-ecosystem/internal/billing/domain"
 	"github.com/sapliy/fintech-ecosystem/internal/billing/infrastructure"
 	"github.com/sapliy/fintech-ecosystem/internal/billing/service"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
 )
 
 func main() {
@@ -32,10 +37,28 @@ func main() {
 
 	repo := infrastructure.NewSQLRepository(db)
 
-	// Mock payment client for now - in reality this would be a gRPC client to Payment service
-	paymentClient := &mockPaymentClient{}
+	// Subscription events go to their own Kafka topic
+	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
+	if kafkaBrokers == "" {
+		kafkaBrokers = "localhost:9092"
+	}
+	kafkaProducer := messaging.NewKafkaProducer(strings.Split(kafkaBrokers, ","), "billing")
+	defer func() {
+		if err := kafkaProducer.Close(); err != nil {
+			log.Printf("Failed to close Kafka producer: %v", err)
+		}
+	}()
+
+	billingService := domain.NewBillingService(repo, kafkaProducer)
 
-	worker := service.NewSubscriptionWorker(repo, paymentClient, 1*time.Minute)
+	// Invoices are collected through the payments service's REST API
+	paymentURL := os.Getenv("PAYMENT_SERVICE_URL")
+	if paymentURL == "" {
+		paymentURL = "http://127.0.0.1:8082"
+	}
+	paymentClient := infrastructure.NewHTTPPaymentClient(paymentURL)
+
+	worker := service.NewSubscriptionWorker(billingService, paymentClient, 1*time.Minute)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -58,17 +81,39 @@ func main() {
 		}
 	}()
 
+	handler := &BillingHandler{service: billingService, worker: worker}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plans", handler.HandlePlans)
+	mux.HandleFunc("/subscriptions", handler.HandleSubscriptions)
+	mux.HandleFunc("/subscriptions/", handler.HandleSubscription)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		jsonutil.WriteJSON(w, http.StatusOK, map[string]string{
+			"status":  "active",
+			"service": "billing",
+		})
+	})
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8092"
+	}
+	httpServer := &http.Server{Addr: ":" + port, Handler: mux}
+	go func() {
+		log.Printf("Billing REST API starting on :%s", port)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("failed to serve HTTP: %v", err)
+		}
+	}()
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
 
 	log.Println("Shutting down billing service...")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown failed: %v", err)
+	}
 	s.GracefulStop()
 }
-
-type mockPaymentClient struct{}
-
-func (m *mockPaymentClient) CreatePayment(ctx context.Context, userID, orgID string, amount int64, currency string) (string, error) {
-	log.Printf("Mock Payment: user=%s, org=%s, amount=%d %s", userID, orgID, amount, currency)
-	return "pmt_mock_" + userID, nil
-}
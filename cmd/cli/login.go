@@ -54,13 +54,24 @@ var loginCmd = &cobra.Command{
 			return
 		}
 
-		var loginResp struct {
-			Token string `json:"token"`
-		}
+		var loginResp loginResponse
 		if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
 			fmt.Printf("Failed to decode login response: %v\n", err)
 			return
 		}
+		if loginResp.TwoFactorRequired {
+			if loginResp.Method == "email" {
+				fmt.Println("Your organization requires two-factor authentication. We emailed you a security code.")
+				fmt.Print("Security code: ")
+			} else {
+				fmt.Print("Authentication code (or recovery code): ")
+			}
+			scanner.Scan()
+			if loginResp, err = completeTwoFactorLogin(gatewayURL, loginResp.ChallengeID, strings.TrimSpace(scanner.Text())); err != nil {
+				fmt.Printf("Login failed: %v\n", err)
+				return
+			}
+		}
 
 		// Get an API key for the user (test environment by default)
 		client := &http.Client{}
@@ -95,6 +106,9 @@ var loginCmd = &cobra.Command{
 		// Save to config
 		viper.Set("api_key", keyResp.Key)
 		viper.Set("email", email)
+		// The session's refresh token renews access without logging in again
+		viper.Set("refresh_token", loginResp.RefreshToken)
+		viper.Set("session_id", loginResp.SessionID)
 		if err := viper.WriteConfig(); err != nil {
 			fmt.Printf("Warning: failed to write config: %v\n", err)
 		}
@@ -104,6 +118,65 @@ var loginCmd = &cobra.Command{
 	},
 }
 
+// loginResponse is a session, or the challenge of a second factor
+type loginResponse struct {
+	Token             string `json:"token"`
+	RefreshToken      string `json:"refresh_token"`
+	SessionID         string `json:"session_id"`
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeID       string `json:"challenge_id"`
+	Method            string `json:"method"`
+}
+
+// completeTwoFactorLogin answers the login's second factor challenge
+func completeTwoFactorLogin(gatewayURL, challengeID, code string) (loginResponse, error) {
+	var loginResp loginResponse
+	body, _ := json.Marshal(map[string]string{"challenge_id": challengeID, "code": code})
+	resp, err := http.Post(gatewayURL+"/auth/login/2fa", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return loginResp, fmt.Errorf("error connecting to gateway: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return loginResp, fmt.Errorf("invalid code")
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
+		return loginResp, fmt.Errorf("failed to decode login response: %w", err)
+	}
+	return loginResp, nil
+}
+
+// refreshAccessToken exchanges the stored refresh token for an access token,
+// storing the rotated refresh token
+func refreshAccessToken(gatewayURL string) (string, error) {
+	refreshToken := viper.GetString("refresh_token")
+	if refreshToken == "" {
+		return "", fmt.Errorf("no session, log in again")
+	}
+	body, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+	resp, err := http.Post(gatewayURL+"/auth/token/refresh", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return "", fmt.Errorf("error connecting to gateway: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("session expired or revoked, log in again")
+	}
+
+	var refreshResp struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&refreshResp); err != nil {
+		return "", fmt.Errorf("failed to decode refresh response: %w", err)
+	}
+	viper.Set("refresh_token", refreshResp.RefreshToken)
+	if err := viper.WriteConfig(); err != nil {
+		fmt.Printf("Warning: failed to write config: %v\n", err)
+	}
+	return refreshResp.Token, nil
+}
+
 func init() {
 	rootCmd.AddCommand(loginCmd)
 }
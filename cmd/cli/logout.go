@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -11,8 +12,16 @@ var logoutCmd = &cobra.Command{
 	Use:   "logout",
 	Short: "Log out of the microservices platform",
 	Run: func(cmd *cobra.Command, args []string) {
+		if sessionID := viper.GetString("session_id"); sessionID != "" {
+			if err := revokeSession(sessionID); err != nil {
+				fmt.Printf("Warning: failed to revoke session: %v\n", err)
+			}
+		}
+
 		viper.Set("api_key", "")
 		viper.Set("email", "")
+		viper.Set("refresh_token", "")
+		viper.Set("session_id", "")
 		if err := viper.WriteConfig(); err != nil {
 			fmt.Printf("Warning: failed to write config: %v\n", err)
 		}
@@ -20,6 +29,31 @@ var logoutCmd = &cobra.Command{
 	},
 }
 
+// revokeSession ends the login session, so that its refresh token cannot be
+// used from a copy of the config
+func revokeSession(sessionID string) error {
+	gatewayURL := viper.GetString("gateway_url")
+	if gatewayURL == "" {
+		gatewayURL = "http://localhost:8080"
+	}
+	token, err := refreshAccessToken(gatewayURL)
+	if err != nil {
+		return err
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, gatewayURL+"/auth/sessions/"+sessionID, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error connecting to gateway: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(logoutCmd)
 }
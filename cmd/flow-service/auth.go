@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/policy"
+	"github.com/sapliy/fintech-ecosystem/pkg/apikey"
+	"github.com/sapliy/fintech-ecosystem/pkg/jwtutil"
+	"github.com/sapliy/fintech-ecosystem/pkg/scopes"
+	pb "github.com/sapliy/fintech-ecosystem/proto/auth"
+)
+
+var (
+	ErrInvalidKey     = errors.New("invalid or revoked API key")
+	ErrPublishableKey = errors.New("publishable keys cannot use the flow API")
+	ErrNoZoneAccess   = errors.New("no access to zone")
+)
+
+// publicRoutes are served without authentication, by path template and
+// method. Inbound webhooks are verified by their signature instead.
+var publicRoutes = map[string]string{
+	"/v1/zones/{zoneId}/hooks/{hookId}": "POST",
+	"/metrics":                          "",
+	"/health":                           "GET",
+}
+
+// Principal is the caller a request was authenticated as
+type Principal struct {
+	UserID string
+	OrgID  string
+	ZoneID string // The only zone the caller may access
+	Mode   string // Mode of the zone, test or live
+	// KeyEnvironment is the environment of the API key's prefix, test or
+	// live; empty for JWTs
+	KeyEnvironment string
+	Roles          []policy.Role
+	// Scopes restrict API keys; JWTs act for the user and are not scoped
+	Scopes string
+	Scoped bool
+	// Session marks users authenticated with a session JWT. Their tokens
+	// carry no zone, so the zone of each request is bound to them once the
+	// user is found to be a member of its organization.
+	Session bool
+}
+
+type principalKey struct{}
+
+// principalFrom returns the caller authenticated for the request, if any
+func principalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// callerOwnsZone reports whether the authenticated caller may access the
+// zone. Requests that went through no authentication are not restricted.
+func callerOwnsZone(ctx context.Context, zoneID string) bool {
+	p, ok := principalFrom(ctx)
+	return !ok || (p.ZoneID != "" && p.ZoneID == zoneID)
+}
+
+// authorizeZone rejects the request if the caller may not access a zone
+// taken from its body
+func authorizeZone(w http.ResponseWriter, r *http.Request, zoneID string) bool {
+	if !callerOwnsZone(r.Context(), zoneID) {
+		http.Error(w, fmt.Sprintf("No access to zone %s", zoneID), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// callerOrg is the organization resources created by the caller belong to.
+// Authenticated callers always create them in their own organization.
+func callerOrg(ctx context.Context, requested string) string {
+	if p, ok := principalFrom(ctx); ok && p.OrgID != "" {
+		return p.OrgID
+	}
+	return requested
+}
+
+// KeyValidator resolves the hash of an API key to the key's owner
+type KeyValidator interface {
+	ValidateKey(ctx context.Context, keyHash string) (*Principal, error)
+}
+
+// authServiceKeys validates API keys with the auth service
+type authServiceKeys struct {
+	client pb.AuthServiceClient
+}
+
+func NewAuthServiceKeyValidator(client pb.AuthServiceClient) KeyValidator {
+	return &authServiceKeys{client: client}
+}
+
+func (v *authServiceKeys) ValidateKey(ctx context.Context, keyHash string) (*Principal, error) {
+	res, err := v.client.ValidateKey(ctx, &pb.ValidateKeyRequest{KeyHash: keyHash})
+	if err != nil {
+		return nil, err
+	}
+	if !res.Valid {
+		return nil, ErrInvalidKey
+	}
+	if res.KeyType == "publishable" {
+		return nil, ErrPublishableKey
+	}
+
+	return &Principal{
+		UserID: res.UserId,
+		OrgID:  res.OrgId,
+		ZoneID: res.ZoneId,
+		Mode:   res.Mode,
+		Roles:  policy.RolesFrom(res.Role),
+		Scopes: res.Scopes,
+		Scoped: true,
+	}, nil
+}
+
+// ZoneAccess is what a user may do in a zone of their organization
+type ZoneAccess struct {
+	OrgID string
+	Mode  string
+	Role  string
+}
+
+// ZoneMemberships resolves a user's access to a zone through their
+// membership of the zone's organization. It returns ErrNoZoneAccess when they
+// have none.
+type ZoneMemberships interface {
+	ResolveZone(ctx context.Context, userID, orgID, zoneID string) (*ZoneAccess, error)
+}
+
+// authServiceMemberships resolves zone access with the auth service
+type authServiceMemberships struct {
+	client pb.AuthServiceClient
+}
+
+func NewAuthServiceZoneMemberships(client pb.AuthServiceClient) ZoneMemberships {
+	return &authServiceMemberships{client: client}
+}
+
+func (m *authServiceMemberships) ResolveZone(ctx context.Context, userID, orgID, zoneID string) (*ZoneAccess, error) {
+	res, err := m.client.ResolveZone(ctx, &pb.ResolveZoneRequest{UserId: userID, OrgId: orgID, ZoneId: zoneID})
+	if err != nil {
+		return nil, err
+	}
+	if !res.Allowed {
+		return nil, ErrNoZoneAccess
+	}
+	return &ZoneAccess{OrgID: res.OrgId, Mode: res.Mode, Role: res.Role}, nil
+}
+
+// zoneResolver finds the zone of the resource a route variable names
+type zoneResolver func(ctx context.Context, id string) (string, error)
+
+// Authenticator authenticates flow API requests with an API key or a JWT
+// and confines callers to the zone their credentials belong to. Writes are
+// also checked against the policy engine.
+type Authenticator struct {
+	keys       KeyValidator
+	members    ZoneMemberships
+	hmacSecret string
+	policies   *policy.PolicyMiddleware
+	resolvers  map[string]zoneResolver
+}
+
+// NewAuthenticator creates an authenticator. API keys are hashed with
+// hmacSecret before they are looked up; session JWTs reach the zones members
+// gives them access to.
+func NewAuthenticator(keys KeyValidator, members ZoneMemberships, hmacSecret string, engine policy.PolicyEngine) *Authenticator {
+	return &Authenticator{
+		keys:       keys,
+		members:    members,
+		hmacSecret: hmacSecret,
+		policies:   policy.NewPolicyMiddleware(engine),
+		resolvers:  make(map[string]zoneResolver),
+	}
+}
+
+// ResolveZone sets how the zone of the resource named by a route variable,
+// such as flowId, is found. Resources of other zones are reported missing.
+func (a *Authenticator) ResolveZone(routeVar string, resolve func(ctx context.Context, id string) (string, error)) {
+	a.resolvers[routeVar] = resolve
+}
+
+// Middleware authenticates every route except the public ones. It is meant
+// for router.Use, so route variables are available.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPublicRoute(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := a.authenticate(r)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if err == ErrPublishableKey {
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if scope := requiredScope(r); principal.Scoped && !scopes.HasScope(principal.Scopes, scope) {
+			scopes.WriteInsufficientScope(w, scope, principal.Scopes)
+			return
+		}
+		vars := mux.Vars(r)
+		if principal.Session {
+			if err := a.bindZone(r.Context(), r, principal); err != nil {
+				if err != ErrNoZoneAccess {
+					log.Printf("Zone access resolution failed: %v", err)
+					http.Error(w, "Failed to resolve zone access", http.StatusServiceUnavailable)
+					return
+				}
+				http.Error(w, "No access to zone", http.StatusForbidden)
+				return
+			}
+		}
+		ctx := context.WithValue(r.Context(), principalKey{}, principal)
+
+		if zoneID, ok := vars["zoneId"]; ok && !callerOwnsZone(ctx, zoneID) {
+			http.Error(w, fmt.Sprintf("No access to zone %s", zoneID), http.StatusForbidden)
+			return
+		}
+		for name, resolve := range a.resolvers {
+			id, ok := vars[name]
+			if !ok {
+				continue
+			}
+			zoneID, err := resolve(ctx, id)
+			if err != nil || !callerOwnsZone(ctx, zoneID) {
+				http.Error(w, "Not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		if action := requiredAction(r); action != "" {
+			err := a.policies.Check(ctx, &policy.PolicyContext{
+				UserID:         principal.UserID,
+				OrgID:          principal.OrgID,
+				ZoneID:         principal.ZoneID,
+				Roles:          principal.Roles,
+				Action:         action,
+				Environment:    principal.Mode,
+				KeyEnvironment: principal.KeyEnvironment,
+			})
+			if err != nil {
+				policy.WriteError(w, err)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticate resolves the credentials of a request. API keys (sk_ or pk_)
+// are validated with the key validator; anything else must be a JWT. As with
+// the gateway, the credentials may come from the api_key query parameter for
+// WebSocket clients.
+func (a *Authenticator) authenticate(r *http.Request) (*Principal, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("api_key")
+	}
+	if token == "" {
+		return nil, errors.New("missing credentials")
+	}
+
+	if strings.HasPrefix(token, "sk_") || strings.HasPrefix(token, "pk_") {
+		principal, err := a.keys.ValidateKey(r.Context(), apikey.HashKey(token, a.hmacSecret))
+		if err != nil {
+			if err != ErrInvalidKey && err != ErrPublishableKey {
+				log.Printf("API key validation failed: %v", err)
+				return nil, ErrInvalidKey
+			}
+			return nil, err
+		}
+		principal.KeyEnvironment = apikey.Environment(token)
+		return principal, nil
+	}
+
+	claims, err := jwtutil.ValidateToken(token)
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+	return &Principal{UserID: claims.UserID, OrgID: claims.OrgID, ZoneID: claims.ZoneID, Roles: policy.RolesFrom(claims.Role), Session: true}, nil
+}
+
+// bindZone binds a session's user to the zone of the request: the zoneId
+// route variable, else the X-Zone-ID header, else the zone of the resource a
+// route variable names. The zone's organization, mode and the user's role in
+// it come from their membership, so policies see live zones as live. Requests
+// naming no zone keep the token's zone, if any.
+func (a *Authenticator) bindZone(ctx context.Context, r *http.Request, p *Principal) error {
+	vars := mux.Vars(r)
+	zoneID := vars["zoneId"]
+	if zoneID == "" {
+		zoneID = r.Header.Get("X-Zone-ID")
+	}
+	fromResource := false
+	if zoneID == "" {
+		for name, resolve := range a.resolvers {
+			if id, ok := vars[name]; ok {
+				if resolved, err := resolve(ctx, id); err == nil {
+					zoneID, fromResource = resolved, true
+					break
+				}
+			}
+		}
+	}
+	if zoneID == "" {
+		zoneID = p.ZoneID
+	}
+	if zoneID == "" {
+		return nil
+	}
+	// Tokens issued for a zone stay in it
+	if p.ZoneID != "" && p.ZoneID != zoneID {
+		return ErrNoZoneAccess
+	}
+	if a.members == nil {
+		return ErrNoZoneAccess
+	}
+
+	access, err := a.members.ResolveZone(ctx, p.UserID, p.OrgID, zoneID)
+	if err == ErrNoZoneAccess && fromResource {
+		return nil // Reported missing, as are other zones' resources
+	}
+	if err != nil {
+		return err
+	}
+	p.ZoneID = zoneID
+	p.OrgID = access.OrgID
+	p.Mode = access.Mode
+	p.Roles = policy.RolesFrom(access.Role)
+	return nil
+}
+
+// requiredScope is the scope an API key needs for a request. Secrets,
+// debugging and deletes are flow administration; event replays need the
+// events scopes.
+func requiredScope(r *http.Request) string {
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+	tmpl, _ := mux.CurrentRoute(r).GetPathTemplate()
+	switch {
+	case strings.Contains(tmpl, "/secrets"), strings.Contains(tmpl, "/debug"):
+		return scopes.FlowsAdmin
+	case strings.Contains(tmpl, "/events"), strings.Contains(tmpl, "/replay"):
+		if read {
+			return scopes.EventsRead
+		}
+		return scopes.EventsWrite
+	case r.Method == http.MethodDelete:
+		return scopes.FlowsAdmin
+	case read:
+		return scopes.FlowsRead
+	}
+	return scopes.FlowsWrite
+}
+
+// requiredAction is the policy action a request needs. Reads need none;
+// secrets are zone settings and every other write deploys flow changes,
+// which the policy engine checks as live deploys in live zones.
+func requiredAction(r *http.Request) policy.Action {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ""
+	}
+	if tmpl, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil && strings.Contains(tmpl, "/secrets") {
+		return policy.ActionSettingsUpdate
+	}
+	return policy.ActionFlowDeploy
+}
+
+func isPublicRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return false
+	}
+	method, ok := publicRoutes[tmpl]
+	return ok && (method == "" || method == r.Method)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/flow"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// maxBulkRetry caps how many dead letters one bulk retry starts
+const maxBulkRetry = 100
+
+// DeadLetterHandler lists a zone's failed executions and retries them
+type DeadLetterHandler struct {
+	queue *flow.DeadLetterQueue
+}
+
+func NewDeadLetterHandler(queue *flow.DeadLetterQueue) *DeadLetterHandler {
+	return &DeadLetterHandler{queue: queue}
+}
+
+func registerDeadLetterRoutes(r *mux.Router, h *DeadLetterHandler) {
+	r.HandleFunc("/v1/zones/{zoneId}/executions/dead-letter", h.ListDeadLetters).Methods("GET")
+	r.HandleFunc("/v1/zones/{zoneId}/executions/dead-letter/retry", h.RetryDeadLetters).Methods("POST")
+	r.HandleFunc("/v1/zones/{zoneId}/executions/dead-letter/{entryId}", h.GetDeadLetter).Methods("GET")
+	r.HandleFunc("/v1/zones/{zoneId}/executions/dead-letter/{entryId}/retry", h.RetryDeadLetter).Methods("POST")
+}
+
+// ListDeadLetters lists the zone's dead letters, newest first. The status
+// query parameter filters by status.
+func (h *DeadLetterHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(query.Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	entries, err := h.queue.List(r.Context(), vars["zoneId"], domain.DeadLetterStatus(query.Get("status")), limit, offset)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list dead letters: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dead_letters": entries,
+		"count":        len(entries),
+		"limit":        limit,
+		"offset":       offset,
+	})
+}
+
+func (h *DeadLetterHandler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	entry, ok := h.zoneEntry(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
+
+// RetryDeadLetter starts a retry of the entry in the background
+func (h *DeadLetterHandler) RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	entry, ok := h.zoneEntry(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.queue.Retry(r.Context(), entry); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(entry)
+}
+
+// RetryDeadLetters retries the listed entries, or every dead entry of the
+// zone when no IDs are given
+func (h *DeadLetterHandler) RetryDeadLetters(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if len(req.IDs) > maxBulkRetry {
+		http.Error(w, fmt.Sprintf("At most %d dead letters can be retried at once", maxBulkRetry), http.StatusBadRequest)
+		return
+	}
+
+	var entries []*domain.DeadLetter
+	if len(req.IDs) == 0 {
+		var err error
+		entries, err = h.queue.List(r.Context(), vars["zoneId"], domain.DeadLetterDead, maxBulkRetry, 0)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to list dead letters: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	failed := map[string]string{}
+	for _, id := range req.IDs {
+		entry, err := h.queue.Get(r.Context(), vars["zoneId"], id)
+		if err != nil {
+			failed[id] = err.Error()
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	retried := []string{}
+	for _, entry := range entries {
+		if err := h.queue.Retry(r.Context(), entry); err != nil {
+			failed[entry.ID] = err.Error()
+			continue
+		}
+		retried = append(retried, entry.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"retried": retried,
+		"failed":  failed,
+	})
+}
+
+// zoneEntry loads a dead letter of the zone in the path
+func (h *DeadLetterHandler) zoneEntry(w http.ResponseWriter, r *http.Request) (*domain.DeadLetter, bool) {
+	vars := mux.Vars(r)
+
+	entry, err := h.queue.Get(r.Context(), vars["zoneId"], vars["entryId"])
+	if err != nil {
+		if err == domain.ErrDeadLetterNotFound {
+			http.Error(w, "Dead letter not found", http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("Failed to get dead letter: %v", err), http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return entry, true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// DeliveryHandler lists the archived requests of a flow's webhook action
+// nodes
+type DeliveryHandler struct {
+	deliveries domain.WebhookDeliveryStore
+}
+
+func NewDeliveryHandler(deliveries domain.WebhookDeliveryStore) *DeliveryHandler {
+	return &DeliveryHandler{deliveries: deliveries}
+}
+
+func registerDeliveryRoutes(r *mux.Router, h *DeliveryHandler) {
+	r.HandleFunc("/v1/flows/{flowId}/deliveries", h.ListDeliveries).Methods("GET")
+}
+
+// ListDeliveries lists a flow's webhook deliveries, newest first. The
+// execution_id and node_id query parameters narrow the list.
+func (h *DeliveryHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(query.Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	deliveries, err := h.deliveries.ListWebhookDeliveries(r.Context(), domain.WebhookDeliveryFilter{
+		FlowID:      vars["flowId"],
+		ExecutionID: query.Get("execution_id"),
+		NodeID:      query.Get("node_id"),
+		Limit:       limit,
+		Offset:      offset,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list deliveries: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*domain.WebhookDelivery{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
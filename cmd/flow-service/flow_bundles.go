@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/flow"
+)
+
+// ExportFlow returns the flow as a portable bundle without its secrets
+func (s *FlowServer) ExportFlow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	f, err := s.repo.GetFlow(r.Context(), vars["flowId"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Flow not found: %v", err), http.StatusNotFound)
+		return
+	}
+
+	bundle, err := flow.ExportFlow(f)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to export flow: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.flow.json"`, f.ID))
+	json.NewEncoder(w).Encode(bundle)
+}
+
+// ImportFlow creates a disabled flow in the zone from an exported bundle.
+// Every secret placeholder in the bundle must be given a value.
+func (s *FlowServer) ImportFlow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req struct {
+		Bundle  *flow.FlowBundle  `json:"bundle"`
+		OrgID   string            `json:"org_id"`
+		Name    string            `json:"name"` // Overrides the bundle's name
+		Secrets map[string]string `json:"secrets"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Bundle == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	f, err := flow.ImportFlow(req.Bundle, callerOrg(r.Context(), req.OrgID), vars["zoneId"], req.Secrets)
+	if err != nil {
+		var missing *flow.MissingSecretsError
+		if errors.As(err, &missing) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":           "Missing secrets",
+				"missing_secrets": missing.Names,
+			})
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name != "" {
+		f.Name = req.Name
+	}
+
+	if err := s.validator.Validate(f); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	if err := s.repo.CreateFlow(r.Context(), f); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create flow: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(f)
+}
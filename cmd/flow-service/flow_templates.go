@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/flow"
+)
+
+// ListFlowTemplates returns the gallery of built-in flow templates
+func (s *FlowServer) ListFlowTemplates(w http.ResponseWriter, r *http.Request) {
+	templates := flow.FlowTemplates()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+func (s *FlowServer) GetFlowTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	t, ok := flow.GetFlowTemplate(vars["templateId"])
+	if !ok {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// CreateFlowFromTemplate creates a disabled flow in the zone from a
+// template, filling in the given parameters
+func (s *FlowServer) CreateFlowFromTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	t, ok := flow.GetFlowTemplate(vars["templateId"])
+	if !ok {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		OrgID      string                 `json:"org_id"`
+		Name       string                 `json:"name"` // Overrides the template's name
+		Parameters map[string]interface{} `json:"parameters"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	f, err := flow.InstantiateTemplate(t, callerOrg(r.Context(), req.OrgID), vars["zoneId"], req.Parameters)
+	if err != nil {
+		var missing *flow.MissingParametersError
+		if errors.As(err, &missing) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":              "Missing parameters",
+				"missing_parameters": missing.Names,
+			})
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name != "" {
+		f.Name = req.Name
+	}
+
+	if err := s.validator.Validate(f); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	if err := s.repo.CreateFlow(r.Context(), f); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create flow: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(f)
+}
@@ -3,14 +3,28 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
 	"github.com/sapliy/fintech-ecosystem/internal/flow"
 	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/nodes"
 	"github.com/sapliy/fintech-ecosystem/internal/flow/testutil"
+	"github.com/sapliy/fintech-ecosystem/internal/policy"
+	"github.com/sapliy/fintech-ecosystem/pkg/apikey"
+	"github.com/sapliy/fintech-ecosystem/pkg/jwtutil"
 )
 
 func TestFlowServer_StartDebugSession(t *testing.T) {
@@ -47,6 +61,7 @@ func TestFlowServer_StartDebugSession(t *testing.T) {
 
 	req := httptest.NewRequest("POST", "/api/v1/flows/flow_test/zones/zone_456/debug", bytes.NewBuffer(reqBodyBytes))
 	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"flowId": "flow_test", "zoneId": "zone_456"})
 	w := httptest.NewRecorder()
 
 	// Execute
@@ -78,3 +93,2341 @@ func TestFlowServer_StartDebugSession(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
 }
+
+func TestFlowServer_DeleteAndRestoreFlow(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	server := NewFlowServer(debugService, repo)
+	router := setupRoutes(server, NewWebhookReplayer(repo, nil, debugService, repo))
+
+	testFlow := &domain.Flow{ID: "flow_del", ZoneID: "zone_1", Name: "To Delete", Enabled: true}
+	if err := repo.CreateFlow(context.Background(), testFlow); err != nil {
+		t.Fatalf("Failed to create test flow: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/v1/flows/flow_del", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 on delete, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/zones/zone_1/flows", nil))
+	var list struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to unmarshal list response: %v", err)
+	}
+	if list.Count != 0 {
+		t.Errorf("Expected deleted flow to be excluded from listing, got %d flows", list.Count)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/v1/flows/flow_del", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 when deleting twice, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/flows/flow_del/restore", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 on restore, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/flows/flow_del", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected restored flow to be retrievable, got %d", w.Code)
+	}
+}
+
+func TestFlowServer_RollbackFlowVersion(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	server := NewFlowServer(debugService, repo)
+	router := setupRoutes(server, NewWebhookReplayer(repo, nil, debugService, repo))
+
+	original := &domain.Flow{
+		ID:     "flow_ver",
+		ZoneID: "zone_1",
+		Nodes:  []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}},
+	}
+	if err := repo.CreateFlow(context.Background(), original); err != nil {
+		t.Fatalf("Failed to create test flow: %v", err)
+	}
+
+	edited := *original
+	edited.Nodes = []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "broken", Type: domain.NodeWebhook}}
+	if err := repo.UpdateFlow(context.Background(), &edited); err != nil {
+		t.Fatalf("Failed to update test flow: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/flows/flow_ver/versions/1/rollback", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 on rollback, got %d: %s", w.Code, w.Body.String())
+	}
+
+	current, _ := repo.GetFlow(context.Background(), "flow_ver")
+	if current.Version != 3 {
+		t.Errorf("Expected rollback to create version 3, got %d", current.Version)
+	}
+	if len(current.Nodes) != 1 {
+		t.Errorf("Expected nodes from version 1 to be restored, got %d nodes", len(current.Nodes))
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/flows/flow_ver/versions", nil))
+	var list struct {
+		Count int `json:"count"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Count != 3 {
+		t.Errorf("Expected 3 versions, got %d", list.Count)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/flows/flow_ver/versions/9", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown version, got %d", w.Code)
+	}
+}
+
+func TestFlowServer_ValidateFlow(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	router := setupRoutes(NewFlowServer(debugService, repo), NewWebhookReplayer(repo, nil, debugService, repo))
+
+	valid := `{"id":"flow_valid","nodes":[
+		{"id":"trigger","type":"eventTrigger"},
+		{"id":"check","type":"condition","data":{"label":"Big?","expression":"amount > 100"}},
+		{"id":"log","type":"auditLog"}],
+	"edges":[
+		{"id":"e1","source":"trigger","target":"check"},
+		{"id":"e2","source":"check","target":"log","source_handle":"true"}]}`
+	invalid := `{"id":"flow_invalid","nodes":[
+		{"id":"trigger","type":"eventTrigger"},
+		{"id":"a","type":"auditLog"},
+		{"id":"b","type":"delay","data":{"duration":"soon"}},
+		{"id":"c","type":"switch","data":{"cases":"EUR"}},
+		{"id":"d","type":"teleport"}],
+	"edges":[
+		{"id":"e1","source":"a","target":"b"},
+		{"id":"e2","source":"b","target":"a"},
+		{"id":"e3","source":"c","target":"missing"}]}`
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/flows/validate", bytes.NewBufferString(valid)))
+	var result struct {
+		Valid  bool                   `json:"valid"`
+		Issues []flow.ValidationIssue `json:"issues"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if w.Code != http.StatusOK || !result.Valid {
+		t.Fatalf("Expected the flow to be valid, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/flows/validate", bytes.NewBufferString(invalid)))
+	result.Issues = nil
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if result.Valid {
+		t.Fatalf("Expected the flow to be invalid")
+	}
+	var messages []string
+	for _, issue := range result.Issues {
+		messages = append(messages, issue.NodeID+issue.EdgeID+": "+issue.Message)
+	}
+	all := strings.Join(messages, "\n")
+	for _, want := range []string{"b: invalid duration", "c: config.cases must be of type array", "c: config.field is required", "d: unknown node type teleport", `e3: references unknown node "missing"`, "cycle: a -> b -> a", "a: node is unreachable"} {
+		if !strings.Contains(all, want) {
+			t.Errorf("Expected an issue mentioning %q, got:\n%s", want, all)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/flows/validate", bytes.NewBufferString(`{"nodes":[{"id":"log","type":"auditLog"}]}`)))
+	if !strings.Contains(w.Body.String(), "flow has no trigger node") {
+		t.Errorf("Expected a missing trigger to be reported, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/flows", bytes.NewBufferString(invalid)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid flow to be rejected on create, got %d", w.Code)
+	}
+	if _, err := repo.GetFlow(context.Background(), "flow_invalid"); err == nil {
+		t.Errorf("Expected the invalid flow not to be saved")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/flows", bytes.NewBufferString(valid)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/v1/flows/flow_valid", bytes.NewBufferString(invalid)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid flow to be rejected on update, got %d", w.Code)
+	}
+}
+
+func TestFlowServer_ExportImportFlow(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	router := setupRoutes(NewFlowServer(debugService, repo), NewWebhookReplayer(repo, nil, debugService, repo))
+
+	repo.CreateFlow(context.Background(), &domain.Flow{
+		ID:      "flow_src",
+		OrgID:   "org_1",
+		ZoneID:  "zone_test",
+		Name:    "Large payment alert",
+		Enabled: true,
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "alert", Type: "slack", Data: json.RawMessage(`{"webhook_url":"https://hooks.slack.com/services/T0/B0/abc","text":"Paid {{amount}}"}`)},
+		},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "alert"}},
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/flows/flow_src/export", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "hooks.slack.com") {
+		t.Fatalf("Expected the Slack webhook URL to be removed from the bundle: %s", w.Body.String())
+	}
+	var bundle flow.FlowBundle
+	json.Unmarshal(w.Body.Bytes(), &bundle)
+	if bundle.Source.ZoneID != "zone_test" || len(bundle.Secrets) != 1 || bundle.Secrets[0].Name != "alert.webhook_url" {
+		t.Fatalf("Unexpected bundle: %s", w.Body.String())
+	}
+
+	importReq := func(secrets map[string]string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"bundle": bundle, "org_id": "org_1", "secrets": secrets})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_live/flows/import", bytes.NewBuffer(body)))
+		return w
+	}
+
+	w = importReq(nil)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "alert.webhook_url") {
+		t.Errorf("Expected the import to require the missing secret, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = importReq(map[string]string{"alert.webhook_url": "https://hooks.slack.com/services/T1/B1/live"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var imported domain.Flow
+	json.Unmarshal(w.Body.Bytes(), &imported)
+	if imported.ID == "flow_src" || imported.ZoneID != "zone_live" || imported.Enabled || imported.Name != "Large payment alert" {
+		t.Errorf("Unexpected imported flow: %s", w.Body.String())
+	}
+	if !strings.Contains(string(imported.Nodes[1].Data), "T1/B1/live") {
+		t.Errorf("Expected the secret to be filled in, got %s", imported.Nodes[1].Data)
+	}
+}
+
+func TestFlowServer_TestFlow(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	server := NewFlowServer(debugService, repo)
+	router := setupRoutes(server, NewWebhookReplayer(repo, nil, debugService, repo))
+
+	testFlow := &domain.Flow{
+		ID:     "flow_dry",
+		ZoneID: "zone_1",
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "check", Type: domain.NodeCondition, Data: json.RawMessage(`{"field":"amount","operator":"gt","value":100}`)},
+			{ID: "notify", Type: domain.NodeWebhook},
+		},
+		Edges: []domain.Edge{
+			{ID: "e1", Source: "trigger", Target: "check"},
+			{ID: "e2", Source: "check", Target: "notify", SourceHandle: "true"},
+		},
+	}
+	if err := repo.CreateFlow(context.Background(), testFlow); err != nil {
+		t.Fatalf("Failed to create test flow: %v", err)
+	}
+
+	body := bytes.NewBufferString(`{"event":{"amount":250}}`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/flows/flow_dry/test", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Status domain.ExecutionStatus `json:"status"`
+		Trace  []domain.ExecutionStep `json:"trace"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if resp.Status != domain.ExecutionCompleted {
+		t.Errorf("Expected completed status, got %s", resp.Status)
+	}
+	if len(resp.Trace) != 3 {
+		t.Fatalf("Expected 3 trace steps, got %d", len(resp.Trace))
+	}
+	if string(resp.Trace[2].Output) != `{"status":"simulated"}` {
+		t.Errorf("Expected webhook node to be simulated, got %s", resp.Trace[2].Output)
+	}
+
+	executions, _ := repo.ListExecutions(context.Background(), "flow_dry", 10, 0)
+	if len(executions) != 0 {
+		t.Errorf("Expected dry run not to persist executions, got %d", len(executions))
+	}
+}
+
+func TestFlowServer_TestFlowParallelJoin(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	server := NewFlowServer(debugService, repo)
+	router := setupRoutes(server, NewWebhookReplayer(repo, nil, debugService, repo))
+
+	testFlow := &domain.Flow{
+		ID:     "flow_fanout",
+		ZoneID: "zone_1",
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "check", Type: domain.NodeCondition, Data: json.RawMessage(`{"field":"amount","operator":"gt","value":100}`)},
+			{ID: "audit", Type: domain.NodeAuditLog},
+			{ID: "join", Type: domain.NodeJoin},
+		},
+		Edges: []domain.Edge{
+			{ID: "e1", Source: "trigger", Target: "check"},
+			{ID: "e2", Source: "trigger", Target: "audit"},
+			{ID: "e3", Source: "check", Target: "join", SourceHandle: "true"},
+			{ID: "e4", Source: "audit", Target: "join"},
+		},
+	}
+	if err := repo.CreateFlow(context.Background(), testFlow); err != nil {
+		t.Fatalf("Failed to create test flow: %v", err)
+	}
+
+	body := bytes.NewBufferString(`{"event":{"amount":250}}`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/flows/flow_fanout/test", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Status domain.ExecutionStatus `json:"status"`
+		Trace  []domain.ExecutionStep `json:"trace"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if resp.Status != domain.ExecutionCompleted {
+		t.Errorf("Expected completed status, got %s", resp.Status)
+	}
+
+	var joins []domain.ExecutionStep
+	for _, step := range resp.Trace {
+		if step.NodeID == "join" {
+			joins = append(joins, step)
+		}
+	}
+	if len(joins) != 1 {
+		t.Fatalf("Expected join to run once, ran %d times", len(joins))
+	}
+
+	var output struct {
+		Amount   float64                    `json:"amount"`
+		Branches map[string]json.RawMessage `json:"branches"`
+	}
+	json.Unmarshal(joins[0].Output, &output)
+	if len(output.Branches) != 2 || output.Branches["check"] == nil || output.Branches["audit"] == nil {
+		t.Errorf("Expected outputs of both branches, got %s", joins[0].Output)
+	}
+	if output.Amount != 250 {
+		t.Errorf("Expected merged output to keep audit fields, got %s", joins[0].Output)
+	}
+}
+
+// failingGate fails the named node before it runs
+type failingGate struct {
+	nodeID string
+}
+
+func (g *failingGate) BeforeNode(ctx context.Context, node *domain.Node, input map[string]interface{}) {
+}
+
+func (g *failingGate) AfterNode(ctx context.Context, node *domain.Node, output map[string]interface{}, err error) {
+}
+
+func (g *failingGate) AwaitNode(ctx context.Context, node *domain.Node, input map[string]interface{}) error {
+	if node.ID == g.nodeID {
+		return fmt.Errorf("%s unavailable", node.ID)
+	}
+	return nil
+}
+
+func TestFlowRunner_BranchErrorPolicy(t *testing.T) {
+	newFlow := func(policy domain.BranchErrorPolicy) *domain.Flow {
+		return &domain.Flow{
+			ID: "flow_policy",
+			Nodes: []domain.Node{
+				{ID: "trigger", Type: domain.NodeTrigger},
+				{ID: "primary", Type: domain.NodeAuditLog},
+				{ID: "optional", Type: domain.NodeAuditLog},
+				{ID: "join", Type: domain.NodeJoin},
+			},
+			Edges: []domain.Edge{
+				{ID: "e1", Source: "trigger", Target: "primary"},
+				{ID: "e2", Source: "trigger", Target: "optional", ErrorPolicy: policy},
+				{ID: "e3", Source: "primary", Target: "join"},
+				{ID: "e4", Source: "optional", Target: "join"},
+			},
+		}
+	}
+
+	runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+	runner.AddHook(&failingGate{nodeID: "optional"})
+
+	exec, err := runner.DryRun(context.Background(), newFlow(domain.BranchContinue), map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Expected continue policy to tolerate the failed branch: %v", err)
+	}
+	last := exec.Steps[len(exec.Steps)-1]
+	if last.NodeID != "join" || last.Status != domain.ExecutionCompleted {
+		t.Fatalf("Expected join to complete last, got %s (%s)", last.NodeID, last.Status)
+	}
+	var output struct {
+		BranchErrors map[string]string `json:"branch_errors"`
+	}
+	json.Unmarshal(last.Output, &output)
+	if output.BranchErrors["optional"] != "optional unavailable" {
+		t.Errorf("Expected branch error to reach the join, got %s", last.Output)
+	}
+
+	exec, err = runner.DryRun(context.Background(), newFlow(domain.BranchFailFast), map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if exec.Status != domain.ExecutionFailed {
+		t.Errorf("Expected fail policy to fail the execution, got %s", exec.Status)
+	}
+	for _, step := range exec.Steps {
+		if step.NodeID == "join" {
+			t.Error("Expected join not to run after a failed branch")
+		}
+	}
+}
+
+// taggingHandler writes the node's ID into its input's nested map
+type taggingHandler struct{}
+
+func (taggingHandler) Execute(ctx context.Context, node *domain.Node, input map[string]interface{}) (map[string]interface{}, error) {
+	input["payment"].(map[string]interface{})["tagged_by"] = node.ID
+	return input, nil
+}
+
+func TestFlowRunner_BranchesCopyInput(t *testing.T) {
+	runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+	runner.RegisterHandler("tag", taggingHandler{})
+	testFlow := &domain.Flow{
+		ID: "flow_tags",
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "left", Type: "tag"},
+			{ID: "right", Type: "tag"},
+			{ID: "join", Type: domain.NodeJoin},
+		},
+		Edges: []domain.Edge{
+			{ID: "e1", Source: "trigger", Target: "left"},
+			{ID: "e2", Source: "trigger", Target: "right"},
+			{ID: "e3", Source: "left", Target: "join"},
+			{ID: "e4", Source: "right", Target: "join"},
+		},
+	}
+	input := map[string]interface{}{"payment": map[string]interface{}{"id": "pi_1"}}
+
+	exec, err := runner.DryRun(context.Background(), testFlow, input)
+	if err != nil || exec.Status != domain.ExecutionCompleted {
+		t.Fatalf("Expected the dry run to complete, got %v, %v", exec, err)
+	}
+	if _, tagged := input["payment"].(map[string]interface{})["tagged_by"]; tagged {
+		t.Error("Expected the branches not to modify the input they were given")
+	}
+	for _, step := range exec.Steps {
+		if step.NodeID != "left" && step.NodeID != "right" {
+			continue
+		}
+		var output struct {
+			Payment map[string]string `json:"payment"`
+		}
+		json.Unmarshal(step.Output, &output)
+		if output.Payment["tagged_by"] != step.NodeID {
+			t.Errorf("Expected %s to see only its own change, got %s", step.NodeID, step.Output)
+		}
+	}
+}
+
+// stalledRepository holds the writes of one flow's executions until released
+type stalledRepository struct {
+	*testutil.MockFlowRepository
+	flowID  string
+	stalled chan struct{}
+	release chan struct{}
+}
+
+func (r *stalledRepository) UpdateExecution(ctx context.Context, exec *domain.FlowExecution) error {
+	if exec.FlowID == r.flowID {
+		select {
+		case r.stalled <- struct{}{}:
+		default:
+		}
+		<-r.release
+	}
+	return r.MockFlowRepository.UpdateExecution(ctx, exec)
+}
+
+func TestFlowRunner_ExecutionsDoNotWaitOnEachOther(t *testing.T) {
+	repo := &stalledRepository{
+		MockFlowRepository: testutil.NewMockFlowRepository(),
+		flowID:             "flow_stalled",
+		stalled:            make(chan struct{}, 1),
+		release:            make(chan struct{}),
+	}
+	runner := domain.NewFlowRunner(repo)
+	newFlow := func(id string) *domain.Flow {
+		return &domain.Flow{
+			ID:    id,
+			Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "audit", Type: domain.NodeAuditLog}},
+			Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "audit"}},
+		}
+	}
+
+	stalledDone := make(chan error, 1)
+	go func() {
+		stalledDone <- runner.Execute(context.Background(), newFlow("flow_stalled"), map[string]interface{}{})
+	}()
+	<-repo.stalled
+
+	// Another execution runs to completion while the first one's write is
+	// still in flight
+	done := make(chan error, 1)
+	go func() { done <- runner.Execute(context.Background(), newFlow("flow_other"), map[string]interface{}{}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Execute failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the execution not to wait for another execution's write")
+	}
+
+	close(repo.release)
+	if err := <-stalledDone; err != nil {
+		t.Errorf("Execute failed: %v", err)
+	}
+}
+
+// blockingGate holds the named node until the execution's context is done
+type blockingGate struct {
+	nodeID string
+}
+
+func (g *blockingGate) BeforeNode(ctx context.Context, node *domain.Node, input map[string]interface{}) {
+}
+
+func (g *blockingGate) AfterNode(ctx context.Context, node *domain.Node, output map[string]interface{}, err error) {
+}
+
+func (g *blockingGate) AwaitNode(ctx context.Context, node *domain.Node, input map[string]interface{}) error {
+	if node.ID != g.nodeID {
+		return nil
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestFlowRunner_Switch(t *testing.T) {
+	testFlow := &domain.Flow{
+		ID: "flow_switch",
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "route", Type: domain.NodeSwitch, Data: json.RawMessage(`{"field":"type","cases":["payment.succeeded","payment.failed"]}`)},
+			{ID: "succeeded", Type: domain.NodeAuditLog},
+			{ID: "failed", Type: domain.NodeAuditLog},
+			{ID: "other", Type: domain.NodeAuditLog},
+		},
+		Edges: []domain.Edge{
+			{ID: "e1", Source: "trigger", Target: "route"},
+			{ID: "e2", Source: "route", Target: "succeeded", SourceHandle: "payment.succeeded"},
+			{ID: "e3", Source: "route", Target: "failed", SourceHandle: "payment.failed"},
+			{ID: "e4", Source: "route", Target: "other", SourceHandle: domain.SwitchDefaultHandle},
+		},
+	}
+	runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+
+	for eventType, want := range map[string]string{
+		"payment.succeeded": "succeeded",
+		"payment.failed":    "failed",
+		"refund.completed":  "other",
+	} {
+		exec, err := runner.DryRun(context.Background(), testFlow, map[string]interface{}{"type": eventType, "amount": 100})
+		if err != nil {
+			t.Fatalf("DryRun failed: %v", err)
+		}
+		var ran []string
+		for _, step := range exec.Steps {
+			ran = append(ran, step.NodeID)
+		}
+		if len(ran) != 3 || ran[2] != want {
+			t.Errorf("Expected %s to route to %s, ran %v", eventType, want, ran)
+			continue
+		}
+		var branchInput map[string]interface{}
+		json.Unmarshal(exec.Steps[2].Input, &branchInput)
+		if branchInput["amount"] != float64(100) {
+			t.Errorf("Expected the switch to pass its input through, got %v", branchInput)
+		}
+	}
+}
+
+func TestFlowRunner_ConditionExpression(t *testing.T) {
+	testFlow := &domain.Flow{
+		ID: "flow_expression",
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "check", Type: domain.NodeCondition, Data: json.RawMessage(`{"expression":"amount > 100 && currency == \"USD\" && created_at < now() - 1h"}`)},
+			{ID: "review", Type: domain.NodeAuditLog},
+			{ID: "skip", Type: domain.NodeAuditLog},
+		},
+		Edges: []domain.Edge{
+			{ID: "e1", Source: "trigger", Target: "check"},
+			{ID: "e2", Source: "check", Target: "review", SourceHandle: "true"},
+			{ID: "e3", Source: "check", Target: "skip", SourceHandle: "false"},
+		},
+	}
+	runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+
+	old := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	recent := time.Now().UTC().Format(time.RFC3339)
+	cases := []struct {
+		input map[string]interface{}
+		want  string
+	}{
+		{map[string]interface{}{"amount": 150, "currency": "USD", "created_at": old}, "review"},
+		{map[string]interface{}{"amount": 150, "currency": "EUR", "created_at": old}, "skip"},
+		{map[string]interface{}{"amount": 150, "currency": "USD", "created_at": recent}, "skip"},
+		{map[string]interface{}{"amount": 50, "currency": "USD", "created_at": old}, "skip"},
+	}
+	for _, tc := range cases {
+		exec, err := runner.DryRun(context.Background(), testFlow, tc.input)
+		if err != nil {
+			t.Fatalf("DryRun failed: %v", err)
+		}
+		if len(exec.Steps) != 3 || exec.Steps[2].NodeID != tc.want {
+			t.Errorf("Expected %v to route to %s, got %d steps", tc.input, tc.want, len(exec.Steps))
+		}
+	}
+
+	testFlow.Nodes[1].Data = json.RawMessage(`{"expression":"amount >"}`)
+	exec, _ := runner.DryRun(context.Background(), testFlow, map[string]interface{}{"amount": 150})
+	if exec == nil || exec.Status != domain.ExecutionFailed {
+		t.Errorf("Expected an invalid expression to fail the execution")
+	}
+}
+
+// itemGate fails the body of a loop for one item
+type itemGate struct {
+	item string
+}
+
+func (g *itemGate) BeforeNode(ctx context.Context, node *domain.Node, input map[string]interface{}) {
+}
+
+func (g *itemGate) AfterNode(ctx context.Context, node *domain.Node, output map[string]interface{}, err error) {
+}
+
+func (g *itemGate) AwaitNode(ctx context.Context, node *domain.Node, input map[string]interface{}) error {
+	if input["sku"] == g.item {
+		return fmt.Errorf("%s out of stock", g.item)
+	}
+	return nil
+}
+
+func TestFlowRunner_Loop(t *testing.T) {
+	newFlow := func(loopData string) *domain.Flow {
+		return &domain.Flow{
+			ID: "flow_loop",
+			Nodes: []domain.Node{
+				{ID: "trigger", Type: domain.NodeTrigger},
+				{ID: "each", Type: domain.NodeLoop, Data: json.RawMessage(loopData)},
+				{ID: "reserve", Type: domain.NodeAuditLog},
+				{ID: "done", Type: domain.NodeAuditLog},
+			},
+			Edges: []domain.Edge{
+				{ID: "e1", Source: "trigger", Target: "each"},
+				{ID: "e2", Source: "each", Target: "reserve", SourceHandle: domain.LoopBodyHandle},
+				{ID: "e3", Source: "each", Target: "done"},
+			},
+		}
+	}
+	input := map[string]interface{}{
+		"order": map[string]interface{}{"items": []interface{}{"A", "B", "C"}},
+	}
+
+	runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+	runner.AddHook(&itemGate{item: "B"})
+
+	exec, _ := runner.DryRun(context.Background(), newFlow(`{"arrayPath":"order.items","itemKey":"sku"}`), input)
+	if exec.Status != domain.ExecutionCompleted {
+		t.Fatalf("Expected completed execution, got %s", exec.Status)
+	}
+	var bodyRuns int
+	var done map[string]interface{}
+	for _, step := range exec.Steps {
+		switch step.NodeID {
+		case "reserve":
+			bodyRuns++
+		case "done":
+			json.Unmarshal(step.Input, &done)
+		}
+	}
+	if bodyRuns != 3 {
+		t.Errorf("Expected the body to run once per item, got %d", bodyRuns)
+	}
+	items, _ := done["items"].([]interface{})
+	if len(items) != 3 || items[1] != nil {
+		t.Fatalf("Expected 3 aggregated outputs with the failed item empty, got %v", done["items"])
+	}
+	if first, _ := items[0].(map[string]interface{}); first["sku"] != "A" || first["index"] != float64(0) {
+		t.Errorf("Expected item and index injected into the body input, got %v", first)
+	}
+	if errs, _ := done["errors"].(map[string]interface{}); errs["1"] != "B out of stock" {
+		t.Errorf("Expected the failed item to be reported, got %v", done["errors"])
+	}
+
+	exec, _ = runner.DryRun(context.Background(), newFlow(`{"arrayPath":"order.items","itemKey":"sku","breakOnError":true}`), input)
+	if exec.Status != domain.ExecutionFailed {
+		t.Errorf("Expected break-on-error to fail the execution, got %s", exec.Status)
+	}
+	for _, step := range exec.Steps {
+		if step.NodeID == "reserve" && step.Status == domain.ExecutionCompleted && strings.Contains(string(step.Input), `"sku":"C"`) {
+			t.Error("Expected the loop to stop at the failed item")
+		}
+	}
+
+	exec, _ = runner.DryRun(context.Background(), newFlow(`{"arrayPath":"order.items","maxIterations":2}`), input)
+	if exec.Status != domain.ExecutionFailed {
+		t.Errorf("Expected the iteration guard to fail the execution, got %s", exec.Status)
+	}
+}
+
+func TestFlowRunner_Timeout(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	runner := domain.NewFlowRunner(repo)
+	runner.AddHook(&blockingGate{nodeID: "slow"})
+
+	testFlow := &domain.Flow{
+		ID:              "flow_timeout",
+		MaxDuration:     1,
+		OnTimeoutNodeID: "cleanup",
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "slow", Type: domain.NodeAuditLog},
+			{ID: "after", Type: domain.NodeAuditLog},
+			{ID: "cleanup", Type: domain.NodeAuditLog},
+		},
+		Edges: []domain.Edge{
+			{ID: "e1", Source: "trigger", Target: "slow"},
+			{ID: "e2", Source: "slow", Target: "after"},
+		},
+	}
+
+	err := runner.Execute(context.Background(), testFlow, map[string]interface{}{})
+	if err != domain.ErrExecutionTimedOut {
+		t.Fatalf("Expected timeout error, got %v", err)
+	}
+
+	executions, _ := repo.ListExecutions(context.Background(), "flow_timeout", 10, 0)
+	if len(executions) != 1 {
+		t.Fatalf("Expected 1 persisted execution, got %d", len(executions))
+	}
+	exec := executions[0]
+	if exec.Status != domain.ExecutionTimedOut {
+		t.Errorf("Expected timed_out status, got %s", exec.Status)
+	}
+
+	statuses := make(map[string]domain.ExecutionStatus)
+	for _, step := range exec.Steps {
+		statuses[step.NodeID] = step.Status
+	}
+	if statuses["trigger"] != domain.ExecutionCompleted {
+		t.Errorf("Expected completed trigger step to be kept, got %s", statuses["trigger"])
+	}
+	if statuses["slow"] != domain.ExecutionTimedOut {
+		t.Errorf("Expected slow step to be timed out, got %s", statuses["slow"])
+	}
+	if _, ran := statuses["after"]; ran {
+		t.Error("Expected nodes after the deadline not to run")
+	}
+	if statuses["cleanup"] != domain.ExecutionCompleted {
+		t.Errorf("Expected on-timeout node to run, got %s", statuses["cleanup"])
+	}
+}
+
+func TestExecutionRecovery(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	ctx := context.Background()
+
+	testFlow := &domain.Flow{
+		ID:     "flow_recover",
+		ZoneID: "zone_1",
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "audit", Type: domain.NodeAuditLog},
+			{ID: "notify", Type: domain.NodeAuditLog},
+			{ID: "loop", Type: domain.NodeLoop},
+		},
+		Edges: []domain.Edge{
+			{ID: "e1", Source: "trigger", Target: "audit"},
+			{ID: "e2", Source: "audit", Target: "notify"},
+		},
+	}
+	if err := repo.CreateFlow(ctx, testFlow); err != nil {
+		t.Fatalf("Failed to create test flow: %v", err)
+	}
+
+	stale := time.Now().Add(-time.Hour)
+	payload := json.RawMessage(`{"amount":250}`)
+	interrupted := &domain.FlowExecution{
+		ID:             "exec_interrupted",
+		FlowID:         "flow_recover",
+		FlowVersion:    1,
+		Status:         domain.ExecutionRunning,
+		StartedAt:      stale,
+		CheckpointedAt: stale,
+		Steps: []domain.ExecutionStep{
+			{NodeID: "trigger", Status: domain.ExecutionCompleted, Input: payload, Output: payload},
+			{NodeID: "audit", Status: domain.ExecutionRunning, Input: payload},
+		},
+	}
+	unsafe := &domain.FlowExecution{
+		ID:             "exec_unsafe",
+		FlowID:         "flow_recover",
+		FlowVersion:    1,
+		Status:         domain.ExecutionRunning,
+		StartedAt:      stale,
+		CheckpointedAt: stale,
+		Steps: []domain.ExecutionStep{
+			{NodeID: "loop", Status: domain.ExecutionRunning, Input: payload},
+		},
+	}
+	live := &domain.FlowExecution{
+		ID:             "exec_live",
+		FlowID:         "flow_recover",
+		FlowVersion:    1,
+		Status:         domain.ExecutionRunning,
+		StartedAt:      time.Now(),
+		CheckpointedAt: time.Now(),
+	}
+	for _, exec := range []*domain.FlowExecution{interrupted, unsafe, live} {
+		repo.CreateExecution(ctx, exec)
+	}
+
+	recovery := flow.NewExecutionRecovery(repo, domain.NewFlowRunner(repo), time.Minute)
+	if n := recovery.RecoverOnce(ctx); n != 2 {
+		t.Fatalf("Expected 2 recovered executions, got %d", n)
+	}
+
+	exec, _ := repo.GetExecution(ctx, "exec_interrupted")
+	if exec.Status != domain.ExecutionCompleted {
+		t.Errorf("Expected interrupted execution to complete, got %s", exec.Status)
+	}
+	var order []string
+	for _, step := range exec.Steps {
+		order = append(order, step.NodeID)
+	}
+	if fmt.Sprint(order) != "[trigger audit notify]" {
+		t.Errorf("Expected audit to be re-run and notify to follow, got %v", order)
+	}
+
+	exec, _ = repo.GetExecution(ctx, "exec_unsafe")
+	if exec.Status != domain.ExecutionFailed {
+		t.Errorf("Expected loop execution to fail over, got %s", exec.Status)
+	}
+
+	exec, _ = repo.GetExecution(ctx, "exec_live")
+	if exec.Status != domain.ExecutionRunning {
+		t.Errorf("Expected recently checkpointed execution to be left alone, got %s", exec.Status)
+	}
+}
+
+// countingHandler counts the times it ran
+type countingHandler struct {
+	runs int
+}
+
+func (h *countingHandler) Execute(ctx context.Context, node *domain.Node, input map[string]interface{}) (map[string]interface{}, error) {
+	h.runs++
+	return input, nil
+}
+
+// sleepingHandler runs for a while and notes when it ran
+type sleepingHandler struct {
+	duration       time.Duration
+	started, ended time.Time
+}
+
+func (h *sleepingHandler) Execute(ctx context.Context, node *domain.Node, input map[string]interface{}) (map[string]interface{}, error) {
+	h.started = time.Now()
+	time.Sleep(h.duration)
+	h.ended = time.Now()
+	return input, nil
+}
+
+// checkpointLog notes when executions were written
+type checkpointLog struct {
+	*testutil.MockFlowRepository
+	mu      sync.Mutex
+	written []time.Time
+}
+
+func (r *checkpointLog) UpdateExecution(ctx context.Context, exec *domain.FlowExecution) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.written = append(r.written, time.Now())
+	return r.MockFlowRepository.UpdateExecution(ctx, exec)
+}
+
+// listedRepository lists the given executions as incomplete, like an
+// instance that listed them before another one recovered them
+type listedRepository struct {
+	*testutil.MockFlowRepository
+	listed []*domain.FlowExecution
+}
+
+func (r *listedRepository) ListIncompleteExecutions(ctx context.Context, checkpointedBefore time.Time) ([]*domain.FlowExecution, error) {
+	return r.listed, nil
+}
+
+func TestExecutionRecovery_ClaimsOnce(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	ctx := context.Background()
+	testFlow := &domain.Flow{
+		ID: "flow_claimed", ZoneID: "zone_1",
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "call", Type: "counting"}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "call"}},
+	}
+	repo.CreateFlow(ctx, testFlow)
+	stale := time.Now().Add(-time.Hour)
+	payload := json.RawMessage(`{}`)
+	repo.CreateExecution(ctx, &domain.FlowExecution{
+		ID: "exec_stale", FlowID: testFlow.ID, FlowVersion: 1, Status: domain.ExecutionRunning,
+		StartedAt: stale, CheckpointedAt: stale,
+		Steps: []domain.ExecutionStep{{NodeID: "trigger", Status: domain.ExecutionCompleted, Input: payload, Output: payload}},
+	})
+	listed, _ := repo.ListIncompleteExecutions(ctx, time.Now())
+	staleCopy := *listed[0]
+
+	counting := &countingHandler{}
+	runner := domain.NewFlowRunner(repo)
+	runner.RegisterHandler("counting", counting)
+
+	if n := flow.NewExecutionRecovery(repo, runner, time.Minute).RecoverOnce(ctx); n != 1 {
+		t.Fatalf("Expected 1 recovered execution, got %d", n)
+	}
+	// Another instance listed the execution before it was recovered
+	other := &listedRepository{MockFlowRepository: repo, listed: []*domain.FlowExecution{&staleCopy}}
+	if n := flow.NewExecutionRecovery(other, runner, time.Minute).RecoverOnce(ctx); n != 0 {
+		t.Errorf("Expected the execution already claimed to be skipped, got %d recovered", n)
+	}
+	if counting.runs != 1 {
+		t.Errorf("Expected the node to run once, ran %d times", counting.runs)
+	}
+}
+
+func TestFlowRunner_HeartbeatWhileNodeRuns(t *testing.T) {
+	repo := &checkpointLog{MockFlowRepository: testutil.NewMockFlowRepository()}
+	runner := domain.NewFlowRunner(repo)
+	runner.SetHeartbeat(10 * time.Millisecond)
+	slow := &sleepingHandler{duration: 100 * time.Millisecond}
+	runner.RegisterHandler("slow", slow)
+
+	testFlow := &domain.Flow{
+		ID:    "flow_slow",
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "wait", Type: "slow"}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "wait"}},
+	}
+	if err := runner.Execute(context.Background(), testFlow, map[string]interface{}{}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	during := 0
+	for _, at := range repo.written {
+		if at.After(slow.started) && at.Before(slow.ended) {
+			during++
+		}
+	}
+	if during < 2 {
+		t.Errorf("Expected the execution checkpointed while the node ran, got %d checkpoints", during)
+	}
+}
+
+func TestKafkaTrigger_HandleMessage(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	ctx := context.Background()
+
+	flows := []*domain.Flow{
+		{
+			ID: "flow_large", ZoneID: "zone_1", Enabled: true,
+			Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger, Data: json.RawMessage(`{"eventType":"payment.*","filters":{"currency":"USD"}}`)}},
+		},
+		{
+			ID: "flow_refund", ZoneID: "zone_1", Enabled: true,
+			Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger, Data: json.RawMessage(`{"eventType":"refund.created"}`)}},
+		},
+		{
+			ID: "flow_disabled", ZoneID: "zone_1", Enabled: false,
+			Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger, Data: json.RawMessage(`{"eventType":"payment.succeeded"}`)}},
+		},
+		{
+			ID: "flow_other_zone", ZoneID: "zone_2", Enabled: true,
+			Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger, Data: json.RawMessage(`{"eventType":"payment.succeeded"}`)}},
+		},
+	}
+	for _, f := range flows {
+		repo.CreateFlow(ctx, f)
+	}
+
+	dispatcher := flow.NewTriggerDispatcher(repo, domain.NewFlowRunner(repo), time.Minute)
+	kafkaTrigger := flow.NewKafkaTrigger(dispatcher, flow.KafkaTriggerConfig{})
+
+	shared := flow.TopicRoute{Topic: "payments"}
+	msg := []byte(`{"id":"evt_1","type":"payment.succeeded","zone_id":"zone_1","data":{"currency":"USD","amount":100}}`)
+	if err := kafkaTrigger.HandleMessage(ctx, shared, "evt_1", msg); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	filtered := []byte(`{"id":"evt_2","type":"payment.succeeded","zone_id":"zone_1","data":{"currency":"EUR"}}`)
+	if err := kafkaTrigger.HandleMessage(ctx, shared, "evt_2", filtered); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	// A zone-dedicated topic routes events to its zone regardless of payload
+	routed := flow.TopicRoute{Topic: "zone-2-events", ZoneID: "zone_2"}
+	if err := kafkaTrigger.HandleMessage(ctx, routed, "evt_3", []byte(`{"type":"payment.succeeded","amount":5}`)); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	expected := map[string]int{"flow_large": 1, "flow_refund": 0, "flow_disabled": 0, "flow_other_zone": 1}
+	for flowID, want := range expected {
+		executions, _ := repo.ListExecutions(ctx, flowID, 10, 0)
+		if len(executions) != want {
+			t.Errorf("Expected %d executions of %s, got %d", want, flowID, len(executions))
+		}
+	}
+
+	if _, err := repo.GetEventByID(ctx, "evt_3"); err != nil {
+		t.Errorf("Expected triggering event to be stored for replay: %v", err)
+	}
+}
+
+func TestWebhookHooks_ReceiveSignedWebhook(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	ctx := context.Background()
+
+	repo.CreateFlow(ctx, &domain.Flow{
+		ID: "flow_hook", ZoneID: "zone_1", Enabled: true,
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger, Data: json.RawMessage(`{"eventType":"shipment.delivered"}`)}},
+	})
+
+	hooks := NewWebhookHookHandler(repo, flow.NewTriggerDispatcher(repo, domain.NewFlowRunner(repo), time.Minute))
+	router := mux.NewRouter()
+	registerWebhookHookRoutes(router, hooks)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_1/hooks", bytes.NewBufferString(`{"name":"carrier","event_type":"shipment.delivered"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var hook domain.WebhookHook
+	json.Unmarshal(w.Body.Bytes(), &hook)
+	if hook.Secret == "" {
+		t.Fatal("Expected the secret to be returned on creation")
+	}
+
+	body := []byte(`{"tracking":"TRK1","status":"delivered"}`)
+	sign := func(timestamp string, body []byte) string {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	bodyOnly := hmac.New(sha256.New, []byte(hook.Secret))
+	bodyOnly.Write(body)
+	url := fmt.Sprintf("/v1/zones/zone_1/hooks/%s", hook.ID)
+
+	rejected := []struct {
+		name      string
+		body      string
+		timestamp string
+		signature string
+	}{
+		{"Tampered payload", `{"tracking":"TRK2","status":"delivered"}`, now, sign(now, body)},
+		{"Missing timestamp", string(body), "", sign(now, body)},
+		{"Tampered timestamp", string(body), stale, sign(now, body)},
+		{"Replayed after the tolerance", string(body), stale, sign(stale, body)},
+		{"Signature of the body alone", string(body), now, hex.EncodeToString(bodyOnly.Sum(nil))},
+	}
+	for _, tt := range rejected {
+		req := httptest.NewRequest("POST", url, bytes.NewBufferString(tt.body))
+		req.Header.Set("X-Sapliy-Timestamp", tt.timestamp)
+		req.Header.Set("X-Sapliy-Signature", tt.signature)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected status 401, got %d", tt.name, w.Code)
+		}
+	}
+
+	// Wrong zone
+	req := httptest.NewRequest("POST", fmt.Sprintf("/v1/zones/zone_2/hooks/%s", hook.ID), bytes.NewBuffer(body))
+	req.Header.Set("X-Sapliy-Timestamp", now)
+	req.Header.Set("X-Sapliy-Signature", sign(now, body))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another zone, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", url, bytes.NewBuffer(body))
+	req.Header.Set("X-Sapliy-Timestamp", now)
+	req.Header.Set("X-Sapliy-Signature", sign(now, body))
+	req.Header.Set("X-Sapliy-Event-ID", "evt_carrier_1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		EventID        string `json:"event_id"`
+		FlowsTriggered int    `json:"flows_triggered"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.EventID != "evt_carrier_1" || resp.FlowsTriggered != 1 {
+		t.Errorf("Unexpected response: %s", w.Body.String())
+	}
+
+	hooks.Wait()
+	executions, _ := repo.ListExecutions(ctx, "flow_hook", 10, 0)
+	if len(executions) != 1 {
+		t.Errorf("Expected 1 execution, got %d", len(executions))
+	}
+	event, err := repo.GetEventByID(ctx, "evt_carrier_1")
+	if err != nil || event.Type != "shipment.delivered" {
+		t.Errorf("Expected webhook to be stored as a shipment.delivered event, got %v (%v)", event, err)
+	}
+}
+
+func TestSchedules_CRUDAndReload(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	ctx := context.Background()
+
+	repo.CreateFlow(ctx, &domain.Flow{ID: "flow_nightly", ZoneID: "zone_1", Enabled: true})
+
+	registry := flow.NewScheduleRegistry(repo, repo, domain.NewFlowRunner(repo))
+	router := mux.NewRouter()
+	registerScheduleRoutes(router, NewScheduleHandler(registry))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/v1/flows/flow_nightly/schedule", bytes.NewBufferString(`{"cron_expression":"0 61 * * *"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid cron expression, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/v1/flows/flow_missing/schedule", bytes.NewBufferString(`{"interval_seconds":60}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown flow, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/v1/flows/flow_nightly/schedule", bytes.NewBufferString(`{"cron_expression":"0 2 * * *","timezone":"Europe/Berlin"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var saved domain.FlowSchedule
+	json.Unmarshal(w.Body.Bytes(), &saved)
+	if saved.ZoneID != "zone_1" || !saved.Enabled || saved.NextRunAt.IsZero() {
+		t.Errorf("Unexpected schedule: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/zones/zone_1/schedules", nil))
+	var list struct {
+		Count int `json:"count"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Count != 1 {
+		t.Errorf("Expected 1 schedule in the zone, got %d", list.Count)
+	}
+
+	// A fresh registry restores the persisted schedule
+	if err := flow.NewScheduleRegistry(repo, repo, domain.NewFlowRunner(repo)).Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	stored, err := repo.GetFlowSchedule(ctx, "flow_nightly")
+	if err != nil || stored.CronExpr != "0 2 * * *" || !stored.NextRunAt.Equal(saved.NextRunAt) {
+		t.Errorf("Expected the schedule to be persisted, got %+v (%v)", stored, err)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/v1/flows/flow_nightly/schedule", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/flows/flow_nightly/schedule", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after delete, got %d", w.Code)
+	}
+}
+
+// tagNode is a custom node type that labels its input
+type tagNode struct {
+	id  string
+	Tag string `json:"tag"`
+}
+
+func (n *tagNode) ID() string   { return n.id }
+func (n *tagNode) Type() string { return "tag" }
+
+func (n *tagNode) Execute(ctx context.Context, input map[string]interface{}) (*nodes.NodeResult, error) {
+	return &nodes.NodeResult{Success: true, Output: map[string]interface{}{"tag": n.Tag, "node": n.id, "amount": input["amt"]}}, nil
+}
+
+func TestNodeRegistry_CustomNodeTypes(t *testing.T) {
+	registry := nodes.DefaultNodeRegistry()
+	err := registry.Register(nodes.NodeTypeDefinition{
+		Type:   "tag",
+		Schema: json.RawMessage(`{"type":"object","properties":{"tag":{"type":"string"}}}`),
+		Factory: func(config json.RawMessage) (nodes.Node, error) {
+			var n tagNode
+			var c struct {
+				ID  string `json:"id"`
+				Tag string `json:"tag"`
+			}
+			if err := json.Unmarshal(config, &c); err != nil {
+				return nil, err
+			}
+			n.id, n.Tag = c.ID, c.Tag
+			return &n, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := registry.Register(nodes.NodeTypeDefinition{Type: "tag", Factory: func(json.RawMessage) (nodes.Node, error) { return nil, nil }}); err == nil {
+		t.Errorf("Expected registering a type twice to fail")
+	}
+
+	testFlow := &domain.Flow{
+		ID: "flow_registry",
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "map", Type: domain.NodeTransform, Data: json.RawMessage(`{"label":"Map","mappings":{"amt":"data.amount"}}`)},
+			{ID: "label", Type: "tag", Data: json.RawMessage(`{"tag":"vip"}`)},
+			{ID: "notify", Type: "webhook_action", Data: json.RawMessage(`{"url":"http://127.0.0.1:1/hook"}`)},
+		},
+		Edges: []domain.Edge{
+			{ID: "e1", Source: "trigger", Target: "map"},
+			{ID: "e2", Source: "map", Target: "label"},
+			{ID: "e3", Source: "label", Target: "notify"},
+		},
+	}
+	runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+	registry.Install(runner)
+
+	exec, err := runner.DryRun(context.Background(), testFlow, map[string]interface{}{"data": map[string]interface{}{"amount": 250.0}})
+	if err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if exec.Status != domain.ExecutionCompleted || len(exec.Steps) != 4 {
+		t.Fatalf("Expected 4 completed steps, got %s with %d steps", exec.Status, len(exec.Steps))
+	}
+	var tagged map[string]interface{}
+	json.Unmarshal(exec.Steps[2].Output, &tagged)
+	if tagged["tag"] != "vip" || tagged["node"] != "label" || tagged["amount"] != 250.0 {
+		t.Errorf("Unexpected output of the custom node: %s", exec.Steps[2].Output)
+	}
+	if !strings.Contains(string(exec.Steps[3].Output), "simulated") {
+		t.Errorf("Expected the webhook action to be simulated in a dry run, got %s", exec.Steps[3].Output)
+	}
+
+	// Invalid config fails the node
+	testFlow.Nodes[3] = domain.Node{ID: "notify", Type: domain.NodeDelay, Data: json.RawMessage(`{"duration":"soon"}`)}
+	exec, _ = runner.DryRun(context.Background(), testFlow, map[string]interface{}{})
+	if exec.Status != domain.ExecutionFailed || !strings.Contains(exec.Steps[3].Error, "invalid duration") {
+		t.Errorf("Expected an invalid node config to fail the execution, got %s", exec.Status)
+	}
+
+	router := mux.NewRouter()
+	registerNodeTypeRoutes(router, NewNodeTypeHandler(registry))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/node-types", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var list struct {
+		NodeTypes []nodes.NodeTypeDefinition `json:"node_types"`
+		Count     int                        `json:"count"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	found := map[string]bool{}
+	for _, def := range list.NodeTypes {
+		found[def.Type] = len(def.Schema) > 0
+	}
+	for _, typ := range []string{"condition", "switch", "transform", "webhook_action", "tag"} {
+		if !found[typ] {
+			t.Errorf("Expected %s with a schema in the node type listing", typ)
+		}
+	}
+}
+
+func TestWebhookReplayer_GetPastEventsFiltered(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	router := setupRoutes(NewFlowServer(debugService, repo), NewWebhookReplayer(repo, nil, debugService, repo))
+
+	base := time.Date(2026, 3, 3, 14, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		repo.CreateEvent(context.Background(), &domain.Event{
+			ID:        fmt.Sprintf("evt_failed_%d", i),
+			Type:      "payment.failed",
+			ZoneID:    "zone_1",
+			Data:      json.RawMessage(`{"customer":{"country":"EG"}}`),
+			CreatedAt: base.Add(time.Duration(i) * 20 * time.Minute),
+		})
+	}
+	repo.CreateEvent(context.Background(), &domain.Event{
+		ID: "evt_ok", Type: "payment.succeeded", ZoneID: "zone_1", Data: json.RawMessage(`{}`), CreatedAt: base.Add(time.Minute),
+	})
+	repo.CreateEvent(context.Background(), &domain.Event{
+		ID: "evt_late", Type: "payment.failed", ZoneID: "zone_1", Data: json.RawMessage(`{}`), CreatedAt: base.Add(3 * time.Hour),
+	})
+
+	var seen []string
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		url := "/v1/zones/zone_1/events/past?type=payment.failed&from=2026-03-03T14:00:00Z&to=2026-03-03T16:00:00Z&data.customer.country=EG&limit=2"
+		if cursor != "" {
+			url += "&cursor=" + cursor
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp struct {
+			Events     []*domain.Event `json:"events"`
+			NextCursor string          `json:"next_cursor"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		for _, e := range resp.Events {
+			seen = append(seen, e.ID)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+
+	if len(seen) != 5 {
+		t.Fatalf("Expected 5 matching events across pages, got %d: %v", len(seen), seen)
+	}
+	if seen[0] != "evt_failed_4" || seen[4] != "evt_failed_0" {
+		t.Errorf("Expected newest-first ordering, got %v", seen)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/zones/zone_1/events/past?cursor=not-a-cursor!", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid cursor, got %d", w.Code)
+	}
+}
+
+type recordingRetriggerer struct {
+	mu       sync.Mutex
+	replayed []*domain.Event
+}
+
+func (r *recordingRetriggerer) RetriggerEvent(ctx context.Context, event *domain.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replayed = append(r.replayed, event)
+	return nil
+}
+
+func TestWebhookReplayer_BulkReplayJob(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	retriggerer := &recordingRetriggerer{}
+	router := setupRoutes(NewFlowServer(debugService, repo), NewWebhookReplayer(repo, retriggerer, debugService, repo))
+
+	repo.CreateEvent(context.Background(), &domain.Event{ID: "evt_1", Type: "payment.failed", ZoneID: "zone_1"})
+	repo.CreateEvent(context.Background(), &domain.Event{ID: "evt_2", Type: "payment.failed", ZoneID: "zone_1"})
+	repo.CreateEvent(context.Background(), &domain.Event{ID: "evt_other", Type: "payment.failed", ZoneID: "zone_2"})
+
+	// Unknown events and other zones' events are refused before any is replayed
+	for _, ids := range []string{`["evt_1","missing"]`, `["evt_1","evt_other"]`} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_1/events/bulk-replay", strings.NewReader(`{"eventIds":`+ids+`}`)))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 replaying %s, got %d: %s", ids, w.Code, w.Body.String())
+		}
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/events/evt_other/replay", strings.NewReader(`{"zoneId":"zone_1"}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 replaying another zone's event, got %d: %s", w.Code, w.Body.String())
+	}
+
+	body := bytes.NewBufferString(`{"eventIds":["evt_1","evt_2"]}`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_1/events/bulk-replay", body))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var queued struct {
+		JobID string `json:"jobId"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &queued)
+
+	var job flow.ReplayJob
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/replay-jobs/"+queued.JobID, nil))
+		json.Unmarshal(w.Body.Bytes(), &job)
+		if job.Status == flow.ReplayJobCompleted {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if job.Status != flow.ReplayJobCompleted {
+		t.Fatalf("Expected job to complete, got status %s", job.Status)
+	}
+	if job.Processed != 2 || job.Succeeded != 2 || job.Failed != 0 {
+		t.Errorf("Unexpected progress: processed=%d succeeded=%d failed=%d", job.Processed, job.Succeeded, job.Failed)
+	}
+	if len(retriggerer.replayed) != 2 {
+		t.Errorf("Expected 2 replayed events, got %d", len(retriggerer.replayed))
+	}
+
+	// Jobs submitted without the handler's checks still keep to their zone
+	jobs := flow.NewReplayJobManager(repo, retriggerer)
+	submitted := jobs.Submit(flow.ReplayJobRequest{ZoneID: "zone_1", EventIDs: []string{"evt_other", "missing", "evt_1"}})
+	direct, _ := jobs.Get(submitted.ID)
+	for deadline = time.Now().Add(2 * time.Second); time.Now().Before(deadline) && direct.Status != flow.ReplayJobCompleted; {
+		time.Sleep(5 * time.Millisecond)
+		direct, _ = jobs.Get(submitted.ID)
+	}
+	if direct.Status != flow.ReplayJobCompleted {
+		t.Fatalf("Expected the job to complete, got status %s", direct.Status)
+	}
+	if direct.Succeeded != 1 || direct.Failed != 2 || direct.Results[0].Error != "Event not found in zone zone_1" {
+		t.Errorf("Expected the other zone's event and the unknown one to fail, got %+v", direct.Results)
+	}
+	if len(retriggerer.replayed) != 3 {
+		t.Errorf("Expected only evt_1 replayed again, got %d replays", len(retriggerer.replayed))
+	}
+}
+
+func TestWebhookReplayer_CancelReplayJob(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	replayer := NewWebhookReplayer(repo, &recordingRetriggerer{}, debugService, repo)
+	router := setupRoutes(NewFlowServer(debugService, repo), replayer)
+	for _, id := range []string{"a", "b", "c"} {
+		repo.CreateEvent(context.Background(), &domain.Event{ID: id, Type: "payment.failed", ZoneID: "zone_1"})
+	}
+
+	body := bytes.NewBufferString(`{"eventIds":["a","b","c"],"delay":60000}`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_1/events/bulk-replay", body))
+	var queued struct {
+		JobID string `json:"jobId"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &queued)
+
+	// Unfinished jobs are never expired
+	if n := replayer.jobs.ExpireFinishedJobs(0); n != 0 {
+		t.Errorf("Expected a running job to be kept, expired %d", n)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/replay-jobs/"+queued.JobID+"/cancel", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 on cancel, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var job flow.ReplayJob
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) && job.Status != flow.ReplayJobCancelled {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/replay-jobs/"+queued.JobID, nil))
+		json.Unmarshal(w.Body.Bytes(), &job)
+		time.Sleep(5 * time.Millisecond)
+	}
+	if job.Status != flow.ReplayJobCancelled {
+		t.Errorf("Expected cancelled status, got %s", job.Status)
+	}
+	if job.Processed >= 3 {
+		t.Errorf("Expected cancellation before all events were replayed, processed=%d", job.Processed)
+	}
+
+	// Finished jobs are kept for the retention period, then forgotten
+	if n := replayer.jobs.ExpireFinishedJobs(time.Hour); n != 0 {
+		t.Errorf("Expected the job to be kept within the retention, expired %d", n)
+	}
+	time.Sleep(time.Millisecond)
+	if n := replayer.jobs.ExpireFinishedJobs(0); n != 1 {
+		t.Errorf("Expected the finished job to expire, expired %d", n)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/replay-jobs/"+queued.JobID, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an expired job, got %d", w.Code)
+	}
+}
+
+func TestReplaySchedules_RecurringRun(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	retriggerer := &recordingRetriggerer{}
+	replayer := NewWebhookReplayer(repo, retriggerer, debugService, repo)
+	router := setupRoutes(NewFlowServer(debugService, repo), replayer)
+
+	now := time.Now()
+	repo.CreateEvent(context.Background(), &domain.Event{ID: "prod_1", Type: "payment.failed", ZoneID: "zone_prod", CreatedAt: now.Add(-2 * time.Hour)})
+	repo.CreateEvent(context.Background(), &domain.Event{ID: "prod_old", Type: "payment.failed", ZoneID: "zone_prod", CreatedAt: now.Add(-48 * time.Hour)})
+
+	body := bytes.NewBufferString(`{"name":"nightly","source_zone_id":"zone_prod","lookback_seconds":86400,"interval_seconds":86400}`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_staging/replay-schedules", body))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created domain.ReplaySchedule
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	scheduler := flow.NewReplayScheduler(repo, repo, replayer.jobs, time.Minute)
+	scheduler.RunDue(context.Background(), now.Add(time.Second))
+
+	schedule, _ := repo.GetReplaySchedule(context.Background(), created.ID)
+	if schedule.LastJobID == "" {
+		t.Fatal("Expected schedule run to queue a replay job")
+	}
+	if !schedule.Enabled || !schedule.NextRunAt.After(now) {
+		t.Errorf("Expected recurring schedule to stay enabled with a future run, got enabled=%v next=%v", schedule.Enabled, schedule.NextRunAt)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := replayer.jobs.Get(schedule.LastJobID); job.Status == flow.ReplayJobCompleted {
+			if job.Total != 1 || job.Results[0].EventID != "prod_1" || job.Succeeded != 1 {
+				t.Errorf("Expected only the event inside the lookback window replayed, got %+v", job.Results)
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Replay job did not complete")
+}
+
+func TestReplaySchedules_OneShotRequiresRunAt(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	router := setupRoutes(NewFlowServer(debugService, repo), NewWebhookReplayer(repo, nil, debugService, repo))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_1/replay-schedules", bytes.NewBufferString(`{"lookback_seconds":3600}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestZoneSecrets_ResolvedAtRuntime(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	secrets, err := flow.NewZoneSecrets(repo, bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewZoneSecrets failed: %v", err)
+	}
+	router := mux.NewRouter()
+	registerSecretRoutes(router, NewSecretHandler(secrets))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_1/secrets", strings.NewReader(`{"name":"api_token","value":"tok \"123\""}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "123") {
+		t.Errorf("Expected the secret value not to be returned, got %s", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_1/secrets", strings.NewReader(`{"name":"bad-name","value":"x"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid name to be rejected, got %d", w.Code)
+	}
+
+	// Stored encrypted and bound to its zone
+	stored, _ := repo.GetZoneSecret(context.Background(), "zone_1", "api_token")
+	if bytes.Contains(stored.Ciphertext, []byte("123")) {
+		t.Error("Expected the secret to be stored encrypted")
+	}
+	if _, err := secrets.ResolveSecret(context.Background(), "zone_2", "api_token"); err == nil {
+		t.Error("Expected another zone not to resolve the secret")
+	}
+
+	var auth string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	}))
+	defer target.Close()
+
+	runner := domain.NewFlowRunner(repo)
+	nodes.DefaultNodeRegistry().Install(runner)
+	runner.SetSecrets(secrets)
+	config, _ := json.Marshal(map[string]interface{}{
+		"url":     target.URL,
+		"headers": map[string]string{"Authorization": "Bearer {{secrets.api_token}}"},
+	})
+	testFlow := &domain.Flow{
+		ID: "flow_secrets", ZoneID: "zone_1",
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "call", Type: "webhook_action", Data: config}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "call"}},
+	}
+	if err := runner.Execute(context.Background(), testFlow, map[string]interface{}{}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if auth != `Bearer tok "123"` {
+		t.Errorf("Expected the secret in the request header, got %q", auth)
+	}
+	if strings.Contains(string(testFlow.Nodes[1].Data), "123") {
+		t.Error("Expected the flow definition to keep the secret reference")
+	}
+
+	// Deleted secrets fail the node that refers to them
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/v1/zones/zone_1/secrets/api_token", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+	if err := runner.Execute(context.Background(), testFlow, map[string]interface{}{}); err == nil || !strings.Contains(err.Error(), "api_token") {
+		t.Errorf("Expected a missing secret to fail the execution, got %v", err)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+	nodes.DefaultNodeRegistry().Install(runner)
+
+	// Drop mode fails executions once the burst is used up
+	limited := &domain.Flow{
+		ID: "flow_limited", ZoneID: "zone_1",
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "limit", Type: "rate_limit", Data: json.RawMessage(`{"key":"partner-{{partner}}","rps":0.01,"burst":2,"mode":"drop"}`)},
+		},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "limit"}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := runner.Execute(context.Background(), limited, map[string]interface{}{"partner": "acme"}); err != nil {
+			t.Fatalf("Execution %d failed: %v", i, err)
+		}
+	}
+	if err := runner.Execute(context.Background(), limited, map[string]interface{}{"partner": "acme"}); err == nil || !strings.Contains(err.Error(), "rate limit exceeded") {
+		t.Errorf("Expected the third execution to be dropped, got %v", err)
+	}
+	if err := runner.Execute(context.Background(), limited, map[string]interface{}{"partner": "globex"}); err != nil {
+		t.Errorf("Expected another key to have its own limit, got %v", err)
+	}
+
+	// Queue mode spaces out requests to the same destination
+	var received []time.Time
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, time.Now())
+	}))
+	defer target.Close()
+
+	config, _ := json.Marshal(map[string]interface{}{
+		"url":       target.URL,
+		"rateLimit": map[string]interface{}{"rps": 20, "burst": 1},
+	})
+	throttled := &domain.Flow{
+		ID: "flow_throttled", ZoneID: "zone_1",
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "call", Type: "webhook_action", Data: config}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "call"}},
+	}
+	for i := 0; i < 3; i++ {
+		if err := runner.Execute(context.Background(), throttled, map[string]interface{}{}); err != nil {
+			t.Fatalf("Execution %d failed: %v", i, err)
+		}
+	}
+	if len(received) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(received))
+	}
+	if spread := received[2].Sub(received[0]); spread < 80*time.Millisecond {
+		t.Errorf("Expected queued requests to be spaced at 20 rps, got them within %v", spread)
+	}
+
+	registry := nodes.DefaultNodeRegistry()
+	if problems := registry.ValidateConfig("rate_limit", json.RawMessage(`{"rps":5,"mode":"burst"}`)); len(problems) == 0 {
+		t.Error("Expected an unknown mode to be rejected")
+	}
+}
+
+// flakyHandler fails until healed
+type flakyHandler struct {
+	healed bool
+}
+
+func (h *flakyHandler) Execute(ctx context.Context, node *domain.Node, input map[string]interface{}) (map[string]interface{}, error) {
+	if !h.healed {
+		return nil, fmt.Errorf("downstream unavailable")
+	}
+	return input, nil
+}
+
+func TestDeadLetterQueue_RetryWithBackoff(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	runner := domain.NewFlowRunner(repo)
+	flaky := &flakyHandler{}
+	runner.RegisterHandler("flaky", flaky)
+	queue := flow.NewDeadLetterQueue(repo, repo, runner, time.Hour)
+	router := mux.NewRouter()
+	registerDeadLetterRoutes(router, NewDeadLetterHandler(queue))
+
+	testFlow := &domain.Flow{
+		ID: "flow_flaky", ZoneID: "zone_1", Enabled: true,
+		Nodes:       []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "call", Type: "flaky"}},
+		Edges:       []domain.Edge{{ID: "e1", Source: "trigger", Target: "call"}},
+		RetryPolicy: &domain.RetryPolicy{MaxAttempts: 3, InitialBackoffSeconds: 10, Multiplier: 3},
+	}
+	repo.CreateFlow(context.Background(), testFlow)
+	if err := runner.Execute(context.Background(), testFlow, map[string]interface{}{"amount": 42.0}); err == nil {
+		t.Fatal("Expected the execution to fail")
+	}
+
+	entries, _ := repo.ListDeadLetters(context.Background(), "zone_1", "", 10, 0)
+	if len(entries) != 1 || entries[0].Status != domain.DeadLetterScheduled {
+		t.Fatalf("Expected one scheduled dead letter, got %+v", entries)
+	}
+	entry := entries[0]
+
+	// Each failed retry backs off further until the attempts run out
+	for attempt, want := range []time.Duration{10 * time.Second, 30 * time.Second} {
+		if got := entry.NextRetryAt.Sub(entry.UpdatedAt); got != want {
+			t.Errorf("Attempt %d: expected a backoff of %v, got %v", attempt+1, want, got)
+		}
+		past := time.Now().Add(-time.Second)
+		entry.NextRetryAt = &past
+		repo.UpdateDeadLetter(context.Background(), entry)
+		queue.RetryDue(context.Background())
+		queue.Wait()
+		entry, _ = repo.GetDeadLetter(context.Background(), entry.ID)
+	}
+	if entry.Status != domain.DeadLetterDead || entry.Attempts != 3 || entry.NextRetryAt != nil {
+		t.Fatalf("Expected the entry to be dead after 3 attempts, got %s after %d", entry.Status, entry.Attempts)
+	}
+	if !strings.Contains(entry.Error, "downstream unavailable") {
+		t.Errorf("Expected the last error to be kept, got %q", entry.Error)
+	}
+
+	// A manual retry reruns the original input
+	flaky.healed = true
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_1/executions/dead-letter/"+entry.ID+"/retry", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	queue.Wait()
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/zones/zone_1/executions/dead-letter?status=resolved", nil))
+	var list struct {
+		DeadLetters []domain.DeadLetter `json:"dead_letters"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.DeadLetters) != 1 || list.DeadLetters[0].ID != entry.ID {
+		t.Fatalf("Expected the entry to be resolved, got %s", w.Body.String())
+	}
+	exec, _ := repo.GetExecution(context.Background(), list.DeadLetters[0].ExecutionID)
+	if exec == nil || !strings.Contains(string(exec.Input), `"amount":42`) {
+		t.Errorf("Expected the retry to use the original input")
+	}
+
+	// Resolved entries are not retried again, and other zones cannot see them
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_1/executions/dead-letter/"+entry.ID+"/retry", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a resolved entry, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/zones/zone_2/executions/dead-letter/"+entry.ID, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 from another zone, got %d", w.Code)
+	}
+}
+
+func TestDeadLetterQueue_RetryKeepsIdempotencyKeys(t *testing.T) {
+	var keys []string
+	payments := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		w.Write([]byte(`{"status":"refunded"}`))
+	}))
+	defer payments.Close()
+
+	repo := testutil.NewMockFlowRepository()
+	runner := domain.NewFlowRunner(repo)
+	nodes.DefaultNodeRegistry().Install(runner)
+	flaky := &flakyHandler{}
+	runner.RegisterHandler("flaky", flaky)
+	queue := flow.NewDeadLetterQueue(repo, repo, runner, time.Hour)
+
+	// The refund succeeds and a later node fails, so the retry runs the
+	// refund again
+	config, _ := json.Marshal(map[string]string{"base_url": payments.URL, "payment_intent_id": "pi_1"})
+	testFlow := &domain.Flow{
+		ID: "flow_refund_then_fail", ZoneID: "zone_1", Enabled: true,
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "refund", Type: "refund_action", Data: config}, {ID: "call", Type: "flaky"}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "refund"}, {ID: "e2", Source: "refund", Target: "call"}},
+	}
+	repo.CreateFlow(context.Background(), testFlow)
+	if err := runner.Execute(context.Background(), testFlow, map[string]interface{}{}); err == nil {
+		t.Fatal("Expected the execution to fail")
+	}
+	entries, _ := repo.ListDeadLetters(context.Background(), "zone_1", "", 10, 0)
+	if len(entries) != 1 || entries[0].OriginExecutionID == "" || entries[0].OriginExecutionID != entries[0].ExecutionID {
+		t.Fatalf("Expected one dead letter of the original execution, got %+v", entries)
+	}
+	origin := entries[0].OriginExecutionID
+
+	// A retry that fails again and one that succeeds both reuse the key
+	for _, healed := range []bool{false, true} {
+		flaky.healed = healed
+		entry, _ := repo.GetDeadLetter(context.Background(), entries[0].ID)
+		if err := queue.Retry(context.Background(), entry); err != nil {
+			t.Fatalf("Retry failed: %v", err)
+		}
+		queue.Wait()
+	}
+	entry, _ := repo.GetDeadLetter(context.Background(), entries[0].ID)
+	if entry.Status != domain.DeadLetterResolved || entry.ExecutionID == origin || entry.OriginExecutionID != origin {
+		t.Fatalf("Expected the entry resolved by a new execution of the same origin, got %+v", entry)
+	}
+	want := "flow_" + origin + "_refund_pi_1"
+	if len(keys) != 3 {
+		t.Fatalf("Expected 3 refund requests, got %d", len(keys))
+	}
+	for i, key := range keys {
+		if key != want {
+			t.Errorf("Request %d: expected the key %q, got %q", i+1, want, key)
+		}
+	}
+}
+
+// blockingHandler holds executions until released
+type blockingHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *blockingHandler) Execute(ctx context.Context, node *domain.Node, input map[string]interface{}) (map[string]interface{}, error) {
+	h.started <- struct{}{}
+	<-h.release
+	return input, nil
+}
+
+func TestDeadLetterQueue_RetryClaimsOnce(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	runner := domain.NewFlowRunner(repo)
+	blocking := &blockingHandler{started: make(chan struct{}, 2), release: make(chan struct{})}
+	runner.RegisterHandler("blocking", blocking)
+	queue := flow.NewDeadLetterQueue(repo, repo, runner, time.Hour)
+
+	testFlow := &domain.Flow{
+		ID: "flow_blocking", ZoneID: "zone_1", Enabled: true,
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "call", Type: "blocking"}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "call"}},
+	}
+	repo.CreateFlow(context.Background(), testFlow)
+	entry := &domain.DeadLetter{ID: "dlq_1", FlowID: testFlow.ID, ZoneID: "zone_1", Status: domain.DeadLetterDead, Attempts: 1}
+	repo.CreateDeadLetter(context.Background(), entry)
+
+	// Both callers loaded the entry while it was dead
+	first, _ := repo.GetDeadLetter(context.Background(), entry.ID)
+	second, _ := repo.GetDeadLetter(context.Background(), entry.ID)
+	if err := queue.Retry(context.Background(), first); err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	<-blocking.started
+	if err := queue.Retry(context.Background(), second); err == nil {
+		t.Error("Expected the entry already retrying not to be claimed again")
+	}
+	close(blocking.release)
+	queue.Wait()
+
+	if execs, _ := repo.ListExecutions(context.Background(), testFlow.ID, 10, 0); len(execs) != 1 {
+		t.Errorf("Expected 1 retry execution, got %d", len(execs))
+	}
+	if stored, _ := repo.GetDeadLetter(context.Background(), entry.ID); stored.Status != domain.DeadLetterResolved {
+		t.Errorf("Expected the entry resolved, got %s", stored.Status)
+	}
+}
+
+// staticKeys validates the API keys it was given
+type staticKeys map[string]*Principal
+
+func (k staticKeys) ValidateKey(ctx context.Context, keyHash string) (*Principal, error) {
+	if p, ok := k[keyHash]; ok {
+		return p, nil
+	}
+	return nil, ErrInvalidKey
+}
+
+// staticMemberships gives users the access it was given, by user and zone
+type staticMemberships map[string]*ZoneAccess
+
+func (m staticMemberships) ResolveZone(ctx context.Context, userID, orgID, zoneID string) (*ZoneAccess, error) {
+	access, ok := m[userID+"/"+zoneID]
+	if !ok || (orgID != "" && orgID != access.OrgID) {
+		return nil, ErrNoZoneAccess
+	}
+	return access, nil
+}
+
+func TestAuthenticator_ZoneIsolation(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	router := setupRoutes(NewFlowServer(debugService, repo), NewWebhookReplayer(repo, nil, debugService, repo))
+	registerWebhookHookRoutes(router, NewWebhookHookHandler(repo, nil))
+
+	keys := staticKeys{
+		apikey.HashKey("sk_test_zone1", "secret"): {UserID: "user_1", OrgID: "org_1", ZoneID: "zone_1", Mode: "test", Roles: []policy.Role{policy.RoleAdmin}},
+		apikey.HashKey("sk_test_reader", "secret"): {UserID: "user_1", OrgID: "org_1", ZoneID: "zone_1", Mode: "test", Roles: []policy.Role{policy.RoleAdmin},
+			Scopes: "flows:read", Scoped: true},
+		apikey.HashKey("sk_test_writer", "secret"): {UserID: "user_1", OrgID: "org_1", ZoneID: "zone_1", Mode: "test", Roles: []policy.Role{policy.RoleAdmin},
+			Scopes: "flows:write", Scoped: true},
+		apikey.HashKey("sk_test_developer", "secret"): {UserID: "user_3", OrgID: "org_1", ZoneID: "zone_1", Mode: "test", Roles: []policy.Role{policy.RoleDeveloper}},
+		apikey.HashKey("sk_live_developer", "secret"): {UserID: "user_3", OrgID: "org_1", ZoneID: "zone_1", Mode: "live", Roles: []policy.Role{policy.RoleDeveloper}},
+		apikey.HashKey("sk_test_livezone", "secret"):  {UserID: "user_1", OrgID: "org_1", ZoneID: "zone_1", Mode: "live", Roles: []policy.Role{policy.RoleAdmin}},
+	}
+	members := staticMemberships{
+		"user_2/zone_1": {OrgID: "org_1", Mode: "test", Role: string(policy.RoleViewer)},
+		"user_4/zone_1": {OrgID: "org_1", Mode: "test", Role: string(policy.RoleDeveloper)},
+		"user_4/zone_3": {OrgID: "org_1", Mode: "live", Role: string(policy.RoleDeveloper)},
+	}
+	auth := NewAuthenticator(keys, members, "secret", policy.NewHardcodedPolicyEngine())
+	auth.ResolveZone("flowId", repo.GetFlowZoneID)
+	router.Use(auth.Middleware)
+
+	for _, f := range []*domain.Flow{
+		{ID: "flow_1", OrgID: "org_1", ZoneID: "zone_1", Name: "Mine", Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}}},
+		{ID: "flow_2", OrgID: "org_2", ZoneID: "zone_2", Name: "Theirs", Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}}},
+		{ID: "flow_3", OrgID: "org_1", ZoneID: "zone_3", Name: "Live", Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}}},
+	} {
+		repo.CreateFlow(context.Background(), f)
+	}
+	viewerToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwtutil.Claims{
+		UserID: "user_2", OrgID: "org_1", ZoneID: "zone_1", Role: string(policy.RoleViewer),
+	}).SignedString(jwtutil.SecretKey)
+	// Session tokens name no organization nor zone
+	sessionToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwtutil.Claims{UserID: "user_4"}).SignedString(jwtutil.SecretKey)
+
+	tests := []struct {
+		name, method, path, auth, body string
+		want                           int
+	}{
+		{"no credentials", "GET", "/v1/flows/flow_1", "", "", http.StatusUnauthorized},
+		{"unknown key", "GET", "/v1/flows/flow_1", "sk_test_other", "", http.StatusUnauthorized},
+		{"bad token", "GET", "/v1/flows/flow_1", "not-a-jwt", "", http.StatusUnauthorized},
+		{"own flow", "GET", "/v1/flows/flow_1", "sk_test_zone1", "", http.StatusOK},
+		{"other zone's flow", "GET", "/v1/flows/flow_2", "sk_test_zone1", "", http.StatusNotFound},
+		{"other zone's flow by ID", "DELETE", "/v1/flows/flow_2", "sk_test_zone1", "", http.StatusNotFound},
+		{"own zone", "GET", "/v1/zones/zone_1/flows", "sk_test_zone1", "", http.StatusOK},
+		{"other zone", "GET", "/v1/zones/zone_2/flows", "sk_test_zone1", "", http.StatusForbidden},
+		{"create in other zone", "POST", "/v1/flows", "sk_test_zone1", `{"zone_id":"zone_2","name":"x","nodes":[{"id":"trigger","type":"eventTrigger"}]}`, http.StatusForbidden},
+		{"bulk with other zone's flow", "POST", "/v1/flows/bulk", "sk_test_zone1", `{"flowIds":["flow_1","flow_2"],"enabled":true}`, http.StatusNotFound},
+		{"viewer reads", "GET", "/v1/flows/flow_1", viewerToken, "", http.StatusOK},
+		{"viewer writes", "POST", "/v1/flows/flow_1/disable", viewerToken, "", http.StatusForbidden},
+		{"zone token in another zone", "GET", "/v1/zones/zone_2/flows", viewerToken, "", http.StatusForbidden},
+		{"session in a member zone", "GET", "/v1/zones/zone_1/flows", sessionToken, "", http.StatusOK},
+		{"session in another organization's zone", "GET", "/v1/zones/zone_2/flows", sessionToken, "", http.StatusForbidden},
+		{"session reads a flow", "GET", "/v1/flows/flow_1", sessionToken, "", http.StatusOK},
+		{"session reads another organization's flow", "GET", "/v1/flows/flow_2", sessionToken, "", http.StatusNotFound},
+		{"session writes in a test zone", "POST", "/v1/flows/flow_1/disable", sessionToken, "", http.StatusOK},
+		{"session writes in a live zone", "POST", "/v1/flows/flow_3/disable", sessionToken, "", http.StatusForbidden},
+		{"session without a zone", "POST", "/v1/flows", sessionToken, `{"zone_id":"zone_1","name":"x","nodes":[{"id":"trigger","type":"eventTrigger"}]}`, http.StatusForbidden},
+		{"replay schedule from own zone", "POST", "/v1/zones/zone_1/replay-schedules", "sk_test_zone1", `{"name":"x","source_zone_id":"zone_1","lookback_seconds":60,"interval_seconds":60}`, http.StatusCreated},
+		{"replay schedule from other zone", "POST", "/v1/zones/zone_1/replay-schedules", "sk_test_zone1", `{"name":"x","source_zone_id":"zone_2","lookback_seconds":60,"interval_seconds":60}`, http.StatusForbidden},
+		{"public webhook route", "POST", "/v1/zones/zone_2/hooks/hook_1", "", `{}`, http.StatusNotFound},
+		{"read scope reads", "GET", "/v1/flows/flow_1", "sk_test_reader", "", http.StatusOK},
+		{"read scope writes", "POST", "/v1/flows/flow_1/disable", "sk_test_reader", "", http.StatusForbidden},
+		{"write scope writes", "POST", "/v1/flows/flow_1/disable", "sk_test_writer", "", http.StatusOK},
+		{"write scope debugs", "POST", "/v1/flows/flow_1/zones/zone_1/debug", "sk_test_writer", "", http.StatusForbidden},
+		{"write scope deletes", "DELETE", "/v1/flows/flow_1", "sk_test_writer", "", http.StatusForbidden},
+		{"developer writes in a test zone", "POST", "/v1/flows/flow_1/disable", "sk_test_developer", "", http.StatusOK},
+		{"developer writes in a live zone", "POST", "/v1/flows/flow_1/disable", "sk_live_developer", "", http.StatusForbidden},
+		{"test key writes in a live zone", "POST", "/v1/flows/flow_1/disable", "sk_test_livezone", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.auth != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	// Policy denials answer the standard body
+	req := httptest.NewRequest("POST", "/v1/flows/flow_1/disable", nil)
+	req.Header.Set("Authorization", "Bearer "+viewerToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var denied policy.DeniedResponse
+	json.Unmarshal(w.Body.Bytes(), &denied)
+	if denied.Code != policy.ErrorCode || denied.Action != policy.ActionFlowDeploy || denied.Reason == "" {
+		t.Errorf("Expected a policy_denied body for flow.deploy, got %s", w.Body.String())
+	}
+	req = httptest.NewRequest("POST", "/v1/flows/flow_1/disable", nil)
+	req.Header.Set("Authorization", "Bearer sk_live_developer")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &denied)
+	if denied.Action != policy.ActionFlowDeployLive {
+		t.Errorf("Expected live deploys to be denied as flow.deploy.live, got %s", w.Body.String())
+	}
+
+	// Flows created without a zone land in the caller's zone and organization
+	req = httptest.NewRequest("POST", "/v1/flows", strings.NewReader(`{"name":"Defaulted","org_id":"org_2","nodes":[{"id":"trigger","type":"eventTrigger"}]}`))
+	req.Header.Set("Authorization", "Bearer sk_test_zone1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var created domain.Flow
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if w.Code != http.StatusCreated || created.ZoneID != "zone_1" || created.OrgID != "org_1" {
+		t.Errorf("Expected the flow in zone_1 of org_1, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Sessions name the zone they act in with X-Zone-ID
+	for zone, want := range map[string]int{"zone_1": http.StatusCreated, "zone_2": http.StatusForbidden} {
+		req = httptest.NewRequest("POST", "/v1/flows", strings.NewReader(`{"zone_id":"zone_1","name":"Session","nodes":[{"id":"trigger","type":"eventTrigger"}]}`))
+		req.Header.Set("Authorization", "Bearer "+sessionToken)
+		req.Header.Set("X-Zone-ID", zone)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("Expected status %d creating a flow with X-Zone-ID %s, got %d: %s", want, zone, w.Code, w.Body.String())
+		}
+	}
+
+	// Live zones are checked as live deploys for sessions too
+	req = httptest.NewRequest("POST", "/v1/flows/flow_3/disable", nil)
+	req.Header.Set("Authorization", "Bearer "+sessionToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &denied)
+	if denied.Action != policy.ActionFlowDeployLive {
+		t.Errorf("Expected the session's live deploy to be denied as flow.deploy.live, got %s", w.Body.String())
+	}
+}
+
+func TestWebhookReplayer_IdempotentReplay(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	retriggerer := &recordingRetriggerer{}
+	replayer := NewWebhookReplayer(repo, retriggerer, debugService, repo)
+	replayer.jobs.SetAttemptStore(repo, time.Hour)
+	router := setupRoutes(NewFlowServer(debugService, repo), replayer)
+
+	repo.CreateEvent(context.Background(), &domain.Event{ID: "evt_1", Type: "payment.failed", ZoneID: "zone_1"})
+	repo.CreateEvent(context.Background(), &domain.Event{ID: "evt_2", Type: "payment.failed", ZoneID: "zone_1"})
+
+	replay := func(key string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("POST", "/v1/events/evt_1/replay", strings.NewReader(`{"zoneId":"zone_1"}`))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	_, first := replay("key_1")
+	_, again := replay("key_1")
+	if again["deduplicated"] != true || again["eventId"] != first["eventId"] {
+		t.Errorf("Expected the repeated key to return the first replay, got %v", again)
+	}
+	_, keyless := replay("")
+	if keyless["deduplicated"] != true {
+		t.Errorf("Expected a keyless replay of an already replayed event to be deduplicated, got %v", keyless)
+	}
+	_, newKey := replay("key_2")
+	if newKey["deduplicated"] == true {
+		t.Errorf("Expected a new key to replay the event again, got %v", newKey)
+	}
+	if len(retriggerer.replayed) != 2 {
+		t.Fatalf("Expected 2 replayed events, got %d", len(retriggerer.replayed))
+	}
+
+	// Bulk replays skip events replayed before and duplicates within the job
+	body := strings.NewReader(`{"eventIds":["evt_1","evt_2","evt_2"]}`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_1/events/bulk-replay", body))
+	var queued struct {
+		JobID string `json:"jobId"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &queued)
+
+	var job flow.ReplayJob
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) && job.Status != flow.ReplayJobCompleted {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/replay-jobs/"+queued.JobID, nil))
+		json.Unmarshal(w.Body.Bytes(), &job)
+		time.Sleep(5 * time.Millisecond)
+	}
+	if job.Succeeded != 3 || job.Deduplicated != 2 {
+		t.Errorf("Expected 3 successes of which 2 deduplicated, got %d and %d", job.Succeeded, job.Deduplicated)
+	}
+	if len(retriggerer.replayed) != 3 {
+		t.Errorf("Expected 3 replayed events, got %d", len(retriggerer.replayed))
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/events/evt_1/replays", nil))
+	var listed struct {
+		Attempts []domain.ReplayAttempt `json:"attempts"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	if len(listed.Attempts) != 2 || listed.Attempts[0].IdempotencyKey != "key_2" {
+		t.Errorf("Expected the 2 replays of evt_1, newest first, got %s", w.Body.String())
+	}
+}
+
+func TestZoneSecrets_RedactedFromExecutions(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	secrets, err := flow.NewZoneSecrets(repo, bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewZoneSecrets failed: %v", err)
+	}
+	if _, err := secrets.Put(context.Background(), "zone_1", "api_token", "tok_live_123"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	runner := domain.NewFlowRunner(repo)
+	nodes.DefaultNodeRegistry().Install(runner)
+	runner.SetSecrets(secrets)
+	// Nothing listens on the port, so the request fails with an error
+	// naming the URL
+	config, _ := json.Marshal(map[string]interface{}{
+		"url":     "http://127.0.0.1:1/hook?token={{secrets.api_token}}",
+		"timeout": "1s",
+	})
+	testFlow := &domain.Flow{
+		ID: "flow_secret_url", ZoneID: "zone_1",
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "call", Type: "webhook_action", Data: config}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "call"}},
+	}
+	err = runner.Execute(context.Background(), testFlow, map[string]interface{}{})
+	if err == nil {
+		t.Fatal("Expected the webhook to fail")
+	}
+	if strings.Contains(err.Error(), "tok_live_123") {
+		t.Errorf("Expected the secret redacted from the returned error, got %v", err)
+	}
+
+	execs, _ := repo.ListExecutions(context.Background(), testFlow.ID, 10, 0)
+	if len(execs) != 1 {
+		t.Fatalf("Expected 1 execution, got %d", len(execs))
+	}
+	stored, _ := json.Marshal(execs[0])
+	if strings.Contains(string(stored), "tok_live_123") {
+		t.Errorf("Expected the secret not stored with the execution, got %s", stored)
+	}
+	step := execs[0].Steps[len(execs[0].Steps)-1]
+	if step.Status != domain.ExecutionFailed || !strings.Contains(step.Error, "token=[REDACTED]") {
+		t.Errorf("Expected the failed step to keep a redacted error, got %+v", step)
+	}
+}
+
+func TestWebhookDeliveries_Captured(t *testing.T) {
+	calls := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"score":42}`))
+	}))
+	defer target.Close()
+
+	repo := testutil.NewMockFlowRepository()
+	runner := domain.NewFlowRunner(repo)
+	registry := nodes.DefaultNodeRegistry()
+	registry.CaptureDeliveries(repo)
+	registry.Install(runner)
+
+	captured, _ := json.Marshal(map[string]interface{}{
+		"url":               target.URL,
+		"method":            "POST",
+		"headers":           map[string]string{"Authorization": "Bearer secret"},
+		"retryCount":        1,
+		"retryDelay":        "1ms",
+		"captureDeliveries": true,
+	})
+	plain, _ := json.Marshal(map[string]interface{}{"url": target.URL})
+	f := &domain.Flow{
+		ID: "flow_deliveries", ZoneID: "zone_1",
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "call", Type: "webhook_action", Data: captured},
+			{ID: "notify", Type: "webhook_action", Data: plain},
+		},
+		Edges: []domain.Edge{
+			{ID: "e1", Source: "trigger", Target: "call"},
+			{ID: "e2", Source: "call", Target: "notify"},
+		},
+	}
+	if err := runner.Execute(context.Background(), f, map[string]interface{}{}); err != nil {
+		t.Fatalf("Execution failed: %v", err)
+	}
+
+	r := mux.NewRouter()
+	registerDeliveryRoutes(r, NewDeliveryHandler(repo))
+	list := func(query string) []*domain.WebhookDelivery {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/flows/flow_deliveries/deliveries"+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var res struct {
+			Deliveries []*domain.WebhookDelivery `json:"deliveries"`
+		}
+		json.NewDecoder(rr.Body).Decode(&res)
+		return res.Deliveries
+	}
+
+	deliveries := list("")
+	if len(deliveries) != 2 {
+		t.Fatalf("Expected 2 deliveries of the capturing node only, got %d", len(deliveries))
+	}
+	if deliveries[0].Attempt != 2 || deliveries[0].StatusCode != http.StatusOK || deliveries[0].ResponseBody != `{"score":42}` {
+		t.Errorf("Unexpected latest delivery: %+v", deliveries[0])
+	}
+	if deliveries[1].Attempt != 1 || deliveries[1].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected first delivery: %+v", deliveries[1])
+	}
+	if got := deliveries[0].RequestHeaders["Authorization"]; got != "[REDACTED]" {
+		t.Errorf("Expected the Authorization header to be redacted, got %q", got)
+	}
+	if deliveries[0].ExecutionID == "" || deliveries[0].NodeID != "call" {
+		t.Errorf("Expected the delivery to name its execution and node, got %+v", deliveries[0])
+	}
+
+	if got := list("?execution_id=" + deliveries[0].ExecutionID); len(got) != 2 {
+		t.Errorf("Expected 2 deliveries for the execution, got %d", len(got))
+	}
+	if got := list("?execution_id=exec_other"); len(got) != 0 {
+		t.Errorf("Expected no deliveries for another execution, got %d", len(got))
+	}
+}
+
+func TestFlowServer_FlowTemplates(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	debugService := flow.NewDebugService(repo)
+	router := setupRoutes(NewFlowServer(debugService, repo), NewWebhookReplayer(repo, nil, debugService, repo))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/flow-templates", nil))
+	var gallery struct {
+		Templates []flow.FlowTemplate `json:"templates"`
+	}
+	json.NewDecoder(w.Body).Decode(&gallery)
+	if w.Code != http.StatusOK || len(gallery.Templates) != 3 {
+		t.Fatalf("Expected 3 templates, got %d (status %d)", len(gallery.Templates), w.Code)
+	}
+
+	// Every template creates a valid flow from its defaults
+	required := map[string]interface{}{"from_address": "billing@example.com", "smtp_host": "smtp.example.com"}
+	for _, tmpl := range gallery.Templates {
+		params := map[string]interface{}{}
+		for _, p := range tmpl.Parameters {
+			if p.Required {
+				params[p.Name] = required[p.Name]
+			}
+		}
+		body, _ := json.Marshal(map[string]interface{}{"parameters": params})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_test/flows/from-template/"+tmpl.ID, bytes.NewReader(body)))
+		if w.Code != http.StatusCreated {
+			t.Errorf("Expected template %s to create a flow, got %d: %s", tmpl.ID, w.Code, w.Body.String())
+		}
+	}
+
+	// Parameters are substituted with their types
+	body := `{"name":"High risk","parameters":{"risk_threshold":65,"channel":"#fraud"}}`
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_test/flows/from-template/fraud-hold-escalation", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created domain.Flow
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.Name != "High risk" || created.ZoneID != "zone_test" || created.Enabled {
+		t.Errorf("Unexpected flow: %+v", created)
+	}
+	for _, node := range created.Nodes {
+		switch node.ID {
+		case "check":
+			if !strings.Contains(string(node.Data), `"value":65`) {
+				t.Errorf("Expected the threshold as a number, got %s", node.Data)
+			}
+		case "alert":
+			if !strings.Contains(string(node.Data), `"channel":"#fraud"`) || !strings.Contains(string(node.Data), "{{secrets.SLACK_WEBHOOK_URL}}") {
+				t.Errorf("Expected the channel and default webhook URL, got %s", node.Data)
+			}
+		}
+	}
+
+	// Missing, mistyped and unknown parameters are rejected
+	for path, body := range map[string]string{
+		"refund-confirmation-email":  `{"parameters":{"smtp_host":"smtp.example.com"}}`,
+		"fraud-hold-escalation":      `{"parameters":{"risk_threshold":"high"}}`,
+		"payment-failed-slack-alert": `{"parameters":{"colour":"red"}}`,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_test/flows/from-template/"+path, strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", path, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/zones/zone_test/flows/from-template/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown template, got %d", w.Code)
+	}
+}
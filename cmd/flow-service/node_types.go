@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/nodes"
+)
+
+// NodeTypeHandler lists the node types flows can use
+type NodeTypeHandler struct {
+	registry *nodes.NodeRegistry
+}
+
+func NewNodeTypeHandler(registry *nodes.NodeRegistry) *NodeTypeHandler {
+	return &NodeTypeHandler{registry: registry}
+}
+
+func registerNodeTypeRoutes(r *mux.Router, h *NodeTypeHandler) {
+	r.HandleFunc("/v1/node-types", h.ListNodeTypes).Methods("GET")
+}
+
+// ListNodeTypes returns every registered node type with its config schema
+func (h *NodeTypeHandler) ListNodeTypes(w http.ResponseWriter, r *http.Request) {
+	types := h.registry.Types()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"node_types": types,
+		"count":      len(types),
+	})
+}
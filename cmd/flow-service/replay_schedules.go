@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// Replay Schedule Handlers
+
+type replayScheduleRequest struct {
+	Name            string    `json:"name"`
+	SourceZoneID    string    `json:"source_zone_id"`
+	EventTypes      []string  `json:"event_types"`
+	LookbackSeconds int       `json:"lookback_seconds"`
+	IntervalSeconds int       `json:"interval_seconds"`
+	DelayMs         int       `json:"delay_ms"`
+	Enabled         *bool     `json:"enabled"`
+	RunAt           time.Time `json:"run_at"` // First (or only) run; defaults to now for recurring schedules
+}
+
+func (req *replayScheduleRequest) validate() error {
+	if req.LookbackSeconds <= 0 {
+		return fmt.Errorf("lookback_seconds must be positive")
+	}
+	if req.IntervalSeconds < 0 || req.DelayMs < 0 {
+		return fmt.Errorf("interval_seconds and delay_ms must not be negative")
+	}
+	if req.IntervalSeconds == 0 && req.RunAt.IsZero() {
+		return fmt.Errorf("one-shot schedules require run_at")
+	}
+	return nil
+}
+
+func (req *replayScheduleRequest) applyTo(schedule *domain.ReplaySchedule) {
+	schedule.Name = req.Name
+	schedule.SourceZoneID = req.SourceZoneID
+	if schedule.SourceZoneID == "" {
+		schedule.SourceZoneID = schedule.ZoneID
+	}
+	schedule.EventTypes = req.EventTypes
+	schedule.LookbackSeconds = req.LookbackSeconds
+	schedule.IntervalSeconds = req.IntervalSeconds
+	schedule.DelayMs = req.DelayMs
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	if !req.RunAt.IsZero() {
+		schedule.NextRunAt = req.RunAt
+	}
+	schedule.UpdatedAt = time.Now()
+}
+
+func (wr *WebhookReplayer) CreateReplaySchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	zoneID := vars["zoneId"]
+
+	var req replayScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Events are read from the source zone, so the caller must own it too
+	if req.SourceZoneID != "" && !authorizeZone(w, r, req.SourceZoneID) {
+		return
+	}
+
+	now := time.Now()
+	schedule := &domain.ReplaySchedule{
+		ID:        fmt.Sprintf("rsched_%d", now.UnixNano()),
+		ZoneID:    zoneID,
+		Enabled:   true,
+		NextRunAt: now,
+		CreatedAt: now,
+	}
+	req.applyTo(schedule)
+
+	if err := wr.schedules.CreateReplaySchedule(r.Context(), schedule); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create replay schedule: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(schedule)
+}
+
+func (wr *WebhookReplayer) ListReplaySchedules(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	zoneID := vars["zoneId"]
+
+	schedules, err := wr.schedules.ListReplaySchedules(r.Context(), zoneID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list replay schedules: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"schedules": schedules,
+		"count":     len(schedules),
+	})
+}
+
+// zoneSchedule loads a schedule and checks it belongs to the zone in the path
+func (wr *WebhookReplayer) zoneSchedule(w http.ResponseWriter, r *http.Request) (*domain.ReplaySchedule, bool) {
+	vars := mux.Vars(r)
+
+	schedule, err := wr.schedules.GetReplaySchedule(r.Context(), vars["scheduleId"])
+	if err != nil || schedule.ZoneID != vars["zoneId"] {
+		if err != nil && err != domain.ErrReplayScheduleNotFound {
+			http.Error(w, fmt.Sprintf("Failed to get replay schedule: %v", err), http.StatusInternalServerError)
+		} else {
+			http.Error(w, "Replay schedule not found", http.StatusNotFound)
+		}
+		return nil, false
+	}
+	return schedule, true
+}
+
+func (wr *WebhookReplayer) GetReplaySchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := wr.zoneSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+func (wr *WebhookReplayer) UpdateReplaySchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := wr.zoneSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	var req replayScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.RunAt.IsZero() {
+		req.RunAt = schedule.NextRunAt
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Events are read from the source zone, so the caller must own it too
+	if req.SourceZoneID != "" && !authorizeZone(w, r, req.SourceZoneID) {
+		return
+	}
+	req.applyTo(schedule)
+
+	if err := wr.schedules.UpdateReplaySchedule(r.Context(), schedule); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update replay schedule: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+func (wr *WebhookReplayer) DeleteReplaySchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := wr.zoneSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := wr.schedules.DeleteReplaySchedule(r.Context(), schedule.ID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete replay schedule: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/flow"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// ScheduleHandler exposes CRUD endpoints for flow schedules
+type ScheduleHandler struct {
+	registry *flow.ScheduleRegistry
+}
+
+func NewScheduleHandler(registry *flow.ScheduleRegistry) *ScheduleHandler {
+	return &ScheduleHandler{registry: registry}
+}
+
+func registerScheduleRoutes(r *mux.Router, h *ScheduleHandler) {
+	r.HandleFunc("/v1/zones/{zoneId}/schedules", h.ListSchedules).Methods("GET")
+	r.HandleFunc("/v1/flows/{flowId}/schedule", h.GetSchedule).Methods("GET")
+	r.HandleFunc("/v1/flows/{flowId}/schedule", h.PutSchedule).Methods("PUT")
+	r.HandleFunc("/v1/flows/{flowId}/schedule", h.DeleteSchedule).Methods("DELETE")
+}
+
+func (h *ScheduleHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	schedules, err := h.registry.List(r.Context(), vars["zoneId"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list schedules: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"schedules": schedules,
+		"count":     len(schedules),
+	})
+}
+
+func (h *ScheduleHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	schedule, err := h.registry.Get(r.Context(), vars["flowId"])
+	if err != nil {
+		if err == domain.ErrFlowScheduleNotFound {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("Failed to get schedule: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// PutSchedule creates or replaces a flow's schedule. Schedules are enabled
+// unless the request says otherwise.
+func (h *ScheduleHandler) PutSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req struct {
+		CronExpr        string `json:"cron_expression"`
+		IntervalSeconds int    `json:"interval_seconds"`
+		Timezone        string `json:"timezone"`
+		Enabled         *bool  `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	schedule := &domain.FlowSchedule{
+		FlowID:          vars["flowId"],
+		CronExpr:        req.CronExpr,
+		IntervalSeconds: req.IntervalSeconds,
+		Timezone:        req.Timezone,
+		Enabled:         req.Enabled == nil || *req.Enabled,
+	}
+	saved, err := h.registry.Save(r.Context(), schedule)
+	if err != nil {
+		switch {
+		case errors.Is(err, flow.ErrInvalidSchedule):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err == domain.ErrFlowNotFound:
+			http.Error(w, "Flow not found", http.StatusNotFound)
+		default:
+			http.Error(w, fmt.Sprintf("Failed to save schedule: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+func (h *ScheduleHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.registry.Delete(r.Context(), vars["flowId"]); err != nil {
+		if err == domain.ErrFlowScheduleNotFound {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("Failed to delete schedule: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
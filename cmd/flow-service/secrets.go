@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/flow"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// SecretHandler manages the encrypted credentials of a zone. Values can be
+// written but are never read back; flows refer to them as {{secrets.NAME}}.
+type SecretHandler struct {
+	secrets *flow.ZoneSecrets
+}
+
+func NewSecretHandler(secrets *flow.ZoneSecrets) *SecretHandler {
+	return &SecretHandler{secrets: secrets}
+}
+
+func registerSecretRoutes(r *mux.Router, h *SecretHandler) {
+	r.HandleFunc("/v1/zones/{zoneId}/secrets", h.ListSecrets).Methods("GET")
+	r.HandleFunc("/v1/zones/{zoneId}/secrets", h.CreateSecret).Methods("POST")
+	r.HandleFunc("/v1/zones/{zoneId}/secrets/{name}", h.PutSecret).Methods("PUT")
+	r.HandleFunc("/v1/zones/{zoneId}/secrets/{name}", h.DeleteSecret).Methods("DELETE")
+}
+
+func (h *SecretHandler) ListSecrets(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	secrets, err := h.secrets.List(r.Context(), vars["zoneId"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list secrets: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"secrets": secrets,
+		"count":   len(secrets),
+	})
+}
+
+func (h *SecretHandler) CreateSecret(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	h.saveSecret(w, r, req.Name, req.Value, http.StatusCreated)
+}
+
+// PutSecret creates the named secret or replaces its value
+func (h *SecretHandler) PutSecret(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	h.saveSecret(w, r, mux.Vars(r)["name"], req.Value, http.StatusOK)
+}
+
+func (h *SecretHandler) saveSecret(w http.ResponseWriter, r *http.Request, name, value string, status int) {
+	if value == "" {
+		http.Error(w, "value is required", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := h.secrets.Put(r.Context(), mux.Vars(r)["zoneId"], name, value)
+	if err == flow.ErrInvalidSecretName {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save secret: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(secret)
+}
+
+func (h *SecretHandler) DeleteSecret(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.secrets.Delete(r.Context(), vars["zoneId"], vars["name"]); err != nil {
+		if err == domain.ErrZoneSecretNotFound {
+			http.Error(w, "Secret not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to delete secret: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
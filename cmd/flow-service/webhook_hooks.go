@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/flow"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/triggers"
+)
+
+// maxWebhookBody caps the size of inbound webhook payloads
+const maxWebhookBody = 1 << 20
+
+// WebhookHookHandler manages inbound webhook hooks and turns signed
+// deliveries into events that trigger flows
+type WebhookHookHandler struct {
+	hooks      domain.WebhookHookStore
+	dispatcher *flow.TriggerDispatcher
+	inflight   sync.WaitGroup
+}
+
+func NewWebhookHookHandler(hooks domain.WebhookHookStore, dispatcher *flow.TriggerDispatcher) *WebhookHookHandler {
+	return &WebhookHookHandler{
+		hooks:      hooks,
+		dispatcher: dispatcher,
+	}
+}
+
+func registerWebhookHookRoutes(r *mux.Router, h *WebhookHookHandler) {
+	r.HandleFunc("/v1/zones/{zoneId}/hooks", h.CreateWebhookHook).Methods("POST")
+	r.HandleFunc("/v1/zones/{zoneId}/hooks", h.ListWebhookHooks).Methods("GET")
+	r.HandleFunc("/v1/zones/{zoneId}/hooks/{hookId}", h.DeleteWebhookHook).Methods("DELETE")
+	r.HandleFunc("/v1/zones/{zoneId}/hooks/{hookId}", h.ReceiveWebhook).Methods("POST")
+}
+
+// Wait blocks until executions started by received webhooks have finished
+func (h *WebhookHookHandler) Wait() {
+	h.inflight.Wait()
+}
+
+func (h *WebhookHookHandler) CreateWebhookHook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req struct {
+		Name      string `json:"name"`
+		EventType string `json:"event_type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	hook := &domain.WebhookHook{
+		ID:        fmt.Sprintf("hook_%d", now.UnixNano()),
+		ZoneID:    vars["zoneId"],
+		Name:      req.Name,
+		EventType: req.EventType,
+		Secret:    "whsec_" + hex.EncodeToString(secret),
+		Enabled:   true,
+		CreatedAt: now,
+	}
+	if err := h.hooks.CreateWebhookHook(r.Context(), hook); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create webhook hook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hook)
+}
+
+func (h *WebhookHookHandler) ListWebhookHooks(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	hooks, err := h.hooks.ListWebhookHooks(r.Context(), vars["zoneId"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list webhook hooks: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hooks": hooks,
+		"count": len(hooks),
+	})
+}
+
+// zoneHook loads a hook and checks it belongs to the zone in the path
+func (h *WebhookHookHandler) zoneHook(w http.ResponseWriter, r *http.Request) (*domain.WebhookHook, bool) {
+	vars := mux.Vars(r)
+
+	hook, err := h.hooks.GetWebhookHook(r.Context(), vars["hookId"])
+	if err != nil || hook.ZoneID != vars["zoneId"] {
+		if err != nil && err != domain.ErrWebhookHookNotFound {
+			http.Error(w, fmt.Sprintf("Failed to get webhook hook: %v", err), http.StatusInternalServerError)
+		} else {
+			http.Error(w, "Webhook hook not found", http.StatusNotFound)
+		}
+		return nil, false
+	}
+	return hook, true
+}
+
+func (h *WebhookHookHandler) DeleteWebhookHook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.zoneHook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.hooks.DeleteWebhookHook(r.Context(), hook.ID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete webhook hook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReceiveWebhook verifies a delivery's HMAC-SHA256 signature of its
+// X-Sapliy-Timestamp and body, converts its JSON payload into an event and
+// triggers the zone's matching flows. The flows run in the background; the
+// response reports how many were started.
+func (h *WebhookHookHandler) ReceiveWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.zoneHook(w, r)
+	if !ok {
+		return
+	}
+	if !hook.Enabled {
+		http.Error(w, "Webhook hook is disabled", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	signature := r.Header.Get("X-Sapliy-Signature")
+	if signature == "" {
+		signature = r.Header.Get("X-Webhook-Signature")
+	}
+	timestamp := r.Header.Get("X-Sapliy-Timestamp")
+	if timestamp == "" {
+		timestamp = r.Header.Get("X-Webhook-Timestamp")
+	}
+	if !validSignature(hook.Secret, timestamp, body, signature, time.Now()) {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil || payload == nil {
+		http.Error(w, "Payload must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	event := &triggers.Event{
+		ID:        r.Header.Get("X-Sapliy-Event-ID"),
+		Type:      hook.EventType,
+		ZoneID:    hook.ZoneID,
+		Data:      payload,
+		CreatedAt: time.Now(),
+	}
+	if event.ID == "" {
+		event.ID = fmt.Sprintf("whk_%d", event.CreatedAt.UnixNano())
+	}
+	if event.Type == "" {
+		event.Type = r.Header.Get("X-Sapliy-Event-Type")
+	}
+	if event.Type == "" {
+		event.Type, _ = payload["type"].(string)
+	}
+	if event.Type == "" {
+		http.Error(w, "Event type is required", http.StatusBadRequest)
+		return
+	}
+
+	flows, err := h.dispatcher.Match(r.Context(), event)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to match flows: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if len(flows) > 0 {
+		input := make(map[string]interface{}, len(payload)+1)
+		for k, v := range payload {
+			input[k] = v
+		}
+		input["zone_id"] = hook.ZoneID
+
+		h.inflight.Add(1)
+		go func() {
+			defer h.inflight.Done()
+			if err := h.dispatcher.Run(context.Background(), flows, event, input); err != nil {
+				log.Printf("Webhook %s: %v", hook.ID, err)
+			}
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"event_id":        event.ID,
+		"flows_triggered": len(flows),
+	})
+}
+
+// webhookTolerance is how far a delivery's timestamp may be from now, so
+// that a captured delivery cannot be replayed later
+const webhookTolerance = 5 * time.Minute
+
+// validSignature checks a hex HMAC-SHA256 of the Unix timestamp, a dot and
+// the body, optionally prefixed with "sha256=", in constant time. The
+// timestamp must be within webhookTolerance of now.
+func validSignature(secret, timestamp string, body []byte, signature string, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > webhookTolerance || d < -webhookTolerance {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
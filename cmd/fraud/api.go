@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sapliy/fintech-ecosystem/internal/fraud"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// apiRoutes serves the pre-authorization check and, with a database, the
+// reviewers' case and list APIs next to the metrics and health endpoints
+func apiRoutes(s *scorer) http.Handler {
+	r := mux.NewRouter()
+	r.Handle("/metrics", promhttp.Handler())
+	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		jsonutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "active"})
+	})
+	r.HandleFunc(fraud.CheckPath, s.handleCheck)
+
+	if s.cases != nil {
+		h := &caseHandler{cases: s.cases}
+		r.HandleFunc("/v1/fraud/cases", h.ListCases).Methods(http.MethodGet)
+		r.HandleFunc("/v1/fraud/cases/{id}", h.GetCase).Methods(http.MethodGet)
+		r.HandleFunc("/v1/fraud/cases/{id}/assign", h.AssignCase).Methods(http.MethodPost)
+		r.HandleFunc("/v1/fraud/cases/{id}/transition", h.TransitionCase).Methods(http.MethodPost)
+		r.HandleFunc("/v1/fraud/cases/{id}/notes", h.AddCaseNote).Methods(http.MethodPost)
+	}
+	if s.outcomes != nil {
+		h := &performanceHandler{outcomes: s.outcomes}
+		r.HandleFunc("/v1/fraud/rules/{id}/performance", h.RulePerformance).Methods(http.MethodGet)
+	}
+	if s.lists != nil {
+		h := &listHandler{lists: s.lists}
+		r.HandleFunc("/v1/fraud/lists", h.ListEntries).Methods(http.MethodGet)
+		r.HandleFunc("/v1/fraud/lists", h.CreateEntry).Methods(http.MethodPost)
+		r.HandleFunc("/v1/fraud/lists/{id}", h.GetEntry).Methods(http.MethodGet)
+		r.HandleFunc("/v1/fraud/lists/{id}", h.UpdateEntry).Methods(http.MethodPatch)
+		r.HandleFunc("/v1/fraud/lists/{id}", h.DeleteEntry).Methods(http.MethodDelete)
+	}
+	return r
+}
+
+// handleCheck decides on a payment before it is confirmed. The decision is
+// answered as soon as the payment is scored; it is recorded afterwards so
+// the caller's latency budget only pays for the rules.
+func (s *scorer) handleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonutil.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	var req fraud.CheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if req.PaymentID == "" {
+		jsonutil.WriteErrorJSON(w, "payment_id is required")
+		return
+	}
+	if req.Amount <= 0 {
+		jsonutil.WriteErrorJSON(w, "amount must be positive")
+		return
+	}
+
+	a := s.score(r.Context(), req, fraud.StagePreAuthorization)
+	s.checked.add(req.PaymentID)
+
+	jsonutil.WriteJSON(w, http.StatusOK, fraud.CheckResponse{
+		PaymentID: a.TransactionID,
+		Score:     a.Score,
+		Decision:  a.Decision,
+		Signals:   a.Signals,
+	})
+
+	go func() {
+		if err := s.record(context.Background(), a, req.Mode); err != nil {
+			log.Printf("Failed to record fraud check of %s: %v", a.TransactionID, err)
+		}
+	}()
+}
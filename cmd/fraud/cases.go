@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/fraud"
+	"github.com/sapliy/fintech-ecosystem/pkg/audit"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+type AssignCaseRequest struct {
+	Assignee string `json:"assignee"` // Empty unassigns
+}
+
+type TransitionCaseRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+type AddCaseNoteRequest struct {
+	Body string `json:"body"`
+}
+
+// caseResponse is a case with links to the records it is about
+type caseResponse struct {
+	*fraud.Case
+	Links map[string]string `json:"links"`
+}
+
+func withLinks(c *fraud.Case) caseResponse {
+	return caseResponse{Case: c, Links: map[string]string{
+		"payment":    "/v1/payments/intents/" + c.PaymentID,
+		"user_cases": "/v1/fraud/cases?user_id=" + c.UserID,
+	}}
+}
+
+// caseHandler is the reviewers' API over fraud cases. Reviewers are owners
+// and admins, and only see the cases of the zone they are scoped to.
+type caseHandler struct {
+	cases *fraud.CaseService
+}
+
+// reviewer returns the calling owner or admin, answering 401 or 403 for
+// anyone else
+func reviewer(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+		return "", false
+	}
+	if role := r.Header.Get("X-Role"); role != "owner" && role != "admin" {
+		jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": "Only owners and admins can review fraud"})
+		return "", false
+	}
+	return userID, true
+}
+
+// loadCase returns the case of the path, answering 404 for cases of other
+// zones
+func (h *caseHandler) loadCase(w http.ResponseWriter, r *http.Request) (*fraud.Case, bool) {
+	c, err := h.cases.GetCase(r.Context(), mux.Vars(r)["id"])
+	zoneID := r.Header.Get("X-Zone-ID")
+	if errors.Is(err, fraud.ErrCaseNotFound) || (err == nil && zoneID != "" && c.ZoneID != zoneID) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Case not found"})
+		return nil, false
+	}
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get case"})
+		return nil, false
+	}
+	return c, true
+}
+
+func (h *caseHandler) ListCases(w http.ResponseWriter, r *http.Request) {
+	if _, ok := reviewer(w, r); !ok {
+		return
+	}
+	q := r.URL.Query()
+	filter := fraud.CaseFilter{
+		ZoneID:     r.Header.Get("X-Zone-ID"),
+		UserID:     q.Get("user_id"),
+		PaymentID:  q.Get("payment_id"),
+		Status:     q.Get("status"),
+		AssignedTo: q.Get("assigned_to"),
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			jsonutil.WriteErrorJSON(w, "limit must be a positive integer")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	cases, err := h.cases.ListCases(r.Context(), filter)
+	if errors.Is(err, fraud.ErrInvalidCaseStatus) {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list cases"})
+		return
+	}
+	data := make([]caseResponse, len(cases))
+	for i := range cases {
+		data[i] = withLinks(&cases[i])
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func (h *caseHandler) GetCase(w http.ResponseWriter, r *http.Request) {
+	if _, ok := reviewer(w, r); !ok {
+		return
+	}
+	c, ok := h.loadCase(w, r)
+	if !ok {
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, withLinks(c))
+}
+
+// AssignCase hands a case to a reviewer, the caller if none is given
+func (h *caseHandler) AssignCase(w http.ResponseWriter, r *http.Request) {
+	userID, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+	var req AssignCaseRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonutil.WriteErrorJSON(w, "Invalid request body")
+			return
+		}
+	} else {
+		req.Assignee = userID
+	}
+	c, ok := h.loadCase(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.cases.Assign(r.Context(), c, req.Assignee, userID); err != nil {
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to assign case"})
+		return
+	}
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      userID,
+		Action:       "fraud_case.assigned",
+		ResourceType: "fraud_case",
+		ResourceID:   c.ID,
+		Metadata:     map[string]interface{}{"assignee": req.Assignee, "payment_id": c.PaymentID},
+	})
+	jsonutil.WriteJSON(w, http.StatusOK, withLinks(c))
+}
+
+// TransitionCase moves a case through the workflow
+func (h *caseHandler) TransitionCase(w http.ResponseWriter, r *http.Request) {
+	userID, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+	var req TransitionCaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	c, ok := h.loadCase(w, r)
+	if !ok {
+		return
+	}
+
+	previous := c.Status
+	err := h.cases.Transition(r.Context(), c, req.Status, userID, req.Reason)
+	switch {
+	case errors.Is(err, fraud.ErrCaseTransition):
+		jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, fraud.ErrInvalidCaseStatus), errors.Is(err, fraud.ErrCaseNoteTooLong):
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	case err != nil:
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update case"})
+		return
+	}
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      userID,
+		Action:       "fraud_case.status_changed",
+		ResourceType: "fraud_case",
+		ResourceID:   c.ID,
+		Metadata:     map[string]interface{}{"from": previous, "to": c.Status, "payment_id": c.PaymentID},
+	})
+	jsonutil.WriteJSON(w, http.StatusOK, withLinks(c))
+}
+
+func (h *caseHandler) AddCaseNote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+	var req AddCaseNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	c, ok := h.loadCase(w, r)
+	if !ok {
+		return
+	}
+
+	note, err := h.cases.AddNote(r.Context(), c, userID, req.Body)
+	if errors.Is(err, fraud.ErrEmptyCaseNote) || errors.Is(err, fraud.ErrCaseNoteTooLong) {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to add note"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusCreated, note)
+}
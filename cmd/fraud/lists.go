@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/fraud"
+	"github.com/sapliy/fintech-ecosystem/pkg/audit"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+type CreateListEntryRequest struct {
+	List      string     `json:"list"`
+	Type      string     `json:"type"`
+	Value     string     `json:"value"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type UpdateListEntryRequest struct {
+	Reason    *string    `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"` // Null makes the entry permanent
+}
+
+// listHandler manages the allowlists and blocklists. Like cases, entries
+// are managed by owners and admins within the zone they are scoped to;
+// entries created without a zone apply to every zone.
+type listHandler struct {
+	lists *fraud.ListService
+}
+
+// loadEntry returns the entry of the path, answering 404 for entries of
+// other zones
+func (h *listHandler) loadEntry(w http.ResponseWriter, r *http.Request) (*fraud.ListEntry, bool) {
+	e, err := h.lists.GetEntry(r.Context(), mux.Vars(r)["id"])
+	zoneID := r.Header.Get("X-Zone-ID")
+	if errors.Is(err, fraud.ErrListEntryNotFound) || (err == nil && zoneID != "" && e.ZoneID != zoneID) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "List entry not found"})
+		return nil, false
+	}
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get list entry"})
+		return nil, false
+	}
+	return e, true
+}
+
+func (h *listHandler) ListEntries(w http.ResponseWriter, r *http.Request) {
+	if _, ok := reviewer(w, r); !ok {
+		return
+	}
+	q := r.URL.Query()
+	filter := fraud.ListFilter{
+		ZoneID: r.Header.Get("X-Zone-ID"),
+		List:   q.Get("list"),
+		Type:   q.Get("type"),
+		Value:  q.Get("value"),
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			jsonutil.WriteErrorJSON(w, "limit must be a positive integer")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.lists.ListEntries(r.Context(), filter)
+	if errors.Is(err, fraud.ErrInvalidList) {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list entries"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": entries})
+}
+
+func (h *listHandler) CreateEntry(w http.ResponseWriter, r *http.Request) {
+	userID, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+	var req CreateListEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+
+	e := &fraud.ListEntry{
+		ZoneID:    r.Header.Get("X-Zone-ID"),
+		List:      req.List,
+		Type:      req.Type,
+		Value:     req.Value,
+		Reason:    req.Reason,
+		CreatedBy: userID,
+		ExpiresAt: req.ExpiresAt,
+	}
+	err := h.lists.CreateEntry(r.Context(), e)
+	switch {
+	case errors.Is(err, fraud.ErrDuplicateEntry):
+		jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, fraud.ErrInvalidList), errors.Is(err, fraud.ErrInvalidEntryType),
+		errors.Is(err, fraud.ErrInvalidEntryValue), errors.Is(err, fraud.ErrEntryExpired):
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	case err != nil:
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create list entry"})
+		return
+	}
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      userID,
+		Action:       "fraud_list.entry_added",
+		ResourceType: "fraud_list_entry",
+		ResourceID:   e.ID,
+		Metadata:     map[string]interface{}{"list": e.List, "type": e.Type, "value": e.Value},
+	})
+	jsonutil.WriteJSON(w, http.StatusCreated, e)
+}
+
+func (h *listHandler) GetEntry(w http.ResponseWriter, r *http.Request) {
+	if _, ok := reviewer(w, r); !ok {
+		return
+	}
+	e, ok := h.loadEntry(w, r)
+	if !ok {
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, e)
+}
+
+// UpdateEntry changes an entry's reason or expiry. The list, type and value
+// cannot change; delete the entry and add another instead.
+func (h *listHandler) UpdateEntry(w http.ResponseWriter, r *http.Request) {
+	userID, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	var req UpdateListEntryRequest
+	for field, value := range raw {
+		var err error
+		switch field {
+		case "reason":
+			err = json.Unmarshal(value, &req.Reason)
+		case "expires_at":
+			err = json.Unmarshal(value, &req.ExpiresAt)
+		default:
+			jsonutil.WriteErrorJSON(w, field+" cannot be changed")
+			return
+		}
+		if err != nil {
+			jsonutil.WriteErrorJSON(w, "Invalid "+field)
+			return
+		}
+	}
+	e, ok := h.loadEntry(w, r)
+	if !ok {
+		return
+	}
+
+	reason, expiresAt := e.Reason, e.ExpiresAt
+	if req.Reason != nil {
+		reason = *req.Reason
+	}
+	if _, ok := raw["expires_at"]; ok {
+		expiresAt = req.ExpiresAt
+	}
+	err := h.lists.UpdateEntry(r.Context(), e, reason, expiresAt)
+	switch {
+	case errors.Is(err, fraud.ErrEntryExpired):
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	case errors.Is(err, fraud.ErrListEntryNotFound):
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "List entry not found"})
+		return
+	case err != nil:
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update list entry"})
+		return
+	}
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      userID,
+		Action:       "fraud_list.entry_updated",
+		ResourceType: "fraud_list_entry",
+		ResourceID:   e.ID,
+		Metadata:     map[string]interface{}{"reason": e.Reason, "expires_at": e.ExpiresAt},
+	})
+	jsonutil.WriteJSON(w, http.StatusOK, e)
+}
+
+func (h *listHandler) DeleteEntry(w http.ResponseWriter, r *http.Request) {
+	userID, ok := reviewer(w, r)
+	if !ok {
+		return
+	}
+	e, ok := h.loadEntry(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.lists.DeleteEntry(r.Context(), e.ID)
+	if errors.Is(err, fraud.ErrListEntryNotFound) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "List entry not found"})
+		return
+	}
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to delete list entry"})
+		return
+	}
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      userID,
+		Action:       "fraud_list.entry_removed",
+		ResourceType: "fraud_list_entry",
+		ResourceID:   e.ID,
+		Metadata:     map[string]interface{}{"list": e.List, "type": e.Type, "value": e.Value},
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
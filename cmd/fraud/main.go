@@ -2,19 +2,20 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
-	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
 	"github.com/sapliy/fintech-ecosystem/internal/fraud"
+	"github.com/sapliy/fintech-ecosystem/pkg/database"
 	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
-	"github.com/sapliy/fintech-ecosystem/pkg/monitoring"
 )
 
 var (
@@ -22,52 +23,38 @@ var (
 		Name: "fraud_risky_payments_total",
 		Help: "Total number of payments flagged as risky.",
 	}, []string{"reason"})
+	PaymentDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fraud_payment_decisions_total",
+		Help: "Total number of scored payments, by decision.",
+	}, []string{"decision"})
+	RiskScores = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "fraud_risk_score",
+		Help:    "Risk scores of payments, out of 100.",
+		Buckets: prometheus.LinearBuckets(10, 10, 10),
+	})
+	PaymentOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fraud_payment_outcomes_total",
+		Help: "Total number of scored payments labeled from their disputes, by outcome.",
+	}, []string{"outcome"})
 )
 
 type PaymentEvent struct {
-	Type string `json:"type"`
-	Data struct {
-		ID       string `json:"id"`
-		Amount   int64  `json:"amount"`
-		Currency string `json:"currency"`
-		UserID   string `json:"user_id"`
+	Type   string `json:"type"`
+	ZoneID string `json:"zone_id"`
+	Mode   string `json:"mode"`
+	Data   struct {
+		ID        string `json:"id"`
+		PaymentID string `json:"payment_id"`
+		DisputeID string `json:"dispute_id"`
+		Amount    int64  `json:"amount"`
+		Currency  string `json:"currency"`
+		UserID    string `json:"user_id"`
+		// Metadata may carry customer_id, ip_country, card_country and
+		// device_id for the geo-mismatch and new-device rules
+		Metadata map[string]string `json:"metadata"`
 	} `json:"data"`
 }
 
-type VelocityTracker struct {
-	mu       sync.Mutex
-	payments map[string][]time.Time
-}
-
-func NewVelocityTracker() *VelocityTracker {
-	return &VelocityTracker{
-		payments: make(map[string][]time.Time),
-	}
-}
-
-func (v *VelocityTracker) AddAndCheck(userID string) bool {
-	v.mu.Lock()
-	defer v.mu.Unlock()
-
-	now := time.Now()
-	window := 1 * time.Minute
-	threshold := 5
-
-	// Add current
-	v.payments[userID] = append(v.payments[userID], now)
-
-	// Clean up old and count
-	var fresh []time.Time
-	for _, t := range v.payments[userID] {
-		if now.Sub(t) < window {
-			fresh = append(fresh, t)
-		}
-	}
-	v.payments[userID] = fresh
-
-	return len(fresh) > threshold
-}
-
 func main() {
 	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
 	if kafkaBrokers == "" {
@@ -98,13 +85,113 @@ func main() {
 		}
 	}
 
+	// Velocity windows are shared by every instance through Redis, or kept
+	// in memory without REDIS_ADDR
+	velocity := fraud.NewVelocityRule(1*time.Minute, 5)
 	engine := fraud.NewEngine(
 		&fraud.AmountRule{Limit: 1000000}, // $10,000 in cents
-		fraud.NewVelocityRule(1*time.Minute, 5),
+		velocity,
 	)
+	if redisAddr := os.Getenv("REDIS_ADDR"); redisAddr != "" {
+		rdb := redis.NewClient(&redis.Options{Addr: redisAddr})
+		if err := rdb.Ping(context.Background()).Err(); err != nil {
+			log.Printf("Redis connection failed, velocity checks are skipped until it is up: %v", err)
+		}
+		store := fraud.NewRedisVelocityStore(rdb)
+		velocity.Store = store
+		engine.SetVelocityStore(store)
+	} else {
+		log.Println("REDIS_ADDR not set, velocity is tracked in memory by this instance only")
+	}
 
-	// Start Metrics Server
-	monitoring.StartMetricsServer(":8081") // Fraud service metrics
+	// Scores and review cases are kept in the database when there is one
+	var db *sql.DB
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		var err error
+		if db, err = database.Connect(dsn); err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		if err := database.Migrate(db, "fraud", "migrations/fraud"); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+	}
+	var scores fraud.ScoreStore
+	var cases *fraud.CaseService
+	var lists *fraud.ListService
+	var outcomes fraud.OutcomeStore
+	if db != nil {
+		scores = fraud.DBScoreStore{DB: db}
+		outcomes = fraud.DBOutcomeStore{DB: db}
+		cases = fraud.NewCaseService(fraud.NewSQLCaseRepository(db))
+
+		// Allowlists and blocklists are matched in memory and refreshed from
+		// the database, so changes made on other instances are picked up
+		repo := fraud.NewSQLListRepository(db)
+		active := fraud.NewLists()
+		if err := active.Refresh(context.Background(), repo); err != nil {
+			log.Fatalf("Failed to load fraud lists: %v", err)
+		}
+		engine.SetLists(active)
+		lists = fraud.NewListService(repo, active)
+
+		interval := 30 * time.Second
+		if v := os.Getenv("FRAUD_LISTS_REFRESH_INTERVAL"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				interval = d
+			} else {
+				log.Printf("Invalid FRAUD_LISTS_REFRESH_INTERVAL %q, using %s", v, interval)
+			}
+		}
+		go active.Watch(context.Background(), repo, interval)
+	}
+
+	// fraud.scored events carry each payment's score and its breakdown
+	producer := messaging.NewKafkaProducer(brokers, "fraud")
+	defer func() {
+		_ = producer.Close()
+	}()
+
+	// Rules and thresholds come from FRAUD_RULES_FILE or the database when
+	// configured, and are reloaded without a restart
+	if source := ruleSource(db); source != nil {
+		if err := engine.Load(context.Background(), source); err != nil {
+			log.Fatalf("Failed to load fraud rules: %v", err)
+		}
+		log.Printf("Loaded %d fraud rules", len(engine.Rules()))
+
+		interval := 30 * time.Second
+		if v := os.Getenv("FRAUD_RULES_RELOAD_INTERVAL"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				interval = d
+			} else {
+				log.Printf("Invalid FRAUD_RULES_RELOAD_INTERVAL %q, using %s", v, interval)
+			}
+		}
+		go engine.WatchRules(context.Background(), source, interval)
+	}
+
+	s := &scorer{
+		engine:   engine,
+		scores:   scores,
+		cases:    cases,
+		lists:    lists,
+		outcomes: outcomes,
+		producer: producer,
+		alerts:   rabbitClient,
+		checked:  newCheckedPayments(),
+	}
+
+	// Metrics, health and the pre-authorization check API
+	addr := ":8081"
+	if port := os.Getenv("PORT"); port != "" {
+		addr = ":" + port
+	}
+	go func() {
+		log.Printf("Fraud API listening on %s", addr)
+		if err := http.ListenAndServe(addr, apiRoutes(s)); err != nil {
+			log.Fatalf("Fraud API failed: %v", err)
+		}
+	}()
 
 	log.Println("Fraud Detection Service started. Monitoring 'payments' topic...")
 
@@ -114,40 +201,44 @@ func main() {
 			return err
 		}
 
-		if event.Type != "payment.succeeded" {
-			return nil
+		paymentID := event.Data.PaymentID
+		if paymentID == "" {
+			paymentID = event.Data.ID
 		}
 
-		tx := fraud.Transaction{
-			ID:       event.Data.ID,
-			Amount:   event.Data.Amount,
-			Currency: event.Data.Currency,
-			UserID:   event.Data.UserID,
+		// Disputes label the decisions made on their payments
+		if strings.HasPrefix(event.Type, "dispute.") {
+			return s.label(context.Background(), event.Type, paymentID, event.Data.DisputeID)
 		}
-
-		results, isRisky := engine.Check(context.Background(), tx)
-		if isRisky {
-			for _, res := range results {
-				if !res.Passed {
-					log.Printf("⚠️ FRAUD ALERT: %s - %s (UserID: %s)", res.RuleName, res.Message, tx.UserID)
-					RiskyPayments.WithLabelValues(res.RuleName).Inc()
-
-					if rabbitClient != nil {
-						alert := map[string]string{
-							"user_id": tx.UserID,
-							"reason":  fmt.Sprintf("%s: %s", res.RuleName, res.Message),
-							"time":    time.Now().Format(time.RFC3339),
-							"tx_id":   tx.ID,
-						}
-						body, _ := json.Marshal(alert)
-						if err := rabbitClient.Publish(context.Background(), "risk_alerts", body); err != nil {
-							log.Printf("Failed to publish risk alert: %v", err)
-						}
-					}
-				}
-			}
+		if event.Type != "payment.succeeded" {
+			return nil
+		}
+		// Payments checked before confirmation are already scored
+		if s.checked.seen(paymentID) {
+			return nil
 		}
 
-		return nil
+		ctx := context.Background()
+		a := s.score(ctx, fraud.CheckRequest{
+			PaymentID: paymentID,
+			ZoneID:    event.ZoneID,
+			UserID:    event.Data.UserID,
+			Amount:    event.Data.Amount,
+			Currency:  event.Data.Currency,
+			Metadata:  event.Data.Metadata,
+		}, fraud.StagePostPayment)
+		return s.record(ctx, a, event.Mode)
 	})
 }
+
+// ruleSource picks where the fraud rules are configured: a JSON file, the
+// database, or nowhere, in which case the built-in rules are kept
+func ruleSource(db *sql.DB) fraud.RuleSource {
+	if path := os.Getenv("FRAUD_RULES_FILE"); path != "" {
+		return fraud.FileRuleSource{Path: path}
+	}
+	if db != nil {
+		return fraud.DBRuleSource{DB: db}
+	}
+	return nil
+}
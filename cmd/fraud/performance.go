@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/fraud"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// Rule performance looks at the last 30 days unless asked otherwise, and at
+// most a year
+const (
+	defaultPerformanceRange = 30 * 24 * time.Hour
+	maxPerformanceRange     = 366 * 24 * time.Hour
+)
+
+// performanceHandler measures the rules against the chargebacks of the
+// payments they scored, for risk teams to tune weights and thresholds
+type performanceHandler struct {
+	outcomes fraud.OutcomeStore
+}
+
+// RulePerformance answers a rule's precision and recall from from to to,
+// the last 30 days by default, by day or week
+func (h *performanceHandler) RulePerformance(w http.ResponseWriter, r *http.Request) {
+	if _, ok := reviewer(w, r); !ok {
+		return
+	}
+	q := r.URL.Query()
+	to := time.Now().UTC()
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			jsonutil.WriteErrorJSON(w, "to must be an RFC 3339 time")
+			return
+		}
+		to = t
+	}
+	from := to.Add(-defaultPerformanceRange)
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			jsonutil.WriteErrorJSON(w, "from must be an RFC 3339 time")
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		jsonutil.WriteErrorJSON(w, "from must be before to")
+		return
+	}
+	if to.Sub(from) > maxPerformanceRange {
+		jsonutil.WriteErrorJSON(w, "from and to must be at most a year apart")
+		return
+	}
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = fraud.PeriodDay
+	}
+
+	rule := mux.Vars(r)["id"]
+	scores, err := h.outcomes.LabeledScores(r.Context(), rule, r.Header.Get("X-Zone-ID"), from, to)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get rule performance"})
+		return
+	}
+	perf, err := fraud.ComputeRulePerformance(rule, scores, from, to, interval)
+	if errors.Is(err, fraud.ErrInvalidPeriod) {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get rule performance"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, perf)
+}
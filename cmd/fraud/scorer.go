@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/fraud"
+	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
+)
+
+// checkedTTL is how long payments checked before confirmation are
+// remembered, so their payment.succeeded events do not score them again
+const checkedTTL = 24 * time.Hour
+
+// scorer scores payments and records the assessments: kept in the
+// database, announced as fraud.scored events and, unless approved, opened
+// as cases and sent to risk_alerts for human review
+type scorer struct {
+	engine   *fraud.Engine
+	scores   fraud.ScoreStore   // nil without a database
+	cases    *fraud.CaseService // nil without a database
+	lists    *fraud.ListService // nil without a database
+	outcomes fraud.OutcomeStore // nil without a database
+	producer *messaging.KafkaProducer
+	alerts   *messaging.RabbitMQClient // nil without RabbitMQ
+	checked  *checkedPayments
+}
+
+// score assesses the payment and logs the decision
+func (s *scorer) score(ctx context.Context, req fraud.CheckRequest, stage string) *fraud.Assessment {
+	start := time.Now()
+	a := s.engine.Score(ctx, req.Transaction())
+	a.Stage = stage
+
+	PaymentDecisions.WithLabelValues(string(a.Decision)).Inc()
+	RiskScores.Observe(float64(a.Score))
+	for _, signal := range a.Flagged() {
+		RiskyPayments.WithLabelValues(signal.Rule).Inc()
+	}
+	log.Printf("Fraud decision for %s (zone %s, %s): %s, score %d in %s",
+		a.TransactionID, a.ZoneID, stage, a.Decision, a.Score, time.Since(start))
+	return a
+}
+
+// record keeps, announces and alerts on an assessment
+func (s *scorer) record(ctx context.Context, a *fraud.Assessment, mode string) error {
+	if s.scores != nil {
+		if err := s.scores.SaveScore(ctx, a); err != nil {
+			return err
+		}
+	}
+	if body, err := fraud.ScoredEvent(a, mode); err == nil {
+		if err := s.producer.Publish(ctx, a.TransactionID, body); err != nil {
+			log.Printf("Failed to publish fraud.scored for %s: %v", a.TransactionID, err)
+		}
+	}
+	if a.Decision == fraud.DecisionApprove {
+		return nil
+	}
+
+	var caseID string
+	if s.cases != nil {
+		c, err := s.cases.OpenCase(ctx, a)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			return nil // Recorded before, e.g. a redelivered event
+		}
+		caseID = c.ID
+	}
+
+	var reasons []string
+	for _, signal := range a.Flagged() {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", signal.Rule, signal.Message))
+	}
+	log.Printf("⚠️ FRAUD ALERT: %s scored %d, %s (UserID: %s) - %s",
+		a.TransactionID, a.Score, a.Decision, a.UserID, strings.Join(reasons, "; "))
+
+	if s.alerts != nil {
+		alert := map[string]string{
+			"user_id":  a.UserID,
+			"zone_id":  a.ZoneID,
+			"reason":   strings.Join(reasons, "; "),
+			"score":    strconv.Itoa(a.Score),
+			"decision": string(a.Decision),
+			"stage":    a.Stage,
+			"case_id":  caseID,
+			"time":     time.Now().Format(time.RFC3339),
+			"tx_id":    a.TransactionID,
+		}
+		body, _ := json.Marshal(alert)
+		if err := s.alerts.Publish(ctx, "risk_alerts", body); err != nil {
+			log.Printf("Failed to publish risk alert: %v", err)
+		}
+	}
+	return nil
+}
+
+// label records what a dispute event says became of a scored payment, so
+// decisions can be measured against chargebacks
+func (s *scorer) label(ctx context.Context, eventType, paymentID, disputeID string) error {
+	outcome, ok := fraud.OutcomeOfDisputeEvent(eventType)
+	if !ok || s.outcomes == nil || paymentID == "" {
+		return nil
+	}
+	labeled, err := s.outcomes.LabelOutcome(ctx, paymentID, disputeID, outcome)
+	if err != nil {
+		return err
+	}
+	if !labeled {
+		log.Printf("Dispute %s of %s has no fraud score to label", disputeID, paymentID)
+		return nil
+	}
+	PaymentOutcomes.WithLabelValues(outcome).Inc()
+	return nil
+}
+
+// checkedPayments remembers the payments checked before confirmation for
+// checkedTTL
+type checkedPayments struct {
+	mu        sync.Mutex
+	payments  map[string]time.Time
+	lastSweep time.Time
+}
+
+func newCheckedPayments() *checkedPayments {
+	return &checkedPayments{payments: make(map[string]time.Time)}
+}
+
+func (c *checkedPayments) add(paymentID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) > time.Minute {
+		for id, at := range c.payments {
+			if now.Sub(at) > checkedTTL {
+				delete(c.payments, id)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.payments[paymentID] = now
+}
+
+func (c *checkedPayments) seen(paymentID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	at, ok := c.payments[paymentID]
+	return ok && time.Since(at) <= checkedTTL
+}
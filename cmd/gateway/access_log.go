@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/observability"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader correlates a request across the gateway and the services
+// it calls. A caller's own ID is kept, otherwise the gateway makes one.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the caller's request IDs written to the logs
+const maxRequestIDLength = 128
+
+type accessLogKey struct{}
+
+// accessLogEntry collects what the access log records about a request as it
+// goes through the gateway
+type accessLogEntry struct {
+	requestID string
+	upstream  string
+	instance  string
+}
+
+// accessLog returns the request's entry, or nil outside AccessLogMiddleware
+func accessLog(ctx context.Context) *accessLogEntry {
+	entry, _ := ctx.Value(accessLogKey{}).(*accessLogEntry)
+	return entry
+}
+
+// AccessLogMiddleware gives every request an X-Request-ID, passed upstream
+// and returned to the caller, and logs each request once it is served: its
+// method, path, upstream, status, latency and bytes written, with its
+// request and trace IDs.
+func AccessLogMiddleware(logger *observability.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+			r.Header.Set(requestIDHeader, requestID)
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		entry := &accessLogEntry{requestID: requestID}
+		r = r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry))
+		rec := &accessLogWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		attrs := []any{
+			"request_id", requestID,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", rec.bytes,
+			"remote_ip", clientIP(r),
+		}
+		if entry.upstream != "" {
+			attrs = append(attrs, "upstream", entry.upstream, "upstream_instance", entry.instance)
+		}
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			attrs = append(attrs, "trace_id", sc.TraceID().String())
+		}
+
+		switch {
+		case rec.status >= http.StatusInternalServerError:
+			logger.Error("Request served", attrs...)
+		case rec.status >= http.StatusBadRequest:
+			logger.Warn("Request served", attrs...)
+		default:
+			logger.Info("Request served", attrs...)
+		}
+	})
+}
+
+// validRequestID reports whether a caller's request ID is safe to keep:
+// not too long and printable ASCII without spaces
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// accessLogWriter records the status and size of the response
+type accessLogWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Hijack lets WebSocket upgrades through.
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.status = http.StatusSwitchingProtocols
+	w.wroteHeader = true
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Flush lets streamed responses through.
+func (w *accessLogWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
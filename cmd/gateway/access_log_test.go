@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/observability"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// captureLogs returns a logger writing JSON lines to the returned buffer
+func captureLogs() (*observability.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return &observability.Logger{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}, &buf
+}
+
+// accessLogLines returns the "Request served" lines logged to buf
+func accessLogLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to decode log line %q: %v", line, err)
+		}
+		if entry["msg"] == "Request served" {
+			lines = append(lines, entry)
+		}
+	}
+	return lines
+}
+
+func TestAccessLogMiddleware_RequestID(t *testing.T) {
+	payments := newUpstream(t, http.StatusOK)
+	h, _ := testGateway(t, payments.URL, payments.URL, nil)
+	logger, _ := captureLogs()
+	handler := AccessLogMiddleware(logger, h)
+
+	tests := []struct {
+		name      string
+		requestID string
+		kept      bool
+	}{
+		{"Caller's ID", "req-123", true},
+		{"No ID", "", false},
+		{"ID with spaces", "req 123", false},
+		{"ID too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{"X-API-Key": secretKey}
+			if tt.requestID != "" {
+				headers[requestIDHeader] = tt.requestID
+			}
+			w := serve(handler, "GET", "/v1/payments/payment_intents", nil, headers)
+
+			got := w.Header().Get(requestIDHeader)
+			if tt.kept && got != tt.requestID {
+				t.Errorf("Expected the caller's ID %q returned, got %q", tt.requestID, got)
+			}
+			if !tt.kept && (got == tt.requestID || !validRequestID(got)) {
+				t.Errorf("Expected a new request ID, got %q", got)
+			}
+			if forwarded := payments.last().Header.Get(requestIDHeader); forwarded != got {
+				t.Errorf("Expected %q forwarded upstream, got %q", got, forwarded)
+			}
+		})
+	}
+}
+
+func TestAccessLogMiddleware_Log(t *testing.T) {
+	payments := newUpstream(t, http.StatusCreated)
+	h, _ := testGateway(t, payments.URL, payments.URL, nil)
+	logger, buf := captureLogs()
+	handler := AccessLogMiddleware(logger, h)
+
+	serve(handler, "POST", "/v1/payments/payment_intents", []byte(`{}`), map[string]string{"X-API-Key": secretKey, requestIDHeader: "req-1"})
+	serve(handler, "GET", "/v1/payments/payment_intents", nil, map[string]string{requestIDHeader: "req-2"})
+
+	lines := accessLogLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per request, got %d", len(lines))
+	}
+
+	proxied := lines[0]
+	want := map[string]any{
+		"level": "INFO", "request_id": "req-1", "method": "POST", "path": "/v1/payments/payment_intents",
+		"status": float64(http.StatusCreated), "upstream": "payment", "upstream_instance": payments.Listener.Addr().String(),
+	}
+	for attr, value := range want {
+		if proxied[attr] != value {
+			t.Errorf("Expected %s %v, got %v", attr, value, proxied[attr])
+		}
+	}
+
+	rejected := lines[1]
+	if rejected["level"] != "WARN" || rejected["status"] != float64(http.StatusUnauthorized) {
+		t.Errorf("Expected a rejected request logged as a warning with its status, got %v", rejected)
+	}
+	if _, ok := rejected["upstream"]; ok {
+		t.Errorf("Expected no upstream for a request never proxied, got %v", rejected["upstream"])
+	}
+}
+
+func TestAccessLogMiddleware_TraceContext(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	payments := newUpstream(t, http.StatusOK)
+	h, _ := testGateway(t, payments.URL, payments.URL, nil)
+	logger, buf := captureLogs()
+	handler := otelhttp.NewHandler(AccessLogMiddleware(logger, h), "gateway-test")
+
+	serve(handler, "GET", "/v1/payments/payment_intents", nil, map[string]string{
+		"X-API-Key":   secretKey,
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	})
+
+	traceparent := payments.last().Header.Get("traceparent")
+	if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || strings.Contains(traceparent, "00f067aa0ba902b7") {
+		t.Errorf("Expected the caller's trace continued upstream from the gateway's span, got %q", traceparent)
+	}
+	lines := accessLogLines(t, buf)
+	if len(lines) != 1 || lines[0]["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the trace ID logged, got %v", lines)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/apikey"
+	"github.com/sapliy/fintech-ecosystem/pkg/jwtutil"
+	"github.com/sapliy/fintech-ecosystem/pkg/scopes"
+	pb "github.com/sapliy/fintech-ecosystem/proto/auth"
+)
+
+var (
+	errMissingCredentials = errors.New("missing API key or token")
+	errInvalidAPIKey      = errors.New("invalid or revoked API key")
+	errInvalidToken       = errors.New("invalid or expired token")
+	errNoZoneAccess       = errors.New("no access to zone")
+)
+
+// authErrorMessages are the responses to failed authentication
+var authErrorMessages = map[error]string{
+	errMissingCredentials: "Missing API Key or token",
+	errInvalidAPIKey:      "Invalid or revoked API Key",
+	errInvalidToken:       "Invalid or expired token",
+}
+
+// identityHeaders are set by the gateway from the authenticated caller.
+// Downstream services trust them, so callers can never set them.
+var identityHeaders = []string{"X-User-ID", "X-Environment", "X-Org-ID", "X-Role", "X-Zone-ID", "X-Zone-Mode", scopes.Header}
+
+// principal is who a request is authenticated as: an API key, a user's
+// session JWT or an OAuth access token
+type principal struct {
+	UserID      string
+	OrgID       string
+	Role        string
+	ZoneID      string
+	Mode        string
+	Environment string
+	KeyType     string
+	Quota       int32
+	// RateKey identifies the caller to the rate limiter
+	RateKey string
+	// Scopes restrict what the caller may do when Scoped is set. Session
+	// JWTs act for the user and are not scoped.
+	Scopes string
+	Scoped bool
+	// Session marks users authenticated with a session JWT, whose zone is
+	// bound per request by bindZone
+	Session bool
+}
+
+// credentials returns the API key or token of the request, from the
+// X-API-Key header, a Bearer token or, for WebSockets and simple GETs, the
+// api_key query parameter
+func credentials(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+func isAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, "sk_") || strings.HasPrefix(credential, "pk_")
+}
+
+// authenticate resolves the credentials to a principal. API keys and OAuth
+// access tokens are checked with the auth service; session JWTs, signed by
+// it, are verified locally.
+func (h *GatewayHandler) authenticate(ctx context.Context, credential string) (*principal, error) {
+	if credential == "" {
+		return nil, errMissingCredentials
+	}
+
+	if isAPIKey(credential) {
+		keyHash := apikey.HashKey(credential, h.hmacSecret)
+		userID, env, keyScopes, orgID, role, quota, zoneID, mode, keyType, valid := h.validateKeyWithAuthService(ctx, keyHash)
+		if !valid {
+			return nil, errInvalidAPIKey
+		}
+		// The prefix is what callers see; policies keep test and live keys apart by it
+		if prefixEnv := apikey.Environment(credential); prefixEnv != "" {
+			env = prefixEnv
+		}
+		return &principal{
+			UserID:      userID,
+			OrgID:       orgID,
+			Role:        role,
+			ZoneID:      zoneID,
+			Mode:        mode,
+			Environment: env,
+			KeyType:     keyType,
+			Quota:       quota,
+			RateKey:     keyHash,
+			Scopes:      keyScopes,
+			Scoped:      true,
+		}, nil
+	}
+
+	if claims, err := jwtutil.ValidateToken(credential); err == nil {
+		if claims.UserID == "" {
+			return nil, errInvalidToken
+		}
+		if h.sessionRevoked(ctx, claims.SessionID) {
+			return nil, errInvalidToken
+		}
+		return &principal{
+			UserID:  claims.UserID,
+			OrgID:   claims.OrgID,
+			Role:    claims.Role,
+			ZoneID:  claims.ZoneID,
+			RateKey: "user_" + claims.UserID,
+			Session: true,
+		}, nil
+	}
+
+	res, err := h.authClient.ValidateToken(ctx, &pb.ValidateTokenRequest{AccessToken: credential})
+	if err != nil {
+		h.logger.Error("Auth service gRPC token validation call failed", "error", err)
+		return nil, errInvalidToken
+	}
+	if !res.Valid || res.UserId == "" {
+		return nil, errInvalidToken
+	}
+	return &principal{
+		UserID:  res.UserId,
+		RateKey: "oauth_" + res.ClientId + "_" + res.UserId,
+		Scopes:  res.Scope,
+		Scoped:  true,
+	}, nil
+}
+
+// bindZone binds a session's user to the zone named by the request's
+// X-Zone-ID, read before the caller's identity headers are cleared. Session
+// tokens carry no zone, so the auth service checks the user is a member of
+// the zone's organization and gives the zone's mode and the user's role in
+// it. Requests naming no zone keep the token's zone, if any.
+func (h *GatewayHandler) bindZone(ctx context.Context, p *principal, requested string) error {
+	if !p.Session {
+		return nil
+	}
+	zoneID := requested
+	if zoneID == "" {
+		zoneID = p.ZoneID
+	}
+	if zoneID == "" {
+		return nil
+	}
+	// Tokens issued for a zone stay in it
+	if p.ZoneID != "" && p.ZoneID != zoneID {
+		return errNoZoneAccess
+	}
+
+	res, err := h.authClient.ResolveZone(ctx, &pb.ResolveZoneRequest{UserId: p.UserID, OrgId: p.OrgID, ZoneId: zoneID})
+	if err != nil {
+		return err
+	}
+	if !res.Allowed {
+		return errNoZoneAccess
+	}
+	p.ZoneID = zoneID
+	p.OrgID = res.OrgId
+	p.Mode = res.Mode
+	p.Role = res.Role
+	return nil
+}
+
+// sessionRevoked reports whether the auth service revoked the login session
+// of a token. Redis errors are logged and let the token through: it is
+// signed and short-lived.
+func (h *GatewayHandler) sessionRevoked(ctx context.Context, sessionID string) bool {
+	if sessionID == "" || h.rdb == nil {
+		return false
+	}
+	revoked, err := h.rdb.Exists(ctx, jwtutil.RevokedSessionKey(sessionID)).Result()
+	if err != nil {
+		h.logger.Error("Failed to check session revocation", "session_id", sessionID, "error", err)
+		return false
+	}
+	return revoked > 0
+}
+
+// clearIdentity removes identity headers a caller sent
+func clearIdentity(r *http.Request) {
+	for _, header := range identityHeaders {
+		r.Header.Del(header)
+	}
+}
+
+// injectIdentity sets the identity headers downstream services expect,
+// replacing any the caller sent
+func injectIdentity(r *http.Request, p *principal) {
+	clearIdentity(r)
+	values := map[string]string{
+		"X-User-ID":     p.UserID,
+		"X-Environment": p.Environment,
+		"X-Org-ID":      p.OrgID,
+		"X-Role":        p.Role,
+		"X-Zone-ID":     p.ZoneID,
+		"X-Zone-Mode":   p.Mode,
+	}
+	for header, value := range values {
+		if value != "" {
+			r.Header.Set(header, value)
+		}
+	}
+	// Services enforce finer scopes than the gateway's by resource
+	if p.Scoped {
+		r.Header.Set(scopes.Header, p.Scopes)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/sapliy/fintech-ecosystem/pkg/apikey"
+	"github.com/sapliy/fintech-ecosystem/pkg/jwtutil"
+	"github.com/sapliy/fintech-ecosystem/pkg/observability"
+	pb "github.com/sapliy/fintech-ecosystem/proto/auth"
+	"google.golang.org/grpc"
+)
+
+// fakeAuth answers the auth service calls of the gateway from fixed data
+type fakeAuth struct {
+	pb.AuthServiceClient
+	keys   map[string]*pb.ValidateKeyResponse   // By key hash
+	zones  map[string]*pb.ResolveZoneResponse   // By user and zone, "user_1/zone_1"
+	tokens map[string]*pb.ValidateTokenResponse // OAuth access tokens
+}
+
+func (a *fakeAuth) ValidateKey(ctx context.Context, in *pb.ValidateKeyRequest, opts ...grpc.CallOption) (*pb.ValidateKeyResponse, error) {
+	if res, ok := a.keys[in.KeyHash]; ok {
+		return res, nil
+	}
+	return &pb.ValidateKeyResponse{Valid: false}, nil
+}
+
+func (a *fakeAuth) ValidateToken(ctx context.Context, in *pb.ValidateTokenRequest, opts ...grpc.CallOption) (*pb.ValidateTokenResponse, error) {
+	if res, ok := a.tokens[in.AccessToken]; ok {
+		return res, nil
+	}
+	return &pb.ValidateTokenResponse{Valid: false}, nil
+}
+
+func (a *fakeAuth) ResolveZone(ctx context.Context, in *pb.ResolveZoneRequest, opts ...grpc.CallOption) (*pb.ResolveZoneResponse, error) {
+	res, ok := a.zones[in.UserId+"/"+in.ZoneId]
+	if !ok || (in.OrgId != "" && in.OrgId != res.OrgId) {
+		return &pb.ResolveZoneResponse{Allowed: false}, nil
+	}
+	return res, nil
+}
+
+func sessionToken(t *testing.T, claims *jwtutil.Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtutil.SecretKey)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestGatewayHandler_Authenticate(t *testing.T) {
+	auth := &fakeAuth{
+		keys: map[string]*pb.ValidateKeyResponse{
+			apikey.HashKey("sk_live_1", testHMACSecret): {
+				Valid: true, UserId: "user_1", OrgId: "org_1", Role: "developer", ZoneId: "zone_1", Mode: "live",
+				Environment: "live", KeyType: "secret", Scopes: "payments:read", RateLimitQuota: 500,
+			},
+			apikey.HashKey("sk_test_1", testHMACSecret): {Valid: true, UserId: "user_1", ZoneId: "zone_1", Environment: "live", KeyType: "secret", Scopes: "*"},
+		},
+		tokens: map[string]*pb.ValidateTokenResponse{
+			"oauth_token_1": {Valid: true, ClientId: "client_1", UserId: "user_3", Scope: "payments:read"},
+			"oauth_no_user": {Valid: true, ClientId: "client_1"},
+		},
+	}
+	h := &GatewayHandler{authClient: auth, hmacSecret: testHMACSecret, logger: observability.NewLogger("gateway-test")}
+
+	tests := []struct {
+		name       string
+		credential string
+		want       *principal
+		wantErr    error
+	}{
+		{"API key", "sk_live_1", &principal{
+			UserID: "user_1", OrgID: "org_1", Role: "developer", ZoneID: "zone_1", Mode: "live", Environment: "live",
+			KeyType: "secret", Quota: 500, RateKey: apikey.HashKey("sk_live_1", testHMACSecret), Scopes: "payments:read", Scoped: true,
+		}, nil},
+		{"Environment from the key's prefix", "sk_test_1", &principal{
+			UserID: "user_1", ZoneID: "zone_1", Environment: "test", KeyType: "secret", RateKey: apikey.HashKey("sk_test_1", testHMACSecret), Scopes: "*", Scoped: true,
+		}, nil},
+		{"Unknown API key", "sk_live_unknown", nil, errInvalidAPIKey},
+		{"Session token", sessionToken(t, &jwtutil.Claims{UserID: "user_1", OrgID: "org_1", Role: "admin", ZoneID: "zone_1"}), &principal{
+			UserID: "user_1", OrgID: "org_1", Role: "admin", ZoneID: "zone_1", RateKey: "user_user_1", Session: true,
+		}, nil},
+		{"Session token without a user", sessionToken(t, &jwtutil.Claims{OrgID: "org_1"}), nil, errInvalidToken},
+		{"OAuth token", "oauth_token_1", &principal{UserID: "user_3", RateKey: "oauth_client_1_user_3", Scopes: "payments:read", Scoped: true}, nil},
+		{"OAuth token without a user", "oauth_no_user", nil, errInvalidToken},
+		{"Unknown token", "not-a-token", nil, errInvalidToken},
+		{"No credentials", "", nil, errMissingCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.authenticate(context.Background(), tt.credential)
+			if err != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.want != nil && *got != *tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		headers map[string]string
+		want    string
+	}{
+		{"API key header", "/v1/flows", map[string]string{"X-API-Key": "sk_live_1", "Authorization": "Bearer token"}, "sk_live_1"},
+		{"Bearer token", "/v1/flows", map[string]string{"Authorization": "Bearer token"}, "token"},
+		{"Other authorization", "/v1/flows", map[string]string{"Authorization": "Basic dXNlcg=="}, ""},
+		{"Query parameter", "/v1/events/stream?api_key=pk_live_1", nil, "pk_live_1"},
+		{"None", "/v1/flows", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := credentials(r); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInjectIdentity(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/flows", nil)
+	for _, header := range identityHeaders {
+		r.Header.Set(header, "spoofed")
+	}
+
+	injectIdentity(r, &principal{UserID: "user_1", ZoneID: "zone_1", Mode: "live"})
+	want := map[string]string{"X-User-ID": "user_1", "X-Zone-ID": "zone_1", "X-Zone-Mode": "live", "X-Org-ID": "", "X-Role": "", "X-Environment": "", "X-Scopes": ""}
+	for header, value := range want {
+		if got := r.Header.Get(header); got != value {
+			t.Errorf("Expected %s %q, got %q", header, value, got)
+		}
+	}
+}
+
+func TestGatewayHandler_SessionRevocationUnavailable(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { _ = rdb.Close() })
+	h := &GatewayHandler{rdb: rdb, hmacSecret: testHMACSecret, logger: observability.NewLogger("gateway-test")}
+
+	// The token is signed and short-lived, so it is let through while
+	// revocations cannot be checked
+	token := sessionToken(t, &jwtutil.Claims{UserID: "user_1", SessionID: "sess_1"})
+	if got, err := h.authenticate(context.Background(), token); err != nil || got.UserID != "user_1" {
+		t.Errorf("Expected the session token accepted, got %+v, %v", got, err)
+	}
+}
+
+func TestGatewayHandler_BindsSessionZone(t *testing.T) {
+	auth := &fakeAuth{zones: map[string]*pb.ResolveZoneResponse{
+		"user_1/zone_live": {Allowed: true, OrgId: "org_1", Mode: "live", Role: "developer"},
+	}}
+	h := &GatewayHandler{authClient: auth}
+
+	tests := []struct {
+		name      string
+		claims    *jwtutil.Claims
+		requested string
+		wantErr   error
+		wantZone  string
+	}{
+		{"member zone", &jwtutil.Claims{UserID: "user_1"}, "zone_live", nil, "zone_live"},
+		{"member zone of the session's organization", &jwtutil.Claims{UserID: "user_1", OrgID: "org_1"}, "zone_live", nil, "zone_live"},
+		{"zone of another organization", &jwtutil.Claims{UserID: "user_1", OrgID: "org_2"}, "zone_live", errNoZoneAccess, ""},
+		{"not a member", &jwtutil.Claims{UserID: "user_2"}, "zone_live", errNoZoneAccess, ""},
+		{"token's own zone", &jwtutil.Claims{UserID: "user_1", ZoneID: "zone_live"}, "", nil, "zone_live"},
+		{"outside the token's zone", &jwtutil.Claims{UserID: "user_1", ZoneID: "zone_test"}, "zone_live", errNoZoneAccess, ""},
+		{"no zone", &jwtutil.Claims{UserID: "user_1"}, "", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caller, err := h.authenticate(context.Background(), sessionToken(t, tt.claims))
+			if err != nil {
+				t.Fatalf("authenticate failed: %v", err)
+			}
+			if err := h.bindZone(context.Background(), caller, tt.requested); err != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			r := httptest.NewRequest("GET", "/v1/flows", nil)
+			r.Header.Set("X-Zone-ID", tt.requested)
+			injectIdentity(r, caller)
+			if got := r.Header.Get("X-Zone-ID"); got != tt.wantZone {
+				t.Errorf("Expected X-Zone-ID %q, got %q", tt.wantZone, got)
+			}
+			if tt.wantZone != "" {
+				if r.Header.Get("X-Zone-Mode") != "live" || r.Header.Get("X-Org-ID") != "org_1" || r.Header.Get("X-Role") != "developer" {
+					t.Errorf("Expected the zone's mode, organization and role, got %v", r.Header)
+				}
+			}
+		})
+	}
+}
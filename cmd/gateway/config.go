@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sapliy/fintech-ecosystem/pkg/observability"
+	"github.com/sapliy/fintech-ecosystem/pkg/ratelimit"
+	"github.com/spf13/viper"
+)
+
+// How a route rewrites the path before proxying it upstream
+const (
+	// StripNone proxies the path as is, /v1 included
+	StripNone = "none"
+	// StripVersion drops the /v1 prefix
+	StripVersion = "version"
+	// StripPrefix drops the /v1 prefix and the route's prefix
+	StripPrefix = "prefix"
+)
+
+const (
+	defaultUpstreamTimeout = 30 * time.Second
+	defaultRetries         = 2
+)
+
+// defaultCircuitBreaker opens an instance's breaker after 5 failures in a
+// row and tries it again after 30 seconds
+var defaultCircuitBreaker = CircuitBreakerConfig{Failures: 5, OpenTimeout: 30 * time.Second}
+
+// defaultRateLimit applies to callers without a quota of their own
+var defaultRateLimit = ratelimit.Config{Limit: 100, Window: time.Minute, Burst: 100}
+
+// GatewayConfig is the gateway's routing: the upstream services by name and
+// the path prefixes routed to them. An upstream's URL may list several
+// instances separated by commas.
+type GatewayConfig struct {
+	Upstreams       map[string]string `mapstructure:"upstreams"`
+	Routes          []RouteConfig     `mapstructure:"routes"`
+	UpstreamTimeout time.Duration     `mapstructure:"upstream_timeout"`
+	// RateLimit is the token bucket of each client, by API key, token or
+	// IP, unless the route or the API key's quota sets another
+	RateLimit ratelimit.Config `mapstructure:"rate_limit"`
+	// CircuitBreaker applies to each upstream instance
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+	// Retries is how many more times a failed idempotent request is tried
+	// on another instance, unless the route sets its own
+	Retries int `mapstructure:"retries"`
+	// OpenAPIDir holds OpenAPI specs named after upstreams, e.g.
+	// payment.yaml. Requests to an upstream with a spec are validated
+	// against it before being proxied.
+	OpenAPIDir string `mapstructure:"openapi_dir"`
+	// GRPCUpstreams are the gRPC addresses of the services routes can
+	// transcode REST requests to, by name
+	GRPCUpstreams map[string]string `mapstructure:"grpc_upstreams"`
+
+	specs map[string]*openAPISpec
+}
+
+// CircuitBreakerConfig opens an upstream instance's breaker after Failures
+// failed requests in a row. While open, requests to the instance fail at
+// once; after OpenTimeout a single request is let through to test it.
+type CircuitBreakerConfig struct {
+	Failures    uint32        `mapstructure:"failures"`
+	OpenTimeout time.Duration `mapstructure:"open_timeout"`
+}
+
+// RouteConfig routes the paths starting with Prefix, after an optional /v1,
+// to an upstream. Timeout bounds each try's wait for the upstream's
+// response headers and defaults to the config's UpstreamTimeout. Routes
+// require an API key or token unless Public is set. A RateLimit gives each
+// client a bucket for the route of its own. A Transcode route serves its
+// requests with the gRPC upstream named Upstream, whose HTTP bindings the
+// route's prefix stands for; Strip and Retries do not apply to it.
+type RouteConfig struct {
+	Prefix    string            `mapstructure:"prefix"`
+	Upstream  string            `mapstructure:"upstream"`
+	Strip     string            `mapstructure:"strip"`
+	Timeout   time.Duration     `mapstructure:"timeout"`
+	Retries   *int              `mapstructure:"retries"`
+	Public    bool              `mapstructure:"public"`
+	Transcode bool              `mapstructure:"transcode"`
+	RateLimit *ratelimit.Config `mapstructure:"rate_limit"`
+}
+
+// defaultGatewayConfig is the routing of the services in this repository
+func defaultGatewayConfig() *GatewayConfig {
+	return &GatewayConfig{
+		Upstreams: map[string]string{
+			"auth":         "http://127.0.0.1:8081",
+			"payment":      "http://127.0.0.1:8082",
+			"ledger":       "http://127.0.0.1:8083",
+			"notification": "http://127.0.0.1:8084",
+			"wallet":       "http://127.0.0.1:8085",
+			"flow":         "http://127.0.0.1:8084",
+			"events":       "http://127.0.0.1:8089",
+			"billing":      "http://127.0.0.1:8092", // Billing REST API (subscriptions, plans)
+			"fraud":        "http://127.0.0.1:8091",
+		},
+		Routes: []RouteConfig{
+			{Prefix: "/payments", Upstream: "payment", Strip: StripPrefix},
+			{Prefix: "/ledger", Upstream: "ledger", Strip: StripPrefix},
+			{Prefix: "/wallets", Upstream: "wallet", Strip: StripNone},
+			{Prefix: "/billing", Upstream: "billing", Strip: StripPrefix},
+			{Prefix: "/subscriptions", Upstream: "billing", Strip: StripVersion},
+			{Prefix: "/webhooks", Upstream: "notification", Strip: StripNone},
+			// Email and SMS providers' delivery callbacks, verified by their
+			// signatures
+			{Prefix: "/notifications/callbacks", Upstream: "notification", Strip: StripNone, Public: true},
+			{Prefix: "/notifications", Upstream: "notification", Strip: StripNone},
+			{Prefix: "/events", Upstream: "events", Strip: StripNone},
+			{Prefix: "/flows", Upstream: "flow", Strip: StripNone},
+			{Prefix: "/executions", Upstream: "flow", Strip: StripNone},
+			{Prefix: "/flow-templates", Upstream: "flow", Strip: StripNone},
+			// Flow debug sessions, including their WebSocket
+			{Prefix: "/debug", Upstream: "flow", Strip: StripNone},
+			{Prefix: "/fraud", Upstream: "fraud", Strip: StripNone},
+			// Custom policies, managed by organization admins
+			{Prefix: "/policy", Upstream: "auth", Strip: StripVersion},
+			// The ledger's gRPC API, e.g. /v1/rpc/ledger/accounts/{id}
+			{Prefix: "/rpc/ledger", Upstream: "ledger", Transcode: true},
+		},
+		GRPCUpstreams: map[string]string{
+			"ledger": "127.0.0.1:50052",
+		},
+		UpstreamTimeout: defaultUpstreamTimeout,
+		RateLimit:       defaultRateLimit,
+		CircuitBreaker:  defaultCircuitBreaker,
+		Retries:         defaultRetries,
+	}
+}
+
+// LoadGatewayConfig builds the config from the defaults, the YAML file at
+// path if one is given, and then the environment: <NAME>_SERVICE_URL sets
+// the URL of the upstream named name, e.g. PAYMENT_SERVICE_URL,
+// <NAME>_GRPC_ADDR the address of the gRPC upstream, e.g. LEDGER_GRPC_ADDR,
+// and GATEWAY_OPENAPI_DIR the directory of OpenAPI specs. The file's
+// upstreams are added to the defaults, and its routes replace the default
+// route with the same prefix or are added.
+func LoadGatewayConfig(path string) (*GatewayConfig, error) {
+	cfg := defaultGatewayConfig()
+
+	if path != "" {
+		v := viper.New()
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read gateway config %s: %w", path, err)
+		}
+		var file GatewayConfig
+		if err := v.Unmarshal(&file); err != nil {
+			return nil, fmt.Errorf("failed to parse gateway config %s: %w", path, err)
+		}
+		cfg.merge(&file)
+	}
+
+	for name := range cfg.Upstreams {
+		if u := os.Getenv(strings.ToUpper(name) + "_SERVICE_URL"); u != "" {
+			cfg.Upstreams[name] = u
+		}
+	}
+
+	for name := range cfg.GRPCUpstreams {
+		if addr := os.Getenv(strings.ToUpper(name) + "_GRPC_ADDR"); addr != "" {
+			cfg.GRPCUpstreams[name] = addr
+		}
+	}
+	if dir := os.Getenv("GATEWAY_OPENAPI_DIR"); dir != "" {
+		cfg.OpenAPIDir = dir
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.OpenAPIDir != "" {
+		specs, err := LoadOpenAPISpecs(cfg.OpenAPIDir)
+		if err != nil {
+			return nil, err
+		}
+		for name := range specs {
+			if _, ok := cfg.Upstreams[name]; !ok {
+				return nil, fmt.Errorf("OpenAPI spec for unknown upstream %q", name)
+			}
+		}
+		cfg.specs = specs
+	}
+	return cfg, nil
+}
+
+func (c *GatewayConfig) merge(file *GatewayConfig) {
+	for name, u := range file.Upstreams {
+		c.Upstreams[name] = u
+	}
+	for name, addr := range file.GRPCUpstreams {
+		c.GRPCUpstreams[name] = addr
+	}
+	for _, route := range file.Routes {
+		replaced := false
+		for i := range c.Routes {
+			if c.Routes[i].Prefix == route.Prefix {
+				c.Routes[i] = route
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			c.Routes = append(c.Routes, route)
+		}
+	}
+	if file.UpstreamTimeout > 0 {
+		c.UpstreamTimeout = file.UpstreamTimeout
+	}
+	if file.RateLimit.Limit > 0 {
+		c.RateLimit = file.RateLimit
+	}
+	if file.CircuitBreaker.Failures > 0 {
+		c.CircuitBreaker.Failures = file.CircuitBreaker.Failures
+	}
+	if file.CircuitBreaker.OpenTimeout > 0 {
+		c.CircuitBreaker.OpenTimeout = file.CircuitBreaker.OpenTimeout
+	}
+	if file.Retries != 0 {
+		c.Retries = file.Retries // Negative ones are rejected by validate
+	}
+	if file.OpenAPIDir != "" {
+		c.OpenAPIDir = file.OpenAPIDir
+	}
+}
+
+// validate checks every route has a known, valid upstream, and fills in
+// the route defaults
+func (c *GatewayConfig) validate() error {
+	for name, upstream := range c.Upstreams {
+		urls := upstreamURLs(upstream)
+		if len(urls) == 0 {
+			return fmt.Errorf("upstream %s: no URL", name)
+		}
+		for _, u := range urls {
+			parsed, err := url.Parse(u)
+			if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+				return fmt.Errorf("upstream %s: invalid URL %q", name, u)
+			}
+		}
+	}
+	if _, ok := c.Upstreams["auth"]; !ok {
+		return fmt.Errorf("the auth upstream is required")
+	}
+	if c.UpstreamTimeout <= 0 {
+		c.UpstreamTimeout = defaultUpstreamTimeout
+	}
+	if err := validateRateLimit(&c.RateLimit); err != nil {
+		return fmt.Errorf("rate_limit: %w", err)
+	}
+	if c.CircuitBreaker.Failures == 0 {
+		c.CircuitBreaker.Failures = defaultCircuitBreaker.Failures
+	}
+	if c.CircuitBreaker.OpenTimeout <= 0 {
+		c.CircuitBreaker.OpenTimeout = defaultCircuitBreaker.OpenTimeout
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+
+	for i := range c.Routes {
+		route := &c.Routes[i]
+		if !strings.HasPrefix(route.Prefix, "/") || route.Prefix == "/" {
+			return fmt.Errorf("route %q: prefix must start with / and not be /", route.Prefix)
+		}
+		if route.Transcode {
+			if _, ok := c.GRPCUpstreams[route.Upstream]; !ok {
+				return fmt.Errorf("route %s: unknown gRPC upstream %q", route.Prefix, route.Upstream)
+			}
+			if _, ok := transcoders[route.Upstream]; !ok {
+				return fmt.Errorf("route %s: the gateway cannot transcode to %q", route.Prefix, route.Upstream)
+			}
+		} else if _, ok := c.Upstreams[route.Upstream]; !ok {
+			return fmt.Errorf("route %s: unknown upstream %q", route.Prefix, route.Upstream)
+		}
+		switch route.Strip {
+		case "":
+			route.Strip = StripNone
+		case StripNone, StripVersion, StripPrefix:
+		default:
+			return fmt.Errorf("route %s: strip must be %s, %s or %s", route.Prefix, StripNone, StripVersion, StripPrefix)
+		}
+		if route.Timeout <= 0 {
+			route.Timeout = c.UpstreamTimeout
+		}
+		if route.Retries == nil {
+			retries := c.Retries
+			route.Retries = &retries
+		} else if *route.Retries < 0 {
+			return fmt.Errorf("route %s: retries must not be negative", route.Prefix)
+		}
+		if route.RateLimit != nil {
+			if err := validateRateLimit(route.RateLimit); err != nil {
+				return fmt.Errorf("route %s: rate_limit: %w", route.Prefix, err)
+			}
+		}
+	}
+
+	// Longer prefixes are matched first, so /events/archive can be routed
+	// apart from /events
+	sort.SliceStable(c.Routes, func(i, j int) bool {
+		return len(c.Routes[i].Prefix) > len(c.Routes[j].Prefix)
+	})
+	return nil
+}
+
+func validateRateLimit(cfg *ratelimit.Config) error {
+	if cfg.Limit <= 0 || cfg.Window <= 0 {
+		return fmt.Errorf("limit and window must be positive")
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = cfg.Limit
+	}
+	return nil
+}
+
+// match returns the route of the path, with the /v1 prefix removed
+func (c *GatewayConfig) match(p string) (*RouteConfig, bool) {
+	for i := range c.Routes {
+		if strings.HasPrefix(p, c.Routes[i].Prefix) {
+			return &c.Routes[i], true
+		}
+	}
+	return nil, false
+}
+
+// WatchGatewayConfig reloads the config on SIGHUP and, when it comes from a
+// file, whenever the file changes, until the context is cancelled. A config
+// that fails to load is logged and the current one kept.
+func WatchGatewayConfig(ctx context.Context, path string, logger *observability.Logger, apply func(*GatewayConfig)) {
+	reload := make(chan string, 1)
+	trigger := func(reason string) {
+		select {
+		case reload <- reason:
+		default:
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	if path != "" {
+		v := viper.New()
+		v.SetConfigFile(path)
+		v.OnConfigChange(func(e fsnotify.Event) { trigger("file changed") })
+		v.WatchConfig()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			trigger("SIGHUP")
+			continue
+		case reason := <-reload:
+			cfg, err := LoadGatewayConfig(path)
+			if err != nil {
+				logger.Error("Failed to reload gateway config, keeping the current one", "reason", reason, "error", err)
+				continue
+			}
+			apply(cfg)
+			logger.Info("Gateway config reloaded", "reason", reason, "routes", len(cfg.Routes), "upstreams", len(cfg.Upstreams))
+		}
+	}
+}
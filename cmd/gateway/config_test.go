@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/observability"
+)
+
+// writeConfig replaces the config file at once, as deploys do, so a
+// watcher never reads it half-written
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Failed to replace config: %v", err)
+	}
+}
+
+func TestLoadGatewayConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	writeConfig(t, path, `
+upstreams:
+  reports: http://reports:8095,http://reports-2:8095
+routes:
+  - prefix: /payments
+    upstream: payment
+    strip: version
+    retries: 0
+  - prefix: /reports
+    upstream: reports
+    timeout: 5s
+  - prefix: /events/archive
+    upstream: reports
+retries: 3
+circuit_breaker:
+  failures: 10
+`)
+	t.Setenv("PAYMENT_SERVICE_URL", "http://payments.internal:8082")
+	t.Setenv("LEDGER_GRPC_ADDR", "ledger.internal:50052")
+
+	cfg, err := LoadGatewayConfig(path)
+	if err != nil {
+		t.Fatalf("LoadGatewayConfig failed: %v", err)
+	}
+	if cfg.Upstreams["payment"] != "http://payments.internal:8082" || cfg.Upstreams["ledger"] == "" || cfg.Upstreams["reports"] == "" {
+		t.Errorf("Expected the defaults and the file's upstreams with the environment's payment URL, got %v", cfg.Upstreams)
+	}
+
+	if cfg.GRPCUpstreams["ledger"] != "ledger.internal:50052" {
+		t.Errorf("Expected the environment's ledger gRPC address, got %v", cfg.GRPCUpstreams)
+	}
+
+	payments, _ := cfg.match("/payments/payment_intents")
+	if payments == nil || payments.Strip != StripVersion || *payments.Retries != 0 {
+		t.Errorf("Expected the file's payments route to replace the default, got %+v", payments)
+	}
+	reports, _ := cfg.match("/reports/daily")
+	if reports == nil || reports.Timeout != 5*time.Second || reports.Strip != StripNone || *reports.Retries != 3 {
+		t.Errorf("Expected the file's reports route added with the defaults filled in, got %+v", reports)
+	}
+	if archive, _ := cfg.match("/events/archive/2024"); archive == nil || archive.Upstream != "reports" || archive.Timeout != defaultUpstreamTimeout {
+		t.Errorf("Expected the longer prefix matched first, got %+v", archive)
+	}
+	if callbacks, _ := cfg.match("/notifications/callbacks/sendgrid"); callbacks == nil || !callbacks.Public {
+		t.Errorf("Expected the public callbacks route matched before /notifications, got %+v", callbacks)
+	}
+	if cfg.CircuitBreaker.Failures != 10 || cfg.CircuitBreaker.OpenTimeout != defaultCircuitBreaker.OpenTimeout {
+		t.Errorf("Expected the file's breaker failures with the default open timeout, got %+v", cfg.CircuitBreaker)
+	}
+	if _, ok := cfg.match("/unknown"); ok {
+		t.Error("Expected no route for an unknown path")
+	}
+
+	invalid := []struct {
+		name    string
+		content string
+	}{
+		{"Unknown upstream", "routes:\n  - prefix: /reports\n    upstream: reports\n"},
+		{"Invalid URL", "upstreams:\n  reports: not-a-url\n"},
+		{"Invalid instance URL", "upstreams:\n  reports: http://reports:8095,not-a-url\n"},
+		{"Upstream without a URL", "upstreams:\n  reports: ' , '\n"},
+		{"Invalid strip", "routes:\n  - prefix: /payments\n    upstream: payment\n    strip: all\n"},
+		{"Negative retries", "retries: -1\n"},
+		{"Negative route retries", "routes:\n  - prefix: /payments\n    upstream: payment\n    retries: -1\n"},
+		{"Transcode to an unknown gRPC upstream", "routes:\n  - prefix: /rpc/reports\n    upstream: reports\n    transcode: true\n"},
+		{"Transcode without bindings", "grpc_upstreams:\n  wallet: wallet:50053\nroutes:\n  - prefix: /rpc/wallet\n    upstream: wallet\n    transcode: true\n"},
+		{"Root prefix", "routes:\n  - prefix: /\n    upstream: payment\n"},
+		{"Prefix without a slash", "routes:\n  - prefix: payments\n    upstream: payment\n"},
+	}
+	for _, tt := range invalid {
+		writeConfig(t, path, tt.content)
+		if _, err := LoadGatewayConfig(path); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	if _, err := LoadGatewayConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected a missing file to fail")
+	}
+}
+
+func TestWatchGatewayConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	config := func(payment string) string {
+		return fmt.Sprintf("upstreams:\n  payment: %s\n", payment)
+	}
+	writeConfig(t, path, config("http://payments-1:8082"))
+	cfg, err := LoadGatewayConfig(path)
+	if err != nil {
+		t.Fatalf("LoadGatewayConfig failed: %v", err)
+	}
+	h := NewGatewayHandler(cfg, nil, nil, nil, "", observability.NewLogger("gateway-test"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	applied := make(chan *GatewayConfig, 10)
+	go WatchGatewayConfig(ctx, path, observability.NewLogger("gateway-test"), func(cfg *GatewayConfig) {
+		h.SetConfig(cfg)
+		applied <- cfg
+	})
+
+	// Rewritten until the watcher, started in the background, sees it
+	deadline := time.After(5 * time.Second)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+reload:
+	for {
+		select {
+		case cfg := <-applied:
+			if cfg.Upstreams["payment"] == "http://payments-2:8082" {
+				break reload
+			}
+		case <-ticker.C:
+			writeConfig(t, path, config("http://payments-2:8082"))
+		case <-deadline:
+			t.Fatal("Expected the changed config reloaded")
+		}
+	}
+	if got := h.config.Load().Upstreams["payment"]; got != "http://payments-2:8082" {
+		t.Errorf("Expected the gateway switched to the reloaded config, got %s", got)
+	}
+
+	// An invalid config is not applied
+	for len(applied) > 0 {
+		<-applied
+	}
+	writeConfig(t, path, config("not-a-url"))
+	select {
+	case cfg := <-applied:
+		t.Errorf("Expected the invalid config kept out, got %v", cfg.Upstreams["payment"])
+	case <-time.After(500 * time.Millisecond):
+	}
+	if got := h.config.Load().Upstreams["payment"]; got != "http://payments-2:8082" {
+		t.Errorf("Expected the current config kept, got %s", got)
+	}
+}
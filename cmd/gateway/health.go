@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+const (
+	// healthProbeTimeout bounds each upstream instance's health check
+	healthProbeTimeout = 2 * time.Second
+	// healthCacheTTL spares the upstreams from being probed on every one of
+	// the load balancers' checks
+	healthCacheTTL = 2 * time.Second
+)
+
+// Overall health verdicts
+const (
+	HealthHealthy   = "healthy"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+)
+
+// HealthReport is the gateway's health: healthy when every upstream is up,
+// degraded when some are down and unhealthy when none are
+type HealthReport struct {
+	Status    string                    `json:"status"`
+	Service   string                    `json:"service"`
+	Date      string                    `json:"date"`
+	Upstreams map[string]UpstreamHealth `json:"upstreams"`
+}
+
+// UpstreamHealth is an upstream's health: up when any of its instances
+// answered its /health with a 2xx. Latency is the fastest such answer.
+type UpstreamHealth struct {
+	Status    string           `json:"status"`
+	LatencyMS int64            `json:"latency_ms"`
+	Instances []InstanceHealth `json:"instances"`
+}
+
+// InstanceHealth is one upstream instance's answer to the health probe
+type InstanceHealth struct {
+	URL       string `json:"url"`
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Circuit   string `json:"circuit"`
+	Error     string `json:"error,omitempty"`
+}
+
+// healthCache holds the last report for healthCacheTTL
+type healthCache struct {
+	mu      sync.Mutex
+	report  *HealthReport
+	expires time.Time
+}
+
+// handleHealth probes every upstream and answers 200 unless all are down,
+// so load balancers keep a degraded gateway in rotation
+func (h *GatewayHandler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	report := h.health(r.Context())
+	status := http.StatusOK
+	if report.Status == HealthUnhealthy {
+		status = http.StatusServiceUnavailable
+	}
+	jsonutil.WriteJSON(w, status, report)
+}
+
+// health returns the cached report, or probes the upstreams. Concurrent
+// checks wait for the one probing rather than probe again.
+func (h *GatewayHandler) health(ctx context.Context) *HealthReport {
+	// The report is shared, so a caller going away must not cut it short
+	ctx = context.WithoutCancel(ctx)
+
+	h.healthCache.mu.Lock()
+	defer h.healthCache.mu.Unlock()
+
+	if h.healthCache.report != nil && time.Now().Before(h.healthCache.expires) {
+		return h.healthCache.report
+	}
+
+	cfg := h.config.Load()
+	report := &HealthReport{
+		Service:   "gateway",
+		Date:      time.Now().Format(time.DateTime),
+		Upstreams: make(map[string]UpstreamHealth, len(cfg.Upstreams)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name := range cfg.Upstreams {
+		pool, err := h.pool(cfg, name)
+		if err != nil {
+			report.Upstreams[name] = UpstreamHealth{Status: "down"}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			upstream := probeUpstream(ctx, h.healthClient, pool)
+			mu.Lock()
+			report.Upstreams[name] = upstream
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	up := 0
+	for _, upstream := range report.Upstreams {
+		if upstream.Status == "up" {
+			up++
+		}
+	}
+	switch {
+	case up == len(report.Upstreams):
+		report.Status = HealthHealthy
+	case up == 0:
+		report.Status = HealthUnhealthy
+	default:
+		report.Status = HealthDegraded
+	}
+
+	h.healthCache.report = report
+	h.healthCache.expires = time.Now().Add(healthCacheTTL)
+	return report
+}
+
+// probeUpstream checks the /health of each of the upstream's instances at
+// the same time
+func probeUpstream(ctx context.Context, client *http.Client, pool *upstreamPool) UpstreamHealth {
+	instances := make([]InstanceHealth, len(pool.instances))
+	var wg sync.WaitGroup
+	for i, inst := range pool.instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			instances[i] = probeInstance(ctx, client, inst)
+		}()
+	}
+	wg.Wait()
+
+	upstream := UpstreamHealth{Status: "down", Instances: instances}
+	for _, inst := range instances {
+		if inst.Status == "up" && (upstream.Status != "up" || inst.LatencyMS < upstream.LatencyMS) {
+			upstream.Status = "up"
+			upstream.LatencyMS = inst.LatencyMS
+		}
+	}
+	return upstream
+}
+
+func probeInstance(ctx context.Context, client *http.Client, inst *upstreamInstance) InstanceHealth {
+	result := InstanceHealth{
+		URL:     inst.url.String(),
+		Status:  "down",
+		Circuit: inst.breaker.State().String(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, inst.url.JoinPath("/health").String(), nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	result.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result.Error = resp.Status
+		return result
+	}
+	result.Status = "up"
+	return result
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/observability"
+)
+
+// healthGateway is a gateway whose only upstreams are the given ones
+func healthGateway(upstreams map[string]string) *GatewayHandler {
+	cfg := defaultGatewayConfig()
+	cfg.Upstreams = upstreams
+	return NewGatewayHandler(cfg, nil, nil, nil, testHMACSecret, observability.NewLogger("gateway-test"))
+}
+
+func checkHealth(t *testing.T, h *GatewayHandler) (int, HealthReport) {
+	t.Helper()
+	w := serve(h, "GET", "/health", nil, nil)
+	var report HealthReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report %s: %v", w.Body, err)
+	}
+	return w.Code, report
+}
+
+func TestGatewayHandler_Health(t *testing.T) {
+	up, down := newUpstream(t, http.StatusOK), newUpstream(t, http.StatusInternalServerError)
+
+	tests := []struct {
+		name       string
+		upstreams  map[string]string
+		wantCode   int
+		wantStatus string
+	}{
+		{"All up", map[string]string{"payment": up.URL, "ledger": up.URL}, http.StatusOK, HealthHealthy},
+		{"Some down", map[string]string{"payment": up.URL, "ledger": down.URL}, http.StatusOK, HealthDegraded},
+		{"All down", map[string]string{"payment": down.URL, "ledger": "http://127.0.0.1:1"}, http.StatusServiceUnavailable, HealthUnhealthy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, report := checkHealth(t, healthGateway(tt.upstreams))
+			if code != tt.wantCode || report.Status != tt.wantStatus {
+				t.Errorf("Expected %d %s, got %d %s", tt.wantCode, tt.wantStatus, code, report.Status)
+			}
+			if len(report.Upstreams) != len(tt.upstreams) || report.Service != "gateway" {
+				t.Errorf("Expected every upstream reported, got %+v", report)
+			}
+		})
+	}
+
+	if got := up.last().URL.Path; got != "/health" {
+		t.Errorf("Expected the upstreams' /health probed, got %s", got)
+	}
+}
+
+func TestGatewayHandler_HealthInstances(t *testing.T) {
+	up, down := newUpstream(t, http.StatusOK), newUpstream(t, http.StatusInternalServerError)
+	h := healthGateway(map[string]string{"payment": down.URL + "," + up.URL})
+
+	code, report := checkHealth(t, h)
+	if code != http.StatusOK || report.Status != HealthHealthy {
+		t.Fatalf("Expected an upstream with one instance up healthy, got %d %s", code, report.Status)
+	}
+	payment := report.Upstreams["payment"]
+	if payment.Status != "up" || len(payment.Instances) != 2 {
+		t.Fatalf("Expected the upstream up with both instances reported, got %+v", payment)
+	}
+	want := []InstanceHealth{
+		{URL: down.URL, Status: "down", Circuit: "closed", Error: "500 Internal Server Error"},
+		{URL: up.URL, Status: "up", Circuit: "closed"},
+	}
+	for i, inst := range payment.Instances {
+		inst.LatencyMS = 0
+		if inst != want[i] {
+			t.Errorf("Instance %d: expected %+v, got %+v", i+1, want[i], inst)
+		}
+	}
+
+	// Checks within the cache's lifetime are answered without probing again
+	before := up.hits()
+	for i := 0; i < 3; i++ {
+		checkHealth(t, h)
+	}
+	if up.hits() != before {
+		t.Errorf("Expected the cached report served, got %d more probes", up.hits()-before)
+	}
+}
@@ -4,19 +4,26 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/sapliy/fintech-ecosystem/pkg/apikey"
 	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
 	"github.com/sapliy/fintech-ecosystem/pkg/observability"
+	"github.com/sapliy/fintech-ecosystem/pkg/ratelimit"
 	"github.com/sapliy/fintech-ecosystem/pkg/scopes"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 
 	pb "github.com/sapliy/fintech-ecosystem/proto/auth"
 	walletpb "github.com/sapliy/fintech-ecosystem/proto/wallet"
@@ -37,44 +44,63 @@ var (
 	}, []string{"method", "path", "status"})
 )
 
-// GatewayHandler holds the configuration for upstream service URLs and Redis.
+// GatewayHandler holds the routing configuration, which can be swapped
+// while serving, and the clients of the services it calls itself.
 type GatewayHandler struct {
-	authServiceURL         string
-	paymentServiceURL      string
-	ledgerServiceURL       string
-	walletServiceURL       string
-	billingServiceURL      string
-	eventsServiceURL       string
-	flowServiceURL         string
-	notificationServiceURL string
-	rdb                    *redis.Client
-	upgrader               websocket.Upgrader
-	authClient             pb.AuthServiceClient
-	walletClient           walletpb.WalletServiceClient
-	hmacSecret             string
-	logger                 *observability.Logger
+	config       atomic.Pointer[GatewayConfig]
+	transports   sync.Map // Upstream timeout -> *http.Transport
+	pools        sync.Map // Upstream and breaker settings -> *upstreamPool
+	transcoders  sync.Map // gRPC upstream and address -> http.Handler
+	healthClient *http.Client
+	healthCache  healthCache
+	rdb          *redis.Client
+	limiter      rateLimiter
+	upgrader     websocket.Upgrader
+	authClient   pb.AuthServiceClient
+	walletClient walletpb.WalletServiceClient
+	hmacSecret   string
+	logger       *observability.Logger
+}
+
+// rateLimiter is the part of *ratelimit.Limiter the gateway uses
+type rateLimiter interface {
+	AllowTokenBucket(ctx context.Context, key string, n int64, cfg ratelimit.Config) (*ratelimit.Result, error)
 }
 
 // NewGatewayHandler creates a new instance of GatewayHandler.
-func NewGatewayHandler(auth, payment, ledger, wallet, billing, events, flow, notification string, rdb *redis.Client, authClient pb.AuthServiceClient, walletClient walletpb.WalletServiceClient, hmacSecret string, logger *observability.Logger) *GatewayHandler {
-	return &GatewayHandler{
-		authServiceURL:         auth,
-		paymentServiceURL:      payment,
-		ledgerServiceURL:       ledger,
-		walletServiceURL:       wallet,
-		billingServiceURL:      billing,
-		eventsServiceURL:       events,
-		flowServiceURL:         flow,
-		notificationServiceURL: notification,
-		rdb:                    rdb,
+func NewGatewayHandler(cfg *GatewayConfig, rdb *redis.Client, authClient pb.AuthServiceClient, walletClient walletpb.WalletServiceClient, hmacSecret string, logger *observability.Logger) *GatewayHandler {
+	h := &GatewayHandler{
+		rdb:     rdb,
+		limiter: ratelimit.NewLimiter(rdb, ratelimit.WithKeyPrefix("gateway:ratelimit:")),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool { return true },
 		},
+		healthClient: &http.Client{Timeout: healthProbeTimeout},
 		authClient:   authClient,
 		walletClient: walletClient,
 		hmacSecret:   hmacSecret,
 		logger:       logger,
 	}
+	h.SetConfig(cfg)
+	return h
+}
+
+// SetConfig switches the gateway to the config; requests already being
+// proxied finish with the old one
+func (h *GatewayHandler) SetConfig(cfg *GatewayConfig) {
+	h.config.Store(cfg)
+}
+
+// transport returns the shared transport for upstreams with the timeout, so
+// connections are reused across requests
+func (h *GatewayHandler) transport(timeout time.Duration) http.RoundTripper {
+	if t, ok := h.transports.Load(timeout); ok {
+		return t.(*http.Transport)
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ResponseHeaderTimeout = timeout
+	actual, _ := h.transports.LoadOrStore(timeout, t)
+	return actual.(*http.Transport)
 }
 
 // validateKeyWithAuthService calls the Auth service to validate the API key hash.
@@ -88,36 +114,75 @@ func (h *GatewayHandler) validateKeyWithAuthService(ctx context.Context, keyHash
 	return res.UserId, res.Environment, res.Scopes, res.OrgId, res.Role, res.RateLimitQuota, res.ZoneId, res.Mode, res.KeyType, res.Valid
 }
 
-// checkRateLimit checks if the key has exceeded its quota.
-func (h *GatewayHandler) checkRateLimit(ctx context.Context, keyHash string, quota int32) (bool, error) {
-	if quota <= 0 {
-		quota = 100 // Default fallback
-	}
-	window := time.Now().Unix() / 60
-	redisKey := fmt.Sprintf("rate_limit:%s:%d", keyHash, window)
-
-	count, err := h.rdb.Incr(ctx, redisKey).Result()
+// rateLimit takes a token from the client's bucket, setting the rate limit
+// headers, and answers 429 when the bucket is empty. A route with a rate
+// limit has buckets of its own; elsewhere an API key's quota, per minute, or
+// the gateway's default applies.
+func (h *GatewayHandler) rateLimit(w http.ResponseWriter, r *http.Request, cfg *GatewayConfig, route *RouteConfig, client string, quota int32) bool {
+	limit := cfg.RateLimit
+	bucket := client
+	if route != nil && route.RateLimit != nil {
+		limit = *route.RateLimit
+		bucket = client + ":" + route.Prefix
+	} else if quota > 0 {
+		limit = ratelimit.Config{Limit: int64(quota), Window: time.Minute, Burst: int64(quota)}
+	}
+
+	result, err := h.limiter.AllowTokenBucket(r.Context(), bucket, 1, limit)
 	if err != nil {
-		return false, err
+		h.logger.Error("Redis error in rate limiter", "error", err)
+		// Fail open or closed? Closed for security.
+		jsonutil.WriteErrorJSON(w, "Internal Server Error")
+		return false
 	}
 
-	if count == 1 {
-		h.rdb.Expire(ctx, redisKey, 60*time.Second)
+	w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(limit.Limit, 10))
+	w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+	if !result.Allowed {
+		retryAfter := max(int64(math.Ceil(result.RetryAfter.Seconds())), 1)
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+		jsonutil.WriteJSON(w, http.StatusTooManyRequests, map[string]string{"error": "Rate limit exceeded"})
+		return false
 	}
+	return true
+}
 
-	return count <= int64(quota), nil
+// clientIP is the address the request came from, for limiting callers
+// without credentials
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
 
-// proxyRequest creates a reverse proxy to the target URL and serves the request.
-func (h *GatewayHandler) proxyRequest(target string, w http.ResponseWriter, r *http.Request) {
-	targetURL, err := url.Parse(target)
+// proxyRequest creates a reverse proxy to the named upstream and serves the
+// request, waiting up to timeout for each try's response headers and trying
+// idempotent requests up to retries more times on other instances.
+func (h *GatewayHandler) proxyRequest(cfg *GatewayConfig, upstream string, timeout time.Duration, retries int, w http.ResponseWriter, r *http.Request) {
+	if spec := cfg.specs[upstream]; spec != nil && !h.validateRequest(w, r, spec) {
+		return
+	}
+
+	pool, err := h.pool(cfg, upstream)
 	if err != nil {
-		h.logger.Error("Error parsing target URL", "target", target, "error", err)
+		h.logger.Error("Error parsing upstream URL", "upstream", upstream, "error", err)
 		jsonutil.WriteErrorJSON(w, "Internal Server Error; Invalid Target")
 		return
 	}
 
+	// The transport picks the instance of each try; instances share a path
+	targetURL := pool.instances[0].url
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+	proxy.Transport = &upstreamTransport{pool: pool, base: h.transport(timeout), retries: retries}
+	if entry := accessLog(r.Context()); entry != nil {
+		entry.upstream = upstream
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		h.upstreamError(w, upstream, cfg.CircuitBreaker.OpenTimeout, err)
+	}
 	originalDirector := proxy.Director
 	proxy.Director = func(req *http.Request) {
 		originalDirector(req)
@@ -138,138 +203,133 @@ func (h *GatewayHandler) proxyRequest(target string, w http.ResponseWriter, r *h
 		if mode := r.Header.Get("X-Zone-Mode"); mode != "" {
 			req.Header.Set("X-Zone-Mode", mode)
 		}
+		// Continue the gateway's trace upstream
+		otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
 	}
 
 	proxy.ServeHTTP(w, r)
 }
 
+// validateRequest checks the request against the upstream's OpenAPI spec,
+// answering 400 with what is wrong if it is not valid
+func (h *GatewayHandler) validateRequest(w http.ResponseWriter, r *http.Request, spec *openAPISpec) bool {
+	problems, err := spec.validateRequest(r)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, "Failed to read request body")
+		return false
+	}
+	if len(problems) > 0 {
+		jsonutil.WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":   "Invalid request",
+			"details": problems,
+		})
+		return false
+	}
+	return true
+}
+
+// upstreamError answers a request the upstream failed: 503 at once while
+// its breakers are open, 504 when it timed out and 502 otherwise
+func (h *GatewayHandler) upstreamError(w http.ResponseWriter, upstream string, openTimeout time.Duration, err error) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errUpstreamUnavailable):
+		w.Header().Set("Retry-After", strconv.Itoa(int(openTimeout.Seconds())))
+		jsonutil.WriteJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "Service Unavailable"})
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		h.logger.Warn("Upstream timed out", "upstream", upstream, "error", err)
+		jsonutil.WriteJSON(w, http.StatusGatewayTimeout, map[string]string{"error": "Gateway Timeout"})
+	default:
+		h.logger.Error("Upstream request failed", "upstream", upstream, "error", err)
+		jsonutil.WriteJSON(w, http.StatusBadGateway, map[string]string{"error": "Bad Gateway"})
+	}
+}
+
 // ServeHTTP implements the http.Handler interface with Middleware.
 func (h *GatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
-	h.logger.Info("Incoming request", "method", r.Method, "path", path)
 
 	if strings.HasPrefix(path, "/auth") || path == "/health" {
 		h.logger.Debug("Routing public path", "path", path)
+		clearIdentity(r)
 		h.routePublic(w, r)
 		return
 	}
 
-	// Protected Endpoints (API Key Required)
-	// Extract Secret Key
-	authHeader := r.Header.Get("Authorization")
-	apiKey := ""
-	if strings.HasPrefix(authHeader, "Bearer ") {
-		apiKey = strings.TrimPrefix(authHeader, "Bearer ")
-	} else {
-		// Fallback to query parameter for WebSockets or simple GETs
-		apiKey = r.URL.Query().Get("api_key")
+	// Route to Service
+	// Handle /v1 prefix by optional stripping
+	p := path
+	if after, ok := strings.CutPrefix(p, "/v1"); ok {
+		p = after
 	}
 
-	if apiKey == "" || (!strings.HasPrefix(apiKey, "sk_") && !strings.HasPrefix(apiKey, "pk_")) {
-		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Missing or invalid API Key"})
+	cfg := h.config.Load()
+	route, routed := cfg.match(p)
+	if routed && route.Public {
+		clearIdentity(r)
+		if !h.rateLimit(w, r, cfg, route, "ip_"+clientIP(r), 0) {
+			return
+		}
+		h.proxyRoute(cfg, route, path, p, w, r)
 		return
 	}
 
-	// Hash Key
-	keyHash := apikey.HashKey(apiKey, h.hmacSecret)
+	// Protected Endpoints (API Key or token required)
+	caller, err := h.authenticate(r.Context(), credentials(r))
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": authErrorMessages[err]})
+		return
+	}
 
-	// Validate with Auth Service
-	userID, env, keyScopes, orgID, role, quota, zoneID, mode, keyType, valid := h.validateKeyWithAuthService(r.Context(), keyHash)
-	if !valid {
-		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid or revoked API Key"})
+	if err := h.bindZone(r.Context(), caller, r.Header.Get("X-Zone-ID")); err != nil {
+		if err == errNoZoneAccess {
+			jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": "No access to zone"})
+			return
+		}
+		h.logger.Error("Auth service gRPC zone resolution call failed", "error", err)
+		jsonutil.WriteJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "Service Unavailable"})
 		return
 	}
 
 	// Key Type Enforcement (Example: pk_ keys can only emit events)
-	if keyType == "publishable" && !strings.HasPrefix(path, "/v1/events/emit") {
+	if caller.KeyType == "publishable" && !strings.HasPrefix(path, "/v1/events/emit") {
 		jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": "Publishable keys only allowed for event emission"})
 		return
 	}
 
-	// Scope Enforcement
-	requiredScope := scopes.GetRequiredScope(path, r.Method)
-	if requiredScope != "" && !scopes.HasScope(keyScopes, requiredScope) {
-		jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{
-			"error":          "Insufficient scope",
-			"required_scope": requiredScope,
-		})
+	// Scope Enforcement, by the path without /v1
+	requiredScope := scopes.GetRequiredScope(p, r.Method)
+	if caller.Scoped && !scopes.HasScope(caller.Scopes, requiredScope) {
+		scopes.WriteInsufficientScope(w, requiredScope, caller.Scopes)
 		return
 	}
 
 	// Rate Limiting
-	allowed, err := h.checkRateLimit(r.Context(), keyHash, quota)
-	if err != nil {
-		h.logger.Error("Redis error in rate limiter", "error", err)
-		// Fail open or closed? Closed for security.
-		jsonutil.WriteErrorJSON(w, "Internal Server Error")
-		return
-	}
-	if !allowed {
-		w.Header().Set("Retry-After", "60")
-		jsonutil.WriteJSON(w, http.StatusTooManyRequests, map[string]string{"error": "Rate limit exceeded"})
+	if !h.rateLimit(w, r, cfg, route, caller.RateKey, caller.Quota) {
 		return
 	}
 
 	// Inject Context
-	r.Header.Set("X-User-ID", userID)
-	r.Header.Set("X-Environment", env)
-	r.Header.Set("X-Org-ID", orgID)
-	r.Header.Set("X-Role", role)
-	r.Header.Set("X-Zone-ID", zoneID)
-	r.Header.Set("X-Zone-Mode", mode)
-
-	// Route to Service
-	// Handle /v1 prefix by optional stripping
-	p := path
-	if after, ok := strings.CutPrefix(p, "/v1"); ok {
-		p = after
-	}
+	injectIdentity(r, caller)
 
+	// Handled by the gateway itself rather than proxied
 	switch {
-	case strings.HasPrefix(p, "/payments"):
-		http.StripPrefix(path[:len(path)-len(p)]+"/payments", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			h.proxyRequest(h.paymentServiceURL, w, r)
-		})).ServeHTTP(w, r)
-
-	case strings.HasPrefix(p, "/ledger"):
-		http.StripPrefix(path[:len(path)-len(p)]+"/ledger", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			h.proxyRequest(h.ledgerServiceURL, w, r)
-		})).ServeHTTP(w, r)
-
-	case strings.HasPrefix(p, "/wallets"):
-		h.proxyRequest(h.walletServiceURL, w, r)
-
-	case strings.HasPrefix(p, "/billing"):
-		http.StripPrefix(path[:len(path)-len(p)]+"/billing", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			h.proxyRequest(h.billingServiceURL, w, r)
-		})).ServeHTTP(w, r)
-
-	case strings.HasPrefix(p, "/webhooks") || strings.HasPrefix(p, "/notifications"):
-		h.proxyRequest(h.notificationServiceURL, w, r)
-
-	case strings.HasPrefix(p, "/events"):
-		// Use internal WS handler for events stream, or proxy for others
-		if p == "/events/stream" && websocket.IsWebSocketUpgrade(r) {
-			h.handleWebSocket(w, r)
-			return
-		}
-		if p == "/events/emit" && r.Method == http.MethodPost {
-			h.handleEventEmit(w, r)
-			return
-		}
-		h.proxyRequest(h.eventsServiceURL, w, r)
-
-	case strings.HasPrefix(p, "/flows") || strings.HasPrefix(p, "/executions"):
-		h.proxyRequest(h.flowServiceURL, w, r)
-
+	case p == "/events/stream" && websocket.IsWebSocketUpgrade(r):
+		h.handleWebSocket(w, r)
+		return
+	case p == "/events/emit" && r.Method == http.MethodPost:
+		h.handleEventEmit(w, r)
+		return
 	case p == "/ws": // Legacy or alternative WS path
 		if websocket.IsWebSocketUpgrade(r) {
 			h.handleWebSocket(w, r)
 			return
 		}
 		jsonutil.WriteErrorJSON(w, "WebSocket upgrade required")
+		return
+	}
 
-	default:
+	if !routed {
 		// Fallback for root path if it's a WebSocket upgrade
 		if (p == "/" || p == "") && websocket.IsWebSocketUpgrade(r) {
 			h.handleWebSocket(w, r)
@@ -277,6 +337,28 @@ func (h *GatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		h.logger.Warn("Route not found", "path", path)
 		jsonutil.WriteErrorJSON(w, "Not Found")
+		return
+	}
+	h.proxyRoute(cfg, route, path, p, w, r)
+}
+
+// proxyRoute proxies the request to the route's upstream. p is the path
+// without its /v1 prefix.
+func (h *GatewayHandler) proxyRoute(cfg *GatewayConfig, route *RouteConfig, path, p string, w http.ResponseWriter, r *http.Request) {
+	if route.Transcode {
+		h.transcodeRoute(cfg, route, p, w, r)
+		return
+	}
+	proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.proxyRequest(cfg, route.Upstream, route.Timeout, *route.Retries, w, r)
+	})
+	switch route.Strip {
+	case StripPrefix:
+		http.StripPrefix(path[:len(path)-len(p)]+route.Prefix, proxy).ServeHTTP(w, r)
+	case StripVersion:
+		http.StripPrefix(path[:len(path)-len(p)], proxy).ServeHTTP(w, r)
+	default:
+		proxy.ServeHTTP(w, r)
 	}
 }
 
@@ -451,17 +533,13 @@ func (h *GatewayHandler) handleEventEmit(w http.ResponseWriter, r *http.Request)
 func (h *GatewayHandler) routePublic(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 	if strings.HasPrefix(path, "/auth") {
+		cfg := h.config.Load()
 		http.StripPrefix("/auth", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			h.proxyRequest(h.authServiceURL, w, r)
+			h.proxyRequest(cfg, "auth", cfg.UpstreamTimeout, cfg.Retries, w, r)
 		})).ServeHTTP(w, r)
 		return
 	}
-	// Health
-	jsonutil.WriteJSON(w, http.StatusOK, map[string]string{
-		"status":  "active",
-		"service": "gateway",
-		"date":    time.Now().Format(time.DateTime),
-	})
+	h.handleHealth(w, r)
 }
 
 // CORSMiddleware adds Cross-Origin Resource Sharing headers so the
@@ -488,7 +566,8 @@ func CORSMiddleware(allowedOrigins string, next http.Handler) http.Handler {
 		}
 
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key, X-Zone-ID")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key, X-API-Key, X-Request-ID, X-Zone-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-Request-ID")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		w.Header().Set("Access-Control-Max-Age", "86400")
 
@@ -511,47 +590,13 @@ func main() {
 		redisAddr = "localhost:6379"
 	}
 
-	authURL := os.Getenv("AUTH_SERVICE_URL")
-	if authURL == "" {
-		authURL = "http://127.0.0.1:8081"
-	}
-
-	paymentURL := os.Getenv("PAYMENT_SERVICE_URL")
-	if paymentURL == "" {
-		paymentURL = "http://127.0.0.1:8082"
-	}
-
-	ledgerURL := os.Getenv("LEDGER_SERVICE_URL")
-	if ledgerURL == "" {
-		ledgerURL = "http://127.0.0.1:8083"
-	}
-
-	walletURL := os.Getenv("WALLET_SERVICE_URL")
-	if walletURL == "" {
-		walletURL = "http://127.0.0.1:8085"
-	}
-
-	notificationURL := os.Getenv("NOTIFICATION_SERVICE_URL")
-	if notificationURL == "" {
-		notificationURL = "http://127.0.0.1:8084"
-	}
-
-	eventsURL := os.Getenv("EVENTS_SERVICE_URL")
-	if eventsURL == "" {
-		eventsURL = "http://127.0.0.1:8089"
-	}
-
-	flowURL := os.Getenv("FLOW_SERVICE_URL")
-	if flowURL == "" {
-		flowURL = "http://127.0.0.1:8088"
-	}
-
-	billingURL := os.Getenv("BILLING_SERVICE_URL")
-	if billingURL == "" {
-		billingURL = "http://127.0.0.1:8089" // Assuming a port for billing REST if added, or proxying gRPC?
-		// Note: Billing seems gRPC only currently, but Gateway proxies HTTP.
-		// For now, I'll point it to where billing might listen or keep it for future REST expansion.
-		// If Billing has no REST handlers, this will 404/502 which is expected for now.
+	// Routes and upstreams come from GATEWAY_CONFIG_FILE, if set, and the
+	// *_SERVICE_URL variables; SIGHUP or editing the file reloads them
+	configFile := os.Getenv("GATEWAY_CONFIG_FILE")
+	cfg, err := LoadGatewayConfig(configFile)
+	if err != nil {
+		logger.Error("Failed to load gateway config", "error", err)
+		os.Exit(1)
 	}
 
 	rdb := redis.NewClient(&redis.Options{
@@ -627,7 +672,8 @@ func main() {
 		logger.Warn("API_KEY_HMAC_SECRET not set, using default for dev")
 	}
 
-	gateway := NewGatewayHandler(authURL, paymentURL, ledgerURL, walletURL, billingURL, eventsURL, flowURL, notificationURL, rdb, authClient, walletClient, hmacSecret, logger)
+	gateway := NewGatewayHandler(cfg, rdb, authClient, walletClient, hmacSecret, logger)
+	go WatchGatewayConfig(context.Background(), configFile, logger, gateway.SetConfig)
 
 	// CORS configuration
 	corsOrigins := os.Getenv("CORS_ALLOWED_ORIGINS")
@@ -636,9 +682,10 @@ func main() {
 		logger.Info("CORS_ALLOWED_ORIGINS not set, defaulting to localhost:3000")
 	}
 
-	// Wrap handler with CORS, OpenTelemetry and Prometheus
+	// Wrap handler with CORS, access logging, OpenTelemetry and Prometheus
 	corsHandler := CORSMiddleware(corsOrigins, gateway)
-	otelHandler := otelhttp.NewHandler(corsHandler, "gateway-request")
+	accessLogHandler := AccessLogMiddleware(logger, corsHandler)
+	otelHandler := otelhttp.NewHandler(accessLogHandler, "gateway-request")
 	promHandler := monitoring.PrometheusMiddleware(otelHandler)
 
 	server := &http.Server{
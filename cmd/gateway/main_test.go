@@ -0,0 +1,623 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sapliy/fintech-ecosystem/pkg/apikey"
+	"github.com/sapliy/fintech-ecosystem/pkg/jwtutil"
+	"github.com/sapliy/fintech-ecosystem/pkg/monitoring"
+	"github.com/sapliy/fintech-ecosystem/pkg/observability"
+	"github.com/sapliy/fintech-ecosystem/pkg/ratelimit"
+	"github.com/sapliy/fintech-ecosystem/pkg/resilience"
+	pb "github.com/sapliy/fintech-ecosystem/proto/auth"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const testHMACSecret = "test-secret"
+
+// Keys the test auth service knows
+const (
+	secretKey      = "sk_live_1"
+	publishableKey = "pk_live_1"
+	limitedKey     = "sk_live_limited"
+	quotaKey       = "sk_live_quota"
+	noScopeKey     = "sk_live_noscope"
+)
+
+// fakeLimiter allows every bucket but those it denies, recording the
+// buckets taken from and their limits
+type fakeLimiter struct {
+	mu      sync.Mutex
+	denied  map[string]bool
+	buckets []string
+	limits  []ratelimit.Config
+}
+
+func (l *fakeLimiter) AllowTokenBucket(ctx context.Context, key string, n int64, cfg ratelimit.Config) (*ratelimit.Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buckets = append(l.buckets, key)
+	l.limits = append(l.limits, cfg)
+	if l.denied[key] {
+		return &ratelimit.Result{Allowed: false, ResetAt: time.Now().Add(time.Minute), RetryAfter: 1500 * time.Millisecond}, nil
+	}
+	return &ratelimit.Result{Allowed: true, Remaining: cfg.Burst - 1, ResetAt: time.Now().Add(time.Minute)}, nil
+}
+
+func (l *fakeLimiter) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buckets, l.limits = nil, nil
+}
+
+// upstream is a test upstream instance that answers with status and
+// records the requests it got
+type upstream struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+	status   int
+}
+
+func newUpstream(t *testing.T, status int) *upstream {
+	t.Helper()
+	u := &upstream{status: status}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.requests = append(u.requests, r)
+		u.mu.Unlock()
+		w.WriteHeader(u.status)
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func (u *upstream) hits() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.requests)
+}
+
+func (u *upstream) last() *http.Request {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.requests[len(u.requests)-1]
+}
+
+// testGateway routes the payment and auth upstreams to the given URLs,
+// with configure's changes to the default config
+func testGateway(t *testing.T, payment, auth string, configure func(*GatewayConfig)) (*GatewayHandler, *fakeLimiter) {
+	t.Helper()
+	cfg := defaultGatewayConfig()
+	cfg.Upstreams["payment"] = payment
+	cfg.Upstreams["auth"] = auth
+	if configure != nil {
+		configure(cfg)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("Invalid config: %v", err)
+	}
+
+	key := func(k string) string { return apikey.HashKey(k, testHMACSecret) }
+	authService := &fakeAuth{
+		keys: map[string]*pb.ValidateKeyResponse{
+			key(secretKey):      {Valid: true, UserId: "user_1", OrgId: "org_1", Role: "developer", ZoneId: "zone_1", Mode: "live", Environment: "live", KeyType: "secret", Scopes: "*"},
+			key(publishableKey): {Valid: true, UserId: "user_1", ZoneId: "zone_1", Mode: "live", KeyType: "publishable", Scopes: "*"},
+			key(limitedKey):     {Valid: true, UserId: "user_2", ZoneId: "zone_2", Mode: "test", KeyType: "secret", Scopes: "*"},
+			key(quotaKey):       {Valid: true, UserId: "user_2", ZoneId: "zone_2", Mode: "test", KeyType: "secret", Scopes: "*", RateLimitQuota: 500},
+			key(noScopeKey):     {Valid: true, UserId: "user_1", ZoneId: "zone_1", Mode: "live", KeyType: "secret", Scopes: "ledger:read"},
+		},
+		zones: map[string]*pb.ResolveZoneResponse{
+			"user_1/zone_1": {Allowed: true, OrgId: "org_1", Mode: "live", Role: "admin"},
+		},
+		tokens: map[string]*pb.ValidateTokenResponse{
+			"oauth_token_1": {Valid: true, ClientId: "client_1", UserId: "user_3", Scope: "payments:read"},
+		},
+	}
+	limiter := &fakeLimiter{denied: map[string]bool{key(limitedKey): true}}
+	h := NewGatewayHandler(cfg, nil, authService, nil, testHMACSecret, observability.NewLogger("gateway-test"))
+	h.limiter = limiter
+	return h, limiter
+}
+
+func serve(h http.Handler, method, target string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	var r *http.Request
+	if body != nil {
+		r = httptest.NewRequest(method, target, bytes.NewReader(body))
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestGatewayHandler_PublicPaths(t *testing.T) {
+	auth := newUpstream(t, http.StatusOK)
+	status := newUpstream(t, http.StatusOK)
+	h, _ := testGateway(t, auth.URL, auth.URL, func(cfg *GatewayConfig) {
+		cfg.Upstreams["status"] = status.URL
+		cfg.Routes = append(cfg.Routes, RouteConfig{Prefix: "/status", Upstream: "status", Public: true})
+	})
+
+	spoofed := map[string]string{}
+	for _, header := range identityHeaders {
+		spoofed[header] = "spoofed"
+	}
+
+	for target, upstream := range map[string]*upstream{"/auth/login": auth, "/v1/status/components": status} {
+		w := serve(h, "POST", target, []byte(`{}`), spoofed)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 without credentials, got %d: %s", target, w.Code, w.Body)
+		}
+		r := upstream.last()
+		for _, header := range identityHeaders {
+			if got := r.Header.Get(header); got != "" {
+				t.Errorf("%s: expected %s stripped, got %q", target, header, got)
+			}
+		}
+	}
+	if got := auth.last().URL.Path; got != "/login" {
+		t.Errorf("Expected /auth stripped for the auth service, got %s", got)
+	}
+	if got := status.last().URL.Path; got != "/v1/status/components" {
+		t.Errorf("Expected the public route's path proxied as is, got %s", got)
+	}
+
+	w := serve(h, "GET", "/v1/payments/payment_intents", nil, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a protected path to require credentials, got %d", w.Code)
+	}
+}
+
+func TestGatewayHandler_IdentityHeaders(t *testing.T) {
+	payments := newUpstream(t, http.StatusOK)
+	h, _ := testGateway(t, payments.URL, payments.URL, nil)
+
+	// Identity headers the caller sent never reach the upstream
+	with := func(headers map[string]string) map[string]string {
+		merged := map[string]string{}
+		for _, header := range identityHeaders {
+			merged[header] = "spoofed"
+		}
+		for k, v := range headers {
+			merged[k] = v
+		}
+		return merged
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    map[string]string
+	}{
+		{"API key", map[string]string{"X-API-Key": secretKey}, map[string]string{
+			"X-User-ID": "user_1", "X-Org-ID": "org_1", "X-Role": "developer", "X-Zone-ID": "zone_1", "X-Zone-Mode": "live", "X-Environment": "live", "X-Scopes": "*",
+		}},
+		// The session's zone, role and mode come from the user's membership
+		// of the zone the request names; its scopes are not restricted
+		{"Session token", map[string]string{"Authorization": "Bearer " + sessionToken(t, &jwtutil.Claims{UserID: "user_1"}), "X-Zone-ID": "zone_1"}, map[string]string{
+			"X-User-ID": "user_1", "X-Org-ID": "org_1", "X-Role": "admin", "X-Zone-ID": "zone_1", "X-Zone-Mode": "live", "X-Environment": "", "X-Scopes": "",
+		}},
+		{"OAuth token", map[string]string{"Authorization": "Bearer oauth_token_1"}, map[string]string{
+			"X-User-ID": "user_3", "X-Org-ID": "", "X-Role": "", "X-Zone-ID": "", "X-Zone-Mode": "", "X-Environment": "", "X-Scopes": "payments:read",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, "GET", "/v1/payments/payment_intents", nil, with(tt.headers))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+			}
+			r := payments.last()
+			for header, value := range tt.want {
+				if got := r.Header.Get(header); got != value {
+					t.Errorf("Expected %s %q, got %q", header, value, got)
+				}
+			}
+			if r.URL.Path != "/payment_intents" {
+				t.Errorf("Expected the route's prefix stripped, got %s", r.URL.Path)
+			}
+		})
+	}
+}
+
+func TestGatewayHandler_Rejections(t *testing.T) {
+	payments := newUpstream(t, http.StatusOK)
+	h, _ := testGateway(t, payments.URL, payments.URL, nil)
+
+	tests := []struct {
+		name      string
+		headers   map[string]string
+		wantCode  int
+		wantError string
+	}{
+		{"No credentials", nil, http.StatusUnauthorized, "Missing API Key or token"},
+		{"Unknown API key", map[string]string{"X-API-Key": "sk_live_unknown"}, http.StatusUnauthorized, "Invalid or revoked API Key"},
+		{"Invalid token", map[string]string{"Authorization": "Bearer not-a-token"}, http.StatusUnauthorized, "Invalid or expired token"},
+		{"Zone of another organization", map[string]string{"Authorization": "Bearer " + sessionToken(t, &jwtutil.Claims{UserID: "user_1", OrgID: "org_2"}), "X-Zone-ID": "zone_1"}, http.StatusForbidden, "No access to zone"},
+		{"Publishable key outside event emission", map[string]string{"X-API-Key": publishableKey}, http.StatusForbidden, "Publishable keys only allowed for event emission"},
+		{"Out of the key's scopes", map[string]string{"X-API-Key": noScopeKey}, http.StatusForbidden, "Insufficient scope"},
+		{"Rate limited", map[string]string{"X-API-Key": limitedKey}, http.StatusTooManyRequests, "Rate limit exceeded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, "GET", "/v1/payments/payment_intents", nil, tt.headers)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d: %s", tt.wantCode, w.Code, w.Body)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Expected a JSON error, got %s", w.Body)
+			}
+			if body["error"] != tt.wantError {
+				t.Errorf("Expected error %q, got %v", tt.wantError, body["error"])
+			}
+		})
+	}
+
+	if payments.hits() != 0 {
+		t.Errorf("Expected no rejected request proxied, got %d", payments.hits())
+	}
+
+	t.Run("Retry-After", func(t *testing.T) {
+		w := serve(h, "GET", "/v1/payments/payment_intents", nil, map[string]string{"X-API-Key": limitedKey})
+		if got := w.Header().Get("Retry-After"); got != "2" {
+			t.Errorf("Expected Retry-After rounded up to 2 seconds, got %q", got)
+		}
+		if w.Header().Get("X-RateLimit-Remaining") != "0" || w.Header().Get("X-RateLimit-Limit") != "100" || w.Header().Get("X-RateLimit-Reset") == "" {
+			t.Errorf("Expected the rate limit headers, got %v", w.Header())
+		}
+	})
+}
+
+func TestGatewayHandler_RateLimitBuckets(t *testing.T) {
+	payments := newUpstream(t, http.StatusOK)
+	status := newUpstream(t, http.StatusOK)
+	reportLimit := ratelimit.Config{Limit: 10, Window: time.Second, Burst: 10}
+	h, limiter := testGateway(t, payments.URL, payments.URL, func(cfg *GatewayConfig) {
+		cfg.Upstreams["status"] = status.URL
+		cfg.Routes = append(cfg.Routes,
+			RouteConfig{Prefix: "/status", Upstream: "status", Public: true},
+			RouteConfig{Prefix: "/reports", Upstream: "payment", RateLimit: &reportLimit},
+		)
+	})
+	keyBucket := apikey.HashKey(secretKey, testHMACSecret)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		headers    map[string]string
+		wantBucket string
+		wantLimit  ratelimit.Config
+	}{
+		{"API key", "GET", "/v1/payments/payment_intents", map[string]string{"X-API-Key": secretKey}, keyBucket, defaultRateLimit},
+		{"API key's quota", "GET", "/v1/payments/payment_intents", map[string]string{"X-API-Key": quotaKey},
+			apikey.HashKey(quotaKey, testHMACSecret), ratelimit.Config{Limit: 500, Window: time.Minute, Burst: 500}},
+		{"Session token", "GET", "/v1/flows", map[string]string{"Authorization": "Bearer " + sessionToken(t, &jwtutil.Claims{UserID: "user_1"})}, "user_user_1", defaultRateLimit},
+		{"OAuth token", "GET", "/v1/payments/payment_intents", map[string]string{"Authorization": "Bearer oauth_token_1"}, "oauth_client_1_user_3", defaultRateLimit},
+		{"Public route by IP", "POST", "/v1/status/components", nil, "ip_192.0.2.1", defaultRateLimit},
+		{"Route's own limit", "GET", "/v1/reports/daily", map[string]string{"X-API-Key": secretKey}, keyBucket + ":/reports", reportLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter.reset()
+			serve(h, tt.method, tt.target, nil, tt.headers)
+			if len(limiter.buckets) != 1 || limiter.buckets[0] != tt.wantBucket {
+				t.Fatalf("Expected the %s bucket, got %v", tt.wantBucket, limiter.buckets)
+			}
+			if limiter.limits[0] != tt.wantLimit {
+				t.Errorf("Expected the limit %+v, got %+v", tt.wantLimit, limiter.limits[0])
+			}
+		})
+	}
+}
+
+func TestGatewayHandler_CircuitBreaker(t *testing.T) {
+	payments := newUpstream(t, http.StatusBadGateway)
+	h, _ := testGateway(t, payments.URL, payments.URL, func(cfg *GatewayConfig) {
+		cfg.CircuitBreaker = CircuitBreakerConfig{Failures: 2, OpenTimeout: 30 * time.Second}
+		cfg.Retries = 0
+	})
+	key := map[string]string{"X-API-Key": secretKey}
+
+	// The upstream's own error passes through until the breaker opens
+	for i := 0; i < 2; i++ {
+		if w := serve(h, "GET", "/v1/payments/payment_intents", nil, key); w.Code != http.StatusBadGateway {
+			t.Fatalf("Request %d: expected the upstream's 502, got %d", i+1, w.Code)
+		}
+	}
+
+	w := serve(h, "GET", "/v1/payments/payment_intents", nil, key)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 once the breaker is open, got %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Expected Retry-After of the open timeout, got %q", got)
+	}
+	if payments.hits() != 2 {
+		t.Errorf("Expected the open breaker to fail fast, got %d upstream hits", payments.hits())
+	}
+
+	t.Run("Breakers survive a reload", func(t *testing.T) {
+		before, _ := h.pool(h.config.Load(), "payment")
+		reloaded := *h.config.Load()
+		h.SetConfig(&reloaded)
+		after, _ := h.pool(&reloaded, "payment")
+		if after != before || after.instances[0].breaker.State() != resilience.StateOpen {
+			t.Error("Expected the reloaded config to keep the instance's open breaker")
+		}
+	})
+
+	t.Run("Closed again once the upstream recovers", func(t *testing.T) {
+		recovering := newUpstream(t, http.StatusBadGateway)
+		h, _ := testGateway(t, recovering.URL, recovering.URL, func(cfg *GatewayConfig) {
+			cfg.CircuitBreaker = CircuitBreakerConfig{Failures: 1, OpenTimeout: 100 * time.Millisecond}
+			cfg.Retries = 0
+		})
+		serve(h, "GET", "/v1/payments/payment_intents", nil, key)
+		if w := serve(h, "GET", "/v1/payments/payment_intents", nil, key); w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Expected the breaker open, got %d", w.Code)
+		}
+
+		recovering.mu.Lock()
+		recovering.status = http.StatusOK
+		recovering.mu.Unlock()
+		time.Sleep(150 * time.Millisecond)
+		for i := 0; i < 3; i++ {
+			if w := serve(h, "GET", "/v1/payments/payment_intents", nil, key); w.Code != http.StatusOK {
+				t.Fatalf("Request %d after the open timeout: expected 200, got %d", i+1, w.Code)
+			}
+		}
+	})
+}
+
+func TestGatewayHandler_Retries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		body     []byte
+		wantHits int
+	}{
+		{"GET", "GET", nil, 3},
+		{"HEAD", "HEAD", nil, 3},
+		{"GET with a body", "GET", []byte(`{"q":1}`), 1},
+		{"POST", "POST", []byte(`{}`), 1},
+		{"DELETE", "DELETE", nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, second := newUpstream(t, http.StatusServiceUnavailable), newUpstream(t, http.StatusServiceUnavailable)
+			h, _ := testGateway(t, first.URL+","+second.URL, first.URL, func(cfg *GatewayConfig) {
+				cfg.Retries = 2
+				cfg.CircuitBreaker.Failures = 100
+			})
+
+			w := serve(h, tt.method, "/v1/payments/refunds", tt.body, map[string]string{"X-API-Key": secretKey})
+			if w.Code != http.StatusServiceUnavailable {
+				t.Fatalf("Expected the last try's 503, got %d", w.Code)
+			}
+			if hits := first.hits() + second.hits(); hits != tt.wantHits {
+				t.Errorf("Expected %d tries, got %d", tt.wantHits, hits)
+			}
+			if tt.wantHits > 1 && (first.hits() == 0 || second.hits() == 0) {
+				t.Errorf("Expected retries spread over both instances, got %d and %d", first.hits(), second.hits())
+			}
+		})
+	}
+
+	t.Run("Retry succeeds on another instance", func(t *testing.T) {
+		down, up := newUpstream(t, http.StatusBadGateway), newUpstream(t, http.StatusOK)
+		h, _ := testGateway(t, down.URL+","+up.URL, down.URL, func(cfg *GatewayConfig) {
+			cfg.Retries = 1
+			cfg.CircuitBreaker.Failures = 100
+		})
+		for i := 0; i < 4; i++ {
+			if w := serve(h, "GET", "/v1/payments/refunds", nil, map[string]string{"X-API-Key": secretKey}); w.Code != http.StatusOK {
+				t.Fatalf("Request %d: expected 200, got %d", i+1, w.Code)
+			}
+		}
+	})
+
+	t.Run("Route without retries", func(t *testing.T) {
+		first, second := newUpstream(t, http.StatusServiceUnavailable), newUpstream(t, http.StatusServiceUnavailable)
+		none := 0
+		h, _ := testGateway(t, first.URL+","+second.URL, first.URL, func(cfg *GatewayConfig) {
+			cfg.Retries = 2
+			cfg.Routes = append(cfg.Routes, RouteConfig{Prefix: "/payments/payouts", Upstream: "payment", Retries: &none})
+		})
+		serve(h, "GET", "/v1/payments/payouts", nil, map[string]string{"X-API-Key": secretKey})
+		if hits := first.hits() + second.hits(); hits != 1 {
+			t.Errorf("Expected the route's retries to replace the default, got %d tries", hits)
+		}
+	})
+}
+
+func TestGatewayHandler_UpstreamErrors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	t.Cleanup(slow.Close)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name     string
+		upstream string
+		wantCode int
+	}{
+		{"Timed out", slow.URL, http.StatusGatewayTimeout},
+		{"Unreachable", closed.URL, http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := testGateway(t, tt.upstream, tt.upstream, func(cfg *GatewayConfig) {
+				cfg.UpstreamTimeout = 50 * time.Millisecond
+				cfg.Retries = 0
+			})
+			w := serve(h, "GET", "/v1/payments/payment_intents", nil, map[string]string{"X-API-Key": secretKey})
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected %d, got %d: %s", tt.wantCode, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestGatewayHandler_FlowAndFraudRoutes(t *testing.T) {
+	flow, fraud := newUpstream(t, http.StatusOK), newUpstream(t, http.StatusOK)
+	h, _ := testGateway(t, flow.URL, flow.URL, func(cfg *GatewayConfig) {
+		cfg.Upstreams["flow"] = flow.URL
+		cfg.Upstreams["fraud"] = fraud.URL
+	})
+
+	for target, upstream := range map[string]*upstream{
+		"/v1/flows/flow_1":            flow,
+		"/v1/executions/exec_1":       flow,
+		"/v1/flow-templates":          flow,
+		"/v1/debug/sessions/dbg_1":    flow,
+		"/v1/fraud/cases?status=open": fraud,
+		"/v1/fraud/rules/velocity_ip": fraud,
+	} {
+		before := upstream.hits()
+		if w := serve(h, "GET", target, nil, map[string]string{"X-API-Key": secretKey}); w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", target, w.Code, w.Body)
+		}
+		if upstream.hits() != before+1 {
+			t.Fatalf("%s: expected the request routed to its upstream", target)
+		}
+		if got := upstream.last().URL.RequestURI(); got != target {
+			t.Errorf("Expected %s proxied as is, got %s", target, got)
+		}
+	}
+}
+
+func TestGatewayHandler_WebSocket(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	identity := make(chan string, 1)
+	flow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity <- r.Header.Get("X-User-ID")
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		for {
+			kind, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(kind, append([]byte("echo: "), msg...)); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(flow.Close)
+
+	h, _ := testGateway(t, flow.URL, flow.URL, func(cfg *GatewayConfig) {
+		cfg.Upstreams["flow"] = flow.URL
+	})
+	// Wrapped as main wraps it, so the upgrade has to get through every layer
+	handler := monitoring.PrometheusMiddleware(otelhttp.NewHandler(AccessLogMiddleware(h.logger, CORSMiddleware("*", h)), "gateway-test"))
+	gateway := httptest.NewServer(handler)
+	t.Cleanup(gateway.Close)
+
+	url := "ws" + gateway.URL[len("http"):] + "/v1/debug/sessions/dbg_1/ws?api_key=" + secretKey
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial through the gateway: %v (%v)", err, resp)
+	}
+	defer func() { _ = conn.Close() }()
+	if got := <-identity; got != "user_1" {
+		t.Errorf("Expected the upgrade authenticated as user_1, got %q", got)
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("step")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil || string(msg) != "echo: step" {
+		t.Errorf("Expected the upstream's echo, got %q, %v", msg, err)
+	}
+
+	if _, resp, err := websocket.DefaultDialer.Dial("ws"+gateway.URL[len("http"):]+"/v1/debug/sessions/dbg_1/ws", nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected an upgrade without credentials refused, got %v", err)
+	}
+}
+
+func TestGatewayHandler_OpenAPIValidation(t *testing.T) {
+	payments := newUpstream(t, http.StatusOK)
+	h, _ := testGateway(t, payments.URL, payments.URL, func(cfg *GatewayConfig) {
+		specs, err := LoadOpenAPISpecs("../../config/openapi")
+		if err != nil {
+			t.Fatalf("Failed to load specs: %v", err)
+		}
+		cfg.specs = specs
+	})
+	key := map[string]string{"X-API-Key": secretKey, "Content-Type": "application/json"}
+
+	tests := []struct {
+		name        string
+		target      string
+		body        string
+		wantCode    int
+		wantDetails []string
+	}{
+		{"Valid", "/v1/payments/payment_intents", `{"amount": 1000, "currency": "USD"}`, http.StatusOK, nil},
+		{"Missing field", "/v1/payments/payment_intents", `{"amount": 1000}`, http.StatusBadRequest, []string{"currency"}},
+		{"Below minimum", "/v1/payments/payment_intents", `{"amount": 0, "currency": "USD"}`, http.StatusBadRequest, []string{"amount"}},
+		{"Wrong type", "/v1/payments/payment_intents", `{"amount": "1000", "currency": "USD"}`, http.StatusBadRequest, []string{"amount"}},
+		{"Not in enum", "/v1/payments/disputes/dp_1/close", `{"outcome": "draw"}`, http.StatusBadRequest, []string{"outcome"}},
+		{"Operation without a spec", "/v1/payments/refunds", `{"anything": true}`, http.StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := payments.hits()
+			w := serve(h, "POST", tt.target, []byte(tt.body), key)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d: %s", tt.wantCode, w.Code, w.Body)
+			}
+			if tt.wantCode != http.StatusBadRequest {
+				if payments.hits() != before+1 {
+					t.Error("Expected the valid request proxied")
+				}
+				return
+			}
+			if payments.hits() != before {
+				t.Error("Expected the invalid request not proxied")
+			}
+
+			var body struct {
+				Error   string   `json:"error"`
+				Details []string `json:"details"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error != "Invalid request" {
+				t.Fatalf("Expected an invalid request error, got %s", w.Body)
+			}
+			for _, want := range tt.wantDetails {
+				if !strings.Contains(strings.Join(body.Details, "; "), want) {
+					t.Errorf("Expected the details to name %s, got %v", want, body.Details)
+				}
+			}
+		})
+	}
+}
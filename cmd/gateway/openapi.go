@@ -0,0 +1,560 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	// maxValidatedBodyBytes bounds the bodies read for validation. Larger
+	// bodies are passed upstream unvalidated.
+	maxValidatedBodyBytes = 1 << 20
+	// maxValidationErrors bounds the problems reported for a request
+	maxValidationErrors = 10
+)
+
+// openAPISpec is the subset of an OpenAPI 3 document the gateway validates
+// requests against: operations' header and query parameters and JSON
+// request bodies
+type openAPISpec struct {
+	Paths      map[string]*openAPIPathItem `yaml:"paths"`
+	Components struct {
+		Schemas    map[string]*jsonSchema       `yaml:"schemas"`
+		Parameters map[string]*openAPIParameter `yaml:"parameters"`
+	} `yaml:"components"`
+
+	routes []openAPIRoute
+}
+
+type openAPIPathItem struct {
+	Parameters []*openAPIParameter `yaml:"parameters"`
+	Get        *openAPIOperation   `yaml:"get"`
+	Put        *openAPIOperation   `yaml:"put"`
+	Post       *openAPIOperation   `yaml:"post"`
+	Delete     *openAPIOperation   `yaml:"delete"`
+	Patch      *openAPIOperation   `yaml:"patch"`
+}
+
+type openAPIOperation struct {
+	Parameters  []*openAPIParameter `yaml:"parameters"`
+	RequestBody *struct {
+		Required bool `yaml:"required"`
+		Content  map[string]struct {
+			Schema *jsonSchema `yaml:"schema"`
+		} `yaml:"content"`
+	} `yaml:"requestBody"`
+}
+
+type openAPIParameter struct {
+	Ref      string      `yaml:"$ref"`
+	Name     string      `yaml:"name"`
+	In       string      `yaml:"in"`
+	Required bool        `yaml:"required"`
+	Schema   *jsonSchema `yaml:"schema"`
+}
+
+// jsonSchema is the subset of JSON Schema used by OpenAPI request bodies
+type jsonSchema struct {
+	Ref                  string                 `yaml:"$ref"`
+	Type                 string                 `yaml:"type"`
+	Nullable             bool                   `yaml:"nullable"`
+	Required             []string               `yaml:"required"`
+	Properties           map[string]*jsonSchema `yaml:"properties"`
+	AdditionalProperties yaml.Node              `yaml:"additionalProperties"`
+	Items                *jsonSchema            `yaml:"items"`
+	Enum                 []any                  `yaml:"enum"`
+	Minimum              *float64               `yaml:"minimum"`
+	Maximum              *float64               `yaml:"maximum"`
+	MinLength            *int                   `yaml:"minLength"`
+	MaxLength            *int                   `yaml:"maxLength"`
+	Pattern              string                 `yaml:"pattern"`
+	MinItems             *int                   `yaml:"minItems"`
+	MaxItems             *int                   `yaml:"maxItems"`
+	AllOf                []*jsonSchema          `yaml:"allOf"`
+	OneOf                []*jsonSchema          `yaml:"oneOf"`
+	AnyOf                []*jsonSchema          `yaml:"anyOf"`
+
+	ref        *jsonSchema
+	pattern    *regexp.Regexp
+	additional *jsonSchema
+	closed     bool
+}
+
+// openAPIRoute is an operation's path template compiled for matching
+type openAPIRoute struct {
+	segments []string
+	item     *openAPIPathItem
+}
+
+// LoadOpenAPISpecs loads the spec of each upstream from dir, named after
+// the upstream, e.g. payment.yaml or payment.json for the payment upstream
+func LoadOpenAPISpecs(dir string) (map[string]*openAPISpec, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenAPI specs: %w", err)
+	}
+	specs := make(map[string]*openAPISpec)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OpenAPI spec %s: %w", entry.Name(), err)
+		}
+		spec, err := parseOpenAPISpec(data)
+		if err != nil {
+			return nil, fmt.Errorf("OpenAPI spec %s: %w", entry.Name(), err)
+		}
+		specs[strings.TrimSuffix(entry.Name(), ext)] = spec
+	}
+	return specs, nil
+}
+
+// parseOpenAPISpec parses a YAML or JSON spec and resolves its references
+func parseOpenAPISpec(data []byte) (*openAPISpec, error) {
+	var spec openAPISpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+
+	resolved := map[*jsonSchema]bool{}
+	for name, s := range spec.Components.Schemas {
+		if err := spec.resolveSchema(s, resolved); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	for path, item := range spec.Paths {
+		if err := spec.resolveParameters(item.Parameters, resolved); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for method, op := range item.operations() {
+			if err := spec.resolveParameters(op.Parameters, resolved); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			if op.RequestBody == nil {
+				continue
+			}
+			for _, media := range op.RequestBody.Content {
+				if err := spec.resolveSchema(media.Schema, resolved); err != nil {
+					return nil, fmt.Errorf("%s %s: %w", method, path, err)
+				}
+			}
+		}
+		spec.routes = append(spec.routes, openAPIRoute{segments: strings.Split(strings.Trim(path, "/"), "/"), item: item})
+	}
+
+	// Literal segments win over templated ones, so /payments/search is
+	// matched before /payments/{id}
+	sort.SliceStable(spec.routes, func(i, j int) bool {
+		return templatedSegments(spec.routes[i].segments) < templatedSegments(spec.routes[j].segments)
+	})
+	return &spec, nil
+}
+
+func templatedSegments(segments []string) int {
+	n := 0
+	for _, s := range segments {
+		if strings.HasPrefix(s, "{") {
+			n++
+		}
+	}
+	return n
+}
+
+func (item *openAPIPathItem) operations() map[string]*openAPIOperation {
+	ops := map[string]*openAPIOperation{}
+	for method, op := range map[string]*openAPIOperation{
+		http.MethodGet:    item.Get,
+		http.MethodPut:    item.Put,
+		http.MethodPost:   item.Post,
+		http.MethodDelete: item.Delete,
+		http.MethodPatch:  item.Patch,
+	} {
+		if op != nil {
+			ops[method] = op
+		}
+	}
+	return ops
+}
+
+func (spec *openAPISpec) resolveParameters(params []*openAPIParameter, resolved map[*jsonSchema]bool) error {
+	for i, p := range params {
+		if p.Ref != "" {
+			name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/")
+			target := spec.Components.Parameters[name]
+			if !ok || target == nil {
+				return fmt.Errorf("unknown parameter %s", p.Ref)
+			}
+			params[i] = target
+			p = target
+		}
+		if err := spec.resolveSchema(p.Schema, resolved); err != nil {
+			return fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// resolveSchema links the schema's references to the component schemas and
+// compiles its patterns
+func (spec *openAPISpec) resolveSchema(s *jsonSchema, resolved map[*jsonSchema]bool) error {
+	if s == nil || resolved[s] {
+		return nil
+	}
+	resolved[s] = true
+
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		s.ref = spec.Components.Schemas[name]
+		if !ok || s.ref == nil {
+			return fmt.Errorf("unknown schema %s", s.Ref)
+		}
+		return spec.resolveSchema(s.ref, resolved)
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = re
+	}
+	if s.AdditionalProperties.Kind != 0 {
+		if s.AdditionalProperties.Kind == yaml.ScalarNode {
+			var allowed bool
+			if err := s.AdditionalProperties.Decode(&allowed); err != nil {
+				return fmt.Errorf("additionalProperties: %w", err)
+			}
+			s.closed = !allowed
+		} else {
+			s.additional = &jsonSchema{}
+			if err := s.AdditionalProperties.Decode(s.additional); err != nil {
+				return fmt.Errorf("additionalProperties: %w", err)
+			}
+		}
+	}
+
+	children := []*jsonSchema{s.Items, s.additional}
+	for _, prop := range s.Properties {
+		children = append(children, prop)
+	}
+	children = append(children, s.AllOf...)
+	children = append(children, s.OneOf...)
+	children = append(children, s.AnyOf...)
+	for _, child := range children {
+		if err := spec.resolveSchema(child, resolved); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// operation returns the spec's operation for the request, with the
+// parameters of its path, or nil if the spec does not describe it
+func (spec *openAPISpec) operation(method, path string) (*openAPIOperation, []*openAPIParameter) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, route := range spec.routes {
+		if !matchSegments(route.segments, segments) {
+			continue
+		}
+		op := route.item.operations()[method]
+		if op == nil {
+			return nil, nil
+		}
+		return op, append(append([]*openAPIParameter{}, route.item.Parameters...), op.Parameters...)
+	}
+	return nil, nil
+}
+
+func matchSegments(template, segments []string) bool {
+	if len(template) != len(segments) {
+		return false
+	}
+	for i, s := range template {
+		if !strings.HasPrefix(s, "{") && s != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRequest checks the request against its operation in the spec,
+// returning what is wrong with it. Requests the spec does not describe are
+// not checked. The body is read and put back for the upstream.
+func (spec *openAPISpec) validateRequest(r *http.Request) ([]string, error) {
+	op, params := spec.operation(r.Method, r.URL.Path)
+	if op == nil {
+		return nil, nil
+	}
+
+	v := &validation{}
+	query := r.URL.Query()
+	for _, p := range params {
+		var value string
+		var present bool
+		switch p.In {
+		case "header":
+			value = r.Header.Get(p.Name)
+			present = value != ""
+		case "query":
+			present = query.Has(p.Name)
+			value = query.Get(p.Name)
+		default:
+			continue
+		}
+		if !present {
+			if p.Required {
+				v.addf("%s %s is required", p.In, p.Name)
+			}
+			continue
+		}
+		if p.Schema != nil {
+			v.validate(parseParameter(value, p.Schema), p.Schema, p.In+" "+p.Name)
+		}
+	}
+
+	if op.RequestBody != nil {
+		if err := v.validateBody(r, op); err != nil {
+			return nil, err
+		}
+	}
+	return v.errs, nil
+}
+
+func (v *validation) validateBody(r *http.Request, op *openAPIOperation) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBodyBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxValidatedBodyBytes {
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil
+	}
+	r.Body = readCloser{bytes.NewReader(body), r.Body}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			v.addf("request body is required")
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "" {
+		mediaType = "application/json"
+	}
+	media, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		v.addf("unsupported content type %s", mediaType)
+		return nil
+	}
+	if media.Schema == nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		v.addf("request body is not valid JSON: %v", err)
+		return nil
+	}
+	v.validate(value, media.Schema, "body")
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// parseParameter converts a header or query value to the schema's type so
+// it can be validated like a JSON value
+func parseParameter(value string, s *jsonSchema) any {
+	for s.ref != nil {
+		s = s.ref
+	}
+	switch s.Type {
+	case "integer", "number":
+		return json.Number(value)
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// validation collects the problems found in a request
+type validation struct {
+	errs []string
+}
+
+func (v *validation) addf(format string, args ...any) {
+	if len(v.errs) < maxValidationErrors {
+		v.errs = append(v.errs, fmt.Sprintf(format, args...))
+	}
+}
+
+// validate checks a decoded JSON value against the schema, naming the
+// value by path in the problems found
+func (v *validation) validate(value any, s *jsonSchema, path string) {
+	for s.ref != nil {
+		s = s.ref
+	}
+	if value == nil {
+		if !s.Nullable && s.Type != "" {
+			v.addf("%s must not be null", path)
+		}
+		return
+	}
+
+	for _, sub := range s.AllOf {
+		v.validate(value, sub, path)
+	}
+	if len(s.OneOf) > 0 || len(s.AnyOf) > 0 {
+		alternatives := append(append([]*jsonSchema{}, s.OneOf...), s.AnyOf...)
+		matched := false
+		for _, sub := range alternatives {
+			alt := &validation{}
+			alt.validate(value, sub, path)
+			if len(alt.errs) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.addf("%s does not match any of the allowed schemas", path)
+		}
+	}
+
+	if !v.validateType(value, s, path) {
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(value, s.Enum) {
+		v.addf("%s must be one of %s", path, formatEnum(s.Enum))
+	}
+
+	switch value := value.(type) {
+	case string:
+		length := len([]rune(value))
+		if s.MinLength != nil && length < *s.MinLength {
+			v.addf("%s must be at least %d characters", path, *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			v.addf("%s must be at most %d characters", path, *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			v.addf("%s must match %s", path, s.Pattern)
+		}
+	case json.Number:
+		f, _ := value.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			v.addf("%s must be at least %v", path, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			v.addf("%s must be at most %v", path, *s.Maximum)
+		}
+	case []any:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			v.addf("%s must have at least %d items", path, *s.MinItems)
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			v.addf("%s must have at most %d items", path, *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				v.validate(item, s.Items, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				v.addf("%s.%s is required", path, name)
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := s.Properties[name]; ok {
+				v.validate(value[name], prop, path+"."+name)
+			} else if s.additional != nil {
+				v.validate(value[name], s.additional, path+"."+name)
+			} else if s.closed {
+				v.addf("%s.%s is not allowed", path, name)
+			}
+		}
+	}
+}
+
+// validateType reports whether the value has the schema's type, noting the
+// problem if not
+func (v *validation) validateType(value any, s *jsonSchema, path string) bool {
+	ok := true
+	switch s.Type {
+	case "":
+	case "string":
+		_, ok = value.(string)
+	case "boolean":
+		_, ok = value.(bool)
+	case "array":
+		_, ok = value.([]any)
+	case "object":
+		_, ok = value.(map[string]any)
+	case "number":
+		n, isNumber := value.(json.Number)
+		if ok = isNumber; ok {
+			_, err := n.Float64()
+			ok = err == nil
+		}
+	case "integer":
+		n, isNumber := value.(json.Number)
+		if ok = isNumber; ok {
+			f, err := n.Float64()
+			ok = err == nil && f == math.Trunc(f)
+		}
+	}
+	if !ok {
+		article := "a"
+		if strings.ContainsAny(s.Type[:1], "aeiou") {
+			article = "an"
+		}
+		v.addf("%s must be %s %s", path, article, s.Type)
+	}
+	return ok
+}
+
+func inEnum(value any, enum []any) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatEnum(enum []any) string {
+	values := make([]string, len(enum))
+	for i, allowed := range enum {
+		values[i] = fmt.Sprint(allowed)
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSpec = `
+openapi: 3.0.3
+paths:
+  /orders:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          required: true
+          schema:
+            type: integer
+            minimum: 1
+            maximum: 100
+        - name: expand
+          in: query
+          schema:
+            type: boolean
+    post:
+      parameters:
+        - $ref: "#/components/parameters/IdempotencyKey"
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Order"
+  /orders/search:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [query]
+  /orders/{id}:
+    parameters:
+      - name: X-Zone-ID
+        in: header
+        required: true
+        schema:
+          type: string
+    patch:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              additionalProperties: false
+              properties:
+                note:
+                  type: string
+                  nullable: true
+                contact:
+                  oneOf:
+                    - type: object
+                      required: [email]
+                    - type: object
+                      required: [phone]
+components:
+  parameters:
+    IdempotencyKey:
+      name: Idempotency-Key
+      in: header
+      required: true
+      schema:
+        type: string
+        maxLength: 8
+  schemas:
+    Order:
+      type: object
+      required: [amount, currency, customer]
+      properties:
+        amount:
+          type: integer
+          minimum: 1
+        currency:
+          type: string
+          pattern: "^[A-Z]{3}$"
+        status:
+          type: string
+          enum: [pending, paid]
+        customer:
+          $ref: "#/components/schemas/Customer"
+        items:
+          type: array
+          minItems: 1
+          items:
+            type: object
+            required: [sku]
+            properties:
+              sku:
+                type: string
+                minLength: 2
+              quantity:
+                type: integer
+        metadata:
+          type: object
+          additionalProperties:
+            type: string
+    Customer:
+      type: object
+      required: [id]
+      properties:
+        id:
+          type: string
+        address:
+          type: object
+          required: [country]
+          properties:
+            country:
+              type: string
+              maxLength: 2
+`
+
+const validOrder = `{"amount": 100, "currency": "USD", "customer": {"id": "cus_1"}}`
+
+func TestOpenAPISpec_ValidateRequest(t *testing.T) {
+	spec, err := parseOpenAPISpec([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("parseOpenAPISpec failed: %v", err)
+	}
+	idempotent := map[string]string{"Idempotency-Key": "key_1"}
+	zoned := map[string]string{"X-Zone-ID": "zone_1"}
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		headers map[string]string
+		body    string
+		want    []string
+	}{
+		{"Valid body", "POST", "/orders", idempotent, validOrder, nil},
+		{"Missing required header", "POST", "/orders", nil, validOrder, []string{"header Idempotency-Key is required"}},
+		{"Header too long", "POST", "/orders", map[string]string{"Idempotency-Key": "much-too-long"}, validOrder, []string{"header Idempotency-Key must be at most 8 characters"}},
+		{"Path item header", "PATCH", "/orders/ord_1", nil, `{}`, []string{"header X-Zone-ID is required"}},
+		{"Valid query", "GET", "/orders?limit=10&expand=true", nil, "", nil},
+		{"Missing required query", "GET", "/orders", nil, "", []string{"query limit is required"}},
+		{"Query of the wrong type", "GET", "/orders?limit=ten&expand=maybe", nil, "", []string{"query limit must be an integer", "query expand must be a boolean"}},
+		{"Query out of range", "GET", "/orders?limit=0", nil, "", []string{"query limit must be at least 1"}},
+		{"Missing required body", "POST", "/orders", idempotent, "", []string{"request body is required"}},
+		{"Optional body", "POST", "/orders/search", nil, "", nil},
+		{"Literal segment matched first", "POST", "/orders/search", nil, `{}`, []string{"body.query is required"}},
+		{"Invalid JSON", "POST", "/orders", idempotent, `{"amount":`, []string{"request body is not valid JSON"}},
+		{"Wrong body type", "POST", "/orders", idempotent, `[]`, []string{"body must be an object"}},
+		{"Missing fields", "POST", "/orders", idempotent, `{"amount": 100}`, []string{"body.currency is required", "body.customer is required"}},
+		{"Wrong field types", "POST", "/orders", idempotent, `{"amount": 1.5, "currency": 840, "customer": {"id": "cus_1"}}`, []string{"body.amount must be an integer", "body.currency must be a string"}},
+		{"Below minimum", "POST", "/orders", idempotent, `{"amount": 0, "currency": "USD", "customer": {"id": "cus_1"}}`, []string{"body.amount must be at least 1"}},
+		{"Pattern", "POST", "/orders", idempotent, `{"amount": 100, "currency": "usd", "customer": {"id": "cus_1"}}`, []string{"body.currency must match ^[A-Z]{3}$"}},
+		{"Not in enum", "POST", "/orders", idempotent, `{"amount": 100, "currency": "USD", "customer": {"id": "cus_1"}, "status": "lost"}`, []string{"body.status must be one of pending, paid"}},
+		{"Nested object through a reference", "POST", "/orders", idempotent, `{"amount": 100, "currency": "USD", "customer": {"address": {"country": "USA"}}}`, []string{"body.customer.id is required", "body.customer.address.country must be at most 2 characters"}},
+		{"Array items", "POST", "/orders", idempotent, `{"amount": 100, "currency": "USD", "customer": {"id": "cus_1"}, "items": [{"sku": "a", "quantity": "2"}, {}]}`, []string{"body.items[0].quantity must be an integer", "body.items[0].sku must be at least 2 characters", "body.items[1].sku is required"}},
+		{"Too few items", "POST", "/orders", idempotent, `{"amount": 100, "currency": "USD", "customer": {"id": "cus_1"}, "items": []}`, []string{"body.items must have at least 1 items"}},
+		{"Additional properties schema", "POST", "/orders", idempotent, `{"amount": 100, "currency": "USD", "customer": {"id": "cus_1"}, "metadata": {"a": "b", "c": 1}}`, []string{"body.metadata.c must be a string"}},
+		{"Additional properties not allowed", "PATCH", "/orders/ord_1", zoned, `{"note": "x", "extra": 1}`, []string{"body.extra is not allowed"}},
+		{"Nullable", "PATCH", "/orders/ord_1", zoned, `{"note": null}`, nil},
+		{"Matching one of", "PATCH", "/orders/ord_1", zoned, `{"contact": {"phone": "555"}}`, nil},
+		{"Matching none of", "PATCH", "/orders/ord_1", zoned, `{"contact": {}}`, []string{"body.contact does not match any of the allowed schemas"}},
+		{"Operation not described", "DELETE", "/orders/ord_1", nil, `not json`, nil},
+		{"Path not described", "POST", "/customers", nil, `not json`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			got, err := spec.validateRequest(r)
+			if err != nil {
+				t.Fatalf("validateRequest failed: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for i := range tt.want {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("Expected %q, got %q", tt.want[i], got[i])
+				}
+			}
+
+			// The body is put back for the upstream
+			if body, _ := io.ReadAll(r.Body); string(body) != tt.body {
+				t.Errorf("Expected the body %q kept, got %q", tt.body, body)
+			}
+		})
+	}
+}
+
+func TestOpenAPISpec_ValidateBodyLimits(t *testing.T) {
+	spec, err := parseOpenAPISpec([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("parseOpenAPISpec failed: %v", err)
+	}
+	validate := func(contentType, body string) []string {
+		r := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", "key_1")
+		r.Header.Set("Content-Type", contentType)
+		problems, err := spec.validateRequest(r)
+		if err != nil {
+			t.Fatalf("validateRequest failed: %v", err)
+		}
+		return problems
+	}
+
+	if got := validate("text/plain", "hello"); !reflect.DeepEqual(got, []string{"unsupported content type text/plain"}) {
+		t.Errorf("Expected the content type refused, got %v", got)
+	}
+
+	large := `{"amount": "x", "padding": "` + strings.Repeat("a", maxValidatedBodyBytes) + `"}`
+	if got := validate("application/json", large); len(got) != 0 {
+		t.Errorf("Expected a body over the limit passed on unvalidated, got %v", got)
+	}
+
+	items := strings.TrimSuffix(strings.Repeat(`{},`, 20), ",")
+	if got := validate("application/json", `{"amount": 100, "currency": "USD", "customer": {"id": "cus_1"}, "items": [`+items+`]}`); len(got) != maxValidationErrors {
+		t.Errorf("Expected the problems capped at %d, got %d", maxValidationErrors, len(got))
+	}
+}
+
+func TestParseOpenAPISpec_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"Unknown schema", "paths:\n  /a:\n    post:\n      requestBody:\n        content:\n          application/json:\n            schema:\n              $ref: '#/components/schemas/Missing'\n"},
+		{"Unknown parameter", "paths:\n  /a:\n    get:\n      parameters:\n        - $ref: '#/components/parameters/Missing'\n"},
+		{"Invalid pattern", "components:\n  schemas:\n    A:\n      type: string\n      pattern: '['\n"},
+		{"Invalid YAML", "paths: [\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseOpenAPISpec([]byte(tt.spec)); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestLoadGatewayConfig_OpenAPI(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "payment.yaml"), []byte(testSpec), 0o600); err != nil {
+		t.Fatalf("Failed to write spec: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a spec"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	t.Setenv("GATEWAY_OPENAPI_DIR", dir)
+
+	cfg, err := LoadGatewayConfig("")
+	if err != nil {
+		t.Fatalf("LoadGatewayConfig failed: %v", err)
+	}
+	if len(cfg.specs) != 1 || cfg.specs["payment"] == nil {
+		t.Errorf("Expected the payment spec loaded, got %v", cfg.specs)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "unknown.yaml"), []byte(testSpec), 0o600); err != nil {
+		t.Fatalf("Failed to write spec: %v", err)
+	}
+	if _, err := LoadGatewayConfig(""); err == nil {
+		t.Error("Expected a spec for an unknown upstream to fail")
+	}
+}
+
+// TestGatewayHandler_OpenAPIBody checks the 400 answered for an invalid
+// request names each problem
+func TestGatewayHandler_OpenAPIBody(t *testing.T) {
+	payments := newUpstream(t, http.StatusOK)
+	h, _ := testGateway(t, payments.URL, payments.URL, func(cfg *GatewayConfig) {
+		spec, err := parseOpenAPISpec([]byte(testSpec))
+		if err != nil {
+			t.Fatalf("parseOpenAPISpec failed: %v", err)
+		}
+		cfg.specs = map[string]*openAPISpec{"payment": spec}
+	})
+
+	w := serve(h, "POST", "/v1/payments/orders", []byte(`{"amount": 0}`), map[string]string{"X-API-Key": secretKey})
+	want := `{"details":["header Idempotency-Key is required","body.currency is required","body.customer is required","body.amount must be at least 1"],"error":"Invalid request"}`
+	if w.Code != http.StatusBadRequest || strings.TrimSpace(w.Body.String()) != want {
+		t.Errorf("Expected 400 %s, got %d %s", want, w.Code, w.Body)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+	"github.com/sapliy/fintech-ecosystem/pkg/monitoring"
+	ledgerpb "github.com/sapliy/fintech-ecosystem/proto/ledger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// transcoder serves a gRPC service's google.api.http bindings, all under
+// root, as REST
+type transcoder struct {
+	root     string
+	register func(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
+}
+
+// transcoders are the gRPC upstreams routes can transcode to, by name
+var transcoders = map[string]transcoder{
+	"ledger": {root: "/v1/ledger", register: ledgerpb.RegisterLedgerServiceHandler},
+}
+
+// transcodedHeaders are passed to the gRPC service as metadata, lowercased
+var transcodedHeaders = append([]string{requestIDHeader}, identityHeaders...)
+
+// transcoderMux returns the REST handler of the named gRPC upstream,
+// connecting to it the first time. Connections are kept per address, so
+// they survive config reloads.
+func (h *GatewayHandler) transcoderMux(cfg *GatewayConfig, name string) (http.Handler, error) {
+	addr := cfg.GRPCUpstreams[name]
+	key := name + "|" + addr
+	if mux, ok := h.transcoders.Load(key); ok {
+		return mux.(http.Handler), nil
+	}
+
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(monitoring.UnaryClientInterceptor("gateway"), zoneInterceptor),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s gRPC at %s: %w", name, addr, err)
+	}
+
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(func(header string) (string, bool) {
+			for _, name := range transcodedHeaders {
+				if textproto.CanonicalMIMEHeaderKey(header) == textproto.CanonicalMIMEHeaderKey(name) {
+					return strings.ToLower(name), true
+				}
+			}
+			return runtime.DefaultHeaderMatcher(header)
+		}),
+	)
+	if err := transcoders[name].register(context.Background(), mux, conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	actual, loaded := h.transcoders.LoadOrStore(key, mux)
+	if loaded {
+		_ = conn.Close()
+	}
+	return actual.(http.Handler), nil
+}
+
+// transcodeRoute serves the request with the gRPC service of the route's
+// upstream. The route's prefix stands for the service's binding root, so
+// /v1/rpc/ledger/transactions calls the RPC bound to /v1/ledger/transactions.
+func (h *GatewayHandler) transcodeRoute(cfg *GatewayConfig, route *RouteConfig, p string, w http.ResponseWriter, r *http.Request) {
+	mux, err := h.transcoderMux(cfg, route.Upstream)
+	if err != nil {
+		h.logger.Error("Failed to set up transcoding", "upstream", route.Upstream, "error", err)
+		jsonutil.WriteJSON(w, http.StatusBadGateway, map[string]string{"error": "Bad Gateway"})
+		return
+	}
+	if entry := accessLog(r.Context()); entry != nil {
+		entry.upstream = route.Upstream
+		entry.instance = cfg.GRPCUpstreams[route.Upstream]
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), route.Timeout)
+	defer cancel()
+	r = r.WithContext(ctx)
+	r.URL.Path = transcoders[route.Upstream].root + strings.TrimPrefix(p, route.Prefix)
+	r.URL.RawPath = ""
+	mux.ServeHTTP(w, r)
+}
+
+// zoneInterceptor scopes transcoded calls to the caller's zone: the
+// zone_id and mode fields of the request, at any depth, are set from the
+// identity the gateway authenticated, whatever the caller sent
+func zoneInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if msg, ok := req.(proto.Message); ok {
+		setZone(msg.ProtoReflect(), first(md.Get("x-zone-id")), first(md.Get("x-zone-mode")))
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func setZone(msg protoreflect.Message, zoneID, mode string) {
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch {
+		case fd.Name() == "zone_id" && fd.Kind() == protoreflect.StringKind && !fd.IsList():
+			msg.Set(fd, protoreflect.ValueOfString(zoneID))
+		case fd.Name() == "mode" && fd.Kind() == protoreflect.StringKind && !fd.IsList():
+			msg.Set(fd, protoreflect.ValueOfString(mode))
+		case fd.Kind() == protoreflect.MessageKind && fd.IsList():
+			list := msg.Get(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				setZone(list.Get(j).Message(), zoneID, mode)
+			}
+		case fd.Kind() == protoreflect.MessageKind && !fd.IsMap() && msg.Has(fd):
+			setZone(msg.Mutable(fd).Message(), zoneID, mode)
+		}
+	}
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
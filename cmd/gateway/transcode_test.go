@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	ledgerpb "github.com/sapliy/fintech-ecosystem/proto/ledger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeLedger records the requests of the RPCs the tests transcode to
+type fakeLedger struct {
+	ledgerpb.UnimplementedLedgerServiceServer
+	mu   sync.Mutex
+	list *ledgerpb.ListTransactionsRequest
+	bulk *ledgerpb.BulkRecordRequest
+}
+
+func (l *fakeLedger) ListTransactions(ctx context.Context, req *ledgerpb.ListTransactionsRequest) (*ledgerpb.ListTransactionsResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.list = req
+	return &ledgerpb.ListTransactionsResponse{}, nil
+}
+
+func (l *fakeLedger) BulkRecordTransactions(ctx context.Context, req *ledgerpb.BulkRecordRequest) (*ledgerpb.BulkRecordResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bulk = req
+	return &ledgerpb.BulkRecordResponse{}, nil
+}
+
+func TestGatewayHandler_TranscodeZone(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ledger := &fakeLedger{}
+	srv := grpc.NewServer()
+	ledgerpb.RegisterLedgerServiceServer(srv, ledger)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	payments := newUpstream(t, http.StatusOK)
+	h, _ := testGateway(t, payments.URL, payments.URL, func(cfg *GatewayConfig) {
+		cfg.GRPCUpstreams["ledger"] = lis.Addr().String()
+	})
+	key := map[string]string{"X-API-Key": secretKey, "X-Zone-ID": "zone_other", "Content-Type": "application/json"}
+
+	w := serve(h, "GET", "/v1/rpc/ledger/transactions?zone_id=zone_other&limit=5", nil, key)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if ledger.list.ZoneId != "zone_1" || ledger.list.Limit != 5 {
+		t.Errorf("Expected the key's zone to replace the caller's, got %+v", ledger.list)
+	}
+
+	body := `{"transactions": [
+		{"reference_id": "ref_1", "zone_id": "zone_other", "mode": "test"},
+		{"reference_id": "ref_2"}
+	]}`
+	w = serve(h, "POST", "/v1/rpc/ledger/bulk-transactions", []byte(body), key)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if len(ledger.bulk.Transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(ledger.bulk.Transactions))
+	}
+	for _, tx := range ledger.bulk.Transactions {
+		if tx.ZoneId != "zone_1" || tx.Mode != "live" {
+			t.Errorf("Expected %s in the key's zone and mode, got %s %s", tx.ReferenceId, tx.ZoneId, tx.Mode)
+		}
+	}
+}
+
+func TestZoneInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		wantZone string
+		wantMode string
+	}{
+		{"Caller's zone", metadata.Pairs("x-zone-id", "zone_1", "x-zone-mode", "live"), "zone_1", "live"},
+		{"Caller without a zone", metadata.MD{}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &ledgerpb.RecordTransactionRequest{ZoneId: "zone_other", Mode: "test", AccountId: "acc_1", Amount: 100}
+			ctx := metadata.NewOutgoingContext(context.Background(), tt.md)
+
+			var sent *ledgerpb.RecordTransactionRequest
+			invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				sent = req.(*ledgerpb.RecordTransactionRequest)
+				return nil
+			}
+			if err := zoneInterceptor(ctx, "/ledger.LedgerService/RecordTransaction", req, nil, nil, invoker); err != nil {
+				t.Fatalf("zoneInterceptor failed: %v", err)
+			}
+			if sent.ZoneId != tt.wantZone || sent.Mode != tt.wantMode {
+				t.Errorf("Expected zone %q and mode %q, got %q and %q", tt.wantZone, tt.wantMode, sent.ZoneId, sent.Mode)
+			}
+			if sent.AccountId != "acc_1" || sent.Amount != 100 {
+				t.Errorf("Expected the rest of the request untouched, got %+v", sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/resilience"
+)
+
+// errUpstreamUnavailable is returned when every instance of an upstream has
+// its circuit breaker open, so the request fails fast with a 503
+var errUpstreamUnavailable = errors.New("upstream unavailable")
+
+// upstreamInstance is one instance of an upstream service, e.g. one of the
+// comma-separated URLs of PAYMENT_SERVICE_URL
+type upstreamInstance struct {
+	url     *url.URL
+	breaker *resilience.CircuitBreaker
+}
+
+// upstreamPool spreads requests over the instances of an upstream whose
+// circuit breakers are not open
+type upstreamPool struct {
+	name      string
+	instances []*upstreamInstance
+	next      atomic.Uint64
+}
+
+// upstreamURLs splits an upstream's comma-separated instance URLs
+func upstreamURLs(upstream string) []string {
+	var urls []string
+	for _, u := range strings.Split(upstream, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// pool returns the instances of the named upstream. Breakers are kept per
+// instance URL and breaker settings, so they survive config reloads.
+func (h *GatewayHandler) pool(cfg *GatewayConfig, name string) (*upstreamPool, error) {
+	key := fmt.Sprintf("%s|%s|%d|%s", name, cfg.Upstreams[name], cfg.CircuitBreaker.Failures, cfg.CircuitBreaker.OpenTimeout)
+	if p, ok := h.pools.Load(key); ok {
+		return p.(*upstreamPool), nil
+	}
+
+	p := &upstreamPool{name: name}
+	for _, raw := range upstreamURLs(cfg.Upstreams[name]) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		p.instances = append(p.instances, &upstreamInstance{
+			url: u,
+			breaker: resilience.NewCircuitBreaker(resilience.Settings{
+				Name:    name + " " + u.Host,
+				Timeout: cfg.CircuitBreaker.OpenTimeout,
+				ReadyToTrip: func(counts resilience.Counts) bool {
+					return counts.ConsecutiveFailures >= cfg.CircuitBreaker.Failures
+				},
+				OnStateChange: func(name string, from, to resilience.State) {
+					h.logger.Warn("Upstream circuit breaker changed state", "upstream", name, "from", from.String(), "to", to.String())
+				},
+			}),
+		})
+	}
+	if len(p.instances) == 0 {
+		return nil, fmt.Errorf("upstream %s has no instances", name)
+	}
+
+	actual, _ := h.pools.LoadOrStore(key, p)
+	return actual.(*upstreamPool), nil
+}
+
+// pick returns the next instance whose breaker is not open, preferring
+// ones not yet tried for the request, or nil if every breaker is open
+func (p *upstreamPool) pick(tried map[*upstreamInstance]bool) *upstreamInstance {
+	start := p.next.Add(1)
+	var fallback *upstreamInstance
+	for i := range p.instances {
+		inst := p.instances[(start+uint64(i))%uint64(len(p.instances))]
+		if inst.breaker.State() == resilience.StateOpen {
+			continue
+		}
+		if !tried[inst] {
+			return inst
+		}
+		if fallback == nil {
+			fallback = inst
+		}
+	}
+	return fallback
+}
+
+// upstreamTransport sends each try of a request to a healthy instance of
+// the upstream, recording the outcome in the instance's breaker. Idempotent
+// requests without a body are retried on another instance when a try fails.
+type upstreamTransport struct {
+	pool    *upstreamPool
+	base    http.RoundTripper
+	retries int
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if isRetryable(req) {
+		attempts += t.retries
+	}
+
+	tried := map[*upstreamInstance]bool{}
+	lastErr := errUpstreamUnavailable
+	for attempt := 1; attempt <= attempts; attempt++ {
+		inst := t.pool.pick(tried)
+		if inst == nil {
+			return nil, errUpstreamUnavailable
+		}
+		tried[inst] = true
+		if entry := accessLog(req.Context()); entry != nil {
+			entry.instance = inst.url.Host
+		}
+
+		done, err := inst.breaker.Allow()
+		if err != nil {
+			// Half-open and already trying a request
+			lastErr = errUpstreamUnavailable
+			continue
+		}
+
+		out := req.Clone(req.Context())
+		out.URL.Scheme = inst.url.Scheme
+		out.URL.Host = inst.url.Host
+		out.Host = inst.url.Host
+
+		resp, err := t.base.RoundTrip(out)
+		if err == nil && !isUpstreamFailure(resp.StatusCode) {
+			done(true)
+			return resp, nil
+		}
+		done(false)
+
+		if err == nil {
+			if attempt == attempts {
+				// Pass the upstream's own error response through
+				return resp, nil
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			lastErr = fmt.Errorf("%s returned %d", inst.url.Host, resp.StatusCode)
+			continue
+		}
+		lastErr = err
+		if req.Context().Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// isRetryable reports whether a failed try of the request can be sent
+// again: idempotent methods without a body
+func isRetryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0
+	default:
+		return false
+	}
+}
+
+// isUpstreamFailure reports whether the status means the instance, rather
+// than the request, failed
+func isUpstreamFailure(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
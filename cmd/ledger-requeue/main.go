@@ -0,0 +1,124 @@
+// Command ledger-requeue sends the events in the ledger DLQ back to the
+// ledger consumer once whatever made them fail is fixed. It requeues what is
+// in the DLQ when it starts, at most -limit events, and exits. With -dry-run
+// it lists them instead, leaving them in the DLQ.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/ledger/infrastructure"
+	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
+	"github.com/segmentio/kafka-go"
+)
+
+func main() {
+	limit := flag.Int64("limit", 0, "maximum number of events to requeue, 0 for all")
+	dryRun := flag.Bool("dry-run", false, "list the dead-lettered events without requeueing them")
+	flag.Parse()
+
+	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
+	if kafkaBrokers == "" {
+		kafkaBrokers = "localhost:9092"
+	}
+	brokers := strings.Split(kafkaBrokers, ",")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	depth, err := messaging.ConsumerLag(ctx, brokers, infrastructure.LedgerDLQTopic, infrastructure.LedgerRequeueGroup)
+	if err != nil {
+		log.Fatalf("Failed to get DLQ depth: %v", err)
+	}
+	if *limit > 0 && *limit < depth {
+		depth = *limit
+	}
+	if depth == 0 {
+		log.Println("DLQ is empty, nothing to requeue")
+		return
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: brokers,
+		Topic:   infrastructure.LedgerDLQTopic,
+		GroupID: infrastructure.LedgerRequeueGroup,
+	})
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Failed to close DLQ reader: %v", err)
+		}
+	}()
+	retry := messaging.NewKafkaProducer(brokers, infrastructure.LedgerRetryTopic)
+	defer func() {
+		if err := retry.Close(); err != nil {
+			log.Printf("Failed to close retry producer: %v", err)
+		}
+	}()
+
+	requeued, err := requeue(ctx, reader, retry, depth, *dryRun)
+	if err != nil {
+		log.Printf("Stopped after %d of %d events: %v", requeued, depth, err)
+		return
+	}
+	if *dryRun {
+		log.Printf("Listed %d dead-lettered events", requeued)
+		return
+	}
+	log.Printf("Requeued %d events to %s", requeued, infrastructure.LedgerRetryTopic)
+}
+
+// dlqReader is the part of *kafka.Reader requeue uses
+type dlqReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type publisher interface {
+	Publish(ctx context.Context, key string, value []byte) error
+}
+
+// requeue publishes the original messages of the next depth dead letters to
+// retry, committing each once it is requeued, and returns how many were.
+// Malformed dead letters are dropped. With dryRun it only lists them.
+func requeue(ctx context.Context, reader dlqReader, retry publisher, depth int64, dryRun bool) (int64, error) {
+	var requeued int64
+	for requeued < depth {
+		m, err := reader.FetchMessage(ctx)
+		if err != nil {
+			return requeued, err
+		}
+
+		var letter messaging.DeadLetter
+		if err := json.Unmarshal(m.Value, &letter); err != nil {
+			log.Printf("Malformed dead letter at offset %d, dropping it: %v", m.Offset, err)
+			letter = messaging.DeadLetter{}
+		} else {
+			log.Printf("Dead letter from %s/%d offset %d, failed %s after %d attempts: %s",
+				letter.Topic, letter.Partition, letter.Offset, letter.FailedAt.Format(time.RFC3339), letter.Attempts, letter.Error)
+		}
+		if dryRun {
+			requeued++
+			continue
+		}
+
+		if letter.Value != nil {
+			if err := retry.Publish(ctx, letter.Key, letter.Value); err != nil {
+				return requeued, err
+			}
+		}
+		if err := reader.CommitMessages(ctx, m); err != nil {
+			return requeued, fmt.Errorf("failed to commit offset %d: %w", m.Offset, err)
+		}
+		requeued++
+	}
+	return requeued, nil
+}
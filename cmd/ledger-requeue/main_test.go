@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeDLQ hands out its dead letters in order and records the offsets
+// committed
+type fakeDLQ struct {
+	messages  []kafka.Message
+	committed []int64
+}
+
+func (d *fakeDLQ) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(d.messages) == 0 {
+		return kafka.Message{}, io.EOF
+	}
+	m := d.messages[0]
+	d.messages = d.messages[1:]
+	return m, nil
+}
+
+func (d *fakeDLQ) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, m := range msgs {
+		d.committed = append(d.committed, m.Offset)
+	}
+	return nil
+}
+
+type requeued struct {
+	key   string
+	value string
+}
+
+type fakeRetry struct {
+	published []requeued
+	err       error
+}
+
+func (r *fakeRetry) Publish(ctx context.Context, key string, value []byte) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.published = append(r.published, requeued{key, string(value)})
+	return nil
+}
+
+// deadLetters returns the DLQ messages for the given messages of the
+// payments topic, as the ledger consumer dead-letters them
+func deadLetters(t *testing.T, values ...string) []kafka.Message {
+	t.Helper()
+	var messages []kafka.Message
+	for i, value := range values {
+		payload, err := json.Marshal(messaging.DeadLetter{
+			Topic: "payments", Offset: int64(100 + i), ConsumerGroup: "ledger-service",
+			Key: "pi_1", Value: []byte(value), Error: "ledger unavailable", Attempts: 5, FailedAt: time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, kafka.Message{Offset: int64(i), Value: payload})
+	}
+	return messages
+}
+
+func TestRequeue(t *testing.T) {
+	event := `{"id":"evt_pi_1","type":"payment.succeeded","data":{"payment_id":"pi_1","amount":1000}}`
+
+	t.Run("Round trip", func(t *testing.T) {
+		dlq := &fakeDLQ{messages: deadLetters(t, event, `{"id":"evt_pi_2"}`)}
+		retry := &fakeRetry{}
+
+		n, err := requeue(context.Background(), dlq, retry, 2, false)
+		if err != nil || n != 2 {
+			t.Fatalf("Expected 2 events requeued, got %d, %v", n, err)
+		}
+		if len(retry.published) != 2 || retry.published[0] != (requeued{"pi_1", event}) {
+			t.Errorf("Expected the original messages requeued, got %v", retry.published)
+		}
+		if len(dlq.committed) != 2 {
+			t.Errorf("Expected both dead letters committed, got %v", dlq.committed)
+		}
+	})
+
+	t.Run("Stops at the depth", func(t *testing.T) {
+		dlq := &fakeDLQ{messages: deadLetters(t, event, event, event)}
+		retry := &fakeRetry{}
+
+		if n, err := requeue(context.Background(), dlq, retry, 2, false); err != nil || n != 2 {
+			t.Fatalf("Expected 2 events requeued, got %d, %v", n, err)
+		}
+		if len(dlq.messages) != 1 || len(dlq.committed) != 2 {
+			t.Errorf("Expected the third dead letter left in the DLQ, got %d left and %v committed", len(dlq.messages), dlq.committed)
+		}
+	})
+
+	t.Run("Malformed dead letter is dropped", func(t *testing.T) {
+		dlq := &fakeDLQ{messages: append([]kafka.Message{{Offset: 7, Value: []byte("{not json")}}, deadLetters(t, event)...)}
+		retry := &fakeRetry{}
+
+		if n, err := requeue(context.Background(), dlq, retry, 2, false); err != nil || n != 2 {
+			t.Fatalf("Expected 2 dead letters handled, got %d, %v", n, err)
+		}
+		if len(retry.published) != 1 || len(dlq.committed) != 2 || dlq.committed[0] != 7 {
+			t.Errorf("Expected only the valid event requeued and both committed, got %v and %v", retry.published, dlq.committed)
+		}
+	})
+
+	t.Run("Failed publish is not committed", func(t *testing.T) {
+		dlq := &fakeDLQ{messages: deadLetters(t, event)}
+		retry := &fakeRetry{err: errors.New("broker unavailable")}
+
+		if n, err := requeue(context.Background(), dlq, retry, 1, false); err == nil || n != 0 {
+			t.Fatalf("Expected to stop at the failed publish, got %d, %v", n, err)
+		}
+		if len(dlq.committed) != 0 {
+			t.Errorf("Expected the dead letter left in the DLQ, got %v committed", dlq.committed)
+		}
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		dlq := &fakeDLQ{messages: deadLetters(t, event, event)}
+		retry := &fakeRetry{}
+
+		if n, err := requeue(context.Background(), dlq, retry, 2, true); err != nil || n != 2 {
+			t.Fatalf("Expected 2 events listed, got %d, %v", n, err)
+		}
+		if len(retry.published) != 0 || len(dlq.committed) != 0 {
+			t.Errorf("Expected nothing requeued or committed, got %v and %v", retry.published, dlq.committed)
+		}
+	})
+
+	t.Run("DLQ drained early", func(t *testing.T) {
+		dlq := &fakeDLQ{messages: deadLetters(t, event)}
+		if n, err := requeue(context.Background(), dlq, &fakeRetry{}, 3, false); !errors.Is(err, io.EOF) || n != 1 {
+			t.Errorf("Expected to stop after 1 event, got %d, %v", n, err)
+		}
+	})
+}
@@ -4,85 +4,131 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"sync"
 
 	"github.com/sapliy/fintech-ecosystem/internal/ledger/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/ledger/infrastructure"
 	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
 )
 
+// PaymentEvent is an event of the payments topic, as published by the
+// payments outbox or replayed by the flow service
 type PaymentEvent struct {
-	Type string `json:"type"`
-	Data struct {
-		ID       string `json:"id"`
-		Amount   int64  `json:"amount"`
-		Currency string `json:"currency"`
-		UserID   string `json:"user_id"`
-		ZoneID   string `json:"zone_id"`
-		Mode     string `json:"mode"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	ZoneID   string `json:"zone_id"`
+	Mode     string `json:"mode"`
+	ReplayOf string `json:"replay_of"` // Set on replays: the ID of the replayed event
+	Data     struct {
+		PaymentID string `json:"payment_id"`
+		RefundID  string `json:"refund_id"`
+		Amount    int64  `json:"amount"`
+		Currency  string `json:"currency"`
+		UserID    string `json:"user_id"`
 	} `json:"data"`
 }
 
-func StartKafkaConsumer(brokers []string, service *domain.LedgerService) {
-	consumer := messaging.NewKafkaConsumer(brokers, "payments", "ledger-group")
+// StartKafkaConsumer records payment events, and requeued events, until the
+// context is cancelled. Events failing every retry go to the DLQ.
+func StartKafkaConsumer(ctx context.Context, brokers []string, service *domain.LedgerService) {
+	dlq := messaging.NewKafkaProducer(brokers, infrastructure.LedgerDLQTopic)
+	defer func() {
+		if err := dlq.Close(); err != nil {
+			log.Printf("Failed to close DLQ producer: %v", err)
+		}
+	}()
 
-	log.Println("Ledger Kafka Consumer started on topic 'payments'")
+	handler := func(key string, value []byte) error {
+		return handlePaymentEvent(ctx, service, key, value)
+	}
+	onDeadLetter := func(messaging.DeadLetter) {
+		infrastructure.DeadLetteredMessages.Inc()
+	}
 
-	consumer.Consume(context.Background(), func(key string, value []byte) error {
-		var event PaymentEvent
-		if err := json.Unmarshal(value, &event); err != nil {
-			return err
-		}
+	var wg sync.WaitGroup
+	for _, topic := range []string{"payments", infrastructure.LedgerRetryTopic} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			consumer := messaging.NewKafkaConsumer(brokers, topic, "ledger-group")
+			defer func() {
+				if err := consumer.Close(); err != nil {
+					log.Printf("Failed to close Kafka consumer for %s: %v", topic, err)
+				}
+			}()
+
+			log.Printf("Ledger Kafka Consumer started on topic '%s'", topic)
+			consumer.ConsumeWithDLQ(ctx, messaging.DefaultRetryPolicy(), dlq, handler, onDeadLetter)
+		}()
+	}
+	wg.Wait()
+}
+
+// handlePaymentEvent records the event's transaction once per event ID.
+// Replays republish the event under a new ID, so they are recorded under
+// the ID of the event they replay.
+func handlePaymentEvent(ctx context.Context, service *domain.LedgerService, key string, value []byte) error {
+	var event PaymentEvent
+	if err := json.Unmarshal(value, &event); err != nil {
+		return messaging.Permanent(err)
+	}
+	eventID := event.ReplayOf
+	if eventID == "" {
+		eventID = event.ID
+	}
+	if eventID == "" {
+		eventID = key
+	}
 
-		log.Printf("Ledger: Received Kafka event type %s for ID %s", event.Type, event.Data.ID)
+	log.Printf("Ledger: Received Kafka event type %s for payment %s", event.Type, event.Data.PaymentID)
 
-		var txReq domain.TransactionRequest
+	var txReq domain.TransactionRequest
 
-		switch event.Type {
-		case "payment.succeeded":
-			txReq = domain.TransactionRequest{
-				ReferenceID: event.Data.ID,
-				Description: "Kafka Event: Payment Success",
-				Entries: []domain.EntryRequest{
-					{
-						AccountID: "user_" + event.Data.UserID,
-						Amount:    event.Data.Amount,
-						Direction: "credit",
-					},
-					{
-						AccountID: "system_balancing",
-						Amount:    -event.Data.Amount,
-						Direction: "debit",
-					},
+	switch event.Type {
+	case "payment.succeeded":
+		txReq = domain.TransactionRequest{
+			ReferenceID: event.Data.PaymentID,
+			Description: "Kafka Event: Payment Success",
+			Entries: []domain.EntryRequest{
+				{
+					AccountID: "user_" + event.Data.UserID,
+					Amount:    event.Data.Amount,
+					Direction: "credit",
 				},
-			}
-		case "payment.refunded":
-			// Reversing entries
-			txReq = domain.TransactionRequest{
-				ReferenceID: "refund_" + event.Data.ID,
-				Description: "Kafka Event: Payment Refunded",
-				Entries: []domain.EntryRequest{
-					{
-						AccountID: "user_" + event.Data.UserID,
-						Amount:    -event.Data.Amount, // Negative credit is a debit
-						Direction: "debit",            // Explicitly set direction
-					},
-					{
-						AccountID: "system_balancing",
-						Amount:    event.Data.Amount,
-						Direction: "credit",
-					},
+				{
+					AccountID: "system_balancing",
+					Amount:    -event.Data.Amount,
+					Direction: "debit",
 				},
-			}
-		default:
-			return nil // Ignore other events
+			},
 		}
-
-		ctx := context.Background()
-		if err := service.RecordTransaction(ctx, txReq, event.Data.ZoneID, event.Data.Mode); err != nil {
-			log.Printf("Failed to record transaction for event %s (ID: %s): %v", event.Type, event.Data.ID, err)
-			return err
+	case "refund.completed":
+		// Reversing entries, once per refund of the payment
+		txReq = domain.TransactionRequest{
+			ReferenceID: "refund_" + event.Data.RefundID,
+			Description: "Kafka Event: Payment Refunded",
+			Entries: []domain.EntryRequest{
+				{
+					AccountID: "user_" + event.Data.UserID,
+					Amount:    -event.Data.Amount, // Negative credit is a debit
+					Direction: "debit",            // Explicitly set direction
+				},
+				{
+					AccountID: "system_balancing",
+					Amount:    event.Data.Amount,
+					Direction: "credit",
+				},
+			},
 		}
+	default:
+		return nil // Ignore other events
+	}
+
+	if err := service.RecordEventTransaction(ctx, eventID, txReq, event.ZoneID, event.Mode); err != nil {
+		log.Printf("Failed to record transaction for event %s (payment %s): %v", event.Type, event.Data.PaymentID, err)
+		return err
+	}
 
-		log.Printf("Ledger: Successfully recorded transaction for event %s (ID: %s)", event.Type, event.Data.ID)
-		return nil
-	})
+	log.Printf("Ledger: Successfully recorded transaction for event %s (payment %s)", event.Type, event.Data.PaymentID)
+	return nil
 }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sapliy/fintech-ecosystem/internal/ledger/domain"
+	paymentDomain "github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
+)
+
+// paymentEvents returns the payment.succeeded and refund.completed events
+// the payments service queues for a captured and partly refunded payment
+func paymentEvents(t *testing.T) (succeeded, refunded []byte) {
+	t.Helper()
+	var payloads [][]byte
+	repo := &paymentDomain.MockRepository{
+		BeginTxFunc: func(ctx context.Context) (paymentDomain.TransactionContext, error) {
+			return &paymentDomain.MockTransactionContext{
+				UpdateStatusFunc: func(ctx context.Context, id, status string) error { return nil },
+				SetProviderTransactionFunc: func(ctx context.Context, id, methodType, transactionID string) error {
+					return nil
+				},
+				UpdateRefundStatusFunc: func(ctx context.Context, id, status string) error { return nil },
+				CreateOutboxEventFunc: func(ctx context.Context, eventType, aggregateID string, payload []byte) error {
+					payloads = append(payloads, payload)
+					return nil
+				},
+				CommitFunc:   func() error { return nil },
+				RollbackFunc: func() error { return nil },
+			}, nil
+		},
+	}
+	service := paymentDomain.NewPaymentService(repo)
+
+	intent := &paymentDomain.PaymentIntent{ID: "pi_1", ZoneID: "zone_1", Mode: "live", Amount: 1000, Currency: "USD", UserID: "user_1"}
+	if err := service.MarkSucceeded(context.Background(), intent, paymentDomain.PaymentMethodCard, "txn_1"); err != nil {
+		t.Fatalf("MarkSucceeded failed: %v", err)
+	}
+	refund := &paymentDomain.Refund{ID: "re_1", PaymentIntentID: "pi_1", Amount: 300, Currency: "USD", Status: "pending"}
+	if err := service.CompleteRefund(context.Background(), intent, refund); err != nil {
+		t.Fatalf("CompleteRefund failed: %v", err)
+	}
+	if len(payloads) != 2 {
+		t.Fatalf("Expected two queued events, got %d", len(payloads))
+	}
+	return payloads[0], payloads[1]
+}
+
+// replay republishes an event the way the flow service replays it: the
+// event under a new ID, pointing at the replayed one
+func replay(t *testing.T, value []byte, replayedID string) []byte {
+	t.Helper()
+	var event map[string]interface{}
+	if err := json.Unmarshal(value, &event); err != nil {
+		t.Fatal(err)
+	}
+	replayed, _ := json.Marshal(map[string]interface{}{
+		"id":        replayedID,
+		"type":      event["type"],
+		"zone_id":   event["zone_id"],
+		"replay_of": event["id"],
+		"data":      event["data"],
+	})
+	return replayed
+}
+
+func TestHandlePaymentEvent(t *testing.T) {
+	succeeded, refunded := paymentEvents(t)
+
+	processed := map[string]string{} // Event ID to transaction ID
+	var transactions []*domain.Transaction
+	entries := map[string][]*domain.Entry{}
+	txCtx := &domain.MockTransactionContext{
+		GetProcessedEventFunc: func(ctx context.Context, eventID string) (string, error) {
+			return processed[eventID], nil
+		},
+		// Only the processed events tell events apart
+		CheckIdempotencyFunc: func(ctx context.Context, referenceID string) (string, error) { return "", nil },
+		CreateTransactionFunc: func(ctx context.Context, tx *domain.Transaction) (string, error) {
+			transactions = append(transactions, tx)
+			return tx.ReferenceID, nil
+		},
+		CreateEntryFunc: func(ctx context.Context, entry *domain.Entry) error {
+			entries[entry.TransactionID] = append(entries[entry.TransactionID], entry)
+			return nil
+		},
+		CreateOutboxEventFunc: func(ctx context.Context, eventType string, payload []byte) error { return nil },
+		CreateProcessedEventFunc: func(ctx context.Context, eventID, transactionID string) (bool, error) {
+			processed[eventID] = transactionID
+			return true, nil
+		},
+		CommitFunc:   func() error { return nil },
+		RollbackFunc: func() error { return nil },
+	}
+	service := domain.NewLedgerService(&domain.MockRepository{
+		GetAccountFunc: func(ctx context.Context, id string) (*domain.Account, error) {
+			return &domain.Account{ID: id, Currency: "USD"}, nil
+		},
+		BeginTxFunc: func(ctx context.Context) (domain.TransactionContext, error) { return txCtx, nil },
+	}, nil)
+
+	deliveries := []struct {
+		name  string
+		key   string
+		value []byte
+	}{
+		{"Payment", "pi_1", succeeded},
+		{"Redelivered payment", "pi_1", succeeded},
+		{"Replayed payment", "replay_1", replay(t, succeeded, "replay_1")},
+		{"Refund", "pi_1", refunded},
+		{"Replayed refund", "replay_2", replay(t, refunded, "replay_2")},
+	}
+	for _, d := range deliveries {
+		if err := handlePaymentEvent(context.Background(), service, d.key, d.value); err != nil {
+			t.Fatalf("%s: unexpected error %v", d.name, err)
+		}
+	}
+
+	if len(transactions) != 2 {
+		t.Fatalf("Expected the payment and the refund recorded once each, got %d transactions", len(transactions))
+	}
+	payment, refund := transactions[0], transactions[1]
+	if payment.ReferenceID != "pi_1" || payment.ZoneID != "zone_1" || payment.Mode != "live" {
+		t.Errorf("Expected the payment recorded for pi_1 in zone_1 live, got %+v", payment)
+	}
+	if refund.ReferenceID != "refund_re_1" {
+		t.Errorf("Expected the refund recorded for re_1, got %s", refund.ReferenceID)
+	}
+	if processed["evt_pi_1"] != "pi_1" || processed["evt_refund_completed_re_1"] != "refund_re_1" {
+		t.Errorf("Expected each event processed under its payments ID, got %v", processed)
+	}
+
+	credits := map[string]int64{}
+	for _, e := range append(entries["pi_1"], entries["refund_re_1"]...) {
+		credits[e.AccountID] += e.Amount
+	}
+	if credits["user_user_1"] != 700 || credits["system_balancing"] != -700 {
+		t.Errorf("Expected user_1 credited with 700 net, got %v", credits)
+	}
+}
+
+func TestHandlePaymentEvent_InvalidPayload(t *testing.T) {
+	service := domain.NewLedgerService(&domain.MockRepository{}, nil)
+	err := handlePaymentEvent(context.Background(), service, "pi_1", []byte(`{not json`))
+	if !messaging.IsPermanent(err) {
+		t.Fatalf("Expected a permanent error for an invalid payload, got %v", err)
+	}
+}
@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"maps"
+	"time"
 
 	"github.com/sapliy/fintech-ecosystem/internal/ledger/domain"
 	pb "github.com/sapliy/fintech-ecosystem/proto/ledger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -18,23 +24,47 @@ func NewLedgerGRPCServer(service *domain.LedgerService) *LedgerGRPCServer {
 	return &LedgerGRPCServer{service: service}
 }
 
+// RecordTransaction records the request's entries as one transaction, or
+// without entries credits account_id against the system balancing account
 func (s *LedgerGRPCServer) RecordTransaction(ctx context.Context, req *pb.RecordTransactionRequest) (*pb.RecordTransactionResponse, error) {
-	entry := domain.EntryRequest{
-		AccountID: req.AccountId,
-		Amount:    req.Amount,
-		Direction: "credit",
-	}
+	var entries []domain.EntryRequest
+	if len(req.Entries) > 0 {
+		for _, e := range req.Entries {
+			direction := e.Direction
+			if direction == "" {
+				direction = "credit"
+				if e.Amount < 0 {
+					direction = "debit"
+				}
+			}
+			entries = append(entries, domain.EntryRequest{
+				AccountID: e.AccountId,
+				Amount:    e.Amount,
+				Currency:  e.Currency,
+				Direction: direction,
+			})
+		}
+	} else {
+		entry := domain.EntryRequest{
+			AccountID: req.AccountId,
+			Amount:    req.Amount,
+			Currency:  req.Currency,
+			Direction: "credit",
+		}
 
-	balancingEntry := domain.EntryRequest{
-		AccountID: "system_balancing",
-		Amount:    -req.Amount,
-		Direction: "debit",
+		balancingEntry := domain.EntryRequest{
+			AccountID: "system_balancing",
+			Amount:    -req.Amount,
+			Currency:  req.Currency,
+			Direction: "debit",
+		}
+		entries = []domain.EntryRequest{entry, balancingEntry}
 	}
 
 	txReq := domain.TransactionRequest{
 		ReferenceID: req.ReferenceId,
 		Description: req.Description,
-		Entries:     []domain.EntryRequest{entry, balancingEntry},
+		Entries:     entries,
 	}
 
 	err := s.service.RecordTransaction(ctx, txReq, req.ZoneId, req.Mode)
@@ -61,6 +91,9 @@ func (s *LedgerGRPCServer) CreateAccount(ctx context.Context, req *pb.CreateAcco
 	}, nil
 }
 
+// BulkRecordTransactions records each request on its own, against the
+// system balancing account; one failing does not undo the others. Entries
+// that must post together go in one RecordTransaction instead.
 func (s *LedgerGRPCServer) BulkRecordTransactions(ctx context.Context, req *pb.BulkRecordRequest) (*pb.BulkRecordResponse, error) {
 	var txRequests []domain.TransactionRequest
 	for _, tr := range req.Transactions {
@@ -68,8 +101,8 @@ func (s *LedgerGRPCServer) BulkRecordTransactions(ctx context.Context, req *pb.B
 			ReferenceID: tr.ReferenceId,
 			Description: tr.Description,
 			Entries: []domain.EntryRequest{
-				{AccountID: tr.AccountId, Amount: tr.Amount, Direction: "credit"},
-				{AccountID: "system_balancing", Amount: -tr.Amount, Direction: "debit"},
+				{AccountID: tr.AccountId, Amount: tr.Amount, Currency: tr.Currency, Direction: "credit"},
+				{AccountID: "system_balancing", Amount: -tr.Amount, Currency: tr.Currency, Direction: "debit"},
 			},
 		})
 	}
@@ -117,3 +150,134 @@ func (s *LedgerGRPCServer) GetAccount(ctx context.Context, req *pb.GetAccountReq
 		CreatedAt: timestamppb.New(acc.CreatedAt),
 	}, nil
 }
+
+func (s *LedgerGRPCServer) ListTransactions(ctx context.Context, req *pb.ListTransactionsRequest) (*pb.ListTransactionsResponse, error) {
+	txs, err := s.service.ListTransactions(ctx, req.ZoneId, int(req.Limit))
+	if err != nil {
+		log.Printf("GRPC ListTransactions error: %v", err)
+		return nil, err
+	}
+
+	transactions := make([]*pb.Transaction, len(txs))
+	for i := range txs {
+		transactions[i] = toPBTransaction(&txs[i])
+	}
+	return &pb.ListTransactionsResponse{
+		Transactions: transactions,
+	}, nil
+}
+
+func (s *LedgerGRPCServer) GetTransaction(ctx context.Context, req *pb.GetTransactionRequest) (*pb.GetTransactionResponse, error) {
+	tx, err := s.service.GetTransaction(ctx, req.TransactionId)
+	if err != nil {
+		log.Printf("GRPC GetTransaction error: %v", err)
+		return nil, err
+	}
+	if tx == nil {
+		return nil, status.Error(codes.NotFound, "transaction not found")
+	}
+
+	return &pb.GetTransactionResponse{
+		Transaction: toPBTransaction(tx),
+	}, nil
+}
+
+func (s *LedgerGRPCServer) GetAccountEntries(ctx context.Context, req *pb.GetAccountEntriesRequest) (*pb.GetAccountEntriesResponse, error) {
+	q := domain.StatementQuery{
+		Currency: req.Currency,
+		Cursor:   req.Cursor,
+		Limit:    int(req.Limit),
+	}
+	if req.From != nil {
+		q.From = req.From.AsTime()
+	}
+	if req.To != nil {
+		q.To = req.To.AsTime()
+	}
+
+	stmt, err := s.service.GetStatement(ctx, req.AccountId, q)
+	if err != nil {
+		return nil, grpcStatementError(err)
+	}
+
+	entries := make([]*pb.StatementEntry, len(stmt.Entries))
+	for i, e := range stmt.Entries {
+		entries[i] = &pb.StatementEntry{
+			Entry:       toPBEntry(&e.Entry),
+			ReferenceId: e.ReferenceID,
+			Description: e.Description,
+			Balance:     e.Balance,
+		}
+	}
+	return &pb.GetAccountEntriesResponse{
+		AccountId:      stmt.AccountID,
+		Currency:       stmt.Currency,
+		OpeningBalance: stmt.OpeningBalance,
+		ClosingBalance: stmt.ClosingBalance,
+		Entries:        entries,
+		NextCursor:     stmt.NextCursor,
+	}, nil
+}
+
+// WatchBalance streams the account's balances until the client goes away
+func (s *LedgerGRPCServer) WatchBalance(req *pb.WatchBalanceRequest, stream grpc.ServerStreamingServer[pb.BalanceUpdate]) error {
+	err := s.service.WatchBalance(stream.Context(), req.AccountId, domain.DefaultWatchInterval, func(acc *domain.Account) error {
+		return stream.Send(&pb.BalanceUpdate{
+			AccountId:        acc.ID,
+			Currency:         acc.Currency,
+			Balance:          acc.Balance,
+			AvailableBalance: acc.AvailableBalance,
+			Balances:         maps.Clone(acc.Balances),
+			UpdatedAt:        timestamppb.New(time.Now()),
+		})
+	})
+	switch {
+	case errors.Is(err, domain.ErrAccountNotFound):
+		return status.Error(codes.NotFound, "account not found")
+	case errors.Is(err, context.Canceled):
+		return nil
+	case err != nil:
+		log.Printf("GRPC WatchBalance error: %v", err)
+	}
+	return err
+}
+
+func grpcStatementError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrAccountNotFound):
+		return status.Error(codes.NotFound, "account not found")
+	case errors.Is(err, domain.ErrInvalidStatementRange), errors.Is(err, domain.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		log.Printf("GRPC GetAccountEntries error: %v", err)
+		return err
+	}
+}
+
+func toPBTransaction(tx *domain.TransactionWithEntries) *pb.Transaction {
+	entries := make([]*pb.Entry, len(tx.Entries))
+	for i := range tx.Entries {
+		entries[i] = toPBEntry(&tx.Entries[i])
+	}
+	return &pb.Transaction{
+		Id:          tx.ID,
+		ReferenceId: tx.ReferenceID,
+		Description: tx.Description,
+		ZoneId:      tx.ZoneID,
+		Mode:        tx.Mode,
+		CreatedAt:   timestamppb.New(tx.CreatedAt),
+		Entries:     entries,
+	}
+}
+
+func toPBEntry(e *domain.Entry) *pb.Entry {
+	return &pb.Entry{
+		Id:            e.ID,
+		TransactionId: e.TransactionID,
+		AccountId:     e.AccountID,
+		Amount:        e.Amount,
+		Currency:      e.Currency,
+		Direction:     string(e.Direction),
+		CreatedAt:     timestamppb.New(e.CreatedAt),
+	}
+}
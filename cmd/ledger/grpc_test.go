@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sapliy/fintech-ecosystem/internal/ledger/domain"
+	pb "github.com/sapliy/fintech-ecosystem/proto/ledger"
+)
+
+func TestLedgerGRPCServer_RecordTransactionEntries(t *testing.T) {
+	var transactions []*domain.Transaction
+	var created []*domain.Entry
+	txCtx := &domain.MockTransactionContext{
+		CheckIdempotencyFunc: func(ctx context.Context, referenceID string) (string, error) { return "", nil },
+		CreateTransactionFunc: func(ctx context.Context, tx *domain.Transaction) (string, error) {
+			transactions = append(transactions, tx)
+			return "tx_1", nil
+		},
+		CreateEntryFunc: func(ctx context.Context, entry *domain.Entry) error {
+			created = append(created, entry)
+			return nil
+		},
+		CreateOutboxEventFunc: func(ctx context.Context, eventType string, payload []byte) error { return nil },
+		CommitFunc:            func() error { return nil },
+		RollbackFunc:          func() error { return nil },
+	}
+	mRepo := &domain.MockRepository{
+		GetAccountFunc: func(ctx context.Context, id string) (*domain.Account, error) {
+			return &domain.Account{ID: id, Currency: "USD"}, nil
+		},
+		BeginTxFunc: func(ctx context.Context) (domain.TransactionContext, error) { return txCtx, nil },
+	}
+	server := NewLedgerGRPCServer(domain.NewLedgerService(mRepo, nil))
+
+	_, err := server.RecordTransaction(context.Background(), &pb.RecordTransactionRequest{
+		ReferenceId: "ref_1",
+		ZoneId:      "zone_1",
+		Mode:        "test",
+		Entries: []*pb.EntryRequest{
+			{AccountId: "merchant", Amount: -250},
+			{AccountId: "fees", Amount: 250},
+		},
+	})
+	if err != nil {
+		t.Fatalf("RecordTransaction failed: %v", err)
+	}
+	if len(transactions) != 1 || transactions[0].ReferenceID != "ref_1" {
+		t.Fatalf("Expected one transaction, got %v", transactions)
+	}
+	if len(created) != 2 || created[0].AccountID != "merchant" || created[0].Direction != "debit" ||
+		created[1].AccountID != "fees" || created[1].Direction != "credit" {
+		t.Errorf("Expected the entries as given, directed by sign, got %+v %+v", created[0], created[1])
+	}
+
+	// Unbalanced entries are rejected as a whole
+	_, err = server.RecordTransaction(context.Background(), &pb.RecordTransactionRequest{
+		ReferenceId: "ref_2",
+		Entries: []*pb.EntryRequest{
+			{AccountId: "merchant", Amount: -250},
+			{AccountId: "fees", Amount: 200},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "not balanced") {
+		t.Errorf("Expected an unbalanced transaction to be rejected, got %v", err)
+	}
+	if len(transactions) != 1 {
+		t.Errorf("Expected nothing recorded for the unbalanced transaction, got %d transactions", len(transactions))
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
@@ -15,12 +17,7 @@ type LedgerHandler struct {
 }
 
 func (h *LedgerHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Name     string             `json:"name"`
-		Type     domain.AccountType `json:"type"`
-		Currency string             `json:"currency"`
-		UserID   *string            `json:"user_id"`
-	}
+	var req domain.AccountRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		jsonutil.WriteErrorJSON(w, "Invalid request body")
 		return
@@ -35,15 +32,44 @@ func (h *LedgerHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 		req.Currency = "USD" // Default
 	}
 
-	acc, err := h.service.CreateAccount(r.Context(), req.Name, req.Type, req.Currency, req.UserID, r.Header.Get("X-Zone-ID"), r.Header.Get("X-Zone-Mode"))
+	acc, err := h.service.OpenAccount(r.Context(), req, r.Header.Get("X-Zone-ID"), r.Header.Get("X-Zone-Mode"))
 	if err != nil {
-		jsonutil.WriteErrorJSON(w, "Failed to create account")
+		switch {
+		case errors.Is(err, domain.ErrAccountCodeInUse), errors.Is(err, domain.ErrParentAccountPosted):
+			jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrInvalidAccountCode), errors.Is(err, domain.ErrParentAccountNotFound), errors.Is(err, domain.ErrParentAccountMismatch):
+			jsonutil.WriteErrorJSON(w, err.Error())
+		default:
+			jsonutil.WriteErrorJSON(w, "Failed to create account")
+		}
 		return
 	}
 
 	jsonutil.WriteJSON(w, http.StatusCreated, acc)
 }
 
+// GetAccountTree handles GET /accounts/tree, the zone's chart of accounts
+// with rolled up balances. ?root= limits it to the tree below an account.
+func (h *LedgerHandler) GetAccountTree(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonutil.WriteErrorJSON(w, "Method not allowed")
+		return
+	}
+
+	tree, err := h.service.GetAccountTree(r.Context(), r.Header.Get("X-Zone-ID"), r.Header.Get("X-Zone-Mode"), r.URL.Query().Get("root"))
+	if err != nil {
+		if errors.Is(err, domain.ErrAccountNotFound) {
+			jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to get account tree: %v", err)
+		jsonutil.WriteErrorJSON(w, "Error retrieving account tree")
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"accounts": tree})
+}
+
 func (h *LedgerHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(r.URL.Path, "/")
 	if len(parts) < 3 {
@@ -81,6 +107,8 @@ func (h *LedgerHandler) RecordTransaction(w http.ResponseWriter, r *http.Request
 	if err := h.service.RecordTransaction(r.Context(), req, r.Header.Get("X-Zone-ID"), r.Header.Get("X-Zone-Mode")); err != nil {
 		if strings.Contains(err.Error(), "transaction is not balanced") {
 			jsonutil.WriteErrorJSON(w, err.Error()) // 400 Bad Request
+		} else if errors.Is(err, domain.ErrPeriodClosed) {
+			jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
 		} else {
 			jsonutil.WriteErrorJSON(w, "Failed to record transaction: "+err.Error())
 		}
@@ -89,6 +117,45 @@ func (h *LedgerHandler) RecordTransaction(w http.ResponseWriter, r *http.Request
 
 	jsonutil.WriteJSON(w, http.StatusCreated, map[string]string{"status": "recorded"})
 }
+
+// ReverseTransaction handles POST /transactions/{id}/reverse. The actor is
+// the caller the gateway authenticated when there is one.
+func (h *LedgerHandler) ReverseTransaction(w http.ResponseWriter, r *http.Request) {
+	// parts: ["", "transactions", "{id}", "reverse"]
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(parts) != 4 || parts[2] == "" {
+		jsonutil.WriteErrorJSON(w, "Invalid URL")
+		return
+	}
+
+	var req domain.ReversalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if userID := r.Header.Get("X-User-ID"); userID != "" {
+		req.Actor = userID
+	}
+
+	reversal, err := h.service.ReverseTransaction(r.Context(), parts[2], req)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTransactionNotFound):
+			jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrAlreadyReversed), errors.Is(err, domain.ErrReverseReversal), errors.Is(err, domain.ErrReferenceInUse):
+			jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, domain.ErrReversalReason), errors.Is(err, domain.ErrReversalActor):
+			jsonutil.WriteErrorJSON(w, err.Error())
+		default:
+			log.Printf("Failed to reverse transaction %s: %v", parts[2], err)
+			jsonutil.WriteErrorJSON(w, "Failed to reverse transaction: "+err.Error())
+		}
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusCreated, reversal)
+}
+
 func (h *LedgerHandler) BulkRecordTransactions(w http.ResponseWriter, r *http.Request) {
 	var reqs []domain.TransactionRequest
 	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
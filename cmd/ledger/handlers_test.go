@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sapliy/fintech-ecosystem/internal/ledger/domain"
 )
@@ -113,3 +114,72 @@ func TestLedgerHandler_RecordTransaction(t *testing.T) {
 		})
 	}
 }
+
+func TestLedgerHandler_GetAccountEntries(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	newHandler := func(filters *[]domain.EntryFilter) *LedgerHandler {
+		mRepo := &domain.MockRepository{
+			GetAccountFunc: func(ctx context.Context, id string) (*domain.Account, error) {
+				if id != "acc_1" {
+					return nil, nil
+				}
+				return &domain.Account{ID: id, Currency: "USD"}, nil
+			},
+			ListAccountEntriesFunc: func(ctx context.Context, filter domain.EntryFilter) ([]domain.StatementEntry, error) {
+				*filters = append(*filters, filter)
+				return []domain.StatementEntry{
+					{Entry: domain.Entry{ID: "e1", TransactionID: "tx_1", AccountID: "acc_1", Amount: 500, Direction: domain.Credit, CreatedAt: t0}, ReferenceID: "pi_1", Description: "Payment"},
+					{Entry: domain.Entry{ID: "e2", TransactionID: "tx_2", AccountID: "acc_1", Amount: -200, Direction: domain.Debit, CreatedAt: t0.Add(time.Hour)}, ReferenceID: "re_1", Description: "Refund, partial"},
+				}, nil
+			},
+			GetBalanceAtFunc: func(ctx context.Context, accountID, currency string, pos domain.EntryPosition) (int64, error) {
+				return 100, nil
+			},
+		}
+		return &LedgerHandler{service: domain.NewLedgerService(mRepo, nil)}
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"JSON", "/accounts/acc_1/entries?from=2026-03-01&to=2026-03-31", http.StatusOK, `"closing_balance":400`},
+		{"CSV", "/accounts/acc_1/entries?from=2026-03-01&format=csv", http.StatusOK,
+			"posted_at,entry_id,transaction_id,reference_id,description,direction,amount,balance\n" +
+				"2026-03-01T09:30:00Z,e1,tx_1,pi_1,Payment,credit,500,600\n" +
+				"2026-03-01T10:30:00Z,e2,tx_2,re_1,\"Refund, partial\",debit,-200,400\n"},
+		{"Unknown Account", "/accounts/acc_2/entries", http.StatusNotFound, "Account not found"},
+		{"Invalid Date", "/accounts/acc_1/entries?from=March", http.StatusBadRequest, "from must be"},
+		{"Range Ends Before Start", "/accounts/acc_1/entries?from=2026-03-31&to=2026-03-01", http.StatusBadRequest, "statement range"},
+		{"Invalid Format", "/accounts/acc_1/entries?format=xml", http.StatusBadRequest, "format must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filters []domain.EntryFilter
+			h := newHandler(&filters)
+
+			req := httptest.NewRequest("GET", tt.url, nil)
+			w := httptest.NewRecorder()
+
+			h.GetAccountEntries(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain '%s', got '%s'", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+
+	// A date as the end of the range includes that whole day
+	var filters []domain.EntryFilter
+	h := newHandler(&filters)
+	h.GetAccountEntries(httptest.NewRecorder(), httptest.NewRequest("GET", "/accounts/acc_1/entries?to=2026-03-31", nil))
+	if len(filters) != 1 || !filters[0].To.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the range to end at the start of 2026-04-01, got %+v", filters)
+	}
+}
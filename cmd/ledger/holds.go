@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/sapliy/fintech-ecosystem/internal/ledger/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// HandleAccountHolds serves GET and POST /accounts/{id}/holds, listing the
+// account's active holds and placing new ones
+func (h *LedgerHandler) HandleAccountHolds(w http.ResponseWriter, r *http.Request) {
+	// parts: ["", "accounts", "{id}", "holds"]
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(parts) != 4 || parts[2] == "" {
+		jsonutil.WriteErrorJSON(w, "Invalid URL")
+		return
+	}
+	accountID := parts[2]
+
+	switch r.Method {
+	case http.MethodGet:
+		holds, err := h.service.ListHolds(r.Context(), accountID)
+		if err != nil {
+			writeHoldError(w, err)
+			return
+		}
+		if holds == nil {
+			holds = []domain.Hold{}
+		}
+		jsonutil.WriteJSON(w, http.StatusOK, holds)
+	case http.MethodPost:
+		var req domain.HoldRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonutil.WriteErrorJSON(w, "Invalid request body")
+			return
+		}
+		hold, err := h.service.PlaceHold(r.Context(), accountID, req)
+		if err != nil {
+			writeHoldError(w, err)
+			return
+		}
+		jsonutil.WriteJSON(w, http.StatusCreated, hold)
+	default:
+		jsonutil.WriteErrorJSON(w, "Method not allowed")
+	}
+}
+
+// HandleHold serves GET /holds/{id} and the POST /holds/{id}/release and
+// /holds/{id}/capture actions
+func (h *LedgerHandler) HandleHold(w http.ResponseWriter, r *http.Request) {
+	// parts: ["", "holds", "{id}"] or ["", "holds", "{id}", "{action}"]
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[2] == "" {
+		jsonutil.WriteErrorJSON(w, "Invalid URL")
+		return
+	}
+	id := parts[2]
+	action := ""
+	if len(parts) == 4 {
+		action = parts[3]
+	}
+
+	var hold *domain.Hold
+	var err error
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		hold, err = h.service.GetHold(r.Context(), id)
+	case r.Method == http.MethodPost && action == "release":
+		hold, err = h.service.ReleaseHold(r.Context(), id)
+	case r.Method == http.MethodPost && action == "capture":
+		var req domain.CaptureRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonutil.WriteErrorJSON(w, "Invalid request body")
+			return
+		}
+		hold, err = h.service.CaptureHold(r.Context(), id, req, r.Header.Get("X-Zone-ID"), r.Header.Get("X-Zone-Mode"))
+	default:
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Not Found"})
+		return
+	}
+	if err != nil {
+		writeHoldError(w, err)
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, hold)
+}
+
+func writeHoldError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrHoldNotFound), errors.Is(err, domain.ErrAccountNotFound):
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrHoldNotActive), errors.Is(err, domain.ErrReferenceInUse):
+		jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidHoldAmount), errors.Is(err, domain.ErrInvalidHoldExpiry),
+		errors.Is(err, domain.ErrInvalidCapture), errors.Is(err, domain.ErrHoldReference), errors.Is(err, domain.ErrCounterAccount):
+		jsonutil.WriteErrorJSON(w, err.Error())
+	default:
+		// Transactions failing validation, e.g. an unknown counter account
+		log.Printf("Hold request failed: %v", err)
+		jsonutil.WriteErrorJSON(w, "Failed to process hold: "+err.Error())
+	}
+}
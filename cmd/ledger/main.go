@@ -96,13 +96,49 @@ func main() {
 		kafkaBrokers = "localhost:9092"
 	}
 	brokers := strings.Split(kafkaBrokers, ",")
-	go StartKafkaConsumer(brokers, service)
+	go StartKafkaConsumer(context.Background(), brokers, service)
+	go infrastructure.StartDLQMonitor(context.Background(), brokers, 30*time.Second)
 
 	// Start Outbox Publisher for Reliable Event Delivery
 	ledgerProducer := messaging.NewKafkaProducer(brokers, "ledger-events")
 	publisher := infrastructure.NewOutboxPublisher(repo, ledgerProducer, 2*time.Second)
 	go publisher.Start(context.Background())
 
+	// Snapshot balances every BALANCE_SNAPSHOT_INTERVAL, BALANCE_SNAPSHOT_LAG
+	// behind now to leave room for transactions still in flight
+	snapshotInterval := time.Hour
+	if v := os.Getenv("BALANCE_SNAPSHOT_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			snapshotInterval = d
+		} else {
+			logger.Warn("Invalid BALANCE_SNAPSHOT_INTERVAL, using default", "value", v)
+		}
+	}
+	snapshotLag := 5 * time.Minute
+	if v := os.Getenv("BALANCE_SNAPSHOT_LAG"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			snapshotLag = d
+		} else {
+			logger.Warn("Invalid BALANCE_SNAPSHOT_LAG, using default", "value", v)
+		}
+	}
+	// Consumed event IDs are kept for LEDGER_PROCESSED_EVENT_TTL to drop
+	// redeliveries and replays
+	processedEventTTL := domain.DefaultProcessedEventTTL
+	if v := os.Getenv("LEDGER_PROCESSED_EVENT_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			processedEventTTL = d
+		} else {
+			logger.Warn("Invalid LEDGER_PROCESSED_EVENT_TTL, using default", "value", v)
+		}
+	}
+	if db != nil {
+		snapshotter := infrastructure.NewBalanceSnapshotter(sqlRepo, snapshotInterval, snapshotLag)
+		go snapshotter.Start(context.Background())
+		go service.StartHoldExpiry(context.Background(), time.Minute)
+		go service.StartProcessedEventCleanup(context.Background(), time.Hour, processedEventTTL)
+	}
+
 	handler := &LedgerHandler{service: service}
 
 	mux := http.NewServeMux()
@@ -117,9 +153,19 @@ func main() {
 	mux.Handle("/metrics", promhttp.Handler())
 
 	mux.HandleFunc("/accounts", handler.CreateAccount)
+	mux.HandleFunc("/accounts/tree", handler.GetAccountTree)
 
-	// Simple routing for /accounts/{id}
+	// Simple routing for /accounts/{id}, /accounts/{id}/entries and
+	// /accounts/{id}/holds
 	mux.HandleFunc("/accounts/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/entries") {
+			handler.GetAccountEntries(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/holds") {
+			handler.HandleAccountHolds(w, r)
+			return
+		}
 		if r.Method == http.MethodGet {
 			handler.GetAccount(w, r)
 			return
@@ -135,8 +181,12 @@ func main() {
 		handler.RecordTransaction(w, r)
 	})
 
-	// Simple routing for /transactions/{id}
+	// Simple routing for /transactions/{id} and /transactions/{id}/reverse
 	mux.HandleFunc("/transactions/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/reverse") {
+			handler.ReverseTransaction(w, r)
+			return
+		}
 		if r.Method == http.MethodGet {
 			handler.GetTransaction(w, r)
 			return
@@ -146,6 +196,34 @@ func main() {
 
 	mux.HandleFunc("/bulk-transactions", handler.BulkRecordTransactions)
 
+	mux.HandleFunc("/holds/", handler.HandleHold)
+
+	mux.HandleFunc("/periods", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			handler.ListPeriods(w, r)
+			return
+		}
+		jsonutil.WriteErrorJSON(w, "Not Found")
+	})
+
+	mux.HandleFunc("/periods/", handler.HandlePeriod)
+
+	mux.HandleFunc("/reports/trial-balance", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			handler.GetTrialBalance(w, r)
+			return
+		}
+		jsonutil.WriteErrorJSON(w, "Not Found")
+	})
+
+	mux.HandleFunc("/reports/balance-check", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			handler.CheckBalance(w, r)
+			return
+		}
+		jsonutil.WriteErrorJSON(w, "Not Found")
+	})
+
 	port := ":8083"
 	logger.Info("Ledger service HTTP starting", "port", port)
 
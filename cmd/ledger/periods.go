@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/sapliy/fintech-ecosystem/internal/ledger/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// ListPeriods handles GET /periods, listing the closed accounting periods
+func (h *LedgerHandler) ListPeriods(w http.ResponseWriter, r *http.Request) {
+	periods, err := h.service.ListPeriods(r.Context())
+	if err != nil {
+		writePeriodError(w, err)
+		return
+	}
+	if periods == nil {
+		periods = []domain.Period{}
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, periods)
+}
+
+// HandlePeriod serves GET /periods/{YYYY-MM} and POST /periods/{YYYY-MM}/close.
+// The closing actor is the caller the gateway authenticated when there is
+// one.
+func (h *LedgerHandler) HandlePeriod(w http.ResponseWriter, r *http.Request) {
+	// parts: ["", "periods", "{period}"] or ["", "periods", "{period}", "close"]
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[2] == "" {
+		jsonutil.WriteErrorJSON(w, "Invalid URL")
+		return
+	}
+	period := parts[2]
+
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 3:
+		p, err := h.service.GetPeriod(r.Context(), period)
+		if err != nil {
+			writePeriodError(w, err)
+			return
+		}
+		jsonutil.WriteJSON(w, http.StatusOK, p)
+	case r.Method == http.MethodPost && len(parts) == 4 && parts[3] == "close":
+		var req domain.ClosePeriodRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonutil.WriteErrorJSON(w, "Invalid request body")
+			return
+		}
+		if userID := r.Header.Get("X-User-ID"); userID != "" {
+			req.ClosedBy = userID
+		}
+		p, err := h.service.ClosePeriod(r.Context(), period, req)
+		if err != nil {
+			writePeriodError(w, err)
+			return
+		}
+		log.Printf("Accounting period %s closed by %s", p.Period, p.ClosedBy)
+		jsonutil.WriteJSON(w, http.StatusCreated, p)
+	default:
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Not Found"})
+	}
+}
+
+func writePeriodError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrPeriodNotFound), errors.Is(err, domain.ErrAccountNotFound):
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrPeriodAlreadyClosed):
+		jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidPeriod), errors.Is(err, domain.ErrPeriodNotEnded), errors.Is(err, domain.ErrPeriodClosedBy):
+		jsonutil.WriteErrorJSON(w, err.Error())
+	default:
+		log.Printf("Period request failed: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to process period"})
+	}
+}
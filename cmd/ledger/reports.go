@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// GetTrialBalance serves GET /reports/trial-balance. as_of is when the books
+// are closed, an RFC 3339 time or a date closing them at the end of that
+// day; it defaults to now. zone limits the report to a zone's accounts.
+func (h *LedgerHandler) GetTrialBalance(w http.ResponseWriter, r *http.Request) {
+	asOf, err := parseTimeBound(r.URL.Query().Get("as_of"), true)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, "as_of must be an RFC 3339 time or a YYYY-MM-DD date")
+		return
+	}
+	if asOf.IsZero() {
+		asOf = time.Now().UTC()
+	}
+
+	report, err := h.service.GetTrialBalance(r.Context(), asOf, r.URL.Query().Get("zone"))
+	if err != nil {
+		log.Printf("Failed to get trial balance: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get trial balance"})
+		return
+	}
+	if !report.Balanced {
+		log.Printf("Ledger integrity alarm: trial balance as of %s does not balance", asOf.Format(time.RFC3339))
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, report)
+}
+
+// CheckBalance serves GET /reports/balance-check, listing up to limit of the
+// most recent transactions whose entries do not sum to zero. Any found are
+// logged and exported as the ledger_unbalanced_transactions gauge to alert on.
+func (h *LedgerHandler) CheckBalance(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			jsonutil.WriteErrorJSON(w, "limit must be a positive integer")
+			return
+		}
+	}
+
+	check, err := h.service.CheckBalance(r.Context(), limit)
+	if err != nil {
+		log.Printf("Failed to check ledger balance: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to check balance"})
+		return
+	}
+	if !check.Balanced {
+		log.Printf("Ledger integrity alarm: %d unbalanced transactions found", len(check.UnbalancedTransactions))
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, check)
+}
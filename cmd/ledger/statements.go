@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/ledger/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/currency"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+var statementCSVHeader = []string{"posted_at", "entry_id", "transaction_id", "reference_id", "description", "direction", "amount", "balance"}
+
+// GetAccountEntries serves GET /accounts/{id}/entries, the account's
+// statement in currency, by default the account's own. from and to take
+// RFC 3339 times or dates; a date as to includes that whole day. With
+// format=csv every page of the range is exported as one CSV file.
+func (h *LedgerHandler) GetAccountEntries(w http.ResponseWriter, r *http.Request) {
+	// parts: ["", "accounts", "{id}", "entries"]
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(parts) != 4 || parts[2] == "" {
+		jsonutil.WriteErrorJSON(w, "Invalid URL")
+		return
+	}
+	id := parts[2]
+
+	q := r.URL.Query()
+	var query domain.StatementQuery
+	var err error
+	if query.From, err = parseTimeBound(q.Get("from"), false); err != nil {
+		jsonutil.WriteErrorJSON(w, "from must be an RFC 3339 time or a YYYY-MM-DD date")
+		return
+	}
+	if query.To, err = parseTimeBound(q.Get("to"), true); err != nil {
+		jsonutil.WriteErrorJSON(w, "to must be an RFC 3339 time or a YYYY-MM-DD date")
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit < 1 {
+			jsonutil.WriteErrorJSON(w, "limit must be a positive integer")
+			return
+		}
+	}
+	if v := q.Get("currency"); v != "" {
+		if query.Currency, err = currency.Normalize(v); err != nil {
+			jsonutil.WriteErrorJSON(w, err.Error())
+			return
+		}
+	}
+	query.Cursor = q.Get("cursor")
+
+	switch q.Get("format") {
+	case "", "json":
+		statement, err := h.service.GetStatement(r.Context(), id, query)
+		if err != nil {
+			writeStatementError(w, err)
+			return
+		}
+		jsonutil.WriteJSON(w, http.StatusOK, statement)
+	case "csv":
+		h.exportStatement(w, r, id, query)
+	default:
+		jsonutil.WriteErrorJSON(w, "format must be json or csv")
+	}
+}
+
+// exportStatement writes the statement as CSV, following the cursor until
+// the range is exhausted. Errors after the first page can only be logged,
+// as the response is already under way.
+func (h *LedgerHandler) exportStatement(w http.ResponseWriter, r *http.Request, id string, query domain.StatementQuery) {
+	query.Limit = domain.MaxStatementLimit
+	statement, err := h.service.GetStatement(r.Context(), id, query)
+	if err != nil {
+		writeStatementError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="statement-`+id+`.csv"`)
+	out := csv.NewWriter(w)
+	_ = out.Write(statementCSVHeader)
+	for {
+		for _, e := range statement.Entries {
+			_ = out.Write([]string{
+				e.CreatedAt.UTC().Format(time.RFC3339),
+				e.ID,
+				e.TransactionID,
+				e.ReferenceID,
+				e.Description,
+				string(e.Direction),
+				strconv.FormatInt(e.Amount, 10),
+				strconv.FormatInt(e.Balance, 10),
+			})
+		}
+		if statement.NextCursor == "" {
+			break
+		}
+		query.Cursor = statement.NextCursor
+		if statement, err = h.service.GetStatement(r.Context(), id, query); err != nil {
+			log.Printf("Failed to export statement of account %s: %v", id, err)
+			break
+		}
+	}
+	out.Flush()
+}
+
+// parseTimeBound parses a bound of a reporting range. A date as the end of
+// a range stands for the end of that day.
+func parseTimeBound(v string, end bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func writeStatementError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrAccountNotFound):
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Account not found"})
+	case errors.Is(err, domain.ErrInvalidStatementRange), errors.Is(err, domain.ErrInvalidCursor):
+		jsonutil.WriteErrorJSON(w, err.Error())
+	default:
+		log.Printf("Failed to get statement: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get statement"})
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sapliy/fintech-ecosystem/internal/notification"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+	"github.com/sapliy/fintech-ecosystem/pkg/scopes"
+)
+
+type SetLocaleRequest struct {
+	Locale string `json:"locale"` // e.g. fr-CA
+}
+
+type SetPreferenceRequest struct {
+	Channel   notification.Channel   `json:"channel"`
+	EventType notification.EventType `json:"event_type"` // Empty for every event type
+	Enabled   bool                   `json:"enabled"`
+}
+
+// apiRoutes serves the metrics and health endpoints, sending and scheduling
+// notifications and, with a database, the users' notification preferences,
+// the template management API, partners' webhook endpoints, in-app inboxes
+// and delivery tracking
+func apiRoutes(repo *notification.Repository, templates *notification.TemplateService, endpoints *notification.EndpointRegistry, inbox *notification.Inbox, delivery *deliveryHandler, sends *sendHandler) http.Handler {
+	r := mux.NewRouter()
+	r.Handle("/metrics", promhttp.Handler())
+	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		jsonutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "active"})
+	})
+
+	r.HandleFunc("/v1/notifications", sends.Send).Methods(http.MethodPost)
+	if sends.scheduler != nil {
+		r.HandleFunc("/v1/notifications/scheduled/{id}", sends.GetScheduled).Methods(http.MethodGet)
+		r.HandleFunc("/v1/notifications/scheduled/{id}", sends.CancelScheduled).Methods(http.MethodDelete)
+	}
+	if repo != nil {
+		h := &preferenceHandler{store: repo}
+		r.HandleFunc("/v1/notifications/preferences", h.GetPreferences).Methods(http.MethodGet)
+		r.HandleFunc("/v1/notifications/preferences", h.SetPreference).Methods(http.MethodPut)
+		r.HandleFunc("/v1/notifications/preferences", h.DeletePreference).Methods(http.MethodDelete)
+		r.HandleFunc("/v1/notifications/preferences/locale", h.GetLocale).Methods(http.MethodGet)
+		r.HandleFunc("/v1/notifications/preferences/locale", h.SetLocale).Methods(http.MethodPut)
+	}
+	if templates != nil {
+		h := &templateHandler{templates: templates}
+		r.HandleFunc("/v1/notifications/templates", h.ListTemplates).Methods(http.MethodGet)
+		r.HandleFunc("/v1/notifications/templates", h.CreateTemplate).Methods(http.MethodPost)
+		r.HandleFunc("/v1/notifications/templates/{id}", h.GetTemplate).Methods(http.MethodGet)
+		r.HandleFunc("/v1/notifications/templates/{id}", h.UpdateTemplate).Methods(http.MethodPatch)
+		r.HandleFunc("/v1/notifications/templates/{id}", h.DeleteTemplate).Methods(http.MethodDelete)
+		r.HandleFunc("/v1/notifications/templates/{id}/publish", h.PublishTemplate).Methods(http.MethodPost)
+		r.HandleFunc("/v1/notifications/templates/{id}/preview", h.PreviewTemplate).Methods(http.MethodPost)
+	}
+	if inbox != nil {
+		h := &inboxHandler{inbox: inbox}
+		r.HandleFunc("/v1/notifications/inbox", h.List).Methods(http.MethodGet)
+		r.HandleFunc("/v1/notifications/inbox/unread", h.Unread).Methods(http.MethodGet)
+		r.HandleFunc("/v1/notifications/inbox/stream", h.Stream).Methods(http.MethodGet)
+		r.HandleFunc("/v1/notifications/inbox/read", h.MarkAllRead).Methods(http.MethodPost)
+		r.HandleFunc("/v1/notifications/inbox/{id}/read", h.MarkRead).Methods(http.MethodPost)
+		r.HandleFunc("/v1/notifications/inbox/{id}/archive", h.Archive).Methods(http.MethodPost)
+	}
+	if endpoints != nil {
+		h := &endpointHandler{endpoints: endpoints}
+		// API keys manage endpoints only with webhooks:admin
+		sr := r.PathPrefix("/v1/notifications/webhooks/endpoints").Subrouter()
+		sr.Use(scopes.Require(scopes.WebhooksAdmin))
+		sr.HandleFunc("", h.ListEndpoints).Methods(http.MethodGet)
+		sr.HandleFunc("", h.CreateEndpoint).Methods(http.MethodPost)
+		sr.HandleFunc("/{id}", h.GetEndpoint).Methods(http.MethodGet)
+		sr.HandleFunc("/{id}", h.UpdateEndpoint).Methods(http.MethodPatch)
+		sr.HandleFunc("/{id}", h.DeleteEndpoint).Methods(http.MethodDelete)
+	}
+	if delivery != nil {
+		// Providers call back only when their signatures can be checked
+		if delivery.resendSecret != "" {
+			r.HandleFunc("/v1/notifications/callbacks/resend", delivery.ResendCallback).Methods(http.MethodPost)
+		}
+		if delivery.twilioToken != "" {
+			r.HandleFunc("/v1/notifications/callbacks/twilio", delivery.TwilioCallback).Methods(http.MethodPost)
+		}
+		r.HandleFunc("/v1/notifications/{id}/events", delivery.Events).Methods(http.MethodGet)
+		r.HandleFunc("/v1/notifications/webhooks/{id}/attempts", delivery.WebhookAttempts).Methods(http.MethodGet)
+	}
+	return r
+}
+
+// preferenceHandler lets users manage their own notification preferences
+type preferenceHandler struct {
+	store notification.PreferenceStore
+}
+
+func (h *preferenceHandler) user(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+		return "", false
+	}
+	return userID, true
+}
+
+// GetPreferences lists the user's preferences and the security event types
+// they cannot opt out of
+func (h *preferenceHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	prefs, err := h.store.GetPreferences(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to get preferences of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get preferences"})
+		return
+	}
+	mandatory := []notification.EventType{}
+	for eventType := range notification.MandatoryEvents {
+		mandatory = append(mandatory, eventType)
+	}
+	slices.Sort(mandatory)
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"data":             prefs,
+		"mandatory_events": mandatory,
+	})
+}
+
+// SetPreference turns a channel on or off for an event type, or for every
+// event type when none is given
+func (h *preferenceHandler) SetPreference(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	var req SetPreferenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+
+	pref := &notification.Preference{
+		UserID:    userID,
+		Channel:   req.Channel,
+		EventType: req.EventType,
+		Enabled:   req.Enabled,
+	}
+	if err := pref.Validate(); err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	if err := h.store.SetPreference(r.Context(), pref); err != nil {
+		log.Printf("Failed to set preference of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to set preference"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, pref)
+}
+
+// DeletePreference turns a channel back to its default for the event type
+// of the query, or the preference for every event type without one
+func (h *preferenceHandler) DeletePreference(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	pref := &notification.Preference{
+		UserID:    userID,
+		Channel:   notification.Channel(q.Get("channel")),
+		EventType: notification.EventType(q.Get("event_type")),
+		Enabled:   true,
+	}
+	if err := pref.Validate(); err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+
+	err := h.store.DeletePreference(r.Context(), userID, pref.Channel, pref.EventType)
+	if errors.Is(err, notification.ErrPreferenceMissing) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Preference not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete preference of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to delete preference"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetLocale returns the locale the user is sent notifications in
+func (h *preferenceHandler) GetLocale(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	locale, err := h.store.GetLocale(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to get locale of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get locale"})
+		return
+	}
+	if locale == "" {
+		locale = notification.DefaultLocale
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]string{"locale": locale})
+}
+
+// SetLocale sets the locale the user is sent notifications in. Templates
+// missing in it fall back to its language, then to English.
+func (h *preferenceHandler) SetLocale(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	var req SetLocaleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	locale, err := notification.NormalizeLocale(req.Locale)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	if err := h.store.SetLocale(r.Context(), userID, locale); err != nil {
+		log.Printf("Failed to set locale of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to set locale"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]string{"locale": locale})
+}
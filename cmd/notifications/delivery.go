@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/notification"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// maxCallbackBody caps the size of provider callbacks
+const maxCallbackBody = 1 << 20
+
+// deliveryHandler receives the providers' delivery callbacks and serves
+// the delivery timeline of notifications
+type deliveryHandler struct {
+	tracker *notification.DeliveryTracker
+	// resendSecret is the signing secret of the Resend webhook endpoint
+	resendSecret string
+	// twilioToken and twilioCallbackURL verify Twilio's status callbacks,
+	// which are signed over the URL Twilio was given
+	twilioToken       string
+	twilioCallbackURL string
+	// webhooks keeps the attempts to deliver webhooks
+	webhooks *notification.Repository
+}
+
+// record applies a provider's status, acknowledging messages no
+// notification was sent as so the provider stops retrying them
+func (h *deliveryHandler) record(w http.ResponseWriter, r *http.Request, provider string, status *notification.ProviderStatus) {
+	n, err := h.tracker.Callback(r.Context(), provider, status.MessageID, status.Status, status.Detail, status.OccurredAt)
+	if errors.Is(err, notification.ErrUnknownMessage) {
+		log.Printf("Ignoring %s callback for unknown message %s", provider, status.MessageID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to record %s callback for %s: %v", provider, status.MessageID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to record callback"})
+		return
+	}
+	DeliveryCallbacks.WithLabelValues(provider, string(status.Status)).Inc()
+	log.Printf("Notification %s is %s (%s)", n.ID, n.Status, status.Detail)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendCallback receives Resend's email delivery events
+func (h *deliveryHandler) ResendCallback(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCallbackBody))
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if err := notification.VerifyResendSignature(h.resendSecret, r.Header.Get("svix-id"),
+		r.Header.Get("svix-timestamp"), r.Header.Get("svix-signature"), body, time.Now()); err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid signature"})
+		return
+	}
+
+	status, tracked, err := notification.ParseResendEvent(body)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid event")
+		return
+	}
+	if !tracked {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.record(w, r, notification.ProviderResend, status)
+}
+
+// TwilioCallback receives Twilio's SMS status callbacks
+func (h *deliveryHandler) TwilioCallback(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxCallbackBody)
+	if err := r.ParseForm(); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if err := notification.VerifyTwilioSignature(h.twilioToken, h.twilioCallbackURL, r.PostForm,
+		r.Header.Get("X-Twilio-Signature")); err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid signature"})
+		return
+	}
+
+	status, tracked := notification.ParseTwilioStatus(r.PostForm)
+	if !tracked {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.record(w, r, notification.ProviderTwilio, status)
+}
+
+// Events answers a notification's delivery timeline to its user, and to
+// owners and admins
+func (h *deliveryHandler) Events(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+		return
+	}
+
+	n, events, err := h.tracker.Timeline(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("Failed to get notification timeline: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get notification"})
+		return
+	}
+	role := r.Header.Get("X-Role")
+	if n == nil || (n.UserID != userID && role != "owner" && role != "admin") {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Notification not found"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"notification": n,
+		"data":         events,
+	})
+}
+
+// WebhookAttempts answers the attempts to deliver a webhook, with what its
+// endpoint answered, to owners and admins
+func (h *deliveryHandler) WebhookAttempts(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-User-ID") == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+		return
+	}
+	if role := r.Header.Get("X-Role"); role != "owner" && role != "admin" {
+		jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": "Only owners and admins can view webhook deliveries"})
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	attempts, err := h.webhooks.ListWebhookAttempts(r.Context(), id)
+	if err != nil {
+		log.Printf("Failed to list attempts of webhook %s: %v", id, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list webhook attempts"})
+		return
+	}
+	if len(attempts) == 0 {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Webhook not found"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": attempts})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/notification"
+)
+
+// smsDriver returns the SMS driver SMS_PROVIDER selects: twilio, or log to
+// only log messages
+func smsDriver() (notification.Driver, error) {
+	switch provider := getEnv("SMS_PROVIDER", "log"); provider {
+	case "twilio":
+		return notification.NewTwilioDriver(notification.TwilioConfig{
+			AccountSID:          os.Getenv("TWILIO_ACCOUNT_SID"),
+			AuthToken:           os.Getenv("TWILIO_AUTH_TOKEN"),
+			FromNumber:          os.Getenv("TWILIO_FROM_NUMBER"),
+			MessagingServiceSID: os.Getenv("TWILIO_MESSAGING_SERVICE_SID"),
+			StatusCallbackURL:   os.Getenv("TWILIO_STATUS_CALLBACK_URL"),
+		})
+	case "log":
+		log.Println("Warning: SMS_PROVIDER is log, SMS are not sent")
+		return notification.NewSMSDriver(), nil
+	default:
+		return nil, fmt.Errorf("unknown SMS provider: %s", provider)
+	}
+}
+
+// webDriver returns the web push driver PUSH_PROVIDER selects: fcm, with
+// the service account key at FCM_CREDENTIALS_FILE, or log to only log pushes
+func webDriver() (notification.Driver, error) {
+	switch provider := getEnv("PUSH_PROVIDER", "log"); provider {
+	case "fcm":
+		account, err := notification.LoadServiceAccount(os.Getenv("FCM_CREDENTIALS_FILE"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load FCM credentials: %w", err)
+		}
+		return notification.NewFCMDriver(account), nil
+	case "log":
+		log.Println("Warning: PUSH_PROVIDER is log, web pushes are not sent")
+		return notification.NewWebDriver(), nil
+	default:
+		return nil, fmt.Errorf("unknown push provider: %s", provider)
+	}
+}
+
+// suppressionConfig returns the default limits, overridden by
+// NOTIFICATION_RATE_LIMIT_<CHANNEL> (messages per recipient per hour, 0 for
+// none) and NOTIFICATION_DEDUPE_WINDOW
+func suppressionConfig() notification.SuppressionConfig {
+	config := notification.DefaultSuppressionConfig
+	config.Limits = maps.Clone(config.Limits)
+	for _, channel := range []notification.Channel{notification.Email, notification.SMS, notification.Web} {
+		env := "NOTIFICATION_RATE_LIMIT_" + strings.ToUpper(string(channel))
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			log.Fatalf("Invalid %s: %q", env, value)
+		}
+		if limit == 0 {
+			delete(config.Limits, channel)
+		} else {
+			config.Limits[channel] = limit
+		}
+	}
+	if value := os.Getenv("NOTIFICATION_DEDUPE_WINDOW"); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid NOTIFICATION_DEDUPE_WINDOW: %v", err)
+		}
+		config.DedupeWindow = window
+	}
+	return config
+}
+
+// webhookConfig reads the timeout of webhook deliveries from
+// NOTIFICATION_WEBHOOK_TIMEOUT and their retries from
+// NOTIFICATION_WEBHOOK_MAX_RETRIES
+func webhookConfig() notification.WebhookConfig {
+	config := notification.DefaultWebhookConfig
+	if value := os.Getenv("NOTIFICATION_WEBHOOK_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			log.Fatalf("Invalid NOTIFICATION_WEBHOOK_TIMEOUT: %q", value)
+		}
+		config.Timeout = timeout
+	}
+	if value := os.Getenv("NOTIFICATION_WEBHOOK_MAX_RETRIES"); value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			log.Fatalf("Invalid NOTIFICATION_WEBHOOK_MAX_RETRIES: %q", value)
+		}
+		config.MaxRetries = retries
+	}
+	return config
+}
+
+// workerResult counts a task's outcome. Undeliverable and suppressed tasks
+// are acked, as requeueing them cannot succeed.
+func workerResult(channel string, err error) error {
+	var suppressed *notification.SuppressedError
+	switch {
+	case err == nil:
+		NotificationsSent.WithLabelValues(channel, "success").Inc()
+		return nil
+	case errors.As(err, &suppressed):
+		NotificationsSuppressed.WithLabelValues(channel, suppressed.Reason).Inc()
+		return nil
+	case errors.Is(err, notification.ErrUndeliverable):
+		NotificationsSent.WithLabelValues(channel, "undeliverable").Inc()
+		return nil
+	default:
+		NotificationsSent.WithLabelValues(channel, "error").Inc()
+		return err
+	}
+}
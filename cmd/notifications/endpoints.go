@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/notification"
+	"github.com/sapliy/fintech-ecosystem/pkg/audit"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+type CreateEndpointRequest struct {
+	PartnerID  string                   `json:"partner_id"`
+	URL        string                   `json:"url"`
+	EventTypes []notification.EventType `json:"event_types"`
+}
+
+type UpdateEndpointRequest struct {
+	URL        *string                  `json:"url"`
+	EventTypes []notification.EventType `json:"event_types"`
+	Active     *bool                    `json:"active"`
+}
+
+// endpointHandler manages the partners' webhook endpoints. Partners are
+// onboarded by the platform, so only owners and admins manage them.
+type endpointHandler struct {
+	endpoints *notification.EndpointRegistry
+}
+
+func (h *endpointHandler) admin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+		return "", false
+	}
+	if role := r.Header.Get("X-Role"); role != "owner" && role != "admin" {
+		jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": "Only owners and admins can manage webhook endpoints"})
+		return "", false
+	}
+	return userID, true
+}
+
+func (h *endpointHandler) loadEndpoint(w http.ResponseWriter, r *http.Request) (*notification.WebhookEndpoint, bool) {
+	e, err := h.endpoints.GetEndpoint(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, notification.ErrEndpointNotFound) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Webhook endpoint not found"})
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Failed to get webhook endpoint: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get webhook endpoint"})
+		return nil, false
+	}
+	return e, true
+}
+
+// writeEndpointError answers the errors of changing an endpoint
+func writeEndpointError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, notification.ErrInvalidEndpoint), errors.Is(err, notification.ErrInvalidEndpointURL),
+		errors.Is(err, notification.ErrUnknownWebhookEvent):
+		jsonutil.WriteErrorJSON(w, err.Error())
+	default:
+		log.Printf("Failed to %s webhook endpoint: %v", action, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to " + action + " webhook endpoint"})
+	}
+}
+
+// ListEndpoints answers the endpoints, of one partner with ?partner_id=
+func (h *endpointHandler) ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admin(w, r); !ok {
+		return
+	}
+	endpoints, err := h.endpoints.ListEndpoints(r.Context(), r.URL.Query().Get("partner_id"))
+	if err != nil {
+		writeEndpointError(w, err, "list")
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": endpoints})
+}
+
+// CreateEndpoint registers an endpoint, answering its signing secret this
+// once
+func (h *endpointHandler) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.admin(w, r)
+	if !ok {
+		return
+	}
+	var req CreateEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+
+	e := &notification.WebhookEndpoint{PartnerID: req.PartnerID, URL: req.URL, EventTypes: req.EventTypes}
+	if err := h.endpoints.CreateEndpoint(r.Context(), e); err != nil {
+		writeEndpointError(w, err, "create")
+		return
+	}
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      userID,
+		Action:       "notification_webhook_endpoint.created",
+		ResourceType: "notification_webhook_endpoint",
+		ResourceID:   e.ID,
+		Metadata:     map[string]interface{}{"partner_id": e.PartnerID, "url": e.URL, "event_types": e.EventTypes},
+	})
+	jsonutil.WriteJSON(w, http.StatusCreated, e)
+}
+
+func (h *endpointHandler) GetEndpoint(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admin(w, r); !ok {
+		return
+	}
+	e, ok := h.loadEndpoint(w, r)
+	if !ok {
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, e)
+}
+
+// UpdateEndpoint changes an endpoint's URL or event types, or pauses and
+// resumes it with active
+func (h *endpointHandler) UpdateEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.admin(w, r)
+	if !ok {
+		return
+	}
+	var req UpdateEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	e, ok := h.loadEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.endpoints.UpdateEndpoint(r.Context(), e, req.URL, req.EventTypes, req.Active); err != nil {
+		writeEndpointError(w, err, "update")
+		return
+	}
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      userID,
+		Action:       "notification_webhook_endpoint.updated",
+		ResourceType: "notification_webhook_endpoint",
+		ResourceID:   e.ID,
+		Metadata:     map[string]interface{}{"url": e.URL, "event_types": e.EventTypes, "active": e.Active},
+	})
+	jsonutil.WriteJSON(w, http.StatusOK, e)
+}
+
+func (h *endpointHandler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.admin(w, r)
+	if !ok {
+		return
+	}
+	e, ok := h.loadEndpoint(w, r)
+	if !ok {
+		return
+	}
+	if err := h.endpoints.DeleteEndpoint(r.Context(), e.ID); err != nil {
+		writeEndpointError(w, err, "delete")
+		return
+	}
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      userID,
+		Action:       "notification_webhook_endpoint.deleted",
+		ResourceType: "notification_webhook_endpoint",
+		ResourceID:   e.ID,
+		Metadata:     map[string]interface{}{"partner_id": e.PartnerID},
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
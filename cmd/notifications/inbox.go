@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/notification"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// streamHeartbeat keeps idle inbox streams open through proxies
+const streamHeartbeat = 25 * time.Second
+
+// inboxHandler serves users their in-app notifications: the web
+// notifications sent to them
+type inboxHandler struct {
+	inbox *notification.Inbox
+}
+
+func (h *inboxHandler) user(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+		return "", false
+	}
+	return userID, true
+}
+
+// List answers the user's inbox, newest first, with its unread count.
+// ?unread=true keeps the unread notifications, ?archived=true lists the
+// archived ones instead, and ?before= with the created_at of the last one
+// pages through.
+func (h *inboxHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	filter := notification.InboxFilter{
+		Unread:   q.Get("unread") == "true",
+		Archived: q.Get("archived") == "true",
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 100 {
+			jsonutil.WriteErrorJSON(w, "limit must be between 1 and 100")
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := q.Get("before"); v != "" {
+		before, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			jsonutil.WriteErrorJSON(w, "before must be an RFC 3339 time")
+			return
+		}
+		filter.Before = &before
+	}
+
+	notifications, unread, err := h.inbox.List(r.Context(), userID, filter)
+	if err != nil {
+		log.Printf("Failed to list inbox of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list notifications"})
+		return
+	}
+	if notifications == nil {
+		notifications = []notification.Notification{}
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"data":         notifications,
+		"unread_count": unread,
+	})
+}
+
+// Unread answers the user's unread count, for a notification bell
+func (h *inboxHandler) Unread(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	unread, err := h.inbox.Unread(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to count unread notifications of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to count notifications"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]int{"unread_count": unread})
+}
+
+func (h *inboxHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	h.update(w, r, "mark read", h.inbox.MarkRead)
+}
+
+func (h *inboxHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	h.update(w, r, "archive", h.inbox.Archive)
+}
+
+// update applies a change to one of the user's notifications. Others'
+// notifications are not found.
+func (h *inboxHandler) update(w http.ResponseWriter, r *http.Request, action string, apply func(ctx context.Context, userID, id string) error) {
+	userID, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	err := apply(r.Context(), userID, id)
+	if errors.Is(err, notification.ErrInboxNotFound) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Notification not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to %s notification %s: %v", action, id, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to " + action + " notification"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllRead marks every notification in the user's inbox read
+func (h *inboxHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	marked, err := h.inbox.MarkAllRead(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to mark inbox of %s read: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to mark notifications read"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]int64{"marked": marked})
+}
+
+// Stream pushes the user's new notifications as server-sent events, after
+// an unread event with their unread count
+func (h *inboxHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.user(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Streaming is unsupported"})
+		return
+	}
+
+	// Subscribe before counting, so that nothing falls in between
+	notifications, err := h.inbox.Subscribe(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to subscribe to inbox of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "Failed to open notification stream"})
+		return
+	}
+	unread, err := h.inbox.Unread(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to count unread notifications of %s: %v", userID, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to count notifications"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Unbuffered through nginx
+	w.WriteHeader(http.StatusOK)
+	writeEvent(w, "unread", map[string]int{"unread_count": unread})
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case n, ok := <-notifications:
+			if !ok {
+				return
+			}
+			writeEvent(w, "notification", n)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes a server-sent event with a JSON payload
+func writeEvent(w http.ResponseWriter, event string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", event, err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
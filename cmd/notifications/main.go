@@ -4,8 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -14,7 +19,6 @@ import (
 	"github.com/sapliy/fintech-ecosystem/internal/notification"
 	"github.com/sapliy/fintech-ecosystem/pkg/database"
 	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
-	"github.com/sapliy/fintech-ecosystem/pkg/monitoring"
 )
 
 var (
@@ -32,6 +36,16 @@ var (
 		Name: "notifications_sent_total",
 		Help: "Total number of notifications sent by workers.",
 	}, []string{"channel", "status"})
+
+	NotificationsSuppressed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notifications_suppressed_total",
+		Help: "Total number of notifications held back by rate limiting or deduplication.",
+	}, []string{"channel", "reason"})
+
+	DeliveryCallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_delivery_callbacks_total",
+		Help: "Total number of delivery statuses reported by providers.",
+	}, []string{"provider", "status"})
 )
 
 func main() {
@@ -43,7 +57,14 @@ func main() {
 	redisAddr := getEnv("REDIS_ADDR", "localhost:6379")
 	dbDSN := getEnv("DATABASE_URL", "")
 
-	ctx := context.Background()
+	// SIGTERM stops the consumers, which finish the messages they hold
+	// before the service exits
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdownTimeout, err := time.ParseDuration(getEnv("NOTIFICATION_SHUTDOWN_TIMEOUT", "30s"))
+	if err != nil {
+		log.Fatalf("Invalid NOTIFICATION_SHUTDOWN_TIMEOUT: %v", err)
+	}
 
 	// Initialize Redis client
 	rdb := redis.NewClient(&redis.Options{
@@ -96,20 +117,131 @@ func main() {
 	// Initialize driver registry for workers
 	registry := notification.NewDriverRegistry()
 	registry.Register(notification.NewEmailDriver())
-	registry.Register(notification.NewSMSDriver())
-	registry.Register(notification.NewWebDriver())
+	sms, err := smsDriver()
+	if err != nil {
+		log.Fatalf("Failed to configure SMS driver: %v", err)
+	}
+	registry.Register(sms)
+	web, err := webDriver()
+	if err != nil {
+		log.Fatalf("Failed to configure web push driver: %v", err)
+	}
+	registry.Register(web)
+
+	// Users' opt-outs are enforced when events are routed and again by the
+	// workers, for the tasks queued by flows
+	var preferences *notification.Preferences
+	if repo != nil {
+		preferences = notification.NewPreferences(repo)
+	} else {
+		log.Println("Warning: No database, notification preferences are not enforced")
+	}
+
+	// Templates published in the database take over the built-in ones
+	var renderer *notification.Renderer
+	var templates *notification.TemplateService
+	if repo != nil {
+		renderer = notification.NewRenderer(repo)
+		templates = notification.NewTemplateService(repo, renderer)
+	}
+
+	// Sent notifications are tracked until their providers report them
+	// delivered, bounced or opened
+	var tracker *notification.DeliveryTracker
+	var delivery *deliveryHandler
+	if repo != nil {
+		tracker = notification.NewDeliveryTracker(repo)
+		delivery = &deliveryHandler{
+			tracker:           tracker,
+			resendSecret:      os.Getenv("RESEND_WEBHOOK_SECRET"),
+			twilioToken:       os.Getenv("TWILIO_AUTH_TOKEN"),
+			twilioCallbackURL: os.Getenv("TWILIO_STATUS_CALLBACK_URL"),
+			webhooks:          repo,
+		}
+	}
+
+	// Events are posted to the partners' endpoints subscribed to them
+	var endpoints *notification.EndpointRegistry
+	if repo != nil {
+		endpoints = notification.NewEndpointRegistry(repo)
+	} else {
+		log.Println("Warning: No database, no webhooks are sent to partners")
+	}
 
 	// Initialize event router
 	router := notification.NewRouter(rabbitClient)
+	router.SetPreferences(preferences)
+	router.SetEndpoints(endpoints)
+
+	// Low-priority emails are held for the users' digests, sent once per
+	// NOTIFICATION_DIGEST_WINDOW. A window of 0 sends every email right away.
+	digestWindow, err := time.ParseDuration(getEnv("NOTIFICATION_DIGEST_WINDOW", "1h"))
+	if err != nil {
+		log.Fatalf("Invalid NOTIFICATION_DIGEST_WINDOW: %v", err)
+	}
+	if repo != nil && digestWindow > 0 {
+		digester := notification.NewDigester(repo, rabbitClient, digestWindow)
+		router.SetDigester(digester)
+		go digester.Run(ctx, time.Minute)
+		log.Printf("Notification digests enabled (window: %s)", digestWindow)
+	}
+
+	// Scheduled notifications wait in Redis until their send time
+	var scheduler *notification.Scheduler
+	if rdb != nil {
+		scheduler = notification.NewScheduler(notification.NewRedisScheduleStore(rdb), rabbitClient)
+		go scheduler.Run(ctx, time.Second)
+	} else {
+		log.Println("Warning: No Redis, scheduled notifications are sent right away")
+	}
+
+	// Recipients get a limited number of messages per hour and no identical
+	// messages in a row
+	var suppressor *notification.Suppressor
+	if rdb != nil {
+		suppressor = notification.NewSuppressor(notification.NewRedisSuppressionStore(rdb), suppressionConfig())
+	}
+
+	// Web notifications make up the users' in-app inboxes, pushed to the
+	// inboxes open on any instance through Redis
+	var inbox *notification.Inbox
+	if repo != nil {
+		if rdb != nil {
+			inbox = notification.NewInbox(repo, notification.NewRedisInboxBroker(rdb))
+		} else {
+			inbox = notification.NewInbox(repo, notification.NewLocalInboxBroker())
+		}
+	}
 
 	// Initialize Email Service
 	emailService := notification.NewEmailService(os.Getenv("RESEND_API_KEY"))
 
+	// Webhooks are posted to partners with retries, each attempt recorded
+	webhookWorker := notification.NewWebhookWorker(rdb)
+	webhookWorker.SetConfig(webhookConfig())
+	if repo != nil {
+		webhookWorker.SetAttemptStore(repo)
+	}
+
 	// Start notification workers (consume from RabbitMQ)
-	startWorkers(rabbitClient, registry, rdb, repo, emailService)
+	workers := startWorkers(ctx, rabbitClient, workerOptions(), registry, rdb, preferences, renderer, tracker, scheduler, suppressor, inbox, emailService, webhookWorker)
 
-	// Start Metrics Server
-	monitoring.StartMetricsServer(":8084")
+	// Metrics, health, sending, the preferences and templates APIs and
+	// delivery callbacks
+	srv := &http.Server{
+		Addr:    ":8084",
+		Handler: apiRoutes(repo, templates, endpoints, inbox, delivery, &sendHandler{publisher: rabbitClient, scheduler: scheduler}),
+	}
+	if inbox != nil {
+		// Open inbox streams would hold up shutdown
+		srv.RegisterOnShutdown(inbox.Close)
+	}
+	go func() {
+		log.Println("Notification API listening on :8084")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Notification API failed: %v", err)
+		}
+	}()
 
 	log.Println("Notification Service started")
 	log.Printf("  - Kafka: %s (topic: %s, group: %s)", kafkaBrokers, kafkaTopic, kafkaGroupID)
@@ -126,7 +258,9 @@ func main() {
 		}
 	}()
 
-	// Consume events from Kafka and route to RabbitMQ
+	// Consume events from Kafka and route to RabbitMQ until shutdown. The
+	// event in flight is routed to the end.
+	routeCtx := context.WithoutCancel(ctx)
 	kafkaConsumer.Consume(ctx, func(key string, value []byte) error {
 		var event notification.Event
 		if err := json.Unmarshal(value, &event); err != nil {
@@ -138,7 +272,7 @@ func main() {
 		log.Printf("Received event: type=%s, id=%s", event.Type, event.ID)
 
 		// Route event to appropriate RabbitMQ queues
-		if err := router.Route(ctx, &event); err != nil {
+		if err := router.Route(routeCtx, &event); err != nil {
 			log.Printf("Failed to route event: %v", err)
 			EventsProcessed.WithLabelValues(string(event.Type), "error").Inc()
 			return err
@@ -148,53 +282,90 @@ func main() {
 		return nil
 	})
 
-	// Keep main running
-	select {}
+	log.Println("Shutting down Notification Service...")
+	if shutdown(srv, workers, shutdownTimeout) {
+		log.Println("Notification Service stopped")
+	} else {
+		log.Println("Notification Service stopped before its workers finished, their messages are requeued")
+	}
 }
 
-func startWorkers(rabbitClient *messaging.RabbitMQClient, registry *notification.DriverRegistry, rdb *redis.Client, repo *notification.Repository, emailService *notification.EmailService) {
+// shutdown stops the API once its requests in flight are served, then waits
+// for the workers to finish their messages, all within the timeout. It
+// reports whether the workers finished.
+func shutdown(srv *http.Server, workers *sync.WaitGroup, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Notification API shutdown error: %v", err)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// startWorkers consumes the notification queues until ctx is done. The
+// returned WaitGroup is done once the workers have finished their messages.
+func startWorkers(ctx context.Context, rabbitClient *messaging.RabbitMQClient, opts messaging.ConsumeOptions, registry *notification.DriverRegistry, rdb *redis.Client, preferences *notification.Preferences, renderer *notification.Renderer, tracker *notification.DeliveryTracker, scheduler *notification.Scheduler, suppressor *notification.Suppressor, inbox *notification.Inbox, emailService *notification.EmailService, webhookWorker *notification.WebhookWorker) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	consume := func(queue string, handler func(body []byte) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := rabbitClient.ConsumeConcurrently(ctx, queue, opts, handler); err != nil {
+				log.Printf("Consumer for %s stopped: %v", queue, err)
+			}
+		}()
+	}
+
 	// Email worker
 	emailDriver, _ := registry.Get(notification.Email)
 	emailWorker := notification.NewWorker(notification.Email, emailDriver, rdb, emailService)
-	rabbitClient.Consume("email.notifications", func(body []byte) error {
-		err := emailWorker.ProcessTask(context.Background(), body)
-		if err != nil {
-			NotificationsSent.WithLabelValues("email", "error").Inc()
-		} else {
-			NotificationsSent.WithLabelValues("email", "success").Inc()
-		}
-		return err
+	emailWorker.SetPreferences(preferences)
+	emailWorker.SetRenderer(renderer)
+	emailWorker.SetTracker(tracker)
+	emailWorker.SetScheduler(scheduler)
+	emailWorker.SetSuppressor(suppressor)
+	consume("email.notifications", func(body []byte) error {
+		return workerResult("email", emailWorker.ProcessTask(context.Background(), body))
 	})
 
 	// SMS worker
 	smsDriver, _ := registry.Get(notification.SMS)
 	smsWorker := notification.NewWorker(notification.SMS, smsDriver, rdb, nil)
-	rabbitClient.Consume("sms.notifications", func(body []byte) error {
-		err := smsWorker.ProcessTask(context.Background(), body)
-		if err != nil {
-			NotificationsSent.WithLabelValues("sms", "error").Inc()
-		} else {
-			NotificationsSent.WithLabelValues("sms", "success").Inc()
-		}
-		return err
+	smsWorker.SetPreferences(preferences)
+	smsWorker.SetRenderer(renderer)
+	smsWorker.SetTracker(tracker)
+	smsWorker.SetScheduler(scheduler)
+	smsWorker.SetSuppressor(suppressor)
+	consume("sms.notifications", func(body []byte) error {
+		return workerResult("sms", smsWorker.ProcessTask(context.Background(), body))
 	})
 
 	// Web push worker
 	webDriver, _ := registry.Get(notification.Web)
 	webWorker := notification.NewWorker(notification.Web, webDriver, rdb, nil)
-	rabbitClient.Consume("web.notifications", func(body []byte) error {
-		err := webWorker.ProcessTask(context.Background(), body)
-		if err != nil {
-			NotificationsSent.WithLabelValues("web", "error").Inc()
-		} else {
-			NotificationsSent.WithLabelValues("web", "success").Inc()
-		}
-		return err
+	webWorker.SetPreferences(preferences)
+	webWorker.SetRenderer(renderer)
+	webWorker.SetTracker(tracker)
+	webWorker.SetScheduler(scheduler)
+	webWorker.SetSuppressor(suppressor)
+	webWorker.SetInbox(inbox)
+	consume("web.notifications", func(body []byte) error {
+		return workerResult("web", webWorker.ProcessTask(context.Background(), body))
 	})
 
 	// Webhook worker
-	webhookWorker := notification.NewWebhookWorker(rdb)
-	rabbitClient.Consume("webhook.notifications", func(body []byte) error {
+	consume("webhook.notifications", func(body []byte) error {
 		err := webhookWorker.ProcessWebhook(context.Background(), body)
 		if err != nil {
 			NotificationsSent.WithLabelValues("webhook", "error").Inc()
@@ -204,7 +375,34 @@ func startWorkers(rabbitClient *messaging.RabbitMQClient, registry *notification
 		return err
 	})
 
-	log.Println("Workers started for: email, sms, web, webhook")
+	log.Printf("Workers started for: email, sms, web, webhook (concurrency: %d, prefetch: %d)", opts.Concurrency, opts.Prefetch)
+	return &wg
+}
+
+// workerOptions bounds the messages each worker handles at once, with
+// NOTIFICATION_WORKER_CONCURRENCY, and the unacked messages it holds, with
+// NOTIFICATION_WORKER_PREFETCH
+func workerOptions() messaging.ConsumeOptions {
+	opts := messaging.ConsumeOptions{Concurrency: 4}
+	for env, value := range map[string]*int{
+		"NOTIFICATION_WORKER_CONCURRENCY": &opts.Concurrency,
+		"NOTIFICATION_WORKER_PREFETCH":    &opts.Prefetch,
+	} {
+		if v := os.Getenv(env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid %s: %q", env, v)
+			}
+			*value = n
+		}
+	}
+	if opts.Prefetch == 0 {
+		opts.Prefetch = 2 * opts.Concurrency
+	}
+	if opts.Prefetch < opts.Concurrency {
+		log.Fatalf("NOTIFICATION_WORKER_PREFETCH must be at least NOTIFICATION_WORKER_CONCURRENCY")
+	}
+	return opts
 }
 
 func getEnv(key, defaultValue string) string {
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("sent"))
+	}))
+	defer server.Close()
+
+	// A request and a worker's message are in flight when the shutdown starts
+	sent := make(chan string, 1)
+	go func() {
+		resp, err := http.Post(server.URL+"/v1/notifications/send", "application/json", nil)
+		if err != nil {
+			sent <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		sent <- string(body)
+	}()
+	<-started
+
+	var workers sync.WaitGroup
+	var handled atomic.Bool
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		<-release
+		handled.Store(true)
+	}()
+
+	stopped := make(chan bool, 1)
+	go func() { stopped <- shutdown(server.Config, &workers, 5*time.Second) }()
+	select {
+	case <-stopped:
+		t.Fatal("Expected the shutdown to wait for the work in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if body := <-sent; body != "sent" {
+		t.Errorf("Expected the request in flight to complete, got %q", body)
+	}
+	if !<-stopped || !handled.Load() {
+		t.Error("Expected the workers to finish their messages before exit")
+	}
+
+	// Workers still busy at the timeout leave their messages to be requeued
+	var stuck sync.WaitGroup
+	stuck.Add(1)
+	defer stuck.Done()
+	idle := httptest.NewServer(http.NotFoundHandler())
+	defer idle.Close()
+	if shutdown(idle.Config, &stuck, 20*time.Millisecond) {
+		t.Error("Expected the shutdown to give up on workers after the timeout")
+	}
+}
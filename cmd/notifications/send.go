@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/notification"
+	"github.com/sapliy/fintech-ecosystem/pkg/audit"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// sendHandler queues notifications for the workers, or schedules them for
+// later. Notifications go to any recipient, so only owners and admins send
+// them.
+type sendHandler struct {
+	publisher notification.RabbitPublisher
+	scheduler *notification.Scheduler // nil without Redis
+}
+
+func (h *sendHandler) admin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+		return "", false
+	}
+	if role := r.Header.Get("X-Role"); role != "owner" && role != "admin" {
+		jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": "Only owners and admins can send notifications"})
+		return "", false
+	}
+	return userID, true
+}
+
+// Send queues a notification, or schedules it when it has a send_at or a
+// delay
+func (h *sendHandler) Send(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.admin(w, r)
+	if !ok {
+		return
+	}
+	var req notification.NotificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	switch req.Channel {
+	case notification.Email, notification.SMS, notification.Web:
+	default:
+		jsonutil.WriteErrorJSON(w, notification.ErrInvalidChannel.Error())
+		return
+	}
+	if req.Recipient == "" || req.TemplateID == "" {
+		jsonutil.WriteErrorJSON(w, "recipient and template_id are required")
+		return
+	}
+	if req.Locale != "" {
+		locale, err := notification.NormalizeLocale(req.Locale)
+		if err != nil {
+			jsonutil.WriteErrorJSON(w, err.Error())
+			return
+		}
+		req.Locale = locale
+	}
+	now := time.Now()
+	if err := req.ResolveSchedule(now); err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+
+	task := notification.TaskFromRequest("api_"+uuid.New().String(), &req)
+	if task.SendAt != nil && task.SendAt.After(now) {
+		if h.scheduler == nil {
+			jsonutil.WriteJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "Scheduling is unavailable"})
+			return
+		}
+		scheduled, err := h.scheduler.Schedule(r.Context(), task, *task.SendAt)
+		if err != nil {
+			log.Printf("Failed to schedule notification: %v", err)
+			jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to schedule notification"})
+			return
+		}
+		audit.Log(r.Context(), audit.AuditLog{
+			ActorID:      userID,
+			Action:       "notification.scheduled",
+			ResourceType: "notification",
+			ResourceID:   task.ID,
+			Metadata:     map[string]interface{}{"channel": task.Channel, "template_id": task.TemplateID, "send_at": scheduled.SendAt},
+		})
+		jsonutil.WriteJSON(w, http.StatusAccepted, map[string]interface{}{
+			"id":      task.ID,
+			"status":  "scheduled",
+			"send_at": scheduled.SendAt,
+		})
+		return
+	}
+
+	task.SendAt = nil
+	body, err := json.Marshal(task)
+	if err == nil {
+		err = h.publisher.Publish(r.Context(), notification.QueueFor(task.Channel), body)
+	}
+	if err != nil {
+		log.Printf("Failed to queue notification: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to queue notification"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusAccepted, map[string]interface{}{
+		"id":     task.ID,
+		"status": "queued",
+	})
+}
+
+// GetScheduled returns a notification that is not sent yet
+func (h *sendHandler) GetScheduled(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admin(w, r); !ok {
+		return
+	}
+	scheduled, err := h.scheduler.Get(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, notification.ErrScheduleNotFound) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get scheduled notification: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get scheduled notification"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, scheduled)
+}
+
+// CancelScheduled drops a notification that is not sent yet
+func (h *sendHandler) CancelScheduled(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.admin(w, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	err := h.scheduler.Cancel(r.Context(), id)
+	if errors.Is(err, notification.ErrScheduleNotFound) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to cancel scheduled notification: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to cancel scheduled notification"})
+		return
+	}
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      userID,
+		Action:       "notification.schedule_canceled",
+		ResourceType: "notification",
+		ResourceID:   id,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/notification"
+	"github.com/sapliy/fintech-ecosystem/pkg/audit"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+type CreateTemplateRequest struct {
+	Name    string               `json:"name"`
+	Channel notification.Channel `json:"channel"`
+	Locale  string               `json:"locale"` // Defaults to en
+	Subject string               `json:"subject"`
+	Body    string               `json:"body"`
+}
+
+type UpdateTemplateRequest struct {
+	Subject *string `json:"subject"`
+	Body    *string `json:"body"`
+}
+
+type PreviewTemplateRequest struct {
+	Data map[string]string `json:"data"` // Laid over the sample data
+}
+
+// templateHandler manages notification templates. Templates apply to every
+// notification, so only owners and admins manage them.
+type templateHandler struct {
+	templates *notification.TemplateService
+}
+
+func (h *templateHandler) admin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := r.Header.Get("X-User-ID")
+	if userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+		return "", false
+	}
+	if role := r.Header.Get("X-Role"); role != "owner" && role != "admin" {
+		jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": "Only owners and admins can manage templates"})
+		return "", false
+	}
+	return userID, true
+}
+
+func (h *templateHandler) loadTemplate(w http.ResponseWriter, r *http.Request) (*notification.Template, bool) {
+	t, err := h.templates.GetTemplate(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, notification.ErrTemplateNotFound) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Template not found"})
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Failed to get template: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get template"})
+		return nil, false
+	}
+	return t, true
+}
+
+// writeTemplateError answers the errors of changing a template
+func writeTemplateError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, notification.ErrTemplateNotDraft):
+		jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, notification.ErrInvalidTemplate), errors.Is(err, notification.ErrInvalidChannel),
+		errors.Is(err, notification.ErrTemplateSyntax), errors.Is(err, notification.ErrInvalidTemplateStatus):
+		jsonutil.WriteErrorJSON(w, err.Error())
+	default:
+		log.Printf("Failed to %s template: %v", action, err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to " + action + " template"})
+	}
+}
+
+func (h *templateHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admin(w, r); !ok {
+		return
+	}
+	q := r.URL.Query()
+	templates, err := h.templates.ListTemplates(r.Context(), notification.TemplateFilter{
+		Name:    q.Get("name"),
+		Channel: notification.Channel(q.Get("channel")),
+		Locale:  q.Get("locale"),
+		Status:  q.Get("status"),
+	})
+	if err != nil {
+		writeTemplateError(w, err, "list")
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"data": templates})
+}
+
+// CreateTemplate adds a draft as the next version of a template
+func (h *templateHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.admin(w, r)
+	if !ok {
+		return
+	}
+	var req CreateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+
+	t := &notification.Template{
+		Name:      req.Name,
+		Channel:   req.Channel,
+		Locale:    req.Locale,
+		Subject:   req.Subject,
+		Body:      req.Body,
+		CreatedBy: userID,
+	}
+	if err := h.templates.CreateTemplate(r.Context(), t); err != nil {
+		writeTemplateError(w, err, "create")
+		return
+	}
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      userID,
+		Action:       "notification_template.created",
+		ResourceType: "notification_template",
+		ResourceID:   t.ID,
+		Metadata:     map[string]interface{}{"name": t.Name, "channel": t.Channel, "locale": t.Locale, "version": t.Version},
+	})
+	jsonutil.WriteJSON(w, http.StatusCreated, t)
+}
+
+func (h *templateHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admin(w, r); !ok {
+		return
+	}
+	t, ok := h.loadTemplate(w, r)
+	if !ok {
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, t)
+}
+
+// UpdateTemplate changes a draft's subject or body
+func (h *templateHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admin(w, r); !ok {
+		return
+	}
+	var req UpdateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	t, ok := h.loadTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	subject, body := t.Subject, t.Body
+	if req.Subject != nil {
+		subject = *req.Subject
+	}
+	if req.Body != nil {
+		body = *req.Body
+	}
+	if err := h.templates.UpdateTemplate(r.Context(), t, subject, body); err != nil {
+		writeTemplateError(w, err, "update")
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, t)
+}
+
+// DeleteTemplate discards a draft
+func (h *templateHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admin(w, r); !ok {
+		return
+	}
+	t, ok := h.loadTemplate(w, r)
+	if !ok {
+		return
+	}
+	if err := h.templates.DeleteTemplate(r.Context(), t); err != nil {
+		writeTemplateError(w, err, "delete")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PublishTemplate makes a draft the version notifications are sent with
+func (h *templateHandler) PublishTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.admin(w, r)
+	if !ok {
+		return
+	}
+	t, ok := h.loadTemplate(w, r)
+	if !ok {
+		return
+	}
+	if err := h.templates.PublishTemplate(r.Context(), t); err != nil {
+		writeTemplateError(w, err, "publish")
+		return
+	}
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      userID,
+		Action:       "notification_template.published",
+		ResourceType: "notification_template",
+		ResourceID:   t.ID,
+		Metadata:     map[string]interface{}{"name": t.Name, "channel": t.Channel, "locale": t.Locale, "version": t.Version},
+	})
+	jsonutil.WriteJSON(w, http.StatusOK, t)
+}
+
+// PreviewTemplate renders any version of a template against sample data
+func (h *templateHandler) PreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.admin(w, r); !ok {
+		return
+	}
+	var req PreviewTemplateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonutil.WriteErrorJSON(w, "Invalid request body")
+			return
+		}
+	}
+	t, ok := h.loadTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	rendered, err := h.templates.Preview(t, req.Data)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, "Failed to render template: "+err.Error())
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, rendered)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/payment/infrastructure"
+	"github.com/sapliy/fintech-ecosystem/pkg/audit"
+	"github.com/sapliy/fintech-ecosystem/pkg/bank"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+const defaultAuthorizationTTL = 7 * 24 * time.Hour
+
+type CaptureIntentRequest struct {
+	AmountToCapture int64 `json:"amount_to_capture"` // In cents; omitted or zero captures the whole amount
+}
+
+// authorizePaymentIntent places a bank hold for a manually captured intent
+// being confirmed. The intent then requires capture before the hold expires.
+// Only cards support holds.
+func (h *PaymentHandler) authorizePaymentIntent(w http.ResponseWriter, r *http.Request, intent *domain.PaymentIntent, method *domain.PaymentMethod, adapter bank.Client) {
+	if method.Type != domain.PaymentMethodCard {
+		jsonutil.WriteErrorJSON(w, "manual capture is only supported for cards")
+		return
+	}
+
+	result, err := adapter.Authorize(r.Context(), intent.Amount, intent.Currency, method.Token)
+	if err != nil || result.Status != bank.StatusSuccess {
+		if updateErr := h.service.UpdateStatus(r.Context(), intent.ID, "failed"); updateErr != nil {
+			log.Printf("Failed to update status: %v", updateErr)
+		}
+		intent.Status = "failed"
+		h.publishWebhook(r, domain.EventPaymentFailed, intent)
+		jsonutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "failed", "reason": "Bank declined"})
+		return
+	}
+
+	ttl := h.authorizationTTL
+	if ttl <= 0 {
+		ttl = defaultAuthorizationTTL
+	}
+	if err := h.service.AuthorizePaymentIntent(r.Context(), intent, result.TransactionID, time.Now().UTC().Add(ttl)); err != nil {
+		infrastructure.PaymentRequests.WithLabelValues("confirm", "error").Inc()
+		if voidErr := adapter.Void(r.Context(), result.TransactionID); voidErr != nil {
+			log.Printf("Failed to void authorization %s: %v", result.TransactionID, voidErr)
+		}
+		jsonutil.WriteErrorJSON(w, "Failed to update payment status")
+		return
+	}
+	infrastructure.PaymentRequests.WithLabelValues("confirm", "success").Inc()
+
+	jsonutil.WriteJSON(w, http.StatusOK, intent)
+}
+
+// CapturePaymentIntent collects all or part of an authorized payment. The
+// part of the hold that is not captured is released.
+func (h *PaymentHandler) CapturePaymentIntent(w http.ResponseWriter, r *http.Request) {
+	timer := prometheus.NewTimer(infrastructure.PaymentLatency.WithLabelValues("capture"))
+	defer timer.ObserveDuration()
+
+	id := mux.Vars(r)["id"]
+
+	var req CaptureIntentRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonutil.WriteErrorJSON(w, "Invalid request body")
+			return
+		}
+	}
+
+	intent, err := h.service.GetPaymentIntent(r.Context(), id)
+	if err != nil || intent == nil {
+		jsonutil.WriteErrorJSON(w, "Payment intent not found")
+		return
+	}
+
+	amount, err := h.service.CheckCapture(intent, req.AmountToCapture, time.Now())
+	if err != nil {
+		infrastructure.PaymentRequests.WithLabelValues("capture", "error").Inc()
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+
+	adapter, err := h.banks.Adapter(domain.PaymentMethodCard)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, "card payments are not supported")
+		return
+	}
+	result, err := adapter.Capture(r.Context(), intent.AuthorizationID, amount)
+	if err != nil || result.Status != bank.StatusSuccess {
+		infrastructure.PaymentRequests.WithLabelValues("capture", "error").Inc()
+		log.Printf("Bank capture of %s failed: %v", id, err)
+		jsonutil.WriteJSON(w, http.StatusBadGateway, map[string]string{"error": "Bank capture failed"})
+		return
+	}
+
+	// Refunds are made against the capture, which some banks give the ID
+	// of the hold
+	transactionID := result.TransactionID
+	if transactionID == "" {
+		transactionID = intent.AuthorizationID
+	}
+	if err := h.service.CapturePaymentIntent(r.Context(), intent, amount, transactionID); err != nil {
+		infrastructure.PaymentRequests.WithLabelValues("capture", "error").Inc()
+		if err == domain.ErrNotCapturable {
+			jsonutil.WriteErrorJSON(w, err.Error())
+			return
+		}
+		// Critical: the bank captured funds we failed to record
+		log.Printf("Failed to record capture of %s: %v", id, err)
+		jsonutil.WriteErrorJSON(w, "Failed to update payment status")
+		return
+	}
+	infrastructure.PaymentRequests.WithLabelValues("capture", "success").Inc()
+
+	h.publishPaymentSucceeded(r, intent)
+	h.recordLedgerEntries(r, intent)
+	h.publishWebhook(r, domain.EventPaymentSucceeded, intent)
+
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      intent.UserID,
+		Action:       "payment.captured",
+		ResourceType: "payment_intent",
+		ResourceID:   intent.ID,
+		Metadata: map[string]interface{}{
+			"amount":          intent.Amount,
+			"amount_captured": intent.AmountCaptured,
+			"currency":        intent.Currency,
+		},
+	})
+
+	jsonutil.WriteJSON(w, http.StatusOK, intent)
+}
+
+// ExpireAuthorizations releases the holds of intents that were not captured
+// in time and cancels them
+func (h *PaymentHandler) ExpireAuthorizations(ctx context.Context) {
+	adapter, err := h.banks.Adapter(domain.PaymentMethodCard)
+	if err != nil {
+		return
+	}
+	expired, err := h.service.ListExpiredAuthorizations(ctx, time.Now(), 100)
+	if err != nil {
+		log.Printf("Failed to list expired authorizations: %v", err)
+		return
+	}
+	for _, intent := range expired {
+		if err := adapter.Void(ctx, intent.AuthorizationID); err != nil {
+			log.Printf("Failed to void authorization of %s: %v", intent.ID, err)
+			continue
+		}
+		if err := h.service.UpdateStatus(ctx, intent.ID, "canceled"); err != nil {
+			log.Printf("Failed to cancel %s: %v", intent.ID, err)
+		}
+	}
+}
+
+// StartAuthorizationExpiry expires uncaptured authorizations every interval
+// until the context is cancelled. A run in progress is not interrupted, so
+// shutdown never leaves a hold voided but its intent still authorized.
+func (h *PaymentHandler) StartAuthorizationExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.ExpireAuthorizations(context.WithoutCancel(ctx))
+		}
+	}
+}
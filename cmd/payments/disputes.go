@@ -0,0 +1,288 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/audit"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+	pb "github.com/sapliy/fintech-ecosystem/proto/ledger"
+)
+
+// CreateDisputeRequest opens a dispute. Banks send it from their chargeback
+// webhooks; merchants can also record a dispute they were told about.
+type CreateDisputeRequest struct {
+	PaymentIntentID string     `json:"payment_intent_id"`
+	Amount          int64      `json:"amount"` // Zero disputes the whole captured amount
+	Reason          string     `json:"reason"`
+	BankReference   string     `json:"bank_reference"`
+	EvidenceDueBy   *time.Time `json:"evidence_due_by"` // Default: 7 days
+}
+
+type AddDisputeEvidenceRequest struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url"`
+}
+
+type CloseDisputeRequest struct {
+	Outcome string `json:"outcome"` // won or lost
+}
+
+// fromBank reports whether a request was sent by the bank's webhook, which
+// authenticates with the shared BANK_WEBHOOK_SECRET
+func (h *PaymentHandler) fromBank(r *http.Request) bool {
+	secret := r.Header.Get("X-Bank-Webhook-Secret")
+	return h.bankWebhookSecret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(h.bankWebhookSecret)) == 1
+}
+
+// disputeCaller returns the merchant making a dispute request, or "" for
+// the bank, which may act on any merchant's disputes
+func (h *PaymentHandler) disputeCaller(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if h.fromBank(r) {
+		return "", true
+	}
+	return h.requireUser(w, r)
+}
+
+// CreateDispute opens a dispute of a collected payment
+func (h *PaymentHandler) CreateDispute(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.disputeCaller(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateDisputeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if req.PaymentIntentID == "" {
+		jsonutil.WriteErrorJSON(w, "payment_intent_id is required")
+		return
+	}
+
+	intent, err := h.service.GetPaymentIntent(r.Context(), req.PaymentIntentID)
+	if err != nil || intent == nil || (userID != "" && intent.UserID != userID) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Payment intent not found"})
+		return
+	}
+
+	dispute := &domain.Dispute{
+		Amount:        req.Amount,
+		Reason:        req.Reason,
+		Source:        domain.DisputeSourceManual,
+		BankReference: req.BankReference,
+		EvidenceDueBy: req.EvidenceDueBy,
+	}
+	if userID == "" {
+		dispute.Source = domain.DisputeSourceBank
+	}
+	if err := h.service.OpenDispute(r.Context(), intent, dispute); err != nil {
+		h.writeDisputeError(w, err)
+		return
+	}
+
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      intent.UserID,
+		Action:       "dispute.created",
+		ResourceType: "dispute",
+		ResourceID:   dispute.ID,
+		Metadata: map[string]interface{}{
+			"payment_intent_id": intent.ID,
+			"amount":            dispute.Amount,
+			"currency":          dispute.Currency,
+			"source":            dispute.Source,
+		},
+	})
+
+	jsonutil.WriteJSON(w, http.StatusCreated, dispute)
+}
+
+// ListDisputes lists the caller's disputes newest first, filtered by the
+// status and payment_intent_id query parameters
+func (h *PaymentHandler) ListDisputes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	disputes, err := h.service.ListDisputes(r.Context(), domain.DisputeFilter{
+		UserID:          userID,
+		PaymentIntentID: query.Get("payment_intent_id"),
+		Status:          query.Get("status"),
+	})
+	if err != nil {
+		log.Printf("Failed to list disputes: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list disputes"})
+		return
+	}
+	if disputes == nil {
+		disputes = []domain.Dispute{}
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, disputes)
+}
+
+// GetDispute returns a dispute of the caller
+func (h *PaymentHandler) GetDispute(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.disputeCaller(w, r)
+	if !ok {
+		return
+	}
+
+	dispute, err := h.service.GetDispute(r.Context(), userID, mux.Vars(r)["id"])
+	if err != nil {
+		h.writeDisputeError(w, err)
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, dispute)
+}
+
+// AddDisputeEvidence attaches evidence to a dispute awaiting a response
+func (h *PaymentHandler) AddDisputeEvidence(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.disputeCaller(w, r)
+	if !ok {
+		return
+	}
+
+	var req AddDisputeEvidenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	dispute, err := h.service.AddDisputeEvidence(r.Context(), userID, mux.Vars(r)["id"], domain.DisputeEvidence{
+		Type:        req.Type,
+		Description: req.Description,
+		FileName:    req.FileName,
+		ContentType: req.ContentType,
+		Size:        req.Size,
+		URL:         req.URL,
+	})
+	if err != nil {
+		h.writeDisputeError(w, err)
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, dispute)
+}
+
+// SubmitDispute sends the dispute's evidence to the bank for review
+func (h *PaymentHandler) SubmitDispute(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.disputeCaller(w, r)
+	if !ok {
+		return
+	}
+
+	dispute, err := h.service.SubmitDispute(r.Context(), userID, mux.Vars(r)["id"])
+	if err != nil {
+		h.writeDisputeError(w, err)
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, dispute)
+}
+
+// CloseDispute records the outcome of a dispute. Only the bank decides
+// disputes; merchants may accept one, closing it as lost. Lost disputes are
+// reversed in the ledger.
+func (h *PaymentHandler) CloseDispute(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.disputeCaller(w, r)
+	if !ok {
+		return
+	}
+
+	var req CloseDisputeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	if userID != "" && req.Outcome != domain.DisputeLost {
+		jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": "Merchants can only accept a dispute as lost"})
+		return
+	}
+
+	dispute, err := h.service.CloseDispute(r.Context(), userID, mux.Vars(r)["id"], req.Outcome)
+	if err != nil {
+		h.writeDisputeError(w, err)
+		return
+	}
+	if dispute.Status == domain.DisputeLost {
+		h.recordDisputeReversal(r, dispute)
+	}
+
+	audit.Log(r.Context(), audit.AuditLog{
+		ActorID:      dispute.UserID,
+		Action:       "dispute." + dispute.Status,
+		ResourceType: "dispute",
+		ResourceID:   dispute.ID,
+		Metadata: map[string]interface{}{
+			"payment_intent_id": dispute.PaymentIntentID,
+			"amount":            dispute.Amount,
+			"currency":          dispute.Currency,
+		},
+	})
+
+	jsonutil.WriteJSON(w, http.StatusOK, dispute)
+}
+
+// recordDisputeReversal takes the amount of a lost dispute back from the
+// merchant in the Ledger, in the merchant's settlement currency. The
+// platform keeps its fee of split payments; the connected account bears the
+// whole chargeback.
+func (h *PaymentHandler) recordDisputeReversal(r *http.Request, dispute *domain.Dispute) {
+	if h.ledgerClient == nil {
+		return
+	}
+	intent, err := h.service.GetPaymentIntent(r.Context(), dispute.PaymentIntentID)
+	if err != nil || intent == nil {
+		log.Printf("Failed to get payment intent of dispute %s: %v", dispute.ID, err)
+		return
+	}
+	disputed := *intent
+	disputed.AmountCaptured = dispute.Amount
+	disputed.ApplicationFeeAmount = 0
+	amount, _, settlementCurrency := h.settlementAmounts(r.Context(), &disputed)
+
+	merchantAccount := "user_" + intent.UserID
+	if intent.ApplicationFeeAmount > 0 && intent.OnBehalfOf != "" {
+		merchantAccount = "acc_" + intent.OnBehalfOf
+	}
+	_, err = h.ledgerClient.RecordTransaction(r.Context(), &pb.RecordTransactionRequest{
+		AccountId:   merchantAccount,
+		Amount:      -amount,
+		Currency:    settlementCurrency,
+		Description: intent.LedgerDescription("Chargeback of " + intent.ID),
+		ReferenceId: dispute.ID,
+		ZoneId:      intent.ZoneID,
+		Mode:        intent.Mode,
+	})
+	if err != nil {
+		log.Printf("Failed to record chargeback of dispute %s in ledger: %v", dispute.ID, err)
+	}
+}
+
+func (h *PaymentHandler) writeDisputeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrDisputeNotFound):
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrNotDisputable), errors.Is(err, domain.ErrDisputeExceedsAmount),
+		errors.Is(err, domain.ErrInvalidDisputeAmount), errors.Is(err, domain.ErrInvalidEvidence),
+		errors.Is(err, domain.ErrInvalidOutcome), errors.Is(err, domain.ErrTooMuchEvidence),
+		errors.Is(err, domain.ErrNoDisputeEvidence):
+		jsonutil.WriteErrorJSON(w, err.Error())
+	case errors.Is(err, domain.ErrDisputeClosed), errors.Is(err, domain.ErrDisputeNotOpen):
+		jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		log.Printf("Dispute request failed: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal Server Error"})
+	}
+}
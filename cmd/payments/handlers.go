@@ -2,15 +2,22 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/fraud"
 	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
 	"github.com/sapliy/fintech-ecosystem/internal/payment/infrastructure"
+	"github.com/sapliy/fintech-ecosystem/internal/policy"
 	"github.com/sapliy/fintech-ecosystem/pkg/audit"
 	"github.com/sapliy/fintech-ecosystem/pkg/bank"
+	"github.com/sapliy/fintech-ecosystem/pkg/currency"
 	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
 	"github.com/sapliy/fintech-ecosystem/pkg/jwtutil"
 
@@ -22,12 +29,23 @@ import (
 )
 
 type PaymentHandler struct {
-	service       *domain.PaymentService
-	bankClient    bank.Client
-	rdb           *redis.Client
-	ledgerClient  pb.LedgerServiceClient
-	kafkaProducer *messaging.KafkaProducer
-	rabbitClient  *messaging.RabbitMQClient
+	service      *domain.PaymentService
+	banks        *bank.Router // Bank adapters by payment method type
+	rdb          *redis.Client
+	ledgerClient pb.LedgerServiceClient
+	rabbitClient *messaging.RabbitMQClient
+	webhooks     *domain.WebhookService
+	fx           currency.Converter // Converts payments to merchants' settlement currencies
+	idempotency  IdempotencyConfig
+	fraud        *fraud.Client            // Pre-authorization checks, skipped if nil
+	policies     *policy.PolicyMiddleware // Payment and refund policies, skipped if nil
+
+	// authorizationTTL is how long manual capture holds last (default: 7 days)
+	authorizationTTL time.Duration
+	// bankWebhookSecret authenticates the bank's dispute webhooks
+	bankWebhookSecret string
+	// payoutDelay is how long captured payments wait before payout
+	payoutDelay time.Duration
 }
 
 // IdempotencyMiddleware wraps a handler to ensure idempotency.
@@ -53,8 +71,24 @@ func (h *PaymentHandler) IdempotencyMiddleware(next http.HandlerFunc) http.Handl
 			return
 		}
 
+		// Only one request per key runs at a time; duplicates that arrive
+		// while it runs are rejected rather than executed twice
+		store := h.idempotency.store(h.service)
+		scopedKey := h.idempotency.scopedKey(r, key)
+		unlock, err := store.Lock(r.Context(), userID, scopedKey, h.idempotency.lockTTL())
+		if err != nil {
+			if errors.Is(err, domain.ErrIdempotencyKeyInFlight) {
+				jsonutil.WriteJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			}
+			log.Printf("Error locking idempotency key: %v", err)
+			jsonutil.WriteErrorJSON(w, "Internal Server Error")
+			return
+		}
+		defer unlock()
+
 		// Check if key exists for this user
-		record, err := h.service.GetIdempotencyKey(r.Context(), userID, key)
+		record, err := store.Get(r.Context(), userID, scopedKey)
 		if err != nil {
 			log.Printf("Error checking idempotency key: %v", err)
 			jsonutil.WriteErrorJSON(w, "Internal Server Error")
@@ -80,7 +114,13 @@ func (h *PaymentHandler) IdempotencyMiddleware(next http.HandlerFunc) http.Handl
 
 		// Save key if it's not a server error (5xx)
 		if recorder.StatusCode < 500 {
-			if err := h.service.SaveIdempotencyKey(r.Context(), userID, key, recorder.StatusCode, recorder.Body.String()); err != nil {
+			record := &domain.IdempotencyRecord{
+				UserID:       userID,
+				Key:          scopedKey,
+				ResponseBody: recorder.Body.String(),
+				StatusCode:   recorder.StatusCode,
+			}
+			if err := store.Save(r.Context(), record, h.idempotency.ttl()); err != nil {
 				log.Printf("Failed to save idempotency key: %v", err)
 			}
 		}
@@ -88,15 +128,18 @@ func (h *PaymentHandler) IdempotencyMiddleware(next http.HandlerFunc) http.Handl
 }
 
 type CreateIntentRequest struct {
-	Amount               int64  `json:"amount"`
-	Currency             string `json:"currency"`
-	Description          string `json:"description"`
-	ApplicationFeeAmount int64  `json:"application_fee_amount"`
-	OnBehalfOf           string `json:"on_behalf_of"` // Connected Account ID
+	Amount               int64             `json:"amount"`
+	Currency             string            `json:"currency"`
+	Description          string            `json:"description"`
+	ApplicationFeeAmount int64             `json:"application_fee_amount"`
+	OnBehalfOf           string            `json:"on_behalf_of"`   // Connected Account ID
+	CaptureMethod        string            `json:"capture_method"` // automatic (default) or manual
+	StatementDescriptor  string            `json:"statement_descriptor"`
+	Metadata             map[string]string `json:"metadata"`
 }
 
 type ConfirmIntentRequest struct {
-	PaymentMethodID string `json:"payment_method_id"` // e.g., "tok_visa"
+	PaymentMethodID string `json:"payment_method_id"` // A tokenized payment method, or a card test token e.g. "tok_visa"
 }
 
 // extractUserIDFromToken is a helper to get UserID.
@@ -124,11 +167,6 @@ func (h *PaymentHandler) CreatePaymentIntent(w http.ResponseWriter, r *http.Requ
 	timer := prometheus.NewTimer(infrastructure.PaymentLatency.WithLabelValues("create"))
 	defer timer.ObserveDuration()
 
-	if r.Method != http.MethodPost {
-		jsonutil.WriteErrorJSON(w, "Method not allowed")
-		return
-	}
-
 	userID, err := extractUserIDFromToken(r)
 	if err != nil {
 		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
@@ -154,17 +192,49 @@ func (h *PaymentHandler) CreatePaymentIntent(w http.ResponseWriter, r *http.Requ
 		jsonutil.WriteErrorJSON(w, "Amount and Currency are required")
 		return
 	}
+	// Amounts are in the currency's minor units, e.g. cents or whole yen
+	code, err := currency.Normalize(req.Currency)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	req.Currency = code
+
+	if req.CaptureMethod == "" {
+		req.CaptureMethod = domain.CaptureAutomatic
+	}
+	if req.CaptureMethod != domain.CaptureAutomatic && req.CaptureMethod != domain.CaptureManual {
+		jsonutil.WriteErrorJSON(w, "capture_method must be automatic or manual")
+		return
+	}
+	if err := domain.ValidateStatementDescriptor(req.StatementDescriptor); err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	if err := domain.ValidateMetadata(req.Metadata); err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	if !h.authorize(w, r, policy.ActionPaymentCreate, map[string]interface{}{
+		"amount":   req.Amount,
+		"currency": req.Currency,
+	}) {
+		return
+	}
 
 	intent := &domain.PaymentIntent{
 		Amount:               req.Amount,
 		Currency:             req.Currency,
 		Status:               "requires_payment_method",
 		Description:          req.Description,
+		StatementDescriptor:  req.StatementDescriptor,
+		Metadata:             req.Metadata,
 		UserID:               userID,
 		ZoneID:               r.Header.Get("X-Zone-ID"),
 		Mode:                 r.Header.Get("X-Zone-Mode"),
 		ApplicationFeeAmount: req.ApplicationFeeAmount,
 		OnBehalfOf:           req.OnBehalfOf,
+		CaptureMethod:        req.CaptureMethod,
 	}
 
 	if err := h.service.CreatePaymentIntent(r.Context(), intent); err != nil {
@@ -196,24 +266,7 @@ func (h *PaymentHandler) ConfirmPaymentIntent(w http.ResponseWriter, r *http.Req
 	timer := prometheus.NewTimer(infrastructure.PaymentLatency.WithLabelValues("confirm"))
 	defer timer.ObserveDuration()
 
-	if r.Method != http.MethodPost {
-		jsonutil.WriteErrorJSON(w, "Method not allowed")
-		return
-	}
-
-	// Extract ID from URL path (e.g. /payment_intents/{id}/confirm)
-	// Simple parsing since we use ServeMux
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 3 {
-		jsonutil.WriteErrorJSON(w, "Invalid path")
-		return
-	}
-	// Expected path: /payment_intents/{id}/confirm
-	// parts: ["", "payment_intents", "{id}", "confirm"]
-	// Wait, in main.go we will likely mount this handler at a specific path.
-	// Let's assume we handle the ID extraction there or here properly.
-	// If mounted at /payment_intents/, then parts are correct.
-	id := pathParts[2]
+	id := mux.Vars(r)["id"]
 
 	var req ConfirmIntentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -236,18 +289,52 @@ func (h *PaymentHandler) ConfirmPaymentIntent(w http.ResponseWriter, r *http.Req
 		jsonutil.WriteErrorJSON(w, "Payment already succeeded")
 		return
 	}
+	if intent.Status == "requires_capture" {
+		jsonutil.WriteErrorJSON(w, "Payment already authorized")
+		return
+	}
+
+	method, err := h.resolvePaymentMethod(r, intent, req.PaymentMethodID)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	adapter, err := h.banks.Adapter(method.Type)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, fmt.Sprintf("%s payments are not supported", method.Type))
+		return
+	}
 
-	// Call Mock Bank
-	if _, err := h.bankClient.Charge(r.Context(), intent.Amount, intent.Currency, "tok_visa"); err != nil {
+	if h.declinedByFraudCheck(r, intent, method) {
 		if updateErr := h.service.UpdateStatus(r.Context(), id, "failed"); updateErr != nil {
 			log.Printf("Failed to update status: %v", updateErr)
 		}
+		intent.Status = "failed"
+		h.publishWebhook(r, domain.EventPaymentFailed, intent)
+		jsonutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "failed", "reason": "Declined by risk check"})
+		return
+	}
+
+	// Manually captured payments only place a hold until they are captured
+	if intent.CaptureMethod == domain.CaptureManual {
+		h.authorizePaymentIntent(w, r, intent, method, adapter)
+		return
+	}
+
+	// Charge through the bank adapter of the payment method
+	result, err := adapter.Charge(r.Context(), intent.Amount, intent.Currency, method.Token)
+	if err != nil || result.Status != bank.StatusSuccess {
+		if updateErr := h.service.UpdateStatus(r.Context(), id, "failed"); updateErr != nil {
+			log.Printf("Failed to update status: %v", updateErr)
+		}
+		intent.Status = "failed"
+		h.publishWebhook(r, domain.EventPaymentFailed, intent)
 		jsonutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "failed", "reason": "Bank declined"})
 		return
 	}
 
-	// Update Status
-	if err := h.service.UpdateStatus(r.Context(), id, "succeeded"); err != nil {
+	// Update Status, queueing the payment.succeeded event with it
+	if err := h.service.MarkSucceeded(r.Context(), intent, method.Type, result.TransactionID); err != nil {
 		infrastructure.PaymentRequests.WithLabelValues("confirm", "error").Inc()
 		// Critical: In real world, we need to handle state consistency here
 		jsonutil.WriteErrorJSON(w, "Failed to update payment status")
@@ -255,7 +342,55 @@ func (h *PaymentHandler) ConfirmPaymentIntent(w http.ResponseWriter, r *http.Req
 	}
 	infrastructure.PaymentRequests.WithLabelValues("confirm", "success").Inc()
 
-	// Publish Webhook Event to Redis (for CLI listen feature)
+	h.publishPaymentSucceeded(r, intent)
+	h.recordLedgerEntries(r, intent)
+	h.publishWebhook(r, domain.EventPaymentSucceeded, intent)
+
+	// NOTE: Notifications are now handled by the Notification Service
+	// which consumes payment.succeeded events from Kafka and routes to
+	// appropriate channels (email, SMS, webhook) via RabbitMQ workers.
+
+	jsonutil.WriteJSON(w, http.StatusOK, intent)
+}
+
+// declinedByFraudCheck asks the fraud service whether to go ahead with the
+// payment. Payments sent for review go ahead; the fraud service alerts on
+// them. A check that fails or times out approves the payment.
+func (h *PaymentHandler) declinedByFraudCheck(r *http.Request, intent *domain.PaymentIntent, method *domain.PaymentMethod) bool {
+	if h.fraud == nil {
+		return false
+	}
+	start := time.Now()
+	result, err := h.fraud.CheckPayment(r.Context(), fraud.CheckRequest{
+		PaymentID: intent.ID,
+		ZoneID:    intent.ZoneID,
+		Mode:      intent.Mode,
+		UserID:    intent.UserID,
+		Amount:    intent.Amount,
+		Currency:  intent.Currency,
+
+		PaymentMethodID: method.ID,
+		Metadata:        intent.Metadata,
+	})
+	elapsed := time.Since(start)
+	infrastructure.FraudCheckLatency.Observe(elapsed.Seconds())
+	infrastructure.FraudChecks.WithLabelValues(string(result.Decision), strconv.FormatBool(result.Fallback)).Inc()
+
+	if err != nil {
+		log.Printf("Fraud check of %s failed after %s, approving: %v", intent.ID, elapsed, err)
+		return false
+	}
+	log.Printf("Fraud check of %s: %s, score %d in %s", intent.ID, result.Decision, result.Score, elapsed)
+	return result.Decision == fraud.DecisionDecline
+}
+
+// publishPaymentSucceeded announces a collected payment on Redis, for the
+// CLI listen feature. The Kafka event, the source of truth, is published
+// from the outbox.
+func (h *PaymentHandler) publishPaymentSucceeded(r *http.Request, intent *domain.PaymentIntent) {
+	if h.rdb == nil {
+		return
+	}
 	event := map[string]interface{}{
 		"type":    "payment.succeeded",
 		"zone_id": intent.ZoneID,
@@ -264,41 +399,26 @@ func (h *PaymentHandler) ConfirmPaymentIntent(w http.ResponseWriter, r *http.Req
 	}
 	eventBody, _ := json.Marshal(event)
 	h.rdb.Publish(r.Context(), "webhook_events", eventBody)
+}
 
-	// Publish structured event to Kafka (source of truth)
-	// The Notification Service will consume this and route to appropriate channels
-	kafkaEvent := map[string]interface{}{
-		"id":        "evt_" + intent.ID,
-		"type":      "payment.succeeded",
-		"timestamp": intent.CreatedAt,
-		"zone_id":   intent.ZoneID,
-		"mode":      intent.Mode,
-		"data": map[string]interface{}{
-			"payment_id":  intent.ID,
-			"user_id":     intent.UserID,
-			"amount":      intent.Amount,
-			"currency":    intent.Currency,
-			"description": intent.Description,
-			"status":      "succeeded",
-		},
-	}
-	kafkaEventBody, _ := json.Marshal(kafkaEvent)
-	if err := h.kafkaProducer.Publish(r.Context(), intent.ID, kafkaEventBody); err != nil {
-		log.Printf("Failed to publish event to Kafka: %v", err)
-		// We still proceed, but Kafka failure should be alerted in production
+// recordLedgerEntries records the captured amount of a payment in the
+// Ledger via gRPC, in the merchant's settlement currency
+func (h *PaymentHandler) recordLedgerEntries(r *http.Request, intent *domain.PaymentIntent) {
+	if h.ledgerClient == nil {
+		return
 	}
+	amount, fee, settlementCurrency := h.settlementAmounts(r.Context(), intent)
 
-	// Record in Ledger via gRPC
 	// If it's a split payment, record multiple entries
 	if intent.ApplicationFeeAmount > 0 && intent.OnBehalfOf != "" {
-		netAmount := intent.Amount - intent.ApplicationFeeAmount
+		netAmount := amount - fee
 
 		// 1. Credit Connected Account (Net)
-		_, err = h.ledgerClient.RecordTransaction(r.Context(), &pb.RecordTransactionRequest{
+		_, err := h.ledgerClient.RecordTransaction(r.Context(), &pb.RecordTransactionRequest{
 			AccountId:   "acc_" + intent.OnBehalfOf,
 			Amount:      netAmount,
-			Currency:    intent.Currency,
-			Description: "Payout for " + intent.ID,
+			Currency:    settlementCurrency,
+			Description: intent.LedgerDescription("Payout for " + intent.ID),
 			ReferenceId: intent.ID,
 			ZoneId:      intent.ZoneID,
 			Mode:        intent.Mode,
@@ -310,9 +430,9 @@ func (h *PaymentHandler) ConfirmPaymentIntent(w http.ResponseWriter, r *http.Req
 		// 2. Credit Platform Account (Fee)
 		_, err = h.ledgerClient.RecordTransaction(r.Context(), &pb.RecordTransactionRequest{
 			AccountId:   "platform_main",
-			Amount:      intent.ApplicationFeeAmount,
-			Currency:    intent.Currency,
-			Description: "Fee for " + intent.ID,
+			Amount:      fee,
+			Currency:    settlementCurrency,
+			Description: intent.LedgerDescription("Fee for " + intent.ID),
 			ReferenceId: intent.ID,
 			ZoneId:      intent.ZoneID,
 			Mode:        intent.Mode,
@@ -324,9 +444,9 @@ func (h *PaymentHandler) ConfirmPaymentIntent(w http.ResponseWriter, r *http.Req
 		// 3. Debit Customer (Total)
 		_, err = h.ledgerClient.RecordTransaction(r.Context(), &pb.RecordTransactionRequest{
 			AccountId:   "user_" + intent.UserID,
-			Amount:      -intent.Amount,
-			Currency:    intent.Currency,
-			Description: "Payment " + intent.ID,
+			Amount:      -amount,
+			Currency:    settlementCurrency,
+			Description: intent.LedgerDescription("Payment " + intent.ID),
 			ReferenceId: intent.ID,
 			ZoneId:      intent.ZoneID,
 			Mode:        intent.Mode,
@@ -336,13 +456,13 @@ func (h *PaymentHandler) ConfirmPaymentIntent(w http.ResponseWriter, r *http.Req
 		}
 	} else {
 		// Standard Payment
-		_, err = h.ledgerClient.RecordTransaction(r.Context(), &pb.RecordTransactionRequest{
+		_, err := h.ledgerClient.RecordTransaction(r.Context(), &pb.RecordTransactionRequest{
 			AccountId: "user_" + intent.UserID, // This implementation seems to credit user?
 			// Looking at original code: amount was positive. Usually payments DEBIT user.
 			// Let's stick to original behavior but wrap it.
-			Amount:      intent.Amount,
-			Currency:    intent.Currency,
-			Description: "Payment for intent " + intent.ID,
+			Amount:      amount,
+			Currency:    settlementCurrency,
+			Description: intent.LedgerDescription("Payment for intent " + intent.ID),
 			ReferenceId: intent.ID,
 			ZoneId:      intent.ZoneID,
 			Mode:        intent.Mode,
@@ -351,67 +471,93 @@ func (h *PaymentHandler) ConfirmPaymentIntent(w http.ResponseWriter, r *http.Req
 			log.Printf("Failed to record transaction in ledger: %v", err)
 		}
 	}
+}
 
-	intent.Status = "succeeded"
-
-	// NOTE: Notifications are now handled by the Notification Service
-	// which consumes payment.succeeded events from Kafka and routes to
-	// appropriate channels (email, SMS, webhook) via RabbitMQ workers.
+type RefundIntentRequest struct {
+	Amount int64  `json:"amount"` // In cents; omitted or zero refunds what is left
+	Reason string `json:"reason"`
+}
 
-	jsonutil.WriteJSON(w, http.StatusOK, intent)
+// RefundResponse is the refunded intent along with the refund just made
+type RefundResponse struct {
+	*domain.PaymentIntent
+	Refund *domain.Refund `json:"refund"`
 }
 
+// RefundPaymentIntent refunds part or all of a succeeded payment. Intents
+// can be refunded several times until their whole amount is returned.
 func (h *PaymentHandler) RefundPaymentIntent(w http.ResponseWriter, r *http.Request) {
 	timer := prometheus.NewTimer(infrastructure.PaymentLatency.WithLabelValues("refund"))
 	defer timer.ObserveDuration()
 
-	if r.Method != http.MethodPost {
-		jsonutil.WriteErrorJSON(w, "Method not allowed")
-		return
-	}
+	id := mux.Vars(r)["id"]
 
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 3 {
-		jsonutil.WriteErrorJSON(w, "Invalid path")
-		return
+	var req RefundIntentRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonutil.WriteErrorJSON(w, "Invalid request body")
+			return
+		}
 	}
-	id := pathParts[2]
 
 	intent, err := h.service.GetPaymentIntent(r.Context(), id)
 	if err != nil || intent == nil {
 		jsonutil.WriteErrorJSON(w, "Payment intent not found")
 		return
 	}
+	// Full refunds are checked for what is left to refund
+	amount := req.Amount
+	if amount == 0 {
+		amount = intent.AmountCaptured - intent.AmountRefunded
+	}
+	if !h.authorize(w, r, policy.ActionRefundCreate, map[string]interface{}{
+		"amount":            amount,
+		"currency":          intent.Currency,
+		"payment_intent_id": intent.ID,
+	}) {
+		return
+	}
+	adapter, err := h.refundAdapter(intent)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
 
-	if intent.Status != "succeeded" {
-		jsonutil.WriteErrorJSON(w, "Only succeeded payments can be refunded")
+	refund, intent, err := h.service.RefundPaymentIntent(r.Context(), intent, req.Amount, req.Reason)
+	if err != nil {
+		infrastructure.PaymentRequests.WithLabelValues("refund", "error").Inc()
+		switch err {
+		case domain.ErrNotRefundable, domain.ErrInvalidRefundAmount, domain.ErrRefundExceedsAmount:
+			jsonutil.WriteErrorJSON(w, err.Error())
+		default:
+			log.Printf("Failed to refund %s: %v", id, err)
+			jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create refund"})
+		}
 		return
 	}
 
-	// Update Status to refunded
-	if err := h.service.UpdateStatus(r.Context(), id, "refunded"); err != nil {
+	// Refund through the bank that collected the payment
+	result, err := adapter.Refund(r.Context(), intent.ProviderTransactionID, refund.Amount)
+	if err != nil || result.Status == bank.StatusFailed {
 		infrastructure.PaymentRequests.WithLabelValues("refund", "error").Inc()
-		jsonutil.WriteErrorJSON(w, "Failed to update refund status")
-		return
-	}
-
-	// Publish structured event to Kafka (Notification Service will consume this)
-	kafkaEvent := map[string]interface{}{
-		"id":        "evt_refund_" + intent.ID,
-		"type":      "refund.completed",
-		"timestamp": intent.CreatedAt,
-		"data": map[string]interface{}{
-			"refund_id":  "ref_" + intent.ID,
-			"payment_id": intent.ID,
-			"user_id":    intent.UserID,
-			"amount":     intent.Amount,
-			"currency":   intent.Currency,
-			"status":     "completed",
-		},
+		log.Printf("Bank refund %s of %s failed: %v", refund.ID, id, err)
+		// Release the reserved amount so the payment can be refunded again
+		if _, failErr := h.service.FailRefund(r.Context(), intent, refund); failErr != nil {
+			log.Printf("Failed to release refund %s: %v", refund.ID, failErr)
+		}
+		jsonutil.WriteJSON(w, http.StatusBadGateway, map[string]string{"error": "Bank refund failed"})
+		return
 	}
-	kafkaEventBody, _ := json.Marshal(kafkaEvent)
-	if err := h.kafkaProducer.Publish(r.Context(), intent.ID, kafkaEventBody); err != nil {
-		log.Printf("Failed to publish refund event to Kafka: %v", err)
+	if result.TransactionID != "" {
+		if err := h.service.SetProviderRefundID(r.Context(), refund, result.TransactionID); err != nil {
+			log.Printf("Failed to record bank refund of %s: %v", refund.ID, err)
+		}
+	}
+	// Refunds the bank has not settled yet are completed by the refund sync
+	if result.Status == bank.StatusSuccess {
+		if err := h.service.CompleteRefund(r.Context(), intent, refund); err != nil {
+			log.Printf("Failed to complete refund %s: %v", refund.ID, err)
+		}
 	}
 
 	// Audit Log
@@ -421,28 +567,122 @@ func (h *PaymentHandler) RefundPaymentIntent(w http.ResponseWriter, r *http.Requ
 		ResourceType: "payment_intent",
 		ResourceID:   intent.ID,
 		Metadata: map[string]interface{}{
-			"amount":   intent.Amount,
-			"currency": intent.Currency,
+			"refund_id":       refund.ID,
+			"amount":          refund.Amount,
+			"amount_refunded": intent.AmountRefunded,
+			"currency":        intent.Currency,
 		},
 	})
 
 	infrastructure.PaymentRequests.WithLabelValues("refund", "success").Inc()
-	intent.Status = "refunded"
-	jsonutil.WriteJSON(w, http.StatusOK, intent)
+	if refund.Status == "succeeded" {
+		h.publishWebhook(r, domain.EventPaymentRefunded, intent)
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, RefundResponse{PaymentIntent: intent, Refund: refund})
+}
+
+// ListRefunds returns the refunds of a payment intent, oldest first
+func (h *PaymentHandler) ListRefunds(w http.ResponseWriter, r *http.Request) {
+	refunds, err := h.service.ListRefunds(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("Failed to list refunds: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list refunds"})
+		return
+	}
+	if refunds == nil {
+		refunds = []domain.Refund{}
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, refunds)
 }
+
+// ListPaymentIntents lists the payment intents of the caller's zone newest
+// first. It filters by the user_id, status, created[gte] and created[lte]
+// query parameters and pages with limit and starting_after, the next_cursor
+// of the previous page. A zone query parameter must name the caller's zone.
 func (h *PaymentHandler) ListPaymentIntents(w http.ResponseWriter, r *http.Request) {
-	zoneID := r.URL.Query().Get("zone")
-	limitStr := r.URL.Query().Get("limit")
-	limit := 50
-	if limitStr != "" {
-		fmt.Sscanf(limitStr, "%d", &limit)
+	query := r.URL.Query()
+	zoneID := r.Header.Get("X-Zone-ID")
+	if zoneID == "" {
+		jsonutil.WriteErrorJSON(w, "A zone is required to list payment intents")
+		return
+	}
+	if requested := query.Get("zone"); requested != "" && requested != zoneID {
+		jsonutil.WriteJSON(w, http.StatusForbidden, map[string]string{"error": "No access to zone " + requested})
+		return
+	}
+	filter := domain.PaymentIntentFilter{
+		ZoneID:        zoneID,
+		UserID:        query.Get("user_id"),
+		Status:        query.Get("status"),
+		StartingAfter: query.Get("starting_after"),
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if _, err := fmt.Sscanf(limitStr, "%d", &filter.Limit); err != nil || filter.Limit <= 0 {
+			jsonutil.WriteErrorJSON(w, "limit must be a positive integer")
+			return
+		}
+	}
+	for param, dest := range map[string]**time.Time{"created[gte]": &filter.CreatedGte, "created[lte]": &filter.CreatedLte} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeParam(value)
+		if err != nil {
+			jsonutil.WriteErrorJSON(w, param+" must be an RFC 3339 time or a Unix timestamp")
+			return
+		}
+		*dest = &t
+	}
+	// metadata[key]=value selects intents carrying that pair
+	for param, values := range query {
+		key, ok := strings.CutPrefix(param, "metadata[")
+		if !ok || !strings.HasSuffix(key, "]") || len(values) == 0 {
+			continue
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = map[string]string{}
+		}
+		filter.Metadata[strings.TrimSuffix(key, "]")] = values[0]
 	}
 
-	intents, err := h.service.ListPaymentIntents(r.Context(), zoneID, limit)
+	page, err := h.service.ListPaymentIntents(r.Context(), filter)
 	if err != nil {
-		jsonutil.WriteErrorJSON(w, "Failed to list payment intents")
+		log.Printf("Failed to list payment intents: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list payment intents"})
 		return
 	}
 
-	jsonutil.WriteJSON(w, http.StatusOK, intents)
+	jsonutil.WriteJSON(w, http.StatusOK, page)
+}
+
+// GetPaymentIntent returns one payment intent of the caller's zone. Intents
+// of another zone are reported missing.
+func (h *PaymentHandler) GetPaymentIntent(w http.ResponseWriter, r *http.Request) {
+	zoneID := r.Header.Get("X-Zone-ID")
+	if zoneID == "" {
+		jsonutil.WriteErrorJSON(w, "A zone is required to get a payment intent")
+		return
+	}
+	intent, err := h.service.GetPaymentIntent(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("Failed to get payment intent: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get payment intent"})
+		return
+	}
+	if intent == nil || intent.ZoneID != zoneID {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Payment intent not found"})
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, intent)
+}
+
+// parseTimeParam accepts an RFC 3339 time or Unix seconds
+func parseTimeParam(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, value)
 }
@@ -2,12 +2,28 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/fraud"
 	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/payment/infrastructure"
+	"github.com/sapliy/fintech-ecosystem/internal/policy"
+	"github.com/sapliy/fintech-ecosystem/pkg/bank"
+	"github.com/sapliy/fintech-ecosystem/pkg/currency"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+	pb "github.com/sapliy/fintech-ecosystem/proto/ledger"
+	"google.golang.org/grpc"
 )
 
 func TestPaymentHandler_CreatePaymentIntent(t *testing.T) {
@@ -48,6 +64,43 @@ func TestPaymentHandler_CreatePaymentIntent(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   "Amount and Currency are required",
 		},
+		{
+			name:           "Unsupported Currency",
+			reqBody:        `{"amount":1000,"currency":"XYZ"}`,
+			headers:        map[string]string{"X-User-ID": "user_123"},
+			mockSetup:      func(m *domain.MockRepository) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "unsupported currency",
+		},
+		{
+			name:    "Metadata And Statement Descriptor",
+			reqBody: `{"amount":1000,"currency":"USD","statement_descriptor":"ACME STORE","metadata":{"order_id":"ord_1"}}`,
+			headers: map[string]string{"X-User-ID": "user_123"},
+			mockSetup: func(m *domain.MockRepository) {
+				m.CreatePaymentIntentFunc = func(ctx context.Context, intent *domain.PaymentIntent) error {
+					intent.ID = "pi_123"
+					return nil
+				}
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `"statement_descriptor":"ACME STORE","metadata":{"order_id":"ord_1"}`,
+		},
+		{
+			name:           "Invalid Statement Descriptor",
+			reqBody:        `{"amount":1000,"currency":"USD","statement_descriptor":"12345"}`,
+			headers:        map[string]string{"X-User-ID": "user_123"},
+			mockSetup:      func(m *domain.MockRepository) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid statement_descriptor",
+		},
+		{
+			name:           "Invalid Metadata Key",
+			reqBody:        `{"amount":1000,"currency":"USD","metadata":{"a[b]":"c"}}`,
+			headers:        map[string]string{"X-User-ID": "user_123"},
+			mockSetup:      func(m *domain.MockRepository) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid metadata",
+		},
 	}
 
 	for _, tt := range tests {
@@ -145,3 +198,1688 @@ func TestPaymentHandler_IdempotencyMiddleware(t *testing.T) {
 		})
 	}
 }
+
+// memoryWebhooks is an in-memory domain.WebhookRepository
+type memoryWebhooks struct {
+	mu         sync.Mutex
+	endpoints  []*domain.WebhookEndpoint
+	deliveries []*domain.WebhookDelivery
+}
+
+func (m *memoryWebhooks) CreateWebhookEndpoint(ctx context.Context, e *domain.WebhookEndpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.ID = fmt.Sprintf("we_%d", len(m.endpoints)+1)
+	e.CreatedAt = time.Now()
+	stored := *e
+	m.endpoints = append(m.endpoints, &stored)
+	return nil
+}
+
+func (m *memoryWebhooks) GetWebhookEndpoint(ctx context.Context, id string) (*domain.WebhookEndpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.endpoints {
+		if e.ID == id {
+			found := *e
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryWebhooks) ListWebhookEndpoints(ctx context.Context, userID string) ([]*domain.WebhookEndpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var endpoints []*domain.WebhookEndpoint
+	for i := len(m.endpoints) - 1; i >= 0; i-- {
+		if m.endpoints[i].UserID == userID {
+			found := *m.endpoints[i]
+			endpoints = append(endpoints, &found)
+		}
+	}
+	return endpoints, nil
+}
+
+func (m *memoryWebhooks) DeleteWebhookEndpoint(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, e := range m.endpoints {
+		if e.ID == id {
+			m.endpoints = append(m.endpoints[:i], m.endpoints[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *memoryWebhooks) CreateWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d.ID = fmt.Sprintf("wd_%d", len(m.deliveries)+1)
+	stored := *d
+	m.deliveries = append(m.deliveries, &stored)
+	return nil
+}
+
+func (m *memoryWebhooks) UpdateWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, stored := range m.deliveries {
+		if stored.ID == d.ID {
+			updated := *d
+			m.deliveries[i] = &updated
+		}
+	}
+	return nil
+}
+
+func (m *memoryWebhooks) ListWebhookDeliveries(ctx context.Context, endpointID string, limit int) ([]*domain.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deliveries []*domain.WebhookDelivery
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if m.deliveries[i].EndpointID == endpointID {
+			found := *m.deliveries[i]
+			deliveries = append(deliveries, &found)
+		}
+	}
+	return deliveries, nil
+}
+
+func (m *memoryWebhooks) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*domain.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []*domain.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.Status == domain.WebhookDeliveryPending && d.NextAttemptAt != nil && !d.NextAttemptAt.After(now) {
+			found := *d
+			due = append(due, &found)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(*due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (m *memoryWebhooks) ClaimWebhookDelivery(ctx context.Context, id string, dueAt, lease time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.deliveries {
+		if d.ID == id && d.Status == domain.WebhookDeliveryPending && d.NextAttemptAt != nil && d.NextAttemptAt.Equal(dueAt) {
+			d.NextAttemptAt = &lease
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestPaymentHandler_Webhooks(t *testing.T) {
+	var mu sync.Mutex
+	var received []*http.Request
+	var bodies [][]byte
+	merchant := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r)
+		bodies = append(bodies, body)
+		if len(received) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer merchant.Close()
+
+	store := &memoryWebhooks{}
+	webhooks := domain.NewWebhookService(store, domain.WebhookConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, Timeout: time.Second})
+	h := &PaymentHandler{webhooks: webhooks}
+
+	// Register an endpoint for succeeded payments
+	req := httptest.NewRequest("POST", "/webhooks", strings.NewReader(fmt.Sprintf(`{"url":%q,"events":["payment.succeeded"]}`, merchant.URL)))
+	req.Header.Set("X-User-ID", "user_1")
+	w := httptest.NewRecorder()
+	h.CreateWebhookEndpoint(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var endpoint domain.WebhookEndpoint
+	json.NewDecoder(w.Body).Decode(&endpoint)
+	if !strings.HasPrefix(endpoint.Secret, "whsec_") {
+		t.Fatalf("Expected a signing secret, got %q", endpoint.Secret)
+	}
+
+	req = httptest.NewRequest("POST", "/webhooks", strings.NewReader(`{"url":"ftp://example.com","events":["payment.succeeded"]}`))
+	req.Header.Set("X-User-ID", "user_1")
+	w = httptest.NewRecorder()
+	h.CreateWebhookEndpoint(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a non-HTTP URL, got %d", w.Code)
+	}
+
+	// Only subscribed events of the endpoint's owner are delivered
+	intent := &domain.PaymentIntent{ID: "pi_1", UserID: "user_1", Amount: 1000, Currency: "USD", Status: "succeeded"}
+	webhooks.Publish(context.Background(), domain.EventPaymentFailed, intent)
+	webhooks.Publish(context.Background(), domain.EventPaymentSucceeded, &domain.PaymentIntent{ID: "pi_2", UserID: "user_2"})
+	webhooks.Publish(context.Background(), domain.EventPaymentSucceeded, intent)
+	webhooks.Wait()
+
+	// The first attempt fails and is retried after the backoff
+	time.Sleep(5 * time.Millisecond)
+	webhooks.RetryDue(context.Background())
+	webhooks.Wait()
+
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(received))
+	}
+	for i, r := range received {
+		if err := domain.VerifyWebhookPayload(endpoint.Secret, r.Header.Get("X-Sapliy-Timestamp"), r.Header.Get("X-Sapliy-Signature-V2"), bodies[i], time.Now()); err != nil {
+			t.Errorf("Attempt %d: expected a valid signature, got %v", i+1, err)
+		}
+		if r.Header.Get("X-Sapliy-Signature") != "" {
+			t.Errorf("Attempt %d: expected no signature of the body alone", i+1)
+		}
+		if r.Header.Get("X-Sapliy-Event-Type") != domain.EventPaymentSucceeded {
+			t.Errorf("Attempt %d: unexpected event type %s", i+1, r.Header.Get("X-Sapliy-Event-Type"))
+		}
+	}
+
+	// Captured deliveries cannot be replayed later or re-stamped
+	timestamp, signature := received[1].Header.Get("X-Sapliy-Timestamp"), received[1].Header.Get("X-Sapliy-Signature-V2")
+	if err := domain.VerifyWebhookPayload(endpoint.Secret, timestamp, signature, bodies[1], time.Now().Add(10*time.Minute)); err == nil {
+		t.Error("Expected a replayed delivery to be rejected")
+	}
+	if err := domain.VerifyWebhookPayload(endpoint.Secret, fmt.Sprint(time.Now().Unix()+1), signature, bodies[1], time.Now()); err == nil {
+		t.Error("Expected a re-stamped delivery to be rejected")
+	}
+
+	// The delivery log shows the outcome
+	list := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/webhooks/"+endpoint.ID+"/deliveries", nil)
+		req.Header.Set("X-User-ID", userID)
+		w := httptest.NewRecorder()
+		setupRoutes(h).ServeHTTP(w, req)
+		return w
+	}
+	w = list("user_1")
+	var deliveries []domain.WebhookDelivery
+	json.NewDecoder(w.Body).Decode(&deliveries)
+	if len(deliveries) != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", len(deliveries))
+	}
+	if d := deliveries[0]; d.Status != domain.WebhookDeliverySucceeded || d.Attempts != 2 || d.LastStatusCode != http.StatusOK {
+		t.Errorf("Unexpected delivery: %+v", d)
+	}
+	if w := list("user_2"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected another user's endpoint to be hidden, got %d", w.Code)
+	}
+
+	// Secrets are not listed
+	req = httptest.NewRequest("GET", "/webhooks", nil)
+	req.Header.Set("X-User-ID", "user_1")
+	w = httptest.NewRecorder()
+	h.ListWebhookEndpoints(w, req)
+	if strings.Contains(w.Body.String(), endpoint.Secret) {
+		t.Errorf("Expected the secret to be left out of the list: %s", w.Body.String())
+	}
+}
+
+// staleDue lists the deliveries it was given, like an instance that listed
+// them before another one claimed them
+type staleDue struct {
+	*memoryWebhooks
+	due []*domain.WebhookDelivery
+}
+
+func (s *staleDue) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*domain.WebhookDelivery, error) {
+	return s.due, nil
+}
+
+func TestPaymentHandler_WebhookRetriesClaimedOnce(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	merchant := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+	}))
+	defer merchant.Close()
+
+	store := &memoryWebhooks{}
+	endpoint := &domain.WebhookEndpoint{UserID: "user_1", URL: merchant.URL, Secret: "whsec_test", Enabled: true}
+	store.CreateWebhookEndpoint(context.Background(), endpoint)
+	due := time.Now().Add(-time.Minute)
+	store.CreateWebhookDelivery(context.Background(), &domain.WebhookDelivery{
+		EndpointID: endpoint.ID, EventID: "evt_1", EventType: domain.EventPaymentSucceeded,
+		Payload: []byte(`{}`), Status: domain.WebhookDeliveryPending, NextAttemptAt: &due,
+	})
+	listed, _ := store.ListDueWebhookDeliveries(context.Background(), time.Now(), 10)
+
+	config := domain.WebhookConfig{Timeout: time.Second}
+	first := domain.NewWebhookService(store, config)
+	second := domain.NewWebhookService(&staleDue{memoryWebhooks: store, due: listed}, config)
+	first.RetryDue(context.Background())
+	second.RetryDue(context.Background())
+	first.Wait()
+	second.Wait()
+
+	if attempts != 1 {
+		t.Errorf("Expected the due delivery to be attempted once, got %d", attempts)
+	}
+}
+
+func TestPaymentHandler_ListPaymentIntents(t *testing.T) {
+	intents := []domain.PaymentIntent{{ID: "pi_3"}, {ID: "pi_2"}, {ID: "pi_1"}}
+	var got domain.PaymentIntentFilter
+	mRepo := &domain.MockRepository{
+		ListPaymentIntentsFunc: func(ctx context.Context, filter domain.PaymentIntentFilter) ([]domain.PaymentIntent, error) {
+			got = filter
+			if filter.Limit < len(intents) {
+				return intents[:filter.Limit], nil
+			}
+			return intents, nil
+		},
+	}
+	h := &PaymentHandler{service: domain.NewPaymentService(mRepo)}
+
+	req := httptest.NewRequest("GET", "/intents?user_id=user_1&status=succeeded&created[gte]=1700000000&limit=2&starting_after=pi_4&metadata[order_id]=ord_1", nil)
+	req.Header.Set("X-Zone-ID", "zone_1")
+	w := httptest.NewRecorder()
+	h.ListPaymentIntents(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got.ZoneID != "zone_1" || got.UserID != "user_1" || got.Status != "succeeded" || got.StartingAfter != "pi_4" {
+		t.Errorf("Unexpected filter: %+v", got)
+	}
+	if got.CreatedGte == nil || got.CreatedGte.Unix() != 1700000000 || got.CreatedLte != nil {
+		t.Errorf("Unexpected created range: %v - %v", got.CreatedGte, got.CreatedLte)
+	}
+	if len(got.Metadata) != 1 || got.Metadata["order_id"] != "ord_1" {
+		t.Errorf("Unexpected metadata filter: %v", got.Metadata)
+	}
+	var page domain.PaymentIntentPage
+	json.NewDecoder(w.Body).Decode(&page)
+	if len(page.Data) != 2 || !page.HasMore || page.NextCursor != "pi_2" {
+		t.Errorf("Unexpected page: %+v", page)
+	}
+
+	list := func(path, zone string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-User-ID", "user_1")
+		if zone != "" {
+			req.Header.Set("X-Zone-ID", zone)
+		}
+		w := httptest.NewRecorder()
+		setupRoutes(h).ServeHTTP(w, req)
+		return w
+	}
+
+	w = list("/intents?zone=zone_1", "zone_1")
+	var last domain.PaymentIntentPage
+	json.NewDecoder(w.Body).Decode(&last)
+	if len(last.Data) != 3 || last.HasMore || last.NextCursor != "" {
+		t.Errorf("Expected the last page, got %+v", last)
+	}
+
+	if w := list("/intents?created[lte]=yesterday", "zone_1"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid time, got %d", w.Code)
+	}
+
+	// Callers only list their own zone
+	got = domain.PaymentIntentFilter{}
+	if w := list("/intents?zone=zone_2", "zone_1"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for another zone, got %d", w.Code)
+	}
+	if w := list("/intents?zone=zone_2", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without the caller's zone, got %d", w.Code)
+	}
+	if got.ZoneID != "" {
+		t.Errorf("Expected no intents listed, got a query of %q", got.ZoneID)
+	}
+
+	w = httptest.NewRecorder()
+	setupRoutes(h).ServeHTTP(w, httptest.NewRequest("GET", "/intents", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a user, got %d", w.Code)
+	}
+}
+
+func TestPaymentHandler_GetPaymentIntent(t *testing.T) {
+	mRepo := &domain.MockRepository{
+		GetPaymentIntentFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+			if id != "pi_1" {
+				return nil, nil
+			}
+			return &domain.PaymentIntent{ID: "pi_1", ZoneID: "zone_1", Amount: 1000}, nil
+		},
+	}
+	h := &PaymentHandler{service: domain.NewPaymentService(mRepo)}
+
+	tests := []struct {
+		path           string
+		zone           string
+		expectedStatus int
+	}{
+		{"/intents/pi_1", "zone_1", http.StatusOK},
+		{"/intents/pi_1", "", http.StatusBadRequest},
+		{"/intents/pi_1", "zone_2", http.StatusNotFound},
+		{"/intents/pi_missing", "zone_1", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.zone != "" {
+			req.Header.Set("X-Zone-ID", tt.zone)
+		}
+		w := httptest.NewRecorder()
+		setupRoutes(h).ServeHTTP(w, req)
+		if w.Code != tt.expectedStatus {
+			t.Errorf("%s in zone %q: expected status %d, got %d", tt.path, tt.zone, tt.expectedStatus, w.Code)
+		}
+	}
+}
+
+func TestPaymentHandler_PartialRefunds(t *testing.T) {
+	intent := domain.PaymentIntent{ID: "pi_1", Amount: 1000, AmountCaptured: 1000, Currency: "USD", Status: "succeeded", UserID: "user_1",
+		PaymentMethodType: domain.PaymentMethodWallet, ProviderTransactionID: "txn_1"}
+	var refunds []domain.Refund
+	var events []domain.OutboxEvent
+	mRepo := &domain.MockRepository{
+		GetPaymentIntentFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+			found := intent
+			return &found, nil
+		},
+		BeginTxFunc: outboxTx(domain.MockTransactionContext{
+			GetPaymentIntentForUpdateFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+				found := intent
+				return &found, nil
+			},
+			CreateRefundFunc: func(ctx context.Context, refund *domain.Refund) error {
+				refund.ID = fmt.Sprintf("re_%d", len(refunds)+1)
+				refunds = append(refunds, *refund)
+				return nil
+			},
+			UpdateAmountRefundedFunc: func(ctx context.Context, id string, amountRefunded int64, status string) error {
+				intent.AmountRefunded = amountRefunded
+				intent.Status = status
+				return nil
+			},
+			UpdateRefundStatusFunc: func(ctx context.Context, id, status string) error {
+				return nil
+			},
+		}, &events),
+		SetProviderRefundIDFunc: func(ctx context.Context, id, providerRefundID string) error {
+			return nil
+		},
+	}
+	wallets := &fakeBank{}
+	banks := bank.NewRouter()
+	banks.Register(domain.PaymentMethodCard, &fakeBank{})
+	banks.Register(domain.PaymentMethodWallet, wallets)
+	h := &PaymentHandler{service: domain.NewPaymentService(mRepo), banks: banks}
+
+	tests := []struct {
+		name             string
+		reqBody          string
+		expectedStatus   int
+		expectedBody     string
+		expectedRefunded int64
+	}{
+		{"Partial refund", `{"amount":300,"reason":"damaged"}`, http.StatusOK, `"status":"partially_refunded"`, 300},
+		{"Over-refund", `{"amount":800}`, http.StatusBadRequest, "exceeds", 300},
+		{"Negative amount", `{"amount":-5}`, http.StatusBadRequest, "must be positive", 300},
+		{"Refund the rest", ``, http.StatusOK, `"amount_refunded":1000`, 1000},
+		{"Fully refunded", `{"amount":1}`, http.StatusBadRequest, "only succeeded payments", 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/intents/pi_1/refund", strings.NewReader(tt.reqBody))
+			req.Header.Set("X-User-ID", "user_1")
+			w := httptest.NewRecorder()
+			setupRoutes(h).ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain '%s', got '%s'", tt.expectedBody, w.Body.String())
+			}
+			if intent.AmountRefunded != tt.expectedRefunded {
+				t.Errorf("Expected %d refunded, got %d", tt.expectedRefunded, intent.AmountRefunded)
+			}
+		})
+	}
+
+	if len(refunds) != 2 || refunds[0].Amount != 300 || refunds[1].Amount != 700 || refunds[0].Reason != "damaged" {
+		t.Errorf("Unexpected refunds: %+v", refunds)
+	}
+	if strings.Join(wallets.refunded, ",") != "txn_1:300,txn_1:700" {
+		t.Errorf("Expected the refunds made against the wallet payment, got %v", wallets.refunded)
+	}
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	if strings.Join(types, ",") != "refund.initiated,refund.completed,refund.initiated,refund.completed" {
+		t.Errorf("Unexpected outbox events: %v", types)
+	}
+}
+
+// bankRefunds is a refundable card payment whose refunds the card bank
+// settles with refundStatus
+type bankRefunds struct {
+	h       *PaymentHandler
+	intent  *domain.PaymentIntent
+	refunds map[string]*domain.Refund
+	events  *[]domain.OutboxEvent
+	cards   *fakeBank
+}
+
+func newBankRefunds(refundStatus bank.Status) *bankRefunds {
+	f := &bankRefunds{
+		intent: &domain.PaymentIntent{ID: "pi_1", Amount: 1000, AmountCaptured: 1000, Currency: "USD", Status: "succeeded", UserID: "user_1",
+			PaymentMethodType: domain.PaymentMethodCard, ProviderTransactionID: "txn_1"},
+		refunds: map[string]*domain.Refund{},
+		events:  &[]domain.OutboxEvent{},
+		cards:   &fakeBank{refundStatus: refundStatus},
+	}
+	mRepo := &domain.MockRepository{
+		GetPaymentIntentFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+			found := *f.intent
+			return &found, nil
+		},
+		BeginTxFunc: outboxTx(domain.MockTransactionContext{
+			GetPaymentIntentForUpdateFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+				found := *f.intent
+				return &found, nil
+			},
+			CreateRefundFunc: func(ctx context.Context, refund *domain.Refund) error {
+				refund.ID = fmt.Sprintf("re_%d", len(f.refunds)+1)
+				stored := *refund
+				f.refunds[refund.ID] = &stored
+				return nil
+			},
+			UpdateAmountRefundedFunc: func(ctx context.Context, id string, amountRefunded int64, status string) error {
+				f.intent.AmountRefunded = amountRefunded
+				f.intent.Status = status
+				return nil
+			},
+			UpdateRefundStatusFunc: func(ctx context.Context, id, status string) error {
+				f.refunds[id].Status = status
+				return nil
+			},
+		}, f.events),
+		SetProviderRefundIDFunc: func(ctx context.Context, id, providerRefundID string) error {
+			f.refunds[id].ProviderRefundID = providerRefundID
+			return nil
+		},
+		ListPendingRefundsFunc: func(ctx context.Context, limit int) ([]domain.Refund, error) {
+			var pending []domain.Refund
+			for _, refund := range f.refunds {
+				if refund.Status == "pending" && refund.ProviderRefundID != "" {
+					pending = append(pending, *refund)
+				}
+			}
+			return pending, nil
+		},
+	}
+	banks := bank.NewRouter()
+	banks.Register(domain.PaymentMethodCard, f.cards)
+	f.h = &PaymentHandler{service: domain.NewPaymentService(mRepo), banks: banks}
+	return f
+}
+
+func (f *bankRefunds) refund(body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/intents/pi_1/refund", strings.NewReader(body))
+	req.Header.Set("X-User-ID", "user_1")
+	w := httptest.NewRecorder()
+	setupRoutes(f.h).ServeHTTP(w, req)
+	return w
+}
+
+func (f *bankRefunds) eventTypes() string {
+	var types []string
+	for _, e := range *f.events {
+		types = append(types, e.Type)
+	}
+	return strings.Join(types, ",")
+}
+
+func TestPaymentHandler_PendingRefunds(t *testing.T) {
+	f := newBankRefunds(bank.StatusPending)
+
+	w := f.refund(`{"amount":400}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"pending"`) {
+		t.Fatalf("Expected a pending refund, got %d: %s", w.Code, w.Body.String())
+	}
+	if f.refunds["re_1"].ProviderRefundID != "re_bank_1" || f.eventTypes() != "refund.initiated" {
+		t.Fatalf("Expected the bank refund recorded and only refund.initiated queued, got %+v and %s", f.refunds["re_1"], f.eventTypes())
+	}
+	if f.intent.AmountRefunded != 400 {
+		t.Errorf("Expected the pending amount reserved, got %d", f.intent.AmountRefunded)
+	}
+
+	// The sync completes the refund once the bank reports it settled
+	f.h.SyncPendingRefunds(context.Background())
+	if f.refunds["re_1"].Status != "succeeded" || f.eventTypes() != "refund.initiated,refund.completed" {
+		t.Errorf("Expected the refund completed by the sync, got %s with %s", f.refunds["re_1"].Status, f.eventTypes())
+	}
+}
+
+// rejectingBank reports every transaction failed
+type rejectingBank struct{ fakeBank }
+
+func (b *rejectingBank) GetStatus(ctx context.Context, transactionID string) (*bank.TransactionResult, error) {
+	return &bank.TransactionResult{TransactionID: transactionID, Status: bank.StatusFailed}, nil
+}
+
+func TestPaymentHandler_FailedRefunds(t *testing.T) {
+	t.Run("Rejected by the bank", func(t *testing.T) {
+		f := newBankRefunds(bank.StatusFailed)
+
+		w := f.refund(`{"amount":400}`)
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("Expected status 502, got %d: %s", w.Code, w.Body.String())
+		}
+		if f.refunds["re_1"].Status != "failed" || f.intent.AmountRefunded != 0 || f.intent.Status != "succeeded" {
+			t.Errorf("Expected the refund failed and its amount released, got %s with %d refunded, intent %s",
+				f.refunds["re_1"].Status, f.intent.AmountRefunded, f.intent.Status)
+		}
+		if f.eventTypes() != "refund.initiated,refund.failed" {
+			t.Errorf("Expected refund.initiated and refund.failed, got %s", f.eventTypes())
+		}
+
+		// The released amount can be refunded again
+		f.cards.refundStatus = bank.StatusSuccess
+		if w := f.refund(``); w.Code != http.StatusOK || f.intent.AmountRefunded != 1000 {
+			t.Errorf("Expected the whole payment refunded, got %d with %d refunded: %s", w.Code, f.intent.AmountRefunded, w.Body.String())
+		}
+	})
+
+	t.Run("Rejected after a partial refund", func(t *testing.T) {
+		f := newBankRefunds(bank.StatusSuccess)
+		f.refund(`{"amount":300}`)
+		f.cards.refundStatus = bank.StatusFailed
+		f.refund(`{"amount":200}`)
+		if f.intent.AmountRefunded != 300 || f.intent.Status != "partially_refunded" {
+			t.Errorf("Expected only the first refund kept, got %d refunded, intent %s", f.intent.AmountRefunded, f.intent.Status)
+		}
+	})
+
+	t.Run("Rejected while pending", func(t *testing.T) {
+		f := newBankRefunds(bank.StatusPending)
+		rejecting := &rejectingBank{fakeBank{refundStatus: bank.StatusPending}}
+		f.h.banks.Register(domain.PaymentMethodCard, rejecting)
+
+		if w := f.refund(`{"amount":400}`); w.Code != http.StatusOK {
+			t.Fatalf("Expected a pending refund, got %d: %s", w.Code, w.Body.String())
+		}
+		f.h.SyncPendingRefunds(context.Background())
+		if f.refunds["re_1"].Status != "failed" || f.intent.AmountRefunded != 0 {
+			t.Errorf("Expected the sync to fail the refund and release it, got %s with %d refunded", f.refunds["re_1"].Status, f.intent.AmountRefunded)
+		}
+	})
+}
+
+// outboxTx begins mock transactions making the given changes, adding the
+// outbox events they queue to events when committed
+func outboxTx(changes domain.MockTransactionContext, events *[]domain.OutboxEvent) func(ctx context.Context) (domain.TransactionContext, error) {
+	return func(ctx context.Context) (domain.TransactionContext, error) {
+		var pending []domain.OutboxEvent
+		txCtx := changes
+		txCtx.CreateOutboxEventFunc = func(ctx context.Context, eventType, aggregateID string, payload []byte) error {
+			pending = append(pending, domain.OutboxEvent{
+				ID:          fmt.Sprintf("evt_%d", len(*events)+len(pending)+1),
+				Type:        eventType,
+				AggregateID: aggregateID,
+				Payload:     payload,
+				CreatedAt:   time.Now(),
+			})
+			return nil
+		}
+		txCtx.CommitFunc = func() error {
+			*events = append(*events, pending...)
+			pending = nil
+			return nil
+		}
+		txCtx.RollbackFunc = func() error {
+			pending = nil
+			return nil
+		}
+		return &txCtx, nil
+	}
+}
+
+// fakeBank approves every charge and hold and records charges, captures,
+// voids and refunds. Refunds end in refundStatus, succeeded if unset.
+type fakeBank struct {
+	name         string
+	charged      []string
+	captured     map[string]int64
+	voided       []string
+	refunded     []string // Transaction and amount, "txn_1:300"
+	refundStatus bank.Status
+}
+
+func (b *fakeBank) Tokenize(ctx context.Context, details bank.PaymentDetails) (string, error) {
+	return "tok_" + b.name + "_" + details.Type, nil
+}
+
+func (b *fakeBank) Charge(ctx context.Context, amount int64, currency, cardToken string) (*bank.TransactionResult, error) {
+	b.charged = append(b.charged, cardToken)
+	return &bank.TransactionResult{TransactionID: "txn_1", Status: bank.StatusSuccess}, nil
+}
+
+func (b *fakeBank) Authorize(ctx context.Context, amount int64, currency, cardToken string) (*bank.TransactionResult, error) {
+	return &bank.TransactionResult{TransactionID: "auth_1", Status: bank.StatusSuccess}, nil
+}
+
+func (b *fakeBank) Capture(ctx context.Context, authorizationID string, amount int64) (*bank.TransactionResult, error) {
+	b.captured[authorizationID] = amount
+	return &bank.TransactionResult{TransactionID: "txn_2", Status: bank.StatusSuccess}, nil
+}
+
+func (b *fakeBank) Void(ctx context.Context, authorizationID string) error {
+	b.voided = append(b.voided, authorizationID)
+	return nil
+}
+
+func (b *fakeBank) Refund(ctx context.Context, transactionID string, amount int64) (*bank.TransactionResult, error) {
+	b.refunded = append(b.refunded, fmt.Sprintf("%s:%d", transactionID, amount))
+	status := b.refundStatus
+	if status == "" {
+		status = bank.StatusSuccess
+	}
+	return &bank.TransactionResult{TransactionID: fmt.Sprintf("re_bank_%d", len(b.refunded)), Status: status}, nil
+}
+
+func (b *fakeBank) GetStatus(ctx context.Context, transactionID string) (*bank.TransactionResult, error) {
+	return &bank.TransactionResult{TransactionID: transactionID, Status: bank.StatusSuccess}, nil
+}
+
+func TestPaymentHandler_Policies(t *testing.T) {
+	intent := domain.PaymentIntent{ID: "pi_1", Amount: 1000, AmountCaptured: 1000, Currency: "USD", Status: "succeeded", UserID: "user_1",
+		PaymentMethodType: domain.PaymentMethodCard, ProviderTransactionID: "txn_1"}
+	var events []domain.OutboxEvent
+	mRepo := &domain.MockRepository{
+		CreatePaymentIntentFunc: func(ctx context.Context, created *domain.PaymentIntent) error {
+			created.ID = "pi_2"
+			return nil
+		},
+		GetPaymentIntentFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+			found := intent
+			return &found, nil
+		},
+		BeginTxFunc: outboxTx(domain.MockTransactionContext{
+			GetPaymentIntentForUpdateFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+				found := intent
+				return &found, nil
+			},
+			CreateRefundFunc: func(ctx context.Context, refund *domain.Refund) error {
+				refund.ID = "re_1"
+				return nil
+			},
+			UpdateAmountRefundedFunc: func(ctx context.Context, id string, amountRefunded int64, status string) error {
+				intent.AmountRefunded = amountRefunded
+				intent.Status = status
+				return nil
+			},
+			UpdateRefundStatusFunc: func(ctx context.Context, id, status string) error {
+				return nil
+			},
+		}, &events),
+		SetProviderRefundIDFunc: func(ctx context.Context, id, providerRefundID string) error {
+			return nil
+		},
+	}
+
+	// Finance refunds only under $5.00 in org_1
+	engine := policy.NewDatabasePolicyEngine(nil, policy.NewHardcodedPolicyEngine())
+	engine.Set([]policy.Policy{{
+		ID: "pol_1", OrgID: "org_1", Name: "Small refunds", Effect: policy.EffectAllow, Enabled: true,
+		Roles:      []policy.Role{policy.RoleFinance},
+		Actions:    []policy.Action{policy.ActionRefundCreate},
+		Conditions: []policy.Condition{{Attribute: "resource.amount", Operator: policy.OpLt, Value: 500.0}},
+	}}, "1")
+	banks := bank.NewRouter()
+	banks.Register(domain.PaymentMethodCard, &fakeBank{})
+	h := &PaymentHandler{service: domain.NewPaymentService(mRepo), banks: banks, policies: policy.NewPolicyMiddleware(engine)}
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		role           string
+		expectedStatus int
+		expectedAction policy.Action
+	}{
+		{"Member pays", "/intents", `{"amount":1000,"currency":"USD"}`, "member", http.StatusForbidden, policy.ActionPaymentCreate},
+		{"Finance pays", "/intents", `{"amount":1000,"currency":"USD"}`, "finance", http.StatusCreated, ""},
+		{"Owner pays", "/intents", `{"amount":1000,"currency":"USD"}`, "owner", http.StatusCreated, ""},
+		{"No organization pays", "/intents", `{"amount":1000,"currency":"USD"}`, "", http.StatusForbidden, policy.ActionPaymentCreate},
+		{"No organization refunds", "/intents/pi_1/refund", `{"amount":100}`, "", http.StatusForbidden, policy.ActionRefundCreate},
+		{"Finance refunds under the limit", "/intents/pi_1/refund", `{"amount":300}`, "finance", http.StatusOK, ""},
+		{"Finance refunds the rest over the limit", "/intents/pi_1/refund", ``, "finance", http.StatusForbidden, policy.ActionRefundCreate},
+		{"Developer refunds", "/intents/pi_1/refund", `{"amount":100}`, "developer", http.StatusForbidden, policy.ActionRefundCreate},
+		{"Admin refunds the rest", "/intents/pi_1/refund", ``, "admin", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("X-User-ID", "user_1")
+			if tt.role != "" {
+				req.Header.Set("X-Org-ID", "org_1")
+				req.Header.Set("X-Role", tt.role)
+			}
+			w := httptest.NewRecorder()
+			setupRoutes(h).ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedAction == "" {
+				return
+			}
+			var denied policy.DeniedResponse
+			json.Unmarshal(w.Body.Bytes(), &denied)
+			if denied.Code != policy.ErrorCode || denied.Action != tt.expectedAction || denied.Reason == "" {
+				t.Errorf("Expected a policy_denied body for %s, got %s", tt.expectedAction, w.Body.String())
+			}
+		})
+	}
+
+	if intent.AmountRefunded != 1000 {
+		t.Errorf("Expected the payment fully refunded, got %d", intent.AmountRefunded)
+	}
+}
+
+func TestPaymentHandler_ManualCapture(t *testing.T) {
+	intents := map[string]*domain.PaymentIntent{
+		"pi_1": {ID: "pi_1", Amount: 1000, Currency: "USD", Status: "requires_payment_method", CaptureMethod: domain.CaptureManual},
+		"pi_2": {ID: "pi_2", Amount: 500, Currency: "USD", Status: "requires_payment_method", CaptureMethod: domain.CaptureManual},
+	}
+	mRepo := &domain.MockRepository{
+		GetPaymentIntentFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+			found := *intents[id]
+			return &found, nil
+		},
+		AuthorizePaymentIntentFunc: func(ctx context.Context, id, authorizationID string, expiresAt time.Time) error {
+			intents[id].Status = "requires_capture"
+			intents[id].AuthorizationID = authorizationID
+			intents[id].AuthorizationExpiresAt = &expiresAt
+			return nil
+		},
+		BeginTxFunc: outboxTx(domain.MockTransactionContext{
+			SetProviderTransactionFunc: func(ctx context.Context, id, methodType, transactionID string) error {
+				intents[id].PaymentMethodType = methodType
+				intents[id].ProviderTransactionID = transactionID
+				return nil
+			},
+			CapturePaymentIntentFunc: func(ctx context.Context, id string, amount int64) error {
+				if intents[id].Status != "requires_capture" {
+					return domain.ErrNotCapturable
+				}
+				intents[id].Status = "succeeded"
+				intents[id].AmountCaptured = amount
+				return nil
+			},
+		}, &[]domain.OutboxEvent{}),
+		ListExpiredAuthorizationsFunc: func(ctx context.Context, now time.Time, limit int) ([]domain.PaymentIntent, error) {
+			var expired []domain.PaymentIntent
+			for _, intent := range intents {
+				if intent.Status == "requires_capture" && !now.Before(*intent.AuthorizationExpiresAt) {
+					expired = append(expired, *intent)
+				}
+			}
+			return expired, nil
+		},
+		UpdateStatusFunc: func(ctx context.Context, id, status string) error {
+			intents[id].Status = status
+			return nil
+		},
+	}
+	fb := &fakeBank{captured: map[string]int64{}}
+	banks := bank.NewRouter()
+	banks.Register(domain.PaymentMethodCard, fb)
+	h := &PaymentHandler{service: domain.NewPaymentService(mRepo), banks: banks, authorizationTTL: time.Hour}
+
+	routes := setupRoutes(h)
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("X-User-ID", "user_1")
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/intents/pi_1/confirm", `{"payment_method_id":"tok_visa"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"requires_capture"`) {
+		t.Fatalf("Expected the payment to be authorized, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name           string
+		reqBody        string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Over-capture", `{"amount_to_capture":1500}`, http.StatusBadRequest, "exceeds"},
+		{"Partial capture", `{"amount_to_capture":600}`, http.StatusOK, `"amount_captured":600`},
+		{"Captured twice", `{"amount_to_capture":100}`, http.StatusBadRequest, "does not require capture"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post("/intents/pi_1/capture", tt.reqBody)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain '%s', got '%s'", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+	if fb.captured["auth_1"] != 600 {
+		t.Errorf("Expected the bank to capture 600, got %d", fb.captured["auth_1"])
+	}
+	if intents["pi_1"].PaymentMethodType != domain.PaymentMethodCard || intents["pi_1"].ProviderTransactionID != "txn_2" {
+		t.Errorf("Expected the card capture kept for refunds, got %s %s", intents["pi_1"].PaymentMethodType, intents["pi_1"].ProviderTransactionID)
+	}
+
+	// An expired hold can no longer be captured and is released
+	post("/intents/pi_2/confirm", `{"payment_method_id":"tok_visa"}`)
+	past := time.Now().Add(-time.Minute)
+	intents["pi_2"].AuthorizationExpiresAt = &past
+	if w := post("/intents/pi_2/capture", ``); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected expired capture to fail, got %d: %s", w.Code, w.Body.String())
+	}
+	h.ExpireAuthorizations(context.Background())
+	if intents["pi_2"].Status != "canceled" || len(fb.voided) != 1 {
+		t.Errorf("Expected the expired hold to be voided and canceled, got %s and voids %v", intents["pi_2"].Status, fb.voided)
+	}
+}
+
+func TestPaymentHandler_PaymentMethods(t *testing.T) {
+	methods := map[string]*domain.PaymentMethod{}
+	intent := &domain.PaymentIntent{ID: "pi_1", Amount: 1000, Currency: "USD", Status: "requires_payment_method", UserID: "user_1"}
+	mRepo := &domain.MockRepository{
+		CreatePaymentMethodFunc: func(ctx context.Context, method *domain.PaymentMethod) error {
+			method.ID = fmt.Sprintf("pm_%d", len(methods)+1)
+			methods[method.ID] = method
+			return nil
+		},
+		GetPaymentMethodFunc: func(ctx context.Context, id string) (*domain.PaymentMethod, error) {
+			return methods[id], nil
+		},
+		GetPaymentIntentFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+			found := *intent
+			return &found, nil
+		},
+		BeginTxFunc: outboxTx(domain.MockTransactionContext{
+			SetProviderTransactionFunc: func(ctx context.Context, id, methodType, transactionID string) error {
+				intent.PaymentMethodType = methodType
+				intent.ProviderTransactionID = transactionID
+				return nil
+			},
+			UpdateStatusFunc: func(ctx context.Context, id, status string) error {
+				intent.Status = status
+				return nil
+			},
+		}, &[]domain.OutboxEvent{}),
+	}
+	cards := &fakeBank{name: "cards"}
+	wallets := &fakeBank{name: "wallets"}
+	banks := bank.NewRouter()
+	banks.Register(domain.PaymentMethodCard, cards)
+	banks.Register(domain.PaymentMethodWallet, wallets)
+	h := &PaymentHandler{service: domain.NewPaymentService(mRepo), banks: banks}
+
+	tests := []struct {
+		name           string
+		reqBody        string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Card", `{"type":"card","card":{"number":"4242 4242 4242 4242","exp_month":12,"exp_year":2099,"cvc":"123"}}`, http.StatusCreated, `"last4":"4242"`},
+		{"Wallet", `{"type":"wallet","wallet":{"provider":"apple_pay","token":"wal_1"}}`, http.StatusCreated, `"provider":"apple_pay"`},
+		{"Invalid card number", `{"type":"card","card":{"number":"4242424242424241","exp_month":12,"exp_year":2099}}`, http.StatusBadRequest, "invalid card number"},
+		{"Expired card", `{"type":"card","card":{"number":"4242424242424242","exp_month":1,"exp_year":2001}}`, http.StatusBadRequest, "expired"},
+		{"No adapter", `{"type":"bank_transfer","bank_transfer":{"account_number":"000123456789"}}`, http.StatusBadRequest, "not supported"},
+		{"Unknown type", `{"type":"cash"}`, http.StatusBadRequest, "type must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/payment_methods", strings.NewReader(tt.reqBody))
+			req.Header.Set("X-User-ID", "user_1")
+			w := httptest.NewRecorder()
+			h.CreatePaymentMethod(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain '%s', got '%s'", tt.expectedBody, w.Body.String())
+			}
+			if strings.Contains(w.Body.String(), "4242424242424242") || strings.Contains(w.Body.String(), "tok_") {
+				t.Errorf("Expected neither the card number nor the token in the response, got %s", w.Body.String())
+			}
+		})
+	}
+	if methods["pm_1"].Token != "tok_cards_card" || methods["pm_2"].Token != "tok_wallets_wallet" {
+		t.Fatalf("Expected each method tokenized by its adapter, got %+v", methods)
+	}
+
+	// Another user's methods are hidden
+	req := httptest.NewRequest("GET", "/payment_methods/pm_1", nil)
+	req.Header.Set("X-User-ID", "user_2")
+	w := httptest.NewRecorder()
+	setupRoutes(h).ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's method, got %d", w.Code)
+	}
+
+	// Confirmation is charged through the adapter of the method's type
+	req = httptest.NewRequest("POST", "/intents/pi_1/confirm", strings.NewReader(`{"payment_method_id":"pm_2"}`))
+	req.Header.Set("X-User-ID", "user_1")
+	w = httptest.NewRecorder()
+	setupRoutes(h).ServeHTTP(w, req)
+	if w.Code != http.StatusOK || intent.Status != "succeeded" {
+		t.Fatalf("Expected the payment to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(wallets.charged) != 1 || wallets.charged[0] != "tok_wallets_wallet" || len(cards.charged) != 0 {
+		t.Errorf("Expected one wallet charge, got wallet %v and card %v", wallets.charged, cards.charged)
+	}
+	if intent.PaymentMethodType != domain.PaymentMethodWallet || intent.ProviderTransactionID != "txn_1" {
+		t.Errorf("Expected the wallet charge kept for refunds, got %s %s", intent.PaymentMethodType, intent.ProviderTransactionID)
+	}
+}
+
+// recordingLedger keeps the transactions recorded through it
+type recordingLedger struct {
+	pb.LedgerServiceClient
+	recorded []*pb.RecordTransactionRequest
+}
+
+func (l *recordingLedger) RecordTransaction(ctx context.Context, in *pb.RecordTransactionRequest, opts ...grpc.CallOption) (*pb.RecordTransactionResponse, error) {
+	l.recorded = append(l.recorded, in)
+	return &pb.RecordTransactionResponse{}, nil
+}
+
+func (l *recordingLedger) BulkRecordTransactions(ctx context.Context, in *pb.BulkRecordRequest, opts ...grpc.CallOption) (*pb.BulkRecordResponse, error) {
+	resp := &pb.BulkRecordResponse{}
+	for _, tx := range in.Transactions {
+		l.recorded = append(l.recorded, tx)
+		resp.Responses = append(resp.Responses, &pb.RecordTransactionResponse{Status: "recorded"})
+	}
+	return resp, nil
+}
+
+func TestPaymentHandler_SettlementCurrency(t *testing.T) {
+	settings := map[string]*domain.MerchantSettings{}
+	intent := &domain.PaymentIntent{ID: "pi_1", Amount: 10000, Currency: "JPY", Status: "requires_payment_method", UserID: "user_1",
+		ApplicationFeeAmount: 1000, OnBehalfOf: "merchant_2"}
+	mRepo := &domain.MockRepository{
+		GetMerchantSettingsFunc: func(ctx context.Context, userID string) (*domain.MerchantSettings, error) {
+			return settings[userID], nil
+		},
+		SaveMerchantSettingsFunc: func(ctx context.Context, s *domain.MerchantSettings) error {
+			settings[s.UserID] = s
+			return nil
+		},
+		GetPaymentIntentFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+			found := *intent
+			return &found, nil
+		},
+		BeginTxFunc: outboxTx(domain.MockTransactionContext{
+			SetProviderTransactionFunc: func(ctx context.Context, id, methodType, transactionID string) error {
+				return nil
+			},
+			UpdateStatusFunc: func(ctx context.Context, id, status string) error {
+				return nil
+			},
+		}, &[]domain.OutboxEvent{}),
+	}
+	rates, err := currency.ParseStaticRates("USD", "JPY=150,EUR=0.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	banks := bank.NewRouter()
+	banks.Register(domain.PaymentMethodCard, &fakeBank{})
+	ledger := &recordingLedger{}
+	h := &PaymentHandler{
+		service:      domain.NewPaymentService(mRepo),
+		banks:        banks,
+		ledgerClient: ledger,
+		fx:           currency.NewCachedConverter(rates, nil, 0),
+	}
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/settings", strings.NewReader(body))
+		req.Header.Set("X-User-ID", "user_1")
+		w := httptest.NewRecorder()
+		setupRoutes(h).ServeHTTP(w, req)
+		return w
+	}
+	if w := put(`{"settlement_currency":"ABC"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown currency, got %d", w.Code)
+	}
+	if w := put(`{"settlement_currency":"eur"}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"settlement_currency":"EUR"`) {
+		t.Fatalf("Expected EUR settlement, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 10000 JPY is 66.67 USD, or 60.00 EUR; the 1000 JPY fee is 6.00 EUR
+	req := httptest.NewRequest("POST", "/intents/pi_1/confirm", strings.NewReader(`{"payment_method_id":"tok_visa"}`))
+	req.Header.Set("X-User-ID", "user_1")
+	w := httptest.NewRecorder()
+	setupRoutes(h).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	expected := map[string]int64{"acc_merchant_2": 5400, "platform_main": 600, "user_user_1": -6000}
+	if len(ledger.recorded) != len(expected) {
+		t.Fatalf("Expected %d ledger entries, got %d", len(expected), len(ledger.recorded))
+	}
+	for _, entry := range ledger.recorded {
+		if entry.Currency != "EUR" || entry.Amount != expected[entry.AccountId] {
+			t.Errorf("Unexpected ledger entry for %s: %d %s", entry.AccountId, entry.Amount, entry.Currency)
+		}
+	}
+}
+
+// memoryIdempotency is an in-memory domain.IdempotencyStore
+type memoryIdempotency struct {
+	mu      sync.Mutex
+	records map[string]*domain.IdempotencyRecord
+	locked  map[string]bool
+}
+
+func (m *memoryIdempotency) Get(ctx context.Context, userID, key string) (*domain.IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.records[userID+"/"+key], nil
+}
+
+func (m *memoryIdempotency) Save(ctx context.Context, record *domain.IdempotencyRecord, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[record.UserID+"/"+record.Key] = record
+	return nil
+}
+
+func (m *memoryIdempotency) Lock(ctx context.Context, userID, key string, ttl time.Duration) (func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locked[userID+"/"+key] {
+		return nil, domain.ErrIdempotencyKeyInFlight
+	}
+	m.locked[userID+"/"+key] = true
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.locked, userID+"/"+key)
+	}, nil
+}
+
+func TestPaymentHandler_IdempotencyInFlight(t *testing.T) {
+	store := &memoryIdempotency{records: map[string]*domain.IdempotencyRecord{}, locked: map[string]bool{}}
+	h := &PaymentHandler{idempotency: IdempotencyConfig{Store: store, Scope: domain.IdempotencyScopeEndpoint}}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls int
+	next := h.IdempotencyMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			close(started)
+			<-release
+		}
+		jsonutil.WriteJSON(w, http.StatusCreated, map[string]int{"call": calls})
+	})
+	send := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, nil)
+		req.Header.Set("X-User-ID", "user_1")
+		req.Header.Set("Idempotency-Key", "key_1")
+		req.Header.Set("X-User-ID", "user_1")
+		w := httptest.NewRecorder()
+		next(w, req)
+		return w
+	}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- send("/intents") }()
+	<-started
+
+	if w := send("/intents"); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a concurrent duplicate, got %d", w.Code)
+	}
+	close(release)
+	if w := <-first; w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", w.Code)
+	}
+
+	w := send("/intents")
+	if w.Code != http.StatusCreated || w.Header().Get("X-Idempotency-Hit") != "true" || !strings.Contains(w.Body.String(), `"call":1`) {
+		t.Errorf("Expected the first response replayed, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Keys are scoped to the endpoint
+	w = send("/intents/pi_1/confirm")
+	if w.Header().Get("X-Idempotency-Hit") == "true" || !strings.Contains(w.Body.String(), `"call":2`) {
+		t.Errorf("Expected the key to be new on another endpoint, got %q", w.Body.String())
+	}
+}
+
+// flakyProducer fails to publish until it is fixed and keeps what it
+// published
+type flakyProducer struct {
+	down      bool
+	published []string
+}
+
+func (p *flakyProducer) Publish(ctx context.Context, key string, value []byte) error {
+	if p.down {
+		return fmt.Errorf("kafka unavailable")
+	}
+	p.published = append(p.published, key)
+	return nil
+}
+
+func TestPaymentHandler_Outbox(t *testing.T) {
+	intent := &domain.PaymentIntent{ID: "pi_1", Amount: 1000, Currency: "USD", Status: "requires_payment_method", UserID: "user_1"}
+	var events []domain.OutboxEvent
+	processed := map[string]bool{}
+	mRepo := &domain.MockRepository{
+		GetPaymentIntentFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+			found := *intent
+			return &found, nil
+		},
+		BeginTxFunc: outboxTx(domain.MockTransactionContext{
+			SetProviderTransactionFunc: func(ctx context.Context, id, methodType, transactionID string) error {
+				return nil
+			},
+			UpdateStatusFunc: func(ctx context.Context, id, status string) error {
+				intent.Status = status
+				return nil
+			},
+		}, &events),
+		GetUnprocessedEventsFunc: func(ctx context.Context, limit int) ([]domain.OutboxEvent, error) {
+			var unprocessed []domain.OutboxEvent
+			for _, e := range events {
+				if !processed[e.ID] {
+					unprocessed = append(unprocessed, e)
+				}
+			}
+			return unprocessed, nil
+		},
+		MarkEventProcessedFunc: func(ctx context.Context, id string) error {
+			processed[id] = true
+			return nil
+		},
+	}
+	banks := bank.NewRouter()
+	banks.Register(domain.PaymentMethodCard, &fakeBank{})
+	h := &PaymentHandler{service: domain.NewPaymentService(mRepo), banks: banks}
+
+	req := httptest.NewRequest("POST", "/intents/pi_1/confirm", strings.NewReader(`{"payment_method_id":"tok_visa"}`))
+	req.Header.Set("X-User-ID", "user_1")
+	w := httptest.NewRecorder()
+	setupRoutes(h).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the payment to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(events) != 1 || events[0].Type != "payment.succeeded" || events[0].AggregateID != "pi_1" {
+		t.Fatalf("Expected a payment.succeeded event in the outbox, got %+v", events)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(events[0].Payload, &payload); err != nil || payload["id"] != "evt_pi_1" {
+		t.Errorf("Unexpected event payload %s: %v", events[0].Payload, err)
+	}
+
+	// Events stay in the outbox until Kafka takes them
+	producer := &flakyProducer{down: true}
+	publisher := infrastructure.NewOutboxPublisher(mRepo, producer, time.Second)
+	publisher.ProcessOutbox(context.Background())
+	if processed[events[0].ID] {
+		t.Fatal("Expected the event to stay unprocessed while Kafka is down")
+	}
+	producer.down = false
+	publisher.ProcessOutbox(context.Background())
+	if !processed[events[0].ID] || len(producer.published) != 1 || producer.published[0] != "pi_1" {
+		t.Errorf("Expected the event published keyed by intent, got %v", producer.published)
+	}
+}
+
+func TestPaymentHandler_Disputes(t *testing.T) {
+	intent := &domain.PaymentIntent{ID: "pi_1", Amount: 1000, AmountCaptured: 1000, Currency: "USD", Status: "succeeded", UserID: "user_1"}
+	disputes := map[string]*domain.Dispute{}
+	var events []domain.OutboxEvent
+	mRepo := &domain.MockRepository{
+		GetPaymentIntentFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+			if id != intent.ID {
+				return nil, nil
+			}
+			found := *intent
+			return &found, nil
+		},
+		GetDisputeFunc: func(ctx context.Context, id string) (*domain.Dispute, error) {
+			return disputes[id], nil
+		},
+		BeginTxFunc: outboxTx(domain.MockTransactionContext{
+			CreateDisputeFunc: func(ctx context.Context, dispute *domain.Dispute) error {
+				dispute.ID = fmt.Sprintf("dp_%d", len(disputes)+1)
+				saved := *dispute
+				disputes[dispute.ID] = &saved
+				return nil
+			},
+			GetDisputeForUpdateFunc: func(ctx context.Context, id string) (*domain.Dispute, error) {
+				found, ok := disputes[id]
+				if !ok {
+					return nil, nil
+				}
+				locked := *found
+				return &locked, nil
+			},
+			UpdateDisputeFunc: func(ctx context.Context, dispute *domain.Dispute) error {
+				saved := *dispute
+				disputes[dispute.ID] = &saved
+				return nil
+			},
+		}, &events),
+	}
+	ledger := &recordingLedger{}
+	h := &PaymentHandler{service: domain.NewPaymentService(mRepo), ledgerClient: ledger, bankWebhookSecret: "bank_secret"}
+
+	routes := setupRoutes(h)
+	do := func(path, body, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("X-User-ID", "user_1")
+		if userID == "" {
+			req.Header.Set("X-Bank-Webhook-Secret", "bank_secret")
+		} else {
+			req.Header.Set("X-User-ID", userID)
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("/disputes", `{"payment_intent_id":"pi_1","amount":1500}`, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a dispute over the captured amount, got %d", w.Code)
+	}
+	if w := do("/disputes", `{"payment_intent_id":"pi_1"}`, "user_2"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another merchant's payment, got %d", w.Code)
+	}
+	w := do("/disputes", `{"payment_intent_id":"pi_1","amount":400,"reason":"fraudulent","bank_reference":"cb_1"}`, "")
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"status":"needs_response"`) || !strings.Contains(w.Body.String(), `"source":"bank"`) {
+		t.Fatalf("Expected the bank to open a dispute, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		reqBody        string
+		userID         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Another merchant", "/disputes/dp_1/evidence", `{"type":"receipt","description":"Signed receipt"}`, "user_2", http.StatusNotFound, "not found"},
+		{"Submit without evidence", "/disputes/dp_1/submit", ``, "user_1", http.StatusBadRequest, "no evidence"},
+		{"Unknown evidence type", "/disputes/dp_1/evidence", `{"type":"selfie","description":"x"}`, "user_1", http.StatusBadRequest, "unknown type"},
+		{"Add evidence", "/disputes/dp_1/evidence", `{"type":"receipt","file_name":"receipt.pdf","content_type":"application/pdf","size":2048,"url":"https://files.example.com/receipt.pdf"}`, "user_1", http.StatusOK, `"file_name":"receipt.pdf"`},
+		{"Submit", "/disputes/dp_1/submit", ``, "user_1", http.StatusOK, `"status":"under_review"`},
+		{"Evidence after submitting", "/disputes/dp_1/evidence", `{"type":"other","description":"late"}`, "user_1", http.StatusConflict, "no longer accepts"},
+		{"Merchant claims a win", "/disputes/dp_1/close", `{"outcome":"won"}`, "user_1", http.StatusForbidden, "only accept"},
+		{"Bank decides", "/disputes/dp_1/close", `{"outcome":"lost"}`, "", http.StatusOK, `"status":"lost"`},
+		{"Closed twice", "/disputes/dp_1/close", `{"outcome":"won"}`, "", http.StatusConflict, "closed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.path, tt.reqBody, tt.userID)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain '%s', got '%s'", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+
+	if len(ledger.recorded) != 1 || ledger.recorded[0].AccountId != "user_user_1" || ledger.recorded[0].Amount != -400 || ledger.recorded[0].ReferenceId != "dp_1" {
+		t.Errorf("Expected a 400 reversal of the lost dispute, got %+v", ledger.recorded)
+	}
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	if strings.Join(types, ",") != "dispute.created,dispute.evidence_added,dispute.under_review,dispute.lost" {
+		t.Errorf("Unexpected outbox events: %v", types)
+	}
+}
+
+func TestPaymentHandler_Payouts(t *testing.T) {
+	intents := []domain.PaymentIntent{
+		{ID: "pi_1", AmountCaptured: 1000, Currency: "USD", Status: "succeeded", UserID: "user_1"},
+		{ID: "pi_2", AmountCaptured: 500, AmountRefunded: 200, Currency: "USD", Status: "partially_refunded", UserID: "user_1"},
+		{ID: "pi_3", AmountCaptured: 2000, Currency: "EUR", Status: "succeeded", UserID: "user_1"},
+		{ID: "pi_4", AmountCaptured: 1000, Currency: "USD", Status: "succeeded", UserID: "user_1", ApplicationFeeAmount: 100, OnBehalfOf: "merchant_2"},
+	}
+	batched := map[string]bool{}
+	batches := map[string]*domain.PayoutBatch{}
+	var order []string
+	var events []domain.OutboxEvent
+	mRepo := &domain.MockRepository{
+		ListUnbatchedPaymentsFunc: func(ctx context.Context, cutoff time.Time, limit int) ([]domain.PaymentIntent, error) {
+			var unbatched []domain.PaymentIntent
+			for _, intent := range intents {
+				if !batched[intent.ID] {
+					unbatched = append(unbatched, intent)
+				}
+			}
+			return unbatched, nil
+		},
+		BeginTxFunc: outboxTx(domain.MockTransactionContext{
+			CreatePayoutBatchFunc: func(ctx context.Context, batch *domain.PayoutBatch) error {
+				batch.ID = fmt.Sprintf("po_%d", len(batches)+1)
+				for _, item := range batch.Items {
+					batched[item.PaymentIntentID] = true
+				}
+				batches[batch.ID] = batch
+				order = append(order, batch.ID)
+				return nil
+			},
+		}, &events),
+		ListPayoutBatchesFunc: func(ctx context.Context, filter domain.PayoutBatchFilter) ([]domain.PayoutBatch, error) {
+			var found []domain.PayoutBatch
+			for _, id := range order {
+				b := batches[id]
+				if (filter.UserID == "" || b.UserID == filter.UserID) && (filter.Status == "" || b.Status == filter.Status) {
+					found = append(found, *b)
+				}
+			}
+			return found, nil
+		},
+		MarkPayoutBatchPostedFunc: func(ctx context.Context, id string, postedAt time.Time) error {
+			batches[id].Status = domain.PayoutPosted
+			return nil
+		},
+		GetPayoutBatchFunc: func(ctx context.Context, id string) (*domain.PayoutBatch, error) {
+			return batches[id], nil
+		},
+	}
+	ledger := &recordingLedger{}
+	h := &PaymentHandler{service: domain.NewPaymentService(mRepo), ledgerClient: ledger}
+
+	h.RunPayouts(context.Background())
+	if len(batches) != 3 {
+		t.Fatalf("Expected a batch per account and currency, got %d", len(batches))
+	}
+	usd := batches["po_1"]
+	if usd.UserID != "user_1" || usd.Currency != "USD" || usd.Amount != 1300 || usd.PaymentCount != 2 || usd.Status != domain.PayoutPosted {
+		t.Errorf("Unexpected USD batch: %+v", usd)
+	}
+	if split := batches["po_3"]; split.UserID != "merchant_2" || split.LedgerAccount != "acc_merchant_2" || split.Amount != 900 {
+		t.Errorf("Expected the connected account paid net of the fee, got %+v", split)
+	}
+
+	// Each batch moves its amount from pending to payable
+	if len(ledger.recorded) != 6 {
+		t.Fatalf("Expected two legs per batch, got %d", len(ledger.recorded))
+	}
+	pending, payable := ledger.recorded[0], ledger.recorded[1]
+	if pending.AccountId != "user_user_1" || pending.Amount != -1300 || payable.AccountId != "payable_user_1" || payable.Amount != 1300 {
+		t.Errorf("Unexpected legs: %+v and %+v", pending, payable)
+	}
+	if len(events) != 3 || events[0].Type != "payout.batch_created" {
+		t.Errorf("Expected a payout.batch_created event per batch, got %+v", events)
+	}
+
+	// A second run finds nothing new to settle
+	h.RunPayouts(context.Background())
+	if len(batches) != 3 || len(ledger.recorded) != 6 {
+		t.Errorf("Expected no new batches or postings, got %d batches and %d legs", len(batches), len(ledger.recorded))
+	}
+
+	req := httptest.NewRequest("GET", "/payouts", nil)
+	req.Header.Set("X-User-ID", "user_1")
+	w := httptest.NewRecorder()
+	h.ListPayouts(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"payment_intent_id":"pi_2","amount":300`) || strings.Contains(w.Body.String(), "merchant_2") {
+		t.Errorf("Expected user_1's batches with their payments, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/payouts/po_3", nil)
+	req.Header.Set("X-User-ID", "user_1")
+	w = httptest.NewRecorder()
+	setupRoutes(h).ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another merchant's batch, got %d", w.Code)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	router := setupRoutes(&PaymentHandler{})
+
+	tests := []struct {
+		method string
+		path   string
+		route  string
+		vars   map[string]string
+	}{
+		{"GET", "/intents", "list_intents", nil},
+		{"POST", "/intents", "create_intent", nil},
+		{"GET", "/intents/pi_1", "get_intent", map[string]string{"id": "pi_1"}},
+		{"POST", "/intents/pi_1/confirm", "confirm_intent", map[string]string{"id": "pi_1"}},
+		{"POST", "/intents/pi_1/capture", "capture_intent", map[string]string{"id": "pi_1"}},
+		{"POST", "/intents/pi_1/refund", "refund_intent", map[string]string{"id": "pi_1"}},
+		{"GET", "/intents/pi_1/refunds", "list_refunds", map[string]string{"id": "pi_1"}},
+		{"GET", "/webhooks", "list_webhooks", nil},
+		{"POST", "/webhooks", "create_webhook", nil},
+		{"DELETE", "/webhooks/we_1", "delete_webhook", map[string]string{"id": "we_1"}},
+		{"GET", "/webhooks/we_1/deliveries", "list_webhook_deliveries", map[string]string{"id": "we_1"}},
+		{"GET", "/settings", "get_settings", nil},
+		{"PUT", "/settings", "update_settings", nil},
+		{"GET", "/payment_methods", "list_payment_methods", nil},
+		{"POST", "/payment_methods", "create_payment_method", nil},
+		{"GET", "/payment_methods/pm_1", "get_payment_method", map[string]string{"id": "pm_1"}},
+		{"GET", "/disputes", "list_disputes", nil},
+		{"POST", "/disputes", "create_dispute", nil},
+		{"GET", "/disputes/dp_1", "get_dispute", map[string]string{"id": "dp_1"}},
+		{"POST", "/disputes/dp_1/evidence", "add_dispute_evidence", map[string]string{"id": "dp_1"}},
+		{"POST", "/disputes/dp_1/submit", "submit_dispute", map[string]string{"id": "dp_1"}},
+		{"POST", "/disputes/dp_1/close", "close_dispute", map[string]string{"id": "dp_1"}},
+		{"GET", "/payouts", "list_payouts", nil},
+		{"GET", "/payouts/po_1", "get_payout", map[string]string{"id": "po_1"}},
+	}
+	for _, tt := range tests {
+		var match mux.RouteMatch
+		if !router.Match(httptest.NewRequest(tt.method, tt.path, nil), &match) || match.Route == nil {
+			t.Errorf("%s %s: no route matched", tt.method, tt.path)
+			continue
+		}
+		if name := match.Route.GetName(); name != tt.route {
+			t.Errorf("%s %s: expected route %s, got %s", tt.method, tt.path, tt.route, name)
+		}
+		for k, v := range tt.vars {
+			if match.Vars[k] != v {
+				t.Errorf("%s %s: expected %s=%s, got %q", tt.method, tt.path, k, v, match.Vars[k])
+			}
+		}
+	}
+
+	// Routes behind the auth middleware reject anonymous callers before
+	// reaching the handler
+	for _, tt := range tests {
+		if tt.route != "get_payout" && tt.route != "confirm_intent" && tt.route != "capture_intent" && tt.route != "refund_intent" {
+			continue
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: expected status 401 without a user, got %d", tt.method, tt.path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/intents/pi_1", nil))
+	if w.Code != http.StatusMethodNotAllowed || !strings.Contains(w.Body.String(), "Method not allowed") {
+		t.Errorf("Expected a JSON 405, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/intents/pi_1/unknown", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "Not Found") {
+		t.Errorf("Expected a JSON 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPaymentHandler_FraudCheck(t *testing.T) {
+	decisions := map[string]string{
+		"pi_decline": `{"payment_id":"pi_decline","score":90,"decision":"decline"}`,
+		"pi_review":  `{"payment_id":"pi_review","score":60,"decision":"review"}`,
+	}
+	fraudService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req fraud.CheckRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.PaymentID == "pi_slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		if body, ok := decisions[req.PaymentID]; ok {
+			_, _ = io.WriteString(w, body)
+			return
+		}
+		_, _ = io.WriteString(w, `{"decision":"approve"}`)
+	}))
+	defer fraudService.Close()
+
+	statuses := map[string]string{}
+	mRepo := &domain.MockRepository{
+		GetPaymentIntentFunc: func(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+			return &domain.PaymentIntent{ID: id, Amount: 1000, Currency: "USD", Status: "requires_payment_method"}, nil
+		},
+		UpdateStatusFunc: func(ctx context.Context, id, status string) error {
+			statuses[id] = status
+			return nil
+		},
+		BeginTxFunc: outboxTx(domain.MockTransactionContext{
+			SetProviderTransactionFunc: func(ctx context.Context, id, methodType, transactionID string) error {
+				return nil
+			},
+			UpdateStatusFunc: func(ctx context.Context, id, status string) error {
+				statuses[id] = status
+				return nil
+			},
+		}, &[]domain.OutboxEvent{}),
+	}
+	fb := &fakeBank{}
+	banks := bank.NewRouter()
+	banks.Register(domain.PaymentMethodCard, fb)
+	h := &PaymentHandler{
+		service: domain.NewPaymentService(mRepo),
+		banks:   banks,
+		fraud:   &fraud.Client{BaseURL: fraudService.URL, Timeout: 50 * time.Millisecond},
+	}
+
+	tests := []struct {
+		id     string
+		status string
+	}{
+		{"pi_decline", "failed"},
+		{"pi_review", "succeeded"},
+		{"pi_approve", "succeeded"},
+		// The fraud service missing its latency budget does not block payments
+		{"pi_slow", "succeeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/intents/"+tt.id+"/confirm", strings.NewReader(`{"payment_method_id":"tok_visa"}`))
+			req.Header.Set("X-User-ID", "user_1")
+			w := httptest.NewRecorder()
+			setupRoutes(h).ServeHTTP(w, req)
+			if w.Code != http.StatusOK || statuses[tt.id] != tt.status {
+				t.Errorf("Expected %s, got %s (%d: %s)", tt.status, statuses[tt.id], w.Code, w.Body.String())
+			}
+		})
+	}
+	if len(fb.charged) != 3 {
+		t.Errorf("Expected the declined payment not to be charged, got %d charges", len(fb.charged))
+	}
+}
+
+func TestDrainer_Shutdown(t *testing.T) {
+	drain := &drainer{delay: 50 * time.Millisecond, timeout: 5 * time.Second}
+	started, release := make(chan struct{}), make(chan struct{})
+	var served atomic.Bool
+	router := mux.NewRouter()
+	router.HandleFunc("/ready", drain.Ready)
+	router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+		served.Store(true)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ready := func() int {
+		resp, err := http.Get(server.URL + "/ready")
+		if err != nil {
+			t.Fatalf("Failed to check readiness: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := ready(); code != http.StatusOK {
+		t.Fatalf("Expected status 200 before the shutdown, got %d", code)
+	}
+
+	// A request is in flight when the shutdown starts
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(server.URL + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	<-started
+
+	var servedFirst, workDone bool
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- drain.Shutdown(server.Config,
+			func(context.Context) { servedFirst = served.Load() },
+			func(context.Context) { workDone = true },
+		)
+	}()
+
+	// Load balancers see the service draining while it still serves
+	deadline := time.Now().Add(time.Second)
+	for ready() != http.StatusServiceUnavailable {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected /ready to fail while draining")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(2 * drain.delay)
+	select {
+	case <-stopped:
+		t.Fatal("Expected the shutdown to wait for the request in flight")
+	default:
+	}
+
+	close(release)
+	if body := <-slow; body != "done" {
+		t.Errorf("Expected the request in flight to complete, got %q", body)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+	if !servedFirst || !workDone {
+		t.Errorf("Expected requests, then background work to finish before exit, got request %v and work %v", servedFirst, workDone)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+)
+
+// IdempotencyConfig sets how requests with an Idempotency-Key are
+// deduplicated
+type IdempotencyConfig struct {
+	Store   domain.IdempotencyStore // nil keeps responses in the database, without expiry or locking
+	Scope   domain.IdempotencyScope // Which requests share a key (default: user)
+	TTL     time.Duration           // How long responses are replayed (default: 24h)
+	LockTTL time.Duration           // Longest a request holds its key (default: 1m)
+}
+
+func (c IdempotencyConfig) store(service *domain.PaymentService) domain.IdempotencyStore {
+	if c.Store == nil {
+		return databaseIdempotencyStore{service}
+	}
+	return c.Store
+}
+
+func (c IdempotencyConfig) ttl() time.Duration {
+	if c.TTL <= 0 {
+		return 24 * time.Hour
+	}
+	return c.TTL
+}
+
+func (c IdempotencyConfig) lockTTL() time.Duration {
+	if c.LockTTL <= 0 {
+		return time.Minute
+	}
+	return c.LockTTL
+}
+
+// scopedKey qualifies a client's key so only requests in the same scope
+// share it
+func (c IdempotencyConfig) scopedKey(r *http.Request, key string) string {
+	switch c.Scope {
+	case domain.IdempotencyScopeZone:
+		return r.Header.Get("X-Zone-ID") + ":" + key
+	case domain.IdempotencyScopeEndpoint:
+		return r.Method + " " + r.URL.Path + ":" + key
+	default:
+		return key
+	}
+}
+
+// databaseIdempotencyStore keeps responses in the payments database as the
+// service always has. Responses never expire and keys are not locked.
+type databaseIdempotencyStore struct {
+	service *domain.PaymentService
+}
+
+func (s databaseIdempotencyStore) Get(ctx context.Context, userID, key string) (*domain.IdempotencyRecord, error) {
+	return s.service.GetIdempotencyKey(ctx, userID, key)
+}
+
+func (s databaseIdempotencyStore) Save(ctx context.Context, record *domain.IdempotencyRecord, ttl time.Duration) error {
+	return s.service.SaveIdempotencyKey(ctx, record.UserID, record.Key, record.StatusCode, record.ResponseBody)
+}
+
+func (s databaseIdempotencyStore) Lock(ctx context.Context, userID, key string, ttl time.Duration) (func(), error) {
+	return func() {}, nil
+}
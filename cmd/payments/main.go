@@ -3,13 +3,19 @@ package main
 import (
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/sapliy/fintech-ecosystem/internal/fraud"
 	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
 	"github.com/sapliy/fintech-ecosystem/internal/payment/infrastructure"
+	"github.com/sapliy/fintech-ecosystem/internal/policy"
 	"github.com/sapliy/fintech-ecosystem/pkg/bank"
+	"github.com/sapliy/fintech-ecosystem/pkg/currency"
 	"github.com/sapliy/fintech-ecosystem/pkg/database"
 	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
 	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
@@ -65,14 +71,55 @@ func main() {
 	rdb := redis.NewClient(&redis.Options{
 		Addr: redisAddr,
 	})
+	redisConnected := true
 	if err := rdb.Ping(context.Background()).Err(); err != nil {
 		logger.Warn("Redis connection failed in Payments", "error", err)
+		redisConnected = false
 	}
 
 	// Initialize dependencies
 	repo := infrastructure.NewSQLRepository(db)
 	service := domain.NewPaymentService(repo)
-	bankClient := bank.NewMockClient()
+	// The bank provider (mock, sandbox or stripe) is chosen by BANK_PROVIDER
+	// and handles every payment method type
+	bankConfig, err := bank.LoadFromEnv()
+	if err != nil {
+		logger.Error("Invalid bank configuration", "error", err)
+		os.Exit(1)
+	}
+	bankClient, err := bank.NewClientFromConfig(bankConfig)
+	if err != nil {
+		logger.Error("Failed to create bank client", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Bank provider configured", "provider", bankConfig.Provider)
+	banks := bank.NewRouter()
+	banks.Register(domain.PaymentMethodCard, bankClient)
+	banks.Register(domain.PaymentMethodBankTransfer, bankClient)
+	banks.Register(domain.PaymentMethodWallet, bankClient)
+	webhooks := domain.NewWebhookService(repo, domain.WebhookConfig{})
+
+	// Exchange rates for settling payments in merchants' currencies, as
+	// units of each currency per unit of FX_BASE_CURRENCY, e.g.
+	// FX_RATES="EUR=0.92,GBP=0.79". Rates are cached in Redis.
+	fxBase := os.Getenv("FX_BASE_CURRENCY")
+	if fxBase == "" {
+		fxBase = "USD"
+	}
+	fxRates, err := currency.ParseStaticRates(fxBase, os.Getenv("FX_RATES"))
+	if err != nil {
+		logger.Error("Invalid exchange rates", "error", err)
+		os.Exit(1)
+	}
+	fxRateTTL := time.Hour
+	if v := os.Getenv("FX_RATE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			fxRateTTL = d
+		} else {
+			logger.Warn("Invalid FX_RATE_TTL, using default", "value", v)
+		}
+	}
+	fx := currency.NewCachedConverter(fxRates, rdb, fxRateTTL)
 
 	// Setup Ledger Service gRPC Client
 	ledgerGRPCAddr := os.Getenv("LEDGER_GRPC_ADDR")
@@ -145,21 +192,184 @@ func main() {
 		}()
 	}
 
+	// Background workers stop on SIGINT or SIGTERM. Runs in progress are
+	// waited for before the producers they publish through are closed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var workers sync.WaitGroup
+	runWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(ctx)
+		}()
+	}
+
+	// Retry failed merchant webhook deliveries
+	webhookRetryInterval := 15 * time.Second
+	if v := os.Getenv("WEBHOOK_RETRY_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			webhookRetryInterval = d
+		} else {
+			logger.Warn("Invalid WEBHOOK_RETRY_INTERVAL, using default", "value", v)
+		}
+	}
+	if db != nil {
+		runWorker(func(ctx context.Context) { webhooks.Start(ctx, webhookRetryInterval) })
+	}
+
+	// Holds of manually captured payments expire if not captured in time
+	authorizationTTL := 7 * 24 * time.Hour
+	if v := os.Getenv("PAYMENT_AUTHORIZATION_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			authorizationTTL = d
+		} else {
+			logger.Warn("Invalid PAYMENT_AUTHORIZATION_TTL, using default", "value", v)
+		}
+	}
+
+	// Captured payments are batched for payout every PAYOUT_INTERVAL once
+	// they are PAYOUT_DELAY old
+	payoutInterval := 24 * time.Hour
+	if v := os.Getenv("PAYOUT_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			payoutInterval = d
+		} else {
+			logger.Warn("Invalid PAYOUT_INTERVAL, using default", "value", v)
+		}
+	}
+	payoutDelay := defaultPayoutDelay
+	if v := os.Getenv("PAYOUT_DELAY"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			payoutDelay = d
+		} else {
+			logger.Warn("Invalid PAYOUT_DELAY, using default", "value", v)
+		}
+	}
+
+	// Idempotent responses are kept in Redis for IDEMPOTENCY_TTL, where keys
+	// of requests in flight are also locked
+	idempotencyScope, err := domain.ParseIdempotencyScope(os.Getenv("IDEMPOTENCY_SCOPE"))
+	if err != nil {
+		logger.Error("Invalid IDEMPOTENCY_SCOPE", "error", err)
+		os.Exit(1)
+	}
+	idempotency := IdempotencyConfig{Scope: idempotencyScope}
+	if v := os.Getenv("IDEMPOTENCY_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			idempotency.TTL = d
+		} else {
+			logger.Warn("Invalid IDEMPOTENCY_TTL, using default", "value", v)
+		}
+	}
+	if v := os.Getenv("IDEMPOTENCY_LOCK_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			idempotency.LockTTL = d
+		} else {
+			logger.Warn("Invalid IDEMPOTENCY_LOCK_TTL, using default", "value", v)
+		}
+	}
+	if redisConnected {
+		idempotency.Store = infrastructure.NewRedisIdempotencyStore(rdb)
+	} else {
+		logger.Warn("Idempotency keys are kept in the database without in-flight locking")
+	}
+
 	// Start Metrics Server
 	monitoring.StartMetricsServer(":8086") // Distinct from HTTP server on 8082 if preferred, but on separate port is standard
 
+	// Payments are checked with the fraud service before they are
+	// confirmed, and approved if it does not answer within
+	// FRAUD_CHECK_TIMEOUT
+	var fraudClient *fraud.Client
+	if fraudURL := os.Getenv("FRAUD_SERVICE_URL"); fraudURL != "" {
+		fraudClient = &fraud.Client{BaseURL: fraudURL, Timeout: fraud.DefaultCheckTimeout}
+		if v := os.Getenv("FRAUD_CHECK_TIMEOUT"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				fraudClient.Timeout = d
+			} else {
+				logger.Warn("Invalid FRAUD_CHECK_TIMEOUT, using default", "value", v)
+			}
+		}
+	}
+
+	// Payments and refunds are checked against the policies of the caller's
+	// organization. Its custom policies are read from POLICY_DB_DSN, the
+	// auth service's database, where decisions are audited too.
+	var policyEngine policy.PolicyEngine = policy.NewEngine()
+	if policyDSN := os.Getenv("POLICY_DB_DSN"); policyDSN != "" {
+		policyDB, err := database.Connect(policyDSN)
+		if err != nil {
+			logger.Error("Failed to connect to the policy database, using built-in policies", "error", err)
+		} else {
+			defer policyDB.Close()
+			custom := policy.NewDatabasePolicyEngine(policy.NewSQLPolicyStore(policyDB), policyEngine)
+			if err := custom.Refresh(context.Background()); err != nil {
+				logger.Warn("Failed to load custom policies", "error", err)
+			}
+			audited := policy.NewAuditedPolicyEngine(custom, policy.NewSQLAuditStore(policyDB), 10000)
+			runWorker(func(ctx context.Context) { custom.Watch(ctx, 30*time.Second) })
+			runWorker(audited.Run)
+			policyEngine = audited
+		}
+	}
+
 	handler := &PaymentHandler{
-		service:       service,
-		bankClient:    bankClient,
-		rdb:           rdb,
-		ledgerClient:  ledgerClient,
-		kafkaProducer: kafkaProducer,
-		rabbitClient:  rabbitClient,
+		service:      service,
+		banks:        banks,
+		rdb:          rdb,
+		ledgerClient: ledgerClient,
+		rabbitClient: rabbitClient,
+		webhooks:     webhooks,
+		fx:           fx,
+		idempotency:  idempotency,
+		fraud:        fraudClient,
+		policies:     policy.NewPolicyMiddleware(policyEngine),
+
+		authorizationTTL:  authorizationTTL,
+		bankWebhookSecret: os.Getenv("BANK_WEBHOOK_SECRET"),
+		payoutDelay:       payoutDelay,
+	}
+	var publisher *infrastructure.OutboxPublisher
+	if db != nil {
+		runWorker(func(ctx context.Context) { handler.StartAuthorizationExpiry(ctx, time.Minute) })
+		runWorker(func(ctx context.Context) { handler.StartRefundSync(ctx, time.Minute) })
+		runWorker(func(ctx context.Context) { handler.StartPayoutSchedule(ctx, payoutInterval) })
+
+		// Publish the events queued in the outbox to Kafka
+		outboxInterval := 2 * time.Second
+		if v := os.Getenv("OUTBOX_POLL_INTERVAL"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				outboxInterval = d
+			} else {
+				logger.Warn("Invalid OUTBOX_POLL_INTERVAL, using default", "value", v)
+			}
+		}
+		publisher = infrastructure.NewOutboxPublisher(repo, kafkaProducer, outboxInterval)
+		runWorker(publisher.Start)
 	}
 
-	mux := http.NewServeMux()
+	// On shutdown /ready fails for SHUTDOWN_DRAIN_DELAY so that load
+	// balancers stop routing here, then requests in flight get up to
+	// SHUTDOWN_TIMEOUT to finish
+	drain := &drainer{delay: 5 * time.Second, timeout: 30 * time.Second}
+	if v := os.Getenv("SHUTDOWN_DRAIN_DELAY"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			drain.delay = d
+		} else {
+			logger.Warn("Invalid SHUTDOWN_DRAIN_DELAY, using default", "value", v)
+		}
+	}
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			drain.timeout = d
+		} else {
+			logger.Warn("Invalid SHUTDOWN_TIMEOUT, using default", "value", v)
+		}
+	}
 
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	router := setupRoutes(handler)
+	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		jsonutil.WriteJSON(w, http.StatusOK, map[string]string{
 			"status":  "active",
 			"service": "payments",
@@ -170,44 +380,44 @@ func main() {
 				return "false"
 			}(),
 		})
-	})
-
-	// Register Handlers
-	// Gateway forwards /v1/payments/* -> /*
-	// So /v1/payments/intents -> /intents
-	mux.HandleFunc("/intents", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodGet {
-			handler.ListPaymentIntents(w, r)
-			return
-		}
-		handler.IdempotencyMiddleware(handler.CreatePaymentIntent)(w, r)
-	})
-
-	// For /confirm, we need to match the path prefix because of the ID parameter
-	// /intents/{id}/confirm
-	mux.HandleFunc("/intents/", func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-		if r.Method == http.MethodPost && strings.HasSuffix(path, "/confirm") {
-			handler.IdempotencyMiddleware(handler.ConfirmPaymentIntent)(w, r)
-			return
-		}
-		if r.Method == http.MethodPost && strings.HasSuffix(path, "/refund") {
-			handler.IdempotencyMiddleware(handler.RefundPaymentIntent)(w, r)
-			return
-		}
-		// Fallback or other sub-resources could go here.
-		jsonutil.WriteErrorJSON(w, "Not Found")
-	})
+	}).Methods(http.MethodGet).Name("health")
+	router.HandleFunc("/ready", drain.Ready).Methods(http.MethodGet).Name("ready")
 
 	port := ":8082"
 	logger.Info("Payments service starting", "port", port)
 
 	// Wrap handler with OpenTelemetry and Prometheus
-	otelHandler := otelhttp.NewHandler(mux, "payments-request")
+	otelHandler := otelhttp.NewHandler(router, "payments-request")
 	promHandler := monitoring.PrometheusMiddleware(otelHandler)
 
-	if err := http.ListenAndServe(port, promHandler); err != nil {
-		logger.Error("Server failed", "error", err)
-		os.Exit(1)
+	srv := &http.Server{
+		Addr:    port,
+		Handler: promHandler,
 	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("Server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("Shutting down payments service", "drain_delay", drain.delay)
+	err = drain.Shutdown(srv,
+		func(context.Context) { workers.Wait() },
+		func(context.Context) { webhooks.Wait() },
+		// Publish what the last requests queued in the outbox before the
+		// Kafka producer is closed
+		func(ctx context.Context) {
+			if publisher != nil {
+				publisher.ProcessOutbox(ctx)
+			}
+		},
+	)
+	if err != nil {
+		logger.Error("Server shutdown error", "error", err)
+	}
+
+	logger.Info("Payments service stopped")
 }
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/bank"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// CreatePaymentMethodRequest carries the raw details of one payment method
+// type. They are exchanged for a token and discarded.
+type CreatePaymentMethodRequest struct {
+	Type string `json:"type"` // card, bank_transfer or wallet
+	Card *struct {
+		Number   string `json:"number"`
+		ExpMonth int    `json:"exp_month"`
+		ExpYear  int    `json:"exp_year"`
+		CVC      string `json:"cvc"`
+	} `json:"card"`
+	BankTransfer *struct {
+		AccountNumber string `json:"account_number"`
+		RoutingNumber string `json:"routing_number"`
+		BankName      string `json:"bank_name"`
+	} `json:"bank_transfer"`
+	Wallet *struct {
+		Provider string `json:"provider"`
+		Token    string `json:"token"` // Issued by the wallet provider
+	} `json:"wallet"`
+}
+
+// CreatePaymentMethod tokenizes a card, bank account or wallet with the bank
+// adapter of its type and saves the token for the caller
+func (h *PaymentHandler) CreatePaymentMethod(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreatePaymentMethodRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+
+	method := &domain.PaymentMethod{UserID: userID, Type: req.Type}
+	details := bank.PaymentDetails{Type: req.Type}
+	switch req.Type {
+	case domain.PaymentMethodCard:
+		if req.Card == nil {
+			jsonutil.WriteErrorJSON(w, "card is required")
+			return
+		}
+		card, err := domain.NewCardDetails(req.Card.Number, req.Card.ExpMonth, req.Card.ExpYear, time.Now())
+		if err != nil {
+			jsonutil.WriteErrorJSON(w, err.Error())
+			return
+		}
+		method.Card = card
+		details.CardNumber = strings.NewReplacer(" ", "", "-", "").Replace(req.Card.Number)
+		details.ExpMonth = req.Card.ExpMonth
+		details.ExpYear = req.Card.ExpYear
+		details.CVC = req.Card.CVC
+	case domain.PaymentMethodBankTransfer:
+		if req.BankTransfer == nil || len(req.BankTransfer.AccountNumber) < 4 {
+			jsonutil.WriteErrorJSON(w, "bank_transfer.account_number is required")
+			return
+		}
+		number := req.BankTransfer.AccountNumber
+		method.BankTransfer = &domain.BankTransferDetails{BankName: req.BankTransfer.BankName, Last4: number[len(number)-4:]}
+		details.AccountNumber = number
+		details.RoutingNumber = req.BankTransfer.RoutingNumber
+	case domain.PaymentMethodWallet:
+		if req.Wallet == nil || req.Wallet.Provider == "" || req.Wallet.Token == "" {
+			jsonutil.WriteErrorJSON(w, "wallet.provider and wallet.token are required")
+			return
+		}
+		method.Wallet = &domain.WalletDetails{Provider: req.Wallet.Provider}
+		details.WalletProvider = req.Wallet.Provider
+		details.WalletToken = req.Wallet.Token
+	default:
+		jsonutil.WriteErrorJSON(w, "type must be card, bank_transfer or wallet")
+		return
+	}
+
+	adapter, err := h.banks.Adapter(req.Type)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, fmt.Sprintf("%s payments are not supported", req.Type))
+		return
+	}
+	method.Token, err = adapter.Tokenize(r.Context(), details)
+	if err != nil {
+		jsonutil.WriteErrorJSON(w, "Tokenization failed: "+err.Error())
+		return
+	}
+
+	if err := h.service.CreatePaymentMethod(r.Context(), method); err != nil {
+		log.Printf("Failed to save payment method: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to save payment method"})
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusCreated, method)
+}
+
+func (h *PaymentHandler) ListPaymentMethods(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	methods, err := h.service.ListPaymentMethods(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to list payment methods: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list payment methods"})
+		return
+	}
+	if methods == nil {
+		methods = []domain.PaymentMethod{}
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, methods)
+}
+
+// GetPaymentMethod returns one of the caller's payment methods
+func (h *PaymentHandler) GetPaymentMethod(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	method, err := h.service.GetPaymentMethod(r.Context(), userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, domain.ErrPaymentMethodNotFound) {
+			jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to get payment method: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal Server Error"})
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, method)
+}
+
+// resolvePaymentMethod returns the payment method an intent is confirmed
+// with: a saved method of the intent's owner, or a card test token such as
+// tok_visa passed directly
+func (h *PaymentHandler) resolvePaymentMethod(r *http.Request, intent *domain.PaymentIntent, id string) (*domain.PaymentMethod, error) {
+	if strings.HasPrefix(id, "tok_") {
+		return &domain.PaymentMethod{Type: domain.PaymentMethodCard, Token: id}, nil
+	}
+	return h.service.GetPaymentMethod(r.Context(), intent.UserID, id)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+	pb "github.com/sapliy/fintech-ecosystem/proto/ledger"
+)
+
+// defaultPayoutDelay is how long captured payments wait before they are
+// batched for payout
+const defaultPayoutDelay = 24 * time.Hour
+
+// RunPayouts batches the payments captured before the payout delay and
+// moves every pending batch to its merchant's payable balance
+func (h *PaymentHandler) RunPayouts(ctx context.Context) {
+	created, err := h.service.CreatePayoutBatches(ctx, time.Now().Add(-h.payoutDelay), 1000)
+	if err != nil {
+		log.Printf("Failed to create payout batches: %v", err)
+	}
+	if len(created) > 0 {
+		log.Printf("Created %d payout batches", len(created))
+	}
+
+	if h.ledgerClient == nil {
+		return
+	}
+	// Batches whose transfer failed before are retried; the Ledger ignores
+	// legs it has already recorded
+	pending, err := h.service.ListPendingPayoutBatches(ctx, 100)
+	if err != nil {
+		log.Printf("Failed to list pending payout batches: %v", err)
+		return
+	}
+	for i := range pending {
+		if err := h.postPayoutBatch(ctx, &pending[i]); err != nil {
+			log.Printf("Failed to post payout batch %s: %v", pending[i].ID, err)
+		}
+	}
+}
+
+// StartPayoutSchedule runs payouts every interval until the context is
+// cancelled. A run in progress is not interrupted, so shutdown never leaves
+// a batch half posted to the Ledger.
+func (h *PaymentHandler) StartPayoutSchedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.RunPayouts(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+// postPayoutBatch moves a batch's amount from the merchant's pending
+// account to its payable account, in the merchant's settlement currency.
+// Each leg is balanced against the Ledger's system account, so together
+// they leave it unchanged.
+func (h *PaymentHandler) postPayoutBatch(ctx context.Context, batch *domain.PayoutBatch) error {
+	amount, _, settlementCurrency := h.settlementAmounts(ctx, &domain.PaymentIntent{
+		ID:             batch.ID,
+		UserID:         batch.UserID,
+		Currency:       batch.Currency,
+		AmountCaptured: batch.Amount,
+	})
+
+	description := "Payout batch " + batch.ID
+	resp, err := h.ledgerClient.BulkRecordTransactions(ctx, &pb.BulkRecordRequest{
+		Transactions: []*pb.RecordTransactionRequest{
+			{
+				AccountId:   batch.LedgerAccount,
+				Amount:      -amount,
+				Currency:    settlementCurrency,
+				Description: description,
+				ReferenceId: batch.ID + ":pending",
+				ZoneId:      batch.ZoneID,
+				Mode:        batch.Mode,
+			},
+			{
+				AccountId:   domain.PayableAccount(batch.UserID),
+				Amount:      amount,
+				Currency:    settlementCurrency,
+				Description: description,
+				ReferenceId: batch.ID + ":payable",
+				ZoneId:      batch.ZoneID,
+				Mode:        batch.Mode,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range resp.Responses {
+		if r.Status != "recorded" {
+			return fmt.Errorf("ledger rejected a leg: %s", r.Status)
+		}
+	}
+	return h.service.MarkPayoutBatchPosted(ctx, batch)
+}
+
+// ListPayouts lists the caller's payout batches with the payments each
+// settled, newest first. The limit query parameter defaults to 20.
+func (h *PaymentHandler) ListPayouts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			jsonutil.WriteErrorJSON(w, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	batches, err := h.service.ListPayoutBatches(r.Context(), userID, limit)
+	if err != nil {
+		log.Printf("Failed to list payouts: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list payouts"})
+		return
+	}
+	if batches == nil {
+		batches = []domain.PayoutBatch{}
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, batches)
+}
+
+// GetPayout returns one of the caller's payout batches with its payments
+func (h *PaymentHandler) GetPayout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	batch, err := h.service.GetPayoutBatch(r.Context(), userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, domain.ErrPayoutBatchNotFound) {
+			jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to get payout: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal Server Error"})
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, batch)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/bank"
+)
+
+// refundAdapter returns the bank adapter of the payment method an intent was
+// collected with, which its refunds must go through
+func (h *PaymentHandler) refundAdapter(intent *domain.PaymentIntent) (bank.Client, error) {
+	if intent.ProviderTransactionID == "" {
+		return nil, errors.New("payment has no bank transaction to refund")
+	}
+	adapter, err := h.banks.Adapter(intent.PaymentMethodType)
+	if err != nil {
+		return nil, fmt.Errorf("%s refunds are not supported", intent.PaymentMethodType)
+	}
+	return adapter, nil
+}
+
+// SyncPendingRefunds asks the bank about the refunds it has not settled yet.
+// Settled refunds are completed; rejected ones fail and release their amount.
+func (h *PaymentHandler) SyncPendingRefunds(ctx context.Context) {
+	refunds, err := h.service.ListPendingRefunds(ctx, 100)
+	if err != nil {
+		log.Printf("Failed to list pending refunds: %v", err)
+		return
+	}
+	for i := range refunds {
+		refund := &refunds[i]
+		intent, err := h.service.GetPaymentIntent(ctx, refund.PaymentIntentID)
+		if err != nil || intent == nil {
+			log.Printf("Failed to get payment intent of refund %s: %v", refund.ID, err)
+			continue
+		}
+		adapter, err := h.refundAdapter(intent)
+		if err != nil {
+			log.Printf("Failed to sync refund %s: %v", refund.ID, err)
+			continue
+		}
+		result, err := adapter.GetStatus(ctx, refund.ProviderRefundID)
+		if err != nil {
+			log.Printf("Failed to get bank status of refund %s: %v", refund.ID, err)
+			continue
+		}
+
+		switch result.Status {
+		case bank.StatusSuccess:
+			if err := h.service.CompleteRefund(ctx, intent, refund); err != nil {
+				log.Printf("Failed to complete refund %s: %v", refund.ID, err)
+				continue
+			}
+			if h.webhooks != nil {
+				if err := h.webhooks.Publish(ctx, domain.EventPaymentRefunded, intent); err != nil {
+					log.Printf("Failed to publish %s webhooks for %s: %v", domain.EventPaymentRefunded, intent.ID, err)
+				}
+			}
+		case bank.StatusFailed:
+			log.Printf("Bank refund %s of %s failed", refund.ProviderRefundID, refund.ID)
+			if _, err := h.service.FailRefund(ctx, intent, refund); err != nil {
+				log.Printf("Failed to release refund %s: %v", refund.ID, err)
+			}
+		}
+	}
+}
+
+// StartRefundSync syncs pending refunds every interval until the context is
+// cancelled
+func (h *PaymentHandler) StartRefundSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.SyncPendingRefunds(context.WithoutCancel(ctx))
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sapliy/fintech-ecosystem/internal/policy"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// middleware wraps a handler, e.g. to authenticate the caller
+type middleware func(http.HandlerFunc) http.HandlerFunc
+
+// route is an endpoint of the payments API and the middleware chain it runs
+// behind, outermost first
+type route struct {
+	name       string
+	method     string
+	path       string
+	handler    http.HandlerFunc
+	middleware []middleware
+}
+
+// routes is the payments API. The gateway forwards /v1/payments/* here with
+// the prefix stripped, so /v1/payments/intents arrives as /intents.
+func (h *PaymentHandler) routes() []route {
+	auth := h.authenticate
+	idempotent := h.IdempotencyMiddleware
+
+	return []route{
+		// Payment intents
+		{"list_intents", http.MethodGet, "/intents", h.ListPaymentIntents, []middleware{auth}},
+		{"create_intent", http.MethodPost, "/intents", h.CreatePaymentIntent, []middleware{auth, idempotent}},
+		{"get_intent", http.MethodGet, "/intents/{id}", h.GetPaymentIntent, nil},
+		{"confirm_intent", http.MethodPost, "/intents/{id}/confirm", h.ConfirmPaymentIntent, []middleware{auth, idempotent}},
+		{"capture_intent", http.MethodPost, "/intents/{id}/capture", h.CapturePaymentIntent, []middleware{auth, idempotent}},
+		{"refund_intent", http.MethodPost, "/intents/{id}/refund", h.RefundPaymentIntent, []middleware{auth, idempotent}},
+		{"list_refunds", http.MethodGet, "/intents/{id}/refunds", h.ListRefunds, nil},
+
+		// Merchant webhook endpoints and their delivery logs
+		{"list_webhooks", http.MethodGet, "/webhooks", h.ListWebhookEndpoints, []middleware{auth}},
+		{"create_webhook", http.MethodPost, "/webhooks", h.CreateWebhookEndpoint, []middleware{auth}},
+		{"delete_webhook", http.MethodDelete, "/webhooks/{id}", h.DeleteWebhookEndpoint, []middleware{auth}},
+		{"list_webhook_deliveries", http.MethodGet, "/webhooks/{id}/deliveries", h.ListWebhookDeliveries, []middleware{auth}},
+
+		// Merchant settings, e.g. the settlement currency
+		{"get_settings", http.MethodGet, "/settings", h.GetMerchantSettings, []middleware{auth}},
+		{"update_settings", http.MethodPut, "/settings", h.UpdateMerchantSettings, []middleware{auth}},
+
+		// Tokenized payment methods
+		{"list_payment_methods", http.MethodGet, "/payment_methods", h.ListPaymentMethods, []middleware{auth}},
+		{"create_payment_method", http.MethodPost, "/payment_methods", h.CreatePaymentMethod, []middleware{auth}},
+		{"get_payment_method", http.MethodGet, "/payment_methods/{id}", h.GetPaymentMethod, []middleware{auth}},
+
+		// Disputes, opened by bank webhooks or by merchants. The bank
+		// authenticates with its webhook secret instead of a user.
+		{"list_disputes", http.MethodGet, "/disputes", h.ListDisputes, []middleware{auth}},
+		{"create_dispute", http.MethodPost, "/disputes", h.CreateDispute, nil},
+		{"get_dispute", http.MethodGet, "/disputes/{id}", h.GetDispute, nil},
+		{"add_dispute_evidence", http.MethodPost, "/disputes/{id}/evidence", h.AddDisputeEvidence, nil},
+		{"submit_dispute", http.MethodPost, "/disputes/{id}/submit", h.SubmitDispute, nil},
+		{"close_dispute", http.MethodPost, "/disputes/{id}/close", h.CloseDispute, nil},
+
+		// Settlement batches and the payments they paid out
+		{"list_payouts", http.MethodGet, "/payouts", h.ListPayouts, []middleware{auth}},
+		{"get_payout", http.MethodGet, "/payouts/{id}", h.GetPayout, []middleware{auth}},
+	}
+}
+
+// setupRoutes builds the router of the payments API. Every route is traced
+// under its path template.
+func setupRoutes(h *PaymentHandler) *mux.Router {
+	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "Not Found"})
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonutil.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+	})
+
+	for _, rt := range h.routes() {
+		handler := rt.handler
+		for i := len(rt.middleware) - 1; i >= 0; i-- {
+			handler = rt.middleware[i](handler)
+		}
+		r.HandleFunc(rt.path, traced(rt.method, rt.path, handler)).Methods(rt.method).Name(rt.name)
+	}
+	return r
+}
+
+// traced names the request span after the route template, so that
+// /intents/{id} is one operation rather than one per intent
+func traced(method, path string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(method + " " + path)
+		span.SetAttributes(attribute.String("http.route", path))
+		next(w, r)
+	}
+}
+
+// authenticate rejects requests without a caller before they reach the
+// handler
+func (h *PaymentHandler) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := h.requireUser(w, r); !ok {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorize checks the policy action for the caller, with the attributes of
+// the payment or refund it would make, answering the request when denied
+func (h *PaymentHandler) authorize(w http.ResponseWriter, r *http.Request, action policy.Action, resource map[string]interface{}) bool {
+	if h.policies == nil {
+		return true
+	}
+	return h.policies.Authorize(w, r, action, resource)
+}
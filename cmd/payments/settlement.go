@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+type UpdateMerchantSettingsRequest struct {
+	SettlementCurrency string `json:"settlement_currency"` // Empty settles in each payment's currency
+}
+
+// GetMerchantSettings returns the caller's settings
+func (h *PaymentHandler) GetMerchantSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	settings, err := h.service.GetMerchantSettings(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to get merchant settings: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get settings"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, settings)
+}
+
+// UpdateMerchantSettings replaces the caller's settings
+func (h *PaymentHandler) UpdateMerchantSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateMerchantSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+	settings := &domain.MerchantSettings{UserID: userID, SettlementCurrency: req.SettlementCurrency}
+	if err := h.service.SaveMerchantSettings(r.Context(), settings); err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, settings)
+}
+
+// settlementAmounts converts a payment's captured amount and application
+// fee to the merchant's settlement currency. Payments stay in their own
+// currency when the merchant has none or no rate is available.
+func (h *PaymentHandler) settlementAmounts(ctx context.Context, intent *domain.PaymentIntent) (amount, fee int64, settlementCurrency string) {
+	amount, fee, settlementCurrency = intent.AmountCaptured, intent.ApplicationFeeAmount, intent.Currency
+	if h.fx == nil {
+		return
+	}
+
+	settings, err := h.service.GetMerchantSettings(ctx, intent.UserID)
+	if err != nil {
+		log.Printf("Failed to get settlement currency of %s: %v", intent.UserID, err)
+		return
+	}
+	if settings.SettlementCurrency == "" || settings.SettlementCurrency == intent.Currency {
+		return
+	}
+
+	convertedAmount, err := h.fx.Convert(ctx, intent.AmountCaptured, intent.Currency, settings.SettlementCurrency)
+	if err != nil {
+		log.Printf("Failed to convert %s from %s to %s: %v", intent.ID, intent.Currency, settings.SettlementCurrency, err)
+		return
+	}
+	convertedFee, err := h.fx.Convert(ctx, intent.ApplicationFeeAmount, intent.Currency, settings.SettlementCurrency)
+	if err != nil {
+		log.Printf("Failed to convert the fee of %s from %s to %s: %v", intent.ID, intent.Currency, settings.SettlementCurrency, err)
+		return
+	}
+	return convertedAmount, convertedFee, settings.SettlementCurrency
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// drainer shuts the service down without dropping work: /ready fails for
+// the drain delay so that load balancers stop routing here, then requests
+// in flight and background work get up to the timeout to finish
+type drainer struct {
+	delay    time.Duration
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+// Ready answers /ready, failing once the shutdown started
+func (d *drainer) Ready(w http.ResponseWriter, r *http.Request) {
+	if d.draining.Load() {
+		jsonutil.WriteJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// Shutdown stops the server after the drain delay and waits for the
+// requests it is serving. The after functions then run in order with what
+// is left of the timeout, e.g. to wait for workers or flush queues. It
+// returns the error of stopping the server.
+func (d *drainer) Shutdown(srv *http.Server, after ...func(ctx context.Context)) error {
+	d.draining.Store(true)
+	time.Sleep(d.delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	for _, fn := range after {
+		fn(ctx)
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+type CreateWebhookEndpointRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // Empty subscribes to every event
+}
+
+// CreateWebhookEndpoint registers an endpoint for the caller's payment
+// events. The signing secret is only returned in this response.
+func (h *PaymentHandler) CreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateWebhookEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonutil.WriteErrorJSON(w, "Invalid request body")
+		return
+	}
+
+	endpoint := &domain.WebhookEndpoint{
+		UserID: userID,
+		ZoneID: r.Header.Get("X-Zone-ID"),
+		URL:    req.URL,
+		Events: req.Events,
+	}
+	if err := h.webhooks.CreateEndpoint(r.Context(), endpoint); err != nil {
+		jsonutil.WriteErrorJSON(w, err.Error())
+		return
+	}
+
+	jsonutil.WriteJSON(w, http.StatusCreated, endpoint)
+}
+
+func (h *PaymentHandler) ListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	endpoints, err := h.webhooks.ListEndpoints(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to list webhook endpoints: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Failed to list webhook endpoints"})
+		return
+	}
+	for _, endpoint := range endpoints {
+		endpoint.Secret = ""
+	}
+	if endpoints == nil {
+		endpoints = []*domain.WebhookEndpoint{}
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, endpoints)
+}
+
+func (h *PaymentHandler) DeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.webhooks.DeleteEndpoint(r.Context(), userID, mux.Vars(r)["id"]); err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries returns the delivery log of an endpoint, newest
+// first
+func (h *PaymentHandler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		fmt.Sscanf(limitStr, "%d", &limit)
+	}
+
+	deliveries, err := h.webhooks.ListDeliveries(r.Context(), userID, mux.Vars(r)["id"], limit)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*domain.WebhookDelivery{}
+	}
+
+	jsonutil.WriteJSON(w, http.StatusOK, deliveries)
+}
+
+// requireUser returns the authenticated caller, who owns the endpoints and
+// payment methods
+func (h *PaymentHandler) requireUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, err := extractUserIDFromToken(r)
+	if err != nil {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
+		return "", false
+	}
+	if userID == "" {
+		jsonutil.WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+		return "", false
+	}
+	return userID, true
+}
+
+func (h *PaymentHandler) writeWebhookError(w http.ResponseWriter, err error) {
+	if err == domain.ErrWebhookEndpointNotFound {
+		jsonutil.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("Webhook endpoint request failed: %v", err)
+	jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal Server Error"})
+}
+
+// publishWebhook delivers a payment event to the merchant's endpoints
+func (h *PaymentHandler) publishWebhook(r *http.Request, eventType string, intent *domain.PaymentIntent) {
+	if h.webhooks == nil {
+		return
+	}
+	if err := h.webhooks.Publish(r.Context(), eventType, intent); err != nil {
+		log.Printf("Failed to publish %s webhooks for %s: %v", eventType, intent.ID, err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/sapliy/fintech-ecosystem/internal/wallet/api"
 	"github.com/sapliy/fintech-ecosystem/internal/wallet/domain"
 	"github.com/sapliy/fintech-ecosystem/internal/wallet/infrastructure"
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
 	"github.com/sapliy/fintech-ecosystem/pkg/monitoring"
 	"github.com/sapliy/fintech-ecosystem/pkg/observability"
 	ledgerpb "github.com/sapliy/fintech-ecosystem/proto/ledger"
@@ -65,6 +66,12 @@ func main() {
 	mux.HandleFunc("/v1/wallets/top-up", handler.TopUp)
 	mux.HandleFunc("/v1/wallets/transfer", handler.Transfer)
 	mux.HandleFunc("/v1/wallets/", handler.GetWallet)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		jsonutil.WriteJSON(w, http.StatusOK, map[string]string{
+			"status":  "active",
+			"service": "wallet",
+		})
+	})
 
 	// Wrap handler with OpenTelemetry and Prometheus
 	otelHandler := otelhttp.NewHandler(mux, "wallet-request")
@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	ErrAPIKeyNotFound   = errors.New("api key not found")
+	ErrAPIKeyRevoked    = errors.New("api key is revoked")
+	ErrInvalidKeyExpiry = errors.New("expires_at must be in the future")
+)
+
+// MaxRotationGracePeriod bounds how long a rotated key keeps working next
+// to its replacement
+const MaxRotationGracePeriod = 7 * 24 * time.Hour
+
+// ListAPIKeys returns the user's keys, newest first, revoked ones included
+func (s *AuthService) ListAPIKeys(ctx context.Context, userID string) ([]APIKey, error) {
+	return s.repo.ListAPIKeys(ctx, userID)
+}
+
+// GetAPIKey returns one of the user's keys. Others' keys are not found.
+func (s *AuthService) GetAPIKey(ctx context.Context, userID, id string) (*APIKey, error) {
+	key, err := s.repo.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || key.UserID != userID {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// SetAPIKeyExpiry sets when the key stops working, or removes its expiry
+// with nil
+func (s *AuthService) SetAPIKeyExpiry(ctx context.Context, key *APIKey, expiresAt *time.Time) error {
+	if key.RevokedAt != nil {
+		return ErrAPIKeyRevoked
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return ErrInvalidKeyExpiry
+	}
+	if err := s.repo.SetAPIKeyExpiry(ctx, key.ID, expiresAt); err != nil {
+		return err
+	}
+	key.ExpiresAt = expiresAt
+	return nil
+}
+
+// RotateAPIKey creates the replacement of a key, which keeps working for the
+// grace period so that its users can switch over. Without a grace period
+// the old key is revoked at once.
+func (s *AuthService) RotateAPIKey(ctx context.Context, old, replacement *APIKey, grace time.Duration) error {
+	if old.RevokedAt != nil {
+		return ErrAPIKeyRevoked
+	}
+	replacement.RotatedFrom = old.ID
+	if err := s.repo.CreateAPIKey(ctx, replacement); err != nil {
+		return err
+	}
+
+	if grace <= 0 {
+		if err := s.repo.RevokeAPIKey(ctx, old.ID); err != nil {
+			return err
+		}
+		now := time.Now()
+		old.RevokedAt = &now
+		return nil
+	}
+	expiresAt := time.Now().Add(grace)
+	if old.ExpiresAt != nil && old.ExpiresAt.Before(expiresAt) {
+		// Rotating never extends a key
+		return nil
+	}
+	if err := s.repo.SetAPIKeyExpiry(ctx, old.ID, &expiresAt); err != nil {
+		return err
+	}
+	old.ExpiresAt = &expiresAt
+	return nil
+}
+
+// RevokeAPIKey stops the key from working
+func (s *AuthService) RevokeAPIKey(ctx context.Context, key *APIKey) error {
+	if key.RevokedAt != nil {
+		return nil
+	}
+	if err := s.repo.RevokeAPIKey(ctx, key.ID); err != nil {
+		return err
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	return nil
+}
+
+// ValidateAPIKey returns the usable key with the hash, or nil, and records
+// its use. Failing to record it is logged only.
+func (s *AuthService) ValidateAPIKey(ctx context.Context, hash string) (*APIKey, error) {
+	key, err := s.repo.GetAPIKeyByHash(ctx, hash)
+	if err != nil || key == nil || !key.Usable(time.Now()) {
+		return nil, err
+	}
+	if err := s.repo.TouchAPIKey(ctx, key.ID); err != nil {
+		log.Printf("Failed to record use of api key %s: %v", key.ID, err)
+	}
+	return key, nil
+}
+
+// ExpireAPIKeys revokes the keys past their expiry, returning how many
+func (s *AuthService) ExpireAPIKeys(ctx context.Context) (int, error) {
+	keys, err := s.repo.RevokeExpiredAPIKeys(ctx, time.Now())
+	return len(keys), err
+}
+
+// RunAPIKeyExpiry revokes expired keys every interval until ctx is done.
+// Keys are refused once expired regardless; this keeps their state current.
+func (s *AuthService) RunAPIKeyExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := s.ExpireAPIKeys(ctx)
+			if err != nil {
+				log.Printf("Failed to revoke expired api keys: %v", err)
+			} else if expired > 0 {
+				log.Printf("Revoked %d expired api keys", expired)
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 type MockRepository struct {
@@ -18,6 +19,13 @@ type MockRepository struct {
 	CreateEmailVerificationTokenFunc   func(ctx context.Context, token *EmailVerificationToken) error
 	GetEmailVerificationTokenFunc      func(ctx context.Context, tokenHash string) (*EmailVerificationToken, error)
 	MarkEmailVerificationTokenUsedFunc func(ctx context.Context, tokenHash string) error
+	CreateSessionFunc                  func(ctx context.Context, session *Session) error
+	GetSessionFunc                     func(ctx context.Context, id string) (*Session, error)
+	GetSessionByTokenHashFunc          func(ctx context.Context, tokenHash string) (*Session, error)
+	ListUserSessionsFunc               func(ctx context.Context, userID string) ([]Session, error)
+	RotateSessionTokenFunc             func(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) (bool, error)
+	RevokeSessionFunc                  func(ctx context.Context, id string) error
+	SetSessionOrgFunc                  func(ctx context.Context, id, orgID string) error
 	CreateOrganizationFunc             func(ctx context.Context, name, domain string) (*Organization, error)
 	GetOrganizationFunc                func(ctx context.Context, id string) (*Organization, error)
 	AddMemberFunc                      func(ctx context.Context, userID, orgID, role string) error
@@ -26,8 +34,35 @@ type MockRepository struct {
 	ListOrgMembersFunc                 func(ctx context.Context, orgID string) ([]Membership, error)
 	GetUserMembershipsFunc             func(ctx context.Context, userID string) ([]Membership, error)
 	GetMembershipFunc                  func(ctx context.Context, userID, orgID string) (*Membership, error)
+	ListUserOrganizationsFunc          func(ctx context.Context, userID string) ([]UserOrganization, error)
+	SetOrgTwoFactorRequiredFunc        func(ctx context.Context, orgID string, required bool) error
+	UserRequiresTwoFactorFunc          func(ctx context.Context, userID string) (bool, error)
+	GetTwoFactorFunc                   func(ctx context.Context, userID string) (*TwoFactor, error)
+	SaveTwoFactorSecretFunc            func(ctx context.Context, userID, secret string) error
+	EnableTwoFactorFunc                func(ctx context.Context, userID string, recoveryCodeHashes []string) error
+	DisableTwoFactorFunc               func(ctx context.Context, userID string) error
+	UseTOTPStepFunc                    func(ctx context.Context, userID string, step int64) (bool, error)
+	ReplaceRecoveryCodesFunc           func(ctx context.Context, userID string, codeHashes []string) error
+	UseRecoveryCodeFunc                func(ctx context.Context, userID, codeHash string) (bool, error)
+	CountRecoveryCodesFunc             func(ctx context.Context, userID string) (int, error)
+	CreateTwoFactorChallengeFunc       func(ctx context.Context, challenge *TwoFactorChallenge) error
+	GetTwoFactorChallengeFunc          func(ctx context.Context, id string) (*TwoFactorChallenge, error)
+	AttemptTwoFactorChallengeFunc      func(ctx context.Context, id string) (int, error)
+	CompleteTwoFactorChallengeFunc     func(ctx context.Context, id string) (bool, error)
+	CreateInvitationFunc               func(ctx context.Context, invitation *Invitation) error
+	GetInvitationFunc                  func(ctx context.Context, id string) (*Invitation, error)
+	GetInvitationByTokenHashFunc       func(ctx context.Context, tokenHash string) (*Invitation, error)
+	ListPendingInvitationsFunc         func(ctx context.Context, orgID string) ([]Invitation, error)
+	AcceptInvitationFunc               func(ctx context.Context, id, userID string) (bool, error)
+	RevokeInvitationFunc               func(ctx context.Context, id string) error
 	CreateAPIKeyFunc                   func(ctx context.Context, key *APIKey) error
 	GetAPIKeyByHashFunc                func(ctx context.Context, hash string) (*APIKey, error)
+	GetAPIKeyFunc                      func(ctx context.Context, id string) (*APIKey, error)
+	ListAPIKeysFunc                    func(ctx context.Context, userID string) ([]APIKey, error)
+	SetAPIKeyExpiryFunc                func(ctx context.Context, id string, expiresAt *time.Time) error
+	RevokeAPIKeyFunc                   func(ctx context.Context, id string) error
+	TouchAPIKeyFunc                    func(ctx context.Context, id string) error
+	RevokeExpiredAPIKeysFunc           func(ctx context.Context, now time.Time) ([]APIKey, error)
 	GetClientByIDFunc                  func(ctx context.Context, clientID string) (*OAuthClient, error)
 	CreateOAuthClientFunc              func(ctx context.Context, client *OAuthClient) error
 	AddRedirectURIFunc                 func(ctx context.Context, clientID, redirectURI string) error
@@ -219,3 +254,249 @@ func (m *MockRepository) MarkEmailVerificationTokenUsed(ctx context.Context, tok
 	}
 	return nil
 }
+
+// Session methods
+
+func (m *MockRepository) CreateSession(ctx context.Context, session *Session) error {
+	if m.CreateSessionFunc != nil {
+		return m.CreateSessionFunc(ctx, session)
+	}
+	return nil
+}
+
+func (m *MockRepository) GetSession(ctx context.Context, id string) (*Session, error) {
+	if m.GetSessionFunc != nil {
+		return m.GetSessionFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) GetSessionByTokenHash(ctx context.Context, tokenHash string) (*Session, error) {
+	if m.GetSessionByTokenHashFunc != nil {
+		return m.GetSessionByTokenHashFunc(ctx, tokenHash)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) ListUserSessions(ctx context.Context, userID string) ([]Session, error) {
+	if m.ListUserSessionsFunc != nil {
+		return m.ListUserSessionsFunc(ctx, userID)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) RotateSessionToken(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) (bool, error) {
+	if m.RotateSessionTokenFunc != nil {
+		return m.RotateSessionTokenFunc(ctx, id, oldHash, newHash, expiresAt)
+	}
+	return true, nil
+}
+
+func (m *MockRepository) RevokeSession(ctx context.Context, id string) error {
+	if m.RevokeSessionFunc != nil {
+		return m.RevokeSessionFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *MockRepository) SetSessionOrg(ctx context.Context, id, orgID string) error {
+	if m.SetSessionOrgFunc != nil {
+		return m.SetSessionOrgFunc(ctx, id, orgID)
+	}
+	return nil
+}
+
+// Organization and invitation methods
+
+func (m *MockRepository) ListUserOrganizations(ctx context.Context, userID string) ([]UserOrganization, error) {
+	if m.ListUserOrganizationsFunc != nil {
+		return m.ListUserOrganizationsFunc(ctx, userID)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) CreateInvitation(ctx context.Context, invitation *Invitation) error {
+	if m.CreateInvitationFunc != nil {
+		return m.CreateInvitationFunc(ctx, invitation)
+	}
+	return nil
+}
+
+func (m *MockRepository) GetInvitation(ctx context.Context, id string) (*Invitation, error) {
+	if m.GetInvitationFunc != nil {
+		return m.GetInvitationFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*Invitation, error) {
+	if m.GetInvitationByTokenHashFunc != nil {
+		return m.GetInvitationByTokenHashFunc(ctx, tokenHash)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) ListPendingInvitations(ctx context.Context, orgID string) ([]Invitation, error) {
+	if m.ListPendingInvitationsFunc != nil {
+		return m.ListPendingInvitationsFunc(ctx, orgID)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) AcceptInvitation(ctx context.Context, id, userID string) (bool, error) {
+	if m.AcceptInvitationFunc != nil {
+		return m.AcceptInvitationFunc(ctx, id, userID)
+	}
+	return true, nil
+}
+
+func (m *MockRepository) RevokeInvitation(ctx context.Context, id string) error {
+	if m.RevokeInvitationFunc != nil {
+		return m.RevokeInvitationFunc(ctx, id)
+	}
+	return nil
+}
+
+// APIKey lifecycle methods
+
+func (m *MockRepository) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	if m.GetAPIKeyFunc != nil {
+		return m.GetAPIKeyFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) ListAPIKeys(ctx context.Context, userID string) ([]APIKey, error) {
+	if m.ListAPIKeysFunc != nil {
+		return m.ListAPIKeysFunc(ctx, userID)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) SetAPIKeyExpiry(ctx context.Context, id string, expiresAt *time.Time) error {
+	if m.SetAPIKeyExpiryFunc != nil {
+		return m.SetAPIKeyExpiryFunc(ctx, id, expiresAt)
+	}
+	return nil
+}
+
+func (m *MockRepository) RevokeAPIKey(ctx context.Context, id string) error {
+	if m.RevokeAPIKeyFunc != nil {
+		return m.RevokeAPIKeyFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *MockRepository) TouchAPIKey(ctx context.Context, id string) error {
+	if m.TouchAPIKeyFunc != nil {
+		return m.TouchAPIKeyFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *MockRepository) RevokeExpiredAPIKeys(ctx context.Context, now time.Time) ([]APIKey, error) {
+	if m.RevokeExpiredAPIKeysFunc != nil {
+		return m.RevokeExpiredAPIKeysFunc(ctx, now)
+	}
+	return nil, nil
+}
+
+// Two-factor methods
+
+func (m *MockRepository) SetOrgTwoFactorRequired(ctx context.Context, orgID string, required bool) error {
+	if m.SetOrgTwoFactorRequiredFunc != nil {
+		return m.SetOrgTwoFactorRequiredFunc(ctx, orgID, required)
+	}
+	return nil
+}
+
+func (m *MockRepository) UserRequiresTwoFactor(ctx context.Context, userID string) (bool, error) {
+	if m.UserRequiresTwoFactorFunc != nil {
+		return m.UserRequiresTwoFactorFunc(ctx, userID)
+	}
+	return false, nil
+}
+
+func (m *MockRepository) GetTwoFactor(ctx context.Context, userID string) (*TwoFactor, error) {
+	if m.GetTwoFactorFunc != nil {
+		return m.GetTwoFactorFunc(ctx, userID)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) SaveTwoFactorSecret(ctx context.Context, userID, secret string) error {
+	if m.SaveTwoFactorSecretFunc != nil {
+		return m.SaveTwoFactorSecretFunc(ctx, userID, secret)
+	}
+	return nil
+}
+
+func (m *MockRepository) EnableTwoFactor(ctx context.Context, userID string, recoveryCodeHashes []string) error {
+	if m.EnableTwoFactorFunc != nil {
+		return m.EnableTwoFactorFunc(ctx, userID, recoveryCodeHashes)
+	}
+	return nil
+}
+
+func (m *MockRepository) DisableTwoFactor(ctx context.Context, userID string) error {
+	if m.DisableTwoFactorFunc != nil {
+		return m.DisableTwoFactorFunc(ctx, userID)
+	}
+	return nil
+}
+
+func (m *MockRepository) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	if m.UseTOTPStepFunc != nil {
+		return m.UseTOTPStepFunc(ctx, userID, step)
+	}
+	return true, nil
+}
+
+func (m *MockRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	if m.ReplaceRecoveryCodesFunc != nil {
+		return m.ReplaceRecoveryCodesFunc(ctx, userID, codeHashes)
+	}
+	return nil
+}
+
+func (m *MockRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	if m.UseRecoveryCodeFunc != nil {
+		return m.UseRecoveryCodeFunc(ctx, userID, codeHash)
+	}
+	return false, nil
+}
+
+func (m *MockRepository) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	if m.CountRecoveryCodesFunc != nil {
+		return m.CountRecoveryCodesFunc(ctx, userID)
+	}
+	return 0, nil
+}
+
+func (m *MockRepository) CreateTwoFactorChallenge(ctx context.Context, challenge *TwoFactorChallenge) error {
+	if m.CreateTwoFactorChallengeFunc != nil {
+		return m.CreateTwoFactorChallengeFunc(ctx, challenge)
+	}
+	return nil
+}
+
+func (m *MockRepository) GetTwoFactorChallenge(ctx context.Context, id string) (*TwoFactorChallenge, error) {
+	if m.GetTwoFactorChallengeFunc != nil {
+		return m.GetTwoFactorChallengeFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) AttemptTwoFactorChallenge(ctx context.Context, id string) (int, error) {
+	if m.AttemptTwoFactorChallengeFunc != nil {
+		return m.AttemptTwoFactorChallengeFunc(ctx, id)
+	}
+	return 1, nil
+}
+
+func (m *MockRepository) CompleteTwoFactorChallenge(ctx context.Context, id string) (bool, error) {
+	if m.CompleteTwoFactorChallengeFunc != nil {
+		return m.CompleteTwoFactorChallengeFunc(ctx, id)
+	}
+	return true, nil
+}
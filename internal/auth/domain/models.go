@@ -36,13 +36,58 @@ type EmailVerificationToken struct {
 	CreatedAt time.Time  `json:"created_at"`
 }
 
+// Session is a login, kept alive by rotating refresh tokens until it
+// expires or is revoked.
+type Session struct {
+	ID                string     `json:"id"`
+	UserID            string     `json:"user_id"`
+	RefreshTokenHash  string     `json:"-"`
+	PreviousTokenHash string     `json:"-"`                // Rotated out, its reuse revokes the session
+	OrgID             string     `json:"org_id,omitempty"` // The organization the session acts for
+	UserAgent         string     `json:"user_agent,omitempty"`
+	IPAddress         string     `json:"ip_address,omitempty"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// TwoFactor is a user's TOTP enrollment, pending until confirmed with a
+// code.
+type TwoFactor struct {
+	UserID       string     `json:"user_id"`
+	Secret       string     `json:"-"`
+	EnabledAt    *time.Time `json:"enabled_at,omitempty"`
+	LastUsedStep int64      `json:"-"` // Codes of this step or older are refused
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Two-factor methods of login challenges
+const (
+	TwoFactorMethodTOTP  = "totp"
+	TwoFactorMethodEmail = "email" // Security codes for users who must enroll
+)
+
+// TwoFactorChallenge is a login waiting for its second factor.
+type TwoFactorChallenge struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Method      string     `json:"method"`
+	CodeHash    string     `json:"-"` // The emailed security code
+	Attempts    int        `json:"attempts"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
 // Organization represents a team or company.
 type Organization struct {
-	ID             string    `json:"id"`
-	Name           string    `json:"name"`
-	Domain         string    `json:"domain,omitempty"`
-	RateLimitQuota int       `json:"rate_limit_quota"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	Domain           string    `json:"domain,omitempty"`
+	RateLimitQuota   int       `json:"rate_limit_quota"`
+	RequireTwoFactor bool      `json:"require_two_factor"` // Members must use two-factor authentication
+	CreatedAt        time.Time `json:"created_at"`
 }
 
 // Membership represents a user's role in an organization.
@@ -53,6 +98,31 @@ type Membership struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// UserOrganization is an organization as seen by one of its members.
+type UserOrganization struct {
+	Organization
+	Role string `json:"role"`
+}
+
+// Invitation asks someone, by email, to join an organization with a role.
+type Invitation struct {
+	ID         string     `json:"id"`
+	OrgID      string     `json:"org_id"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	TokenHash  string     `json:"-"`
+	InvitedBy  string     `json:"invited_by,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Pending reports whether the invitation can still be accepted at the time.
+func (i *Invitation) Pending(at time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && at.Before(i.ExpiresAt)
+}
+
 // APIKey represents a secret key used for API authentication.
 type APIKey struct {
 	ID             string     `json:"id"`
@@ -69,6 +139,15 @@ type APIKey struct {
 	RateLimitQuota int        `json:"rate_limit_quota"` // Per minute, overrides Org quota if > 0
 	CreatedAt      time.Time  `json:"created_at"`
 	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	RotatedFrom    string     `json:"rotated_from,omitempty"` // The key this one replaced
+}
+
+// Usable reports whether the key authenticates at the time: it is neither
+// revoked nor expired.
+func (k *APIKey) Usable(at time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || at.Before(*k.ExpiresAt))
 }
 
 // OAuthClient represents a registered OAuth client application.
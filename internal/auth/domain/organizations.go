@@ -0,0 +1,393 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotOrgMember            = errors.New("not a member of this organization")
+	ErrInvalidRole             = errors.New("invalid role")
+	ErrRoleAboveOwn            = errors.New("cannot manage a role above your own")
+	ErrLastOwner               = errors.New("an organization needs at least one owner")
+	ErrAlreadyMember           = errors.New("already a member of this organization")
+	ErrInvitationNotFound      = errors.New("invitation not found")
+	ErrInvitationClosed        = errors.New("invitation expired, accepted or revoked")
+	ErrInvitationEmailMismatch = errors.New("invitation was sent to another email address")
+)
+
+// InvitationTTL is how long an invitation can be accepted
+const InvitationTTL = 7 * 24 * time.Hour
+
+// EventOrgInvitation asks the notifications service to email an
+// invitation with its org_invitation template
+const EventOrgInvitation = "org.invitation"
+
+// roleRanks orders the roles of an organization, from the most powerful
+var roleRanks = map[string]int{
+	RoleOwner:     4,
+	RoleAdmin:     3,
+	RoleDeveloper: 2,
+	RoleFinance:   1,
+	RoleMember:    1,
+}
+
+// ValidRole reports whether the role is one of an organization's
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// CreateOrganizationWithOwner creates an organization owned by the user
+func (s *AuthService) CreateOrganizationWithOwner(ctx context.Context, userID, name, domain string) (*Organization, error) {
+	org, err := s.repo.CreateOrganization(ctx, name, domain)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.AddMember(ctx, userID, org.ID, RoleOwner); err != nil {
+		return nil, fmt.Errorf("failed to add owner to organization: %w", err)
+	}
+	return org, nil
+}
+
+// ListUserOrganizations returns the organizations the user belongs to
+func (s *AuthService) ListUserOrganizations(ctx context.Context, userID string) ([]UserOrganization, error) {
+	return s.repo.ListUserOrganizations(ctx, userID)
+}
+
+// GetMemberOrganization returns one of the user's organizations
+func (s *AuthService) GetMemberOrganization(ctx context.Context, userID, orgID string) (*UserOrganization, error) {
+	m, err := s.requireRole(ctx, userID, orgID, RoleMember)
+	if err != nil {
+		return nil, err
+	}
+	org, err := s.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, ErrNotOrgMember
+	}
+	return &UserOrganization{Organization: *org, Role: m.Role}, nil
+}
+
+// ListMembers returns the members of one of the user's organizations
+func (s *AuthService) ListMembers(ctx context.Context, userID, orgID string) ([]Membership, error) {
+	if _, err := s.requireRole(ctx, userID, orgID, RoleMember); err != nil {
+		return nil, err
+	}
+	return s.repo.ListOrgMembers(ctx, orgID)
+}
+
+// ChangeMemberRole gives a member another role. Admins manage the roles up
+// to their own, and only owners make or unmake owners. The last owner
+// stays one.
+func (s *AuthService) ChangeMemberRole(ctx context.Context, actorID, orgID, memberID, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	actor, err := s.requireRole(ctx, actorID, orgID, RoleAdmin)
+	if err != nil {
+		return err
+	}
+	member, err := s.repo.GetMembership(ctx, memberID, orgID)
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return ErrNotOrgMember
+	}
+	if roleRanks[role] > roleRanks[actor.Role] || roleRanks[member.Role] > roleRanks[actor.Role] {
+		return ErrRoleAboveOwn
+	}
+	if member.Role == RoleOwner && role != RoleOwner {
+		if err := s.keepAnOwner(ctx, orgID); err != nil {
+			return err
+		}
+	}
+	return s.repo.UpdateMemberRole(ctx, memberID, orgID, role)
+}
+
+// RemoveOrgMember removes a member from the organization. Members may
+// leave on their own; admins remove the members up to their own role.
+func (s *AuthService) RemoveOrgMember(ctx context.Context, actorID, orgID, memberID string) error {
+	member, err := s.repo.GetMembership(ctx, memberID, orgID)
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return ErrNotOrgMember
+	}
+	if actorID != memberID {
+		actor, err := s.requireRole(ctx, actorID, orgID, RoleAdmin)
+		if err != nil {
+			return err
+		}
+		if roleRanks[member.Role] > roleRanks[actor.Role] {
+			return ErrRoleAboveOwn
+		}
+	}
+	if member.Role == RoleOwner {
+		if err := s.keepAnOwner(ctx, orgID); err != nil {
+			return err
+		}
+	}
+	return s.repo.RemoveMember(ctx, memberID, orgID)
+}
+
+// keepAnOwner refuses to change an owner of the organization if they are
+// its last
+func (s *AuthService) keepAnOwner(ctx context.Context, orgID string) error {
+	members, err := s.repo.ListOrgMembers(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	owners := 0
+	for _, m := range members {
+		if m.Role == RoleOwner {
+			owners++
+		}
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// InviteMember invites someone by email to join the organization with a
+// role, up to the inviter's own. The invitation is emailed through the
+// notifications service with a link to accept it.
+func (s *AuthService) InviteMember(ctx context.Context, inviter *User, orgID, email, role string) (*Invitation, error) {
+	if role == "" {
+		role = RoleMember
+	}
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	actor, err := s.requireRole(ctx, inviter.ID, orgID, RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if roleRanks[role] > roleRanks[actor.Role] {
+		return nil, ErrRoleAboveOwn
+	}
+	org, err := s.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, ErrNotOrgMember
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	if invitee, err := s.repo.GetUserByEmail(ctx, email); err != nil {
+		return nil, err
+	} else if invitee != nil {
+		m, err := s.repo.GetMembership(ctx, invitee.ID, orgID)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			return nil, ErrAlreadyMember
+		}
+	}
+
+	rawToken, err := s.GenerateRandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	invitation := &Invitation{
+		ID:        uuid.New().String(),
+		OrgID:     orgID,
+		Email:     email,
+		Role:      role,
+		TokenHash: s.HashString(rawToken),
+		InvitedBy: inviter.ID,
+		ExpiresAt: now.Add(InvitationTTL),
+		CreatedAt: now,
+	}
+	if err := s.repo.CreateInvitation(ctx, invitation); err != nil {
+		return nil, err
+	}
+	s.publishInvitation(ctx, inviter, org, invitation, rawToken)
+	return invitation, nil
+}
+
+// ListInvitations returns the organization's pending invitations to its
+// admins
+func (s *AuthService) ListInvitations(ctx context.Context, userID, orgID string) ([]Invitation, error) {
+	if _, err := s.requireRole(ctx, userID, orgID, RoleAdmin); err != nil {
+		return nil, err
+	}
+	invitations, err := s.repo.ListPendingInvitations(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	pending := invitations[:0]
+	for _, invitation := range invitations {
+		if invitation.Pending(time.Now()) {
+			pending = append(pending, invitation)
+		}
+	}
+	return pending, nil
+}
+
+// RevokeInvitation cancels a pending invitation, so that its link no longer
+// works
+func (s *AuthService) RevokeInvitation(ctx context.Context, userID, orgID, id string) error {
+	if _, err := s.requireRole(ctx, userID, orgID, RoleAdmin); err != nil {
+		return err
+	}
+	invitation, err := s.repo.GetInvitation(ctx, id)
+	if err != nil {
+		return err
+	}
+	if invitation == nil || invitation.OrgID != orgID {
+		return ErrInvitationNotFound
+	}
+	if !invitation.Pending(time.Now()) {
+		return ErrInvitationClosed
+	}
+	return s.repo.RevokeInvitation(ctx, id)
+}
+
+// AcceptInvitation makes the user a member of the organization that invited
+// them. Invitations are accepted once, by the account of the email they
+// were sent to.
+func (s *AuthService) AcceptInvitation(ctx context.Context, user *User, rawToken string) (*Membership, error) {
+	invitation, err := s.repo.GetInvitationByTokenHash(ctx, s.HashString(rawToken))
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil {
+		return nil, ErrInvitationNotFound
+	}
+	if !invitation.Pending(time.Now()) {
+		return nil, ErrInvitationClosed
+	}
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		return nil, ErrInvitationEmailMismatch
+	}
+	if m, err := s.repo.GetMembership(ctx, user.ID, invitation.OrgID); err != nil {
+		return nil, err
+	} else if m != nil {
+		return nil, ErrAlreadyMember
+	}
+
+	accepted, err := s.repo.AcceptInvitation(ctx, invitation.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		// A concurrent accept or revoke won
+		return nil, ErrInvitationClosed
+	}
+	log.Printf("User %s joined organization %s as %s", user.ID, invitation.OrgID, invitation.Role)
+	return s.repo.GetMembership(ctx, user.ID, invitation.OrgID)
+}
+
+// SwitchOrganization makes the session act for one of the user's
+// organizations, or for none if orgID is empty, returning the membership.
+// Tokens of the session carry the organization and the user's role in it
+// from then on.
+func (s *AuthService) SwitchOrganization(ctx context.Context, userID, sessionID, orgID string) (*Membership, error) {
+	var m *Membership
+	if orgID != "" {
+		var err error
+		if m, err = s.requireRole(ctx, userID, orgID, RoleMember); err != nil {
+			return nil, err
+		}
+	}
+	if sessionID != "" {
+		if _, err := s.GetSession(ctx, userID, sessionID); err != nil {
+			return nil, err
+		}
+		if err := s.repo.SetSessionOrg(ctx, sessionID, orgID); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// SessionOrgRole returns the organization the session acts for and the
+// user's role in it, to put in its tokens. A session of a user who left
+// the organization acts for none.
+func (s *AuthService) SessionOrgRole(ctx context.Context, session *Session) (string, string, error) {
+	if session.OrgID == "" {
+		return "", "", nil
+	}
+	m, err := s.repo.GetMembership(ctx, session.UserID, session.OrgID)
+	if err != nil {
+		return "", "", err
+	}
+	if m == nil {
+		return "", "", nil
+	}
+	return m.OrgID, m.Role, nil
+}
+
+// MemberRole returns the user's role in the organization, or
+// ErrNotOrgMember if they do not belong to it
+func (s *AuthService) MemberRole(ctx context.Context, userID, orgID string) (string, error) {
+	m, err := s.repo.GetMembership(ctx, userID, orgID)
+	if err != nil {
+		return "", err
+	}
+	if m == nil {
+		return "", ErrNotOrgMember
+	}
+	return m.Role, nil
+}
+
+// requireRole returns the user's membership of the organization if their
+// role is at least the required one
+func (s *AuthService) requireRole(ctx context.Context, userID, orgID, role string) (*Membership, error) {
+	m, err := s.repo.GetMembership(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrNotOrgMember
+	}
+	if roleRanks[m.Role] < roleRanks[role] {
+		return nil, ErrNotOrgAdmin
+	}
+	return m, nil
+}
+
+// publishInvitation emails the invitation through the notifications service
+func (s *AuthService) publishInvitation(ctx context.Context, inviter *User, org *Organization, invitation *Invitation, rawToken string) {
+	if s.publisher == nil {
+		return
+	}
+	baseURL := os.Getenv("APP_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://sapliy.com"
+	}
+	event := map[string]interface{}{
+		"id":        uuid.New().String(),
+		"type":      EventOrgInvitation,
+		"timestamp": time.Now().UTC(),
+		"data": map[string]string{
+			"invitation_id": invitation.ID,
+			"org_id":        org.ID,
+			"org_name":      org.Name,
+			"role":          invitation.Role,
+			"email":         invitation.Email,
+			"invited_by":    inviter.Email,
+			"token":         rawToken,
+			"link":          fmt.Sprintf("%s/accept-invitation?token=%s", baseURL, rawToken),
+		},
+	}
+	if err := s.publisher.Publish(ctx, "", event); err != nil {
+		log.Printf("Failed to publish invitation event: %v", err)
+	}
+}
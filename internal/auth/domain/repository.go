@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 type Repository interface {
@@ -24,6 +25,45 @@ type Repository interface {
 	GetEmailVerificationToken(ctx context.Context, tokenHash string) (*EmailVerificationToken, error)
 	MarkEmailVerificationTokenUsed(ctx context.Context, tokenHash string) error
 
+	// Session methods
+	CreateSession(ctx context.Context, session *Session) error
+	GetSession(ctx context.Context, id string) (*Session, error)
+	// GetSessionByTokenHash finds the session of a refresh token, current or
+	// rotated out
+	GetSessionByTokenHash(ctx context.Context, tokenHash string) (*Session, error)
+	ListUserSessions(ctx context.Context, userID string) ([]Session, error)
+	// RotateSessionToken replaces the session's refresh token if it is still
+	// oldHash, reporting whether it was
+	RotateSessionToken(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) (bool, error)
+	RevokeSession(ctx context.Context, id string) error
+	// SetSessionOrg switches the organization the session acts for, none if
+	// orgID is empty
+	SetSessionOrg(ctx context.Context, id, orgID string) error
+
+	// Two-factor methods
+	GetTwoFactor(ctx context.Context, userID string) (*TwoFactor, error)
+	// SaveTwoFactorSecret starts or restarts an enrollment. It does not
+	// replace the secret of an enabled enrollment.
+	SaveTwoFactorSecret(ctx context.Context, userID, secret string) error
+	// EnableTwoFactor confirms the enrollment and replaces the user's
+	// recovery codes
+	EnableTwoFactor(ctx context.Context, userID string, recoveryCodeHashes []string) error
+	DisableTwoFactor(ctx context.Context, userID string) error
+	// UseTOTPStep records the step of a code, reporting false if a code of
+	// that step or a later one was already used
+	UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	// UseRecoveryCode spends an unused recovery code, reporting whether it was
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+	CreateTwoFactorChallenge(ctx context.Context, challenge *TwoFactorChallenge) error
+	GetTwoFactorChallenge(ctx context.Context, id string) (*TwoFactorChallenge, error)
+	// AttemptTwoFactorChallenge counts an attempt at the challenge,
+	// returning the attempts so far
+	AttemptTwoFactorChallenge(ctx context.Context, id string) (int, error)
+	// CompleteTwoFactorChallenge reports false if it was already completed
+	CompleteTwoFactorChallenge(ctx context.Context, id string) (bool, error)
+
 	// Organization methods
 	CreateOrganization(ctx context.Context, name, domain string) (*Organization, error)
 	GetOrganization(ctx context.Context, id string) (*Organization, error)
@@ -33,10 +73,37 @@ type Repository interface {
 	ListOrgMembers(ctx context.Context, orgID string) ([]Membership, error)
 	GetUserMemberships(ctx context.Context, userID string) ([]Membership, error)
 	GetMembership(ctx context.Context, userID, orgID string) (*Membership, error)
+	// ListUserOrganizations returns the organizations the user is a member
+	// of, with their role in each
+	ListUserOrganizations(ctx context.Context, userID string) ([]UserOrganization, error)
+	SetOrgTwoFactorRequired(ctx context.Context, orgID string, required bool) error
+	// UserRequiresTwoFactor reports whether any of the user's organizations
+	// requires two-factor authentication
+	UserRequiresTwoFactor(ctx context.Context, userID string) (bool, error)
+
+	// Invitation methods
+	CreateInvitation(ctx context.Context, invitation *Invitation) error
+	GetInvitation(ctx context.Context, id string) (*Invitation, error)
+	GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*Invitation, error)
+	// ListPendingInvitations returns the organization's invitations that were
+	// neither accepted nor revoked
+	ListPendingInvitations(ctx context.Context, orgID string) ([]Invitation, error)
+	// AcceptInvitation makes the user a member with the invitation's role,
+	// reporting false if it was already accepted or revoked
+	AcceptInvitation(ctx context.Context, id, userID string) (bool, error)
+	RevokeInvitation(ctx context.Context, id string) error
 
 	// APIKey methods
 	CreateAPIKey(ctx context.Context, key *APIKey) error
 	GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+	GetAPIKey(ctx context.Context, id string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]APIKey, error)
+	SetAPIKeyExpiry(ctx context.Context, id string, expiresAt *time.Time) error
+	RevokeAPIKey(ctx context.Context, id string) error
+	// TouchAPIKey records that the key was just used
+	TouchAPIKey(ctx context.Context, id string) error
+	// RevokeExpiredAPIKeys revokes the keys expired by now, returning them
+	RevokeExpiredAPIKeys(ctx context.Context, now time.Time) ([]APIKey, error)
 
 	// OAuth methods
 	GetClientByID(ctx context.Context, clientID string) (*OAuthClient, error)
@@ -125,15 +125,7 @@ func (s *AuthService) HasPermission(ctx context.Context, userID, orgID, required
 	if m == nil {
 		return false, nil
 	}
-
-	roles := map[string]int{
-		RoleOwner:     4,
-		RoleAdmin:     3,
-		RoleDeveloper: 2,
-		RoleMember:    1,
-	}
-
-	return roles[m.Role] >= roles[requiredRole], nil
+	return roleRanks[m.Role] >= roleRanks[requiredRole], nil
 }
 
 // APIKey methods
@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused, session revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// SessionTTL is how long a session lasts without being refreshed. Each
+// refresh extends it, so that a CLI in regular use stays logged in.
+const SessionTTL = 30 * 24 * time.Hour
+
+// CreateSession starts a session for a user who logged in, returning the
+// raw refresh token that keeps it alive. Only its hash is stored.
+func (s *AuthService) CreateSession(ctx context.Context, userID, userAgent, ipAddress string) (*Session, string, error) {
+	rawToken, err := s.GenerateRandomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:               uuid.New().String(),
+		UserID:           userID,
+		RefreshTokenHash: s.HashString(rawToken),
+		UserAgent:        userAgent,
+		IPAddress:        ipAddress,
+		ExpiresAt:        now.Add(SessionTTL),
+		LastUsedAt:       now,
+		CreatedAt:        now,
+	}
+	if err := s.repo.CreateSession(ctx, session); err != nil {
+		return nil, "", err
+	}
+	return session, rawToken, nil
+}
+
+// RefreshSession exchanges a refresh token for a new one, rotating it out.
+// A rotated out token presented again was copied, so the session is revoked
+// for the thief and the user alike: ErrRefreshTokenReused comes with the
+// revoked session.
+func (s *AuthService) RefreshSession(ctx context.Context, rawToken string) (*Session, string, error) {
+	tokenHash := s.HashString(rawToken)
+	session, err := s.repo.GetSessionByTokenHash(ctx, tokenHash)
+	if err != nil {
+		return nil, "", err
+	}
+	if session == nil || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if session.RefreshTokenHash != tokenHash {
+		log.Printf("Refresh token of session %s reused, revoking it", session.ID)
+		if err := s.repo.RevokeSession(ctx, session.ID); err != nil {
+			return nil, "", err
+		}
+		return session, "", ErrRefreshTokenReused
+	}
+
+	newToken, err := s.GenerateRandomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+	newHash := s.HashString(newToken)
+	expiresAt := time.Now().Add(SessionTTL)
+	rotated, err := s.repo.RotateSessionToken(ctx, session.ID, tokenHash, newHash, expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+	if !rotated {
+		// A concurrent refresh with the same token won
+		return nil, "", ErrInvalidRefreshToken
+	}
+	session.PreviousTokenHash, session.RefreshTokenHash = tokenHash, newHash
+	session.ExpiresAt, session.LastUsedAt = expiresAt, time.Now()
+	return session, newToken, nil
+}
+
+// GetSession returns the user's session, active or not
+func (s *AuthService) GetSession(ctx context.Context, userID, id string) (*Session, error) {
+	session, err := s.repo.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// ListSessions returns the user's active sessions
+func (s *AuthService) ListSessions(ctx context.Context, userID string) ([]Session, error) {
+	sessions, err := s.repo.ListUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	active := sessions[:0]
+	for _, session := range sessions {
+		if session.RevokedAt == nil && time.Now().Before(session.ExpiresAt) {
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+// RevokeSession ends one of the user's sessions, so that its refresh token
+// no longer works
+func (s *AuthService) RevokeSession(ctx context.Context, userID, id string) error {
+	session, err := s.GetSession(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if session.RevokedAt != nil {
+		return nil
+	}
+	return s.repo.RevokeSession(ctx, id)
+}
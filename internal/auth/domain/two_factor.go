@@ -0,0 +1,350 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sapliy/fintech-ecosystem/pkg/totp"
+)
+
+var (
+	ErrTwoFactorNotEnrolled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorRequiredByOrg   = errors.New("two-factor authentication is required by your organization")
+	ErrInvalidTwoFactorCode     = errors.New("invalid two-factor code")
+	ErrTwoFactorChallengeClosed = errors.New("two-factor challenge expired, log in again")
+	ErrNotOrgAdmin              = errors.New("only organization admins can change this")
+)
+
+const (
+	// TwoFactorIssuer names the account in authenticator apps
+	TwoFactorIssuer = "Sapliy"
+	// TwoFactorChallengeTTL is how long a login waits for its second
+	// factor, as long as the emailed security codes last
+	TwoFactorChallengeTTL = 10 * time.Minute
+	// MaxTwoFactorAttempts closes a challenge after that many wrong codes
+	MaxTwoFactorAttempts = 5
+	// RecoveryCodeCount is how many recovery codes are issued at a time
+	RecoveryCodeCount = 10
+)
+
+// EventSecurityCode asks the notifications service to email a security
+// code with its security_code template
+const EventSecurityCode = "auth.security_code"
+
+// TwoFactorStatus describes a user's two-factor authentication
+type TwoFactorStatus struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabled_at,omitempty"`
+	Required          bool       `json:"required"` // By one of the user's organizations
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+}
+
+// GetTwoFactorStatus returns the user's two-factor status
+func (s *AuthService) GetTwoFactorStatus(ctx context.Context, userID string) (*TwoFactorStatus, error) {
+	required, err := s.repo.UserRequiresTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &TwoFactorStatus{Required: required}
+
+	tf, err := s.repo.GetTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil || tf.EnabledAt == nil {
+		return status, nil
+	}
+	status.Enabled, status.EnabledAt = true, tf.EnabledAt
+	if status.RecoveryCodesLeft, err = s.repo.CountRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// BeginTwoFactorEnrollment provisions a TOTP secret for the user, returning
+// it and the otpauth:// URI to show as a QR code. The enrollment is pending
+// until confirmed with a code.
+func (s *AuthService) BeginTwoFactorEnrollment(ctx context.Context, user *User) (string, string, error) {
+	tf, err := s.repo.GetTwoFactor(ctx, user.ID)
+	if err != nil {
+		return "", "", err
+	}
+	if tf != nil && tf.EnabledAt != nil {
+		return "", "", ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.repo.SaveTwoFactorSecret(ctx, user.ID, secret); err != nil {
+		return "", "", err
+	}
+	return secret, totp.ProvisioningURI(secret, TwoFactorIssuer, user.Email), nil
+}
+
+// ConfirmTwoFactorEnrollment enables two-factor authentication once the
+// user proves their app has the secret, returning their recovery codes.
+// They are shown only once.
+func (s *AuthService) ConfirmTwoFactorEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	tf, err := s.repo.GetTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if tf.EnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if ok, err := s.useTOTPCode(ctx, tf, code); err != nil || !ok {
+		return nil, orInvalidCode(err)
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.EnableTwoFactor(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off with a current code
+// or a recovery code, unless one of the user's organizations requires it
+func (s *AuthService) DisableTwoFactor(ctx context.Context, userID, code string) error {
+	required, err := s.repo.UserRequiresTwoFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorRequiredByOrg
+	}
+	if err := s.verifySecondFactor(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.repo.DisableTwoFactor(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes, e.g. when
+// they run low, returning the new ones
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if err := s.verifySecondFactor(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// StartTwoFactorChallenge returns the challenge that a login with a valid
+// password must pass, or nil if the user needs no second factor. Users who
+// enabled two-factor authentication answer with their app or a recovery
+// code. Users whose organization requires it but who have not enrolled yet
+// are emailed a security code.
+func (s *AuthService) StartTwoFactorChallenge(ctx context.Context, user *User) (*TwoFactorChallenge, error) {
+	tf, err := s.repo.GetTwoFactor(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	method := TwoFactorMethodTOTP
+	if tf == nil || tf.EnabledAt == nil {
+		required, err := s.repo.UserRequiresTwoFactor(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			return nil, nil
+		}
+		method = TwoFactorMethodEmail
+	}
+
+	now := time.Now()
+	challenge := &TwoFactorChallenge{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Method:    method,
+		ExpiresAt: now.Add(TwoFactorChallengeTTL),
+		CreatedAt: now,
+	}
+	var code string
+	if method == TwoFactorMethodEmail {
+		if code, err = randomDigits(totp.Digits); err != nil {
+			return nil, err
+		}
+		challenge.CodeHash = s.HashString(code)
+	}
+	if err := s.repo.CreateTwoFactorChallenge(ctx, challenge); err != nil {
+		return nil, err
+	}
+	if method == TwoFactorMethodEmail {
+		s.publishSecurityCode(ctx, user, code)
+	}
+	return challenge, nil
+}
+
+// VerifyTwoFactorChallenge checks the code of a login's second factor,
+// returning the user it logs in. A challenge is passed only once.
+func (s *AuthService) VerifyTwoFactorChallenge(ctx context.Context, challengeID, code string) (string, error) {
+	challenge, err := s.repo.GetTwoFactorChallenge(ctx, challengeID)
+	if err != nil {
+		return "", err
+	}
+	if challenge == nil || challenge.CompletedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return "", ErrTwoFactorChallengeClosed
+	}
+	attempts, err := s.repo.AttemptTwoFactorChallenge(ctx, challenge.ID)
+	if err != nil {
+		return "", err
+	}
+	if attempts > MaxTwoFactorAttempts {
+		return "", ErrTwoFactorChallengeClosed
+	}
+
+	if challenge.Method == TwoFactorMethodEmail {
+		if challenge.CodeHash == "" || s.HashString(strings.TrimSpace(code)) != challenge.CodeHash {
+			return "", ErrInvalidTwoFactorCode
+		}
+	} else if err := s.verifySecondFactor(ctx, challenge.UserID, code); err != nil {
+		return "", err
+	}
+
+	completed, err := s.repo.CompleteTwoFactorChallenge(ctx, challenge.ID)
+	if err != nil {
+		return "", err
+	}
+	if !completed {
+		// A concurrent verification with the same challenge won
+		return "", ErrTwoFactorChallengeClosed
+	}
+	return challenge.UserID, nil
+}
+
+// verifySecondFactor accepts a current code of the user's app, or spends one
+// of their recovery codes
+func (s *AuthService) verifySecondFactor(ctx context.Context, userID, code string) error {
+	tf, err := s.repo.GetTwoFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if tf == nil || tf.EnabledAt == nil {
+		return ErrTwoFactorNotEnrolled
+	}
+	if ok, err := s.useTOTPCode(ctx, tf, code); err != nil || ok {
+		return err
+	}
+	used, err := s.repo.UseRecoveryCode(ctx, userID, s.HashString(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	log.Printf("User %s used a recovery code", userID)
+	return nil
+}
+
+// useTOTPCode checks a code of the user's app. Each code works once.
+func (s *AuthService) useTOTPCode(ctx context.Context, tf *TwoFactor, code string) (bool, error) {
+	step, ok := totp.Validate(tf.Secret, code, time.Now())
+	if !ok || step <= tf.LastUsedStep {
+		return false, nil
+	}
+	return s.repo.UseTOTPStep(ctx, tf.UserID, step)
+}
+
+func orInvalidCode(err error) error {
+	if err != nil {
+		return err
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+// generateRecoveryCodes returns new recovery codes, like "k3f9q-x2m7d", and
+// the hashes to store
+func (s *AuthService) generateRecoveryCodes() ([]string, []string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		var b strings.Builder
+		for j := 0; j < 10; j++ {
+			if j == 5 {
+				b.WriteByte('-')
+			}
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+			if err != nil {
+				return nil, nil, err
+			}
+			b.WriteByte(alphabet[n.Int64()])
+		}
+		codes[i] = b.String()
+		hashes[i] = s.HashString(normalizeRecoveryCode(codes[i]))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode forgives the case and separators users type
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
+
+func randomDigits(n int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+	v, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", n, v), nil
+}
+
+// publishSecurityCode emails the code to the user through the
+// notifications service
+func (s *AuthService) publishSecurityCode(ctx context.Context, user *User, code string) {
+	if s.publisher == nil {
+		return
+	}
+	event := map[string]interface{}{
+		"id":        uuid.New().String(),
+		"type":      EventSecurityCode,
+		"timestamp": time.Now().UTC(),
+		"data": map[string]string{
+			"user_id": user.ID,
+			"email":   user.Email,
+			"token":   code,
+		},
+	}
+	if err := s.publisher.Publish(ctx, "", event); err != nil {
+		log.Printf("Failed to publish security code event: %v", err)
+	}
+}
+
+// SetOrgTwoFactorRequired turns the organization's two-factor requirement
+// on or off. Only its admins and owners may.
+func (s *AuthService) SetOrgTwoFactorRequired(ctx context.Context, userID, orgID string, required bool) (*Organization, error) {
+	allowed, err := s.HasPermission(ctx, userID, orgID, RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrNotOrgAdmin
+	}
+	if err := s.repo.SetOrgTwoFactorRequired(ctx, orgID, required); err != nil {
+		return nil, err
+	}
+	return s.repo.GetOrganization(ctx, orgID)
+}
@@ -22,8 +22,12 @@ func NewCachedRepository(repo domain.Repository, rdb *redis.Client) *CachedRepos
 	}
 }
 
+func apiKeyCacheKey(hash string) string {
+	return fmt.Sprintf("auth:apikey:%s", hash)
+}
+
 func (r *CachedRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
-	cacheKey := fmt.Sprintf("auth:apikey:%s", hash)
+	cacheKey := apiKeyCacheKey(hash)
 
 	// Try cache
 	val, err := r.rdb.Get(ctx, cacheKey).Result()
@@ -49,4 +53,51 @@ func (r *CachedRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*d
 	return key, nil
 }
 
+// apiKeyTouchInterval is how often a key's last use is written, at most
+const apiKeyTouchInterval = time.Minute
+
+// TouchAPIKey records a key's last use at most once a minute, as keys are
+// validated on every request
+func (r *CachedRepository) TouchAPIKey(ctx context.Context, id string) error {
+	first, err := r.rdb.SetNX(ctx, "auth:apikey:touched:"+id, 1, apiKeyTouchInterval).Result()
+	if err == nil && !first {
+		return nil
+	}
+	return r.Repository.TouchAPIKey(ctx, id)
+}
+
+func (r *CachedRepository) SetAPIKeyExpiry(ctx context.Context, id string, expiresAt *time.Time) error {
+	if err := r.Repository.SetAPIKeyExpiry(ctx, id, expiresAt); err != nil {
+		return err
+	}
+	r.evictAPIKey(ctx, id)
+	return nil
+}
+
+func (r *CachedRepository) RevokeAPIKey(ctx context.Context, id string) error {
+	if err := r.Repository.RevokeAPIKey(ctx, id); err != nil {
+		return err
+	}
+	r.evictAPIKey(ctx, id)
+	return nil
+}
+
+func (r *CachedRepository) RevokeExpiredAPIKeys(ctx context.Context, now time.Time) ([]domain.APIKey, error) {
+	keys, err := r.Repository.RevokeExpiredAPIKeys(ctx, now)
+	for _, key := range keys {
+		r.rdb.Del(ctx, apiKeyCacheKey(key.KeyHash))
+	}
+	return keys, err
+}
+
+// evictAPIKey drops a changed key from the cache, so that it is validated
+// as it is now
+func (r *CachedRepository) evictAPIKey(ctx context.Context, id string) {
+	key, err := r.Repository.GetAPIKey(ctx, id)
+	if err != nil || key == nil {
+		return
+	}
+	r.rdb.Del(ctx, apiKeyCacheKey(key.KeyHash))
+}
+
 // Override other methods if needed, otherwise they delegate to the wrapped Repository
@@ -0,0 +1,38 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
+)
+
+// KafkaPublisher publishes auth events as JSON to a Kafka topic
+type KafkaPublisher struct {
+	producer *messaging.KafkaProducer
+}
+
+func NewKafkaPublisher(brokers []string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{producer: messaging.NewKafkaProducer(brokers, topic)}
+}
+
+// Publish writes the event to the publisher's topic, keyed by the event's
+// "id" when it has one. The topic argument is ignored: the auth service
+// publishes every event to the topic it was configured with.
+func (p *KafkaPublisher) Publish(ctx context.Context, topic string, event interface{}) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	var key string
+	if fields, ok := event.(map[string]interface{}); ok {
+		key, _ = fields["id"].(string)
+	}
+	return p.producer.Publish(ctx, key, value)
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.producer.Close()
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/sapliy/fintech-ecosystem/internal/auth/domain"
 )
@@ -204,13 +205,288 @@ func (r *SQLRepository) MarkEmailVerificationTokenUsed(ctx context.Context, toke
 	return nil
 }
 
+// Session methods
+
+const sessionColumns = `id, user_id, refresh_token_hash, previous_token_hash, org_id, user_agent, ip_address, expires_at, last_used_at, revoked_at, created_at`
+
+func scanSession(scan func(dest ...interface{}) error) (*domain.Session, error) {
+	var session domain.Session
+	var previous, orgID, userAgent, ipAddress sql.NullString
+	if err := scan(&session.ID, &session.UserID, &session.RefreshTokenHash, &previous, &orgID, &userAgent, &ipAddress,
+		&session.ExpiresAt, &session.LastUsedAt, &session.RevokedAt, &session.CreatedAt); err != nil {
+		return nil, err
+	}
+	session.PreviousTokenHash = previous.String
+	session.OrgID = orgID.String
+	session.UserAgent = userAgent.String
+	session.IPAddress = ipAddress.String
+	return &session, nil
+}
+
+func (r *SQLRepository) CreateSession(ctx context.Context, session *domain.Session) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO sessions (id, user_id, refresh_token_hash, user_agent, ip_address, expires_at, last_used_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		session.ID, session.UserID, session.RefreshTokenHash, toNullString(session.UserAgent), toNullString(session.IPAddress),
+		session.ExpiresAt, session.LastUsedAt, session.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) GetSession(ctx context.Context, id string) (*domain.Session, error) {
+	session, err := scanSession(r.db.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE id::text = $1`, id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+func (r *SQLRepository) GetSessionByTokenHash(ctx context.Context, tokenHash string) (*domain.Session, error) {
+	session, err := scanSession(r.db.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE refresh_token_hash = $1 OR previous_token_hash = $1 LIMIT 1`,
+		tokenHash).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+func (r *SQLRepository) ListUserSessions(ctx context.Context, userID string) ([]domain.Session, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+sessionColumns+` FROM sessions WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var sessions []domain.Session
+	for rows.Next() {
+		session, err := scanSession(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *SQLRepository) RotateSessionToken(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET refresh_token_hash = $1, previous_token_hash = $2, expires_at = $3, last_used_at = NOW()
+		 WHERE id::text = $4 AND refresh_token_hash = $2 AND revoked_at IS NULL`,
+		newHash, oldHash, expiresAt, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate session token: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+func (r *SQLRepository) RevokeSession(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = NOW() WHERE id::text = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) SetSessionOrg(ctx context.Context, id, orgID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET org_id = $2 WHERE id::text = $1`, id, toNullString(orgID))
+	if err != nil {
+		return fmt.Errorf("failed to switch session organization: %w", err)
+	}
+	return nil
+}
+
+// Two-factor methods
+
+func (r *SQLRepository) GetTwoFactor(ctx context.Context, userID string) (*domain.TwoFactor, error) {
+	var tf domain.TwoFactor
+	var lastStep sql.NullInt64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT user_id, secret, enabled_at, last_used_step, created_at FROM user_two_factor WHERE user_id = $1`,
+		userID).Scan(&tf.UserID, &tf.Secret, &tf.EnabledAt, &lastStep, &tf.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get two-factor enrollment: %w", err)
+	}
+	tf.LastUsedStep = lastStep.Int64
+	return &tf, nil
+}
+
+func (r *SQLRepository) SaveTwoFactorSecret(ctx context.Context, userID, secret string) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO user_two_factor (user_id, secret) VALUES ($1, $2)
+		 ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = NOW()
+		 WHERE user_two_factor.enabled_at IS NULL`,
+		userID, secret)
+	if err != nil {
+		return fmt.Errorf("failed to save two-factor secret: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) EnableTwoFactor(ctx context.Context, userID string, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE user_two_factor SET enabled_at = NOW() WHERE user_id = $1 AND enabled_at IS NULL`, userID); err != nil {
+		return fmt.Errorf("failed to enable two-factor: %w", err)
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLRepository) DisableTwoFactor(ctx context.Context, userID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_two_factor WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to disable two-factor: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (r *SQLRepository) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE user_two_factor SET last_used_step = $2
+		 WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)`,
+		userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp step: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+func (r *SQLRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID string, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *SQLRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+func (r *SQLRepository) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+func (r *SQLRepository) CreateTwoFactorChallenge(ctx context.Context, challenge *domain.TwoFactorChallenge) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO two_factor_challenges (id, user_id, method, code_hash, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		challenge.ID, challenge.UserID, challenge.Method, toNullString(challenge.CodeHash), challenge.ExpiresAt, challenge.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create two-factor challenge: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) GetTwoFactorChallenge(ctx context.Context, id string) (*domain.TwoFactorChallenge, error) {
+	var c domain.TwoFactorChallenge
+	var codeHash sql.NullString
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, user_id, method, code_hash, attempts, expires_at, completed_at, created_at
+		 FROM two_factor_challenges WHERE id::text = $1`, id).
+		Scan(&c.ID, &c.UserID, &c.Method, &codeHash, &c.Attempts, &c.ExpiresAt, &c.CompletedAt, &c.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get two-factor challenge: %w", err)
+	}
+	c.CodeHash = codeHash.String
+	return &c, nil
+}
+
+func (r *SQLRepository) AttemptTwoFactorChallenge(ctx context.Context, id string) (int, error) {
+	var attempts int
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE two_factor_challenges SET attempts = attempts + 1 WHERE id::text = $1 RETURNING attempts`, id).
+		Scan(&attempts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count two-factor attempt: %w", err)
+	}
+	return attempts, nil
+}
+
+func (r *SQLRepository) CompleteTwoFactorChallenge(ctx context.Context, id string) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE two_factor_challenges SET completed_at = NOW() WHERE id::text = $1 AND completed_at IS NULL`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to complete two-factor challenge: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
 // Organization methods
 
 func (r *SQLRepository) CreateOrganization(ctx context.Context, name, domainName string) (*domain.Organization, error) {
 	var org domain.Organization
 	err := r.db.QueryRowContext(ctx,
-		"INSERT INTO organizations (name, domain) VALUES ($1, $2) RETURNING id, name, domain, created_at",
-		name, domainName).Scan(&org.ID, &org.Name, &org.Domain, &org.CreatedAt)
+		"INSERT INTO organizations (name, domain) VALUES ($1, $2) RETURNING id, name, domain, require_two_factor, created_at",
+		name, domainName).Scan(&org.ID, &org.Name, &org.Domain, &org.RequireTwoFactor, &org.CreatedAt)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create organization: %w", err)
@@ -221,8 +497,8 @@ func (r *SQLRepository) CreateOrganization(ctx context.Context, name, domainName
 func (r *SQLRepository) GetOrganization(ctx context.Context, id string) (*domain.Organization, error) {
 	var org domain.Organization
 	err := r.db.QueryRowContext(ctx,
-		"SELECT id, name, domain, created_at FROM organizations WHERE id = $1",
-		id).Scan(&org.ID, &org.Name, &org.Domain, &org.CreatedAt)
+		"SELECT id, name, domain, require_two_factor, created_at FROM organizations WHERE id = $1",
+		id).Scan(&org.ID, &org.Name, &org.Domain, &org.RequireTwoFactor, &org.CreatedAt)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -323,34 +599,175 @@ func (r *SQLRepository) GetMembership(ctx context.Context, userID, orgID string)
 	return &m, nil
 }
 
-// APIKey methods
+func (r *SQLRepository) ListUserOrganizations(ctx context.Context, userID string) ([]domain.UserOrganization, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT o.id, o.name, o.domain, o.require_two_factor, o.created_at, m.role
+		 FROM memberships m JOIN organizations o ON o.id = m.org_id
+		 WHERE m.user_id = $1 ORDER BY o.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
 
-func (r *SQLRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
-	if key.Scopes == "" {
-		key.Scopes = "*"
+	var orgs []domain.UserOrganization
+	for rows.Next() {
+		var org domain.UserOrganization
+		var domainName sql.NullString
+		if err := rows.Scan(&org.ID, &org.Name, &domainName, &org.RequireTwoFactor, &org.CreatedAt, &org.Role); err != nil {
+			return nil, err
+		}
+		org.Domain = domainName.String
+		orgs = append(orgs, org)
 	}
+	return orgs, rows.Err()
+}
+
+func (r *SQLRepository) SetOrgTwoFactorRequired(ctx context.Context, orgID string, required bool) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE organizations SET require_two_factor = $2, updated_at = NOW() WHERE id = $1", orgID, required)
+	if err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) UserRequiresTwoFactor(ctx context.Context, userID string) (bool, error) {
+	var required bool
 	err := r.db.QueryRowContext(ctx,
-		`INSERT INTO api_keys (user_id, org_id, zone_id, mode, key_prefix, key_hash, truncated_key, environment, scopes, type)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at`,
-		key.UserID, toNullString(key.OrgID), toNullString(key.ZoneID), key.Mode, key.KeyPrefix, key.KeyHash, key.TruncatedKey, key.Environment, key.Scopes, key.Type).
-		Scan(&key.ID, &key.CreatedAt)
+		`SELECT EXISTS (
+			SELECT 1 FROM memberships m JOIN organizations o ON o.id = m.org_id
+			WHERE m.user_id = $1 AND o.require_two_factor
+		)`, userID).Scan(&required)
+	if err != nil {
+		return false, fmt.Errorf("failed to check two-factor requirement: %w", err)
+	}
+	return required, nil
+}
+
+// Invitation methods
+
+const invitationColumns = `id, org_id, email, role, token_hash, invited_by, expires_at, accepted_at, revoked_at, created_at`
+
+func scanInvitation(scan func(dest ...interface{}) error) (*domain.Invitation, error) {
+	var invitation domain.Invitation
+	var invitedBy sql.NullString
+	if err := scan(&invitation.ID, &invitation.OrgID, &invitation.Email, &invitation.Role, &invitation.TokenHash, &invitedBy,
+		&invitation.ExpiresAt, &invitation.AcceptedAt, &invitation.RevokedAt, &invitation.CreatedAt); err != nil {
+		return nil, err
+	}
+	invitation.InvitedBy = invitedBy.String
+	return &invitation, nil
+}
 
+func (r *SQLRepository) CreateInvitation(ctx context.Context, invitation *domain.Invitation) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO org_invitations (id, org_id, email, role, token_hash, invited_by, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		invitation.ID, invitation.OrgID, invitation.Email, invitation.Role, invitation.TokenHash,
+		toNullString(invitation.InvitedBy), invitation.ExpiresAt, invitation.CreatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to create api key: %w", err)
+		return fmt.Errorf("failed to create invitation: %w", err)
 	}
 	return nil
 }
 
-func (r *SQLRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+func (r *SQLRepository) GetInvitation(ctx context.Context, id string) (*domain.Invitation, error) {
+	invitation, err := scanInvitation(r.db.QueryRowContext(ctx,
+		`SELECT `+invitationColumns+` FROM org_invitations WHERE id::text = $1`, id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	return invitation, nil
+}
+
+func (r *SQLRepository) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*domain.Invitation, error) {
+	invitation, err := scanInvitation(r.db.QueryRowContext(ctx,
+		`SELECT `+invitationColumns+` FROM org_invitations WHERE token_hash = $1`, tokenHash).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	return invitation, nil
+}
+
+func (r *SQLRepository) ListPendingInvitations(ctx context.Context, orgID string) ([]domain.Invitation, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+invitationColumns+` FROM org_invitations
+		 WHERE org_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL ORDER BY created_at DESC`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var invitations []domain.Invitation
+	for rows.Next() {
+		invitation, err := scanInvitation(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, *invitation)
+	}
+	return invitations, rows.Err()
+}
+
+func (r *SQLRepository) AcceptInvitation(ctx context.Context, id, userID string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var orgID, role string
+	err = tx.QueryRowContext(ctx,
+		`UPDATE org_invitations SET accepted_at = NOW()
+		 WHERE id::text = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+		 RETURNING org_id, role`, id).Scan(&orgID, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO memberships (user_id, org_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		userID, orgID, role); err != nil {
+		return false, fmt.Errorf("failed to add member: %w", err)
+	}
+	return true, tx.Commit()
+}
+
+func (r *SQLRepository) RevokeInvitation(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE org_invitations SET revoked_at = NOW() WHERE id::text = $1 AND accepted_at IS NULL AND revoked_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+	return nil
+}
+
+// APIKey methods
+
+const apiKeyColumns = `id, user_id, org_id, zone_id, mode, key_prefix, key_hash, truncated_key, environment, scopes, type,
+	created_at, revoked_at, expires_at, last_used_at, rotated_from`
+
+func scanAPIKey(scan func(dest ...interface{}) error) (*domain.APIKey, error) {
 	var key domain.APIKey
 	var scopes sql.NullString
 	var orgID sql.NullString
 	var zoneID sql.NullString
 	var mode sql.NullString
 	var typeStr sql.NullString
-	err := r.db.QueryRowContext(ctx,
-		"SELECT id, user_id, org_id, zone_id, mode, key_prefix, environment, scopes, type, revoked_at FROM api_keys WHERE key_hash = $1",
-		hash).Scan(&key.ID, &key.UserID, &orgID, &zoneID, &mode, &key.KeyPrefix, &key.Environment, &scopes, &typeStr, &key.RevokedAt)
+	var rotatedFrom sql.NullString
+	err := scan(&key.ID, &key.UserID, &orgID, &zoneID, &mode, &key.KeyPrefix, &key.KeyHash, &key.TruncatedKey, &key.Environment,
+		&scopes, &typeStr, &key.CreatedAt, &key.RevokedAt, &key.ExpiresAt, &key.LastUsedAt, &rotatedFrom)
+	if err != nil {
+		return nil, err
+	}
 	key.OrgID = orgID.String
 	key.ZoneID = zoneID.String
 	key.Mode = mode.String
@@ -362,14 +779,112 @@ func (r *SQLRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*doma
 	if key.Scopes == "" {
 		key.Scopes = "*"
 	}
+	key.RotatedFrom = rotatedFrom.String
+	return &key, nil
+}
+
+func (r *SQLRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	if key.Scopes == "" {
+		key.Scopes = "*"
+	}
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO api_keys (user_id, org_id, zone_id, mode, key_prefix, key_hash, truncated_key, environment, scopes, type, expires_at, rotated_from)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, created_at`,
+		key.UserID, toNullString(key.OrgID), toNullString(key.ZoneID), key.Mode, key.KeyPrefix, key.KeyHash, key.TruncatedKey, key.Environment, key.Scopes, key.Type,
+		key.ExpiresAt, toNullString(key.RotatedFrom)).
+		Scan(&key.ID, &key.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
 
+func (r *SQLRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", hash).Scan)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get api key: %w", err)
 	}
-	return &key, nil
+	return key, nil
+}
+
+func (r *SQLRepository) GetAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE id::text = $1", id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *SQLRepository) ListAPIKeys(ctx context.Context, userID string) ([]domain.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var keys []domain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *SQLRepository) SetAPIKeyExpiry(ctx context.Context, id string, expiresAt *time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET expires_at = $1 WHERE id::text = $2 AND revoked_at IS NULL`, expiresAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to set api key expiry: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) RevokeAPIKey(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = NOW() WHERE id::text = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) TouchAPIKey(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = NOW() WHERE id::text = $1`, id)
+	return err
+}
+
+func (r *SQLRepository) RevokeExpiredAPIKeys(ctx context.Context, now time.Time) ([]domain.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`UPDATE api_keys SET revoked_at = expires_at
+		 WHERE revoked_at IS NULL AND expires_at <= $1
+		 RETURNING `+apiKeyColumns, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke expired api keys: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var keys []domain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
 }
 
 // OAuth methods
@@ -5,4 +5,12 @@ import "errors"
 var (
 	ErrPlanNotFound         = errors.New("plan not found")
 	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrInvalidPlan          = errors.New("plan needs a name, a positive amount, a currency and a month or year interval")
+	ErrIncompatiblePlan     = errors.New("plans must share currency and interval")
+	ErrSamePlan             = errors.New("subscription is already on this plan")
+	ErrSubscriptionCanceled = errors.New("subscription is canceled")
+	ErrSubscriptionInactive = errors.New("only active subscriptions can change plan")
+	// ErrPaymentDeclined means the charge reached the bank and was refused,
+	// as opposed to the payment service being unreachable
+	ErrPaymentDeclined = errors.New("payment declined")
 )
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Kafka events of the subscription lifecycle
+const (
+	EventSubscriptionCreated          = "subscription.created"
+	EventSubscriptionPlanChanged      = "subscription.plan_changed"
+	EventSubscriptionRenewed          = "subscription.renewed"
+	EventSubscriptionPaymentSucceeded = "subscription.payment_succeeded"
+	EventSubscriptionPaymentFailed    = "subscription.payment_failed"
+	EventSubscriptionCanceled         = "subscription.canceled"
+)
+
+// EventPublisher sends events to the message broker, keyed by subscription
+type EventPublisher interface {
+	Publish(ctx context.Context, key string, value []byte) error
+}
+
+// publish sends a subscription event. Billing has no outbox, so a failed
+// publish is logged and the change it describes stands.
+func (s *BillingService) publish(ctx context.Context, eventType string, sub *Subscription, data map[string]interface{}) {
+	if s.publisher == nil {
+		return
+	}
+	now := time.Now().UTC()
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["subscription_id"] = sub.ID
+	data["user_id"] = sub.UserID
+	data["org_id"] = sub.OrgID
+	data["plan_id"] = sub.PlanID
+	data["status"] = sub.Status
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":        fmt.Sprintf("evt_%s_%s_%d", strings.ReplaceAll(eventType, ".", "_"), sub.ID, now.UnixNano()),
+		"type":      eventType,
+		"timestamp": now,
+		"data":      data,
+	})
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", eventType, err)
+		return
+	}
+	if err := s.publisher.Publish(ctx, sub.ID, payload); err != nil {
+		log.Printf("Failed to publish %s event for subscription %s: %v", eventType, sub.ID, err)
+	}
+}
+
+func invoiceEventData(inv *Invoice) map[string]interface{} {
+	return map[string]interface{}{
+		"invoice_id":        inv.ID,
+		"amount":            inv.Amount,
+		"currency":          inv.Currency,
+		"reason":            inv.Reason,
+		"attempt_count":     inv.AttemptCount,
+		"next_attempt_at":   inv.NextAttemptAt,
+		"payment_intent_id": inv.PaymentIntentID,
+	}
+}
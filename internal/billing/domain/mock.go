@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type MockRepository struct {
+	CreateSubscriptionFunc   func(ctx context.Context, sub *Subscription) error
+	GetSubscriptionFunc      func(ctx context.Context, id string) (*Subscription, error)
+	UpdateSubscriptionFunc   func(ctx context.Context, sub *Subscription) error
+	ListSubscriptionsFunc    func(ctx context.Context, userID string) ([]*Subscription, error)
+	ListDueSubscriptionsFunc func(ctx context.Context) ([]*Subscription, error)
+	CreatePlanFunc           func(ctx context.Context, plan *Plan) error
+	GetPlanFunc              func(ctx context.Context, id string) (*Plan, error)
+	ListPlansFunc            func(ctx context.Context) ([]*Plan, error)
+	CreateInvoiceFunc        func(ctx context.Context, inv *Invoice) error
+	UpdateInvoiceFunc        func(ctx context.Context, inv *Invoice) error
+	ListInvoicesFunc         func(ctx context.Context, subscriptionID string) ([]*Invoice, error)
+	ListDueInvoicesFunc      func(ctx context.Context, now time.Time, limit int) ([]*Invoice, error)
+}
+
+func (m *MockRepository) CreateSubscription(ctx context.Context, sub *Subscription) error {
+	return m.CreateSubscriptionFunc(ctx, sub)
+}
+
+func (m *MockRepository) GetSubscription(ctx context.Context, id string) (*Subscription, error) {
+	return m.GetSubscriptionFunc(ctx, id)
+}
+
+func (m *MockRepository) UpdateSubscription(ctx context.Context, sub *Subscription) error {
+	return m.UpdateSubscriptionFunc(ctx, sub)
+}
+
+func (m *MockRepository) ListSubscriptions(ctx context.Context, userID string) ([]*Subscription, error) {
+	return m.ListSubscriptionsFunc(ctx, userID)
+}
+
+func (m *MockRepository) ListDueSubscriptions(ctx context.Context) ([]*Subscription, error) {
+	return m.ListDueSubscriptionsFunc(ctx)
+}
+
+func (m *MockRepository) CreatePlan(ctx context.Context, plan *Plan) error {
+	return m.CreatePlanFunc(ctx, plan)
+}
+
+func (m *MockRepository) GetPlan(ctx context.Context, id string) (*Plan, error) {
+	return m.GetPlanFunc(ctx, id)
+}
+
+func (m *MockRepository) ListPlans(ctx context.Context) ([]*Plan, error) {
+	return m.ListPlansFunc(ctx)
+}
+
+func (m *MockRepository) CreateInvoice(ctx context.Context, inv *Invoice) error {
+	return m.CreateInvoiceFunc(ctx, inv)
+}
+
+func (m *MockRepository) UpdateInvoice(ctx context.Context, inv *Invoice) error {
+	return m.UpdateInvoiceFunc(ctx, inv)
+}
+
+func (m *MockRepository) ListInvoices(ctx context.Context, subscriptionID string) ([]*Invoice, error) {
+	return m.ListInvoicesFunc(ctx, subscriptionID)
+}
+
+func (m *MockRepository) ListDueInvoices(ctx context.Context, now time.Time, limit int) ([]*Invoice, error) {
+	return m.ListDueInvoicesFunc(ctx, now, limit)
+}
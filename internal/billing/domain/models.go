@@ -10,7 +10,21 @@ const (
 	SubscriptionStatusActive     SubscriptionStatus = "active"
 	SubscriptionStatusCanceled   SubscriptionStatus = "canceled"
 	SubscriptionStatusPastDue    SubscriptionStatus = "past_due"
-	SubscriptionStatusIncomplete SubscriptionStatus = "incomplete"
+	SubscriptionStatusIncomplete SubscriptionStatus = "incomplete" // The first invoice is not paid yet
+)
+
+// Invoice statuses
+const (
+	InvoiceStatusPaid   = "paid"
+	InvoiceStatusUnpaid = "unpaid"
+	InvoiceStatusVoid   = "void"
+)
+
+// Why an invoice was raised
+const (
+	InvoiceReasonCreate    = "subscription_create"
+	InvoiceReasonCycle     = "subscription_cycle"
+	InvoiceReasonProration = "proration"
 )
 
 type Plan struct {
@@ -27,22 +41,31 @@ type Subscription struct {
 	UserID             string             `json:"user_id"`
 	OrgID              string             `json:"org_id"`
 	PlanID             string             `json:"plan_id"`
+	PaymentMethodID    string             `json:"payment_method_id,omitempty"` // Saved payment method charged each cycle
 	Status             SubscriptionStatus `json:"status"`
 	CurrentPeriodStart time.Time          `json:"current_period_start"`
 	CurrentPeriodEnd   time.Time          `json:"current_period_end"`
+	CreditBalance      int64              `json:"credit_balance"` // Owed to the customer by downgrades, taken off the next invoices
 	CanceledAt         *time.Time         `json:"canceled_at,omitempty"`
 	CreatedAt          time.Time          `json:"created_at"`
 	UpdatedAt          time.Time          `json:"updated_at"`
 }
 
 type Invoice struct {
-	ID              string    `json:"id"`
-	SubscriptionID  string    `json:"subscription_id"`
-	UserID          string    `json:"user_id"`
-	OrgID           string    `json:"org_id"`
-	Amount          int64     `json:"amount"`
-	Currency        string    `json:"currency"`
-	Status          string    `json:"status"` // "paid", "unpaid", "void"
-	PaymentIntentID string    `json:"payment_intent_id,omitempty"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID              string     `json:"id"`
+	SubscriptionID  string     `json:"subscription_id"`
+	UserID          string     `json:"user_id"`
+	OrgID           string     `json:"org_id"`
+	Amount          int64      `json:"amount"`
+	Currency        string     `json:"currency"`
+	Status          string     `json:"status"` // "paid", "unpaid", "void"
+	Reason          string     `json:"reason"`
+	Description     string     `json:"description,omitempty"`
+	PeriodStart     time.Time  `json:"period_start"`
+	PeriodEnd       time.Time  `json:"period_end"`
+	PaymentIntentID string     `json:"payment_intent_id,omitempty"`
+	AttemptCount    int        `json:"attempt_count"`
+	NextAttemptAt   *time.Time `json:"next_attempt_at,omitempty"` // When the charge is retried; nil once settled
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
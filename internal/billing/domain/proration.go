@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// Prorate returns what switching from oldPlan to newPlan at the given time
+// costs for the rest of the current period: the new plan's price for the
+// time left minus the unused part of the old plan. It is negative for
+// downgrades, which leave the customer in credit. Both plans must share
+// currency and interval.
+func Prorate(oldPlan, newPlan *Plan, periodStart, periodEnd, at time.Time) (int64, error) {
+	if oldPlan.Currency != newPlan.Currency || oldPlan.Interval != newPlan.Interval {
+		return 0, ErrIncompatiblePlan
+	}
+	period := periodEnd.Sub(periodStart)
+	if period <= 0 || !at.Before(periodEnd) {
+		return 0, nil
+	}
+	if at.Before(periodStart) {
+		at = periodStart
+	}
+
+	remaining := float64(periodEnd.Sub(at)) / float64(period)
+	unused := int64(math.Round(float64(oldPlan.Amount) * remaining))
+	owed := int64(math.Round(float64(newPlan.Amount) * remaining))
+	return owed - unused, nil
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestProrate(t *testing.T) {
+	basic := &Plan{Amount: 1000, Currency: "USD", Interval: "month"}
+	pro := &Plan{Amount: 3000, Currency: "USD", Interval: "month"}
+
+	tests := []struct {
+		name       string
+		oldPlan    *Plan
+		newPlan    *Plan
+		start, end time.Time
+		at         time.Time
+		want       int64
+		wantErr    error
+	}{
+		{"Upgrade halfway through", basic, pro, date(2023, 4, 1), date(2023, 5, 1), date(2023, 4, 16), 1000, nil},
+		{"Downgrade halfway through", pro, basic, date(2023, 4, 1), date(2023, 5, 1), date(2023, 4, 16), -1000, nil},
+		{"Upgrade with a third left", basic, pro, date(2023, 4, 1), date(2023, 5, 1), date(2023, 4, 21), 667, nil},
+		{"Upgrade at the period start", basic, pro, date(2023, 4, 1), date(2023, 5, 1), date(2023, 4, 1), 2000, nil},
+		{"Change before the period start", basic, pro, date(2023, 4, 1), date(2023, 5, 1), date(2023, 3, 20), 2000, nil},
+		{"Change at the period end", basic, pro, date(2023, 4, 1), date(2023, 5, 1), date(2023, 5, 1), 0, nil},
+		{"Change after the period end", basic, pro, date(2023, 4, 1), date(2023, 5, 1), date(2023, 5, 3), 0, nil},
+		{"Zero-day period", basic, pro, date(2023, 4, 1), date(2023, 4, 1), date(2023, 4, 1), 0, nil},
+		{"Period ending before it starts", basic, pro, date(2023, 4, 1), date(2023, 3, 1), date(2023, 3, 15), 0, nil},
+		// 14 of February's 28 days left in 2023, 15 of 29 in 2024
+		{"Mid-February", basic, pro, date(2023, 2, 1), date(2023, 3, 1), date(2023, 2, 15), 1000, nil},
+		{"Mid-February of a leap year", basic, pro, date(2024, 2, 1), date(2024, 3, 1), date(2024, 2, 15), 1035, nil},
+		{"Other currency", basic, &Plan{Amount: 3000, Currency: "EUR", Interval: "month"}, date(2023, 4, 1), date(2023, 5, 1), date(2023, 4, 16), 0, ErrIncompatiblePlan},
+		{"Other interval", basic, &Plan{Amount: 30000, Currency: "USD", Interval: "year"}, date(2023, 4, 1), date(2023, 5, 1), date(2023, 4, 16), 0, ErrIncompatiblePlan},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Prorate(tt.oldPlan, tt.newPlan, tt.start, tt.end, tt.at)
+			if err != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCalculateNextPeriod(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    time.Time
+		interval string
+		want     time.Time
+	}{
+		{"Month", date(2023, 4, 15), "month", date(2023, 5, 15)},
+		{"Month across the year end", date(2023, 12, 31), "month", date(2024, 1, 31)},
+		{"Into February", date(2023, 1, 31), "month", date(2023, 2, 28)},
+		{"Into February of a leap year", date(2024, 1, 31), "month", date(2024, 2, 29)},
+		{"Into a 30-day month", date(2023, 3, 31), "month", date(2023, 4, 30)},
+		{"From the leap day", date(2024, 2, 29), "month", date(2024, 3, 29)},
+		{"Year", date(2023, 4, 15), "year", date(2024, 4, 15)},
+		{"Year from the leap day", date(2024, 2, 29), "year", date(2025, 2, 28)},
+		{"Unknown interval is a month", date(2023, 4, 15), "", date(2023, 5, 15)},
+		{"Keeps the time of day", time.Date(2023, 1, 31, 9, 30, 0, 0, time.UTC), "month", time.Date(2023, 2, 28, 9, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CalculateNextPeriod(tt.start, tt.interval); !got.Equal(tt.want) {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -2,78 +2,382 @@ package domain
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// DunningSchedule is how long after each failed charge of an invoice the
+// charge is retried. The subscription is canceled when the last retry fails.
+var DunningSchedule = []time.Duration{24 * time.Hour, 3 * 24 * time.Hour, 5 * 24 * time.Hour}
+
 type Repository interface {
 	CreateSubscription(ctx context.Context, sub *Subscription) error
 	GetSubscription(ctx context.Context, id string) (*Subscription, error)
 	UpdateSubscription(ctx context.Context, sub *Subscription) error
+	ListSubscriptions(ctx context.Context, userID string) ([]*Subscription, error)
 	ListDueSubscriptions(ctx context.Context) ([]*Subscription, error)
+	CreatePlan(ctx context.Context, plan *Plan) error
 	GetPlan(ctx context.Context, id string) (*Plan, error)
+	ListPlans(ctx context.Context) ([]*Plan, error)
+	CreateInvoice(ctx context.Context, inv *Invoice) error
+	UpdateInvoice(ctx context.Context, inv *Invoice) error
+	ListInvoices(ctx context.Context, subscriptionID string) ([]*Invoice, error)
+	// ListDueInvoices returns unpaid invoices whose next charge attempt is due
+	ListDueInvoices(ctx context.Context, now time.Time, limit int) ([]*Invoice, error)
 }
 
 type BillingService struct {
-	repo Repository
+	repo      Repository
+	publisher EventPublisher
 }
 
-func NewBillingService(repo Repository) *BillingService {
-	return &BillingService{repo: repo}
+// NewBillingService creates the service. The publisher may be nil, in
+// which case no events are sent.
+func NewBillingService(repo Repository, publisher EventPublisher) *BillingService {
+	return &BillingService{repo: repo, publisher: publisher}
 }
 
-func (s *BillingService) CreateSubscription(ctx context.Context, userID, orgID, planID string) (*Subscription, error) {
-	plan, err := s.repo.GetPlan(ctx, planID)
-	if err != nil {
-		return nil, err
+func (s *BillingService) CreatePlan(ctx context.Context, plan *Plan) error {
+	if plan.Name == "" || plan.Amount <= 0 || len(plan.Currency) != 3 || (plan.Interval != "month" && plan.Interval != "year") {
+		return ErrInvalidPlan
 	}
-	if plan == nil {
-		return nil, ErrPlanNotFound
+	plan.ID = uuid.New().String()
+	plan.CreatedAt = time.Now()
+	return s.repo.CreatePlan(ctx, plan)
+}
+
+func (s *BillingService) ListPlans(ctx context.Context) ([]*Plan, error) {
+	return s.repo.ListPlans(ctx)
+}
+
+// CreateSubscription starts a subscription and raises the invoice of its
+// first period. The subscription is incomplete until that invoice is paid.
+func (s *BillingService) CreateSubscription(ctx context.Context, userID, orgID, planID, paymentMethodID string) (*Subscription, *Invoice, error) {
+	plan, err := s.getPlan(ctx, planID)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	now := time.Now()
 	sub := &Subscription{
 		ID:                 uuid.New().String(),
 		UserID:             userID,
 		OrgID:              orgID,
 		PlanID:             planID,
-		Status:             SubscriptionStatusActive,
-		CurrentPeriodStart: time.Now(),
-		CurrentPeriodEnd:   CalculateNextPeriod(time.Now(), plan.Interval),
-		CreatedAt:          time.Now(),
-		UpdatedAt:          time.Now(),
+		PaymentMethodID:    paymentMethodID,
+		Status:             SubscriptionStatusIncomplete,
+		CurrentPeriodStart: now,
+		CurrentPeriodEnd:   CalculateNextPeriod(now, plan.Interval),
+		CreatedAt:          now,
+		UpdatedAt:          now,
 	}
 
 	if err := s.repo.CreateSubscription(ctx, sub); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	inv, err := s.createInvoice(ctx, sub, plan.Amount, plan.Currency, InvoiceReasonCreate, plan.Name)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return sub, nil
+	s.publish(ctx, EventSubscriptionCreated, sub, map[string]interface{}{"invoice_id": inv.ID})
+	return sub, inv, nil
 }
 
-func (s *BillingService) CancelSubscription(ctx context.Context, id string) (*Subscription, error) {
+// GetSubscription returns a subscription of the user, or of any user when
+// userID is empty
+func (s *BillingService) GetSubscription(ctx context.Context, userID, id string) (*Subscription, error) {
 	sub, err := s.repo.GetSubscription(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	if sub == nil {
+	if sub == nil || (userID != "" && sub.UserID != userID) {
 		return nil, ErrSubscriptionNotFound
 	}
+	return sub, nil
+}
+
+func (s *BillingService) ListSubscriptions(ctx context.Context, userID string) ([]*Subscription, error) {
+	return s.repo.ListSubscriptions(ctx, userID)
+}
+
+// ListInvoices returns the invoices of a subscription of the user
+func (s *BillingService) ListInvoices(ctx context.Context, userID, subscriptionID string) ([]*Invoice, error) {
+	if _, err := s.GetSubscription(ctx, userID, subscriptionID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListInvoices(ctx, subscriptionID)
+}
+
+// CancelSubscription cancels a subscription of the user, or of any user
+// when userID is empty, and voids its unpaid invoices so they are no longer
+// retried
+func (s *BillingService) CancelSubscription(ctx context.Context, userID, id string) (*Subscription, error) {
+	sub, err := s.GetSubscription(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status == SubscriptionStatusCanceled {
+		return nil, ErrSubscriptionCanceled
+	}
+	if err := s.cancel(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (s *BillingService) cancel(ctx context.Context, sub *Subscription) error {
+	invoices, err := s.repo.ListInvoices(ctx, sub.ID)
+	if err != nil {
+		return err
+	}
+	for _, inv := range invoices {
+		if inv.Status != InvoiceStatusUnpaid {
+			continue
+		}
+		inv.Status = InvoiceStatusVoid
+		inv.NextAttemptAt = nil
+		if err := s.repo.UpdateInvoice(ctx, inv); err != nil {
+			return err
+		}
+	}
 
 	now := time.Now()
 	sub.Status = SubscriptionStatusCanceled
 	sub.CanceledAt = &now
 
 	if err := s.repo.UpdateSubscription(ctx, sub); err != nil {
+		return err
+	}
+
+	s.publish(ctx, EventSubscriptionCanceled, sub, nil)
+	return nil
+}
+
+// ChangePlan moves an active subscription to another plan of the same
+// currency and interval. Upgrades are invoiced the prorated difference for
+// the rest of the period; downgrades credit it to the subscription, which
+// is taken off later invoices. The returned invoice is nil when nothing is
+// owed.
+func (s *BillingService) ChangePlan(ctx context.Context, userID, id, planID string) (*Subscription, *Invoice, error) {
+	sub, err := s.GetSubscription(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sub.Status != SubscriptionStatusActive {
+		return nil, nil, ErrSubscriptionInactive
+	}
+	if sub.PlanID == planID {
+		return nil, nil, ErrSamePlan
+	}
+	oldPlan, err := s.getPlan(ctx, sub.PlanID)
+	if err != nil {
+		return nil, nil, err
+	}
+	newPlan, err := s.getPlan(ctx, planID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	amount, err := Prorate(oldPlan, newPlan, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	owed := s.applyCredit(sub, amount)
+
+	sub.PlanID = planID
+	if err := s.repo.UpdateSubscription(ctx, sub); err != nil {
+		return nil, nil, err
+	}
+
+	var inv *Invoice
+	if owed > 0 {
+		description := fmt.Sprintf("Proration from %s to %s", oldPlan.Name, newPlan.Name)
+		if inv, err = s.createInvoice(ctx, sub, owed, newPlan.Currency, InvoiceReasonProration, description); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	s.publish(ctx, EventSubscriptionPlanChanged, sub, map[string]interface{}{
+		"previous_plan_id": oldPlan.ID,
+		"proration_amount": amount,
+		"credit_balance":   sub.CreditBalance,
+	})
+	return sub, inv, nil
+}
+
+// RenewSubscription starts the next period of a due subscription and
+// raises its invoice, less any credit. An invoice covered by credit is
+// settled straight away.
+func (s *BillingService) RenewSubscription(ctx context.Context, sub *Subscription) (*Invoice, error) {
+	plan, err := s.getPlan(ctx, sub.PlanID)
+	if err != nil {
 		return nil, err
 	}
 
-	return sub, nil
+	sub.CurrentPeriodStart = sub.CurrentPeriodEnd
+	sub.CurrentPeriodEnd = CalculateNextPeriod(sub.CurrentPeriodStart, plan.Interval)
+	owed := s.applyCredit(sub, plan.Amount)
+	if err := s.repo.UpdateSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	inv, err := s.createInvoice(ctx, sub, owed, plan.Currency, InvoiceReasonCycle, plan.Name)
+	if err != nil {
+		return nil, err
+	}
+	if owed == 0 {
+		inv.Status = InvoiceStatusPaid
+		inv.NextAttemptAt = nil
+		if err := s.repo.UpdateInvoice(ctx, inv); err != nil {
+			return nil, err
+		}
+	}
+
+	s.publish(ctx, EventSubscriptionRenewed, sub, invoiceEventData(inv))
+	return inv, nil
 }
 
+// AttachPaymentIntent records the payment intent an invoice is collected with
+func (s *BillingService) AttachPaymentIntent(ctx context.Context, inv *Invoice, paymentIntentID string) error {
+	inv.PaymentIntentID = paymentIntentID
+	return s.repo.UpdateInvoice(ctx, inv)
+}
+
+// RecordPayment settles an invoice and reactivates its subscription
+func (s *BillingService) RecordPayment(ctx context.Context, inv *Invoice) error {
+	sub, err := s.GetSubscription(ctx, "", inv.SubscriptionID)
+	if err != nil {
+		return err
+	}
+
+	inv.Status = InvoiceStatusPaid
+	inv.AttemptCount++
+	inv.NextAttemptAt = nil
+	if err := s.repo.UpdateInvoice(ctx, inv); err != nil {
+		return err
+	}
+
+	if sub.Status == SubscriptionStatusPastDue || sub.Status == SubscriptionStatusIncomplete {
+		sub.Status = SubscriptionStatusActive
+		if err := s.repo.UpdateSubscription(ctx, sub); err != nil {
+			return err
+		}
+	}
+
+	s.publish(ctx, EventSubscriptionPaymentSucceeded, sub, invoiceEventData(inv))
+	return nil
+}
+
+// RecordPaymentFailure schedules the next charge of a declined invoice
+// following DunningSchedule and marks its subscription past due. Once the
+// retries are exhausted the subscription is canceled.
+func (s *BillingService) RecordPaymentFailure(ctx context.Context, inv *Invoice, reason string) error {
+	sub, err := s.GetSubscription(ctx, "", inv.SubscriptionID)
+	if err != nil {
+		return err
+	}
+
+	inv.AttemptCount++
+	exhausted := inv.AttemptCount > len(DunningSchedule)
+	if exhausted {
+		inv.NextAttemptAt = nil
+	} else {
+		next := time.Now().Add(DunningSchedule[inv.AttemptCount-1])
+		inv.NextAttemptAt = &next
+	}
+	if err := s.repo.UpdateInvoice(ctx, inv); err != nil {
+		return err
+	}
+
+	if !exhausted && sub.Status == SubscriptionStatusActive {
+		sub.Status = SubscriptionStatusPastDue
+		if err := s.repo.UpdateSubscription(ctx, sub); err != nil {
+			return err
+		}
+	}
+
+	data := invoiceEventData(inv)
+	data["failure_reason"] = reason
+	s.publish(ctx, EventSubscriptionPaymentFailed, sub, data)
+
+	if exhausted && sub.Status != SubscriptionStatusCanceled {
+		return s.cancel(ctx, sub)
+	}
+	return nil
+}
+
+// ListDueInvoices returns the invoices to charge now
+func (s *BillingService) ListDueInvoices(ctx context.Context, limit int) ([]*Invoice, error) {
+	return s.repo.ListDueInvoices(ctx, time.Now(), limit)
+}
+
+func (s *BillingService) ListDueSubscriptions(ctx context.Context) ([]*Subscription, error) {
+	return s.repo.ListDueSubscriptions(ctx)
+}
+
+func (s *BillingService) getPlan(ctx context.Context, id string) (*Plan, error) {
+	plan, err := s.repo.GetPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return nil, ErrPlanNotFound
+	}
+	return plan, nil
+}
+
+// applyCredit takes the subscription's credit off an amount, or adds a
+// negative amount to the credit, and returns what is left to charge
+func (s *BillingService) applyCredit(sub *Subscription, amount int64) int64 {
+	owed := amount - sub.CreditBalance
+	if owed < 0 {
+		sub.CreditBalance = -owed
+		return 0
+	}
+	sub.CreditBalance = 0
+	return owed
+}
+
+// createInvoice raises an unpaid invoice for the subscription's current
+// period, due for collection straight away
+func (s *BillingService) createInvoice(ctx context.Context, sub *Subscription, amount int64, currency, reason, description string) (*Invoice, error) {
+	now := time.Now()
+	inv := &Invoice{
+		ID:             uuid.New().String(),
+		SubscriptionID: sub.ID,
+		UserID:         sub.UserID,
+		OrgID:          sub.OrgID,
+		Amount:         amount,
+		Currency:       currency,
+		Status:         InvoiceStatusUnpaid,
+		Reason:         reason,
+		Description:    description,
+		PeriodStart:    sub.CurrentPeriodStart,
+		PeriodEnd:      sub.CurrentPeriodEnd,
+		NextAttemptAt:  &now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.repo.CreateInvoice(ctx, inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// CalculateNextPeriod returns when a period starting at start ends. A
+// period starting on a day its last month lacks, such as January 31 or
+// February 29, ends on that month's last day instead of spilling into the
+// next one.
 func CalculateNextPeriod(start time.Time, interval string) time.Time {
+	next := start.AddDate(0, 1, 0) // Default to month
 	if interval == "year" {
-		return start.AddDate(1, 0, 0)
+		next = start.AddDate(1, 0, 0)
+	}
+	if next.Day() != start.Day() {
+		next = next.AddDate(0, 0, -next.Day())
 	}
-	return start.AddDate(0, 1, 0) // Default to month
+	return next
 }
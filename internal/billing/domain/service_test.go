@@ -0,0 +1,228 @@
+package domain
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// billingRepo is a mock repository keeping subscriptions and invoices in
+// memory, with a basic and a pro monthly plan
+func billingRepo(sub *Subscription) (*MockRepository, *[]*Invoice) {
+	plans := map[string]*Plan{
+		"plan_basic": {ID: "plan_basic", Name: "Basic", Amount: 1000, Currency: "USD", Interval: "month"},
+		"plan_pro":   {ID: "plan_pro", Name: "Pro", Amount: 3000, Currency: "USD", Interval: "month"},
+	}
+	var invoices []*Invoice
+	repo := &MockRepository{
+		GetPlanFunc: func(ctx context.Context, id string) (*Plan, error) { return plans[id], nil },
+		GetSubscriptionFunc: func(ctx context.Context, id string) (*Subscription, error) {
+			if id != sub.ID {
+				return nil, nil
+			}
+			return sub, nil
+		},
+		UpdateSubscriptionFunc: func(ctx context.Context, s *Subscription) error { return nil },
+		CreateInvoiceFunc: func(ctx context.Context, inv *Invoice) error {
+			invoices = append(invoices, inv)
+			return nil
+		},
+		UpdateInvoiceFunc: func(ctx context.Context, inv *Invoice) error { return nil },
+		ListInvoicesFunc: func(ctx context.Context, subscriptionID string) ([]*Invoice, error) {
+			return invoices, nil
+		},
+	}
+	return repo, &invoices
+}
+
+func TestBillingService_ChangePlan(t *testing.T) {
+	// A 30-day period with 10 days left
+	now := time.Now()
+	start, end := now.Add(-20*24*time.Hour), now.Add(10*24*time.Hour)
+
+	tests := []struct {
+		name        string
+		from, to    string
+		credit      int64
+		wantInvoice int64 // 0 for no invoice
+		wantCredit  int64
+	}{
+		{"Upgrade", "plan_basic", "plan_pro", 0, 667, 0},
+		{"Upgrade paid partly from credit", "plan_basic", "plan_pro", 500, 167, 0},
+		{"Upgrade covered by credit", "plan_basic", "plan_pro", 1000, 0, 333},
+		{"Downgrade", "plan_pro", "plan_basic", 0, 0, 667},
+		{"Downgrade adding to credit", "plan_pro", "plan_basic", 100, 0, 767},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &Subscription{
+				ID: "sub_1", UserID: "user_1", PlanID: tt.from, Status: SubscriptionStatusActive,
+				CurrentPeriodStart: start, CurrentPeriodEnd: end, CreditBalance: tt.credit,
+			}
+			repo, invoices := billingRepo(sub)
+			service := NewBillingService(repo, nil)
+
+			_, inv, err := service.ChangePlan(context.Background(), "user_1", "sub_1", tt.to)
+			if err != nil {
+				t.Fatalf("ChangePlan failed: %v", err)
+			}
+			if sub.PlanID != tt.to {
+				t.Errorf("Expected the subscription on %s, got %s", tt.to, sub.PlanID)
+			}
+			if sub.CreditBalance != tt.wantCredit {
+				t.Errorf("Expected a credit of %d, got %d", tt.wantCredit, sub.CreditBalance)
+			}
+
+			if tt.wantInvoice == 0 {
+				if inv != nil || len(*invoices) != 0 {
+					t.Fatalf("Expected no invoice, got %+v", inv)
+				}
+				return
+			}
+			if inv == nil || len(*invoices) != 1 {
+				t.Fatalf("Expected one invoice, got %d", len(*invoices))
+			}
+			if inv.Amount != tt.wantInvoice || inv.Reason != InvoiceReasonProration || inv.Status != InvoiceStatusUnpaid {
+				t.Errorf("Expected an unpaid proration invoice of %d, got %+v", tt.wantInvoice, inv)
+			}
+			if !inv.PeriodStart.Equal(start) || !inv.PeriodEnd.Equal(end) {
+				t.Errorf("Expected the invoice for the current period, got %s to %s", inv.PeriodStart, inv.PeriodEnd)
+			}
+		})
+	}
+
+	t.Run("Rejected changes", func(t *testing.T) {
+		rejected := []struct {
+			name    string
+			status  SubscriptionStatus
+			to      string
+			wantErr error
+		}{
+			{"Same plan", SubscriptionStatusActive, "plan_basic", ErrSamePlan},
+			{"Past due", SubscriptionStatusPastDue, "plan_pro", ErrSubscriptionInactive},
+			{"Unknown plan", SubscriptionStatusActive, "plan_missing", ErrPlanNotFound},
+		}
+		for _, tt := range rejected {
+			sub := &Subscription{ID: "sub_1", UserID: "user_1", PlanID: "plan_basic", Status: tt.status, CurrentPeriodStart: start, CurrentPeriodEnd: end}
+			repo, _ := billingRepo(sub)
+			if _, _, err := NewBillingService(repo, nil).ChangePlan(context.Background(), "user_1", "sub_1", tt.to); err != tt.wantErr {
+				t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+			}
+		}
+	})
+}
+
+func TestBillingService_RenewSubscription(t *testing.T) {
+	tests := []struct {
+		name       string
+		periodEnd  time.Time
+		credit     int64
+		wantEnd    time.Time
+		wantAmount int64
+		wantStatus string
+		wantCredit int64
+	}{
+		{"Renewal", date(2024, 4, 15), 0, date(2024, 5, 15), 1000, InvoiceStatusUnpaid, 0},
+		{"Renewal into a leap February", date(2024, 1, 31), 0, date(2024, 2, 29), 1000, InvoiceStatusUnpaid, 0},
+		{"Renewal less credit", date(2024, 4, 15), 400, date(2024, 5, 15), 600, InvoiceStatusUnpaid, 0},
+		{"Renewal covered by credit", date(2024, 4, 15), 1500, date(2024, 5, 15), 0, InvoiceStatusPaid, 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &Subscription{
+				ID: "sub_1", PlanID: "plan_basic", Status: SubscriptionStatusActive,
+				CurrentPeriodStart: tt.periodEnd.AddDate(0, -1, 0), CurrentPeriodEnd: tt.periodEnd, CreditBalance: tt.credit,
+			}
+			repo, _ := billingRepo(sub)
+
+			inv, err := NewBillingService(repo, nil).RenewSubscription(context.Background(), sub)
+			if err != nil {
+				t.Fatalf("RenewSubscription failed: %v", err)
+			}
+			if !sub.CurrentPeriodStart.Equal(tt.periodEnd) || !sub.CurrentPeriodEnd.Equal(tt.wantEnd) {
+				t.Errorf("Expected the period %s to %s, got %s to %s", tt.periodEnd, tt.wantEnd, sub.CurrentPeriodStart, sub.CurrentPeriodEnd)
+			}
+			if inv.Amount != tt.wantAmount || inv.Status != tt.wantStatus || inv.Reason != InvoiceReasonCycle {
+				t.Errorf("Expected a %s cycle invoice of %d, got %+v", tt.wantStatus, tt.wantAmount, inv)
+			}
+			if (inv.NextAttemptAt == nil) != (tt.wantStatus == InvoiceStatusPaid) {
+				t.Errorf("Expected only unpaid invoices to be charged, got next attempt %v", inv.NextAttemptAt)
+			}
+			if sub.CreditBalance != tt.wantCredit {
+				t.Errorf("Expected a credit of %d left, got %d", tt.wantCredit, sub.CreditBalance)
+			}
+		})
+	}
+}
+
+func TestBillingService_Dunning(t *testing.T) {
+	sub := &Subscription{ID: "sub_1", PlanID: "plan_basic", Status: SubscriptionStatusActive}
+	repo, invoices := billingRepo(sub)
+	service := NewBillingService(repo, nil)
+	inv := &Invoice{ID: "inv_1", SubscriptionID: "sub_1", Amount: 1000, Status: InvoiceStatusUnpaid}
+	*invoices = append(*invoices, inv)
+
+	// Each failed charge is retried after the next delay of the schedule,
+	// and the subscription canceled when the last retry fails
+	tests := []struct {
+		wantAttempts int
+		wantRetryIn  time.Duration // 0 for no retry
+		wantStatus   SubscriptionStatus
+	}{
+		{1, 24 * time.Hour, SubscriptionStatusPastDue},
+		{2, 3 * 24 * time.Hour, SubscriptionStatusPastDue},
+		{3, 5 * 24 * time.Hour, SubscriptionStatusPastDue},
+		{4, 0, SubscriptionStatusCanceled},
+	}
+	if len(tests) != len(DunningSchedule)+1 {
+		t.Fatalf("Expected a case per retry of the schedule")
+	}
+
+	for _, tt := range tests {
+		before := time.Now()
+		if err := service.RecordPaymentFailure(context.Background(), inv, "card_declined"); err != nil {
+			t.Fatalf("Attempt %d: RecordPaymentFailure failed: %v", tt.wantAttempts, err)
+		}
+		if inv.AttemptCount != tt.wantAttempts {
+			t.Errorf("Expected %d attempts, got %d", tt.wantAttempts, inv.AttemptCount)
+		}
+		if sub.Status != tt.wantStatus {
+			t.Errorf("Attempt %d: expected the subscription %s, got %s", tt.wantAttempts, tt.wantStatus, sub.Status)
+		}
+
+		if tt.wantRetryIn == 0 {
+			if inv.NextAttemptAt != nil || inv.Status != InvoiceStatusVoid {
+				t.Errorf("Attempt %d: expected the invoice voided without a retry, got %s next at %v", tt.wantAttempts, inv.Status, inv.NextAttemptAt)
+			}
+			continue
+		}
+		if inv.NextAttemptAt == nil {
+			t.Fatalf("Attempt %d: expected a retry", tt.wantAttempts)
+		}
+		if retryIn := inv.NextAttemptAt.Sub(before); retryIn < tt.wantRetryIn || retryIn > tt.wantRetryIn+time.Minute {
+			t.Errorf("Attempt %d: expected a retry in %s, got %s", tt.wantAttempts, tt.wantRetryIn, retryIn)
+		}
+	}
+	if sub.CanceledAt == nil {
+		t.Error("Expected the cancellation time recorded")
+	}
+
+	t.Run("Payment after a failure reactivates", func(t *testing.T) {
+		sub := &Subscription{ID: "sub_1", PlanID: "plan_basic", Status: SubscriptionStatusActive}
+		repo, _ := billingRepo(sub)
+		service := NewBillingService(repo, nil)
+		inv := &Invoice{ID: "inv_1", SubscriptionID: "sub_1", Amount: 1000, Status: InvoiceStatusUnpaid}
+
+		if err := service.RecordPaymentFailure(context.Background(), inv, "card_declined"); err != nil {
+			t.Fatalf("RecordPaymentFailure failed: %v", err)
+		}
+		if err := service.RecordPayment(context.Background(), inv); err != nil {
+			t.Fatalf("RecordPayment failed: %v", err)
+		}
+		if sub.Status != SubscriptionStatusActive || inv.Status != InvoiceStatusPaid || inv.NextAttemptAt != nil || inv.AttemptCount != 2 {
+			t.Errorf("Expected a paid invoice after 2 attempts and an active subscription, got %+v, %s", inv, sub.Status)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/billing/domain"
+)
+
+// HTTPPaymentClient collects invoices through the payments service's REST
+// API, acting as the subscriber's user
+type HTTPPaymentClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewHTTPPaymentClient(baseURL string) *HTTPPaymentClient {
+	return &HTTPPaymentClient{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// CreatePaymentIntent creates the payment intent of an invoice. The
+// invoice's ID is the idempotency key, so an invoice gets a single intent
+// however often this is retried.
+func (c *HTTPPaymentClient) CreatePaymentIntent(ctx context.Context, inv *domain.Invoice) (string, error) {
+	var intent struct {
+		ID string `json:"id"`
+	}
+	err := c.post(ctx, "/intents", inv.UserID, "inv_"+inv.ID, map[string]interface{}{
+		"amount":      inv.Amount,
+		"currency":    inv.Currency,
+		"description": fmt.Sprintf("Invoice %s: %s", inv.ID, inv.Description),
+	}, &intent)
+	if err != nil {
+		return "", err
+	}
+	return intent.ID, nil
+}
+
+// ConfirmPaymentIntent charges the payment method. A refused charge returns
+// domain.ErrPaymentDeclined.
+func (c *HTTPPaymentClient) ConfirmPaymentIntent(ctx context.Context, userID, intentID, paymentMethodID, idempotencyKey string) error {
+	var result struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	err := c.post(ctx, "/intents/"+intentID+"/confirm", userID, idempotencyKey, map[string]string{
+		"payment_method_id": paymentMethodID,
+	}, &result)
+	if err != nil {
+		return err
+	}
+	if result.Status != "succeeded" {
+		return fmt.Errorf("%w: %s", domain.ErrPaymentDeclined, result.Reason)
+	}
+	return nil
+}
+
+func (c *HTTPPaymentClient) post(ctx context.Context, path, userID, idempotencyKey string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", userID)
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusBadRequest {
+		// The payments service rejects charges it cannot attempt, such as
+		// an unknown payment method, with a 400
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("%w: %s", domain.ErrPaymentDeclined, apiErr.Error)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("payments service returned %s for %s", resp.Status, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -8,6 +8,14 @@ import (
 	"github.com/sapliy/fintech-ecosystem/internal/billing/domain"
 )
 
+const subscriptionColumns = `id, user_id, org_id, plan_id, COALESCE(payment_method_id, ''), status, current_period_start, current_period_end, credit_balance, canceled_at, created_at, updated_at`
+
+const invoiceColumns = `id, subscription_id, user_id, org_id, amount, currency, status, reason, COALESCE(description, ''), period_start, period_end, COALESCE(payment_intent_id::text, ''), attempt_count, next_attempt_at, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
 type SQLRepository struct {
 	db *sql.DB
 }
@@ -18,33 +26,40 @@ func NewSQLRepository(db *sql.DB) *SQLRepository {
 
 func (r *SQLRepository) CreateSubscription(ctx context.Context, sub *domain.Subscription) error {
 	query := `
-		INSERT INTO subscriptions (id, user_id, org_id, plan_id, status, current_period_start, current_period_end, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
-	_, err := r.db.ExecContext(ctx, query, sub.ID, sub.UserID, sub.OrgID, sub.PlanID, sub.Status, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, sub.CreatedAt, sub.UpdatedAt)
+		INSERT INTO subscriptions (id, user_id, org_id, plan_id, payment_method_id, status, current_period_start, current_period_end, credit_balance, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11)`
+	_, err := r.db.ExecContext(ctx, query, sub.ID, sub.UserID, sub.OrgID, sub.PlanID, sub.PaymentMethodID, sub.Status, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, sub.CreditBalance, sub.CreatedAt, sub.UpdatedAt)
 	return err
 }
 
 func (r *SQLRepository) GetSubscription(ctx context.Context, id string) (*domain.Subscription, error) {
-	query := `SELECT id, user_id, org_id, plan_id, status, current_period_start, current_period_end, canceled_at, created_at, updated_at FROM subscriptions WHERE id = $1`
-	row := r.db.QueryRowContext(ctx, query, id)
-
-	var sub domain.Subscription
-	err := row.Scan(&sub.ID, &sub.UserID, &sub.OrgID, &sub.PlanID, &sub.Status, &sub.CurrentPeriodStart, &sub.CurrentPeriodEnd, &sub.CanceledAt, &sub.CreatedAt, &sub.UpdatedAt)
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id = $1`
+	sub, err := scanSubscription(r.db.QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-	return &sub, err
+	return sub, err
 }
 
 func (r *SQLRepository) UpdateSubscription(ctx context.Context, sub *domain.Subscription) error {
-	query := `UPDATE subscriptions SET status = $1, current_period_start = $2, current_period_end = $3, canceled_at = $4, updated_at = $5 WHERE id = $6`
-	_, err := r.db.ExecContext(ctx, query, sub.Status, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, sub.CanceledAt, time.Now(), sub.ID)
+	sub.UpdatedAt = time.Now()
+	query := `UPDATE subscriptions SET plan_id = $1, status = $2, current_period_start = $3, current_period_end = $4, credit_balance = $5, canceled_at = $6, updated_at = $7 WHERE id = $8`
+	_, err := r.db.ExecContext(ctx, query, sub.PlanID, sub.Status, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, sub.CreditBalance, sub.CanceledAt, sub.UpdatedAt, sub.ID)
 	return err
 }
 
+func (r *SQLRepository) ListSubscriptions(ctx context.Context, userID string) ([]*domain.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE user_id = $1 ORDER BY created_at DESC`
+	return r.querySubscriptions(ctx, query, userID)
+}
+
 func (r *SQLRepository) ListDueSubscriptions(ctx context.Context) ([]*domain.Subscription, error) {
-	query := `SELECT id, user_id, org_id, plan_id, status, current_period_start, current_period_end, created_at, updated_at FROM subscriptions WHERE status = 'active' AND current_period_end <= $1`
-	rows, err := r.db.QueryContext(ctx, query, time.Now())
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE status = 'active' AND current_period_end <= $1`
+	return r.querySubscriptions(ctx, query, time.Now())
+}
+
+func (r *SQLRepository) querySubscriptions(ctx context.Context, query string, args ...interface{}) ([]*domain.Subscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -52,23 +67,110 @@ func (r *SQLRepository) ListDueSubscriptions(ctx context.Context) ([]*domain.Sub
 
 	var subs []*domain.Subscription
 	for rows.Next() {
-		var sub domain.Subscription
-		if err := rows.Scan(&sub.ID, &sub.UserID, &sub.OrgID, &sub.PlanID, &sub.Status, &sub.CurrentPeriodStart, &sub.CurrentPeriodEnd, &sub.CreatedAt, &sub.UpdatedAt); err != nil {
+		sub, err := scanSubscription(rows)
+		if err != nil {
 			return nil, err
 		}
-		subs = append(subs, &sub)
+		subs = append(subs, sub)
 	}
-	return subs, nil
+	return subs, rows.Err()
+}
+
+func scanSubscription(row scanner) (*domain.Subscription, error) {
+	var sub domain.Subscription
+	err := row.Scan(&sub.ID, &sub.UserID, &sub.OrgID, &sub.PlanID, &sub.PaymentMethodID, &sub.Status, &sub.CurrentPeriodStart, &sub.CurrentPeriodEnd, &sub.CreditBalance, &sub.CanceledAt, &sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *SQLRepository) CreatePlan(ctx context.Context, plan *domain.Plan) error {
+	query := `INSERT INTO plans (id, name, amount, currency, interval, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.ExecContext(ctx, query, plan.ID, plan.Name, plan.Amount, plan.Currency, plan.Interval, plan.CreatedAt)
+	return err
 }
 
 func (r *SQLRepository) GetPlan(ctx context.Context, id string) (*domain.Plan, error) {
-	query := `SELECT id, name, amount, currency, interval FROM plans WHERE id = $1`
+	query := `SELECT id, name, amount, currency, interval, created_at FROM plans WHERE id = $1`
 	row := r.db.QueryRowContext(ctx, query, id)
 
 	var plan domain.Plan
-	err := row.Scan(&plan.ID, &plan.Name, &plan.Amount, &plan.Currency, &plan.Interval)
+	err := row.Scan(&plan.ID, &plan.Name, &plan.Amount, &plan.Currency, &plan.Interval, &plan.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	return &plan, err
 }
+
+func (r *SQLRepository) ListPlans(ctx context.Context) ([]*domain.Plan, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, amount, currency, interval, created_at FROM plans ORDER BY amount`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var plans []*domain.Plan
+	for rows.Next() {
+		var plan domain.Plan
+		if err := rows.Scan(&plan.ID, &plan.Name, &plan.Amount, &plan.Currency, &plan.Interval, &plan.CreatedAt); err != nil {
+			return nil, err
+		}
+		plans = append(plans, &plan)
+	}
+	return plans, rows.Err()
+}
+
+func (r *SQLRepository) CreateInvoice(ctx context.Context, inv *domain.Invoice) error {
+	query := `
+		INSERT INTO invoices (id, subscription_id, user_id, org_id, amount, currency, status, reason, description, period_start, period_end, payment_intent_id, attempt_count, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, '')::uuid, $13, $14, $15, $16)`
+	_, err := r.db.ExecContext(ctx, query, inv.ID, inv.SubscriptionID, inv.UserID, inv.OrgID, inv.Amount, inv.Currency, inv.Status, inv.Reason, inv.Description,
+		inv.PeriodStart, inv.PeriodEnd, inv.PaymentIntentID, inv.AttemptCount, inv.NextAttemptAt, inv.CreatedAt, inv.UpdatedAt)
+	return err
+}
+
+func (r *SQLRepository) UpdateInvoice(ctx context.Context, inv *domain.Invoice) error {
+	inv.UpdatedAt = time.Now()
+	query := `UPDATE invoices SET status = $1, payment_intent_id = NULLIF($2, '')::uuid, attempt_count = $3, next_attempt_at = $4, updated_at = $5 WHERE id = $6`
+	_, err := r.db.ExecContext(ctx, query, inv.Status, inv.PaymentIntentID, inv.AttemptCount, inv.NextAttemptAt, inv.UpdatedAt, inv.ID)
+	return err
+}
+
+func (r *SQLRepository) ListInvoices(ctx context.Context, subscriptionID string) ([]*domain.Invoice, error) {
+	query := `SELECT ` + invoiceColumns + ` FROM invoices WHERE subscription_id = $1 ORDER BY created_at DESC`
+	return r.queryInvoices(ctx, query, subscriptionID)
+}
+
+func (r *SQLRepository) ListDueInvoices(ctx context.Context, now time.Time, limit int) ([]*domain.Invoice, error) {
+	query := `SELECT ` + invoiceColumns + ` FROM invoices WHERE status = 'unpaid' AND next_attempt_at <= $1 ORDER BY next_attempt_at LIMIT $2`
+	return r.queryInvoices(ctx, query, now, limit)
+}
+
+func (r *SQLRepository) queryInvoices(ctx context.Context, query string, args ...interface{}) ([]*domain.Invoice, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var invoices []*domain.Invoice
+	for rows.Next() {
+		inv, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, inv)
+	}
+	return invoices, rows.Err()
+}
+
+func scanInvoice(row scanner) (*domain.Invoice, error) {
+	var inv domain.Invoice
+	err := row.Scan(&inv.ID, &inv.SubscriptionID, &inv.UserID, &inv.OrgID, &inv.Amount, &inv.Currency, &inv.Status, &inv.Reason, &inv.Description,
+		&inv.PeriodStart, &inv.PeriodEnd, &inv.PaymentIntentID, &inv.AttemptCount, &inv.NextAttemptAt, &inv.CreatedAt, &inv.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
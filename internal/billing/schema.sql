@@ -12,9 +12,11 @@ CREATE TABLE IF NOT EXISTS subscriptions (
     user_id UUID NOT NULL,
     org_id UUID NOT NULL,
     plan_id UUID NOT NULL REFERENCES plans(id),
+    payment_method_id VARCHAR(255),
     status VARCHAR(50) NOT NULL,
     current_period_start TIMESTAMP WITH TIME ZONE NOT NULL,
     current_period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    credit_balance BIGINT NOT NULL DEFAULT 0,
     canceled_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
@@ -28,12 +30,31 @@ CREATE TABLE IF NOT EXISTS invoices (
     amount BIGINT NOT NULL,
     currency VARCHAR(3) NOT NULL,
     status VARCHAR(50) NOT NULL,
+    reason VARCHAR(50) NOT NULL DEFAULT 'subscription_cycle',
+    description TEXT,
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    period_end TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
     payment_intent_id UUID,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+    attempt_count INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE, -- Next charge of an unpaid invoice (dunning)
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
-CREATE INDEX idx_subscriptions_user_id ON subscriptions(user_id);
-CREATE INDEX idx_subscriptions_org_id ON subscriptions(org_id);
-CREATE INDEX idx_subscriptions_status ON subscriptions(status);
-CREATE INDEX idx_invoices_subscription_id ON invoices(subscription_id);
-CREATE INDEX idx_invoices_org_id ON invoices(org_id);
+-- Columns added with proration and dunning, for databases created before
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS payment_method_id VARCHAR(255);
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS credit_balance BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS reason VARCHAR(50) NOT NULL DEFAULT 'subscription_cycle';
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS period_start TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS period_end TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS attempt_count INT NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_id ON subscriptions(user_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_org_id ON subscriptions(org_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_status ON subscriptions(status);
+CREATE INDEX IF NOT EXISTS idx_invoices_subscription_id ON invoices(subscription_id);
+CREATE INDEX IF NOT EXISTS idx_invoices_org_id ON invoices(org_id);
+CREATE INDEX IF NOT EXISTS idx_invoices_due ON invoices(next_attempt_at) WHERE status = 'unpaid';
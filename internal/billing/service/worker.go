@@ -2,25 +2,35 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/sapliy/fintech-ecosystem/internal/billing/domain"
 )
 
+// dueInvoiceBatch caps the invoices charged per run
+const dueInvoiceBatch = 100
+
+// PaymentClient collects invoices through the payments service
 type PaymentClient interface {
-	CreatePayment(ctx context.Context, userID, orgID string, amount int64, currency string) (string, error)
+	CreatePaymentIntent(ctx context.Context, inv *domain.Invoice) (string, error)
+	// ConfirmPaymentIntent returns domain.ErrPaymentDeclined when the charge is refused
+	ConfirmPaymentIntent(ctx context.Context, userID, intentID, paymentMethodID, idempotencyKey string) error
 }
 
+// SubscriptionWorker renews due subscriptions and charges their invoices,
+// retrying declined charges on the dunning schedule
 type SubscriptionWorker struct {
-	repo          domain.Repository
+	service       *domain.BillingService
 	paymentClient PaymentClient
 	interval      time.Duration
 }
 
-func NewSubscriptionWorker(repo domain.Repository, paymentClient PaymentClient, interval time.Duration) *SubscriptionWorker {
+func NewSubscriptionWorker(service *domain.BillingService, paymentClient PaymentClient, interval time.Duration) *SubscriptionWorker {
 	return &SubscriptionWorker{
-		repo:          repo,
+		service:       service,
 		paymentClient: paymentClient,
 		interval:      interval,
 	}
@@ -41,41 +51,70 @@ func (w *SubscriptionWorker) Start(ctx context.Context) {
 }
 
 func (w *SubscriptionWorker) runProcess(ctx context.Context) {
-	subs, err := w.repo.ListDueSubscriptions(ctx)
+	subs, err := w.service.ListDueSubscriptions(ctx)
 	if err != nil {
 		log.Printf("Worker: failed to list due subscriptions: %v", err)
 		return
 	}
 
 	for _, sub := range subs {
-		if err := w.processSubscription(ctx, sub); err != nil {
-			log.Printf("Worker: failed to process sub %s: %v", sub.ID, err)
+		log.Printf("Worker: Processing renewal for sub %s, user %s", sub.ID, sub.UserID)
+		if _, err := w.service.RenewSubscription(ctx, sub); err != nil {
+			log.Printf("Worker: failed to renew sub %s: %v", sub.ID, err)
 		}
 	}
-}
 
-func (w *SubscriptionWorker) processSubscription(ctx context.Context, sub *domain.Subscription) error {
-	plan, err := w.repo.GetPlan(ctx, sub.PlanID)
+	// Charges the new invoices along with the dunning retries that are due
+	invoices, err := w.service.ListDueInvoices(ctx, dueInvoiceBatch)
 	if err != nil {
-		return err
+		log.Printf("Worker: failed to list due invoices: %v", err)
+		return
 	}
+	for _, inv := range invoices {
+		if err := w.ChargeInvoice(ctx, inv); err != nil {
+			log.Printf("Worker: failed to charge invoice %s: %v", inv.ID, err)
+		}
+	}
+}
 
-	// Create Payment
-	log.Printf("Worker: Processing renewal for sub %s, user %s", sub.ID, sub.UserID)
-	paymentID, err := w.paymentClient.CreatePayment(ctx, sub.UserID, sub.OrgID, plan.Amount, plan.Currency)
+// ChargeInvoice attempts to collect an unpaid invoice with its
+// subscription's payment method. A declined charge is recorded as a failed
+// attempt; an error reaching the payments service leaves the invoice due,
+// and the retry reuses the attempt's idempotency key.
+func (w *SubscriptionWorker) ChargeInvoice(ctx context.Context, inv *domain.Invoice) error {
+	if inv.Status != domain.InvoiceStatusUnpaid {
+		return nil
+	}
+	sub, err := w.service.GetSubscription(ctx, "", inv.SubscriptionID)
 	if err != nil {
-		// In a real scenario, we'd mark the sub as past_due after retries
-		sub.Status = domain.SubscriptionStatusPastDue
-		_ = w.repo.UpdateSubscription(ctx, sub)
 		return err
 	}
+	if sub.PaymentMethodID == "" {
+		return w.service.RecordPaymentFailure(ctx, inv, "no payment method")
+	}
 
-	// Update Period
-	sub.CurrentPeriodStart = sub.CurrentPeriodEnd
-	sub.CurrentPeriodEnd = domain.CalculateNextPeriod(sub.CurrentPeriodStart, plan.Interval)
-	sub.Status = domain.SubscriptionStatusActive
-	sub.UpdatedAt = time.Now()
+	if inv.PaymentIntentID == "" {
+		intentID, err := w.paymentClient.CreatePaymentIntent(ctx, inv)
+		if errors.Is(err, domain.ErrPaymentDeclined) {
+			return w.service.RecordPaymentFailure(ctx, inv, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+		if err := w.service.AttachPaymentIntent(ctx, inv, intentID); err != nil {
+			return err
+		}
+	}
 
-	log.Printf("Worker: Sub %s renewed successfully, payment ID: %s", sub.ID, paymentID)
-	return w.repo.UpdateSubscription(ctx, sub)
+	key := fmt.Sprintf("%s-attempt-%d", inv.ID, inv.AttemptCount+1)
+	err = w.paymentClient.ConfirmPaymentIntent(ctx, inv.UserID, inv.PaymentIntentID, sub.PaymentMethodID, key)
+	switch {
+	case err == nil:
+		log.Printf("Worker: Invoice %s of sub %s paid, payment ID: %s", inv.ID, sub.ID, inv.PaymentIntentID)
+		return w.service.RecordPayment(ctx, inv)
+	case errors.Is(err, domain.ErrPaymentDeclined):
+		return w.service.RecordPaymentFailure(ctx, inv, err.Error())
+	default:
+		return err
+	}
 }
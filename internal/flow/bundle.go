@@ -0,0 +1,205 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// FlowBundleFormat is the version of the bundle layout written by ExportFlow
+const FlowBundleFormat = 1
+
+// FlowBundle is a portable copy of a flow definition. Credentials in node
+// and trigger configs are replaced by ${secret:name} placeholders, which
+// are filled in again when the bundle is imported. References to zone
+// secrets ({{secrets.NAME}}) are kept as they are.
+type FlowBundle struct {
+	Format          int                 `json:"format"`
+	ExportedAt      time.Time           `json:"exported_at"`
+	Source          FlowBundleSource    `json:"source"`
+	Name            string              `json:"name"`
+	Description     string              `json:"description"`
+	Version         int                 `json:"version"`
+	Trigger         domain.Trigger      `json:"trigger"`
+	Nodes           []domain.Node       `json:"nodes"`
+	Edges           []domain.Edge       `json:"edges"`
+	MaxDuration     int                 `json:"max_duration_seconds,omitempty"`
+	OnTimeoutNodeID string              `json:"on_timeout_node_id,omitempty"`
+	RetryPolicy     *domain.RetryPolicy `json:"retry_policy,omitempty"`
+	Secrets         []BundleSecret      `json:"secrets,omitempty"`
+}
+
+// FlowBundleSource records where a bundle was exported from
+type FlowBundleSource struct {
+	FlowID string `json:"flow_id"`
+	OrgID  string `json:"org_id"`
+	ZoneID string `json:"zone_id"`
+}
+
+// BundleSecret describes a value removed from a bundle on export
+type BundleSecret struct {
+	Name   string `json:"name"`
+	NodeID string `json:"node_id,omitempty"` // Empty for the trigger config
+	Path   string `json:"path"`              // Dot path into the config
+}
+
+// MissingSecretsError is returned by ImportFlow when secrets referenced by
+// the bundle were not supplied
+type MissingSecretsError struct {
+	Names []string
+}
+
+func (e *MissingSecretsError) Error() string {
+	return "missing secrets: " + strings.Join(e.Names, ", ")
+}
+
+// sensitiveKeys are substrings of config keys whose values are secrets
+var sensitiveKeys = []string{"password", "secret", "token", "apikey", "api_key", "authorization", "private_key", "credential", "webhook_url"}
+
+var secretPlaceholder = regexp.MustCompile(`\$\{secret:([^}]+)\}`)
+
+// ExportFlow bundles a flow, replacing credentials with placeholders
+func ExportFlow(f *domain.Flow) (*FlowBundle, error) {
+	bundle := &FlowBundle{
+		Format:          FlowBundleFormat,
+		ExportedAt:      time.Now(),
+		Source:          FlowBundleSource{FlowID: f.ID, OrgID: f.OrgID, ZoneID: f.ZoneID},
+		Name:            f.Name,
+		Description:     f.Description,
+		Version:         f.Version,
+		Trigger:         f.Trigger,
+		Nodes:           make([]domain.Node, len(f.Nodes)),
+		Edges:           append([]domain.Edge(nil), f.Edges...),
+		MaxDuration:     f.MaxDuration,
+		OnTimeoutNodeID: f.OnTimeoutNodeID,
+		RetryPolicy:     f.RetryPolicy,
+	}
+
+	config, secrets, err := redactSecrets(f.Trigger.Config, "", "trigger")
+	if err != nil {
+		return nil, fmt.Errorf("trigger config: %w", err)
+	}
+	bundle.Trigger.Config = config
+	bundle.Secrets = append(bundle.Secrets, secrets...)
+
+	for i, node := range f.Nodes {
+		data, secrets, err := redactSecrets(node.Data, node.ID, node.ID)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", node.ID, err)
+		}
+		node.Data = data
+		bundle.Nodes[i] = node
+		bundle.Secrets = append(bundle.Secrets, secrets...)
+	}
+	return bundle, nil
+}
+
+// ImportFlow creates a disabled flow in the zone from a bundle, filling in
+// its secret placeholders. The flow still has to be saved.
+func ImportFlow(bundle *FlowBundle, orgID, zoneID string, secrets map[string]string) (*domain.Flow, error) {
+	if bundle.Format == 0 || bundle.Format > FlowBundleFormat {
+		return nil, fmt.Errorf("unsupported bundle format %d", bundle.Format)
+	}
+
+	var missing []string
+	fill := func(raw json.RawMessage) json.RawMessage {
+		if len(raw) == 0 {
+			return raw
+		}
+		return json.RawMessage(secretPlaceholder.ReplaceAllStringFunc(string(raw), func(match string) string {
+			name := secretPlaceholder.FindStringSubmatch(match)[1]
+			value, ok := secrets[name]
+			if !ok {
+				missing = append(missing, name)
+				return match
+			}
+			// Quoted as a JSON string, minus the quotes the placeholder sits in
+			b, _ := json.Marshal(value)
+			return string(b[1 : len(b)-1])
+		}))
+	}
+
+	now := time.Now()
+	f := &domain.Flow{
+		ID:              fmt.Sprintf("flow_%d", now.UnixNano()),
+		OrgID:           orgID,
+		ZoneID:          zoneID,
+		Name:            bundle.Name,
+		Description:     bundle.Description,
+		Enabled:         false,
+		Trigger:         bundle.Trigger,
+		Nodes:           make([]domain.Node, len(bundle.Nodes)),
+		Edges:           append([]domain.Edge(nil), bundle.Edges...),
+		MaxDuration:     bundle.MaxDuration,
+		OnTimeoutNodeID: bundle.OnTimeoutNodeID,
+		RetryPolicy:     bundle.RetryPolicy,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	f.Trigger.Config = fill(bundle.Trigger.Config)
+	for i, node := range bundle.Nodes {
+		node.Data = fill(node.Data)
+		f.Nodes[i] = node
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, &MissingSecretsError{Names: missing}
+	}
+	return f, nil
+}
+
+// redactSecrets replaces the string values of sensitive keys in a config
+// with placeholders named after prefix and the key's path
+func redactSecrets(raw json.RawMessage, nodeID, prefix string) (json.RawMessage, []BundleSecret, error) {
+	if len(raw) == 0 {
+		return raw, nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, nil, err
+	}
+
+	var secrets []BundleSecret
+	var walk func(v interface{}, path string) interface{}
+	walk = func(v interface{}, path string) interface{} {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		for key, child := range m {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			if s, isString := child.(string); isString && s != "" && isSensitiveKey(key) && !secretPlaceholder.MatchString(s) && !domain.IsSecretReference(s) {
+				name := prefix + "." + childPath
+				secrets = append(secrets, BundleSecret{Name: name, NodeID: nodeID, Path: childPath})
+				m[key] = "${secret:" + name + "}"
+				continue
+			}
+			m[key] = walk(child, childPath)
+		}
+		return m
+	}
+	value = walk(value, "")
+
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].Name < secrets[j].Name })
+	b, err := json.Marshal(value)
+	return b, secrets, err
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+type deadLetterKey struct{}
+
+// DeadLetterQueue keeps failed executions so they can be retried with their
+// original input. Executions of flows with a retry policy are retried
+// automatically with exponential backoff; the rest, and those out of
+// retries, stay dead until retried by hand.
+type DeadLetterQueue struct {
+	store    domain.DeadLetterStore
+	repo     domain.Repository
+	runner   *domain.FlowRunner
+	interval time.Duration
+	inflight sync.WaitGroup
+}
+
+// NewDeadLetterQueue creates a queue that retries with the runner and
+// checks for due retries every interval. It registers itself as the
+// runner's failure handler.
+func NewDeadLetterQueue(store domain.DeadLetterStore, repo domain.Repository, runner *domain.FlowRunner, interval time.Duration) *DeadLetterQueue {
+	q := &DeadLetterQueue{
+		store:    store,
+		repo:     repo,
+		runner:   runner,
+		interval: interval,
+	}
+	runner.SetFailureHandler(q)
+	return q
+}
+
+// ExecutionFailed records a failed execution, or a failed retry of an
+// entry, and schedules its next retry if the flow's policy allows one
+func (q *DeadLetterQueue) ExecutionFailed(ctx context.Context, flow *domain.Flow, exec *domain.FlowExecution, err error) {
+	ctx = context.WithoutCancel(ctx)
+
+	entry, _ := ctx.Value(deadLetterKey{}).(*domain.DeadLetter)
+	if entry == nil {
+		now := time.Now()
+		entry = &domain.DeadLetter{
+			ID:                fmt.Sprintf("dlq_%d", now.UnixNano()),
+			FlowID:            flow.ID,
+			ZoneID:            flow.ZoneID,
+			OriginExecutionID: exec.ID,
+			Input:             exec.Input,
+			CreatedAt:         now,
+		}
+	}
+	entry.ExecutionID = exec.ID
+	q.recordFailure(flow, entry, err)
+
+	if entry.Attempts == 1 {
+		if err := q.store.CreateDeadLetter(ctx, entry); err != nil {
+			log.Printf("Failed to dead-letter execution %s of flow %s: %v", exec.ID, flow.ID, err)
+		}
+		return
+	}
+	if err := q.store.UpdateDeadLetter(ctx, entry); err != nil {
+		log.Printf("Failed to update dead letter %s: %v", entry.ID, err)
+	}
+}
+
+// recordFailure counts a failed attempt and decides what happens next
+func (q *DeadLetterQueue) recordFailure(flow *domain.Flow, entry *domain.DeadLetter, err error) {
+	now := time.Now()
+	entry.Attempts++
+	entry.Error = err.Error()
+	entry.UpdatedAt = now
+	entry.NextRetryAt = nil
+	entry.Status = domain.DeadLetterDead
+
+	if flow != nil && flow.RetryPolicy != nil && entry.Attempts < flow.RetryPolicy.MaxAttempts {
+		next := now.Add(flow.RetryPolicy.Backoff(entry.Attempts))
+		entry.Status = domain.DeadLetterScheduled
+		entry.NextRetryAt = &next
+	}
+}
+
+// Get returns an entry of the zone
+func (q *DeadLetterQueue) Get(ctx context.Context, zoneID, id string) (*domain.DeadLetter, error) {
+	entry, err := q.store.GetDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.ZoneID != zoneID {
+		return nil, domain.ErrDeadLetterNotFound
+	}
+	return entry, nil
+}
+
+// List returns a zone's entries, optionally only those with the status
+func (q *DeadLetterQueue) List(ctx context.Context, zoneID string, status domain.DeadLetterStatus, limit, offset int) ([]*domain.DeadLetter, error) {
+	return q.store.ListDeadLetters(ctx, zoneID, status, limit, offset)
+}
+
+// Retry starts a retry of the entry in the background with the flow's
+// current definition. Resolved entries and those already retrying are left
+// alone; the entry is claimed in the store, so concurrent retries of it
+// start it once.
+func (q *DeadLetterQueue) Retry(ctx context.Context, entry *domain.DeadLetter) error {
+	now := time.Now()
+	claimed, err := q.store.ClaimDeadLetterRetry(ctx, entry.ID, now)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return fmt.Errorf("dead letter %s is already retrying or resolved", entry.ID)
+	}
+	entry.Status = domain.DeadLetterRetrying
+	entry.NextRetryAt = nil
+	entry.UpdatedAt = now
+
+	q.inflight.Add(1)
+	go func() {
+		defer q.inflight.Done()
+		q.run(context.Background(), entry)
+	}()
+	return nil
+}
+
+// run executes the entry's flow again. Its side effects share their
+// idempotency keys with the original execution's, so those it completed are
+// not repeated. Failures of the execution itself are recorded through
+// ExecutionFailed.
+func (q *DeadLetterQueue) run(ctx context.Context, entry *domain.DeadLetter) {
+	if entry.OriginExecutionID != "" {
+		ctx = domain.WithIdempotencyScope(ctx, entry.OriginExecutionID)
+	}
+	flow, err := q.repo.GetFlow(ctx, entry.FlowID)
+	if err == nil {
+		var input map[string]interface{}
+		json.Unmarshal(entry.Input, &input)
+		attempts := entry.Attempts
+		err = q.runner.Execute(context.WithValue(ctx, deadLetterKey{}, entry), flow, input)
+		if err == nil {
+			entry.Status = domain.DeadLetterResolved
+			entry.Error = ""
+			entry.UpdatedAt = time.Now()
+		} else if entry.Attempts != attempts {
+			return // Recorded by ExecutionFailed
+		}
+	}
+	if err != nil {
+		// The execution never started, e.g. because the flow was deleted
+		q.recordFailure(flow, entry, err)
+	}
+	if err := q.store.UpdateDeadLetter(ctx, entry); err != nil {
+		log.Printf("Failed to update dead letter %s: %v", entry.ID, err)
+	}
+}
+
+// Start retries due entries every interval until the context is cancelled
+func (q *DeadLetterQueue) Start(ctx context.Context) {
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			q.RetryDue(ctx)
+		}
+	}
+}
+
+// RetryDue starts the retries that are due
+func (q *DeadLetterQueue) RetryDue(ctx context.Context) {
+	due, err := q.store.ListDueDeadLetters(ctx, time.Now())
+	if err != nil {
+		log.Printf("Failed to list due dead letters: %v", err)
+		return
+	}
+	for _, entry := range due {
+		if err := q.Retry(ctx, entry); err != nil {
+			log.Printf("Failed to retry dead letter %s: %v", entry.ID, err)
+		}
+	}
+}
+
+// Wait blocks until retries started so far have finished
+func (q *DeadLetterQueue) Wait() {
+	q.inflight.Wait()
+}
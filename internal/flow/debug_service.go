@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -17,12 +18,16 @@ type DebugService struct {
 	sessionManager *domain.DebugSessionManager
 	repo           domain.Repository
 	upgrader       websocket.Upgrader
+	configure      []func(*domain.FlowRunner)
 }
 
 // NewDebugService creates a new debug service
 func NewDebugService(repo domain.Repository) *DebugService {
+	sessionManager := domain.NewDebugSessionManager()
+	sessionManager.SetStore(repo)
+
 	return &DebugService{
-		sessionManager: domain.NewDebugSessionManager(),
+		sessionManager: sessionManager,
 		repo:           repo,
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
@@ -32,6 +37,12 @@ func NewDebugService(repo domain.Repository) *DebugService {
 	}
 }
 
+// ConfigureRunners registers a function applied to every runner created for
+// a debug run, e.g. to install a node registry
+func (s *DebugService) ConfigureRunners(fn func(*domain.FlowRunner)) {
+	s.configure = append(s.configure, fn)
+}
+
 // GetRepository returns the underlying repository
 func (s *DebugService) GetRepository() domain.Repository {
 	return s.repo
@@ -68,6 +79,51 @@ func (s *DebugService) GetDebugEvents(sessionID string, since *time.Time) ([]dom
 	return s.sessionManager.GetEvents(sessionID, since)
 }
 
+// SetBreakpoints sets the node IDs a debug session pauses on
+func (s *DebugService) SetBreakpoints(sessionID string, nodeIDs []string) error {
+	return s.sessionManager.SetBreakpoints(sessionID, nodeIDs)
+}
+
+// Step continues, single-steps or aborts an execution paused at a breakpoint
+func (s *DebugService) Step(sessionID string, cmd domain.StepCommand) error {
+	return s.sessionManager.Step(sessionID, cmd)
+}
+
+// GetPausedState returns the breakpoint a session's execution is paused at, if any
+func (s *DebugService) GetPausedState(sessionID string) (*domain.BreakpointState, error) {
+	return s.sessionManager.GetPausedState(sessionID)
+}
+
+// RunFlow executes the session's flow in the background with breakpoints enabled
+func (s *DebugService) RunFlow(ctx context.Context, sessionID string, input map[string]interface{}) error {
+	session, err := s.sessionManager.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	flow, err := s.repo.GetFlow(ctx, session.FlowID)
+	if err != nil {
+		return fmt.Errorf("flow not found: %w", err)
+	}
+
+	flowRunner := domain.NewFlowRunner(s.repo)
+	for _, fn := range s.configure {
+		fn(flowRunner)
+	}
+	runner := NewDebugFlowRunner(flowRunner, s, s.repo)
+	go func() {
+		if err := runner.ExecuteWithDebug(context.Background(), flow, input, sessionID); err != nil {
+			log.Printf("Debug run of flow %s in session %s failed: %v", flow.ID, sessionID, err)
+		}
+	}()
+	return nil
+}
+
+// StartJanitor expires debug sessions inactive for longer than ttl until ctx is cancelled
+func (s *DebugService) StartJanitor(ctx context.Context, ttl, interval time.Duration) {
+	s.sessionManager.RunJanitor(ctx, ttl, interval)
+}
+
 // GetSessionManager returns the session manager for testing
 func (s *DebugService) GetSessionManager() *domain.DebugSessionManager {
 	return s.sessionManager
@@ -94,6 +150,7 @@ type DebugHook struct {
 	sessionID    string
 	debugService *DebugService
 	startTime    map[string]time.Time
+	mu           sync.Mutex // Parallel branches report nodes concurrently
 }
 
 func NewDebugHook(sessionID string, debugService *DebugService) *DebugHook {
@@ -105,12 +162,21 @@ func NewDebugHook(sessionID string, debugService *DebugService) *DebugHook {
 }
 
 func (h *DebugHook) BeforeNode(ctx context.Context, node *domain.Node, input map[string]interface{}) {
+	h.mu.Lock()
 	h.startTime[node.ID] = time.Now()
+	h.mu.Unlock()
 	h.debugService.sessionManager.LogNodeStart(h.sessionID, node.ID, string(node.Type), input)
 }
 
+// AwaitNode pauses execution when the node has a breakpoint in the session
+func (h *DebugHook) AwaitNode(ctx context.Context, node *domain.Node, input map[string]interface{}) error {
+	return h.debugService.sessionManager.WaitAtBreakpoint(ctx, h.sessionID, node.ID, input)
+}
+
 func (h *DebugHook) AfterNode(ctx context.Context, node *domain.Node, output map[string]interface{}, err error) {
+	h.mu.Lock()
 	duration := time.Since(h.startTime[node.ID])
+	h.mu.Unlock()
 	if err != nil {
 		if err.Error() == "execution_paused" {
 			h.debugService.sessionManager.LogNodePaused(h.sessionID, node.ID, "Paused")
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
 )
@@ -12,6 +13,8 @@ type MockFlowRepository struct {
 	flows      map[string]*domain.Flow
 	executions map[string]*domain.FlowExecution
 	events     map[string]*domain.Event
+	sessions   map[string]*domain.DebugSession
+	debugLog   map[string][]domain.DebugEvent
 }
 
 func NewMockFlowRepository() *MockFlowRepository {
@@ -19,6 +22,8 @@ func NewMockFlowRepository() *MockFlowRepository {
 		flows:      make(map[string]*domain.Flow),
 		executions: make(map[string]*domain.FlowExecution),
 		events:     make(map[string]*domain.Event),
+		sessions:   make(map[string]*domain.DebugSession),
+		debugLog:   make(map[string][]domain.DebugEvent),
 	}
 }
 
@@ -28,16 +33,23 @@ func (m *MockFlowRepository) CreateFlow(ctx context.Context, flow *domain.Flow)
 }
 
 func (m *MockFlowRepository) GetFlow(ctx context.Context, id string) (*domain.Flow, error) {
-	if flow, exists := m.flows[id]; exists {
+	if flow, exists := m.flows[id]; exists && flow.DeletedAt == nil {
 		return flow, nil
 	}
 	return nil, domain.ErrFlowNotFound
 }
 
+func (m *MockFlowRepository) GetFlowZoneID(ctx context.Context, id string) (string, error) {
+	if flow, exists := m.flows[id]; exists {
+		return flow.ZoneID, nil
+	}
+	return "", domain.ErrFlowNotFound
+}
+
 func (m *MockFlowRepository) ListFlows(ctx context.Context, zoneID string) ([]*domain.Flow, error) {
 	var flows []*domain.Flow
 	for _, flow := range m.flows {
-		if flow.ZoneID == zoneID {
+		if flow.ZoneID == zoneID && flow.DeletedAt == nil {
 			flows = append(flows, flow)
 		}
 	}
@@ -49,6 +61,36 @@ func (m *MockFlowRepository) UpdateFlow(ctx context.Context, flow *domain.Flow)
 	return nil
 }
 
+func (m *MockFlowRepository) DeleteFlow(ctx context.Context, id string) error {
+	flow, exists := m.flows[id]
+	if !exists || flow.DeletedAt != nil {
+		return domain.ErrFlowNotFound
+	}
+	now := time.Now()
+	flow.DeletedAt = &now
+	return nil
+}
+
+func (m *MockFlowRepository) RestoreFlow(ctx context.Context, id string) error {
+	flow, exists := m.flows[id]
+	if !exists || flow.DeletedAt == nil {
+		return domain.ErrFlowNotFound
+	}
+	flow.DeletedAt = nil
+	return nil
+}
+
+func (m *MockFlowRepository) PurgeDeletedFlows(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	for id, flow := range m.flows {
+		if flow.DeletedAt != nil && flow.DeletedAt.Before(deletedBefore) {
+			delete(m.flows, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
 func (m *MockFlowRepository) CreateExecution(ctx context.Context, exec *domain.FlowExecution) error {
 	m.executions[exec.ID] = exec
 	return nil
@@ -75,6 +117,35 @@ func (m *MockFlowRepository) ListExecutions(ctx context.Context, flowID string,
 	}
 	return executions, nil
 }
+func (m *MockFlowRepository) ClaimExecution(ctx context.Context, id string, checkpointedAt, claimedAt time.Time) (bool, error) {
+	exec, exists := m.executions[id]
+	if !exists || exec.Status != domain.ExecutionRunning {
+		return false, nil
+	}
+	lastSeen := exec.CheckpointedAt
+	if lastSeen.IsZero() {
+		lastSeen = exec.StartedAt
+	}
+	if !lastSeen.Equal(checkpointedAt) {
+		return false, nil
+	}
+	exec.CheckpointedAt = claimedAt
+	return true, nil
+}
+
+func (m *MockFlowRepository) ListIncompleteExecutions(ctx context.Context, checkpointedBefore time.Time) ([]*domain.FlowExecution, error) {
+	var executions []*domain.FlowExecution
+	for _, exec := range m.executions {
+		lastSeen := exec.CheckpointedAt
+		if lastSeen.IsZero() {
+			lastSeen = exec.StartedAt
+		}
+		if exec.Status == domain.ExecutionRunning && lastSeen.Before(checkpointedBefore) {
+			executions = append(executions, exec)
+		}
+	}
+	return executions, nil
+}
 
 func (m *MockFlowRepository) BulkUpdateFlowsEnabled(ctx context.Context, ids []string, enabled bool) error {
 	for _, id := range ids {
@@ -107,6 +178,10 @@ func (m *MockFlowRepository) GetEventByID(ctx context.Context, id string) (*doma
 	return nil, fmt.Errorf("event not found")
 }
 
+func (m *MockFlowRepository) QueryEvents(ctx context.Context, filter domain.EventFilter) (*domain.EventPage, error) {
+	return &domain.EventPage{}, nil
+}
+
 func (m *MockFlowRepository) CreateFlowVersion(ctx context.Context, version *domain.FlowVersion) error {
 	return nil
 }
@@ -119,6 +194,51 @@ func (m *MockFlowRepository) GetFlowVersion(ctx context.Context, flowID string,
 	return nil, nil
 }
 
+func (m *MockFlowRepository) SaveDebugSession(ctx context.Context, session *domain.DebugSession) error {
+	stored := *session
+	stored.Events = nil
+	m.sessions[session.ID] = &stored
+	return nil
+}
+
+func (m *MockFlowRepository) GetDebugSession(ctx context.Context, id string) (*domain.DebugSession, error) {
+	if session, exists := m.sessions[id]; exists {
+		loaded := *session
+		return &loaded, nil
+	}
+	return nil, fmt.Errorf("debug session not found")
+}
+
+func (m *MockFlowRepository) CreateDebugEvent(ctx context.Context, sessionID string, event *domain.DebugEvent) error {
+	m.debugLog[sessionID] = append(m.debugLog[sessionID], *event)
+	if session, exists := m.sessions[sessionID]; exists {
+		session.LastActivity = event.Timestamp
+	}
+	return nil
+}
+
+func (m *MockFlowRepository) GetDebugEvents(ctx context.Context, sessionID string, since *time.Time) ([]domain.DebugEvent, error) {
+	var events []domain.DebugEvent
+	for _, event := range m.debugLog[sessionID] {
+		if since == nil || !event.Timestamp.Before(*since) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (m *MockFlowRepository) DeleteInactiveDebugSessions(ctx context.Context, inactiveSince time.Time) (int64, error) {
+	var deleted int64
+	for id, session := range m.sessions {
+		if session.LastActivity.Before(inactiveSince) {
+			delete(m.sessions, id)
+			delete(m.debugLog, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
 func TestDebugSessionManager(t *testing.T) {
 	manager := domain.NewDebugSessionManager()
 	ctx := context.Background()
@@ -399,3 +519,210 @@ func TestDebugFlowRunner(t *testing.T) {
 		}
 	})
 }
+
+func TestDebugBreakpoints(t *testing.T) {
+	repo := NewMockFlowRepository()
+	service := NewDebugService(repo)
+	ctx := context.Background()
+
+	testFlow := &domain.Flow{
+		ID:     "flow_breakpoint_test",
+		ZoneID: "zone_456",
+		Nodes: []domain.Node{
+			{ID: "node_trigger", Type: domain.NodeTrigger},
+			{ID: "node_condition", Type: domain.NodeCondition, Data: []byte(`{"field":"amount","operator":"gt","value":100}`)},
+		},
+		Edges: []domain.Edge{
+			{ID: "edge_1", Source: "node_trigger", Target: "node_condition"},
+		},
+	}
+	repo.CreateFlow(ctx, testFlow)
+
+	waitForPause := func(sessionID string) *domain.BreakpointState {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if paused, _ := service.GetPausedState(sessionID); paused != nil {
+				return paused
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatal("Execution did not pause at breakpoint")
+		return nil
+	}
+
+	t.Run("Pause, modify input and continue", func(t *testing.T) {
+		session, _ := service.StartDebugSession(ctx, testFlow.ID, "zone_456", domain.DebugLevelInfo)
+		if err := service.SetBreakpoints(session.ID, []string{"node_condition"}); err != nil {
+			t.Fatalf("Failed to set breakpoints: %v", err)
+		}
+
+		runner := NewDebugFlowRunner(domain.NewFlowRunner(repo), service, repo)
+		done := make(chan error, 1)
+		go func() {
+			done <- runner.ExecuteWithDebug(ctx, testFlow, map[string]interface{}{"amount": 50.0}, session.ID)
+		}()
+
+		paused := waitForPause(session.ID)
+		if paused.NodeID != "node_condition" {
+			t.Errorf("Expected pause at node_condition, got %s", paused.NodeID)
+		}
+
+		if err := service.Step(session.ID, domain.StepCommand{Action: domain.StepContinue, Input: map[string]interface{}{"amount": 500.0}}); err != nil {
+			t.Fatalf("Failed to continue: %v", err)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("Execution failed: %v", err)
+		}
+
+		events, _ := service.GetDebugEvents(session.ID, nil)
+		var result interface{}
+		for _, event := range events {
+			if event.Type == domain.DebugEventConditionEval {
+				result = event.Data["result"]
+			}
+		}
+		if result != true {
+			t.Errorf("Expected modified input to make condition true, got %v", result)
+		}
+	})
+
+	t.Run("Abort at breakpoint", func(t *testing.T) {
+		session, _ := service.StartDebugSession(ctx, testFlow.ID, "zone_456", domain.DebugLevelInfo)
+		service.SetBreakpoints(session.ID, []string{"node_trigger"})
+
+		runner := NewDebugFlowRunner(domain.NewFlowRunner(repo), service, repo)
+		done := make(chan error, 1)
+		go func() { done <- runner.ExecuteWithDebug(ctx, testFlow, map[string]interface{}{}, session.ID) }()
+
+		waitForPause(session.ID)
+		if err := service.Step(session.ID, domain.StepCommand{Action: domain.StepAbort}); err != nil {
+			t.Fatalf("Failed to abort: %v", err)
+		}
+		if err := <-done; err != domain.ErrDebugAborted {
+			t.Errorf("Expected ErrDebugAborted, got %v", err)
+		}
+	})
+
+	t.Run("Step when not paused", func(t *testing.T) {
+		session, _ := service.StartDebugSession(ctx, testFlow.ID, "zone_456", domain.DebugLevelInfo)
+		if err := service.Step(session.ID, domain.StepCommand{Action: domain.StepContinue}); err != domain.ErrNotPaused {
+			t.Errorf("Expected ErrNotPaused, got %v", err)
+		}
+	})
+}
+
+func TestDebugSessionPersistence(t *testing.T) {
+	repo := NewMockFlowRepository()
+	ctx := context.Background()
+
+	manager := domain.NewDebugSessionManager()
+	manager.SetStore(repo)
+
+	session, err := manager.CreateSession(ctx, "flow_persist", "zone_456", domain.DebugLevelInfo)
+	if err != nil {
+		t.Fatalf("Failed to create debug session: %v", err)
+	}
+	manager.LogNodeStart(session.ID, "node_1", "condition", map[string]interface{}{"amount": 10})
+
+	t.Run("Session survives restart", func(t *testing.T) {
+		restarted := domain.NewDebugSessionManager()
+		restarted.SetStore(repo)
+
+		loaded, err := restarted.GetSession(session.ID)
+		if err != nil {
+			t.Fatalf("Expected session to be loaded from store: %v", err)
+		}
+		if loaded.FlowID != "flow_persist" {
+			t.Errorf("Expected flow ID flow_persist, got %s", loaded.FlowID)
+		}
+
+		events, err := restarted.GetEvents(session.ID, nil)
+		if err != nil {
+			t.Fatalf("Failed to get events: %v", err)
+		}
+		if len(events) != 2 {
+			t.Errorf("Expected 2 persisted events, got %d", len(events))
+		}
+	})
+
+	t.Run("Inactive sessions expire", func(t *testing.T) {
+		time.Sleep(10 * time.Millisecond)
+		if expired := manager.ExpireInactiveSessions(ctx, 5*time.Millisecond); expired != 1 {
+			t.Errorf("Expected 1 expired session, got %d", expired)
+		}
+		if _, err := manager.GetSession(session.ID); err == nil {
+			t.Error("Expected expired session to be gone from memory and store")
+		}
+	})
+}
+
+// blockingDebugStore holds event writes until release is closed
+type blockingDebugStore struct {
+	*MockFlowRepository
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingDebugStore) CreateDebugEvent(ctx context.Context, sessionID string, event *domain.DebugEvent) error {
+	s.entered <- struct{}{}
+	<-s.release
+	return s.MockFlowRepository.CreateDebugEvent(ctx, sessionID, event)
+}
+
+func TestDebugSessionStoreWrites(t *testing.T) {
+	repo := NewMockFlowRepository()
+	ctx := context.Background()
+
+	manager := domain.NewDebugSessionManager()
+	manager.SetStore(repo)
+	slow, _ := manager.CreateSession(ctx, "flow_slow", "zone_1", domain.DebugLevelInfo)
+	other, _ := manager.CreateSession(ctx, "flow_other", "zone_1", domain.DebugLevelInfo)
+
+	t.Run("Events have distinct IDs", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			manager.LogNodeStart(other.ID, fmt.Sprintf("node_%d", i), "condition", nil)
+		}
+		events, _ := repo.GetDebugEvents(ctx, other.ID, nil)
+		seen := make(map[string]bool)
+		for _, event := range events {
+			if seen[event.ID] {
+				t.Fatalf("Expected distinct event IDs, got %s twice", event.ID)
+			}
+			seen[event.ID] = true
+		}
+		if len(seen) != 101 {
+			t.Errorf("Expected 101 persisted events, got %d", len(seen))
+		}
+	})
+
+	t.Run("Slow writes do not block other sessions", func(t *testing.T) {
+		store := &blockingDebugStore{MockFlowRepository: repo, entered: make(chan struct{}), release: make(chan struct{})}
+		manager.SetStore(store)
+
+		ended := make(chan struct{})
+		go func() {
+			manager.EndSession(slow.ID)
+			close(ended)
+		}()
+		<-store.entered
+
+		done := make(chan struct{})
+		go func() {
+			manager.SetBreakpoints(other.ID, []string{"node_2"})
+			manager.GetPausedState(slow.ID)
+			manager.SessionCounts()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Expected other sessions to proceed while an event is written")
+		}
+
+		close(store.release)
+		<-ended
+		if saved, _ := repo.GetDebugSession(ctx, other.ID); len(saved.Breakpoints) != 1 || saved.Breakpoints[0] != "node_2" {
+			t.Errorf("Expected the breakpoints persisted, got %v", saved.Breakpoints)
+		}
+	})
+}
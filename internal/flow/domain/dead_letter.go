@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// RetryPolicy retries a flow's failed executions with exponential backoff
+// before they are left dead in the dead-letter queue
+type RetryPolicy struct {
+	MaxAttempts           int     `json:"max_attempts"`                      // Executions in total, including the first
+	InitialBackoffSeconds int     `json:"initial_backoff_seconds,omitempty"` // Defaults to 30
+	MaxBackoffSeconds     int     `json:"max_backoff_seconds,omitempty"`     // Defaults to 3600
+	Multiplier            float64 `json:"multiplier,omitempty"`              // Defaults to 2
+}
+
+// Validate checks the policy's settings
+func (p *RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 || p.InitialBackoffSeconds < 0 || p.MaxBackoffSeconds < 0 {
+		return fmt.Errorf("retry policy values must not be negative")
+	}
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return fmt.Errorf("retry policy multiplier must be at least 1")
+	}
+	return nil
+}
+
+// Backoff returns how long to wait before the retry following the given
+// number of failed attempts
+func (p *RetryPolicy) Backoff(attempts int) time.Duration {
+	initial, maxSeconds, multiplier := 30.0, 3600.0, 2.0
+	if p.InitialBackoffSeconds > 0 {
+		initial = float64(p.InitialBackoffSeconds)
+	}
+	if p.MaxBackoffSeconds > 0 {
+		maxSeconds = float64(p.MaxBackoffSeconds)
+	}
+	if p.Multiplier > 0 {
+		multiplier = p.Multiplier
+	}
+	seconds := math.Min(initial*math.Pow(multiplier, float64(attempts-1)), maxSeconds)
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// DeadLetterStatus is the state of a dead-lettered execution
+type DeadLetterStatus string
+
+const (
+	DeadLetterScheduled DeadLetterStatus = "scheduled" // Waiting for an automatic retry at NextRetryAt
+	DeadLetterRetrying  DeadLetterStatus = "retrying"  // A retry is running
+	DeadLetterDead      DeadLetterStatus = "dead"      // Out of automatic retries; can be retried by hand
+	DeadLetterResolved  DeadLetterStatus = "resolved"  // A retry succeeded
+)
+
+// DeadLetter records a failed execution so it can be retried with its
+// original input
+type DeadLetter struct {
+	ID                string           `json:"id"`
+	FlowID            string           `json:"flow_id"`
+	ZoneID            string           `json:"zone_id"`
+	ExecutionID       string           `json:"execution_id"`                  // Most recent failed execution
+	OriginExecutionID string           `json:"origin_execution_id,omitempty"` // First failed execution; retries derive idempotency keys from it
+	Input             json.RawMessage  `json:"input"`
+	Error             string           `json:"error"`
+	Attempts          int              `json:"attempts"`
+	Status            DeadLetterStatus `json:"status"`
+	NextRetryAt       *time.Time       `json:"next_retry_at,omitempty"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+}
+
+// DeadLetterStore persists dead-lettered executions
+type DeadLetterStore interface {
+	CreateDeadLetter(ctx context.Context, entry *DeadLetter) error
+	GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error)
+	UpdateDeadLetter(ctx context.Context, entry *DeadLetter) error
+	// ListDeadLetters returns a zone's entries, newest first, optionally
+	// only those with the given status
+	ListDeadLetters(ctx context.Context, zoneID string, status DeadLetterStatus, limit, offset int) ([]*DeadLetter, error)
+	// ListDueDeadLetters returns scheduled entries whose retry is due
+	ListDueDeadLetters(ctx context.Context, now time.Time) ([]*DeadLetter, error)
+	// ClaimDeadLetterRetry marks the entry retrying only if it is still
+	// scheduled or dead, so that when several callers retry the same entry
+	// exactly one of them claims it
+	ClaimDeadLetterRetry(ctx context.Context, id string, now time.Time) (bool, error)
+}
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// FailureHandler is told about executions started with Execute that end
+// failed or timed out
+type FailureHandler interface {
+	ExecutionFailed(ctx context.Context, flow *Flow, exec *FlowExecution, err error)
+}
@@ -2,6 +2,9 @@ package domain
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -43,6 +46,36 @@ const (
 	DebugEventConditionEval  DebugEventType = "condition_eval"
 	DebugEventWebhookCall    DebugEventType = "webhook_call"
 	DebugEventApprovalReq    DebugEventType = "approval_required"
+	DebugEventBreakpointHit  DebugEventType = "breakpoint_hit"
+	DebugEventResumed        DebugEventType = "resumed"
+)
+
+// StepAction tells a paused execution how to proceed
+type StepAction string
+
+const (
+	StepContinue StepAction = "continue" // Run until the next breakpoint
+	StepNext     StepAction = "step"     // Run the current node and pause before the next one
+	StepAbort    StepAction = "abort"    // Stop the execution
+)
+
+// StepCommand resumes a paused execution, optionally overriding input fields
+type StepCommand struct {
+	Action StepAction             `json:"action"`
+	Input  map[string]interface{} `json:"input,omitempty"`
+}
+
+// BreakpointState describes where a debugged execution is paused
+type BreakpointState struct {
+	NodeID   string                 `json:"node_id"`
+	Input    map[string]interface{} `json:"input"`
+	PausedAt time.Time              `json:"paused_at"`
+}
+
+var (
+	ErrDebugAborted   = errors.New("execution aborted by debugger")
+	ErrNotPaused      = errors.New("debug session is not paused")
+	ErrInvalidStepCmd = errors.New("invalid step action")
 )
 
 // DebugSession represents an active debug session
@@ -56,12 +89,32 @@ type DebugSession struct {
 	Events    []DebugEvent      `json:"events"`
 	Metadata  map[string]string `json:"metadata"`
 	CreatedAt time.Time         `json:"created_at"`
+
+	LastActivity time.Time        `json:"last_activity"`
+	Breakpoints  []string         `json:"breakpoints"`
+	Paused       *BreakpointState `json:"paused,omitempty"`
+	stepping     bool
+	resumeCh     chan StepCommand
+	version      uint64 // Bumped under the manager's mu by each snapshot to save
+	saved        uint64 // Version last written to the store, under saveMu
+}
+
+// DebugSessionStore persists debug sessions and their events so they
+// survive restarts. Repository satisfies it.
+type DebugSessionStore interface {
+	SaveDebugSession(ctx context.Context, session *DebugSession) error
+	GetDebugSession(ctx context.Context, id string) (*DebugSession, error)
+	CreateDebugEvent(ctx context.Context, sessionID string, event *DebugEvent) error
+	GetDebugEvents(ctx context.Context, sessionID string, since *time.Time) ([]DebugEvent, error)
+	DeleteInactiveDebugSessions(ctx context.Context, inactiveSince time.Time) (int64, error)
 }
 
 // DebugSessionManager manages active debug sessions
 type DebugSessionManager struct {
 	sessions map[string]*DebugSession
+	store    DebugSessionStore // Optional: nil keeps sessions in memory only
 	mu       sync.RWMutex
+	saveMu   sync.Mutex // Orders session writes to the store
 }
 
 // NewDebugSessionManager creates a new debug session manager
@@ -71,9 +124,14 @@ func NewDebugSessionManager() *DebugSessionManager {
 	}
 }
 
+// SetStore enables persistence of sessions and events
+func (m *DebugSessionManager) SetStore(store DebugSessionStore) {
+	m.store = store
+}
+
 // CreateSession creates a new debug session
 func (m *DebugSessionManager) CreateSession(ctx context.Context, flowID, zoneID string, level DebugLevel) (*DebugSession, error) {
-	sessionID := fmt.Sprintf("debug_%d", time.Now().UnixNano())
+	sessionID := newDebugID("debug")
 
 	session := &DebugSession{
 		ID:        sessionID,
@@ -85,12 +143,18 @@ func (m *DebugSessionManager) CreateSession(ctx context.Context, flowID, zoneID
 		Events:    make([]DebugEvent, 0),
 		Metadata:  make(map[string]string),
 		CreatedAt: time.Now(),
+
+		LastActivity: time.Now(),
+		Breakpoints:  make([]string, 0),
 	}
 
 	m.mu.Lock()
 	m.sessions[sessionID] = session
+	snapshot := m.snapshotUnsafe(session)
 	m.mu.Unlock()
 
+	m.persistSession(session, snapshot)
+
 	// Send start event
 	m.logEvent(sessionID, DebugEventExecutionStart, DebugLevelInfo, "Flow execution started", map[string]interface{}{
 		"flow_id": flowID,
@@ -102,34 +166,72 @@ func (m *DebugSessionManager) CreateSession(ctx context.Context, flowID, zoneID
 
 // GetSession retrieves a debug session
 func (m *DebugSessionManager) GetSession(sessionID string) (*DebugSession, error) {
+	session, exists := m.lookupSession(sessionID)
+	if !exists {
+		return nil, fmt.Errorf("debug session not found: %s", sessionID)
+	}
+
+	return session, nil
+}
+
+// lookupSession returns a session from memory, falling back to the store
+// for sessions created before a restart
+func (m *DebugSessionManager) lookupSession(sessionID string) (*DebugSession, bool) {
 	m.mu.RLock()
 	session, exists := m.sessions[sessionID]
 	m.mu.RUnlock()
 
-	if !exists {
-		return nil, fmt.Errorf("debug session not found: %s", sessionID)
+	if exists || m.store == nil {
+		return session, exists
 	}
 
-	return session, nil
+	ctx := context.Background()
+	session, err := m.store.GetDebugSession(ctx, sessionID)
+	if err != nil {
+		return nil, false
+	}
+	events, err := m.store.GetDebugEvents(ctx, sessionID, nil)
+	if err != nil {
+		log.Printf("Failed to load events for debug session %s: %v", sessionID, err)
+	}
+	session.Events = append(make([]DebugEvent, 0, len(events)), events...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cached, ok := m.sessions[sessionID]; ok {
+		return cached, true
+	}
+	m.sessions[sessionID] = session
+	return session, true
 }
 
 // EndSession ends a debug session
 func (m *DebugSessionManager) EndSession(sessionID string) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	session, exists := m.sessions[sessionID]
 	if !exists {
+		m.mu.Unlock()
 		return fmt.Errorf("debug session not found: %s", sessionID)
 	}
 
 	session.Active = false
 
+	// Release an execution waiting at a breakpoint
+	if session.resumeCh != nil {
+		session.resumeCh <- StepCommand{Action: StepAbort}
+		session.resumeCh = nil
+		session.Paused = nil
+	}
+
 	// Send end event
-	m.logEventUnsafe(sessionID, DebugEventExecutionEnd, DebugLevelInfo, "Flow execution ended", map[string]interface{}{
+	event, _ := m.appendEventUnsafe(sessionID, DebugEventExecutionEnd, DebugLevelInfo, "Flow execution ended", map[string]interface{}{
 		"duration": time.Since(session.StartTime).String(),
 	})
+	snapshot := m.snapshotUnsafe(session)
+	m.mu.Unlock()
 
+	m.persistEvent(sessionID, &event)
+	m.persistSession(session, snapshot)
 	return nil
 }
 
@@ -210,9 +312,7 @@ func (m *DebugSessionManager) LogApprovalRequired(sessionID, nodeID, approver st
 
 // GetEvents returns events for a session, optionally filtered by level
 func (m *DebugSessionManager) GetEvents(sessionID string, since *time.Time) ([]DebugEvent, error) {
-	m.mu.RLock()
-	session, exists := m.sessions[sessionID]
-	m.mu.RUnlock()
+	session, exists := m.lookupSession(sessionID)
 
 	if !exists {
 		return nil, fmt.Errorf("debug session not found: %s", sessionID)
@@ -229,6 +329,121 @@ func (m *DebugSessionManager) GetEvents(sessionID string, since *time.Time) ([]D
 	return events, nil
 }
 
+// SetBreakpoints replaces the set of node IDs the session pauses on
+func (m *DebugSessionManager) SetBreakpoints(sessionID string, nodeIDs []string) error {
+	m.mu.Lock()
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		m.mu.Unlock()
+		return fmt.Errorf("debug session not found: %s", sessionID)
+	}
+
+	session.Breakpoints = append(make([]string, 0, len(nodeIDs)), nodeIDs...)
+	snapshot := m.snapshotUnsafe(session)
+	m.mu.Unlock()
+
+	m.persistSession(session, snapshot)
+	return nil
+}
+
+// WaitAtBreakpoint blocks the calling execution if the node has a breakpoint
+// (or the session is stepping) until a StepCommand is received. Input
+// overrides from the command are applied to the input map in place.
+func (m *DebugSessionManager) WaitAtBreakpoint(ctx context.Context, sessionID, nodeID string, input map[string]interface{}) error {
+	m.mu.Lock()
+	session, exists := m.sessions[sessionID]
+	if !exists || !session.Active || !(session.stepping || containsString(session.Breakpoints, nodeID)) {
+		m.mu.Unlock()
+		return nil
+	}
+
+	resumeCh := make(chan StepCommand, 1)
+	session.resumeCh = resumeCh
+	session.Paused = &BreakpointState{
+		NodeID:   nodeID,
+		Input:    input,
+		PausedAt: time.Now(),
+	}
+	hit, _ := m.appendEventUnsafe(sessionID, DebugEventBreakpointHit, DebugLevelInfo, fmt.Sprintf("Breakpoint hit at node %s", nodeID), map[string]interface{}{
+		"node_id": nodeID,
+		"input":   input,
+	})
+	m.mu.Unlock()
+	m.persistEvent(sessionID, &hit)
+
+	var cmd StepCommand
+	select {
+	case cmd = <-resumeCh:
+	case <-ctx.Done():
+		cmd = StepCommand{Action: StepAbort}
+	}
+
+	m.mu.Lock()
+	if session.resumeCh == resumeCh {
+		session.resumeCh = nil
+		session.Paused = nil
+	}
+
+	if cmd.Action == StepAbort {
+		session.stepping = false
+		m.mu.Unlock()
+		return ErrDebugAborted
+	}
+
+	for k, v := range cmd.Input {
+		input[k] = v
+	}
+	session.stepping = cmd.Action == StepNext
+
+	resumed, ok := m.appendEventUnsafe(sessionID, DebugEventResumed, DebugLevelInfo, fmt.Sprintf("Execution resumed at node %s (%s)", nodeID, cmd.Action), map[string]interface{}{
+		"node_id": nodeID,
+		"action":  cmd.Action,
+	})
+	m.mu.Unlock()
+
+	if ok {
+		m.persistEvent(sessionID, &resumed)
+	}
+	return nil
+}
+
+// GetPausedState returns where the session's execution is paused, or nil if it is running
+func (m *DebugSessionManager) GetPausedState(sessionID string) (*BreakpointState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("debug session not found: %s", sessionID)
+	}
+	return session.Paused, nil
+}
+
+// Step resumes an execution paused at a breakpoint
+func (m *DebugSessionManager) Step(sessionID string, cmd StepCommand) error {
+	switch cmd.Action {
+	case StepContinue, StepNext, StepAbort:
+	default:
+		return ErrInvalidStepCmd
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("debug session not found: %s", sessionID)
+	}
+	if session.resumeCh == nil {
+		return ErrNotPaused
+	}
+
+	session.resumeCh <- cmd
+	session.resumeCh = nil
+	session.Paused = nil
+	return nil
+}
+
 // GetActiveSessions returns all active debug sessions for a flow
 func (m *DebugSessionManager) GetActiveSessions(flowID string) []*DebugSession {
 	m.mu.RLock()
@@ -244,6 +459,24 @@ func (m *DebugSessionManager) GetActiveSessions(flowID string) []*DebugSession {
 	return sessions
 }
 
+// SessionCounts returns the number of active debug sessions and how many
+// of them are paused at a breakpoint
+func (m *DebugSessionManager) SessionCounts() (active, paused int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, session := range m.sessions {
+		if !session.Active {
+			continue
+		}
+		active++
+		if session.Paused != nil {
+			paused++
+		}
+	}
+	return active, paused
+}
+
 // CleanupOldSessions removes sessions older than the specified duration
 func (m *DebugSessionManager) CleanupOldSessions(maxAge time.Duration) {
 	m.mu.Lock()
@@ -258,46 +491,135 @@ func (m *DebugSessionManager) CleanupOldSessions(maxAge time.Duration) {
 	}
 }
 
-// Private helper methods
+// ExpireInactiveSessions drops sessions with no activity within the TTL,
+// both from memory and from the store. It returns the number of in-memory
+// sessions that were expired.
+func (m *DebugSessionManager) ExpireInactiveSessions(ctx context.Context, ttl time.Duration) int {
+	cutoff := time.Now().Add(-ttl)
 
-func (m *DebugSessionManager) logEvent(sessionID string, eventType DebugEventType, level DebugLevel, message string, data map[string]interface{}) {
-	eventID := fmt.Sprintf("event_%d", time.Now().UnixNano())
+	m.mu.Lock()
+	expired := 0
+	for id, session := range m.sessions {
+		if session.LastActivity.Before(cutoff) {
+			if session.resumeCh != nil {
+				session.resumeCh <- StepCommand{Action: StepAbort}
+				session.resumeCh = nil
+			}
+			delete(m.sessions, id)
+			expired++
+		}
+	}
+	m.mu.Unlock()
 
-	event := DebugEvent{
-		ID:          eventID,
-		ExecutionID: sessionID,
-		Type:        eventType,
-		Level:       level,
-		Message:     message,
-		Data:        data,
-		Timestamp:   time.Now(),
+	if m.store != nil {
+		if _, err := m.store.DeleteInactiveDebugSessions(ctx, cutoff); err != nil {
+			log.Printf("Failed to delete expired debug sessions: %v", err)
+		}
 	}
 
-	// Extract NodeID from data for node-related events
-	if data != nil {
-		if nodeID, ok := data["node_id"]; ok {
-			if nodeIDStr, ok := nodeID.(string); ok {
-				event.NodeID = nodeIDStr
+	return expired
+}
+
+// RunJanitor periodically expires inactive sessions until the context is cancelled
+func (m *DebugSessionManager) RunJanitor(ctx context.Context, ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := m.ExpireInactiveSessions(ctx, ttl); n > 0 {
+				log.Printf("Expired %d inactive debug sessions", n)
 			}
 		}
 	}
+}
 
+// Private helper methods
+
+// newDebugID returns a random ID with the given prefix, so that IDs do not
+// collide between events logged at once or between instances
+func newDebugID(prefix string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return prefix + "_" + hex.EncodeToString(b)
+}
+
+// snapshotUnsafe copies the persisted fields of a session so that they can
+// be saved after m.mu is released. The caller must hold m.mu.
+func (m *DebugSessionManager) snapshotUnsafe(session *DebugSession) *DebugSession {
+	session.version++
+	snapshot := &DebugSession{
+		ID:           session.ID,
+		FlowID:       session.FlowID,
+		ZoneID:       session.ZoneID,
+		Level:        session.Level,
+		Active:       session.Active,
+		StartTime:    session.StartTime,
+		Metadata:     make(map[string]string, len(session.Metadata)),
+		CreatedAt:    session.CreatedAt,
+		LastActivity: session.LastActivity,
+		Breakpoints:  append(make([]string, 0, len(session.Breakpoints)), session.Breakpoints...),
+		version:      session.version,
+	}
+	for k, v := range session.Metadata {
+		snapshot.Metadata[k] = v
+	}
+	return snapshot
+}
+
+// persistSession saves a snapshot of the session unless a newer one was
+// saved while it waited
+func (m *DebugSessionManager) persistSession(session, snapshot *DebugSession) {
+	if m.store == nil {
+		return
+	}
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	if snapshot.version <= session.saved {
+		return
+	}
+	if err := m.store.SaveDebugSession(context.Background(), snapshot); err != nil {
+		log.Printf("Failed to persist debug session %s: %v", session.ID, err)
+		return
+	}
+	session.saved = snapshot.version
+}
+
+func (m *DebugSessionManager) persistEvent(sessionID string, event *DebugEvent) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.CreateDebugEvent(context.Background(), sessionID, event); err != nil {
+		log.Printf("Failed to persist debug event for session %s: %v", sessionID, err)
+	}
+}
+
+func (m *DebugSessionManager) logEvent(sessionID string, eventType DebugEventType, level DebugLevel, message string, data map[string]interface{}) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	event, exists := m.appendEventUnsafe(sessionID, eventType, level, message, data)
+	m.mu.Unlock()
 
-	if session, exists := m.sessions[sessionID]; exists {
-		session.Events = append(session.Events, event)
-	} else {
-		// Session doesn't exist, create a temporary one for logging
+	if !exists {
 		log.Printf("Warning: Debug session %s not found for event: %s", sessionID, message)
+		return
 	}
+	m.persistEvent(sessionID, &event)
 }
 
-func (m *DebugSessionManager) logEventUnsafe(sessionID string, eventType DebugEventType, level DebugLevel, message string, data map[string]interface{}) {
-	eventID := fmt.Sprintf("event_%d", time.Now().UnixNano())
+// appendEventUnsafe adds an event to a session in memory and returns it so
+// that it can be persisted after m.mu is released. The caller must hold m.mu.
+func (m *DebugSessionManager) appendEventUnsafe(sessionID string, eventType DebugEventType, level DebugLevel, message string, data map[string]interface{}) (DebugEvent, bool) {
+	session, exists := m.sessions[sessionID]
+	if !exists {
+		return DebugEvent{}, false
+	}
 
 	event := DebugEvent{
-		ID:          eventID,
+		ID:          newDebugID("event"),
 		ExecutionID: sessionID,
 		Type:        eventType,
 		Level:       level,
@@ -306,9 +628,23 @@ func (m *DebugSessionManager) logEventUnsafe(sessionID string, eventType DebugEv
 		Timestamp:   time.Now(),
 	}
 
-	if session, exists := m.sessions[sessionID]; exists {
-		session.Events = append(session.Events, event)
+	// Extract NodeID from data for node-related events
+	if nodeID, ok := data["node_id"].(string); ok {
+		event.NodeID = nodeID
+	}
+
+	session.Events = append(session.Events, event)
+	session.LastActivity = event.Timestamp
+	return event, true
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
 	}
+	return false
 }
 
 // ShouldLog determines if an event should be logged based on session level
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventFilter selects past events for replay. Zero values are ignored.
+type EventFilter struct {
+	ZoneID string
+	Types  []string
+	From   time.Time
+	To     time.Time
+	// DataFilters matches payload fields by dot path (e.g. "customer.country") against string values
+	DataFilters map[string]string
+	Cursor      string
+	Limit       int
+}
+
+// EventPage is a page of events ordered newest first
+type EventPage struct {
+	Events     []*Event `json:"events"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeEventCursor builds an opaque cursor pointing just after the given event
+func EncodeEventCursor(e *Event) string {
+	raw := fmt.Sprintf("%d|%s", e.CreatedAt.UnixNano(), e.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeEventCursor returns the created_at and ID encoded in a cursor
+func DecodeEventCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	var nanos int64
+	if _, err := fmt.Sscanf(parts[0], "%d", &nanos); err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	return time.Unix(0, nanos), parts[1], nil
+}
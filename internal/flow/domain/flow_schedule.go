@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// FlowSchedule runs a flow on a cron expression or a fixed interval. A flow
+// has at most one schedule.
+type FlowSchedule struct {
+	FlowID          string     `json:"flow_id"`
+	ZoneID          string     `json:"zone_id"`
+	CronExpr        string     `json:"cron_expression,omitempty"`
+	IntervalSeconds int        `json:"interval_seconds,omitempty"` // Used when CronExpr is empty
+	Timezone        string     `json:"timezone,omitempty"`         // IANA name; defaults to UTC
+	Enabled         bool       `json:"enabled"`
+	NextRunAt       time.Time  `json:"next_run_at"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// FlowScheduleStore persists flow schedules so they survive restarts
+type FlowScheduleStore interface {
+	SaveFlowSchedule(ctx context.Context, schedule *FlowSchedule) error
+	GetFlowSchedule(ctx context.Context, flowID string) (*FlowSchedule, error)
+	ListFlowSchedules(ctx context.Context, zoneID string) ([]*FlowSchedule, error)
+	ListEnabledFlowSchedules(ctx context.Context) ([]*FlowSchedule, error)
+	DeleteFlowSchedule(ctx context.Context, flowID string) error
+	// ClaimFlowScheduleRun records a run only if the schedule's next run is
+	// still dueAt, so that when several instances fire the same schedule
+	// exactly one of them claims it
+	ClaimFlowScheduleRun(ctx context.Context, flowID string, dueAt, ranAt, nextRunAt time.Time) (bool, error)
+}
+
+var ErrFlowScheduleNotFound = errors.New("flow schedule not found")
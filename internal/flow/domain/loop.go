@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// LoopBodyHandle is the source handle of the edge from a loop node to the
+// node it runs for each item
+const LoopBodyHandle = "body"
+
+// DefaultMaxLoopIterations caps loops that do not set maxIterations
+const DefaultMaxLoopIterations = 1000
+
+// LoopConfig is the data of a loop node
+type LoopConfig struct {
+	ArrayPath     string `json:"arrayPath"`          // Dot path to the array in the input
+	ItemKey       string `json:"itemKey,omitempty"`  // Defaults to "item"
+	IndexKey      string `json:"indexKey,omitempty"` // Defaults to "index"
+	BodyNode      string `json:"bodyNode,omitempty"` // Defaults to the target of the "body" edge
+	MaxIterations int    `json:"maxIterations"`      // Defaults to DefaultMaxLoopIterations
+	BreakOnError  bool   `json:"breakOnError"`       // Fail the loop on the first failed item
+}
+
+// runLoop runs the loop's body node once per item of the input array. Each
+// run receives the loop input with the item and its index added. The loop
+// output collects the body outputs in item order; failed items are null
+// and their errors are reported under "errors" keyed by index, unless
+// BreakOnError stops the loop at the first failure.
+func (r *FlowRunner) runLoop(ctx context.Context, flow *Flow, node *Node, input map[string]interface{}, exec *FlowExecution) (map[string]interface{}, error) {
+	var config LoopConfig
+	if len(node.Data) > 0 {
+		if err := json.Unmarshal(node.Data, &config); err != nil {
+			return nil, fmt.Errorf("invalid loop config: %w", err)
+		}
+	}
+	if config.ItemKey == "" {
+		config.ItemKey = "item"
+	}
+	if config.IndexKey == "" {
+		config.IndexKey = "index"
+	}
+	if config.MaxIterations <= 0 {
+		config.MaxIterations = DefaultMaxLoopIterations
+	}
+
+	body := loopBody(flow, node, config)
+	if body == nil {
+		return nil, fmt.Errorf("loop %s has no body node", node.ID)
+	}
+	if body.Type == NodeApproval || body.Type == NodeTrigger {
+		return nil, fmt.Errorf("loop %s cannot run %s node %s as its body", node.ID, body.Type, body.ID)
+	}
+
+	value, ok := lookupPath(input, config.ArrayPath)
+	if !ok {
+		return nil, fmt.Errorf("array not found at path %s", config.ArrayPath)
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value at path %s is not an array", config.ArrayPath)
+	}
+	if len(items) > config.MaxIterations {
+		return nil, fmt.Errorf("loop %s has %d items, more than the maximum of %d iterations", node.ID, len(items), config.MaxIterations)
+	}
+
+	results := make([]interface{}, len(items))
+	errs := make(map[string]interface{})
+	for i, item := range items {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		itemInput := make(map[string]interface{}, len(input)+2)
+		for k, v := range input {
+			itemInput[k] = v
+		}
+		itemInput[config.ItemKey] = item
+		itemInput[config.IndexKey] = i
+
+		output, err := r.runStep(ctx, flow, body, itemInput, exec)
+		if err != nil {
+			if config.BreakOnError || ctx.Err() != nil {
+				return nil, fmt.Errorf("loop %s item %d: %w", node.ID, i, err)
+			}
+			errs[fmt.Sprint(i)] = err.Error()
+			continue
+		}
+		results[i] = output
+	}
+
+	output := map[string]interface{}{
+		"items": results,
+		"count": len(items),
+	}
+	if len(errs) > 0 {
+		output["errors"] = errs
+	}
+	return output, nil
+}
+
+// loopBody returns the node a loop runs for each item
+func loopBody(flow *Flow, node *Node, config LoopConfig) *Node {
+	if config.BodyNode != "" {
+		return findNode(flow, config.BodyNode)
+	}
+	for _, edge := range flow.Edges {
+		if edge.Source == node.ID && edge.SourceHandle == LoopBodyHandle {
+			return findNode(flow, edge.Target)
+		}
+	}
+	return nil
+}
+
+// lookupPath resolves a dot separated path such as "order.items" in data.
+// An empty path refers to data itself.
+func lookupPath(data map[string]interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return data, true
+	}
+	var current interface{} = data
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
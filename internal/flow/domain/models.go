@@ -20,21 +20,27 @@ const (
 	NodeLoop          NodeType = "loop"
 	NodeSubflow       NodeType = "subflow"
 	NodeInternalEvent NodeType = "internalEvent"
+	NodeJoin          NodeType = "join"
+	NodeSwitch        NodeType = "switch"
 )
 
 type Flow struct {
-	ID          string    `json:"id"`
-	OrgID       string    `json:"org_id"`
-	ZoneID      string    `json:"zone_id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Enabled     bool      `json:"enabled"`
-	Version     int       `json:"version"` // Current version
-	Trigger     Trigger   `json:"trigger"`
-	Nodes       []Node    `json:"nodes"`
-	Edges       []Edge    `json:"edges"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID              string       `json:"id"`
+	OrgID           string       `json:"org_id"`
+	ZoneID          string       `json:"zone_id"`
+	Name            string       `json:"name"`
+	Description     string       `json:"description"`
+	Enabled         bool         `json:"enabled"`
+	Version         int          `json:"version"` // Current version
+	Trigger         Trigger      `json:"trigger"`
+	Nodes           []Node       `json:"nodes"`
+	Edges           []Edge       `json:"edges"`
+	MaxDuration     int          `json:"max_duration_seconds,omitempty"` // Execution time limit in seconds; 0 means unbounded
+	OnTimeoutNodeID string       `json:"on_timeout_node_id,omitempty"`   // Node run after an execution times out
+	RetryPolicy     *RetryPolicy `json:"retry_policy,omitempty"`         // Automatic retries of failed executions
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+	DeletedAt       *time.Time   `json:"deleted_at,omitempty"` // Set when soft-deleted
 }
 
 type Trigger struct {
@@ -55,8 +61,19 @@ type Edge struct {
 	Source       string `json:"source"`
 	Target       string `json:"target"`
 	SourceHandle string `json:"source_handle,omitempty"`
+	// ErrorPolicy decides what a failure in the branch started by this edge
+	// does to the rest of a fan-out. Defaults to BranchFailFast.
+	ErrorPolicy BranchErrorPolicy `json:"error_policy,omitempty"`
 }
 
+// BranchErrorPolicy controls how a failed parallel branch is handled
+type BranchErrorPolicy string
+
+const (
+	BranchFailFast BranchErrorPolicy = "fail"     // Fail the execution
+	BranchContinue BranchErrorPolicy = "continue" // Record the error and let the join proceed
+)
+
 type ExecutionStatus string
 
 const (
@@ -65,21 +82,25 @@ const (
 	ExecutionPaused    ExecutionStatus = "paused"
 	ExecutionCompleted ExecutionStatus = "completed"
 	ExecutionFailed    ExecutionStatus = "failed"
+	ExecutionTimedOut  ExecutionStatus = "timed_out"
 )
 
 type FlowExecution struct {
-	ID            string          `json:"id"`
-	FlowID        string          `json:"flow_id"`
-	FlowVersion   int             `json:"flow_version"`
-	TriggerID     string          `json:"trigger_id"` // Reference to the event that started it
-	Status        ExecutionStatus `json:"status"`
-	CurrentNodeID string          `json:"current_node_id,omitempty"` // For resuming
-	Input         json.RawMessage `json:"input"`
-	Output        json.RawMessage `json:"output"`
-	Steps         []ExecutionStep `json:"steps"`
-	Metadata      json.RawMessage `json:"metadata,omitempty"` // Execution context
-	StartedAt     time.Time       `json:"started_at"`
-	EndedAt       time.Time       `json:"ended_at,omitempty"`
+	ID             string          `json:"id"`
+	FlowID         string          `json:"flow_id"`
+	FlowVersion    int             `json:"flow_version"`
+	TriggerID      string          `json:"trigger_id"` // Reference to the event that started it
+	Status         ExecutionStatus `json:"status"`
+	CurrentNodeID  string          `json:"current_node_id,omitempty"` // For resuming
+	Input          json.RawMessage `json:"input"`
+	Output         json.RawMessage `json:"output"`
+	Steps          []ExecutionStep `json:"steps"`
+	Metadata       json.RawMessage `json:"metadata,omitempty"` // Execution context
+	StartedAt      time.Time       `json:"started_at"`
+	EndedAt        time.Time       `json:"ended_at,omitempty"`
+	CheckpointedAt time.Time       `json:"checkpointed_at,omitempty"` // Last time progress was persisted
+
+	state *executionState // Set by the runner while it runs the execution
 }
 
 type ExecutionStep struct {
@@ -102,21 +123,40 @@ type Event struct {
 	CreatedAt      time.Time         `json:"created_at"`
 }
 
+// MetaReplayOf is the meta key holding, on a replayed event, the ID of the
+// event it replays
+const MetaReplayOf = "replay_of"
+
 type Repository interface {
 	CreateFlow(ctx context.Context, flow *Flow) error
 	GetFlow(ctx context.Context, id string) (*Flow, error)
+	// GetFlowZoneID returns the zone of a flow, including soft-deleted ones
+	GetFlowZoneID(ctx context.Context, id string) (string, error)
 	ListFlows(ctx context.Context, zoneID string) ([]*Flow, error)
 	UpdateFlow(ctx context.Context, flow *Flow) error
 
+	// Soft-delete lifecycle
+	DeleteFlow(ctx context.Context, id string) error
+	RestoreFlow(ctx context.Context, id string) error
+	PurgeDeletedFlows(ctx context.Context, deletedBefore time.Time) (int64, error)
+
 	CreateExecution(ctx context.Context, exec *FlowExecution) error
 	UpdateExecution(ctx context.Context, exec *FlowExecution) error
 	GetExecution(ctx context.Context, id string) (*FlowExecution, error)
 	ListExecutions(ctx context.Context, flowID string, limit, offset int) ([]*FlowExecution, error)
+	// ListIncompleteExecutions returns running executions last checkpointed before the given time
+	ListIncompleteExecutions(ctx context.Context, checkpointedBefore time.Time) ([]*FlowExecution, error)
+	// ClaimExecution moves a running execution's checkpoint from
+	// checkpointedAt to claimedAt only if it has not moved since, so that
+	// when several instances recover the same execution exactly one of them
+	// claims it
+	ClaimExecution(ctx context.Context, id string, checkpointedAt, claimedAt time.Time) (bool, error)
 
 	// Event methods for replay
 	CreateEvent(ctx context.Context, event *Event) error
 	GetPastEvents(ctx context.Context, zoneID string, limit, offset int) ([]*Event, error)
 	GetEventByID(ctx context.Context, id string) (*Event, error)
+	QueryEvents(ctx context.Context, filter EventFilter) (*EventPage, error)
 
 	// Flow Versioning
 	CreateFlowVersion(ctx context.Context, version *FlowVersion) error
@@ -124,6 +164,13 @@ type Repository interface {
 	GetFlowVersion(ctx context.Context, flowID string, version int) (*FlowVersion, error)
 
 	BulkUpdateFlowsEnabled(ctx context.Context, ids []string, enabled bool) error
+
+	// Debug session persistence
+	SaveDebugSession(ctx context.Context, session *DebugSession) error
+	GetDebugSession(ctx context.Context, id string) (*DebugSession, error)
+	CreateDebugEvent(ctx context.Context, sessionID string, event *DebugEvent) error
+	GetDebugEvents(ctx context.Context, sessionID string, since *time.Time) ([]DebugEvent, error)
+	DeleteInactiveDebugSessions(ctx context.Context, inactiveSince time.Time) (int64, error)
 }
 
 type FlowVersion struct {
@@ -139,4 +186,5 @@ type FlowVersion struct {
 var (
 	ErrFlowNotFound      = errors.New("flow not found")
 	ErrExecutionNotFound = errors.New("execution not found")
+	ErrVersionNotFound   = errors.New("flow version not found")
 )
@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// JoinHandler passes through the merged output of the branches that reached
+// the join. Merging happens in the runner once every branch has finished.
+type JoinHandler struct{}
+
+func (h *JoinHandler) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	return input, nil
+}
+
+type branchKey struct{}
+
+// branch records which join nodes a parallel branch reached and with what
+// output, so the fan-out can run each join once with all branches merged.
+type branch struct {
+	mu       sync.Mutex
+	arrivals map[string]map[string]interface{}
+}
+
+func (b *branch) arrive(joinID string, output map[string]interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.arrivals[joinID] = output
+}
+
+type branchResult struct {
+	edge     Edge
+	arrivals map[string]map[string]interface{}
+	err      error
+}
+
+// runNext follows the given edges. A single edge continues sequentially;
+// several edges fan out into concurrent branches.
+func (r *FlowRunner) runNext(ctx context.Context, flow *Flow, edges []Edge, input map[string]interface{}, exec *FlowExecution) error {
+	switch len(edges) {
+	case 0:
+		return nil
+	case 1:
+		return r.enter(ctx, flow, edges[0], input, exec)
+	default:
+		return r.fanOut(ctx, flow, edges, input, exec)
+	}
+}
+
+// enter runs the edge's target node, unless it is a join reached from inside
+// a parallel branch, in which case the arrival is recorded for the fan-out.
+func (r *FlowRunner) enter(ctx context.Context, flow *Flow, edge Edge, input map[string]interface{}, exec *FlowExecution) error {
+	node := findNode(flow, edge.Target)
+	if node == nil {
+		return nil
+	}
+	if b, ok := ctx.Value(branchKey{}).(*branch); ok && node.Type == NodeJoin {
+		b.arrive(node.ID, input)
+		return nil
+	}
+	return r.executeNode(ctx, flow, node, input, exec)
+}
+
+// copyInput copies an input down to its nested maps and slices, so that
+// branches running at once never share a value one of them may modify
+func copyInput(input map[string]interface{}) map[string]interface{} {
+	if input == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(input))
+	for key, value := range input {
+		copied[key] = copyValue(value)
+	}
+	return copied
+}
+
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return copyInput(v)
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+// fanOut runs every edge as its own branch, waits for all of them and then
+// executes each join they reached with their outputs merged. A branch that
+// fails stops the execution unless its edge uses BranchContinue, in which
+// case the error is reported to the join under "branch_errors". Each branch
+// gets its own copy of the input.
+func (r *FlowRunner) fanOut(ctx context.Context, flow *Flow, edges []Edge, input map[string]interface{}, exec *FlowExecution) error {
+	branchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]branchResult, len(edges))
+	var wg sync.WaitGroup
+	for i, edge := range edges {
+		wg.Add(1)
+		go func(i int, edge Edge) {
+			defer wg.Done()
+			b := &branch{arrivals: make(map[string]map[string]interface{})}
+			err := r.enter(context.WithValue(branchCtx, branchKey{}, b), flow, edge, copyInput(input), exec)
+			if err != nil && err != ErrExecutionPaused && edge.ErrorPolicy != BranchContinue {
+				cancel()
+			}
+			results[i] = branchResult{edge: edge, arrivals: b.arrivals, err: err}
+		}(i, edge)
+	}
+	wg.Wait()
+
+	var paused bool
+	branchErrors := make(map[string]interface{})
+	for _, res := range results {
+		switch {
+		case res.err == nil:
+		case res.err == ErrExecutionPaused:
+			paused = true
+		case res.edge.ErrorPolicy == BranchContinue:
+			branchErrors[res.edge.Target] = res.err.Error()
+		default:
+			return res.err
+		}
+	}
+	// Joins are not run while a branch is paused; Resume continues that
+	// branch on its own.
+	if paused {
+		return ErrExecutionPaused
+	}
+
+	var joinIDs []string
+	seen := make(map[string]bool)
+	for _, res := range results {
+		var reached []string
+		for joinID := range res.arrivals {
+			if !seen[joinID] {
+				seen[joinID] = true
+				reached = append(reached, joinID)
+			}
+		}
+		sort.Strings(reached)
+		joinIDs = append(joinIDs, reached...)
+	}
+
+	for _, joinID := range joinIDs {
+		merged := make(map[string]interface{})
+		branches := make(map[string]interface{})
+		for _, res := range results {
+			output, ok := res.arrivals[joinID]
+			if !ok {
+				continue
+			}
+			for k, v := range output {
+				merged[k] = v
+			}
+			branches[res.edge.Target] = output
+		}
+		merged["branches"] = branches
+		if len(branchErrors) > 0 {
+			merged["branch_errors"] = branchErrors
+		}
+
+		if err := r.executeNode(ctx, flow, findNode(flow, joinID), merged, exec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// ErrExecutionInterrupted marks an execution failed over by recovery because
+// a node that must not run twice was in flight when the service stopped.
+var ErrExecutionInterrupted = fmt.Errorf("execution interrupted by restart")
+
+// RecoveryPolicy decides what recovery does with a node that was running when
+// flow-service stopped. Completed nodes are never run again.
+type RecoveryPolicy string
+
+const (
+	RecoverRerun RecoveryPolicy = "rerun" // Run the node again (at-least-once)
+	RecoverFail  RecoveryPolicy = "fail"  // Fail the execution instead (at-most-once)
+)
+
+// NodeRecoveryPolicies lists the delivery guarantee of each node type across
+// a restart. Types not listed fail over.
+//
+//   - eventTrigger, condition, switch, transform, join, auditLog: no
+//     external side effects, so they are simply run again.
+//   - webhook, internalEvent: run again; receivers may see the same call twice
+//     and should deduplicate on the execution ID.
+//   - approval: run again, which pauses the execution for a new decision.
+//   - delay: run again, restarting the wait from the beginning.
+//   - loop, subflow: fail, as their inner progress is not checkpointed.
+var NodeRecoveryPolicies = map[NodeType]RecoveryPolicy{
+	NodeTrigger:       RecoverRerun,
+	NodeCondition:     RecoverRerun,
+	NodeSwitch:        RecoverRerun,
+	NodeTransform:     RecoverRerun,
+	NodeJoin:          RecoverRerun,
+	NodeAuditLog:      RecoverRerun,
+	NodeWebhook:       RecoverRerun,
+	NodeInternalEvent: RecoverRerun,
+	NodeApproval:      RecoverRerun,
+	NodeDelay:         RecoverRerun,
+	NodeLoop:          RecoverFail,
+	NodeSubflow:       RecoverFail,
+}
+
+// RecoveryPolicyFor returns the recovery policy of a node type
+func RecoveryPolicyFor(nodeType NodeType) RecoveryPolicy {
+	if policy, ok := NodeRecoveryPolicies[nodeType]; ok {
+		return policy
+	}
+	return RecoverFail
+}
+
+type pendingNode struct {
+	node  *Node
+	input map[string]interface{}
+}
+
+// Recover continues a running execution from its last checkpoint. Nodes that
+// were in flight are re-run or fail the execution according to their
+// RecoveryPolicy, then every successor of a completed node that never started
+// is run.
+func (r *FlowRunner) Recover(ctx context.Context, flow *Flow, exec *FlowExecution) error {
+	if exec.Status != ExecutionRunning {
+		return fmt.Errorf("execution %s is not running (status: %s)", exec.ID, exec.Status)
+	}
+	tracked(exec)
+
+	started := make(map[string]bool)
+	for _, step := range exec.Steps {
+		started[step.NodeID] = true
+	}
+
+	var pending []pendingNode
+	kept := make([]ExecutionStep, 0, len(exec.Steps))
+	for _, step := range exec.Steps {
+		if step.Status != ExecutionRunning {
+			kept = append(kept, step)
+			continue
+		}
+
+		node := findNode(flow, step.NodeID)
+		if node == nil || RecoveryPolicyFor(node.Type) == RecoverFail {
+			step.Status = ExecutionFailed
+			step.Error = ErrExecutionInterrupted.Error()
+			exec.Steps = append(kept, step)
+			r.finish(ctx, exec, ExecutionFailed)
+			return ErrExecutionInterrupted
+		}
+
+		// Drop the interrupted step; re-running the node records a new one
+		var input map[string]interface{}
+		json.Unmarshal(step.Input, &input)
+		pending = append(pending, pendingNode{node: node, input: input})
+	}
+
+	for _, step := range kept {
+		if step.Status != ExecutionCompleted {
+			continue
+		}
+		node := findNode(flow, step.NodeID)
+		if node == nil {
+			continue
+		}
+		var output map[string]interface{}
+		json.Unmarshal(step.Output, &output)
+		for _, edge := range outgoingEdges(flow, node, output) {
+			next := findNode(flow, edge.Target)
+			if next == nil || started[next.ID] {
+				continue
+			}
+			started[next.ID] = true
+			pending = append(pending, pendingNode{node: next, input: output})
+		}
+	}
+	exec.Steps = kept
+
+	log.Printf("Recovering execution %s: resuming %d node(s)", exec.ID, len(pending))
+	for _, p := range pending {
+		if err := r.executeNode(ctx, flow, p.node, p.input, exec); err != nil {
+			if err == ErrExecutionPaused {
+				return nil
+			}
+			r.finish(ctx, exec, ExecutionFailed)
+			return err
+		}
+	}
+
+	return r.finish(ctx, exec, ExecutionCompleted)
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ReplayAttempt records one replay of an original event into a zone and
+// its outcome
+type ReplayAttempt struct {
+	ID              string    `json:"id"`
+	ZoneID          string    `json:"zone_id"`  // Zone the event was replayed into
+	EventID         string    `json:"event_id"` // Original event
+	IdempotencyKey  string    `json:"idempotency_key,omitempty"`
+	JobID           string    `json:"job_id,omitempty"` // Bulk replay job, if any
+	ReplayedEventID string    `json:"replayed_event_id,omitempty"`
+	Status          string    `json:"status"` // "success" or "error"
+	Error           string    `json:"error,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// ReplayAttemptStore persists replay attempts
+type ReplayAttemptStore interface {
+	CreateReplayAttempt(ctx context.Context, attempt *ReplayAttempt) error
+	// FindSuccessfulReplay returns the latest successful replay of the event
+	// into the zone made since the given time, or nil if there is none. A
+	// non-empty key only matches attempts made with that idempotency key.
+	FindSuccessfulReplay(ctx context.Context, zoneID, eventID, key string, since time.Time) (*ReplayAttempt, error)
+	// ListReplayAttempts returns an event's replay attempts, newest first
+	ListReplayAttempts(ctx context.Context, eventID string) ([]*ReplayAttempt, error)
+}
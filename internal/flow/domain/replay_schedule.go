@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ReplaySchedule re-drives past events from a source zone into a target zone,
+// either once at RunAt or every IntervalSeconds. Each run replays the events
+// created during the LookbackSeconds window preceding the run.
+type ReplaySchedule struct {
+	ID              string     `json:"id"`
+	ZoneID          string     `json:"zone_id"`        // Zone the events are replayed into
+	SourceZoneID    string     `json:"source_zone_id"` // Zone the events are read from
+	Name            string     `json:"name"`
+	EventTypes      []string   `json:"event_types,omitempty"`
+	LookbackSeconds int        `json:"lookback_seconds"`
+	IntervalSeconds int        `json:"interval_seconds,omitempty"` // 0 for one-shot schedules
+	DelayMs         int        `json:"delay_ms,omitempty"`
+	Enabled         bool       `json:"enabled"`
+	NextRunAt       time.Time  `json:"next_run_at"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	LastJobID       string     `json:"last_job_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Recurring reports whether the schedule runs more than once
+func (s *ReplaySchedule) Recurring() bool {
+	return s.IntervalSeconds > 0
+}
+
+// ReplayScheduleStore persists replay schedules
+type ReplayScheduleStore interface {
+	CreateReplaySchedule(ctx context.Context, schedule *ReplaySchedule) error
+	GetReplaySchedule(ctx context.Context, id string) (*ReplaySchedule, error)
+	ListReplaySchedules(ctx context.Context, zoneID string) ([]*ReplaySchedule, error)
+	UpdateReplaySchedule(ctx context.Context, schedule *ReplaySchedule) error
+	DeleteReplaySchedule(ctx context.Context, id string) error
+	ListDueReplaySchedules(ctx context.Context, now time.Time) ([]*ReplaySchedule, error)
+}
+
+var ErrReplayScheduleNotFound = errors.New("replay schedule not found")
@@ -5,7 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/expr"
 )
 
 // ErrExecutionPaused is a sentinel error used to signal that an execution
@@ -13,6 +17,10 @@ import (
 // stack to prevent Execute() from overwriting the paused status.
 var ErrExecutionPaused = fmt.Errorf("execution paused")
 
+// ErrExecutionTimedOut is returned when an execution exceeds its flow's
+// MaxDuration. The execution has already been persisted as timed out.
+var ErrExecutionTimedOut = fmt.Errorf("execution timed out")
+
 type NodeHandler interface {
 	Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error)
 }
@@ -22,18 +30,43 @@ type FlowRunner struct {
 	handlers       map[NodeType]NodeHandler
 	hooks          []ExecutionHook
 	approvalLedger *ApprovalLedgerService // Optional: for recording approval decisions
+	metrics        RunnerMetrics          // Optional: observes executions and node latency
+	secrets        SecretResolver         // Optional: resolves {{secrets.NAME}} in node configs
+	failures       FailureHandler         // Optional: told about failed executions
+	heartbeat      time.Duration          // How often a running node's execution is checkpointed; 0 disables
+}
+
+// DefaultHeartbeat is how often the execution of a running node is
+// checkpointed, so that recovery does not take a long node for interrupted
+const DefaultHeartbeat = 30 * time.Second
+
+// RunnerMetrics observes executions started with Execute and the nodes they
+// run. Dry runs are not observed. Methods may be called concurrently.
+type RunnerMetrics interface {
+	ExecutionStarted(flow *Flow)
+	ExecutionFinished(flow *Flow, status ExecutionStatus)
+	NodeFinished(flow *Flow, node *Node, duration time.Duration, err error)
 }
 
+// ExecutionHook observes node execution. Hooks may be called concurrently
+// when a flow fans out into parallel branches.
 type ExecutionHook interface {
 	BeforeNode(ctx context.Context, node *Node, input map[string]interface{})
 	AfterNode(ctx context.Context, node *Node, output map[string]interface{}, err error)
 }
 
+// NodeGate is an optional ExecutionHook extension that may block before a
+// node runs (e.g. at a debugger breakpoint) and veto it by returning an error.
+type NodeGate interface {
+	AwaitNode(ctx context.Context, node *Node, input map[string]interface{}) error
+}
+
 func NewFlowRunner(repo Repository) *FlowRunner {
 	r := &FlowRunner{
-		repo:     repo,
-		handlers: make(map[NodeType]NodeHandler),
-		hooks:    make([]ExecutionHook, 0),
+		repo:      repo,
+		handlers:  make(map[NodeType]NodeHandler),
+		hooks:     make([]ExecutionHook, 0),
+		heartbeat: DefaultHeartbeat,
 	}
 	r.registerDefaultHandlers()
 	return r
@@ -43,6 +76,24 @@ func (r *FlowRunner) SetApprovalLedger(ledger *ApprovalLedgerService) {
 	r.approvalLedger = ledger
 }
 
+func (r *FlowRunner) SetMetrics(metrics RunnerMetrics) {
+	r.metrics = metrics
+}
+
+func (r *FlowRunner) SetSecrets(secrets SecretResolver) {
+	r.secrets = secrets
+}
+
+func (r *FlowRunner) SetFailureHandler(handler FailureHandler) {
+	r.failures = handler
+}
+
+// SetHeartbeat sets how often the execution of a running node is
+// checkpointed. It must be well under recovery's staleness window.
+func (r *FlowRunner) SetHeartbeat(interval time.Duration) {
+	r.heartbeat = interval
+}
+
 func (r *FlowRunner) AddHook(hook ExecutionHook) {
 	r.hooks = append(r.hooks, hook)
 }
@@ -52,9 +103,69 @@ func (r *FlowRunner) registerDefaultHandlers() {
 	r.handlers[NodeWebhook] = &WebhookHandler{}
 	r.handlers[NodeApproval] = &ApprovalHandler{}
 	r.handlers[NodeAuditLog] = &AuditHandler{}
+	r.handlers[NodeJoin] = &JoinHandler{}
+	r.handlers[NodeSwitch] = &SwitchHandler{}
 }
 
-func (r *FlowRunner) Execute(ctx context.Context, flow *Flow, input map[string]interface{}) error {
+type simulationKey struct{}
+
+// WithSimulation marks the context as a dry run. Handlers with side effects
+// must only describe what they would have done, and nothing is persisted.
+func WithSimulation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, simulationKey{}, true)
+}
+
+// IsSimulation reports whether the context belongs to a dry run
+func IsSimulation(ctx context.Context) bool {
+	simulated, _ := ctx.Value(simulationKey{}).(bool)
+	return simulated
+}
+
+type executionIDKey struct{}
+
+// ExecutionIDFromContext returns the ID of the execution a node is running
+// in
+func ExecutionIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(executionIDKey{}).(string)
+	return id
+}
+
+type idempotencyScopeKey struct{}
+
+// WithIdempotencyScope makes the executions run with the context derive the
+// idempotency keys of their side effects from id instead of their own ID,
+// so that a new execution retrying a failed one repeats none of the side
+// effects the failed one completed
+func WithIdempotencyScope(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idempotencyScopeKey{}, id)
+}
+
+// IdempotencyScopeFromContext returns the ID a node derives the idempotency
+// keys of its side effects from: the scope the execution was started with,
+// or else the execution's own ID. It is empty outside an execution.
+func IdempotencyScopeFromContext(ctx context.Context) string {
+	if id, _ := ctx.Value(idempotencyScopeKey{}).(string); id != "" && ExecutionIDFromContext(ctx) != "" {
+		return id
+	}
+	return ExecutionIDFromContext(ctx)
+}
+
+type nodeScopeKey struct{}
+
+// NodeScope identifies the flow node a handler is running for
+type NodeScope struct {
+	FlowID string
+	ZoneID string
+	NodeID string
+}
+
+// NodeScopeFromContext returns the flow node a handler is running for
+func NodeScopeFromContext(ctx context.Context) NodeScope {
+	scope, _ := ctx.Value(nodeScopeKey{}).(NodeScope)
+	return scope
+}
+
+func newExecution(flow *Flow, input map[string]interface{}) *FlowExecution {
 	exec := &FlowExecution{
 		ID:          fmt.Sprintf("exec_%d", time.Now().UnixNano()),
 		FlowID:      flow.ID,
@@ -64,46 +175,252 @@ func (r *FlowRunner) Execute(ctx context.Context, flow *Flow, input map[string]i
 	}
 	inputBytes, _ := json.Marshal(input)
 	exec.Input = inputBytes
+	return tracked(exec)
+}
 
-	if err := r.repo.CreateExecution(ctx, exec); err != nil {
-		return err
+// executionState guards an execution while its parallel branches update
+// it. Each execution has its own, so executions never wait on each other.
+type executionState struct {
+	mu        sync.Mutex // Guards the execution's fields; never held across I/O
+	writeMu   sync.Mutex // Orders the writes of the execution's snapshots
+	version   uint64     // Snapshots taken so far
+	persisted uint64     // Version of the latest snapshot written
+}
+
+// tracked gives a new or loaded execution its state. Entry points call it
+// before any branch runs.
+func tracked(exec *FlowExecution) *FlowExecution {
+	if exec.state == nil {
+		exec.state = &executionState{}
 	}
+	return exec
+}
 
-	// Find trigger node
-	var startNode *Node
+func findTriggerNode(flow *Flow) (*Node, error) {
 	for _, n := range flow.Nodes {
 		if n.Type == NodeTrigger {
-			startNode = &n
-			break
+			return &n, nil
 		}
 	}
+	return nil, fmt.Errorf("no trigger node found in flow %s", flow.ID)
+}
 
-	if startNode == nil {
-		return fmt.Errorf("no trigger node found in flow %s", flow.ID)
+// updateExecution persists execution progress unless running a dry run
+func (r *FlowRunner) updateExecution(ctx context.Context, exec *FlowExecution) error {
+	if IsSimulation(ctx) {
+		return nil
+	}
+	state := exec.state
+	state.mu.Lock()
+	exec.CheckpointedAt = time.Now()
+	snapshot := *exec
+	snapshot.Steps = append([]ExecutionStep(nil), exec.Steps...)
+	state.version++
+	version := state.version
+	state.mu.Unlock()
+
+	// Branches checkpointing at once write their snapshots one at a time,
+	// and a snapshot older than the one already written is dropped, so
+	// stored progress never goes backwards
+	state.writeMu.Lock()
+	defer state.writeMu.Unlock()
+	if version < state.persisted {
+		return nil
 	}
+	state.persisted = version
+	return r.repo.UpdateExecution(ctx, &snapshot)
+}
 
-	if err := r.executeNode(ctx, flow, startNode, input, exec); err != nil {
-		if err == ErrExecutionPaused {
-			return nil // Execution paused successfully; status already persisted
+// checkpoint persists progress when a node starts and finishes so that an
+// interrupted execution can be recovered. Failures are logged rather than
+// failing the execution.
+func (r *FlowRunner) checkpoint(ctx context.Context, exec *FlowExecution) {
+	if err := r.updateExecution(ctx, exec); err != nil {
+		log.Printf("Failed to checkpoint execution %s: %v", exec.ID, err)
+	}
+}
+
+// keepAlive checkpoints the execution every heartbeat until the returned
+// function is called, so a node running longer than recovery's staleness
+// window is not recovered while it is still running
+func (r *FlowRunner) keepAlive(ctx context.Context, exec *FlowExecution) (stop func()) {
+	if r.heartbeat <= 0 || IsSimulation(ctx) {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(r.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.checkpoint(ctx, exec)
+			}
 		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (r *FlowRunner) Execute(ctx context.Context, flow *Flow, input map[string]interface{}) error {
+	startNode, err := findTriggerNode(flow)
+	if err != nil {
 		return err
 	}
 
-	exec.Status = ExecutionCompleted
+	exec := newExecution(flow, input)
+	if err := r.repo.CreateExecution(ctx, exec); err != nil {
+		return err
+	}
+	if r.metrics != nil {
+		r.metrics.ExecutionStarted(flow)
+	}
+	ctx, span := startExecutionSpan(ctx, flow, exec)
+
+	err = r.run(ctx, flow, startNode, input, exec)
+	switch err {
+	case nil:
+		err = r.finish(ctx, exec, ExecutionCompleted)
+	case ErrExecutionPaused:
+		err = nil // Execution paused successfully; status already persisted
+	case ErrExecutionTimedOut:
+	default:
+		// Mark it failed so recovery does not pick it up as interrupted
+		r.finish(ctx, exec, ExecutionFailed)
+	}
+	if r.metrics != nil {
+		r.metrics.ExecutionFinished(flow, exec.Status)
+	}
+	if err != nil && r.failures != nil {
+		r.failures.ExecutionFailed(ctx, flow, exec, err)
+	}
+	endExecutionSpan(span, exec, err)
+	return err
+}
+
+// finish records the final status of an execution
+func (r *FlowRunner) finish(ctx context.Context, exec *FlowExecution, status ExecutionStatus) error {
+	exec.Status = status
 	exec.EndedAt = time.Now()
-	return r.repo.UpdateExecution(ctx, exec)
+	return r.updateExecution(ctx, exec)
+}
+
+// DryRun executes a flow against a sample input without side effects.
+// Nothing is persisted; the returned execution carries the node-by-node trace.
+func (r *FlowRunner) DryRun(ctx context.Context, flow *Flow, input map[string]interface{}) (*FlowExecution, error) {
+	ctx = WithSimulation(ctx)
+	exec := newExecution(flow, input)
+
+	startNode, err := findTriggerNode(flow)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.run(ctx, flow, startNode, input, exec)
+	exec.EndedAt = time.Now()
+	switch {
+	case err == ErrExecutionPaused, err == ErrExecutionTimedOut:
+		// Status already set by executeNode or timeOut
+	case err != nil:
+		exec.Status = ExecutionFailed
+	default:
+		exec.Status = ExecutionCompleted
+	}
+	return exec, nil
+}
+
+// run executes the flow from startNode within the flow's MaxDuration.
+func (r *FlowRunner) run(ctx context.Context, flow *Flow, startNode *Node, input map[string]interface{}, exec *FlowExecution) error {
+	runCtx := ctx
+	if flow.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, time.Duration(flow.MaxDuration)*time.Second)
+		defer cancel()
+	}
+
+	err := r.executeNode(runCtx, flow, startNode, input, exec)
+	if err == nil || err == ErrExecutionPaused || ctx.Err() != nil || runCtx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	return r.timeOut(ctx, flow, exec)
+}
+
+// timeOut marks an execution that ran past its deadline as timed out,
+// keeping the steps that already finished, and then runs the flow's
+// on-timeout path without the deadline.
+func (r *FlowRunner) timeOut(ctx context.Context, flow *Flow, exec *FlowExecution) error {
+	exec.state.mu.Lock()
+	timedOutAt := exec.CurrentNodeID
+	for i := range exec.Steps {
+		if exec.Steps[i].Status == ExecutionRunning || strings.HasSuffix(exec.Steps[i].Error, context.DeadlineExceeded.Error()) {
+			exec.Steps[i].Status = ExecutionTimedOut
+			exec.Steps[i].Error = context.DeadlineExceeded.Error()
+		}
+	}
+	exec.state.mu.Unlock()
+	log.Printf("Execution %s timed out after %ds at node %s", exec.ID, flow.MaxDuration, timedOutAt)
+
+	if node := findNode(flow, flow.OnTimeoutNodeID); node != nil {
+		input := map[string]interface{}{
+			"execution_id": exec.ID,
+			"timed_out_at": timedOutAt,
+			"max_duration": flow.MaxDuration,
+		}
+		if err := r.executeNode(ctx, flow, node, input, exec); err != nil {
+			log.Printf("On-timeout path of execution %s failed: %v", exec.ID, err)
+		}
+	}
+
+	exec.state.mu.Lock()
+	exec.Status = ExecutionTimedOut
+	exec.EndedAt = time.Now()
+	exec.state.mu.Unlock()
+	if err := r.updateExecution(ctx, exec); err != nil {
+		return err
+	}
+	return ErrExecutionTimedOut
 }
 
 func (r *FlowRunner) executeNode(ctx context.Context, flow *Flow, node *Node, input map[string]interface{}, exec *FlowExecution) error {
+	output, err := r.runStep(ctx, flow, node, input, exec)
+	if err != nil {
+		return err
+	}
+
+	if err := r.runNext(ctx, flow, outgoingEdges(flow, node, output), output, exec); err != nil {
+		return err
+	}
+
+	return r.updateExecution(ctx, exec)
+}
+
+// runStep runs a single node as a recorded step without following its edges
+func (r *FlowRunner) runStep(ctx context.Context, flow *Flow, node *Node, input map[string]interface{}, exec *FlowExecution) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	log.Printf("Executing node %s (%s)", node.ID, node.Type)
-	exec.CurrentNodeID = node.ID
 
 	step := ExecutionStep{
 		NodeID: node.ID,
 		Status: ExecutionRunning,
 		Input:  func() json.RawMessage { b, _ := json.Marshal(input); return b }(),
 	}
+	exec.state.mu.Lock()
+	exec.CurrentNodeID = node.ID
 	exec.Steps = append(exec.Steps, step)
+	stepIdx := len(exec.Steps) - 1
+	exec.state.mu.Unlock()
+	r.checkpoint(ctx, exec)
 
 	var output map[string]interface{}
 	var err error
@@ -112,11 +429,40 @@ func (r *FlowRunner) executeNode(ctx context.Context, flow *Flow, node *Node, in
 		hook.BeforeNode(ctx, node, input)
 	}
 
-	handler, ok := r.handlers[node.Type]
-	if ok {
-		output, err = handler.Execute(ctx, node, input)
-	} else {
-		output = input
+	for _, hook := range r.hooks {
+		if gate, ok := hook.(NodeGate); ok {
+			if err := gate.AwaitNode(ctx, node, input); err != nil {
+				r.failStep(exec, stepIdx, err)
+				return nil, err
+			}
+		}
+	}
+
+	ctx = context.WithValue(ctx, executionIDKey{}, exec.ID)
+	ctx = context.WithValue(ctx, nodeScopeKey{}, NodeScope{FlowID: flow.ID, ZoneID: flow.ZoneID, NodeID: node.ID})
+	ctx, span := startNodeSpan(ctx, node)
+	started := time.Now()
+	// Secrets are resolved into a copy of the node handed to its handler
+	// only, and their values are scrubbed from what the handler returns,
+	// so they never reach the stored flow, the execution steps or the
+	// nodes downstream
+	resolved, secrets, err := resolveSecrets(ctx, r.secrets, flow.ZoneID, node)
+	if err == nil {
+		ctx = withResolvedSecrets(ctx, secrets)
+		stop := r.keepAlive(ctx, exec)
+		if handler, ok := r.handlers[node.Type]; ok {
+			output, err = handler.Execute(ctx, resolved, input)
+		} else if node.Type == NodeLoop {
+			output, err = r.runLoop(ctx, flow, resolved, input, exec)
+		} else {
+			output = input
+		}
+		stop()
+		output, err = redactOutput(ctx, output), redactError(ctx, err)
+	}
+	endNodeSpan(span, err)
+	if r.metrics != nil && !IsSimulation(ctx) {
+		r.metrics.NodeFinished(flow, node, time.Since(started), err)
 	}
 
 	for _, hook := range r.hooks {
@@ -126,54 +472,70 @@ func (r *FlowRunner) executeNode(ctx context.Context, flow *Flow, node *Node, in
 	if err != nil {
 		if err.Error() == "execution_paused" {
 			log.Printf("Node %s paused execution", node.ID)
+			exec.state.mu.Lock()
 			exec.Status = ExecutionPaused
-			exec.Steps[len(exec.Steps)-1].Status = ExecutionPaused
-			if dbErr := r.repo.UpdateExecution(ctx, exec); dbErr != nil {
-				return dbErr
+			exec.Steps[stepIdx].Status = ExecutionPaused
+			exec.state.mu.Unlock()
+			if dbErr := r.updateExecution(ctx, exec); dbErr != nil {
+				return nil, dbErr
 			}
-			return ErrExecutionPaused
+			return nil, ErrExecutionPaused
 		}
 		log.Printf("Node %s failed: %v", node.ID, err)
-		exec.Steps[len(exec.Steps)-1].Status = ExecutionFailed
-		exec.Steps[len(exec.Steps)-1].Error = err.Error()
-		return err
+		r.failStep(exec, stepIdx, err)
+		return nil, err
 	}
 
 	outputBytes, _ := json.Marshal(output)
-	exec.Steps[len(exec.Steps)-1].Status = ExecutionCompleted
-	exec.Steps[len(exec.Steps)-1].Output = outputBytes
+	exec.state.mu.Lock()
+	exec.Steps[stepIdx].Status = ExecutionCompleted
+	exec.Steps[stepIdx].Output = outputBytes
+	exec.state.mu.Unlock()
+	r.checkpoint(ctx, exec)
+
+	return output, nil
+}
+
+func (r *FlowRunner) failStep(exec *FlowExecution, stepIdx int, err error) {
+	exec.state.mu.Lock()
+	defer exec.state.mu.Unlock()
+	exec.Steps[stepIdx].Status = ExecutionFailed
+	exec.Steps[stepIdx].Error = err.Error()
+}
 
-	// Find next nodes
-	var nextNodes []*Node
+// outgoingEdges returns the edges to follow after a node has run. Condition
+// and switch nodes only follow the handle matching their result, and loop
+// nodes skip the edge to their body, which the loop has already run.
+func outgoingEdges(flow *Flow, node *Node, output map[string]interface{}) []Edge {
+	var edges []Edge
 	for _, edge := range flow.Edges {
-		if edge.Source == node.ID {
-			if node.Type == NodeCondition {
-				res, _ := output["result"].(bool)
-				if (res && edge.SourceHandle == "true") || (!res && edge.SourceHandle == "false") {
-					for _, n := range flow.Nodes {
-						if n.ID == edge.Target {
-							nextNodes = append(nextNodes, &n)
-						}
-					}
-				}
+		if edge.Source != node.ID {
+			continue
+		}
+		if node.Type == NodeCondition {
+			res, _ := output["result"].(bool)
+			if !((res && edge.SourceHandle == "true") || (!res && edge.SourceHandle == "false")) {
 				continue
 			}
-
-			for _, n := range flow.Nodes {
-				if n.ID == edge.Target {
-					nextNodes = append(nextNodes, &n)
-				}
-			}
 		}
+		if node.Type == NodeSwitch && edge.SourceHandle != output["case"] {
+			continue
+		}
+		if node.Type == NodeLoop && edge.SourceHandle == LoopBodyHandle {
+			continue
+		}
+		edges = append(edges, edge)
 	}
+	return edges
+}
 
-	for _, next := range nextNodes {
-		if err := r.executeNode(ctx, flow, next, output, exec); err != nil {
-			return err
+func findNode(flow *Flow, id string) *Node {
+	for _, n := range flow.Nodes {
+		if n.ID == id {
+			return &n
 		}
 	}
-
-	return r.repo.UpdateExecution(ctx, exec)
+	return nil
 }
 
 func (r *FlowRunner) Resume(ctx context.Context, execID string, overrides map[string]interface{}) error {
@@ -181,6 +543,7 @@ func (r *FlowRunner) Resume(ctx context.Context, execID string, overrides map[st
 	if err != nil {
 		return err
 	}
+	tracked(exec)
 
 	if exec.Status != ExecutionPaused {
 		return fmt.Errorf("execution %s is not paused (status: %s)", execID, exec.Status)
@@ -273,24 +636,18 @@ func (r *FlowRunner) Resume(ctx context.Context, execID string, overrides map[st
 	}
 
 	// Continue from next nodes
-	var nextNodes []*Node
+	var nextEdges []Edge
 	for _, edge := range flow.Edges {
 		if edge.Source == currentNode.ID {
-			for _, n := range flow.Nodes {
-				if n.ID == edge.Target {
-					nextNodes = append(nextNodes, &n)
-				}
-			}
+			nextEdges = append(nextEdges, edge)
 		}
 	}
 
-	for _, nextNode := range nextNodes {
-		if err := r.executeNode(ctx, flow, nextNode, overrides, exec); err != nil {
-			if err == ErrExecutionPaused {
-				return nil
-			}
-			return err
+	if err := r.runNext(ctx, flow, nextEdges, overrides, exec); err != nil {
+		if err == ErrExecutionPaused {
+			return nil
 		}
+		return err
 	}
 
 	exec.Status = ExecutionCompleted
@@ -298,6 +655,11 @@ func (r *FlowRunner) Resume(ctx context.Context, execID string, overrides map[st
 	return r.repo.UpdateExecution(ctx, exec)
 }
 
+// RegisterHandler sets the handler for a node type, replacing any existing one
+func (r *FlowRunner) RegisterHandler(nodeType NodeType, handler NodeHandler) {
+	r.handlers[nodeType] = handler
+}
+
 // GetHandler returns the handler for a specific node type
 func (r *FlowRunner) GetHandler(nodeType NodeType) NodeHandler {
 	return r.handlers[nodeType]
@@ -305,16 +667,27 @@ func (r *FlowRunner) GetHandler(nodeType NodeType) NodeHandler {
 
 // --- Specific Handlers ---
 
+// ConditionHandler compares a single field with a value, or evaluates an
+// expression such as amount > 100 && currency == "USD" when one is set
 type ConditionHandler struct{}
 
 func (h *ConditionHandler) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
 	var config struct {
-		Field    string      `json:"field"`
-		Operator string      `json:"operator"`
-		Value    interface{} `json:"value"`
+		Field      string      `json:"field"`
+		Operator   string      `json:"operator"`
+		Value      interface{} `json:"value"`
+		Expression string      `json:"expression"`
 	}
 	json.Unmarshal(node.Data, &config)
 
+	if config.Expression != "" {
+		result, err := expr.EvalBool(config.Expression, input)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"result": result}, nil
+	}
+
 	inputValue, ok := input[config.Field]
 	if !ok {
 		return map[string]interface{}{"result": false}, nil
@@ -333,9 +706,43 @@ func (h *ConditionHandler) Execute(ctx context.Context, node *Node, input map[st
 	return map[string]interface{}{"result": result}, nil
 }
 
+// SwitchDefaultHandle is the source handle followed when no case matches
+const SwitchDefaultHandle = "default"
+
+// SwitchHandler compares an input field with the node's case values. It
+// passes the input through with "case" set to the matching value, or to
+// SwitchDefaultHandle, which is the handle of the edge to follow.
+type SwitchHandler struct{}
+
+func (h *SwitchHandler) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	var config struct {
+		Field string        `json:"field"` // Dot path, e.g. "data.currency"
+		Cases []interface{} `json:"cases"`
+	}
+	json.Unmarshal(node.Data, &config)
+
+	output := make(map[string]interface{}, len(input)+1)
+	for k, v := range input {
+		output[k] = v
+	}
+	output["case"] = SwitchDefaultHandle
+
+	value, ok := lookupPath(input, config.Field)
+	for _, c := range config.Cases {
+		if ok && fmt.Sprintf("%v", value) == fmt.Sprintf("%v", c) {
+			output["case"] = fmt.Sprintf("%v", c)
+			break
+		}
+	}
+	return output, nil
+}
+
 type WebhookHandler struct{}
 
 func (h *WebhookHandler) Execute(ctx context.Context, node *Node, input map[string]interface{}) (map[string]interface{}, error) {
+	if IsSimulation(ctx) {
+		return map[string]interface{}{"status": "simulated"}, nil
+	}
 	log.Printf("Sending webhook for node %s", node.ID)
 	return map[string]interface{}{"status": "sent"}, nil
 }
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer uses the global provider so spans are exported once InitTracer
+// has run and dropped otherwise
+var tracer = otel.Tracer("github.com/sapliy/fintech-ecosystem/internal/flow")
+
+// NodeRetriesKey annotates a node span with how many times the node retried
+// its work. Nodes set it on the span in their context.
+const NodeRetriesKey = attribute.Key("flow.node.retries")
+
+const (
+	flowIDKey      = attribute.Key("flow.id")
+	flowZoneIDKey  = attribute.Key("flow.zone_id")
+	execIDKey      = attribute.Key("flow.execution_id")
+	execStatusKey  = attribute.Key("flow.execution.status")
+	nodeIDKey      = attribute.Key("flow.node.id")
+	nodeTypeKey    = attribute.Key("flow.node.type")
+	nodeSuccessKey = attribute.Key("flow.node.success")
+)
+
+// startExecutionSpan starts the root span of an execution. A span already
+// in ctx, such as the request that triggered the flow, is linked rather
+// than made the parent so every execution is its own trace.
+func startExecutionSpan(ctx context.Context, flow *Flow, exec *FlowExecution) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithAttributes(flowIDKey.String(flow.ID), flowZoneIDKey.String(flow.ZoneID), execIDKey.String(exec.ID)),
+	}
+	if parent := trace.SpanContextFromContext(ctx); parent.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: parent}))
+	}
+	return tracer.Start(ctx, "flow.execute "+flow.Name, opts...)
+}
+
+// endExecutionSpan records the execution's final status on its span
+func endExecutionSpan(span trace.Span, exec *FlowExecution, err error) {
+	span.SetAttributes(execStatusKey.String(string(exec.Status)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startNodeSpan starts a child span for a node of the execution in ctx
+func startNodeSpan(ctx context.Context, node *Node) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "flow.node "+string(node.Type), trace.WithAttributes(
+		nodeIDKey.String(node.ID),
+		nodeTypeKey.String(string(node.Type)),
+	))
+}
+
+// endNodeSpan records whether the node succeeded. A paused node counts as a
+// success.
+func endNodeSpan(span trace.Span, err error) {
+	paused := err != nil && err.Error() == "execution_paused"
+	span.SetAttributes(nodeSuccessKey.Bool(err == nil || paused))
+	if err != nil && !paused {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
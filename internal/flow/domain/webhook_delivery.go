@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// WebhookDelivery archives one request sent by a webhook action node and
+// the response it got, so integrators can see exactly what left the system
+type WebhookDelivery struct {
+	ID              string            `json:"id"`
+	FlowID          string            `json:"flow_id"`
+	ZoneID          string            `json:"zone_id"`
+	ExecutionID     string            `json:"execution_id"`
+	NodeID          string            `json:"node_id"`
+	Attempt         int               `json:"attempt"` // 1 for the first request, then per retry
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	StatusCode      int               `json:"status_code,omitempty"` // 0 when no response was received
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	ResponseBody    string            `json:"response_body,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	LatencyMs       int64             `json:"latency_ms"`
+	CreatedAt       time.Time         `json:"created_at"`
+}
+
+// WebhookDeliveryFilter narrows the deliveries listed for a flow
+type WebhookDeliveryFilter struct {
+	FlowID      string
+	ExecutionID string // Optional
+	NodeID      string // Optional
+	Limit       int
+	Offset      int
+}
+
+// WebhookDeliveryStore persists archived webhook deliveries
+type WebhookDeliveryStore interface {
+	CreateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	// ListWebhookDeliveries returns the matching deliveries, newest first
+	ListWebhookDeliveries(ctx context.Context, filter WebhookDeliveryFilter) ([]*WebhookDelivery, error)
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WebhookHook is an inbound webhook endpoint of a zone. Requests must be
+// signed with the hook's secret; their payloads become events of EventType
+// unless the sender names the type itself.
+type WebhookHook struct {
+	ID        string    `json:"id"`
+	ZoneID    string    `json:"zone_id"`
+	Name      string    `json:"name"`
+	EventType string    `json:"event_type,omitempty"`
+	Secret    string    `json:"secret,omitempty"` // Only returned when the hook is created
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookHookStore persists inbound webhook hooks
+type WebhookHookStore interface {
+	CreateWebhookHook(ctx context.Context, hook *WebhookHook) error
+	GetWebhookHook(ctx context.Context, id string) (*WebhookHook, error)
+	ListWebhookHooks(ctx context.Context, zoneID string) ([]*WebhookHook, error)
+	DeleteWebhookHook(ctx context.Context, id string) error
+}
+
+var ErrWebhookHookNotFound = errors.New("webhook hook not found")
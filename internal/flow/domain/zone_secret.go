@@ -0,0 +1,166 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ZoneSecret is a named credential of a zone, such as an SMTP password or a
+// Slack webhook URL. Node configs refer to it as {{secrets.NAME}} instead
+// of holding the value. Ciphertext is the encrypted value and is never
+// returned by the API.
+type ZoneSecret struct {
+	ZoneID     string    `json:"zone_id"`
+	Name       string    `json:"name"`
+	Ciphertext []byte    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ZoneSecretStore persists zone secrets
+type ZoneSecretStore interface {
+	// SaveZoneSecret creates the secret or replaces the existing one
+	SaveZoneSecret(ctx context.Context, secret *ZoneSecret) error
+	GetZoneSecret(ctx context.Context, zoneID, name string) (*ZoneSecret, error)
+	ListZoneSecrets(ctx context.Context, zoneID string) ([]*ZoneSecret, error)
+	DeleteZoneSecret(ctx context.Context, zoneID, name string) error
+}
+
+var ErrZoneSecretNotFound = errors.New("zone secret not found")
+
+// SecretResolver returns the plaintext value of a zone secret
+type SecretResolver interface {
+	ResolveSecret(ctx context.Context, zoneID, name string) (string, error)
+}
+
+// secretReference matches {{secrets.NAME}} placeholders in node configs
+var secretReference = regexp.MustCompile(`\{\{\s*secrets\.([A-Za-z][A-Za-z0-9_]*)\s*\}\}`)
+
+// IsSecretReference reports whether s refers to a zone secret rather than
+// holding a credential itself
+func IsSecretReference(s string) bool {
+	return secretReference.MatchString(s)
+}
+
+// resolveSecrets returns a copy of the node with the {{secrets.NAME}}
+// placeholders in its config replaced by the zone's secret values, and the
+// values it resolved
+func resolveSecrets(ctx context.Context, resolver SecretResolver, zoneID string, node *Node) (*Node, []string, error) {
+	if resolver == nil || !secretReference.Match(node.Data) {
+		return node, nil, nil
+	}
+
+	var resolveErr error
+	var values []string
+	data := secretReference.ReplaceAllFunc(node.Data, func(match []byte) []byte {
+		name := string(secretReference.FindSubmatch(match)[1])
+		value, err := resolver.ResolveSecret(ctx, zoneID, name)
+		if err != nil {
+			if resolveErr == nil {
+				resolveErr = fmt.Errorf("secret %s: %w", name, err)
+			}
+			return match
+		}
+		values = append(values, value)
+		// Placeholders sit inside JSON strings, so the value is escaped
+		// as a JSON string without its quotes
+		b, _ := json.Marshal(value)
+		return b[1 : len(b)-1]
+	})
+	if resolveErr != nil {
+		return nil, nil, resolveErr
+	}
+
+	resolved := *node
+	resolved.Data = data
+	return &resolved, values, nil
+}
+
+type resolvedSecretsKey struct{}
+
+// withResolvedSecrets records the secret values resolved for a node, in
+// every encoding they may take in a URL, a header or a JSON body, for
+// RedactSecrets
+func withResolvedSecrets(ctx context.Context, values []string) context.Context {
+	if len(values) == 0 {
+		return ctx
+	}
+	seen := make(map[string]bool)
+	var forms []string
+	for _, value := range values {
+		quoted, _ := json.Marshal(value)
+		for _, form := range []string{value, string(quoted[1 : len(quoted)-1]), url.QueryEscape(value), url.PathEscape(value)} {
+			if form != "" && !seen[form] {
+				seen[form] = true
+				forms = append(forms, form)
+			}
+		}
+	}
+	// Longer forms first, so a value is not half replaced inside one of
+	// its own encodings
+	sort.Slice(forms, func(i, j int) bool { return len(forms[i]) > len(forms[j]) })
+	return context.WithValue(ctx, resolvedSecretsKey{}, forms)
+}
+
+// RedactSecrets replaces the zone secret values resolved for the running
+// node with [REDACTED]. Handlers apply it to whatever they persist or
+// report that may carry their resolved config.
+func RedactSecrets(ctx context.Context, s string) string {
+	forms, _ := ctx.Value(resolvedSecretsKey{}).([]string)
+	for _, form := range forms {
+		s = strings.ReplaceAll(s, form, "[REDACTED]")
+	}
+	return s
+}
+
+// redactedError is an error whose message had resolved secret values
+// removed. It unwraps to the original, so errors.Is still sees through it.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
+
+// redactError removes the secret values resolved for the running node from
+// the error's message, such as the URL a *url.Error carries
+func redactError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := RedactSecrets(ctx, err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+// redactOutput removes the secret values resolved for the running node from
+// its output, such as a response echoing a token back
+func redactOutput(ctx context.Context, output map[string]interface{}) map[string]interface{} {
+	if ctx.Value(resolvedSecretsKey{}) == nil || output == nil {
+		return output
+	}
+	b, err := json.Marshal(output)
+	if err != nil {
+		return output
+	}
+	redacted := RedactSecrets(ctx, string(b))
+	if redacted == string(b) {
+		return output
+	}
+	var scrubbed map[string]interface{}
+	if err := json.Unmarshal([]byte(redacted), &scrubbed); err != nil {
+		return output
+	}
+	return scrubbed
+}
@@ -0,0 +1,236 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// OverflowPolicy decides what happens to an execution submitted while the
+// pool's queue is full
+type OverflowPolicy string
+
+const (
+	OverflowDrop   OverflowPolicy = "drop"   // Discard the new execution
+	OverflowDelay  OverflowPolicy = "delay"  // Block the submitter until there is room
+	OverflowReject OverflowPolicy = "reject" // Return ErrQueueFull to the submitter
+)
+
+var (
+	// ErrQueueFull is returned when an execution is rejected by a full queue
+	ErrQueueFull = errors.New("flow execution queue is full")
+	// ErrExecutionDropped is returned when a full queue discards an execution
+	ErrExecutionDropped = errors.New("flow execution dropped: queue is full")
+	// ErrPoolStopped is returned for executions submitted to or still queued
+	// in a stopped pool
+	ErrPoolStopped = errors.New("flow execution pool stopped")
+)
+
+var (
+	flowQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "flow_execution_queue_depth",
+		Help: "Flow executions waiting for a worker.",
+	}, []string{"zone"})
+
+	flowExecutionsRunning = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "flow_executions_running",
+		Help: "Flow executions currently running.",
+	}, []string{"zone"})
+
+	flowQueueOverflows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flow_execution_queue_overflows_total",
+		Help: "Flow executions dropped or rejected because the queue was full.",
+	}, []string{"zone", "policy"})
+)
+
+// ExecutionPoolConfig configures an ExecutionPool
+type ExecutionPoolConfig struct {
+	Workers   int            // Executions run at once across all zones; defaults to 1
+	QueueSize int            // Executions waiting for a worker; defaults to 100
+	ZoneLimit int            // Executions run at once per zone; 0 is unlimited
+	FlowLimit int            // Executions run at once per flow; 0 is unlimited
+	Overflow  OverflowPolicy // Defaults to OverflowDelay
+}
+
+type poolJob struct {
+	ctx   context.Context
+	flow  *domain.Flow
+	input map[string]interface{}
+	done  chan error
+}
+
+// ExecutionPool runs flow executions on a fixed set of workers. Executions
+// wait in a bounded queue until a worker is free and their zone and flow are
+// below their concurrency limits; a busy zone does not hold up the others.
+type ExecutionPool struct {
+	runner *domain.FlowRunner
+	config ExecutionPoolConfig
+
+	mu          sync.Mutex
+	pending     []*poolJob
+	zoneRunning map[string]int
+	flowRunning map[string]int
+	stopped     bool
+	changed     chan struct{} // Closed and replaced whenever the queue or running counts change
+}
+
+// NewExecutionPool creates a pool running executions with the runner
+func NewExecutionPool(runner *domain.FlowRunner, config ExecutionPoolConfig) *ExecutionPool {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.QueueSize < 1 {
+		config.QueueSize = 100
+	}
+	if config.Overflow == "" {
+		config.Overflow = OverflowDelay
+	}
+	return &ExecutionPool{
+		runner:      runner,
+		config:      config,
+		zoneRunning: make(map[string]int),
+		flowRunning: make(map[string]int),
+		changed:     make(chan struct{}),
+	}
+}
+
+// ParseOverflowPolicy parses "drop", "delay" or "reject"
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch p := OverflowPolicy(s); p {
+	case OverflowDrop, OverflowDelay, OverflowReject:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown overflow policy %q", s)
+}
+
+// Submit queues an execution of the flow. The returned channel receives the
+// execution's result once it has run. When the queue is full the pool's
+// overflow policy applies; with OverflowDelay, Submit waits for room until
+// ctx is done.
+func (p *ExecutionPool) Submit(ctx context.Context, flow *domain.Flow, input map[string]interface{}) (<-chan error, error) {
+	job := &poolJob{ctx: ctx, flow: flow, input: input, done: make(chan error, 1)}
+	for {
+		p.mu.Lock()
+		if p.stopped {
+			p.mu.Unlock()
+			return nil, ErrPoolStopped
+		}
+		if len(p.pending) < p.config.QueueSize {
+			p.pending = append(p.pending, job)
+			flowQueueDepth.WithLabelValues(flow.ZoneID).Inc()
+			p.notify()
+			p.mu.Unlock()
+			return job.done, nil
+		}
+
+		switch p.config.Overflow {
+		case OverflowDrop:
+			p.mu.Unlock()
+			flowQueueOverflows.WithLabelValues(flow.ZoneID, string(OverflowDrop)).Inc()
+			return nil, ErrExecutionDropped
+		case OverflowReject:
+			p.mu.Unlock()
+			flowQueueOverflows.WithLabelValues(flow.ZoneID, string(OverflowReject)).Inc()
+			return nil, ErrQueueFull
+		}
+		changed := p.changed
+		p.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Start runs the workers until the context is cancelled. Executions still
+// queued then fail with ErrPoolStopped.
+func (p *ExecutionPool) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	p.stopped = true
+	pending := p.pending
+	p.pending = nil
+	p.notify()
+	p.mu.Unlock()
+	for _, job := range pending {
+		flowQueueDepth.WithLabelValues(job.flow.ZoneID).Dec()
+		job.done <- ErrPoolStopped
+	}
+}
+
+func (p *ExecutionPool) work(ctx context.Context) {
+	for {
+		p.mu.Lock()
+		job := p.next()
+		if job == nil {
+			changed := p.changed
+			p.mu.Unlock()
+			select {
+			case <-changed:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		p.mu.Unlock()
+
+		err := p.runner.Execute(job.ctx, job.flow, job.input)
+
+		p.mu.Lock()
+		p.zoneRunning[job.flow.ZoneID]--
+		p.flowRunning[job.flow.ID]--
+		if p.zoneRunning[job.flow.ZoneID] == 0 {
+			delete(p.zoneRunning, job.flow.ZoneID)
+		}
+		if p.flowRunning[job.flow.ID] == 0 {
+			delete(p.flowRunning, job.flow.ID)
+		}
+		flowExecutionsRunning.WithLabelValues(job.flow.ZoneID).Dec()
+		p.notify()
+		p.mu.Unlock()
+		job.done <- err
+	}
+}
+
+// next removes and returns the oldest queued job whose zone and flow are
+// below their limits, marking it as running. Callers must hold mu.
+func (p *ExecutionPool) next() *poolJob {
+	for i, job := range p.pending {
+		if p.config.ZoneLimit > 0 && p.zoneRunning[job.flow.ZoneID] >= p.config.ZoneLimit {
+			continue
+		}
+		if p.config.FlowLimit > 0 && p.flowRunning[job.flow.ID] >= p.config.FlowLimit {
+			continue
+		}
+		p.pending = append(p.pending[:i], p.pending[i+1:]...)
+		p.zoneRunning[job.flow.ZoneID]++
+		p.flowRunning[job.flow.ID]++
+		flowQueueDepth.WithLabelValues(job.flow.ZoneID).Dec()
+		flowExecutionsRunning.WithLabelValues(job.flow.ZoneID).Inc()
+		p.notify()
+		return job
+	}
+	return nil
+}
+
+// notify wakes everyone waiting for the queue to change. Callers must hold mu.
+func (p *ExecutionPool) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
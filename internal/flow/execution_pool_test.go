@@ -0,0 +1,144 @@
+package flow
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/testutil"
+)
+
+// lockedRepository serializes the execution writes of concurrent runs
+type lockedRepository struct {
+	*testutil.MockFlowRepository
+	mu sync.Mutex
+}
+
+func (r *lockedRepository) CreateExecution(ctx context.Context, exec *domain.FlowExecution) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.MockFlowRepository.CreateExecution(ctx, exec)
+}
+
+func (r *lockedRepository) UpdateExecution(ctx context.Context, exec *domain.FlowExecution) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.MockFlowRepository.UpdateExecution(ctx, exec)
+}
+
+// gateHandler blocks every node until released and tracks how many run at once
+type gateHandler struct {
+	mu      sync.Mutex
+	running map[string]int
+	peak    map[string]int
+	release chan struct{}
+}
+
+func (h *gateHandler) Execute(ctx context.Context, node *domain.Node, input map[string]interface{}) (map[string]interface{}, error) {
+	zone, _ := input["zone_id"].(string)
+	h.mu.Lock()
+	h.running[zone]++
+	if h.running[zone] > h.peak[zone] {
+		h.peak[zone] = h.running[zone]
+	}
+	h.mu.Unlock()
+
+	<-h.release
+
+	h.mu.Lock()
+	h.running[zone]--
+	h.mu.Unlock()
+	return input, nil
+}
+
+func gatedFlow(id, zoneID string) *domain.Flow {
+	return &domain.Flow{
+		ID: id, ZoneID: zoneID, Enabled: true,
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "gate", Type: "gate"}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "gate"}},
+	}
+}
+
+func TestExecutionPool_ZoneLimit(t *testing.T) {
+	runner := domain.NewFlowRunner(&lockedRepository{MockFlowRepository: testutil.NewMockFlowRepository()})
+	gate := &gateHandler{running: map[string]int{}, peak: map[string]int{}, release: make(chan struct{})}
+	runner.RegisterHandler("gate", gate)
+
+	pool := NewExecutionPool(runner, ExecutionPoolConfig{Workers: 4, QueueSize: 10, ZoneLimit: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pool.Start(ctx)
+
+	var results []<-chan error
+	for _, f := range []*domain.Flow{gatedFlow("a1", "zone_a"), gatedFlow("a2", "zone_a"), gatedFlow("a3", "zone_a"), gatedFlow("b1", "zone_b")} {
+		done, err := pool.Submit(ctx, f, map[string]interface{}{"zone_id": f.ZoneID})
+		if err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+		results = append(results, done)
+	}
+
+	// zone_b runs alongside zone_a's single execution
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		gate.mu.Lock()
+		both := gate.running["zone_a"] == 1 && gate.running["zone_b"] == 1
+		gate.mu.Unlock()
+		if both {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected one execution per zone to be running")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	close(gate.release)
+	for _, done := range results {
+		if err := <-done; err != nil {
+			t.Errorf("Execution failed: %v", err)
+		}
+	}
+	if gate.peak["zone_a"] != 1 {
+		t.Errorf("Expected at most 1 concurrent execution in zone_a, got %d", gate.peak["zone_a"])
+	}
+}
+
+func TestExecutionPool_Overflow(t *testing.T) {
+	runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+	f := gatedFlow("flow_1", "zone_1")
+
+	// Without started workers the queue fills after one execution
+	for policy, want := range map[OverflowPolicy]error{OverflowReject: ErrQueueFull, OverflowDrop: ErrExecutionDropped} {
+		pool := NewExecutionPool(runner, ExecutionPoolConfig{QueueSize: 1, Overflow: policy})
+		if _, err := pool.Submit(context.Background(), f, nil); err != nil {
+			t.Fatalf("%s: first Submit failed: %v", policy, err)
+		}
+		if _, err := pool.Submit(context.Background(), f, nil); err != want {
+			t.Errorf("%s: expected %v, got %v", policy, want, err)
+		}
+	}
+
+	pool := NewExecutionPool(runner, ExecutionPoolConfig{QueueSize: 1, Overflow: OverflowDelay})
+	first, _ := pool.Submit(context.Background(), f, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Submit(ctx, f, nil); err != context.DeadlineExceeded {
+		t.Errorf("Expected a delayed submit to wait until its context expires, got %v", err)
+	}
+
+	// Starting the workers makes room for delayed submitters
+	runCtx, stop := context.WithCancel(context.Background())
+	go pool.Start(runCtx)
+	second, err := pool.Submit(context.Background(), &domain.Flow{ID: "flow_2", ZoneID: "zone_1", Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}}}, nil)
+	if err != nil {
+		t.Fatalf("Expected the delayed submit to be queued, got %v", err)
+	}
+	<-first
+	if err := <-second; err != nil {
+		t.Errorf("Execution failed: %v", err)
+	}
+	stop()
+}
@@ -0,0 +1,472 @@
+// Package expr evaluates the expressions used by flow conditions and
+// templates, e.g.
+//
+//	amount > 100 && currency == "USD" && created_at < now() - 1h
+//
+// Expressions support arithmetic (+ - * / %), comparisons, && || !, "in"
+// for lists, strings and maps, field access (a.b, a["b"], items[0]), list
+// literals, duration literals (500ms, 30s, 15m, 1h, 7d) and the functions in
+// functions.go. Identifiers are looked up in the variables passed to Eval;
+// missing fields evaluate to null instead of failing. Strings holding
+// RFC 3339 timestamps are treated as times when combined with a time or a
+// duration.
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Program is a compiled expression, safe for concurrent use
+type Program struct {
+	src  string
+	root node
+}
+
+var programs sync.Map // source -> *Program
+
+// Compile parses an expression
+func Compile(src string) (*Program, error) {
+	if p, ok := programs.Load(src); ok {
+		return p.(*Program), nil
+	}
+	root, err := parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	p := &Program{src: src, root: root}
+	programs.Store(src, p)
+	return p, nil
+}
+
+// Eval evaluates the program against the variables
+func (p *Program) Eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := p.root.eval(vars)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating %q: %w", p.src, err)
+	}
+	return v, nil
+}
+
+// EvalBool evaluates the program and requires a boolean result
+func (p *Program) EvalBool(vars map[string]interface{}) (bool, error) {
+	v, err := p.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression %q returned %s, not a boolean", p.src, typeName(v))
+	}
+	return b, nil
+}
+
+// Eval compiles and evaluates an expression
+func Eval(src string, vars map[string]interface{}) (interface{}, error) {
+	p, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return p.Eval(vars)
+}
+
+// EvalBool compiles and evaluates an expression that must return a boolean
+func EvalBool(src string, vars map[string]interface{}) (bool, error) {
+	p, err := Compile(src)
+	if err != nil {
+		return false, err
+	}
+	return p.EvalBool(vars)
+}
+
+// Format renders a value for substitution into a template
+func Format(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1e15 {
+			return fmt.Sprintf("%d", int64(val))
+		}
+		return fmt.Sprintf("%g", val)
+	case bool:
+		return fmt.Sprintf("%t", val)
+	case time.Time:
+		return val.Format(time.RFC3339)
+	case time.Duration:
+		return val.String()
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
+
+func (n *literal) eval(vars map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *ident) eval(vars map[string]interface{}) (interface{}, error) {
+	return normalize(vars[n.name]), nil
+}
+
+func (n *member) eval(vars map[string]interface{}) (interface{}, error) {
+	obj, err := n.object.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return field(obj, n.name), nil
+}
+
+func (n *index) eval(vars map[string]interface{}) (interface{}, error) {
+	obj, err := n.object.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	key, err := n.key.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch k := key.(type) {
+	case string:
+		return field(obj, k), nil
+	case float64:
+		items, ok := obj.([]interface{})
+		if !ok {
+			return nil, nil
+		}
+		i := int(k)
+		if i < 0 {
+			i += len(items)
+		}
+		if i < 0 || i >= len(items) {
+			return nil, nil
+		}
+		return normalize(items[i]), nil
+	}
+	return nil, fmt.Errorf("cannot index with %s", typeName(key))
+}
+
+func field(obj interface{}, name string) interface{} {
+	if m, ok := obj.(map[string]interface{}); ok {
+		return normalize(m[name])
+	}
+	return nil
+}
+
+func (n *call) eval(vars map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return functions[n.name](args)
+}
+
+func (n *list) eval(vars map[string]interface{}) (interface{}, error) {
+	items := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = v
+	}
+	return items, nil
+}
+
+func (n *unary) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		return !truthy(x), nil
+	}
+	switch v := x.(type) {
+	case float64:
+		return -v, nil
+	case time.Duration:
+		return -v, nil
+	}
+	return nil, fmt.Errorf("cannot negate %s", typeName(x))
+}
+
+func (n *binary) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	// && and || short-circuit
+	switch n.op {
+	case "&&":
+		if !truthy(left) {
+			return false, nil
+		}
+	case "||":
+		if truthy(left) {
+			return true, nil
+		}
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		return truthy(right), nil
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		c, err := compare(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	case "in":
+		return contains(right, left), nil
+	default:
+		return arithmetic(n.op, left, right)
+	}
+}
+
+// normalize converts Go numeric types to float64, the number type of JSON
+// decoded input
+func normalize(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint:
+		return float64(n)
+	case uint32:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	}
+	return v
+}
+
+func truthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case float64:
+		return val != 0
+	case string:
+		return val != ""
+	}
+	return true
+}
+
+// asTime returns v as a time, parsing timestamp strings
+func asTime(v interface{}) (time.Time, bool) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, val); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func isTime(v interface{}) bool {
+	_, ok := v.(time.Time)
+	return ok
+}
+
+func equal(a, b interface{}) bool {
+	if isTime(a) || isTime(b) {
+		ta, okA := asTime(a)
+		tb, okB := asTime(b)
+		return okA && okB && ta.Equal(tb)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func compare(a, b interface{}) (int, error) {
+	if isTime(a) || isTime(b) {
+		ta, okA := asTime(a)
+		tb, okB := asTime(b)
+		if okA && okB {
+			return ta.Compare(tb), nil
+		}
+	}
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			return cmp(x < y, x > y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case time.Duration:
+		if y, ok := b.(time.Duration); ok {
+			return cmp(x < y, x > y), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", typeName(a), typeName(b))
+}
+
+func cmp(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+func contains(collection, item interface{}) bool {
+	switch c := collection.(type) {
+	case []interface{}:
+		for _, v := range c {
+			if equal(normalize(v), item) {
+				return true
+			}
+		}
+	case string:
+		s, ok := item.(string)
+		return ok && strings.Contains(c, s)
+	case map[string]interface{}:
+		key, ok := item.(string)
+		if ok {
+			_, ok = c[key]
+		}
+		return ok
+	}
+	return false
+}
+
+func arithmetic(op string, a, b interface{}) (interface{}, error) {
+	// Times and durations
+	if d, ok := b.(time.Duration); ok && (op == "+" || op == "-") {
+		if t, ok := asTime(a); ok {
+			if op == "-" {
+				d = -d
+			}
+			return t.Add(d), nil
+		}
+	}
+	if d, ok := a.(time.Duration); ok && op == "+" {
+		if t, ok := asTime(b); ok {
+			return t.Add(d), nil
+		}
+	}
+	if op == "-" && (isTime(a) || isTime(b)) {
+		ta, okA := asTime(a)
+		tb, okB := asTime(b)
+		if okA && okB {
+			return ta.Sub(tb), nil
+		}
+	}
+
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			switch op {
+			case "+":
+				return x + y, nil
+			case "-":
+				return x - y, nil
+			case "*":
+				return x * y, nil
+			case "/", "%":
+				if y == 0 {
+					return nil, fmt.Errorf("division by zero")
+				}
+				if op == "/" {
+					return x / y, nil
+				}
+				return math.Mod(x, y), nil
+			}
+		}
+	case time.Duration:
+		switch y := b.(type) {
+		case time.Duration:
+			switch op {
+			case "+":
+				return x + y, nil
+			case "-":
+				return x - y, nil
+			}
+		case float64:
+			switch op {
+			case "*":
+				return time.Duration(float64(x) * y), nil
+			case "/":
+				if y == 0 {
+					return nil, fmt.Errorf("division by zero")
+				}
+				return time.Duration(float64(x) / y), nil
+			}
+		}
+	case string:
+		if op == "+" {
+			return x + Format(b), nil
+		}
+	}
+	if s, ok := b.(string); ok && op == "+" && a != nil {
+		return Format(a) + s, nil
+	}
+	return nil, fmt.Errorf("cannot apply %s to %s and %s", op, typeName(a), typeName(b))
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case time.Time:
+		return "timestamp"
+	case time.Duration:
+		return "duration"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
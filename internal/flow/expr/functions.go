@@ -0,0 +1,156 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// functions are the functions callable from expressions
+var functions = map[string]func(args []interface{}) (interface{}, error){
+	// now() returns the current time in UTC
+	"now": func(args []interface{}) (interface{}, error) {
+		if err := arity("now", args, 0); err != nil {
+			return nil, err
+		}
+		return time.Now().UTC(), nil
+	},
+	// timestamp("2024-01-31T10:00:00Z") parses an RFC 3339 time or a date
+	"timestamp": func(args []interface{}) (interface{}, error) {
+		if err := arity("timestamp", args, 1); err != nil {
+			return nil, err
+		}
+		t, ok := asTime(args[0])
+		if !ok {
+			return nil, fmt.Errorf("timestamp: cannot parse %v", args[0])
+		}
+		return t, nil
+	},
+	// duration("1h30m") parses a Go duration string
+	"duration": func(args []interface{}) (interface{}, error) {
+		s, err := stringArg("duration", args)
+		if err != nil {
+			return nil, err
+		}
+		return time.ParseDuration(s)
+	},
+	"len": func(args []interface{}) (interface{}, error) {
+		if err := arity("len", args, 1); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case string:
+			return float64(len(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		case nil:
+			return float64(0), nil
+		}
+		return nil, fmt.Errorf("len: unsupported %s", typeName(args[0]))
+	},
+	"lower":      stringFunc("lower", strings.ToLower),
+	"upper":      stringFunc("upper", strings.ToUpper),
+	"trim":       stringFunc("trim", strings.TrimSpace),
+	"contains":   stringPredicate("contains", strings.Contains),
+	"startsWith": stringPredicate("startsWith", strings.HasPrefix),
+	"endsWith":   stringPredicate("endsWith", strings.HasSuffix),
+	"matches": func(args []interface{}) (interface{}, error) {
+		if err := arity("matches", args, 2); err != nil {
+			return nil, err
+		}
+		pattern, ok := args[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("matches: pattern must be a string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("matches: %w", err)
+		}
+		return re.MatchString(Format(args[0])), nil
+	},
+	"abs":   numberFunc("abs", math.Abs),
+	"round": numberFunc("round", math.Round),
+	"floor": numberFunc("floor", math.Floor),
+	"ceil":  numberFunc("ceil", math.Ceil),
+	"string": func(args []interface{}) (interface{}, error) {
+		if err := arity("string", args, 1); err != nil {
+			return nil, err
+		}
+		return Format(args[0]), nil
+	},
+	"number": func(args []interface{}) (interface{}, error) {
+		if err := arity("number", args, 1); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case float64:
+			return v, nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("number: cannot parse %q", v)
+			}
+			return f, nil
+		case bool:
+			if v {
+				return float64(1), nil
+			}
+			return float64(0), nil
+		}
+		return nil, fmt.Errorf("number: unsupported %s", typeName(args[0]))
+	},
+}
+
+func arity(name string, args []interface{}, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("%s expects %d argument(s), got %d", name, n, len(args))
+	}
+	return nil
+}
+
+func stringArg(name string, args []interface{}) (string, error) {
+	if err := arity(name, args, 1); err != nil {
+		return "", err
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return "", fmt.Errorf("%s expects a string, got %s", name, typeName(args[0]))
+	}
+	return s, nil
+}
+
+func stringFunc(name string, fn func(string) string) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if err := arity(name, args, 1); err != nil {
+			return nil, err
+		}
+		return fn(Format(args[0])), nil
+	}
+}
+
+func stringPredicate(name string, fn func(s, sub string) bool) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if err := arity(name, args, 2); err != nil {
+			return nil, err
+		}
+		return fn(Format(args[0]), Format(args[1])), nil
+	}
+}
+
+func numberFunc(name string, fn func(float64) float64) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if err := arity(name, args, 1); err != nil {
+			return nil, err
+		}
+		x, ok := args[0].(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s expects a number, got %s", name, typeName(args[0]))
+		}
+		return fn(x), nil
+	}
+}
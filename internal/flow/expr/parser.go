@@ -0,0 +1,237 @@
+package expr
+
+import (
+	"fmt"
+)
+
+// node is a parsed expression
+type node interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+type (
+	literal struct{ value interface{} }
+	ident   struct{ name string }
+	member  struct {
+		object node
+		name   string
+	}
+	index struct {
+		object, key node
+	}
+	call struct {
+		name string
+		args []node
+	}
+	list  struct{ items []node }
+	unary struct {
+		op string
+		x  node
+	}
+	binary struct {
+		op          string
+		left, right node
+	}
+)
+
+// binaryPrecedence orders the binary operators from loosest to tightest
+var binaryPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">=", "in"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func parse(src string) (node, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isOp reports whether the next token is one of the operators; "in" is
+// lexed as an identifier
+func (p *parser) isOp(ops ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokOp && !(tok.kind == tokIdent && tok.text == "in") {
+		return false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		tok := p.peek()
+		if tok.kind == tokEOF {
+			return fmt.Errorf("expected %q at end of expression", op)
+		}
+		return fmt.Errorf("expected %q at %d, got %q", op, tok.pos, tok.text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(binaryPrecedence) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(binaryPrecedence[level]...) {
+		op := p.next().text
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("!", "-") {
+		op := p.next().text
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: op, x: x}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.isOp("."):
+			p.next()
+			tok := p.next()
+			if tok.kind != tokIdent {
+				return nil, fmt.Errorf("expected field name at %d", tok.pos)
+			}
+			n = &member{object: n, name: tok.text}
+		case p.isOp("["):
+			p.next()
+			key, err := p.parseBinary(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = &index{object: n, key: key}
+		case p.isOp("("):
+			id, ok := n.(*ident)
+			if !ok {
+				return nil, fmt.Errorf("only functions can be called, at %d", p.peek().pos)
+			}
+			p.next()
+			args, err := p.parseList(")")
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := functions[id.name]; !ok {
+				return nil, fmt.Errorf("unknown function %s", id.name)
+			}
+			n = &call{name: id.name, args: args}
+		default:
+			return n, nil
+		}
+	}
+}
+
+// parseList parses comma separated expressions up to the closing token
+func (p *parser) parseList(closing string) ([]node, error) {
+	var items []node
+	for !p.isOp(closing) {
+		item, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if !p.isOp(",") {
+			break
+		}
+		p.next()
+	}
+	return items, p.expect(closing)
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		return &literal{value: tok.num}, nil
+	case tokString:
+		return &literal{value: tok.text}, nil
+	case tokDuration:
+		return &literal{value: tok.dur}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		case "null", "nil":
+			return &literal{value: nil}, nil
+		}
+		return &ident{name: tok.text}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			n, err := p.parseBinary(0)
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &list{items: items}, nil
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+}
@@ -2,6 +2,8 @@ package infrastructure
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 
 	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
 	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
@@ -17,7 +19,28 @@ func NewKafkaEventRetriggerer(producer *messaging.KafkaProducer) *KafkaEventRetr
 	}
 }
 
+// replayMessage is a replayed event as republished to Kafka. It keeps the
+// ID of the event it replays, so consumers that process each event once
+// recognize replays of events they have processed.
+type replayMessage struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	ZoneID   string          `json:"zone_id"`
+	ReplayOf string          `json:"replay_of,omitempty"`
+	Data     json.RawMessage `json:"data"`
+}
+
 func (r *KafkaEventRetriggerer) RetriggerEvent(ctx context.Context, event *domain.Event) error {
 	// Re-publish the event to Kafka to trigger standard flow processing
-	return r.producer.Publish(ctx, event.ID, event.Data)
+	value, err := json.Marshal(replayMessage{
+		ID:       event.ID,
+		Type:     event.Type,
+		ZoneID:   event.ZoneID,
+		ReplayOf: event.Meta[domain.MetaReplayOf],
+		Data:     event.Data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode replayed event: %w", err)
+	}
+	return r.producer.Publish(ctx, event.ID, value)
 }
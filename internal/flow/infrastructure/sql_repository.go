@@ -4,8 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
 )
 
@@ -29,8 +33,8 @@ func (r *SQLRepository) CreateFlow(ctx context.Context, flow *domain.Flow) error
 	defer tx.Rollback()
 
 	_, err = tx.ExecContext(ctx,
-		"INSERT INTO flows (id, org_id, zone_id, name, description, enabled, nodes, edges, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
-		flow.ID, flow.OrgID, flow.ZoneID, flow.Name, flow.Description, flow.Enabled, nodesJSON, edgesJSON, flow.Version)
+		"INSERT INTO flows (id, org_id, zone_id, name, description, enabled, nodes, edges, version, max_duration_seconds, on_timeout_node_id, retry_policy) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		flow.ID, flow.OrgID, flow.ZoneID, flow.Name, flow.Description, flow.Enabled, nodesJSON, edgesJSON, flow.Version, flow.MaxDuration, flow.OnTimeoutNodeID, retryPolicyJSON(flow.RetryPolicy))
 	if err != nil {
 		return err
 	}
@@ -47,22 +51,37 @@ func (r *SQLRepository) CreateFlow(ctx context.Context, flow *domain.Flow) error
 }
 
 func (r *SQLRepository) GetFlow(ctx context.Context, id string) (*domain.Flow, error) {
-	row := r.db.QueryRowContext(ctx, "SELECT id, org_id, zone_id, name, description, enabled, nodes, edges, version, created_at, updated_at FROM flows WHERE id = $1", id)
+	row := r.db.QueryRowContext(ctx, "SELECT id, org_id, zone_id, name, description, enabled, nodes, edges, version, max_duration_seconds, on_timeout_node_id, retry_policy, created_at, updated_at FROM flows WHERE id = $1 AND deleted_at IS NULL", id)
 
 	var flow domain.Flow
-	var nodesJS, edgesJS []byte
-	err := row.Scan(&flow.ID, &flow.OrgID, &flow.ZoneID, &flow.Name, &flow.Description, &flow.Enabled, &nodesJS, &edgesJS, &flow.Version, &flow.CreatedAt, &flow.UpdatedAt)
+	var nodesJS, edgesJS, retryJS []byte
+	err := row.Scan(&flow.ID, &flow.OrgID, &flow.ZoneID, &flow.Name, &flow.Description, &flow.Enabled, &nodesJS, &edgesJS, &flow.Version, &flow.MaxDuration, &flow.OnTimeoutNodeID, &retryJS, &flow.CreatedAt, &flow.UpdatedAt)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrFlowNotFound
+		}
 		return nil, err
 	}
 
 	json.Unmarshal(nodesJS, &flow.Nodes)
 	json.Unmarshal(edgesJS, &flow.Edges)
+	if len(retryJS) > 0 {
+		json.Unmarshal(retryJS, &flow.RetryPolicy)
+	}
 	return &flow, nil
 }
 
+func (r *SQLRepository) GetFlowZoneID(ctx context.Context, id string) (string, error) {
+	var zoneID string
+	err := r.db.QueryRowContext(ctx, "SELECT zone_id FROM flows WHERE id = $1", id).Scan(&zoneID)
+	if err == sql.ErrNoRows {
+		return "", domain.ErrFlowNotFound
+	}
+	return zoneID, err
+}
+
 func (r *SQLRepository) ListFlows(ctx context.Context, zoneID string) ([]*domain.Flow, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT id, org_id, zone_id, name, description, enabled, nodes, edges, version, created_at, updated_at FROM flows WHERE zone_id = $1 AND enabled = TRUE", zoneID)
+	rows, err := r.db.QueryContext(ctx, "SELECT id, org_id, zone_id, name, description, enabled, nodes, edges, version, max_duration_seconds, on_timeout_node_id, retry_policy, created_at, updated_at FROM flows WHERE zone_id = $1 AND enabled = TRUE AND deleted_at IS NULL", zoneID)
 	if err != nil {
 		return nil, err
 	}
@@ -71,12 +90,15 @@ func (r *SQLRepository) ListFlows(ctx context.Context, zoneID string) ([]*domain
 	var flows []*domain.Flow
 	for rows.Next() {
 		var f domain.Flow
-		var nodesJS, edgesJS []byte
-		if err := rows.Scan(&f.ID, &f.OrgID, &f.ZoneID, &f.Name, &f.Description, &f.Enabled, &nodesJS, &edgesJS, &f.Version, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		var nodesJS, edgesJS, retryJS []byte
+		if err := rows.Scan(&f.ID, &f.OrgID, &f.ZoneID, &f.Name, &f.Description, &f.Enabled, &nodesJS, &edgesJS, &f.Version, &f.MaxDuration, &f.OnTimeoutNodeID, &retryJS, &f.CreatedAt, &f.UpdatedAt); err != nil {
 			return nil, err
 		}
 		json.Unmarshal(nodesJS, &f.Nodes)
 		json.Unmarshal(edgesJS, &f.Edges)
+		if len(retryJS) > 0 {
+			json.Unmarshal(retryJS, &f.RetryPolicy)
+		}
 		flows = append(flows, &f)
 	}
 	return flows, nil
@@ -104,8 +126,8 @@ func (r *SQLRepository) UpdateFlow(ctx context.Context, flow *domain.Flow) error
 
 	// Update flow
 	_, err = tx.ExecContext(ctx,
-		"UPDATE flows SET name = $1, description = $2, enabled = $3, nodes = $4, edges = $5, version = $6, updated_at = CURRENT_TIMESTAMP WHERE id = $7",
-		flow.Name, flow.Description, flow.Enabled, nodesJSON, edgesJSON, newVersion, flow.ID)
+		"UPDATE flows SET name = $1, description = $2, enabled = $3, nodes = $4, edges = $5, version = $6, max_duration_seconds = $7, on_timeout_node_id = $8, retry_policy = $9, updated_at = CURRENT_TIMESTAMP WHERE id = $10",
+		flow.Name, flow.Description, flow.Enabled, nodesJSON, edgesJSON, newVersion, flow.MaxDuration, flow.OnTimeoutNodeID, retryPolicyJSON(flow.RetryPolicy), flow.ID)
 	if err != nil {
 		return err
 	}
@@ -121,6 +143,69 @@ func (r *SQLRepository) UpdateFlow(ctx context.Context, flow *domain.Flow) error
 	return tx.Commit()
 }
 
+// retryPolicyJSON encodes a flow's retry policy, storing NULL when it has none
+func retryPolicyJSON(p *domain.RetryPolicy) interface{} {
+	if p == nil {
+		return nil
+	}
+	b, _ := json.Marshal(p)
+	return b
+}
+
+// DeleteFlow soft-deletes a flow. The row is kept until PurgeDeletedFlows
+// removes it, so it can still be restored in the meantime.
+func (r *SQLRepository) DeleteFlow(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE flows SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrFlowNotFound
+	}
+	return nil
+}
+
+// RestoreFlow clears the soft-delete marker of a flow that has not been purged yet.
+func (r *SQLRepository) RestoreFlow(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE flows SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrFlowNotFound
+	}
+	return nil
+}
+
+// PurgeDeletedFlows permanently removes flows soft-deleted before the given
+// time, together with their versions and executions.
+func (r *SQLRepository) PurgeDeletedFlows(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	const purgeable = "SELECT id FROM flows WHERE deleted_at IS NOT NULL AND deleted_at < $1"
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM flow_executions WHERE flow_id IN ("+purgeable+")", deletedBefore); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM flow_versions WHERE flow_id IN ("+purgeable+")", deletedBefore); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM flows WHERE deleted_at IS NOT NULL AND deleted_at < $1", deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	purged, _ := res.RowsAffected()
+
+	return purged, tx.Commit()
+}
+
 func (r *SQLRepository) CreateExecution(ctx context.Context, exec *domain.FlowExecution) error {
 	stepsJSON, _ := json.Marshal(exec.Steps)
 	stepsStr := string(stepsJSON)
@@ -162,26 +247,28 @@ func (r *SQLRepository) UpdateExecution(ctx context.Context, exec *domain.FlowEx
 		metadataStr = "{}"
 	}
 
+	var checkpointedAt sql.NullTime
+	if !exec.CheckpointedAt.IsZero() {
+		checkpointedAt = sql.NullTime{Time: exec.CheckpointedAt, Valid: true}
+	}
+
 	_, err := r.db.ExecContext(ctx,
-		"UPDATE flow_executions SET status = $1, current_node_id = $2, output = $3, steps = $4, metadata = $5, ended_at = $6 WHERE id = $7",
-		exec.Status, exec.CurrentNodeID, outputStr, stepsStr, metadataStr, exec.EndedAt, exec.ID)
+		"UPDATE flow_executions SET status = $1, current_node_id = $2, output = $3, steps = $4, metadata = $5, ended_at = $6, checkpointed_at = $7 WHERE id = $8",
+		exec.Status, exec.CurrentNodeID, outputStr, stepsStr, metadataStr, exec.EndedAt, checkpointedAt, exec.ID)
 	return err
 }
 
-func (r *SQLRepository) GetExecution(ctx context.Context, id string) (*domain.FlowExecution, error) {
-	row := r.db.QueryRowContext(ctx, "SELECT id, flow_id, flow_version, trigger_id, status, current_node_id, input, output, steps, metadata, started_at, ended_at FROM flow_executions WHERE id = $1", id)
+const executionColumns = "id, flow_id, flow_version, trigger_id, status, current_node_id, input, output, steps, metadata, started_at, ended_at, checkpointed_at"
 
+func scanExecution(scan func(dest ...interface{}) error) (*domain.FlowExecution, error) {
 	var exec domain.FlowExecution
 	var stepsJS []byte
 	var triggerID sql.NullString
-	var endedAt sql.NullTime
+	var endedAt, checkpointedAt sql.NullTime
 	var version sql.NullInt64
 
-	err := row.Scan(&exec.ID, &exec.FlowID, &version, &triggerID, &exec.Status, &exec.CurrentNodeID, &exec.Input, &exec.Output, &stepsJS, &exec.Metadata, &exec.StartedAt, &endedAt)
+	err := scan(&exec.ID, &exec.FlowID, &version, &triggerID, &exec.Status, &exec.CurrentNodeID, &exec.Input, &exec.Output, &stepsJS, &exec.Metadata, &exec.StartedAt, &endedAt, &checkpointedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, domain.ErrExecutionNotFound
-		}
 		return nil, err
 	}
 
@@ -192,11 +279,27 @@ func (r *SQLRepository) GetExecution(ctx context.Context, id string) (*domain.Fl
 	if endedAt.Valid {
 		exec.EndedAt = endedAt.Time
 	}
+	if checkpointedAt.Valid {
+		exec.CheckpointedAt = checkpointedAt.Time
+	}
 
 	json.Unmarshal(stepsJS, &exec.Steps)
 	return &exec, nil
 }
 
+func (r *SQLRepository) GetExecution(ctx context.Context, id string) (*domain.FlowExecution, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+executionColumns+" FROM flow_executions WHERE id = $1", id)
+
+	exec, err := scanExecution(row.Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrExecutionNotFound
+		}
+		return nil, err
+	}
+	return exec, nil
+}
+
 func (r *SQLRepository) ListExecutions(ctx context.Context, flowID string, limit, offset int) ([]*domain.FlowExecution, error) {
 	// Debug: check total count and flow-specific count
 	var totalCount, flowCount int
@@ -204,9 +307,32 @@ func (r *SQLRepository) ListExecutions(ctx context.Context, flowID string, limit
 	r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM flow_executions WHERE flow_id = $1", flowID).Scan(&flowCount)
 	log.Printf("ListExecutions DEBUG: total=%d, for_flow=%d, flowID=%s", totalCount, flowCount, flowID)
 
-	rows, err := r.db.QueryContext(ctx,
-		"SELECT id, flow_id, flow_version, trigger_id, status, current_node_id, input, output, steps, metadata, started_at, ended_at FROM flow_executions WHERE flow_id = $1 ORDER BY started_at DESC LIMIT $2 OFFSET $3",
+	return r.queryExecutions(ctx,
+		"SELECT "+executionColumns+" FROM flow_executions WHERE flow_id = $1 ORDER BY started_at DESC LIMIT $2 OFFSET $3",
 		flowID, limit, offset)
+}
+
+func (r *SQLRepository) ClaimExecution(ctx context.Context, id string, checkpointedAt, claimedAt time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE flow_executions SET checkpointed_at = $1 WHERE id = $2 AND status = $3 AND COALESCE(checkpointed_at, started_at) = $4",
+		claimedAt, id, domain.ExecutionRunning, checkpointedAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ListIncompleteExecutions returns running executions whose last checkpoint
+// (or start, if never checkpointed) is older than checkpointedBefore
+func (r *SQLRepository) ListIncompleteExecutions(ctx context.Context, checkpointedBefore time.Time) ([]*domain.FlowExecution, error) {
+	return r.queryExecutions(ctx,
+		"SELECT "+executionColumns+" FROM flow_executions WHERE status = $1 AND COALESCE(checkpointed_at, started_at) < $2 ORDER BY started_at",
+		domain.ExecutionRunning, checkpointedBefore)
+}
+
+func (r *SQLRepository) queryExecutions(ctx context.Context, query string, args ...interface{}) ([]*domain.FlowExecution, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -214,28 +340,13 @@ func (r *SQLRepository) ListExecutions(ctx context.Context, flowID string, limit
 
 	var executions []*domain.FlowExecution
 	for rows.Next() {
-		var exec domain.FlowExecution
-		var stepsJS []byte
-		var triggerID sql.NullString
-		var endedAt sql.NullTime
-		var version sql.NullInt64
-
-		if err := rows.Scan(&exec.ID, &exec.FlowID, &version, &triggerID, &exec.Status, &exec.CurrentNodeID, &exec.Input, &exec.Output, &stepsJS, &exec.Metadata, &exec.StartedAt, &endedAt); err != nil {
+		exec, err := scanExecution(rows.Scan)
+		if err != nil {
 			return nil, err
 		}
-
-		exec.FlowVersion = int(version.Int64)
-		if triggerID.Valid {
-			exec.TriggerID = triggerID.String
-		}
-		if endedAt.Valid {
-			exec.EndedAt = endedAt.Time
-		}
-
-		json.Unmarshal(stepsJS, &exec.Steps)
-		executions = append(executions, &exec)
+		executions = append(executions, exec)
 	}
-	return executions, nil
+	return executions, rows.Err()
 }
 
 func (r *SQLRepository) BulkUpdateFlowsEnabled(ctx context.Context, ids []string, enabled bool) error {
@@ -292,6 +403,71 @@ func (r *SQLRepository) GetEventByID(ctx context.Context, id string) (*domain.Ev
 	return &e, nil
 }
 
+// QueryEvents returns events matching the filter, newest first, using keyset
+// pagination on (created_at, id) so deep pages stay cheap.
+func (r *SQLRepository) QueryEvents(ctx context.Context, filter domain.EventFilter) (*domain.EventPage, error) {
+	conditions := []string{"zone_id = $1"}
+	args := []interface{}{filter.ZoneID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(filter.Types) > 0 {
+		conditions = append(conditions, "type = ANY("+arg(pq.Array(filter.Types))+")")
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(filter.To))
+	}
+	for path, value := range filter.DataFilters {
+		conditions = append(conditions, "data #>> "+arg(pq.Array(strings.Split(path, ".")))+" = "+arg(value))
+	}
+	if filter.Cursor != "" {
+		createdAt, id, err := domain.DecodeEventCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "(created_at, id) < ("+arg(createdAt)+", "+arg(id)+")")
+	}
+
+	// Fetch one extra row to know whether another page exists
+	query := "SELECT id, type, zone_id, org_id, data, meta, idempotency_key, created_at FROM events WHERE " +
+		strings.Join(conditions, " AND ") +
+		" ORDER BY created_at DESC, id DESC LIMIT " + arg(filter.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &domain.EventPage{Events: make([]*domain.Event, 0, filter.Limit)}
+	for rows.Next() {
+		var e domain.Event
+		var orgID, idempotencyKey sql.NullString
+		var metaJSON []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.ZoneID, &orgID, &e.Data, &metaJSON, &idempotencyKey, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.OrgID = orgID.String
+		e.IdempotencyKey = idempotencyKey.String
+		json.Unmarshal(metaJSON, &e.Meta)
+		page.Events = append(page.Events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Events) > filter.Limit {
+		page.Events = page.Events[:filter.Limit]
+		page.NextCursor = domain.EncodeEventCursor(page.Events[len(page.Events)-1])
+	}
+	return page, nil
+}
+
 // Flow Versioning methods
 
 func (r *SQLRepository) CreateFlowVersion(ctx context.Context, version *domain.FlowVersion) error {
@@ -332,9 +508,536 @@ func (r *SQLRepository) GetFlowVersion(ctx context.Context, flowID string, versi
 	var nodesJS, edgesJS []byte
 	err := row.Scan(&v.ID, &v.FlowID, &v.Version, &nodesJS, &edgesJS, &v.CreatedAt)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrVersionNotFound
+		}
 		return nil, err
 	}
 	json.Unmarshal(nodesJS, &v.Nodes)
 	json.Unmarshal(edgesJS, &v.Edges)
 	return &v, nil
 }
+
+// Debug session persistence methods
+
+func (r *SQLRepository) SaveDebugSession(ctx context.Context, session *domain.DebugSession) error {
+	breakpointsJSON, _ := json.Marshal(session.Breakpoints)
+	metadataJSON, _ := json.Marshal(session.Metadata)
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO debug_sessions (id, flow_id, zone_id, level, active, breakpoints, metadata, start_time, created_at, last_activity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO UPDATE SET active = EXCLUDED.active, breakpoints = EXCLUDED.breakpoints, metadata = EXCLUDED.metadata, last_activity = EXCLUDED.last_activity`,
+		session.ID, session.FlowID, session.ZoneID, session.Level, session.Active, breakpointsJSON, metadataJSON, session.StartTime, session.CreatedAt, session.LastActivity)
+	return err
+}
+
+func (r *SQLRepository) GetDebugSession(ctx context.Context, id string) (*domain.DebugSession, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT id, flow_id, zone_id, level, active, breakpoints, metadata, start_time, created_at, last_activity FROM debug_sessions WHERE id = $1", id)
+
+	var s domain.DebugSession
+	var breakpointsJS, metadataJS []byte
+	if err := row.Scan(&s.ID, &s.FlowID, &s.ZoneID, &s.Level, &s.Active, &breakpointsJS, &metadataJS, &s.StartTime, &s.CreatedAt, &s.LastActivity); err != nil {
+		return nil, err
+	}
+	json.Unmarshal(breakpointsJS, &s.Breakpoints)
+	json.Unmarshal(metadataJS, &s.Metadata)
+	return &s, nil
+}
+
+func (r *SQLRepository) CreateDebugEvent(ctx context.Context, sessionID string, event *domain.DebugEvent) error {
+	dataJSON, _ := json.Marshal(event.Data)
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO debug_events (id, session_id, execution_id, flow_id, node_id, level, type, message, data, timestamp) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		event.ID, sessionID, event.ExecutionID, event.FlowID, event.NodeID, event.Level, event.Type, event.Message, dataJSON, event.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, "UPDATE debug_sessions SET last_activity = $1 WHERE id = $2", event.Timestamp, sessionID)
+	return err
+}
+
+func (r *SQLRepository) GetDebugEvents(ctx context.Context, sessionID string, since *time.Time) ([]domain.DebugEvent, error) {
+	query := "SELECT id, execution_id, flow_id, node_id, level, type, message, data, timestamp FROM debug_events WHERE session_id = $1"
+	args := []interface{}{sessionID}
+	if since != nil {
+		query += " AND timestamp >= $2"
+		args = append(args, *since)
+	}
+	query += " ORDER BY timestamp ASC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []domain.DebugEvent
+	for rows.Next() {
+		var e domain.DebugEvent
+		var dataJS []byte
+		if err := rows.Scan(&e.ID, &e.ExecutionID, &e.FlowID, &e.NodeID, &e.Level, &e.Type, &e.Message, &dataJS, &e.Timestamp); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(dataJS, &e.Data)
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func (r *SQLRepository) DeleteInactiveDebugSessions(ctx context.Context, inactiveSince time.Time) (int64, error) {
+	// debug_events rows are removed by ON DELETE CASCADE
+	res, err := r.db.ExecContext(ctx, "DELETE FROM debug_sessions WHERE last_activity < $1", inactiveSince)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Replay schedule methods
+
+const replayScheduleColumns = "id, zone_id, source_zone_id, name, event_types, lookback_seconds, interval_seconds, delay_ms, enabled, next_run_at, last_run_at, last_job_id, created_at, updated_at"
+
+func scanReplaySchedule(scan func(dest ...interface{}) error) (*domain.ReplaySchedule, error) {
+	var s domain.ReplaySchedule
+	var lastRunAt sql.NullTime
+	var lastJobID sql.NullString
+	err := scan(&s.ID, &s.ZoneID, &s.SourceZoneID, &s.Name, pq.Array(&s.EventTypes), &s.LookbackSeconds, &s.IntervalSeconds, &s.DelayMs,
+		&s.Enabled, &s.NextRunAt, &lastRunAt, &lastJobID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastRunAt.Valid {
+		s.LastRunAt = &lastRunAt.Time
+	}
+	s.LastJobID = lastJobID.String
+	return &s, nil
+}
+
+func (r *SQLRepository) CreateReplaySchedule(ctx context.Context, s *domain.ReplaySchedule) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO replay_schedules ("+replayScheduleColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)",
+		s.ID, s.ZoneID, s.SourceZoneID, s.Name, pq.Array(s.EventTypes), s.LookbackSeconds, s.IntervalSeconds, s.DelayMs,
+		s.Enabled, s.NextRunAt, s.LastRunAt, s.LastJobID, s.CreatedAt, s.UpdatedAt)
+	return err
+}
+
+func (r *SQLRepository) GetReplaySchedule(ctx context.Context, id string) (*domain.ReplaySchedule, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+replayScheduleColumns+" FROM replay_schedules WHERE id = $1", id)
+	s, err := scanReplaySchedule(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrReplayScheduleNotFound
+	}
+	return s, err
+}
+
+func (r *SQLRepository) ListReplaySchedules(ctx context.Context, zoneID string) ([]*domain.ReplaySchedule, error) {
+	return r.queryReplaySchedules(ctx, "SELECT "+replayScheduleColumns+" FROM replay_schedules WHERE zone_id = $1 ORDER BY created_at DESC", zoneID)
+}
+
+func (r *SQLRepository) ListDueReplaySchedules(ctx context.Context, now time.Time) ([]*domain.ReplaySchedule, error) {
+	return r.queryReplaySchedules(ctx, "SELECT "+replayScheduleColumns+" FROM replay_schedules WHERE enabled = TRUE AND next_run_at <= $1 ORDER BY next_run_at", now)
+}
+
+func (r *SQLRepository) queryReplaySchedules(ctx context.Context, query string, args ...interface{}) ([]*domain.ReplaySchedule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*domain.ReplaySchedule
+	for rows.Next() {
+		s, err := scanReplaySchedule(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func (r *SQLRepository) UpdateReplaySchedule(ctx context.Context, s *domain.ReplaySchedule) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE replay_schedules SET source_zone_id = $1, name = $2, event_types = $3, lookback_seconds = $4, interval_seconds = $5,
+		delay_ms = $6, enabled = $7, next_run_at = $8, last_run_at = $9, last_job_id = $10, updated_at = $11 WHERE id = $12`,
+		s.SourceZoneID, s.Name, pq.Array(s.EventTypes), s.LookbackSeconds, s.IntervalSeconds,
+		s.DelayMs, s.Enabled, s.NextRunAt, s.LastRunAt, s.LastJobID, s.UpdatedAt, s.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrReplayScheduleNotFound
+	}
+	return nil
+}
+
+func (r *SQLRepository) DeleteReplaySchedule(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM replay_schedules WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrReplayScheduleNotFound
+	}
+	return nil
+}
+
+func (r *SQLRepository) CreateWebhookHook(ctx context.Context, hook *domain.WebhookHook) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO webhook_hooks (id, zone_id, name, event_type, secret, enabled, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		hook.ID, hook.ZoneID, hook.Name, hook.EventType, hook.Secret, hook.Enabled, hook.CreatedAt)
+	return err
+}
+
+func (r *SQLRepository) GetWebhookHook(ctx context.Context, id string) (*domain.WebhookHook, error) {
+	var hook domain.WebhookHook
+	err := r.db.QueryRowContext(ctx,
+		"SELECT id, zone_id, name, event_type, secret, enabled, created_at FROM webhook_hooks WHERE id = $1", id).
+		Scan(&hook.ID, &hook.ZoneID, &hook.Name, &hook.EventType, &hook.Secret, &hook.Enabled, &hook.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrWebhookHookNotFound
+		}
+		return nil, err
+	}
+	return &hook, nil
+}
+
+func (r *SQLRepository) ListWebhookHooks(ctx context.Context, zoneID string) ([]*domain.WebhookHook, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, zone_id, name, event_type, enabled, created_at FROM webhook_hooks WHERE zone_id = $1 ORDER BY created_at DESC", zoneID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []*domain.WebhookHook
+	for rows.Next() {
+		var hook domain.WebhookHook
+		if err := rows.Scan(&hook.ID, &hook.ZoneID, &hook.Name, &hook.EventType, &hook.Enabled, &hook.CreatedAt); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, &hook)
+	}
+	return hooks, rows.Err()
+}
+
+func (r *SQLRepository) DeleteWebhookHook(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM webhook_hooks WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrWebhookHookNotFound
+	}
+	return nil
+}
+
+// SaveZoneSecret creates the secret or replaces the existing one
+func (r *SQLRepository) SaveZoneSecret(ctx context.Context, secret *domain.ZoneSecret) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO zone_secrets (zone_id, name, ciphertext, created_at, updated_at) VALUES ($1, $2, $3, $4, $5) "+
+			"ON CONFLICT (zone_id, name) DO UPDATE SET ciphertext = EXCLUDED.ciphertext, updated_at = EXCLUDED.updated_at",
+		secret.ZoneID, secret.Name, secret.Ciphertext, secret.CreatedAt, secret.UpdatedAt)
+	return err
+}
+
+func (r *SQLRepository) GetZoneSecret(ctx context.Context, zoneID, name string) (*domain.ZoneSecret, error) {
+	var secret domain.ZoneSecret
+	err := r.db.QueryRowContext(ctx,
+		"SELECT zone_id, name, ciphertext, created_at, updated_at FROM zone_secrets WHERE zone_id = $1 AND name = $2", zoneID, name).
+		Scan(&secret.ZoneID, &secret.Name, &secret.Ciphertext, &secret.CreatedAt, &secret.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrZoneSecretNotFound
+		}
+		return nil, err
+	}
+	return &secret, nil
+}
+
+func (r *SQLRepository) ListZoneSecrets(ctx context.Context, zoneID string) ([]*domain.ZoneSecret, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT zone_id, name, created_at, updated_at FROM zone_secrets WHERE zone_id = $1 ORDER BY name", zoneID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []*domain.ZoneSecret
+	for rows.Next() {
+		var secret domain.ZoneSecret
+		if err := rows.Scan(&secret.ZoneID, &secret.Name, &secret.CreatedAt, &secret.UpdatedAt); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, &secret)
+	}
+	return secrets, rows.Err()
+}
+
+func (r *SQLRepository) DeleteZoneSecret(ctx context.Context, zoneID, name string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM zone_secrets WHERE zone_id = $1 AND name = $2", zoneID, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrZoneSecretNotFound
+	}
+	return nil
+}
+
+const deadLetterColumns = "id, flow_id, zone_id, execution_id, origin_execution_id, input, error, attempts, status, next_retry_at, created_at, updated_at"
+
+func scanDeadLetter(scan func(dest ...interface{}) error) (*domain.DeadLetter, error) {
+	var e domain.DeadLetter
+	var input []byte
+	var nextRetryAt sql.NullTime
+	err := scan(&e.ID, &e.FlowID, &e.ZoneID, &e.ExecutionID, &e.OriginExecutionID, &input, &e.Error, &e.Attempts, &e.Status, &nextRetryAt, &e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	e.Input = input
+	if nextRetryAt.Valid {
+		e.NextRetryAt = &nextRetryAt.Time
+	}
+	return &e, nil
+}
+
+func (r *SQLRepository) CreateDeadLetter(ctx context.Context, e *domain.DeadLetter) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO flow_dead_letters ("+deadLetterColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		e.ID, e.FlowID, e.ZoneID, e.ExecutionID, e.OriginExecutionID, []byte(e.Input), e.Error, e.Attempts, e.Status, e.NextRetryAt, e.CreatedAt, e.UpdatedAt)
+	return err
+}
+
+func (r *SQLRepository) GetDeadLetter(ctx context.Context, id string) (*domain.DeadLetter, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+deadLetterColumns+" FROM flow_dead_letters WHERE id = $1", id)
+	e, err := scanDeadLetter(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrDeadLetterNotFound
+	}
+	return e, err
+}
+
+func (r *SQLRepository) UpdateDeadLetter(ctx context.Context, e *domain.DeadLetter) error {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE flow_dead_letters SET execution_id = $1, error = $2, attempts = $3, status = $4, next_retry_at = $5, updated_at = $6 WHERE id = $7",
+		e.ExecutionID, e.Error, e.Attempts, e.Status, e.NextRetryAt, e.UpdatedAt, e.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+func (r *SQLRepository) ListDeadLetters(ctx context.Context, zoneID string, status domain.DeadLetterStatus, limit, offset int) ([]*domain.DeadLetter, error) {
+	return r.queryDeadLetters(ctx,
+		"SELECT "+deadLetterColumns+" FROM flow_dead_letters WHERE zone_id = $1 AND ($2 = '' OR status = $2) ORDER BY created_at DESC LIMIT $3 OFFSET $4",
+		zoneID, status, limit, offset)
+}
+
+func (r *SQLRepository) ListDueDeadLetters(ctx context.Context, now time.Time) ([]*domain.DeadLetter, error) {
+	return r.queryDeadLetters(ctx,
+		"SELECT "+deadLetterColumns+" FROM flow_dead_letters WHERE status = $1 AND next_retry_at <= $2 ORDER BY next_retry_at",
+		domain.DeadLetterScheduled, now)
+}
+
+func (r *SQLRepository) ClaimDeadLetterRetry(ctx context.Context, id string, now time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE flow_dead_letters SET status = $1, next_retry_at = NULL, updated_at = $2 WHERE id = $3 AND status IN ($4, $5)",
+		domain.DeadLetterRetrying, now, id, domain.DeadLetterScheduled, domain.DeadLetterDead)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *SQLRepository) queryDeadLetters(ctx context.Context, query string, args ...interface{}) ([]*domain.DeadLetter, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*domain.DeadLetter
+	for rows.Next() {
+		e, err := scanDeadLetter(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+const replayAttemptColumns = "id, zone_id, event_id, idempotency_key, job_id, replayed_event_id, status, error, created_at"
+
+func scanReplayAttempt(scan func(dest ...interface{}) error) (*domain.ReplayAttempt, error) {
+	var a domain.ReplayAttempt
+	err := scan(&a.ID, &a.ZoneID, &a.EventID, &a.IdempotencyKey, &a.JobID, &a.ReplayedEventID, &a.Status, &a.Error, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *SQLRepository) CreateReplayAttempt(ctx context.Context, a *domain.ReplayAttempt) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO replay_attempts ("+replayAttemptColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		a.ID, a.ZoneID, a.EventID, a.IdempotencyKey, a.JobID, a.ReplayedEventID, a.Status, a.Error, a.CreatedAt)
+	return err
+}
+
+func (r *SQLRepository) FindSuccessfulReplay(ctx context.Context, zoneID, eventID, key string, since time.Time) (*domain.ReplayAttempt, error) {
+	row := r.db.QueryRowContext(ctx,
+		"SELECT "+replayAttemptColumns+" FROM replay_attempts WHERE zone_id = $1 AND event_id = $2 AND ($3 = '' OR idempotency_key = $3) AND status = 'success' AND created_at >= $4 ORDER BY created_at DESC LIMIT 1",
+		zoneID, eventID, key, since)
+	a, err := scanReplayAttempt(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+func (r *SQLRepository) ListReplayAttempts(ctx context.Context, eventID string) ([]*domain.ReplayAttempt, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+replayAttemptColumns+" FROM replay_attempts WHERE event_id = $1 ORDER BY created_at DESC", eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []*domain.ReplayAttempt
+	for rows.Next() {
+		a, err := scanReplayAttempt(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+const webhookDeliveryColumns = "id, flow_id, zone_id, execution_id, node_id, attempt, method, url, request_headers, request_body, status_code, response_headers, response_body, error, latency_ms, created_at"
+
+func (r *SQLRepository) CreateWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	requestHeaders, _ := json.Marshal(d.RequestHeaders)
+	responseHeaders, _ := json.Marshal(d.ResponseHeaders)
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO webhook_deliveries ("+webhookDeliveryColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)",
+		d.ID, d.FlowID, d.ZoneID, d.ExecutionID, d.NodeID, d.Attempt, d.Method, d.URL, requestHeaders, d.RequestBody,
+		d.StatusCode, responseHeaders, d.ResponseBody, d.Error, d.LatencyMs, d.CreatedAt)
+	return err
+}
+
+func (r *SQLRepository) ListWebhookDeliveries(ctx context.Context, f domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE flow_id = $1 AND ($2 = '' OR execution_id = $2) AND ($3 = '' OR node_id = $3) ORDER BY created_at DESC LIMIT $4 OFFSET $5",
+		f.FlowID, f.ExecutionID, f.NodeID, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*domain.WebhookDelivery
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		var requestHeaders, responseHeaders []byte
+		err := rows.Scan(&d.ID, &d.FlowID, &d.ZoneID, &d.ExecutionID, &d.NodeID, &d.Attempt, &d.Method, &d.URL, &requestHeaders, &d.RequestBody,
+			&d.StatusCode, &responseHeaders, &d.ResponseBody, &d.Error, &d.LatencyMs, &d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		json.Unmarshal(requestHeaders, &d.RequestHeaders)
+		json.Unmarshal(responseHeaders, &d.ResponseHeaders)
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
+
+const flowScheduleColumns = "flow_id, zone_id, cron_expression, interval_seconds, timezone, enabled, next_run_at, last_run_at, created_at, updated_at"
+
+func scanFlowSchedule(scan func(dest ...interface{}) error) (*domain.FlowSchedule, error) {
+	var s domain.FlowSchedule
+	var lastRunAt sql.NullTime
+	err := scan(&s.FlowID, &s.ZoneID, &s.CronExpr, &s.IntervalSeconds, &s.Timezone, &s.Enabled, &s.NextRunAt, &lastRunAt, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastRunAt.Valid {
+		s.LastRunAt = &lastRunAt.Time
+	}
+	return &s, nil
+}
+
+// SaveFlowSchedule creates the flow's schedule or replaces the existing one
+func (r *SQLRepository) SaveFlowSchedule(ctx context.Context, s *domain.FlowSchedule) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO flow_schedules ("+flowScheduleColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) "+
+			"ON CONFLICT (flow_id) DO UPDATE SET zone_id = EXCLUDED.zone_id, cron_expression = EXCLUDED.cron_expression, "+
+			"interval_seconds = EXCLUDED.interval_seconds, timezone = EXCLUDED.timezone, enabled = EXCLUDED.enabled, "+
+			"next_run_at = EXCLUDED.next_run_at, last_run_at = EXCLUDED.last_run_at, updated_at = EXCLUDED.updated_at",
+		s.FlowID, s.ZoneID, s.CronExpr, s.IntervalSeconds, s.Timezone, s.Enabled, s.NextRunAt, s.LastRunAt, s.CreatedAt, s.UpdatedAt)
+	return err
+}
+
+func (r *SQLRepository) GetFlowSchedule(ctx context.Context, flowID string) (*domain.FlowSchedule, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+flowScheduleColumns+" FROM flow_schedules WHERE flow_id = $1", flowID)
+	s, err := scanFlowSchedule(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrFlowScheduleNotFound
+	}
+	return s, err
+}
+
+func (r *SQLRepository) ListFlowSchedules(ctx context.Context, zoneID string) ([]*domain.FlowSchedule, error) {
+	return r.queryFlowSchedules(ctx, "SELECT "+flowScheduleColumns+" FROM flow_schedules WHERE zone_id = $1 ORDER BY created_at", zoneID)
+}
+
+func (r *SQLRepository) ListEnabledFlowSchedules(ctx context.Context) ([]*domain.FlowSchedule, error) {
+	return r.queryFlowSchedules(ctx, "SELECT "+flowScheduleColumns+" FROM flow_schedules WHERE enabled = TRUE")
+}
+
+func (r *SQLRepository) queryFlowSchedules(ctx context.Context, query string, args ...interface{}) ([]*domain.FlowSchedule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*domain.FlowSchedule
+	for rows.Next() {
+		s, err := scanFlowSchedule(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func (r *SQLRepository) DeleteFlowSchedule(ctx context.Context, flowID string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM flow_schedules WHERE flow_id = $1", flowID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrFlowScheduleNotFound
+	}
+	return nil
+}
+
+func (r *SQLRepository) ClaimFlowScheduleRun(ctx context.Context, flowID string, dueAt, ranAt, nextRunAt time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE flow_schedules SET last_run_at = $1, next_run_at = $2, updated_at = $1 WHERE flow_id = $3 AND next_run_at = $4 AND enabled = TRUE",
+		ranAt, nextRunAt, flowID, dueAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,136 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/triggers"
+	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
+)
+
+// TopicRoute maps a Kafka topic to the zone its events belong to. An empty
+// ZoneID means the topic is shared and every event names its own zone.
+type TopicRoute struct {
+	Topic  string
+	ZoneID string
+}
+
+// ParseTopicRoutes parses a comma separated list of "topic" or "topic=zoneID"
+func ParseTopicRoutes(spec string) []TopicRoute {
+	var routes []TopicRoute
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		topic, zoneID, _ := strings.Cut(entry, "=")
+		routes = append(routes, TopicRoute{Topic: strings.TrimSpace(topic), ZoneID: strings.TrimSpace(zoneID)})
+	}
+	return routes
+}
+
+// KafkaTriggerConfig configures the Kafka trigger consumers
+type KafkaTriggerConfig struct {
+	Brokers []string
+	GroupID string // Shared by all instances so partitions are spread across them
+	Routes  []TopicRoute
+	// ConsumersPerTopic is the number of group members each instance runs per topic
+	ConsumersPerTopic int
+}
+
+// KafkaTrigger consumes events from Kafka and starts an execution of every
+// enabled flow whose trigger matches the event
+type KafkaTrigger struct {
+	dispatcher *TriggerDispatcher
+	config     KafkaTriggerConfig
+}
+
+// NewKafkaTrigger creates a new Kafka trigger
+func NewKafkaTrigger(dispatcher *TriggerDispatcher, config KafkaTriggerConfig) *KafkaTrigger {
+	if config.ConsumersPerTopic < 1 {
+		config.ConsumersPerTopic = 1
+	}
+	return &KafkaTrigger{
+		dispatcher: dispatcher,
+		config:     config,
+	}
+}
+
+// Start consumes every configured topic until the context is cancelled
+func (k *KafkaTrigger) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, route := range k.config.Routes {
+		for i := 0; i < k.config.ConsumersPerTopic; i++ {
+			wg.Add(1)
+			go func(route TopicRoute) {
+				defer wg.Done()
+				consumer := messaging.NewKafkaConsumer(k.config.Brokers, route.Topic, k.config.GroupID)
+				defer consumer.Close()
+
+				log.Printf("Consuming flow triggers from topic %s (group %s)", route.Topic, k.config.GroupID)
+				consumer.Consume(ctx, func(key string, value []byte) error {
+					return k.HandleMessage(ctx, route, key, value)
+				})
+			}(route)
+		}
+	}
+	wg.Wait()
+}
+
+// triggerMessage is the event shape published on trigger topics. Messages
+// without a data or payload object are treated as the payload themselves.
+type triggerMessage struct {
+	ID      string                 `json:"id"`
+	Type    string                 `json:"type"`
+	ZoneID  string                 `json:"zone_id"`
+	Data    map[string]interface{} `json:"data"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// HandleMessage records the event and runs every matching flow, returning
+// once all of their executions have finished
+func (k *KafkaTrigger) HandleMessage(ctx context.Context, route TopicRoute, key string, value []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(value, &raw); err != nil {
+		return fmt.Errorf("invalid event on topic %s: %w", route.Topic, err)
+	}
+	var msg triggerMessage
+	json.Unmarshal(value, &msg)
+
+	event := &triggers.Event{
+		ID:        msg.ID,
+		Type:      msg.Type,
+		ZoneID:    msg.ZoneID,
+		Data:      msg.Data,
+		CreatedAt: time.Now(),
+	}
+	if route.ZoneID != "" {
+		event.ZoneID = route.ZoneID
+	}
+	if event.ID == "" {
+		event.ID = key
+	}
+	if event.Data == nil {
+		event.Data = msg.Payload
+	}
+	if event.Data == nil {
+		event.Data = raw
+	}
+	if event.Type == "" || event.ZoneID == "" {
+		return nil // Not addressed to any flow
+	}
+
+	flows, err := k.dispatcher.Match(ctx, event)
+	if err != nil || len(flows) == 0 {
+		return err
+	}
+
+	input := raw
+	input["zone_id"] = event.ZoneID
+	return k.dispatcher.Run(ctx, flows, event, input)
+}
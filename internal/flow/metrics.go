@@ -0,0 +1,83 @@
+package flow
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+var (
+	flowExecutionsStarted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flow_executions_started_total",
+		Help: "Flow executions started.",
+	}, []string{"flow", "zone"})
+
+	flowExecutionsFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flow_executions_finished_total",
+		Help: "Flow executions that stopped running, by final status (completed, failed, paused, timed_out).",
+	}, []string{"flow", "zone", "status"})
+
+	flowNodeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "flow_node_duration_seconds",
+		Help:    "Time taken to run a flow node, by node type and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"node_type", "status"})
+
+	flowTriggerEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flow_trigger_events_total",
+		Help: "Events checked against flow triggers, by whether any flow matched.",
+	}, []string{"zone", "result"})
+
+	flowTriggerMatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flow_trigger_matches_total",
+		Help: "Events matched by an enabled flow's trigger.",
+	}, []string{"flow", "zone"})
+)
+
+// RunnerMetrics records flow runner activity in Prometheus
+type RunnerMetrics struct{}
+
+// NewRunnerMetrics creates runner metrics for use with FlowRunner.SetMetrics
+func NewRunnerMetrics() *RunnerMetrics {
+	return &RunnerMetrics{}
+}
+
+func (m *RunnerMetrics) ExecutionStarted(flow *domain.Flow) {
+	flowExecutionsStarted.WithLabelValues(flow.ID, flow.ZoneID).Inc()
+}
+
+func (m *RunnerMetrics) ExecutionFinished(flow *domain.Flow, status domain.ExecutionStatus) {
+	flowExecutionsFinished.WithLabelValues(flow.ID, flow.ZoneID, string(status)).Inc()
+}
+
+func (m *RunnerMetrics) NodeFinished(flow *domain.Flow, node *domain.Node, duration time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+		if err.Error() == "execution_paused" {
+			status = "paused"
+		}
+	}
+	flowNodeDuration.WithLabelValues(string(node.Type), status).Observe(duration.Seconds())
+}
+
+// RegisterDebugSessionMetrics exports the number of active and paused debug
+// sessions of the service. It must be called at most once.
+func RegisterDebugSessionMetrics(service *DebugService) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "flow_debug_sessions_active",
+		Help: "Debug sessions currently active.",
+	}, func() float64 {
+		active, _ := service.sessionManager.SessionCounts()
+		return float64(active)
+	})
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "flow_debug_sessions_paused",
+		Help: "Active debug sessions paused at a breakpoint.",
+	}, func() float64 {
+		_, paused := service.sessionManager.SessionCounts()
+		return float64(paused)
+	})
+}
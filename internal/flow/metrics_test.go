@@ -0,0 +1,60 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/testutil"
+)
+
+type failingHandler struct{}
+
+func (failingHandler) Execute(ctx context.Context, node *domain.Node, input map[string]interface{}) (map[string]interface{}, error) {
+	return nil, errors.New("boom")
+}
+
+func TestRunnerMetrics(t *testing.T) {
+	runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+	runner.RegisterHandler("fail", failingHandler{})
+	runner.SetMetrics(NewRunnerMetrics())
+
+	ok := &domain.Flow{ID: "metrics_ok", ZoneID: "metrics_zone", Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}}}
+	failing := &domain.Flow{
+		ID: "metrics_fail", ZoneID: "metrics_zone",
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "fail", Type: "fail"}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "fail"}},
+	}
+	if err := runner.Execute(context.Background(), ok, nil); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if err := runner.Execute(context.Background(), failing, nil); err == nil {
+		t.Fatal("Expected the failing flow to return an error")
+	}
+
+	// Dry runs are not counted
+	if _, err := runner.DryRun(context.Background(), ok, nil); err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		counter prometheus.Collector
+		want    float64
+	}{
+		{flowExecutionsStarted.WithLabelValues("metrics_ok", "metrics_zone"), 1},
+		{flowExecutionsFinished.WithLabelValues("metrics_ok", "metrics_zone", "completed"), 1},
+		{flowExecutionsStarted.WithLabelValues("metrics_fail", "metrics_zone"), 1},
+		{flowExecutionsFinished.WithLabelValues("metrics_fail", "metrics_zone", "failed"), 1},
+	} {
+		if got := promtestutil.ToFloat64(tc.counter); got != tc.want {
+			t.Errorf("Expected %v, got %v", tc.want, got)
+		}
+	}
+
+	if n := promtestutil.CollectAndCount(flowNodeDuration, "flow_node_duration_seconds"); n < 2 {
+		t.Errorf("Expected node latency for the trigger and failing nodes, got %d series", n)
+	}
+}
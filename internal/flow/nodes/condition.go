@@ -7,6 +7,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/expr"
 )
 
 // Node is the interface for all flow nodes
@@ -28,9 +30,10 @@ type NodeResult struct {
 type ConditionNode struct {
 	NodeID      string `json:"id"`
 	Conditions  []Rule `json:"conditions"`
-	TrueNext    string `json:"trueNext"`    // Node ID if condition is true
-	FalseNext   string `json:"falseNext"`   // Node ID if condition is false
-	CombineWith string `json:"combineWith"` // "and" or "or"
+	TrueNext    string `json:"trueNext"`             // Node ID if condition is true
+	FalseNext   string `json:"falseNext"`            // Node ID if condition is false
+	CombineWith string `json:"combineWith"`          // "and" or "or"
+	Expression  string `json:"expression,omitempty"` // Used instead of the rules when set
 }
 
 // Rule represents a single condition rule
@@ -63,6 +66,17 @@ func (n *ConditionNode) Type() string {
 
 // Execute evaluates the conditions
 func (n *ConditionNode) Execute(ctx context.Context, input map[string]interface{}) (*NodeResult, error) {
+	if n.Expression != "" {
+		passed, err := expr.EvalBool(n.Expression, input)
+		if err != nil {
+			return &NodeResult{
+				Success: false,
+				Error:   fmt.Sprintf("failed to evaluate expression: %v", err),
+			}, nil
+		}
+		return n.result(passed), nil
+	}
+
 	allPassed := n.CombineWith == "and"
 
 	for _, rule := range n.Conditions {
@@ -81,18 +95,22 @@ func (n *ConditionNode) Execute(ctx context.Context, input map[string]interface{
 		}
 	}
 
+	return n.result(allPassed), nil
+}
+
+func (n *ConditionNode) result(passed bool) *NodeResult {
 	next := n.FalseNext
-	if allPassed {
+	if passed {
 		next = n.TrueNext
 	}
 
 	return &NodeResult{
 		Success: true,
 		Output: map[string]interface{}{
-			"conditionMet": allPassed,
+			"conditionMet": passed,
 		},
 		Next: next,
-	}, nil
+	}
 }
 
 // evaluateRule evaluates a single rule
@@ -171,6 +189,28 @@ func extractValue(data map[string]interface{}, path string) (interface{}, error)
 	return current, nil
 }
 
+// plainPath matches template placeholders that are a field path rather than
+// an expression
+var plainPath = regexp.MustCompile(`^[\w.]+$`)
+
+// templateString resolves the contents of a {{...}} placeholder. Field
+// paths are looked up in the input; anything else, such as
+// {{amount * 100}} or {{upper(currency)}}, is evaluated as an expression.
+func templateString(input map[string]interface{}, content string) (string, error) {
+	if plainPath.MatchString(content) {
+		value, err := extractValue(input, content)
+		if err != nil {
+			return "", err
+		}
+		return toString(value), nil
+	}
+	value, err := expr.Eval(content, input)
+	if err != nil {
+		return "", err
+	}
+	return expr.Format(value), nil
+}
+
 // compareEqual compares two values for equality
 func compareEqual(a, b interface{}) bool {
 	// Handle JSON comparison
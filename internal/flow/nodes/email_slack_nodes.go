@@ -9,6 +9,8 @@ import (
 	"net/smtp"
 	"strings"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // EmailActionNode sends emails via SMTP
@@ -118,7 +120,8 @@ func NewSlackActionNode(config SlackConfig) *SlackActionNode {
 		Username:   config.Username,
 		IconEmoji:  config.IconEmoji,
 		client: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout:   10 * time.Second,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
 	}
 }
@@ -195,7 +198,7 @@ func (n *SlackActionNode) Execute(ctx context.Context, input map[string]interfac
 	}, nil
 }
 
-// resolveTemplate replaces {{path}} with values from input
+// resolveTemplate replaces {{path}} and {{expression}} with values from input
 func resolveTemplate(template string, input map[string]interface{}) string {
 	result := template
 	// Simple template resolution - find {{...}} and replace
@@ -207,9 +210,9 @@ func resolveTemplate(template string, input map[string]interface{}) string {
 		}
 
 		path := strings.TrimSpace(result[start+2 : end])
-		value, err := extractValue(input, path)
+		value, err := templateString(input, path)
 		if err == nil {
-			result = result[:start] + toString(value) + result[end+2:]
+			result = result[:start] + value + result[end+2:]
 		} else {
 			result = result[:start] + "" + result[end+2:]
 		}
@@ -0,0 +1,103 @@
+package nodes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// extractJSONPath resolves a JSONPath expression against decoded JSON. The
+// supported subset is the root "$", dot or bracketed member names and array
+// indexes, negative indexes counting from the end:
+//
+//	$.data.risk.score
+//	$.items[0].id
+//	$['content-type']
+//	$.events[-1]
+func extractJSONPath(data interface{}, path string) (interface{}, error) {
+	tokens, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current := data
+	for _, tok := range tokens {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			if tok.isIndex {
+				return nil, fmt.Errorf("cannot index object with [%d]", tok.index)
+			}
+			val, ok := v[tok.key]
+			if !ok {
+				return nil, fmt.Errorf("no field %q", tok.key)
+			}
+			current = val
+		case []interface{}:
+			if !tok.isIndex {
+				return nil, fmt.Errorf("cannot read field %q of an array", tok.key)
+			}
+			i := tok.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("index %d out of range", tok.index)
+			}
+			current = v[i]
+		default:
+			return nil, fmt.Errorf("cannot traverse into %T", current)
+		}
+	}
+	return current, nil
+}
+
+type jsonPathToken struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func parseJSONPath(path string) ([]jsonPathToken, error) {
+	rest := strings.TrimSpace(path)
+	rest = strings.TrimPrefix(rest, "$")
+
+	var tokens []jsonPathToken
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty field name", path)
+			}
+			tokens = append(tokens, jsonPathToken{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unclosed bracket", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				tokens = append(tokens, jsonPathToken{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			i, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath %q: bad index %q", path, inner)
+			}
+			tokens = append(tokens, jsonPathToken{index: i, isIndex: true})
+		default:
+			// Paths may omit the leading "$." ("data.score")
+			if len(tokens) > 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q", path)
+			}
+			rest = "." + rest
+		}
+	}
+	return tokens, nil
+}
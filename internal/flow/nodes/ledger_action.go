@@ -0,0 +1,157 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	pb "github.com/sapliy/fintech-ecosystem/proto/ledger"
+)
+
+// LedgerActionNode records a balanced transaction in the ledger service.
+// The amount moves from the debit account to the credit account; account
+// IDs, amount and reference are templates resolved from the flow context
+// (e.g. "{{payment.amount}}"). The reference is scoped to the flow
+// execution, which makes the ledger record it once per execution.
+type LedgerActionNode struct {
+	NodeID        string                 `json:"id"`
+	DebitAccount  string                 `json:"debit_account"`  // Template
+	CreditAccount string                 `json:"credit_account"` // Template
+	Amount        string                 `json:"amount"`         // Template; integer amount in cents
+	Currency      string                 `json:"currency"`
+	Description   string                 `json:"description,omitempty"`  // Template
+	ReferenceID   string                 `json:"reference_id,omitempty"` // Template, e.g. {{payment_intent_id}}
+	ZoneID        string                 `json:"zone_id,omitempty"`      // Defaults to the input's zone_id
+	Mode          string                 `json:"mode,omitempty"`         // Defaults to the input's mode, then "live"
+	NextNode      string                 `json:"next,omitempty"`
+	client        pb.LedgerServiceClient `json:"-"`
+}
+
+// LedgerActionConfig is used to create a new ledger action node
+type LedgerActionConfig struct {
+	ID            string
+	DebitAccount  string
+	CreditAccount string
+	Amount        string
+	Currency      string
+	Description   string
+	ReferenceID   string
+	ZoneID        string
+	Mode          string
+	NextNode      string
+	Client        pb.LedgerServiceClient
+}
+
+// NewLedgerActionNode creates a new ledger action node
+func NewLedgerActionNode(config LedgerActionConfig) *LedgerActionNode {
+	return &LedgerActionNode{
+		NodeID:        config.ID,
+		DebitAccount:  config.DebitAccount,
+		CreditAccount: config.CreditAccount,
+		Amount:        config.Amount,
+		Currency:      config.Currency,
+		Description:   config.Description,
+		ReferenceID:   config.ReferenceID,
+		ZoneID:        config.ZoneID,
+		Mode:          config.Mode,
+		NextNode:      config.NextNode,
+		client:        config.Client,
+	}
+}
+
+// ID returns the node ID
+func (n *LedgerActionNode) ID() string { return n.NodeID }
+
+// Type returns the node type
+func (n *LedgerActionNode) Type() string { return "ledger_action" }
+
+// Execute posts the debit and the matching credit as one balanced
+// transaction, so either both legs are recorded or neither is.
+func (n *LedgerActionNode) Execute(ctx context.Context, input map[string]interface{}) (*NodeResult, error) {
+	if n.client == nil {
+		return &NodeResult{
+			Success: false,
+			Error:   "ledger client not configured",
+		}, fmt.Errorf("ledger client not configured")
+	}
+
+	debit := resolveTemplate(n.DebitAccount, input)
+	credit := resolveTemplate(n.CreditAccount, input)
+	if debit == "" || credit == "" {
+		return &NodeResult{
+			Success: false,
+			Error:   "debit and credit accounts are required",
+		}, fmt.Errorf("debit and credit accounts are required")
+	}
+
+	amountStr := strings.TrimSpace(resolveTemplate(n.Amount, input))
+	amount, err := strconv.ParseInt(amountStr, 10, 64)
+	if err != nil || amount <= 0 {
+		return &NodeResult{
+			Success: false,
+			Error:   fmt.Sprintf("invalid amount %q", amountStr),
+		}, fmt.Errorf("invalid amount %q", amountStr)
+	}
+
+	zoneID := n.ZoneID
+	if zoneID == "" {
+		zoneID, _ = input["zone_id"].(string)
+	}
+	mode := n.Mode
+	if mode == "" {
+		mode, _ = input["mode"].(string)
+	}
+	if mode == "" {
+		mode = "live"
+	}
+
+	referenceID := n.referenceID(ctx, resolveTemplate(n.ReferenceID, input))
+	output := map[string]interface{}{
+		"debit_account":  debit,
+		"credit_account": credit,
+		"amount":         amount,
+		"currency":       n.Currency,
+		"reference_id":   referenceID,
+	}
+	if domain.IsSimulation(ctx) {
+		output["status"] = "simulated"
+		return &NodeResult{Success: true, Output: output, Next: n.NextNode}, nil
+	}
+
+	resp, err := n.client.RecordTransaction(ctx, &pb.RecordTransactionRequest{
+		Currency:    n.Currency,
+		Description: resolveTemplate(n.Description, input),
+		ReferenceId: referenceID,
+		ZoneId:      zoneID,
+		Mode:        mode,
+		Entries: []*pb.EntryRequest{
+			{AccountId: debit, Amount: -amount, Currency: n.Currency, Direction: "debit"},
+			{AccountId: credit, Amount: amount, Currency: n.Currency, Direction: "credit"},
+		},
+	})
+	if err != nil {
+		return &NodeResult{
+			Success: false,
+			Error:   fmt.Sprintf("failed to record ledger transaction: %v", err),
+		}, err
+	}
+
+	output["status"] = resp.GetStatus()
+	return &NodeResult{Success: true, Output: output, Next: n.NextNode}, nil
+}
+
+// referenceID makes the reference unique per execution and node, so that a
+// retried or recovered execution records the transaction once while another
+// execution records its own. Outside an execution the reference is kept.
+func (n *LedgerActionNode) referenceID(ctx context.Context, reference string) string {
+	execID := domain.IdempotencyScopeFromContext(ctx)
+	if execID == "" {
+		return reference
+	}
+	if reference == "" {
+		return fmt.Sprintf("flow_%s_%s", execID, n.NodeID)
+	}
+	return fmt.Sprintf("flow_%s_%s_%s", execID, n.NodeID, reference)
+}
@@ -0,0 +1,159 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/testutil"
+	pb "github.com/sapliy/fintech-ecosystem/proto/ledger"
+	"google.golang.org/grpc"
+)
+
+// recordingLedger keeps the transactions recorded through it
+type recordingLedger struct {
+	pb.LedgerServiceClient
+	recorded []*pb.RecordTransactionRequest
+	err      error
+}
+
+func (l *recordingLedger) RecordTransaction(ctx context.Context, in *pb.RecordTransactionRequest, opts ...grpc.CallOption) (*pb.RecordTransactionResponse, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	l.recorded = append(l.recorded, in)
+	return &pb.RecordTransactionResponse{Status: "recorded"}, nil
+}
+
+func newTestLedgerAction(client pb.LedgerServiceClient) *LedgerActionNode {
+	return NewLedgerActionNode(LedgerActionConfig{
+		ID:            "post",
+		DebitAccount:  "{{merchant}}",
+		CreditAccount: "fees",
+		Amount:        "{{fee}}",
+		Currency:      "USD",
+		ReferenceID:   "{{payment_id}}",
+		Client:        client,
+	})
+}
+
+func TestLedgerActionNode_RecordsOneBalancedTransaction(t *testing.T) {
+	ledger := &recordingLedger{}
+	registry := DefaultNodeRegistry()
+	registry.UseClients(NodeClients{Ledger: ledger})
+	repo := testutil.NewMockFlowRepository()
+	runner := domain.NewFlowRunner(repo)
+	registry.Install(runner)
+
+	config := json.RawMessage(`{"debit_account":"{{merchant}}","credit_account":"fees","amount":"{{fee}}","currency":"USD","reference_id":"{{payment_id}}"}`)
+	flow := &domain.Flow{
+		ID: "flow_fees", ZoneID: "zone_1",
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "post", Type: "ledger_action", Data: config}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "post"}},
+	}
+	input := map[string]interface{}{"merchant": "acct_merchant", "fee": 250, "payment_id": "pi_1", "zone_id": "zone_1", "mode": "test"}
+	for i := 0; i < 2; i++ {
+		if err := runner.Execute(context.Background(), flow, input); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+	}
+
+	if len(ledger.recorded) != 2 {
+		t.Fatalf("Expected one transaction per execution, got %d", len(ledger.recorded))
+	}
+	tx := ledger.recorded[0]
+	if len(tx.Entries) != 2 || tx.ZoneId != "zone_1" || tx.Mode != "test" {
+		t.Fatalf("Expected both legs in one transaction of the input's zone and mode, got %v", tx)
+	}
+	var sum int64
+	for _, e := range tx.Entries {
+		sum += e.Amount
+	}
+	debit, credit := tx.Entries[0], tx.Entries[1]
+	if sum != 0 || debit.AccountId != "acct_merchant" || debit.Amount != -250 || credit.AccountId != "fees" || credit.Amount != 250 {
+		t.Errorf("Expected 250 to move from acct_merchant to fees, got %v", tx.Entries)
+	}
+
+	// The reference is scoped to the execution, so each execution records
+	// its own transaction and a retried one is deduplicated by the ledger
+	executions, _ := repo.ListExecutions(context.Background(), "flow_fees", 10, 0)
+	refs := map[string]bool{}
+	for _, exec := range executions {
+		refs["flow_"+exec.ID+"_post_pi_1"] = true
+	}
+	for _, tx := range ledger.recorded {
+		if !refs[tx.ReferenceId] {
+			t.Errorf("Expected a reference derived from the execution, got %q", tx.ReferenceId)
+		}
+	}
+	if ledger.recorded[0].ReferenceId == ledger.recorded[1].ReferenceId {
+		t.Error("Expected distinct references across executions")
+	}
+}
+
+func TestLedgerActionNode_SkippedInDryRun(t *testing.T) {
+	ledger := &recordingLedger{}
+	registry := DefaultNodeRegistry()
+	registry.UseClients(NodeClients{Ledger: ledger})
+	runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+	registry.Install(runner)
+
+	flow := &domain.Flow{
+		ID: "flow_fees", ZoneID: "zone_1",
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "post", Type: "ledger_action", Data: json.RawMessage(`{"debit_account":"a","credit_account":"b","amount":"1","currency":"USD"}`)},
+		},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "post"}},
+	}
+	if _, err := runner.DryRun(context.Background(), flow, map[string]interface{}{}); err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if len(ledger.recorded) != 0 {
+		t.Errorf("Expected nothing recorded during a dry run, got %v", ledger.recorded)
+	}
+}
+
+func TestLedgerActionNode_SkippedInSimulation(t *testing.T) {
+	ledger := &recordingLedger{}
+	n := newTestLedgerAction(ledger)
+
+	result, err := n.Execute(domain.WithSimulation(context.Background()), map[string]interface{}{"merchant": "acct_merchant", "fee": "250"})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(ledger.recorded) != 0 {
+		t.Errorf("Expected nothing recorded during a dry run, got %v", ledger.recorded)
+	}
+	if result.Output["status"] != "simulated" || result.Output["amount"] != int64(250) {
+		t.Errorf("Expected the simulated transaction to be described, got %v", result.Output)
+	}
+}
+
+func TestLedgerActionNode_Failures(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  map[string]interface{}
+		ledger *recordingLedger
+		want   string
+	}{
+		{"missing account", map[string]interface{}{"fee": "250"}, &recordingLedger{}, "accounts are required"},
+		{"invalid amount", map[string]interface{}{"merchant": "acct_merchant", "fee": "-5"}, &recordingLedger{}, "invalid amount"},
+		{"ledger error", map[string]interface{}{"merchant": "acct_merchant", "fee": "250"}, &recordingLedger{err: errors.New("transaction is not balanced")}, "not balanced"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := newTestLedgerAction(tt.ledger).Execute(context.Background(), tt.input)
+			if err == nil || result.Success || !strings.Contains(result.Error, tt.want) {
+				t.Errorf("Expected failure %q, got %v (%v)", tt.want, result, err)
+			}
+			if len(tt.ledger.recorded) != 0 {
+				t.Errorf("Expected nothing recorded, got %v", tt.ledger.recorded)
+			}
+		})
+	}
+}
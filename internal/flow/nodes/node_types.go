@@ -0,0 +1,516 @@
+package nodes
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/notification"
+)
+
+// DefaultNodeRegistry returns a registry holding the runner's builtin node
+// types and the node types that can be built from their config, with the
+// clients set through UseClients
+func DefaultNodeRegistry() *NodeRegistry {
+	r := NewNodeRegistry()
+	for _, def := range defaultNodeTypes {
+		if err := r.Register(def); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+var defaultNodeTypes = []NodeTypeDefinition{
+	// Executed by the flow runner
+	{
+		Type:        "eventTrigger",
+		Description: "Starts the flow when its trigger fires",
+		Schema:      json.RawMessage(`{"type":"object","properties":{}}`),
+		Builtin:     true,
+	},
+	{
+		Type:        "condition",
+		Description: "Follows the true or false edge depending on a comparison or expression",
+		Schema: json.RawMessage(`{"type":"object","properties":{
+			"field":{"type":"string"},
+			"operator":{"type":"string","enum":["equals","gt"]},
+			"value":{},
+			"expression":{"type":"string","description":"Used instead of field, operator and value when set"}}}`),
+		Builtin: true,
+	},
+	{
+		Type:        "switch",
+		Description: "Follows the edge whose handle matches the field value, or the default edge",
+		Schema: json.RawMessage(`{"type":"object","required":["field","cases"],"properties":{
+			"field":{"type":"string"},
+			"cases":{"type":"array","items":{}}}}`),
+		Builtin: true,
+	},
+	{
+		Type:        "webhook",
+		Description: "Sends the input to the flow's webhook",
+		Schema:      json.RawMessage(`{"type":"object","properties":{}}`),
+		Builtin:     true,
+	},
+	{
+		Type:        "approval",
+		Description: "Pauses the execution until it is approved",
+		Schema: json.RawMessage(`{"type":"object","properties":{
+			"approverRole":{"type":"string","enum":["admin","finance","owner"]},
+			"timeoutHours":{"type":"integer","minimum":0},
+			"message":{"type":"string"},
+			"allowMultiple":{"type":"boolean"}}}`),
+		Builtin: true,
+	},
+	{
+		Type:        "auditLog",
+		Description: "Logs the input and passes it through",
+		Schema:      json.RawMessage(`{"type":"object","properties":{}}`),
+		Builtin:     true,
+	},
+	{
+		Type:        "join",
+		Description: "Waits for every parallel branch leading into it",
+		Schema:      json.RawMessage(`{"type":"object","properties":{}}`),
+		Builtin:     true,
+	},
+	{
+		Type:        "loop",
+		Description: "Runs the body edge once for each item of an array",
+		Schema: json.RawMessage(`{"type":"object","required":["arrayPath"],"properties":{
+			"arrayPath":{"type":"string"},
+			"itemKey":{"type":"string"},
+			"indexKey":{"type":"string"},
+			"bodyNode":{"type":"string"},
+			"maxIterations":{"type":"integer","minimum":0},
+			"breakOnError":{"type":"boolean"}}}`),
+		Builtin: true,
+	},
+
+	// Built through the registry
+	{
+		Type:        "transform",
+		Description: "Builds a new object from paths into the input",
+		Schema: json.RawMessage(`{"type":"object","required":["mappings"],"properties":{
+			"mappings":{"type":"object","additionalProperties":{"type":"string"},"description":"Output key to input path"}}}`),
+		Factory: func(config json.RawMessage) (Node, error) {
+			var c struct {
+				ID       string            `json:"id"`
+				Mappings map[string]string `json:"mappings"`
+			}
+			if err := decodeConfig(config, &c); err != nil {
+				return nil, err
+			}
+			return NewTransformNode(c.ID, c.Mappings), nil
+		},
+	},
+	{
+		Type:        "delay",
+		Description: "Waits before passing the input on",
+		Schema: json.RawMessage(`{"type":"object","required":["duration"],"properties":{
+			"duration":{"type":"string","description":"Go duration, e.g. 30s"}}}`),
+		Factory: func(config json.RawMessage) (Node, error) {
+			var c struct {
+				ID       string `json:"id"`
+				Duration string `json:"duration"`
+			}
+			if err := decodeConfig(config, &c); err != nil {
+				return nil, err
+			}
+			d, err := parseOptionalDuration("duration", c.Duration)
+			if err != nil {
+				return nil, err
+			}
+			return NewDelayNode(c.ID, d), nil
+		},
+	},
+	{
+		Type:        "rate_limit",
+		Description: "Passes the input on at no more than the configured rate, shared by every execution using the same key",
+		Schema: json.RawMessage(`{"type":"object","required":["rps"],"properties":{
+			"key":{"type":"string","description":"Template naming the shared limit, defaults to the node ID"},
+			"rps":{"type":"number","minimum":0},
+			"burst":{"type":"integer","minimum":1},
+			"mode":{"type":"string","enum":["queue","drop"]}}}`),
+		Factory: func(config json.RawMessage) (Node, error) {
+			var c struct {
+				ID  string `json:"id"`
+				Key string `json:"key"`
+				RateLimit
+			}
+			if err := decodeConfig(config, &c); err != nil {
+				return nil, err
+			}
+			if err := c.RateLimit.validate(); err != nil {
+				return nil, err
+			}
+			return NewRateLimitNode(c.ID, c.Key, c.RateLimit), nil
+		},
+	},
+	{
+		Type:        "webhook_action",
+		Description: "Sends an HTTP request and maps fields of the response into the output",
+		Schema: json.RawMessage(`{"type":"object","required":["url"],"properties":{
+			"url":{"type":"string"},
+			"method":{"type":"string"},
+			"headers":{"type":"object","additionalProperties":{"type":"string"}},
+			"body":{"type":"string"},
+			"timeout":{"type":"string","description":"Go duration, defaults to 30s"},
+			"retryCount":{"type":"integer","minimum":0},
+			"retryDelay":{"type":"string","description":"Go duration"},
+			"outputMap":{"type":"object","additionalProperties":{"type":"string"},"description":"Output key to JSONPath into the response body"},
+			"rateLimit":` + rateLimitSchema + `,
+			"captureDeliveries":{"type":"boolean","description":"Archive every request and response for debugging"}}}`),
+		SideEffects: true,
+		Factory: func(config json.RawMessage) (Node, error) {
+			var c struct {
+				ID         string            `json:"id"`
+				URL        string            `json:"url"`
+				Method     string            `json:"method"`
+				Headers    map[string]string `json:"headers"`
+				Body       string            `json:"body"`
+				Timeout    string            `json:"timeout"`
+				RetryCount int               `json:"retryCount"`
+				RetryDelay string            `json:"retryDelay"`
+				OutputMap  map[string]string `json:"outputMap"`
+				RateLimit  *RateLimit        `json:"rateLimit"`
+				Capture    bool              `json:"captureDeliveries"`
+			}
+			if err := decodeConfig(config, &c); err != nil {
+				return nil, err
+			}
+			if c.URL == "" {
+				return nil, fmt.Errorf("url is required")
+			}
+			if c.RateLimit != nil {
+				if err := c.RateLimit.validate(); err != nil {
+					return nil, fmt.Errorf("rateLimit: %w", err)
+				}
+			}
+			timeout, err := parseOptionalDuration("timeout", c.Timeout)
+			if err != nil {
+				return nil, err
+			}
+			retryDelay, err := parseOptionalDuration("retryDelay", c.RetryDelay)
+			if err != nil {
+				return nil, err
+			}
+			return NewWebhookActionNode(WebhookActionConfig{
+				ID:                c.ID,
+				URL:               c.URL,
+				Method:            c.Method,
+				Headers:           c.Headers,
+				Body:              c.Body,
+				Timeout:           timeout,
+				RetryCount:        c.RetryCount,
+				RetryDelay:        retryDelay,
+				OutputMap:         c.OutputMap,
+				RateLimit:         c.RateLimit,
+				CaptureDeliveries: c.Capture,
+			}), nil
+		},
+	},
+	{
+		Type:        "slack",
+		Description: "Posts a message to a Slack incoming webhook",
+		Schema: json.RawMessage(`{"type":"object","required":["webhook_url","text"],"properties":{
+			"webhook_url":{"type":"string"},
+			"channel":{"type":"string"},
+			"text":{"type":"string"},
+			"blocks":{"type":"array"},
+			"username":{"type":"string"},
+			"icon_emoji":{"type":"string"}}}`),
+		SideEffects: true,
+		Factory: func(config json.RawMessage) (Node, error) {
+			var c struct {
+				ID         string          `json:"id"`
+				WebhookURL string          `json:"webhook_url"`
+				Channel    string          `json:"channel"`
+				Text       string          `json:"text"`
+				Blocks     json.RawMessage `json:"blocks"`
+				Username   string          `json:"username"`
+				IconEmoji  string          `json:"icon_emoji"`
+			}
+			if err := decodeConfig(config, &c); err != nil {
+				return nil, err
+			}
+			if c.WebhookURL == "" {
+				return nil, fmt.Errorf("webhook_url is required")
+			}
+			n := NewSlackActionNode(SlackConfig{
+				ID:         c.ID,
+				WebhookURL: c.WebhookURL,
+				Username:   c.Username,
+				IconEmoji:  c.IconEmoji,
+			})
+			n.Channel = c.Channel
+			n.Text = c.Text
+			n.Blocks = c.Blocks
+			return n, nil
+		},
+	},
+	{
+		Type:        "email",
+		Description: "Sends an email over SMTP",
+		Schema: json.RawMessage(`{"type":"object","required":["to","from","smtp_host"],"properties":{
+			"to":{"type":"string","description":"Comma-separated addresses, may be a template"},
+			"subject":{"type":"string"},
+			"body":{"type":"string","description":"HTML, may be a template"},
+			"from":{"type":"string"},
+			"smtp_host":{"type":"string"},
+			"smtp_port":{"type":"string","description":"Defaults to 587"},
+			"username":{"type":"string"},
+			"password":{"type":"string"}}}`),
+		SideEffects: true,
+		Factory: func(config json.RawMessage) (Node, error) {
+			var c struct {
+				ID       string `json:"id"`
+				To       string `json:"to"`
+				Subject  string `json:"subject"`
+				Body     string `json:"body"`
+				From     string `json:"from"`
+				SMTPHost string `json:"smtp_host"`
+				SMTPPort string `json:"smtp_port"`
+				Username string `json:"username"`
+				Password string `json:"password"`
+			}
+			if err := decodeConfig(config, &c); err != nil {
+				return nil, err
+			}
+			if c.To == "" || c.From == "" || c.SMTPHost == "" {
+				return nil, fmt.Errorf("to, from and smtp_host are required")
+			}
+			if c.SMTPPort == "" {
+				c.SMTPPort = "587"
+			}
+			n := NewEmailActionNode(EmailConfig{
+				ID:       c.ID,
+				SMTPHost: c.SMTPHost,
+				SMTPPort: c.SMTPPort,
+				From:     c.From,
+				Username: c.Username,
+				Password: c.Password,
+			})
+			n.To = c.To
+			n.Subject = c.Subject
+			n.Body = c.Body
+			return n, nil
+		},
+	},
+	{
+		// Describes its own side effects during dry runs
+		Type:        "refund_action",
+		Description: "Refunds a payment intent with an execution-scoped idempotency key",
+		Schema: json.RawMessage(`{"type":"object","required":["base_url","payment_intent_id"],"properties":{
+			"base_url":{"type":"string"},
+			"payment_intent_id":{"type":"string"},
+			"headers":{"type":"object","additionalProperties":{"type":"string"}},
+			"timeout":{"type":"string","description":"Go duration, defaults to 30s"}}}`),
+		Factory: func(config json.RawMessage) (Node, error) {
+			var c struct {
+				ID              string            `json:"id"`
+				BaseURL         string            `json:"base_url"`
+				PaymentIntentID string            `json:"payment_intent_id"`
+				Headers         map[string]string `json:"headers"`
+				Timeout         string            `json:"timeout"`
+			}
+			if err := decodeConfig(config, &c); err != nil {
+				return nil, err
+			}
+			if c.BaseURL == "" || c.PaymentIntentID == "" {
+				return nil, fmt.Errorf("base_url and payment_intent_id are required")
+			}
+			timeout, err := parseOptionalDuration("timeout", c.Timeout)
+			if err != nil {
+				return nil, err
+			}
+			return NewRefundActionNode(RefundActionConfig{
+				ID:              c.ID,
+				BaseURL:         c.BaseURL,
+				PaymentIntentID: c.PaymentIntentID,
+				Headers:         c.Headers,
+				Timeout:         timeout,
+			}), nil
+		},
+	},
+	{
+		Type:        "ledger_action",
+		Description: "Records a balanced transaction moving the amount from the debit to the credit account",
+		Schema: json.RawMessage(`{"type":"object","required":["debit_account","credit_account","amount","currency"],"properties":{
+			"debit_account":{"type":"string"},
+			"credit_account":{"type":"string"},
+			"amount":{"type":"string","description":"Template resolving to an integer amount in cents"},
+			"currency":{"type":"string"},
+			"description":{"type":"string"},
+			"reference_id":{"type":"string","description":"Scoped to the execution, which records the transaction once"},
+			"zone_id":{"type":"string","description":"Defaults to the input's zone_id"},
+			"mode":{"type":"string","enum":["test","live"],"description":"Defaults to the input's mode, then live"}}}`),
+		SideEffects: true,
+		Factory: func(config json.RawMessage) (Node, error) {
+			var c struct {
+				ID            string `json:"id"`
+				DebitAccount  string `json:"debit_account"`
+				CreditAccount string `json:"credit_account"`
+				Amount        string `json:"amount"`
+				Currency      string `json:"currency"`
+				Description   string `json:"description"`
+				ReferenceID   string `json:"reference_id"`
+				ZoneID        string `json:"zone_id"`
+				Mode          string `json:"mode"`
+			}
+			if err := decodeConfig(config, &c); err != nil {
+				return nil, err
+			}
+			if c.DebitAccount == "" || c.CreditAccount == "" || c.Amount == "" || c.Currency == "" {
+				return nil, fmt.Errorf("debit_account, credit_account, amount and currency are required")
+			}
+			return NewLedgerActionNode(LedgerActionConfig{
+				ID:            c.ID,
+				DebitAccount:  c.DebitAccount,
+				CreditAccount: c.CreditAccount,
+				Amount:        c.Amount,
+				Currency:      c.Currency,
+				Description:   c.Description,
+				ReferenceID:   c.ReferenceID,
+				ZoneID:        c.ZoneID,
+				Mode:          c.Mode,
+			}), nil
+		},
+	},
+	{
+		Type:        "notify_action",
+		Description: "Enqueues a notification for the notifications service to render and deliver",
+		Schema: json.RawMessage(`{"type":"object","required":["channel","template_id","recipient"],"properties":{
+			"channel":{"type":"string","enum":["email","sms","web"]},
+			"template_id":{"type":"string"},
+			"recipient":{"type":"string"},
+			"user_id":{"type":"string"},
+			"data":{"type":"object","additionalProperties":{"type":"string"},"description":"Template values"}}}`),
+		SideEffects: true,
+		Factory: func(config json.RawMessage) (Node, error) {
+			var c struct {
+				ID         string               `json:"id"`
+				Channel    notification.Channel `json:"channel"`
+				TemplateID string               `json:"template_id"`
+				Recipient  string               `json:"recipient"`
+				UserID     string               `json:"user_id"`
+				Data       map[string]string    `json:"data"`
+			}
+			if err := decodeConfig(config, &c); err != nil {
+				return nil, err
+			}
+			if c.Channel == "" || c.TemplateID == "" || c.Recipient == "" {
+				return nil, fmt.Errorf("channel, template_id and recipient are required")
+			}
+			return NewNotifyActionNode(NotifyActionConfig{
+				ID:         c.ID,
+				Channel:    c.Channel,
+				TemplateID: c.TemplateID,
+				Recipient:  c.Recipient,
+				UserID:     c.UserID,
+				Data:       c.Data,
+			}), nil
+		},
+	},
+	{
+		Type:        "state_get",
+		Description: "Reads a value stored by the zone's flows",
+		Schema:      json.RawMessage(stateSchema(`"key":{"type":"string"}`)),
+		Factory: func(config json.RawMessage) (Node, error) {
+			c, err := decodeStateConfig(config)
+			if err != nil {
+				return nil, err
+			}
+			return NewStateGetNode(c), nil
+		},
+	},
+	{
+		// Writes are persisted, so they are skipped during dry runs
+		Type:        "state_set",
+		Description: "Stores a value for the zone's flows, replacing any previous one",
+		Schema: json.RawMessage(stateSchema(`"key":{"type":"string"},
+			"value":{"type":"string"},
+			"ttl":{"type":"string","description":"Go duration, keeps the key forever when empty"}`)),
+		SideEffects: true,
+		Factory: func(config json.RawMessage) (Node, error) {
+			c, err := decodeStateConfig(config)
+			if err != nil {
+				return nil, err
+			}
+			return NewStateSetNode(c), nil
+		},
+	},
+	{
+		Type:        "state_increment",
+		Description: "Adds to a counter of the zone's flows, expiring it a TTL after its first increment",
+		Schema: json.RawMessage(stateSchema(`"key":{"type":"string"},
+			"by":{"type":"integer","description":"Defaults to 1"},
+			"ttl":{"type":"string","description":"Go duration, keeps the counter forever when empty"}`)),
+		SideEffects: true,
+		Factory: func(config json.RawMessage) (Node, error) {
+			c, err := decodeStateConfig(config)
+			if err != nil {
+				return nil, err
+			}
+			return NewStateIncrementNode(c), nil
+		},
+	},
+}
+
+// stateSchema describes the config of a state node with the given
+// properties besides the shared ones
+func stateSchema(properties string) string {
+	return `{"type":"object","required":["key"],"properties":{` + properties + `,
+			"zone_id":{"type":"string","description":"Outside flows only, which use their own zone"},
+			"output_key":{"type":"string","description":"Defaults to value"}}}`
+}
+
+// decodeStateConfig decodes the config shared by the state nodes
+func decodeStateConfig(config json.RawMessage) (StateConfig, error) {
+	var c struct {
+		ID        string `json:"id"`
+		ZoneID    string `json:"zone_id"`
+		Key       string `json:"key"`
+		Value     string `json:"value"`
+		By        int64  `json:"by"`
+		TTL       string `json:"ttl"`
+		OutputKey string `json:"output_key"`
+	}
+	if err := decodeConfig(config, &c); err != nil {
+		return StateConfig{}, err
+	}
+	if c.Key == "" {
+		return StateConfig{}, fmt.Errorf("key is required")
+	}
+	ttl, err := parseOptionalDuration("ttl", c.TTL)
+	if err != nil {
+		return StateConfig{}, err
+	}
+	return StateConfig{
+		ID:        c.ID,
+		ZoneID:    c.ZoneID,
+		Key:       c.Key,
+		Value:     c.Value,
+		By:        c.By,
+		TTL:       ttl,
+		OutputKey: c.OutputKey,
+	}, nil
+}
+
+// rateLimitSchema describes a RateLimit in node config schemas
+const rateLimitSchema = `{"type":"object","required":["rps"],"properties":{
+	"rps":{"type":"number","minimum":0},
+	"burst":{"type":"integer","minimum":1},
+	"mode":{"type":"string","enum":["queue","drop"]}}}`
+
+func parseOptionalDuration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return d, nil
+}
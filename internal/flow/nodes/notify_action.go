@@ -0,0 +1,126 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/notification"
+)
+
+// NotifyActionNode hands a notification to the notifications service by
+// publishing it to the RabbitMQ queue of its channel, where the service's
+// workers render the template and deliver it
+type NotifyActionNode struct {
+	NodeID     string                       `json:"id"`
+	Channel    notification.Channel         `json:"channel"` // email, sms or web
+	TemplateID string                       `json:"template_id"`
+	Recipient  string                       `json:"recipient"`         // Template: {{customer.email}}
+	UserID     string                       `json:"user_id,omitempty"` // Template
+	Data       map[string]string            `json:"data,omitempty"`    // Template values passed to the notification template
+	NextNode   string                       `json:"next,omitempty"`
+	publisher  notification.RabbitPublisher `json:"-"`
+}
+
+// NotifyActionConfig is used to create a new notify action node
+type NotifyActionConfig struct {
+	ID         string
+	Channel    notification.Channel
+	TemplateID string
+	Recipient  string
+	UserID     string
+	Data       map[string]string
+	NextNode   string
+	Publisher  notification.RabbitPublisher
+}
+
+// NewNotifyActionNode creates a new notify action node
+func NewNotifyActionNode(config NotifyActionConfig) *NotifyActionNode {
+	return &NotifyActionNode{
+		NodeID:     config.ID,
+		Channel:    config.Channel,
+		TemplateID: config.TemplateID,
+		Recipient:  config.Recipient,
+		UserID:     config.UserID,
+		Data:       config.Data,
+		NextNode:   config.NextNode,
+		publisher:  config.Publisher,
+	}
+}
+
+// ID returns the node ID
+func (n *NotifyActionNode) ID() string { return n.NodeID }
+
+// Type returns the node type
+func (n *NotifyActionNode) Type() string { return "notify_action" }
+
+// Execute resolves the notification request from the input and enqueues it
+func (n *NotifyActionNode) Execute(ctx context.Context, input map[string]interface{}) (*NodeResult, error) {
+	req := &notification.NotificationRequest{
+		UserID:     resolveTemplate(n.UserID, input),
+		Recipient:  resolveTemplate(n.Recipient, input),
+		Channel:    n.Channel,
+		TemplateID: n.TemplateID,
+		Data:       make(map[string]string, len(n.Data)),
+	}
+	for key, value := range n.Data {
+		req.Data[key] = resolveTemplate(value, input)
+	}
+
+	switch req.Channel {
+	case notification.Email, notification.SMS, notification.Web:
+	default:
+		return &NodeResult{
+			Success: false,
+			Error:   fmt.Sprintf("unsupported channel %q", req.Channel),
+		}, fmt.Errorf("unsupported channel %q", req.Channel)
+	}
+	if req.Recipient == "" || req.TemplateID == "" {
+		return &NodeResult{
+			Success: false,
+			Error:   "recipient and template_id are required",
+		}, fmt.Errorf("recipient and template_id are required")
+	}
+
+	// Retried executions reuse the task ID so workers deliver only once
+	taskID := fmt.Sprintf("flow_%d_%s", time.Now().UnixNano(), n.NodeID)
+	if execID := domain.IdempotencyScopeFromContext(ctx); execID != "" {
+		taskID = fmt.Sprintf("flow_%s_%s_%s", execID, n.NodeID, req.Recipient)
+	}
+	queue := notification.QueueFor(req.Channel)
+
+	output := map[string]interface{}{
+		"task_id":     taskID,
+		"queue":       queue,
+		"channel":     string(req.Channel),
+		"recipient":   req.Recipient,
+		"template_id": req.TemplateID,
+	}
+	if domain.IsSimulation(ctx) {
+		output["status"] = "simulated"
+		return &NodeResult{Success: true, Output: output, Next: n.NextNode}, nil
+	}
+
+	if n.publisher == nil {
+		return &NodeResult{
+			Success: false,
+			Error:   "notification publisher not configured",
+		}, fmt.Errorf("notification publisher not configured")
+	}
+
+	body, err := json.Marshal(notification.TaskFromRequest(taskID, req))
+	if err != nil {
+		return nil, err
+	}
+	if err := n.publisher.Publish(ctx, queue, body); err != nil {
+		return &NodeResult{
+			Success: false,
+			Error:   fmt.Sprintf("failed to enqueue notification: %v", err),
+		}, err
+	}
+
+	output["status"] = "queued"
+	return &NodeResult{Success: true, Output: output, Next: n.NextNode}, nil
+}
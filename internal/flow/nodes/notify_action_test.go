@@ -0,0 +1,132 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/testutil"
+	"github.com/sapliy/fintech-ecosystem/internal/notification"
+)
+
+// recordingPublisher keeps the messages published through it
+type recordingPublisher struct {
+	queues []string
+	tasks  []notification.NotificationTask
+	err    error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, queue string, body []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	var task notification.NotificationTask
+	if err := json.Unmarshal(body, &task); err != nil {
+		return err
+	}
+	p.queues = append(p.queues, queue)
+	p.tasks = append(p.tasks, task)
+	return nil
+}
+
+func notifyFlow(config string) *domain.Flow {
+	return &domain.Flow{
+		ID: "flow_receipts", ZoneID: "zone_1",
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger},
+			{ID: "notify", Type: "notify_action", Data: json.RawMessage(config)},
+		},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "notify"}},
+	}
+}
+
+func TestNotifyActionNode_EnqueuesThroughRegistry(t *testing.T) {
+	publisher := &recordingPublisher{}
+	registry := DefaultNodeRegistry()
+	registry.UseClients(NodeClients{Notifications: publisher})
+	repo := testutil.NewMockFlowRepository()
+	runner := domain.NewFlowRunner(repo)
+	registry.Install(runner)
+
+	flow := notifyFlow(`{"channel":"email","template_id":"receipt","recipient":"{{customer.email}}",
+		"user_id":"{{customer.id}}","data":{"Amount":"{{amount}}"}}`)
+	input := map[string]interface{}{
+		"customer": map[string]interface{}{"email": "ada@example.com", "id": "user_1"},
+		"amount":   "10.00",
+	}
+	if err := runner.Execute(context.Background(), flow, input); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if len(publisher.tasks) != 1 || publisher.queues[0] != notification.QueueFor(notification.Email) {
+		t.Fatalf("Expected one task on the email queue, got %v", publisher.queues)
+	}
+	task := publisher.tasks[0]
+	if task.Recipient != "ada@example.com" || task.TemplateID != "receipt" || task.Channel != notification.Email {
+		t.Errorf("Expected the resolved request, got %+v", task)
+	}
+	if task.Data["Amount"] != "10.00" || task.Data["UserID"] != "user_1" {
+		t.Errorf("Expected the resolved template data, got %v", task.Data)
+	}
+
+	// The task ID is derived from the execution so workers deliver a
+	// retried execution's notification once
+	executions, _ := repo.ListExecutions(context.Background(), "flow_receipts", 10, 0)
+	if len(executions) != 1 || task.ID != "flow_"+executions[0].ID+"_notify_ada@example.com" {
+		t.Errorf("Expected a task ID derived from the execution, got %q", task.ID)
+	}
+}
+
+func TestNotifyActionNode_NotEnqueuedInDryRun(t *testing.T) {
+	publisher := &recordingPublisher{}
+	registry := DefaultNodeRegistry()
+	registry.UseClients(NodeClients{Notifications: publisher})
+	runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+	registry.Install(runner)
+
+	flow := notifyFlow(`{"channel":"sms","template_id":"alert","recipient":"+15550100"}`)
+	if _, err := runner.DryRun(context.Background(), flow, map[string]interface{}{}); err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if len(publisher.tasks) != 0 {
+		t.Errorf("Expected nothing enqueued during a dry run, got %v", publisher.tasks)
+	}
+}
+
+func TestNotifyActionNode_Failures(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		publisher *recordingPublisher
+		want      string
+	}{
+		{"unsupported channel", `{"channel":"pager","template_id":"alert","recipient":"ops"}`, &recordingPublisher{}, "unsupported channel"},
+		{"unresolved recipient", `{"channel":"email","template_id":"alert","recipient":"{{missing}}"}`, &recordingPublisher{}, "recipient and template_id are required"},
+		{"publish error", `{"channel":"web","template_id":"alert","recipient":"user_1"}`, &recordingPublisher{err: errors.New("connection closed")}, "connection closed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := DefaultNodeRegistry()
+			registry.UseClients(NodeClients{Notifications: tt.publisher})
+			runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+			registry.Install(runner)
+
+			err := runner.Execute(context.Background(), notifyFlow(tt.config), map[string]interface{}{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected the execution to fail with %q, got %v", tt.want, err)
+			}
+			if len(tt.publisher.tasks) != 0 {
+				t.Errorf("Expected nothing enqueued, got %v", tt.publisher.tasks)
+			}
+		})
+	}
+
+	// Configs the node cannot be built from are reported before saving
+	if problems := DefaultNodeRegistry().ValidateConfig("notify_action", json.RawMessage(`{"channel":"email"}`)); len(problems) == 0 {
+		t.Error("Expected a config without template_id and recipient to be invalid")
+	}
+}
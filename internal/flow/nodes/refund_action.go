@@ -0,0 +1,148 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// RefundActionNode refunds a payment intent through the payments service.
+// Requests carry an idempotency key derived from the flow execution, or from
+// the execution a dead-letter retry retries, so a retried or recovered
+// execution never refunds the same payment twice.
+type RefundActionNode struct {
+	NodeID          string            `json:"id"`
+	BaseURL         string            `json:"base_url"`          // e.g. http://payments:8082 or the gateway's /v1/payments
+	PaymentIntentID string            `json:"payment_intent_id"` // Template: {{data.payment_id}}
+	Headers         map[string]string `json:"headers,omitempty"` // Templates; must identify the acting user (X-User-ID or Authorization)
+	NextNode        string            `json:"next,omitempty"`
+	client          *http.Client      `json:"-"`
+}
+
+// RefundActionConfig is used to create a new refund action node
+type RefundActionConfig struct {
+	ID              string
+	BaseURL         string
+	PaymentIntentID string
+	Headers         map[string]string
+	Timeout         time.Duration
+	NextNode        string
+}
+
+// NewRefundActionNode creates a new refund action node
+func NewRefundActionNode(config RefundActionConfig) *RefundActionNode {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &RefundActionNode{
+		NodeID:          config.ID,
+		BaseURL:         strings.TrimSuffix(config.BaseURL, "/"),
+		PaymentIntentID: config.PaymentIntentID,
+		Headers:         config.Headers,
+		NextNode:        config.NextNode,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+	}
+}
+
+// ID returns the node ID
+func (n *RefundActionNode) ID() string { return n.NodeID }
+
+// Type returns the node type
+func (n *RefundActionNode) Type() string { return "refund_action" }
+
+// Execute requests the refund of the resolved payment intent
+func (n *RefundActionNode) Execute(ctx context.Context, input map[string]interface{}) (*NodeResult, error) {
+	intentID := strings.TrimSpace(resolveTemplate(n.PaymentIntentID, input))
+	if intentID == "" {
+		return &NodeResult{
+			Success: false,
+			Error:   "payment intent ID not resolved",
+		}, fmt.Errorf("payment intent ID not resolved")
+	}
+
+	idempotencyKey := n.idempotencyKey(ctx, intentID)
+	if domain.IsSimulation(ctx) {
+		return &NodeResult{
+			Success: true,
+			Output: map[string]interface{}{
+				"payment_intent_id": intentID,
+				"idempotency_key":   idempotencyKey,
+				"status":            "simulated",
+			},
+			Next: n.NextNode,
+		}, nil
+	}
+
+	url := fmt.Sprintf("%s/intents/%s/refund", n.BaseURL, intentID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader("{}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range n.Headers {
+		req.Header.Set(key, resolveTemplate(value, input))
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return &NodeResult{
+			Success: false,
+			Error:   fmt.Sprintf("refund request failed: %v", err),
+		}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &NodeResult{
+			Success: false,
+			Error:   fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
+		}, fmt.Errorf("refund of %s failed with HTTP %d", intentID, resp.StatusCode)
+	}
+
+	var intent map[string]interface{}
+	json.Unmarshal(body, &intent)
+
+	return &NodeResult{
+		Success: true,
+		Output: map[string]interface{}{
+			"payment_intent_id": intentID,
+			"idempotency_key":   idempotencyKey,
+			"status":            intent["status"],
+			"amount":            intent["amount"],
+			"currency":          intent["currency"],
+			"replayed":          resp.Header.Get("X-Idempotency-Hit") == "true",
+		},
+		Next: n.NextNode,
+	}, nil
+}
+
+// idempotencyKey is unique per execution, node and payment so that loops
+// refunding several payments get distinct keys. Retries of a dead letter
+// share the key of the execution they retry. It is empty outside an
+// execution.
+func (n *RefundActionNode) idempotencyKey(ctx context.Context, intentID string) string {
+	execID := domain.IdempotencyScopeFromContext(ctx)
+	if execID == "" {
+		return ""
+	}
+	return fmt.Sprintf("flow_%s_%s_%s", execID, n.NodeID, intentID)
+}
@@ -0,0 +1,146 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/testutil"
+)
+
+// refundServer answers refunds with the given status and keeps the
+// idempotency keys it was sent
+type refundServer struct {
+	*httptest.Server
+	keys []string
+}
+
+func newRefundServer(t *testing.T, status int, header http.Header) *refundServer {
+	s := &refundServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/intents/pi_1/refund" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		s.keys = append(s.keys, r.Header.Get("Idempotency-Key"))
+		for key := range header {
+			w.Header().Set(key, header.Get(key))
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"status":"refunded","amount":500,"currency":"USD"}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func refundFlow(baseURL string) *domain.Flow {
+	config, _ := json.Marshal(map[string]string{"base_url": baseURL, "payment_intent_id": "{{payment_id}}"})
+	return &domain.Flow{
+		ID: "flow_refunds", ZoneID: "zone_1",
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "refund", Type: "refund_action", Data: config}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "refund"}},
+	}
+}
+
+func TestRefundActionNode_IdempotencyKey(t *testing.T) {
+	server := newRefundServer(t, http.StatusOK, nil)
+	repo := testutil.NewMockFlowRepository()
+	runner := domain.NewFlowRunner(repo)
+	DefaultNodeRegistry().Install(runner)
+	flow := refundFlow(server.URL)
+	input := map[string]interface{}{"payment_id": "pi_1"}
+
+	for i := 0; i < 2; i++ {
+		if err := runner.Execute(context.Background(), flow, input); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+	}
+	execs, _ := repo.ListExecutions(context.Background(), flow.ID, 10, 0)
+	if len(server.keys) != 2 || len(execs) != 2 {
+		t.Fatalf("Expected 2 refunds from 2 executions, got %d from %d", len(server.keys), len(execs))
+	}
+	for _, key := range server.keys {
+		if !strings.HasPrefix(key, "flow_exec_") || !strings.HasSuffix(key, "_refund_pi_1") {
+			t.Errorf("Expected a key of the execution, node and payment, got %q", key)
+		}
+	}
+	if server.keys[0] == server.keys[1] {
+		t.Error("Expected each execution to refund with its own key")
+	}
+
+	// Executions retrying another one reuse its keys
+	for i := 0; i < 2; i++ {
+		if err := runner.Execute(domain.WithIdempotencyScope(context.Background(), "exec_origin"), flow, input); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+	}
+	for _, key := range server.keys[2:] {
+		if key != "flow_exec_origin_refund_pi_1" {
+			t.Errorf("Expected the original execution's key, got %q", key)
+		}
+	}
+
+	// Outside an execution no key is sent
+	node := NewRefundActionNode(RefundActionConfig{ID: "refund", BaseURL: server.URL, PaymentIntentID: "pi_1"})
+	if _, err := node.Execute(context.Background(), input); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if key := server.keys[len(server.keys)-1]; key != "" {
+		t.Errorf("Expected no key outside an execution, got %q", key)
+	}
+}
+
+func TestRefundActionNode_Response(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		header       http.Header
+		wantErr      bool
+		wantReplayed bool
+	}{
+		{"Refunded", http.StatusOK, nil, false, false},
+		{"Replayed by the payments service", http.StatusOK, http.Header{"X-Idempotency-Hit": {"true"}}, false, true},
+		{"Rejected", http.StatusConflict, nil, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRefundServer(t, tt.status, tt.header)
+			node := NewRefundActionNode(RefundActionConfig{ID: "refund", BaseURL: server.URL + "/", PaymentIntentID: "{{payment_id}}"})
+
+			result, err := node.Execute(context.Background(), map[string]interface{}{"payment_id": "pi_1"})
+			if (err != nil) != tt.wantErr || result.Success == tt.wantErr {
+				t.Fatalf("Expected failure %v, got %v (%+v)", tt.wantErr, err, result)
+			}
+			if tt.wantErr {
+				if !strings.HasPrefix(result.Error, "HTTP 409") {
+					t.Errorf("Expected the status in the error, got %q", result.Error)
+				}
+				return
+			}
+			if result.Output["replayed"] != tt.wantReplayed || result.Output["status"] != "refunded" || result.Output["amount"] != 500.0 {
+				t.Errorf("Unexpected output %v", result.Output)
+			}
+		})
+	}
+}
+
+func TestRefundActionNode_Simulation(t *testing.T) {
+	server := newRefundServer(t, http.StatusOK, nil)
+	node := NewRefundActionNode(RefundActionConfig{ID: "refund", BaseURL: server.URL, PaymentIntentID: "{{payment_id}}"})
+
+	result, err := node.Execute(domain.WithSimulation(context.Background()), map[string]interface{}{"payment_id": "pi_1"})
+	if err != nil || !result.Success || result.Output["status"] != "simulated" || result.Output["payment_intent_id"] != "pi_1" {
+		t.Fatalf("Expected a simulated refund, got %+v, %v", result, err)
+	}
+	if len(server.keys) != 0 {
+		t.Errorf("Expected a dry run not to call the payments service, got %d calls", len(server.keys))
+	}
+
+	if _, err := node.Execute(context.Background(), map[string]interface{}{}); err == nil {
+		t.Error("Expected an unresolved payment intent to fail")
+	}
+}
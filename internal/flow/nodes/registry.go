@@ -0,0 +1,203 @@
+package nodes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/notification"
+	pb "github.com/sapliy/fintech-ecosystem/proto/ledger"
+)
+
+// Factory builds a node from the configuration persisted in a flow node's
+// data. When run by a flow, the config carries the flow node's ID as "id".
+type Factory func(config json.RawMessage) (Node, error)
+
+// NodeTypeDefinition describes a node type available to flows
+type NodeTypeDefinition struct {
+	Type        string          `json:"type"`
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema"` // JSON Schema of the node's config
+	// SideEffects marks nodes that reach external systems. They are not run
+	// during dry runs.
+	SideEffects bool `json:"side_effects"`
+	// Builtin marks types executed by the flow runner itself, which have no
+	// factory
+	Builtin bool    `json:"builtin"`
+	Factory Factory `json:"-"`
+}
+
+// NodeRegistry maps node types to the factories that build them. Custom node
+// types are added with Register before the registry is installed on a runner.
+type NodeRegistry struct {
+	mu         sync.RWMutex
+	types      map[string]NodeTypeDefinition
+	deliveries domain.WebhookDeliveryStore
+	clients    NodeClients
+}
+
+// NodeClients are the connections of the node types reaching other
+// services. Nodes whose client is nil fail when they run.
+type NodeClients struct {
+	Ledger        pb.LedgerServiceClient       // ledger_action
+	Notifications notification.RabbitPublisher // notify_action
+	Redis         *redis.Client                // state_get, state_set and state_increment
+}
+
+// NewNodeRegistry creates an empty registry
+func NewNodeRegistry() *NodeRegistry {
+	return &NodeRegistry{types: make(map[string]NodeTypeDefinition)}
+}
+
+// Register adds a node type. Types can only be registered once.
+func (r *NodeRegistry) Register(def NodeTypeDefinition) error {
+	if def.Type == "" {
+		return fmt.Errorf("node type is required")
+	}
+	if def.Factory == nil && !def.Builtin {
+		return fmt.Errorf("node type %s has no factory", def.Type)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.types[def.Type]; ok {
+		return fmt.Errorf("node type %s is already registered", def.Type)
+	}
+	r.types[def.Type] = def
+	return nil
+}
+
+// CaptureDeliveries archives the deliveries of webhook action nodes built
+// by the registry that have capturing enabled
+func (r *NodeRegistry) CaptureDeliveries(store domain.WebhookDeliveryStore) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries = store
+}
+
+// UseClients sets the connections handed to the nodes built by the registry
+func (r *NodeRegistry) UseClients(clients NodeClients) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients = clients
+}
+
+// Get returns the definition of a node type
+func (r *NodeRegistry) Get(nodeType string) (NodeTypeDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	def, ok := r.types[nodeType]
+	return def, ok
+}
+
+// Types lists the registered node types ordered by type
+func (r *NodeRegistry) Types() []NodeTypeDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	defs := make([]NodeTypeDefinition, 0, len(r.types))
+	for _, def := range r.types {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Type < defs[j].Type })
+	return defs
+}
+
+// New builds a node of the given type from its config
+func (r *NodeRegistry) New(nodeType string, config json.RawMessage) (Node, error) {
+	def, ok := r.Get(nodeType)
+	if !ok {
+		return nil, fmt.Errorf("unknown node type %s", nodeType)
+	}
+	if def.Factory == nil {
+		return nil, fmt.Errorf("node type %s is built into the runner", nodeType)
+	}
+	n, err := def.Factory(config)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	switch n := n.(type) {
+	case *WebhookActionNode:
+		n.deliveries = r.deliveries
+	case *LedgerActionNode:
+		n.client = r.clients.Ledger
+	case *NotifyActionNode:
+		n.publisher = r.clients.Notifications
+	case *StateGetNode:
+		n.rdb = r.clients.Redis
+	case *StateSetNode:
+		n.rdb = r.clients.Redis
+	case *StateIncrementNode:
+		n.rdb = r.clients.Redis
+	}
+	return n, nil
+}
+
+// Install makes the runner build nodes of every registered type through the
+// registry. Builtin types keep the runner's own handlers.
+func (r *NodeRegistry) Install(runner *domain.FlowRunner) {
+	for _, def := range r.Types() {
+		if def.Builtin {
+			continue
+		}
+		runner.RegisterHandler(domain.NodeType(def.Type), &registryHandler{registry: r, nodeType: def.Type})
+	}
+}
+
+// registryHandler runs a flow node by building it from its persisted data
+type registryHandler struct {
+	registry *NodeRegistry
+	nodeType string
+}
+
+func (h *registryHandler) Execute(ctx context.Context, node *domain.Node, input map[string]interface{}) (map[string]interface{}, error) {
+	def, ok := h.registry.Get(h.nodeType)
+	if !ok {
+		return nil, fmt.Errorf("unknown node type %s", h.nodeType)
+	}
+	if def.SideEffects && domain.IsSimulation(ctx) {
+		return map[string]interface{}{"status": "simulated"}, nil
+	}
+
+	n, err := h.registry.New(h.nodeType, withNodeID(node.Data, node.ID))
+	if err != nil {
+		return nil, fmt.Errorf("invalid config for node %s: %w", node.ID, err)
+	}
+	result, err := n.Execute(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("%s", result.Error)
+	}
+	if result.Output == nil {
+		return input, nil
+	}
+	return result.Output, nil
+}
+
+// decodeConfig decodes a node config, leaving v untouched when the config is
+// empty. Unknown fields, such as the editor's label, are ignored.
+func decodeConfig(config json.RawMessage, v interface{}) error {
+	if len(bytes.TrimSpace(config)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(config, v)
+}
+
+// withNodeID sets "id" in a node config to the flow node's ID
+func withNodeID(config json.RawMessage, id string) json.RawMessage {
+	fields := map[string]json.RawMessage{}
+	if err := decodeConfig(config, &fields); err != nil {
+		return config
+	}
+	fields["id"], _ = json.Marshal(id)
+	b, _ := json.Marshal(fields)
+	return b
+}
@@ -0,0 +1,130 @@
+package nodes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// jsonSchema is the subset of JSON Schema used by node config schemas
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+}
+
+// ValidateConfig checks a node config against its type's schema and, for
+// types built through the registry, that the node can be built from it. It
+// returns one message per problem found.
+func (r *NodeRegistry) ValidateConfig(nodeType string, config json.RawMessage) []string {
+	def, ok := r.Get(nodeType)
+	if !ok {
+		return []string{fmt.Sprintf("unknown node type %s", nodeType)}
+	}
+
+	var value interface{} = map[string]interface{}{}
+	if trimmed := bytes.TrimSpace(config); len(trimmed) > 0 && string(trimmed) != "null" {
+		if err := json.Unmarshal(config, &value); err != nil {
+			return []string{fmt.Sprintf("invalid config: %v", err)}
+		}
+	}
+
+	var problems []string
+	if len(def.Schema) > 0 {
+		var schema jsonSchema
+		if err := json.Unmarshal(def.Schema, &schema); err != nil {
+			return []string{fmt.Sprintf("invalid schema for node type %s: %v", nodeType, err)}
+		}
+		problems = schema.validate("config", value)
+	}
+	if len(problems) == 0 && def.Factory != nil {
+		if _, err := def.Factory(config); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
+
+func (s *jsonSchema) validate(path string, value interface{}) []string {
+	if s == nil {
+		return nil
+	}
+	if s.Type != "" && !hasSchemaType(value, s.Type) {
+		return []string{fmt.Sprintf("%s must be of type %s", path, s.Type)}
+	}
+
+	var problems []string
+	if len(s.Enum) > 0 {
+		allowed := false
+		for _, e := range s.Enum {
+			if compareEqual(value, e) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			problems = append(problems, fmt.Sprintf("%s must be one of %v", path, s.Enum))
+		}
+	}
+	if n, ok := value.(float64); ok && s.Minimum != nil && n < *s.Minimum {
+		problems = append(problems, fmt.Sprintf("%s must be at least %v", path, *s.Minimum))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s is required", path, name))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if prop, ok := s.Properties[k]; ok {
+				problems = append(problems, prop.validate(path+"."+k, v[k])...)
+			} else {
+				problems = append(problems, s.AdditionalProperties.validate(path+"."+k, v[k])...)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			problems = append(problems, s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+		}
+	}
+	return problems
+}
+
+func hasSchemaType(value interface{}, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "null":
+		return value == nil
+	}
+	return true
+}
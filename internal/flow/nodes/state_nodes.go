@@ -0,0 +1,230 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// State nodes read and write values that outlive a single execution, such
+// as counters ("failed payments for this user today") and flags. Keys are
+// templates resolved from the input and are namespaced per zone, so flows
+// in different zones never share state.
+
+// StateConfig is used to create the state nodes
+type StateConfig struct {
+	ID        string
+	ZoneID    string        // Outside flows only; defaults to the input's zone_id
+	Key       string        // Template: failed_payments:{{user_id}}
+	Value     string        // Template; StateSet only
+	By        int64         // StateIncrement only; defaults to 1
+	TTL       time.Duration // StateSet and StateIncrement; zero keeps the key forever
+	OutputKey string        // Output key for the value; defaults to "value"
+	NextNode  string
+	Redis     *redis.Client
+}
+
+// stateNode holds the fields shared by the state nodes
+type stateNode struct {
+	NodeID    string        `json:"id"`
+	ZoneID    string        `json:"zone_id,omitempty"`
+	Key       string        `json:"key"`
+	TTL       time.Duration `json:"ttl,omitempty"`
+	OutputKey string        `json:"output_key,omitempty"`
+	NextNode  string        `json:"next,omitempty"`
+	rdb       *redis.Client `json:"-"`
+}
+
+func newStateNode(config StateConfig) stateNode {
+	outputKey := config.OutputKey
+	if outputKey == "" {
+		outputKey = "value"
+	}
+	return stateNode{
+		NodeID:    config.ID,
+		ZoneID:    config.ZoneID,
+		Key:       config.Key,
+		TTL:       config.TTL,
+		OutputKey: outputKey,
+		NextNode:  config.NextNode,
+		rdb:       config.Redis,
+	}
+}
+
+// ID returns the node ID
+func (n *stateNode) ID() string { return n.NodeID }
+
+// redisKey resolves the zone-scoped Redis key for the input. Nodes run by
+// a flow use the flow's zone, so they keep it when a previous node's output
+// has no zone_id and never reach the state of another zone.
+func (n *stateNode) redisKey(ctx context.Context, input map[string]interface{}) (string, string, error) {
+	if n.rdb == nil {
+		return "", "", fmt.Errorf("redis client not configured")
+	}
+
+	zoneID := domain.NodeScopeFromContext(ctx).ZoneID
+	if zoneID == "" {
+		zoneID = n.ZoneID
+	}
+	if zoneID == "" {
+		zoneID, _ = input["zone_id"].(string)
+	}
+	if zoneID == "" {
+		return "", "", fmt.Errorf("zone_id not specified")
+	}
+
+	key := resolveTemplate(n.Key, input)
+	if key == "" {
+		return "", "", fmt.Errorf("state key resolved to an empty string")
+	}
+	return key, fmt.Sprintf("flow:state:%s:%s", zoneID, key), nil
+}
+
+func (n *stateNode) fail(err error) (*NodeResult, error) {
+	return &NodeResult{
+		Success: false,
+		Error:   err.Error(),
+	}, err
+}
+
+// StateGetNode reads a stored value. A missing key is not an error; the
+// output reports found=false and a nil value.
+type StateGetNode struct {
+	stateNode
+}
+
+// NewStateGetNode creates a new state get node
+func NewStateGetNode(config StateConfig) *StateGetNode {
+	return &StateGetNode{stateNode: newStateNode(config)}
+}
+
+// Type returns the node type
+func (n *StateGetNode) Type() string { return "state_get" }
+
+// Execute reads the value
+func (n *StateGetNode) Execute(ctx context.Context, input map[string]interface{}) (*NodeResult, error) {
+	key, redisKey, err := n.redisKey(ctx, input)
+	if err != nil {
+		return n.fail(err)
+	}
+
+	raw, err := n.rdb.Get(ctx, redisKey).Result()
+	if err != nil && err != redis.Nil {
+		return n.fail(fmt.Errorf("failed to read state %s: %w", key, err))
+	}
+
+	found := err == nil
+	var value interface{}
+	if found {
+		value = decodeStateValue(raw)
+	}
+
+	return &NodeResult{
+		Success: true,
+		Output: map[string]interface{}{
+			"key":       key,
+			"found":     found,
+			n.OutputKey: value,
+		},
+		Next: n.NextNode,
+	}, nil
+}
+
+// StateSetNode stores a value, replacing any previous one
+type StateSetNode struct {
+	stateNode
+	Value string `json:"value"`
+}
+
+// NewStateSetNode creates a new state set node
+func NewStateSetNode(config StateConfig) *StateSetNode {
+	return &StateSetNode{stateNode: newStateNode(config), Value: config.Value}
+}
+
+// Type returns the node type
+func (n *StateSetNode) Type() string { return "state_set" }
+
+// Execute stores the value
+func (n *StateSetNode) Execute(ctx context.Context, input map[string]interface{}) (*NodeResult, error) {
+	key, redisKey, err := n.redisKey(ctx, input)
+	if err != nil {
+		return n.fail(err)
+	}
+
+	raw := resolveTemplate(n.Value, input)
+	if err := n.rdb.Set(ctx, redisKey, raw, n.TTL).Err(); err != nil {
+		return n.fail(fmt.Errorf("failed to write state %s: %w", key, err))
+	}
+
+	return &NodeResult{
+		Success: true,
+		Output: map[string]interface{}{
+			"key":       key,
+			n.OutputKey: decodeStateValue(raw),
+		},
+		Next: n.NextNode,
+	}, nil
+}
+
+// StateIncrementNode atomically adds to a counter, creating it at zero. The
+// TTL is applied when the counter is created, so "per day" counters expire
+// a day after their first increment rather than being extended each time.
+type StateIncrementNode struct {
+	stateNode
+	By int64 `json:"by,omitempty"`
+}
+
+// NewStateIncrementNode creates a new state increment node
+func NewStateIncrementNode(config StateConfig) *StateIncrementNode {
+	by := config.By
+	if by == 0 {
+		by = 1
+	}
+	return &StateIncrementNode{stateNode: newStateNode(config), By: by}
+}
+
+// Type returns the node type
+func (n *StateIncrementNode) Type() string { return "state_increment" }
+
+// Execute increments the counter
+func (n *StateIncrementNode) Execute(ctx context.Context, input map[string]interface{}) (*NodeResult, error) {
+	key, redisKey, err := n.redisKey(ctx, input)
+	if err != nil {
+		return n.fail(err)
+	}
+
+	value, err := n.rdb.IncrBy(ctx, redisKey, n.By).Result()
+	if err != nil {
+		return n.fail(fmt.Errorf("failed to increment state %s: %w", key, err))
+	}
+	if n.TTL > 0 {
+		// A counter without an expiry was just created, possibly by a
+		// concurrent execution
+		if ttl, err := n.rdb.TTL(ctx, redisKey).Result(); err == nil && ttl < 0 {
+			n.rdb.Expire(ctx, redisKey, n.TTL)
+		}
+	}
+
+	return &NodeResult{
+		Success: true,
+		Output: map[string]interface{}{
+			"key":       key,
+			n.OutputKey: value,
+		},
+		Next: n.NextNode,
+	}, nil
+}
+
+// decodeStateValue returns numbers, booleans and JSON documents stored as
+// strings in their decoded form so conditions can compare them
+func decodeStateValue(raw string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err == nil {
+		return value
+	}
+	return raw
+}
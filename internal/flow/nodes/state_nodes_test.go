@@ -0,0 +1,198 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/testutil"
+)
+
+// memoryRedis answers the commands of the state nodes from memory instead
+// of a Redis server
+type memoryRedis struct {
+	values  map[string]string
+	ttls    map[string]time.Duration
+	expires int
+}
+
+func newMemoryRedis() (*memoryRedis, *redis.Client) {
+	m := &memoryRedis{values: map[string]string{}, ttls: map[string]time.Duration{}}
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	rdb.AddHook(m)
+	return m, rdb
+}
+
+func (m *memoryRedis) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (m *memoryRedis) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (m *memoryRedis) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		args := cmd.Args()
+		key := fmt.Sprint(args[1])
+		switch c := cmd.(type) {
+		case *redis.StringCmd: // GET
+			value, ok := m.values[key]
+			if !ok {
+				c.SetErr(redis.Nil)
+				return redis.Nil
+			}
+			c.SetVal(value)
+		case *redis.StatusCmd: // SET key value [EX seconds]
+			m.values[key] = fmt.Sprint(args[2])
+			delete(m.ttls, key)
+			if len(args) == 5 && strings.EqualFold(fmt.Sprint(args[3]), "ex") {
+				seconds, _ := strconv.Atoi(fmt.Sprint(args[4]))
+				m.ttls[key] = time.Duration(seconds) * time.Second
+			}
+			c.SetVal("OK")
+		case *redis.IntCmd: // INCRBY key by
+			current, _ := strconv.ParseInt(m.values[key], 10, 64)
+			by, _ := strconv.ParseInt(fmt.Sprint(args[2]), 10, 64)
+			m.values[key] = strconv.FormatInt(current+by, 10)
+			c.SetVal(current + by)
+		case *redis.DurationCmd: // TTL key
+			ttl, ok := m.ttls[key]
+			if !ok {
+				ttl = -1
+			}
+			c.SetVal(ttl)
+		case *redis.BoolCmd: // EXPIRE key seconds
+			seconds, _ := strconv.Atoi(fmt.Sprint(args[2]))
+			m.ttls[key] = time.Duration(seconds) * time.Second
+			m.expires++
+			c.SetVal(true)
+		default:
+			return fmt.Errorf("unexpected command %v", args)
+		}
+		return nil
+	}
+}
+
+// stateFlow chains the given nodes after a trigger
+func stateFlow(zoneID string, nodes ...domain.Node) *domain.Flow {
+	flow := &domain.Flow{ID: "flow_state_" + zoneID, ZoneID: zoneID, Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}}}
+	for i, n := range nodes {
+		flow.Edges = append(flow.Edges, domain.Edge{ID: fmt.Sprintf("e%d", i), Source: flow.Nodes[i].ID, Target: n.ID})
+		flow.Nodes = append(flow.Nodes, n)
+	}
+	return flow
+}
+
+func newStateRunner(rdb *redis.Client) (*domain.FlowRunner, *testutil.MockFlowRepository) {
+	registry := DefaultNodeRegistry()
+	registry.UseClients(NodeClients{Redis: rdb})
+	repo := testutil.NewMockFlowRepository()
+	runner := domain.NewFlowRunner(repo)
+	registry.Install(runner)
+	return runner, repo
+}
+
+// lastOutput returns the output of the last step of the flow's latest
+// execution
+func lastOutput(t *testing.T, repo *testutil.MockFlowRepository, flowID string) map[string]interface{} {
+	t.Helper()
+	executions, _ := repo.ListExecutions(context.Background(), flowID, 1, 0)
+	if len(executions) == 0 || len(executions[0].Steps) == 0 {
+		t.Fatalf("No execution of %s", flowID)
+	}
+	steps := executions[0].Steps
+	var output map[string]interface{}
+	json.Unmarshal(steps[len(steps)-1].Output, &output)
+	return output
+}
+
+func TestStateIncrementNode_CountsAcrossExecutions(t *testing.T) {
+	store, rdb := newMemoryRedis()
+	runner, _ := newStateRunner(rdb)
+
+	flow := stateFlow("zone_1", domain.Node{ID: "count", Type: "state_increment",
+		Data: json.RawMessage(`{"key":"failed_payments:{{user_id}}","ttl":"24h","output_key":"failures"}`)})
+	for i := 0; i < 3; i++ {
+		if err := runner.Execute(context.Background(), flow, map[string]interface{}{"user_id": "user_1"}); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+	}
+
+	key := "flow:state:zone_1:failed_payments:user_1"
+	if store.values[key] != "3" {
+		t.Errorf("Expected the counter at 3, got %q", store.values[key])
+	}
+	// The TTL runs from the first increment and is not extended
+	if store.ttls[key] != 24*time.Hour || store.expires != 1 {
+		t.Errorf("Expected the TTL set once to 24h, got %v after %d expires", store.ttls[key], store.expires)
+	}
+}
+
+func TestStateNodes_SetThenGet(t *testing.T) {
+	store, rdb := newMemoryRedis()
+	runner, repo := newStateRunner(rdb)
+
+	// The get reads the key from the set's output, which no longer carries
+	// the input's fields
+	flow := stateFlow("zone_1",
+		domain.Node{ID: "flag", Type: "state_set", Data: json.RawMessage(`{"key":"vip:{{user_id}}","value":"{{vip}}","ttl":"1h"}`)},
+		domain.Node{ID: "read", Type: "state_get", Data: json.RawMessage(`{"key":"{{key}}"}`)},
+	)
+	if err := runner.Execute(context.Background(), flow, map[string]interface{}{"user_id": "user_1", "vip": true}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got := store.ttls["flow:state:zone_1:vip:user_1"]; got != time.Hour {
+		t.Errorf("Expected the value to expire in 1h, got %v", got)
+	}
+	output := lastOutput(t, repo, flow.ID)
+	if output["found"] != true || output["value"] != true {
+		t.Errorf("Expected the stored flag decoded, got %v", output)
+	}
+
+	// Another zone's flows do not see the value
+	other := stateFlow("zone_2", domain.Node{ID: "read", Type: "state_get", Data: json.RawMessage(`{"key":"vip:{{user_id}}","zone_id":"zone_1"}`)})
+	if err := runner.Execute(context.Background(), other, map[string]interface{}{"user_id": "user_1", "zone_id": "zone_1"}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if output := lastOutput(t, repo, other.ID); output["found"] != false || output["value"] != nil {
+		t.Errorf("Expected zone_2 not to read zone_1 state, got %v", output)
+	}
+}
+
+func TestStateNodes_NotWrittenInDryRun(t *testing.T) {
+	store, rdb := newMemoryRedis()
+	runner, _ := newStateRunner(rdb)
+
+	flow := stateFlow("zone_1",
+		domain.Node{ID: "count", Type: "state_increment", Data: json.RawMessage(`{"key":"attempts"}`)},
+		domain.Node{ID: "flag", Type: "state_set", Data: json.RawMessage(`{"key":"seen","value":"1"}`)},
+	)
+	if _, err := runner.DryRun(context.Background(), flow, map[string]interface{}{}); err != nil {
+		t.Fatalf("DryRun failed: %v", err)
+	}
+	if len(store.values) != 0 {
+		t.Errorf("Expected nothing stored during a dry run, got %v", store.values)
+	}
+}
+
+func TestStateNodes_InvalidConfig(t *testing.T) {
+	registry := DefaultNodeRegistry()
+	tests := []struct {
+		nodeType string
+		config   string
+	}{
+		{"state_get", `{}`},
+		{"state_set", `{"key":"k","ttl":"soon"}`},
+		{"state_increment", `{"key":"k","by":"two"}`},
+	}
+	for _, tt := range tests {
+		if problems := registry.ValidateConfig(tt.nodeType, json.RawMessage(tt.config)); len(problems) == 0 {
+			t.Errorf("Expected %s config %s to be invalid", tt.nodeType, tt.config)
+		}
+	}
+}
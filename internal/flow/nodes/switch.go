@@ -0,0 +1,63 @@
+package nodes
+
+import (
+	"context"
+)
+
+// SwitchNode routes to one of several branches by comparing an input field
+// against a list of case values, falling back to a default branch
+type SwitchNode struct {
+	NodeID      string       `json:"id"`
+	Field       string       `json:"field"` // Path to the field in input, e.g. "type" or "data.currency"
+	Cases       []SwitchCase `json:"cases"`
+	DefaultNext string       `json:"defaultNext,omitempty"` // Node ID if no case matches
+}
+
+// SwitchCase is a single branch of a switch
+type SwitchCase struct {
+	Value string `json:"value"` // Compared with the field value (can use {{variables}})
+	Next  string `json:"next"`  // Node ID if the case matches
+}
+
+// NewSwitchNode creates a new switch node
+func NewSwitchNode(id, field string, cases []SwitchCase, defaultNext string) *SwitchNode {
+	return &SwitchNode{
+		NodeID:      id,
+		Field:       field,
+		Cases:       cases,
+		DefaultNext: defaultNext,
+	}
+}
+
+// ID returns the node ID
+func (n *SwitchNode) ID() string { return n.NodeID }
+
+// Type returns the node type
+func (n *SwitchNode) Type() string { return "switch" }
+
+// Execute picks the first case whose value equals the field value
+func (n *SwitchNode) Execute(ctx context.Context, input map[string]interface{}) (*NodeResult, error) {
+	value, _ := extractValue(input, n.Field)
+
+	for i, c := range n.Cases {
+		if compareEqual(value, resolveTemplate(c.Value, input)) {
+			return &NodeResult{
+				Success: true,
+				Output: map[string]interface{}{
+					"matched": c.Value,
+					"case":    i,
+				},
+				Next: c.Next,
+			}, nil
+		}
+	}
+
+	return &NodeResult{
+		Success: true,
+		Output: map[string]interface{}{
+			"matched": "default",
+			"case":    -1,
+		},
+		Next: n.DefaultNext,
+	}, nil
+}
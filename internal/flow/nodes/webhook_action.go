@@ -6,10 +6,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // WebhookActionNode sends HTTP requests to external services
@@ -24,7 +30,14 @@ type WebhookActionNode struct {
 	RetryDelay  time.Duration     `json:"retryDelay,omitempty"`
 	NextNode    string            `json:"next,omitempty"`
 	OnErrorNode string            `json:"onError,omitempty"`
-	client      *http.Client      `json:"-"`
+	OutputMap   map[string]string `json:"outputMap,omitempty"` // Output key -> JSONPath into the response body, e.g. "$.data.score"
+	RateLimit   *RateLimit        `json:"rateLimit,omitempty"` // Shared by every request to the same host
+	// CaptureDeliveries archives every request and response when the node
+	// is built by a registry with a delivery store
+	CaptureDeliveries bool         `json:"captureDeliveries,omitempty"`
+	client            *http.Client `json:"-"`
+	limiters          *RateLimiters
+	deliveries        domain.WebhookDeliveryStore
 }
 
 // WebhookActionConfig is used to create a new webhook action node
@@ -39,6 +52,10 @@ type WebhookActionConfig struct {
 	RetryDelay  time.Duration
 	NextNode    string
 	OnErrorNode string
+	OutputMap   map[string]string
+	RateLimit   *RateLimit
+	// CaptureDeliveries archives every request and response
+	CaptureDeliveries bool
 }
 
 // NewWebhookActionNode creates a new webhook action node
@@ -54,18 +71,23 @@ func NewWebhookActionNode(config WebhookActionConfig) *WebhookActionNode {
 	}
 
 	return &WebhookActionNode{
-		NodeID:      config.ID,
-		URL:         config.URL,
-		Method:      method,
-		Headers:     config.Headers,
-		Body:        config.Body,
-		Timeout:     timeout,
-		RetryCount:  config.RetryCount,
-		RetryDelay:  config.RetryDelay,
-		NextNode:    config.NextNode,
-		OnErrorNode: config.OnErrorNode,
+		NodeID:            config.ID,
+		URL:               config.URL,
+		Method:            method,
+		Headers:           config.Headers,
+		Body:              config.Body,
+		Timeout:           timeout,
+		RetryCount:        config.RetryCount,
+		RetryDelay:        config.RetryDelay,
+		NextNode:          config.NextNode,
+		OnErrorNode:       config.OnErrorNode,
+		OutputMap:         config.OutputMap,
+		RateLimit:         config.RateLimit,
+		CaptureDeliveries: config.CaptureDeliveries,
+		limiters:          DefaultRateLimiters,
 		client: &http.Client{
-			Timeout: timeout,
+			Timeout:   timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
 	}
 }
@@ -92,9 +114,19 @@ func (n *WebhookActionNode) Execute(ctx context.Context, input map[string]interf
 		attempts = 1
 	}
 
+	span := trace.SpanFromContext(ctx)
 	for attempt := 1; attempt <= attempts; attempt++ {
-		result, err := n.sendRequest(ctx, resolvedURL, resolvedBody, input)
+		span.SetAttributes(domain.NodeRetriesKey.Int(attempt - 1))
+		if err := n.throttle(ctx, resolvedURL); err != nil {
+			return &NodeResult{
+				Success: false,
+				Error:   err.Error(),
+				Next:    n.OnErrorNode,
+			}, nil
+		}
+		result, err := n.sendRequest(ctx, resolvedURL, resolvedBody, input, attempt)
 		if err == nil && result.Success {
+			n.applyOutputMap(result.Output)
 			result.Next = n.NextNode
 			return result, nil
 		}
@@ -118,8 +150,24 @@ func (n *WebhookActionNode) Execute(ctx context.Context, input map[string]interf
 	}, nil
 }
 
+// throttle takes a token from the destination host's rate limit, if the
+// node has one
+func (n *WebhookActionNode) throttle(ctx context.Context, rawURL string) error {
+	if n.RateLimit == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if err := n.limiters.Take(ctx, "webhook:"+u.Host, *n.RateLimit); err != nil {
+		return fmt.Errorf("%s: %w", u.Host, err)
+	}
+	return nil
+}
+
 // sendRequest performs the actual HTTP request
-func (n *WebhookActionNode) sendRequest(ctx context.Context, url, body string, input map[string]interface{}) (*NodeResult, error) {
+func (n *WebhookActionNode) sendRequest(ctx context.Context, url, body string, input map[string]interface{}, attempt int) (*NodeResult, error) {
 	var bodyReader io.Reader
 	if body != "" {
 		bodyReader = bytes.NewBufferString(body)
@@ -142,14 +190,17 @@ func (n *WebhookActionNode) sendRequest(ctx context.Context, url, body string, i
 	}
 
 	// Send request
+	started := time.Now()
 	resp, err := n.client.Do(req)
 	if err != nil {
+		n.recordDelivery(ctx, req, body, attempt, started, nil, nil, err)
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response body
 	respBody, err := io.ReadAll(resp.Body)
+	n.recordDelivery(ctx, req, body, attempt, started, resp, respBody, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
@@ -179,6 +230,100 @@ func (n *WebhookActionNode) sendRequest(ctx context.Context, url, body string, i
 	}, nil
 }
 
+// maxCapturedBody caps the size of request and response bodies archived
+// for a delivery
+const maxCapturedBody = 64 << 10
+
+// redactedHeaders are not archived with deliveries since they carry
+// credentials
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// recordDelivery archives a request and its response, if the node captures
+// deliveries. The request was built from the node's resolved config, so the
+// zone secret values in it are redacted. Failures to archive do not fail
+// the request.
+func (n *WebhookActionNode) recordDelivery(ctx context.Context, req *http.Request, body string, attempt int, started time.Time, resp *http.Response, respBody []byte, err error) {
+	if !n.CaptureDeliveries || n.deliveries == nil {
+		return
+	}
+
+	scope := domain.NodeScopeFromContext(ctx)
+	delivery := &domain.WebhookDelivery{
+		ID:             fmt.Sprintf("whd_%d", time.Now().UnixNano()),
+		FlowID:         scope.FlowID,
+		ZoneID:         scope.ZoneID,
+		ExecutionID:    domain.ExecutionIDFromContext(ctx),
+		NodeID:         n.NodeID,
+		Attempt:        attempt,
+		Method:         req.Method,
+		URL:            domain.RedactSecrets(ctx, req.URL.String()),
+		RequestHeaders: capturedHeaders(ctx, req.Header),
+		RequestBody:    truncateBody(domain.RedactSecrets(ctx, body)),
+		LatencyMs:      time.Since(started).Milliseconds(),
+		CreatedAt:      started,
+	}
+	if resp != nil {
+		delivery.StatusCode = resp.StatusCode
+		delivery.ResponseHeaders = capturedHeaders(ctx, resp.Header)
+		delivery.ResponseBody = truncateBody(domain.RedactSecrets(ctx, string(respBody)))
+	}
+	if err != nil {
+		delivery.Error = domain.RedactSecrets(ctx, err.Error())
+	}
+
+	if err := n.deliveries.CreateWebhookDelivery(context.WithoutCancel(ctx), delivery); err != nil {
+		log.Printf("Failed to archive webhook delivery of node %s: %v", n.NodeID, err)
+	}
+}
+
+// capturedHeaders returns the headers to archive, without credentials or
+// the values of the zone secrets the node resolved
+func capturedHeaders(ctx context.Context, h http.Header) map[string]string {
+	captured := headerToMap(h)
+	for key, value := range captured {
+		if redactedHeaders[http.CanonicalHeaderKey(key)] {
+			captured[key] = "[REDACTED]"
+		} else {
+			captured[key] = domain.RedactSecrets(ctx, value)
+		}
+	}
+	return captured
+}
+
+func truncateBody(body string) string {
+	if len(body) > maxCapturedBody {
+		return body[:maxCapturedBody]
+	}
+	return body
+}
+
+// applyOutputMap extracts the mapped response fields into the output.
+// Fields missing from the response are left unset and listed under
+// "unmapped" so conditions can check for them.
+func (n *WebhookActionNode) applyOutputMap(output map[string]interface{}) {
+	if len(n.OutputMap) == 0 {
+		return
+	}
+	var unmapped []interface{}
+	for key, path := range n.OutputMap {
+		val, err := extractJSONPath(output["responseBody"], path)
+		if err != nil {
+			unmapped = append(unmapped, key)
+			continue
+		}
+		output[key] = val
+	}
+	if len(unmapped) > 0 {
+		output["unmapped"] = unmapped
+	}
+}
+
 // resolveTemplate replaces {{variable}} placeholders with values from input
 func (n *WebhookActionNode) resolveTemplate(template string, input map[string]interface{}) string {
 	if template == "" {
@@ -206,13 +351,13 @@ func (n *WebhookActionNode) resolveTemplate(template string, input map[string]in
 			return string(b)
 		}
 
-		// Extract nested value
-		val, err := extractValue(input, path)
+		// Extract nested value or evaluate an expression
+		val, err := templateString(input, path)
 		if err != nil {
 			return match
 		}
 
-		return toString(val)
+		return val
 	})
 }
 
@@ -236,9 +381,10 @@ type WebhookActionBuilder struct {
 func NewWebhookAction(id string) *WebhookActionBuilder {
 	return &WebhookActionBuilder{
 		config: WebhookActionConfig{
-			ID:      id,
-			Method:  "POST",
-			Headers: make(map[string]string),
+			ID:        id,
+			Method:    "POST",
+			Headers:   make(map[string]string),
+			OutputMap: make(map[string]string),
 		},
 	}
 }
@@ -280,6 +426,18 @@ func (b *WebhookActionBuilder) Retry(count int, delay time.Duration) *WebhookAct
 	return b
 }
 
+// CaptureDeliveries archives every request and response
+func (b *WebhookActionBuilder) CaptureDeliveries() *WebhookActionBuilder {
+	b.config.CaptureDeliveries = true
+	return b
+}
+
+// MapOutput copies the response value at a JSONPath into an output key
+func (b *WebhookActionBuilder) MapOutput(key, path string) *WebhookActionBuilder {
+	b.config.OutputMap[key] = path
+	return b
+}
+
 // Then sets the next node on success
 func (b *WebhookActionBuilder) Then(nodeID string) *WebhookActionBuilder {
 	b.config.NextNode = nodeID
@@ -0,0 +1,74 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/testutil"
+)
+
+// staticSecrets resolves zone secrets from a map
+type staticSecrets map[string]string
+
+func (s staticSecrets) ResolveSecret(ctx context.Context, zoneID, name string) (string, error) {
+	value, ok := s[name]
+	if !ok {
+		return "", domain.ErrZoneSecretNotFound
+	}
+	return value, nil
+}
+
+func TestWebhookActionNode_DeliveriesRedactSecrets(t *testing.T) {
+	const secret = `s3cr3t"key/v1`
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Upstreams may echo what they were sent
+		w.Header().Set("X-Echo", r.Header.Get("X-Signing-Key"))
+		w.Write([]byte(`{"received":"` + r.URL.Query().Get("token") + `"}`))
+	}))
+	defer target.Close()
+
+	repo := testutil.NewMockFlowRepository()
+	runner := domain.NewFlowRunner(repo)
+	registry := DefaultNodeRegistry()
+	registry.CaptureDeliveries(repo)
+	registry.Install(runner)
+	runner.SetSecrets(staticSecrets{"api_key": secret})
+
+	config, _ := json.Marshal(map[string]interface{}{
+		"url":               target.URL + "/hook?token={{secrets.api_key}}",
+		"headers":           map[string]string{"X-Signing-Key": "{{secrets.api_key}}"},
+		"body":              `{"key":"{{secrets.api_key}}"}`,
+		"captureDeliveries": true,
+	})
+	flow := &domain.Flow{
+		ID: "flow_secret_hook", ZoneID: "zone_1",
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "call", Type: "webhook_action", Data: config}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "call"}},
+	}
+	if err := runner.Execute(context.Background(), flow, map[string]interface{}{}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	deliveries, _ := repo.ListWebhookDeliveries(context.Background(), domain.WebhookDeliveryFilter{FlowID: flow.ID})
+	if len(deliveries) != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", len(deliveries))
+	}
+	archived, _ := json.Marshal(deliveries[0])
+	for _, leaked := range []string{"s3cr3t", "key%22"} {
+		if strings.Contains(string(archived), leaked) {
+			t.Errorf("Expected the secret redacted from the delivery, found %q in %s", leaked, archived)
+		}
+	}
+	d := deliveries[0]
+	if !strings.Contains(d.URL, "token=[REDACTED]") || d.RequestHeaders["X-Signing-Key"] != "[REDACTED]" {
+		t.Errorf("Expected the secret replaced in the URL and headers, got %s %v", d.URL, d.RequestHeaders)
+	}
+	if d.RequestBody != `{"key":"[REDACTED]"}` {
+		t.Errorf("Expected the secret replaced in the body, got %s", d.RequestBody)
+	}
+}
@@ -0,0 +1,53 @@
+package flow
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// FlowPurger periodically hard-deletes flows that have been soft-deleted
+// for longer than the configured retention period
+type FlowPurger struct {
+	repo      domain.Repository
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewFlowPurger creates a new purger for soft-deleted flows
+func NewFlowPurger(repo domain.Repository, retention, interval time.Duration) *FlowPurger {
+	return &FlowPurger{
+		repo:      repo,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Start runs the purge loop until the context is cancelled
+func (p *FlowPurger) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.PurgeOnce(ctx)
+		}
+	}
+}
+
+// PurgeOnce removes all flows deleted before now minus the retention period
+func (p *FlowPurger) PurgeOnce(ctx context.Context) {
+	purged, err := p.repo.PurgeDeletedFlows(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		log.Printf("Flow purge failed: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d soft-deleted flows", purged)
+	}
+}
@@ -0,0 +1,98 @@
+package flow
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// ExecutionRecovery resumes executions left running by a previous process
+type ExecutionRecovery struct {
+	repo       domain.Repository
+	runner     *domain.FlowRunner
+	staleAfter time.Duration
+}
+
+// NewExecutionRecovery creates a recovery for executions that have not been
+// checkpointed for staleAfter, so that runs still progressing in another
+// instance are left alone
+func NewExecutionRecovery(repo domain.Repository, runner *domain.FlowRunner, staleAfter time.Duration) *ExecutionRecovery {
+	return &ExecutionRecovery{
+		repo:       repo,
+		runner:     runner,
+		staleAfter: staleAfter,
+	}
+}
+
+// RecoverOnce resumes or fails over every interrupted execution and returns
+// how many were recovered. Each execution is claimed first, so instances
+// recovering at the same time never run one twice.
+func (e *ExecutionRecovery) RecoverOnce(ctx context.Context) int {
+	executions, err := e.repo.ListIncompleteExecutions(ctx, time.Now().Add(-e.staleAfter))
+	if err != nil {
+		log.Printf("Failed to list incomplete executions: %v", err)
+		return 0
+	}
+
+	recovered := 0
+	for _, exec := range executions {
+		lastSeen := exec.CheckpointedAt
+		if lastSeen.IsZero() {
+			lastSeen = exec.StartedAt
+		}
+		now := time.Now()
+		claimed, err := e.repo.ClaimExecution(ctx, exec.ID, lastSeen, now)
+		if err != nil {
+			log.Printf("Failed to claim execution %s: %v", exec.ID, err)
+			continue
+		}
+		if !claimed {
+			continue // Recovered by another instance, or checkpointed since
+		}
+		exec.CheckpointedAt = now
+
+		flow, err := e.flowForExecution(ctx, exec)
+		if err != nil {
+			log.Printf("Cannot recover execution %s: %v", exec.ID, err)
+			exec.Status = domain.ExecutionFailed
+			exec.EndedAt = time.Now()
+			if err := e.repo.UpdateExecution(ctx, exec); err != nil {
+				log.Printf("Failed to fail over execution %s: %v", exec.ID, err)
+			}
+			continue
+		}
+
+		if err := e.runner.Recover(ctx, flow, exec); err != nil {
+			log.Printf("Recovered execution %s failed: %v", exec.ID, err)
+		}
+		recovered++
+	}
+
+	if recovered > 0 {
+		log.Printf("Recovered %d interrupted executions", recovered)
+	}
+	return recovered
+}
+
+// flowForExecution loads the flow definition the execution started with
+func (e *ExecutionRecovery) flowForExecution(ctx context.Context, exec *domain.FlowExecution) (*domain.Flow, error) {
+	flow, err := e.repo.GetFlow(ctx, exec.FlowID)
+	if err != nil {
+		return nil, err
+	}
+	if exec.FlowVersion == 0 || exec.FlowVersion == flow.Version {
+		return flow, nil
+	}
+
+	version, err := e.repo.GetFlowVersion(ctx, exec.FlowID, exec.FlowVersion)
+	if err != nil {
+		return nil, err
+	}
+	pinned := *flow
+	pinned.Version = version.Version
+	pinned.Nodes = version.Nodes
+	pinned.Edges = version.Edges
+	return &pinned, nil
+}
@@ -0,0 +1,381 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// ReplayJobStatus represents the lifecycle state of a bulk replay job
+type ReplayJobStatus string
+
+const (
+	ReplayJobPending   ReplayJobStatus = "pending"
+	ReplayJobRunning   ReplayJobStatus = "running"
+	ReplayJobCompleted ReplayJobStatus = "completed"
+	ReplayJobCancelled ReplayJobStatus = "cancelled"
+)
+
+var (
+	ErrReplayJobNotFound = errors.New("replay job not found")
+	ErrReplayJobFinished = errors.New("replay job already finished")
+	ErrReplayInProgress  = errors.New("a replay of this event is already in progress")
+)
+
+// ReplayJobRequest describes a batch of events to replay into a zone
+type ReplayJobRequest struct {
+	ZoneID string `json:"zoneId"`
+	// SourceZoneID is the zone the events must belong to, ZoneID if empty.
+	// Only replay schedules, whose source zone was authorized when they
+	// were saved, read another zone's events.
+	SourceZoneID  string   `json:"sourceZoneId,omitempty"`
+	EventIDs      []string `json:"eventIds"`
+	Delay         int      `json:"delay"`         // Minimum delay between replays in milliseconds
+	RatePerSecond float64  `json:"ratePerSecond"` // Optional cap on replays per second
+	// IdempotencyKey scopes deduplication to earlier replays made with the
+	// same key. Without one, any earlier replay of an event counts.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+}
+
+func (r ReplayJobRequest) sourceZone() string {
+	if r.SourceZoneID != "" {
+		return r.SourceZoneID
+	}
+	return r.ZoneID
+}
+
+// ReplayResult is the outcome of replaying a single event
+type ReplayResult struct {
+	EventID    string    `json:"eventId"`
+	Status     string    `json:"status"` // "success" or "error"
+	ReplayedID string    `json:"replayedId,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	ReplayedAt time.Time `json:"replayedAt"`
+	// Deduplicated is set when the event was not replayed again because an
+	// earlier replay within the dedupe window succeeded. ReplayedID and
+	// ReplayedAt then describe that replay.
+	Deduplicated bool `json:"deduplicated,omitempty"`
+}
+
+// ReplayJob tracks the progress of an asynchronous bulk replay
+type ReplayJob struct {
+	ID           string           `json:"id"`
+	Request      ReplayJobRequest `json:"request"`
+	Status       ReplayJobStatus  `json:"status"`
+	Total        int              `json:"total"`
+	Processed    int              `json:"processed"`
+	Succeeded    int              `json:"succeeded"`
+	Failed       int              `json:"failed"`
+	Deduplicated int              `json:"deduplicated"` // Included in Succeeded
+	Results      []ReplayResult   `json:"results"`
+	CreatedAt    time.Time        `json:"createdAt"`
+	StartedAt    *time.Time       `json:"startedAt,omitempty"`
+	FinishedAt   *time.Time       `json:"finishedAt,omitempty"`
+	cancel       context.CancelFunc
+}
+
+// ReplayEventSource loads the original events to replay
+type ReplayEventSource interface {
+	GetEventByID(ctx context.Context, eventID string) (*domain.Event, error)
+}
+
+// ReplayRetriggerer re-publishes a replayed event
+type ReplayRetriggerer interface {
+	RetriggerEvent(ctx context.Context, event *domain.Event) error
+}
+
+// ReplayJobManager runs bulk replays in the background and tracks their progress
+type ReplayJobManager struct {
+	source      ReplayEventSource
+	retriggerer ReplayRetriggerer
+	jobs        map[string]*ReplayJob
+	mu          sync.RWMutex
+
+	attempts    domain.ReplayAttemptStore
+	dedupe      time.Duration
+	replaying   map[string]bool // Dedupe keys of replays in progress
+	replayingMu sync.Mutex
+}
+
+// NewReplayJobManager creates a new replay job manager
+func NewReplayJobManager(source ReplayEventSource, retriggerer ReplayRetriggerer) *ReplayJobManager {
+	return &ReplayJobManager{
+		source:      source,
+		retriggerer: retriggerer,
+		jobs:        make(map[string]*ReplayJob),
+		replaying:   make(map[string]bool),
+	}
+}
+
+// SetAttemptStore records every replay in the store. An event already
+// replayed successfully into a zone within the dedupe window is not
+// replayed there again; a zero window only records.
+func (m *ReplayJobManager) SetAttemptStore(store domain.ReplayAttemptStore, dedupe time.Duration) {
+	m.attempts = store
+	m.dedupe = dedupe
+}
+
+// Attempts lists the recorded replays of an event, newest first
+func (m *ReplayJobManager) Attempts(ctx context.Context, eventID string) ([]*domain.ReplayAttempt, error) {
+	if m.attempts == nil {
+		return nil, nil
+	}
+	return m.attempts.ListReplayAttempts(ctx, eventID)
+}
+
+// Submit registers a job and starts replaying it in the background
+func (m *ReplayJobManager) Submit(req ReplayJobRequest) *ReplayJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &ReplayJob{
+		ID:        fmt.Sprintf("rjob_%d", time.Now().UnixNano()),
+		Request:   req,
+		Status:    ReplayJobPending,
+		Total:     len(req.EventIDs),
+		Results:   make([]ReplayResult, 0, len(req.EventIDs)),
+		CreatedAt: time.Now(),
+		cancel:    cancel,
+	}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	snapshot := job.snapshot()
+	m.mu.Unlock()
+
+	go m.run(ctx, job)
+	return snapshot
+}
+
+// Get returns a point-in-time copy of a job
+func (m *ReplayJobManager) Get(jobID string) (*ReplayJob, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, exists := m.jobs[jobID]
+	if !exists {
+		return nil, ErrReplayJobNotFound
+	}
+	return job.snapshot(), nil
+}
+
+// Cancel stops a pending or running job; already replayed events are kept
+func (m *ReplayJobManager) Cancel(jobID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, exists := m.jobs[jobID]
+	if !exists {
+		return ErrReplayJobNotFound
+	}
+	if job.FinishedAt != nil {
+		return ErrReplayJobFinished
+	}
+
+	job.cancel()
+	return nil
+}
+
+// ExpireFinishedJobs forgets jobs that finished more than retention ago and
+// returns how many were dropped. Pending and running jobs are kept.
+func (m *ReplayJobManager) ExpireFinishedJobs(retention time.Duration) int {
+	cutoff := time.Now().Add(-retention)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expired := 0
+	for id, job := range m.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(m.jobs, id)
+			expired++
+		}
+	}
+	return expired
+}
+
+// RunJanitor periodically expires finished jobs until the context is cancelled
+func (m *ReplayJobManager) RunJanitor(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := m.ExpireFinishedJobs(retention); n > 0 {
+				log.Printf("Expired %d finished replay jobs", n)
+			}
+		}
+	}
+}
+
+func (m *ReplayJobManager) run(ctx context.Context, job *ReplayJob) {
+	defer job.cancel()
+
+	m.mu.Lock()
+	now := time.Now()
+	job.Status = ReplayJobRunning
+	job.StartedAt = &now
+	m.mu.Unlock()
+
+	interval := time.Duration(job.Request.Delay) * time.Millisecond
+	if job.Request.RatePerSecond > 0 {
+		if minInterval := time.Duration(float64(time.Second) / job.Request.RatePerSecond); minInterval > interval {
+			interval = minInterval
+		}
+	}
+
+	status := ReplayJobCompleted
+	for i, eventID := range job.Request.EventIDs {
+		if i > 0 && interval > 0 {
+			timer := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			status = ReplayJobCancelled
+			break
+		}
+
+		result := m.replayOne(ctx, job, eventID, i)
+
+		m.mu.Lock()
+		job.Results = append(job.Results, result)
+		job.Processed++
+		if result.Status == "success" {
+			job.Succeeded++
+			if result.Deduplicated {
+				job.Deduplicated++
+			}
+		} else {
+			job.Failed++
+		}
+		m.mu.Unlock()
+	}
+
+	m.mu.Lock()
+	finished := time.Now()
+	job.Status = status
+	job.FinishedAt = &finished
+	m.mu.Unlock()
+}
+
+func (m *ReplayJobManager) replayOne(ctx context.Context, job *ReplayJob, eventID string, index int) ReplayResult {
+	event, err := m.source.GetEventByID(ctx, eventID)
+	if err != nil {
+		return ReplayResult{
+			EventID:    eventID,
+			Status:     "error",
+			Error:      fmt.Sprintf("Event not found: %v", err),
+			ReplayedAt: time.Now(),
+		}
+	}
+	// A job replays only its source zone's events, whoever submitted it
+	if source := job.Request.sourceZone(); event.ZoneID != source {
+		return ReplayResult{
+			EventID:    eventID,
+			Status:     "error",
+			Error:      fmt.Sprintf("Event not found in zone %s", source),
+			ReplayedAt: time.Now(),
+		}
+	}
+
+	return m.replay(ctx, job.Request.ZoneID, event, job.Request.IdempotencyKey, job.ID, fmt.Sprintf("replay_%d_%d", time.Now().UnixNano(), index))
+}
+
+// ReplayEvent replays a single event into the zone, unless it was already
+// replayed there within the dedupe window
+func (m *ReplayJobManager) ReplayEvent(ctx context.Context, zoneID string, event *domain.Event, key string) ReplayResult {
+	return m.replay(ctx, zoneID, event, key, "", fmt.Sprintf("replay_%d", time.Now().UnixNano()))
+}
+
+func (m *ReplayJobManager) replay(ctx context.Context, zoneID string, event *domain.Event, key, jobID, replayedID string) ReplayResult {
+	// Concurrent replays of the same event would both miss each other's
+	// attempt, so only one runs at a time
+	dedupeKey := zoneID + "/" + event.ID + "/" + key
+	m.replayingMu.Lock()
+	if m.replaying[dedupeKey] {
+		m.replayingMu.Unlock()
+		return ReplayResult{EventID: event.ID, Status: "error", Error: ErrReplayInProgress.Error(), ReplayedAt: time.Now()}
+	}
+	m.replaying[dedupeKey] = true
+	m.replayingMu.Unlock()
+	defer func() {
+		m.replayingMu.Lock()
+		delete(m.replaying, dedupeKey)
+		m.replayingMu.Unlock()
+	}()
+
+	if m.attempts != nil && m.dedupe > 0 {
+		earlier, err := m.attempts.FindSuccessfulReplay(ctx, zoneID, event.ID, key, time.Now().Add(-m.dedupe))
+		if err != nil {
+			log.Printf("Failed to look up earlier replays of event %s: %v", event.ID, err)
+		} else if earlier != nil {
+			return ReplayResult{
+				EventID:      event.ID,
+				Status:       "success",
+				ReplayedID:   earlier.ReplayedEventID,
+				ReplayedAt:   earlier.CreatedAt,
+				Deduplicated: true,
+			}
+		}
+	}
+
+	replayedEvent := &domain.Event{
+		ID:        replayedID,
+		Type:      event.Type,
+		ZoneID:    zoneID,
+		Data:      event.Data,
+		Meta:      map[string]string{domain.MetaReplayOf: event.ID},
+		CreatedAt: time.Now(),
+	}
+
+	result := ReplayResult{
+		EventID:    event.ID,
+		Status:     "success",
+		ReplayedID: replayedEvent.ID,
+		ReplayedAt: replayedEvent.CreatedAt,
+	}
+	if err := m.retriggerer.RetriggerEvent(ctx, replayedEvent); err != nil {
+		result = ReplayResult{
+			EventID:    event.ID,
+			Status:     "error",
+			Error:      fmt.Sprintf("Failed to replay: %v", err),
+			ReplayedAt: replayedEvent.CreatedAt,
+		}
+	}
+
+	if m.attempts != nil {
+		attempt := &domain.ReplayAttempt{
+			ID:              fmt.Sprintf("rpl_%d", time.Now().UnixNano()),
+			ZoneID:          zoneID,
+			EventID:         event.ID,
+			IdempotencyKey:  key,
+			JobID:           jobID,
+			ReplayedEventID: result.ReplayedID,
+			Status:          result.Status,
+			Error:           result.Error,
+			CreatedAt:       result.ReplayedAt,
+		}
+		if err := m.attempts.CreateReplayAttempt(context.WithoutCancel(ctx), attempt); err != nil {
+			log.Printf("Failed to record replay of event %s: %v", event.ID, err)
+		}
+	}
+	return result
+}
+
+// snapshot copies the job so callers can read it without holding the lock
+func (j *ReplayJob) snapshot() *ReplayJob {
+	c := *j
+	c.Results = append([]ReplayResult(nil), j.Results...)
+	c.cancel = nil
+	return &c
+}
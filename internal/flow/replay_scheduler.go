@@ -0,0 +1,132 @@
+package flow
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// ReplayEventQuerier finds the events a scheduled replay should re-drive
+type ReplayEventQuerier interface {
+	QueryEvents(ctx context.Context, filter domain.EventFilter) (*domain.EventPage, error)
+}
+
+// ReplayScheduler fires due replay schedules by submitting replay jobs
+type ReplayScheduler struct {
+	store    domain.ReplayScheduleStore
+	events   ReplayEventQuerier
+	jobs     *ReplayJobManager
+	interval time.Duration
+}
+
+// NewReplayScheduler creates a new replay scheduler
+func NewReplayScheduler(store domain.ReplayScheduleStore, events ReplayEventQuerier, jobs *ReplayJobManager, interval time.Duration) *ReplayScheduler {
+	return &ReplayScheduler{
+		store:    store,
+		events:   events,
+		jobs:     jobs,
+		interval: interval,
+	}
+}
+
+// Start polls for due schedules until the context is cancelled
+func (s *ReplayScheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.RunDue(ctx, now)
+		}
+	}
+}
+
+// RunDue fires every schedule whose next run time has passed
+func (s *ReplayScheduler) RunDue(ctx context.Context, now time.Time) {
+	schedules, err := s.store.ListDueReplaySchedules(ctx, now)
+	if err != nil {
+		log.Printf("Failed to list due replay schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		if err := s.fire(ctx, schedule, now); err != nil {
+			log.Printf("Replay schedule %s failed: %v", schedule.ID, err)
+		}
+	}
+}
+
+func (s *ReplayScheduler) fire(ctx context.Context, schedule *domain.ReplaySchedule, now time.Time) error {
+	eventIDs, err := s.collectEventIDs(ctx, schedule, now)
+	if err != nil {
+		return err
+	}
+
+	if len(eventIDs) > 0 {
+		job := s.jobs.Submit(ReplayJobRequest{
+			ZoneID:       schedule.ZoneID,
+			SourceZoneID: schedule.SourceZoneID,
+			EventIDs:     eventIDs,
+			Delay:        schedule.DelayMs,
+		})
+		schedule.LastJobID = job.ID
+		log.Printf("Replay schedule %s queued job %s with %d events", schedule.ID, job.ID, len(eventIDs))
+	}
+
+	schedule.LastRunAt = &now
+	schedule.UpdatedAt = now
+	if schedule.Recurring() {
+		schedule.NextRunAt = nextScheduledRun(schedule.NextRunAt, time.Duration(schedule.IntervalSeconds)*time.Second, now)
+	} else {
+		schedule.Enabled = false
+	}
+	return s.store.UpdateReplaySchedule(ctx, schedule)
+}
+
+// collectEventIDs pages through the source zone's events in the lookback window
+func (s *ReplayScheduler) collectEventIDs(ctx context.Context, schedule *domain.ReplaySchedule, now time.Time) ([]string, error) {
+	filter := domain.EventFilter{
+		ZoneID: schedule.SourceZoneID,
+		Types:  schedule.EventTypes,
+		From:   now.Add(-time.Duration(schedule.LookbackSeconds) * time.Second),
+		To:     now,
+		Limit:  500,
+	}
+
+	var ids []string
+	for {
+		page, err := s.events.QueryEvents(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range page.Events {
+			ids = append(ids, e.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	// Replay in the order the events originally happened
+	for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+	return ids, nil
+}
+
+// nextScheduledRun advances from the previous run time by whole intervals,
+// skipping runs missed while the service was down
+func nextScheduledRun(previous time.Time, interval time.Duration, now time.Time) time.Time {
+	next := previous.Add(interval)
+	if !next.After(now) {
+		missed := now.Sub(next)/interval + 1
+		next = next.Add(missed * interval)
+	}
+	return next
+}
@@ -0,0 +1,192 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/triggers"
+)
+
+// ErrInvalidSchedule is returned when a schedule's cron expression,
+// interval or timezone cannot be used
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// ScheduleRegistry keeps the in-memory schedule triggers in sync with the
+// persisted flow schedules and executes flows when their schedule fires
+type ScheduleRegistry struct {
+	store     domain.FlowScheduleStore
+	repo      domain.Repository
+	runner    *domain.FlowRunner
+	scheduler *triggers.ScheduleTriggerService
+}
+
+// NewScheduleRegistry creates a new schedule registry
+func NewScheduleRegistry(store domain.FlowScheduleStore, repo domain.Repository, runner *domain.FlowRunner) *ScheduleRegistry {
+	r := &ScheduleRegistry{
+		store:     store,
+		repo:      repo,
+		runner:    runner,
+		scheduler: triggers.NewScheduleTriggerService(),
+	}
+	r.scheduler.SetHandler(r.fire)
+	return r
+}
+
+// Load registers every enabled persisted schedule. Runs missed while the
+// service was down fire once on the first tick.
+func (r *ScheduleRegistry) Load(ctx context.Context) error {
+	schedules, err := r.store.ListEnabledFlowSchedules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load flow schedules: %w", err)
+	}
+
+	for _, schedule := range schedules {
+		trigger, err := scheduleTrigger(schedule)
+		if err != nil {
+			log.Printf("Skipping schedule for flow %s: %v", schedule.FlowID, err)
+			continue
+		}
+		if !schedule.NextRunAt.IsZero() {
+			trigger.NextRun = schedule.NextRunAt
+		}
+		r.scheduler.Register(trigger)
+	}
+	log.Printf("Loaded %d flow schedules", len(schedules))
+	return nil
+}
+
+// Start runs the scheduler until the context is cancelled
+func (r *ScheduleRegistry) Start(ctx context.Context) {
+	r.scheduler.Start()
+	<-ctx.Done()
+	r.scheduler.Stop()
+}
+
+// Save validates and persists a flow's schedule, replacing any existing one,
+// and registers or unregisters it according to its enabled state
+func (r *ScheduleRegistry) Save(ctx context.Context, schedule *domain.FlowSchedule) (*domain.FlowSchedule, error) {
+	flow, err := r.repo.GetFlow(ctx, schedule.FlowID)
+	if err != nil {
+		return nil, err
+	}
+	schedule.ZoneID = flow.ZoneID
+
+	trigger, err := scheduleTrigger(schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	schedule.CreatedAt = now
+	if existing, err := r.store.GetFlowSchedule(ctx, schedule.FlowID); err == nil {
+		schedule.CreatedAt = existing.CreatedAt
+		schedule.LastRunAt = existing.LastRunAt
+	} else if err != domain.ErrFlowScheduleNotFound {
+		return nil, err
+	}
+	schedule.UpdatedAt = now
+	schedule.NextRunAt = trigger.NextRun
+
+	if err := r.store.SaveFlowSchedule(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	if schedule.Enabled {
+		r.scheduler.Register(trigger)
+	} else {
+		r.scheduler.Unregister(schedule.FlowID)
+	}
+	return schedule, nil
+}
+
+// Get returns a flow's schedule
+func (r *ScheduleRegistry) Get(ctx context.Context, flowID string) (*domain.FlowSchedule, error) {
+	return r.store.GetFlowSchedule(ctx, flowID)
+}
+
+// List returns the schedules of a zone's flows
+func (r *ScheduleRegistry) List(ctx context.Context, zoneID string) ([]*domain.FlowSchedule, error) {
+	return r.store.ListFlowSchedules(ctx, zoneID)
+}
+
+// Delete removes a flow's schedule
+func (r *ScheduleRegistry) Delete(ctx context.Context, flowID string) error {
+	if err := r.store.DeleteFlowSchedule(ctx, flowID); err != nil {
+		return err
+	}
+	r.scheduler.Unregister(flowID)
+	return nil
+}
+
+// fire claims the run on the persisted schedule and executes the flow. Every
+// instance fires the same schedules; the one whose claim succeeds runs the
+// flow and the others resync their trigger from the stored schedule.
+func (r *ScheduleRegistry) fire(ctx context.Context, trigger *triggers.ScheduleTrigger) error {
+	claimed, err := r.store.ClaimFlowScheduleRun(ctx, trigger.FlowID, trigger.DueAt, trigger.LastRun, trigger.NextRun)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		r.resync(ctx, trigger.FlowID)
+		return nil
+	}
+
+	flow, err := r.repo.GetFlow(ctx, trigger.FlowID)
+	if err != nil {
+		return err
+	}
+	if !flow.Enabled {
+		return nil
+	}
+
+	log.Printf("Executing scheduled flow %s", flow.ID)
+	return r.runner.Execute(ctx, flow, map[string]interface{}{
+		"trigger":      "schedule",
+		"scheduled_at": trigger.DueAt,
+		"zone_id":      flow.ZoneID,
+	})
+}
+
+// resync replaces a flow's trigger with the stored schedule after another
+// instance claimed its run or changed the schedule
+func (r *ScheduleRegistry) resync(ctx context.Context, flowID string) {
+	schedule, err := r.store.GetFlowSchedule(ctx, flowID)
+	if err != nil || !schedule.Enabled {
+		if err != nil && err != domain.ErrFlowScheduleNotFound {
+			log.Printf("Failed to resync schedule for flow %s: %v", flowID, err)
+			return
+		}
+		r.scheduler.Unregister(flowID)
+		return
+	}
+
+	trigger, err := scheduleTrigger(schedule)
+	if err != nil {
+		log.Printf("Failed to resync schedule for flow %s: %v", flowID, err)
+		return
+	}
+	trigger.NextRun = schedule.NextRunAt
+	r.scheduler.Register(trigger)
+}
+
+// scheduleTrigger builds the trigger described by a schedule
+func scheduleTrigger(schedule *domain.FlowSchedule) (*triggers.ScheduleTrigger, error) {
+	var trigger *triggers.ScheduleTrigger
+	var err error
+	switch {
+	case schedule.CronExpr != "":
+		trigger, err = triggers.NewScheduleTriggerFromCron(schedule.CronExpr, schedule.Timezone, schedule.FlowID, schedule.ZoneID)
+	case schedule.IntervalSeconds > 0:
+		trigger, err = triggers.NewScheduleTrigger(time.Duration(schedule.IntervalSeconds)*time.Second, schedule.Timezone, schedule.FlowID, schedule.ZoneID)
+	default:
+		err = errors.New("a cron expression or a positive interval is required")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	return trigger, nil
+}
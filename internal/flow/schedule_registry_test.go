@@ -0,0 +1,52 @@
+package flow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/testutil"
+)
+
+func TestScheduleRegistry_RunsOnceAcrossInstances(t *testing.T) {
+	repo := testutil.NewMockFlowRepository()
+	ctx := context.Background()
+	repo.CreateFlow(ctx, &domain.Flow{
+		ID: "flow_sched", ZoneID: "zone_1", Enabled: true,
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}},
+	})
+
+	first := NewScheduleRegistry(repo, repo, domain.NewFlowRunner(repo))
+	second := NewScheduleRegistry(repo, repo, domain.NewFlowRunner(repo))
+	if _, err := first.Save(ctx, &domain.FlowSchedule{FlowID: "flow_sched", IntervalSeconds: 60, Enabled: true}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := second.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// Both instances see the schedule come due and fire it
+	due := time.Now().Add(2 * time.Minute)
+	for _, registry := range []*ScheduleRegistry{first, second} {
+		trigger := registry.scheduler.GetAllTriggers()[0]
+		snapshot := *trigger
+		snapshot.LastRun = due
+		snapshot.DueAt = trigger.NextRun
+		snapshot.NextRun = trigger.NextRun.Add(time.Minute)
+		if err := registry.fire(ctx, &snapshot); err != nil {
+			t.Fatalf("fire failed: %v", err)
+		}
+	}
+
+	executions, _ := repo.ListExecutions(ctx, "flow_sched", 10, 0)
+	if len(executions) != 1 {
+		t.Errorf("Expected the schedule to run once, got %d executions", len(executions))
+	}
+
+	// The losing instance picks up the claimed next run
+	stored, _ := repo.GetFlowSchedule(ctx, "flow_sched")
+	if got := second.scheduler.GetAllTriggers()[0].NextRun; !got.Equal(stored.NextRunAt) {
+		t.Errorf("Expected resynced next run %v, got %v", stored.NextRunAt, got)
+	}
+}
@@ -0,0 +1,266 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// FlowTemplate is a ready-made flow that is copied into a zone. Node and
+// trigger configs hold ${param:name} placeholders for the template's
+// parameters; {{...}} templates are left for the runner to resolve.
+type FlowTemplate struct {
+	ID          string              `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Category    string              `json:"category"`
+	Parameters  []TemplateParameter `json:"parameters"`
+	Trigger     domain.Trigger      `json:"trigger"`
+	Nodes       []domain.Node       `json:"nodes"`
+	Edges       []domain.Edge       `json:"edges"`
+}
+
+// TemplateParameter is a value filled into a template when a flow is
+// created from it
+type TemplateParameter struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Type        string      `json:"type"` // "string" or "number"
+	Required    bool        `json:"required"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// MissingParametersError is returned by InstantiateTemplate when required
+// parameters were not supplied
+type MissingParametersError struct {
+	Names []string
+}
+
+func (e *MissingParametersError) Error() string {
+	return "missing parameters: " + strings.Join(e.Names, ", ")
+}
+
+var paramPlaceholder = regexp.MustCompile(`\$\{param:([^}]+)\}`)
+
+// FlowTemplates returns the built-in templates, ordered by ID
+func FlowTemplates() []*FlowTemplate {
+	templates := make([]*FlowTemplate, len(builtinTemplates))
+	copy(templates, builtinTemplates)
+	sort.Slice(templates, func(i, j int) bool { return templates[i].ID < templates[j].ID })
+	return templates
+}
+
+// GetFlowTemplate returns the built-in template with the ID
+func GetFlowTemplate(id string) (*FlowTemplate, bool) {
+	for _, t := range builtinTemplates {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// InstantiateTemplate creates a disabled flow in the zone from a template.
+// A config value that is exactly one placeholder takes the parameter's
+// value and type; placeholders inside longer strings are replaced by the
+// value's text. The flow still has to be validated and saved.
+func InstantiateTemplate(t *FlowTemplate, orgID, zoneID string, params map[string]interface{}) (*domain.Flow, error) {
+	values, err := t.resolveParameters(params)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	f := &domain.Flow{
+		ID:          fmt.Sprintf("flow_%d", now.UnixNano()),
+		OrgID:       orgID,
+		ZoneID:      zoneID,
+		Name:        t.Name,
+		Description: t.Description,
+		Enabled:     false,
+		Trigger:     t.Trigger,
+		Nodes:       make([]domain.Node, len(t.Nodes)),
+		Edges:       append([]domain.Edge(nil), t.Edges...),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if f.Trigger.Config, err = fillParameters(t.Trigger.Config, values); err != nil {
+		return nil, fmt.Errorf("trigger config: %w", err)
+	}
+	for i, node := range t.Nodes {
+		if node.Data, err = fillParameters(node.Data, values); err != nil {
+			return nil, fmt.Errorf("node %s: %w", node.ID, err)
+		}
+		f.Nodes[i] = node
+	}
+	return f, nil
+}
+
+// resolveParameters checks the supplied parameters against the template's
+// and fills in defaults
+func (t *FlowTemplate) resolveParameters(params map[string]interface{}) (map[string]interface{}, error) {
+	declared := make(map[string]bool, len(t.Parameters))
+	values := make(map[string]interface{}, len(t.Parameters))
+	var missing []string
+	for _, p := range t.Parameters {
+		declared[p.Name] = true
+		value, ok := params[p.Name]
+		if !ok || value == nil || value == "" {
+			if p.Required {
+				missing = append(missing, p.Name)
+				continue
+			}
+			value = p.Default
+		}
+		if value == nil && p.Type != "number" {
+			value = ""
+		}
+		switch p.Type {
+		case "number":
+			if _, isNumber := value.(float64); !isNumber && value != nil {
+				return nil, fmt.Errorf("parameter %s must be a number", p.Name)
+			}
+		default:
+			if _, isString := value.(string); !isString {
+				return nil, fmt.Errorf("parameter %s must be a string", p.Name)
+			}
+		}
+		values[p.Name] = value
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, &MissingParametersError{Names: missing}
+	}
+	for name := range params {
+		if !declared[name] {
+			return nil, fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+	return values, nil
+}
+
+// fillParameters replaces the placeholders in a config with parameter values
+func fillParameters(raw json.RawMessage, values map[string]interface{}) (json.RawMessage, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+	var config interface{}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, err
+	}
+
+	var fill func(v interface{}) interface{}
+	fill = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				v[key] = fill(child)
+			}
+			return v
+		case []interface{}:
+			for i, child := range v {
+				v[i] = fill(child)
+			}
+			return v
+		case string:
+			if m := paramPlaceholder.FindStringSubmatch(v); m != nil && m[0] == v {
+				return values[m[1]]
+			}
+			return paramPlaceholder.ReplaceAllStringFunc(v, func(match string) string {
+				value := values[paramPlaceholder.FindStringSubmatch(match)[1]]
+				if value == nil {
+					return ""
+				}
+				return fmt.Sprintf("%v", value)
+			})
+		}
+		return v
+	}
+	return json.Marshal(fill(config))
+}
+
+var builtinTemplates = []*FlowTemplate{
+	{
+		ID:          "payment-failed-slack-alert",
+		Name:        "Payment failed alert",
+		Description: "Posts a message to Slack whenever a payment fails",
+		Category:    "alerts",
+		Parameters: []TemplateParameter{
+			{Name: "slack_webhook_url", Description: "Slack incoming webhook URL", Type: "string", Default: "{{secrets.SLACK_WEBHOOK_URL}}"},
+			{Name: "channel", Description: "Channel to post to", Type: "string", Default: "#payments"},
+		},
+		Trigger: domain.Trigger{Type: "event", EventType: "payment.failed"},
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger, Data: json.RawMessage(`{}`)},
+			{ID: "alert", Type: "slack", Data: json.RawMessage(`{
+				"webhook_url":"${param:slack_webhook_url}",
+				"channel":"${param:channel}",
+				"text":":warning: Payment {{id}} of {{amount}} {{currency}} failed: {{failure_reason}}"}`)},
+		},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "alert"}},
+	},
+	{
+		ID:          "refund-confirmation-email",
+		Name:        "Refund confirmation email",
+		Description: "Emails the customer once a refund has completed",
+		Category:    "notifications",
+		Parameters: []TemplateParameter{
+			{Name: "from_address", Description: "Sender address", Type: "string", Required: true},
+			{Name: "smtp_host", Description: "SMTP server host", Type: "string", Required: true},
+			{Name: "smtp_port", Description: "SMTP server port", Type: "string", Default: "587"},
+			{Name: "smtp_username", Description: "SMTP user", Type: "string"},
+			{Name: "smtp_password", Description: "SMTP password", Type: "string", Default: "{{secrets.SMTP_PASSWORD}}"},
+			{Name: "subject", Description: "Email subject", Type: "string", Default: "Your refund has been processed"},
+		},
+		Trigger: domain.Trigger{Type: "event", EventType: "refund.completed"},
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger, Data: json.RawMessage(`{}`)},
+			{ID: "email", Type: "email", Data: json.RawMessage(`{
+				"to":"{{customer_email}}",
+				"from":"${param:from_address}",
+				"subject":"${param:subject}",
+				"body":"<p>We have refunded {{amount}} {{currency}} for payment {{payment_intent_id}}. It may take a few days to appear on your statement.</p>",
+				"smtp_host":"${param:smtp_host}",
+				"smtp_port":"${param:smtp_port}",
+				"username":"${param:smtp_username}",
+				"password":"${param:smtp_password}"}`)},
+		},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "email"}},
+	},
+	{
+		ID:          "fraud-hold-escalation",
+		Name:        "Fraud hold escalation",
+		Description: "Alerts the risk team about high-risk payments and holds them for approval",
+		Category:    "risk",
+		Parameters: []TemplateParameter{
+			{Name: "risk_threshold", Description: "Risk score above which a payment is held", Type: "number", Default: 80.0},
+			{Name: "slack_webhook_url", Description: "Slack incoming webhook URL of the risk team", Type: "string", Default: "{{secrets.SLACK_WEBHOOK_URL}}"},
+			{Name: "channel", Description: "Channel to post to", Type: "string", Default: "#risk"},
+			{Name: "approver_role", Description: "Role that may release the payment: admin, finance or owner", Type: "string", Default: "finance"},
+			{Name: "timeout_hours", Description: "Hours before an unanswered hold expires", Type: "number", Default: 24.0},
+		},
+		Trigger: domain.Trigger{Type: "event", EventType: "payment.created"},
+		Nodes: []domain.Node{
+			{ID: "trigger", Type: domain.NodeTrigger, Data: json.RawMessage(`{}`)},
+			{ID: "check", Type: domain.NodeCondition, Data: json.RawMessage(`{"field":"risk_score","operator":"gt","value":"${param:risk_threshold}"}`)},
+			{ID: "alert", Type: "slack", Data: json.RawMessage(`{
+				"webhook_url":"${param:slack_webhook_url}",
+				"channel":"${param:channel}",
+				"text":":rotating_light: Payment {{id}} of {{amount}} {{currency}} has risk score {{risk_score}} and is on hold"}`)},
+			{ID: "hold", Type: domain.NodeApproval, Data: json.RawMessage(`{
+				"approverRole":"${param:approver_role}",
+				"timeoutHours":"${param:timeout_hours}",
+				"message":"Release payment {{id}} held for fraud review?"}`)},
+		},
+		Edges: []domain.Edge{
+			{ID: "e1", Source: "trigger", Target: "check"},
+			{ID: "e2", Source: "check", Target: "alert", SourceHandle: "true"},
+			{ID: "e3", Source: "alert", Target: "hold"},
+		},
+	},
+}
@@ -2,7 +2,11 @@ package testutil
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
 )
@@ -12,6 +16,16 @@ type MockFlowRepository struct {
 	flows      map[string]*domain.Flow
 	executions map[string]*domain.FlowExecution
 	events     map[string]*domain.Event
+	sessions   map[string]*domain.DebugSession
+	debugLog   map[string][]domain.DebugEvent
+	versions   map[string][]*domain.FlowVersion
+	schedules  map[string]*domain.ReplaySchedule
+	hooks      map[string]*domain.WebhookHook
+	flowScheds map[string]*domain.FlowSchedule
+	secrets    map[string]*domain.ZoneSecret
+	deadLetter map[string]*domain.DeadLetter
+	replays    []*domain.ReplayAttempt
+	deliveries []*domain.WebhookDelivery
 }
 
 func NewMockFlowRepository() *MockFlowRepository {
@@ -19,25 +33,42 @@ func NewMockFlowRepository() *MockFlowRepository {
 		flows:      make(map[string]*domain.Flow),
 		executions: make(map[string]*domain.FlowExecution),
 		events:     make(map[string]*domain.Event),
+		sessions:   make(map[string]*domain.DebugSession),
+		debugLog:   make(map[string][]domain.DebugEvent),
+		versions:   make(map[string][]*domain.FlowVersion),
+		schedules:  make(map[string]*domain.ReplaySchedule),
+		hooks:      make(map[string]*domain.WebhookHook),
+		flowScheds: make(map[string]*domain.FlowSchedule),
+		secrets:    make(map[string]*domain.ZoneSecret),
+		deadLetter: make(map[string]*domain.DeadLetter),
 	}
 }
 
 func (m *MockFlowRepository) CreateFlow(ctx context.Context, flow *domain.Flow) error {
+	flow.Version = 1
 	m.flows[flow.ID] = flow
+	m.snapshot(flow)
 	return nil
 }
 
 func (m *MockFlowRepository) GetFlow(ctx context.Context, id string) (*domain.Flow, error) {
-	if flow, exists := m.flows[id]; exists {
+	if flow, exists := m.flows[id]; exists && flow.DeletedAt == nil {
 		return flow, nil
 	}
 	return nil, domain.ErrFlowNotFound
 }
 
+func (m *MockFlowRepository) GetFlowZoneID(ctx context.Context, id string) (string, error) {
+	if flow, exists := m.flows[id]; exists {
+		return flow.ZoneID, nil
+	}
+	return "", domain.ErrFlowNotFound
+}
+
 func (m *MockFlowRepository) ListFlows(ctx context.Context, zoneID string) ([]*domain.Flow, error) {
 	var flows []*domain.Flow
 	for _, flow := range m.flows {
-		if flow.ZoneID == zoneID {
+		if flow.ZoneID == zoneID && flow.DeletedAt == nil {
 			flows = append(flows, flow)
 		}
 	}
@@ -45,10 +76,55 @@ func (m *MockFlowRepository) ListFlows(ctx context.Context, zoneID string) ([]*d
 }
 
 func (m *MockFlowRepository) UpdateFlow(ctx context.Context, flow *domain.Flow) error {
+	if existing, exists := m.flows[flow.ID]; exists {
+		flow.Version = existing.Version + 1
+	}
 	m.flows[flow.ID] = flow
+	m.snapshot(flow)
+	return nil
+}
+
+func (m *MockFlowRepository) snapshot(flow *domain.Flow) {
+	m.versions[flow.ID] = append(m.versions[flow.ID], &domain.FlowVersion{
+		ID:        len(m.versions[flow.ID]) + 1,
+		FlowID:    flow.ID,
+		Version:   flow.Version,
+		Nodes:     flow.Nodes,
+		Edges:     flow.Edges,
+		CreatedAt: time.Now(),
+	})
+}
+
+func (m *MockFlowRepository) DeleteFlow(ctx context.Context, id string) error {
+	flow, exists := m.flows[id]
+	if !exists || flow.DeletedAt != nil {
+		return domain.ErrFlowNotFound
+	}
+	now := time.Now()
+	flow.DeletedAt = &now
 	return nil
 }
 
+func (m *MockFlowRepository) RestoreFlow(ctx context.Context, id string) error {
+	flow, exists := m.flows[id]
+	if !exists || flow.DeletedAt == nil {
+		return domain.ErrFlowNotFound
+	}
+	flow.DeletedAt = nil
+	return nil
+}
+
+func (m *MockFlowRepository) PurgeDeletedFlows(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	for id, flow := range m.flows {
+		if flow.DeletedAt != nil && flow.DeletedAt.Before(deletedBefore) {
+			delete(m.flows, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
 func (m *MockFlowRepository) CreateExecution(ctx context.Context, exec *domain.FlowExecution) error {
 	m.executions[exec.ID] = exec
 	return nil
@@ -75,6 +151,35 @@ func (m *MockFlowRepository) ListExecutions(ctx context.Context, flowID string,
 	}
 	return executions, nil
 }
+func (m *MockFlowRepository) ClaimExecution(ctx context.Context, id string, checkpointedAt, claimedAt time.Time) (bool, error) {
+	exec, exists := m.executions[id]
+	if !exists || exec.Status != domain.ExecutionRunning {
+		return false, nil
+	}
+	lastSeen := exec.CheckpointedAt
+	if lastSeen.IsZero() {
+		lastSeen = exec.StartedAt
+	}
+	if !lastSeen.Equal(checkpointedAt) {
+		return false, nil
+	}
+	exec.CheckpointedAt = claimedAt
+	return true, nil
+}
+
+func (m *MockFlowRepository) ListIncompleteExecutions(ctx context.Context, checkpointedBefore time.Time) ([]*domain.FlowExecution, error) {
+	var executions []*domain.FlowExecution
+	for _, exec := range m.executions {
+		lastSeen := exec.CheckpointedAt
+		if lastSeen.IsZero() {
+			lastSeen = exec.StartedAt
+		}
+		if exec.Status == domain.ExecutionRunning && lastSeen.Before(checkpointedBefore) {
+			executions = append(executions, exec)
+		}
+	}
+	return executions, nil
+}
 
 func (m *MockFlowRepository) BulkUpdateFlowsEnabled(ctx context.Context, ids []string, enabled bool) error {
 	for _, id := range ids {
@@ -107,14 +212,435 @@ func (m *MockFlowRepository) GetEventByID(ctx context.Context, id string) (*doma
 	return nil, fmt.Errorf("event not found")
 }
 
+func (m *MockFlowRepository) QueryEvents(ctx context.Context, filter domain.EventFilter) (*domain.EventPage, error) {
+	var matched []*domain.Event
+	for _, event := range m.events {
+		if event.ZoneID == filter.ZoneID && matchesEventFilter(event, filter) {
+			matched = append(matched, event)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].ID > matched[j].ID
+		}
+		return matched[i].CreatedAt.After(matched[j].CreatedAt)
+	})
+
+	if filter.Cursor != "" {
+		createdAt, id, err := domain.DecodeEventCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		start := len(matched)
+		for i, event := range matched {
+			if event.CreatedAt.Before(createdAt) || (event.CreatedAt.Equal(createdAt) && event.ID < id) {
+				start = i
+				break
+			}
+		}
+		matched = matched[start:]
+	}
+
+	page := &domain.EventPage{Events: matched}
+	if len(matched) > filter.Limit {
+		page.Events = matched[:filter.Limit]
+		page.NextCursor = domain.EncodeEventCursor(page.Events[len(page.Events)-1])
+	}
+	return page, nil
+}
+
+func matchesEventFilter(event *domain.Event, filter domain.EventFilter) bool {
+	if len(filter.Types) > 0 {
+		found := false
+		for _, t := range filter.Types {
+			if t == event.Type {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !filter.From.IsZero() && event.CreatedAt.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !event.CreatedAt.Before(filter.To) {
+		return false
+	}
+	if len(filter.DataFilters) > 0 {
+		var data map[string]interface{}
+		json.Unmarshal(event.Data, &data)
+		for path, expected := range filter.DataFilters {
+			var current interface{} = data
+			for _, key := range strings.Split(path, ".") {
+				obj, ok := current.(map[string]interface{})
+				if !ok {
+					return false
+				}
+				current = obj[key]
+			}
+			if current == nil || fmt.Sprintf("%v", current) != expected {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func (m *MockFlowRepository) CreateFlowVersion(ctx context.Context, version *domain.FlowVersion) error {
+	m.versions[version.FlowID] = append(m.versions[version.FlowID], version)
 	return nil
 }
 
 func (m *MockFlowRepository) GetFlowVersions(ctx context.Context, flowID string) ([]*domain.FlowVersion, error) {
-	return nil, nil
+	versions := m.versions[flowID]
+	result := make([]*domain.FlowVersion, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		result = append(result, versions[i])
+	}
+	return result, nil
 }
 
 func (m *MockFlowRepository) GetFlowVersion(ctx context.Context, flowID string, version int) (*domain.FlowVersion, error) {
+	for _, v := range m.versions[flowID] {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, domain.ErrVersionNotFound
+}
+
+func (m *MockFlowRepository) SaveDebugSession(ctx context.Context, session *domain.DebugSession) error {
+	stored := *session
+	stored.Events = nil
+	m.sessions[session.ID] = &stored
+	return nil
+}
+
+func (m *MockFlowRepository) GetDebugSession(ctx context.Context, id string) (*domain.DebugSession, error) {
+	if session, exists := m.sessions[id]; exists {
+		loaded := *session
+		return &loaded, nil
+	}
+	return nil, fmt.Errorf("debug session not found")
+}
+
+func (m *MockFlowRepository) CreateDebugEvent(ctx context.Context, sessionID string, event *domain.DebugEvent) error {
+	m.debugLog[sessionID] = append(m.debugLog[sessionID], *event)
+	if session, exists := m.sessions[sessionID]; exists {
+		session.LastActivity = event.Timestamp
+	}
+	return nil
+}
+
+func (m *MockFlowRepository) GetDebugEvents(ctx context.Context, sessionID string, since *time.Time) ([]domain.DebugEvent, error) {
+	var events []domain.DebugEvent
+	for _, event := range m.debugLog[sessionID] {
+		if since == nil || !event.Timestamp.Before(*since) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (m *MockFlowRepository) DeleteInactiveDebugSessions(ctx context.Context, inactiveSince time.Time) (int64, error) {
+	var deleted int64
+	for id, session := range m.sessions {
+		if session.LastActivity.Before(inactiveSince) {
+			delete(m.sessions, id)
+			delete(m.debugLog, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MockFlowRepository) CreateReplaySchedule(ctx context.Context, schedule *domain.ReplaySchedule) error {
+	m.schedules[schedule.ID] = schedule
+	return nil
+}
+
+func (m *MockFlowRepository) GetReplaySchedule(ctx context.Context, id string) (*domain.ReplaySchedule, error) {
+	if schedule, exists := m.schedules[id]; exists {
+		return schedule, nil
+	}
+	return nil, domain.ErrReplayScheduleNotFound
+}
+
+func (m *MockFlowRepository) ListReplaySchedules(ctx context.Context, zoneID string) ([]*domain.ReplaySchedule, error) {
+	var schedules []*domain.ReplaySchedule
+	for _, schedule := range m.schedules {
+		if schedule.ZoneID == zoneID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+func (m *MockFlowRepository) UpdateReplaySchedule(ctx context.Context, schedule *domain.ReplaySchedule) error {
+	if _, exists := m.schedules[schedule.ID]; !exists {
+		return domain.ErrReplayScheduleNotFound
+	}
+	m.schedules[schedule.ID] = schedule
+	return nil
+}
+
+func (m *MockFlowRepository) DeleteReplaySchedule(ctx context.Context, id string) error {
+	if _, exists := m.schedules[id]; !exists {
+		return domain.ErrReplayScheduleNotFound
+	}
+	delete(m.schedules, id)
+	return nil
+}
+
+func (m *MockFlowRepository) ListDueReplaySchedules(ctx context.Context, now time.Time) ([]*domain.ReplaySchedule, error) {
+	var due []*domain.ReplaySchedule
+	for _, schedule := range m.schedules {
+		if schedule.Enabled && !schedule.NextRunAt.After(now) {
+			due = append(due, schedule)
+		}
+	}
+	return due, nil
+}
+
+func (m *MockFlowRepository) CreateWebhookHook(ctx context.Context, hook *domain.WebhookHook) error {
+	stored := *hook
+	m.hooks[hook.ID] = &stored
+	return nil
+}
+
+func (m *MockFlowRepository) GetWebhookHook(ctx context.Context, id string) (*domain.WebhookHook, error) {
+	if hook, exists := m.hooks[id]; exists {
+		loaded := *hook
+		return &loaded, nil
+	}
+	return nil, domain.ErrWebhookHookNotFound
+}
+
+func (m *MockFlowRepository) ListWebhookHooks(ctx context.Context, zoneID string) ([]*domain.WebhookHook, error) {
+	var hooks []*domain.WebhookHook
+	for _, hook := range m.hooks {
+		if hook.ZoneID == zoneID {
+			listed := *hook
+			listed.Secret = ""
+			hooks = append(hooks, &listed)
+		}
+	}
+	return hooks, nil
+}
+
+func (m *MockFlowRepository) DeleteWebhookHook(ctx context.Context, id string) error {
+	if _, exists := m.hooks[id]; !exists {
+		return domain.ErrWebhookHookNotFound
+	}
+	delete(m.hooks, id)
+	return nil
+}
+
+func (m *MockFlowRepository) SaveFlowSchedule(ctx context.Context, schedule *domain.FlowSchedule) error {
+	stored := *schedule
+	m.flowScheds[schedule.FlowID] = &stored
+	return nil
+}
+
+func (m *MockFlowRepository) GetFlowSchedule(ctx context.Context, flowID string) (*domain.FlowSchedule, error) {
+	if schedule, exists := m.flowScheds[flowID]; exists {
+		loaded := *schedule
+		return &loaded, nil
+	}
+	return nil, domain.ErrFlowScheduleNotFound
+}
+
+func (m *MockFlowRepository) ListFlowSchedules(ctx context.Context, zoneID string) ([]*domain.FlowSchedule, error) {
+	var schedules []*domain.FlowSchedule
+	for _, schedule := range m.flowScheds {
+		if schedule.ZoneID == zoneID {
+			listed := *schedule
+			schedules = append(schedules, &listed)
+		}
+	}
+	return schedules, nil
+}
+
+func (m *MockFlowRepository) ListEnabledFlowSchedules(ctx context.Context) ([]*domain.FlowSchedule, error) {
+	var schedules []*domain.FlowSchedule
+	for _, schedule := range m.flowScheds {
+		if schedule.Enabled {
+			listed := *schedule
+			schedules = append(schedules, &listed)
+		}
+	}
+	return schedules, nil
+}
+
+func (m *MockFlowRepository) DeleteFlowSchedule(ctx context.Context, flowID string) error {
+	if _, exists := m.flowScheds[flowID]; !exists {
+		return domain.ErrFlowScheduleNotFound
+	}
+	delete(m.flowScheds, flowID)
+	return nil
+}
+
+func (m *MockFlowRepository) ClaimFlowScheduleRun(ctx context.Context, flowID string, dueAt, ranAt, nextRunAt time.Time) (bool, error) {
+	schedule, exists := m.flowScheds[flowID]
+	if !exists || !schedule.Enabled || !schedule.NextRunAt.Equal(dueAt) {
+		return false, nil
+	}
+	schedule.LastRunAt = &ranAt
+	schedule.NextRunAt = nextRunAt
+	schedule.UpdatedAt = ranAt
+	return true, nil
+}
+
+func (m *MockFlowRepository) SaveZoneSecret(ctx context.Context, secret *domain.ZoneSecret) error {
+	stored := *secret
+	m.secrets[secret.ZoneID+"/"+secret.Name] = &stored
+	return nil
+}
+
+func (m *MockFlowRepository) GetZoneSecret(ctx context.Context, zoneID, name string) (*domain.ZoneSecret, error) {
+	if secret, exists := m.secrets[zoneID+"/"+name]; exists {
+		loaded := *secret
+		return &loaded, nil
+	}
+	return nil, domain.ErrZoneSecretNotFound
+}
+
+func (m *MockFlowRepository) ListZoneSecrets(ctx context.Context, zoneID string) ([]*domain.ZoneSecret, error) {
+	var secrets []*domain.ZoneSecret
+	for _, secret := range m.secrets {
+		if secret.ZoneID == zoneID {
+			listed := *secret
+			listed.Ciphertext = nil
+			secrets = append(secrets, &listed)
+		}
+	}
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].Name < secrets[j].Name })
+	return secrets, nil
+}
+
+func (m *MockFlowRepository) DeleteZoneSecret(ctx context.Context, zoneID, name string) error {
+	if _, exists := m.secrets[zoneID+"/"+name]; !exists {
+		return domain.ErrZoneSecretNotFound
+	}
+	delete(m.secrets, zoneID+"/"+name)
+	return nil
+}
+
+func (m *MockFlowRepository) CreateReplayAttempt(ctx context.Context, attempt *domain.ReplayAttempt) error {
+	stored := *attempt
+	m.replays = append(m.replays, &stored)
+	return nil
+}
+
+func (m *MockFlowRepository) FindSuccessfulReplay(ctx context.Context, zoneID, eventID, key string, since time.Time) (*domain.ReplayAttempt, error) {
+	for i := len(m.replays) - 1; i >= 0; i-- {
+		a := m.replays[i]
+		if a.ZoneID == zoneID && a.EventID == eventID && (key == "" || a.IdempotencyKey == key) &&
+			a.Status == "success" && !a.CreatedAt.Before(since) {
+			found := *a
+			return &found, nil
+		}
+	}
 	return nil, nil
 }
+
+func (m *MockFlowRepository) ListReplayAttempts(ctx context.Context, eventID string) ([]*domain.ReplayAttempt, error) {
+	var attempts []*domain.ReplayAttempt
+	for i := len(m.replays) - 1; i >= 0; i-- {
+		if m.replays[i].EventID == eventID {
+			listed := *m.replays[i]
+			attempts = append(attempts, &listed)
+		}
+	}
+	return attempts, nil
+}
+
+func (m *MockFlowRepository) CreateWebhookDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	stored := *delivery
+	m.deliveries = append(m.deliveries, &stored)
+	return nil
+}
+
+func (m *MockFlowRepository) ListWebhookDeliveries(ctx context.Context, f domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, error) {
+	var deliveries []*domain.WebhookDelivery
+	for i := len(m.deliveries) - 1; i >= 0; i-- {
+		d := m.deliveries[i]
+		if d.FlowID == f.FlowID && (f.ExecutionID == "" || d.ExecutionID == f.ExecutionID) && (f.NodeID == "" || d.NodeID == f.NodeID) {
+			listed := *d
+			deliveries = append(deliveries, &listed)
+		}
+	}
+	if f.Offset >= len(deliveries) {
+		return nil, nil
+	}
+	deliveries = deliveries[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(deliveries) {
+		deliveries = deliveries[:f.Limit]
+	}
+	return deliveries, nil
+}
+
+func (m *MockFlowRepository) CreateDeadLetter(ctx context.Context, entry *domain.DeadLetter) error {
+	stored := *entry
+	m.deadLetter[entry.ID] = &stored
+	return nil
+}
+
+func (m *MockFlowRepository) GetDeadLetter(ctx context.Context, id string) (*domain.DeadLetter, error) {
+	if entry, exists := m.deadLetter[id]; exists {
+		loaded := *entry
+		return &loaded, nil
+	}
+	return nil, domain.ErrDeadLetterNotFound
+}
+
+func (m *MockFlowRepository) UpdateDeadLetter(ctx context.Context, entry *domain.DeadLetter) error {
+	if _, exists := m.deadLetter[entry.ID]; !exists {
+		return domain.ErrDeadLetterNotFound
+	}
+	stored := *entry
+	m.deadLetter[entry.ID] = &stored
+	return nil
+}
+
+func (m *MockFlowRepository) ListDeadLetters(ctx context.Context, zoneID string, status domain.DeadLetterStatus, limit, offset int) ([]*domain.DeadLetter, error) {
+	var entries []*domain.DeadLetter
+	for _, entry := range m.deadLetter {
+		if entry.ZoneID == zoneID && (status == "" || entry.Status == status) {
+			listed := *entry
+			entries = append(entries, &listed)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].CreatedAt.After(entries[j].CreatedAt) })
+	if offset >= len(entries) {
+		return nil, nil
+	}
+	entries = entries[offset:]
+	if limit > 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (m *MockFlowRepository) ListDueDeadLetters(ctx context.Context, now time.Time) ([]*domain.DeadLetter, error) {
+	var due []*domain.DeadLetter
+	for _, entry := range m.deadLetter {
+		if entry.Status == domain.DeadLetterScheduled && entry.NextRetryAt != nil && !entry.NextRetryAt.After(now) {
+			listed := *entry
+			due = append(due, &listed)
+		}
+	}
+	return due, nil
+}
+
+func (m *MockFlowRepository) ClaimDeadLetterRetry(ctx context.Context, id string, now time.Time) (bool, error) {
+	entry, exists := m.deadLetter[id]
+	if !exists || (entry.Status != domain.DeadLetterScheduled && entry.Status != domain.DeadLetterDead) {
+		return false, nil
+	}
+	entry.Status = domain.DeadLetterRetrying
+	entry.NextRetryAt = nil
+	entry.UpdatedAt = now
+	return true, nil
+}
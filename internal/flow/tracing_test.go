@@ -0,0 +1,73 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/nodes"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestFlowRunner_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	runner := domain.NewFlowRunner(testutil.NewMockFlowRepository())
+	nodes.DefaultNodeRegistry().Install(runner)
+
+	config, _ := json.Marshal(map[string]interface{}{"url": server.URL})
+	f := &domain.Flow{
+		ID: "traced", Name: "Traced", ZoneID: "zone_1",
+		Nodes: []domain.Node{{ID: "trigger", Type: domain.NodeTrigger}, {ID: "hook", Type: "webhook_action", Data: config}},
+		Edges: []domain.Edge{{ID: "e1", Source: "trigger", Target: "hook"}},
+	}
+	if err := runner.Execute(context.Background(), f, map[string]interface{}{}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	root, ok := spans["flow.execute Traced"]
+	if !ok {
+		t.Fatalf("Expected an execution span, got %v", spans)
+	}
+	hook, ok := spans["flow.node webhook_action"]
+	if !ok {
+		t.Fatalf("Expected a webhook_action node span, got %v", spans)
+	}
+	if hook.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Errorf("Expected the node span to be a child of the execution span")
+	}
+
+	attrs := map[string]interface{}{}
+	for _, kv := range hook.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if attrs["flow.node.success"] != true || attrs["flow.node.retries"] != int64(0) {
+		t.Errorf("Unexpected node span attributes: %v", attrs)
+	}
+
+	if traceparent == "" || traceparent[3:35] != root.SpanContext().TraceID().String() {
+		t.Errorf("Expected the webhook request to carry the execution's trace, got %q", traceparent)
+	}
+}
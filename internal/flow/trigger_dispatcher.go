@@ -0,0 +1,198 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/triggers"
+)
+
+type zoneTriggers struct {
+	flowIDs  []string
+	loadedAt time.Time
+}
+
+// TriggerDispatcher matches incoming events against the event triggers of
+// enabled flows and starts their executions. It is shared by every trigger
+// source (Kafka, webhooks) so a zone's triggers are loaded only once.
+type TriggerDispatcher struct {
+	repo     domain.Repository
+	runner   *domain.FlowRunner
+	triggers *triggers.EventTriggerService
+	pool     *ExecutionPool // Optional: bounds concurrent executions
+	zones    map[string]zoneTriggers
+	// refreshInterval is how long a zone's registered triggers are reused
+	// before its flows are reloaded
+	refreshInterval time.Duration
+	mu              sync.Mutex
+}
+
+// NewTriggerDispatcher creates a new trigger dispatcher
+func NewTriggerDispatcher(repo domain.Repository, runner *domain.FlowRunner, refreshInterval time.Duration) *TriggerDispatcher {
+	return &TriggerDispatcher{
+		repo:            repo,
+		runner:          runner,
+		triggers:        triggers.NewEventTriggerService(),
+		zones:           make(map[string]zoneTriggers),
+		refreshInterval: refreshInterval,
+	}
+}
+
+// UseExecutionPool runs executions on the pool instead of starting one
+// goroutine per matched flow
+func (d *TriggerDispatcher) UseExecutionPool(pool *ExecutionPool) {
+	d.pool = pool
+}
+
+// Match returns the enabled flows whose trigger matches the event
+func (d *TriggerDispatcher) Match(ctx context.Context, event *triggers.Event) ([]*domain.Flow, error) {
+	if err := d.loadZone(ctx, event.ZoneID); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	matched, err := d.triggers.Match(ctx, event)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var flows []*domain.Flow
+	for _, trigger := range matched {
+		flow, err := d.repo.GetFlow(ctx, trigger.FlowID)
+		if err != nil || !flow.Enabled {
+			continue
+		}
+		flows = append(flows, flow)
+		flowTriggerMatches.WithLabelValues(flow.ID, event.ZoneID).Inc()
+	}
+
+	result := "matched"
+	if len(flows) == 0 {
+		result = "unmatched"
+	}
+	flowTriggerEvents.WithLabelValues(event.ZoneID, result).Inc()
+	return flows, nil
+}
+
+// Run records the event for replay and executes the given flows with the
+// input, returning once all of the executions have finished. With an
+// execution pool, executions the pool does not accept are skipped and their
+// errors returned.
+func (d *TriggerDispatcher) Run(ctx context.Context, flows []*domain.Flow, event *triggers.Event, input map[string]interface{}) error {
+	dataJSON, _ := json.Marshal(event.Data)
+	if err := d.repo.CreateEvent(ctx, &domain.Event{
+		ID:        event.ID,
+		Type:      event.Type,
+		ZoneID:    event.ZoneID,
+		Data:      dataJSON,
+		CreatedAt: event.CreatedAt,
+	}); err != nil {
+		log.Printf("Failed to persist event %s: %v", event.ID, err)
+	}
+
+	var wg sync.WaitGroup
+	var errs []error
+	for _, flow := range flows {
+		log.Printf("Executing flow %s for event %s (%s)", flow.ID, event.ID, event.Type)
+		if d.pool == nil {
+			wg.Add(1)
+			go func(flow *domain.Flow) {
+				defer wg.Done()
+				d.logResult(flow, event, d.runner.Execute(ctx, flow, input))
+			}(flow)
+			continue
+		}
+
+		done, err := d.pool.Submit(ctx, flow, input)
+		if err != nil {
+			log.Printf("Flow %s not executed for event %s: %v", flow.ID, event.ID, err)
+			errs = append(errs, fmt.Errorf("flow %s: %w", flow.ID, err))
+			continue
+		}
+		wg.Add(1)
+		go func(flow *domain.Flow) {
+			defer wg.Done()
+			d.logResult(flow, event, <-done)
+		}(flow)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (d *TriggerDispatcher) logResult(flow *domain.Flow, event *triggers.Event, err error) {
+	if err != nil {
+		log.Printf("Flow %s failed for event %s: %v", flow.ID, event.ID, err)
+	}
+}
+
+// loadZone registers triggers for the zone's enabled flows, reloading them
+// once the refresh interval has passed
+func (d *TriggerDispatcher) loadZone(ctx context.Context, zoneID string) error {
+	d.mu.Lock()
+	loaded, ok := d.zones[zoneID]
+	d.mu.Unlock()
+	if ok && time.Since(loaded.loadedAt) < d.refreshInterval {
+		return nil
+	}
+
+	flows, err := d.repo.ListFlows(ctx, zoneID)
+	if err != nil {
+		return fmt.Errorf("failed to load flows for zone %s: %w", zoneID, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, flowID := range loaded.flowIDs {
+		d.triggers.Unregister(flowID)
+	}
+
+	current := zoneTriggers{loadedAt: time.Now()}
+	for _, flow := range flows {
+		if !flow.Enabled {
+			continue
+		}
+		if trigger := eventTriggerFor(flow); trigger != nil {
+			d.triggers.Register(trigger)
+			current.flowIDs = append(current.flowIDs, flow.ID)
+		}
+	}
+	d.zones[zoneID] = current
+	return nil
+}
+
+// eventTriggerFor builds the event trigger declared by a flow's trigger node.
+// A trigger node without an event type matches every event in the zone.
+func eventTriggerFor(flow *domain.Flow) *triggers.EventTrigger {
+	for _, n := range flow.Nodes {
+		if n.Type != domain.NodeTrigger {
+			continue
+		}
+		var data struct {
+			EventType string            `json:"eventType"`
+			Filters   map[string]string `json:"filters"`
+		}
+		json.Unmarshal(n.Data, &data)
+
+		eventType := data.EventType
+		if eventType == "" {
+			eventType = flow.Trigger.EventType
+		}
+		if eventType == "" {
+			eventType = "*"
+		}
+
+		trigger := triggers.NewEventTrigger(eventType, flow.ZoneID, flow.ID)
+		for key, value := range data.Filters {
+			trigger.Filters[key] = value
+		}
+		return trigger
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package triggers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Invalid(t *testing.T) {
+	tests := []struct {
+		name, expr string
+	}{
+		{"empty", ""},
+		{"too few fields", "* * * *"},
+		{"too many fields", "* * * * * *"},
+		{"minute out of range", "60 * * * *"},
+		{"hour out of range", "* 24 * * *"},
+		{"day of month zero", "* * 0 * *"},
+		{"day of month out of range", "* * 32 * *"},
+		{"month out of range", "* * * 13 *"},
+		{"day of week out of range", "* * * * 8"},
+		{"zero step", "*/0 * * * *"},
+		{"negative step", "*/-5 * * * *"},
+		{"reversed range", "30-10 * * * *"},
+		{"empty list item", "1,,2 * * * *"},
+		{"not a number", "a * * * *"},
+		{"unknown month name", "* * * foo *"},
+		{"weekday name in month field", "* * * mon *"},
+		{"unknown macro", "@fortnightly"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCron(tt.expr); err == nil {
+				t.Errorf("Expected %q to be rejected", tt.expr)
+			}
+		})
+	}
+}
+
+func TestCronSchedule_Next(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+
+	// January 1st, 2026 is a Thursday
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", utc(2026, 1, 1, 10, 7, 30), utc(2026, 1, 1, 10, 8, 0)},
+		{"strictly after a match", "0 * * * *", utc(2026, 1, 1, 10, 0, 0), utc(2026, 1, 1, 11, 0, 0)},
+		{"minute step", "*/15 * * * *", utc(2026, 1, 1, 10, 7, 0), utc(2026, 1, 1, 10, 15, 0)},
+		{"step from a value", "5/20 * * * *", utc(2026, 1, 1, 10, 26, 0), utc(2026, 1, 1, 10, 45, 0)},
+		{"stepped hour range", "0 9-17/2 * * *", utc(2026, 1, 1, 10, 0, 0), utc(2026, 1, 1, 11, 0, 0)},
+		{"stepped hour range wraps to the next day", "0 9-17/2 * * *", utc(2026, 1, 1, 17, 0, 0), utc(2026, 1, 2, 9, 0, 0)},
+		{"hour list", "30 8,12,18 * * *", utc(2026, 1, 1, 12, 30, 0), utc(2026, 1, 1, 18, 30, 0)},
+		{"day of month list", "0 0 1,15 * *", utc(2026, 1, 2, 0, 0, 0), utc(2026, 1, 15, 0, 0, 0)},
+		{"weekday range by name", "0 12 * * MON-FRI", utc(2026, 1, 3, 12, 0, 0), utc(2026, 1, 5, 12, 0, 0)},
+		{"Sunday as 7", "0 0 * * 7", utc(2026, 1, 1, 0, 0, 0), utc(2026, 1, 4, 0, 0, 0)},
+		{"Sunday as 0", "0 0 * * 0", utc(2026, 1, 1, 0, 0, 0), utc(2026, 1, 4, 0, 0, 0)},
+		{"month by name", "0 0 1 jan *", utc(2026, 2, 1, 0, 0, 0), utc(2027, 1, 1, 0, 0, 0)},
+		{"last day of a 31-day month", "0 0 31 * *", utc(2026, 2, 1, 0, 0, 0), utc(2026, 3, 31, 0, 0, 0)},
+		{"leap day", "0 0 29 2 *", utc(2026, 3, 1, 0, 0, 0), utc(2028, 2, 29, 0, 0, 0)},
+		{"never matches", "0 0 30 2 *", utc(2026, 1, 1, 0, 0, 0), time.Time{}},
+		{"hourly macro", "@hourly", utc(2026, 1, 1, 10, 59, 0), utc(2026, 1, 1, 11, 0, 0)},
+		{"weekly macro", "@weekly", utc(2026, 1, 1, 0, 0, 0), utc(2026, 1, 4, 0, 0, 0)},
+
+		// Restricting both days matches either one: the 13th or a Friday
+		{"day of month or week, by weekday", "0 0 13 * 5", utc(2026, 1, 1, 0, 0, 0), utc(2026, 1, 2, 0, 0, 0)},
+		{"day of month or week, by date", "0 0 13 * 5", utc(2026, 1, 10, 0, 0, 0), utc(2026, 1, 13, 0, 0, 0)},
+		// A starred field restricts with the other one: the 13th only
+		{"day of month with any weekday", "0 0 13 * *", utc(2026, 1, 1, 0, 0, 0), utc(2026, 1, 13, 0, 0, 0)},
+		// "*/2" still counts as starred: odd days that are Mondays
+		{"stepped star day of month and weekday", "0 0 */2 * 1", utc(2026, 1, 1, 0, 0, 0), utc(2026, 1, 5, 0, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) failed: %v", tt.expr, err)
+			}
+			if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronSchedule_NextAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	local := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, loc)
+	}
+
+	// Clocks go from 02:00 to 03:00 on March 8th, 2026 and from 02:00 back
+	// to 01:00 on November 1st, 2026
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"skipped time does not run", "30 2 * * *", local(3, 8, 0, 0), local(3, 9, 2, 30)},
+		{"hourly across the gap", "0 * * * *", local(3, 8, 1, 30), local(3, 8, 3, 0)},
+		{"repeated time runs once", "30 1 * * *", local(11, 1, 1, 30), local(11, 2, 1, 30)},
+		{"repeated time runs in its first pass", "30 1 * * *", local(11, 1, 0, 0), local(11, 1, 1, 30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) failed: %v", tt.expr, err)
+			}
+			got := schedule.Next(tt.from)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+
+	// The repeated hour's second pass is not run again
+	schedule, _ := ParseCron("30 1 * * *")
+	first := local(11, 1, 1, 30)
+	if got := schedule.Next(first.Add(time.Hour)); !got.Equal(local(11, 2, 1, 30)) {
+		t.Errorf("Expected the repeated 01:30 to be skipped, got %v", got)
+	}
+}
@@ -3,21 +3,22 @@ package triggers
 import (
 	"context"
 	"fmt"
-	"strings"
 	"sync"
 	"time"
 )
 
-// ScheduleTrigger triggers flows based on time intervals
+// ScheduleTrigger triggers flows at a fixed interval or on a cron schedule
 type ScheduleTrigger struct {
-	Interval time.Duration `json:"interval"`
-	CronExpr string        `json:"cronExpression,omitempty"` // For documentation only
+	Interval time.Duration `json:"interval"`                 // Zero for cron triggers
+	CronExpr string        `json:"cronExpression,omitempty"` // Takes precedence over Interval
 	Timezone string        `json:"timezone,omitempty"`
 	FlowID   string        `json:"flowId"`
 	ZoneID   string        `json:"zoneId"`
 	LastRun  time.Time     `json:"lastRun,omitempty"`
 	NextRun  time.Time     `json:"nextRun,omitempty"`
+	DueAt    time.Time     `json:"dueAt,omitempty"` // Scheduled time of the most recent run
 	location *time.Location
+	cron     *CronSchedule
 }
 
 // NewScheduleTrigger creates a new schedule trigger
@@ -38,41 +39,36 @@ func NewScheduleTrigger(interval time.Duration, timezone, flowID, zoneID string)
 		ZoneID:   zoneID,
 		location: loc,
 	}
-	t.NextRun = time.Now().In(loc).Add(interval)
+	t.NextRun = t.nextAfter(time.Now())
 
 	return t, nil
 }
 
-// NewScheduleTriggerFromCron creates a trigger from a cron expression (simplified)
+// NewScheduleTriggerFromCron creates a trigger from a 5-field cron
+// expression evaluated in the given timezone
 func NewScheduleTriggerFromCron(cronExpr, timezone, flowID, zoneID string) (*ScheduleTrigger, error) {
-	interval := parseCronToInterval(cronExpr)
-	trigger, err := NewScheduleTrigger(interval, timezone, flowID, zoneID)
+	cron, err := ParseCron(cronExpr)
+	if err != nil {
+		return nil, err
+	}
+	trigger, err := NewScheduleTrigger(0, timezone, flowID, zoneID)
 	if err != nil {
 		return nil, err
 	}
 	trigger.CronExpr = cronExpr
+	trigger.cron = cron
+	trigger.NextRun = trigger.nextAfter(time.Now())
 	return trigger, nil
 }
 
-// parseCronToInterval converts simple cron expressions to intervals
-func parseCronToInterval(cron string) time.Duration {
-	cron = strings.TrimSpace(cron)
-	switch {
-	case strings.Contains(cron, "*/1 ") || cron == "* * * * *":
-		return time.Minute
-	case strings.HasPrefix(cron, "*/5"):
-		return 5 * time.Minute
-	case strings.HasPrefix(cron, "*/15"):
-		return 15 * time.Minute
-	case cron == "0 * * * *":
-		return time.Hour
-	case cron == "0 9 * * *", cron == "0 0 * * *":
-		return 24 * time.Hour
-	case strings.HasSuffix(cron, "* * 1"):
-		return 7 * 24 * time.Hour // Weekly
-	default:
-		return time.Hour // Default to hourly
+// nextAfter returns the run following t in the trigger's timezone
+func (t *ScheduleTrigger) nextAfter(after time.Time) time.Time {
+	after = after.In(t.location)
+	if t.cron != nil {
+		return t.cron.Next(after)
 	}
+	// Whole seconds so the time survives a round trip through the database
+	return after.Add(t.Interval).Truncate(time.Second)
 }
 
 // Type returns the trigger type
@@ -89,8 +85,9 @@ func (t *ScheduleTrigger) ShouldTrigger(ctx context.Context, input interface{})
 
 	now = now.In(t.location)
 
-	// Check if we've passed the next run time
-	if now.After(t.NextRun) || now.Equal(t.NextRun) {
+	// Check if we've passed the next run time; cron schedules with no
+	// further match have a zero NextRun and never fire
+	if !t.NextRun.IsZero() && !now.Before(t.NextRun) {
 		return true, nil
 	}
 
@@ -105,7 +102,8 @@ func (t *ScheduleTrigger) GetConfig() interface{} {
 // UpdateAfterRun updates the trigger state after a successful run
 func (t *ScheduleTrigger) UpdateAfterRun() {
 	t.LastRun = time.Now().In(t.location)
-	t.NextRun = t.LastRun.Add(t.Interval)
+	t.DueAt = t.NextRun
+	t.NextRun = t.nextAfter(t.LastRun)
 }
 
 // GetNextRunTime returns the next scheduled run time
@@ -171,21 +169,23 @@ func (s *ScheduleTriggerService) loop() {
 	}
 }
 
-// checkTriggers checks all triggers and fires any that are due
+// checkTriggers checks all triggers and fires any that are due. A trigger
+// is advanced before its handler runs so a slow run is not fired again on
+// the next tick; the handler receives a snapshot of the advanced trigger.
 func (s *ScheduleTriggerService) checkTriggers(now time.Time) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	for _, trigger := range s.triggers {
 		shouldFire, _ := trigger.ShouldTrigger(context.Background(), now)
 		if shouldFire && s.handler != nil {
-			go func(t *ScheduleTrigger) {
+			trigger.UpdateAfterRun()
+			go func(t ScheduleTrigger) {
 				ctx := context.Background()
-				if err := s.handler(ctx, t); err != nil {
+				if err := s.handler(ctx, &t); err != nil {
 					fmt.Printf("Schedule trigger error for flow %s: %v\n", t.FlowID, err)
 				}
-				t.UpdateAfterRun()
-			}(trigger)
+			}(*trigger)
 		}
 	}
 }
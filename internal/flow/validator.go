@@ -0,0 +1,174 @@
+package flow
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+	"github.com/sapliy/fintech-ecosystem/internal/flow/nodes"
+)
+
+// ValidationIssue is a single problem found in a flow definition
+type ValidationIssue struct {
+	NodeID  string `json:"node_id,omitempty"`
+	EdgeID  string `json:"edge_id,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem found in a flow definition
+type ValidationError struct {
+	Issues []ValidationIssue `json:"issues"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		switch {
+		case issue.NodeID != "":
+			msgs[i] = fmt.Sprintf("node %s: %s", issue.NodeID, issue.Message)
+		case issue.EdgeID != "":
+			msgs[i] = fmt.Sprintf("edge %s: %s", issue.EdgeID, issue.Message)
+		default:
+			msgs[i] = issue.Message
+		}
+	}
+	return "invalid flow: " + strings.Join(msgs, "; ")
+}
+
+// FlowValidator checks flow definitions before they are saved: node configs
+// against their registered schemas, edges against the nodes, and the graph
+// for a trigger, cycles and unreachable nodes
+type FlowValidator struct {
+	registry *nodes.NodeRegistry
+}
+
+// NewFlowValidator creates a validator for the node types in the registry
+func NewFlowValidator(registry *nodes.NodeRegistry) *FlowValidator {
+	return &FlowValidator{registry: registry}
+}
+
+// Validate returns a *ValidationError describing every problem found, or nil
+func (v *FlowValidator) Validate(flow *domain.Flow) error {
+	var issues []ValidationIssue
+
+	byID := make(map[string]*domain.Node, len(flow.Nodes))
+	var triggers []string
+	for i := range flow.Nodes {
+		node := &flow.Nodes[i]
+		if node.ID == "" {
+			issues = append(issues, ValidationIssue{Message: fmt.Sprintf("node %d has no ID", i)})
+			continue
+		}
+		if _, dup := byID[node.ID]; dup {
+			issues = append(issues, ValidationIssue{NodeID: node.ID, Message: "duplicate node ID"})
+			continue
+		}
+		byID[node.ID] = node
+		if node.Type == domain.NodeTrigger {
+			triggers = append(triggers, node.ID)
+		}
+		for _, problem := range v.registry.ValidateConfig(string(node.Type), node.Data) {
+			issues = append(issues, ValidationIssue{NodeID: node.ID, Message: problem})
+		}
+	}
+	if len(triggers) == 0 {
+		issues = append(issues, ValidationIssue{Message: "flow has no trigger node"})
+	}
+	if flow.RetryPolicy != nil {
+		if err := flow.RetryPolicy.Validate(); err != nil {
+			issues = append(issues, ValidationIssue{Message: err.Error()})
+		}
+	}
+	if flow.OnTimeoutNodeID != "" && byID[flow.OnTimeoutNodeID] == nil {
+		issues = append(issues, ValidationIssue{Message: fmt.Sprintf("on-timeout node %s does not exist", flow.OnTimeoutNodeID)})
+	}
+
+	next := make(map[string][]string)
+	for _, edge := range flow.Edges {
+		valid := true
+		for _, end := range []string{edge.Source, edge.Target} {
+			if byID[end] == nil {
+				issues = append(issues, ValidationIssue{EdgeID: edge.ID, Message: fmt.Sprintf("references unknown node %q", end)})
+				valid = false
+			}
+		}
+		if valid {
+			next[edge.Source] = append(next[edge.Source], edge.Target)
+		}
+	}
+
+	if cycle := findCycle(flow.Nodes, next); cycle != nil {
+		issues = append(issues, ValidationIssue{Message: "flow contains a cycle: " + strings.Join(cycle, " -> ")})
+	}
+
+	// Nodes run after a trigger or when the execution times out
+	roots := triggers
+	if byID[flow.OnTimeoutNodeID] != nil {
+		roots = append(roots, flow.OnTimeoutNodeID)
+	}
+	reached := make(map[string]bool)
+	for len(roots) > 0 {
+		id := roots[0]
+		roots = roots[1:]
+		if reached[id] {
+			continue
+		}
+		reached[id] = true
+		roots = append(roots, next[id]...)
+	}
+	if len(triggers) > 0 {
+		for _, node := range flow.Nodes {
+			if node.ID != "" && !reached[node.ID] {
+				issues = append(issues, ValidationIssue{NodeID: node.ID, Message: "node is unreachable from the trigger"})
+			}
+		}
+	}
+
+	if len(issues) > 0 {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
+
+// findCycle returns the node IDs along a cycle in the graph, if any
+func findCycle(flowNodes []domain.Node, next map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		state[id] = visiting
+		path = append(path, id)
+		for _, target := range next[id] {
+			switch state[target] {
+			case visiting:
+				for i, p := range path {
+					if p == target {
+						return append(append([]string{}, path[i:]...), target)
+					}
+				}
+			case unvisited:
+				if cycle := visit(target); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+
+	for _, node := range flowNodes {
+		if state[node.ID] == unvisited {
+			if cycle := visit(node.ID); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
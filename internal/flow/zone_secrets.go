@@ -0,0 +1,121 @@
+package flow
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/flow/domain"
+)
+
+// ErrInvalidSecretName is returned for names that cannot be referenced as
+// {{secrets.NAME}}
+var ErrInvalidSecretName = errors.New("secret names must start with a letter and contain only letters, digits and underscores")
+
+var secretName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+
+// ZoneSecrets stores zone secrets encrypted with AES-256-GCM and resolves
+// them for the flow runner. The zone and name are bound to each ciphertext
+// so a value cannot be moved to another secret in the store.
+type ZoneSecrets struct {
+	store domain.ZoneSecretStore
+	aead  cipher.AEAD
+}
+
+// NewZoneSecrets creates a secrets service encrypting with the 32-byte key
+func NewZoneSecrets(store domain.ZoneSecretStore, key []byte) (*ZoneSecrets, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secrets key must be 32 bytes for AES-256, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &ZoneSecrets{store: store, aead: aead}, nil
+}
+
+// ParseSecretsKey decodes a base64-encoded 32-byte key
+func ParseSecretsKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("secrets key must be base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secrets key must be 32 bytes for AES-256, got %d", len(key))
+	}
+	return key, nil
+}
+
+// Put creates or replaces a secret of the zone
+func (s *ZoneSecrets) Put(ctx context.Context, zoneID, name, value string) (*domain.ZoneSecret, error) {
+	if !secretName.MatchString(name) {
+		return nil, ErrInvalidSecretName
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	now := time.Now()
+	secret := &domain.ZoneSecret{
+		ZoneID:     zoneID,
+		Name:       name,
+		Ciphertext: s.aead.Seal(nonce, nonce, []byte(value), secretAAD(zoneID, name)),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if existing, err := s.store.GetZoneSecret(ctx, zoneID, name); err == nil {
+		secret.CreatedAt = existing.CreatedAt
+	} else if err != domain.ErrZoneSecretNotFound {
+		return nil, err
+	}
+
+	if err := s.store.SaveZoneSecret(ctx, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// List returns the zone's secrets without their values
+func (s *ZoneSecrets) List(ctx context.Context, zoneID string) ([]*domain.ZoneSecret, error) {
+	return s.store.ListZoneSecrets(ctx, zoneID)
+}
+
+// Delete removes a secret of the zone
+func (s *ZoneSecrets) Delete(ctx context.Context, zoneID, name string) error {
+	return s.store.DeleteZoneSecret(ctx, zoneID, name)
+}
+
+// ResolveSecret decrypts a secret of the zone
+func (s *ZoneSecrets) ResolveSecret(ctx context.Context, zoneID, name string) (string, error) {
+	secret, err := s.store.GetZoneSecret(ctx, zoneID, name)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := s.aead.NonceSize()
+	if len(secret.Ciphertext) < nonceSize {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := secret.Ciphertext[:nonceSize], secret.Ciphertext[nonceSize:]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, secretAAD(zoneID, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func secretAAD(zoneID, name string) []byte {
+	return []byte(zoneID + "/" + name)
+}
@@ -0,0 +1,233 @@
+package fraud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Case statuses. A case opens when a payment is sent for review or
+// declined, is picked up by a reviewer and is resolved as fraud or cleared.
+const (
+	CaseOpen           = "open"
+	CaseReviewing      = "reviewing"
+	CaseConfirmedFraud = "confirmed_fraud"
+	CaseCleared        = "cleared"
+)
+
+// caseTransitions are the statuses a case can move to from each status.
+// Resolved cases can be reopened for review.
+var caseTransitions = map[string][]string{
+	CaseOpen:           {CaseReviewing, CaseConfirmedFraud, CaseCleared},
+	CaseReviewing:      {CaseOpen, CaseConfirmedFraud, CaseCleared},
+	CaseConfirmedFraud: {CaseReviewing},
+	CaseCleared:        {CaseReviewing},
+}
+
+// MaxCaseNoteLength caps the length of a reviewer's note
+const MaxCaseNoteLength = 4000
+
+var (
+	ErrCaseNotFound      = errors.New("case not found")
+	ErrInvalidCaseStatus = errors.New("status must be open, reviewing, confirmed_fraud or cleared")
+	ErrCaseTransition    = errors.New("case cannot move to that status")
+	ErrEmptyCaseNote     = errors.New("note is empty")
+	ErrCaseNoteTooLong   = fmt.Errorf("notes are at most %d characters", MaxCaseNoteLength)
+)
+
+// Case is the review of a risky payment
+type Case struct {
+	ID         string     `json:"id"`
+	PaymentID  string     `json:"payment_id"`
+	ZoneID     string     `json:"zone_id"`
+	UserID     string     `json:"user_id"`
+	Score      int        `json:"score"`
+	Decision   Decision   `json:"decision"`
+	Signals    []Signal   `json:"signals"`
+	Status     string     `json:"status"`
+	AssignedTo string     `json:"assigned_to,omitempty"`
+	Notes      []CaseNote `json:"notes,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// Resolved reports whether the case was decided
+func (c *Case) Resolved() bool {
+	return c.Status == CaseConfirmedFraud || c.Status == CaseCleared
+}
+
+// CaseNote is a reviewer's note on a case, or a record of a change to it
+type CaseNote struct {
+	ID        string    `json:"id"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CaseFilter selects cases to list, newest first
+type CaseFilter struct {
+	ZoneID     string
+	UserID     string
+	PaymentID  string
+	Status     string
+	AssignedTo string
+	Limit      int
+}
+
+// CaseRepository keeps cases and their notes
+type CaseRepository interface {
+	// CreateCase opens the case of a payment unless it has one, and
+	// reports whether it did
+	CreateCase(ctx context.Context, c *Case) (bool, error)
+	// GetCase returns the case with its notes, or nil if there is none
+	GetCase(ctx context.Context, id string) (*Case, error)
+	ListCases(ctx context.Context, filter CaseFilter) ([]Case, error)
+	// UpdateCase saves the case's status and assignment
+	UpdateCase(ctx context.Context, c *Case) error
+	AddCaseNote(ctx context.Context, caseID string, note *CaseNote) error
+}
+
+// CaseService runs the case workflow
+type CaseService struct {
+	repo CaseRepository
+}
+
+func NewCaseService(repo CaseRepository) *CaseService {
+	return &CaseService{repo: repo}
+}
+
+// OpenCase opens a case for an assessment that was not approved. A payment
+// already having a case keeps it; the returned case is nil then.
+func (s *CaseService) OpenCase(ctx context.Context, a *Assessment) (*Case, error) {
+	now := time.Now().UTC()
+	c := &Case{
+		PaymentID: a.TransactionID,
+		ZoneID:    a.ZoneID,
+		UserID:    a.UserID,
+		Score:     a.Score,
+		Decision:  a.Decision,
+		Signals:   a.Flagged(),
+		Status:    CaseOpen,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	created, err := s.repo.CreateCase(ctx, c)
+	if err != nil || !created {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *CaseService) GetCase(ctx context.Context, id string) (*Case, error) {
+	c, err := s.repo.GetCase(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrCaseNotFound
+	}
+	return c, nil
+}
+
+func (s *CaseService) ListCases(ctx context.Context, filter CaseFilter) ([]Case, error) {
+	if filter.Status != "" && caseTransitions[filter.Status] == nil {
+		return nil, ErrInvalidCaseStatus
+	}
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 100
+	}
+	return s.repo.ListCases(ctx, filter)
+}
+
+// Assign hands the case to a reviewer, or unassigns it with an empty
+// assignee. An open case being assigned goes under review.
+func (s *CaseService) Assign(ctx context.Context, c *Case, assignee, actor string) error {
+	previous := c.AssignedTo
+	c.AssignedTo = assignee
+	if assignee != "" && c.Status == CaseOpen {
+		c.Status = CaseReviewing
+	}
+	c.UpdatedAt = time.Now().UTC()
+	if err := s.repo.UpdateCase(ctx, c); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Assigned to %s", assignee)
+	switch {
+	case assignee == "":
+		body = fmt.Sprintf("Unassigned from %s", previous)
+	case previous != "":
+		body = fmt.Sprintf("Reassigned from %s to %s", previous, assignee)
+	}
+	return s.addNote(ctx, c, actor, body)
+}
+
+// Transition moves the case to a status, recording why when a reason is
+// given
+func (s *CaseService) Transition(ctx context.Context, c *Case, status, actor, reason string) error {
+	if caseTransitions[status] == nil {
+		return ErrInvalidCaseStatus
+	}
+	allowed := false
+	for _, next := range caseTransitions[c.Status] {
+		allowed = allowed || next == status
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s to %s", ErrCaseTransition, c.Status, status)
+	}
+	if err := validateNote(reason, true); err != nil {
+		return err
+	}
+
+	previous := c.Status
+	now := time.Now().UTC()
+	c.Status = status
+	c.UpdatedAt = now
+	c.ResolvedAt = nil
+	if c.Resolved() {
+		c.ResolvedAt = &now
+	}
+	if err := s.repo.UpdateCase(ctx, c); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Status changed from %s to %s", previous, status)
+	if reason = strings.TrimSpace(reason); reason != "" {
+		body += ": " + reason
+	}
+	return s.addNote(ctx, c, actor, body)
+}
+
+// AddNote adds a reviewer's note to the case
+func (s *CaseService) AddNote(ctx context.Context, c *Case, author, body string) (*CaseNote, error) {
+	if err := validateNote(body, false); err != nil {
+		return nil, err
+	}
+	if err := s.addNote(ctx, c, author, strings.TrimSpace(body)); err != nil {
+		return nil, err
+	}
+	return &c.Notes[len(c.Notes)-1], nil
+}
+
+func (s *CaseService) addNote(ctx context.Context, c *Case, author, body string) error {
+	note := CaseNote{Author: author, Body: body, CreatedAt: time.Now().UTC()}
+	if err := s.repo.AddCaseNote(ctx, c.ID, &note); err != nil {
+		return err
+	}
+	c.Notes = append(c.Notes, note)
+	return nil
+}
+
+func validateNote(body string, optional bool) error {
+	body = strings.TrimSpace(body)
+	if body == "" && !optional {
+		return ErrEmptyCaseNote
+	}
+	if len(body) > MaxCaseNoteLength {
+		return ErrCaseNoteTooLong
+	}
+	return nil
+}
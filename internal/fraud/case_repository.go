@@ -0,0 +1,158 @@
+package fraud
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const caseColumns = `id, payment_id, zone_id, user_id, score, decision, signals, status, assigned_to,
+	created_at, updated_at, resolved_at`
+
+// SQLCaseRepository keeps cases in the fraud_cases and fraud_case_notes
+// tables
+type SQLCaseRepository struct {
+	db *sql.DB
+}
+
+func NewSQLCaseRepository(db *sql.DB) *SQLCaseRepository {
+	return &SQLCaseRepository{db: db}
+}
+
+func (r *SQLCaseRepository) CreateCase(ctx context.Context, c *Case) (bool, error) {
+	signals, err := json.Marshal(c.Signals)
+	if err != nil {
+		return false, err
+	}
+	err = r.db.QueryRowContext(ctx,
+		`INSERT INTO fraud_cases (payment_id, zone_id, user_id, score, decision, signals, status, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 ON CONFLICT (payment_id) DO NOTHING
+		 RETURNING id`,
+		c.PaymentID, c.ZoneID, c.UserID, c.Score, string(c.Decision), signals, c.Status, c.CreatedAt, c.UpdatedAt).
+		Scan(&c.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil // The payment already has a case
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create case: %w", err)
+	}
+	return true, nil
+}
+
+func (r *SQLCaseRepository) GetCase(ctx context.Context, id string) (*Case, error) {
+	c, err := scanCase(r.db.QueryRowContext(ctx,
+		"SELECT "+caseColumns+" FROM fraud_cases WHERE id::text = $1", id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get case: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, author, body, created_at FROM fraud_case_notes WHERE case_id = $1 ORDER BY created_at, id", c.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get case notes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var note CaseNote
+		if err := rows.Scan(&note.ID, &note.Author, &note.Body, &note.CreatedAt); err != nil {
+			return nil, err
+		}
+		c.Notes = append(c.Notes, note)
+	}
+	return c, rows.Err()
+}
+
+func (r *SQLCaseRepository) ListCases(ctx context.Context, filter CaseFilter) ([]Case, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.ZoneID != "" {
+		conditions = append(conditions, "zone_id = "+arg(filter.ZoneID))
+	}
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = "+arg(filter.UserID))
+	}
+	if filter.PaymentID != "" {
+		conditions = append(conditions, "payment_id = "+arg(filter.PaymentID))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+arg(filter.Status))
+	}
+	if filter.AssignedTo != "" {
+		conditions = append(conditions, "assigned_to = "+arg(filter.AssignedTo))
+	}
+
+	query := "SELECT " + caseColumns + " FROM fraud_cases"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC LIMIT " + arg(filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cases: %w", err)
+	}
+	defer rows.Close()
+
+	cases := []Case{}
+	for rows.Next() {
+		c, err := scanCase(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, *c)
+	}
+	return cases, rows.Err()
+}
+
+func (r *SQLCaseRepository) UpdateCase(ctx context.Context, c *Case) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE fraud_cases SET status = $1, assigned_to = $2, updated_at = $3, resolved_at = $4 WHERE id = $5`,
+		c.Status, c.AssignedTo, c.UpdatedAt, c.ResolvedAt, c.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update case: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrCaseNotFound
+	}
+	return nil
+}
+
+func (r *SQLCaseRepository) AddCaseNote(ctx context.Context, caseID string, note *CaseNote) error {
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO fraud_case_notes (case_id, author, body, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+		caseID, note.Author, note.Body, note.CreatedAt).Scan(&note.ID)
+	if err != nil {
+		return fmt.Errorf("failed to add case note: %w", err)
+	}
+	return nil
+}
+
+func scanCase(scan func(dest ...interface{}) error) (*Case, error) {
+	var c Case
+	var decision string
+	var signals []byte
+	var resolvedAt sql.NullTime
+	if err := scan(&c.ID, &c.PaymentID, &c.ZoneID, &c.UserID, &c.Score, &decision, &signals, &c.Status,
+		&c.AssignedTo, &c.CreatedAt, &c.UpdatedAt, &resolvedAt); err != nil {
+		return nil, err
+	}
+	c.Decision = Decision(decision)
+	if err := json.Unmarshal(signals, &c.Signals); err != nil {
+		return nil, fmt.Errorf("invalid signals of case %s: %w", c.ID, err)
+	}
+	if resolvedAt.Valid {
+		c.ResolvedAt = &resolvedAt.Time
+	}
+	return &c, nil
+}
@@ -0,0 +1,131 @@
+package fraud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// memoryCaseRepository keeps cases in a map
+type memoryCaseRepository struct {
+	cases map[string]*Case
+}
+
+func (r *memoryCaseRepository) CreateCase(ctx context.Context, c *Case) (bool, error) {
+	for _, existing := range r.cases {
+		if existing.PaymentID == c.PaymentID {
+			return false, nil
+		}
+	}
+	c.ID = fmt.Sprintf("case_%d", len(r.cases)+1)
+	stored := *c
+	r.cases[c.ID] = &stored
+	return true, nil
+}
+
+func (r *memoryCaseRepository) GetCase(ctx context.Context, id string) (*Case, error) {
+	c, ok := r.cases[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *c
+	return &found, nil
+}
+
+func (r *memoryCaseRepository) ListCases(ctx context.Context, filter CaseFilter) ([]Case, error) {
+	var cases []Case
+	for _, c := range r.cases {
+		cases = append(cases, *c)
+	}
+	return cases, nil
+}
+
+func (r *memoryCaseRepository) UpdateCase(ctx context.Context, c *Case) error {
+	stored := r.cases[c.ID]
+	stored.Status, stored.AssignedTo, stored.ResolvedAt = c.Status, c.AssignedTo, c.ResolvedAt
+	return nil
+}
+
+func (r *memoryCaseRepository) AddCaseNote(ctx context.Context, caseID string, note *CaseNote) error {
+	stored := r.cases[caseID]
+	note.ID = fmt.Sprintf("note_%d", len(stored.Notes)+1)
+	stored.Notes = append(stored.Notes, *note)
+	return nil
+}
+
+func TestCaseService_Workflow(t *testing.T) {
+	repo := &memoryCaseRepository{cases: map[string]*Case{}}
+	service := NewCaseService(repo)
+	ctx := context.Background()
+
+	a := &Assessment{
+		TransactionID: "pi_1", ZoneID: "zone_1", UserID: "user_1", Score: 90, Decision: DecisionDecline,
+		Signals: []Signal{{Rule: "big", Weight: 90, Flagged: true}, {Rule: "geo", Weight: 30}},
+	}
+	c, err := service.OpenCase(ctx, a)
+	if err != nil || c == nil {
+		t.Fatalf("OpenCase failed: %v", err)
+	}
+	if c.Status != CaseOpen || len(c.Signals) != 1 || c.PaymentID != "pi_1" {
+		t.Errorf("Unexpected case %+v", c)
+	}
+	// A payment has one case
+	if again, err := service.OpenCase(ctx, a); err != nil || again != nil {
+		t.Errorf("Expected no second case, got %+v, %v", again, err)
+	}
+
+	if err := service.Assign(ctx, c, "reviewer_1", "admin_1"); err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+	if c.Status != CaseReviewing {
+		t.Errorf("Expected an assigned case under review, got %s", c.Status)
+	}
+
+	if err := service.Transition(ctx, c, "closed", "reviewer_1", ""); !errors.Is(err, ErrInvalidCaseStatus) {
+		t.Errorf("Expected an invalid status error, got %v", err)
+	}
+	if err := service.Transition(ctx, c, CaseConfirmedFraud, "reviewer_1", "Stolen card"); err != nil {
+		t.Fatalf("Transition failed: %v", err)
+	}
+	if !c.Resolved() || c.ResolvedAt == nil {
+		t.Error("Expected the case to be resolved")
+	}
+	if err := service.Transition(ctx, c, CaseCleared, "reviewer_1", ""); !errors.Is(err, ErrCaseTransition) {
+		t.Errorf("Expected a resolved case not to be cleared directly, got %v", err)
+	}
+	if err := service.Transition(ctx, c, CaseReviewing, "reviewer_1", "Customer called"); err != nil || c.ResolvedAt != nil {
+		t.Errorf("Expected the case to be reopened, got %v", err)
+	}
+
+	if _, err := service.AddNote(ctx, c, "reviewer_1", "  "); !errors.Is(err, ErrEmptyCaseNote) {
+		t.Errorf("Expected an empty note to be rejected, got %v", err)
+	}
+	if _, err := service.AddNote(ctx, c, "reviewer_1", "Checked with the bank"); err != nil {
+		t.Fatalf("AddNote failed: %v", err)
+	}
+
+	stored, err := service.GetCase(ctx, c.ID)
+	if err != nil {
+		t.Fatalf("GetCase failed: %v", err)
+	}
+	// Assignment and transitions are recorded next to the notes
+	want := []string{
+		"Assigned to reviewer_1",
+		"Status changed from reviewing to confirmed_fraud: Stolen card",
+		"Status changed from confirmed_fraud to reviewing: Customer called",
+		"Checked with the bank",
+	}
+	if len(stored.Notes) != len(want) {
+		t.Fatalf("Expected %d notes, got %+v", len(want), stored.Notes)
+	}
+	for i, body := range want {
+		if stored.Notes[i].Body != body {
+			t.Errorf("Note %d: expected %q, got %q", i, body, stored.Notes[i].Body)
+		}
+	}
+
+	if _, err := service.GetCase(ctx, "case_404"); !errors.Is(err, ErrCaseNotFound) {
+		t.Errorf("Expected ErrCaseNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package fraud
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CheckPath is where the fraud service answers pre-authorization checks
+const CheckPath = "/v1/fraud/check"
+
+// CheckRequest is a payment about to be confirmed. Metadata may carry
+// customer_id, ip_country, card_country and device_id for the geo-mismatch
+// and new-device rules, card_fingerprint and ip_address for velocity, and
+// email and card_bin for the lists; the card falls back to the payment
+// method.
+type CheckRequest struct {
+	PaymentID       string            `json:"payment_id"`
+	ZoneID          string            `json:"zone_id"`
+	Mode            string            `json:"mode,omitempty"`
+	UserID          string            `json:"user_id"`
+	Amount          int64             `json:"amount"`
+	Currency        string            `json:"currency"`
+	PaymentMethodID string            `json:"payment_method_id,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+}
+
+// Transaction is the payment as the rules see it
+func (r CheckRequest) Transaction() Transaction {
+	return Transaction{
+		ID:       r.PaymentID,
+		ZoneID:   r.ZoneID,
+		Amount:   r.Amount,
+		Currency: r.Currency,
+		UserID:   r.UserID,
+
+		CustomerID:  r.Metadata["customer_id"],
+		IPCountry:   r.Metadata["ip_country"],
+		CardCountry: r.Metadata["card_country"],
+		DeviceID:    r.Metadata["device_id"],
+		CardID:      cmp.Or(r.Metadata["card_fingerprint"], r.PaymentMethodID),
+		IPAddress:   r.Metadata["ip_address"],
+		Email:       r.Metadata["email"],
+		BIN:         r.Metadata["card_bin"],
+	}
+}
+
+// CheckResponse is the decision on a payment. Fallback is set when the
+// fraud service could not decide in time and the payment was approved
+// without being scored.
+type CheckResponse struct {
+	PaymentID string   `json:"payment_id"`
+	Score     int      `json:"score"`
+	Decision  Decision `json:"decision"`
+	Signals   []Signal `json:"signals,omitempty"`
+	Fallback  bool     `json:"fallback,omitempty"`
+}
+
+// Client calls the fraud service's pre-authorization check within a
+// latency budget
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+	// Timeout is the latency budget of a check, 300ms if unset
+	Timeout time.Duration
+}
+
+// DefaultCheckTimeout is the latency budget of checks
+const DefaultCheckTimeout = 300 * time.Millisecond
+
+// CheckPayment asks the fraud service for a decision on the payment. A
+// check that fails or runs out of time approves the payment, so an
+// unavailable fraud service never blocks payments; the error says why.
+func (c *Client) CheckPayment(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
+	resp, err := c.checkPayment(ctx, req)
+	if err != nil {
+		return &CheckResponse{PaymentID: req.PaymentID, Decision: DecisionApprove, Fallback: true}, err
+	}
+	return resp, nil
+}
+
+func (c *Client) checkPayment(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.BaseURL, "/")+CheckPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("fraud check failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fraud check failed: %s", httpResp.Status)
+	}
+	var resp CheckResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("invalid fraud check response: %w", err)
+	}
+	switch resp.Decision {
+	case DecisionApprove, DecisionReview, DecisionDecline:
+	default:
+		return nil, fmt.Errorf("invalid fraud decision %q", resp.Decision)
+	}
+	return &resp, nil
+}
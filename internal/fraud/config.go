@@ -0,0 +1,378 @@
+package fraud
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// RuleConfig configures one rule of a rule set. Type picks the kind of rule
+// and Params, a JSON object, its settings; Name identifies the rule in
+// results and alerts. Weight is what the rule adds to a payment's risk
+// score when it flags it, DefaultRuleWeight if unset.
+type RuleConfig struct {
+	Name    string          `json:"name"`
+	Type    string          `json:"type"`
+	Enabled bool            `json:"enabled"`
+	Weight  *int            `json:"weight,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+func (cfg RuleConfig) weight() int {
+	if cfg.Weight == nil {
+		return DefaultRuleWeight
+	}
+	return *cfg.Weight
+}
+
+// RuleSet is a JSON rule set file. Thresholds are keyed by zone ID, with
+// DefaultZone applying to zones without their own.
+type RuleSet struct {
+	Rules      []RuleConfig          `json:"rules"`
+	Thresholds map[string]Thresholds `json:"thresholds,omitempty"`
+}
+
+// RuleEnv is what configured rules share with the engine building them
+type RuleEnv struct {
+	// Velocity keeps the windows of velocity rules, in memory if nil
+	Velocity VelocityStore
+}
+
+// RuleFactory builds a rule of a type from its params
+type RuleFactory func(params json.RawMessage, env RuleEnv) (Rule, error)
+
+var (
+	ruleTypesMu sync.RWMutex
+	ruleTypes   = map[string]RuleFactory{}
+)
+
+// RegisterRuleType makes a type of rule configurable. The built-in types
+// are amount_threshold, velocity, geo_mismatch and new_device.
+func RegisterRuleType(ruleType string, factory RuleFactory) {
+	ruleTypesMu.Lock()
+	defer ruleTypesMu.Unlock()
+	ruleTypes[ruleType] = factory
+}
+
+// duration is a time.Duration written as a string in JSON, e.g. "1m"
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"1m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func init() {
+	RegisterRuleType("amount_threshold", func(params json.RawMessage, env RuleEnv) (Rule, error) {
+		var p struct {
+			Limit    int64  `json:"limit"`
+			Currency string `json:"currency"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Limit <= 0 {
+			return nil, fmt.Errorf("limit must be positive")
+		}
+		return &AmountRule{Limit: p.Limit, Currency: p.Currency}, nil
+	})
+	RegisterRuleType("velocity", func(params json.RawMessage, env RuleEnv) (Rule, error) {
+		var p struct {
+			Window    duration    `json:"window"`
+			Threshold int         `json:"threshold"`
+			By        VelocityKey `json:"by"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Window <= 0 || p.Threshold <= 0 {
+			return nil, fmt.Errorf("window and threshold must be positive")
+		}
+		if !p.By.valid() {
+			return nil, fmt.Errorf("by must be user, card or ip")
+		}
+		rule := NewVelocityRule(time.Duration(p.Window), p.Threshold)
+		if p.By != "" {
+			rule.By = p.By
+		}
+		if env.Velocity != nil {
+			rule.Store = env.Velocity
+		}
+		return rule, nil
+	})
+	RegisterRuleType("geo_mismatch", func(params json.RawMessage, env RuleEnv) (Rule, error) {
+		var p struct {
+			Allowed map[string][]string `json:"allowed"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return &GeoMismatchRule{Allowed: p.Allowed}, nil
+	})
+	RegisterRuleType("new_device", func(params json.RawMessage, env RuleEnv) (Rule, error) {
+		p := struct {
+			TTL duration `json:"ttl"`
+		}{TTL: duration(30 * 24 * time.Hour)}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.TTL <= 0 {
+			return nil, fmt.Errorf("ttl must be positive")
+		}
+		return NewNewDeviceRule(time.Duration(p.TTL)), nil
+	})
+}
+
+// namedRule reports a configured rule under its configured name
+type namedRule struct {
+	Rule
+	name string
+}
+
+func (r *namedRule) Name() string { return r.name }
+
+func (r *namedRule) Check(ctx context.Context, tx Transaction) (RuleResult, error) {
+	res, err := r.Rule.Check(ctx, tx)
+	res.RuleName = r.name
+	return res, err
+}
+
+// BuildRule builds the rule a config describes
+func BuildRule(cfg RuleConfig, env RuleEnv) (Rule, error) {
+	ruleTypesMu.RLock()
+	factory, ok := ruleTypes[cfg.Type]
+	ruleTypesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("rule %s: unknown type %q", cfg.Name, cfg.Type)
+	}
+	rule, err := factory(cfg.Params, env)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %w", cfg.Name, err)
+	}
+	if cfg.Name == "" {
+		return rule, nil
+	}
+	return &namedRule{Rule: rule, name: cfg.Name}, nil
+}
+
+// ApplyConfig switches the engine to the enabled rules of the configs. A
+// rule configured as before is kept as is, so what it has learned, such as
+// a payer's recent payments, survives reloads. Nothing changes if any
+// config is invalid.
+func (e *Engine) ApplyConfig(configs []RuleConfig) error {
+	e.mu.RLock()
+	current := make(map[string]Rule, len(e.configs))
+	for i, cfg := range e.configs {
+		current[configKey(cfg)] = e.rules[i]
+	}
+	env := e.env
+	e.mu.RUnlock()
+
+	names := map[string]bool{}
+	var rules []Rule
+	var weights []int
+	var enabled []RuleConfig
+	for _, cfg := range configs {
+		if cfg.Name == "" {
+			return fmt.Errorf("rule of type %q has no name", cfg.Type)
+		}
+		if names[cfg.Name] {
+			return fmt.Errorf("rule %s is configured twice", cfg.Name)
+		}
+		names[cfg.Name] = true
+		if !cfg.Enabled {
+			continue
+		}
+		if w := cfg.weight(); w < 0 || w > MaxScore {
+			return fmt.Errorf("rule %s: weight must be between 0 and %d", cfg.Name, MaxScore)
+		}
+
+		rule, ok := current[configKey(cfg)]
+		if !ok {
+			var err error
+			if rule, err = BuildRule(cfg, env); err != nil {
+				return err
+			}
+		}
+		rules = append(rules, rule)
+		weights = append(weights, cfg.weight())
+		enabled = append(enabled, cfg)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+	e.weights = weights
+	e.configs = enabled
+	return nil
+}
+
+func configKey(cfg RuleConfig) string {
+	var params any
+	if err := json.Unmarshal(cfg.Params, &params); err != nil {
+		params = string(cfg.Params)
+	}
+	// Marshalling sorts object keys, so formatting does not matter
+	key, _ := json.Marshal([]any{cfg.Name, cfg.Type, params})
+	return string(key)
+}
+
+// RuleSource loads the rule set's configs
+type RuleSource interface {
+	LoadRules(ctx context.Context) ([]RuleConfig, error)
+}
+
+// FileRuleSource loads a JSON RuleSet file
+type FileRuleSource struct {
+	Path string
+}
+
+func (s FileRuleSource) LoadRules(ctx context.Context) ([]RuleConfig, error) {
+	set, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return set.Rules, nil
+}
+
+func (s FileRuleSource) LoadThresholds(ctx context.Context) (map[string]Thresholds, error) {
+	set, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return set.Thresholds, nil
+}
+
+func (s FileRuleSource) load() (*RuleSet, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules %s: %w", s.Path, err)
+	}
+	var set RuleSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse rules %s: %w", s.Path, err)
+	}
+	return &set, nil
+}
+
+// DBRuleSource loads the fraud_rules table
+type DBRuleSource struct {
+	DB *sql.DB
+}
+
+func (s DBRuleSource) LoadRules(ctx context.Context) ([]RuleConfig, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT name, type, enabled, weight, params
+		FROM fraud_rules
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rules: %w", err)
+	}
+	defer rows.Close()
+
+	var configs []RuleConfig
+	for rows.Next() {
+		var cfg RuleConfig
+		var weight int
+		var params []byte
+		if err := rows.Scan(&cfg.Name, &cfg.Type, &cfg.Enabled, &weight, &params); err != nil {
+			return nil, err
+		}
+		cfg.Weight = &weight
+		cfg.Params = params
+		configs = append(configs, cfg)
+	}
+	return configs, rows.Err()
+}
+
+func (s DBRuleSource) LoadThresholds(ctx context.Context) (map[string]Thresholds, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT zone_id, review_threshold, decline_threshold
+		FROM fraud_thresholds
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load thresholds: %w", err)
+	}
+	defer rows.Close()
+
+	thresholds := map[string]Thresholds{}
+	for rows.Next() {
+		var zoneID string
+		var t Thresholds
+		if err := rows.Scan(&zoneID, &t.Review, &t.Decline); err != nil {
+			return nil, err
+		}
+		thresholds[zoneID] = t
+	}
+	return thresholds, rows.Err()
+}
+
+// Load applies the source's rule set, and its thresholds if it is a
+// ThresholdSource
+func (e *Engine) Load(ctx context.Context, source RuleSource) error {
+	configs, err := source.LoadRules(ctx)
+	if err != nil {
+		return err
+	}
+	ts, ok := source.(ThresholdSource)
+	if !ok {
+		return e.ApplyConfig(configs)
+	}
+
+	thresholds, err := ts.LoadThresholds(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateThresholds(thresholds); err != nil {
+		return err
+	}
+	if err := e.ApplyConfig(configs); err != nil {
+		return err
+	}
+	e.SetThresholds(thresholds)
+	return nil
+}
+
+// WatchRules loads the source's rule set, and its thresholds if it is a
+// ThresholdSource, into the engine every interval until the context is
+// cancelled, so rules can be changed without a restart. A rule set that
+// fails to load is logged and the current one kept.
+func (e *Engine) WatchRules(ctx context.Context, source RuleSource, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Load(ctx, source); err != nil {
+				log.Printf("Failed to reload fraud rules, keeping the current ones: %v", err)
+			}
+		}
+	}
+}
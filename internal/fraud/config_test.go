@@ -0,0 +1,123 @@
+package fraud
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestApplyConfig_KeepsUnchangedRulesAcrossReloads(t *testing.T) {
+	engine := NewEngine()
+	configs := []RuleConfig{
+		{Name: "velocity", Type: "velocity", Enabled: true, Params: json.RawMessage(`{"window": "1m", "threshold": 1}`)},
+		{Name: "big", Type: "amount_threshold", Enabled: true, Params: json.RawMessage(`{"limit": 500}`)},
+	}
+	if err := engine.ApplyConfig(configs); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+
+	ctx := context.Background()
+	tx := Transaction{ID: "tx_1", Amount: 100, UserID: "user_1"}
+	if _, risky := engine.Check(ctx, tx); risky {
+		t.Fatal("expected the first payment to pass")
+	}
+
+	// Same velocity rule, reformatted, with a lower limit
+	configs[0].Params = json.RawMessage(`{"threshold":1,"window":"1m"}`)
+	configs[1].Params = json.RawMessage(`{"limit": 50}`)
+	if err := engine.ApplyConfig(configs); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+
+	tx.ID = "tx_2"
+	results, risky := engine.Check(ctx, tx)
+	if !risky {
+		t.Fatal("expected the second payment to be risky")
+	}
+	for _, res := range results {
+		if res.Passed {
+			t.Errorf("expected %s to flag the payment", res.RuleName)
+		}
+	}
+}
+
+func TestApplyConfig_RejectsInvalidRuleSets(t *testing.T) {
+	engine := NewEngine()
+	valid := RuleConfig{Name: "big", Type: "amount_threshold", Enabled: true, Params: json.RawMessage(`{"limit": 500}`)}
+	if err := engine.ApplyConfig([]RuleConfig{valid}); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+
+	for name, configs := range map[string][]RuleConfig{
+		"unknown type":  {valid, {Name: "x", Type: "nope", Enabled: true}},
+		"unknown param": {{Name: "big", Type: "amount_threshold", Enabled: true, Params: json.RawMessage(`{"limt": 5}`)}},
+		"bad duration":  {{Name: "v", Type: "velocity", Enabled: true, Params: json.RawMessage(`{"window": "soon", "threshold": 1}`)}},
+		"duplicate":     {valid, valid},
+	} {
+		if err := engine.ApplyConfig(configs); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if rules := engine.Rules(); len(rules) != 1 || rules[0].Name() != "big" {
+		t.Errorf("expected the previous rule set to be kept, got %d rules", len(rules))
+	}
+}
+
+func TestApplyConfig_SkipsDisabledRules(t *testing.T) {
+	engine := NewEngine()
+	err := engine.ApplyConfig([]RuleConfig{
+		{Name: "geo", Type: "geo_mismatch", Enabled: true, Params: json.RawMessage(`{"allowed": {"US": ["CA"]}}`)},
+		{Name: "device", Type: "new_device", Enabled: false},
+	})
+	if err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if rules := engine.Rules(); len(rules) != 1 || rules[0].Name() != "geo" {
+		t.Fatalf("expected only the geo rule, got %d rules", len(rules))
+	}
+
+	ctx := context.Background()
+	if _, risky := engine.Check(ctx, Transaction{CardCountry: "US", IPCountry: "CA"}); risky {
+		t.Error("expected an allowed country to pass")
+	}
+	if _, risky := engine.Check(ctx, Transaction{CardCountry: "US", IPCountry: "BR"}); !risky {
+		t.Error("expected a mismatched country to be risky")
+	}
+}
+
+func TestVelocityRule_CountsByKey(t *testing.T) {
+	engine := NewEngine()
+	store := NewMemoryVelocityStore()
+	engine.SetVelocityStore(store)
+	err := engine.ApplyConfig([]RuleConfig{
+		{Name: "card", Type: "velocity", Enabled: true, Params: json.RawMessage(`{"window": "1m", "threshold": 1, "by": "card"}`)},
+	})
+	if err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+
+	ctx := context.Background()
+	first := Transaction{ID: "tx_1", UserID: "user_1", CardID: "card_1"}
+	if _, risky := engine.Check(ctx, first); risky {
+		t.Fatal("expected the first payment to pass")
+	}
+	// A redelivered payment is not counted twice
+	if _, risky := engine.Check(ctx, first); risky {
+		t.Fatal("expected the same payment to pass again")
+	}
+	// Another user paying with the same card is
+	if _, risky := engine.Check(ctx, Transaction{ID: "tx_2", UserID: "user_2", CardID: "card_1"}); !risky {
+		t.Fatal("expected the second payment with the card to be risky")
+	}
+	// Payments without a card are not counted
+	if _, risky := engine.Check(ctx, Transaction{ID: "tx_3", UserID: "user_1"}); risky {
+		t.Fatal("expected a payment without a card to pass")
+	}
+
+	if err := engine.ApplyConfig([]RuleConfig{
+		{Name: "card", Type: "velocity", Enabled: true, Params: json.RawMessage(`{"window": "1m", "threshold": 1, "by": "phone"}`)},
+	}); err == nil {
+		t.Error("expected an unknown key to be rejected")
+	}
+}
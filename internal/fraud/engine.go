@@ -3,6 +3,7 @@ package fraud
 import (
 	"context"
 	"fmt"
+	"sync"
 )
 
 type RuleResult struct {
@@ -18,24 +19,91 @@ type Rule interface {
 
 type Transaction struct {
 	ID       string
+	ZoneID   string
 	Amount   int64
 	Currency string
 	UserID   string
+	// CustomerID is who paid, when the merchant said; rules keyed by payer
+	// fall back to UserID
+	CustomerID  string
+	IPCountry   string // ISO country of the payer's IP address
+	CardCountry string // ISO country the card was issued in
+	DeviceID    string // Fingerprint of the payer's device
+	CardID      string // Fingerprint of the card, or its payment method
+	IPAddress   string // The payer's IP address
+	Email       string // The payer's email address
+	BIN         string // The first 6 to 8 digits of the card number
+	// Allowlisted is set for payments matching the allowlist, which skip
+	// velocity checks
+	Allowlisted bool
 }
 
+// payer returns the key of the transaction's payer
+func (tx Transaction) payer() string {
+	if tx.CustomerID != "" {
+		return tx.CustomerID
+	}
+	return tx.UserID
+}
+
+// Engine runs every rule of its rule set against a transaction. The rule
+// set can be swapped while checking, see SetRules and WatchRules.
 type Engine struct {
-	rules []Rule
+	mu         sync.RWMutex
+	rules      []Rule
+	weights    []int
+	configs    []RuleConfig
+	thresholds map[string]Thresholds
+	env        RuleEnv
+	lists      *Lists
 }
 
 func NewEngine(rules ...Rule) *Engine {
-	return &Engine{rules: rules}
+	return &Engine{rules: rules, weights: defaultWeights(len(rules))}
+}
+
+// Rules returns the rules currently checked
+func (e *Engine) Rules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.rules
+}
+
+// SetVelocityStore makes the velocity rules configured from now on keep
+// their windows in the store, e.g. a RedisVelocityStore shared by every
+// instance
+func (e *Engine) SetVelocityStore(store VelocityStore) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.env.Velocity = store
+}
+
+// SetLists makes scoring decline payments matching the blocklist and skip
+// velocity checks for the allowlist
+func (e *Engine) SetLists(lists *Lists) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lists = lists
+}
+
+// SetRules replaces the rule set
+func (e *Engine) SetRules(rules ...Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+	e.weights = defaultWeights(len(rules))
+	e.configs = nil
 }
 
 func (e *Engine) Check(ctx context.Context, tx Transaction) ([]RuleResult, bool) {
-	results := make([]RuleResult, 0, len(e.rules))
+	return check(ctx, e.Rules(), tx)
+}
+
+func check(ctx context.Context, rules []Rule, tx Transaction) ([]RuleResult, bool) {
+	results := make([]RuleResult, 0, len(rules))
 	isRisky := false
 
-	for _, rule := range e.rules {
+	for _, rule := range rules {
 		res, err := rule.Check(ctx, tx)
 		if err != nil {
 			results = append(results, RuleResult{
@@ -0,0 +1,227 @@
+package fraud
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Outcomes of scored payments, learnt from their disputes. Payments never
+// disputed are taken as legitimate.
+const (
+	OutcomeDisputed   = "disputed"   // A dispute is open
+	OutcomeChargeback = "chargeback" // The dispute was lost: fraud
+	OutcomeLegitimate = "legitimate" // The dispute was won
+)
+
+// Periods rule performance is broken down by
+const (
+	PeriodDay  = "day"
+	PeriodWeek = "week"
+)
+
+var ErrInvalidPeriod = errors.New("interval must be day or week")
+
+// OutcomeOfDisputeEvent returns the outcome a dispute event labels its
+// payment with, if any
+func OutcomeOfDisputeEvent(eventType string) (string, bool) {
+	switch eventType {
+	case "dispute.created", "dispute.under_review":
+		return OutcomeDisputed, true
+	case "dispute.lost":
+		return OutcomeChargeback, true
+	case "dispute.won":
+		return OutcomeLegitimate, true
+	}
+	return "", false
+}
+
+// LabeledScore is a scored payment with what became of it
+type LabeledScore struct {
+	PaymentID string
+	ScoredAt  time.Time
+	Signals   []Signal
+	Outcome   string // Empty when never disputed
+}
+
+// RuleMetrics counts how a rule's flags compare with chargebacks. Payments
+// still disputed are left out until the dispute closes.
+type RuleMetrics struct {
+	Payments       int      `json:"payments"`
+	Flagged        int      `json:"flagged"`
+	Chargebacks    int      `json:"chargebacks"`
+	Pending        int      `json:"pending"`
+	TruePositives  int      `json:"true_positives"`
+	FalsePositives int      `json:"false_positives"`
+	FalseNegatives int      `json:"false_negatives"`
+	Precision      *float64 `json:"precision"` // Nil without flagged payments
+	Recall         *float64 `json:"recall"`    // Nil without chargebacks
+}
+
+func (m *RuleMetrics) add(flagged bool, outcome string) {
+	m.Payments++
+	if flagged {
+		m.Flagged++
+	}
+	switch {
+	case outcome == OutcomeDisputed:
+		m.Pending++
+	case outcome == OutcomeChargeback && flagged:
+		m.Chargebacks++
+		m.TruePositives++
+	case outcome == OutcomeChargeback:
+		m.Chargebacks++
+		m.FalseNegatives++
+	case flagged:
+		m.FalsePositives++
+	}
+}
+
+func (m *RuleMetrics) finish() {
+	if n := m.TruePositives + m.FalsePositives; n > 0 {
+		p := float64(m.TruePositives) / float64(n)
+		m.Precision = &p
+	}
+	if n := m.TruePositives + m.FalseNegatives; n > 0 {
+		r := float64(m.TruePositives) / float64(n)
+		m.Recall = &r
+	}
+}
+
+// PeriodMetrics are a rule's metrics over the payments scored in a period
+type PeriodMetrics struct {
+	Start time.Time `json:"start"`
+	RuleMetrics
+}
+
+// RulePerformance is how well a rule's flags predicted chargebacks over a
+// time range, in total and by period
+type RulePerformance struct {
+	Rule     string          `json:"rule"`
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"`
+	Interval string          `json:"interval"`
+	Total    RuleMetrics     `json:"total"`
+	Periods  []PeriodMetrics `json:"periods"`
+}
+
+// ComputeRulePerformance measures the rule over the payments scored from
+// from to to. Payments the rule did not run on, e.g. scored before it was
+// added, are left out.
+func ComputeRulePerformance(rule string, scores []LabeledScore, from, to time.Time, interval string) (*RulePerformance, error) {
+	var step func(time.Time) time.Time
+	var start time.Time
+	switch interval {
+	case PeriodDay:
+		start = from.UTC().Truncate(24 * time.Hour)
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case PeriodWeek:
+		day := from.UTC().Truncate(24 * time.Hour)
+		start = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)) // Monday
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	default:
+		return nil, ErrInvalidPeriod
+	}
+
+	perf := &RulePerformance{Rule: rule, From: from, To: to, Interval: interval, Periods: []PeriodMetrics{}}
+	for t := start; t.Before(to); t = step(t) {
+		perf.Periods = append(perf.Periods, PeriodMetrics{Start: t})
+	}
+
+	for _, s := range scores {
+		if s.ScoredAt.Before(from) || !s.ScoredAt.Before(to) {
+			continue
+		}
+		ran, flagged := false, false
+		for _, signal := range s.Signals {
+			if signal.Rule == rule {
+				ran, flagged = true, signal.Flagged
+				break
+			}
+		}
+		if !ran {
+			continue
+		}
+		perf.Total.add(flagged, s.Outcome)
+		for i := len(perf.Periods) - 1; i >= 0; i-- {
+			if !s.ScoredAt.Before(perf.Periods[i].Start) {
+				perf.Periods[i].add(flagged, s.Outcome)
+				break
+			}
+		}
+	}
+
+	perf.Total.finish()
+	for i := range perf.Periods {
+		perf.Periods[i].finish()
+	}
+	return perf, nil
+}
+
+// OutcomeStore labels scored payments and reads them back
+type OutcomeStore interface {
+	// LabelOutcome records the outcome of a scored payment. It reports
+	// false when the payment was never scored.
+	LabelOutcome(ctx context.Context, paymentID, disputeID, outcome string) (bool, error)
+	// LabeledScores returns the payments of the zone, or of every zone when
+	// empty, scored in the range with a signal of the rule
+	LabeledScores(ctx context.Context, rule, zoneID string, from, to time.Time) ([]LabeledScore, error)
+}
+
+// DBOutcomeStore keeps outcomes next to the scores in fraud_scores
+type DBOutcomeStore struct {
+	DB *sql.DB
+}
+
+func (s DBOutcomeStore) LabelOutcome(ctx context.Context, paymentID, disputeID, outcome string) (bool, error) {
+	// A chargeback is final: a later dispute of the payment does not
+	// relabel it as pending
+	result, err := s.DB.ExecContext(ctx, `
+		UPDATE fraud_scores SET outcome = $2, dispute_id = $3, labeled_at = NOW()
+		WHERE payment_id = $1 AND (outcome IS DISTINCT FROM 'chargeback' OR $2 <> 'disputed')
+	`, paymentID, outcome, disputeID)
+	if err != nil {
+		return false, fmt.Errorf("failed to label outcome of %s: %w", paymentID, err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+func (s DBOutcomeStore) LabeledScores(ctx context.Context, rule, zoneID string, from, to time.Time) ([]LabeledScore, error) {
+	ruleSignal, err := json.Marshal([]map[string]string{{"rule": rule}})
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT payment_id, scored_at, signals, outcome FROM fraud_scores
+		WHERE scored_at >= $1 AND scored_at < $2 AND signals @> $3`
+	args := []interface{}{from, to, string(ruleSignal)}
+	if zoneID != "" {
+		query += " AND zone_id = $4"
+		args = append(args, zoneID)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get labeled scores: %w", err)
+	}
+	defer rows.Close()
+
+	var scores []LabeledScore
+	for rows.Next() {
+		var ls LabeledScore
+		var signals []byte
+		var outcome sql.NullString
+		if err := rows.Scan(&ls.PaymentID, &ls.ScoredAt, &signals, &outcome); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(signals, &ls.Signals); err != nil {
+			return nil, fmt.Errorf("invalid signals of %s: %w", ls.PaymentID, err)
+		}
+		ls.Outcome = outcome.String
+		scores = append(scores, ls)
+	}
+	return scores, rows.Err()
+}
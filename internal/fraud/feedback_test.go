@@ -0,0 +1,61 @@
+package fraud
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeRulePerformance(t *testing.T) {
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC) // A Monday
+	flag := func(flagged bool) []Signal {
+		return []Signal{{Rule: "velocity", Flagged: flagged}, {Rule: "geo", Flagged: !flagged}}
+	}
+	scores := []LabeledScore{
+		{PaymentID: "tp", ScoredAt: day.Add(time.Hour), Signals: flag(true), Outcome: OutcomeChargeback},
+		{PaymentID: "fp", ScoredAt: day.Add(2 * time.Hour), Signals: flag(true)},
+		{PaymentID: "won", ScoredAt: day.Add(3 * time.Hour), Signals: flag(true), Outcome: OutcomeLegitimate},
+		{PaymentID: "fn", ScoredAt: day.Add(25 * time.Hour), Signals: flag(false), Outcome: OutcomeChargeback},
+		{PaymentID: "pending", ScoredAt: day.Add(26 * time.Hour), Signals: flag(true), Outcome: OutcomeDisputed},
+		{PaymentID: "tn", ScoredAt: day.Add(27 * time.Hour), Signals: flag(false)},
+		{PaymentID: "other rule", ScoredAt: day.Add(28 * time.Hour), Signals: []Signal{{Rule: "geo", Flagged: true}}},
+		{PaymentID: "out of range", ScoredAt: day.Add(72 * time.Hour), Signals: flag(true), Outcome: OutcomeChargeback},
+	}
+
+	perf, err := ComputeRulePerformance("velocity", scores, day, day.Add(48*time.Hour), PeriodDay)
+	if err != nil {
+		t.Fatalf("ComputeRulePerformance failed: %v", err)
+	}
+	total := perf.Total
+	if total.Payments != 6 || total.Flagged != 4 || total.Chargebacks != 2 || total.Pending != 1 {
+		t.Errorf("unexpected totals: %+v", total)
+	}
+	if total.TruePositives != 1 || total.FalsePositives != 2 || total.FalseNegatives != 1 {
+		t.Errorf("unexpected confusion counts: %+v", total)
+	}
+	if total.Precision == nil || *total.Precision != 1.0/3 || total.Recall == nil || *total.Recall != 0.5 {
+		t.Errorf("unexpected precision %v and recall %v", total.Precision, total.Recall)
+	}
+
+	if len(perf.Periods) != 2 {
+		t.Fatalf("expected 2 days, got %d", len(perf.Periods))
+	}
+	first, second := perf.Periods[0], perf.Periods[1]
+	if first.Payments != 3 || *first.Precision != 1.0/3 || *first.Recall != 1 {
+		t.Errorf("unexpected first day: %+v", first)
+	}
+	if second.Payments != 3 || second.Precision != nil || *second.Recall != 0 {
+		t.Errorf("unexpected second day: %+v", second)
+	}
+
+	weekly, err := ComputeRulePerformance("velocity", scores, day.Add(36*time.Hour), day.Add(10*24*time.Hour), PeriodWeek)
+	if err != nil {
+		t.Fatalf("ComputeRulePerformance failed: %v", err)
+	}
+	if len(weekly.Periods) != 2 || !weekly.Periods[0].Start.Equal(day) || weekly.Periods[0].Payments != 1 {
+		t.Errorf("unexpected weeks: %+v", weekly.Periods)
+	}
+
+	if _, err := ComputeRulePerformance("velocity", scores, day, day.Add(time.Hour), "month"); err != ErrInvalidPeriod {
+		t.Errorf("expected an invalid interval to be rejected, got %v", err)
+	}
+}
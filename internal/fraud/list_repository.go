@@ -0,0 +1,138 @@
+package fraud
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const listEntryColumns = `id, zone_id, list, type, value, reason, created_by, expires_at, created_at, updated_at`
+
+// SQLListRepository keeps list entries in the fraud_list_entries table
+type SQLListRepository struct {
+	db *sql.DB
+}
+
+func NewSQLListRepository(db *sql.DB) *SQLListRepository {
+	return &SQLListRepository{db: db}
+}
+
+func (r *SQLListRepository) CreateEntry(ctx context.Context, e *ListEntry) error {
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO fraud_list_entries (zone_id, list, type, value, reason, created_by, expires_at, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		e.ZoneID, e.List, e.Type, e.Value, e.Reason, e.CreatedBy, e.ExpiresAt, e.CreatedAt, e.UpdatedAt).
+		Scan(&e.ID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateEntry
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create list entry: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLListRepository) GetEntry(ctx context.Context, id string) (*ListEntry, error) {
+	e, err := scanListEntry(r.db.QueryRowContext(ctx,
+		"SELECT "+listEntryColumns+" FROM fraud_list_entries WHERE id::text = $1", id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get list entry: %w", err)
+	}
+	return e, nil
+}
+
+func (r *SQLListRepository) ListEntries(ctx context.Context, filter ListFilter) ([]ListEntry, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.ZoneID != "" {
+		conditions = append(conditions, "zone_id = "+arg(filter.ZoneID))
+	}
+	if filter.List != "" {
+		conditions = append(conditions, "list = "+arg(filter.List))
+	}
+	if filter.Type != "" {
+		conditions = append(conditions, "type = "+arg(filter.Type))
+	}
+	if filter.Value != "" {
+		conditions = append(conditions, "value = "+arg(filter.Value))
+	}
+
+	query := "SELECT " + listEntryColumns + " FROM fraud_list_entries"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC LIMIT " + arg(filter.Limit)
+	return r.queryEntries(ctx, query, args...)
+}
+
+func (r *SQLListRepository) UpdateEntry(ctx context.Context, e *ListEntry) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE fraud_list_entries SET reason = $1, expires_at = $2, updated_at = $3 WHERE id = $4`,
+		e.Reason, e.ExpiresAt, e.UpdatedAt, e.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update list entry: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrListEntryNotFound
+	}
+	return nil
+}
+
+func (r *SQLListRepository) DeleteEntry(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM fraud_list_entries WHERE id::text = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete list entry: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrListEntryNotFound
+	}
+	return nil
+}
+
+func (r *SQLListRepository) ActiveEntries(ctx context.Context) ([]ListEntry, error) {
+	return r.queryEntries(ctx,
+		"SELECT "+listEntryColumns+" FROM fraud_list_entries WHERE expires_at IS NULL OR expires_at > NOW()")
+}
+
+func (r *SQLListRepository) queryEntries(ctx context.Context, query string, args ...interface{}) ([]ListEntry, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []ListEntry{}
+	for rows.Next() {
+		e, err := scanListEntry(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+	return entries, rows.Err()
+}
+
+func scanListEntry(scan func(dest ...interface{}) error) (*ListEntry, error) {
+	var e ListEntry
+	var expiresAt sql.NullTime
+	if err := scan(&e.ID, &e.ZoneID, &e.List, &e.Type, &e.Value, &e.Reason, &e.CreatedBy, &expiresAt,
+		&e.CreatedAt, &e.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		e.ExpiresAt = &expiresAt.Time
+	}
+	return &e, nil
+}
@@ -0,0 +1,286 @@
+package fraud
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Managed lists. Payments matching a blocklist entry are declined without
+// running the rules; allowlisted ones skip velocity checks.
+const (
+	ListAllow = "allow"
+	ListBlock = "block"
+)
+
+// What list entries match
+const (
+	EntryUserID          = "user_id"
+	EntryEmail           = "email"
+	EntryCardFingerprint = "card_fingerprint"
+	EntryIP              = "ip"
+	EntryBIN             = "bin"
+)
+
+var (
+	ErrListEntryNotFound = errors.New("list entry not found")
+	ErrInvalidList       = errors.New("list must be allow or block")
+	ErrInvalidEntryType  = errors.New("type must be user_id, email, card_fingerprint, ip or bin")
+	ErrInvalidEntryValue = errors.New("invalid value for the entry type")
+	ErrEntryExpired      = errors.New("expires_at must be in the future")
+	ErrDuplicateEntry    = errors.New("the list already has this entry")
+)
+
+// ListEntry is a user, email, card, IP address or card BIN on a list. An
+// entry without a zone applies to every zone.
+type ListEntry struct {
+	ID        string     `json:"id"`
+	ZoneID    string     `json:"zone_id,omitempty"`
+	List      string     `json:"list"`
+	Type      string     `json:"type"`
+	Value     string     `json:"value"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Expired reports whether the entry no longer applies
+func (e *ListEntry) Expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
+
+// Normalize checks the entry and puts its value in the form it is matched
+// in: emails lowercased, IP addresses canonical and BINs 6 to 8 digits
+func (e *ListEntry) Normalize() error {
+	if e.List != ListAllow && e.List != ListBlock {
+		return ErrInvalidList
+	}
+	value := strings.TrimSpace(e.Value)
+	switch e.Type {
+	case EntryUserID, EntryCardFingerprint:
+	case EntryEmail:
+		value = strings.ToLower(value)
+		if !strings.Contains(value, "@") {
+			return ErrInvalidEntryValue
+		}
+	case EntryIP:
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return ErrInvalidEntryValue
+		}
+		value = addr.Unmap().String()
+	case EntryBIN:
+		if len(value) < 6 || len(value) > 8 || strings.Trim(value, "0123456789") != "" {
+			return ErrInvalidEntryValue
+		}
+	default:
+		return ErrInvalidEntryType
+	}
+	if value == "" {
+		return ErrInvalidEntryValue
+	}
+	e.Value = value
+	return nil
+}
+
+// ListFilter selects list entries, newest first
+type ListFilter struct {
+	ZoneID string
+	List   string
+	Type   string
+	Value  string
+	Limit  int
+}
+
+// ListRepository keeps list entries
+type ListRepository interface {
+	// CreateEntry adds an entry, failing with ErrDuplicateEntry when the
+	// zone's list has it
+	CreateEntry(ctx context.Context, e *ListEntry) error
+	// GetEntry returns the entry, or nil if there is none
+	GetEntry(ctx context.Context, id string) (*ListEntry, error)
+	ListEntries(ctx context.Context, filter ListFilter) ([]ListEntry, error)
+	// UpdateEntry saves the entry's reason and expiry
+	UpdateEntry(ctx context.Context, e *ListEntry) error
+	DeleteEntry(ctx context.Context, id string) error
+	// ActiveEntries returns every entry that has not expired
+	ActiveEntries(ctx context.Context) ([]ListEntry, error)
+}
+
+// Lists holds the active entries of every list in memory, for payments to
+// be matched against without a round trip to the database
+type Lists struct {
+	mu      sync.RWMutex
+	entries map[string][]ListEntry // list|type|value -> entries of any zone
+}
+
+func NewLists() *Lists {
+	return &Lists{entries: make(map[string][]ListEntry)}
+}
+
+func listKey(list, entryType, value string) string {
+	return list + "|" + entryType + "|" + value
+}
+
+// Set replaces the entries held
+func (l *Lists) Set(entries []ListEntry) {
+	byKey := make(map[string][]ListEntry, len(entries))
+	for _, e := range entries {
+		key := listKey(e.List, e.Type, e.Value)
+		byKey[key] = append(byKey[key], e)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = byKey
+}
+
+// Match returns the first entry of the list the transaction matches in its
+// zone, or nil
+func (l *Lists) Match(list string, tx Transaction) *ListEntry {
+	if l == nil {
+		return nil
+	}
+	now := time.Now()
+
+	candidates := []struct{ entryType, value string }{
+		{EntryUserID, tx.UserID},
+		{EntryUserID, tx.CustomerID},
+		{EntryEmail, strings.ToLower(strings.TrimSpace(tx.Email))},
+		{EntryCardFingerprint, tx.CardID},
+	}
+	if addr, err := netip.ParseAddr(tx.IPAddress); err == nil {
+		candidates = append(candidates, struct{ entryType, value string }{EntryIP, addr.Unmap().String()})
+	}
+	for n := 6; n <= 8 && n <= len(tx.BIN); n++ {
+		candidates = append(candidates, struct{ entryType, value string }{EntryBIN, tx.BIN[:n]})
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, c := range candidates {
+		if c.value == "" {
+			continue
+		}
+		for _, e := range l.entries[listKey(list, c.entryType, c.value)] {
+			if (e.ZoneID == "" || e.ZoneID == tx.ZoneID) && !e.Expired(now) {
+				return &e
+			}
+		}
+	}
+	return nil
+}
+
+// Refresh loads the active entries from the repository
+func (l *Lists) Refresh(ctx context.Context, repo ListRepository) error {
+	entries, err := repo.ActiveEntries(ctx)
+	if err != nil {
+		return err
+	}
+	l.Set(entries)
+	return nil
+}
+
+// Watch refreshes the entries every interval until the context is
+// cancelled, so changes made on other instances are picked up
+func (l *Lists) Watch(ctx context.Context, repo ListRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Refresh(ctx, repo); err != nil {
+				log.Printf("Failed to refresh fraud lists, keeping the current ones: %v", err)
+			}
+		}
+	}
+}
+
+// ListService manages list entries, keeping the in-memory lists in sync
+// with the changes it makes
+type ListService struct {
+	repo  ListRepository
+	lists *Lists
+}
+
+func NewListService(repo ListRepository, lists *Lists) *ListService {
+	return &ListService{repo: repo, lists: lists}
+}
+
+func (s *ListService) CreateEntry(ctx context.Context, e *ListEntry) error {
+	if err := e.Normalize(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if e.Expired(now) {
+		return ErrEntryExpired
+	}
+	e.CreatedAt, e.UpdatedAt = now, now
+	if err := s.repo.CreateEntry(ctx, e); err != nil {
+		return err
+	}
+	s.refresh(ctx)
+	return nil
+}
+
+func (s *ListService) GetEntry(ctx context.Context, id string) (*ListEntry, error) {
+	e, err := s.repo.GetEntry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrListEntryNotFound
+	}
+	return e, nil
+}
+
+func (s *ListService) ListEntries(ctx context.Context, filter ListFilter) ([]ListEntry, error) {
+	if filter.List != "" && filter.List != ListAllow && filter.List != ListBlock {
+		return nil, ErrInvalidList
+	}
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 100
+	}
+	return s.repo.ListEntries(ctx, filter)
+}
+
+// UpdateEntry changes the entry's reason and expiry; a nil expiry makes it
+// permanent
+func (s *ListService) UpdateEntry(ctx context.Context, e *ListEntry, reason string, expiresAt *time.Time) error {
+	now := time.Now().UTC()
+	if expiresAt != nil && !now.Before(*expiresAt) {
+		return ErrEntryExpired
+	}
+	e.Reason = reason
+	e.ExpiresAt = expiresAt
+	e.UpdatedAt = now
+	if err := s.repo.UpdateEntry(ctx, e); err != nil {
+		return err
+	}
+	s.refresh(ctx)
+	return nil
+}
+
+func (s *ListService) DeleteEntry(ctx context.Context, id string) error {
+	if err := s.repo.DeleteEntry(ctx, id); err != nil {
+		return err
+	}
+	s.refresh(ctx)
+	return nil
+}
+
+// refresh applies a change right away on this instance. Should it fail,
+// the change is picked up by the next Watch refresh.
+func (s *ListService) refresh(ctx context.Context) {
+	if err := s.lists.Refresh(ctx, s.repo); err != nil {
+		log.Printf("Failed to refresh fraud lists after a change: %v", err)
+	}
+}
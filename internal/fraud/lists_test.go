@@ -0,0 +1,107 @@
+package fraud
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestListEntry_Normalize(t *testing.T) {
+	tests := []struct {
+		entry ListEntry
+		value string
+		err   error
+	}{
+		{ListEntry{List: ListBlock, Type: EntryEmail, Value: " Fraud@Example.com "}, "fraud@example.com", nil},
+		{ListEntry{List: ListBlock, Type: EntryIP, Value: "::ffff:10.0.0.1"}, "10.0.0.1", nil},
+		{ListEntry{List: ListAllow, Type: EntryBIN, Value: "4242424"}, "4242424", nil},
+		{ListEntry{List: ListBlock, Type: EntryBIN, Value: "4242"}, "", ErrInvalidEntryValue},
+		{ListEntry{List: ListBlock, Type: EntryIP, Value: "not-an-ip"}, "", ErrInvalidEntryValue},
+		{ListEntry{List: ListBlock, Type: EntryUserID, Value: "  "}, "", ErrInvalidEntryValue},
+		{ListEntry{List: ListBlock, Type: "phone", Value: "555"}, "", ErrInvalidEntryType},
+		{ListEntry{List: "grey", Type: EntryUserID, Value: "user_1"}, "", ErrInvalidList},
+	}
+	for _, tt := range tests {
+		e := tt.entry
+		err := e.Normalize()
+		if err != tt.err {
+			t.Errorf("%s %q: got error %v, want %v", tt.entry.Type, tt.entry.Value, err, tt.err)
+			continue
+		}
+		if err == nil && e.Value != tt.value {
+			t.Errorf("%s %q: got %q, want %q", tt.entry.Type, tt.entry.Value, e.Value, tt.value)
+		}
+	}
+}
+
+func TestLists_Match(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	lists := NewLists()
+	lists.Set([]ListEntry{
+		{ID: "global", List: ListBlock, Type: EntryEmail, Value: "fraud@example.com"},
+		{ID: "zoned", ZoneID: "zone_1", List: ListBlock, Type: EntryUserID, Value: "user_1"},
+		{ID: "expired", List: ListBlock, Type: EntryIP, Value: "10.0.0.1", ExpiresAt: &past},
+		{ID: "bin", List: ListBlock, Type: EntryBIN, Value: "424242"},
+		{ID: "allowed", List: ListAllow, Type: EntryUserID, Value: "user_1"},
+	})
+
+	tests := []struct {
+		name string
+		tx   Transaction
+		want string
+	}{
+		{"global entry in any zone", Transaction{ZoneID: "zone_2", Email: "Fraud@Example.com"}, "global"},
+		{"zoned entry in its zone", Transaction{ZoneID: "zone_1", UserID: "user_1"}, "zoned"},
+		{"zoned entry in another zone", Transaction{ZoneID: "zone_2", UserID: "user_1"}, ""},
+		{"expired entry", Transaction{IPAddress: "10.0.0.1"}, ""},
+		{"BIN prefix", Transaction{BIN: "42424299"}, "bin"},
+		{"other BIN", Transaction{BIN: "55555555"}, ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if e := lists.Match(ListBlock, tt.tx); e != nil {
+			got = e.ID
+		}
+		if got != tt.want {
+			t.Errorf("%s: matched %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if e := lists.Match(ListAllow, Transaction{UserID: "user_1"}); e == nil || e.ID != "allowed" {
+		t.Error("expected the allowlist to match")
+	}
+}
+
+func TestScore_AppliesLists(t *testing.T) {
+	engine := NewEngine()
+	engine.SetVelocityStore(NewMemoryVelocityStore())
+	err := engine.ApplyConfig([]RuleConfig{
+		{Name: "velocity", Type: "velocity", Enabled: true, Weight: weight(90), Params: json.RawMessage(`{"window": "1m", "threshold": 1}`)},
+	})
+	if err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	lists := NewLists()
+	lists.Set([]ListEntry{
+		{List: ListBlock, Type: EntryCardFingerprint, Value: "card_stolen"},
+		{List: ListAllow, Type: EntryUserID, Value: "user_vip"},
+	})
+	engine.SetLists(lists)
+
+	ctx := context.Background()
+	a := engine.Score(ctx, Transaction{ID: "tx_1", UserID: "user_1", CardID: "card_stolen", Amount: 10})
+	if a.Decision != DecisionDecline || a.Score != MaxScore || len(a.Signals) != 1 || a.Signals[0].Rule != "Blocklist" {
+		t.Errorf("expected a blocklisted card to be declined, got %d (%s) with %v", a.Score, a.Decision, a.Signals)
+	}
+
+	for i, id := range []string{"tx_2", "tx_3", "tx_4"} {
+		a := engine.Score(ctx, Transaction{ID: id, UserID: "user_vip", Amount: 10})
+		if a.Decision != DecisionApprove {
+			t.Errorf("payment %d: expected an allowlisted user to skip velocity, got %s", i+1, a.Decision)
+		}
+	}
+	engine.Score(ctx, Transaction{ID: "tx_5", UserID: "user_2", Amount: 10})
+	if a := engine.Score(ctx, Transaction{ID: "tx_6", UserID: "user_2", Amount: 10}); a.Decision != DecisionDecline {
+		t.Errorf("expected other users to be velocity checked, got %s", a.Decision)
+	}
+}
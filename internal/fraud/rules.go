@@ -3,18 +3,25 @@ package fraud
 import (
 	"context"
 	"fmt"
+	"log"
+	"strings"
 	"sync"
 	"time"
 )
 
-// AmountRule checks if a transaction amount exceeds a limit.
+// AmountRule checks if a transaction amount exceeds a limit. With a
+// Currency set, only transactions in that currency are checked.
 type AmountRule struct {
-	Limit int64
+	Limit    int64
+	Currency string
 }
 
 func (r *AmountRule) Name() string { return "AmountRule" }
 
 func (r *AmountRule) Check(ctx context.Context, tx Transaction) (RuleResult, error) {
+	if r.Currency != "" && !strings.EqualFold(r.Currency, tx.Currency) {
+		return RuleResult{RuleName: r.Name(), Passed: true}, nil
+	}
 	if tx.Amount > r.Limit {
 		return RuleResult{
 			RuleName: r.Name(),
@@ -25,46 +32,132 @@ func (r *AmountRule) Check(ctx context.Context, tx Transaction) (RuleResult, err
 	return RuleResult{RuleName: r.Name(), Passed: true}, nil
 }
 
-// VelocityRule checks if a user has made too many transactions in a time window.
+// VelocityRule checks if a user, card or IP address has made too many
+// transactions in a time window. Transactions without the key, or
+// allowlisted, pass.
 type VelocityRule struct {
 	Window    time.Duration
 	Threshold int
-	mu        sync.Mutex
-	history   map[string][]time.Time
+	By        VelocityKey // user if unset
+	Store     VelocityStore
 }
 
+// NewVelocityRule counts each user's transactions in memory
 func NewVelocityRule(window time.Duration, threshold int) *VelocityRule {
 	return &VelocityRule{
 		Window:    window,
 		Threshold: threshold,
-		history:   make(map[string][]time.Time),
+		By:        VelocityByUser,
+		Store:     NewMemoryVelocityStore(),
 	}
 }
 
 func (r *VelocityRule) Name() string { return "VelocityRule" }
 
 func (r *VelocityRule) Check(ctx context.Context, tx Transaction) (RuleResult, error) {
+	by := r.By
+	if by == "" {
+		by = VelocityByUser
+	}
+	value := by.of(tx)
+	if value == "" || tx.Allowlisted {
+		return RuleResult{RuleName: r.Name(), Passed: true}, nil
+	}
+
+	key := fmt.Sprintf("%s:%s:%s", by, r.Window, value)
+	count, err := r.Store.Record(ctx, key, tx.ID, time.Now(), r.Window)
+	if err != nil {
+		// Payments are not held up by the store being down
+		log.Printf("Velocity check of %s skipped: %v", tx.ID, err)
+		return RuleResult{RuleName: r.Name(), Passed: true}, nil
+	}
+
+	if count > r.Threshold {
+		return RuleResult{
+			RuleName: r.Name(),
+			Passed:   false,
+			Message:  fmt.Sprintf("Velocity high: %d transactions by %s in %v", count, by, r.Window),
+		}, nil
+	}
+
+	return RuleResult{RuleName: r.Name(), Passed: true}, nil
+}
+
+// GeoMismatchRule flags payments made from a country other than the one
+// the card was issued in. Payments missing either country pass.
+type GeoMismatchRule struct {
+	// Allowed pairs the card countries with the other countries their
+	// cards may be used from without being flagged, e.g. US: [CA, MX]
+	Allowed map[string][]string
+}
+
+func (r *GeoMismatchRule) Name() string { return "GeoMismatchRule" }
+
+func (r *GeoMismatchRule) Check(ctx context.Context, tx Transaction) (RuleResult, error) {
+	ip, card := strings.ToUpper(tx.IPCountry), strings.ToUpper(tx.CardCountry)
+	if ip == "" || card == "" || ip == card {
+		return RuleResult{RuleName: r.Name(), Passed: true}, nil
+	}
+	for _, allowed := range r.Allowed[card] {
+		if strings.EqualFold(allowed, ip) {
+			return RuleResult{RuleName: r.Name(), Passed: true}, nil
+		}
+	}
+	return RuleResult{
+		RuleName: r.Name(),
+		Passed:   false,
+		Message:  fmt.Sprintf("Card from %s used from %s", card, ip),
+	}, nil
+}
+
+// NewDeviceRule flags a payer paying from a device it has not used in the
+// last TTL, once it has paid from another. A payer's first payment passes.
+type NewDeviceRule struct {
+	TTL     time.Duration
+	mu      sync.Mutex
+	devices map[string]map[string]time.Time // Payer -> device -> last seen
+}
+
+func NewNewDeviceRule(ttl time.Duration) *NewDeviceRule {
+	return &NewDeviceRule{
+		TTL:     ttl,
+		devices: make(map[string]map[string]time.Time),
+	}
+}
+
+func (r *NewDeviceRule) Name() string { return "NewDeviceRule" }
+
+func (r *NewDeviceRule) Check(ctx context.Context, tx Transaction) (RuleResult, error) {
+	if tx.DeviceID == "" {
+		return RuleResult{RuleName: r.Name(), Passed: true}, nil
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	now := time.Now()
-	r.history[tx.UserID] = append(r.history[tx.UserID], now)
-
-	var fresh []time.Time
-	for _, t := range r.history[tx.UserID] {
-		if now.Sub(t) < r.Window {
-			fresh = append(fresh, t)
+	payer := tx.payer()
+	seen := r.devices[payer]
+	for device, at := range seen {
+		if now.Sub(at) >= r.TTL {
+			delete(seen, device)
 		}
 	}
-	r.history[tx.UserID] = fresh
+	if seen == nil {
+		seen = make(map[string]time.Time)
+		r.devices[payer] = seen
+	}
 
-	if len(fresh) > r.Threshold {
+	_, known := seen[tx.DeviceID]
+	firstPayment := len(seen) == 0
+	seen[tx.DeviceID] = now
+
+	if !known && !firstPayment {
 		return RuleResult{
 			RuleName: r.Name(),
 			Passed:   false,
-			Message:  fmt.Sprintf("Velocity high: %d transactions in %v", len(fresh), r.Window),
+			Message:  fmt.Sprintf("New device %s for payer with %d known devices", tx.DeviceID, len(seen)-1),
 		}, nil
 	}
-
 	return RuleResult{RuleName: r.Name(), Passed: true}, nil
 }
@@ -0,0 +1,227 @@
+package fraud
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// MaxScore is the riskiest score; scores add up the weights of the rules
+	// flagging a payment, capped at MaxScore
+	MaxScore = 100
+	// DefaultRuleWeight is the weight of rules not given one
+	DefaultRuleWeight = 50
+	// DefaultZone keys the thresholds of zones without their own
+	DefaultZone = "default"
+)
+
+// Decision is what to do with a payment given its risk score
+type Decision string
+
+const (
+	DecisionApprove Decision = "approve"
+	DecisionReview  Decision = "review"
+	DecisionDecline Decision = "decline"
+)
+
+// Stages at which payments are scored
+const (
+	// StagePreAuthorization is a check before the payment is confirmed
+	StagePreAuthorization = "pre_authorization"
+	// StagePostPayment is the scoring of a payment that succeeded
+	StagePostPayment = "post_payment"
+)
+
+// Thresholds are the scores from which payments are sent for review and
+// declined
+type Thresholds struct {
+	Review  int `json:"review"`
+	Decline int `json:"decline"`
+}
+
+// DefaultThresholds apply when neither the zone nor DefaultZone has any
+var DefaultThresholds = Thresholds{Review: 50, Decline: 80}
+
+// Decide returns the decision for a score
+func (t Thresholds) Decide(score int) Decision {
+	switch {
+	case score >= t.Decline:
+		return DecisionDecline
+	case score >= t.Review:
+		return DecisionReview
+	default:
+		return DecisionApprove
+	}
+}
+
+func (t Thresholds) validate() error {
+	if t.Review <= 0 || t.Review > t.Decline || t.Decline > MaxScore {
+		return fmt.Errorf("thresholds must satisfy 0 < review <= decline <= %d, got %d and %d", MaxScore, t.Review, t.Decline)
+	}
+	return nil
+}
+
+func validateThresholds(thresholds map[string]Thresholds) error {
+	for zoneID, t := range thresholds {
+		if err := t.validate(); err != nil {
+			return fmt.Errorf("zone %s: %w", zoneID, err)
+		}
+	}
+	return nil
+}
+
+// ThresholdSource loads the decision thresholds, by zone ID
+type ThresholdSource interface {
+	LoadThresholds(ctx context.Context) (map[string]Thresholds, error)
+}
+
+// SetThresholds replaces the decision thresholds, keyed by zone ID
+func (e *Engine) SetThresholds(thresholds map[string]Thresholds) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.thresholds = thresholds
+}
+
+// Thresholds returns the decision thresholds of a zone
+func (e *Engine) Thresholds(zoneID string) Thresholds {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if t, ok := e.thresholds[zoneID]; ok {
+		return t
+	}
+	if t, ok := e.thresholds[DefaultZone]; ok {
+		return t
+	}
+	return DefaultThresholds
+}
+
+// Signal is one rule's part in a risk score
+type Signal struct {
+	Rule    string `json:"rule"`
+	Weight  int    `json:"weight"`
+	Flagged bool   `json:"flagged"`
+	Message string `json:"message,omitempty"`
+}
+
+// Assessment is the risk of a payment: its score out of MaxScore, the
+// signals it adds up and the decision the zone's thresholds make of it
+type Assessment struct {
+	TransactionID string     `json:"payment_id"`
+	ZoneID        string     `json:"zone_id"`
+	UserID        string     `json:"user_id"`
+	Score         int        `json:"score"`
+	Decision      Decision   `json:"decision"`
+	Thresholds    Thresholds `json:"thresholds"`
+	Signals       []Signal   `json:"signals"`
+	Stage         string     `json:"stage,omitempty"`
+	ScoredAt      time.Time  `json:"scored_at"`
+}
+
+// Flagged returns the signals of the rules that flagged the payment
+func (a *Assessment) Flagged() []Signal {
+	var flagged []Signal
+	for _, s := range a.Signals {
+		if s.Flagged {
+			flagged = append(flagged, s)
+		}
+	}
+	return flagged
+}
+
+// Score checks the transaction against every rule and weighs the ones
+// flagging it into a risk score, decided on with the thresholds of the
+// transaction's zone. Blocklisted transactions are declined outright.
+func (e *Engine) Score(ctx context.Context, tx Transaction) *Assessment {
+	e.mu.RLock()
+	rules, weights, lists := e.rules, e.weights, e.lists
+	e.mu.RUnlock()
+
+	a := &Assessment{
+		TransactionID: tx.ID,
+		ZoneID:        tx.ZoneID,
+		UserID:        tx.UserID,
+		Thresholds:    e.Thresholds(tx.ZoneID),
+		ScoredAt:      time.Now().UTC(),
+	}
+
+	// Blocked payments are declined without running the rules
+	if blocked := lists.Match(ListBlock, tx); blocked != nil {
+		a.Score = MaxScore
+		a.Decision = DecisionDecline
+		a.Signals = []Signal{{
+			Rule:    "Blocklist",
+			Weight:  MaxScore,
+			Flagged: true,
+			Message: fmt.Sprintf("%s %s is blocked", blocked.Type, blocked.Value),
+		}}
+		return a
+	}
+	tx.Allowlisted = lists.Match(ListAllow, tx) != nil
+
+	results, _ := check(ctx, rules, tx)
+	a.Signals = make([]Signal, len(results))
+	for i, res := range results {
+		a.Signals[i] = Signal{Rule: res.RuleName, Weight: weights[i], Flagged: !res.Passed, Message: res.Message}
+		if !res.Passed {
+			a.Score += weights[i]
+		}
+	}
+	a.Score = min(a.Score, MaxScore)
+	a.Decision = a.Thresholds.Decide(a.Score)
+	return a
+}
+
+func defaultWeights(n int) []int {
+	weights := make([]int, n)
+	for i := range weights {
+		weights[i] = DefaultRuleWeight
+	}
+	return weights
+}
+
+// ScoreStore persists assessments
+type ScoreStore interface {
+	SaveScore(ctx context.Context, a *Assessment) error
+}
+
+// DBScoreStore keeps assessments in the fraud_scores table, one per
+// payment; scoring a payment again replaces its assessment
+type DBScoreStore struct {
+	DB *sql.DB
+}
+
+func (s DBScoreStore) SaveScore(ctx context.Context, a *Assessment) error {
+	signals, err := json.Marshal(a.Signals)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO fraud_scores (payment_id, zone_id, user_id, score, decision, signals, stage, scored_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (payment_id) DO UPDATE SET
+			score = EXCLUDED.score,
+			decision = EXCLUDED.decision,
+			signals = EXCLUDED.signals,
+			stage = EXCLUDED.stage,
+			scored_at = EXCLUDED.scored_at
+	`, a.TransactionID, a.ZoneID, a.UserID, a.Score, string(a.Decision), signals, a.Stage, a.ScoredAt)
+	if err != nil {
+		return fmt.Errorf("failed to save score of %s: %w", a.TransactionID, err)
+	}
+	return nil
+}
+
+// ScoredEvent builds the fraud.scored event announcing an assessment
+func ScoredEvent(a *Assessment, mode string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"id":        "evt_fraud_scored_" + a.TransactionID,
+		"type":      "fraud.scored",
+		"timestamp": a.ScoredAt,
+		"zone_id":   a.ZoneID,
+		"mode":      mode,
+		"data":      a,
+	})
+}
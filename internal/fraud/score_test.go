@@ -0,0 +1,81 @@
+package fraud
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func weight(w int) *int { return &w }
+
+func TestScore_WeighsFlaggingRulesAgainstZoneThresholds(t *testing.T) {
+	engine := NewEngine()
+	err := engine.ApplyConfig([]RuleConfig{
+		{Name: "big", Type: "amount_threshold", Enabled: true, Weight: weight(40), Params: json.RawMessage(`{"limit": 500}`)},
+		{Name: "geo", Type: "geo_mismatch", Enabled: true, Weight: weight(30)},
+		{Name: "huge", Type: "amount_threshold", Enabled: true, Weight: weight(90), Params: json.RawMessage(`{"limit": 5000}`)},
+	})
+	if err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	engine.SetThresholds(map[string]Thresholds{
+		DefaultZone:  {Review: 50, Decline: 80},
+		"zone_risky": {Review: 30, Decline: 60},
+	})
+
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		tx       Transaction
+		score    int
+		decision Decision
+	}{
+		{"clean", Transaction{Amount: 100}, 0, DecisionApprove},
+		{"one signal", Transaction{Amount: 1000}, 40, DecisionApprove},
+		{"one signal in a strict zone", Transaction{ZoneID: "zone_risky", Amount: 1000}, 40, DecisionReview},
+		{"two signals", Transaction{Amount: 1000, CardCountry: "US", IPCountry: "BR"}, 70, DecisionReview},
+		{"capped", Transaction{Amount: 10000, CardCountry: "US", IPCountry: "BR"}, MaxScore, DecisionDecline},
+	}
+	for _, tt := range tests {
+		a := engine.Score(ctx, tt.tx)
+		if a.Score != tt.score || a.Decision != tt.decision {
+			t.Errorf("%s: got %d (%s), want %d (%s)", tt.name, a.Score, a.Decision, tt.score, tt.decision)
+		}
+		if len(a.Signals) != 3 {
+			t.Errorf("%s: expected a signal per rule, got %d", tt.name, len(a.Signals))
+		}
+	}
+}
+
+func TestScore_DefaultsWithoutConfig(t *testing.T) {
+	engine := NewEngine(&AmountRule{Limit: 500})
+	a := engine.Score(context.Background(), Transaction{Amount: 1000})
+	if a.Score != DefaultRuleWeight || a.Decision != DefaultThresholds.Decide(DefaultRuleWeight) {
+		t.Errorf("got %d (%s)", a.Score, a.Decision)
+	}
+	if flagged := a.Flagged(); len(flagged) != 1 || flagged[0].Rule != "AmountRule" {
+		t.Errorf("expected AmountRule flagged, got %+v", flagged)
+	}
+}
+
+func TestLoad_RejectsInvalidThresholds(t *testing.T) {
+	engine := NewEngine()
+	source := staticSource{thresholds: map[string]Thresholds{"zone_1": {Review: 90, Decline: 60}}}
+	if err := engine.Load(context.Background(), source); err == nil {
+		t.Fatal("expected review above decline to be rejected")
+	}
+	if got := engine.Thresholds("zone_1"); got != DefaultThresholds {
+		t.Errorf("expected the default thresholds to be kept, got %+v", got)
+	}
+}
+
+type staticSource struct {
+	rules      []RuleConfig
+	thresholds map[string]Thresholds
+}
+
+func (s staticSource) LoadRules(ctx context.Context) ([]RuleConfig, error) { return s.rules, nil }
+
+func (s staticSource) LoadThresholds(ctx context.Context) (map[string]Thresholds, error) {
+	return s.thresholds, nil
+}
@@ -0,0 +1,126 @@
+package fraud
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// VelocityKey is what velocity is counted by
+type VelocityKey string
+
+const (
+	VelocityByUser VelocityKey = "user"
+	VelocityByCard VelocityKey = "card"
+	VelocityByIP   VelocityKey = "ip"
+)
+
+// of returns the transaction's value of the key, empty when unknown
+func (k VelocityKey) of(tx Transaction) string {
+	switch k {
+	case VelocityByCard:
+		return tx.CardID
+	case VelocityByIP:
+		return tx.IPAddress
+	default:
+		return tx.payer()
+	}
+}
+
+func (k VelocityKey) valid() bool {
+	switch k {
+	case "", VelocityByUser, VelocityByCard, VelocityByIP:
+		return true
+	}
+	return false
+}
+
+// VelocityStore keeps the transactions of each key over a sliding window
+type VelocityStore interface {
+	// Record adds the transaction to the key's window ending at now and
+	// returns how many transactions the window holds. Recording the same
+	// transaction again does not count it twice.
+	Record(ctx context.Context, key, txID string, now time.Time, window time.Duration) (int, error)
+}
+
+// MemoryVelocityStore keeps windows in memory, for a single instance.
+// Windows with nothing left in them are dropped.
+type MemoryVelocityStore struct {
+	mu        sync.Mutex
+	windows   map[string]map[string]time.Time // Key -> transaction -> time
+	lastSweep time.Time
+}
+
+func NewMemoryVelocityStore() *MemoryVelocityStore {
+	return &MemoryVelocityStore{windows: make(map[string]map[string]time.Time)}
+}
+
+func (s *MemoryVelocityStore) Record(ctx context.Context, key, txID string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Every key's window is swept now and then, so keys that see no more
+	// transactions do not stay around
+	if now.Sub(s.lastSweep) > window {
+		for k, txs := range s.windows {
+			evict(txs, now, window)
+			if len(txs) == 0 {
+				delete(s.windows, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	txs := s.windows[key]
+	if txs == nil {
+		txs = make(map[string]time.Time)
+		s.windows[key] = txs
+	}
+	evict(txs, now, window)
+	if txID == "" {
+		txID = strconv.FormatInt(now.UnixNano(), 10)
+	}
+	txs[txID] = now
+	return len(txs), nil
+}
+
+func evict(txs map[string]time.Time, now time.Time, window time.Duration) {
+	for id, at := range txs {
+		if now.Sub(at) >= window {
+			delete(txs, id)
+		}
+	}
+}
+
+// RedisVelocityStore keeps windows in Redis sorted sets shared by every
+// instance. Each key expires a window after its last transaction.
+type RedisVelocityStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+func NewRedisVelocityStore(client *redis.Client) *RedisVelocityStore {
+	return &RedisVelocityStore{client: client, keyPrefix: "sapliy:fraud:velocity:"}
+}
+
+func (s *RedisVelocityStore) Record(ctx context.Context, key, txID string, now time.Time, window time.Duration) (int, error) {
+	redisKey := s.keyPrefix + key
+	windowStart := now.Add(-window).UnixMilli()
+	if txID == "" {
+		txID = strconv.FormatInt(now.UnixNano(), 10)
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, redisKey, "-inf", strconv.FormatInt(windowStart, 10))
+	pipe.ZAdd(ctx, redisKey, redis.Z{Score: float64(now.UnixMilli()), Member: txID})
+	countCmd := pipe.ZCard(ctx, redisKey)
+	pipe.PExpire(ctx, redisKey, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("velocity record failed: %w", err)
+	}
+	return int(countCmd.Val()), nil
+}
@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+var (
+	ErrInvalidAccountCode    = errors.New("account code must be digits, optionally grouped with '.' or '-', e.g. 1000 or 1100.10")
+	ErrAccountCodeInUse      = errors.New("account code is already in use")
+	ErrParentAccountNotFound = errors.New("parent account not found")
+	ErrParentAccountMismatch = errors.New("parent account must have the same type, zone and mode")
+	ErrParentAccountPosted   = errors.New("parent account already has entries; only accounts without entries can have sub-accounts")
+	ErrNotLeafAccount        = errors.New("entries can only post to leaf accounts")
+)
+
+var accountCodePattern = regexp.MustCompile(`^[0-9]+([.-][0-9]+)*$`)
+
+// AccountRequest opens an account, optionally under a parent in the chart
+// of accounts. Code, e.g. 1100, is unique within the zone and mode.
+type AccountRequest struct {
+	Name     string      `json:"name"`
+	Type     AccountType `json:"type"`
+	Currency string      `json:"currency"`
+	UserID   *string     `json:"user_id"`
+	Code     string      `json:"code"`
+	ParentID string      `json:"parent_id"`
+}
+
+// AccountNode is an account in the chart of accounts. RollupBalances sums
+// the posted balances of the account and every account below it.
+type AccountNode struct {
+	Account
+	RollupBalances map[string]int64 `json:"rollup_balances"`
+	Children       []*AccountNode   `json:"children"`
+}
+
+// OpenAccount creates an account from the request. A parent must be of the
+// same type, zone and mode, and may not have entries of its own: once it
+// has sub-accounts only they can be posted to.
+func (s *LedgerService) OpenAccount(ctx context.Context, req AccountRequest, zoneID, mode string) (*Account, error) {
+	acc := &Account{
+		Name:     req.Name,
+		Type:     req.Type,
+		Currency: req.Currency,
+		UserID:   req.UserID,
+		Code:     req.Code,
+		ZoneID:   zoneID,
+		Mode:     mode,
+	}
+
+	if req.Code != "" {
+		if len(req.Code) > 20 || !accountCodePattern.MatchString(req.Code) {
+			return nil, ErrInvalidAccountCode
+		}
+		existing, err := s.repo.GetAccountByCode(ctx, zoneID, mode, req.Code)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, ErrAccountCodeInUse
+		}
+	}
+
+	if req.ParentID != "" {
+		parent, err := s.repo.GetAccount(ctx, req.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			return nil, ErrParentAccountNotFound
+		}
+		if parent.Type != acc.Type || parent.ZoneID != zoneID || parent.Mode != mode {
+			return nil, ErrParentAccountMismatch
+		}
+		posted, err := s.repo.AccountHasEntries(ctx, parent.ID)
+		if err != nil {
+			return nil, err
+		}
+		if posted {
+			return nil, ErrParentAccountPosted
+		}
+		acc.ParentID = &parent.ID
+	}
+
+	if err := s.repo.CreateAccount(ctx, acc); err != nil {
+		return nil, err
+	}
+	acc.Balance = 0
+	acc.Balances = map[string]int64{acc.Currency: 0}
+	acc.PostedBalance = 0
+	acc.AvailableBalance = 0
+	return acc, nil
+}
+
+// GetAccountTree returns the zone's chart of accounts as trees ordered by
+// code, with each account's balances rolled up into its parents. rootID
+// limits it to the tree below that account.
+func (s *LedgerService) GetAccountTree(ctx context.Context, zoneID, mode, rootID string) ([]*AccountNode, error) {
+	accounts, err := s.repo.ListChartAccounts(ctx, zoneID, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[string]*AccountNode, len(accounts))
+	for _, acc := range accounts {
+		nodes[acc.ID] = &AccountNode{Account: acc, Children: []*AccountNode{}}
+	}
+
+	var roots []*AccountNode
+	for _, acc := range accounts {
+		node := nodes[acc.ID]
+		if acc.ParentID != nil {
+			if parent, ok := nodes[*acc.ParentID]; ok {
+				parent.HasChildren = true
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	if rootID != "" {
+		root, ok := nodes[rootID]
+		if !ok {
+			return nil, ErrAccountNotFound
+		}
+		roots = []*AccountNode{root}
+	}
+
+	sortAccountNodes(roots)
+	for _, root := range roots {
+		rollup(root)
+	}
+	return roots, nil
+}
+
+// checkLeafAccount refuses entries to an account with sub-accounts
+func checkLeafAccount(acc *Account) error {
+	if acc.HasChildren {
+		return fmt.Errorf("%w: account %s has sub-accounts", ErrNotLeafAccount, acc.ID)
+	}
+	return nil
+}
+
+func rollup(node *AccountNode) map[string]int64 {
+	node.RollupBalances = make(map[string]int64, len(node.Balances))
+	for code, balance := range node.Balances {
+		node.RollupBalances[code] += balance
+	}
+	sortAccountNodes(node.Children)
+	for _, child := range node.Children {
+		for code, balance := range rollup(child) {
+			node.RollupBalances[code] += balance
+		}
+	}
+	return node.RollupBalances
+}
+
+// sortAccountNodes orders accounts by code, with those without one last
+// and by name
+func sortAccountNodes(nodes []*AccountNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if (a.Code == "") != (b.Code == "") {
+			return a.Code != ""
+		}
+		if a.Code != b.Code {
+			return a.Code < b.Code
+		}
+		return a.Name < b.Name
+	})
+}
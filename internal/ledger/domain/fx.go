@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/currency"
+)
+
+// Validate checks a mixed-currency transaction's entries against the
+// conversion: every entry is in one of the pair's currencies, each currency
+// balances, and the conversion legs exchange amounts at the rate, give or
+// take a minor unit of rounding.
+func (fx *FXConversion) Validate(entries []EntryRequest) error {
+	base, ok := currency.Lookup(fx.BaseCurrency)
+	if !ok {
+		return fmt.Errorf("unsupported fx base currency: %s", fx.BaseCurrency)
+	}
+	quote, ok := currency.Lookup(fx.QuoteCurrency)
+	if !ok {
+		return fmt.Errorf("unsupported fx quote currency: %s", fx.QuoteCurrency)
+	}
+	if base.Code == quote.Code {
+		return errors.New("fx base and quote currencies must differ")
+	}
+	if !(fx.Rate > 0) || math.IsInf(fx.Rate, 0) {
+		return errors.New("fx rate must be positive")
+	}
+	fx.BaseCurrency, fx.QuoteCurrency = base.Code, quote.Code
+
+	sums := map[string]int64{}
+	converted := map[string]int64{}
+	for _, e := range entries {
+		if e.Currency != base.Code && e.Currency != quote.Code {
+			return fmt.Errorf("entry for account %s is in %s, outside the fx pair %s/%s", e.AccountID, e.Currency, base.Code, quote.Code)
+		}
+		sums[e.Currency] += e.Amount
+		if e.Conversion {
+			converted[e.Currency] += e.Amount
+		}
+	}
+	for _, code := range []string{base.Code, quote.Code} {
+		if sums[code] != 0 {
+			return fmt.Errorf("transaction is not balanced in %s (sum != 0)", code)
+		}
+	}
+
+	baseLeg, quoteLeg := converted[base.Code], converted[quote.Code]
+	if baseLeg == 0 || quoteLeg == 0 {
+		return errors.New("fx conversion requires conversion entries in both currencies")
+	}
+	if (baseLeg > 0) == (quoteLeg > 0) {
+		return errors.New("fx conversion entries must move the two currencies in opposite directions")
+	}
+	baseLeg, quoteLeg = absAmount(baseLeg), absAmount(quoteLeg)
+	expected := currency.ConvertMinor(baseLeg, base, quote, fx.Rate)
+	if absAmount(expected-quoteLeg) > 1 {
+		return fmt.Errorf("fx conversion entries do not match the rate: %d %s at %g is %d %s, got %d", baseLeg, base.Code, fx.Rate, expected, quote.Code, quoteLeg)
+	}
+	return nil
+}
+
+func absAmount(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
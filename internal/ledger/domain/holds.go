@@ -0,0 +1,275 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+type HoldStatus string
+
+const (
+	HoldActive   HoldStatus = "active"
+	HoldReleased HoldStatus = "released"
+	HoldCaptured HoldStatus = "captured"
+	HoldExpired  HoldStatus = "expired"
+)
+
+const (
+	DefaultHoldTTL = 7 * 24 * time.Hour
+	MaxHoldTTL     = 30 * 24 * time.Hour
+)
+
+var (
+	ErrHoldNotFound      = errors.New("hold not found")
+	ErrHoldNotActive     = errors.New("hold is no longer active")
+	ErrInvalidHoldAmount = errors.New("hold amount must not be zero")
+	ErrInvalidHoldExpiry = errors.New("hold must expire in the future and within 30 days")
+	ErrInvalidCapture    = errors.New("capture amount must be positive and at most the amount held")
+	ErrHoldReference     = errors.New("reference_id is required")
+	ErrCounterAccount    = errors.New("counter_account_id is required")
+	ErrReferenceInUse    = errors.New("reference_id is already used by another transaction")
+)
+
+// Hold reserves an amount of an account's balance until it is captured into
+// a transaction, released, or expires. Amount is signed like an entry's: it
+// is what capturing the hold posts to the account. Active holds count
+// towards the account's available balance but not its posted balance.
+type Hold struct {
+	ID             string     `json:"id"`
+	AccountID      string     `json:"account_id"`
+	Amount         int64      `json:"amount"`
+	Currency       string     `json:"currency"`
+	ReferenceID    string     `json:"reference_id"`
+	Description    string     `json:"description"`
+	Status         HoldStatus `json:"status"`
+	CapturedAmount int64      `json:"captured_amount,omitempty"`
+	TransactionID  string     `json:"transaction_id,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// HoldRequest places a hold. ReferenceID makes it idempotent; ExpiresAt
+// defaults to DefaultHoldTTL from now.
+type HoldRequest struct {
+	Amount      int64     `json:"amount"`
+	ReferenceID string    `json:"reference_id"`
+	Description string    `json:"description"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// CaptureRequest posts a hold. The held account is balanced against
+// CounterAccountID. Amount is the magnitude to capture, by default all of
+// it; whatever is not captured is released.
+type CaptureRequest struct {
+	CounterAccountID string `json:"counter_account_id"`
+	Amount           int64  `json:"amount"`
+	ReferenceID      string `json:"reference_id"`
+	Description      string `json:"description"`
+}
+
+// Active reports whether the hold still reserves its amount at the given time
+func (h *Hold) Active(at time.Time) bool {
+	return h.Status == HoldActive && at.Before(h.ExpiresAt)
+}
+
+// withHolds sets the account's posted balance and its balance available
+// once active holds are taken into account
+func (s *LedgerService) withHolds(ctx context.Context, acc *Account) (*Account, error) {
+	held, err := s.repo.GetHeldAmount(ctx, acc.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	acc.PostedBalance = acc.Balance
+	acc.AvailableBalance = acc.Balance + held
+	return acc, nil
+}
+
+// PlaceHold reserves an amount of the account's balance in its currency.
+// Placing a hold again with the same reference returns the first one.
+func (s *LedgerService) PlaceHold(ctx context.Context, accountID string, req HoldRequest) (*Hold, error) {
+	if req.Amount == 0 {
+		return nil, ErrInvalidHoldAmount
+	}
+	if req.ReferenceID == "" {
+		return nil, ErrHoldReference
+	}
+	now := time.Now().UTC()
+	if req.ExpiresAt.IsZero() {
+		req.ExpiresAt = now.Add(DefaultHoldTTL)
+	}
+	if !req.ExpiresAt.After(now) || req.ExpiresAt.After(now.Add(MaxHoldTTL)) {
+		return nil, ErrInvalidHoldExpiry
+	}
+
+	if existing, err := s.repo.GetHoldByReference(ctx, req.ReferenceID); err != nil || existing != nil {
+		return existing, err
+	}
+
+	acc, err := s.repo.GetAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if acc == nil {
+		return nil, ErrAccountNotFound
+	}
+	if err := checkLeafAccount(acc); err != nil {
+		return nil, err
+	}
+
+	hold := &Hold{
+		AccountID:   acc.ID,
+		Amount:      req.Amount,
+		Currency:    acc.Currency,
+		ReferenceID: req.ReferenceID,
+		Description: req.Description,
+		Status:      HoldActive,
+		ExpiresAt:   req.ExpiresAt.UTC(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.CreateHold(ctx, hold); err != nil {
+		return nil, err
+	}
+	s.watchers.notify(hold.AccountID)
+	return hold, nil
+}
+
+func (s *LedgerService) GetHold(ctx context.Context, id string) (*Hold, error) {
+	hold, err := s.repo.GetHold(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hold == nil {
+		return nil, ErrHoldNotFound
+	}
+	return hold, nil
+}
+
+// ListHolds returns the account's active holds
+func (s *LedgerService) ListHolds(ctx context.Context, accountID string) ([]Hold, error) {
+	return s.repo.ListActiveHolds(ctx, accountID, time.Now())
+}
+
+// ReleaseHold gives the held amount back to the account's available balance
+func (s *LedgerService) ReleaseHold(ctx context.Context, id string) (*Hold, error) {
+	hold, err := s.GetHold(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status == HoldReleased {
+		return hold, nil
+	}
+	if !hold.Active(time.Now()) {
+		return nil, ErrHoldNotActive
+	}
+
+	released, err := s.repo.ReleaseHold(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !released {
+		return nil, ErrHoldNotActive
+	}
+	s.watchers.notify(hold.AccountID)
+	return s.GetHold(ctx, id)
+}
+
+// CaptureHold posts the held amount, or part of it, as a transaction
+// between the held account and the counter account. The hold is marked
+// captured in the same database transaction, so a hold is captured once.
+func (s *LedgerService) CaptureHold(ctx context.Context, id string, req CaptureRequest, zoneID, mode string) (*Hold, error) {
+	hold, err := s.GetHold(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status == HoldCaptured {
+		return hold, nil
+	}
+	if !hold.Active(time.Now()) {
+		return nil, ErrHoldNotActive
+	}
+	if req.CounterAccountID == "" {
+		return nil, ErrCounterAccount
+	}
+
+	held := absAmount(hold.Amount)
+	if req.Amount == 0 {
+		req.Amount = held
+	}
+	if req.Amount < 0 || req.Amount > held {
+		return nil, ErrInvalidCapture
+	}
+	amount := req.Amount
+	if hold.Amount < 0 {
+		amount = -amount
+	}
+	if req.ReferenceID == "" {
+		req.ReferenceID = "hold_capture:" + hold.ID
+	}
+	if req.Description == "" {
+		req.Description = hold.Description
+	}
+
+	_, err = s.recordTransaction(ctx, TransactionRequest{
+		ReferenceID: req.ReferenceID,
+		Description: req.Description,
+		Entries: []EntryRequest{
+			{AccountID: hold.AccountID, Amount: amount, Currency: hold.Currency},
+			{AccountID: req.CounterAccountID, Amount: -amount, Currency: hold.Currency},
+		},
+	}, zoneID, mode, func(txCtx TransactionContext, transactionID string) error {
+		captured, err := txCtx.CaptureHold(ctx, hold.ID, transactionID, req.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to capture hold: %w", err)
+		}
+		if !captured {
+			return ErrHoldNotActive
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A transaction already recorded under the reference leaves the hold
+	// as it was
+	hold, err = s.GetHold(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != HoldCaptured {
+		return nil, ErrReferenceInUse
+	}
+	return hold, nil
+}
+
+// ExpireHolds marks the holds past their expiry as expired. Expired holds
+// stop counting towards available balances when they expire regardless;
+// this keeps their status truthful.
+func (s *LedgerService) ExpireHolds(ctx context.Context) (int, error) {
+	return s.repo.ExpireHolds(ctx, time.Now())
+}
+
+// StartHoldExpiry expires holds every interval until the context is
+// cancelled
+func (s *LedgerService) StartHoldExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if count, err := s.ExpireHolds(ctx); err != nil {
+				log.Printf("Failed to expire holds: %v", err)
+			} else if count > 0 {
+				log.Printf("Expired %d holds", count)
+			}
+		}
+	}
+}
@@ -2,16 +2,36 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 type MockRepository struct {
-	CreateAccountFunc        func(ctx context.Context, acc *Account) error
-	GetAccountFunc           func(ctx context.Context, id string) (*Account, error)
-	BeginTxFunc              func(ctx context.Context) (TransactionContext, error)
-	GetUnprocessedEventsFunc func(ctx context.Context, limit int) ([]OutboxEvent, error)
-	MarkEventProcessedFunc   func(ctx context.Context, id string) error
-	ListTransactionsFunc     func(ctx context.Context, zoneID string, limit int) ([]TransactionWithEntries, error)
-	GetTransactionFunc       func(ctx context.Context, id string) (*TransactionWithEntries, error)
+	CreateAccountFunc              func(ctx context.Context, acc *Account) error
+	GetAccountFunc                 func(ctx context.Context, id string) (*Account, error)
+	BeginTxFunc                    func(ctx context.Context) (TransactionContext, error)
+	GetUnprocessedEventsFunc       func(ctx context.Context, limit int) ([]OutboxEvent, error)
+	MarkEventProcessedFunc         func(ctx context.Context, id string) error
+	ListTransactionsFunc           func(ctx context.Context, zoneID string, limit int) ([]TransactionWithEntries, error)
+	GetTransactionFunc             func(ctx context.Context, id string) (*TransactionWithEntries, error)
+	ListAccountEntriesFunc         func(ctx context.Context, filter EntryFilter) ([]StatementEntry, error)
+	GetBalanceAtFunc               func(ctx context.Context, accountID, currency string, pos EntryPosition) (int64, error)
+	ListAccountBalancesFunc        func(ctx context.Context, before time.Time, zoneID string) ([]AccountBalance, error)
+	ListUnbalancedTransactionsFunc func(ctx context.Context, limit int) ([]UnbalancedTransaction, error)
+	CreateBalanceSnapshotsFunc     func(ctx context.Context, asOf time.Time) (int, error)
+	CreateHoldFunc                 func(ctx context.Context, hold *Hold) error
+	GetHoldFunc                    func(ctx context.Context, id string) (*Hold, error)
+	GetHoldByReferenceFunc         func(ctx context.Context, referenceID string) (*Hold, error)
+	ListActiveHoldsFunc            func(ctx context.Context, accountID string, at time.Time) ([]Hold, error)
+	GetHeldAmountFunc              func(ctx context.Context, accountID string, at time.Time) (int64, error)
+	ReleaseHoldFunc                func(ctx context.Context, id string) (bool, error)
+	ExpireHoldsFunc                func(ctx context.Context, at time.Time) (int, error)
+	ClosePeriodFunc                func(ctx context.Context, period *Period) (bool, error)
+	GetPeriodFunc                  func(ctx context.Context, period string) (*Period, error)
+	ListPeriodsFunc                func(ctx context.Context) ([]Period, error)
+	GetAccountByCodeFunc           func(ctx context.Context, zoneID, mode, code string) (*Account, error)
+	AccountHasEntriesFunc          func(ctx context.Context, id string) (bool, error)
+	ListChartAccountsFunc          func(ctx context.Context, zoneID, mode string) ([]Account, error)
+	DeleteProcessedEventsFunc      func(ctx context.Context, before time.Time) (int, error)
 }
 
 func (m *MockRepository) CreateAccount(ctx context.Context, acc *Account) error {
@@ -42,13 +62,94 @@ func (m *MockRepository) GetTransaction(ctx context.Context, id string) (*Transa
 	return m.GetTransactionFunc(ctx, id)
 }
 
+func (m *MockRepository) ListAccountEntries(ctx context.Context, filter EntryFilter) ([]StatementEntry, error) {
+	return m.ListAccountEntriesFunc(ctx, filter)
+}
+
+func (m *MockRepository) GetBalanceAt(ctx context.Context, accountID, currency string, pos EntryPosition) (int64, error) {
+	return m.GetBalanceAtFunc(ctx, accountID, currency, pos)
+}
+
+func (m *MockRepository) ListAccountBalances(ctx context.Context, before time.Time, zoneID string) ([]AccountBalance, error) {
+	return m.ListAccountBalancesFunc(ctx, before, zoneID)
+}
+
+func (m *MockRepository) ListUnbalancedTransactions(ctx context.Context, limit int) ([]UnbalancedTransaction, error) {
+	return m.ListUnbalancedTransactionsFunc(ctx, limit)
+}
+
+func (m *MockRepository) CreateBalanceSnapshots(ctx context.Context, asOf time.Time) (int, error) {
+	return m.CreateBalanceSnapshotsFunc(ctx, asOf)
+}
+
+func (m *MockRepository) CreateHold(ctx context.Context, hold *Hold) error {
+	return m.CreateHoldFunc(ctx, hold)
+}
+
+func (m *MockRepository) GetHold(ctx context.Context, id string) (*Hold, error) {
+	return m.GetHoldFunc(ctx, id)
+}
+
+func (m *MockRepository) GetHoldByReference(ctx context.Context, referenceID string) (*Hold, error) {
+	return m.GetHoldByReferenceFunc(ctx, referenceID)
+}
+
+func (m *MockRepository) ListActiveHolds(ctx context.Context, accountID string, at time.Time) ([]Hold, error) {
+	return m.ListActiveHoldsFunc(ctx, accountID, at)
+}
+
+func (m *MockRepository) GetHeldAmount(ctx context.Context, accountID string, at time.Time) (int64, error) {
+	return m.GetHeldAmountFunc(ctx, accountID, at)
+}
+
+func (m *MockRepository) ReleaseHold(ctx context.Context, id string) (bool, error) {
+	return m.ReleaseHoldFunc(ctx, id)
+}
+
+func (m *MockRepository) ExpireHolds(ctx context.Context, at time.Time) (int, error) {
+	return m.ExpireHoldsFunc(ctx, at)
+}
+
+func (m *MockRepository) ClosePeriod(ctx context.Context, period *Period) (bool, error) {
+	return m.ClosePeriodFunc(ctx, period)
+}
+
+func (m *MockRepository) GetPeriod(ctx context.Context, period string) (*Period, error) {
+	return m.GetPeriodFunc(ctx, period)
+}
+
+func (m *MockRepository) ListPeriods(ctx context.Context) ([]Period, error) {
+	return m.ListPeriodsFunc(ctx)
+}
+
+func (m *MockRepository) GetAccountByCode(ctx context.Context, zoneID, mode, code string) (*Account, error) {
+	return m.GetAccountByCodeFunc(ctx, zoneID, mode, code)
+}
+
+func (m *MockRepository) AccountHasEntries(ctx context.Context, id string) (bool, error) {
+	return m.AccountHasEntriesFunc(ctx, id)
+}
+
+func (m *MockRepository) ListChartAccounts(ctx context.Context, zoneID, mode string) ([]Account, error) {
+	return m.ListChartAccountsFunc(ctx, zoneID, mode)
+}
+
+func (m *MockRepository) DeleteProcessedEvents(ctx context.Context, before time.Time) (int, error) {
+	return m.DeleteProcessedEventsFunc(ctx, before)
+}
+
 type MockTransactionContext struct {
-	CreateTransactionFunc func(ctx context.Context, tx *Transaction) (string, error)
-	CreateEntryFunc       func(ctx context.Context, entry *Entry) error
-	CheckIdempotencyFunc  func(ctx context.Context, referenceID string) (string, error)
-	CreateOutboxEventFunc func(ctx context.Context, eventType string, payload []byte) error
-	CommitFunc            func() error
-	RollbackFunc          func() error
+	CreateTransactionFunc    func(ctx context.Context, tx *Transaction) (string, error)
+	CreateEntryFunc          func(ctx context.Context, entry *Entry) error
+	CheckIdempotencyFunc     func(ctx context.Context, referenceID string) (string, error)
+	CreateOutboxEventFunc    func(ctx context.Context, eventType string, payload []byte) error
+	CaptureHoldFunc          func(ctx context.Context, holdID, transactionID string, amount int64) (bool, error)
+	CreateReversalFunc       func(ctx context.Context, reversal *Reversal) (bool, error)
+	GetClosedPeriodFunc      func(ctx context.Context, period string) (*Period, error)
+	GetProcessedEventFunc    func(ctx context.Context, eventID string) (string, error)
+	CreateProcessedEventFunc func(ctx context.Context, eventID, transactionID string) (bool, error)
+	CommitFunc               func() error
+	RollbackFunc             func() error
 }
 
 func (m *MockTransactionContext) CreateTransaction(ctx context.Context, tx *Transaction) (string, error) {
@@ -67,6 +168,26 @@ func (m *MockTransactionContext) CreateOutboxEvent(ctx context.Context, eventTyp
 	return m.CreateOutboxEventFunc(ctx, eventType, payload)
 }
 
+func (m *MockTransactionContext) CaptureHold(ctx context.Context, holdID, transactionID string, amount int64) (bool, error) {
+	return m.CaptureHoldFunc(ctx, holdID, transactionID, amount)
+}
+
+func (m *MockTransactionContext) CreateReversal(ctx context.Context, reversal *Reversal) (bool, error) {
+	return m.CreateReversalFunc(ctx, reversal)
+}
+
+func (m *MockTransactionContext) GetClosedPeriod(ctx context.Context, period string) (*Period, error) {
+	return m.GetClosedPeriodFunc(ctx, period)
+}
+
+func (m *MockTransactionContext) GetProcessedEvent(ctx context.Context, eventID string) (string, error) {
+	return m.GetProcessedEventFunc(ctx, eventID)
+}
+
+func (m *MockTransactionContext) CreateProcessedEvent(ctx context.Context, eventID, transactionID string) (bool, error) {
+	return m.CreateProcessedEventFunc(ctx, eventID, transactionID)
+}
+
 func (m *MockTransactionContext) Commit() error {
 	return m.CommitFunc()
 }
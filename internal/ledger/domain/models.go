@@ -20,16 +20,25 @@ const (
 	Credit TransactionType = "credit"
 )
 
+// Account holds balances in any number of currencies. Currency is the one
+// its entries default to, and Balance the balance in it. PostedBalance
+// repeats Balance, next to AvailableBalance which also counts active holds.
 type Account struct {
-	ID        string      `json:"id"`
-	ZoneID    string      `json:"zone_id"`
-	Mode      string      `json:"mode"`
-	Name      string      `json:"name"`
-	Type      AccountType `json:"type"`
-	Currency  string      `json:"currency"`
-	Balance   int64       `json:"balance"`
-	UserID    *string     `json:"user_id,omitempty"`
-	CreatedAt time.Time   `json:"created_at"`
+	ID               string           `json:"id"`
+	ZoneID           string           `json:"zone_id"`
+	Mode             string           `json:"mode"`
+	Name             string           `json:"name"`
+	Type             AccountType      `json:"type"`
+	Currency         string           `json:"currency"`
+	Balance          int64            `json:"balance"`
+	PostedBalance    int64            `json:"posted_balance"`
+	AvailableBalance int64            `json:"available_balance"`
+	Balances         map[string]int64 `json:"balances"`
+	UserID           *string          `json:"user_id,omitempty"`
+	Code             string           `json:"code,omitempty"`
+	ParentID         *string          `json:"parent_id,omitempty"`
+	HasChildren      bool             `json:"has_children"`
+	CreatedAt        time.Time        `json:"created_at"`
 }
 
 type Transaction struct {
@@ -38,6 +47,9 @@ type Transaction struct {
 	Mode        string    `json:"mode"`
 	ReferenceID string    `json:"reference_id"`
 	Description string    `json:"description"`
+	ReversedBy  string    `json:"reversed_by,omitempty"` // The transaction reversing this one
+	ReversalOf  string    `json:"reversal_of,omitempty"` // The transaction this one reverses
+	EffectiveAt time.Time `json:"effective_at"`          // The accounting date, CreatedAt unless backdated
 	CreatedAt   time.Time `json:"created_at"`
 }
 
@@ -51,6 +63,7 @@ type Entry struct {
 	TransactionID string          `json:"transaction_id"`
 	AccountID     string          `json:"account_id"`
 	Amount        int64           `json:"amount"`
+	Currency      string          `json:"currency"`
 	Direction     TransactionType `json:"direction"`
 	CreatedAt     time.Time       `json:"created_at"`
 }
@@ -59,12 +72,33 @@ type TransactionRequest struct {
 	ReferenceID string         `json:"reference_id"`
 	Description string         `json:"description"`
 	Entries     []EntryRequest `json:"entries"`
+	FX          *FXConversion  `json:"fx,omitempty"` // Required when entries are in two currencies
+	EffectiveAt time.Time      `json:"effective_at"` // Optional accounting date in the past, e.g. for adjustments
+
+	// reversal is set on the compensating transactions of reversals, whose
+	// entries balance per currency without an FX conversion
+	reversal bool
+	// eventID is set on transactions recording a consumed event, which are
+	// recorded once per event
+	eventID string
 }
 
 type EntryRequest struct {
-	AccountID string `json:"account_id"`
-	Amount    int64  `json:"amount"`    // Signed amount
-	Direction string `json:"direction"` // Optional, helpful for validation
+	AccountID  string `json:"account_id"`
+	Amount     int64  `json:"amount"`               // Signed amount
+	Currency   string `json:"currency,omitempty"`   // Defaults to the account's currency
+	Direction  string `json:"direction"`            // Optional, helpful for validation
+	Conversion bool   `json:"conversion,omitempty"` // A leg of the FX conversion
+}
+
+// FXConversion is the exchange a mixed-currency transaction makes, at Rate
+// units of QuoteCurrency per unit of BaseCurrency. The entries marked as
+// conversion legs move the exchanged amounts, e.g. into and out of an FX
+// account, so that each currency still balances on its own.
+type FXConversion struct {
+	BaseCurrency  string  `json:"base_currency"`
+	QuoteCurrency string  `json:"quote_currency"`
+	Rate          float64 `json:"rate"`
 }
 
 type OutboxEvent struct {
@@ -0,0 +1,127 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PeriodLayout formats accounting periods, which are calendar months in UTC
+const PeriodLayout = "2006-01"
+
+var (
+	ErrInvalidPeriod       = errors.New("period must be a month formatted as YYYY-MM")
+	ErrPeriodNotEnded      = errors.New("period can only be closed once it has ended")
+	ErrPeriodAlreadyClosed = errors.New("period is already closed")
+	ErrPeriodNotFound      = errors.New("period is not closed")
+	ErrPeriodClosedBy      = errors.New("closed_by is required")
+	ErrPeriodClosed        = errors.New("period is closed")
+	ErrFutureEffectiveDate = errors.New("effective_at must not be in the future")
+)
+
+// Period is a closed accounting period. Transactions dated within it are
+// refused, except adjusting ones posting to its adjustment account.
+type Period struct {
+	Period              string    `json:"period"`
+	AdjustmentAccountID string    `json:"adjustment_account_id,omitempty"`
+	ClosedBy            string    `json:"closed_by"`
+	ClosedAt            time.Time `json:"closed_at"`
+}
+
+type ClosePeriodRequest struct {
+	AdjustmentAccountID string `json:"adjustment_account_id"`
+	ClosedBy            string `json:"closed_by"`
+}
+
+// PeriodOf returns the accounting period a time falls in
+func PeriodOf(t time.Time) string {
+	return t.UTC().Format(PeriodLayout)
+}
+
+// ClosePeriod closes a period that has ended. Closing is final: entries
+// dated within the period can only be adjusted through the adjustment
+// account, if one is given.
+func (s *LedgerService) ClosePeriod(ctx context.Context, period string, req ClosePeriodRequest) (*Period, error) {
+	start, err := time.Parse(PeriodLayout, period)
+	if err != nil {
+		return nil, ErrInvalidPeriod
+	}
+	if start.AddDate(0, 1, 0).After(time.Now()) {
+		return nil, ErrPeriodNotEnded
+	}
+	if req.ClosedBy == "" {
+		return nil, ErrPeriodClosedBy
+	}
+	if req.AdjustmentAccountID != "" {
+		acc, err := s.repo.GetAccount(ctx, req.AdjustmentAccountID)
+		if err != nil {
+			return nil, err
+		}
+		if acc == nil {
+			return nil, ErrAccountNotFound
+		}
+	}
+
+	p := &Period{
+		Period:              period,
+		AdjustmentAccountID: req.AdjustmentAccountID,
+		ClosedBy:            req.ClosedBy,
+	}
+	closed, err := s.repo.ClosePeriod(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if !closed {
+		return nil, ErrPeriodAlreadyClosed
+	}
+	return p, nil
+}
+
+func (s *LedgerService) GetPeriod(ctx context.Context, period string) (*Period, error) {
+	if _, err := time.Parse(PeriodLayout, period); err != nil {
+		return nil, ErrInvalidPeriod
+	}
+	p, err := s.repo.GetPeriod(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrPeriodNotFound
+	}
+	return p, nil
+}
+
+// ListPeriods returns the closed periods, most recent first
+func (s *LedgerService) ListPeriods(ctx context.Context) ([]Period, error) {
+	return s.repo.ListPeriods(ctx)
+}
+
+// checkPeriod refuses entries dated within a closed period unless one of
+// them posts to the period's adjustment account. It runs in the database
+// transaction recording them, which keeps the period from being closed
+// until that commits.
+func checkPeriod(ctx context.Context, txCtx TransactionContext, effectiveAt time.Time, entries []EntryRequest) error {
+	period := PeriodOf(effectiveAt)
+	if period == PeriodOf(time.Now()) {
+		// The current period cannot be closed yet
+		return nil
+	}
+
+	p, err := txCtx.GetClosedPeriod(ctx, period)
+	if err != nil {
+		return fmt.Errorf("failed to check period %s: %w", period, err)
+	}
+	if p == nil {
+		return nil
+	}
+	if p.AdjustmentAccountID != "" {
+		for _, e := range entries {
+			if e.AccountID == p.AdjustmentAccountID {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s; adjusting entries must post to account %s", ErrPeriodClosed, period, p.AdjustmentAccountID)
+	}
+	return fmt.Errorf("%w: %s", ErrPeriodClosed, period)
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// DefaultProcessedEventTTL is how long a consumed event's ID is kept to
+// recognise it being delivered again. Older redeliveries are still caught
+// by their transaction's reference ID.
+const DefaultProcessedEventTTL = 7 * 24 * time.Hour
+
+var errEventProcessed = errors.New("event already processed")
+
+// RecordEventTransaction records the transaction for a consumed event at
+// most once per event ID. The event is marked processed in the same
+// database transaction as the entries, so a replay, redelivery or consumer
+// rebalance never posts it twice, even under a new reference ID.
+func (s *LedgerService) RecordEventTransaction(ctx context.Context, eventID string, req TransactionRequest, zoneID, mode string) error {
+	req.eventID = eventID
+	_, err := s.recordTransaction(ctx, req, zoneID, mode, nil)
+	if errors.Is(err, errEventProcessed) {
+		// Processed concurrently by another consumer
+		return nil
+	}
+	return err
+}
+
+// StartProcessedEventCleanup forgets events processed longer than ttl ago,
+// every interval until the context is cancelled
+func (s *LedgerService) StartProcessedEventCleanup(ctx context.Context, interval, ttl time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if count, err := s.repo.DeleteProcessedEvents(ctx, time.Now().Add(-ttl)); err != nil {
+				log.Printf("Failed to delete processed events: %v", err)
+			} else if count > 0 {
+				log.Printf("Deleted %d processed events", count)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+const DefaultBalanceCheckLimit = 100
+
+// AccountBalance is an account's debits and credits in a currency at a
+// point in time.
+// Debits are the entries' positive amounts and credits their negative ones,
+// so the balance is debits less credits.
+type AccountBalance struct {
+	AccountID string      `json:"account_id"`
+	Name      string      `json:"name"`
+	Type      AccountType `json:"type"`
+	Currency  string      `json:"currency"`
+	Debits    int64       `json:"debits"`
+	Credits   int64       `json:"credits"`
+	Balance   int64       `json:"balance"`
+}
+
+// AccountTypeTotal sums the balances of the accounts of a type in a
+// currency, e.g. all USD liabilities
+type AccountTypeTotal struct {
+	Type     AccountType `json:"type"`
+	Currency string      `json:"currency"`
+	Debits   int64       `json:"debits"`
+	Credits  int64       `json:"credits"`
+	Balance  int64       `json:"balance"`
+}
+
+// CurrencyTotal sums every account in a currency. The books balance when
+// debits equal credits in each currency.
+type CurrencyTotal struct {
+	Currency string `json:"currency"`
+	Debits   int64  `json:"debits"`
+	Credits  int64  `json:"credits"`
+	Balanced bool   `json:"balanced"`
+}
+
+// TrialBalance is the ledger's accounts as they stood at AsOf
+type TrialBalance struct {
+	AsOf       time.Time          `json:"as_of"`
+	Accounts   []AccountBalance   `json:"accounts"`
+	Types      []AccountTypeTotal `json:"types"`
+	Currencies []CurrencyTotal    `json:"currencies"`
+	Balanced   bool               `json:"balanced"`
+}
+
+// UnbalancedTransaction is a transaction whose entries in Currency do not
+// sum to zero. RecordTransaction never writes one, so any found points at
+// the books having been altered outside of the service.
+type UnbalancedTransaction struct {
+	ID          string    `json:"id"`
+	ReferenceID string    `json:"reference_id"`
+	Description string    `json:"description"`
+	Currency    string    `json:"currency"`
+	Imbalance   int64     `json:"imbalance"`
+	EntryCount  int       `json:"entry_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// BalanceCheck is the outcome of checking every transaction balances
+type BalanceCheck struct {
+	CheckedAt              time.Time               `json:"checked_at"`
+	Balanced               bool                    `json:"balanced"`
+	UnbalancedTransactions []UnbalancedTransaction `json:"unbalanced_transactions"`
+}
+
+// GetTrialBalance sums the entries posted before asOf per account, per
+// account type and per currency. zoneID limits it to a zone's accounts.
+func (s *LedgerService) GetTrialBalance(ctx context.Context, asOf time.Time, zoneID string) (*TrialBalance, error) {
+	accounts, err := s.repo.ListAccountBalances(ctx, asOf, zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TrialBalance{AsOf: asOf, Accounts: []AccountBalance{}, Balanced: true}
+	type typeKey struct {
+		accType  AccountType
+		currency string
+	}
+	types := map[typeKey]*AccountTypeTotal{}
+	currencies := map[string]*CurrencyTotal{}
+	for _, acc := range accounts {
+		acc.Balance = acc.Debits - acc.Credits
+		report.Accounts = append(report.Accounts, acc)
+
+		key := typeKey{acc.Type, acc.Currency}
+		typeTotal, ok := types[key]
+		if !ok {
+			typeTotal = &AccountTypeTotal{Type: acc.Type, Currency: acc.Currency}
+			types[key] = typeTotal
+		}
+		typeTotal.Debits += acc.Debits
+		typeTotal.Credits += acc.Credits
+		typeTotal.Balance += acc.Balance
+
+		currencyTotal, ok := currencies[acc.Currency]
+		if !ok {
+			currencyTotal = &CurrencyTotal{Currency: acc.Currency}
+			currencies[acc.Currency] = currencyTotal
+		}
+		currencyTotal.Debits += acc.Debits
+		currencyTotal.Credits += acc.Credits
+	}
+
+	report.Types = make([]AccountTypeTotal, 0, len(types))
+	for _, t := range types {
+		report.Types = append(report.Types, *t)
+	}
+	sort.Slice(report.Types, func(i, j int) bool {
+		if report.Types[i].Type != report.Types[j].Type {
+			return report.Types[i].Type < report.Types[j].Type
+		}
+		return report.Types[i].Currency < report.Types[j].Currency
+	})
+
+	report.Currencies = make([]CurrencyTotal, 0, len(currencies))
+	for _, c := range currencies {
+		c.Balanced = c.Debits == c.Credits
+		if !c.Balanced {
+			report.Balanced = false
+		}
+		report.Currencies = append(report.Currencies, *c)
+	}
+	sort.Slice(report.Currencies, func(i, j int) bool {
+		return report.Currencies[i].Currency < report.Currencies[j].Currency
+	})
+	return report, nil
+}
+
+// CheckBalance looks for transactions whose entries do not sum to zero,
+// returning up to limit of the most recent ones
+func (s *LedgerService) CheckBalance(ctx context.Context, limit int) (*BalanceCheck, error) {
+	if limit <= 0 {
+		limit = DefaultBalanceCheckLimit
+	}
+	unbalanced, err := s.repo.ListUnbalancedTransactions(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	if s.metrics != nil {
+		s.metrics.RecordUnbalancedTransactions(len(unbalanced))
+	}
+	if unbalanced == nil {
+		unbalanced = []UnbalancedTransaction{}
+	}
+	return &BalanceCheck{
+		CheckedAt:              time.Now().UTC(),
+		Balanced:               len(unbalanced) == 0,
+		UnbalancedTransactions: unbalanced,
+	}, nil
+}
@@ -2,16 +2,36 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 type Repository interface {
 	CreateAccount(ctx context.Context, acc *Account) error
 	GetAccount(ctx context.Context, id string) (*Account, error)
+	GetAccountByCode(ctx context.Context, zoneID, mode, code string) (*Account, error)
+	AccountHasEntries(ctx context.Context, id string) (bool, error)
+	ListChartAccounts(ctx context.Context, zoneID, mode string) ([]Account, error)
+	DeleteProcessedEvents(ctx context.Context, before time.Time) (int, error)
 	BeginTx(ctx context.Context) (TransactionContext, error)
 	GetUnprocessedEvents(ctx context.Context, limit int) ([]OutboxEvent, error)
 	MarkEventProcessed(ctx context.Context, id string) error
 	ListTransactions(ctx context.Context, zoneID string, limit int) ([]TransactionWithEntries, error)
 	GetTransaction(ctx context.Context, id string) (*TransactionWithEntries, error)
+	ListAccountEntries(ctx context.Context, filter EntryFilter) ([]StatementEntry, error)
+	GetBalanceAt(ctx context.Context, accountID, currency string, pos EntryPosition) (int64, error)
+	ListAccountBalances(ctx context.Context, before time.Time, zoneID string) ([]AccountBalance, error)
+	ListUnbalancedTransactions(ctx context.Context, limit int) ([]UnbalancedTransaction, error)
+	CreateBalanceSnapshots(ctx context.Context, asOf time.Time) (int, error)
+	CreateHold(ctx context.Context, hold *Hold) error
+	GetHold(ctx context.Context, id string) (*Hold, error)
+	GetHoldByReference(ctx context.Context, referenceID string) (*Hold, error)
+	ListActiveHolds(ctx context.Context, accountID string, at time.Time) ([]Hold, error)
+	GetHeldAmount(ctx context.Context, accountID string, at time.Time) (int64, error)
+	ReleaseHold(ctx context.Context, id string) (bool, error)
+	ExpireHolds(ctx context.Context, at time.Time) (int, error)
+	ClosePeriod(ctx context.Context, period *Period) (bool, error)
+	GetPeriod(ctx context.Context, period string) (*Period, error)
+	ListPeriods(ctx context.Context) ([]Period, error)
 }
 
 type TransactionContext interface {
@@ -19,6 +39,11 @@ type TransactionContext interface {
 	CreateEntry(ctx context.Context, entry *Entry) error
 	CheckIdempotency(ctx context.Context, referenceID string) (string, error)
 	CreateOutboxEvent(ctx context.Context, eventType string, payload []byte) error
+	CaptureHold(ctx context.Context, holdID, transactionID string, amount int64) (bool, error)
+	CreateReversal(ctx context.Context, reversal *Reversal) (bool, error)
+	GetClosedPeriod(ctx context.Context, period string) (*Period, error)
+	GetProcessedEvent(ctx context.Context, eventID string) (string, error)
+	CreateProcessedEvent(ctx context.Context, eventID, transactionID string) (bool, error)
 	Commit() error
 	Rollback() error
 }
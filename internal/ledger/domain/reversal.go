@@ -0,0 +1,160 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrAlreadyReversed     = errors.New("transaction is already reversed")
+	ErrReverseReversal     = errors.New("a reversal cannot be reversed; record the original transaction again instead")
+	ErrReversalReason      = errors.New("reason is required")
+	ErrReversalActor       = errors.New("actor is required")
+)
+
+// Reversal links a transaction to the compensating transaction reversing it.
+// A transaction is reversed at most once.
+type Reversal struct {
+	TransactionID string    `json:"transaction_id"`
+	ReversalID    string    `json:"reversal_id"`
+	Reason        string    `json:"reason"`
+	Actor         string    `json:"actor"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ReversalRequest reverses a transaction. ReferenceID defaults to one
+// derived from the transaction, so retrying a reversal is safe.
+type ReversalRequest struct {
+	Reason      string `json:"reason"`
+	Actor       string `json:"actor"`
+	ReferenceID string `json:"reference_id"`
+}
+
+// ReverseTransaction records the transaction's entries again with opposite
+// amounts, in the zone and mode of the original, and marks the original as
+// reversed by it. Both happen in one database transaction along with a
+// ledger.transaction.reversed outbox event.
+func (s *LedgerService) ReverseTransaction(ctx context.Context, id string, req ReversalRequest) (*TransactionWithEntries, error) {
+	if req.Reason == "" {
+		return nil, ErrReversalReason
+	}
+	if req.Actor == "" {
+		return nil, ErrReversalActor
+	}
+	if req.ReferenceID == "" {
+		req.ReferenceID = "reversal:" + id
+	}
+
+	original, err := s.repo.GetTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if original == nil {
+		return nil, ErrTransactionNotFound
+	}
+	if original.ReversalOf != "" {
+		return nil, ErrReverseReversal
+	}
+	if original.ReversedBy != "" {
+		return s.existingReversal(ctx, original, req.ReferenceID)
+	}
+
+	entries := make([]EntryRequest, len(original.Entries))
+	for i, e := range original.Entries {
+		entries[i] = EntryRequest{
+			AccountID: e.AccountID,
+			Amount:    -e.Amount,
+			Currency:  e.Currency,
+			Direction: string(oppositeDirection(e.Direction)),
+		}
+	}
+
+	reversalID, err := s.recordTransaction(ctx, TransactionRequest{
+		ReferenceID: req.ReferenceID,
+		Description: fmt.Sprintf("Reversal of %s: %s", original.ID, req.Reason),
+		Entries:     entries,
+		reversal:    true,
+	}, original.ZoneID, original.Mode, func(txCtx TransactionContext, transactionID string) error {
+		reversal := &Reversal{
+			TransactionID: original.ID,
+			ReversalID:    transactionID,
+			Reason:        req.Reason,
+			Actor:         req.Actor,
+		}
+		created, err := txCtx.CreateReversal(ctx, reversal)
+		if err != nil {
+			return fmt.Errorf("failed to mark transaction reversed: %w", err)
+		}
+		if !created {
+			return ErrAlreadyReversed
+		}
+
+		eventData, _ := json.Marshal(map[string]interface{}{
+			"id":                      transactionID,
+			"original_transaction_id": original.ID,
+			"reference_id":            req.ReferenceID,
+			"reason":                  req.Reason,
+			"actor":                   req.Actor,
+			"entries":                 entries,
+			"zone_id":                 original.ZoneID,
+			"mode":                    original.Mode,
+		})
+		if err := txCtx.CreateOutboxEvent(ctx, "ledger.transaction.reversed", eventData); err != nil {
+			return fmt.Errorf("failed to create outbox event: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrAlreadyReversed) {
+		// Reversed concurrently; a retry of the same reversal still
+		// gets it back
+		if original, err = s.repo.GetTransaction(ctx, id); err != nil {
+			return nil, err
+		}
+		return s.existingReversal(ctx, original, req.ReferenceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reversal, err := s.repo.GetTransaction(ctx, reversalID)
+	if err != nil {
+		return nil, err
+	}
+	// A transaction already recorded under the reference does not reverse
+	// anything
+	if reversal == nil || reversal.ReversalOf != original.ID {
+		return nil, ErrReferenceInUse
+	}
+	return reversal, nil
+}
+
+// existingReversal returns the reversal of an already reversed transaction
+// when it was made under referenceID, as a retry would
+func (s *LedgerService) existingReversal(ctx context.Context, original *TransactionWithEntries, referenceID string) (*TransactionWithEntries, error) {
+	if original == nil || original.ReversedBy == "" {
+		return nil, ErrAlreadyReversed
+	}
+	reversal, err := s.repo.GetTransaction(ctx, original.ReversedBy)
+	if err != nil {
+		return nil, err
+	}
+	if reversal == nil || reversal.ReferenceID != referenceID {
+		return nil, ErrAlreadyReversed
+	}
+	return reversal, nil
+}
+
+func oppositeDirection(d TransactionType) TransactionType {
+	switch d {
+	case Debit:
+		return Credit
+	case Credit:
+		return Debit
+	default:
+		return d
+	}
+}
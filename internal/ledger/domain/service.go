@@ -5,46 +5,57 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/currency"
 )
 
 type Metrics interface {
 	RecordTransaction(status string)
+	RecordUnbalancedTransactions(count int)
 }
 
 type LedgerService struct {
-	repo    Repository
-	metrics Metrics
+	repo     Repository
+	metrics  Metrics
+	watchers *balanceWatchers
 }
 
 func NewLedgerService(repo Repository, metrics Metrics) *LedgerService {
 	return &LedgerService{
-		repo:    repo,
-		metrics: metrics,
+		repo:     repo,
+		metrics:  metrics,
+		watchers: newBalanceWatchers(),
 	}
 }
 
 func (s *LedgerService) CreateAccount(ctx context.Context, name string, accType AccountType, currency string, userID *string, zoneID, mode string) (*Account, error) {
-	acc := &Account{
+	return s.OpenAccount(ctx, AccountRequest{
 		Name:     name,
 		Type:     accType,
 		Currency: currency,
 		UserID:   userID,
-		ZoneID:   zoneID,
-		Mode:     mode,
-	}
-	err := s.repo.CreateAccount(ctx, acc)
-	if err != nil {
-		return nil, err
-	}
-	acc.Balance = 0
-	return acc, nil
+	}, zoneID, mode)
 }
 
 func (s *LedgerService) GetAccount(ctx context.Context, id string) (*Account, error) {
-	return s.repo.GetAccount(ctx, id)
+	acc, err := s.repo.GetAccount(ctx, id)
+	if err != nil || acc == nil {
+		return acc, err
+	}
+	return s.withHolds(ctx, acc)
 }
 
-func (s *LedgerService) RecordTransaction(ctx context.Context, req TransactionRequest, zoneID, mode string) (err error) {
+func (s *LedgerService) RecordTransaction(ctx context.Context, req TransactionRequest, zoneID, mode string) error {
+	_, err := s.recordTransaction(ctx, req, zoneID, mode, nil)
+	return err
+}
+
+// recordTransaction records the transaction and returns its ID. within,
+// when given, runs in the database transaction once the entries are
+// written, and rolls everything back if it fails. It does not run when the
+// transaction was already recorded.
+func (s *LedgerService) recordTransaction(ctx context.Context, req TransactionRequest, zoneID, mode string, within func(txCtx TransactionContext, transactionID string) error) (transactionID string, err error) {
 	defer func() {
 		if s.metrics != nil {
 			if err != nil {
@@ -55,57 +66,107 @@ func (s *LedgerService) RecordTransaction(ctx context.Context, req TransactionRe
 		}
 	}()
 
-	// 1. Validate Balance (Sum of amounts must be 0)
-	var sum int64
-	for _, e := range req.Entries {
-		sum += e.Amount
-	}
-	if sum != 0 {
-		return errors.New("transaction is not balanced (sum != 0)")
+	// 1. Validate Balance (Sum of amounts must be 0). Mixed-currency
+	// transactions balance per currency instead, checked with their FX.
+	if req.FX == nil && !req.reversal {
+		var sum int64
+		for _, e := range req.Entries {
+			sum += e.Amount
+		}
+		if sum != 0 {
+			return "", errors.New("transaction is not balanced (sum != 0)")
+		}
 	}
 
-	// 2. Validate Currency Consistency
-	var commonCurrency string
-	for _, e := range req.Entries {
+	// 2. Validate Currency Consistency. Entries default to their account's
+	// currency, and may only mix currencies through an FX conversion.
+	entries := make([]EntryRequest, len(req.Entries))
+	for i, e := range req.Entries {
 		acc, err := s.repo.GetAccount(ctx, e.AccountID)
 		if err != nil {
-			return fmt.Errorf("failed to get account %s for currency check: %w", e.AccountID, err)
+			return "", fmt.Errorf("failed to get account %s for currency check: %w", e.AccountID, err)
 		}
 		if acc == nil {
-			return fmt.Errorf("account %s not found", e.AccountID)
+			return "", fmt.Errorf("account %s not found", e.AccountID)
+		}
+		if err := checkLeafAccount(acc); err != nil {
+			return "", err
 		}
 
-		if commonCurrency == "" {
-			commonCurrency = acc.Currency
-		} else if commonCurrency != acc.Currency {
-			return fmt.Errorf("multi-currency transactions not supported: account %s has currency %s, expected %s", e.AccountID, acc.Currency, commonCurrency)
+		if e.Currency == "" {
+			e.Currency = acc.Currency
+		} else if e.Currency, err = currency.Normalize(e.Currency); err != nil {
+			return "", fmt.Errorf("entry for account %s: %w", e.AccountID, err)
+		}
+		if req.FX == nil && !req.reversal && i > 0 && e.Currency != entries[0].Currency {
+			return "", fmt.Errorf("mixed-currency transactions require an fx conversion: account %s has currency %s, expected %s", e.AccountID, e.Currency, entries[0].Currency)
+		}
+		entries[i] = e
+	}
+	req.Entries = entries
+	if req.FX != nil {
+		if err := req.FX.Validate(req.Entries); err != nil {
+			return "", err
+		}
+	}
+	if req.reversal {
+		sums := map[string]int64{}
+		for _, e := range req.Entries {
+			sums[e.Currency] += e.Amount
+		}
+		for code, sum := range sums {
+			if sum != 0 {
+				return "", fmt.Errorf("transaction is not balanced in %s (sum != 0)", code)
+			}
 		}
 	}
 
+	now := time.Now()
+	if req.EffectiveAt.IsZero() {
+		req.EffectiveAt = now
+	} else if req.EffectiveAt.After(now) {
+		return "", ErrFutureEffectiveDate
+	}
+
 	txCtx, err := s.repo.BeginTx(ctx)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer func() { _ = txCtx.Rollback() }()
 
+	if req.eventID != "" {
+		processedID, err := txCtx.GetProcessedEvent(ctx, req.eventID)
+		if err != nil {
+			return "", fmt.Errorf("failed to check processed events: %w", err)
+		}
+		if processedID != "" {
+			return processedID, nil // Event already processed
+		}
+	}
+
 	// 3. Check for existing transaction (Idempotency)
 	existingID, err := txCtx.CheckIdempotency(ctx, req.ReferenceID)
 	if err != nil {
-		return fmt.Errorf("failed to check idempotency: %w", err)
+		return "", fmt.Errorf("failed to check idempotency: %w", err)
 	}
 	if existingID != "" {
-		return nil // Already exists
+		return existingID, nil // Already exists
+	}
+
+	if err := checkPeriod(ctx, txCtx, req.EffectiveAt, req.Entries); err != nil {
+		return "", err
 	}
 
 	// 4. Insert Transaction Record
-	transactionID, err := txCtx.CreateTransaction(ctx, &Transaction{
+	transactionID, err = txCtx.CreateTransaction(ctx, &Transaction{
 		ReferenceID: req.ReferenceID,
 		Description: req.Description,
 		ZoneID:      zoneID,
 		Mode:        mode,
+		EffectiveAt: req.EffectiveAt,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create transaction: %w", err)
+		return "", fmt.Errorf("failed to create transaction: %w", err)
 	}
 
 	// 5. Insert Entries
@@ -114,10 +175,11 @@ func (s *LedgerService) RecordTransaction(ctx context.Context, req TransactionRe
 			TransactionID: transactionID,
 			AccountID:     e.AccountID,
 			Amount:        e.Amount,
+			Currency:      e.Currency,
 			Direction:     TransactionType(e.Direction),
 		})
 		if err != nil {
-			return fmt.Errorf("failed to create entry for account %s: %w", e.AccountID, err)
+			return "", fmt.Errorf("failed to create entry for account %s: %w", e.AccountID, err)
 		}
 	}
 
@@ -127,15 +189,42 @@ func (s *LedgerService) RecordTransaction(ctx context.Context, req TransactionRe
 		"reference_id": req.ReferenceID,
 		"description":  req.Description,
 		"entries":      req.Entries,
+		"fx":           req.FX,
+		"effective_at": req.EffectiveAt,
 		"zone_id":      zoneID,
 		"mode":         mode,
 	})
 	err = txCtx.CreateOutboxEvent(ctx, "transaction.recorded", eventData)
 	if err != nil {
-		return fmt.Errorf("failed to create outbox event: %w", err)
+		return "", fmt.Errorf("failed to create outbox event: %w", err)
+	}
+
+	if req.eventID != "" {
+		created, err := txCtx.CreateProcessedEvent(ctx, req.eventID, transactionID)
+		if err != nil {
+			return "", fmt.Errorf("failed to mark event processed: %w", err)
+		}
+		if !created {
+			return "", errEventProcessed
+		}
+	}
+
+	if within != nil {
+		if err := within(txCtx, transactionID); err != nil {
+			return "", err
+		}
 	}
 
-	return txCtx.Commit()
+	if err := txCtx.Commit(); err != nil {
+		return "", err
+	}
+
+	accountIDs := make([]string, len(req.Entries))
+	for i, e := range req.Entries {
+		accountIDs[i] = e.AccountID
+	}
+	s.watchers.notify(accountIDs...)
+	return transactionID, nil
 }
 func (s *LedgerService) BulkRecordTransactions(ctx context.Context, requests []TransactionRequest, zoneID, mode string) ([]error, error) {
 	errs := make([]error, len(requests))
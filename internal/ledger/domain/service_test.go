@@ -3,7 +3,11 @@ package domain
 import (
 	"context"
 	"errors"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestRecordTransaction_TableDriven(t *testing.T) {
@@ -112,3 +116,1030 @@ func TestCreateAccount_TableDriven(t *testing.T) {
 		})
 	}
 }
+
+func TestGetStatement_RunningBalance(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	entries := []StatementEntry{
+		{Entry: Entry{ID: "e1", AccountID: "acc_1", Amount: 500, CreatedAt: t0}},
+		{Entry: Entry{ID: "e2", AccountID: "acc_1", Amount: -200, CreatedAt: t0.Add(time.Hour)}},
+		{Entry: Entry{ID: "e3", AccountID: "acc_1", Amount: 50, CreatedAt: t0.Add(2 * time.Hour)}},
+	}
+
+	var gotFilter EntryFilter
+	var gotPos EntryPosition
+	mockRepo := &MockRepository{
+		GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+			return &Account{ID: id, Currency: "USD"}, nil
+		},
+		ListAccountEntriesFunc: func(ctx context.Context, filter EntryFilter) ([]StatementEntry, error) {
+			gotFilter = filter
+			return entries, nil
+		},
+		GetBalanceAtFunc: func(ctx context.Context, accountID, currency string, pos EntryPosition) (int64, error) {
+			gotPos = pos
+			return 1000, nil
+		},
+	}
+	service := NewLedgerService(mockRepo, nil)
+
+	statement, err := service.GetStatement(context.Background(), "acc_1", StatementQuery{From: t0, Limit: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotFilter.Limit != 3 {
+		t.Errorf("Expected one entry beyond the limit to be fetched, got limit %d", gotFilter.Limit)
+	}
+	if !gotPos.CreatedAt.Equal(t0) || gotPos.ID != "" {
+		t.Errorf("Expected the opening balance before the range, got %+v", gotPos)
+	}
+	if statement.OpeningBalance != 1000 || statement.ClosingBalance != 1300 {
+		t.Errorf("Expected balances 1000 to 1300, got %d to %d", statement.OpeningBalance, statement.ClosingBalance)
+	}
+	if len(statement.Entries) != 2 || statement.Entries[0].Balance != 1500 || statement.Entries[1].Balance != 1300 {
+		t.Errorf("Unexpected running balances: %+v", statement.Entries)
+	}
+
+	// The next page opens with the balance up to the last entry returned
+	cursor, err := DecodeCursor(statement.NextCursor)
+	if err != nil {
+		t.Fatalf("Expected a next cursor, got %q: %v", statement.NextCursor, err)
+	}
+	if cursor.ID != "e2" || !cursor.CreatedAt.Equal(entries[1].CreatedAt) {
+		t.Errorf("Expected the cursor to point at e2, got %+v", cursor)
+	}
+	entries = entries[2:]
+	statement, err = service.GetStatement(context.Background(), "acc_1", StatementQuery{From: t0, Limit: 2, Cursor: statement.NextCursor})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotFilter.After == nil || gotFilter.After.ID != "e2" || gotPos.ID != "e2" {
+		t.Errorf("Expected the second page to continue after e2, got filter %+v and position %+v", gotFilter.After, gotPos)
+	}
+	if statement.NextCursor != "" || len(statement.Entries) != 1 || statement.Entries[0].Balance != 1050 {
+		t.Errorf("Unexpected last page: %+v", statement)
+	}
+}
+
+func TestGetStatement_Errors(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		query       StatementQuery
+		account     *Account
+		expectedErr error
+	}{
+		{"Range Ends Before Start", StatementQuery{From: t0, To: t0.Add(-time.Hour)}, &Account{ID: "acc_1"}, ErrInvalidStatementRange},
+		{"Invalid Cursor", StatementQuery{Cursor: "not-a-cursor"}, &Account{ID: "acc_1"}, ErrInvalidCursor},
+		{"Account Not Found", StatementQuery{}, nil, ErrAccountNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{
+				GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+					return tt.account, nil
+				},
+			}
+			service := NewLedgerService(mockRepo, nil)
+
+			_, err := service.GetStatement(context.Background(), "acc_1", tt.query)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+type fakeMetrics struct {
+	unbalanced int
+}
+
+func (m *fakeMetrics) RecordTransaction(status string) {}
+
+func (m *fakeMetrics) RecordUnbalancedTransactions(count int) {
+	m.unbalanced = count
+}
+
+func TestGetTrialBalance(t *testing.T) {
+	tests := []struct {
+		name             string
+		balances         []AccountBalance
+		expectedBalanced bool
+		expectedTypes    []AccountTypeTotal
+	}{
+		{
+			name: "Balanced Books",
+			balances: []AccountBalance{
+				{AccountID: "cash", Type: Asset, Currency: "USD", Debits: 1000, Credits: 200},
+				{AccountID: "bank", Type: Asset, Currency: "USD", Debits: 300},
+				{AccountID: "payable", Type: Liability, Currency: "USD", Debits: 200, Credits: 900},
+				{AccountID: "fees", Type: Revenue, Currency: "USD", Credits: 400},
+				{AccountID: "eur_cash", Type: Asset, Currency: "EUR", Debits: 50},
+				{AccountID: "eur_payable", Type: Liability, Currency: "EUR", Credits: 50},
+			},
+			expectedBalanced: true,
+			expectedTypes: []AccountTypeTotal{
+				{Type: Asset, Currency: "EUR", Debits: 50, Balance: 50},
+				{Type: Asset, Currency: "USD", Debits: 1300, Credits: 200, Balance: 1100},
+				{Type: Liability, Currency: "EUR", Credits: 50, Balance: -50},
+				{Type: Liability, Currency: "USD", Debits: 200, Credits: 900, Balance: -700},
+				{Type: Revenue, Currency: "USD", Credits: 400, Balance: -400},
+			},
+		},
+		{
+			name: "Unbalanced Currency",
+			balances: []AccountBalance{
+				{AccountID: "cash", Type: Asset, Currency: "USD", Debits: 100},
+				{AccountID: "payable", Type: Liability, Currency: "USD", Credits: 100},
+				{AccountID: "eur_cash", Type: Asset, Currency: "EUR", Debits: 70},
+			},
+			expectedBalanced: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asOf := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+			mockRepo := &MockRepository{
+				ListAccountBalancesFunc: func(ctx context.Context, before time.Time, zoneID string) ([]AccountBalance, error) {
+					if !before.Equal(asOf) {
+						t.Errorf("Expected entries before %v, got %v", asOf, before)
+					}
+					return tt.balances, nil
+				},
+			}
+			service := NewLedgerService(mockRepo, nil)
+
+			report, err := service.GetTrialBalance(context.Background(), asOf, "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if report.Balanced != tt.expectedBalanced {
+				t.Errorf("Expected balanced %v, got %v (%+v)", tt.expectedBalanced, report.Balanced, report.Currencies)
+			}
+			if len(report.Accounts) != len(tt.balances) || report.Accounts[0].Balance != tt.balances[0].Debits-tt.balances[0].Credits {
+				t.Errorf("Unexpected account balances: %+v", report.Accounts)
+			}
+			if tt.expectedTypes != nil && !reflect.DeepEqual(report.Types, tt.expectedTypes) {
+				t.Errorf("Expected type totals %+v, got %+v", tt.expectedTypes, report.Types)
+			}
+		})
+	}
+}
+
+func TestCheckBalance(t *testing.T) {
+	metrics := &fakeMetrics{}
+	mockRepo := &MockRepository{
+		ListUnbalancedTransactionsFunc: func(ctx context.Context, limit int) ([]UnbalancedTransaction, error) {
+			if limit != DefaultBalanceCheckLimit {
+				t.Errorf("Expected the default limit, got %d", limit)
+			}
+			return []UnbalancedTransaction{{ID: "tx_1", Imbalance: 25, EntryCount: 2}}, nil
+		},
+	}
+	service := NewLedgerService(mockRepo, metrics)
+
+	check, err := service.CheckBalance(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if check.Balanced || len(check.UnbalancedTransactions) != 1 {
+		t.Errorf("Expected tx_1 to be reported unbalanced, got %+v", check)
+	}
+	if metrics.unbalanced != 1 {
+		t.Errorf("Expected the unbalanced count to be recorded, got %d", metrics.unbalanced)
+	}
+}
+
+func TestRecordTransaction_Currencies(t *testing.T) {
+	accounts := map[string]*Account{
+		"usd_wallet": {ID: "usd_wallet", Currency: "USD"},
+		"eur_wallet": {ID: "eur_wallet", Currency: "EUR"},
+		"fx":         {ID: "fx", Currency: "USD"},
+	}
+	conversion := []EntryRequest{
+		{AccountID: "usd_wallet", Amount: -10000},
+		{AccountID: "fx", Amount: 10000, Conversion: true},
+		{AccountID: "fx", Amount: -9200, Currency: "eur", Conversion: true},
+		{AccountID: "eur_wallet", Amount: 9200},
+	}
+	eurUSD := &FXConversion{BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: 0.92}
+
+	tests := []struct {
+		name        string
+		req         TransactionRequest
+		expectedErr string
+	}{
+		{
+			name: "Single Currency",
+			req: TransactionRequest{ReferenceID: "ref_1", Entries: []EntryRequest{
+				{AccountID: "usd_wallet", Amount: 100},
+				{AccountID: "fx", Amount: -100},
+			}},
+		},
+		{
+			name: "Mixed Currencies Without FX",
+			req: TransactionRequest{ReferenceID: "ref_2", Entries: []EntryRequest{
+				{AccountID: "usd_wallet", Amount: 100},
+				{AccountID: "eur_wallet", Amount: -100},
+			}},
+			expectedErr: "mixed-currency transactions require an fx conversion: account eur_wallet has currency EUR, expected USD",
+		},
+		{
+			name: "FX Conversion",
+			req:  TransactionRequest{ReferenceID: "ref_3", Entries: conversion, FX: eurUSD},
+		},
+		{
+			name: "FX Rate Mismatch",
+			req: TransactionRequest{ReferenceID: "ref_4", Entries: conversion,
+				FX: &FXConversion{BaseCurrency: "USD", QuoteCurrency: "EUR", Rate: 0.9}},
+			expectedErr: "fx conversion entries do not match the rate: 10000 USD at 0.9 is 9000 EUR, got 9200",
+		},
+		{
+			name: "FX Currency Unbalanced",
+			req: TransactionRequest{ReferenceID: "ref_5", FX: eurUSD, Entries: []EntryRequest{
+				{AccountID: "usd_wallet", Amount: -10000},
+				{AccountID: "fx", Amount: 10000, Conversion: true},
+				{AccountID: "fx", Amount: -9200, Currency: "EUR", Conversion: true},
+				{AccountID: "eur_wallet", Amount: 9000},
+			}},
+			expectedErr: "transaction is not balanced in EUR (sum != 0)",
+		},
+		{
+			name: "FX Without Conversion Entries",
+			req: TransactionRequest{ReferenceID: "ref_6", FX: eurUSD, Entries: []EntryRequest{
+				{AccountID: "usd_wallet", Amount: -10000},
+				{AccountID: "fx", Amount: 10000},
+				{AccountID: "fx", Amount: -9200, Currency: "EUR"},
+				{AccountID: "eur_wallet", Amount: 9200},
+			}},
+			expectedErr: "fx conversion requires conversion entries in both currencies",
+		},
+		{
+			name: "Entry Outside FX Pair",
+			req: TransactionRequest{ReferenceID: "ref_7", FX: eurUSD, Entries: append([]EntryRequest{
+				{AccountID: "usd_wallet", Amount: 100, Currency: "GBP"},
+			}, conversion...)},
+			expectedErr: "entry for account usd_wallet is in GBP, outside the fx pair USD/EUR",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created []*Entry
+			txCtx := &MockTransactionContext{
+				CheckIdempotencyFunc: func(ctx context.Context, referenceID string) (string, error) { return "", nil },
+				CreateTransactionFunc: func(ctx context.Context, tx *Transaction) (string, error) {
+					return "tx_1", nil
+				},
+				CreateEntryFunc: func(ctx context.Context, entry *Entry) error {
+					created = append(created, entry)
+					return nil
+				},
+				CreateOutboxEventFunc: func(ctx context.Context, eventType string, payload []byte) error { return nil },
+				CommitFunc:            func() error { return nil },
+				RollbackFunc:          func() error { return nil },
+			}
+			mockRepo := &MockRepository{
+				GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+					return accounts[id], nil
+				},
+				BeginTxFunc: func(ctx context.Context) (TransactionContext, error) { return txCtx, nil },
+			}
+			service := NewLedgerService(mockRepo, nil)
+
+			err := service.RecordTransaction(context.Background(), tt.req, "zone_123", "test")
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("Expected error '%s', got '%v'", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for i, e := range created {
+				want := accounts[tt.req.Entries[i].AccountID].Currency
+				if tt.req.Entries[i].Currency != "" {
+					want = strings.ToUpper(tt.req.Entries[i].Currency)
+				}
+				if e.Currency != want {
+					t.Errorf("Expected entry %d in %s, got %s", i, want, e.Currency)
+				}
+			}
+		})
+	}
+}
+
+func TestPlaceHold(t *testing.T) {
+	existing := &Hold{ID: "hold_1", ReferenceID: "ref_existing", Status: HoldActive}
+
+	tests := []struct {
+		name        string
+		req         HoldRequest
+		expectedErr error
+		expectedID  string
+	}{
+		{name: "Zero Amount", req: HoldRequest{ReferenceID: "ref_1"}, expectedErr: ErrInvalidHoldAmount},
+		{name: "Missing Reference", req: HoldRequest{Amount: -100}, expectedErr: ErrHoldReference},
+		{
+			name:        "Expiry Too Far",
+			req:         HoldRequest{Amount: -100, ReferenceID: "ref_1", ExpiresAt: time.Now().Add(MaxHoldTTL + time.Hour)},
+			expectedErr: ErrInvalidHoldExpiry,
+		},
+		{name: "Idempotent", req: HoldRequest{Amount: -100, ReferenceID: "ref_existing"}, expectedID: "hold_1"},
+		{name: "Account Not Found", req: HoldRequest{Amount: -100, ReferenceID: "ref_missing"}, expectedErr: ErrAccountNotFound},
+		{name: "Placed", req: HoldRequest{Amount: -100, ReferenceID: "ref_new"}, expectedID: "hold_new"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{
+				GetHoldByReferenceFunc: func(ctx context.Context, referenceID string) (*Hold, error) {
+					if referenceID == existing.ReferenceID {
+						return existing, nil
+					}
+					return nil, nil
+				},
+				GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+					if tt.req.ReferenceID == "ref_missing" {
+						return nil, nil
+					}
+					return &Account{ID: id, Currency: "USD"}, nil
+				},
+				CreateHoldFunc: func(ctx context.Context, hold *Hold) error {
+					hold.ID = "hold_new"
+					return nil
+				},
+			}
+			service := NewLedgerService(mockRepo, nil)
+
+			hold, err := service.PlaceHold(context.Background(), "acc_1", tt.req)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected error '%v', got '%v'", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if hold.ID != tt.expectedID {
+				t.Errorf("Expected hold %s, got %s", tt.expectedID, hold.ID)
+			}
+			if tt.expectedID == "hold_new" && (hold.Currency != "USD" || hold.Status != HoldActive || hold.ExpiresAt.IsZero()) {
+				t.Errorf("Unexpected hold: %+v", hold)
+			}
+		})
+	}
+}
+
+func TestCaptureHold(t *testing.T) {
+	tests := []struct {
+		name           string
+		hold           Hold
+		req            CaptureRequest
+		captured       bool
+		expectedErr    error
+		expectedAmount int64
+	}{
+		{
+			name:           "Full Capture",
+			hold:           Hold{Amount: -500, Status: HoldActive, ExpiresAt: time.Now().Add(time.Hour)},
+			req:            CaptureRequest{CounterAccountID: "merchant"},
+			captured:       true,
+			expectedAmount: -500,
+		},
+		{
+			name:           "Partial Capture",
+			hold:           Hold{Amount: -500, Status: HoldActive, ExpiresAt: time.Now().Add(time.Hour)},
+			req:            CaptureRequest{CounterAccountID: "merchant", Amount: 200},
+			captured:       true,
+			expectedAmount: -200,
+		},
+		{
+			name:        "Over Capture",
+			hold:        Hold{Amount: -500, Status: HoldActive, ExpiresAt: time.Now().Add(time.Hour)},
+			req:         CaptureRequest{CounterAccountID: "merchant", Amount: 600},
+			expectedErr: ErrInvalidCapture,
+		},
+		{
+			name:        "Missing Counter Account",
+			hold:        Hold{Amount: -500, Status: HoldActive, ExpiresAt: time.Now().Add(time.Hour)},
+			expectedErr: ErrCounterAccount,
+		},
+		{
+			name:        "Expired",
+			hold:        Hold{Amount: -500, Status: HoldActive, ExpiresAt: time.Now().Add(-time.Minute)},
+			req:         CaptureRequest{CounterAccountID: "merchant"},
+			expectedErr: ErrHoldNotActive,
+		},
+		{
+			name:        "Released Concurrently",
+			hold:        Hold{Amount: -500, Status: HoldActive, ExpiresAt: time.Now().Add(time.Hour)},
+			req:         CaptureRequest{CounterAccountID: "merchant"},
+			captured:    false,
+			expectedErr: ErrHoldNotActive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hold := tt.hold
+			hold.ID, hold.AccountID, hold.Currency = "hold_1", "customer", "USD"
+
+			var created []*Entry
+			var capturedAmount int64
+			txCtx := &MockTransactionContext{
+				CheckIdempotencyFunc: func(ctx context.Context, referenceID string) (string, error) { return "", nil },
+				CreateTransactionFunc: func(ctx context.Context, tx *Transaction) (string, error) {
+					return "tx_1", nil
+				},
+				CreateEntryFunc: func(ctx context.Context, entry *Entry) error {
+					created = append(created, entry)
+					return nil
+				},
+				CreateOutboxEventFunc: func(ctx context.Context, eventType string, payload []byte) error { return nil },
+				CaptureHoldFunc: func(ctx context.Context, holdID, transactionID string, amount int64) (bool, error) {
+					if tt.captured {
+						capturedAmount = amount
+						hold.Status, hold.CapturedAmount, hold.TransactionID = HoldCaptured, amount, transactionID
+					}
+					return tt.captured, nil
+				},
+				CommitFunc:   func() error { return nil },
+				RollbackFunc: func() error { return nil },
+			}
+			mockRepo := &MockRepository{
+				GetHoldFunc: func(ctx context.Context, id string) (*Hold, error) {
+					h := hold
+					return &h, nil
+				},
+				GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+					return &Account{ID: id, Currency: "USD"}, nil
+				},
+				BeginTxFunc: func(ctx context.Context) (TransactionContext, error) { return txCtx, nil },
+			}
+			service := NewLedgerService(mockRepo, nil)
+
+			result, err := service.CaptureHold(context.Background(), "hold_1", tt.req, "zone_123", "test")
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected error '%v', got '%v'", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Status != HoldCaptured || result.TransactionID != "tx_1" {
+				t.Errorf("Expected hold captured by tx_1, got %+v", result)
+			}
+			if capturedAmount != absAmount(tt.expectedAmount) {
+				t.Errorf("Expected captured amount %d, got %d", absAmount(tt.expectedAmount), capturedAmount)
+			}
+			if len(created) != 2 || created[0].AccountID != "customer" || created[0].Amount != tt.expectedAmount ||
+				created[1].AccountID != "merchant" || created[1].Amount != -tt.expectedAmount {
+				t.Errorf("Unexpected entries: %+v", created)
+			}
+		})
+	}
+}
+
+func TestGetAccount_AvailableBalance(t *testing.T) {
+	mockRepo := &MockRepository{
+		GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+			return &Account{ID: id, Currency: "USD", Balance: 1000}, nil
+		},
+		GetHeldAmountFunc: func(ctx context.Context, accountID string, at time.Time) (int64, error) {
+			return -300, nil
+		},
+	}
+	service := NewLedgerService(mockRepo, nil)
+
+	acc, err := service.GetAccount(context.Background(), "acc_1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if acc.PostedBalance != 1000 || acc.AvailableBalance != 700 {
+		t.Errorf("Expected posted 1000 and available 700, got %d and %d", acc.PostedBalance, acc.AvailableBalance)
+	}
+}
+
+func TestWatchBalance(t *testing.T) {
+	var mu sync.Mutex
+	balance := int64(100)
+	txCtx := &MockTransactionContext{
+		CheckIdempotencyFunc: func(ctx context.Context, referenceID string) (string, error) { return "", nil },
+		CreateTransactionFunc: func(ctx context.Context, tx *Transaction) (string, error) {
+			return "tx_1", nil
+		},
+		CreateEntryFunc: func(ctx context.Context, entry *Entry) error {
+			if entry.AccountID == "acc_1" {
+				mu.Lock()
+				balance += entry.Amount
+				mu.Unlock()
+			}
+			return nil
+		},
+		CreateOutboxEventFunc: func(ctx context.Context, eventType string, payload []byte) error { return nil },
+		CommitFunc:            func() error { return nil },
+		RollbackFunc:          func() error { return nil },
+	}
+	mockRepo := &MockRepository{
+		GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+			if id == "missing" {
+				return nil, nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			return &Account{ID: id, Currency: "USD", Balance: balance, Balances: map[string]int64{"USD": balance}}, nil
+		},
+		GetHeldAmountFunc: func(ctx context.Context, accountID string, at time.Time) (int64, error) { return 0, nil },
+		BeginTxFunc:       func(ctx context.Context) (TransactionContext, error) { return txCtx, nil },
+	}
+	service := NewLedgerService(mockRepo, nil)
+
+	err := service.WatchBalance(context.Background(), "missing", time.Hour, func(*Account) error { return nil })
+	if !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("Expected ErrAccountNotFound, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan int64, 4)
+	done := make(chan error, 1)
+	go func() {
+		// A long interval leaves the notification as the only way to see the change
+		done <- service.WatchBalance(ctx, "acc_1", time.Hour, func(acc *Account) error {
+			updates <- acc.AvailableBalance
+			return nil
+		})
+	}()
+
+	if got := <-updates; got != 100 {
+		t.Fatalf("Expected initial balance 100, got %d", got)
+	}
+	err = service.RecordTransaction(ctx, TransactionRequest{ReferenceID: "ref_1", Entries: []EntryRequest{
+		{AccountID: "acc_1", Amount: 50},
+		{AccountID: "acc_2", Amount: -50},
+	}}, "zone_123", "test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case got := <-updates:
+		if got != 150 {
+			t.Errorf("Expected updated balance 150, got %d", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a balance update after the transaction")
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestReverseTransaction(t *testing.T) {
+	original := TransactionWithEntries{
+		Transaction: Transaction{ID: "tx_1", ReferenceID: "ref_1", ZoneID: "zone_123", Mode: "live"},
+		Entries: []Entry{
+			{AccountID: "cash", Amount: 500, Currency: "USD", Direction: Debit},
+			{AccountID: "revenue", Amount: -500, Currency: "USD", Direction: Credit},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		id          string
+		req         ReversalRequest
+		reversedBy  string
+		expectedErr error
+	}{
+		{name: "Missing Reason", id: "tx_1", req: ReversalRequest{Actor: "user_1"}, expectedErr: ErrReversalReason},
+		{name: "Missing Actor", id: "tx_1", req: ReversalRequest{Reason: "duplicate"}, expectedErr: ErrReversalActor},
+		{name: "Not Found", id: "tx_missing", req: ReversalRequest{Reason: "duplicate", Actor: "user_1"}, expectedErr: ErrTransactionNotFound},
+		{name: "Reversal Of Reversal", id: "tx_rev", req: ReversalRequest{Reason: "duplicate", Actor: "user_1"}, reversedBy: "tx_rev", expectedErr: ErrReverseReversal},
+		{name: "Already Reversed", id: "tx_1", req: ReversalRequest{Reason: "duplicate", Actor: "user_1", ReferenceID: "other"}, reversedBy: "tx_rev", expectedErr: ErrAlreadyReversed},
+		{name: "Retried", id: "tx_1", req: ReversalRequest{Reason: "duplicate", Actor: "user_1"}, reversedBy: "tx_rev"},
+		{name: "Reversed", id: "tx_1", req: ReversalRequest{Reason: "duplicate", Actor: "user_1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txs := map[string]TransactionWithEntries{"tx_1": original}
+			if tt.reversedBy != "" {
+				orig := txs["tx_1"]
+				orig.ReversedBy = tt.reversedBy
+				txs["tx_1"] = orig
+				txs["tx_rev"] = TransactionWithEntries{Transaction: Transaction{ID: "tx_rev", ReferenceID: "reversal:tx_1", ReversalOf: "tx_1"}}
+			}
+
+			var created []*Entry
+			var reversal *Reversal
+			var events []string
+			txCtx := &MockTransactionContext{
+				CheckIdempotencyFunc: func(ctx context.Context, referenceID string) (string, error) { return "", nil },
+				CreateTransactionFunc: func(ctx context.Context, tx *Transaction) (string, error) {
+					if tx.ZoneID != "zone_123" || tx.Mode != "live" {
+						t.Errorf("Expected the original's zone and mode, got %s and %s", tx.ZoneID, tx.Mode)
+					}
+					txs["tx_rev"] = TransactionWithEntries{Transaction: Transaction{ID: "tx_rev", ReferenceID: tx.ReferenceID}}
+					return "tx_rev", nil
+				},
+				CreateEntryFunc: func(ctx context.Context, entry *Entry) error {
+					created = append(created, entry)
+					return nil
+				},
+				CreateOutboxEventFunc: func(ctx context.Context, eventType string, payload []byte) error {
+					events = append(events, eventType)
+					return nil
+				},
+				CreateReversalFunc: func(ctx context.Context, r *Reversal) (bool, error) {
+					reversal = r
+					rev := txs["tx_rev"]
+					rev.ReversalOf = r.TransactionID
+					txs["tx_rev"] = rev
+					return true, nil
+				},
+				CommitFunc:   func() error { return nil },
+				RollbackFunc: func() error { return nil },
+			}
+			mockRepo := &MockRepository{
+				GetTransactionFunc: func(ctx context.Context, id string) (*TransactionWithEntries, error) {
+					tx, ok := txs[id]
+					if !ok {
+						return nil, nil
+					}
+					return &tx, nil
+				},
+				GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+					return &Account{ID: id, Currency: "USD"}, nil
+				},
+				BeginTxFunc: func(ctx context.Context) (TransactionContext, error) { return txCtx, nil },
+			}
+			service := NewLedgerService(mockRepo, nil)
+
+			result, err := service.ReverseTransaction(context.Background(), tt.id, tt.req)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected error '%v', got '%v'", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.ID != "tx_rev" || result.ReversalOf != "tx_1" {
+				t.Errorf("Expected reversal tx_rev of tx_1, got %+v", result.Transaction)
+			}
+			if tt.reversedBy != "" {
+				if len(created) != 0 {
+					t.Errorf("Expected a retry to record nothing, got %d entries", len(created))
+				}
+				return
+			}
+
+			if len(created) != 2 || created[0].Amount != -500 || created[0].Direction != Credit ||
+				created[1].Amount != 500 || created[1].Direction != Debit {
+				t.Errorf("Expected opposite entries, got %+v and %+v", created[0], created[1])
+			}
+			if reversal == nil || reversal.TransactionID != "tx_1" || reversal.Reason != "duplicate" || reversal.Actor != "user_1" {
+				t.Errorf("Unexpected reversal: %+v", reversal)
+			}
+			if !reflect.DeepEqual(events, []string{"transaction.recorded", "ledger.transaction.reversed"}) {
+				t.Errorf("Unexpected outbox events: %v", events)
+			}
+		})
+	}
+}
+
+func TestClosePeriod(t *testing.T) {
+	current := PeriodOf(time.Now())
+	tests := []struct {
+		name        string
+		period      string
+		req         ClosePeriodRequest
+		closed      bool
+		expectedErr error
+	}{
+		{name: "Invalid Period", period: "2024-13", req: ClosePeriodRequest{ClosedBy: "user_1"}, expectedErr: ErrInvalidPeriod},
+		{name: "Not Ended", period: current, req: ClosePeriodRequest{ClosedBy: "user_1"}, expectedErr: ErrPeriodNotEnded},
+		{name: "Missing Actor", period: "2024-01", expectedErr: ErrPeriodClosedBy},
+		{name: "Unknown Adjustment Account", period: "2024-01", req: ClosePeriodRequest{ClosedBy: "user_1", AdjustmentAccountID: "missing"}, expectedErr: ErrAccountNotFound},
+		{name: "Already Closed", period: "2024-01", req: ClosePeriodRequest{ClosedBy: "user_1"}, closed: true, expectedErr: ErrPeriodAlreadyClosed},
+		{name: "Closed", period: "2024-01", req: ClosePeriodRequest{ClosedBy: "user_1", AdjustmentAccountID: "adjustments"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recorded *Period
+			mockRepo := &MockRepository{
+				GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+					if id == "missing" {
+						return nil, nil
+					}
+					return &Account{ID: id}, nil
+				},
+				ClosePeriodFunc: func(ctx context.Context, period *Period) (bool, error) {
+					if tt.closed {
+						return false, nil
+					}
+					recorded = period
+					return true, nil
+				},
+			}
+			service := NewLedgerService(mockRepo, nil)
+
+			p, err := service.ClosePeriod(context.Background(), tt.period, tt.req)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected error '%v', got '%v'", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p != recorded || p.Period != "2024-01" || p.ClosedBy != "user_1" || p.AdjustmentAccountID != "adjustments" {
+				t.Errorf("Unexpected period: %+v", p)
+			}
+		})
+	}
+}
+
+func TestRecordTransaction_ClosedPeriod(t *testing.T) {
+	closedAt := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	periods := map[string]*Period{
+		"2024-01": {Period: "2024-01", AdjustmentAccountID: "adjustments"},
+		"2024-02": {Period: "2024-02"},
+	}
+
+	tests := []struct {
+		name        string
+		effectiveAt time.Time
+		accountID   string
+		expectedErr string
+	}{
+		{name: "Current Period", accountID: "cash"},
+		{name: "Open Past Period", effectiveAt: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), accountID: "cash"},
+		{name: "Closed Period", effectiveAt: closedAt, accountID: "cash",
+			expectedErr: "period is closed: 2024-01; adjusting entries must post to account adjustments"},
+		{name: "Adjusting Entry", effectiveAt: closedAt, accountID: "adjustments"},
+		{name: "Closed Without Adjustment Account", effectiveAt: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), accountID: "adjustments",
+			expectedErr: "period is closed: 2024-02"},
+		{name: "Future", effectiveAt: time.Now().Add(time.Hour), accountID: "cash", expectedErr: ErrFutureEffectiveDate.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recorded *Transaction
+			txCtx := &MockTransactionContext{
+				CheckIdempotencyFunc: func(ctx context.Context, referenceID string) (string, error) { return "", nil },
+				GetClosedPeriodFunc: func(ctx context.Context, period string) (*Period, error) {
+					return periods[period], nil
+				},
+				CreateTransactionFunc: func(ctx context.Context, tx *Transaction) (string, error) {
+					recorded = tx
+					return "tx_1", nil
+				},
+				CreateEntryFunc:       func(ctx context.Context, entry *Entry) error { return nil },
+				CreateOutboxEventFunc: func(ctx context.Context, eventType string, payload []byte) error { return nil },
+				CommitFunc:            func() error { return nil },
+				RollbackFunc:          func() error { return nil },
+			}
+			mockRepo := &MockRepository{
+				GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+					return &Account{ID: id, Currency: "USD"}, nil
+				},
+				BeginTxFunc: func(ctx context.Context) (TransactionContext, error) { return txCtx, nil },
+			}
+			service := NewLedgerService(mockRepo, nil)
+
+			err := service.RecordTransaction(context.Background(), TransactionRequest{
+				ReferenceID: "ref_1",
+				EffectiveAt: tt.effectiveAt,
+				Entries: []EntryRequest{
+					{AccountID: tt.accountID, Amount: 100},
+					{AccountID: "revenue", Amount: -100},
+				},
+			}, "zone_123", "test")
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("Expected error '%s', got '%v'", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !tt.effectiveAt.IsZero() && !recorded.EffectiveAt.Equal(tt.effectiveAt) {
+				t.Errorf("Expected effective date %v, got %v", tt.effectiveAt, recorded.EffectiveAt)
+			}
+		})
+	}
+}
+
+func TestOpenAccount_Chart(t *testing.T) {
+	parentID := "acc_1000"
+	tests := []struct {
+		name        string
+		req         AccountRequest
+		zoneID      string
+		posted      bool
+		expectedErr error
+	}{
+		{name: "Top Level", req: AccountRequest{Name: "Assets", Type: Asset, Code: "1000"}, zoneID: "zone_123"},
+		{name: "Sub-account", req: AccountRequest{Name: "Cash", Type: Asset, Code: "1100", ParentID: parentID}, zoneID: "zone_123"},
+		{name: "Invalid Code", req: AccountRequest{Name: "Cash", Type: Asset, Code: "cash"}, zoneID: "zone_123", expectedErr: ErrInvalidAccountCode},
+		{name: "Code In Use", req: AccountRequest{Name: "Cash", Type: Asset, Code: "1000"}, zoneID: "zone_123", expectedErr: ErrAccountCodeInUse},
+		{name: "Unknown Parent", req: AccountRequest{Name: "Cash", Type: Asset, ParentID: "missing"}, zoneID: "zone_123", expectedErr: ErrParentAccountNotFound},
+		{name: "Parent Of Another Type", req: AccountRequest{Name: "Fees", Type: Revenue, ParentID: parentID}, zoneID: "zone_123", expectedErr: ErrParentAccountMismatch},
+		{name: "Parent In Another Zone", req: AccountRequest{Name: "Cash", Type: Asset, ParentID: parentID}, zoneID: "zone_456", expectedErr: ErrParentAccountMismatch},
+		{name: "Parent With Entries", req: AccountRequest{Name: "Cash", Type: Asset, ParentID: parentID}, zoneID: "zone_123", posted: true, expectedErr: ErrParentAccountPosted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := "1000"
+			if tt.expectedErr == nil {
+				existing = ""
+			}
+			mockRepo := &MockRepository{
+				GetAccountByCodeFunc: func(ctx context.Context, zoneID, mode, code string) (*Account, error) {
+					if code == existing {
+						return &Account{ID: parentID, Code: code}, nil
+					}
+					return nil, nil
+				},
+				GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+					if id != parentID {
+						return nil, nil
+					}
+					return &Account{ID: parentID, Type: Asset, ZoneID: "zone_123", Mode: "test", Code: "1000"}, nil
+				},
+				AccountHasEntriesFunc: func(ctx context.Context, id string) (bool, error) { return tt.posted, nil },
+				CreateAccountFunc: func(ctx context.Context, acc *Account) error {
+					acc.ID = "acc_new"
+					return nil
+				},
+			}
+			service := NewLedgerService(mockRepo, nil)
+
+			acc, err := service.OpenAccount(context.Background(), tt.req, tt.zoneID, "test")
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected error '%v', got '%v'", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if acc.Code != tt.req.Code {
+				t.Errorf("Expected code %s, got %s", tt.req.Code, acc.Code)
+			}
+			if tt.req.ParentID != "" && (acc.ParentID == nil || *acc.ParentID != parentID) {
+				t.Errorf("Expected parent %s, got %v", parentID, acc.ParentID)
+			}
+		})
+	}
+}
+
+func TestGetAccountTree(t *testing.T) {
+	parent := func(id string) *string { return &id }
+	mockRepo := &MockRepository{
+		ListChartAccountsFunc: func(ctx context.Context, zoneID, mode string) ([]Account, error) {
+			return []Account{
+				{ID: "bank", Name: "Bank", Code: "1110", ParentID: parent("cash"), Currency: "USD", Balances: map[string]int64{"USD": 700, "EUR": 50}},
+				{ID: "assets", Name: "Assets", Code: "1000", Currency: "USD", Balances: map[string]int64{"USD": 0}},
+				{ID: "fees", Name: "Fees", Code: "4000", Currency: "USD", Balances: map[string]int64{"USD": -900}},
+				{ID: "till", Name: "Till", Code: "1120", ParentID: parent("cash"), Currency: "USD", Balances: map[string]int64{"USD": 200}},
+				{ID: "cash", Name: "Cash", Code: "1100", ParentID: parent("assets"), Currency: "USD", Balances: map[string]int64{"USD": 0}},
+			}, nil
+		},
+	}
+	service := NewLedgerService(mockRepo, nil)
+
+	tree, err := service.GetAccountTree(context.Background(), "zone_123", "test", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tree) != 2 || tree[0].ID != "assets" || tree[1].ID != "fees" {
+		t.Fatalf("Expected roots assets and fees, got %+v", tree)
+	}
+	assets := tree[0]
+	if !assets.HasChildren || len(assets.Children) != 1 || assets.Children[0].ID != "cash" {
+		t.Fatalf("Expected cash under assets, got %+v", assets.Children)
+	}
+	cash := assets.Children[0]
+	if len(cash.Children) != 2 || cash.Children[0].ID != "bank" || cash.Children[1].ID != "till" {
+		t.Errorf("Expected bank then till under cash, got %+v", cash.Children)
+	}
+	if assets.RollupBalances["USD"] != 900 || assets.RollupBalances["EUR"] != 50 {
+		t.Errorf("Expected assets to roll up 900 USD and 50 EUR, got %v", assets.RollupBalances)
+	}
+	if cash.Balances["USD"] != 0 || cash.RollupBalances["USD"] != 900 {
+		t.Errorf("Expected cash to hold 0 and roll up 900 USD, got %v and %v", cash.Balances, cash.RollupBalances)
+	}
+
+	subtree, err := service.GetAccountTree(context.Background(), "zone_123", "test", "cash")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(subtree) != 1 || subtree[0].ID != "cash" {
+		t.Errorf("Expected the tree below cash, got %+v", subtree)
+	}
+	if _, err := service.GetAccountTree(context.Background(), "zone_123", "test", "missing"); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected error '%v', got '%v'", ErrAccountNotFound, err)
+	}
+}
+
+func TestRecordTransaction_ParentAccount(t *testing.T) {
+	mockRepo := &MockRepository{
+		GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+			return &Account{ID: id, Currency: "USD", HasChildren: id == "assets"}, nil
+		},
+	}
+	service := NewLedgerService(mockRepo, nil)
+
+	err := service.RecordTransaction(context.Background(), TransactionRequest{
+		ReferenceID: "ref_1",
+		Entries: []EntryRequest{
+			{AccountID: "assets", Amount: 100},
+			{AccountID: "revenue", Amount: -100},
+		},
+	}, "zone_123", "test")
+	if !errors.Is(err, ErrNotLeafAccount) {
+		t.Errorf("Expected error '%v', got '%v'", ErrNotLeafAccount, err)
+	}
+}
+
+func TestRecordEventTransaction(t *testing.T) {
+	tests := []struct {
+		name        string
+		processedID string
+		conflict    bool
+		recorded    bool
+	}{
+		{name: "New Event", recorded: true},
+		{name: "Replayed Event", processedID: "tx_0"},
+		{name: "Processed Concurrently", conflict: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var marked string
+			committed := false
+			txCtx := &MockTransactionContext{
+				GetProcessedEventFunc: func(ctx context.Context, eventID string) (string, error) {
+					return tt.processedID, nil
+				},
+				CheckIdempotencyFunc: func(ctx context.Context, referenceID string) (string, error) { return "", nil },
+				CreateTransactionFunc: func(ctx context.Context, tx *Transaction) (string, error) {
+					return "tx_1", nil
+				},
+				CreateEntryFunc:       func(ctx context.Context, entry *Entry) error { return nil },
+				CreateOutboxEventFunc: func(ctx context.Context, eventType string, payload []byte) error { return nil },
+				CreateProcessedEventFunc: func(ctx context.Context, eventID, transactionID string) (bool, error) {
+					if tt.conflict {
+						return false, nil
+					}
+					marked = eventID + "=" + transactionID
+					return true, nil
+				},
+				CommitFunc: func() error {
+					committed = true
+					return nil
+				},
+				RollbackFunc: func() error { return nil },
+			}
+			mockRepo := &MockRepository{
+				GetAccountFunc: func(ctx context.Context, id string) (*Account, error) {
+					return &Account{ID: id, Currency: "USD"}, nil
+				},
+				BeginTxFunc: func(ctx context.Context) (TransactionContext, error) { return txCtx, nil },
+			}
+			service := NewLedgerService(mockRepo, nil)
+
+			err := service.RecordEventTransaction(context.Background(), "evt_pi_1", TransactionRequest{
+				ReferenceID: "replay_123",
+				Entries: []EntryRequest{
+					{AccountID: "user_1", Amount: 100},
+					{AccountID: "system_balancing", Amount: -100},
+				},
+			}, "zone_123", "test")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if committed != tt.recorded {
+				t.Errorf("Expected committed %v, got %v", tt.recorded, committed)
+			}
+			if tt.recorded && marked != "evt_pi_1=tx_1" {
+				t.Errorf("Expected event marked processed by tx_1, got %q", marked)
+			}
+		})
+	}
+}
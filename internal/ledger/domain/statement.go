@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/currency"
+)
+
+const (
+	DefaultStatementLimit = 100
+	MaxStatementLimit     = 1000
+)
+
+var (
+	ErrAccountNotFound       = errors.New("account not found")
+	ErrInvalidStatementRange = errors.New("statement range must end after it starts")
+	ErrInvalidCursor         = errors.New("invalid cursor")
+)
+
+// StatementEntry is an entry of an account statement with the transaction
+// it belongs to and the account's balance once it was posted
+type StatementEntry struct {
+	Entry
+	ReferenceID string `json:"reference_id"`
+	Description string `json:"description"`
+	Balance     int64  `json:"balance"`
+}
+
+// Statement is a page of an account's entries in a currency, in posting
+// order. The opening balance is the account's balance in that currency
+// before the first entry of the page and the closing balance its balance
+// after the last.
+type Statement struct {
+	AccountID      string           `json:"account_id"`
+	Currency       string           `json:"currency"`
+	OpeningBalance int64            `json:"opening_balance"`
+	ClosingBalance int64            `json:"closing_balance"`
+	Entries        []StatementEntry `json:"entries"`
+	NextCursor     string           `json:"next_cursor,omitempty"`
+}
+
+// StatementQuery selects the entries in Currency, by default the account's,
+// posted from From (inclusive) until To (exclusive). A zero bound leaves that
+// end open. Cursor continues from the NextCursor of a previous page.
+type StatementQuery struct {
+	Currency string
+	From     time.Time
+	To       time.Time
+	Cursor   string
+	Limit    int
+}
+
+// EntryPosition is where an entry falls in an account's posting order.
+// Entries posted at the same instant are ordered by ID. Without an ID it is
+// the instant before anything posted at CreatedAt.
+type EntryPosition struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// EntryFilter selects an account's entries for a statement page
+type EntryFilter struct {
+	AccountID string
+	Currency  string
+	From      time.Time
+	To        time.Time
+	After     *EntryPosition
+	Limit     int
+}
+
+// EncodeCursor returns the opaque cursor of the page after the entry at pos
+func EncodeCursor(pos EntryPosition) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pos.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + pos.ID))
+}
+
+// DecodeCursor reverses EncodeCursor
+func DecodeCursor(cursor string) (*EntryPosition, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	at, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &EntryPosition{CreatedAt: createdAt, ID: id}, nil
+}
+
+// GetStatement returns a page of an account's entries with the running
+// balance after each of them
+func (s *LedgerService) GetStatement(ctx context.Context, accountID string, q StatementQuery) (*Statement, error) {
+	if !q.From.IsZero() && !q.To.IsZero() && !q.To.After(q.From) {
+		return nil, ErrInvalidStatementRange
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultStatementLimit
+	}
+	if q.Limit > MaxStatementLimit {
+		q.Limit = MaxStatementLimit
+	}
+
+	filter := EntryFilter{AccountID: accountID, From: q.From, To: q.To, Limit: q.Limit + 1}
+	if q.Cursor != "" {
+		after, err := DecodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = after
+	}
+
+	acc, err := s.repo.GetAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if acc == nil {
+		return nil, ErrAccountNotFound
+	}
+	filter.Currency = acc.Currency
+	if q.Currency != "" {
+		if filter.Currency, err = currency.Normalize(q.Currency); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := s.repo.ListAccountEntries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{AccountID: acc.ID, Currency: filter.Currency, Entries: []StatementEntry{}}
+	if len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+		last := entries[len(entries)-1]
+		statement.NextCursor = EncodeCursor(EntryPosition{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	// The balance brought forward is everything posted before the page:
+	// up to the cursor's entry when there is one, otherwise before the range
+	switch {
+	case filter.After != nil:
+		statement.OpeningBalance, err = s.repo.GetBalanceAt(ctx, accountID, filter.Currency, *filter.After)
+	case !q.From.IsZero():
+		statement.OpeningBalance, err = s.repo.GetBalanceAt(ctx, accountID, filter.Currency, EntryPosition{CreatedAt: q.From})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	balance := statement.OpeningBalance
+	for _, e := range entries {
+		balance += e.Amount
+		e.Balance = balance
+		statement.Entries = append(statement.Entries, e)
+	}
+	statement.ClosingBalance = balance
+	return statement, nil
+}
@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is how often a balance watch re-reads the account
+// when nothing in this process has changed it, to pick up transactions
+// recorded by other instances and holds expiring
+const DefaultWatchInterval = 5 * time.Second
+
+// balanceWatchers wakes balance watches up when a transaction or hold in
+// this process touches their account
+type balanceWatchers struct {
+	mu       sync.Mutex
+	watchers map[string]map[chan struct{}]struct{}
+}
+
+func newBalanceWatchers() *balanceWatchers {
+	return &balanceWatchers{watchers: map[string]map[chan struct{}]struct{}{}}
+}
+
+func (b *balanceWatchers) subscribe(accountID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.watchers[accountID] == nil {
+		b.watchers[accountID] = map[chan struct{}]struct{}{}
+	}
+	b.watchers[accountID][ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.watchers[accountID], ch)
+		if len(b.watchers[accountID]) == 0 {
+			delete(b.watchers, accountID)
+		}
+	}
+}
+
+// notify wakes the watches of the accounts without blocking; a watch
+// already due to re-read its account is not woken twice
+func (b *balanceWatchers) notify(accountIDs ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, id := range accountIDs {
+		for ch := range b.watchers[id] {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// WatchBalance sends the account, with its balances, to send straight away
+// and again every time its balances change, until the context is cancelled
+// or send fails. Changes made in this process are sent as they happen;
+// others are picked up every interval.
+func (s *LedgerService) WatchBalance(ctx context.Context, accountID string, interval time.Duration, send func(*Account) error) error {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	changed, unsubscribe := s.watchers.subscribe(accountID)
+	defer unsubscribe()
+
+	acc, err := s.GetAccount(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if acc == nil {
+		return ErrAccountNotFound
+	}
+	if err := send(acc); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		case <-ticker.C:
+		}
+
+		next, err := s.GetAccount(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			return ErrAccountNotFound
+		}
+		if next.AvailableBalance == acc.AvailableBalance && maps.Equal(next.Balances, acc.Balances) {
+			continue
+		}
+		if err := send(next); err != nil {
+			return err
+		}
+		acc = next
+	}
+}
@@ -35,6 +35,10 @@ func (r *CachedRepository) CreateAccount(ctx context.Context, acc *domain.Accoun
 	}
 	// Invalidate cache just in case
 	r.redis.Del(ctx, r.accKey(acc.ID))
+	if acc.ParentID != nil {
+		// The parent now has sub-accounts, so entries to it are refused
+		r.redis.Del(ctx, r.accKey(*acc.ParentID))
+	}
 	return nil
 }
 
@@ -89,6 +93,54 @@ func (r *CachedRepository) GetTransaction(ctx context.Context, id string) (*doma
 	return r.repo.GetTransaction(ctx, id)
 }
 
+func (r *CachedRepository) ListAccountEntries(ctx context.Context, filter domain.EntryFilter) ([]domain.StatementEntry, error) {
+	return r.repo.ListAccountEntries(ctx, filter)
+}
+
+func (r *CachedRepository) GetBalanceAt(ctx context.Context, accountID, currency string, pos domain.EntryPosition) (int64, error) {
+	return r.repo.GetBalanceAt(ctx, accountID, currency, pos)
+}
+
+func (r *CachedRepository) ListAccountBalances(ctx context.Context, before time.Time, zoneID string) ([]domain.AccountBalance, error) {
+	return r.repo.ListAccountBalances(ctx, before, zoneID)
+}
+
+func (r *CachedRepository) ListUnbalancedTransactions(ctx context.Context, limit int) ([]domain.UnbalancedTransaction, error) {
+	return r.repo.ListUnbalancedTransactions(ctx, limit)
+}
+
+func (r *CachedRepository) CreateBalanceSnapshots(ctx context.Context, asOf time.Time) (int, error) {
+	return r.repo.CreateBalanceSnapshots(ctx, asOf)
+}
+
+func (r *CachedRepository) CreateHold(ctx context.Context, hold *domain.Hold) error {
+	return r.repo.CreateHold(ctx, hold)
+}
+
+func (r *CachedRepository) GetHold(ctx context.Context, id string) (*domain.Hold, error) {
+	return r.repo.GetHold(ctx, id)
+}
+
+func (r *CachedRepository) GetHoldByReference(ctx context.Context, referenceID string) (*domain.Hold, error) {
+	return r.repo.GetHoldByReference(ctx, referenceID)
+}
+
+func (r *CachedRepository) ListActiveHolds(ctx context.Context, accountID string, at time.Time) ([]domain.Hold, error) {
+	return r.repo.ListActiveHolds(ctx, accountID, at)
+}
+
+func (r *CachedRepository) GetHeldAmount(ctx context.Context, accountID string, at time.Time) (int64, error) {
+	return r.repo.GetHeldAmount(ctx, accountID, at)
+}
+
+func (r *CachedRepository) ReleaseHold(ctx context.Context, id string) (bool, error) {
+	return r.repo.ReleaseHold(ctx, id)
+}
+
+func (r *CachedRepository) ExpireHolds(ctx context.Context, at time.Time) (int, error) {
+	return r.repo.ExpireHolds(ctx, at)
+}
+
 type cachedTransactionContext struct {
 	domain.TransactionContext
 	redis       *redis.Client
@@ -114,3 +166,31 @@ func (c *cachedTransactionContext) Commit() error {
 	}
 	return err
 }
+
+func (r *CachedRepository) ClosePeriod(ctx context.Context, period *domain.Period) (bool, error) {
+	return r.repo.ClosePeriod(ctx, period)
+}
+
+func (r *CachedRepository) GetPeriod(ctx context.Context, period string) (*domain.Period, error) {
+	return r.repo.GetPeriod(ctx, period)
+}
+
+func (r *CachedRepository) ListPeriods(ctx context.Context) ([]domain.Period, error) {
+	return r.repo.ListPeriods(ctx)
+}
+
+func (r *CachedRepository) GetAccountByCode(ctx context.Context, zoneID, mode, code string) (*domain.Account, error) {
+	return r.repo.GetAccountByCode(ctx, zoneID, mode, code)
+}
+
+func (r *CachedRepository) AccountHasEntries(ctx context.Context, id string) (bool, error) {
+	return r.repo.AccountHasEntries(ctx, id)
+}
+
+func (r *CachedRepository) ListChartAccounts(ctx context.Context, zoneID, mode string) ([]domain.Account, error) {
+	return r.repo.ListChartAccounts(ctx, zoneID, mode)
+}
+
+func (r *CachedRepository) DeleteProcessedEvents(ctx context.Context, before time.Time) (int, error) {
+	return r.repo.DeleteProcessedEvents(ctx, before)
+}
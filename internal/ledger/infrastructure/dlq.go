@@ -0,0 +1,43 @@
+package infrastructure
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/messaging"
+)
+
+const (
+	// LedgerDLQTopic receives the payment events the ledger failed to record
+	// after every retry, wrapped in a messaging.DeadLetter
+	LedgerDLQTopic = "ledger.dlq"
+	// LedgerRetryTopic is where requeued events go back to. Only the ledger
+	// consumes it, so other consumers of the original topic don't see them
+	// twice.
+	LedgerRetryTopic = "ledger.retry"
+	// LedgerRequeueGroup is the consumer group requeueing the DLQ; its lag
+	// is the DLQ depth
+	LedgerRequeueGroup = "ledger-dlq-requeue"
+)
+
+// StartDLQMonitor reports the DLQ depth every interval until the context is
+// cancelled
+func StartDLQMonitor(ctx context.Context, brokers []string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			depth, err := messaging.ConsumerLag(ctx, brokers, LedgerDLQTopic, LedgerRequeueGroup)
+			if err != nil {
+				log.Printf("Failed to get DLQ depth: %v", err)
+				continue
+			}
+			DLQDepth.Set(float64(depth))
+		}
+	}
+}
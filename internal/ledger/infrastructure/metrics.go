@@ -21,6 +21,21 @@ var (
 		Name: "ledger_outbox_lag_total",
 		Help: "Current number of unprocessed events in the outbox.",
 	})
+
+	UnbalancedTransactions = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ledger_unbalanced_transactions",
+		Help: "Number of transactions whose entries did not sum to zero at the last balance check.",
+	})
+
+	DeadLetteredMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ledger_dlq_messages_total",
+		Help: "Total number of events the ledger consumer sent to the DLQ after exhausting retries.",
+	})
+
+	DLQDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ledger_dlq_depth",
+		Help: "Number of messages in the ledger DLQ not yet requeued.",
+	})
 )
 
 type PrometheusMetrics struct{}
@@ -29,6 +44,10 @@ func (m *PrometheusMetrics) RecordTransaction(status string) {
 	TransactionsRecorded.WithLabelValues(status).Inc()
 }
 
+func (m *PrometheusMetrics) RecordUnbalancedTransactions(count int) {
+	UnbalancedTransactions.Set(float64(count))
+}
+
 func (m *PrometheusMetrics) StartTimer() *prometheus.Timer {
 	return prometheus.NewTimer(TransactionLatency)
 }
@@ -0,0 +1,59 @@
+package infrastructure
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/ledger/domain"
+)
+
+// BalanceSnapshotter periodically snapshots the balances of accounts with
+// new entries, so balance reads only sum the entries since the last
+// snapshot. Snapshots are taken lag behind now: a transaction still in
+// flight has its entries timestamped when it began, and would otherwise
+// commit behind a snapshot already taken and be counted by neither the
+// snapshot nor the entries read on top of it.
+type BalanceSnapshotter struct {
+	repo     domain.Repository
+	interval time.Duration
+	lag      time.Duration
+}
+
+func NewBalanceSnapshotter(repo domain.Repository, interval, lag time.Duration) *BalanceSnapshotter {
+	return &BalanceSnapshotter{
+		repo:     repo,
+		interval: interval,
+		lag:      lag,
+	}
+}
+
+func (s *BalanceSnapshotter) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	log.Printf("Balance Snapshotter started (every %v, %v behind)", s.interval, s.lag)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Snapshot(ctx)
+		}
+	}
+}
+
+// Snapshot records the balances of the accounts with entries since their
+// last snapshot
+func (s *BalanceSnapshotter) Snapshot(ctx context.Context) {
+	asOf := time.Now().Add(-s.lag)
+	count, err := s.repo.CreateBalanceSnapshots(ctx, asOf)
+	if err != nil {
+		log.Printf("Failed to snapshot balances: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("Snapshotted %d account balances as of %s", count, asOf.UTC().Format(time.RFC3339))
+	}
+}
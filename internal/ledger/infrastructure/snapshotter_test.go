@@ -0,0 +1,122 @@
+package infrastructure
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/ledger/domain"
+)
+
+// snapshotLedger keeps an account's entries and balance snapshots the way
+// the balance_snapshots queries read them: a snapshot covers the entries up
+// to and including its as_of, and a balance is the latest snapshot plus the
+// entries after it
+type snapshotLedger struct {
+	entries   []domain.Entry
+	snapshots []balanceSnapshot // Oldest first
+}
+
+type balanceSnapshot struct {
+	asOf    time.Time
+	balance int64
+}
+
+func (l *snapshotLedger) post(amount int64, createdAt time.Time) {
+	l.entries = append(l.entries, domain.Entry{Amount: amount, CreatedAt: createdAt})
+}
+
+func (l *snapshotLedger) latest() (time.Time, int64) {
+	if len(l.snapshots) == 0 {
+		return time.Time{}, 0
+	}
+	s := l.snapshots[len(l.snapshots)-1]
+	return s.asOf, s.balance
+}
+
+func (l *snapshotLedger) createSnapshots(ctx context.Context, asOf time.Time) (int, error) {
+	since, balance := l.latest()
+	changed := false
+	for _, e := range l.entries {
+		if e.CreatedAt.After(since) && !e.CreatedAt.After(asOf) {
+			balance += e.Amount
+			changed = true
+		}
+	}
+	if !changed {
+		return 0, nil
+	}
+	l.snapshots = append(l.snapshots, balanceSnapshot{asOf: asOf, balance: balance})
+	return 1, nil
+}
+
+func (l *snapshotLedger) balance() int64 {
+	since, balance := l.latest()
+	for _, e := range l.entries {
+		if e.CreatedAt.After(since) {
+			balance += e.Amount
+		}
+	}
+	return balance
+}
+
+func (l *snapshotLedger) sum() int64 {
+	var sum int64
+	for _, e := range l.entries {
+		sum += e.Amount
+	}
+	return sum
+}
+
+func TestBalanceSnapshotter_Snapshot(t *testing.T) {
+	ledger := &snapshotLedger{}
+	var asOfs []time.Time
+	repo := &domain.MockRepository{
+		CreateBalanceSnapshotsFunc: func(ctx context.Context, asOf time.Time) (int, error) {
+			asOfs = append(asOfs, asOf)
+			return ledger.createSnapshots(ctx, asOf)
+		},
+	}
+	snapshotter := NewBalanceSnapshotter(repo, time.Minute, 5*time.Second)
+	now := time.Now()
+
+	ledger.post(1000, now.Add(-time.Hour))
+	ledger.post(-300, now.Add(-time.Minute))
+	// Still in flight: timestamped when its transaction began, committed
+	// only after the snapshot
+	inFlight := now.Add(-2 * time.Second)
+
+	snapshotter.Snapshot(context.Background())
+	if len(asOfs) != 1 {
+		t.Fatalf("Expected one snapshot, got %d", len(asOfs))
+	}
+	if asOfs[0].Before(now.Add(-5*time.Second)) || asOfs[0].After(time.Now().Add(-5*time.Second)) {
+		t.Errorf("Expected the snapshot taken 5s behind now, got %s behind", now.Sub(asOfs[0]))
+	}
+	if ledger.snapshots[0].balance != 700 {
+		t.Errorf("Expected a snapshot of 700, got %d", ledger.snapshots[0].balance)
+	}
+
+	ledger.post(50, inFlight)
+	if ledger.balance() != ledger.sum() {
+		t.Errorf("Expected the balance to equal the entries' sum %d across the snapshot, got %d", ledger.sum(), ledger.balance())
+	}
+
+	// A snapshot on top of the last covers only the entries since
+	ledger.post(-100, time.Now())
+	time.Sleep(10 * time.Millisecond)
+	snapshotter.lag = 0
+	snapshotter.Snapshot(context.Background())
+	if len(ledger.snapshots) != 2 {
+		t.Fatalf("Expected a second snapshot, got %d", len(ledger.snapshots))
+	}
+	if ledger.balance() != ledger.sum() || ledger.snapshots[1].balance != ledger.sum() {
+		t.Errorf("Expected the snapshot and balance to equal the entries' sum %d, got %d and %d", ledger.sum(), ledger.snapshots[1].balance, ledger.balance())
+	}
+
+	// Nothing new, nothing to snapshot
+	snapshotter.Snapshot(context.Background())
+	if len(ledger.snapshots) != 2 {
+		t.Errorf("Expected no snapshot without new entries, got %d", len(ledger.snapshots))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/sapliy/fintech-ecosystem/internal/ledger/domain"
 )
@@ -19,8 +20,8 @@ func NewSQLRepository(db *sql.DB) *SQLRepository {
 
 func (r *SQLRepository) CreateAccount(ctx context.Context, acc *domain.Account) error {
 	err := r.db.QueryRowContext(ctx,
-		`INSERT INTO accounts (name, type, currency, user_id, zone_id, mode) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
-		acc.Name, acc.Type, acc.Currency, acc.UserID, acc.ZoneID, acc.Mode).Scan(&acc.ID, &acc.CreatedAt)
+		`INSERT INTO accounts (name, type, currency, user_id, zone_id, mode, code, parent_id) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8) RETURNING id, created_at`,
+		acc.Name, acc.Type, acc.Currency, acc.UserID, acc.ZoneID, acc.Mode, acc.Code, acc.ParentID).Scan(&acc.ID, &acc.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create account: %w", err)
 	}
@@ -30,18 +31,54 @@ func (r *SQLRepository) CreateAccount(ctx context.Context, acc *domain.Account)
 func (r *SQLRepository) GetAccount(ctx context.Context, id string) (*domain.Account, error) {
 	acc := &domain.Account{}
 	err := r.db.QueryRowContext(ctx,
-		`SELECT a.id, a.name, a.type, a.currency, COALESCE(SUM(e.amount), 0) as balance, a.user_id, a.created_at, a.zone_id, a.mode 
-		 FROM accounts a 
-		 LEFT JOIN entries e ON a.id = e.account_id 
-		 WHERE a.id = $1 
-		 GROUP BY a.id, a.zone_id, a.mode`,
-		id).Scan(&acc.ID, &acc.Name, &acc.Type, &acc.Currency, &acc.Balance, &acc.UserID, &acc.CreatedAt, &acc.ZoneID, &acc.Mode)
+		`SELECT id, name, type, currency, user_id, created_at, zone_id, mode, COALESCE(code, ''), parent_id,
+		        EXISTS (SELECT 1 FROM accounts c WHERE c.parent_id = accounts.id)
+		 FROM accounts WHERE id = $1`,
+		id).Scan(&acc.ID, &acc.Name, &acc.Type, &acc.Currency, &acc.UserID, &acc.CreatedAt, &acc.ZoneID, &acc.Mode, &acc.Code, &acc.ParentID, &acc.HasChildren)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get account: %w", err)
 	}
+
+	// Each balance is its latest snapshot plus the entries since. Entries
+	// written before entries had a currency are in the account's.
+	rows, err := r.db.QueryContext(ctx,
+		`WITH latest AS (
+			SELECT DISTINCT ON (currency) currency, as_of, balance
+			FROM balance_snapshots
+			WHERE account_id = $1
+			ORDER BY currency, as_of DESC
+		), delta AS (
+			SELECT COALESCE(e.currency, $2) AS currency, SUM(e.amount) AS amount
+			FROM entries e
+			LEFT JOIN latest l ON l.currency = COALESCE(e.currency, $2)
+			WHERE e.account_id = $1 AND (l.as_of IS NULL OR e.created_at > l.as_of)
+			GROUP BY 1
+		)
+		SELECT COALESCE(l.currency, d.currency), COALESCE(l.balance, 0) + COALESCE(d.amount, 0)
+		FROM latest l
+		FULL OUTER JOIN delta d ON d.currency = l.currency`,
+		id, acc.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account balances: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	acc.Balances = map[string]int64{acc.Currency: 0}
+	for rows.Next() {
+		var code string
+		var balance int64
+		if err := rows.Scan(&code, &balance); err != nil {
+			return nil, fmt.Errorf("failed to get account balances: %w", err)
+		}
+		acc.Balances[code] = balance
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get account balances: %w", err)
+	}
+	acc.Balance = acc.Balances[acc.Currency]
 	return acc, nil
 }
 
@@ -85,15 +122,15 @@ type sqlTxContext struct {
 func (c *sqlTxContext) CreateTransaction(ctx context.Context, tx *domain.Transaction) (string, error) {
 	var id string
 	err := c.tx.QueryRowContext(ctx,
-		`INSERT INTO transactions (reference_id, description, zone_id, mode) VALUES ($1, $2, $3, $4) RETURNING id`,
-		tx.ReferenceID, tx.Description, tx.ZoneID, tx.Mode).Scan(&id)
+		`INSERT INTO transactions (reference_id, description, zone_id, mode, effective_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		tx.ReferenceID, tx.Description, tx.ZoneID, tx.Mode, tx.EffectiveAt).Scan(&id)
 	return id, err
 }
 
 func (c *sqlTxContext) CreateEntry(ctx context.Context, entry *domain.Entry) error {
 	_, err := c.tx.ExecContext(ctx,
-		`INSERT INTO entries (transaction_id, account_id, amount, direction) VALUES ($1, $2, $3, $4)`,
-		entry.TransactionID, entry.AccountID, entry.Amount, entry.Direction)
+		`INSERT INTO entries (transaction_id, account_id, amount, currency, direction) VALUES ($1, $2, $3, $4, $5)`,
+		entry.TransactionID, entry.AccountID, entry.Amount, entry.Currency, entry.Direction)
 	return err
 }
 
@@ -116,6 +153,71 @@ func (c *sqlTxContext) CreateOutboxEvent(ctx context.Context, eventType string,
 	return err
 }
 
+// CaptureHold marks an active hold captured by the transaction, reporting
+// whether it was still active
+func (c *sqlTxContext) CaptureHold(ctx context.Context, holdID, transactionID string, amount int64) (bool, error) {
+	res, err := c.tx.ExecContext(ctx,
+		`UPDATE holds SET status = 'captured', captured_amount = $1, transaction_id = $2, updated_at = NOW()
+		 WHERE id = $3 AND status = 'active' AND expires_at > NOW()`,
+		amount, transactionID, holdID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// CreateReversal records the reversal, reporting false when the transaction
+// was already reversed
+func (c *sqlTxContext) CreateReversal(ctx context.Context, reversal *domain.Reversal) (bool, error) {
+	err := c.tx.QueryRowContext(ctx,
+		`INSERT INTO transaction_reversals (transaction_id, reversal_id, reason, actor) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (transaction_id) DO NOTHING
+		 RETURNING created_at`,
+		reversal.TransactionID, reversal.ReversalID, reversal.Reason, reversal.Actor).Scan(&reversal.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetClosedPeriod returns the period if it is closed. It locks out closing
+// periods until the transaction ends, so a period cannot close between the
+// check and the entries being committed.
+func (c *sqlTxContext) GetClosedPeriod(ctx context.Context, period string) (*domain.Period, error) {
+	if _, err := c.tx.ExecContext(ctx, `LOCK TABLE accounting_periods IN SHARE MODE`); err != nil {
+		return nil, err
+	}
+	return scanPeriod(c.tx.QueryRowContext(ctx, `SELECT `+periodColumns+` FROM accounting_periods WHERE period = $1`, period))
+}
+
+// GetProcessedEvent returns the transaction recorded for the event, or ""
+// if it was not processed
+func (c *sqlTxContext) GetProcessedEvent(ctx context.Context, eventID string) (string, error) {
+	var transactionID string
+	err := c.tx.QueryRowContext(ctx,
+		`SELECT transaction_id FROM processed_events WHERE event_id = $1`, eventID).Scan(&transactionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return transactionID, err
+}
+
+// CreateProcessedEvent marks the event processed, reporting false when it
+// already was. A concurrent insert of the same event waits for the other
+// transaction to end.
+func (c *sqlTxContext) CreateProcessedEvent(ctx context.Context, eventID, transactionID string) (bool, error) {
+	res, err := c.tx.ExecContext(ctx,
+		`INSERT INTO processed_events (event_id, transaction_id) VALUES ($1, $2)
+		 ON CONFLICT (event_id) DO NOTHING`,
+		eventID, transactionID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
 func (c *sqlTxContext) Commit() error {
 	return c.tx.Commit()
 }
@@ -124,11 +226,20 @@ func (c *sqlTxContext) Rollback() error {
 	return c.tx.Rollback()
 }
 
+const transactionColumns = `t.id, t.reference_id, t.description, t.zone_id, t.mode,
+	COALESCE(rb.reversal_id::text, ''), COALESCE(ro.transaction_id::text, ''), COALESCE(t.effective_at, t.created_at), t.created_at`
+
+// transactionReversalJoins joins the reversal of a transaction, as rb, and
+// the reversal it is, as ro
+const transactionReversalJoins = `
+	LEFT JOIN transaction_reversals rb ON rb.transaction_id = t.id
+	LEFT JOIN transaction_reversals ro ON ro.reversal_id = t.id`
+
 func (r *SQLRepository) ListTransactions(ctx context.Context, zoneID string, limit int) ([]domain.TransactionWithEntries, error) {
-	query := `SELECT id, reference_id, description, zone_id, mode, created_at 
-			  FROM transactions 
-			  WHERE ($1 = '' OR zone_id = $1) 
-			  ORDER BY created_at DESC 
+	query := `SELECT ` + transactionColumns + `
+			  FROM transactions t` + transactionReversalJoins + `
+			  WHERE ($1 = '' OR t.zone_id = $1)
+			  ORDER BY t.created_at DESC
 			  LIMIT $2`
 
 	rows, err := r.db.QueryContext(ctx, query, zoneID, limit)
@@ -140,7 +251,7 @@ func (r *SQLRepository) ListTransactions(ctx context.Context, zoneID string, lim
 	var txs []domain.TransactionWithEntries
 	for rows.Next() {
 		var tx domain.TransactionWithEntries
-		if err := rows.Scan(&tx.ID, &tx.ReferenceID, &tx.Description, &tx.ZoneID, &tx.Mode, &tx.CreatedAt); err != nil {
+		if err := rows.Scan(&tx.ID, &tx.ReferenceID, &tx.Description, &tx.ZoneID, &tx.Mode, &tx.ReversedBy, &tx.ReversalOf, &tx.EffectiveAt, &tx.CreatedAt); err != nil {
 			return nil, err
 		}
 
@@ -158,8 +269,8 @@ func (r *SQLRepository) ListTransactions(ctx context.Context, zoneID string, lim
 func (r *SQLRepository) GetTransaction(ctx context.Context, id string) (*domain.TransactionWithEntries, error) {
 	tx := &domain.TransactionWithEntries{}
 	err := r.db.QueryRowContext(ctx,
-		`SELECT id, reference_id, description, zone_id, mode, created_at FROM transactions WHERE id = $1`,
-		id).Scan(&tx.ID, &tx.ReferenceID, &tx.Description, &tx.ZoneID, &tx.Mode, &tx.CreatedAt)
+		`SELECT `+transactionColumns+` FROM transactions t`+transactionReversalJoins+` WHERE t.id = $1`,
+		id).Scan(&tx.ID, &tx.ReferenceID, &tx.Description, &tx.ZoneID, &tx.Mode, &tx.ReversedBy, &tx.ReversalOf, &tx.EffectiveAt, &tx.CreatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -177,7 +288,10 @@ func (r *SQLRepository) GetTransaction(ctx context.Context, id string) (*domain.
 
 func (r *SQLRepository) getTransactionEntries(ctx context.Context, txID string) ([]domain.Entry, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, transaction_id, account_id, amount, direction, created_at FROM entries WHERE transaction_id = $1`,
+		`SELECT e.id, e.transaction_id, e.account_id, e.amount, COALESCE(e.currency, a.currency), e.direction, e.created_at
+		 FROM entries e
+		 JOIN accounts a ON a.id = e.account_id
+		 WHERE e.transaction_id = $1`,
 		txID)
 	if err != nil {
 		return nil, err
@@ -187,10 +301,391 @@ func (r *SQLRepository) getTransactionEntries(ctx context.Context, txID string)
 	var entries []domain.Entry
 	for rows.Next() {
 		var e domain.Entry
-		if err := rows.Scan(&e.ID, &e.TransactionID, &e.AccountID, &e.Amount, &e.Direction, &e.CreatedAt); err != nil {
+		if err := rows.Scan(&e.ID, &e.TransactionID, &e.AccountID, &e.Amount, &e.Currency, &e.Direction, &e.CreatedAt); err != nil {
 			return nil, err
 		}
 		entries = append(entries, e)
 	}
 	return entries, nil
 }
+
+// ListAccountEntries returns an account's entries in posting order, with the
+// reference and description of their transactions
+func (r *SQLRepository) ListAccountEntries(ctx context.Context, filter domain.EntryFilter) ([]domain.StatementEntry, error) {
+	query := `SELECT e.id, e.transaction_id, e.account_id, e.amount, COALESCE(e.currency, a.currency), e.direction, e.created_at, t.reference_id, COALESCE(t.description, '')
+			  FROM entries e
+			  JOIN transactions t ON t.id = e.transaction_id
+			  JOIN accounts a ON a.id = e.account_id
+			  WHERE e.account_id = $1 AND COALESCE(e.currency, a.currency) = $2`
+	args := []interface{}{filter.AccountID, filter.Currency}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if !filter.From.IsZero() {
+		query += " AND e.created_at >= " + arg(filter.From)
+	}
+	if !filter.To.IsZero() {
+		query += " AND e.created_at < " + arg(filter.To)
+	}
+	if filter.After != nil {
+		query += fmt.Sprintf(" AND (e.created_at, e.id::text) > (%s, %s)", arg(filter.After.CreatedAt), arg(filter.After.ID))
+	}
+	query += " ORDER BY e.created_at, e.id::text LIMIT " + arg(filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []domain.StatementEntry
+	for rows.Next() {
+		var e domain.StatementEntry
+		if err := rows.Scan(&e.ID, &e.TransactionID, &e.AccountID, &e.Amount, &e.Currency, &e.Direction, &e.CreatedAt, &e.ReferenceID, &e.Description); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetBalanceAt sums an account's entries in a currency up to and including
+// the entry at pos, or those posted before pos.CreatedAt when pos has no ID.
+// It starts from the latest snapshot taken before pos.
+func (r *SQLRepository) GetBalanceAt(ctx context.Context, accountID, currency string, pos domain.EntryPosition) (int64, error) {
+	var balance int64
+	err := r.db.QueryRowContext(ctx,
+		`WITH latest AS (
+			SELECT as_of, balance
+			FROM balance_snapshots
+			WHERE account_id = $1 AND currency = $2 AND as_of < $3
+			ORDER BY as_of DESC
+			LIMIT 1
+		)
+		SELECT COALESCE((SELECT balance FROM latest), 0) + COALESCE(SUM(e.amount), 0)
+		FROM entries e
+		JOIN accounts a ON a.id = e.account_id
+		WHERE e.account_id = $1 AND COALESCE(e.currency, a.currency) = $2
+		  AND e.created_at > COALESCE((SELECT as_of FROM latest), '-infinity')
+		  AND (e.created_at < $3 OR ($4 <> '' AND e.created_at = $3 AND e.id::text <= $4))`,
+		accountID, currency, pos.CreatedAt, pos.ID).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+	return balance, nil
+}
+
+// ListAccountBalances sums each account's debits and credits posted before
+// the given time, per currency the account holds
+func (r *SQLRepository) ListAccountBalances(ctx context.Context, before time.Time, zoneID string) ([]domain.AccountBalance, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT a.id, a.name, a.type, COALESCE(e.currency, a.currency),
+		        COALESCE(SUM(e.amount) FILTER (WHERE e.amount > 0), 0),
+		        COALESCE(-SUM(e.amount) FILTER (WHERE e.amount < 0), 0)
+		 FROM accounts a
+		 LEFT JOIN entries e ON e.account_id = a.id AND e.created_at < $1
+		 WHERE ($2 = '' OR a.zone_id = $2)
+		 GROUP BY a.id, COALESCE(e.currency, a.currency)
+		 ORDER BY a.type, a.name, 4`,
+		before, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var balances []domain.AccountBalance
+	for rows.Next() {
+		var b domain.AccountBalance
+		if err := rows.Scan(&b.AccountID, &b.Name, &b.Type, &b.Currency, &b.Debits, &b.Credits); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+// GetAccountByCode returns the account with the code in the zone and mode,
+// without its balances
+func (r *SQLRepository) GetAccountByCode(ctx context.Context, zoneID, mode, code string) (*domain.Account, error) {
+	acc := &domain.Account{}
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, name, type, currency, user_id, created_at, zone_id, mode, code, parent_id
+		 FROM accounts WHERE zone_id = $1 AND mode = $2 AND code = $3`,
+		zoneID, mode, code).Scan(&acc.ID, &acc.Name, &acc.Type, &acc.Currency, &acc.UserID, &acc.CreatedAt, &acc.ZoneID, &acc.Mode, &acc.Code, &acc.ParentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get account by code: %w", err)
+	}
+	return acc, nil
+}
+
+func (r *SQLRepository) AccountHasEntries(ctx context.Context, id string) (bool, error) {
+	var posted bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM entries WHERE account_id = $1)`, id).Scan(&posted)
+	if err != nil {
+		return false, fmt.Errorf("failed to check account entries: %w", err)
+	}
+	return posted, nil
+}
+
+// ListChartAccounts returns the accounts of the zone and mode with their
+// posted balances, for building the chart of accounts
+func (r *SQLRepository) ListChartAccounts(ctx context.Context, zoneID, mode string) ([]domain.Account, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT a.id, a.name, a.type, a.currency, a.user_id, a.created_at, a.zone_id, a.mode, COALESCE(a.code, ''), a.parent_id,
+		        COALESCE(e.currency, a.currency), COALESCE(SUM(e.amount), 0)
+		 FROM accounts a
+		 LEFT JOIN entries e ON e.account_id = a.id
+		 WHERE ($1 = '' OR a.zone_id = $1) AND ($2 = '' OR a.mode = $2)
+		 GROUP BY a.id, COALESCE(e.currency, a.currency)
+		 ORDER BY a.id`,
+		zoneID, mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var accounts []domain.Account
+	for rows.Next() {
+		var acc domain.Account
+		var code string
+		var balance int64
+		if err := rows.Scan(&acc.ID, &acc.Name, &acc.Type, &acc.Currency, &acc.UserID, &acc.CreatedAt, &acc.ZoneID, &acc.Mode, &acc.Code, &acc.ParentID, &code, &balance); err != nil {
+			return nil, fmt.Errorf("failed to list accounts: %w", err)
+		}
+		// Rows come grouped by account, one per currency
+		if n := len(accounts); n > 0 && accounts[n-1].ID == acc.ID {
+			accounts[n-1].Balances[code] = balance
+		} else {
+			acc.Balances = map[string]int64{acc.Currency: 0, code: balance}
+			accounts = append(accounts, acc)
+		}
+		last := &accounts[len(accounts)-1]
+		last.Balance = last.Balances[last.Currency]
+		last.PostedBalance = last.Balance
+	}
+	return accounts, rows.Err()
+}
+
+// ListUnbalancedTransactions returns the most recent transactions whose
+// entries in some currency do not sum to zero
+func (r *SQLRepository) ListUnbalancedTransactions(ctx context.Context, limit int) ([]domain.UnbalancedTransaction, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT t.id, t.reference_id, COALESCE(t.description, ''), COALESCE(e.currency, a.currency), SUM(e.amount), COUNT(e.id), t.created_at
+		 FROM transactions t
+		 JOIN entries e ON e.transaction_id = t.id
+		 JOIN accounts a ON a.id = e.account_id
+		 GROUP BY t.id, COALESCE(e.currency, a.currency)
+		 HAVING SUM(e.amount) <> 0
+		 ORDER BY t.created_at DESC
+		 LIMIT $1`,
+		limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var txs []domain.UnbalancedTransaction
+	for rows.Next() {
+		var tx domain.UnbalancedTransaction
+		if err := rows.Scan(&tx.ID, &tx.ReferenceID, &tx.Description, &tx.Currency, &tx.Imbalance, &tx.EntryCount, &tx.CreatedAt); err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return txs, rows.Err()
+}
+
+// CreateBalanceSnapshots snapshots, as of the given time, the balance of
+// every account and currency with entries since its latest snapshot. It
+// returns how many snapshots were taken.
+func (r *SQLRepository) CreateBalanceSnapshots(ctx context.Context, asOf time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx,
+		`WITH latest AS (
+			SELECT DISTINCT ON (account_id, currency) account_id, currency, as_of, balance
+			FROM balance_snapshots
+			ORDER BY account_id, currency, as_of DESC
+		), delta AS (
+			SELECT e.account_id, COALESCE(e.currency, a.currency) AS currency, SUM(e.amount) AS amount
+			FROM entries e
+			JOIN accounts a ON a.id = e.account_id
+			LEFT JOIN latest l ON l.account_id = e.account_id AND l.currency = COALESCE(e.currency, a.currency)
+			WHERE e.created_at <= $1 AND (l.as_of IS NULL OR e.created_at > l.as_of)
+			GROUP BY 1, 2
+		)
+		INSERT INTO balance_snapshots (account_id, currency, as_of, balance)
+		SELECT d.account_id, d.currency, $1, COALESCE(l.balance, 0) + d.amount
+		FROM delta d
+		LEFT JOIN latest l ON l.account_id = d.account_id AND l.currency = d.currency
+		ON CONFLICT (account_id, currency, as_of) DO NOTHING`,
+		asOf)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create balance snapshots: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+const holdColumns = `id, account_id, amount, currency, reference_id, COALESCE(description, ''), status, captured_amount, COALESCE(transaction_id::text, ''), expires_at, created_at, updated_at`
+
+func (r *SQLRepository) CreateHold(ctx context.Context, hold *domain.Hold) error {
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO holds (account_id, amount, currency, reference_id, description, status, expires_at, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		hold.AccountID, hold.Amount, hold.Currency, hold.ReferenceID, hold.Description, hold.Status, hold.ExpiresAt, hold.CreatedAt, hold.UpdatedAt).Scan(&hold.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create hold: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) GetHold(ctx context.Context, id string) (*domain.Hold, error) {
+	return r.getHold(ctx, `SELECT `+holdColumns+` FROM holds WHERE id = $1`, id)
+}
+
+func (r *SQLRepository) GetHoldByReference(ctx context.Context, referenceID string) (*domain.Hold, error) {
+	return r.getHold(ctx, `SELECT `+holdColumns+` FROM holds WHERE reference_id = $1`, referenceID)
+}
+
+func (r *SQLRepository) getHold(ctx context.Context, query string, arg string) (*domain.Hold, error) {
+	var h domain.Hold
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(&h.ID, &h.AccountID, &h.Amount, &h.Currency, &h.ReferenceID, &h.Description,
+		&h.Status, &h.CapturedAmount, &h.TransactionID, &h.ExpiresAt, &h.CreatedAt, &h.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get hold: %w", err)
+	}
+	return &h, nil
+}
+
+// ListActiveHolds returns the account's holds not yet captured, released or
+// expired at the given time, soonest to expire first
+func (r *SQLRepository) ListActiveHolds(ctx context.Context, accountID string, at time.Time) ([]domain.Hold, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+holdColumns+` FROM holds WHERE account_id = $1 AND status = 'active' AND expires_at > $2 ORDER BY expires_at`,
+		accountID, at)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var holds []domain.Hold
+	for rows.Next() {
+		var h domain.Hold
+		if err := rows.Scan(&h.ID, &h.AccountID, &h.Amount, &h.Currency, &h.ReferenceID, &h.Description,
+			&h.Status, &h.CapturedAmount, &h.TransactionID, &h.ExpiresAt, &h.CreatedAt, &h.UpdatedAt); err != nil {
+			return nil, err
+		}
+		holds = append(holds, h)
+	}
+	return holds, rows.Err()
+}
+
+// GetHeldAmount sums the account's holds active at the given time
+func (r *SQLRepository) GetHeldAmount(ctx context.Context, accountID string, at time.Time) (int64, error) {
+	var held int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM holds WHERE account_id = $1 AND status = 'active' AND expires_at > $2`,
+		accountID, at).Scan(&held)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get held amount: %w", err)
+	}
+	return held, nil
+}
+
+// ReleaseHold releases an active hold, reporting whether it was active
+func (r *SQLRepository) ReleaseHold(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE holds SET status = 'released', updated_at = NOW() WHERE id = $1 AND status = 'active' AND expires_at > NOW()`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ExpireHolds marks the active holds past their expiry as expired
+func (r *SQLRepository) ExpireHolds(ctx context.Context, at time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE holds SET status = 'expired', updated_at = NOW() WHERE status = 'active' AND expires_at <= $1`, at)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// DeleteProcessedEvents forgets the events processed before the time
+func (r *SQLRepository) DeleteProcessedEvents(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM processed_events WHERE processed_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+const periodColumns = `period, COALESCE(adjustment_account_id::text, ''), closed_by, closed_at`
+
+// ClosePeriod records the period as closed, reporting false when it already
+// was
+func (r *SQLRepository) ClosePeriod(ctx context.Context, period *domain.Period) (bool, error) {
+	var adjustmentAccountID *string
+	if period.AdjustmentAccountID != "" {
+		adjustmentAccountID = &period.AdjustmentAccountID
+	}
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO accounting_periods (period, adjustment_account_id, closed_by) VALUES ($1, $2, $3)
+		 ON CONFLICT (period) DO NOTHING
+		 RETURNING closed_at`,
+		period.Period, adjustmentAccountID, period.ClosedBy).Scan(&period.ClosedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to close period: %w", err)
+	}
+	return true, nil
+}
+
+func (r *SQLRepository) GetPeriod(ctx context.Context, period string) (*domain.Period, error) {
+	return scanPeriod(r.db.QueryRowContext(ctx, `SELECT `+periodColumns+` FROM accounting_periods WHERE period = $1`, period))
+}
+
+func (r *SQLRepository) ListPeriods(ctx context.Context) ([]domain.Period, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+periodColumns+` FROM accounting_periods ORDER BY period DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var periods []domain.Period
+	for rows.Next() {
+		var p domain.Period
+		if err := rows.Scan(&p.Period, &p.AdjustmentAccountID, &p.ClosedBy, &p.ClosedAt); err != nil {
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
+
+func scanPeriod(row *sql.Row) (*domain.Period, error) {
+	var p domain.Period
+	if err := row.Scan(&p.Period, &p.AdjustmentAccountID, &p.ClosedBy, &p.ClosedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get period: %w", err)
+	}
+	return &p, nil
+}
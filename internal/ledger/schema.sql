@@ -8,6 +8,14 @@ CREATE TABLE IF NOT EXISTS accounts (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- The chart of accounts: codes such as 1000 and 1100, and parents whose
+-- balances roll up their sub-accounts'. Only accounts without sub-accounts
+-- are posted to.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS zone_id TEXT;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS mode TEXT;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS code VARCHAR(20);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES accounts(id);
+
 CREATE TABLE IF NOT EXISTS transactions (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     reference_id VARCHAR(255) UNIQUE NOT NULL, -- Idempotency key / Reference to external event
@@ -15,15 +23,76 @@ CREATE TABLE IF NOT EXISTS transactions (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- The accounting date of backdated transactions, e.g. adjustments to a
+-- closed period. NULL means created_at.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS effective_at TIMESTAMP WITH TIME ZONE;
+
 CREATE TABLE IF NOT EXISTS entries (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     transaction_id UUID NOT NULL REFERENCES transactions(id),
     account_id UUID NOT NULL REFERENCES accounts(id),
     amount BIGINT NOT NULL CHECK (amount != 0), -- Must be positive for Debit, Negative for Credit
+    currency VARCHAR(3), -- NULL on entries written before accounts held several currencies: the account's
     direction VARCHAR(10) NOT NULL, -- 'debit' or 'credit'
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+ALTER TABLE entries ADD COLUMN IF NOT EXISTS currency VARCHAR(3);
+
+-- Balances as of a point in time, so reads only sum the entries since.
+-- Entries are immutable, so a snapshot never goes stale.
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    currency VARCHAR(3) NOT NULL,
+    as_of TIMESTAMP WITH TIME ZONE NOT NULL,
+    balance BIGINT NOT NULL,
+    PRIMARY KEY (account_id, currency, as_of)
+);
+
+-- Reservations of account balances, e.g. for card authorizations. Active
+-- holds count towards available balances until captured, released or expired.
+CREATE TABLE IF NOT EXISTS holds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    amount BIGINT NOT NULL CHECK (amount != 0), -- Signed like the entry capturing it posts
+    currency VARCHAR(3) NOT NULL,
+    reference_id VARCHAR(255) UNIQUE NOT NULL,
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'active', -- active, released, captured, expired
+    captured_amount BIGINT NOT NULL DEFAULT 0,
+    transaction_id UUID REFERENCES transactions(id),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Transactions are immutable, so reversing one records the link to its
+-- compensating transaction here. The primary key reverses a transaction once.
+CREATE TABLE IF NOT EXISTS transaction_reversals (
+    transaction_id UUID PRIMARY KEY REFERENCES transactions(id),
+    reversal_id UUID UNIQUE NOT NULL REFERENCES transactions(id),
+    reason TEXT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Closed accounting periods (YYYY-MM, UTC). Transactions dated within one
+-- are refused unless they post to its adjustment account. Closing is final.
+CREATE TABLE IF NOT EXISTS accounting_periods (
+    period CHAR(7) PRIMARY KEY,
+    adjustment_account_id UUID REFERENCES accounts(id),
+    closed_by VARCHAR(255) NOT NULL,
+    closed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Consumed events, so an event is posted once however often it is
+-- delivered. Rows older than the retention are deleted.
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id VARCHAR(255) PRIMARY KEY,
+    transaction_id UUID NOT NULL REFERENCES transactions(id),
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
 CREATE TABLE IF NOT EXISTS outbox (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     event_type VARCHAR(255) NOT NULL,
@@ -62,11 +131,26 @@ CREATE TRIGGER trg_immutable_entries
 BEFORE UPDATE OR DELETE ON entries
 FOR EACH ROW EXECUTE FUNCTION prevent_mutation();
 
+CREATE TRIGGER trg_immutable_transaction_reversals
+BEFORE UPDATE OR DELETE ON transaction_reversals
+FOR EACH ROW EXECUTE FUNCTION prevent_mutation();
+
+CREATE TRIGGER trg_immutable_accounting_periods
+BEFORE UPDATE OR DELETE ON accounting_periods
+FOR EACH ROW EXECUTE FUNCTION prevent_mutation();
+
 CREATE TRIGGER trg_immutable_outbox
 BEFORE UPDATE OR DELETE ON outbox
 FOR EACH ROW EXECUTE FUNCTION prevent_outbox_mutation();
 
 CREATE INDEX IF NOT EXISTS idx_accounts_user_id ON accounts(user_id);
 CREATE INDEX IF NOT EXISTS idx_accounts_org_id ON accounts(org_id);
+CREATE INDEX IF NOT EXISTS idx_accounts_parent_id ON accounts(parent_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_zone_code ON accounts(zone_id, mode, code) WHERE code IS NOT NULL;
 CREATE INDEX IF NOT EXISTS idx_entries_transaction_id ON entries(transaction_id);
 CREATE INDEX IF NOT EXISTS idx_entries_account_id ON entries(account_id);
+CREATE INDEX IF NOT EXISTS idx_entries_created_at ON entries(created_at);
+CREATE INDEX IF NOT EXISTS idx_holds_account_active ON holds(account_id, expires_at) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at ON processed_events(processed_at);
+-- Account statements page through entries in posting order
+CREATE INDEX IF NOT EXISTS idx_entries_account_created_at ON entries(account_id, created_at, id);
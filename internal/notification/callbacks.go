@@ -0,0 +1,162 @@
+package notification
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// callbackTolerance is how old a signed Resend callback may be, against
+// replays
+const callbackTolerance = 5 * time.Minute
+
+var ErrInvalidCallbackSignature = errors.New("invalid callback signature")
+
+// ProviderStatus is what a provider's callback says became of a message
+type ProviderStatus struct {
+	MessageID  string
+	Status     Status
+	Detail     string
+	OccurredAt time.Time
+}
+
+// resendStatuses maps Resend's webhook event types to statuses. Other
+// events, e.g. email.clicked, are not tracked.
+var resendStatuses = map[string]Status{
+	"email.sent":       StatusSent,
+	"email.delivered":  StatusDelivered,
+	"email.bounced":    StatusBounced,
+	"email.failed":     StatusFailed,
+	"email.opened":     StatusOpened,
+	"email.complained": StatusDelivered, // Delivered, then marked as spam
+}
+
+// VerifyResendSignature checks the Svix signature Resend signs its webhooks
+// with. The secret is the endpoint's signing secret, whsec_ included.
+func VerifyResendSignature(secret, id, timestamp, signatures string, body []byte, now time.Time) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil {
+		return err
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidCallbackSignature
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > callbackTolerance || d < -callbackTolerance {
+		return ErrInvalidCallbackSignature
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	for _, signature := range strings.Fields(signatures) {
+		version, sig, ok := strings.Cut(signature, ",")
+		if ok && version == "v1" && hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidCallbackSignature
+}
+
+// ParseResendEvent reads a Resend webhook. It reports false for events that
+// are not tracked.
+func ParseResendEvent(body []byte) (*ProviderStatus, bool, error) {
+	var event struct {
+		Type      string    `json:"type"`
+		CreatedAt time.Time `json:"created_at"`
+		Data      struct {
+			EmailID string `json:"email_id"`
+			Bounce  struct {
+				Message string `json:"message"`
+			} `json:"bounce"`
+			Failed struct {
+				Reason string `json:"reason"`
+			} `json:"failed"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, false, err
+	}
+	status, ok := resendStatuses[event.Type]
+	if !ok || event.Data.EmailID == "" {
+		return nil, false, nil
+	}
+	detail := event.Type
+	if msg := event.Data.Bounce.Message + event.Data.Failed.Reason; msg != "" {
+		detail += ": " + msg
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+	return &ProviderStatus{
+		MessageID:  event.Data.EmailID,
+		Status:     status,
+		Detail:     detail,
+		OccurredAt: event.CreatedAt,
+	}, true, nil
+}
+
+// twilioStatuses maps Twilio's message statuses to ours. Statuses before
+// sending, e.g. queued, are not tracked.
+var twilioStatuses = map[string]Status{
+	"sent":        StatusSent,
+	"delivered":   StatusDelivered,
+	"undelivered": StatusBounced,
+	"failed":      StatusFailed,
+	"read":        StatusOpened,
+}
+
+// VerifyTwilioSignature checks the X-Twilio-Signature of a status
+// callback: the callback URL followed by the sorted form parameters, signed
+// with the account's auth token
+func VerifyTwilioSignature(authToken, callbackURL string, form url.Values, signature string) error {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(callbackURL)
+	for _, k := range keys {
+		for _, v := range form[k] {
+			b.WriteString(k)
+			b.WriteString(v)
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(b.String()))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidCallbackSignature
+	}
+	return nil
+}
+
+// ParseTwilioStatus reads a Twilio status callback. It reports false for
+// statuses that are not tracked.
+func ParseTwilioStatus(form url.Values) (*ProviderStatus, bool) {
+	status, ok := twilioStatuses[form.Get("MessageStatus")]
+	if !ok || form.Get("MessageSid") == "" {
+		return nil, false
+	}
+	detail := form.Get("MessageStatus")
+	if code := form.Get("ErrorCode"); code != "" {
+		detail += ": error " + code
+	}
+	return &ProviderStatus{
+		MessageID:  form.Get("MessageSid"),
+		Status:     status,
+		Detail:     detail,
+		OccurredAt: time.Now().UTC(),
+	}, true
+}
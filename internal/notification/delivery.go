@@ -0,0 +1,211 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// Providers notifications are sent with
+const (
+	ProviderResend = "resend"
+	ProviderTwilio = "twilio"
+)
+
+// Kinds of delivery events
+const (
+	DeliveryAttempt    = "attempt"    // The worker tried to send the notification
+	DeliveryCallback   = "callback"   // The provider reported on it
+	DeliverySuppressed = "suppressed" // The worker held it back
+)
+
+var ErrUnknownMessage = errors.New("no notification was sent as this message")
+
+// statusRank orders statuses so callbacks arriving out of order do not move
+// a notification back, e.g. a late "delivered" after "opened"
+var statusRank = map[Status]int{
+	StatusPending:    0,
+	StatusSent:       1,
+	StatusDelivered:  2,
+	StatusBounced:    2,
+	StatusFailed:     2,
+	StatusOpened:     3,
+	StatusSuppressed: 2,
+}
+
+// DeliveryEvent is an entry of a notification's delivery timeline: an
+// attempt to send it, or a status its provider reported
+type DeliveryEvent struct {
+	ID             string    `json:"id"`
+	NotificationID string    `json:"notification_id"`
+	Kind           string    `json:"kind"`
+	Status         Status    `json:"status"`
+	Attempt        int       `json:"attempt,omitempty"`
+	Provider       string    `json:"provider,omitempty"`
+	Detail         string    `json:"detail,omitempty"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// DeliveryStore keeps notifications and their delivery events
+type DeliveryStore interface {
+	// TrackTask creates the notification of a task, or returns the one
+	// created by an earlier attempt
+	TrackTask(ctx context.Context, taskID string, n *Notification) error
+	// SetStatus moves the notification to the status; sending it records
+	// its provider and message ID
+	SetStatus(ctx context.Context, n *Notification) error
+	AddDeliveryEvent(ctx context.Context, e *DeliveryEvent) error
+	// CountAttempts returns how many attempts were made to send the
+	// notification
+	CountAttempts(ctx context.Context, notificationID string) (int, error)
+	// FindByProviderMessage returns the notification sent as the message,
+	// or nil if there is none
+	FindByProviderMessage(ctx context.Context, provider, messageID string) (*Notification, error)
+	GetByID(ctx context.Context, id string) (*Notification, error)
+	ListDeliveryEvents(ctx context.Context, notificationID string) ([]DeliveryEvent, error)
+}
+
+// DeliveryTracker records the delivery of notifications: each attempt the
+// workers make, and what providers report afterwards. A nil tracker records
+// nothing.
+type DeliveryTracker struct {
+	store DeliveryStore
+}
+
+func NewDeliveryTracker(store DeliveryStore) *DeliveryTracker {
+	return &DeliveryTracker{store: store}
+}
+
+// Begin returns the notification a task is sent as, creating it on the
+// first attempt. Tracking is best effort: on error the task is sent
+// untracked.
+func (t *DeliveryTracker) Begin(ctx context.Context, task *NotificationTask, channel Channel, title, content string) *Notification {
+	if t == nil {
+		return nil
+	}
+	n := &Notification{
+		UserID:    task.Data["UserID"],
+		Recipient: task.Recipient,
+		Channel:   channel,
+		Title:     title,
+		Content:   content,
+	}
+	if err := t.store.TrackTask(ctx, task.ID, n); err != nil {
+		log.Printf("Failed to track task %s: %v", task.ID, err)
+		return nil
+	}
+	return n
+}
+
+// Attempt records an attempt to send the notification, marking it sent
+// when it succeeded
+func (t *DeliveryTracker) Attempt(ctx context.Context, n *Notification, provider, messageID string, sendErr error) {
+	if t == nil || n == nil {
+		return
+	}
+	attempts, err := t.store.CountAttempts(ctx, n.ID)
+	if err != nil {
+		log.Printf("Failed to count attempts of notification %s: %v", n.ID, err)
+	}
+	event := &DeliveryEvent{
+		NotificationID: n.ID,
+		Kind:           DeliveryAttempt,
+		Status:         StatusSent,
+		Attempt:        attempts + 1,
+		Provider:       provider,
+		OccurredAt:     time.Now().UTC(),
+	}
+	if sendErr != nil {
+		event.Status = StatusFailed
+		event.Detail = sendErr.Error()
+	}
+	if err := t.store.AddDeliveryEvent(ctx, event); err != nil {
+		log.Printf("Failed to record attempt of notification %s: %v", n.ID, err)
+	}
+	if sendErr != nil {
+		return
+	}
+
+	n.Provider, n.ProviderMessageID = provider, messageID
+	t.advance(ctx, n, StatusSent, event.OccurredAt)
+}
+
+// Suppress records a task held back from sending, and why
+func (t *DeliveryTracker) Suppress(ctx context.Context, task *NotificationTask, channel Channel, reason string) {
+	n := t.Begin(ctx, task, channel, "", "")
+	if n == nil {
+		return
+	}
+	at := time.Now().UTC()
+	if err := t.store.AddDeliveryEvent(ctx, &DeliveryEvent{
+		NotificationID: n.ID,
+		Kind:           DeliverySuppressed,
+		Status:         StatusSuppressed,
+		Detail:         reason,
+		OccurredAt:     at,
+	}); err != nil {
+		log.Printf("Failed to record suppression of notification %s: %v", n.ID, err)
+	}
+	t.advance(ctx, n, StatusSuppressed, at)
+}
+
+// Fail marks the notification failed once its task gives up
+func (t *DeliveryTracker) Fail(ctx context.Context, n *Notification) {
+	if t == nil || n == nil {
+		return
+	}
+	t.advance(ctx, n, StatusFailed, time.Now().UTC())
+}
+
+// Callback records a status the provider reported for a message it sent,
+// failing with ErrUnknownMessage for messages no notification was sent as
+func (t *DeliveryTracker) Callback(ctx context.Context, provider, messageID string, status Status, detail string, occurredAt time.Time) (*Notification, error) {
+	n, err := t.store.FindByProviderMessage(ctx, provider, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, ErrUnknownMessage
+	}
+	if err := t.store.AddDeliveryEvent(ctx, &DeliveryEvent{
+		NotificationID: n.ID,
+		Kind:           DeliveryCallback,
+		Status:         status,
+		Provider:       provider,
+		Detail:         detail,
+		OccurredAt:     occurredAt,
+	}); err != nil {
+		return nil, err
+	}
+	t.advance(ctx, n, status, occurredAt)
+	return n, nil
+}
+
+// Timeline returns the notification and its delivery events, oldest first
+func (t *DeliveryTracker) Timeline(ctx context.Context, id string) (*Notification, []DeliveryEvent, error) {
+	n, err := t.store.GetByID(ctx, id)
+	if err != nil || n == nil {
+		return nil, nil, err
+	}
+	events, err := t.store.ListDeliveryEvents(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return n, events, nil
+}
+
+// advance moves the notification to the status unless it is already
+// further along
+func (t *DeliveryTracker) advance(ctx context.Context, n *Notification, status Status, at time.Time) {
+	if statusRank[status] <= statusRank[n.Status] {
+		return
+	}
+	n.Status = status
+	if status == StatusSent {
+		n.SentAt = &at
+	}
+	if err := t.store.SetStatus(ctx, n); err != nil {
+		log.Printf("Failed to update status of notification %s: %v", n.ID, err)
+	}
+}
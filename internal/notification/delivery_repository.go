@@ -0,0 +1,99 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TrackTask inserts the notification of a task, or loads the one an
+// earlier attempt inserted.
+func (r *Repository) TrackTask(ctx context.Context, taskID string, n *Notification) error {
+	query := `
+		INSERT INTO notifications (id, task_id, user_id, recipient, channel, title, content, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (task_id) DO UPDATE SET title = EXCLUDED.title, content = EXCLUDED.content
+		RETURNING id, status, created_at, sent_at, provider, provider_message_id
+	`
+	return r.db.QueryRowContext(ctx, query,
+		uuid.New().String(), taskID, n.UserID, n.Recipient, n.Channel, n.Title, n.Content, StatusPending, time.Now(),
+	).Scan(&n.ID, &n.Status, &n.CreatedAt, &n.SentAt, &n.Provider, &n.ProviderMessageID)
+}
+
+// SetStatus saves a notification's status, sent_at and provider message.
+func (r *Repository) SetStatus(ctx context.Context, n *Notification) error {
+	query := `
+		UPDATE notifications SET status = $1, sent_at = $2, provider = $3, provider_message_id = $4
+		WHERE id = $5
+	`
+	_, err := r.db.ExecContext(ctx, query, n.Status, n.SentAt, n.Provider, n.ProviderMessageID, n.ID)
+	return err
+}
+
+// AddDeliveryEvent inserts an entry of a notification's delivery timeline.
+func (r *Repository) AddDeliveryEvent(ctx context.Context, e *DeliveryEvent) error {
+	e.ID = uuid.New().String()
+	query := `
+		INSERT INTO notification_events (id, notification_id, kind, status, attempt, provider, detail, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		e.ID, e.NotificationID, e.Kind, e.Status, e.Attempt, e.Provider, e.Detail, e.OccurredAt,
+	)
+	return err
+}
+
+// CountAttempts counts the attempts made to send a notification.
+func (r *Repository) CountAttempts(ctx context.Context, notificationID string) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM notification_events WHERE notification_id = $1 AND kind = 'attempt'`, notificationID,
+	).Scan(&n)
+	return n, err
+}
+
+// FindByProviderMessage retrieves the notification sent as a provider's
+// message.
+func (r *Repository) FindByProviderMessage(ctx context.Context, provider, messageID string) (*Notification, error) {
+	query := `
+		SELECT id, user_id, recipient, channel, COALESCE(title, ''), content, status, created_at, sent_at,
+			provider, provider_message_id
+		FROM notifications WHERE provider = $1 AND provider_message_id = $2
+	`
+	var n Notification
+	err := r.db.QueryRowContext(ctx, query, provider, messageID).Scan(&n.ID, &n.UserID, &n.Recipient, &n.Channel,
+		&n.Title, &n.Content, &n.Status, &n.CreatedAt, &n.SentAt, &n.Provider, &n.ProviderMessageID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// ListDeliveryEvents retrieves a notification's delivery timeline, oldest
+// first.
+func (r *Repository) ListDeliveryEvents(ctx context.Context, notificationID string) ([]DeliveryEvent, error) {
+	query := `
+		SELECT id, notification_id, kind, status, attempt, provider, detail, occurred_at
+		FROM notification_events WHERE notification_id = $1 ORDER BY occurred_at, id
+	`
+	rows, err := r.db.QueryContext(ctx, query, notificationID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	events := []DeliveryEvent{}
+	for rows.Next() {
+		var e DeliveryEvent
+		if err := rows.Scan(&e.ID, &e.NotificationID, &e.Kind, &e.Status, &e.Attempt, &e.Provider, &e.Detail, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
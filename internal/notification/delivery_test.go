@@ -0,0 +1,204 @@
+package notification
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// memoryDeliveryStore keeps notifications by task and their events
+type memoryDeliveryStore struct {
+	byTask        map[string]*Notification
+	notifications map[string]*Notification
+	events        []DeliveryEvent
+}
+
+func newMemoryDeliveryStore() *memoryDeliveryStore {
+	return &memoryDeliveryStore{byTask: map[string]*Notification{}, notifications: map[string]*Notification{}}
+}
+
+func (s *memoryDeliveryStore) TrackTask(ctx context.Context, taskID string, n *Notification) error {
+	if existing, ok := s.byTask[taskID]; ok {
+		*n = *existing
+		return nil
+	}
+	n.ID = fmt.Sprintf("n-%d", len(s.notifications)+1)
+	n.Status = StatusPending
+	stored := *n
+	s.byTask[taskID] = &stored
+	s.notifications[n.ID] = &stored
+	return nil
+}
+
+func (s *memoryDeliveryStore) SetStatus(ctx context.Context, n *Notification) error {
+	*s.notifications[n.ID] = *n
+	return nil
+}
+
+func (s *memoryDeliveryStore) AddDeliveryEvent(ctx context.Context, e *DeliveryEvent) error {
+	e.ID = fmt.Sprintf("e-%d", len(s.events)+1)
+	s.events = append(s.events, *e)
+	return nil
+}
+
+func (s *memoryDeliveryStore) CountAttempts(ctx context.Context, notificationID string) (int, error) {
+	count := 0
+	for _, e := range s.events {
+		if e.NotificationID == notificationID && e.Kind == DeliveryAttempt {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryDeliveryStore) FindByProviderMessage(ctx context.Context, provider, messageID string) (*Notification, error) {
+	for _, n := range s.notifications {
+		if n.Provider == provider && n.ProviderMessageID == messageID {
+			found := *n
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryDeliveryStore) GetByID(ctx context.Context, id string) (*Notification, error) {
+	n, ok := s.notifications[id]
+	if !ok {
+		return nil, nil
+	}
+	found := *n
+	return &found, nil
+}
+
+func (s *memoryDeliveryStore) ListDeliveryEvents(ctx context.Context, notificationID string) ([]DeliveryEvent, error) {
+	var events []DeliveryEvent
+	for _, e := range s.events {
+		if e.NotificationID == notificationID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func TestDeliveryTracker_TracksAttemptsAndCallbacks(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryDeliveryStore()
+	tracker := NewDeliveryTracker(store)
+	task := &NotificationTask{ID: "task-1", Recipient: "a@example.com", Data: map[string]string{"UserID": "user-1"}}
+
+	// The first attempt fails and the retry sends it
+	n := tracker.Begin(ctx, task, Email, "Receipt", "Paid")
+	tracker.Attempt(ctx, n, ProviderResend, "", errors.New("timeout"))
+	n = tracker.Begin(ctx, task, Email, "Receipt", "Paid")
+	tracker.Attempt(ctx, n, ProviderResend, "msg-1", nil)
+	if len(store.notifications) != 1 {
+		t.Fatalf("got %d notifications, want 1 across retries", len(store.notifications))
+	}
+
+	// Callbacks arriving out of order do not move the notification back
+	at := time.Now().UTC()
+	if _, err := tracker.Callback(ctx, ProviderResend, "msg-1", StatusOpened, "email.opened", at); err != nil {
+		t.Fatalf("Callback failed: %v", err)
+	}
+	if _, err := tracker.Callback(ctx, ProviderResend, "msg-1", StatusDelivered, "email.delivered", at.Add(-time.Second)); err != nil {
+		t.Fatalf("Callback failed: %v", err)
+	}
+
+	got, events, err := tracker.Timeline(ctx, n.ID)
+	if err != nil {
+		t.Fatalf("Timeline failed: %v", err)
+	}
+	if got.Status != StatusOpened || got.SentAt == nil {
+		t.Errorf("got status %s, sent at %v, want opened after being sent", got.Status, got.SentAt)
+	}
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4", len(events))
+	}
+	if events[0].Attempt != 1 || events[0].Status != StatusFailed || events[1].Attempt != 2 || events[1].Status != StatusSent {
+		t.Errorf("got attempts %+v, %+v, want a failed first and a sent second", events[0], events[1])
+	}
+
+	if _, err := tracker.Callback(ctx, ProviderResend, "msg-unknown", StatusDelivered, "", at); !errors.Is(err, ErrUnknownMessage) {
+		t.Errorf("got %v, want ErrUnknownMessage", err)
+	}
+}
+
+func TestDeliveryTracker_Nil(t *testing.T) {
+	var tracker *DeliveryTracker
+	n := tracker.Begin(context.Background(), &NotificationTask{ID: "task-1"}, SMS, "", "Hi")
+	tracker.Attempt(context.Background(), n, ProviderTwilio, "SM1", nil)
+	tracker.Fail(context.Background(), n)
+	if n != nil {
+		t.Errorf("got %+v, want no notification", n)
+	}
+}
+
+func TestVerifyResendSignature(t *testing.T) {
+	key := []byte("resend-signing-key")
+	secret := "whsec_" + base64.StdEncoding.EncodeToString(key)
+	body := []byte(`{"type":"email.delivered"}`)
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("msg_1." + timestamp + "."))
+	mac.Write(body)
+	signature := "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	if err := VerifyResendSignature(secret, "msg_1", timestamp, "v1,bogus "+signature, body, now); err != nil {
+		t.Errorf("got %v for a valid signature", err)
+	}
+	if err := VerifyResendSignature(secret, "msg_1", timestamp, signature, []byte(`{}`), now); err == nil {
+		t.Error("accepted a tampered body")
+	}
+	if err := VerifyResendSignature(secret, "msg_1", timestamp, signature, body, now.Add(10*time.Minute)); err == nil {
+		t.Error("accepted a stale callback")
+	}
+}
+
+func TestParseResendEvent(t *testing.T) {
+	status, tracked, err := ParseResendEvent([]byte(`{"type":"email.bounced","created_at":"2024-01-02T03:04:05Z",
+		"data":{"email_id":"em_1","bounce":{"message":"mailbox full"}}}`))
+	if err != nil || !tracked {
+		t.Fatalf("got tracked %v, %v", tracked, err)
+	}
+	if status.MessageID != "em_1" || status.Status != StatusBounced || status.Detail != "email.bounced: mailbox full" {
+		t.Errorf("got %+v", status)
+	}
+
+	if _, tracked, err := ParseResendEvent([]byte(`{"type":"email.clicked","data":{"email_id":"em_1"}}`)); err != nil || tracked {
+		t.Errorf("got tracked %v, %v for an untracked event", tracked, err)
+	}
+}
+
+func TestVerifyTwilioSignature(t *testing.T) {
+	callbackURL := "https://api.example.com/notifications/callbacks/twilio"
+	form := url.Values{"MessageSid": {"SM1"}, "MessageStatus": {"delivered"}}
+
+	mac := hmac.New(sha1.New, []byte("token"))
+	mac.Write([]byte(callbackURL + "MessageSidSM1MessageStatusdelivered"))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	if err := VerifyTwilioSignature("token", callbackURL, form, signature); err != nil {
+		t.Errorf("got %v for a valid signature", err)
+	}
+	if err := VerifyTwilioSignature("other", callbackURL, form, signature); err == nil {
+		t.Error("accepted a signature made with another token")
+	}
+
+	status, tracked := ParseTwilioStatus(url.Values{"MessageSid": {"SM1"}, "MessageStatus": {"undelivered"}, "ErrorCode": {"30003"}})
+	if !tracked || status.Status != StatusBounced || status.Detail != "undelivered: error 30003" {
+		t.Errorf("got %+v, %v", status, tracked)
+	}
+	if _, tracked := ParseTwilioStatus(url.Values{"MessageSid": {"SM1"}, "MessageStatus": {"queued"}}); tracked {
+		t.Error("tracked a queued message")
+	}
+}
@@ -0,0 +1,203 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// EventDigest is the combined email of the notifications held back for a
+// user, which users can opt out of like any other event
+const EventDigest EventType = "notification.digest"
+
+// TemplateDigest renders digest emails
+const TemplateDigest = "digest"
+
+// CriticalEvents are sent right away even when their routing allows
+// digests: failures users must act on, and security messages
+var CriticalEvents = map[EventType]bool{
+	EventPaymentFailed:  true,
+	EventUserRegistered: true,
+	EventPasswordReset:  true,
+	EventSecurityCode:   true,
+	EventOrgInvitation:  true,
+}
+
+// digestLines summarize each event type in one line of a digest
+var digestLines = map[EventType]string{
+	EventPaymentSucceeded: `Payment of {{.Amount}} {{.Currency}} succeeded ({{.TransactionID}})`,
+	EventRefundInitiated:  `Refund of {{.Amount}} {{.Currency}} initiated ({{.RefundID}})`,
+	EventRefundCompleted:  `Refund of {{.Amount}} {{.Currency}} completed ({{.RefundID}})`,
+}
+
+// DigestItem is a notification held back for the user's next digest
+type DigestItem struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"user_id"`
+	Recipient string            `json:"recipient"`
+	EventType EventType         `json:"event_type"`
+	Data      map[string]string `json:"data"`
+	CreatedAt time.Time         `json:"created_at"`
+	DueAt     time.Time         `json:"due_at"`
+}
+
+// DigestStore keeps the items of pending digests
+type DigestStore interface {
+	// AddDigestItem holds the item until the user's pending digest is due,
+	// or for the window when none is pending
+	AddDigestItem(ctx context.Context, item *DigestItem, window time.Duration) error
+	// DueDigestUsers lists the users whose digest is due
+	DueDigestUsers(ctx context.Context, now time.Time) ([]string, error)
+	// ClaimDigestItems takes the user's pending items, for one instance to
+	// send them
+	ClaimDigestItems(ctx context.Context, userID string, now time.Time) ([]DigestItem, error)
+	// ReleaseDigestItems puts claimed items back, to be sent next time
+	ReleaseDigestItems(ctx context.Context, ids []string) error
+}
+
+// Digester aggregates low-priority email notifications per user over a
+// window and sends them as one email
+type Digester struct {
+	store     DigestStore
+	publisher RabbitPublisher
+	window    time.Duration
+}
+
+func NewDigester(store DigestStore, publisher RabbitPublisher, window time.Duration) *Digester {
+	return &Digester{store: store, publisher: publisher, window: window}
+}
+
+// Holds reports whether the event's email goes to the user's digest
+// rather than out right away
+func (d *Digester) Holds(config RoutingConfig, eventType EventType, userID string) bool {
+	return d != nil && config.Digest && !CriticalEvents[eventType] && userID != ""
+}
+
+// Add holds the event's notification for the user's digest
+func (d *Digester) Add(ctx context.Context, event *Event, data map[string]string) error {
+	item := &DigestItem{
+		UserID:    data["UserID"],
+		Recipient: data["Recipient"],
+		EventType: event.Type,
+		Data:      data,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := d.store.AddDigestItem(ctx, item, d.window); err != nil {
+		return err
+	}
+	log.Printf("Held %s for user %s until %s", event.Type, item.UserID, item.DueAt.Format(time.RFC3339))
+	return nil
+}
+
+// Flush sends the digests that are due, returning how many were sent
+func (d *Digester) Flush(ctx context.Context, now time.Time) (int, error) {
+	users, err := d.store.DueDigestUsers(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, userID := range users {
+		items, err := d.store.ClaimDigestItems(ctx, userID, now)
+		if err != nil {
+			log.Printf("Failed to claim digest of user %s: %v", userID, err)
+			continue
+		}
+		if len(items) == 0 {
+			continue // Claimed by another instance
+		}
+
+		task := DigestTask(userID, items, now)
+		if err := d.publish(ctx, task); err != nil {
+			log.Printf("Failed to send digest of user %s, releasing it: %v", userID, err)
+			ids := make([]string, len(items))
+			for i, item := range items {
+				ids[i] = item.ID
+			}
+			if err := d.store.ReleaseDigestItems(ctx, ids); err != nil {
+				log.Printf("Failed to release digest of user %s: %v", userID, err)
+			}
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func (d *Digester) publish(ctx context.Context, task *NotificationTask) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	return d.publisher.Publish(ctx, QueueFor(Email), data)
+}
+
+// Run flushes due digests every interval until the context is cancelled
+func (d *Digester) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n, err := d.Flush(ctx, now.UTC()); err != nil {
+				log.Printf("Failed to flush notification digests: %v", err)
+			} else if n > 0 {
+				log.Printf("Sent %d notification digests", n)
+			}
+		}
+	}
+}
+
+// DigestTask builds the email task combining a user's items, oldest first
+func DigestTask(userID string, items []DigestItem, now time.Time) *NotificationTask {
+	sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.Before(items[j].CreatedAt) })
+
+	lines := make([]string, len(items))
+	for i, item := range items {
+		lines[i] = digestLine(item)
+	}
+	last := items[len(items)-1]
+	data := map[string]string{
+		"UserID":   userID,
+		"UserName": last.Data["UserName"],
+		"Locale":   last.Data["Locale"],
+		"Count":    strconv.Itoa(len(items)),
+		"Summary":  strings.Join(lines, "\n"),
+	}
+	return &NotificationTask{
+		ID:         fmt.Sprintf("digest_%s_%d", userID, now.Unix()),
+		Channel:    Email,
+		Recipient:  last.Recipient,
+		TemplateID: TemplateDigest,
+		Data:       data,
+		EventType:  EventDigest,
+		MaxRetries: 3,
+	}
+}
+
+// digestLine summarizes an item, falling back to its event type
+func digestLine(item DigestItem) string {
+	line, ok := digestLines[item.EventType]
+	if !ok {
+		return string(item.EventType)
+	}
+	tmpl, err := template.New(string(item.EventType)).Parse(line)
+	if err != nil {
+		return string(item.EventType)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, item.Data); err != nil {
+		return string(item.EventType)
+	}
+	return buf.String()
+}
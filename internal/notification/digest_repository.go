@@ -0,0 +1,89 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AddDigestItem inserts an item, due with the user's pending digest or
+// after the window when none is pending.
+func (r *Repository) AddDigestItem(ctx context.Context, item *DigestItem, window time.Duration) error {
+	data, err := json.Marshal(item.Data)
+	if err != nil {
+		return err
+	}
+	item.ID = uuid.New().String()
+	query := `
+		INSERT INTO notification_digest_items (id, user_id, recipient, event_type, data, created_at, due_at)
+		SELECT $1, $2, $3, $4, $5, $6, COALESCE(
+			(SELECT MIN(due_at) FROM notification_digest_items WHERE user_id = $2 AND sent_at IS NULL), $7)
+		RETURNING due_at
+	`
+	return r.db.QueryRowContext(ctx, query,
+		item.ID, item.UserID, item.Recipient, item.EventType, data, item.CreatedAt, item.CreatedAt.Add(window),
+	).Scan(&item.DueAt)
+}
+
+// DueDigestUsers lists the users whose pending digest is due.
+func (r *Repository) DueDigestUsers(ctx context.Context, now time.Time) ([]string, error) {
+	query := `
+		SELECT user_id FROM notification_digest_items
+		WHERE sent_at IS NULL
+		GROUP BY user_id HAVING MIN(due_at) <= $1
+	`
+	rows, err := r.db.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var users []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		users = append(users, userID)
+	}
+	return users, rows.Err()
+}
+
+// ClaimDigestItems marks the user's pending items sent and returns them.
+func (r *Repository) ClaimDigestItems(ctx context.Context, userID string, now time.Time) ([]DigestItem, error) {
+	query := `
+		UPDATE notification_digest_items SET sent_at = $2
+		WHERE user_id = $1 AND sent_at IS NULL
+		RETURNING id, user_id, recipient, event_type, data, created_at, due_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var items []DigestItem
+	for rows.Next() {
+		var item DigestItem
+		var data []byte
+		if err := rows.Scan(&item.ID, &item.UserID, &item.Recipient, &item.EventType, &data,
+			&item.CreatedAt, &item.DueAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &item.Data); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// ReleaseDigestItems makes claimed items pending again.
+func (r *Repository) ReleaseDigestItems(ctx context.Context, ids []string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE notification_digest_items SET sent_at = NULL WHERE id::text = ANY($1)`, pq.Array(ids))
+	return err
+}
@@ -0,0 +1,133 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryDigestStore keeps digest items in memory
+type memoryDigestStore struct {
+	items []DigestItem
+	sent  map[string]bool
+}
+
+func (s *memoryDigestStore) AddDigestItem(ctx context.Context, item *DigestItem, window time.Duration) error {
+	item.ID = fmt.Sprintf("item-%d", len(s.items)+1)
+	item.DueAt = item.CreatedAt.Add(window)
+	for _, pending := range s.items {
+		if pending.UserID == item.UserID && !s.sent[pending.ID] && pending.DueAt.Before(item.DueAt) {
+			item.DueAt = pending.DueAt
+		}
+	}
+	s.items = append(s.items, *item)
+	return nil
+}
+
+func (s *memoryDigestStore) DueDigestUsers(ctx context.Context, now time.Time) ([]string, error) {
+	var users []string
+	for _, item := range s.items {
+		if !s.sent[item.ID] && !item.DueAt.After(now) {
+			users = append(users, item.UserID)
+		}
+	}
+	return users, nil
+}
+
+func (s *memoryDigestStore) ClaimDigestItems(ctx context.Context, userID string, now time.Time) ([]DigestItem, error) {
+	var items []DigestItem
+	for _, item := range s.items {
+		if item.UserID == userID && !s.sent[item.ID] {
+			s.sent[item.ID] = true
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (s *memoryDigestStore) ReleaseDigestItems(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		delete(s.sent, id)
+	}
+	return nil
+}
+
+// taskPublisher keeps the tasks published to each queue
+type taskPublisher struct {
+	tasks map[string][]NotificationTask
+}
+
+func (p *taskPublisher) Publish(ctx context.Context, queue string, body []byte) error {
+	var task NotificationTask
+	_ = json.Unmarshal(body, &task)
+	p.tasks[queue] = append(p.tasks[queue], task)
+	return nil
+}
+
+func TestDigester_HoldsLowPriorityEmails(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryDigestStore{sent: map[string]bool{}}
+	publisher := &taskPublisher{tasks: map[string][]NotificationTask{}}
+	digester := NewDigester(store, publisher, time.Hour)
+	router := NewRouter(publisher)
+	router.SetDigester(digester)
+
+	for i, eventType := range []EventType{EventPaymentSucceeded, EventPaymentSucceeded, EventPaymentFailed} {
+		data, _ := json.Marshal(PaymentEventData{PaymentID: fmt.Sprintf("pay_%d", i), UserID: "user_1", Amount: 1000, Currency: "USD"})
+		if err := router.Route(ctx, &Event{ID: fmt.Sprintf("evt_%d", i), Type: eventType, Data: data}); err != nil {
+			t.Fatalf("Route failed: %v", err)
+		}
+	}
+
+	// Only the failure, which is critical, is emailed right away. Web
+	// pushes are never held.
+	if emails := publisher.tasks["email.notifications"]; len(emails) != 1 || emails[0].EventType != EventPaymentFailed {
+		t.Fatalf("got emails %+v, want only the failure", emails)
+	}
+	if pushes := publisher.tasks["web.notifications"]; len(pushes) != 3 {
+		t.Errorf("got %d pushes, want 3", len(pushes))
+	}
+	if len(store.items) != 2 {
+		t.Fatalf("got %d held items, want 2", len(store.items))
+	}
+
+	// Nothing is due before the window is over
+	if n, err := digester.Flush(ctx, time.Now().Add(30*time.Minute)); err != nil || n != 0 {
+		t.Fatalf("got %d digests, %v before the window is over", n, err)
+	}
+
+	n, err := digester.Flush(ctx, time.Now().Add(time.Hour+time.Minute))
+	if err != nil || n != 1 {
+		t.Fatalf("got %d digests, %v, want 1", n, err)
+	}
+	emails := publisher.tasks["email.notifications"]
+	digest := emails[len(emails)-1]
+	if digest.TemplateID != TemplateDigest || digest.EventType != EventDigest || digest.Data["Count"] != "2" {
+		t.Errorf("got digest %+v", digest)
+	}
+	if lines := strings.Split(digest.Data["Summary"], "\n"); len(lines) != 2 || !strings.Contains(lines[0], "pay_0") {
+		t.Errorf("got summary %q", digest.Data["Summary"])
+	}
+
+	// Sent items are not sent again
+	if n, _ := digester.Flush(ctx, time.Now().Add(2*time.Hour)); n != 0 {
+		t.Errorf("got %d digests, want the items sent once", n)
+	}
+}
+
+func TestRenderBuiltin_Digest(t *testing.T) {
+	rendered, err := renderBuiltin(TemplateDigest, Email, map[string]string{
+		"UserName": "Ada",
+		"Count":    "2",
+		"Summary":  "Payment of 10.00 USD succeeded (pay_1)\nRefund of 5.00 USD completed (re_1)",
+	})
+	if err != nil {
+		t.Fatalf("renderBuiltin failed: %v", err)
+	}
+	if rendered.Subject != GetEmailSubject(TemplateDigest) || !strings.Contains(rendered.Body, "re_1") {
+		t.Errorf("got %+v", rendered)
+	}
+}
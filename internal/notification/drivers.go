@@ -2,8 +2,12 @@ package notification
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+
+	"github.com/google/uuid"
 )
 
 // Driver defines the interface for sending notifications via different channels.
@@ -12,6 +16,48 @@ type Driver interface {
 	Channel() Channel
 }
 
+// MessageSender is a Driver whose provider identifies the messages it sends,
+// for their delivery callbacks to be matched to the notifications
+type MessageSender interface {
+	SendMessage(ctx context.Context, recipient, title, content string) (string, error)
+	// Provider names the provider the message IDs belong to
+	Provider() string
+}
+
+// ErrUndeliverable marks tasks given up on without retrying, as their
+// provider refused them for good
+var ErrUndeliverable = errors.New("notification is undeliverable")
+
+// SendError is a provider's refusal to send a message, classified by its
+// driver for the worker to decide on retrying
+type SendError struct {
+	Provider  string
+	Status    int    // HTTP status of the provider's answer
+	Code      string // The provider's error code, e.g. Twilio's 21211
+	Message   string
+	Retryable bool
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("%s refused the message (status %d, code %s): %s", e.Provider, e.Status, e.Code, e.Message)
+}
+
+// IsRetryable reports whether sending again may succeed. Errors drivers did
+// not classify, e.g. network errors, are retried.
+func IsRetryable(err error) bool {
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		return sendErr.Retryable
+	}
+	return true
+}
+
+// retryableStatus reports whether an HTTP status is worth retrying: server
+// errors, throttling and timeouts
+func retryableStatus(status int) bool {
+	return status >= 500 || status == 429 || status == 408
+}
+
 // EmailDriver is a mock email driver (e.g., SendGrid).
 type EmailDriver struct{}
 
@@ -32,7 +78,8 @@ func (d *EmailDriver) Send(ctx context.Context, recipient, title, content string
 	return nil
 }
 
-// SMSDriver is a mock SMS driver (e.g., Twilio).
+// SMSDriver is a mock SMS driver, logging messages instead of sending them.
+// TwilioDriver sends them.
 type SMSDriver struct{}
 
 func NewSMSDriver() *SMSDriver {
@@ -43,15 +90,27 @@ func (d *SMSDriver) Channel() Channel {
 	return SMS
 }
 
+func (d *SMSDriver) Provider() string {
+	return ProviderTwilio
+}
+
 func (d *SMSDriver) Send(ctx context.Context, recipient, title, content string) error {
+	_, err := d.SendMessage(ctx, recipient, title, content)
+	return err
+}
+
+// SendMessage sends the SMS, returning a Twilio-style message SID
+func (d *SMSDriver) SendMessage(ctx context.Context, recipient, title, content string) (string, error) {
 	// In production, this would call Twilio, AWS SNS, etc.
+	sid := "SM" + strings.ReplaceAll(uuid.New().String(), "-", "")
 	log.Printf("[SMS DRIVER] Sending SMS to %s", recipient)
 	log.Printf("[SMS DRIVER] Message: %s", content)
-	log.Printf("[SMS DRIVER] SMS sent successfully to %s", recipient)
-	return nil
+	log.Printf("[SMS DRIVER] SMS %s sent successfully to %s", sid, recipient)
+	return sid, nil
 }
 
-// WebDriver is a mock web push notification driver.
+// WebDriver is a mock web push notification driver. FCMDriver sends the
+// pushes.
 type WebDriver struct{}
 
 func NewWebDriver() *WebDriver {
@@ -0,0 +1,164 @@
+package notification
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTwilioDriver_SendMessage(t *testing.T) {
+	var status int
+	var answer string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "AC1" || pass != "token" {
+			t.Errorf("got credentials %s:%s", user, pass)
+		}
+		if r.URL.Path != "/Accounts/AC1/Messages.json" {
+			t.Errorf("got path %s", r.URL.Path)
+		}
+		if r.FormValue("To") != "+15550001111" || r.FormValue("From") != "+15559990000" || r.FormValue("StatusCallback") == "" {
+			t.Errorf("got form %v", r.Form)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(answer))
+	}))
+	defer server.Close()
+
+	driver, err := NewTwilioDriver(TwilioConfig{
+		AccountSID:        "AC1",
+		AuthToken:         "token",
+		FromNumber:        "+15559990000",
+		StatusCallbackURL: "https://api.example.com/notifications/callbacks/twilio",
+	})
+	if err != nil {
+		t.Fatalf("NewTwilioDriver failed: %v", err)
+	}
+	driver.baseURL = server.URL
+
+	status, answer = http.StatusCreated, `{"sid":"SM123"}`
+	sid, err := driver.SendMessage(context.Background(), "+15550001111", "", "Your code is 1234")
+	if err != nil || sid != "SM123" {
+		t.Fatalf("got %q, %v", sid, err)
+	}
+
+	tests := []struct {
+		status    int
+		answer    string
+		retryable bool
+	}{
+		{http.StatusBadRequest, `{"code":21211,"message":"Invalid 'To' Phone Number"}`, false},
+		{http.StatusTooManyRequests, `{"code":20429,"message":"Too Many Requests"}`, true},
+		{http.StatusServiceUnavailable, `{}`, true},
+	}
+	for _, tt := range tests {
+		status, answer = tt.status, tt.answer
+		_, err := driver.SendMessage(context.Background(), "+15550001111", "", "Hi")
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) {
+			t.Fatalf("got %v, want a SendError", err)
+		}
+		if IsRetryable(err) != tt.retryable {
+			t.Errorf("status %d: got retryable %v, want %v", tt.status, IsRetryable(err), tt.retryable)
+		}
+	}
+}
+
+func TestFCMDriver_SendMessage(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	tokens := 0
+	var answer string
+	var status int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("assertion") == "" {
+			t.Error("got no assertion")
+		}
+		tokens++
+		_, _ = w.Write([]byte(`{"access_token":"access-1","expires_in":3600}`))
+	})
+	mux.HandleFunc("/projects/project-1/messages:send", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-1" {
+			t.Errorf("got authorization %q", r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Message struct {
+				Token        string            `json:"token"`
+				Notification map[string]string `json:"notification"`
+			} `json:"message"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Message.Token != "device-token" || body.Message.Notification["title"] != "Payment received" {
+			t.Errorf("got message %+v", body.Message)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(answer))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	driver := NewFCMDriver(&ServiceAccount{
+		ProjectID:   "project-1",
+		ClientEmail: "fcm@project-1.iam.gserviceaccount.com",
+		PrivateKey:  string(privateKey),
+		TokenURI:    server.URL + "/token",
+	})
+	driver.baseURL = server.URL
+
+	status, answer = http.StatusOK, `{"name":"projects/project-1/messages/42"}`
+	for i := 0; i < 2; i++ {
+		name, err := driver.SendMessage(context.Background(), "device-token", "Payment received", "$10.00")
+		if err != nil || name != "projects/project-1/messages/42" {
+			t.Fatalf("got %q, %v", name, err)
+		}
+	}
+	if tokens != 1 {
+		t.Errorf("got %d token exchanges, want the token cached", tokens)
+	}
+
+	status, answer = http.StatusNotFound, `{"error":{"status":"NOT_FOUND","message":"Requested entity was not found.",
+		"details":[{"errorCode":"UNREGISTERED"}]}}`
+	_, err = driver.SendMessage(context.Background(), "device-token", "Payment received", "$10.00")
+	if err == nil || IsRetryable(err) {
+		t.Errorf("got %v, want a permanent error for an unregistered token", err)
+	}
+
+	status, answer = http.StatusServiceUnavailable, `{"error":{"status":"UNAVAILABLE"}}`
+	_, err = driver.SendMessage(context.Background(), "device-token", "Payment received", "$10.00")
+	if err == nil || !IsRetryable(err) {
+		t.Errorf("got %v, want a retryable error", err)
+	}
+}
+
+func TestWorker_GivesUpOnUndeliverableTasks(t *testing.T) {
+	worker := NewWorker(SMS, &failingDriver{err: &SendError{Provider: ProviderTwilio, Status: 400, Code: "21610"}}, nil, nil)
+	body, _ := json.Marshal(NotificationTask{ID: "task-1", Recipient: "+15550001111", MaxRetries: 3})
+
+	if err := worker.ProcessTask(context.Background(), body); !errors.Is(err, ErrUndeliverable) {
+		t.Errorf("got %v, want ErrUndeliverable", err)
+	}
+}
+
+// failingDriver fails every send with its error
+type failingDriver struct {
+	err error
+}
+
+func (d *failingDriver) Channel() Channel {
+	return SMS
+}
+
+func (d *failingDriver) Send(ctx context.Context, recipient, title, content string) error {
+	return d.err
+}
@@ -34,8 +34,9 @@ func NewEmailService(apiKey string) *EmailService {
 	}
 }
 
-// SendEmail sends a transactional email
-func (s *EmailService) SendEmail(ctx context.Context, to, subject, htmlBody string) error {
+// SendEmail sends a transactional email, returning the ID Resend's delivery
+// callbacks refer to it by
+func (s *EmailService) SendEmail(ctx context.Context, to, subject, htmlBody string) (string, error) {
 	params := &resend.SendEmailRequest{
 		From:    s.fromEmail,
 		To:      []string{to},
@@ -43,10 +44,10 @@ func (s *EmailService) SendEmail(ctx context.Context, to, subject, htmlBody stri
 		Html:    htmlBody,
 	}
 
-	_, err := s.client.Emails.SendWithContext(ctx, params)
+	sent, err := s.client.Emails.SendWithContext(ctx, params)
 	if err != nil {
-		return fmt.Errorf("failed to send email via Resend: %w", err)
+		return "", fmt.Errorf("failed to send email via Resend: %w", err)
 	}
 
-	return nil
+	return sent.Id, nil
 }
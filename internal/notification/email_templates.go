@@ -11,6 +11,7 @@ const (
 	TemplateVerification   = "email_verification"
 	TemplateForgotPassword = "forgot_password"
 	TemplateSecurityCode   = "security_code"
+	TemplateOrgInvitation  = "org_invitation"
 )
 
 // Base URL for assets (should be configurable via env, hardcoded for now or use app URL)
@@ -24,6 +25,10 @@ func GetEmailSubject(templateID string) string {
 		return "Reset your password"
 	case TemplateSecurityCode:
 		return "Your security code"
+	case TemplateOrgInvitation:
+		return "You're invited to join an organization on Sapliy"
+	case TemplateDigest:
+		return "Your recent activity on Sapliy"
 	default:
 		return "Notification from Sapliy"
 	}
@@ -146,15 +151,34 @@ const securityCodeContent = `
     <p>Do not share this code with anyone.</p>
 `
 
-func RenderEmailTemplate(templateID string, data map[string]string) (string, error) {
-	// Basic data enrichment
-	tmplData := map[string]interface{}{
-		"LogoURL": AssetsBaseURL + "/sapliy-logo.png", // Assumes mapped/hosted
-	}
-	for k, v := range data {
-		tmplData[k] = v
-	}
+const orgInvitationContent = `
+    <h1>Join {{.OrgName}}</h1>
+    <p>{{.InvitedBy}} invited you to join {{.OrgName}} on Sapliy as {{.Role}}.</p>
+    <table role="presentation" border="0" cellpadding="0" cellspacing="0" class="btn btn-primary">
+        <tbody>
+            <tr>
+                <td align="center">
+                    <table role="presentation" border="0" cellpadding="0" cellspacing="0">
+                        <tbody>
+                            <tr>
+                                <td> <a href="{{.Link}}" target="_blank">Accept Invitation</a> </td>
+                            </tr>
+                        </tbody>
+                    </table>
+                </td>
+            </tr>
+        </tbody>
+    </table>
+    <p>This invitation will expire in 7 days. If you weren't expecting it, you can safely ignore this email.</p>
+`
 
+const digestContent = `
+    <h1>Your Recent Activity</h1>
+    <p>Hello {{.UserName}}, here are your {{.Count}} latest updates:</p>
+    <p style="white-space: pre-line;">{{.Summary}}</p>
+`
+
+func RenderEmailTemplate(templateID string, data map[string]string) (string, error) {
 	var contentTmpl string
 	switch templateID {
 	case TemplateVerification:
@@ -163,9 +187,25 @@ func RenderEmailTemplate(templateID string, data map[string]string) (string, err
 		contentTmpl = forgotPasswordContent
 	case TemplateSecurityCode:
 		contentTmpl = securityCodeContent
+	case TemplateOrgInvitation:
+		contentTmpl = orgInvitationContent
+	case TemplateDigest:
+		contentTmpl = digestContent
 	default:
 		return "", fmt.Errorf("unknown template: %s", templateID)
 	}
+	return renderEmail(contentTmpl, data)
+}
+
+// renderEmail renders an email's content block within the base layout
+func renderEmail(contentTmpl string, data map[string]string) (string, error) {
+	// Basic data enrichment
+	tmplData := map[string]interface{}{
+		"LogoURL": AssetsBaseURL + "/sapliy-logo.png", // Assumes mapped/hosted
+	}
+	for k, v := range data {
+		tmplData[k] = v
+	}
 
 	// First render the content block
 	tContent, err := template.New("content").Parse(contentTmpl)
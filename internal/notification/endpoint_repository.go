@@ -0,0 +1,100 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+const webhookEndpointColumns = `id, partner_id, url, secret, event_types, active, created_at, updated_at`
+
+func scanWebhookEndpoint(scan func(dest ...interface{}) error) (*WebhookEndpoint, error) {
+	var e WebhookEndpoint
+	var types []string
+	if err := scan(&e.ID, &e.PartnerID, &e.URL, &e.Secret, pq.Array(&types), &e.Active, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		return nil, err
+	}
+	for _, t := range types {
+		e.EventTypes = append(e.EventTypes, EventType(t))
+	}
+	return &e, nil
+}
+
+func eventTypeStrings(types []EventType) []string {
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = string(t)
+	}
+	return s
+}
+
+// CreateWebhookEndpoint inserts a partner's webhook endpoint.
+func (r *Repository) CreateWebhookEndpoint(ctx context.Context, e *WebhookEndpoint) error {
+	query := `
+		INSERT INTO notification_webhook_endpoints (partner_id, url, secret, event_types, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+	return r.db.QueryRowContext(ctx, query,
+		e.PartnerID, e.URL, e.Secret, pq.Array(eventTypeStrings(e.EventTypes)), e.Active, e.CreatedAt, e.UpdatedAt,
+	).Scan(&e.ID)
+}
+
+// GetWebhookEndpoint retrieves an endpoint, or nil if there is none.
+func (r *Repository) GetWebhookEndpoint(ctx context.Context, id string) (*WebhookEndpoint, error) {
+	query := `SELECT ` + webhookEndpointColumns + ` FROM notification_webhook_endpoints WHERE id::text = $1`
+	e, err := scanWebhookEndpoint(r.db.QueryRowContext(ctx, query, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+// ListWebhookEndpoints retrieves a partner's endpoints, or all endpoints
+// for an empty partner.
+func (r *Repository) ListWebhookEndpoints(ctx context.Context, partnerID string) ([]WebhookEndpoint, error) {
+	query := `SELECT ` + webhookEndpointColumns + ` FROM notification_webhook_endpoints
+		WHERE $1 = '' OR partner_id = $1 ORDER BY created_at`
+	return r.queryWebhookEndpoints(ctx, query, partnerID)
+}
+
+// SubscribedEndpoints retrieves the active endpoints subscribed to an event
+// type.
+func (r *Repository) SubscribedEndpoints(ctx context.Context, eventType EventType) ([]WebhookEndpoint, error) {
+	query := `SELECT ` + webhookEndpointColumns + ` FROM notification_webhook_endpoints
+		WHERE active AND $1 = ANY(event_types) ORDER BY created_at`
+	return r.queryWebhookEndpoints(ctx, query, string(eventType))
+}
+
+func (r *Repository) queryWebhookEndpoints(ctx context.Context, query string, args ...interface{}) ([]WebhookEndpoint, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []WebhookEndpoint
+	for rows.Next() {
+		e, err := scanWebhookEndpoint(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, *e)
+	}
+	return endpoints, rows.Err()
+}
+
+// UpdateWebhookEndpoint saves an endpoint's URL, event types and active
+// flag.
+func (r *Repository) UpdateWebhookEndpoint(ctx context.Context, e *WebhookEndpoint) error {
+	query := `UPDATE notification_webhook_endpoints SET url = $1, event_types = $2, active = $3, updated_at = $4 WHERE id::text = $5`
+	_, err := r.db.ExecContext(ctx, query, e.URL, pq.Array(eventTypeStrings(e.EventTypes)), e.Active, e.UpdatedAt, e.ID)
+	return err
+}
+
+// DeleteWebhookEndpoint removes an endpoint.
+func (r *Repository) DeleteWebhookEndpoint(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM notification_webhook_endpoints WHERE id::text = $1`, id)
+	return err
+}
@@ -0,0 +1,161 @@
+package notification
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+var (
+	ErrEndpointNotFound    = errors.New("webhook endpoint not found")
+	ErrInvalidEndpointURL  = errors.New("url must be an absolute http or https URL")
+	ErrInvalidEndpoint     = errors.New("partner_id and at least one event type are required")
+	ErrUnknownWebhookEvent = errors.New("event type is not sent to webhooks")
+)
+
+// WebhookEndpoint is a partner's URL the events it subscribes to are
+// posted to
+type WebhookEndpoint struct {
+	ID         string      `json:"id"`
+	PartnerID  string      `json:"partner_id"`
+	URL        string      `json:"url"`
+	Secret     string      `json:"secret,omitempty"` // Only answered when created
+	EventTypes []EventType `json:"event_types"`
+	Active     bool        `json:"active"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// Validate checks the endpoint's URL and that it subscribes only to the
+// events routed to webhooks
+func (e *WebhookEndpoint) Validate() error {
+	if e.PartnerID == "" || len(e.EventTypes) == 0 {
+		return ErrInvalidEndpoint
+	}
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidEndpointURL
+	}
+	for _, t := range e.EventTypes {
+		if !DefaultRoutingRules[t].Webhook {
+			return fmt.Errorf("%w: %s", ErrUnknownWebhookEvent, t)
+		}
+	}
+	return nil
+}
+
+// WebhookEndpointStore persists partners' webhook endpoints
+type WebhookEndpointStore interface {
+	CreateWebhookEndpoint(ctx context.Context, e *WebhookEndpoint) error
+	// GetWebhookEndpoint returns the endpoint, or nil if there is none
+	GetWebhookEndpoint(ctx context.Context, id string) (*WebhookEndpoint, error)
+	// ListWebhookEndpoints returns the partner's endpoints, or all of them
+	// for an empty partner
+	ListWebhookEndpoints(ctx context.Context, partnerID string) ([]WebhookEndpoint, error)
+	// UpdateWebhookEndpoint saves the endpoint's URL, event types and
+	// active flag
+	UpdateWebhookEndpoint(ctx context.Context, e *WebhookEndpoint) error
+	DeleteWebhookEndpoint(ctx context.Context, id string) error
+	// SubscribedEndpoints returns the active endpoints subscribed to the
+	// event type
+	SubscribedEndpoints(ctx context.Context, eventType EventType) ([]WebhookEndpoint, error)
+}
+
+// EndpointRegistry manages the partners' webhook endpoints. A nil registry
+// has no endpoints.
+type EndpointRegistry struct {
+	store WebhookEndpointStore
+}
+
+func NewEndpointRegistry(store WebhookEndpointStore) *EndpointRegistry {
+	return &EndpointRegistry{store: store}
+}
+
+// CreateEndpoint saves an active endpoint with a new signing secret
+func (reg *EndpointRegistry) CreateEndpoint(ctx context.Context, e *WebhookEndpoint) error {
+	e.EventTypes = compactEventTypes(e.EventTypes)
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate secret: %w", err)
+	}
+	now := time.Now().UTC()
+	e.Secret = "whsec_" + hex.EncodeToString(secret)
+	e.Active = true
+	e.CreatedAt, e.UpdatedAt = now, now
+	return reg.store.CreateWebhookEndpoint(ctx, e)
+}
+
+// GetEndpoint returns the endpoint without its secret
+func (reg *EndpointRegistry) GetEndpoint(ctx context.Context, id string) (*WebhookEndpoint, error) {
+	e, err := reg.store.GetWebhookEndpoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrEndpointNotFound
+	}
+	e.Secret = ""
+	return e, nil
+}
+
+// ListEndpoints returns the endpoints without their secrets
+func (reg *EndpointRegistry) ListEndpoints(ctx context.Context, partnerID string) ([]WebhookEndpoint, error) {
+	endpoints, err := reg.store.ListWebhookEndpoints(ctx, partnerID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range endpoints {
+		endpoints[i].Secret = ""
+	}
+	return endpoints, nil
+}
+
+// UpdateEndpoint changes the endpoint's URL, event types or active flag,
+// leaving the fields given as nil as they are
+func (reg *EndpointRegistry) UpdateEndpoint(ctx context.Context, e *WebhookEndpoint, url *string, eventTypes []EventType, active *bool) error {
+	if url != nil {
+		e.URL = *url
+	}
+	if eventTypes != nil {
+		e.EventTypes = compactEventTypes(eventTypes)
+	}
+	if active != nil {
+		e.Active = *active
+	}
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	e.UpdatedAt = time.Now().UTC()
+	return reg.store.UpdateWebhookEndpoint(ctx, e)
+}
+
+func (reg *EndpointRegistry) DeleteEndpoint(ctx context.Context, id string) error {
+	return reg.store.DeleteWebhookEndpoint(ctx, id)
+}
+
+// Subscribed returns the active endpoints the event type is posted to
+func (reg *EndpointRegistry) Subscribed(ctx context.Context, eventType EventType) ([]WebhookEndpoint, error) {
+	if reg == nil {
+		return nil, nil
+	}
+	return reg.store.SubscribedEndpoints(ctx, eventType)
+}
+
+// compactEventTypes drops repeated event types
+func compactEventTypes(types []EventType) []EventType {
+	var compacted []EventType
+	for _, t := range types {
+		if !slices.Contains(compacted, t) {
+			compacted = append(compacted, t)
+		}
+	}
+	return compacted
+}
@@ -0,0 +1,176 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// memoryEndpointStore keeps webhook endpoints in a slice
+type memoryEndpointStore struct {
+	endpoints []*WebhookEndpoint
+	err       error
+}
+
+func (s *memoryEndpointStore) CreateWebhookEndpoint(ctx context.Context, e *WebhookEndpoint) error {
+	e.ID = fmt.Sprintf("we_%d", len(s.endpoints)+1)
+	stored := *e
+	s.endpoints = append(s.endpoints, &stored)
+	return nil
+}
+
+func (s *memoryEndpointStore) GetWebhookEndpoint(ctx context.Context, id string) (*WebhookEndpoint, error) {
+	for _, e := range s.endpoints {
+		if e.ID == id {
+			found := *e
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryEndpointStore) ListWebhookEndpoints(ctx context.Context, partnerID string) ([]WebhookEndpoint, error) {
+	var endpoints []WebhookEndpoint
+	for _, e := range s.endpoints {
+		if partnerID == "" || e.PartnerID == partnerID {
+			endpoints = append(endpoints, *e)
+		}
+	}
+	return endpoints, nil
+}
+
+func (s *memoryEndpointStore) UpdateWebhookEndpoint(ctx context.Context, e *WebhookEndpoint) error {
+	for _, stored := range s.endpoints {
+		if stored.ID == e.ID {
+			stored.URL, stored.EventTypes, stored.Active, stored.UpdatedAt = e.URL, e.EventTypes, e.Active, e.UpdatedAt
+		}
+	}
+	return nil
+}
+
+func (s *memoryEndpointStore) DeleteWebhookEndpoint(ctx context.Context, id string) error {
+	s.endpoints = slices.DeleteFunc(s.endpoints, func(e *WebhookEndpoint) bool { return e.ID == id })
+	return nil
+}
+
+func (s *memoryEndpointStore) SubscribedEndpoints(ctx context.Context, eventType EventType) ([]WebhookEndpoint, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	var endpoints []WebhookEndpoint
+	for _, e := range s.endpoints {
+		if e.Active && slices.Contains(e.EventTypes, eventType) {
+			endpoints = append(endpoints, *e)
+		}
+	}
+	return endpoints, nil
+}
+
+// webhookPublisher keeps the webhook tasks published
+type webhookPublisher struct {
+	webhooks []WebhookTask
+}
+
+func (p *webhookPublisher) Publish(ctx context.Context, queue string, body []byte) error {
+	if queue == "webhook.notifications" {
+		var task WebhookTask
+		if err := json.Unmarshal(body, &task); err != nil {
+			return err
+		}
+		p.webhooks = append(p.webhooks, task)
+	}
+	return nil
+}
+
+func TestEndpointRegistry_CreateEndpoint(t *testing.T) {
+	ctx := context.Background()
+	registry := NewEndpointRegistry(&memoryEndpointStore{})
+
+	e := &WebhookEndpoint{PartnerID: "acme", URL: "https://acme.example/hooks",
+		EventTypes: []EventType{EventPaymentSucceeded, EventPaymentSucceeded}}
+	if err := registry.CreateEndpoint(ctx, e); err != nil {
+		t.Fatalf("CreateEndpoint failed: %v", err)
+	}
+	if !e.Active || len(e.Secret) < len("whsec_")+1 || len(e.EventTypes) != 1 {
+		t.Errorf("got %+v, want an active endpoint with a secret", e)
+	}
+	got, err := registry.GetEndpoint(ctx, e.ID)
+	if err != nil || got.Secret != "" {
+		t.Errorf("got %+v (%v), want the endpoint without its secret", got, err)
+	}
+	if _, err := registry.GetEndpoint(ctx, "we_404"); !errors.Is(err, ErrEndpointNotFound) {
+		t.Errorf("got %v, want ErrEndpointNotFound", err)
+	}
+
+	for _, invalid := range []struct {
+		endpoint *WebhookEndpoint
+		err      error
+	}{
+		{&WebhookEndpoint{PartnerID: "acme", URL: "ftp://acme.example", EventTypes: []EventType{EventPaymentFailed}}, ErrInvalidEndpointURL},
+		{&WebhookEndpoint{PartnerID: "acme", URL: "/hooks", EventTypes: []EventType{EventPaymentFailed}}, ErrInvalidEndpointURL},
+		{&WebhookEndpoint{URL: "https://acme.example", EventTypes: []EventType{EventPaymentFailed}}, ErrInvalidEndpoint},
+		{&WebhookEndpoint{PartnerID: "acme", URL: "https://acme.example"}, ErrInvalidEndpoint},
+		// Not routed to webhooks
+		{&WebhookEndpoint{PartnerID: "acme", URL: "https://acme.example", EventTypes: []EventType{EventPasswordReset}}, ErrUnknownWebhookEvent},
+	} {
+		if err := registry.CreateEndpoint(ctx, invalid.endpoint); !errors.Is(err, invalid.err) {
+			t.Errorf("%+v: got %v, want %v", invalid.endpoint, err, invalid.err)
+		}
+	}
+}
+
+func TestRouter_Route_FansOutToSubscribedEndpoints(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryEndpointStore{}
+	registry := NewEndpointRegistry(store)
+	for _, e := range []*WebhookEndpoint{
+		{PartnerID: "acme", URL: "https://acme.example/hooks", EventTypes: []EventType{EventPaymentFailed}},
+		{PartnerID: "globex", URL: "https://globex.example/hooks", EventTypes: []EventType{EventPaymentFailed, EventRefundCompleted}},
+		{PartnerID: "initech", URL: "https://initech.example/hooks", EventTypes: []EventType{EventRefundCompleted}},
+	} {
+		if err := registry.CreateEndpoint(ctx, e); err != nil {
+			t.Fatalf("CreateEndpoint failed: %v", err)
+		}
+	}
+	paused := store.endpoints[1]
+	publisher := &webhookPublisher{}
+	router := NewRouter(publisher)
+	router.SetEndpoints(registry)
+
+	data, _ := json.Marshal(PaymentEventData{PaymentID: "pay_1", UserID: "user_1", Amount: 1000})
+	if err := router.Route(ctx, &Event{ID: "evt_1", Type: EventPaymentFailed, Data: data}); err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if len(publisher.webhooks) != 2 {
+		t.Fatalf("got %d webhooks, want one per subscribed endpoint", len(publisher.webhooks))
+	}
+	for i, partner := range []string{"acme", "globex"} {
+		task := publisher.webhooks[i]
+		endpoint := store.endpoints[i]
+		if task.PartnerID != partner || task.URL != endpoint.URL || task.Secret != endpoint.Secret || task.ID != "webhook_evt_1_"+endpoint.ID {
+			t.Errorf("got %+v, want the webhook to %s", task, partner)
+		}
+	}
+
+	// Inactive endpoints get nothing
+	active := false
+	if err := registry.UpdateEndpoint(ctx, paused, nil, nil, &active); err != nil {
+		t.Fatalf("UpdateEndpoint failed: %v", err)
+	}
+	publisher.webhooks = nil
+	if err := router.Route(ctx, &Event{ID: "evt_2", Type: EventPaymentFailed, Data: data}); err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if len(publisher.webhooks) != 1 || publisher.webhooks[0].PartnerID != "acme" {
+		t.Errorf("got %+v, want only acme's webhook", publisher.webhooks)
+	}
+
+	// Endpoints that cannot be read fail the event, to be redelivered
+	store.err = errors.New("database down")
+	if err := router.Route(ctx, &Event{ID: "evt_3", Type: EventPaymentFailed, Data: data}); err == nil {
+		t.Error("expected the event to fail")
+	}
+}
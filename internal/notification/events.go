@@ -21,6 +21,8 @@ const (
 	// Auth events
 	EventUserRegistered EventType = "user.registered"
 	EventPasswordReset  EventType = "password.reset"
+	EventSecurityCode   EventType = "auth.security_code" // Second factor of a login
+	EventOrgInvitation  EventType = "org.invitation"
 
 	// Webhook events
 	EventWebhookDelivery EventType = "webhook.delivery"
@@ -67,6 +69,17 @@ type UserEventData struct {
 	Token     string `json:"token,omitempty"`
 }
 
+// InvitationEventData contains an invitation to join an organization
+type InvitationEventData struct {
+	InvitationID string `json:"invitation_id"`
+	OrgID        string `json:"org_id"`
+	OrgName      string `json:"org_name"`
+	Role         string `json:"role"`
+	Email        string `json:"email"`
+	InvitedBy    string `json:"invited_by"`
+	Link         string `json:"link"`
+}
+
 // WebhookDeliveryData contains webhook delivery task data
 type WebhookDeliveryData struct {
 	WebhookID  string            `json:"webhook_id"`
@@ -121,6 +134,15 @@ func (e *Event) ParseUserEventData() (*UserEventData, error) {
 	return &data, nil
 }
 
+// ParseInvitationEventData parses the event data as InvitationEventData
+func (e *Event) ParseInvitationEventData() (*InvitationEventData, error) {
+	var data InvitationEventData
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
 // generateEventID creates a unique event ID
 func generateEventID() string {
 	return "evt_" + time.Now().Format("20060102150405") + "_" + randomString(8)
@@ -0,0 +1,235 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ProviderFCM is Firebase Cloud Messaging, which sends web pushes
+const ProviderFCM = "fcm"
+
+const (
+	fcmAPIURL = "https://fcm.googleapis.com/v1"
+	fcmScope  = "https://www.googleapis.com/auth/firebase.messaging"
+)
+
+// fcmRetryableCodes are FCM errors worth sending again. Others, e.g.
+// UNREGISTERED for a token the browser dropped, are permanent.
+var fcmRetryableCodes = map[string]bool{
+	"UNAVAILABLE":     true,
+	"INTERNAL":        true,
+	"QUOTA_EXCEEDED":  true,
+	"UNAUTHENTICATED": true, // The access token expired, a new one is fetched
+}
+
+// ServiceAccount is the part of a Google service account key FCM needs
+type ServiceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// LoadServiceAccount reads a service account key file
+func LoadServiceAccount(path string) (*ServiceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var account ServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("invalid service account key: %w", err)
+	}
+	if account.ProjectID == "" || account.ClientEmail == "" || account.PrivateKey == "" || account.TokenURI == "" {
+		return nil, fmt.Errorf("service account key is incomplete")
+	}
+	return &account, nil
+}
+
+// FCMDriver sends web pushes through the FCM HTTP v1 API. Recipients are
+// the FCM registration tokens of the users' browsers.
+type FCMDriver struct {
+	account    *ServiceAccount
+	baseURL    string
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+func NewFCMDriver(account *ServiceAccount) *FCMDriver {
+	return &FCMDriver{
+		account:    account,
+		baseURL:    fcmAPIURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (d *FCMDriver) Channel() Channel {
+	return Web
+}
+
+func (d *FCMDriver) Provider() string {
+	return ProviderFCM
+}
+
+func (d *FCMDriver) Send(ctx context.Context, recipient, title, content string) error {
+	_, err := d.SendMessage(ctx, recipient, title, content)
+	return err
+}
+
+// SendMessage sends the push, returning the message name FCM gave it
+func (d *FCMDriver) SendMessage(ctx context.Context, recipient, title, content string) (string, error) {
+	token, err := d.token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to authenticate with FCM: %w", err)
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"token":        recipient,
+			"notification": map[string]string{"title": title, "body": content},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	endpoint := fmt.Sprintf("%s/projects/%s/messages:send", d.baseURL, d.account.ProjectID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send push via FCM: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read FCM response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		sendErr := fcmError(resp.StatusCode, body)
+		if sendErr.Code == "UNAUTHENTICATED" {
+			d.forgetToken()
+		}
+		return "", sendErr
+	}
+
+	var message struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &message); err != nil || message.Name == "" {
+		return "", fmt.Errorf("unexpected FCM response: %s", body)
+	}
+	return message.Name, nil
+}
+
+// token returns an OAuth access token for FCM, exchanging a JWT signed with
+// the service account's key when the cached one is about to expire
+func (d *FCMDriver) token(ctx context.Context) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.accessToken != "" && time.Now().Add(time.Minute).Before(d.expiresAt) {
+		return d.accessToken, nil
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(d.account.PrivateKey))
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   d.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   d.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned status: %d", resp.StatusCode)
+	}
+
+	var grant struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&grant); err != nil {
+		return "", err
+	}
+	d.accessToken = grant.AccessToken
+	d.expiresAt = now.Add(time.Duration(grant.ExpiresIn) * time.Second)
+	return d.accessToken, nil
+}
+
+func (d *FCMDriver) forgetToken() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.accessToken = ""
+}
+
+// fcmError classifies FCM's answer to a failed send by its error code
+func fcmError(status int, body []byte) *SendError {
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &apiErr)
+
+	code := apiErr.Error.Status
+	for _, detail := range apiErr.Error.Details {
+		if detail.ErrorCode != "" {
+			code = detail.ErrorCode
+		}
+	}
+	retryable := fcmRetryableCodes[code]
+	if code == "" {
+		retryable = retryableStatus(status)
+	}
+	return &SendError{
+		Provider:  ProviderFCM,
+		Status:    status,
+		Code:      code,
+		Message:   apiErr.Error.Message,
+		Retryable: retryable,
+	}
+}
@@ -0,0 +1,249 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrInboxNotFound = errors.New("notification not found")
+
+// InboxFilter selects the notifications of an inbox, newest first
+type InboxFilter struct {
+	Unread   bool       // Only those not read yet
+	Archived bool       // The archived ones rather than the others
+	Before   *time.Time // Older than this, to page through the inbox
+	Limit    int
+}
+
+// InboxStore reads and updates the web notifications sent to users, which
+// make up their in-app inboxes
+type InboxStore interface {
+	ListInbox(ctx context.Context, userID string, filter InboxFilter) ([]Notification, error)
+	CountUnread(ctx context.Context, userID string) (int, error)
+	// MarkRead, MarkAllRead and Archive report how many of the user's
+	// notifications they found
+	MarkRead(ctx context.Context, userID, id string) (int64, error)
+	MarkAllRead(ctx context.Context, userID string) (int64, error)
+	Archive(ctx context.Context, userID, id string) (int64, error)
+}
+
+// InboxBroker carries new in-app notifications to the users' open inboxes,
+// across instances of the service
+type InboxBroker interface {
+	Publish(ctx context.Context, n *Notification) error
+	// Subscribe delivers the user's new notifications until ctx is done,
+	// then closes the channel
+	Subscribe(ctx context.Context, userID string) (<-chan Notification, error)
+}
+
+// Inbox serves the users' in-app notifications and pushes new ones to them.
+// A nil Inbox pushes nothing.
+type Inbox struct {
+	store  InboxStore
+	broker InboxBroker
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func NewInbox(store InboxStore, broker InboxBroker) *Inbox {
+	return &Inbox{store: store, broker: broker, closed: make(chan struct{})}
+}
+
+// List returns the user's notifications matching the filter and their
+// unread count
+func (i *Inbox) List(ctx context.Context, userID string, filter InboxFilter) ([]Notification, int, error) {
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 50
+	}
+	notifications, err := i.store.ListInbox(ctx, userID, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	unread, err := i.store.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return notifications, unread, nil
+}
+
+func (i *Inbox) Unread(ctx context.Context, userID string) (int, error) {
+	return i.store.CountUnread(ctx, userID)
+}
+
+// MarkRead marks one of the user's notifications read
+func (i *Inbox) MarkRead(ctx context.Context, userID, id string) error {
+	return found(i.store.MarkRead(ctx, userID, id))
+}
+
+// MarkAllRead marks every notification of the user read, returning how
+// many were unread
+func (i *Inbox) MarkAllRead(ctx context.Context, userID string) (int64, error) {
+	return i.store.MarkAllRead(ctx, userID)
+}
+
+// Archive moves one of the user's notifications out of their inbox, read
+func (i *Inbox) Archive(ctx context.Context, userID, id string) error {
+	return found(i.store.Archive(ctx, userID, id))
+}
+
+func found(n int64, err error) error {
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInboxNotFound
+	}
+	return nil
+}
+
+// Notify pushes a web notification just sent to its user's open inboxes.
+// Failing to is logged, the notification is in the inbox regardless.
+func (i *Inbox) Notify(ctx context.Context, n *Notification) {
+	if i == nil || n == nil || n.UserID == "" {
+		return
+	}
+	if err := i.broker.Publish(ctx, n); err != nil {
+		log.Printf("Failed to push notification %s to the inbox of %s: %v", n.ID, n.UserID, err)
+	}
+}
+
+// Subscribe delivers the user's new notifications until ctx is done or the
+// inbox is closed
+func (i *Inbox) Subscribe(ctx context.Context, userID string) (<-chan Notification, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	ch, err := i.broker.Subscribe(ctx, userID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-i.closed:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+	return ch, nil
+}
+
+// Close ends the subscriptions, e.g. so that open streams do not hold up
+// shutdown
+func (i *Inbox) Close() {
+	i.closeOnce.Do(func() { close(i.closed) })
+}
+
+// subscriberBuffer is how many notifications a slow subscriber may fall
+// behind before it misses some
+const subscriberBuffer = 16
+
+// LocalInboxBroker carries notifications to the inboxes open on this
+// instance only, for when there is no Redis
+type LocalInboxBroker struct {
+	mu   sync.Mutex
+	subs map[string]map[chan Notification]struct{}
+}
+
+func NewLocalInboxBroker() *LocalInboxBroker {
+	return &LocalInboxBroker{subs: make(map[string]map[chan Notification]struct{})}
+}
+
+func (b *LocalInboxBroker) Publish(ctx context.Context, n *Notification) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[n.UserID] {
+		select {
+		case ch <- *n:
+		default:
+			log.Printf("Inbox subscriber of %s is behind, dropping notification %s", n.UserID, n.ID)
+		}
+	}
+	return nil
+}
+
+func (b *LocalInboxBroker) Subscribe(ctx context.Context, userID string) (<-chan Notification, error) {
+	ch := make(chan Notification, subscriberBuffer)
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[chan Notification]struct{})
+	}
+	b.subs[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subs[userID], ch)
+		if len(b.subs[userID]) == 0 {
+			delete(b.subs, userID)
+		}
+		b.mu.Unlock()
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// RedisInboxBroker carries notifications over Redis pub/sub, to the inboxes
+// open on any instance
+type RedisInboxBroker struct {
+	client *redis.Client
+}
+
+func NewRedisInboxBroker(client *redis.Client) *RedisInboxBroker {
+	return &RedisInboxBroker{client: client}
+}
+
+func inboxChannel(userID string) string {
+	return "notif:inbox:" + userID
+}
+
+func (b *RedisInboxBroker) Publish(ctx context.Context, n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, inboxChannel(n.UserID), body).Err()
+}
+
+func (b *RedisInboxBroker) Subscribe(ctx context.Context, userID string) (<-chan Notification, error) {
+	pubsub := b.client.Subscribe(ctx, inboxChannel(userID))
+	// Wait for the subscription, so that its failure is reported
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+
+	ch := make(chan Notification, subscriberBuffer)
+	go func() {
+		defer close(ch)
+		defer func() { _ = pubsub.Close() }()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var n Notification
+				if err := json.Unmarshal([]byte(msg.Payload), &n); err != nil {
+					log.Printf("Ignoring malformed inbox notification: %v", err)
+					continue
+				}
+				select {
+				case ch <- n:
+				default:
+					log.Printf("Inbox subscriber of %s is behind, dropping notification %s", userID, n.ID)
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
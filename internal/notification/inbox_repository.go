@@ -0,0 +1,86 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+)
+
+// inboxCondition selects the web notifications sent to a user, which make
+// up their inbox
+const inboxCondition = `user_id = $1 AND channel = 'web' AND status IN ('sent', 'delivered', 'opened')`
+
+// ListInbox retrieves the user's web notifications matching the filter,
+// newest first.
+func (r *Repository) ListInbox(ctx context.Context, userID string, filter InboxFilter) ([]Notification, error) {
+	query := `
+		SELECT id, user_id, recipient, channel, COALESCE(title, ''), content, status, created_at, sent_at, read_at, archived_at
+		FROM notifications WHERE ` + inboxCondition
+	args := []interface{}{userID}
+	if filter.Archived {
+		query += ` AND archived_at IS NOT NULL`
+	} else {
+		query += ` AND archived_at IS NULL`
+	}
+	if filter.Unread {
+		query += ` AND read_at IS NULL`
+	}
+	if filter.Before != nil {
+		args = append(args, *filter.Before)
+		query += fmt.Sprintf(` AND created_at < $%d`, len(args))
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY created_at DESC LIMIT $%d`, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Recipient, &n.Channel, &n.Title, &n.Content, &n.Status,
+			&n.CreatedAt, &n.SentAt, &n.ReadAt, &n.ArchivedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// CountUnread counts the unread notifications in the user's inbox.
+func (r *Repository) CountUnread(ctx context.Context, userID string) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM notifications WHERE `+inboxCondition+` AND read_at IS NULL AND archived_at IS NULL`, userID,
+	).Scan(&n)
+	return n, err
+}
+
+// MarkRead marks one of the user's web notifications read.
+func (r *Repository) MarkRead(ctx context.Context, userID, id string) (int64, error) {
+	return r.updateInbox(ctx,
+		`UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE `+inboxCondition+` AND id::text = $2`, userID, id)
+}
+
+// MarkAllRead marks the unread notifications in the user's inbox read.
+func (r *Repository) MarkAllRead(ctx context.Context, userID string) (int64, error) {
+	return r.updateInbox(ctx,
+		`UPDATE notifications SET read_at = NOW() WHERE `+inboxCondition+` AND read_at IS NULL AND archived_at IS NULL`, userID)
+}
+
+// Archive archives one of the user's web notifications, marking it read.
+func (r *Repository) Archive(ctx context.Context, userID, id string) (int64, error) {
+	return r.updateInbox(ctx, `
+		UPDATE notifications SET archived_at = COALESCE(archived_at, NOW()), read_at = COALESCE(read_at, NOW())
+		WHERE `+inboxCondition+` AND id::text = $2`, userID, id)
+}
+
+func (r *Repository) updateInbox(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,132 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// memoryInboxStore keeps one user's inbox in a slice
+type memoryInboxStore struct {
+	notifications []Notification
+}
+
+func (s *memoryInboxStore) ListInbox(ctx context.Context, userID string, filter InboxFilter) ([]Notification, error) {
+	var notifications []Notification
+	for _, n := range s.notifications {
+		if n.UserID == userID && (n.ArchivedAt != nil) == filter.Archived && (!filter.Unread || n.ReadAt == nil) {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
+
+func (s *memoryInboxStore) CountUnread(ctx context.Context, userID string) (int, error) {
+	unread, _ := s.ListInbox(ctx, userID, InboxFilter{Unread: true})
+	return len(unread), nil
+}
+
+func (s *memoryInboxStore) MarkRead(ctx context.Context, userID, id string) (int64, error) {
+	return s.update(userID, id, func(n *Notification, now time.Time) { n.ReadAt = &now }), nil
+}
+
+func (s *memoryInboxStore) MarkAllRead(ctx context.Context, userID string) (int64, error) {
+	return s.update(userID, "", func(n *Notification, now time.Time) {
+		if n.ReadAt == nil {
+			n.ReadAt = &now
+		}
+	}), nil
+}
+
+func (s *memoryInboxStore) Archive(ctx context.Context, userID, id string) (int64, error) {
+	return s.update(userID, id, func(n *Notification, now time.Time) { n.ReadAt, n.ArchivedAt = &now, &now }), nil
+}
+
+// update applies a change to the user's notification with the id, or to
+// all of them for an empty id
+func (s *memoryInboxStore) update(userID, id string, apply func(n *Notification, now time.Time)) int64 {
+	var updated int64
+	for i := range s.notifications {
+		n := &s.notifications[i]
+		if n.UserID == userID && (id == "" || n.ID == id) {
+			apply(n, time.Now())
+			updated++
+		}
+	}
+	return updated
+}
+
+func TestInbox_ReadsAndArchives(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryInboxStore{notifications: []Notification{
+		{ID: "n1", UserID: "user-1", Channel: Web},
+		{ID: "n2", UserID: "user-1", Channel: Web},
+		{ID: "n3", UserID: "user-2", Channel: Web},
+	}}
+	inbox := NewInbox(store, NewLocalInboxBroker())
+
+	if err := inbox.MarkRead(ctx, "user-1", "n1"); err != nil {
+		t.Fatalf("MarkRead failed: %v", err)
+	}
+	// Others' notifications are not found
+	if err := inbox.MarkRead(ctx, "user-1", "n3"); !errors.Is(err, ErrInboxNotFound) {
+		t.Errorf("got %v, want ErrInboxNotFound", err)
+	}
+	notifications, unread, err := inbox.List(ctx, "user-1", InboxFilter{})
+	if err != nil || len(notifications) != 2 || unread != 1 {
+		t.Errorf("got %d notifications, %d unread (%v), want 2 with 1 unread", len(notifications), unread, err)
+	}
+
+	if err := inbox.Archive(ctx, "user-1", "n2"); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	notifications, unread, _ = inbox.List(ctx, "user-1", InboxFilter{})
+	if len(notifications) != 1 || unread != 0 {
+		t.Errorf("got %d notifications, %d unread, want the archived one gone", len(notifications), unread)
+	}
+	archived, _, _ := inbox.List(ctx, "user-1", InboxFilter{Archived: true})
+	if len(archived) != 1 || archived[0].ID != "n2" {
+		t.Errorf("got %+v, want the archived notification", archived)
+	}
+}
+
+func TestWorker_PushesWebNotificationsToTheInbox(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	inbox := NewInbox(&memoryInboxStore{}, NewLocalInboxBroker())
+	notifications, err := inbox.Subscribe(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	worker := NewWorker(Web, &countingDriver{}, nil, nil)
+	worker.SetTracker(NewDeliveryTracker(newMemoryDeliveryStore()))
+	worker.SetInbox(inbox)
+	body, _ := json.Marshal(NotificationTask{ID: "task-1", Channel: Web, Recipient: "user-1", TemplateID: "payment_success",
+		Data: map[string]string{"UserID": "user-1"}})
+	if err := worker.ProcessTask(ctx, body); err != nil {
+		t.Fatalf("ProcessTask failed: %v", err)
+	}
+
+	select {
+	case n := <-notifications:
+		if n.UserID != "user-1" || n.Channel != Web {
+			t.Errorf("got %+v, want the web notification to user-1", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the notification to be pushed")
+	}
+
+	// Closing the inbox ends the subscriptions
+	inbox.Close()
+	select {
+	case _, ok := <-notifications:
+		if ok {
+			t.Error("expected the subscription to end")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the subscription to end")
+	}
+}
@@ -0,0 +1,161 @@
+package notification
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidLocale = errors.New("locale must be a language, optionally with a region, e.g. fr or fr-CA")
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// NormalizeLocale returns the locale in its canonical form, e.g. fr-CA for
+// fr_ca
+func NormalizeLocale(locale string) (string, error) {
+	lang, region, hasRegion := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+	locale = strings.ToLower(lang)
+	if hasRegion {
+		locale += "-" + strings.ToUpper(region)
+	}
+	if !localePattern.MatchString(locale) {
+		return "", ErrInvalidLocale
+	}
+	return locale, nil
+}
+
+// numberFormat is how a language writes numbers and amounts
+type numberFormat struct {
+	decimal     string
+	group       string
+	symbolAfter bool   // 10,00 € rather than €10.00
+	datePattern string // time.Format layout of dates
+}
+
+// numberFormats by language, and by locale where the region writes
+// differently. Others are written as in English.
+var numberFormats = map[string]numberFormat{
+	"en":    {decimal: ".", group: ",", datePattern: "Jan 2, 2006"},
+	"en-GB": {decimal: ".", group: ",", datePattern: "2 Jan 2006"},
+	"fr":    {decimal: ",", group: "\u00a0", symbolAfter: true, datePattern: "02/01/2006"},
+	"fr-CA": {decimal: ",", group: "\u00a0", symbolAfter: true, datePattern: "2006-01-02"},
+	"fr-CH": {decimal: ".", group: "'", symbolAfter: true, datePattern: "02.01.2006"},
+	"de":    {decimal: ",", group: ".", symbolAfter: true, datePattern: "02.01.2006"},
+	"de-CH": {decimal: ".", group: "'", symbolAfter: true, datePattern: "02.01.2006"},
+	"es":    {decimal: ",", group: ".", symbolAfter: true, datePattern: "02/01/2006"},
+	"es-MX": {decimal: ".", group: ",", datePattern: "02/01/2006"},
+	"it":    {decimal: ",", group: ".", symbolAfter: true, datePattern: "02/01/2006"},
+	"pt":    {decimal: ",", group: ".", symbolAfter: true, datePattern: "02/01/2006"},
+	"pt-BR": {decimal: ",", group: ".", datePattern: "02/01/2006"},
+	"nl":    {decimal: ",", group: ".", datePattern: "02-01-2006"},
+	"ar":    {decimal: ".", group: ",", symbolAfter: true, datePattern: "02/01/2006"},
+}
+
+// currencySymbols of the common currencies. Others are written with their
+// code.
+var currencySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "BRL": "R$", "CAD": "$", "CHF": "CHF", "EGP": "E£", "MXN": "$",
+}
+
+// currencyDecimals of the currencies without two minor digits
+var currencyDecimals = map[string]int{
+	"JPY": 0, "KRW": 0, "CLP": 0, "VND": 0, "BHD": 3, "KWD": 3, "JOD": 3, "OMR": 3, "TND": 3,
+}
+
+// currencyDecimalsOf returns the minor digits of the currency
+func currencyDecimalsOf(currency string) int {
+	if decimals, ok := currencyDecimals[strings.ToUpper(currency)]; ok {
+		return decimals
+	}
+	return 2
+}
+
+// formatFor returns the format of the locale, falling back through its
+// language to English
+func formatFor(locale string) numberFormat {
+	for _, candidate := range candidateLocales(locale) {
+		if f, ok := numberFormats[candidate]; ok {
+			return f
+		}
+	}
+	return numberFormats[DefaultLocale]
+}
+
+// FormatNumber writes an amount in minor units as a number of the locale,
+// e.g. 123456 with 2 decimals as 1,234.56 in en and 1.234,56 in de
+func FormatNumber(minor int64, decimals int, locale string) string {
+	f := formatFor(locale)
+	sign := ""
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	digits := strconv.FormatInt(minor, 10)
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-decimals], digits[len(digits)-decimals:]
+
+	var b strings.Builder
+	for i, d := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.group)
+		}
+		b.WriteRune(d)
+	}
+	if decimals > 0 {
+		b.WriteString(f.decimal)
+		b.WriteString(fraction)
+	}
+	return sign + b.String()
+}
+
+// FormatMoney writes an amount in minor units with its currency, e.g.
+// $1,234.56 in en and 1.234,56 € in de, with a non-breaking space
+func FormatMoney(minor int64, currency, locale string) string {
+	currency = strings.ToUpper(currency)
+	sign := ""
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	number := FormatNumber(minor, currencyDecimalsOf(currency), locale)
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		return sign + number + "\u00a0" + currency
+	}
+	if formatFor(locale).symbolAfter {
+		return sign + number + "\u00a0" + symbol
+	}
+	return sign + symbol + number
+}
+
+// FormatDate writes a date as the locale does
+func FormatDate(t time.Time, locale string) string {
+	return t.Format(formatFor(locale).datePattern)
+}
+
+// Localize returns the template data with its amounts and dates written
+// for its Locale: Amount from AmountMinor, Money with the currency, and
+// Date from Timestamp. Data without them is returned as is.
+func Localize(data map[string]string) map[string]string {
+	minor, hasAmount := data["AmountMinor"]
+	timestamp, hasDate := data["Timestamp"]
+	if !hasAmount && !hasDate {
+		return data
+	}
+
+	localized := make(map[string]string, len(data)+2)
+	for k, v := range data {
+		localized[k] = v
+	}
+	locale := data["Locale"]
+	if amount, err := strconv.ParseInt(minor, 10, 64); hasAmount && err == nil {
+		localized["Amount"] = FormatNumber(amount, currencyDecimalsOf(data["Currency"]), locale)
+		localized["Money"] = FormatMoney(amount, data["Currency"], locale)
+	}
+	if t, err := time.Parse(time.RFC3339, timestamp); hasDate && err == nil {
+		localized["Date"] = FormatDate(t, locale)
+	}
+	return localized
+}
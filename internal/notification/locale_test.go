@@ -0,0 +1,111 @@
+package notification
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		in, want string
+		valid    bool
+	}{
+		{"fr", "fr", true},
+		{"fr_ca", "fr-CA", true},
+		{"PT-br", "pt-BR", true},
+		{"", "", false},
+		{"french", "", false},
+		{"fr-CA-x", "", false},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeLocale(tt.in)
+		if (err == nil) != tt.valid || got != tt.want {
+			t.Errorf("%q: got %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		minor    int64
+		currency string
+		locale   string
+		want     string
+	}{
+		{123456, "USD", "en", "$1,234.56"},
+		{123456, "EUR", "de", "1.234,56\u00a0€"},
+		{123456, "EUR", "de-AT", "1.234,56\u00a0€"}, // The language's format
+		{123456, "CAD", "fr-CA", "1\u00a0234,56\u00a0$"},
+		{123456, "CHF", "de-CH", "1'234.56\u00a0CHF"},
+		{5, "USD", "en", "$0.05"},
+		{-1050, "GBP", "en-GB", "-£10.50"},
+		{1500, "JPY", "en", "¥1,500"},
+		{1000, "SEK", "en", "10.00\u00a0SEK"},
+		{1000, "USD", "xx", "$10.00"}, // English for unknown locales
+	}
+	for _, tt := range tests {
+		if got := FormatMoney(tt.minor, tt.currency, tt.locale); got != tt.want {
+			t.Errorf("%d %s in %s: got %q, want %q", tt.minor, tt.currency, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	data := map[string]string{
+		"AmountMinor": "250000",
+		"Amount":      "2,500.00",
+		"Currency":    "EUR",
+		"Timestamp":   "2024-03-05T10:00:00Z",
+		"Locale":      "fr",
+	}
+	got := Localize(data)
+	if got["Amount"] != "2\u00a0500,00" || got["Money"] != "2\u00a0500,00\u00a0€" || got["Date"] != "05/03/2024" {
+		t.Errorf("got %v", got)
+	}
+	if data["Amount"] != "2,500.00" {
+		t.Error("Localize changed its input")
+	}
+
+	plain := map[string]string{"Code": "1234"}
+	if got := Localize(plain); len(got) != 1 {
+		t.Errorf("got %v, want the data as is", got)
+	}
+}
+
+func TestRenderer_RendersInTheUsersLocale(t *testing.T) {
+	ctx := context.Background()
+	templates := &memoryTemplateStore{}
+	service := NewTemplateService(templates, nil)
+	renderer := NewRenderer(templates)
+	for _, tmpl := range []*Template{
+		{Name: "payment_success", Channel: SMS, Locale: "en", Body: "Paid {{.Money}} on {{.Date}}"},
+		{Name: "payment_success", Channel: SMS, Locale: "fr", Body: "Payé {{.Money}} le {{.Date}}"},
+	} {
+		if err := service.CreateTemplate(ctx, tmpl); err != nil {
+			t.Fatalf("CreateTemplate failed: %v", err)
+		}
+		if err := service.PublishTemplate(ctx, tmpl); err != nil {
+			t.Fatalf("PublishTemplate failed: %v", err)
+		}
+	}
+
+	prefs := NewPreferences(&memoryPreferenceStore{locales: map[string]string{"user_1": "fr-CA"}})
+	data := prefs.WithLocale(ctx, map[string]string{
+		"UserID":      "user_1",
+		"AmountMinor": "1050",
+		"Currency":    "CAD",
+		"Timestamp":   time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC).Format(time.RFC3339),
+	})
+
+	// fr-CA has no template of its own and falls back to fr, written the
+	// Canadian way
+	rendered, err := renderer.Render(ctx, "payment_success", SMS, data)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(rendered.Body, "Payé 10,50\u00a0$ le 2024-03-05") {
+		t.Errorf("got %q", rendered.Body)
+	}
+}
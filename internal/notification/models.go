@@ -14,10 +14,19 @@ const (
 
 type Status string
 
+// Notification statuses. Sent notifications move on as their provider
+// reports delivering, bouncing or failing them, and opens of delivered
+// emails.
 const (
-	StatusPending Status = "pending"
-	StatusSent    Status = "sent"
-	StatusFailed  Status = "failed"
+	StatusPending   Status = "pending"
+	StatusSent      Status = "sent"
+	StatusDelivered Status = "delivered"
+	StatusBounced   Status = "bounced"
+	StatusFailed    Status = "failed"
+	StatusOpened    Status = "opened"
+	// StatusSuppressed notifications were held back by rate limiting or
+	// deduplication and never sent
+	StatusSuppressed Status = "suppressed"
 )
 
 type Notification struct {
@@ -30,6 +39,14 @@ type Notification struct {
 	Status    Status     `json:"status"`
 	CreatedAt time.Time  `json:"created_at"`
 	SentAt    *time.Time `json:"sent_at,omitempty"`
+	// The provider that sent the notification and its ID there, which its
+	// delivery callbacks refer to
+	Provider          string `json:"provider,omitempty"`
+	ProviderMessageID string `json:"provider_message_id,omitempty"`
+	// When the user read and archived the notification, for web
+	// notifications in their inbox
+	ReadAt     *time.Time `json:"read_at,omitempty"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
 }
 
 type NotificationRequest struct {
@@ -38,4 +55,14 @@ type NotificationRequest struct {
 	Channel    Channel           `json:"channel"`
 	TemplateID string            `json:"template_id"`
 	Data       map[string]string `json:"data"`
+	// EventType is what the notification is about, for the user's
+	// preferences; empty only matches their preferences for AllEvents
+	EventType EventType `json:"event_type,omitempty"`
+	// Locale the notification is written in, e.g. fr-CA. Without one, the
+	// user's chosen locale or English.
+	Locale string `json:"locale,omitempty"`
+	// SendAt or Delay, e.g. 2h, schedules the notification instead of
+	// sending it right away
+	SendAt *time.Time `json:"send_at,omitempty"`
+	Delay  string     `json:"delay,omitempty"`
 }
@@ -0,0 +1,160 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// AllEvents stands for every event type in a preference, e.g. to opt out of
+// SMS altogether
+const AllEvents EventType = "*"
+
+// MandatoryEvents are security messages. They are delivered whatever the
+// user's preferences, so nobody can opt out of password resets or of
+// verifying their account.
+var MandatoryEvents = map[EventType]bool{
+	EventUserRegistered: true,
+	EventPasswordReset:  true,
+	EventSecurityCode:   true,
+}
+
+var (
+	ErrInvalidChannel    = errors.New("channel must be email, sms or web")
+	ErrMandatoryEvent    = errors.New("security notifications cannot be turned off")
+	ErrPreferenceMissing = errors.New("preference not found")
+)
+
+// Preference turns a channel on or off for a user, for one event type or
+// for AllEvents. A preference for the event type wins over the one for
+// AllEvents; without either the channel is on.
+type Preference struct {
+	UserID    string    `json:"user_id"`
+	Channel   Channel   `json:"channel"`
+	EventType EventType `json:"event_type"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks the preference, defaulting its event type to AllEvents
+func (p *Preference) Validate() error {
+	switch p.Channel {
+	case Email, SMS, Web:
+	default:
+		return ErrInvalidChannel
+	}
+	if p.EventType == "" {
+		p.EventType = AllEvents
+	}
+	if !p.Enabled && MandatoryEvents[p.EventType] {
+		return ErrMandatoryEvent
+	}
+	return nil
+}
+
+// PreferenceStore keeps users' preferences
+type PreferenceStore interface {
+	GetPreferences(ctx context.Context, userID string) ([]Preference, error)
+	SetPreference(ctx context.Context, p *Preference) error
+	// DeletePreference fails with ErrPreferenceMissing when the user has
+	// no such preference
+	DeletePreference(ctx context.Context, userID string, channel Channel, eventType EventType) error
+	// GetLocale returns "" for users who have not chosen a locale
+	GetLocale(ctx context.Context, userID string) (string, error)
+	SetLocale(ctx context.Context, userID, locale string) error
+}
+
+// Allows reports whether the preferences let the event be sent on the
+// channel
+func Allows(prefs []Preference, channel Channel, eventType EventType) bool {
+	if MandatoryEvents[eventType] {
+		return true
+	}
+	allowed := true
+	for _, p := range prefs {
+		if p.Channel != channel {
+			continue
+		}
+		if p.EventType == eventType {
+			return p.Enabled
+		}
+		if p.EventType == AllEvents {
+			allowed = p.Enabled
+		}
+	}
+	return allowed
+}
+
+// Preferences enforces users' preferences when notifications are routed
+// and sent
+type Preferences struct {
+	store PreferenceStore
+}
+
+func NewPreferences(store PreferenceStore) *Preferences {
+	return &Preferences{store: store}
+}
+
+// Allowed reports whether the user can be sent the event on the channel.
+// Notifications that are not for a user, and security messages, always
+// are.
+func (p *Preferences) Allowed(ctx context.Context, userID string, channel Channel, eventType EventType) (bool, error) {
+	if p == nil || userID == "" || MandatoryEvents[eventType] {
+		return true, nil
+	}
+	prefs, err := p.store.GetPreferences(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return Allows(prefs, channel, eventType), nil
+}
+
+// Filter returns the channels of the list the user can be sent the event
+// on
+func (p *Preferences) Filter(ctx context.Context, userID string, eventType EventType, channels []Channel) ([]Channel, error) {
+	if p == nil || userID == "" || MandatoryEvents[eventType] {
+		return channels, nil
+	}
+	prefs, err := p.store.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var allowed []Channel
+	for _, c := range channels {
+		if Allows(prefs, c, eventType) {
+			allowed = append(allowed, c)
+		}
+	}
+	return allowed, nil
+}
+
+// Locale returns the locale the user chose to be sent notifications in, or
+// "" for the default
+func (p *Preferences) Locale(ctx context.Context, userID string) (string, error) {
+	if p == nil || userID == "" {
+		return "", nil
+	}
+	return p.store.GetLocale(ctx, userID)
+}
+
+// WithLocale returns the template data in the user's chosen locale, unless
+// it has a locale already. Failing to read the locale leaves the default.
+func (p *Preferences) WithLocale(ctx context.Context, data map[string]string) map[string]string {
+	if data["Locale"] != "" {
+		return data
+	}
+	locale, err := p.Locale(ctx, data["UserID"])
+	if err != nil {
+		log.Printf("Failed to get locale of user %s: %v", data["UserID"], err)
+	}
+	if locale == "" {
+		return data
+	}
+	localized := make(map[string]string, len(data)+1)
+	for k, v := range data {
+		localized[k] = v
+	}
+	localized["Locale"] = locale
+	return localized
+}
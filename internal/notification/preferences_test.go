@@ -0,0 +1,142 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// memoryPreferenceStore keeps preferences by user
+type memoryPreferenceStore struct {
+	prefs   map[string][]Preference
+	locales map[string]string
+	err     error
+}
+
+func (s *memoryPreferenceStore) GetPreferences(ctx context.Context, userID string) ([]Preference, error) {
+	return s.prefs[userID], s.err
+}
+
+func (s *memoryPreferenceStore) SetPreference(ctx context.Context, p *Preference) error {
+	s.prefs[p.UserID] = append(s.prefs[p.UserID], *p)
+	return nil
+}
+
+func (s *memoryPreferenceStore) DeletePreference(ctx context.Context, userID string, channel Channel, eventType EventType) error {
+	return nil
+}
+
+func (s *memoryPreferenceStore) GetLocale(ctx context.Context, userID string) (string, error) {
+	return s.locales[userID], s.err
+}
+
+func (s *memoryPreferenceStore) SetLocale(ctx context.Context, userID, locale string) error {
+	s.locales[userID] = locale
+	return nil
+}
+
+// recordingPublisher records the queues tasks were published to
+type recordingPublisher struct {
+	queues []string
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, queue string, body []byte) error {
+	p.queues = append(p.queues, queue)
+	return nil
+}
+
+func TestAllows(t *testing.T) {
+	prefs := []Preference{
+		{Channel: SMS, EventType: AllEvents, Enabled: false},
+		{Channel: SMS, EventType: EventPaymentFailed, Enabled: true},
+		{Channel: Email, EventType: EventPaymentSucceeded, Enabled: false},
+	}
+	tests := []struct {
+		channel   Channel
+		eventType EventType
+		want      bool
+	}{
+		{SMS, EventRefundCompleted, false},
+		{SMS, EventPaymentFailed, true}, // The event's preference wins
+		{Email, EventPaymentSucceeded, false},
+		{Email, EventPaymentFailed, true}, // On by default
+		{Web, EventPaymentSucceeded, true},
+		{SMS, EventPasswordReset, true}, // Security messages are mandatory
+		{SMS, EventSecurityCode, true},
+	}
+	for _, tt := range tests {
+		if got := Allows(prefs, tt.channel, tt.eventType); got != tt.want {
+			t.Errorf("%s for %s: got %v, want %v", tt.channel, tt.eventType, got, tt.want)
+		}
+	}
+}
+
+func TestPreference_Validate(t *testing.T) {
+	p := &Preference{Channel: SMS}
+	if err := p.Validate(); err != nil || p.EventType != AllEvents {
+		t.Errorf("expected a channel-wide preference, got %q (%v)", p.EventType, err)
+	}
+	if err := (&Preference{Channel: "pigeon"}).Validate(); err != ErrInvalidChannel {
+		t.Errorf("expected an unknown channel to be rejected, got %v", err)
+	}
+	if err := (&Preference{Channel: SMS, EventType: EventPasswordReset}).Validate(); err != ErrMandatoryEvent {
+		t.Errorf("expected opting out of security messages to be rejected, got %v", err)
+	}
+}
+
+func TestRouter_Route_SkipsOptedOutChannels(t *testing.T) {
+	store := &memoryPreferenceStore{prefs: map[string][]Preference{
+		"user_1": {{UserID: "user_1", Channel: SMS, EventType: AllEvents, Enabled: false}},
+	}}
+	publisher := &recordingPublisher{}
+	router := NewRouter(publisher)
+	router.SetPreferences(NewPreferences(store))
+
+	data, _ := json.Marshal(PaymentEventData{PaymentID: "pay_1", UserID: "user_1", Amount: 1000})
+	if err := router.Route(context.Background(), &Event{ID: "evt_1", Type: EventPaymentFailed, Data: data}); err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	// No webhooks without partner endpoints
+	want := []string{"email.notifications", "web.notifications"}
+	if len(publisher.queues) != len(want) {
+		t.Fatalf("expected %v, got %v", want, publisher.queues)
+	}
+	for i := range want {
+		if publisher.queues[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, publisher.queues)
+		}
+	}
+
+	// Preferences that cannot be read fail the event rather than risk it
+	store.err = errors.New("database down")
+	publisher.queues = nil
+	if err := router.Route(context.Background(), &Event{ID: "evt_2", Type: EventPaymentFailed, Data: data}); err == nil {
+		t.Error("expected the event to fail")
+	}
+	if len(publisher.queues) != 0 {
+		t.Errorf("expected nothing to be routed, got %v", publisher.queues)
+	}
+}
+
+func TestService_Send_RefusesOptedOutNotifications(t *testing.T) {
+	store := &memoryPreferenceStore{prefs: map[string][]Preference{
+		"user_1": {{UserID: "user_1", Channel: SMS, EventType: AllEvents, Enabled: false}},
+	}}
+	registry := NewDriverRegistry()
+	registry.Register(NewSMSDriver())
+	service := NewService(nil, registry)
+	service.SetPreferences(NewPreferences(store))
+
+	ctx := context.Background()
+	req := &NotificationRequest{UserID: "user_1", Recipient: "+15550100", Channel: SMS, TemplateID: "otp", EventType: EventPaymentFailed}
+	if _, err := service.Send(ctx, req); err != ErrOptedOut {
+		t.Errorf("expected ErrOptedOut, got %v", err)
+	}
+
+	req.EventType = EventPasswordReset
+	notif, err := service.Send(ctx, req)
+	if err != nil || notif.Status != StatusSent {
+		t.Errorf("expected a security message to be sent, got %v", err)
+	}
+}
@@ -48,13 +48,15 @@ func (r *Repository) UpdateStatus(ctx context.Context, id string, status Status)
 // GetByID retrieves a notification by its ID.
 func (r *Repository) GetByID(ctx context.Context, id string) (*Notification, error) {
 	query := `
-		SELECT id, user_id, recipient, channel, title, content, status, created_at, sent_at
-		FROM notifications WHERE id = $1
+		SELECT id, user_id, recipient, channel, COALESCE(title, ''), content, status, created_at, sent_at,
+			provider, provider_message_id
+		FROM notifications WHERE id::text = $1
 	`
 	row := r.db.QueryRowContext(ctx, query, id)
 
 	var n Notification
-	err := row.Scan(&n.ID, &n.UserID, &n.Recipient, &n.Channel, &n.Title, &n.Content, &n.Status, &n.CreatedAt, &n.SentAt)
+	err := row.Scan(&n.ID, &n.UserID, &n.Recipient, &n.Channel, &n.Title, &n.Content, &n.Status, &n.CreatedAt, &n.SentAt,
+		&n.Provider, &n.ProviderMessageID)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -67,7 +69,8 @@ func (r *Repository) GetByID(ctx context.Context, id string) (*Notification, err
 // GetByUserID retrieves all notifications for a given user.
 func (r *Repository) GetByUserID(ctx context.Context, userID string) ([]*Notification, error) {
 	query := `
-		SELECT id, user_id, recipient, channel, title, content, status, created_at, sent_at
+		SELECT id, user_id, recipient, channel, COALESCE(title, ''), content, status, created_at, sent_at,
+			provider, provider_message_id
 		FROM notifications WHERE user_id = $1 ORDER BY created_at DESC
 	`
 	rows, err := r.db.QueryContext(ctx, query, userID)
@@ -96,10 +99,84 @@ func (r *Repository) GetByUserID(ctx context.Context, userID string) ([]*Notific
 	var notifications []*Notification
 	for rows.Next() {
 		var n Notification
-		if err := rows.Scan(&n.ID, &n.UserID, &n.Recipient, &n.Channel, &n.Title, &n.Content, &n.Status, &n.CreatedAt, &n.SentAt); err != nil {
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Recipient, &n.Channel, &n.Title, &n.Content, &n.Status, &n.CreatedAt, &n.SentAt,
+			&n.Provider, &n.ProviderMessageID); err != nil {
 			return nil, err
 		}
 		notifications = append(notifications, &n)
 	}
 	return notifications, rows.Err()
 }
+
+// GetPreferences retrieves a user's notification preferences.
+func (r *Repository) GetPreferences(ctx context.Context, userID string) ([]Preference, error) {
+	query := `
+		SELECT user_id, channel, event_type, enabled, updated_at
+		FROM notification_preferences WHERE user_id = $1 ORDER BY channel, event_type
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	prefs := []Preference{}
+	for rows.Next() {
+		var p Preference
+		if err := rows.Scan(&p.UserID, &p.Channel, &p.EventType, &p.Enabled, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		prefs = append(prefs, p)
+	}
+	return prefs, rows.Err()
+}
+
+// SetPreference creates or replaces a user's preference for a channel and
+// event type.
+func (r *Repository) SetPreference(ctx context.Context, p *Preference) error {
+	p.UpdatedAt = time.Now()
+	query := `
+		INSERT INTO notification_preferences (user_id, channel, event_type, enabled, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, channel, event_type) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.ExecContext(ctx, query, p.UserID, p.Channel, p.EventType, p.Enabled, p.UpdatedAt)
+	return err
+}
+
+// DeletePreference removes a user's preference, turning the channel back to
+// its default.
+func (r *Repository) DeletePreference(ctx context.Context, userID string, channel Channel, eventType EventType) error {
+	query := `DELETE FROM notification_preferences WHERE user_id = $1 AND channel = $2 AND event_type = $3`
+	result, err := r.db.ExecContext(ctx, query, userID, channel, eventType)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrPreferenceMissing
+	}
+	return nil
+}
+
+// GetLocale retrieves the locale a user is sent notifications in, or ""
+// when they have not chosen one.
+func (r *Repository) GetLocale(ctx context.Context, userID string) (string, error) {
+	var locale string
+	err := r.db.QueryRowContext(ctx, `SELECT locale FROM notification_locales WHERE user_id = $1`, userID).Scan(&locale)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return locale, err
+}
+
+// SetLocale sets the locale a user is sent notifications in.
+func (r *Repository) SetLocale(ctx context.Context, userID, locale string) error {
+	query := `
+		INSERT INTO notification_locales (user_id, locale, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET locale = EXCLUDED.locale, updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.ExecContext(ctx, query, userID, locale, time.Now())
+	return err
+}
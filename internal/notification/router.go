@@ -3,7 +3,11 @@ package notification
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"slices"
+	"strconv"
+	"time"
 )
 
 // NotificationTask represents a task to be processed by workers
@@ -17,6 +21,8 @@ type NotificationTask struct {
 	EventType  EventType         `json:"event_type"`
 	RetryCount int               `json:"retry_count"`
 	MaxRetries int               `json:"max_retries"`
+	// SendAt holds the task until then, when workers receive it early
+	SendAt *time.Time `json:"send_at,omitempty"`
 }
 
 // WebhookTask represents a webhook delivery task
@@ -38,6 +44,9 @@ type RoutingConfig struct {
 	SMS       bool
 	Web       bool
 	Webhook   bool
+	// Digest lets the email wait for the user's digest, unless the event
+	// is critical
+	Digest bool
 }
 
 // DefaultRoutingRules defines the default routing for each event type
@@ -48,6 +57,7 @@ var DefaultRoutingRules = map[EventType]RoutingConfig{
 		SMS:       false,
 		Web:       true,
 		Webhook:   true,
+		Digest:    true,
 	},
 	EventPaymentFailed: {
 		EventType: EventPaymentFailed,
@@ -62,6 +72,7 @@ var DefaultRoutingRules = map[EventType]RoutingConfig{
 		SMS:       false,
 		Web:       true,
 		Webhook:   true,
+		Digest:    true,
 	},
 	EventRefundCompleted: {
 		EventType: EventRefundCompleted,
@@ -69,6 +80,7 @@ var DefaultRoutingRules = map[EventType]RoutingConfig{
 		SMS:       true,
 		Web:       true,
 		Webhook:   true,
+		Digest:    true,
 	},
 	EventUserRegistered: {
 		EventType: EventUserRegistered,
@@ -84,12 +96,29 @@ var DefaultRoutingRules = map[EventType]RoutingConfig{
 		Web:       false,
 		Webhook:   false,
 	},
+	EventSecurityCode: {
+		EventType: EventSecurityCode,
+		Email:     true,
+		SMS:       false,
+		Web:       false,
+		Webhook:   false,
+	},
+	EventOrgInvitation: {
+		EventType: EventOrgInvitation,
+		Email:     true,
+		SMS:       false,
+		Web:       false,
+		Webhook:   false,
+	},
 }
 
 // Router routes events to appropriate notification channels
 type Router struct {
 	rabbitClient RabbitPublisher
 	rules        map[EventType]RoutingConfig
+	preferences  *Preferences
+	digester     *Digester
+	endpoints    *EndpointRegistry
 }
 
 // RabbitPublisher interface for RabbitMQ publishing
@@ -105,6 +134,23 @@ func NewRouter(rabbitClient RabbitPublisher) *Router {
 	}
 }
 
+// SetPreferences makes the router skip the channels users opted out of
+func (r *Router) SetPreferences(preferences *Preferences) {
+	r.preferences = preferences
+}
+
+// SetDigester makes the router hold the emails of digest-eligible events
+// for the users' digests
+func (r *Router) SetDigester(digester *Digester) {
+	r.digester = digester
+}
+
+// SetEndpoints makes the router post events to the partners' endpoints
+// subscribed to them. Without a registry no webhooks are sent.
+func (r *Router) SetEndpoints(endpoints *EndpointRegistry) {
+	r.endpoints = endpoints
+}
+
 // Route processes an event and routes it to appropriate queues
 func (r *Router) Route(ctx context.Context, event *Event) error {
 	config, ok := r.rules[event.Type]
@@ -115,8 +161,49 @@ func (r *Router) Route(ctx context.Context, event *Event) error {
 
 	templateData := r.extractTemplateData(event)
 
-	// Route to email queue
+	// Leave out the channels the user opted out of. Failing to read their
+	// preferences fails the event, to be redelivered, rather than risk
+	// messaging someone who opted out.
+	var channels []Channel
 	if config.Email {
+		channels = append(channels, Email)
+	}
+	if config.SMS {
+		channels = append(channels, SMS)
+	}
+	if config.Web {
+		channels = append(channels, Web)
+	}
+	allowed, err := r.preferences.Filter(ctx, templateData["UserID"], event.Type, channels)
+	if err != nil {
+		return fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+	for _, channel := range channels {
+		if !slices.Contains(allowed, channel) {
+			log.Printf("User %s opted out of %s for %s", templateData["UserID"], channel, event.Type)
+		}
+	}
+
+	// Failing to find the subscribed endpoints fails the event too, so
+	// partners do not miss it
+	var endpoints []WebhookEndpoint
+	if config.Webhook {
+		endpoints, err = r.endpoints.Subscribed(ctx, event.Type)
+		if err != nil {
+			return fmt.Errorf("failed to get webhook endpoints: %w", err)
+		}
+	}
+
+	// Route to email queue, or to the user's digest. An email that cannot
+	// be held is sent right away.
+	if slices.Contains(allowed, Email) && r.digester.Holds(config, event.Type, templateData["UserID"]) {
+		if err := r.digester.Add(ctx, event, templateData); err == nil {
+			allowed = slices.DeleteFunc(allowed, func(c Channel) bool { return c == Email })
+		} else {
+			log.Printf("Failed to hold %s for digest, sending it now: %v", event.Type, err)
+		}
+	}
+	if slices.Contains(allowed, Email) {
 		task := r.createNotificationTask(event, Email, templateData)
 		if err := r.publishTask(ctx, "email.notifications", task); err != nil {
 			log.Printf("Failed to route to email queue: %v", err)
@@ -124,7 +211,7 @@ func (r *Router) Route(ctx context.Context, event *Event) error {
 	}
 
 	// Route to SMS queue
-	if config.SMS {
+	if slices.Contains(allowed, SMS) {
 		task := r.createNotificationTask(event, SMS, templateData)
 		if err := r.publishTask(ctx, "sms.notifications", task); err != nil {
 			log.Printf("Failed to route to SMS queue: %v", err)
@@ -132,18 +219,18 @@ func (r *Router) Route(ctx context.Context, event *Event) error {
 	}
 
 	// Route to Web Push queue
-	if config.Web {
+	if slices.Contains(allowed, Web) {
 		task := r.createNotificationTask(event, Web, templateData)
 		if err := r.publishTask(ctx, "web.notifications", task); err != nil {
 			log.Printf("Failed to route to web queue: %v", err)
 		}
 	}
 
-	// Route to Webhook queue
-	if config.Webhook {
-		webhookTask := r.createWebhookTask(event)
+	// Route to Webhook queue, once per subscribed endpoint
+	for i := range endpoints {
+		webhookTask := r.createWebhookTask(event, &endpoints[i])
 		if err := r.publishTask(ctx, "webhook.notifications", webhookTask); err != nil {
-			log.Printf("Failed to route to webhook queue: %v", err)
+			log.Printf("Failed to route to webhook queue for endpoint %s: %v", endpoints[i].ID, err)
 		}
 	}
 
@@ -164,11 +251,46 @@ func (r *Router) createNotificationTask(event *Event, channel Channel, data map[
 	}
 }
 
-func (r *Router) createWebhookTask(event *Event) *WebhookTask {
+// QueueFor returns the queue consumed by the workers of a channel
+func QueueFor(channel Channel) string {
+	return string(channel) + ".notifications"
+}
+
+// TaskFromRequest builds the worker task for a notification requested
+// directly rather than derived from an event. The ID is the idempotency key
+// workers use to skip tasks they have already sent. The request's delay
+// must be resolved into its send_at first.
+func TaskFromRequest(id string, req *NotificationRequest) *NotificationTask {
+	data := req.Data
+	if data == nil {
+		data = make(map[string]string)
+	}
+	if req.UserID != "" {
+		data["UserID"] = req.UserID
+	}
+	if req.Locale != "" {
+		data["Locale"] = req.Locale
+	}
+	return &NotificationTask{
+		ID:         id,
+		Channel:    req.Channel,
+		Recipient:  req.Recipient,
+		TemplateID: req.TemplateID,
+		Data:       data,
+		EventType:  req.EventType,
+		MaxRetries: 3,
+		SendAt:     req.SendAt,
+	}
+}
+
+// createWebhookTask builds the delivery of an event to an endpoint. The ID
+// is unique per endpoint, as workers skip the IDs they have delivered.
+func (r *Router) createWebhookTask(event *Event, endpoint *WebhookEndpoint) *WebhookTask {
 	return &WebhookTask{
-		ID:         "webhook_" + event.ID,
-		PartnerID:  "default", // Would be extracted from user preferences
-		URL:        "",        // Would be fetched from partner config
+		ID:         "webhook_" + event.ID + "_" + endpoint.ID,
+		PartnerID:  endpoint.PartnerID,
+		URL:        endpoint.URL,
+		Secret:     endpoint.Secret,
 		Payload:    event.Data,
 		EventType:  event.Type,
 		RetryCount: 0,
@@ -186,13 +308,17 @@ func (r *Router) publishTask(ctx context.Context, queue string, task interface{}
 
 func (r *Router) extractTemplateData(event *Event) map[string]string {
 	data := make(map[string]string)
+	if !event.Timestamp.IsZero() {
+		data["Timestamp"] = event.Timestamp.UTC().Format(time.RFC3339)
+	}
 
 	switch event.Type {
 	case EventPaymentSucceeded, EventPaymentFailed:
 		if paymentData, err := event.ParsePaymentEventData(); err == nil {
 			data["UserID"] = paymentData.UserID
 			data["Recipient"] = "user_" + paymentData.UserID + "@example.com"
-			data["Amount"] = formatAmount(paymentData.Amount)
+			data["AmountMinor"] = strconv.FormatInt(paymentData.Amount, 10)
+			data["Amount"] = FormatNumber(paymentData.Amount, currencyDecimalsOf(paymentData.Currency), DefaultLocale)
 			data["Currency"] = paymentData.Currency
 			data["TransactionID"] = paymentData.PaymentID
 			data["UserName"] = "User " + paymentData.UserID
@@ -204,7 +330,8 @@ func (r *Router) extractTemplateData(event *Event) map[string]string {
 		if refundData, err := event.ParseRefundEventData(); err == nil {
 			data["UserID"] = refundData.UserID
 			data["Recipient"] = "user_" + refundData.UserID + "@example.com"
-			data["Amount"] = formatAmount(refundData.Amount)
+			data["AmountMinor"] = strconv.FormatInt(refundData.Amount, 10)
+			data["Amount"] = FormatNumber(refundData.Amount, currencyDecimalsOf(refundData.Currency), DefaultLocale)
 			data["Currency"] = refundData.Currency
 			data["RefundID"] = refundData.RefundID
 			data["UserName"] = "User " + refundData.UserID
@@ -226,6 +353,21 @@ func (r *Router) extractTemplateData(event *Event) map[string]string {
 			// For templates expecting 'Code', map token to it if it's short/OTP
 			data["Code"] = userData.Token
 		}
+	case EventSecurityCode:
+		if userData, err := event.ParseUserEventData(); err == nil {
+			data["UserID"] = userData.UserID
+			data["Recipient"] = userData.Email
+			data["Code"] = userData.Token
+		}
+	case EventOrgInvitation:
+		// Invitees may not have an account yet, so there is no UserID
+		if invitation, err := event.ParseInvitationEventData(); err == nil {
+			data["Recipient"] = invitation.Email
+			data["OrgName"] = invitation.OrgName
+			data["Role"] = invitation.Role
+			data["InvitedBy"] = invitation.InvitedBy
+			data["Link"] = invitation.Link
+		}
 	}
 
 	return data
@@ -243,11 +385,11 @@ func (r *Router) getTemplateID(eventType EventType) string {
 		return TemplateVerification
 	case EventPasswordReset:
 		return "otp"
+	case EventSecurityCode:
+		return TemplateSecurityCode
+	case EventOrgInvitation:
+		return TemplateOrgInvitation
 	default:
 		return "generic"
 	}
 }
-
-func formatAmount(amount int64) string {
-	return string(rune(amount/100)) + "." + string(rune(amount%100))
-}
@@ -0,0 +1,234 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MaxScheduleAhead is how far ahead notifications can be scheduled
+const MaxScheduleAhead = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidSchedule  = errors.New("give either send_at or delay, with delay a duration such as 30m")
+	ErrScheduleTooFar   = errors.New("notifications can be scheduled up to 30 days ahead")
+	ErrScheduleNotFound = errors.New("scheduled notification not found or already sent")
+)
+
+// ResolveSchedule turns the request's delay into its send_at, checking the
+// schedule. Requests without either are sent right away.
+func (r *NotificationRequest) ResolveSchedule(now time.Time) error {
+	if r.Delay != "" {
+		if r.SendAt != nil {
+			return ErrInvalidSchedule
+		}
+		delay, err := time.ParseDuration(r.Delay)
+		if err != nil || delay < 0 {
+			return ErrInvalidSchedule
+		}
+		sendAt := now.Add(delay).UTC()
+		r.SendAt, r.Delay = &sendAt, ""
+	}
+	if r.SendAt != nil && r.SendAt.After(now.Add(MaxScheduleAhead)) {
+		return ErrScheduleTooFar
+	}
+	return nil
+}
+
+// ScheduledNotification is a task held until its send time
+type ScheduledNotification struct {
+	ID        string           `json:"id"`
+	SendAt    time.Time        `json:"send_at"`
+	CreatedAt time.Time        `json:"created_at"`
+	Task      NotificationTask `json:"task"`
+}
+
+// ScheduleStore keeps scheduled notifications by send time
+type ScheduleStore interface {
+	AddScheduled(ctx context.Context, s *ScheduledNotification) error
+	// GetScheduled returns nil for notifications that are not scheduled
+	GetScheduled(ctx context.Context, id string) (*ScheduledNotification, error)
+	// RemoveScheduled reports false when the notification was already
+	// removed, e.g. sent by another instance or canceled
+	RemoveScheduled(ctx context.Context, id string) (bool, error)
+	// DueScheduled lists the IDs of notifications due by now, soonest first
+	DueScheduled(ctx context.Context, now time.Time, limit int) ([]string, error)
+}
+
+// Scheduler holds notifications until their send time, then queues them
+// for the workers of their channel
+type Scheduler struct {
+	store     ScheduleStore
+	publisher RabbitPublisher
+}
+
+func NewScheduler(store ScheduleStore, publisher RabbitPublisher) *Scheduler {
+	return &Scheduler{store: store, publisher: publisher}
+}
+
+// Schedule holds the task until sendAt. The task's ID identifies the
+// scheduled notification, e.g. to cancel it.
+func (s *Scheduler) Schedule(ctx context.Context, task *NotificationTask, sendAt time.Time) (*ScheduledNotification, error) {
+	scheduled := &ScheduledNotification{
+		ID:        task.ID,
+		SendAt:    sendAt.UTC(),
+		CreatedAt: time.Now().UTC(),
+		Task:      *task,
+	}
+	scheduled.Task.SendAt = &scheduled.SendAt
+	if err := s.store.AddScheduled(ctx, scheduled); err != nil {
+		return nil, err
+	}
+	log.Printf("Scheduled task %s via %s for %s", task.ID, task.Channel, scheduled.SendAt.Format(time.RFC3339))
+	return scheduled, nil
+}
+
+// Get returns a notification that is not sent yet, failing with
+// ErrScheduleNotFound otherwise
+func (s *Scheduler) Get(ctx context.Context, id string) (*ScheduledNotification, error) {
+	scheduled, err := s.store.GetScheduled(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if scheduled == nil {
+		return nil, ErrScheduleNotFound
+	}
+	return scheduled, nil
+}
+
+// Cancel drops a notification that is not sent yet
+func (s *Scheduler) Cancel(ctx context.Context, id string) error {
+	removed, err := s.store.RemoveScheduled(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrScheduleNotFound
+	}
+	log.Printf("Canceled scheduled task %s", id)
+	return nil
+}
+
+// Release queues the notifications that are due, returning how many were
+// queued. Notifications that fail to queue stay scheduled for the next
+// run.
+func (s *Scheduler) Release(ctx context.Context, now time.Time) (int, error) {
+	ids, err := s.store.DueScheduled(ctx, now, 100)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, id := range ids {
+		scheduled, err := s.store.GetScheduled(ctx, id)
+		if err != nil || scheduled == nil {
+			continue
+		}
+		// Only the instance removing it sends it
+		if removed, err := s.store.RemoveScheduled(ctx, id); err != nil || !removed {
+			continue
+		}
+
+		task := scheduled.Task
+		task.SendAt = nil
+		body, err := json.Marshal(&task)
+		if err == nil {
+			err = s.publisher.Publish(ctx, QueueFor(task.Channel), body)
+		}
+		if err != nil {
+			log.Printf("Failed to queue scheduled task %s, retrying next run: %v", id, err)
+			if err := s.store.AddScheduled(ctx, scheduled); err != nil {
+				log.Printf("Failed to reschedule task %s: %v", id, err)
+			}
+			continue
+		}
+		released++
+	}
+	return released, nil
+}
+
+// Run queues due notifications every interval until the context is
+// cancelled
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n, err := s.Release(ctx, now.UTC()); err != nil {
+				log.Printf("Failed to release scheduled notifications: %v", err)
+			} else if n > 0 {
+				log.Printf("Queued %d scheduled notifications", n)
+			}
+		}
+	}
+}
+
+const (
+	scheduledKey       = "notif:scheduled"
+	scheduledTaskKey   = "notif:scheduled:task:"
+	scheduledRetention = 24 * time.Hour // Kept past the send time, in case releasing lags
+)
+
+// RedisScheduleStore keeps scheduled notifications in a sorted set scored
+// by send time, with each notification under its own key
+type RedisScheduleStore struct {
+	client *redis.Client
+}
+
+func NewRedisScheduleStore(client *redis.Client) *RedisScheduleStore {
+	return &RedisScheduleStore{client: client}
+}
+
+func (s *RedisScheduleStore) AddScheduled(ctx context.Context, scheduled *ScheduledNotification) error {
+	data, err := json.Marshal(scheduled)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(scheduled.SendAt) + scheduledRetention
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, scheduledTaskKey+scheduled.ID, data, ttl)
+		pipe.ZAdd(ctx, scheduledKey, redis.Z{Score: float64(scheduled.SendAt.UnixMilli()), Member: scheduled.ID})
+		return nil
+	})
+	return err
+}
+
+func (s *RedisScheduleStore) GetScheduled(ctx context.Context, id string) (*ScheduledNotification, error) {
+	data, err := s.client.Get(ctx, scheduledTaskKey+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var scheduled ScheduledNotification
+	if err := json.Unmarshal(data, &scheduled); err != nil {
+		return nil, err
+	}
+	return &scheduled, nil
+}
+
+func (s *RedisScheduleStore) RemoveScheduled(ctx context.Context, id string) (bool, error) {
+	removed, err := s.client.ZRem(ctx, scheduledKey, id).Result()
+	if err != nil || removed == 0 {
+		return false, err
+	}
+	return true, s.client.Del(ctx, scheduledTaskKey+id).Err()
+}
+
+func (s *RedisScheduleStore) DueScheduled(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	return s.client.ZRangeByScore(ctx, scheduledKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: int64(limit),
+	}).Result()
+}
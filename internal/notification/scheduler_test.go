@@ -0,0 +1,134 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+// memoryScheduleStore keeps scheduled notifications in memory
+type memoryScheduleStore struct {
+	scheduled map[string]ScheduledNotification
+}
+
+func (s *memoryScheduleStore) AddScheduled(ctx context.Context, scheduled *ScheduledNotification) error {
+	s.scheduled[scheduled.ID] = *scheduled
+	return nil
+}
+
+func (s *memoryScheduleStore) GetScheduled(ctx context.Context, id string) (*ScheduledNotification, error) {
+	scheduled, ok := s.scheduled[id]
+	if !ok {
+		return nil, nil
+	}
+	return &scheduled, nil
+}
+
+func (s *memoryScheduleStore) RemoveScheduled(ctx context.Context, id string) (bool, error) {
+	_, ok := s.scheduled[id]
+	delete(s.scheduled, id)
+	return ok, nil
+}
+
+func (s *memoryScheduleStore) DueScheduled(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	var ids []string
+	for id, scheduled := range s.scheduled {
+		if !scheduled.SendAt.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return s.scheduled[ids[i]].SendAt.Before(s.scheduled[ids[j]].SendAt) })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+func TestNotificationRequest_ResolveSchedule(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	sendAt := now.Add(time.Hour)
+
+	req := &NotificationRequest{Delay: "90m"}
+	if err := req.ResolveSchedule(now); err != nil || !req.SendAt.Equal(now.Add(90*time.Minute)) || req.Delay != "" {
+		t.Errorf("got send_at %v, delay %q, %v", req.SendAt, req.Delay, err)
+	}
+
+	tests := []struct {
+		req  NotificationRequest
+		want error
+	}{
+		{NotificationRequest{}, nil},
+		{NotificationRequest{SendAt: &sendAt}, nil},
+		{NotificationRequest{SendAt: &sendAt, Delay: "1h"}, ErrInvalidSchedule},
+		{NotificationRequest{Delay: "soon"}, ErrInvalidSchedule},
+		{NotificationRequest{Delay: "-1h"}, ErrInvalidSchedule},
+		{NotificationRequest{Delay: "800h"}, ErrScheduleTooFar},
+	}
+	for _, tt := range tests {
+		if err := tt.req.ResolveSchedule(now); !errors.Is(err, tt.want) {
+			t.Errorf("%+v: got %v, want %v", tt.req, err, tt.want)
+		}
+	}
+}
+
+func TestScheduler_ReleasesDueNotifications(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryScheduleStore{scheduled: map[string]ScheduledNotification{}}
+	publisher := &taskPublisher{tasks: map[string][]NotificationTask{}}
+	scheduler := NewScheduler(store, publisher)
+	now := time.Now()
+
+	for _, task := range []*NotificationTask{
+		{ID: "task-1", Channel: SMS, Recipient: "+15550100", TemplateID: "otp"},
+		{ID: "task-2", Channel: Email, Recipient: "a@example.com", TemplateID: "welcome"},
+		{ID: "task-3", Channel: Web, Recipient: "device", TemplateID: "generic"},
+	} {
+		if _, err := scheduler.Schedule(ctx, task, now.Add(time.Hour)); err != nil {
+			t.Fatalf("Schedule failed: %v", err)
+		}
+	}
+	if err := scheduler.Cancel(ctx, "task-3"); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if err := scheduler.Cancel(ctx, "task-3"); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("got %v, want ErrScheduleNotFound canceling twice", err)
+	}
+
+	if n, _ := scheduler.Release(ctx, now.Add(30*time.Minute)); n != 0 {
+		t.Fatalf("released %d notifications before their send time", n)
+	}
+	if n, err := scheduler.Release(ctx, now.Add(time.Hour)); err != nil || n != 2 {
+		t.Fatalf("got %d released, %v, want 2", n, err)
+	}
+	if len(publisher.tasks["sms.notifications"]) != 1 || len(publisher.tasks["email.notifications"]) != 1 ||
+		len(publisher.tasks["web.notifications"]) != 0 {
+		t.Errorf("got %v", publisher.tasks)
+	}
+	if task := publisher.tasks["sms.notifications"][0]; task.SendAt != nil {
+		t.Errorf("got released task %+v still scheduled", task)
+	}
+
+	// Sent notifications cannot be canceled
+	if err := scheduler.Cancel(ctx, "task-1"); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("got %v, want ErrScheduleNotFound", err)
+	}
+}
+
+func TestWorker_SchedulesEarlyTasks(t *testing.T) {
+	store := &memoryScheduleStore{scheduled: map[string]ScheduledNotification{}}
+	driver := &failingDriver{err: errors.New("must not be sent yet")}
+	worker := NewWorker(SMS, driver, nil, nil)
+	worker.SetScheduler(NewScheduler(store, &taskPublisher{tasks: map[string][]NotificationTask{}}))
+
+	sendAt := time.Now().Add(time.Hour)
+	body, _ := json.Marshal(NotificationTask{ID: "flow_1", Channel: SMS, Recipient: "+15550100", SendAt: &sendAt})
+	if err := worker.ProcessTask(context.Background(), body); err != nil {
+		t.Fatalf("ProcessTask failed: %v", err)
+	}
+	if _, ok := store.scheduled["flow_1"]; !ok {
+		t.Error("expected the task to be scheduled")
+	}
+}
@@ -12,3 +12,129 @@ CREATE TABLE IF NOT EXISTS notifications (
 
 CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id);
 CREATE INDEX IF NOT EXISTS idx_notifications_status ON notifications(status);
+
+-- Users' opt-outs, by channel and event type ('*' for every event type).
+-- Channels without a preference are on.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id VARCHAR(255) NOT NULL,
+    channel VARCHAR(50) NOT NULL,
+    event_type VARCHAR(100) NOT NULL DEFAULT '*',
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, channel, event_type)
+);
+
+-- Versions of notification templates. Drafts are edited until published;
+-- publishing archives the version in use. Published templates take over
+-- the built-in ones of the same name on their channel.
+CREATE TABLE IF NOT EXISTS notification_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    channel VARCHAR(50) NOT NULL,
+    locale VARCHAR(20) NOT NULL DEFAULT 'en',
+    version INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'published', 'archived')),
+    subject TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    published_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (name, channel, locale, version)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_templates_published
+    ON notification_templates(name, channel, locale) WHERE status = 'published';
+
+-- Delivery tracking. Notifications sent by the workers are keyed by their
+-- task, and matched to their providers' callbacks by the message ID.
+ALTER TABLE notifications ALTER COLUMN user_id TYPE VARCHAR(255);
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS task_id VARCHAR(255) UNIQUE;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS provider VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS provider_message_id VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_notifications_provider_message
+    ON notifications(provider, provider_message_id) WHERE provider_message_id <> '';
+
+-- Each attempt to send a notification, and each status its provider
+-- reported
+CREATE TABLE IF NOT EXISTS notification_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('attempt', 'callback')),
+    status VARCHAR(50) NOT NULL,
+    attempt INT NOT NULL DEFAULT 0,
+    provider VARCHAR(50) NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_events_notification ON notification_events(notification_id, occurred_at);
+
+-- Notifications held back for the users' digests, sent together once the
+-- first one's window is over
+CREATE TABLE IF NOT EXISTS notification_digest_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id VARCHAR(255) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    due_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_digest_items_pending
+    ON notification_digest_items(user_id, due_at) WHERE sent_at IS NULL;
+
+-- Notifications held back by rate limiting or deduplication
+ALTER TABLE notification_events DROP CONSTRAINT IF EXISTS notification_events_kind_check;
+ALTER TABLE notification_events ADD CONSTRAINT notification_events_kind_check
+    CHECK (kind IN ('attempt', 'callback', 'suppressed'));
+
+-- The locale each user is sent notifications in
+CREATE TABLE IF NOT EXISTS notification_locales (
+    user_id VARCHAR(255) PRIMARY KEY,
+    locale VARCHAR(20) NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Each attempt to deliver a webhook and what its endpoint answered
+CREATE TABLE IF NOT EXISTS notification_webhook_attempts (
+    id UUID PRIMARY KEY,
+    webhook_id VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    attempt INT NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    delivered BOOLEAN NOT NULL DEFAULT FALSE,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_webhook_attempts_webhook
+    ON notification_webhook_attempts(webhook_id, occurred_at);
+
+-- Partners' endpoints the events they subscribe to are posted to
+CREATE TABLE IF NOT EXISTS notification_webhook_endpoints (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    partner_id VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    event_types TEXT[] NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_webhook_endpoints_partner ON notification_webhook_endpoints(partner_id);
+CREATE INDEX IF NOT EXISTS idx_notification_webhook_endpoints_event_types
+    ON notification_webhook_endpoints USING GIN (event_types) WHERE active;
+
+-- In-app inboxes: when users read and archived their web notifications
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS read_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_inbox
+    ON notifications(user_id, created_at DESC) WHERE channel = 'web' AND archived_at IS NULL;
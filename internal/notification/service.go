@@ -2,13 +2,19 @@ package notification
 
 import (
 	"context"
+	"errors"
 	"log"
 )
 
+// ErrOptedOut is returned for notifications the user opted out of
+var ErrOptedOut = errors.New("user opted out of these notifications")
+
 // Service handles the business logic for sending notifications.
 type Service struct {
-	repo     *Repository
-	registry *DriverRegistry
+	repo        *Repository
+	registry    *DriverRegistry
+	preferences *Preferences
+	renderer    *Renderer
 }
 
 func NewService(repo *Repository, registry *DriverRegistry) *Service {
@@ -18,19 +24,49 @@ func NewService(repo *Repository, registry *DriverRegistry) *Service {
 	}
 }
 
+// SetRenderer makes Send render notifications with the published templates
+func (s *Service) SetRenderer(renderer *Renderer) {
+	s.renderer = renderer
+}
+
+// SetPreferences makes Send refuse the notifications users opted out of
+func (s *Service) SetPreferences(preferences *Preferences) {
+	s.preferences = preferences
+}
+
 // Send processes a notification request: renders the template, persists, and sends via the appropriate driver.
+// Notifications the user opted out of are not sent and fail with ErrOptedOut.
 func (s *Service) Send(ctx context.Context, req *NotificationRequest) (*Notification, error) {
-	// Render the template
-	content, err := RenderTemplate(req.TemplateID, req.Data)
+	allowed, err := s.preferences.Allowed(ctx, req.UserID, req.Channel, req.EventType)
 	if err != nil {
-		log.Printf("Failed to render template %s: %v", req.TemplateID, err)
-		content = "Notification content unavailable"
+		return nil, err
+	}
+	if !allowed {
+		log.Printf("User %s opted out of %s for %q, not sending", req.UserID, req.Channel, req.EventType)
+		return nil, ErrOptedOut
 	}
 
-	// Determine title from template data or use a default
-	title := req.Data["Title"]
-	if title == "" {
-		title = "Notification"
+	// Render the template in the request's or the user's locale, titled by
+	// the template's subject, the template data or a default
+	data := req.Data
+	if req.Locale != "" || req.UserID != "" {
+		data = make(map[string]string, len(req.Data)+2)
+		for k, v := range req.Data {
+			data[k] = v
+		}
+		if req.Locale != "" {
+			data["Locale"] = req.Locale
+		}
+		if req.UserID != "" {
+			data["UserID"] = req.UserID
+		}
+		data = s.preferences.WithLocale(ctx, data)
+	}
+	title, content := "Notification", "Notification content unavailable"
+	if rendered, err := s.renderer.Render(ctx, req.TemplateID, req.Channel, data); err != nil {
+		log.Printf("Failed to render template %s: %v", req.TemplateID, err)
+	} else {
+		title, content = rendered.Subject, rendered.Body
 	}
 
 	// Create notification record
@@ -0,0 +1,182 @@
+package notification
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Reasons notifications are suppressed
+const (
+	SuppressedRateLimit = "rate_limited" // Too many messages to the recipient
+	SuppressedDuplicate = "duplicate"    // The same message was just sent
+)
+
+// SuppressedError is returned for tasks not sent to protect recipients
+// from notification storms
+type SuppressedError struct {
+	Reason string
+}
+
+func (e *SuppressedError) Error() string {
+	return "notification suppressed: " + e.Reason
+}
+
+// SuppressionConfig limits the messages sent to each recipient
+type SuppressionConfig struct {
+	// Limits caps the messages per recipient per RateWindow, by channel.
+	// Channels without a limit are not limited.
+	Limits     map[Channel]int
+	RateWindow time.Duration
+	// DedupeWindow is how long an identical message is not sent again, 0
+	// to send duplicates
+	DedupeWindow time.Duration
+}
+
+// DefaultSuppressionConfig allows few SMS, which are costly and intrusive
+var DefaultSuppressionConfig = SuppressionConfig{
+	Limits:       map[Channel]int{Email: 20, SMS: 5, Web: 30},
+	RateWindow:   time.Hour,
+	DedupeWindow: 10 * time.Minute,
+}
+
+// SuppressionStore keeps the counters of rate limiting and the keys of
+// recently sent messages
+type SuppressionStore interface {
+	Count(ctx context.Context, key string) (int, error)
+	Increment(ctx context.Context, key string, ttl time.Duration) error
+	// Owner returns the task that sent the message of the key, or ""
+	Owner(ctx context.Context, key string) (string, error)
+	SetOwner(ctx context.Context, key, owner string, ttl time.Duration) error
+}
+
+// Suppressor holds back messages beyond a recipient's rate limit, and
+// messages identical to one just sent. Messages count once sent, so failed
+// attempts and their retries do not. A nil Suppressor suppresses nothing.
+type Suppressor struct {
+	store  SuppressionStore
+	config SuppressionConfig
+}
+
+func NewSuppressor(store SuppressionStore, config SuppressionConfig) *Suppressor {
+	return &Suppressor{store: store, config: config}
+}
+
+// Check returns a SuppressedError when the task must not be sent. Failing
+// to read the counters lets the task through.
+func (s *Suppressor) Check(ctx context.Context, task *NotificationTask, channel Channel) error {
+	if s == nil {
+		return nil
+	}
+	if s.config.DedupeWindow > 0 {
+		owner, err := s.store.Owner(ctx, s.dedupeKey(task, channel))
+		if err != nil {
+			log.Printf("Failed to check duplicates of task %s: %v", task.ID, err)
+		} else if owner != "" && owner != task.ID {
+			return &SuppressedError{Reason: SuppressedDuplicate}
+		}
+	}
+
+	if limit, ok := s.config.Limits[channel]; ok {
+		count, err := s.store.Count(ctx, s.rateKey(task, channel, time.Now()))
+		if err != nil {
+			log.Printf("Failed to check rate of task %s: %v", task.ID, err)
+		} else if count >= limit {
+			return &SuppressedError{Reason: SuppressedRateLimit}
+		}
+	}
+	return nil
+}
+
+// Record counts a sent task against its recipient's limit and for
+// deduplication
+func (s *Suppressor) Record(ctx context.Context, task *NotificationTask, channel Channel) {
+	if s == nil {
+		return
+	}
+	if s.config.DedupeWindow > 0 {
+		if err := s.store.SetOwner(ctx, s.dedupeKey(task, channel), task.ID, s.config.DedupeWindow); err != nil {
+			log.Printf("Failed to record task %s for deduplication: %v", task.ID, err)
+		}
+	}
+	if _, ok := s.config.Limits[channel]; ok {
+		if err := s.store.Increment(ctx, s.rateKey(task, channel, time.Now()), s.config.RateWindow); err != nil {
+			log.Printf("Failed to count task %s against the rate limit: %v", task.ID, err)
+		}
+	}
+}
+
+// rateKey is the counter of the recipient's messages on the channel in the
+// current window
+func (s *Suppressor) rateKey(task *NotificationTask, channel Channel, now time.Time) string {
+	window := now.Unix() / int64(s.config.RateWindow.Seconds())
+	return fmt.Sprintf("notif:rate:%s:%s:%d", channel, task.Recipient, window)
+}
+
+// dedupeKey identifies a message by its template, recipient and data
+func (s *Suppressor) dedupeKey(task *NotificationTask, channel Channel) string {
+	keys := make([]string, 0, len(task.Data))
+	for k := range task.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, part := range []string{string(channel), task.TemplateID, task.Recipient} {
+		h.Write([]byte(strconv.Quote(part)))
+	}
+	for _, k := range keys {
+		if k == "Timestamp" {
+			continue // When the event happened, not what the message says
+		}
+		h.Write([]byte(strconv.Quote(k) + "=" + strconv.Quote(task.Data[k])))
+	}
+	return "notif:dedupe:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// RedisSuppressionStore keeps the suppression keys in Redis, expiring them
+// with their windows
+type RedisSuppressionStore struct {
+	client *redis.Client
+}
+
+func NewRedisSuppressionStore(client *redis.Client) *RedisSuppressionStore {
+	return &RedisSuppressionStore{client: client}
+}
+
+func (s *RedisSuppressionStore) Count(ctx context.Context, key string) (int, error) {
+	count, err := s.client.Get(ctx, key).Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return count, err
+}
+
+func (s *RedisSuppressionStore) Increment(ctx context.Context, key string, ttl time.Duration) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisSuppressionStore) Owner(ctx context.Context, key string) (string, error) {
+	owner, err := s.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return owner, err
+}
+
+func (s *RedisSuppressionStore) SetOwner(ctx context.Context, key, owner string, ttl time.Duration) error {
+	return s.client.Set(ctx, key, owner, ttl).Err()
+}
@@ -0,0 +1,123 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// memorySuppressionStore keeps suppression keys in memory, without expiry
+type memorySuppressionStore struct {
+	counts map[string]int
+	owners map[string]string
+}
+
+func newMemorySuppressionStore() *memorySuppressionStore {
+	return &memorySuppressionStore{counts: map[string]int{}, owners: map[string]string{}}
+}
+
+func (s *memorySuppressionStore) Count(ctx context.Context, key string) (int, error) {
+	return s.counts[key], nil
+}
+
+func (s *memorySuppressionStore) Increment(ctx context.Context, key string, ttl time.Duration) error {
+	s.counts[key]++
+	return nil
+}
+
+func (s *memorySuppressionStore) Owner(ctx context.Context, key string) (string, error) {
+	return s.owners[key], nil
+}
+
+func (s *memorySuppressionStore) SetOwner(ctx context.Context, key, owner string, ttl time.Duration) error {
+	s.owners[key] = owner
+	return nil
+}
+
+// countingDriver counts the messages it sends
+type countingDriver struct {
+	sent int
+}
+
+func (d *countingDriver) Channel() Channel {
+	return SMS
+}
+
+func (d *countingDriver) Send(ctx context.Context, recipient, title, content string) error {
+	d.sent++
+	return nil
+}
+
+func TestWorker_SuppressesStorms(t *testing.T) {
+	ctx := context.Background()
+	driver := &countingDriver{}
+	worker := NewWorker(SMS, driver, nil, nil)
+	worker.SetSuppressor(NewSuppressor(newMemorySuppressionStore(), SuppressionConfig{
+		Limits:       map[Channel]int{SMS: 3},
+		RateWindow:   time.Hour,
+		DedupeWindow: 10 * time.Minute,
+	}))
+
+	process := func(id, code string) error {
+		body, _ := json.Marshal(NotificationTask{ID: id, Channel: SMS, Recipient: "+15550100", TemplateID: "otp",
+			Data: map[string]string{"OTPCode": code}})
+		return worker.ProcessTask(ctx, body)
+	}
+
+	if err := process("task-1", "1111"); err != nil {
+		t.Fatalf("ProcessTask failed: %v", err)
+	}
+	// The same task again is not a duplicate of itself, e.g. when redelivered
+	if err := process("task-1", "1111"); err != nil {
+		t.Errorf("got %v redelivering a task", err)
+	}
+
+	var suppressed *SuppressedError
+	if err := process("task-2", "1111"); !errors.As(err, &suppressed) || suppressed.Reason != SuppressedDuplicate {
+		t.Errorf("got %v, want a duplicate", err)
+	}
+
+	if err := process("task-3", "2222"); err != nil {
+		t.Fatalf("ProcessTask failed: %v", err)
+	}
+	if err := process("task-4", "3333"); !errors.As(err, &suppressed) || suppressed.Reason != SuppressedRateLimit {
+		t.Errorf("got %v, want the rate limit", err)
+	}
+	if driver.sent != 3 {
+		t.Errorf("got %d messages sent, want 3", driver.sent)
+	}
+}
+
+func TestSuppressor_LimitsPerRecipientAndChannel(t *testing.T) {
+	ctx := context.Background()
+	suppressor := NewSuppressor(newMemorySuppressionStore(), SuppressionConfig{
+		Limits:     map[Channel]int{SMS: 1},
+		RateWindow: time.Hour,
+	})
+
+	first := &NotificationTask{ID: "task-1", Recipient: "+15550100", TemplateID: "otp"}
+	suppressor.Record(ctx, first, SMS)
+	for i, tt := range []struct {
+		recipient string
+		channel   Channel
+		limited   bool
+	}{
+		{"+15550100", SMS, true},
+		{"+15550199", SMS, false}, // Another recipient
+		{"+15550100", Web, false}, // No limit on web
+	} {
+		task := &NotificationTask{ID: fmt.Sprintf("task-%d", i+2), Recipient: tt.recipient, TemplateID: "otp"}
+		err := suppressor.Check(ctx, task, tt.channel)
+		if (err != nil) != tt.limited {
+			t.Errorf("%s on %s: got %v, want limited %v", tt.recipient, tt.channel, err, tt.limited)
+		}
+	}
+
+	// Duplicates are sent without a dedupe window
+	if err := suppressor.Check(ctx, &NotificationTask{ID: "task-9", Recipient: "+15550100", TemplateID: "otp"}, Web); err != nil {
+		t.Errorf("got %v, want duplicates allowed", err)
+	}
+}
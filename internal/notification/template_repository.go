@@ -0,0 +1,151 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const templateColumns = `id, name, channel, locale, version, status, subject, body, created_by, created_at, updated_at, published_at`
+
+// CreateTemplate inserts the template as the next version of its name,
+// channel and locale.
+func (r *Repository) CreateTemplate(ctx context.Context, t *Template) error {
+	query := `
+		INSERT INTO notification_templates (name, channel, locale, version, status, subject, body, created_by, created_at, updated_at)
+		SELECT $1, $2, $3, COALESCE(MAX(version), 0) + 1, $4, $5, $6, $7, $8, $9
+		FROM notification_templates WHERE name = $1 AND channel = $2 AND locale = $3
+		RETURNING id, version
+	`
+	return r.db.QueryRowContext(ctx, query,
+		t.Name, t.Channel, t.Locale, t.Status, t.Subject, t.Body, t.CreatedBy, t.CreatedAt, t.UpdatedAt,
+	).Scan(&t.ID, &t.Version)
+}
+
+// GetTemplate retrieves a template version by its ID.
+func (r *Repository) GetTemplate(ctx context.Context, id string) (*Template, error) {
+	query := `SELECT ` + templateColumns + ` FROM notification_templates WHERE id::text = $1`
+	t, err := scanTemplate(r.db.QueryRowContext(ctx, query, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// ListTemplates retrieves the template versions matching the filter.
+func (r *Repository) ListTemplates(ctx context.Context, filter TemplateFilter) ([]Template, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.Name != "" {
+		conditions = append(conditions, "name = "+arg(filter.Name))
+	}
+	if filter.Channel != "" {
+		conditions = append(conditions, "channel = "+arg(filter.Channel))
+	}
+	if filter.Locale != "" {
+		conditions = append(conditions, "locale = "+arg(filter.Locale))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+arg(filter.Status))
+	}
+
+	query := `SELECT ` + templateColumns + ` FROM notification_templates`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY name, channel, locale, version DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	templates := []Template{}
+	for rows.Next() {
+		t, err := scanTemplate(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+	return templates, rows.Err()
+}
+
+// UpdateTemplate saves a draft's subject and body.
+func (r *Repository) UpdateTemplate(ctx context.Context, t *Template) error {
+	query := `UPDATE notification_templates SET subject = $1, body = $2, updated_at = $3 WHERE id = $4 AND status = 'draft'`
+	result, err := r.db.ExecContext(ctx, query, t.Subject, t.Body, t.UpdatedAt, t.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTemplateNotDraft
+	}
+	return nil
+}
+
+// DeleteTemplate deletes a draft.
+func (r *Repository) DeleteTemplate(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM notification_templates WHERE id::text = $1 AND status = 'draft'`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTemplateNotDraft
+	}
+	return nil
+}
+
+// PublishTemplate publishes a draft and archives the version it replaces,
+// in one transaction.
+func (r *Repository) PublishTemplate(ctx context.Context, t *Template) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE notification_templates SET status = 'archived', updated_at = $4
+		WHERE name = $1 AND channel = $2 AND locale = $3 AND status = 'published'
+	`, t.Name, t.Channel, t.Locale, t.UpdatedAt); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `
+		UPDATE notification_templates SET status = 'published', published_at = $1, updated_at = $2
+		WHERE id = $3 AND status = 'draft'
+	`, t.PublishedAt, t.UpdatedAt, t.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTemplateNotDraft
+	}
+	return tx.Commit()
+}
+
+// PublishedTemplate retrieves the published version of a template.
+func (r *Repository) PublishedTemplate(ctx context.Context, name string, channel Channel, locale string) (*Template, error) {
+	query := `SELECT ` + templateColumns + ` FROM notification_templates
+		WHERE name = $1 AND channel = $2 AND locale = $3 AND status = 'published'`
+	t, err := scanTemplate(r.db.QueryRowContext(ctx, query, name, channel, locale).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+func scanTemplate(scan func(dest ...interface{}) error) (*Template, error) {
+	var t Template
+	if err := scan(&t.ID, &t.Name, &t.Channel, &t.Locale, &t.Version, &t.Status, &t.Subject, &t.Body,
+		&t.CreatedBy, &t.CreatedAt, &t.UpdatedAt, &t.PublishedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,354 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	htmltemplate "html/template"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Template statuses. A template is written as a draft, and replaces the
+// published version of its name, channel and locale once published.
+const (
+	TemplateDraft     = "draft"
+	TemplatePublished = "published"
+	TemplateArchived  = "archived"
+)
+
+// DefaultLocale is the locale templates fall back to
+const DefaultLocale = "en"
+
+// templateCacheTTL is how long published templates are cached, so changes
+// published on other instances are picked up
+const templateCacheTTL = time.Minute
+
+var (
+	ErrTemplateNotFound      = errors.New("template not found")
+	ErrTemplateNotDraft      = errors.New("only drafts can be changed")
+	ErrInvalidTemplate       = errors.New("name, channel and body are required")
+	ErrTemplateSyntax        = errors.New("template does not parse")
+	ErrInvalidTemplateStatus = errors.New("status must be draft, published or archived")
+)
+
+// Template is a version of a notification template stored in the database.
+// Published templates take over the built-in ones of the same name on their
+// channel. Email bodies are HTML, rendered within the email layout.
+type Template struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"` // The template ID notifications refer to, e.g. payment_success
+	Channel     Channel    `json:"channel"`
+	Locale      string     `json:"locale"`
+	Version     int        `json:"version"`
+	Status      string     `json:"status"`
+	Subject     string     `json:"subject,omitempty"`
+	Body        string     `json:"body"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// Validate checks the template has what it needs and parses
+func (t *Template) Validate() error {
+	if t.Name == "" || t.Body == "" {
+		return ErrInvalidTemplate
+	}
+	switch t.Channel {
+	case Email, SMS, Web:
+	default:
+		return ErrInvalidChannel
+	}
+	if t.Locale == "" {
+		t.Locale = DefaultLocale
+	}
+	if _, err := template.New("subject").Parse(t.Subject); err != nil {
+		return ErrTemplateSyntax
+	}
+	if t.Channel == Email {
+		if _, err := htmltemplate.New("body").Parse(t.Body); err != nil {
+			return ErrTemplateSyntax
+		}
+	} else if _, err := template.New("body").Parse(t.Body); err != nil {
+		return ErrTemplateSyntax
+	}
+	return nil
+}
+
+// TemplateFilter selects templates, newest versions first
+type TemplateFilter struct {
+	Name    string
+	Channel Channel
+	Locale  string
+	Status  string
+}
+
+// TemplateStore keeps the versions of templates
+type TemplateStore interface {
+	// CreateTemplate adds the template as the next version of its name,
+	// channel and locale
+	CreateTemplate(ctx context.Context, t *Template) error
+	// GetTemplate returns the template, or nil if there is none
+	GetTemplate(ctx context.Context, id string) (*Template, error)
+	ListTemplates(ctx context.Context, filter TemplateFilter) ([]Template, error)
+	// UpdateTemplate saves a draft's subject and body
+	UpdateTemplate(ctx context.Context, t *Template) error
+	DeleteTemplate(ctx context.Context, id string) error
+	// PublishTemplate publishes a draft, archiving the version it replaces
+	PublishTemplate(ctx context.Context, t *Template) error
+	// PublishedTemplate returns the published version, or nil if there is
+	// none
+	PublishedTemplate(ctx context.Context, name string, channel Channel, locale string) (*Template, error)
+}
+
+// TemplateService manages template drafts and their publication
+type TemplateService struct {
+	store    TemplateStore
+	renderer *Renderer
+}
+
+func NewTemplateService(store TemplateStore, renderer *Renderer) *TemplateService {
+	return &TemplateService{store: store, renderer: renderer}
+}
+
+// CreateTemplate adds a draft as the next version of the template
+func (s *TemplateService) CreateTemplate(ctx context.Context, t *Template) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	t.Status = TemplateDraft
+	t.CreatedAt, t.UpdatedAt = now, now
+	t.PublishedAt = nil
+	return s.store.CreateTemplate(ctx, t)
+}
+
+func (s *TemplateService) GetTemplate(ctx context.Context, id string) (*Template, error) {
+	t, err := s.store.GetTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrTemplateNotFound
+	}
+	return t, nil
+}
+
+func (s *TemplateService) ListTemplates(ctx context.Context, filter TemplateFilter) ([]Template, error) {
+	switch filter.Status {
+	case "", TemplateDraft, TemplatePublished, TemplateArchived:
+	default:
+		return nil, ErrInvalidTemplateStatus
+	}
+	return s.store.ListTemplates(ctx, filter)
+}
+
+// UpdateTemplate changes a draft's subject and body
+func (s *TemplateService) UpdateTemplate(ctx context.Context, t *Template, subject, body string) error {
+	if t.Status != TemplateDraft {
+		return ErrTemplateNotDraft
+	}
+	t.Subject, t.Body = subject, body
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	t.UpdatedAt = time.Now().UTC()
+	return s.store.UpdateTemplate(ctx, t)
+}
+
+// DeleteTemplate discards a draft. Published and archived versions are
+// kept as the template's history.
+func (s *TemplateService) DeleteTemplate(ctx context.Context, t *Template) error {
+	if t.Status != TemplateDraft {
+		return ErrTemplateNotDraft
+	}
+	return s.store.DeleteTemplate(ctx, t.ID)
+}
+
+// PublishTemplate makes a draft the version notifications are rendered with
+func (s *TemplateService) PublishTemplate(ctx context.Context, t *Template) error {
+	if t.Status != TemplateDraft {
+		return ErrTemplateNotDraft
+	}
+	now := time.Now().UTC()
+	t.Status = TemplatePublished
+	t.PublishedAt = &now
+	t.UpdatedAt = now
+	if err := s.store.PublishTemplate(ctx, t); err != nil {
+		return err
+	}
+	s.renderer.forget(t.Name, t.Channel, t.Locale)
+	return nil
+}
+
+// Rendered is a notification rendered from its template
+type Rendered struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// SampleData fills the variables of the built-in templates for previews
+var SampleData = map[string]string{
+	"UserName":      "Jane Doe",
+	"Amount":        "42.00",
+	"Currency":      "USD",
+	"TransactionID": "pay_sample",
+	"RefundID":      "ref_sample",
+	"FailReason":    "card_declined",
+	"OTPCode":       "123456",
+	"Code":          "123456",
+	"ExpiryMinutes": "10",
+	"Link":          "https://sapliy.com/verify?token=sample",
+}
+
+// Preview renders the template, published or not, with the data laid over
+// SampleData
+func (s *TemplateService) Preview(t *Template, data map[string]string) (*Rendered, error) {
+	merged := make(map[string]string, len(SampleData)+len(data))
+	for k, v := range SampleData {
+		merged[k] = v
+	}
+	for k, v := range data {
+		merged[k] = v
+	}
+	return renderStored(t, merged)
+}
+
+// Renderer renders notifications with their published templates, falling
+// back to the built-in ones. The locale comes from the notification's
+// "Locale" data, falling back to its language and then to DefaultLocale.
+// A nil Renderer only uses the built-in templates.
+type Renderer struct {
+	store TemplateStore
+
+	mu    sync.Mutex
+	cache map[string]cachedTemplate
+}
+
+type cachedTemplate struct {
+	template *Template // nil when none is published
+	loadedAt time.Time
+}
+
+func NewRenderer(store TemplateStore) *Renderer {
+	return &Renderer{store: store, cache: make(map[string]cachedTemplate)}
+}
+
+// Render renders the notification of the template for the channel, with
+// its amounts and dates written for the data's locale
+func (r *Renderer) Render(ctx context.Context, name string, channel Channel, data map[string]string) (*Rendered, error) {
+	data = Localize(data)
+	if r != nil {
+		for _, locale := range candidateLocales(data["Locale"]) {
+			t, err := r.published(ctx, name, channel, locale)
+			if err != nil {
+				log.Printf("Failed to get template %s (%s, %s), using the built-in one: %v", name, channel, locale, err)
+				break
+			}
+			if t != nil {
+				return renderStored(t, data)
+			}
+		}
+	}
+	return renderBuiltin(name, channel, data)
+}
+
+func (r *Renderer) published(ctx context.Context, name string, channel Channel, locale string) (*Template, error) {
+	key := name + "|" + string(channel) + "|" + locale
+	r.mu.Lock()
+	cached, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < templateCacheTTL {
+		return cached.template, nil
+	}
+
+	t, err := r.store.PublishedTemplate(ctx, name, channel, locale)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.cache[key] = cachedTemplate{template: t, loadedAt: time.Now()}
+	r.mu.Unlock()
+	return t, nil
+}
+
+// forget drops a cached template, for a publication to apply right away
+func (r *Renderer) forget(name string, channel Channel, locale string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, name+"|"+string(channel)+"|"+locale)
+}
+
+// candidateLocales lists the locales to look for, e.g. pt-BR, pt and en
+func candidateLocales(locale string) []string {
+	var locales []string
+	if locale != "" {
+		locales = append(locales, locale)
+		if lang, _, ok := strings.Cut(locale, "-"); ok {
+			locales = append(locales, lang)
+		}
+	}
+	if !slices.Contains(locales, DefaultLocale) {
+		locales = append(locales, DefaultLocale)
+	}
+	return locales
+}
+
+func renderStored(t *Template, data map[string]string) (*Rendered, error) {
+	var subject bytes.Buffer
+	subjectTmpl, err := template.New("subject").Parse(t.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if err := subjectTmpl.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+
+	if t.Channel == Email {
+		body, err := renderEmail(t.Body, data)
+		if err != nil {
+			return nil, err
+		}
+		return &Rendered{Subject: subject.String(), Body: body}, nil
+	}
+	var body bytes.Buffer
+	bodyTmpl, err := template.New("body").Parse(t.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := bodyTmpl.Execute(&body, data); err != nil {
+		return nil, err
+	}
+	return &Rendered{Subject: subject.String(), Body: body.String()}, nil
+}
+
+// renderBuiltin renders the built-in template. Emails without an HTML
+// template of their own get the text one.
+func renderBuiltin(name string, channel Channel, data map[string]string) (*Rendered, error) {
+	subject := data["Title"]
+	if channel == Email {
+		if body, err := RenderEmailTemplate(name, data); err == nil {
+			return &Rendered{Subject: GetEmailSubject(name), Body: body}, nil
+		}
+		if subject == "" {
+			subject = GetEmailSubject(name)
+		}
+	}
+
+	body, err := RenderTemplate(name, data)
+	if err != nil {
+		return nil, err
+	}
+	if subject == "" {
+		subject = "Notification"
+	}
+	return &Rendered{Subject: subject, Body: body}, nil
+}
@@ -0,0 +1,195 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// memoryTemplateStore keeps template versions in a slice
+type memoryTemplateStore struct {
+	templates []*Template
+}
+
+func (s *memoryTemplateStore) CreateTemplate(ctx context.Context, t *Template) error {
+	t.Version = 1
+	for _, existing := range s.templates {
+		if existing.Name == t.Name && existing.Channel == t.Channel && existing.Locale == t.Locale && existing.Version >= t.Version {
+			t.Version = existing.Version + 1
+		}
+	}
+	t.ID = fmt.Sprintf("tmpl_%d", len(s.templates)+1)
+	stored := *t
+	s.templates = append(s.templates, &stored)
+	return nil
+}
+
+func (s *memoryTemplateStore) GetTemplate(ctx context.Context, id string) (*Template, error) {
+	for _, t := range s.templates {
+		if t.ID == id {
+			found := *t
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryTemplateStore) ListTemplates(ctx context.Context, filter TemplateFilter) ([]Template, error) {
+	var templates []Template
+	for _, t := range s.templates {
+		templates = append(templates, *t)
+	}
+	return templates, nil
+}
+
+func (s *memoryTemplateStore) UpdateTemplate(ctx context.Context, t *Template) error {
+	stored, _ := s.find(t.ID)
+	stored.Subject, stored.Body, stored.UpdatedAt = t.Subject, t.Body, t.UpdatedAt
+	return nil
+}
+
+func (s *memoryTemplateStore) DeleteTemplate(ctx context.Context, id string) error {
+	_, i := s.find(id)
+	s.templates = append(s.templates[:i], s.templates[i+1:]...)
+	return nil
+}
+
+func (s *memoryTemplateStore) PublishTemplate(ctx context.Context, t *Template) error {
+	for _, existing := range s.templates {
+		if existing.Name == t.Name && existing.Channel == t.Channel && existing.Locale == t.Locale && existing.Status == TemplatePublished {
+			existing.Status = TemplateArchived
+		}
+	}
+	stored, _ := s.find(t.ID)
+	stored.Status, stored.PublishedAt = TemplatePublished, t.PublishedAt
+	return nil
+}
+
+func (s *memoryTemplateStore) PublishedTemplate(ctx context.Context, name string, channel Channel, locale string) (*Template, error) {
+	for _, t := range s.templates {
+		if t.Name == name && t.Channel == channel && t.Locale == locale && t.Status == TemplatePublished {
+			found := *t
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryTemplateStore) find(id string) (*Template, int) {
+	for i, t := range s.templates {
+		if t.ID == id {
+			return t, i
+		}
+	}
+	return nil, -1
+}
+
+func TestTemplateService_VersionsAndPublishes(t *testing.T) {
+	store := &memoryTemplateStore{}
+	renderer := NewRenderer(store)
+	service := NewTemplateService(store, renderer)
+	ctx := context.Background()
+	data := map[string]string{"UserName": "Ada", "Amount": "10.00", "Currency": "EUR"}
+
+	first := &Template{Name: "payment_success", Channel: SMS, Subject: "Paid", Body: "v1 {{.Amount}} {{.Currency}}"}
+	if err := service.CreateTemplate(ctx, first); err != nil {
+		t.Fatalf("CreateTemplate failed: %v", err)
+	}
+	if first.Version != 1 || first.Status != TemplateDraft || first.Locale != DefaultLocale {
+		t.Fatalf("unexpected draft: %+v", first)
+	}
+
+	// Drafts are not used
+	rendered, err := renderer.Render(ctx, "payment_success", SMS, data)
+	if err != nil || !strings.Contains(rendered.Body, "Your payment of 10.00 EUR was successful") {
+		t.Fatalf("expected the built-in template, got %+v (%v)", rendered, err)
+	}
+
+	if err := service.UpdateTemplate(ctx, first, "Paid", "v1 {{.Amount}} {{.Currency}}!"); err != nil {
+		t.Fatalf("UpdateTemplate failed: %v", err)
+	}
+	if err := service.PublishTemplate(ctx, first); err != nil {
+		t.Fatalf("PublishTemplate failed: %v", err)
+	}
+	if err := service.UpdateTemplate(ctx, first, "Paid", "changed"); err != ErrTemplateNotDraft {
+		t.Errorf("expected published templates to be read-only, got %v", err)
+	}
+	rendered, err = renderer.Render(ctx, "payment_success", SMS, data)
+	if err != nil || rendered.Body != "v1 10.00 EUR!" || rendered.Subject != "Paid" {
+		t.Fatalf("expected the published template, got %+v (%v)", rendered, err)
+	}
+
+	second := &Template{Name: "payment_success", Channel: SMS, Body: "v2 {{.Amount}}"}
+	if err := service.CreateTemplate(ctx, second); err != nil {
+		t.Fatalf("CreateTemplate failed: %v", err)
+	}
+	if second.Version != 2 {
+		t.Errorf("expected version 2, got %d", second.Version)
+	}
+	if err := service.PublishTemplate(ctx, second); err != nil {
+		t.Fatalf("PublishTemplate failed: %v", err)
+	}
+	if stored, _ := store.find(first.ID); stored.Status != TemplateArchived {
+		t.Errorf("expected the first version to be archived, got %s", stored.Status)
+	}
+	rendered, _ = renderer.Render(ctx, "payment_success", SMS, data)
+	if rendered.Body != "v2 10.00" {
+		t.Errorf("expected the second version, got %q", rendered.Body)
+	}
+
+	if err := service.CreateTemplate(ctx, &Template{Name: "bad", Channel: SMS, Body: "{{.Amount"}); err != ErrTemplateSyntax {
+		t.Errorf("expected a template that does not parse to be rejected, got %v", err)
+	}
+}
+
+func TestRenderer_FallsBackThroughLocales(t *testing.T) {
+	store := &memoryTemplateStore{}
+	renderer := NewRenderer(store)
+	service := NewTemplateService(store, renderer)
+	ctx := context.Background()
+
+	for _, tmpl := range []*Template{
+		{Name: "otp", Channel: SMS, Locale: "en", Body: "Code {{.OTPCode}}"},
+		{Name: "otp", Channel: SMS, Locale: "pt", Body: "Código {{.OTPCode}}"},
+	} {
+		if err := service.CreateTemplate(ctx, tmpl); err != nil {
+			t.Fatalf("CreateTemplate failed: %v", err)
+		}
+		if err := service.PublishTemplate(ctx, tmpl); err != nil {
+			t.Fatalf("PublishTemplate failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"pt-BR", "Código 1234"},
+		{"pt", "Código 1234"},
+		{"fr", "Code 1234"},
+		{"", "Code 1234"},
+	}
+	for _, tt := range tests {
+		rendered, err := renderer.Render(ctx, "otp", SMS, map[string]string{"OTPCode": "1234", "Locale": tt.locale})
+		if err != nil || rendered.Body != tt.want {
+			t.Errorf("locale %q: got %+v (%v), want %q", tt.locale, rendered, err, tt.want)
+		}
+	}
+}
+
+func TestTemplateService_Preview(t *testing.T) {
+	service := NewTemplateService(&memoryTemplateStore{}, nil)
+	tmpl := &Template{Name: "payment_success", Channel: Email, Subject: "Hi {{.UserName}}", Body: "<p>{{.Amount}} {{.Currency}}</p>"}
+
+	rendered, err := service.Preview(tmpl, map[string]string{"Currency": "GBP"})
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if rendered.Subject != "Hi Jane Doe" {
+		t.Errorf("expected the sample data in the subject, got %q", rendered.Subject)
+	}
+	if !strings.Contains(rendered.Body, "<p>42.00 GBP</p>") || !strings.Contains(rendered.Body, "<!DOCTYPE html>") {
+		t.Errorf("expected the email within the layout, got %q", rendered.Body)
+	}
+}
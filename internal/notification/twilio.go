@@ -0,0 +1,136 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const twilioAPIURL = "https://api.twilio.com/2010-04-01"
+
+// twilioPermanentCodes are Twilio errors sending again cannot fix, e.g. an
+// invalid number or a recipient who replied STOP
+var twilioPermanentCodes = map[int]bool{
+	21211: true, // Invalid 'To' phone number
+	21408: true, // Region not enabled
+	21610: true, // Recipient unsubscribed
+	21612: true, // Unreachable 'To' number
+	21614: true, // 'To' number is not a mobile number
+}
+
+// TwilioConfig configures the Twilio SMS driver
+type TwilioConfig struct {
+	AccountSID string
+	AuthToken  string
+	// Messages are sent from the number, or through the messaging service
+	// when one is given
+	FromNumber          string
+	MessagingServiceSID string
+	// StatusCallbackURL receives the messages' delivery statuses
+	StatusCallbackURL string
+}
+
+// TwilioDriver sends SMS through Twilio's Messages API
+type TwilioDriver struct {
+	config     TwilioConfig
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewTwilioDriver(config TwilioConfig) (*TwilioDriver, error) {
+	if config.AccountSID == "" || config.AuthToken == "" {
+		return nil, fmt.Errorf("twilio account SID and auth token are required")
+	}
+	if config.FromNumber == "" && config.MessagingServiceSID == "" {
+		return nil, fmt.Errorf("twilio from number or messaging service SID is required")
+	}
+	return &TwilioDriver{
+		config:     config,
+		baseURL:    twilioAPIURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (d *TwilioDriver) Channel() Channel {
+	return SMS
+}
+
+func (d *TwilioDriver) Provider() string {
+	return ProviderTwilio
+}
+
+func (d *TwilioDriver) Send(ctx context.Context, recipient, title, content string) error {
+	_, err := d.SendMessage(ctx, recipient, title, content)
+	return err
+}
+
+// SendMessage sends the SMS, returning its message SID
+func (d *TwilioDriver) SendMessage(ctx context.Context, recipient, title, content string) (string, error) {
+	form := url.Values{"To": {recipient}, "Body": {content}}
+	if d.config.MessagingServiceSID != "" {
+		form.Set("MessagingServiceSid", d.config.MessagingServiceSID)
+	} else {
+		form.Set("From", d.config.FromNumber)
+	}
+	if d.config.StatusCallbackURL != "" {
+		form.Set("StatusCallback", d.config.StatusCallbackURL)
+	}
+
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", d.baseURL, d.config.AccountSID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(d.config.AccountSID, d.config.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send SMS via Twilio: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read Twilio response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return "", twilioError(resp.StatusCode, body)
+	}
+
+	var message struct {
+		SID string `json:"sid"`
+	}
+	if err := json.Unmarshal(body, &message); err != nil || message.SID == "" {
+		return "", fmt.Errorf("unexpected Twilio response: %s", body)
+	}
+	return message.SID, nil
+}
+
+// twilioError classifies Twilio's answer to a failed request: client errors
+// are permanent unless Twilio throttled the request
+func twilioError(status int, body []byte) *SendError {
+	var apiErr struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(body, &apiErr)
+
+	retryable := retryableStatus(status)
+	if twilioPermanentCodes[apiErr.Code] {
+		retryable = false
+	}
+	return &SendError{
+		Provider:  ProviderTwilio,
+		Status:    status,
+		Code:      strconv.Itoa(apiErr.Code),
+		Message:   apiErr.Message,
+		Retryable: retryable,
+	}
+}
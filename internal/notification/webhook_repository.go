@@ -0,0 +1,41 @@
+package notification
+
+import (
+	"context"
+)
+
+// AddWebhookAttempt inserts the result of an attempt to deliver a webhook.
+func (r *Repository) AddWebhookAttempt(ctx context.Context, a *WebhookAttempt) error {
+	query := `
+		INSERT INTO notification_webhook_attempts (id, webhook_id, url, attempt, status_code, error, duration_ms, delivered, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		a.ID, a.WebhookID, a.URL, a.Attempt, a.StatusCode, a.Error, a.DurationMS, a.Delivered, a.OccurredAt,
+	)
+	return err
+}
+
+// ListWebhookAttempts retrieves the attempts to deliver a webhook, oldest
+// first.
+func (r *Repository) ListWebhookAttempts(ctx context.Context, webhookID string) ([]WebhookAttempt, error) {
+	query := `
+		SELECT id, webhook_id, url, attempt, status_code, error, duration_ms, delivered, occurred_at
+		FROM notification_webhook_attempts WHERE webhook_id = $1 ORDER BY occurred_at, attempt
+	`
+	rows, err := r.db.QueryContext(ctx, query, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []WebhookAttempt
+	for rows.Next() {
+		var a WebhookAttempt
+		if err := rows.Scan(&a.ID, &a.WebhookID, &a.URL, &a.Attempt, &a.StatusCode, &a.Error, &a.DurationMS, &a.Delivered, &a.OccurredAt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
@@ -216,3 +216,83 @@ func TestWebhookWorker_ProcessWebhook(t *testing.T) {
 		}
 	})
 }
+
+// memoryWebhookAttemptStore keeps webhook attempts in a slice
+type memoryWebhookAttemptStore struct {
+	attempts []WebhookAttempt
+}
+
+func (s *memoryWebhookAttemptStore) AddWebhookAttempt(ctx context.Context, a *WebhookAttempt) error {
+	s.attempts = append(s.attempts, *a)
+	return nil
+}
+
+func TestWebhookWorker_RetriesWithTheWholeSignedPayload(t *testing.T) {
+	payload := `{"amount":1000}`
+	var bodies []string
+	worker := NewWebhookWorker(nil)
+	worker.SetConfig(WebhookConfig{Timeout: time.Second, MaxRetries: 2, InitialBackoff: time.Millisecond})
+	store := &memoryWebhookAttemptStore{}
+	worker.SetAttemptStore(store)
+	worker.httpClient.Transport = &MockTransport{
+		RoundTripFunc: func(r *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			if err := VerifyWebhookSignature("test_secret", r.Header.Get("X-Sapliy-Timestamp"), r.Header.Get("X-Sapliy-Signature-V2"), body, time.Now()); err != nil {
+				return nil, err
+			}
+			status := http.StatusServiceUnavailable
+			if len(bodies) == 2 {
+				status = http.StatusOK
+			}
+			return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+		},
+	}
+
+	task, _ := json.Marshal(WebhookTask{ID: "wh_1", URL: "http://example.com/hook", Payload: json.RawMessage(payload), Secret: "test_secret"})
+	if err := worker.ProcessWebhook(context.Background(), task); err != nil {
+		t.Fatalf("ProcessWebhook failed: %v", err)
+	}
+	if len(bodies) != 2 || bodies[1] != payload {
+		t.Errorf("got bodies %q, want the payload sent twice", bodies)
+	}
+
+	if len(store.attempts) != 2 {
+		t.Fatalf("got %d attempts recorded, want 2", len(store.attempts))
+	}
+	first, second := store.attempts[0], store.attempts[1]
+	if first.Delivered || first.StatusCode != http.StatusServiceUnavailable || first.Error == "" {
+		t.Errorf("got first attempt %+v, want it failed with 503", first)
+	}
+	if !second.Delivered || second.Attempt != 2 || second.StatusCode != http.StatusOK {
+		t.Errorf("got second attempt %+v, want it delivered", second)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1706972400, 0)
+	payload := []byte(`{"id":"evt_1"}`)
+	timestamp := "1706972400"
+	signature := SignWebhook("whsec", timestamp, payload)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		payload   []byte
+		now       time.Time
+		valid     bool
+	}{
+		{"valid", timestamp, signature, payload, now, true},
+		{"tampered payload", timestamp, signature, []byte(`{"id":"evt_2"}`), now, false},
+		{"other timestamp", "1706972401", signature, payload, now, false},
+		{"replayed", timestamp, signature, payload, now.Add(10 * time.Minute), false},
+		{"not signed", timestamp, "", payload, now, false},
+	}
+	for _, tt := range tests {
+		err := VerifyWebhookSignature("whsec", tt.timestamp, tt.signature, tt.payload, tt.now)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
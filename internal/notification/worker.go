@@ -3,16 +3,17 @@ package notification
 import (
 	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -23,6 +24,12 @@ type Worker struct {
 	redis        *redis.Client
 	maxRetry     int
 	emailService *EmailService
+	preferences  *Preferences
+	renderer     *Renderer
+	tracker      *DeliveryTracker
+	scheduler    *Scheduler
+	suppressor   *Suppressor
+	inbox        *Inbox
 }
 
 // NewWorker creates a new notification worker
@@ -36,6 +43,41 @@ func NewWorker(channel Channel, driver Driver, redisClient *redis.Client, emailS
 	}
 }
 
+// SetPreferences makes the worker drop the tasks of users who opted out of
+// its channel, e.g. those queued by flows rather than routed
+func (w *Worker) SetPreferences(preferences *Preferences) {
+	w.preferences = preferences
+}
+
+// SetRenderer makes the worker render tasks with the published templates
+func (w *Worker) SetRenderer(renderer *Renderer) {
+	w.renderer = renderer
+}
+
+// SetTracker makes the worker record the notifications it sends and each
+// attempt to send them
+func (w *Worker) SetTracker(tracker *DeliveryTracker) {
+	w.tracker = tracker
+}
+
+// SetScheduler makes the worker hold the tasks it receives before their
+// send time, e.g. those queued by flows
+func (w *Worker) SetScheduler(scheduler *Scheduler) {
+	w.scheduler = scheduler
+}
+
+// SetSuppressor makes the worker hold back tasks beyond their recipient's
+// rate limit and duplicates of tasks just sent
+func (w *Worker) SetSuppressor(suppressor *Suppressor) {
+	w.suppressor = suppressor
+}
+
+// SetInbox makes the worker push the web notifications it sends to their
+// users' open inboxes
+func (w *Worker) SetInbox(inbox *Inbox) {
+	w.inbox = inbox
+}
+
 // ProcessTask processes a notification task with idempotency and retry logic
 func (w *Worker) ProcessTask(ctx context.Context, body []byte) error {
 	var task NotificationTask
@@ -43,6 +85,23 @@ func (w *Worker) ProcessTask(ctx context.Context, body []byte) error {
 		return fmt.Errorf("failed to unmarshal task: %w", err)
 	}
 
+	if task.SendAt != nil && time.Now().Before(*task.SendAt) {
+		if w.scheduler != nil {
+			_, err := w.scheduler.Schedule(ctx, &task, *task.SendAt)
+			return err
+		}
+		log.Printf("Task %s is scheduled for %s but scheduling is unavailable, sending it now", task.ID, task.SendAt.Format(time.RFC3339))
+	}
+
+	allowed, err := w.preferences.Allowed(ctx, task.Data["UserID"], w.channel, task.EventType)
+	if err != nil {
+		return fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+	if !allowed {
+		log.Printf("Task %s dropped: user %s opted out of %s", task.ID, task.Data["UserID"], w.channel)
+		return nil
+	}
+
 	// Idempotency check
 	if w.redis != nil {
 		idempotencyKey := fmt.Sprintf("notif:sent:%s", task.ID)
@@ -55,39 +114,65 @@ func (w *Worker) ProcessTask(ctx context.Context, body []byte) error {
 		}
 	}
 
+	// Protect recipients from notification storms
+	if err := w.suppressor.Check(ctx, &task, w.channel); err != nil {
+		log.Printf("Task %s to %s suppressed: %v", task.ID, task.Recipient, err)
+		var suppressed *SuppressedError
+		if errors.As(err, &suppressed) {
+			w.tracker.Suppress(ctx, &task, w.channel, suppressed.Reason)
+		}
+		return err
+	}
+
+	// Write the notification in the user's locale
+	task.Data = w.preferences.WithLocale(ctx, task.Data)
+
 	// Check if this is an email task
 	if task.Channel == "email" && w.emailService != nil {
-		subject := GetEmailSubject(task.TemplateID)
-		htmlBody, err := RenderEmailTemplate(task.TemplateID, task.Data)
+		email, err := w.renderer.Render(ctx, task.TemplateID, Email, task.Data)
 		if err != nil {
 			log.Printf("Failed to render email template: %v", err)
 			return err
 		}
 
-		if err := w.emailService.SendEmail(ctx, task.Recipient, subject, htmlBody); err != nil {
+		n := w.tracker.Begin(ctx, &task, Email, email.Subject, email.Body)
+		messageID, err := w.emailService.SendEmail(ctx, task.Recipient, email.Subject, email.Body)
+		w.tracker.Attempt(ctx, n, ProviderResend, messageID, err)
+		if err != nil {
 			log.Printf("Failed to send email: %v", err)
-			return w.handleRetry(ctx, &task, err)
+			return w.handleRetry(ctx, &task, n, err)
 		}
 	} else {
 		// Render template for other drivers
-		content, err := RenderTemplate(task.TemplateID, task.Data)
-		if err != nil {
+		title, content := "Notification", "Notification content unavailable"
+		if rendered, err := w.renderer.Render(ctx, task.TemplateID, w.channel, task.Data); err != nil {
 			log.Printf("Failed to render template: %v", err)
-			content = "Notification content unavailable"
+		} else {
+			title, content = rendered.Subject, rendered.Body
 		}
 
-		title := task.Data["Title"]
-		if title == "" {
-			title = "Notification"
+		// Send via driver, keeping the provider's message ID when it has one
+		n := w.tracker.Begin(ctx, &task, w.channel, title, content)
+		var provider, messageID string
+		var err error
+		if sender, ok := w.driver.(MessageSender); ok {
+			provider = sender.Provider()
+			messageID, err = sender.SendMessage(ctx, task.Recipient, title, content)
+		} else {
+			err = w.driver.Send(ctx, task.Recipient, title, content)
 		}
-
-		// Send via driver
-		if err := w.driver.Send(ctx, task.Recipient, title, content); err != nil {
+		w.tracker.Attempt(ctx, n, provider, messageID, err)
+		if err != nil {
 			log.Printf("Failed to send notification: %v", err)
-			return w.handleRetry(ctx, &task, err)
+			return w.handleRetry(ctx, &task, n, err)
+		}
+		if w.channel == Web {
+			w.inbox.Notify(ctx, n)
 		}
 	}
 
+	w.suppressor.Record(ctx, &task, w.channel)
+
 	// Mark as sent (idempotency)
 	if w.redis != nil {
 		w.redis.Set(ctx, fmt.Sprintf("notif:sent:%s", task.ID), "1", 24*time.Hour)
@@ -97,10 +182,17 @@ func (w *Worker) ProcessTask(ctx context.Context, body []byte) error {
 	return nil
 }
 
-func (w *Worker) handleRetry(ctx context.Context, task *NotificationTask, originalErr error) error {
+func (w *Worker) handleRetry(ctx context.Context, task *NotificationTask, n *Notification, originalErr error) error {
+	if !IsRetryable(originalErr) {
+		log.Printf("Task %s is undeliverable, not retrying: %v", task.ID, originalErr)
+		w.tracker.Fail(ctx, n)
+		return fmt.Errorf("%w: %w", ErrUndeliverable, originalErr)
+	}
+
 	task.RetryCount++
 	if task.RetryCount >= task.MaxRetries {
 		log.Printf("Task %s exceeded max retries, sending to DLQ", task.ID)
+		w.tracker.Fail(ctx, n)
 		return fmt.Errorf("max retries exceeded: %w", originalErr)
 	}
 
@@ -117,18 +209,32 @@ func (w *Worker) handleRetry(ctx context.Context, task *NotificationTask, origin
 
 // WebhookWorker processes webhook delivery tasks
 type WebhookWorker struct {
-	redis      *redis.Client
-	maxRetry   int
-	httpClient *http.Client
+	redis          *redis.Client
+	maxRetry       int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	httpClient     *http.Client
+	attempts       WebhookAttemptStore // nil to not keep delivery results
 }
 
 // NewWebhookWorker creates a new webhook worker
 func NewWebhookWorker(redisClient *redis.Client) *WebhookWorker {
-	return &WebhookWorker{
-		redis:      redisClient,
-		maxRetry:   5,
-		httpClient: &http.Client{Timeout: 10 * time.Second},
-	}
+	w := &WebhookWorker{redis: redisClient}
+	w.SetConfig(DefaultWebhookConfig)
+	return w
+}
+
+// SetConfig sets the timeout, retries and backoff of deliveries
+func (w *WebhookWorker) SetConfig(config WebhookConfig) {
+	w.maxRetry = config.MaxRetries
+	w.initialBackoff = config.InitialBackoff
+	w.maxBackoff = config.MaxBackoff
+	w.httpClient = &http.Client{Timeout: config.Timeout}
+}
+
+// SetAttemptStore keeps the result of every delivery attempt
+func (w *WebhookWorker) SetAttemptStore(store WebhookAttemptStore) {
+	w.attempts = store
 }
 
 // ProcessWebhook processes a webhook delivery task
@@ -156,38 +262,16 @@ func (w *WebhookWorker) ProcessWebhook(ctx context.Context, body []byte) error {
 		return nil
 	}
 
-	// Create HMAC signature
-	signature := createHMAC(task.Payload, task.Secret)
-
-	// Prepare request
-	req, err := http.NewRequestWithContext(ctx, "POST", task.URL, bytes.NewBuffer(task.Payload))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	// New standardized header (aligned with strategic documentation)
-	req.Header.Set("X-Sapliy-Signature", signature)
-	req.Header.Set("X-Sapliy-Event-ID", task.ID)
-	req.Header.Set("X-Sapliy-Event-Type", string(task.EventType))
-	req.Header.Set("X-Sapliy-Timestamp", time.Now().UTC().Format(time.RFC3339))
-	// Backward compatibility: deprecated headers (remove after 2026-05-14)
-	req.Header.Set("X-Webhook-Signature", signature)
-	req.Header.Set("X-Webhook-Event", string(task.EventType))
-	req.Header.Set("X-Webhook-ID", task.ID)
-	req.Header.Set("X-Webhook-Timestamp", time.Now().UTC().Format(time.RFC3339))
-
 	client := w.httpClient
 	if client == nil {
-		client = &http.Client{Timeout: 10 * time.Second}
+		client = &http.Client{Timeout: DefaultWebhookConfig.Timeout}
 	}
 
 	// Retry loop
 	var lastErr error
 	for i := 0; i <= w.maxRetry; i++ {
 		if i > 0 {
-			// Exponential backoff: 1s, 2s, 4s, 8s, 16s
-			sleepDuration := time.Duration(math.Pow(2, float64(i-1))) * time.Second
+			sleepDuration := w.backoff(i)
 			log.Printf("Webhook %s retry %d/%d in %v...", task.ID, i, w.maxRetry, sleepDuration)
 			select {
 			case <-ctx.Done():
@@ -196,46 +280,109 @@ func (w *WebhookWorker) ProcessWebhook(ctx context.Context, body []byte) error {
 			}
 		}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Printf("Webhook %s attempt %d failed: %v", task.ID, i+1, err)
-			lastErr = err
-			continue // Retry on network error
-		}
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			// Success
-			log.Printf("[WEBHOOK] Successfully delivered %s to %s (Status: %d)", task.ID, task.URL, resp.StatusCode)
+		status, err := w.deliver(ctx, client, &task, i+1)
+		if err == nil {
+			log.Printf("[WEBHOOK] Successfully delivered %s to %s (Status: %d)", task.ID, task.URL, status)
 
 			// Mark as delivered
 			if w.redis != nil {
 				w.redis.Set(ctx, fmt.Sprintf("webhook:sent:%s", task.ID), "1", 7*24*time.Hour)
 			}
-			_ = resp.Body.Close()
 			return nil
 		}
+		lastErr = err
+		if status == 0 {
+			log.Printf("Webhook %s attempt %d failed: %v", task.ID, i+1, err)
+			continue // Retry on network error
+		}
 
 		// Handle HTTP errors
-		log.Printf("Webhook %s attempt %d returned status: %d", task.ID, i+1, resp.StatusCode)
-		lastErr = fmt.Errorf("server returned status: %d", resp.StatusCode)
+		log.Printf("Webhook %s attempt %d returned status: %d", task.ID, i+1, status)
 
-		// Don't retry on client errors (4xx) except maybe 429 (Too Many Requests) or 408 (Request Timeout)
-		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != 429 {
-			log.Printf("Webhook %s failed with client error %d, not retrying", task.ID, resp.StatusCode)
-			_ = resp.Body.Close()
+		// Don't retry on client errors (4xx) except 429 (Too Many Requests)
+		if status >= 400 && status < 500 && status != http.StatusTooManyRequests {
+			log.Printf("Webhook %s failed with client error %d, not retrying", task.ID, status)
 			return lastErr
 		}
-		_ = resp.Body.Close()
 	}
 
 	return fmt.Errorf("failed to deliver webhook %s after %d retries: %w", task.ID, w.maxRetry, lastErr)
 }
 
-// createHMAC creates an HMAC signature for webhook verification
-func createHMAC(payload []byte, secret string) string {
-	if secret == "" {
-		return ""
+// deliver posts the webhook once and records the attempt. It returns the
+// endpoint's status, 0 when it did not answer.
+func (w *WebhookWorker) deliver(ctx context.Context, client *http.Client, task *WebhookTask, attempt int) (int, error) {
+	// Each attempt is signed anew, so that its timestamp is current
+	now := time.Now().UTC()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := createHMAC(task.Payload, task.Secret)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", task.URL, bytes.NewReader(task.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	// New standardized header (aligned with strategic documentation)
+	req.Header.Set("X-Sapliy-Signature", signature)
+	req.Header.Set("X-Sapliy-Event-ID", task.ID)
+	req.Header.Set("X-Sapliy-Event-Type", string(task.EventType))
+	req.Header.Set("X-Sapliy-Timestamp", timestamp)
+	req.Header.Set("X-Sapliy-Delivery-Attempt", strconv.Itoa(attempt))
+	if task.Secret != "" {
+		req.Header.Set("X-Sapliy-Signature-V2", SignWebhook(task.Secret, timestamp, task.Payload))
+		req.Header.Set("X-Sapliy-Signature-Version", "2")
+	}
+	// Backward compatibility: deprecated headers (remove after 2026-05-14)
+	req.Header.Set("X-Webhook-Signature", signature)
+	req.Header.Set("X-Webhook-Event", string(task.EventType))
+	req.Header.Set("X-Webhook-ID", task.ID)
+	req.Header.Set("X-Webhook-Timestamp", now.Format(time.RFC3339))
+
+	status := 0
+	resp, err := client.Do(req)
+	if err == nil {
+		status = resp.StatusCode
+		// Drained so that the connection is reused
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		_ = resp.Body.Close()
+		if status < 200 || status >= 300 {
+			err = fmt.Errorf("server returned status: %d", status)
+		}
+	}
+	attemptErr := ""
+	if err != nil {
+		attemptErr = err.Error()
+	}
+	w.record(ctx, &WebhookAttempt{
+		WebhookID:  task.ID,
+		URL:        task.URL,
+		Attempt:    attempt,
+		StatusCode: status,
+		Error:      attemptErr,
+		DurationMS: time.Since(now).Milliseconds(),
+		Delivered:  err == nil,
+		OccurredAt: now,
+	})
+	return status, err
+}
+
+// record keeps an attempt's result. Failing to does not fail the delivery.
+func (w *WebhookWorker) record(ctx context.Context, a *WebhookAttempt) {
+	if w.attempts == nil {
+		return
+	}
+	a.ID = uuid.New().String()
+	if err := w.attempts.AddWebhookAttempt(context.WithoutCancel(ctx), a); err != nil {
+		log.Printf("Failed to record attempt %d of webhook %s: %v", a.Attempt, a.WebhookID, err)
+	}
+}
+
+// backoff returns the wait before the given retry
+func (w *WebhookWorker) backoff(retry int) time.Duration {
+	d := float64(w.initialBackoff) * math.Pow(2, float64(retry-1))
+	if w.maxBackoff > 0 {
+		d = math.Min(d, float64(w.maxBackoff))
 	}
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write(payload)
-	return hex.EncodeToString(h.Sum(nil))
+	return time.Duration(d)
 }
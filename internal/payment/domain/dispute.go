@@ -0,0 +1,282 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Dispute statuses. A dispute waits for the merchant's evidence, is reviewed
+// by the bank once the evidence is submitted, and is closed as won or lost.
+const (
+	DisputeNeedsResponse = "needs_response"
+	DisputeUnderReview   = "under_review"
+	DisputeWon           = "won"
+	DisputeLost          = "lost"
+)
+
+// Where a dispute was opened
+const (
+	DisputeSourceBank   = "bank"
+	DisputeSourceManual = "manual"
+)
+
+// Kafka events of the dispute lifecycle, consumed by fraud and notifications
+const (
+	KafkaDisputeCreated       = "dispute.created"
+	KafkaDisputeEvidenceAdded = "dispute.evidence_added"
+	KafkaDisputeUnderReview   = "dispute.under_review"
+	KafkaDisputeWon           = "dispute.won"
+	KafkaDisputeLost          = "dispute.lost"
+)
+
+// MaxDisputeEvidence caps the evidence attached to one dispute
+const MaxDisputeEvidence = 20
+
+// defaultEvidenceWindow is how long merchants have to respond when the bank
+// sets no deadline
+const defaultEvidenceWindow = 7 * 24 * time.Hour
+
+var (
+	ErrDisputeNotFound      = errors.New("dispute not found")
+	ErrNotDisputable        = errors.New("only collected payments can be disputed")
+	ErrDisputeExceedsAmount = errors.New("dispute exceeds the captured amount")
+	ErrDisputeClosed        = errors.New("dispute is closed")
+	ErrDisputeNotOpen       = errors.New("dispute no longer accepts evidence")
+	ErrNoDisputeEvidence    = errors.New("dispute has no evidence to submit")
+	ErrTooMuchEvidence      = fmt.Errorf("a dispute takes at most %d pieces of evidence", MaxDisputeEvidence)
+	ErrInvalidDisputeAmount = errors.New("dispute amount must be positive")
+	ErrInvalidEvidence      = errors.New("invalid evidence")
+	ErrInvalidOutcome       = errors.New("outcome must be won or lost")
+)
+
+// disputeEvidenceTypes are the kinds of evidence a merchant can attach
+var disputeEvidenceTypes = map[string]bool{
+	"receipt":                true,
+	"shipping_documentation": true,
+	"customer_communication": true,
+	"refund_policy":          true,
+	"service_documentation":  true,
+	"other":                  true,
+}
+
+// Dispute is a chargeback of a payment raised by the customer's bank
+type Dispute struct {
+	ID              string            `json:"id"`
+	PaymentIntentID string            `json:"payment_intent_id"`
+	UserID          string            `json:"user_id"` // The merchant of the disputed payment
+	Amount          int64             `json:"amount"`
+	Currency        string            `json:"currency"`
+	Reason          string            `json:"reason"`
+	Status          string            `json:"status"`
+	Source          string            `json:"source"`
+	BankReference   string            `json:"bank_reference,omitempty"`
+	Evidence        []DisputeEvidence `json:"evidence"`
+	EvidenceDueBy   *time.Time        `json:"evidence_due_by,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// DisputeEvidence describes a document supporting the merchant's case.
+// Only its metadata is kept; the file itself lives in the merchant's storage.
+type DisputeEvidence struct {
+	Type        string    `json:"type"`
+	Description string    `json:"description,omitempty"`
+	FileName    string    `json:"file_name,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int64     `json:"size,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
+// Closed reports whether the bank has decided the dispute
+func (d *Dispute) Closed() bool {
+	return d.Status == DisputeWon || d.Status == DisputeLost
+}
+
+// DisputeFilter selects disputes to list
+type DisputeFilter struct {
+	UserID          string
+	PaymentIntentID string
+	Status          string
+}
+
+// OpenDispute opens a dispute of a collected payment. An amount of zero
+// disputes the whole captured amount.
+func (s *PaymentService) OpenDispute(ctx context.Context, intent *PaymentIntent, dispute *Dispute) error {
+	if intent.AmountCaptured == 0 || (intent.Status != "succeeded" && intent.Status != "partially_refunded" && intent.Status != "refunded") {
+		return ErrNotDisputable
+	}
+	if dispute.Amount == 0 {
+		dispute.Amount = intent.AmountCaptured
+	}
+	if dispute.Amount < 0 {
+		return ErrInvalidDisputeAmount
+	}
+	if dispute.Amount > intent.AmountCaptured {
+		return ErrDisputeExceedsAmount
+	}
+
+	now := time.Now().UTC()
+	dispute.PaymentIntentID = intent.ID
+	dispute.UserID = intent.UserID
+	dispute.Currency = intent.Currency
+	dispute.Status = DisputeNeedsResponse
+	dispute.Evidence = []DisputeEvidence{}
+	if dispute.EvidenceDueBy == nil {
+		dueBy := now.Add(defaultEvidenceWindow)
+		dispute.EvidenceDueBy = &dueBy
+	}
+	dispute.CreatedAt = now
+	dispute.UpdatedAt = now
+
+	txCtx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = txCtx.Rollback() }()
+
+	if err := txCtx.CreateDispute(ctx, dispute); err != nil {
+		return err
+	}
+	if err := s.queueDisputeEvent(ctx, txCtx, KafkaDisputeCreated, dispute); err != nil {
+		return err
+	}
+	return txCtx.Commit()
+}
+
+// GetDispute returns a dispute of the merchant, or of any merchant when
+// userID is empty
+func (s *PaymentService) GetDispute(ctx context.Context, userID, id string) (*Dispute, error) {
+	dispute, err := s.repo.GetDispute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dispute == nil || (userID != "" && dispute.UserID != userID) {
+		return nil, ErrDisputeNotFound
+	}
+	return dispute, nil
+}
+
+func (s *PaymentService) ListDisputes(ctx context.Context, filter DisputeFilter) ([]Dispute, error) {
+	return s.repo.ListDisputes(ctx, filter)
+}
+
+// AddDisputeEvidence attaches evidence to a dispute still waiting for the
+// merchant's response
+func (s *PaymentService) AddDisputeEvidence(ctx context.Context, userID, id string, evidence DisputeEvidence) (*Dispute, error) {
+	if !disputeEvidenceTypes[evidence.Type] {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidEvidence, evidence.Type)
+	}
+	if evidence.Description == "" && evidence.URL == "" {
+		return nil, fmt.Errorf("%w: a description or url is required", ErrInvalidEvidence)
+	}
+	if evidence.Size < 0 {
+		return nil, fmt.Errorf("%w: size must not be negative", ErrInvalidEvidence)
+	}
+	evidence.AddedAt = time.Now().UTC()
+
+	return s.updateDispute(ctx, userID, id, func(d *Dispute) (string, error) {
+		if d.Status != DisputeNeedsResponse {
+			return "", ErrDisputeNotOpen
+		}
+		if len(d.Evidence) >= MaxDisputeEvidence {
+			return "", ErrTooMuchEvidence
+		}
+		d.Evidence = append(d.Evidence, evidence)
+		return KafkaDisputeEvidenceAdded, nil
+	})
+}
+
+// SubmitDispute sends the merchant's evidence to the bank for review
+func (s *PaymentService) SubmitDispute(ctx context.Context, userID, id string) (*Dispute, error) {
+	return s.updateDispute(ctx, userID, id, func(d *Dispute) (string, error) {
+		if d.Status != DisputeNeedsResponse {
+			return "", ErrDisputeNotOpen
+		}
+		if len(d.Evidence) == 0 {
+			return "", ErrNoDisputeEvidence
+		}
+		d.Status = DisputeUnderReview
+		return KafkaDisputeUnderReview, nil
+	})
+}
+
+// CloseDispute records the bank's decision, won or lost. Merchants close a
+// dispute as lost when they accept it.
+func (s *PaymentService) CloseDispute(ctx context.Context, userID, id, outcome string) (*Dispute, error) {
+	if outcome != DisputeWon && outcome != DisputeLost {
+		return nil, ErrInvalidOutcome
+	}
+	return s.updateDispute(ctx, userID, id, func(d *Dispute) (string, error) {
+		if d.Closed() {
+			return "", ErrDisputeClosed
+		}
+		d.Status = outcome
+		if outcome == DisputeWon {
+			return KafkaDisputeWon, nil
+		}
+		return KafkaDisputeLost, nil
+	})
+}
+
+// updateDispute applies change to a locked dispute and queues the event it
+// returns in the same transaction
+func (s *PaymentService) updateDispute(ctx context.Context, userID, id string, change func(d *Dispute) (string, error)) (*Dispute, error) {
+	txCtx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = txCtx.Rollback() }()
+
+	dispute, err := txCtx.GetDisputeForUpdate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dispute == nil || (userID != "" && dispute.UserID != userID) {
+		return nil, ErrDisputeNotFound
+	}
+
+	eventType, err := change(dispute)
+	if err != nil {
+		return nil, err
+	}
+	dispute.UpdatedAt = time.Now().UTC()
+	if err := txCtx.UpdateDispute(ctx, dispute); err != nil {
+		return nil, err
+	}
+	if err := s.queueDisputeEvent(ctx, txCtx, eventType, dispute); err != nil {
+		return nil, err
+	}
+	if err := txCtx.Commit(); err != nil {
+		return nil, err
+	}
+	return dispute, nil
+}
+
+func (s *PaymentService) queueDisputeEvent(ctx context.Context, txCtx TransactionContext, eventType string, dispute *Dispute) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":        fmt.Sprintf("evt_%s_%s_%d", strings.ReplaceAll(eventType, ".", "_"), dispute.ID, dispute.UpdatedAt.UnixNano()),
+		"type":      eventType,
+		"timestamp": dispute.UpdatedAt,
+		"data": map[string]interface{}{
+			"dispute_id":      dispute.ID,
+			"payment_id":      dispute.PaymentIntentID,
+			"user_id":         dispute.UserID,
+			"amount":          dispute.Amount,
+			"currency":        dispute.Currency,
+			"reason":          dispute.Reason,
+			"status":          dispute.Status,
+			"source":          dispute.Source,
+			"evidence_count":  len(dispute.Evidence),
+			"evidence_due_by": dispute.EvidenceDueBy,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return txCtx.CreateOutboxEvent(ctx, eventType, dispute.PaymentIntentID, payload)
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Kafka event types published through the outbox
+const (
+	KafkaPaymentSucceeded = "payment.succeeded"
+	KafkaRefundInitiated  = "refund.initiated"
+	KafkaRefundCompleted  = "refund.completed"
+	KafkaRefundFailed     = "refund.failed"
+)
+
+// paymentSucceededEvent builds the structured payment.succeeded event the
+// Notification Service consumes
+func paymentSucceededEvent(intent *PaymentIntent) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"id":        "evt_" + intent.ID,
+		"type":      KafkaPaymentSucceeded,
+		"timestamp": intent.CreatedAt,
+		"zone_id":   intent.ZoneID,
+		"mode":      intent.Mode,
+		"data": map[string]interface{}{
+			"payment_id":           intent.ID,
+			"user_id":              intent.UserID,
+			"amount":               intent.AmountCaptured,
+			"currency":             intent.Currency,
+			"description":          intent.Description,
+			"statement_descriptor": intent.StatementDescriptor,
+			"metadata":             intent.Metadata,
+			"status":               "succeeded",
+		},
+	})
+}
+
+// refundEvent builds a refund lifecycle event
+func refundEvent(eventType string, intent *PaymentIntent, refund *Refund) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"id":        "evt_" + strings.ReplaceAll(eventType, ".", "_") + "_" + refund.ID,
+		"type":      eventType,
+		"timestamp": time.Now().UTC(),
+		"zone_id":   intent.ZoneID,
+		"mode":      intent.Mode,
+		"data": map[string]interface{}{
+			"refund_id":       refund.ID,
+			"payment_id":      intent.ID,
+			"user_id":         intent.UserID,
+			"amount":          refund.Amount,
+			"amount_refunded": intent.AmountRefunded,
+			"currency":        refund.Currency,
+			"reason":          refund.Reason,
+			"status":          refund.Status,
+			"metadata":        intent.Metadata,
+		},
+	})
+}
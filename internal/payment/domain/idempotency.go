@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrIdempotencyKeyInFlight = errors.New("a request with this idempotency key is in progress")
+
+// IdempotencyScope decides which requests share an Idempotency-Key
+type IdempotencyScope string
+
+const (
+	IdempotencyScopeUser     IdempotencyScope = "user"     // One key per user across all requests
+	IdempotencyScopeZone     IdempotencyScope = "zone"     // One key per user and zone
+	IdempotencyScopeEndpoint IdempotencyScope = "endpoint" // One key per user, method and path
+)
+
+// ParseIdempotencyScope reads a scope name; empty means IdempotencyScopeUser
+func ParseIdempotencyScope(s string) (IdempotencyScope, error) {
+	switch IdempotencyScope(s) {
+	case "":
+		return IdempotencyScopeUser, nil
+	case IdempotencyScopeUser, IdempotencyScopeZone, IdempotencyScopeEndpoint:
+		return IdempotencyScope(s), nil
+	default:
+		return "", fmt.Errorf("unknown idempotency scope %q", s)
+	}
+}
+
+// IdempotencyStore keeps the responses of idempotent requests for replay
+// and locks the keys of requests still executing. Keys are already scoped;
+// they are unique per user.
+type IdempotencyStore interface {
+	Get(ctx context.Context, userID, key string) (*IdempotencyRecord, error)
+	// Save keeps a response for ttl
+	Save(ctx context.Context, record *IdempotencyRecord, ttl time.Duration) error
+	// Lock takes the in-flight lock of a key for at most ttl, failing with
+	// ErrIdempotencyKeyInFlight while another request holds it
+	Lock(ctx context.Context, userID, key string, ttl time.Duration) (unlock func(), err error)
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Limits on the metadata merchants attach to payment intents
+const (
+	MaxMetadataKeys        = 50
+	MaxMetadataKeyLength   = 40
+	MaxMetadataValueLength = 500
+)
+
+// MaxStatementDescriptorLength is the longest descriptor banks print on
+// card statements
+const MaxStatementDescriptorLength = 22
+
+var (
+	ErrInvalidMetadata            = errors.New("invalid metadata")
+	ErrInvalidStatementDescriptor = errors.New("invalid statement_descriptor")
+)
+
+// ValidateMetadata checks metadata against the key count and length limits.
+// Keys may not contain square brackets, which the list endpoint uses to
+// filter by metadata.
+func ValidateMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxMetadataKeys {
+		return fmt.Errorf("%w: at most %d keys are allowed", ErrInvalidMetadata, MaxMetadataKeys)
+	}
+	for key, value := range metadata {
+		if key == "" || len(key) > MaxMetadataKeyLength || strings.ContainsAny(key, "[]") {
+			return fmt.Errorf("%w: key %q must be 1 to %d characters without brackets", ErrInvalidMetadata, key, MaxMetadataKeyLength)
+		}
+		if len(value) > MaxMetadataValueLength {
+			return fmt.Errorf("%w: value of %q is longer than %d characters", ErrInvalidMetadata, key, MaxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// ValidateStatementDescriptor checks a descriptor is printable ASCII of at
+// most MaxStatementDescriptorLength characters, with at least one letter
+// and none of the characters banks reject
+func ValidateStatementDescriptor(descriptor string) error {
+	if descriptor == "" {
+		return nil
+	}
+	if len(descriptor) > MaxStatementDescriptorLength {
+		return fmt.Errorf("%w: at most %d characters are allowed", ErrInvalidStatementDescriptor, MaxStatementDescriptorLength)
+	}
+	hasLetter := false
+	for _, c := range descriptor {
+		if c < ' ' || c > '~' || strings.ContainsRune(`<>\'"*`, c) {
+			return fmt.Errorf("%w: %q is not allowed", ErrInvalidStatementDescriptor, c)
+		}
+		if unicode.IsLetter(c) {
+			hasLetter = true
+		}
+	}
+	if !hasLetter {
+		return fmt.Errorf("%w: at least one letter is required", ErrInvalidStatementDescriptor)
+	}
+	return nil
+}
+
+// LedgerDescription describes a ledger entry of the intent, adding its
+// statement descriptor so entries match what the customer sees
+func (i *PaymentIntent) LedgerDescription(base string) string {
+	if i.StatementDescriptor == "" {
+		return base
+	}
+	return base + " (" + i.StatementDescriptor + ")"
+}
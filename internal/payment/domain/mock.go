@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 type MockRepository struct {
@@ -10,11 +11,36 @@ type MockRepository struct {
 	UpdateStatusFunc        func(ctx context.Context, id, status string) error
 	GetIdempotencyKeyFunc   func(ctx context.Context, userID, key string) (*IdempotencyRecord, error)
 	SaveIdempotencyKeyFunc  func(ctx context.Context, userID, key string, statusCode int, body string) error
-	ListPaymentIntentsFunc  func(ctx context.Context, zoneID string, limit int) ([]PaymentIntent, error)
+	ListPaymentIntentsFunc  func(ctx context.Context, filter PaymentIntentFilter) ([]PaymentIntent, error)
+	ListRefundsFunc         func(ctx context.Context, intentID string) ([]Refund, error)
+	SetProviderRefundIDFunc func(ctx context.Context, id, providerRefundID string) error
+	ListPendingRefundsFunc  func(ctx context.Context, limit int) ([]Refund, error)
+
+	AuthorizePaymentIntentFunc    func(ctx context.Context, id, authorizationID string, expiresAt time.Time) error
+	ListExpiredAuthorizationsFunc func(ctx context.Context, now time.Time, limit int) ([]PaymentIntent, error)
+
+	CreatePaymentMethodFunc func(ctx context.Context, method *PaymentMethod) error
+	GetPaymentMethodFunc    func(ctx context.Context, id string) (*PaymentMethod, error)
+	ListPaymentMethodsFunc  func(ctx context.Context, userID string) ([]PaymentMethod, error)
+
+	GetMerchantSettingsFunc  func(ctx context.Context, userID string) (*MerchantSettings, error)
+	SaveMerchantSettingsFunc func(ctx context.Context, settings *MerchantSettings) error
+
+	GetDisputeFunc   func(ctx context.Context, id string) (*Dispute, error)
+	ListDisputesFunc func(ctx context.Context, filter DisputeFilter) ([]Dispute, error)
+
+	ListUnbatchedPaymentsFunc func(ctx context.Context, cutoff time.Time, limit int) ([]PaymentIntent, error)
+	GetPayoutBatchFunc        func(ctx context.Context, id string) (*PayoutBatch, error)
+	ListPayoutBatchesFunc     func(ctx context.Context, filter PayoutBatchFilter) ([]PayoutBatch, error)
+	MarkPayoutBatchPostedFunc func(ctx context.Context, id string, postedAt time.Time) error
+
+	BeginTxFunc              func(ctx context.Context) (TransactionContext, error)
+	GetUnprocessedEventsFunc func(ctx context.Context, limit int) ([]OutboxEvent, error)
+	MarkEventProcessedFunc   func(ctx context.Context, id string) error
 }
 
-func (m *MockRepository) ListPaymentIntents(ctx context.Context, zoneID string, limit int) ([]PaymentIntent, error) {
-	return m.ListPaymentIntentsFunc(ctx, zoneID, limit)
+func (m *MockRepository) ListPaymentIntents(ctx context.Context, filter PaymentIntentFilter) ([]PaymentIntent, error) {
+	return m.ListPaymentIntentsFunc(ctx, filter)
 }
 
 func (m *MockRepository) CreatePaymentIntent(ctx context.Context, intent *PaymentIntent) error {
@@ -36,3 +62,152 @@ func (m *MockRepository) GetIdempotencyKey(ctx context.Context, userID, key stri
 func (m *MockRepository) SaveIdempotencyKey(ctx context.Context, userID, key string, statusCode int, body string) error {
 	return m.SaveIdempotencyKeyFunc(ctx, userID, key, statusCode, body)
 }
+
+func (m *MockRepository) ListRefunds(ctx context.Context, intentID string) ([]Refund, error) {
+	return m.ListRefundsFunc(ctx, intentID)
+}
+
+func (m *MockRepository) SetProviderRefundID(ctx context.Context, id, providerRefundID string) error {
+	return m.SetProviderRefundIDFunc(ctx, id, providerRefundID)
+}
+
+func (m *MockRepository) ListPendingRefunds(ctx context.Context, limit int) ([]Refund, error) {
+	return m.ListPendingRefundsFunc(ctx, limit)
+}
+
+func (m *MockRepository) AuthorizePaymentIntent(ctx context.Context, id, authorizationID string, expiresAt time.Time) error {
+	return m.AuthorizePaymentIntentFunc(ctx, id, authorizationID, expiresAt)
+}
+
+func (m *MockRepository) ListExpiredAuthorizations(ctx context.Context, now time.Time, limit int) ([]PaymentIntent, error) {
+	return m.ListExpiredAuthorizationsFunc(ctx, now, limit)
+}
+
+func (m *MockRepository) CreatePaymentMethod(ctx context.Context, method *PaymentMethod) error {
+	return m.CreatePaymentMethodFunc(ctx, method)
+}
+
+func (m *MockRepository) GetPaymentMethod(ctx context.Context, id string) (*PaymentMethod, error) {
+	return m.GetPaymentMethodFunc(ctx, id)
+}
+
+func (m *MockRepository) ListPaymentMethods(ctx context.Context, userID string) ([]PaymentMethod, error) {
+	return m.ListPaymentMethodsFunc(ctx, userID)
+}
+
+func (m *MockRepository) GetMerchantSettings(ctx context.Context, userID string) (*MerchantSettings, error) {
+	return m.GetMerchantSettingsFunc(ctx, userID)
+}
+
+func (m *MockRepository) SaveMerchantSettings(ctx context.Context, settings *MerchantSettings) error {
+	return m.SaveMerchantSettingsFunc(ctx, settings)
+}
+
+func (m *MockRepository) GetDispute(ctx context.Context, id string) (*Dispute, error) {
+	return m.GetDisputeFunc(ctx, id)
+}
+
+func (m *MockRepository) ListDisputes(ctx context.Context, filter DisputeFilter) ([]Dispute, error) {
+	return m.ListDisputesFunc(ctx, filter)
+}
+
+func (m *MockRepository) ListUnbatchedPayments(ctx context.Context, cutoff time.Time, limit int) ([]PaymentIntent, error) {
+	return m.ListUnbatchedPaymentsFunc(ctx, cutoff, limit)
+}
+
+func (m *MockRepository) GetPayoutBatch(ctx context.Context, id string) (*PayoutBatch, error) {
+	return m.GetPayoutBatchFunc(ctx, id)
+}
+
+func (m *MockRepository) ListPayoutBatches(ctx context.Context, filter PayoutBatchFilter) ([]PayoutBatch, error) {
+	return m.ListPayoutBatchesFunc(ctx, filter)
+}
+
+func (m *MockRepository) MarkPayoutBatchPosted(ctx context.Context, id string, postedAt time.Time) error {
+	return m.MarkPayoutBatchPostedFunc(ctx, id, postedAt)
+}
+
+func (m *MockRepository) BeginTx(ctx context.Context) (TransactionContext, error) {
+	return m.BeginTxFunc(ctx)
+}
+
+func (m *MockRepository) GetUnprocessedEvents(ctx context.Context, limit int) ([]OutboxEvent, error) {
+	return m.GetUnprocessedEventsFunc(ctx, limit)
+}
+
+func (m *MockRepository) MarkEventProcessed(ctx context.Context, id string) error {
+	return m.MarkEventProcessedFunc(ctx, id)
+}
+
+type MockTransactionContext struct {
+	GetPaymentIntentForUpdateFunc func(ctx context.Context, id string) (*PaymentIntent, error)
+	UpdateStatusFunc              func(ctx context.Context, id, status string) error
+	CapturePaymentIntentFunc      func(ctx context.Context, id string, amount int64) error
+	SetProviderTransactionFunc    func(ctx context.Context, id, methodType, transactionID string) error
+	CreateRefundFunc              func(ctx context.Context, refund *Refund) error
+	UpdateAmountRefundedFunc      func(ctx context.Context, id string, amountRefunded int64, status string) error
+	UpdateRefundStatusFunc        func(ctx context.Context, id, status string) error
+	CreateDisputeFunc             func(ctx context.Context, dispute *Dispute) error
+	GetDisputeForUpdateFunc       func(ctx context.Context, id string) (*Dispute, error)
+	UpdateDisputeFunc             func(ctx context.Context, dispute *Dispute) error
+	CreatePayoutBatchFunc         func(ctx context.Context, batch *PayoutBatch) error
+	CreateOutboxEventFunc         func(ctx context.Context, eventType, aggregateID string, payload []byte) error
+	CommitFunc                    func() error
+	RollbackFunc                  func() error
+}
+
+func (m *MockTransactionContext) GetPaymentIntentForUpdate(ctx context.Context, id string) (*PaymentIntent, error) {
+	return m.GetPaymentIntentForUpdateFunc(ctx, id)
+}
+
+func (m *MockTransactionContext) UpdateStatus(ctx context.Context, id, status string) error {
+	return m.UpdateStatusFunc(ctx, id, status)
+}
+
+func (m *MockTransactionContext) CapturePaymentIntent(ctx context.Context, id string, amount int64) error {
+	return m.CapturePaymentIntentFunc(ctx, id, amount)
+}
+
+func (m *MockTransactionContext) SetProviderTransaction(ctx context.Context, id, methodType, transactionID string) error {
+	return m.SetProviderTransactionFunc(ctx, id, methodType, transactionID)
+}
+
+func (m *MockTransactionContext) CreateRefund(ctx context.Context, refund *Refund) error {
+	return m.CreateRefundFunc(ctx, refund)
+}
+
+func (m *MockTransactionContext) UpdateAmountRefunded(ctx context.Context, id string, amountRefunded int64, status string) error {
+	return m.UpdateAmountRefundedFunc(ctx, id, amountRefunded, status)
+}
+
+func (m *MockTransactionContext) UpdateRefundStatus(ctx context.Context, id, status string) error {
+	return m.UpdateRefundStatusFunc(ctx, id, status)
+}
+
+func (m *MockTransactionContext) CreateDispute(ctx context.Context, dispute *Dispute) error {
+	return m.CreateDisputeFunc(ctx, dispute)
+}
+
+func (m *MockTransactionContext) GetDisputeForUpdate(ctx context.Context, id string) (*Dispute, error) {
+	return m.GetDisputeForUpdateFunc(ctx, id)
+}
+
+func (m *MockTransactionContext) UpdateDispute(ctx context.Context, dispute *Dispute) error {
+	return m.UpdateDisputeFunc(ctx, dispute)
+}
+
+func (m *MockTransactionContext) CreatePayoutBatch(ctx context.Context, batch *PayoutBatch) error {
+	return m.CreatePayoutBatchFunc(ctx, batch)
+}
+
+func (m *MockTransactionContext) CreateOutboxEvent(ctx context.Context, eventType, aggregateID string, payload []byte) error {
+	return m.CreateOutboxEventFunc(ctx, eventType, aggregateID, payload)
+}
+
+func (m *MockTransactionContext) Commit() error {
+	return m.CommitFunc()
+}
+
+func (m *MockTransactionContext) Rollback() error {
+	return m.RollbackFunc()
+}
@@ -6,17 +6,82 @@ import (
 
 // PaymentIntent represents a payment transaction intent.
 type PaymentIntent struct {
-	ID                   string    `json:"id"`
-	ZoneID               string    `json:"zone_id"`
-	Mode                 string    `json:"mode"`
-	Amount               int64     `json:"amount"` // In cents
-	Currency             string    `json:"currency"`
-	Status               string    `json:"status"` // requires_payment_method, succeeded, failed
-	Description          string    `json:"description,omitempty"`
-	UserID               string    `json:"user_id"`
-	ApplicationFeeAmount int64     `json:"application_fee_amount,omitempty"`
-	OnBehalfOf           string    `json:"on_behalf_of,omitempty"`
-	CreatedAt            time.Time `json:"created_at"`
+	ID                     string            `json:"id"`
+	ZoneID                 string            `json:"zone_id"`
+	Mode                   string            `json:"mode"`
+	Amount                 int64             `json:"amount"`          // In cents
+	AmountCaptured         int64             `json:"amount_captured"` // Amount actually charged, in cents
+	AmountRefunded         int64             `json:"amount_refunded"` // Sum of all refunds, in cents
+	Currency               string            `json:"currency"`
+	Status                 string            `json:"status"`                   // requires_payment_method, requires_capture, succeeded, failed, canceled, partially_refunded, refunded
+	CaptureMethod          string            `json:"capture_method,omitempty"` // automatic, or manual to authorize on confirm and capture later
+	Description            string            `json:"description,omitempty"`
+	StatementDescriptor    string            `json:"statement_descriptor,omitempty"` // Shown on the customer's card statement
+	Metadata               map[string]string `json:"metadata,omitempty"`             // Merchant's key/value pairs
+	UserID                 string            `json:"user_id"`
+	ApplicationFeeAmount   int64             `json:"application_fee_amount,omitempty"`
+	OnBehalfOf             string            `json:"on_behalf_of,omitempty"`
+	AuthorizationID        string            `json:"authorization_id,omitempty"`         // Bank hold of a manually captured intent
+	AuthorizationExpiresAt *time.Time        `json:"authorization_expires_at,omitempty"` // When an uncaptured hold is released
+	PaymentMethodType      string            `json:"payment_method_type,omitempty"`      // Selects the bank adapter the payment went through
+	ProviderTransactionID  string            `json:"provider_transaction_id,omitempty"`  // Bank charge or capture that refunds are made against
+	CreatedAt              time.Time         `json:"created_at"`
+}
+
+// Capture methods of a payment intent
+const (
+	CaptureAutomatic = "automatic"
+	CaptureManual    = "manual"
+)
+
+// Refund returns part or all of a succeeded payment. An intent can be
+// refunded several times until its whole amount has been returned.
+type Refund struct {
+	ID               string    `json:"id"`
+	PaymentIntentID  string    `json:"payment_intent_id"`
+	Amount           int64     `json:"amount"` // In cents
+	Currency         string    `json:"currency"`
+	Status           string    `json:"status"` // pending, succeeded, failed
+	Reason           string    `json:"reason,omitempty"`
+	ProviderRefundID string    `json:"provider_refund_id,omitempty"` // The bank's refund, looked up while it is pending
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// PaymentIntentFilter narrows a list of payment intents. Intents are listed
+// newest first; StartingAfter is the ID of the last intent of the previous
+// page.
+type PaymentIntentFilter struct {
+	ZoneID        string
+	UserID        string
+	Status        string
+	CreatedGte    *time.Time
+	CreatedLte    *time.Time
+	StartingAfter string
+	Limit         int
+	Metadata      map[string]string // Intents whose metadata has all of these pairs
+}
+
+// PaymentIntentPage is one page of a payment intent list
+type PaymentIntentPage struct {
+	Data       []PaymentIntent `json:"data"`
+	HasMore    bool            `json:"has_more"`
+	NextCursor string          `json:"next_cursor,omitempty"` // Pass as starting_after for the next page
+}
+
+// MerchantSettings are a merchant's payment preferences
+type MerchantSettings struct {
+	UserID             string    `json:"user_id"`
+	SettlementCurrency string    `json:"settlement_currency,omitempty"` // Ledger entries are posted in this currency; empty posts in each payment's currency
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// OutboxEvent is an event waiting in the outbox to be published to Kafka
+type OutboxEvent struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	AggregateID string    `json:"aggregate_id"` // The payment intent, used as the Kafka key
+	Payload     []byte    `json:"payload"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 // IdempotencyRecord keys response.
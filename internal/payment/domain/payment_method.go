@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Payment method types, each charged through its own bank adapter
+const (
+	PaymentMethodCard         = "card"
+	PaymentMethodBankTransfer = "bank_transfer"
+	PaymentMethodWallet       = "wallet"
+)
+
+var ErrPaymentMethodNotFound = errors.New("payment method not found")
+
+// PaymentMethod is a tokenized card, bank account or wallet. Raw details are
+// exchanged for the adapter's token when the method is created; only the
+// token and what is needed to display the method are stored.
+type PaymentMethod struct {
+	ID           string               `json:"id"`
+	UserID       string               `json:"user_id"`
+	Type         string               `json:"type"`
+	Token        string               `json:"-"` // Charged by the bank adapter of the type
+	Card         *CardDetails         `json:"card,omitempty"`
+	BankTransfer *BankTransferDetails `json:"bank_transfer,omitempty"`
+	Wallet       *WalletDetails       `json:"wallet,omitempty"`
+	CreatedAt    time.Time            `json:"created_at"`
+}
+
+type CardDetails struct {
+	Brand    string `json:"brand"`
+	Last4    string `json:"last4"`
+	ExpMonth int    `json:"exp_month"`
+	ExpYear  int    `json:"exp_year"`
+}
+
+type BankTransferDetails struct {
+	BankName string `json:"bank_name,omitempty"`
+	Last4    string `json:"last4"`
+}
+
+type WalletDetails struct {
+	Provider string `json:"provider"` // e.g. apple_pay, google_pay, paypal
+}
+
+// NewCardDetails validates a card number and expiry and keeps the parts of
+// them that may be stored
+func NewCardDetails(number string, expMonth, expYear int, now time.Time) (*CardDetails, error) {
+	number = strings.NewReplacer(" ", "", "-", "").Replace(number)
+	if len(number) < 12 || len(number) > 19 || !luhnValid(number) {
+		return nil, errors.New("invalid card number")
+	}
+	if expMonth < 1 || expMonth > 12 {
+		return nil, errors.New("invalid expiry month")
+	}
+	if expYear < now.Year() || (expYear == now.Year() && expMonth < int(now.Month())) {
+		return nil, errors.New("card has expired")
+	}
+	return &CardDetails{
+		Brand:    cardBrand(number),
+		Last4:    number[len(number)-4:],
+		ExpMonth: expMonth,
+		ExpYear:  expYear,
+	}, nil
+}
+
+// CreatePaymentMethod saves a tokenized payment method
+func (s *PaymentService) CreatePaymentMethod(ctx context.Context, method *PaymentMethod) error {
+	switch method.Type {
+	case PaymentMethodCard, PaymentMethodBankTransfer, PaymentMethodWallet:
+	default:
+		return fmt.Errorf("unknown payment method type %s", method.Type)
+	}
+	if method.Token == "" {
+		return errors.New("payment method has no token")
+	}
+	return s.repo.CreatePaymentMethod(ctx, method)
+}
+
+// GetPaymentMethod returns a payment method of the user
+func (s *PaymentService) GetPaymentMethod(ctx context.Context, userID, id string) (*PaymentMethod, error) {
+	method, err := s.repo.GetPaymentMethod(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if method == nil || method.UserID != userID {
+		return nil, ErrPaymentMethodNotFound
+	}
+	return method, nil
+}
+
+func (s *PaymentService) ListPaymentMethods(ctx context.Context, userID string) ([]PaymentMethod, error) {
+	return s.repo.ListPaymentMethods(ctx, userID)
+}
+
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func cardBrand(number string) string {
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return "visa"
+	case number[0] == '5' && number[1] >= '1' && number[1] <= '5', strings.HasPrefix(number, "2"):
+		return "mastercard"
+	case strings.HasPrefix(number, "34"), strings.HasPrefix(number, "37"):
+		return "amex"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,201 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Payout batch statuses. A batch is pending until its funds are moved to
+// the merchant's payable account in the Ledger.
+const (
+	PayoutPending = "pending"
+	PayoutPosted  = "posted"
+)
+
+// KafkaPayoutBatchCreated announces a settlement batch
+const KafkaPayoutBatchCreated = "payout.batch_created"
+
+var (
+	ErrPayoutBatchNotFound = errors.New("payout batch not found")
+	// ErrPayoutConflict means another run batched some of the payments first
+	ErrPayoutConflict = errors.New("payments were batched concurrently")
+)
+
+// PayoutBatch settles the captured payments of one merchant account in one
+// currency. Its amount moves from the account's pending balance, where
+// payments are credited, to its payable balance, which is paid out.
+type PayoutBatch struct {
+	ID            string       `json:"id"`
+	UserID        string       `json:"user_id"`        // The merchant paid, or the connected account of split payments
+	LedgerAccount string       `json:"ledger_account"` // The pending account the payments were credited to
+	ZoneID        string       `json:"zone_id,omitempty"`
+	Mode          string       `json:"mode,omitempty"`
+	Currency      string       `json:"currency"`
+	Amount        int64        `json:"amount"`
+	PaymentCount  int          `json:"payment_count"`
+	Status        string       `json:"status"`
+	CutoffAt      time.Time    `json:"cutoff_at"` // Payments captured up to this time were batched
+	CreatedAt     time.Time    `json:"created_at"`
+	PostedAt      *time.Time   `json:"posted_at,omitempty"`
+	Items         []PayoutItem `json:"items,omitempty"`
+}
+
+// PayoutItem is a payment settled by a batch and the amount it contributed
+type PayoutItem struct {
+	PaymentIntentID string `json:"payment_intent_id"`
+	Amount          int64  `json:"amount"`
+}
+
+// PayoutBatchFilter selects payout batches to list
+type PayoutBatchFilter struct {
+	UserID      string
+	Status      string
+	Limit       int
+	OldestFirst bool
+	WithItems   bool
+}
+
+// PayableAccount is the ledger account a merchant is paid out from
+func PayableAccount(userID string) string {
+	return "payable_" + userID
+}
+
+// payoutPayee returns who a payment is paid out to, the ledger account it
+// was credited to, and the amount that account received. Split payments pay
+// the connected account the amount net of the platform's fee.
+func payoutPayee(intent *PaymentIntent) (userID, account string, amount int64) {
+	amount = intent.AmountCaptured - intent.AmountRefunded
+	if intent.ApplicationFeeAmount > 0 && intent.OnBehalfOf != "" {
+		return intent.OnBehalfOf, "acc_" + intent.OnBehalfOf, amount - intent.ApplicationFeeAmount
+	}
+	return intent.UserID, "user_" + intent.UserID, amount
+}
+
+// CreatePayoutBatches batches the payments captured before cutoff that have
+// not been paid out, one batch per merchant account, currency, zone and
+// mode. Payments refunded down to nothing are left out.
+func (s *PaymentService) CreatePayoutBatches(ctx context.Context, cutoff time.Time, limit int) ([]*PayoutBatch, error) {
+	intents, err := s.repo.ListUnbatchedPayments(ctx, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	type batchKey struct{ account, currency, zone, mode string }
+	batches := map[batchKey]*PayoutBatch{}
+	var keys []batchKey
+	for i := range intents {
+		userID, account, amount := payoutPayee(&intents[i])
+		if amount <= 0 {
+			continue
+		}
+		key := batchKey{account, intents[i].Currency, intents[i].ZoneID, intents[i].Mode}
+		batch, ok := batches[key]
+		if !ok {
+			batch = &PayoutBatch{
+				UserID:        userID,
+				LedgerAccount: account,
+				ZoneID:        intents[i].ZoneID,
+				Mode:          intents[i].Mode,
+				Currency:      intents[i].Currency,
+				Status:        PayoutPending,
+				CutoffAt:      cutoff,
+			}
+			batches[key] = batch
+			keys = append(keys, key)
+		}
+		batch.Amount += amount
+		batch.PaymentCount++
+		batch.Items = append(batch.Items, PayoutItem{PaymentIntentID: intents[i].ID, Amount: amount})
+	}
+
+	var created []*PayoutBatch
+	for _, key := range keys {
+		batch := batches[key]
+		if err := s.createPayoutBatch(ctx, batch); err != nil {
+			if errors.Is(err, ErrPayoutConflict) {
+				continue // The other run settles these payments
+			}
+			return created, err
+		}
+		created = append(created, batch)
+	}
+	return created, nil
+}
+
+func (s *PaymentService) createPayoutBatch(ctx context.Context, batch *PayoutBatch) error {
+	batch.CreatedAt = time.Now().UTC()
+
+	txCtx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = txCtx.Rollback() }()
+
+	if err := txCtx.CreatePayoutBatch(ctx, batch); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":        "evt_payout_" + batch.ID,
+		"type":      KafkaPayoutBatchCreated,
+		"timestamp": batch.CreatedAt,
+		"zone_id":   batch.ZoneID,
+		"mode":      batch.Mode,
+		"data": map[string]interface{}{
+			"payout_batch_id": batch.ID,
+			"user_id":         batch.UserID,
+			"amount":          batch.Amount,
+			"currency":        batch.Currency,
+			"payment_count":   batch.PaymentCount,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := txCtx.CreateOutboxEvent(ctx, KafkaPayoutBatchCreated, batch.ID, payload); err != nil {
+		return err
+	}
+	return txCtx.Commit()
+}
+
+// ListPendingPayoutBatches returns batches whose ledger transfer has not
+// been recorded yet, oldest first
+func (s *PaymentService) ListPendingPayoutBatches(ctx context.Context, limit int) ([]PayoutBatch, error) {
+	return s.repo.ListPayoutBatches(ctx, PayoutBatchFilter{Status: PayoutPending, Limit: limit, OldestFirst: true})
+}
+
+// MarkPayoutBatchPosted records that a batch's funds were moved in the Ledger
+func (s *PaymentService) MarkPayoutBatchPosted(ctx context.Context, batch *PayoutBatch) error {
+	now := time.Now().UTC()
+	if err := s.repo.MarkPayoutBatchPosted(ctx, batch.ID, now); err != nil {
+		return err
+	}
+	batch.Status = PayoutPosted
+	batch.PostedAt = &now
+	return nil
+}
+
+// ListPayoutBatches returns a merchant's batches with their payments, newest
+// first. The limit defaults to 20 and is capped at 100.
+func (s *PaymentService) ListPayoutBatches(ctx context.Context, userID string, limit int) ([]PayoutBatch, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return s.repo.ListPayoutBatches(ctx, PayoutBatchFilter{UserID: userID, Limit: limit, WithItems: true})
+}
+
+// GetPayoutBatch returns a batch of the merchant with its payments
+func (s *PaymentService) GetPayoutBatch(ctx context.Context, userID, id string) (*PayoutBatch, error) {
+	batch, err := s.repo.GetPayoutBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil || batch.UserID != userID {
+		return nil, ErrPayoutBatchNotFound
+	}
+	return batch, nil
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 type Repository interface {
@@ -10,5 +11,70 @@ type Repository interface {
 	UpdateStatus(ctx context.Context, id, status string) error
 	GetIdempotencyKey(ctx context.Context, userID, key string) (*IdempotencyRecord, error)
 	SaveIdempotencyKey(ctx context.Context, userID, key string, statusCode int, body string) error
-	ListPaymentIntents(ctx context.Context, zoneID string, limit int) ([]PaymentIntent, error)
+	ListPaymentIntents(ctx context.Context, filter PaymentIntentFilter) ([]PaymentIntent, error)
+	// ListRefunds returns an intent's refunds, oldest first
+	ListRefunds(ctx context.Context, intentID string) ([]Refund, error)
+	// SetProviderRefundID records the bank's refund of a refund
+	SetProviderRefundID(ctx context.Context, id, providerRefundID string) error
+	// ListPendingRefunds returns refunds the bank has not settled yet,
+	// oldest first
+	ListPendingRefunds(ctx context.Context, limit int) ([]Refund, error)
+	// AuthorizePaymentIntent records a bank hold on a manually captured
+	// intent, which then requires capture
+	AuthorizePaymentIntent(ctx context.Context, id, authorizationID string, expiresAt time.Time) error
+	// ListExpiredAuthorizations returns intents still requiring capture
+	// whose hold has expired
+	ListExpiredAuthorizations(ctx context.Context, now time.Time, limit int) ([]PaymentIntent, error)
+	CreatePaymentMethod(ctx context.Context, method *PaymentMethod) error
+	GetPaymentMethod(ctx context.Context, id string) (*PaymentMethod, error)
+	// ListPaymentMethods returns a user's payment methods, newest first
+	ListPaymentMethods(ctx context.Context, userID string) ([]PaymentMethod, error)
+	GetMerchantSettings(ctx context.Context, userID string) (*MerchantSettings, error)
+	// SaveMerchantSettings creates or replaces a merchant's settings
+	SaveMerchantSettings(ctx context.Context, settings *MerchantSettings) error
+	GetDispute(ctx context.Context, id string) (*Dispute, error)
+	ListDisputes(ctx context.Context, filter DisputeFilter) ([]Dispute, error)
+	// ListUnbatchedPayments returns payments captured up to cutoff that are
+	// in no payout batch, oldest first
+	ListUnbatchedPayments(ctx context.Context, cutoff time.Time, limit int) ([]PaymentIntent, error)
+	GetPayoutBatch(ctx context.Context, id string) (*PayoutBatch, error)
+	ListPayoutBatches(ctx context.Context, filter PayoutBatchFilter) ([]PayoutBatch, error)
+	MarkPayoutBatchPosted(ctx context.Context, id string, postedAt time.Time) error
+	// BeginTx starts a transaction for changes whose events must be
+	// published exactly when the changes are committed
+	BeginTx(ctx context.Context) (TransactionContext, error)
+	GetUnprocessedEvents(ctx context.Context, limit int) ([]OutboxEvent, error)
+	MarkEventProcessed(ctx context.Context, id string) error
+}
+
+// TransactionContext makes changes to payments along with the outbox events
+// announcing them, all committed or rolled back together
+type TransactionContext interface {
+	// GetPaymentIntentForUpdate reads an intent and locks it until the
+	// transaction ends
+	GetPaymentIntentForUpdate(ctx context.Context, id string) (*PaymentIntent, error)
+	UpdateStatus(ctx context.Context, id, status string) error
+	// CapturePaymentIntent marks an intent that requires capture as
+	// succeeded with the captured amount, or fails with ErrNotCapturable
+	CapturePaymentIntent(ctx context.Context, id string, amount int64) error
+	// SetProviderTransaction records the payment method type and bank
+	// transaction that collected an intent
+	SetProviderTransaction(ctx context.Context, id, methodType, transactionID string) error
+	CreateRefund(ctx context.Context, refund *Refund) error
+	UpdateAmountRefunded(ctx context.Context, id string, amountRefunded int64, status string) error
+	UpdateRefundStatus(ctx context.Context, id, status string) error
+	CreateDispute(ctx context.Context, dispute *Dispute) error
+	// GetDisputeForUpdate reads a dispute and locks it until the transaction
+	// ends, or returns nil if it does not exist
+	GetDisputeForUpdate(ctx context.Context, id string) (*Dispute, error)
+	// UpdateDispute saves a dispute's status and evidence
+	UpdateDispute(ctx context.Context, dispute *Dispute) error
+	// CreatePayoutBatch saves a batch and its items and assigns its payments
+	// to it, or fails with ErrPayoutConflict if any is already batched
+	CreatePayoutBatch(ctx context.Context, batch *PayoutBatch) error
+	// CreateOutboxEvent queues an event for Kafka, keyed by the intent it is
+	// about so the events of an intent stay in order
+	CreateOutboxEvent(ctx context.Context, eventType, aggregateID string, payload []byte) error
+	Commit() error
+	Rollback() error
 }
@@ -2,8 +2,24 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/currency"
+)
+
+var (
+	ErrInvalidRefundAmount  = errors.New("refund amount must be positive")
+	ErrRefundExceedsAmount  = errors.New("refund exceeds the amount left to refund")
+	ErrNotRefundable        = errors.New("only succeeded payments can be refunded")
+	ErrNotCapturable        = errors.New("payment intent does not require capture")
+	ErrAuthorizationExpired = errors.New("authorization has expired")
+	ErrCaptureExceedsAmount = errors.New("capture exceeds the authorized amount")
 )
 
+// MaxPaymentIntentPageSize caps the intents returned by one list call
+const MaxPaymentIntentPageSize = 100
+
 type PaymentService struct {
 	repo Repository
 }
@@ -32,9 +48,316 @@ func (s *PaymentService) SaveIdempotencyKey(ctx context.Context, userID, key str
 	return s.repo.SaveIdempotencyKey(ctx, userID, key, statusCode, body)
 }
 
-func (s *PaymentService) ListPaymentIntents(ctx context.Context, zoneID string, limit int) ([]PaymentIntent, error) {
-	if limit <= 0 {
-		limit = 50
+// ListPaymentIntents returns a page of intents matching the filter. The
+// limit defaults to 50 and is capped at MaxPaymentIntentPageSize.
+func (s *PaymentService) ListPaymentIntents(ctx context.Context, filter PaymentIntentFilter) (*PaymentIntentPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if filter.Limit > MaxPaymentIntentPageSize {
+		filter.Limit = MaxPaymentIntentPageSize
+	}
+	limit := filter.Limit
+
+	// One more than asked for tells whether there is another page
+	filter.Limit++
+	intents, err := s.repo.ListPaymentIntents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &PaymentIntentPage{Data: intents}
+	if len(intents) > limit {
+		page.Data = intents[:limit]
+		page.HasMore = true
+		page.NextCursor = page.Data[limit-1].ID
+	}
+	if page.Data == nil {
+		page.Data = []PaymentIntent{}
+	}
+	return page, nil
+}
+
+// MarkSucceeded records that an intent's full amount was collected by the
+// bank transaction of the payment method type's adapter, and queues its
+// payment.succeeded event in the same transaction
+func (s *PaymentService) MarkSucceeded(ctx context.Context, intent *PaymentIntent, methodType, transactionID string) error {
+	txCtx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = txCtx.Rollback() }()
+
+	if err := txCtx.UpdateStatus(ctx, intent.ID, "succeeded"); err != nil {
+		return err
+	}
+	if err := txCtx.SetProviderTransaction(ctx, intent.ID, methodType, transactionID); err != nil {
+		return err
+	}
+	succeeded := *intent
+	succeeded.Status = "succeeded"
+	succeeded.AmountCaptured = intent.Amount
+	succeeded.PaymentMethodType = methodType
+	succeeded.ProviderTransactionID = transactionID
+	if err := s.queuePaymentSucceeded(ctx, txCtx, &succeeded); err != nil {
+		return err
+	}
+	if err := txCtx.Commit(); err != nil {
+		return err
+	}
+	*intent = succeeded
+	return nil
+}
+
+// RefundPaymentIntent starts a refund of part of an intent's amount. An
+// amount of zero refunds whatever has not been refunded yet. The refund is
+// pending, its amount reserved, until the bank settles it with
+// CompleteRefund or rejects it with FailRefund.
+func (s *PaymentService) RefundPaymentIntent(ctx context.Context, intent *PaymentIntent, amount int64, reason string) (*Refund, *PaymentIntent, error) {
+	if amount < 0 {
+		return nil, nil, ErrInvalidRefundAmount
+	}
+
+	txCtx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = txCtx.Rollback() }()
+
+	// Lock the intent so concurrent refunds see each other's amounts
+	locked, err := txCtx.GetPaymentIntentForUpdate(ctx, intent.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if locked.Status != "succeeded" && locked.Status != "partially_refunded" {
+		return nil, nil, ErrNotRefundable
+	}
+	remaining := locked.AmountCaptured - locked.AmountRefunded
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount == 0 || amount > remaining {
+		return nil, nil, ErrRefundExceedsAmount
+	}
+
+	refund := &Refund{
+		PaymentIntentID: locked.ID,
+		Amount:          amount,
+		Currency:        locked.Currency,
+		Status:          "pending",
+		Reason:          reason,
+	}
+	if err := txCtx.CreateRefund(ctx, refund); err != nil {
+		return nil, nil, err
+	}
+
+	locked.AmountRefunded += amount
+	locked.Status = "partially_refunded"
+	if locked.AmountRefunded == locked.AmountCaptured {
+		locked.Status = "refunded"
+	}
+	if err := txCtx.UpdateAmountRefunded(ctx, locked.ID, locked.AmountRefunded, locked.Status); err != nil {
+		return nil, nil, err
+	}
+	if err := s.queueRefundEvent(ctx, txCtx, KafkaRefundInitiated, locked, refund); err != nil {
+		return nil, nil, err
+	}
+	if err := txCtx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return refund, locked, nil
+}
+
+// CompleteRefund marks a pending refund as succeeded and queues its
+// refund.completed event
+func (s *PaymentService) CompleteRefund(ctx context.Context, intent *PaymentIntent, refund *Refund) error {
+	txCtx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = txCtx.Rollback() }()
+
+	if err := txCtx.UpdateRefundStatus(ctx, refund.ID, "succeeded"); err != nil {
+		return err
+	}
+	completed := *refund
+	completed.Status = "succeeded"
+	if err := s.queueRefundEvent(ctx, txCtx, KafkaRefundCompleted, intent, &completed); err != nil {
+		return err
+	}
+	if err := txCtx.Commit(); err != nil {
+		return err
+	}
+	refund.Status = "succeeded"
+	return nil
+}
+
+// FailRefund marks a pending refund the bank rejected as failed and
+// releases its amount so it can be refunded again. It queues the
+// refund.failed event and returns the intent with the amount released.
+func (s *PaymentService) FailRefund(ctx context.Context, intent *PaymentIntent, refund *Refund) (*PaymentIntent, error) {
+	txCtx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = txCtx.Rollback() }()
+
+	locked, err := txCtx.GetPaymentIntentForUpdate(ctx, intent.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := txCtx.UpdateRefundStatus(ctx, refund.ID, "failed"); err != nil {
+		return nil, err
+	}
+
+	locked.AmountRefunded -= refund.Amount
+	if locked.AmountRefunded < 0 {
+		locked.AmountRefunded = 0
+	}
+	locked.Status = "partially_refunded"
+	if locked.AmountRefunded == 0 {
+		locked.Status = "succeeded"
+	}
+	if err := txCtx.UpdateAmountRefunded(ctx, locked.ID, locked.AmountRefunded, locked.Status); err != nil {
+		return nil, err
+	}
+	failed := *refund
+	failed.Status = "failed"
+	if err := s.queueRefundEvent(ctx, txCtx, KafkaRefundFailed, locked, &failed); err != nil {
+		return nil, err
+	}
+	if err := txCtx.Commit(); err != nil {
+		return nil, err
+	}
+	refund.Status = "failed"
+	return locked, nil
+}
+
+func (s *PaymentService) ListRefunds(ctx context.Context, intentID string) ([]Refund, error) {
+	return s.repo.ListRefunds(ctx, intentID)
+}
+
+// SetProviderRefundID records the bank's refund, which a pending refund is
+// looked up by until the bank settles it
+func (s *PaymentService) SetProviderRefundID(ctx context.Context, refund *Refund, providerRefundID string) error {
+	if err := s.repo.SetProviderRefundID(ctx, refund.ID, providerRefundID); err != nil {
+		return err
+	}
+	refund.ProviderRefundID = providerRefundID
+	return nil
+}
+
+func (s *PaymentService) ListPendingRefunds(ctx context.Context, limit int) ([]Refund, error) {
+	return s.repo.ListPendingRefunds(ctx, limit)
+}
+
+// AuthorizePaymentIntent records the bank hold placed when a manually
+// captured intent is confirmed
+func (s *PaymentService) AuthorizePaymentIntent(ctx context.Context, intent *PaymentIntent, authorizationID string, expiresAt time.Time) error {
+	if err := s.repo.AuthorizePaymentIntent(ctx, intent.ID, authorizationID, expiresAt); err != nil {
+		return err
+	}
+	intent.Status = "requires_capture"
+	intent.AuthorizationID = authorizationID
+	intent.AuthorizationExpiresAt = &expiresAt
+	return nil
+}
+
+// CheckCapture validates a capture of the intent before the bank is asked
+// for it and returns the amount to capture. An amount of zero captures the
+// whole authorized amount.
+func (s *PaymentService) CheckCapture(intent *PaymentIntent, amount int64, now time.Time) (int64, error) {
+	if intent.Status != "requires_capture" {
+		return 0, ErrNotCapturable
+	}
+	if intent.AuthorizationExpiresAt != nil && !now.Before(*intent.AuthorizationExpiresAt) {
+		return 0, ErrAuthorizationExpired
+	}
+	if amount == 0 {
+		amount = intent.Amount
+	}
+	if amount < 0 {
+		return 0, errors.New("capture amount must be positive")
+	}
+	if amount > intent.Amount {
+		return 0, ErrCaptureExceedsAmount
+	}
+	return amount, nil
+}
+
+// CapturePaymentIntent records a card capture made with the bank as its
+// transactionID and queues the payment.succeeded event. The rest of a
+// partially captured authorization is released.
+func (s *PaymentService) CapturePaymentIntent(ctx context.Context, intent *PaymentIntent, amount int64, transactionID string) error {
+	txCtx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = txCtx.Rollback() }()
+
+	if err := txCtx.CapturePaymentIntent(ctx, intent.ID, amount); err != nil {
+		return err
+	}
+	if err := txCtx.SetProviderTransaction(ctx, intent.ID, PaymentMethodCard, transactionID); err != nil {
+		return err
+	}
+	captured := *intent
+	captured.Status = "succeeded"
+	captured.AmountCaptured = amount
+	captured.PaymentMethodType = PaymentMethodCard
+	captured.ProviderTransactionID = transactionID
+	if err := s.queuePaymentSucceeded(ctx, txCtx, &captured); err != nil {
+		return err
+	}
+	if err := txCtx.Commit(); err != nil {
+		return err
+	}
+	*intent = captured
+	return nil
+}
+
+func (s *PaymentService) ListExpiredAuthorizations(ctx context.Context, now time.Time, limit int) ([]PaymentIntent, error) {
+	return s.repo.ListExpiredAuthorizations(ctx, now, limit)
+}
+
+// GetMerchantSettings returns a merchant's settings, or the defaults if the
+// merchant has saved none
+func (s *PaymentService) GetMerchantSettings(ctx context.Context, userID string) (*MerchantSettings, error) {
+	settings, err := s.repo.GetMerchantSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = &MerchantSettings{UserID: userID}
+	}
+	return settings, nil
+}
+
+// SaveMerchantSettings validates and saves a merchant's settings
+func (s *PaymentService) SaveMerchantSettings(ctx context.Context, settings *MerchantSettings) error {
+	if settings.SettlementCurrency != "" {
+		code, err := currency.Normalize(settings.SettlementCurrency)
+		if err != nil {
+			return err
+		}
+		settings.SettlementCurrency = code
+	}
+	settings.UpdatedAt = time.Now().UTC()
+	return s.repo.SaveMerchantSettings(ctx, settings)
+}
+
+func (s *PaymentService) queuePaymentSucceeded(ctx context.Context, txCtx TransactionContext, intent *PaymentIntent) error {
+	payload, err := paymentSucceededEvent(intent)
+	if err != nil {
+		return err
+	}
+	return txCtx.CreateOutboxEvent(ctx, KafkaPaymentSucceeded, intent.ID, payload)
+}
+
+func (s *PaymentService) queueRefundEvent(ctx context.Context, txCtx TransactionContext, eventType string, intent *PaymentIntent, refund *Refund) error {
+	payload, err := refundEvent(eventType, intent, refund)
+	if err != nil {
+		return err
 	}
-	return s.repo.ListPaymentIntents(ctx, zoneID, limit)
+	return txCtx.CreateOutboxEvent(ctx, eventType, intent.ID, payload)
 }
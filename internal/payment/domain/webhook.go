@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Events delivered to merchant webhook endpoints
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+	EventPaymentRefunded  = "payment.refunded"
+)
+
+// WebhookEvents are the event types endpoints can subscribe to
+var WebhookEvents = []string{EventPaymentSucceeded, EventPaymentFailed, EventPaymentRefunded}
+
+// WebhookEndpoint is a merchant URL that payment events are delivered to.
+// Deliveries are signed with the endpoint's secret.
+type WebhookEndpoint struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	ZoneID    string    `json:"zone_id,omitempty"` // Only events of this zone; empty for every zone
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"` // Only returned when the endpoint is created
+	Events    []string  `json:"events"`           // Empty subscribes to every event
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Subscribed reports whether the endpoint receives the event type
+func (e *WebhookEndpoint) Subscribed(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, t := range e.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus is the state of an event's delivery to an endpoint
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"   // Waiting for an attempt at NextAttemptAt
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded" // The endpoint answered with 2xx
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"    // Out of attempts
+)
+
+// WebhookDelivery is one event sent to one endpoint, with the outcome of its
+// latest attempt
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	EndpointID     string                `json:"endpoint_id"`
+	EventID        string                `json:"event_id"`
+	EventType      string                `json:"event_type"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	LastStatusCode int                   `json:"last_status_code,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// WebhookRepository persists webhook endpoints and their deliveries
+type WebhookRepository interface {
+	CreateWebhookEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error
+	GetWebhookEndpoint(ctx context.Context, id string) (*WebhookEndpoint, error)
+	// ListWebhookEndpoints returns a user's endpoints, newest first
+	ListWebhookEndpoints(ctx context.Context, userID string) ([]*WebhookEndpoint, error)
+	DeleteWebhookEndpoint(ctx context.Context, id string) error
+	CreateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	UpdateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	// ListWebhookDeliveries returns an endpoint's deliveries, newest first
+	ListWebhookDeliveries(ctx context.Context, endpointID string, limit int) ([]*WebhookDelivery, error)
+	// ListDueWebhookDeliveries returns pending deliveries whose next
+	// attempt is due
+	ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*WebhookDelivery, error)
+	// ClaimWebhookDelivery moves a pending delivery's next attempt from
+	// dueAt to lease. It reports false if another instance claimed the
+	// delivery first.
+	ClaimWebhookDelivery(ctx context.Context, id string, dueAt, lease time.Time) (bool, error)
+}
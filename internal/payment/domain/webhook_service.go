@@ -0,0 +1,338 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWebhookEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+)
+
+// webhookTolerance is how old a signed delivery may be when its endpoint
+// verifies it, against replays
+const webhookTolerance = 5 * time.Minute
+
+// WebhookConfig sets how deliveries are attempted
+type WebhookConfig struct {
+	MaxAttempts    int           // Attempts per delivery, including the first (default: 8)
+	InitialBackoff time.Duration // Wait before the first retry (default: 30s)
+	MaxBackoff     time.Duration // Longest wait between retries (default: 6h)
+	Timeout        time.Duration // Timeout of one attempt (default: 10s)
+}
+
+// WebhookService delivers payment events to the endpoints merchants
+// registered. Every delivery is attempted once in the background when the
+// event is published; failed ones are retried with exponential backoff by
+// RetryDue until they run out of attempts.
+type WebhookService struct {
+	repo     WebhookRepository
+	client   *http.Client
+	config   WebhookConfig
+	inflight sync.WaitGroup
+}
+
+func NewWebhookService(repo WebhookRepository, config WebhookConfig) *WebhookService {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 8
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = 30 * time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 6 * time.Hour
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &WebhookService{
+		repo:   repo,
+		client: &http.Client{Timeout: config.Timeout},
+		config: config,
+	}
+}
+
+// CreateEndpoint validates and saves an endpoint with a new signing secret
+func (s *WebhookService) CreateEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error {
+	u, err := url.Parse(endpoint.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	for _, t := range endpoint.Events {
+		if !isWebhookEvent(t) {
+			return fmt.Errorf("unknown event type %s", t)
+		}
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate secret: %w", err)
+	}
+	endpoint.Secret = "whsec_" + hex.EncodeToString(secret)
+	endpoint.Enabled = true
+	return s.repo.CreateWebhookEndpoint(ctx, endpoint)
+}
+
+// GetEndpoint returns an endpoint of the user
+func (s *WebhookService) GetEndpoint(ctx context.Context, userID, id string) (*WebhookEndpoint, error) {
+	endpoint, err := s.repo.GetWebhookEndpoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == nil || endpoint.UserID != userID {
+		return nil, ErrWebhookEndpointNotFound
+	}
+	return endpoint, nil
+}
+
+func (s *WebhookService) ListEndpoints(ctx context.Context, userID string) ([]*WebhookEndpoint, error) {
+	return s.repo.ListWebhookEndpoints(ctx, userID)
+}
+
+// DeleteEndpoint removes an endpoint of the user along with its deliveries
+func (s *WebhookService) DeleteEndpoint(ctx context.Context, userID, id string) error {
+	if _, err := s.GetEndpoint(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteWebhookEndpoint(ctx, id)
+}
+
+// ListDeliveries returns the delivery log of an endpoint of the user
+func (s *WebhookService) ListDeliveries(ctx context.Context, userID, endpointID string, limit int) ([]*WebhookDelivery, error) {
+	if _, err := s.GetEndpoint(ctx, userID, endpointID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	return s.repo.ListWebhookDeliveries(ctx, endpointID, limit)
+}
+
+// Publish delivers a payment event to the user's endpoints that subscribe
+// to it. Deliveries are recorded before Publish returns and attempted in
+// the background.
+func (s *WebhookService) Publish(ctx context.Context, eventType string, intent *PaymentIntent) error {
+	endpoints, err := s.repo.ListWebhookEndpoints(ctx, intent.UserID)
+	if err != nil {
+		return err
+	}
+
+	id := make([]byte, 12)
+	rand.Read(id)
+	now := time.Now().UTC()
+	event := map[string]interface{}{
+		"id":         "evt_" + hex.EncodeToString(id),
+		"type":       eventType,
+		"created_at": now,
+		"zone_id":    intent.ZoneID,
+		"mode":       intent.Mode,
+		"data":       intent,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	lease := s.lease()
+	for _, endpoint := range endpoints {
+		if !endpoint.Enabled || !endpoint.Subscribed(eventType) || (endpoint.ZoneID != "" && endpoint.ZoneID != intent.ZoneID) {
+			continue
+		}
+		delivery := &WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       event["id"].(string),
+			EventType:     eventType,
+			Payload:       payload,
+			Status:        WebhookDeliveryPending,
+			NextAttemptAt: &lease,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := s.repo.CreateWebhookDelivery(ctx, delivery); err != nil {
+			log.Printf("Failed to record delivery of %s to endpoint %s: %v", eventType, endpoint.ID, err)
+			continue
+		}
+		s.start(endpoint, delivery)
+	}
+	return nil
+}
+
+// RetryDue attempts the pending deliveries whose next attempt is due
+func (s *WebhookService) RetryDue(ctx context.Context) {
+	due, err := s.repo.ListDueWebhookDeliveries(ctx, time.Now(), 100)
+	if err != nil {
+		log.Printf("Failed to list due webhook deliveries: %v", err)
+		return
+	}
+	for _, delivery := range due {
+		endpoint, err := s.repo.GetWebhookEndpoint(ctx, delivery.EndpointID)
+		if err != nil || endpoint == nil {
+			log.Printf("Failed to load endpoint %s of delivery %s: %v", delivery.EndpointID, delivery.ID, err)
+			continue
+		}
+		lease := s.lease()
+		claimed, err := s.repo.ClaimWebhookDelivery(ctx, delivery.ID, *delivery.NextAttemptAt, lease)
+		if err != nil {
+			log.Printf("Failed to claim delivery %s: %v", delivery.ID, err)
+			continue
+		}
+		if !claimed {
+			continue // Another instance is attempting it
+		}
+		delivery.NextAttemptAt = &lease
+		s.start(endpoint, delivery)
+	}
+}
+
+// Start retries due deliveries every interval until the context is cancelled
+func (s *WebhookService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RetryDue(ctx)
+		}
+	}
+}
+
+// lease is when a delivery being attempted becomes due again, in case the
+// attempt never records its outcome
+func (s *WebhookService) lease() time.Time {
+	return time.Now().UTC().Add(2 * s.config.Timeout)
+}
+
+// Wait blocks until attempts started so far have finished
+func (s *WebhookService) Wait() {
+	s.inflight.Wait()
+}
+
+func (s *WebhookService) start(endpoint *WebhookEndpoint, delivery *WebhookDelivery) {
+	s.inflight.Add(1)
+	go func() {
+		defer s.inflight.Done()
+		s.attempt(context.Background(), endpoint, delivery)
+	}()
+}
+
+// attempt sends the delivery once and records the outcome
+func (s *WebhookService) attempt(ctx context.Context, endpoint *WebhookEndpoint, delivery *WebhookDelivery) {
+	delivery.Attempts++
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+
+	status, err := s.send(ctx, endpoint, delivery)
+	delivery.LastStatusCode = status
+	now := time.Now().UTC()
+	delivery.UpdatedAt = now
+	delivery.NextAttemptAt = nil
+	switch {
+	case err == nil:
+		delivery.Status = WebhookDeliverySucceeded
+	case delivery.Attempts >= s.config.MaxAttempts:
+		delivery.Status = WebhookDeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		next := now.Add(s.backoff(delivery.Attempts))
+		delivery.Status = WebhookDeliveryPending
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = &next
+	}
+
+	if err := s.repo.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		log.Printf("Failed to update delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// send posts the payload to the endpoint, signed over the time of the
+// attempt and the body as the notification service signs its webhooks
+func (s *WebhookService) send(ctx context.Context, endpoint *WebhookEndpoint, delivery *WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sapliy-Timestamp", timestamp)
+	req.Header.Set("X-Sapliy-Signature-V2", SignWebhookPayload(endpoint.Secret, timestamp, delivery.Payload))
+	req.Header.Set("X-Sapliy-Signature-Version", "2")
+	req.Header.Set("X-Sapliy-Event-ID", delivery.EventID)
+	req.Header.Set("X-Sapliy-Event-Type", delivery.EventType)
+	req.Header.Set("X-Sapliy-Delivery-Attempt", fmt.Sprint(delivery.Attempts))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait before the retry following the given number of
+// failed attempts
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	d := float64(s.config.InitialBackoff) * math.Pow(2, float64(attempts-1))
+	return time.Duration(math.Min(d, float64(s.config.MaxBackoff)))
+}
+
+// SignWebhookPayload returns the signature of a delivery attempted at the
+// given Unix timestamp: the hex HMAC-SHA256 of the timestamp, a dot and the
+// payload, sent in the X-Sapliy-Signature-V2 header as sha256=<hex>
+func SignWebhookPayload(secret, timestamp string, payload []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "."))
+	h.Write(payload)
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyWebhookPayload is how endpoints check a delivery: the
+// X-Sapliy-Signature-V2 header must be the signature of the raw body under
+// the X-Sapliy-Timestamp header, and the timestamp within 5 minutes of now.
+// A captured delivery therefore cannot be replayed later or re-stamped.
+func VerifyWebhookPayload(secret, timestamp, signature string, payload []byte, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > webhookTolerance || d < -webhookTolerance {
+		return ErrInvalidWebhookSignature
+	}
+	if !strings.HasPrefix(signature, "sha256=") || !hmac.Equal([]byte(signature), []byte(SignWebhookPayload(secret, timestamp, payload))) {
+		return ErrInvalidWebhookSignature
+	}
+	return nil
+}
+
+func isWebhookEvent(eventType string) bool {
+	for _, t := range WebhookEvents {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+)
+
+const disputeColumns = `id, payment_intent_id, user_id, amount, currency, reason, status, source, bank_reference,
+	evidence, evidence_due_by, created_at, updated_at`
+
+func (r *SQLRepository) GetDispute(ctx context.Context, id string) (*domain.Dispute, error) {
+	dispute, err := scanDispute(r.db.QueryRowContext(ctx,
+		"SELECT "+disputeColumns+" FROM disputes WHERE id = $1", id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get dispute: %w", err)
+	}
+	return dispute, nil
+}
+
+func (r *SQLRepository) ListDisputes(ctx context.Context, filter domain.DisputeFilter) ([]domain.Dispute, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = "+arg(filter.UserID))
+	}
+	if filter.PaymentIntentID != "" {
+		conditions = append(conditions, "payment_intent_id = "+arg(filter.PaymentIntentID))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+arg(filter.Status))
+	}
+
+	query := "SELECT " + disputeColumns + " FROM disputes"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var disputes []domain.Dispute
+	for rows.Next() {
+		dispute, err := scanDispute(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, *dispute)
+	}
+	return disputes, rows.Err()
+}
+
+func (c *sqlTxContext) CreateDispute(ctx context.Context, dispute *domain.Dispute) error {
+	evidence, err := json.Marshal(dispute.Evidence)
+	if err != nil {
+		return err
+	}
+	err = c.tx.QueryRowContext(ctx,
+		`INSERT INTO disputes (payment_intent_id, user_id, amount, currency, reason, status, source, bank_reference, evidence, evidence_due_by, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		dispute.PaymentIntentID, dispute.UserID, dispute.Amount, dispute.Currency, dispute.Reason, dispute.Status, dispute.Source,
+		dispute.BankReference, evidence, dispute.EvidenceDueBy, dispute.CreatedAt, dispute.UpdatedAt).
+		Scan(&dispute.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create dispute: %w", err)
+	}
+	return nil
+}
+
+func (c *sqlTxContext) GetDisputeForUpdate(ctx context.Context, id string) (*domain.Dispute, error) {
+	dispute, err := scanDispute(c.tx.QueryRowContext(ctx,
+		"SELECT "+disputeColumns+" FROM disputes WHERE id = $1 FOR UPDATE", id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock dispute: %w", err)
+	}
+	return dispute, nil
+}
+
+func (c *sqlTxContext) UpdateDispute(ctx context.Context, dispute *domain.Dispute) error {
+	evidence, err := json.Marshal(dispute.Evidence)
+	if err != nil {
+		return err
+	}
+	if _, err := c.tx.ExecContext(ctx, "UPDATE disputes SET status = $1, evidence = $2, updated_at = $3 WHERE id = $4",
+		dispute.Status, evidence, dispute.UpdatedAt, dispute.ID); err != nil {
+		return fmt.Errorf("failed to update dispute: %w", err)
+	}
+	return nil
+}
+
+func scanDispute(scan func(dest ...interface{}) error) (*domain.Dispute, error) {
+	var d domain.Dispute
+	var reason, bankReference sql.NullString
+	var evidenceDueBy sql.NullTime
+	var raw []byte
+	if err := scan(&d.ID, &d.PaymentIntentID, &d.UserID, &d.Amount, &d.Currency, &reason, &d.Status, &d.Source, &bankReference,
+		&raw, &evidenceDueBy, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &d.Evidence); err != nil {
+		return nil, fmt.Errorf("failed to decode dispute evidence: %w", err)
+	}
+	if d.Evidence == nil {
+		d.Evidence = []domain.DisputeEvidence{}
+	}
+	d.Reason = reason.String
+	d.BankReference = bankReference.String
+	if evidenceDueBy.Valid {
+		d.EvidenceDueBy = &evidenceDueBy.Time
+	}
+	return &d, nil
+}
@@ -0,0 +1,37 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+)
+
+func (r *SQLRepository) GetMerchantSettings(ctx context.Context, userID string) (*domain.MerchantSettings, error) {
+	var settings domain.MerchantSettings
+	var settlementCurrency sql.NullString
+	err := r.db.QueryRowContext(ctx,
+		"SELECT user_id, settlement_currency, updated_at FROM merchant_settings WHERE user_id = $1", userID).
+		Scan(&settings.UserID, &settlementCurrency, &settings.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get merchant settings: %w", err)
+	}
+	settings.SettlementCurrency = settlementCurrency.String
+	return &settings, nil
+}
+
+func (r *SQLRepository) SaveMerchantSettings(ctx context.Context, settings *domain.MerchantSettings) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO merchant_settings (user_id, settlement_currency, updated_at) VALUES ($1, NULLIF($2, ''), $3)
+		 ON CONFLICT (user_id) DO UPDATE SET settlement_currency = EXCLUDED.settlement_currency, updated_at = EXCLUDED.updated_at`,
+		settings.UserID, settings.SettlementCurrency, settings.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save merchant settings: %w", err)
+	}
+	return nil
+}
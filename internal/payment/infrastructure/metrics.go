@@ -16,4 +16,30 @@ var (
 		Help:    "Latency of payment operations.",
 		Buckets: prometheus.DefBuckets,
 	}, []string{"operation"})
+
+	OutboxLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "payments_outbox_lag_total",
+		Help: "Current number of unprocessed events in the outbox.",
+	})
+
+	OutboxOldestEventAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "payments_outbox_oldest_event_age_seconds",
+		Help: "Age of the oldest unprocessed event in the outbox.",
+	})
+
+	OutboxEventsPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "payments_outbox_events_published_total",
+		Help: "Total number of outbox events published to Kafka.",
+	}, []string{"type", "status"})
+
+	FraudChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "payments_fraud_checks_total",
+		Help: "Total number of pre-authorization fraud checks, by decision.",
+	}, []string{"decision", "fallback"})
+
+	FraudCheckLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "payments_fraud_check_latency_seconds",
+		Help:    "Latency of pre-authorization fraud checks.",
+		Buckets: []float64{.01, .025, .05, .1, .2, .3, .5, 1},
+	})
 )
@@ -0,0 +1,84 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+)
+
+const paymentMethodColumns = "id, user_id, type, token, details, created_at"
+
+// paymentMethodDetails is the displayable part of a payment method, stored
+// as JSON next to its token
+type paymentMethodDetails struct {
+	Card         *domain.CardDetails         `json:"card,omitempty"`
+	BankTransfer *domain.BankTransferDetails `json:"bank_transfer,omitempty"`
+	Wallet       *domain.WalletDetails       `json:"wallet,omitempty"`
+}
+
+func (r *SQLRepository) CreatePaymentMethod(ctx context.Context, method *domain.PaymentMethod) error {
+	details, err := json.Marshal(paymentMethodDetails{Card: method.Card, BankTransfer: method.BankTransfer, Wallet: method.Wallet})
+	if err != nil {
+		return err
+	}
+	err = r.db.QueryRowContext(ctx,
+		`INSERT INTO payment_methods (user_id, type, token, details)
+		 VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		method.UserID, method.Type, method.Token, details).
+		Scan(&method.ID, &method.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create payment method: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) GetPaymentMethod(ctx context.Context, id string) (*domain.PaymentMethod, error) {
+	method, err := scanPaymentMethod(r.db.QueryRowContext(ctx,
+		"SELECT "+paymentMethodColumns+" FROM payment_methods WHERE id = $1", id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get payment method: %w", err)
+	}
+	return method, nil
+}
+
+func (r *SQLRepository) ListPaymentMethods(ctx context.Context, userID string) ([]domain.PaymentMethod, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+paymentMethodColumns+" FROM payment_methods WHERE user_id = $1 ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var methods []domain.PaymentMethod
+	for rows.Next() {
+		method, err := scanPaymentMethod(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, *method)
+	}
+	return methods, rows.Err()
+}
+
+func scanPaymentMethod(scan func(dest ...interface{}) error) (*domain.PaymentMethod, error) {
+	var m domain.PaymentMethod
+	var raw []byte
+	if err := scan(&m.ID, &m.UserID, &m.Type, &m.Token, &raw, &m.CreatedAt); err != nil {
+		return nil, err
+	}
+	var details paymentMethodDetails
+	if err := json.Unmarshal(raw, &details); err != nil {
+		return nil, fmt.Errorf("failed to decode payment method details: %w", err)
+	}
+	m.Card = details.Card
+	m.BankTransfer = details.BankTransfer
+	m.Wallet = details.Wallet
+	return &m, nil
+}
@@ -0,0 +1,179 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+)
+
+const payoutBatchColumns = `id, user_id, ledger_account, zone_id, mode, currency, amount, payment_count, status,
+	cutoff_at, created_at, posted_at`
+
+func (r *SQLRepository) ListUnbatchedPayments(ctx context.Context, cutoff time.Time, limit int) ([]domain.PaymentIntent, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+paymentIntentColumns+` FROM payment_intents
+		 WHERE payout_batch_id IS NULL AND status IN ('succeeded', 'partially_refunded') AND created_at <= $1
+		 ORDER BY created_at LIMIT $2`,
+		cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var intents []domain.PaymentIntent
+	for rows.Next() {
+		intent, err := scanPaymentIntent(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		intents = append(intents, *intent)
+	}
+	return intents, rows.Err()
+}
+
+func (r *SQLRepository) GetPayoutBatch(ctx context.Context, id string) (*domain.PayoutBatch, error) {
+	batch, err := scanPayoutBatch(r.db.QueryRowContext(ctx,
+		"SELECT "+payoutBatchColumns+" FROM payout_batches WHERE id = $1", id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get payout batch: %w", err)
+	}
+	if batch.Items, err = r.listPayoutItems(ctx, batch.ID); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+func (r *SQLRepository) ListPayoutBatches(ctx context.Context, filter domain.PayoutBatchFilter) ([]domain.PayoutBatch, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = "+arg(filter.UserID))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+arg(filter.Status))
+	}
+
+	query := "SELECT " + payoutBatchColumns + " FROM payout_batches"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if filter.OldestFirst {
+		query += " ORDER BY created_at, id"
+	} else {
+		query += " ORDER BY created_at DESC, id DESC"
+	}
+	query += " LIMIT " + arg(filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []domain.PayoutBatch
+	for rows.Next() {
+		batch, err := scanPayoutBatch(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, *batch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if filter.WithItems {
+		for i := range batches {
+			if batches[i].Items, err = r.listPayoutItems(ctx, batches[i].ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return batches, nil
+}
+
+func (r *SQLRepository) MarkPayoutBatchPosted(ctx context.Context, id string, postedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE payout_batches SET status = $1, posted_at = $2 WHERE id = $3",
+		domain.PayoutPosted, postedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark payout batch posted: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) listPayoutItems(ctx context.Context, batchID string) ([]domain.PayoutItem, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT payment_intent_id, amount FROM payout_items WHERE payout_batch_id = $1 ORDER BY payment_intent_id", batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []domain.PayoutItem
+	for rows.Next() {
+		var item domain.PayoutItem
+		if err := rows.Scan(&item.PaymentIntentID, &item.Amount); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (c *sqlTxContext) CreatePayoutBatch(ctx context.Context, batch *domain.PayoutBatch) error {
+	err := c.tx.QueryRowContext(ctx,
+		`INSERT INTO payout_batches (user_id, ledger_account, zone_id, mode, currency, amount, payment_count, status, cutoff_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		batch.UserID, batch.LedgerAccount, batch.ZoneID, batch.Mode, batch.Currency, batch.Amount, batch.PaymentCount,
+		batch.Status, batch.CutoffAt, batch.CreatedAt).
+		Scan(&batch.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create payout batch: %w", err)
+	}
+
+	for _, item := range batch.Items {
+		res, err := c.tx.ExecContext(ctx,
+			"UPDATE payment_intents SET payout_batch_id = $1 WHERE id = $2 AND payout_batch_id IS NULL",
+			batch.ID, item.PaymentIntentID)
+		if err != nil {
+			return fmt.Errorf("failed to batch payment %s: %w", item.PaymentIntentID, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return domain.ErrPayoutConflict
+		}
+		if _, err := c.tx.ExecContext(ctx,
+			"INSERT INTO payout_items (payout_batch_id, payment_intent_id, amount) VALUES ($1, $2, $3)",
+			batch.ID, item.PaymentIntentID, item.Amount); err != nil {
+			return fmt.Errorf("failed to create payout item: %w", err)
+		}
+	}
+	return nil
+}
+
+func scanPayoutBatch(scan func(dest ...interface{}) error) (*domain.PayoutBatch, error) {
+	var b domain.PayoutBatch
+	var zoneID, mode sql.NullString
+	var postedAt sql.NullTime
+	if err := scan(&b.ID, &b.UserID, &b.LedgerAccount, &zoneID, &mode, &b.Currency, &b.Amount, &b.PaymentCount, &b.Status,
+		&b.CutoffAt, &b.CreatedAt, &postedAt); err != nil {
+		return nil, err
+	}
+	b.ZoneID = zoneID.String
+	b.Mode = mode.String
+	if postedAt.Valid {
+		b.PostedAt = &postedAt.Time
+	}
+	return &b, nil
+}
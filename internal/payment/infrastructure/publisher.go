@@ -0,0 +1,78 @@
+package infrastructure
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+)
+
+// EventProducer publishes an event to Kafka under a key
+type EventProducer interface {
+	Publish(ctx context.Context, key string, value []byte) error
+}
+
+// OutboxPublisher publishes the events queued in the payments outbox to
+// Kafka, keyed by payment intent so each intent's events stay in order
+type OutboxPublisher struct {
+	repo         domain.Repository
+	producer     EventProducer
+	pollInterval time.Duration
+}
+
+func NewOutboxPublisher(repo domain.Repository, producer EventProducer, interval time.Duration) *OutboxPublisher {
+	return &OutboxPublisher{
+		repo:         repo,
+		producer:     producer,
+		pollInterval: interval,
+	}
+}
+
+func (p *OutboxPublisher) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	log.Printf("Outbox Publisher started (polling every %v)", p.pollInterval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.ProcessOutbox(ctx)
+		}
+	}
+}
+
+// ProcessOutbox publishes a batch of unprocessed events. Events that fail
+// to publish are retried on the next poll.
+func (p *OutboxPublisher) ProcessOutbox(ctx context.Context) {
+	events, err := p.repo.GetUnprocessedEvents(ctx, 50)
+	if err != nil {
+		log.Printf("Failed to fetch outbox events: %v", err)
+		return
+	}
+
+	// Update lag metrics
+	OutboxLag.Set(float64(len(events)))
+	if len(events) > 0 {
+		OutboxOldestEventAge.Set(time.Since(events[0].CreatedAt).Seconds())
+	} else {
+		OutboxOldestEventAge.Set(0)
+	}
+
+	for _, e := range events {
+		if err := p.producer.Publish(ctx, e.AggregateID, e.Payload); err != nil {
+			OutboxEventsPublished.WithLabelValues(e.Type, "error").Inc()
+			log.Printf("Failed to publish outbox event %s (%s) to Kafka: %v", e.ID, e.Type, err)
+			// Later events of the batch may belong to the same intent
+			return
+		}
+		OutboxEventsPublished.WithLabelValues(e.Type, "success").Inc()
+
+		if err := p.repo.MarkEventProcessed(ctx, e.ID); err != nil {
+			log.Printf("Failed to mark event %s as processed: %v", e.ID, err)
+		}
+	}
+}
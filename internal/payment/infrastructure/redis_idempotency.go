@@ -0,0 +1,82 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+)
+
+// unlockScript deletes a lock only if it is still held by the same request
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// RedisIdempotencyStore keeps idempotent responses in Redis, where they
+// expire on their own, and locks keys of in-flight requests with SET NX.
+type RedisIdempotencyStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisIdempotencyStore(rdb *redis.Client) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{rdb: rdb}
+}
+
+func (s *RedisIdempotencyStore) Get(ctx context.Context, userID, key string) (*domain.IdempotencyRecord, error) {
+	data, err := s.rdb.Get(ctx, idempotencyRedisKey("response", userID, key)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+	var record domain.IdempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode idempotency record: %w", err)
+	}
+	return &record, nil
+}
+
+func (s *RedisIdempotencyStore) Save(ctx context.Context, record *domain.IdempotencyRecord, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, idempotencyRedisKey("response", record.UserID, record.Key), data, ttl).Err()
+}
+
+func (s *RedisIdempotencyStore) Lock(ctx context.Context, userID, key string, ttl time.Duration) (func(), error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	lockKey := idempotencyRedisKey("lock", userID, key)
+	value := hex.EncodeToString(token)
+
+	ok, err := s.rdb.SetNX(ctx, lockKey, value, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock idempotency key: %w", err)
+	}
+	if !ok {
+		return nil, domain.ErrIdempotencyKeyInFlight
+	}
+	return func() {
+		// The request's context may be done; the lock must go regardless
+		if err := unlockScript.Run(context.Background(), s.rdb, []string{lockKey}, value).Err(); err != nil {
+			log.Printf("Failed to unlock idempotency key: %v", err)
+		}
+	}, nil
+}
+
+func idempotencyRedisKey(kind, userID, key string) string {
+	return fmt.Sprintf("payments:idempotency:%s:%s:%s", kind, userID, key)
+}
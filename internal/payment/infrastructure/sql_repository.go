@@ -3,12 +3,19 @@ package infrastructure
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
 )
 
+const paymentIntentColumns = `id, amount, amount_refunded, amount_captured, currency, status, capture_method, description,
+	statement_descriptor, metadata, user_id, application_fee_amount, on_behalf_of, zone_id, mode, authorization_id, authorization_expires_at,
+	payment_method_type, provider_transaction_id, created_at`
+
 type SQLRepository struct {
 	db *sql.DB
 }
@@ -21,15 +28,22 @@ func (r *SQLRepository) CreatePaymentIntent(ctx context.Context, intent *domain.
 	if intent.Currency == "" {
 		intent.Currency = "USD"
 	}
-	var onBehalfOf sql.NullString
+	var onBehalfOf, statementDescriptor sql.NullString
 	if intent.OnBehalfOf != "" {
 		onBehalfOf = sql.NullString{String: intent.OnBehalfOf, Valid: true}
 	}
+	if intent.StatementDescriptor != "" {
+		statementDescriptor = sql.NullString{String: intent.StatementDescriptor, Valid: true}
+	}
+	metadata, err := encodeMetadata(intent.Metadata)
+	if err != nil {
+		return err
+	}
 
-	err := r.db.QueryRowContext(ctx,
-		`INSERT INTO payment_intents (amount, currency, status, description, user_id, application_fee_amount, on_behalf_of, zone_id, mode) 
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at`,
-		intent.Amount, intent.Currency, intent.Status, intent.Description, intent.UserID, intent.ApplicationFeeAmount, onBehalfOf, intent.ZoneID, intent.Mode).
+	err = r.db.QueryRowContext(ctx,
+		`INSERT INTO payment_intents (amount, currency, status, description, statement_descriptor, metadata, user_id, application_fee_amount, on_behalf_of, zone_id, mode, capture_method) 
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, created_at`,
+		intent.Amount, intent.Currency, intent.Status, intent.Description, statementDescriptor, metadata, intent.UserID, intent.ApplicationFeeAmount, onBehalfOf, intent.ZoneID, intent.Mode, intent.CaptureMethod).
 		Scan(&intent.ID, &intent.CreatedAt)
 
 	if err != nil {
@@ -39,29 +53,23 @@ func (r *SQLRepository) CreatePaymentIntent(ctx context.Context, intent *domain.
 }
 
 func (r *SQLRepository) GetPaymentIntent(ctx context.Context, id string) (*domain.PaymentIntent, error) {
-	var intent domain.PaymentIntent
-	var description, onBehalfOf, zoneID, mode sql.NullString
-	err := r.db.QueryRowContext(ctx,
-		"SELECT id, amount, currency, status, description, user_id, application_fee_amount, on_behalf_of, zone_id, mode, created_at FROM payment_intents WHERE id = $1",
-		id).Scan(&intent.ID, &intent.Amount, &intent.Currency, &intent.Status, &description, &intent.UserID, &intent.ApplicationFeeAmount, &onBehalfOf, &zoneID, &mode, &intent.CreatedAt)
-
+	intent, err := scanPaymentIntent(r.db.QueryRowContext(ctx,
+		"SELECT "+paymentIntentColumns+" FROM payment_intents WHERE id = $1", id).Scan)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil // Not found
 		}
 		return nil, fmt.Errorf("failed to get payment intent: %w", err)
 	}
-
-	intent.Description = description.String
-	intent.OnBehalfOf = onBehalfOf.String
-	intent.ZoneID = zoneID.String
-	intent.Mode = mode.String
-
-	return &intent, nil
+	return intent, nil
 }
 
+// UpdateStatus sets an intent's status. Intents that succeed without a
+// separate capture are captured in full.
 func (r *SQLRepository) UpdateStatus(ctx context.Context, id, status string) error {
-	_, err := r.db.ExecContext(ctx, "UPDATE payment_intents SET status = $1 WHERE id = $2", status, id)
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE payment_intents SET status = $1, amount_captured = CASE WHEN $1 = 'succeeded' THEN amount ELSE amount_captured END WHERE id = $2",
+		status, id)
 	if err != nil {
 		return fmt.Errorf("failed to update payment status: %w", err)
 	}
@@ -86,14 +94,47 @@ func (r *SQLRepository) SaveIdempotencyKey(ctx context.Context, userID, key stri
 	return err
 }
 
-func (r *SQLRepository) ListPaymentIntents(ctx context.Context, zoneID string, limit int) ([]domain.PaymentIntent, error) {
-	query := `SELECT id, amount, currency, status, description, user_id, application_fee_amount, on_behalf_of, zone_id, mode, created_at 
-			  FROM payment_intents 
-			  WHERE ($1 = '' OR zone_id = $1) 
-			  ORDER BY created_at DESC 
-			  LIMIT $2`
+func (r *SQLRepository) ListPaymentIntents(ctx context.Context, filter domain.PaymentIntentFilter) ([]domain.PaymentIntent, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.ZoneID != "" {
+		conditions = append(conditions, "zone_id = "+arg(filter.ZoneID))
+	}
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = "+arg(filter.UserID))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+arg(filter.Status))
+	}
+	if filter.CreatedGte != nil {
+		conditions = append(conditions, "created_at >= "+arg(*filter.CreatedGte))
+	}
+	if filter.CreatedLte != nil {
+		conditions = append(conditions, "created_at <= "+arg(*filter.CreatedLte))
+	}
+	if len(filter.Metadata) > 0 {
+		metadata, err := encodeMetadata(filter.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "metadata @> "+arg(metadata)+"::jsonb")
+	}
+	if filter.StartingAfter != "" {
+		// Keyset pagination on the list order
+		conditions = append(conditions, "(created_at, id) < (SELECT created_at, id FROM payment_intents WHERE id = "+arg(filter.StartingAfter)+")")
+	}
 
-	rows, err := r.db.QueryContext(ctx, query, zoneID, limit)
+	query := "SELECT " + paymentIntentColumns + " FROM payment_intents"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT " + arg(filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -101,16 +142,253 @@ func (r *SQLRepository) ListPaymentIntents(ctx context.Context, zoneID string, l
 
 	var intents []domain.PaymentIntent
 	for rows.Next() {
-		var intent domain.PaymentIntent
-		var description, onBehalfOf, zoneID, mode sql.NullString
-		if err := rows.Scan(&intent.ID, &intent.Amount, &intent.Currency, &intent.Status, &description, &intent.UserID, &intent.ApplicationFeeAmount, &onBehalfOf, &zoneID, &mode, &intent.CreatedAt); err != nil {
+		intent, err := scanPaymentIntent(rows.Scan)
+		if err != nil {
 			return nil, err
 		}
-		intent.Description = description.String
-		intent.OnBehalfOf = onBehalfOf.String
-		intent.ZoneID = zoneID.String
-		intent.Mode = mode.String
-		intents = append(intents, intent)
+		intents = append(intents, *intent)
 	}
 	return intents, nil
 }
+
+const refundColumns = "id, payment_intent_id, amount, currency, status, reason, provider_refund_id, created_at"
+
+func (r *SQLRepository) ListRefunds(ctx context.Context, intentID string) ([]domain.Refund, error) {
+	return r.queryRefunds(ctx,
+		"SELECT "+refundColumns+" FROM refunds WHERE payment_intent_id = $1 ORDER BY created_at",
+		intentID)
+}
+
+func (r *SQLRepository) SetProviderRefundID(ctx context.Context, id, providerRefundID string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE refunds SET provider_refund_id = $1 WHERE id = $2", providerRefundID, id)
+	if err != nil {
+		return fmt.Errorf("failed to record provider refund: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) ListPendingRefunds(ctx context.Context, limit int) ([]domain.Refund, error) {
+	return r.queryRefunds(ctx,
+		"SELECT "+refundColumns+` FROM refunds
+		 WHERE status = 'pending' AND provider_refund_id IS NOT NULL ORDER BY created_at LIMIT $1`,
+		limit)
+}
+
+func (r *SQLRepository) queryRefunds(ctx context.Context, query string, args ...interface{}) ([]domain.Refund, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []domain.Refund
+	for rows.Next() {
+		var refund domain.Refund
+		var reason, providerRefundID sql.NullString
+		if err := rows.Scan(&refund.ID, &refund.PaymentIntentID, &refund.Amount, &refund.Currency, &refund.Status, &reason, &providerRefundID, &refund.CreatedAt); err != nil {
+			return nil, err
+		}
+		refund.Reason = reason.String
+		refund.ProviderRefundID = providerRefundID.String
+		refunds = append(refunds, refund)
+	}
+	return refunds, rows.Err()
+}
+
+func (r *SQLRepository) AuthorizePaymentIntent(ctx context.Context, id, authorizationID string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE payment_intents SET status = 'requires_capture', authorization_id = $1, authorization_expires_at = $2 WHERE id = $3",
+		authorizationID, expiresAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to record authorization: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) ListExpiredAuthorizations(ctx context.Context, now time.Time, limit int) ([]domain.PaymentIntent, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+paymentIntentColumns+` FROM payment_intents
+		 WHERE status = 'requires_capture' AND authorization_expires_at <= $1 ORDER BY authorization_expires_at LIMIT $2`,
+		now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var intents []domain.PaymentIntent
+	for rows.Next() {
+		intent, err := scanPaymentIntent(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		intents = append(intents, *intent)
+	}
+	return intents, rows.Err()
+}
+
+func (r *SQLRepository) BeginTx(ctx context.Context) (domain.TransactionContext, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTxContext{tx: tx}, nil
+}
+
+func (r *SQLRepository) GetUnprocessedEvents(ctx context.Context, limit int) ([]domain.OutboxEvent, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, event_type, aggregate_id, payload, created_at FROM outbox WHERE processed_at IS NULL ORDER BY created_at ASC LIMIT $1`,
+		limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []domain.OutboxEvent
+	for rows.Next() {
+		var e domain.OutboxEvent
+		if err := rows.Scan(&e.ID, &e.Type, &e.AggregateID, &e.Payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (r *SQLRepository) MarkEventProcessed(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE outbox SET processed_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+type sqlTxContext struct {
+	tx *sql.Tx
+}
+
+func (c *sqlTxContext) GetPaymentIntentForUpdate(ctx context.Context, id string) (*domain.PaymentIntent, error) {
+	intent, err := scanPaymentIntent(c.tx.QueryRowContext(ctx,
+		"SELECT "+paymentIntentColumns+" FROM payment_intents WHERE id = $1 FOR UPDATE", id).Scan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock payment intent: %w", err)
+	}
+	return intent, nil
+}
+
+func (c *sqlTxContext) UpdateStatus(ctx context.Context, id, status string) error {
+	_, err := c.tx.ExecContext(ctx,
+		"UPDATE payment_intents SET status = $1, amount_captured = CASE WHEN $1 = 'succeeded' THEN amount ELSE amount_captured END WHERE id = $2",
+		status, id)
+	if err != nil {
+		return fmt.Errorf("failed to update payment status: %w", err)
+	}
+	return nil
+}
+
+func (c *sqlTxContext) CapturePaymentIntent(ctx context.Context, id string, amount int64) error {
+	res, err := c.tx.ExecContext(ctx,
+		"UPDATE payment_intents SET status = 'succeeded', amount_captured = $1 WHERE id = $2 AND status = 'requires_capture'",
+		amount, id)
+	if err != nil {
+		return fmt.Errorf("failed to capture payment intent: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrNotCapturable
+	}
+	return nil
+}
+
+func (c *sqlTxContext) SetProviderTransaction(ctx context.Context, id, methodType, transactionID string) error {
+	_, err := c.tx.ExecContext(ctx,
+		"UPDATE payment_intents SET payment_method_type = $1, provider_transaction_id = $2 WHERE id = $3",
+		methodType, transactionID, id)
+	if err != nil {
+		return fmt.Errorf("failed to record provider transaction: %w", err)
+	}
+	return nil
+}
+
+func (c *sqlTxContext) CreateRefund(ctx context.Context, refund *domain.Refund) error {
+	err := c.tx.QueryRowContext(ctx,
+		`INSERT INTO refunds (payment_intent_id, amount, currency, status, reason)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		refund.PaymentIntentID, refund.Amount, refund.Currency, refund.Status, refund.Reason).
+		Scan(&refund.ID, &refund.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refund: %w", err)
+	}
+	return nil
+}
+
+func (c *sqlTxContext) UpdateAmountRefunded(ctx context.Context, id string, amountRefunded int64, status string) error {
+	if _, err := c.tx.ExecContext(ctx, "UPDATE payment_intents SET amount_refunded = $1, status = $2 WHERE id = $3",
+		amountRefunded, status, id); err != nil {
+		return fmt.Errorf("failed to update refunded amount: %w", err)
+	}
+	return nil
+}
+
+func (c *sqlTxContext) UpdateRefundStatus(ctx context.Context, id, status string) error {
+	_, err := c.tx.ExecContext(ctx, "UPDATE refunds SET status = $1 WHERE id = $2", status, id)
+	if err != nil {
+		return fmt.Errorf("failed to update refund status: %w", err)
+	}
+	return nil
+}
+
+func (c *sqlTxContext) CreateOutboxEvent(ctx context.Context, eventType, aggregateID string, payload []byte) error {
+	_, err := c.tx.ExecContext(ctx,
+		`INSERT INTO outbox (event_type, aggregate_id, payload) VALUES ($1, $2, $3)`,
+		eventType, aggregateID, payload)
+	return err
+}
+
+func (c *sqlTxContext) Commit() error {
+	return c.tx.Commit()
+}
+
+func (c *sqlTxContext) Rollback() error {
+	return c.tx.Rollback()
+}
+
+func scanPaymentIntent(scan func(dest ...interface{}) error) (*domain.PaymentIntent, error) {
+	var intent domain.PaymentIntent
+	var description, statementDescriptor, onBehalfOf, zoneID, mode, captureMethod, authorizationID, methodType, transactionID sql.NullString
+	var metadata []byte
+	var authorizationExpiresAt sql.NullTime
+	err := scan(&intent.ID, &intent.Amount, &intent.AmountRefunded, &intent.AmountCaptured, &intent.Currency, &intent.Status, &captureMethod, &description,
+		&statementDescriptor, &metadata, &intent.UserID, &intent.ApplicationFeeAmount, &onBehalfOf, &zoneID, &mode, &authorizationID, &authorizationExpiresAt,
+		&methodType, &transactionID, &intent.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	intent.Description = description.String
+	intent.StatementDescriptor = statementDescriptor.String
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &intent.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata: %w", err)
+		}
+	}
+	intent.OnBehalfOf = onBehalfOf.String
+	intent.ZoneID = zoneID.String
+	intent.Mode = mode.String
+	intent.CaptureMethod = captureMethod.String
+	intent.AuthorizationID = authorizationID.String
+	if authorizationExpiresAt.Valid {
+		intent.AuthorizationExpiresAt = &authorizationExpiresAt.Time
+	}
+	intent.PaymentMethodType = methodType.String
+	intent.ProviderTransactionID = transactionID.String
+	return &intent, nil
+}
+
+// encodeMetadata returns metadata as the JSON object stored in the JSONB
+// column
+func encodeMetadata(metadata map[string]string) (string, error) {
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,150 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sapliy/fintech-ecosystem/internal/payment/domain"
+)
+
+const webhookEndpointColumns = "id, user_id, zone_id, url, secret, events, enabled, created_at"
+
+const webhookDeliveryColumns = `id, endpoint_id, event_id, event_type, payload, status, attempts,
+	last_status_code, last_error, next_attempt_at, created_at, updated_at`
+
+func (r *SQLRepository) CreateWebhookEndpoint(ctx context.Context, endpoint *domain.WebhookEndpoint) error {
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO webhook_endpoints (user_id, zone_id, url, secret, events, enabled)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		endpoint.UserID, endpoint.ZoneID, endpoint.URL, endpoint.Secret, pq.Array(endpoint.Events), endpoint.Enabled).
+		Scan(&endpoint.ID, &endpoint.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook endpoint: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) GetWebhookEndpoint(ctx context.Context, id string) (*domain.WebhookEndpoint, error) {
+	endpoint, err := scanWebhookEndpoint(r.db.QueryRowContext(ctx,
+		"SELECT "+webhookEndpointColumns+" FROM webhook_endpoints WHERE id = $1", id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+	return endpoint, nil
+}
+
+func (r *SQLRepository) ListWebhookEndpoints(ctx context.Context, userID string) ([]*domain.WebhookEndpoint, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+webhookEndpointColumns+" FROM webhook_endpoints WHERE user_id = $1 ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []*domain.WebhookEndpoint
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+func (r *SQLRepository) DeleteWebhookEndpoint(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM webhook_endpoints WHERE id = $1", id)
+	return err
+}
+
+func (r *SQLRepository) CreateWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		d.EndpointID, d.EventID, d.EventType, []byte(d.Payload), d.Status, d.Attempts, d.NextAttemptAt, d.CreatedAt, d.UpdatedAt).
+		Scan(&d.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRepository) UpdateWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = $2, attempts = $3, last_status_code = $4, last_error = $5,
+		 next_attempt_at = $6, updated_at = $7 WHERE id = $1`,
+		d.ID, d.Status, d.Attempts, d.LastStatusCode, d.LastError, d.NextAttemptAt, d.UpdatedAt)
+	return err
+}
+
+func (r *SQLRepository) ListWebhookDeliveries(ctx context.Context, endpointID string, limit int) ([]*domain.WebhookDelivery, error) {
+	return r.queryWebhookDeliveries(ctx,
+		"SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE endpoint_id = $1 ORDER BY created_at DESC LIMIT $2",
+		endpointID, limit)
+}
+
+func (r *SQLRepository) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*domain.WebhookDelivery, error) {
+	return r.queryWebhookDeliveries(ctx,
+		"SELECT "+webhookDeliveryColumns+` FROM webhook_deliveries
+		 WHERE status = 'pending' AND next_attempt_at <= $1 ORDER BY next_attempt_at LIMIT $2`,
+		now, limit)
+}
+
+func (r *SQLRepository) ClaimWebhookDelivery(ctx context.Context, id string, dueAt, lease time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at = $3, updated_at = NOW()
+		 WHERE id = $1 AND status = 'pending' AND next_attempt_at = $2`,
+		id, dueAt, lease)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *SQLRepository) queryWebhookDeliveries(ctx context.Context, query string, args ...interface{}) ([]*domain.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*domain.WebhookDelivery
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		var payload []byte
+		var lastError sql.NullString
+		var nextAttemptAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+			&d.LastStatusCode, &lastError, &nextAttemptAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		d.Payload = payload
+		d.LastError = lastError.String
+		if nextAttemptAt.Valid {
+			d.NextAttemptAt = &nextAttemptAt.Time
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
+
+func scanWebhookEndpoint(scan func(dest ...interface{}) error) (*domain.WebhookEndpoint, error) {
+	var e domain.WebhookEndpoint
+	var zoneID sql.NullString
+	if err := scan(&e.ID, &e.UserID, &zoneID, &e.URL, &e.Secret, pq.Array(&e.Events), &e.Enabled, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	e.ZoneID = zoneID.String
+	return &e, nil
+}
@@ -0,0 +1,195 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	auditBatchSize     = 100
+	auditFlushInterval = time.Second
+)
+
+var ErrInvalidAuditRange = errors.New("from must be before to")
+
+// AuditFilter selects audit logs of an organization, newest first. Zero
+// times leave the range open.
+type AuditFilter struct {
+	OrgID  string
+	UserID string
+	Action Action
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+// AuditStore keeps policy decisions
+type AuditStore interface {
+	WriteAuditLogs(ctx context.Context, logs []PolicyAuditLog) error
+	ListAuditLogs(ctx context.Context, filter AuditFilter) ([]PolicyAuditLog, error)
+	// PruneAuditLogs removes the decisions made before the time, returning
+	// how many it removed
+	PruneAuditLogs(ctx context.Context, before time.Time) (int64, error)
+}
+
+// AuditedPolicyEngine records every decision, allow and deny, of the engine
+// it wraps. Decisions are queued and written in batches by Run so checks
+// never wait on the store; when the queue is full they are dropped and
+// counted rather than slowing requests down.
+type AuditedPolicyEngine struct {
+	engine  PolicyEngine
+	store   AuditStore
+	queue   chan PolicyAuditLog
+	dropped atomic.Int64
+}
+
+// NewAuditedPolicyEngine wraps the engine, queueing up to bufferSize
+// decisions for the store
+func NewAuditedPolicyEngine(engine PolicyEngine, store AuditStore, bufferSize int) *AuditedPolicyEngine {
+	return &AuditedPolicyEngine{
+		engine: engine,
+		store:  store,
+		queue:  make(chan PolicyAuditLog, bufferSize),
+	}
+}
+
+// Check evaluates the wrapped engine and queues its decision
+func (e *AuditedPolicyEngine) Check(ctx context.Context, pctx *PolicyContext) (*PolicyResult, error) {
+	result, err := e.engine.Check(ctx, pctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The action checked is the live one in live zones
+	resolved := pctx.resolved()
+	entry := PolicyAuditLog{
+		Timestamp: time.Now().UTC(),
+		UserID:    pctx.UserID,
+		OrgID:     pctx.OrgID,
+		ZoneID:    pctx.ZoneID,
+		Roles:     resolved.Roles,
+		Action:    resolved.Action,
+		Allowed:   result.Allowed,
+		Reason:    result.Reason,
+		Rules:     result.Rules,
+	}
+	if len(pctx.Resource) > 0 {
+		if resource, err := json.Marshal(pctx.Resource); err == nil {
+			entry.Resource = string(resource)
+		}
+	}
+	select {
+	case e.queue <- entry:
+	default:
+		e.dropped.Add(1)
+	}
+	return result, nil
+}
+
+// Run writes the queued decisions until the context is cancelled, then
+// writes those still queued
+func (e *AuditedPolicyEngine) Run(ctx context.Context) {
+	ticker := time.NewTicker(auditFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]PolicyAuditLog, 0, auditBatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+		drain:
+			for {
+				select {
+				case entry := <-e.queue:
+					batch = append(batch, entry)
+				default:
+					break drain
+				}
+			}
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			e.flush(flushCtx, batch)
+			cancel()
+			return
+		case entry := <-e.queue:
+			batch = append(batch, entry)
+			if len(batch) >= auditBatchSize {
+				e.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			e.flush(ctx, batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (e *AuditedPolicyEngine) flush(ctx context.Context, batch []PolicyAuditLog) {
+	if dropped := e.dropped.Swap(0); dropped > 0 {
+		log.Printf("Policy audit queue full, dropped %d decisions", dropped)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	if err := e.store.WriteAuditLogs(ctx, batch); err != nil {
+		log.Printf("Failed to write %d policy decisions to the audit log: %v", len(batch), err)
+	}
+}
+
+// ListAuditLogs returns the decisions matching the filter, at most 100
+// unless asked for up to 1000
+func ListAuditLogs(ctx context.Context, store AuditStore, filter AuditFilter) ([]PolicyAuditLog, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, ErrInvalidAuditRange
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	if filter.Limit > 1000 {
+		filter.Limit = 1000
+	}
+	return store.ListAuditLogs(ctx, filter)
+}
+
+// AuditPruner removes decisions older than the retention period
+type AuditPruner struct {
+	store     AuditStore
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewAuditPruner creates a pruner keeping decisions for the retention
+// period
+func NewAuditPruner(store AuditStore, retention, interval time.Duration) *AuditPruner {
+	return &AuditPruner{store: store, retention: retention, interval: interval}
+}
+
+// Start runs the prune loop until the context is cancelled
+func (p *AuditPruner) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.PruneOnce(ctx)
+		}
+	}
+}
+
+// PruneOnce removes the decisions made before now minus the retention
+// period
+func (p *AuditPruner) PruneOnce(ctx context.Context) {
+	pruned, err := p.store.PruneAuditLogs(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		log.Printf("Policy audit log pruning failed: %v", err)
+		return
+	}
+	if pruned > 0 {
+		log.Printf("Pruned %d policy audit logs", pruned)
+	}
+}
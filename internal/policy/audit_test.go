@@ -0,0 +1,119 @@
+package policy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryAuditStore keeps audit logs in memory for tests
+type memoryAuditStore struct {
+	mu   sync.Mutex
+	logs []PolicyAuditLog
+}
+
+func (s *memoryAuditStore) WriteAuditLogs(ctx context.Context, logs []PolicyAuditLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = append(s.logs, logs...)
+	return nil
+}
+
+func (s *memoryAuditStore) ListAuditLogs(ctx context.Context, filter AuditFilter) ([]PolicyAuditLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var logs []PolicyAuditLog
+	for _, l := range s.logs {
+		if l.OrgID == filter.OrgID && (filter.UserID == "" || l.UserID == filter.UserID) {
+			logs = append(logs, l)
+		}
+	}
+	return logs, nil
+}
+
+func (s *memoryAuditStore) PruneAuditLogs(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var kept []PolicyAuditLog
+	for _, l := range s.logs {
+		if !l.Timestamp.Before(before) {
+			kept = append(kept, l)
+		}
+	}
+	pruned := int64(len(s.logs) - len(kept))
+	s.logs = kept
+	return pruned, nil
+}
+
+func TestAuditedPolicyEngine_RecordsDecisions(t *testing.T) {
+	store := &memoryAuditStore{}
+	engine := NewAuditedPolicyEngine(NewHardcodedPolicyEngine(), store, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		engine.Run(ctx)
+		close(done)
+	}()
+
+	allowed, err := engine.Check(ctx, &PolicyContext{UserID: "u1", OrgID: "org-1", Roles: []Role{RoleFinance}, Action: ActionRefundCreate,
+		Resource: map[string]interface{}{"amount": 1000}})
+	if err != nil || !allowed.Allowed {
+		t.Fatalf("expected the refund to be allowed, got %+v, %v", allowed, err)
+	}
+	denied, err := engine.Check(ctx, &PolicyContext{UserID: "u2", OrgID: "org-1", Roles: []Role{RoleViewer}, Action: ActionFlowDeploy})
+	if err != nil || denied.Allowed {
+		t.Fatalf("expected the deploy to be denied, got %+v, %v", denied, err)
+	}
+
+	// Stopping writes the decisions still queued
+	cancel()
+	<-done
+
+	logs, _ := store.ListAuditLogs(context.Background(), AuditFilter{OrgID: "org-1"})
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 decisions, got %d", len(logs))
+	}
+	if !logs[0].Allowed || logs[0].Resource != `{"amount":1000}` || logs[0].Action != ActionRefundCreate {
+		t.Errorf("unexpected allow decision: %+v", logs[0])
+	}
+	if logs[1].Allowed || logs[1].UserID != "u2" || logs[1].Reason == "" {
+		t.Errorf("unexpected deny decision: %+v", logs[1])
+	}
+}
+
+func TestAuditedPolicyEngine_DropsWhenFull(t *testing.T) {
+	engine := NewAuditedPolicyEngine(NewHardcodedPolicyEngine(), &memoryAuditStore{}, 1)
+
+	for i := 0; i < 3; i++ {
+		if _, err := engine.Check(context.Background(), &PolicyContext{Roles: []Role{RoleAdmin}, Action: ActionZoneCreate}); err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+	}
+	if dropped := engine.dropped.Load(); dropped != 2 {
+		t.Errorf("expected 2 dropped decisions, got %d", dropped)
+	}
+}
+
+func TestAuditPruner_PruneOnce(t *testing.T) {
+	now := time.Now()
+	store := &memoryAuditStore{logs: []PolicyAuditLog{
+		{OrgID: "org-1", Timestamp: now.Add(-48 * time.Hour)},
+		{OrgID: "org-1", Timestamp: now.Add(-time.Hour)},
+	}}
+
+	NewAuditPruner(store, 24*time.Hour, time.Hour).PruneOnce(context.Background())
+
+	if len(store.logs) != 1 || store.logs[0].Timestamp != now.Add(-time.Hour) {
+		t.Errorf("expected only the recent decision to be kept, got %+v", store.logs)
+	}
+}
+
+func TestListAuditLogs_InvalidRange(t *testing.T) {
+	now := time.Now()
+	_, err := ListAuditLogs(context.Background(), &memoryAuditStore{}, AuditFilter{OrgID: "org-1", From: now, To: now.Add(-time.Hour)})
+	if err != ErrInvalidAuditRange {
+		t.Errorf("expected ErrInvalidAuditRange, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DatabasePolicyEngine implements Phase 2 policies stored in the database,
+// with conditions on the request's resource and attributes. It holds the
+// enabled policies and the zone roles in memory and reloads them when they
+// change.
+//
+// A deny policy whose conditions hold denies the request. Otherwise allow
+// policies covering the request decide it: it is allowed when the
+// conditions of one of them hold and denied when none do. Requests no
+// policy covers are left to the fallback engine.
+//
+// Roles granted in the request's zone are added to the caller's, and API
+// keys acting in a zone of the other environment are denied before any
+// policy is evaluated.
+type DatabasePolicyEngine struct {
+	store    PolicyStore
+	fallback PolicyEngine
+
+	mu        sync.RWMutex
+	policies  []Policy
+	zoneRoles map[zoneUser][]Role
+	version   string
+}
+
+// zoneUser keys the roles granted to a user in a zone
+type zoneUser struct {
+	zoneID string
+	userID string
+}
+
+// NewDatabasePolicyEngine creates an engine over the store, deferring to
+// the fallback, the hardcoded engine if nil, for requests no policy covers
+func NewDatabasePolicyEngine(store PolicyStore, fallback PolicyEngine) *DatabasePolicyEngine {
+	if fallback == nil {
+		fallback = NewHardcodedPolicyEngine()
+	}
+	return &DatabasePolicyEngine{store: store, fallback: fallback}
+}
+
+// Check evaluates the stored policies, then the fallback engine
+func (e *DatabasePolicyEngine) Check(ctx context.Context, pctx *PolicyContext) (*PolicyResult, error) {
+	e.mu.RLock()
+	policies := e.policies
+	granted := e.zoneRoles[zoneUser{zoneID: pctx.ZoneID, userID: pctx.UserID}]
+	e.mu.RUnlock()
+
+	if len(granted) > 0 {
+		withGrants := *pctx
+		withGrants.ZoneRoles = map[string][]Role{pctx.ZoneID: append(slices.Clone(pctx.ZoneRoles[pctx.ZoneID]), granted...)}
+		pctx = &withGrants
+	}
+	pctx = pctx.resolved()
+	if result := checkKeyEnvironment(pctx); result != nil {
+		return result, nil
+	}
+
+	var allowedBy, governedBy *Policy
+	for i := range policies {
+		p := &policies[i]
+		if !p.appliesTo(pctx) {
+			continue
+		}
+		if p.Effect == EffectDeny {
+			if p.conditionsHold(pctx) {
+				return &PolicyResult{
+					Allowed: false,
+					Reason:  fmt.Sprintf("denied by policy: %s", p.Name),
+					Rules:   []string{"policy:" + p.ID},
+				}, nil
+			}
+			continue
+		}
+		if governedBy == nil {
+			governedBy = p
+		}
+		if allowedBy == nil && p.conditionsHold(pctx) {
+			allowedBy = p
+		}
+	}
+
+	switch {
+	case allowedBy != nil:
+		return &PolicyResult{
+			Allowed: true,
+			Reason:  fmt.Sprintf("allowed by policy: %s", allowedBy.Name),
+			Rules:   []string{"policy:" + allowedBy.ID},
+		}, nil
+	case governedBy != nil:
+		return &PolicyResult{
+			Allowed: false,
+			Reason:  fmt.Sprintf("conditions of policy %s not met", governedBy.Name),
+			Rules:   []string{"policy:" + governedBy.ID},
+		}, nil
+	}
+	return e.fallback.Check(ctx, pctx)
+}
+
+// Set replaces the policies held
+func (e *DatabasePolicyEngine) Set(policies []Policy, version string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policies = policies
+	e.version = version
+}
+
+// SetZoneRoles replaces the zone roles held
+func (e *DatabasePolicyEngine) SetZoneRoles(grants []ZoneRole) {
+	zoneRoles := make(map[zoneUser][]Role)
+	for _, g := range grants {
+		key := zoneUser{zoneID: g.ZoneID, userID: g.UserID}
+		zoneRoles[key] = append(zoneRoles[key], g.Role)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.zoneRoles = zoneRoles
+}
+
+// Refresh loads the enabled policies and the zone roles from the store
+func (e *DatabasePolicyEngine) Refresh(ctx context.Context) error {
+	// Reading the version first makes a change racing the load show up as
+	// a new version on the next poll
+	version, err := e.store.Version(ctx)
+	if err != nil {
+		return err
+	}
+	policies, err := e.store.EnabledPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	grants, err := e.store.AllZoneRoles(ctx)
+	if err != nil {
+		return err
+	}
+	e.SetZoneRoles(grants)
+	e.Set(policies, version)
+	return nil
+}
+
+// Watch polls the store's version every interval until the context is
+// cancelled and reloads the policies when it changed, so changes made
+// through other instances are picked up
+func (e *DatabasePolicyEngine) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			version, err := e.store.Version(ctx)
+			if err != nil {
+				log.Printf("Failed to check policies for changes, keeping the current ones: %v", err)
+				continue
+			}
+			e.mu.RLock()
+			changed := version != e.version
+			e.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := e.Refresh(ctx); err != nil {
+				log.Printf("Failed to reload policies, keeping the current ones: %v", err)
+			}
+		}
+	}
+}
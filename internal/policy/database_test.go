@@ -0,0 +1,308 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// memoryStore keeps policies and zone roles in memory for tests
+type memoryStore struct {
+	policies  map[string]Policy
+	zoneRoles map[string]ZoneRole
+	changes   int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{policies: make(map[string]Policy), zoneRoles: make(map[string]ZoneRole)}
+}
+
+func (s *memoryStore) CreatePolicy(ctx context.Context, p *Policy) error {
+	s.changes++
+	p.ID = fmt.Sprintf("policy-%d", s.changes)
+	s.policies[p.ID] = *p
+	return nil
+}
+
+func (s *memoryStore) GetPolicy(ctx context.Context, id string) (*Policy, error) {
+	p, ok := s.policies[id]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (s *memoryStore) ListPolicies(ctx context.Context, orgID string) ([]Policy, error) {
+	var policies []Policy
+	for _, p := range s.policies {
+		if orgID == "" || p.OrgID == orgID {
+			policies = append(policies, p)
+		}
+	}
+	return policies, nil
+}
+
+func (s *memoryStore) UpdatePolicy(ctx context.Context, p *Policy) error {
+	if _, ok := s.policies[p.ID]; !ok {
+		return ErrPolicyNotFound
+	}
+	s.changes++
+	s.policies[p.ID] = *p
+	return nil
+}
+
+func (s *memoryStore) DeletePolicy(ctx context.Context, id string) error {
+	if _, ok := s.policies[id]; !ok {
+		return ErrPolicyNotFound
+	}
+	s.changes++
+	delete(s.policies, id)
+	return nil
+}
+
+func (s *memoryStore) EnabledPolicies(ctx context.Context) ([]Policy, error) {
+	var policies []Policy
+	for _, p := range s.policies {
+		if p.Enabled {
+			policies = append(policies, p)
+		}
+	}
+	return policies, nil
+}
+
+func (s *memoryStore) GrantZoneRole(ctx context.Context, g *ZoneRole) error {
+	for _, existing := range s.zoneRoles {
+		if existing.ZoneID == g.ZoneID && existing.UserID == g.UserID && existing.Role == g.Role {
+			return ErrZoneRoleExists
+		}
+	}
+	s.changes++
+	g.ID = fmt.Sprintf("zone-role-%d", s.changes)
+	s.zoneRoles[g.ID] = *g
+	return nil
+}
+
+func (s *memoryStore) GetZoneRole(ctx context.Context, id string) (*ZoneRole, error) {
+	g, ok := s.zoneRoles[id]
+	if !ok {
+		return nil, nil
+	}
+	return &g, nil
+}
+
+func (s *memoryStore) ListZoneRoles(ctx context.Context, zoneID string) ([]ZoneRole, error) {
+	var grants []ZoneRole
+	for _, g := range s.zoneRoles {
+		if g.ZoneID == zoneID {
+			grants = append(grants, g)
+		}
+	}
+	return grants, nil
+}
+
+func (s *memoryStore) RevokeZoneRole(ctx context.Context, id string) error {
+	if _, ok := s.zoneRoles[id]; !ok {
+		return ErrZoneRoleNotFound
+	}
+	s.changes++
+	delete(s.zoneRoles, id)
+	return nil
+}
+
+func (s *memoryStore) AllZoneRoles(ctx context.Context) ([]ZoneRole, error) {
+	var grants []ZoneRole
+	for _, g := range s.zoneRoles {
+		grants = append(grants, g)
+	}
+	return grants, nil
+}
+
+func (s *memoryStore) Version(ctx context.Context) (string, error) {
+	return fmt.Sprint(s.changes), nil
+}
+
+func TestDatabasePolicyEngine_Check(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	engine := NewDatabasePolicyEngine(store, NewHardcodedPolicyEngine())
+	service := NewPolicyService(store, engine)
+
+	// Finance refunds only amounts under $500 in zone X; conditions come
+	// from JSON like they do through the API
+	var conditions []Condition
+	if err := json.Unmarshal([]byte(`[{"attribute": "resource.amount", "operator": "lt", "value": 50000}]`), &conditions); err != nil {
+		t.Fatal(err)
+	}
+	refunds := &Policy{
+		OrgID:      "org-1",
+		ZoneID:     "zone-x",
+		Name:       "Small refunds",
+		Effect:     EffectAllow,
+		Roles:      []Role{RoleFinance},
+		Actions:    []Action{ActionRefundCreate},
+		Conditions: conditions,
+		Enabled:    true,
+	}
+	if err := service.CreatePolicy(ctx, refunds); err != nil {
+		t.Fatalf("CreatePolicy failed: %v", err)
+	}
+	blocked := &Policy{
+		OrgID:      "org-1",
+		Name:       "Blocked user",
+		Effect:     EffectDeny,
+		Actions:    []Action{"payment.*"},
+		Conditions: []Condition{{Attribute: "user_id", Operator: OpIn, Value: []interface{}{"user-blocked"}}},
+		Enabled:    true,
+	}
+	if err := service.CreatePolicy(ctx, blocked); err != nil {
+		t.Fatalf("CreatePolicy failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		pctx     PolicyContext
+		expected bool
+		rule     string
+	}{
+		{
+			name:     "Finance refund under the limit",
+			pctx:     PolicyContext{OrgID: "org-1", ZoneID: "zone-x", Roles: []Role{RoleFinance}, Action: ActionRefundCreate, Resource: map[string]interface{}{"amount": int64(49999)}},
+			expected: true,
+			rule:     "policy:" + refunds.ID,
+		},
+		{
+			name:     "Finance refund over the limit",
+			pctx:     PolicyContext{OrgID: "org-1", ZoneID: "zone-x", Roles: []Role{RoleFinance}, Action: ActionRefundCreate, Resource: map[string]interface{}{"amount": int64(50000)}},
+			expected: false,
+			rule:     "policy:" + refunds.ID,
+		},
+		{
+			name:     "Finance refund without an amount",
+			pctx:     PolicyContext{OrgID: "org-1", ZoneID: "zone-x", Roles: []Role{RoleFinance}, Action: ActionRefundCreate},
+			expected: false,
+			rule:     "policy:" + refunds.ID,
+		},
+		{
+			name:     "Finance refund in another zone falls back",
+			pctx:     PolicyContext{OrgID: "org-1", ZoneID: "zone-y", Roles: []Role{RoleFinance}, Action: ActionRefundCreate, Resource: map[string]interface{}{"amount": int64(90000)}},
+			expected: true,
+			rule:     "role:finance",
+		},
+		{
+			name:     "Admin refund is not covered",
+			pctx:     PolicyContext{OrgID: "org-1", ZoneID: "zone-x", Roles: []Role{RoleAdmin}, Action: ActionRefundCreate, Resource: map[string]interface{}{"amount": int64(90000)}},
+			expected: true,
+			rule:     "role:admin",
+		},
+		{
+			name:     "Deny policy wins over roles",
+			pctx:     PolicyContext{UserID: "user-blocked", OrgID: "org-1", Roles: []Role{RoleAdmin}, Action: ActionPaymentCreate},
+			expected: false,
+			rule:     "policy:" + blocked.ID,
+		},
+		{
+			name:     "Deny policy of another organization",
+			pctx:     PolicyContext{UserID: "user-blocked", OrgID: "org-2", Roles: []Role{RoleFinance}, Action: ActionPaymentCreate},
+			expected: true,
+			rule:     "role:finance",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := engine.Check(ctx, &tt.pctx)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if result.Allowed != tt.expected {
+				t.Errorf("expected allowed=%v, got %v (%s)", tt.expected, result.Allowed, result.Reason)
+			}
+			if len(result.Rules) == 0 || result.Rules[0] != tt.rule {
+				t.Errorf("expected rule %s, got %v", tt.rule, result.Rules)
+			}
+		})
+	}
+
+	// Disabling the policy takes effect right away
+	refunds.Enabled = false
+	if err := service.UpdatePolicy(ctx, refunds); err != nil {
+		t.Fatalf("UpdatePolicy failed: %v", err)
+	}
+	result, _ := engine.Check(ctx, &PolicyContext{OrgID: "org-1", ZoneID: "zone-x", Roles: []Role{RoleFinance}, Action: ActionRefundCreate, Resource: map[string]interface{}{"amount": int64(90000)}})
+	if !result.Allowed {
+		t.Errorf("expected the disabled policy to be ignored, got %s", result.Reason)
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		err    error
+	}{
+		{"Valid", Policy{Name: "p", Effect: EffectAllow, Actions: []Action{"*"}}, nil},
+		{"Missing name", Policy{Effect: EffectAllow, Actions: []Action{"*"}}, ErrInvalidPolicy},
+		{"Unknown effect", Policy{Name: "p", Effect: "maybe", Actions: []Action{"*"}}, ErrInvalidPolicy},
+		{"No actions", Policy{Name: "p", Effect: EffectDeny}, ErrInvalidPolicy},
+		{"Unknown attribute", Policy{Name: "p", Effect: EffectDeny, Actions: []Action{"*"},
+			Conditions: []Condition{{Attribute: "amount", Operator: OpLt, Value: 1.0}}}, ErrInvalidCondition},
+		{"Comparing text", Policy{Name: "p", Effect: EffectDeny, Actions: []Action{"*"},
+			Conditions: []Condition{{Attribute: "resource.amount", Operator: OpLt, Value: "500"}}}, ErrInvalidCondition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestDatabasePolicyEngine_ZoneRoles(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	engine := NewDatabasePolicyEngine(store, NewHardcodedPolicyEngine())
+	service := NewPolicyService(store, engine)
+
+	grant := &ZoneRole{OrgID: "org-1", ZoneID: "zone-live", UserID: "user-1", Role: RoleFinance}
+	if err := service.GrantZoneRole(ctx, grant); err != nil {
+		t.Fatalf("GrantZoneRole failed: %v", err)
+	}
+	if err := service.GrantZoneRole(ctx, &ZoneRole{OrgID: "org-1", ZoneID: "zone-live", UserID: "user-1", Role: RoleFinance}); err != ErrZoneRoleExists {
+		t.Errorf("expected ErrZoneRoleExists, got %v", err)
+	}
+	if err := service.GrantZoneRole(ctx, &ZoneRole{OrgID: "org-1", ZoneID: "zone-live", UserID: "user-1", Role: "owner"}); err != ErrInvalidZoneRole {
+		t.Errorf("expected ErrInvalidZoneRole, got %v", err)
+	}
+
+	deploy := func(userID, zoneID string) *PolicyResult {
+		t.Helper()
+		result, err := engine.Check(ctx, &PolicyContext{UserID: userID, OrgID: "org-1", ZoneID: zoneID, Environment: EnvironmentLive,
+			Roles: []Role{RoleDeveloper}, Action: ActionFlowDeploy})
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		return result
+	}
+
+	// The developer deploys live only in the zone they were granted finance in
+	if result := deploy("user-1", "zone-live"); !result.Allowed {
+		t.Errorf("expected the zone role to allow the live deploy, got %s", result.Reason)
+	}
+	if result := deploy("user-1", "zone-other"); result.Allowed {
+		t.Errorf("expected the live deploy to be denied in another zone")
+	}
+	if result := deploy("user-2", "zone-live"); result.Allowed {
+		t.Errorf("expected the live deploy to be denied for another user")
+	}
+
+	// Revoking takes effect right away
+	if err := service.RevokeZoneRole(ctx, grant.ID); err != nil {
+		t.Fatalf("RevokeZoneRole failed: %v", err)
+	}
+	if result := deploy("user-1", "zone-live"); result.Allowed {
+		t.Errorf("expected the revoked zone role to be ignored")
+	}
+}
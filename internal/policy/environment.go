@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Environments of zones and API keys
+const (
+	EnvironmentTest = "test"
+	EnvironmentLive = "live"
+)
+
+// liveActions are checked as their live counterparts in live zones, so
+// their permission in test zones does not carry over to live ones
+var liveActions = map[Action]Action{
+	ActionFlowDeploy: ActionFlowDeployLive,
+}
+
+// testActions are open to developers in test zones, where no real money
+// moves
+var testActions = []Action{
+	ActionFlowDeploy,
+	ActionPaymentCreate,
+	ActionRefundCreate,
+	ActionKeyCreate,
+	ActionKeyRevoke,
+	ActionSettingsUpdate,
+}
+
+// resolved returns the context engines evaluate: the roles granted in its
+// zone added to the caller's, and the action its live counterpart in a live
+// zone
+func (pctx *PolicyContext) resolved() *PolicyContext {
+	resolved := *pctx
+	if zoneRoles := pctx.ZoneRoles[pctx.ZoneID]; pctx.ZoneID != "" && len(zoneRoles) > 0 {
+		resolved.Roles = slices.Clone(pctx.Roles)
+		for _, role := range zoneRoles {
+			if !slices.Contains(resolved.Roles, role) {
+				resolved.Roles = append(resolved.Roles, role)
+			}
+		}
+	}
+	if live, ok := liveActions[pctx.Action]; ok && pctx.Environment == EnvironmentLive {
+		resolved.Action = live
+	}
+	return &resolved
+}
+
+// checkKeyEnvironment denies API keys acting in a zone of the other
+// environment: test keys never reach live zones nor live keys test ones.
+// It returns nil when the environments agree or either is unknown.
+func checkKeyEnvironment(pctx *PolicyContext) *PolicyResult {
+	if pctx.KeyEnvironment == "" || pctx.Environment == "" || pctx.KeyEnvironment == pctx.Environment {
+		return nil
+	}
+	return &PolicyResult{
+		Allowed: false,
+		Reason:  fmt.Sprintf("%s keys cannot act in %s zones", pctx.KeyEnvironment, pctx.Environment),
+		Rules:   []string{"environment:" + pctx.KeyEnvironment + "_key"},
+	}
+}
+
+// checkTestEnvironment allows developers the test actions in test zones.
+// It returns nil for anything else.
+func checkTestEnvironment(pctx *PolicyContext) *PolicyResult {
+	if pctx.Environment != EnvironmentTest || !slices.Contains(pctx.Roles, RoleDeveloper) ||
+		!slices.Contains(testActions, pctx.Action) {
+		return nil
+	}
+	return &PolicyResult{
+		Allowed: true,
+		Reason:  "allowed for developers in test zones",
+		Rules:   []string{"environment:test"},
+	}
+}
+
+// checkEnvironment makes the decisions the environment alone settles, for
+// the engines to apply before their own rules
+func checkEnvironment(pctx *PolicyContext) *PolicyResult {
+	if result := checkKeyEnvironment(pctx); result != nil {
+		return result
+	}
+	return checkTestEnvironment(pctx)
+}
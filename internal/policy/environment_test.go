@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnvironment_Check(t *testing.T) {
+	engines := map[string]PolicyEngine{
+		"hardcoded": NewHardcodedPolicyEngine(),
+		"database":  NewDatabasePolicyEngine(nil, nil),
+	}
+	if opa, err := NewOPAPolicyEngine("../../config/policies.rego"); err == nil {
+		engines["opa"] = opa
+	}
+
+	tests := []struct {
+		name     string
+		pctx     PolicyContext
+		expected bool
+		rule     string
+	}{
+		{
+			name:     "Developer deploys in a test zone",
+			pctx:     PolicyContext{Roles: []Role{RoleDeveloper}, Action: ActionFlowDeploy, Environment: EnvironmentTest},
+			expected: true,
+			rule:     "environment:test",
+		},
+		{
+			name:     "Developer refunds in a test zone",
+			pctx:     PolicyContext{Roles: []Role{RoleDeveloper}, Action: ActionRefundCreate, Environment: EnvironmentTest},
+			expected: true,
+			rule:     "environment:test",
+		},
+		{
+			name:     "Developer deploys in a live zone",
+			pctx:     PolicyContext{Roles: []Role{RoleDeveloper}, Action: ActionFlowDeploy, Environment: EnvironmentLive},
+			expected: false,
+		},
+		{
+			name:     "Developer refunds in a live zone",
+			pctx:     PolicyContext{Roles: []Role{RoleDeveloper}, Action: ActionRefundCreate, Environment: EnvironmentLive},
+			expected: false,
+		},
+		{
+			name:     "Finance deploys in a live zone",
+			pctx:     PolicyContext{Roles: []Role{RoleFinance}, Action: ActionFlowDeploy, Environment: EnvironmentLive},
+			expected: true,
+		},
+		{
+			name:     "Viewer deploys in a test zone",
+			pctx:     PolicyContext{Roles: []Role{RoleViewer}, Action: ActionFlowDeploy, Environment: EnvironmentTest},
+			expected: false,
+		},
+		{
+			name:     "Developer granted finance in the zone",
+			pctx:     PolicyContext{ZoneID: "zone-1", Roles: []Role{RoleDeveloper}, Action: ActionFlowDeploy, Environment: EnvironmentLive, ZoneRoles: map[string][]Role{"zone-1": {RoleFinance}}},
+			expected: true,
+		},
+		{
+			name:     "Developer granted finance in another zone",
+			pctx:     PolicyContext{ZoneID: "zone-1", Roles: []Role{RoleDeveloper}, Action: ActionFlowDeploy, Environment: EnvironmentLive, ZoneRoles: map[string][]Role{"zone-2": {RoleFinance}}},
+			expected: false,
+		},
+		{
+			name:     "Test key in a live zone",
+			pctx:     PolicyContext{Roles: []Role{RoleAdmin}, Action: ActionPaymentCreate, Environment: EnvironmentLive, KeyEnvironment: EnvironmentTest},
+			expected: false,
+			rule:     "environment:test_key",
+		},
+		{
+			name:     "Live key in a test zone",
+			pctx:     PolicyContext{Roles: []Role{RoleAdmin}, Action: ActionPaymentCreate, Environment: EnvironmentTest, KeyEnvironment: EnvironmentLive},
+			expected: false,
+			rule:     "environment:live_key",
+		},
+		{
+			name:     "Live key in a live zone",
+			pctx:     PolicyContext{Roles: []Role{RoleAdmin}, Action: ActionPaymentCreate, Environment: EnvironmentLive, KeyEnvironment: EnvironmentLive},
+			expected: true,
+		},
+	}
+
+	for name, engine := range engines {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				result, err := engine.Check(context.Background(), &tt.pctx)
+				if err != nil {
+					t.Fatalf("Check failed: %v", err)
+				}
+				if result.Allowed != tt.expected {
+					t.Errorf("expected allowed=%v, got %v (%s)", tt.expected, result.Allowed, result.Reason)
+				}
+				if tt.rule != "" && (len(result.Rules) == 0 || result.Rules[0] != tt.rule) {
+					t.Errorf("expected rule %s, got %v", tt.rule, result.Rules)
+				}
+			})
+		}
+	}
+}
@@ -3,6 +3,7 @@ package policy
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 // Action represents an action that can be policy-controlled
@@ -40,6 +41,14 @@ type PolicyContext struct {
 	Roles    []Role
 	Resource map[string]interface{}
 	Action   Action
+	// Environment is the mode of the zone, test or live, if known
+	Environment string
+	// KeyEnvironment is the environment of the caller's API key, from its
+	// prefix; empty for sessions
+	KeyEnvironment string
+	// ZoneRoles are roles granted to the caller in single zones, on top of
+	// Roles
+	ZoneRoles map[string][]Role
 }
 
 // PolicyResult contains the result of a policy check
@@ -64,6 +73,11 @@ func NewHardcodedPolicyEngine() *HardcodedPolicyEngine {
 
 // Check evaluates hardcoded policies
 func (e *HardcodedPolicyEngine) Check(ctx context.Context, pctx *PolicyContext) (*PolicyResult, error) {
+	pctx = pctx.resolved()
+	if result := checkEnvironment(pctx); result != nil {
+		return result, nil
+	}
+
 	result := &PolicyResult{
 		Allowed: false,
 		Rules:   make([]string, 0),
@@ -167,7 +181,8 @@ func NewPolicyMiddleware(engine PolicyEngine) *PolicyMiddleware {
 	return &PolicyMiddleware{engine: engine}
 }
 
-// Check performs a policy check and returns an error if denied
+// Check performs a policy check and returns a *DeniedError if denied, naming
+// the action checked, e.g. flow.deploy.live in live zones
 func (m *PolicyMiddleware) Check(ctx context.Context, pctx *PolicyContext) error {
 	result, err := m.engine.Check(ctx, pctx)
 	if err != nil {
@@ -175,7 +190,7 @@ func (m *PolicyMiddleware) Check(ctx context.Context, pctx *PolicyContext) error
 	}
 
 	if !result.Allowed {
-		return fmt.Errorf("denied: %s", result.Reason)
+		return &DeniedError{Action: pctx.resolved().Action, Reason: result.Reason, Rules: result.Rules}
 	}
 
 	return nil
@@ -183,12 +198,15 @@ func (m *PolicyMiddleware) Check(ctx context.Context, pctx *PolicyContext) error
 
 // Audit logs policy decisions for compliance
 type PolicyAuditLog struct {
-	Timestamp string   `json:"timestamp"`
-	UserID    string   `json:"userId"`
-	OrgID     string   `json:"orgId"`
-	Action    Action   `json:"action"`
-	Resource  string   `json:"resource,omitempty"`
-	Allowed   bool     `json:"allowed"`
-	Reason    string   `json:"reason"`
-	Rules     []string `json:"rules,omitempty"`
+	ID        string    `json:"id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	UserID    string    `json:"userId"`
+	OrgID     string    `json:"orgId"`
+	ZoneID    string    `json:"zoneId,omitempty"`
+	Roles     []Role    `json:"roles,omitempty"`
+	Action    Action    `json:"action"`
+	Resource  string    `json:"resource,omitempty"` // The request's resource as JSON
+	Allowed   bool      `json:"allowed"`
+	Reason    string    `json:"reason"`
+	Rules     []string  `json:"rules,omitempty"`
 }
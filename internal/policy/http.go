@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// ErrorCode identifies policy denials to clients
+const ErrorCode = "policy_denied"
+
+// DeniedError is returned by PolicyMiddleware.Check when the policy denies
+// the action
+type DeniedError struct {
+	Action Action
+	Reason string
+	Rules  []string
+}
+
+func (e *DeniedError) Error() string {
+	return "denied: " + e.Reason
+}
+
+// DeniedResponse is the body of the 403 answered to requests the policy
+// denies
+type DeniedResponse struct {
+	Error  string `json:"error"`
+	Code   string `json:"code"`
+	Action Action `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// WriteError answers a request whose policy check failed: a 403 when the
+// policy denied it and a 500 when it could not be evaluated
+func WriteError(w http.ResponseWriter, err error) {
+	var denied *DeniedError
+	if !errors.As(err, &denied) {
+		log.Printf("Policy check failed: %v", err)
+		jsonutil.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Policy check failed"})
+		return
+	}
+	jsonutil.WriteJSON(w, http.StatusForbidden, DeniedResponse{
+		Error:  "Forbidden by policy",
+		Code:   ErrorCode,
+		Action: denied.Action,
+		Reason: denied.Reason,
+	})
+}
+
+// RolesFrom maps an organization role to the policy roles it holds. Owners
+// hold the admin role; members only read.
+func RolesFrom(role string) []Role {
+	switch role {
+	case "":
+		return nil
+	case "owner":
+		return []Role{RoleAdmin}
+	case "member":
+		return []Role{RoleViewer}
+	}
+	return []Role{Role(role)}
+}
+
+// ContextFromHeaders builds the policy context of a request from the
+// identity headers the gateway sets. X-Environment is only set for API
+// keys, to the environment of their prefix. Callers acting for no
+// organization have no policy context, which is reported by ok being false.
+func ContextFromHeaders(r *http.Request, action Action, resource map[string]interface{}) (pctx *PolicyContext, ok bool) {
+	orgID := r.Header.Get("X-Org-ID")
+	if orgID == "" {
+		return nil, false
+	}
+	return &PolicyContext{
+		UserID:         r.Header.Get("X-User-ID"),
+		OrgID:          orgID,
+		ZoneID:         r.Header.Get("X-Zone-ID"),
+		Roles:          RolesFrom(r.Header.Get("X-Role")),
+		Resource:       resource,
+		Action:         action,
+		Environment:    r.Header.Get("X-Zone-Mode"),
+		KeyEnvironment: r.Header.Get("X-Environment"),
+	}, true
+}
+
+// Authorize checks the action for the caller of the request, answering it
+// when the policy does not allow it. Callers acting for no organization are
+// denied: no policy grants them anything.
+func (m *PolicyMiddleware) Authorize(w http.ResponseWriter, r *http.Request, action Action, resource map[string]interface{}) bool {
+	pctx, ok := ContextFromHeaders(r, action, resource)
+	if !ok {
+		WriteError(w, &DeniedError{Action: action, Reason: "the caller acts for no organization"})
+		return false
+	}
+	if err := m.Check(r.Context(), pctx); err != nil {
+		WriteError(w, err)
+		return false
+	}
+	return true
+}
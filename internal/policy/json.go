@@ -33,6 +33,11 @@ func NewJSONPolicyEngine(path string) (*JSONPolicyEngine, error) {
 
 // Check evaluates policies loaded from JSON
 func (e *JSONPolicyEngine) Check(ctx context.Context, pctx *PolicyContext) (*PolicyResult, error) {
+	pctx = pctx.resolved()
+	if result := checkEnvironment(pctx); result != nil {
+		return result, nil
+	}
+
 	result := &PolicyResult{
 		Allowed: false,
 		Rules:   make([]string, 0),
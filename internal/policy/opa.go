@@ -35,10 +35,17 @@ func NewOPAPolicyEngine(path string) (*OPAPolicyEngine, error) {
 
 // Check evaluates policies using OPA Rego
 func (e *OPAPolicyEngine) Check(ctx context.Context, pctx *PolicyContext) (*PolicyResult, error) {
+	pctx = pctx.resolved()
+	if result := checkEnvironment(pctx); result != nil {
+		return result, nil
+	}
+
 	// Prepare input for OPA
 	input := map[string]interface{}{
-		"roles":  pctx.Roles,
-		"action": string(pctx.Action),
+		"roles":       pctx.Roles,
+		"action":      string(pctx.Action),
+		"zone_id":     pctx.ZoneID,
+		"environment": pctx.Environment,
 	}
 
 	results, err := e.query.Eval(ctx, rego.EvalInput(input))
@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PolicyService manages custom policies and zone roles, keeping the engine of this
+// instance, if any, in sync with the changes it makes
+type PolicyService struct {
+	store  PolicyStore
+	engine *DatabasePolicyEngine
+}
+
+func NewPolicyService(store PolicyStore, engine *DatabasePolicyEngine) *PolicyService {
+	return &PolicyService{store: store, engine: engine}
+}
+
+func (s *PolicyService) CreatePolicy(ctx context.Context, p *Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	p.CreatedAt, p.UpdatedAt = now, now
+	if err := s.store.CreatePolicy(ctx, p); err != nil {
+		return err
+	}
+	s.refresh(ctx)
+	return nil
+}
+
+func (s *PolicyService) GetPolicy(ctx context.Context, id string) (*Policy, error) {
+	p, err := s.store.GetPolicy(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrPolicyNotFound
+	}
+	return p, nil
+}
+
+func (s *PolicyService) ListPolicies(ctx context.Context, orgID string) ([]Policy, error) {
+	return s.store.ListPolicies(ctx, orgID)
+}
+
+// UpdatePolicy saves the policy's changes; its organization and author
+// stay
+func (s *PolicyService) UpdatePolicy(ctx context.Context, p *Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	p.UpdatedAt = time.Now().UTC()
+	if err := s.store.UpdatePolicy(ctx, p); err != nil {
+		return err
+	}
+	s.refresh(ctx)
+	return nil
+}
+
+func (s *PolicyService) DeletePolicy(ctx context.Context, id string) error {
+	if err := s.store.DeletePolicy(ctx, id); err != nil {
+		return err
+	}
+	s.refresh(ctx)
+	return nil
+}
+
+func (s *PolicyService) GrantZoneRole(ctx context.Context, g *ZoneRole) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	g.CreatedAt = time.Now().UTC()
+	if err := s.store.GrantZoneRole(ctx, g); err != nil {
+		return err
+	}
+	s.refresh(ctx)
+	return nil
+}
+
+func (s *PolicyService) GetZoneRole(ctx context.Context, id string) (*ZoneRole, error) {
+	g, err := s.store.GetZoneRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, ErrZoneRoleNotFound
+	}
+	return g, nil
+}
+
+func (s *PolicyService) ListZoneRoles(ctx context.Context, zoneID string) ([]ZoneRole, error) {
+	return s.store.ListZoneRoles(ctx, zoneID)
+}
+
+func (s *PolicyService) RevokeZoneRole(ctx context.Context, id string) error {
+	if err := s.store.RevokeZoneRole(ctx, id); err != nil {
+		return err
+	}
+	s.refresh(ctx)
+	return nil
+}
+
+// refresh applies a change right away on this instance. Should it fail,
+// the change is picked up by the next Watch poll.
+func (s *PolicyService) refresh(ctx context.Context) {
+	if s.engine == nil {
+		return
+	}
+	if err := s.engine.Refresh(ctx); err != nil {
+		log.Printf("Failed to reload policies after a change: %v", err)
+	}
+}
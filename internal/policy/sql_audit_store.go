@@ -0,0 +1,110 @@
+package policy
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const auditLogColumns = `id, created_at, user_id, org_id, zone_id, roles, action, resource, allowed, reason, rules`
+
+// SQLAuditStore keeps policy decisions in the policy_audit_logs table
+type SQLAuditStore struct {
+	db *sql.DB
+}
+
+func NewSQLAuditStore(db *sql.DB) *SQLAuditStore {
+	return &SQLAuditStore{db: db}
+}
+
+// WriteAuditLogs inserts the decisions in a single statement
+func (s *SQLAuditStore) WriteAuditLogs(ctx context.Context, logs []PolicyAuditLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	var rows []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	for _, l := range logs {
+		roles, _ := json.Marshal(l.Roles)
+		rules, _ := json.Marshal(l.Rules)
+		var resource interface{}
+		if l.Resource != "" {
+			resource = l.Resource
+		}
+		rows = append(rows, "("+strings.Join([]string{
+			arg(l.Timestamp), arg(l.UserID), arg(l.OrgID), arg(l.ZoneID), arg(roles), arg(string(l.Action)),
+			arg(resource), arg(l.Allowed), arg(l.Reason), arg(rules),
+		}, ", ")+")")
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO policy_audit_logs (created_at, user_id, org_id, zone_id, roles, action, resource, allowed, reason, rules)
+		 VALUES `+strings.Join(rows, ", "), args...)
+	if err != nil {
+		return fmt.Errorf("failed to write policy audit logs: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLAuditStore) ListAuditLogs(ctx context.Context, filter AuditFilter) ([]PolicyAuditLog, error) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	conditions := []string{"org_id = " + arg(filter.OrgID)}
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = "+arg(filter.UserID))
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "action = "+arg(string(filter.Action)))
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(filter.To))
+	}
+
+	query := "SELECT " + auditLogColumns + " FROM policy_audit_logs WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY created_at DESC LIMIT " + arg(filter.Limit)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list policy audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	logs := []PolicyAuditLog{}
+	for rows.Next() {
+		var l PolicyAuditLog
+		var roles, rules []byte
+		var resource sql.NullString
+		if err := rows.Scan(&l.ID, &l.Timestamp, &l.UserID, &l.OrgID, &l.ZoneID, &roles, &l.Action, &resource,
+			&l.Allowed, &l.Reason, &rules); err != nil {
+			return nil, err
+		}
+		l.Resource = resource.String
+		if err := json.Unmarshal(roles, &l.Roles); err != nil {
+			return nil, fmt.Errorf("invalid roles of policy audit log %s: %w", l.ID, err)
+		}
+		if err := json.Unmarshal(rules, &l.Rules); err != nil {
+			return nil, fmt.Errorf("invalid rules of policy audit log %s: %w", l.ID, err)
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+func (s *SQLAuditStore) PruneAuditLogs(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM policy_audit_logs WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune policy audit logs: %w", err)
+	}
+	return result.RowsAffected()
+}
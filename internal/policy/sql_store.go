@@ -0,0 +1,227 @@
+package policy
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const policyColumns = `id, org_id, zone_id, name, description, effect, roles, actions, conditions, enabled, created_by, created_at, updated_at`
+
+// SQLPolicyStore keeps custom policies in the policies table and zone
+// roles in the zone_roles table
+type SQLPolicyStore struct {
+	db *sql.DB
+}
+
+func NewSQLPolicyStore(db *sql.DB) *SQLPolicyStore {
+	return &SQLPolicyStore{db: db}
+}
+
+func (s *SQLPolicyStore) CreatePolicy(ctx context.Context, p *Policy) error {
+	roles, actions, conditions, err := encodePolicy(p)
+	if err != nil {
+		return err
+	}
+	err = s.db.QueryRowContext(ctx,
+		`INSERT INTO policies (org_id, zone_id, name, description, effect, roles, actions, conditions, enabled, created_by, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		p.OrgID, p.ZoneID, p.Name, p.Description, p.Effect, roles, actions, conditions, p.Enabled, p.CreatedBy,
+		p.CreatedAt, p.UpdatedAt).
+		Scan(&p.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create policy: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLPolicyStore) GetPolicy(ctx context.Context, id string) (*Policy, error) {
+	p, err := scanPolicy(s.db.QueryRowContext(ctx,
+		"SELECT "+policyColumns+" FROM policies WHERE id::text = $1", id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get policy: %w", err)
+	}
+	return p, nil
+}
+
+func (s *SQLPolicyStore) ListPolicies(ctx context.Context, orgID string) ([]Policy, error) {
+	if orgID == "" {
+		return s.queryPolicies(ctx, "SELECT "+policyColumns+" FROM policies ORDER BY created_at DESC")
+	}
+	return s.queryPolicies(ctx,
+		"SELECT "+policyColumns+" FROM policies WHERE org_id = $1 ORDER BY created_at DESC", orgID)
+}
+
+func (s *SQLPolicyStore) UpdatePolicy(ctx context.Context, p *Policy) error {
+	roles, actions, conditions, err := encodePolicy(p)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE policies SET zone_id = $1, name = $2, description = $3, effect = $4, roles = $5, actions = $6,
+		 conditions = $7, enabled = $8, updated_at = $9 WHERE id::text = $10`,
+		p.ZoneID, p.Name, p.Description, p.Effect, roles, actions, conditions, p.Enabled, p.UpdatedAt, p.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update policy: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrPolicyNotFound
+	}
+	return nil
+}
+
+func (s *SQLPolicyStore) DeletePolicy(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM policies WHERE id::text = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete policy: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrPolicyNotFound
+	}
+	return nil
+}
+
+func (s *SQLPolicyStore) EnabledPolicies(ctx context.Context) ([]Policy, error) {
+	return s.queryPolicies(ctx, "SELECT "+policyColumns+" FROM policies WHERE enabled ORDER BY created_at")
+}
+
+const zoneRoleColumns = `id, org_id, zone_id, user_id, role, granted_by, created_at`
+
+// GrantZoneRole inserts the grant, reporting ErrZoneRoleExists when the
+// user already holds the role in the zone
+func (s *SQLPolicyStore) GrantZoneRole(ctx context.Context, g *ZoneRole) error {
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO zone_roles (org_id, zone_id, user_id, role, granted_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (zone_id, user_id, role) DO NOTHING RETURNING id`,
+		g.OrgID, g.ZoneID, g.UserID, string(g.Role), g.GrantedBy, g.CreatedAt).
+		Scan(&g.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrZoneRoleExists
+		}
+		return fmt.Errorf("failed to grant zone role: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLPolicyStore) GetZoneRole(ctx context.Context, id string) (*ZoneRole, error) {
+	var g ZoneRole
+	err := s.db.QueryRowContext(ctx, "SELECT "+zoneRoleColumns+" FROM zone_roles WHERE id::text = $1", id).
+		Scan(&g.ID, &g.OrgID, &g.ZoneID, &g.UserID, &g.Role, &g.GrantedBy, &g.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get zone role: %w", err)
+	}
+	return &g, nil
+}
+
+func (s *SQLPolicyStore) ListZoneRoles(ctx context.Context, zoneID string) ([]ZoneRole, error) {
+	return s.queryZoneRoles(ctx,
+		"SELECT "+zoneRoleColumns+" FROM zone_roles WHERE zone_id = $1 ORDER BY created_at", zoneID)
+}
+
+func (s *SQLPolicyStore) RevokeZoneRole(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM zone_roles WHERE id::text = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke zone role: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrZoneRoleNotFound
+	}
+	return nil
+}
+
+func (s *SQLPolicyStore) AllZoneRoles(ctx context.Context) ([]ZoneRole, error) {
+	return s.queryZoneRoles(ctx, "SELECT "+zoneRoleColumns+" FROM zone_roles")
+}
+
+// Version is the number of policies and zone roles and their latest
+// change. Deleting one changes the former, creating or changing one the
+// latter.
+func (s *SQLPolicyStore) Version(ctx context.Context) (string, error) {
+	var policies, zoneRoles int
+	var policiesLatest, zoneRolesLatest sql.NullTime
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT (SELECT COUNT(*) FROM policies), (SELECT MAX(updated_at) FROM policies),
+		 (SELECT COUNT(*) FROM zone_roles), (SELECT MAX(created_at) FROM zone_roles)`).
+		Scan(&policies, &policiesLatest, &zoneRoles, &zoneRolesLatest); err != nil {
+		return "", fmt.Errorf("failed to get policies version: %w", err)
+	}
+	return fmt.Sprintf("%d-%d-%d-%d", policies, policiesLatest.Time.UnixNano(), zoneRoles,
+		zoneRolesLatest.Time.UnixNano()), nil
+}
+
+func (s *SQLPolicyStore) queryZoneRoles(ctx context.Context, query string, args ...interface{}) ([]ZoneRole, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list zone roles: %w", err)
+	}
+	defer rows.Close()
+
+	grants := []ZoneRole{}
+	for rows.Next() {
+		var g ZoneRole
+		if err := rows.Scan(&g.ID, &g.OrgID, &g.ZoneID, &g.UserID, &g.Role, &g.GrantedBy, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+func (s *SQLPolicyStore) queryPolicies(ctx context.Context, query string, args ...interface{}) ([]Policy, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := []Policy{}
+	for rows.Next() {
+		p, err := scanPolicy(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, *p)
+	}
+	return policies, rows.Err()
+}
+
+func encodePolicy(p *Policy) (roles, actions, conditions []byte, err error) {
+	if roles, err = json.Marshal(p.Roles); err != nil {
+		return nil, nil, nil, err
+	}
+	if actions, err = json.Marshal(p.Actions); err != nil {
+		return nil, nil, nil, err
+	}
+	if conditions, err = json.Marshal(p.Conditions); err != nil {
+		return nil, nil, nil, err
+	}
+	return roles, actions, conditions, nil
+}
+
+func scanPolicy(scan func(dest ...interface{}) error) (*Policy, error) {
+	var p Policy
+	var roles, actions, conditions []byte
+	if err := scan(&p.ID, &p.OrgID, &p.ZoneID, &p.Name, &p.Description, &p.Effect, &roles, &actions, &conditions,
+		&p.Enabled, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(roles, &p.Roles); err != nil {
+		return nil, fmt.Errorf("invalid roles of policy %s: %w", p.ID, err)
+	}
+	if err := json.Unmarshal(actions, &p.Actions); err != nil {
+		return nil, fmt.Errorf("invalid actions of policy %s: %w", p.ID, err)
+	}
+	if err := json.Unmarshal(conditions, &p.Conditions); err != nil {
+		return nil, fmt.Errorf("invalid conditions of policy %s: %w", p.ID, err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,252 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Effects of stored policies. A deny that applies wins over any allow.
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Condition operators
+const (
+	OpEq    = "eq"
+	OpNeq   = "neq"
+	OpLt    = "lt"
+	OpLte   = "lte"
+	OpGt    = "gt"
+	OpGte   = "gte"
+	OpIn    = "in"
+	OpNotIn = "not_in"
+)
+
+var (
+	ErrPolicyNotFound   = errors.New("policy not found")
+	ErrInvalidPolicy    = errors.New("name, effect (allow or deny) and actions are required")
+	ErrInvalidCondition = errors.New("conditions need an attribute (user_id, org_id, zone_id, environment or resource.<field>), an operator (eq, neq, lt, lte, gt, gte, in, not_in) and a value of its type")
+)
+
+// Condition compares an attribute of the request to a value. Attributes
+// are user_id, org_id, zone_id, environment (the zone's mode) or
+// resource.<field>, e.g. resource.amount in minor units.
+type Condition struct {
+	Attribute string      `json:"attribute"`
+	Operator  string      `json:"operator"`
+	Value     interface{} `json:"value"`
+}
+
+// Policy is a custom policy stored in the database. It applies to the
+// requests of its roles (any role if none) for its actions, in its
+// organization and zone when set. Actions may end with a wildcard, e.g.
+// "payment.*". Its conditions must all hold for its effect to apply.
+type Policy struct {
+	ID          string      `json:"id"`
+	OrgID       string      `json:"org_id,omitempty"`  // Every organization if empty
+	ZoneID      string      `json:"zone_id,omitempty"` // Every zone if empty
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Effect      string      `json:"effect"`
+	Roles       []Role      `json:"roles"`
+	Actions     []Action    `json:"actions"`
+	Conditions  []Condition `json:"conditions"`
+	Enabled     bool        `json:"enabled"`
+	CreatedBy   string      `json:"created_by,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// Validate checks the policy can be evaluated
+func (p *Policy) Validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" || (p.Effect != EffectAllow && p.Effect != EffectDeny) || len(p.Actions) == 0 {
+		return ErrInvalidPolicy
+	}
+	for _, action := range p.Actions {
+		if action == "" {
+			return ErrInvalidPolicy
+		}
+	}
+	for _, c := range p.Conditions {
+		if err := c.validate(); err != nil {
+			return err
+		}
+	}
+	if p.Roles == nil {
+		p.Roles = []Role{}
+	}
+	if p.Conditions == nil {
+		p.Conditions = []Condition{}
+	}
+	return nil
+}
+
+// appliesTo reports whether the policy covers the request, conditions
+// aside
+func (p *Policy) appliesTo(pctx *PolicyContext) bool {
+	if !p.Enabled || (p.OrgID != "" && p.OrgID != pctx.OrgID) || (p.ZoneID != "" && p.ZoneID != pctx.ZoneID) {
+		return false
+	}
+	if !slices.ContainsFunc(p.Actions, func(a Action) bool { return actionMatches(a, pctx.Action) }) {
+		return false
+	}
+	return len(p.Roles) == 0 || slices.ContainsFunc(pctx.Roles, func(r Role) bool { return slices.Contains(p.Roles, r) })
+}
+
+// conditionsHold reports whether every condition holds for the request
+func (p *Policy) conditionsHold(pctx *PolicyContext) bool {
+	for _, c := range p.Conditions {
+		if !c.holds(pctx) {
+			return false
+		}
+	}
+	return true
+}
+
+// actionMatches reports whether a policy's action, "*" or ending with ".*"
+// for wildcards, covers the action
+func actionMatches(pattern, action Action) bool {
+	if pattern == "*" || pattern == action {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(string(pattern), "*")
+	return ok && strings.HasPrefix(string(action), prefix)
+}
+
+func (c *Condition) validate() error {
+	if c.Attribute != "user_id" && c.Attribute != "org_id" && c.Attribute != "zone_id" &&
+		c.Attribute != "environment" && !strings.HasPrefix(c.Attribute, "resource.") {
+		return ErrInvalidCondition
+	}
+	switch c.Operator {
+	case OpEq, OpNeq:
+		if c.Value == nil {
+			return ErrInvalidCondition
+		}
+	case OpLt, OpLte, OpGt, OpGte:
+		if _, ok := toFloat(c.Value); !ok {
+			return ErrInvalidCondition
+		}
+	case OpIn, OpNotIn:
+		if _, ok := c.Value.([]interface{}); !ok {
+			return ErrInvalidCondition
+		}
+	default:
+		return ErrInvalidCondition
+	}
+	return nil
+}
+
+// holds evaluates the condition. Conditions on attributes the request
+// lacks do not hold.
+func (c *Condition) holds(pctx *PolicyContext) bool {
+	actual, ok := attribute(pctx, c.Attribute)
+	if !ok {
+		return false
+	}
+	switch c.Operator {
+	case OpEq:
+		return equal(actual, c.Value)
+	case OpNeq:
+		return !equal(actual, c.Value)
+	case OpIn, OpNotIn:
+		values, _ := c.Value.([]interface{})
+		in := slices.ContainsFunc(values, func(v interface{}) bool { return equal(actual, v) })
+		return in == (c.Operator == OpIn)
+	}
+
+	a, okA := toFloat(actual)
+	b, okB := toFloat(c.Value)
+	if !okA || !okB {
+		return false
+	}
+	switch c.Operator {
+	case OpLt:
+		return a < b
+	case OpLte:
+		return a <= b
+	case OpGt:
+		return a > b
+	case OpGte:
+		return a >= b
+	}
+	return false
+}
+
+func attribute(pctx *PolicyContext, name string) (interface{}, bool) {
+	switch name {
+	case "user_id":
+		return pctx.UserID, pctx.UserID != ""
+	case "org_id":
+		return pctx.OrgID, pctx.OrgID != ""
+	case "zone_id":
+		return pctx.ZoneID, pctx.ZoneID != ""
+	case "environment":
+		return pctx.Environment, pctx.Environment != ""
+	}
+	field, _ := strings.CutPrefix(name, "resource.")
+	v, ok := pctx.Resource[field]
+	return v, ok && v != nil
+}
+
+// equal compares numbers by value and anything else by its text
+func equal(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// PolicyStore keeps custom policies and zone roles
+type PolicyStore interface {
+	CreatePolicy(ctx context.Context, p *Policy) error
+	// GetPolicy returns the policy, or nil if there is none
+	GetPolicy(ctx context.Context, id string) (*Policy, error)
+	// ListPolicies returns the organization's policies, or all of them if
+	// orgID is empty
+	ListPolicies(ctx context.Context, orgID string) ([]Policy, error)
+	UpdatePolicy(ctx context.Context, p *Policy) error
+	DeletePolicy(ctx context.Context, id string) error
+	// EnabledPolicies returns the policies to evaluate
+	EnabledPolicies(ctx context.Context) ([]Policy, error)
+
+	GrantZoneRole(ctx context.Context, g *ZoneRole) error
+	// GetZoneRole returns the grant, or nil if there is none
+	GetZoneRole(ctx context.Context, id string) (*ZoneRole, error)
+	// ListZoneRoles returns the roles granted in the zone
+	ListZoneRoles(ctx context.Context, zoneID string) ([]ZoneRole, error)
+	RevokeZoneRole(ctx context.Context, id string) error
+	// AllZoneRoles returns the grants of every zone to evaluate
+	AllZoneRoles(ctx context.Context) ([]ZoneRole, error)
+
+	// Version changes whenever a policy or zone role is created, changed
+	// or deleted
+	Version(ctx context.Context) (string, error)
+}
@@ -0,0 +1,41 @@
+package policy
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrZoneRoleNotFound = errors.New("zone role not found")
+	ErrZoneRoleExists   = errors.New("the role is already granted in the zone")
+	ErrInvalidZoneRole  = errors.New("zone_id, user_id and a role (admin, finance, developer or viewer) are required")
+)
+
+// ZoneRole grants a user a role in a single zone of the organization, on
+// top of their organization role, e.g. developer in a test zone only
+type ZoneRole struct {
+	ID        string    `json:"id"`
+	OrgID     string    `json:"org_id"`
+	ZoneID    string    `json:"zone_id"`
+	UserID    string    `json:"user_id"`
+	Role      Role      `json:"role"`
+	GrantedBy string    `json:"granted_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks the grant names a zone, a user and a role that exists
+func (g *ZoneRole) Validate() error {
+	if g.OrgID == "" || g.ZoneID == "" || g.UserID == "" || !ValidRole(g.Role) {
+		return ErrInvalidZoneRole
+	}
+	return nil
+}
+
+// ValidRole reports whether a role can be granted
+func ValidRole(role Role) bool {
+	switch role {
+	case RoleAdmin, RoleFinance, RoleDeveloper, RoleViewer:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+-- Migration: Login sessions with rotating refresh tokens
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token_hash TEXT NOT NULL UNIQUE,
+    previous_token_hash TEXT, -- Rotated out, presenting it again revokes the session
+    user_agent TEXT,
+    ip_address TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id, created_at DESC);
+CREATE INDEX idx_sessions_previous_token ON sessions(previous_token_hash);
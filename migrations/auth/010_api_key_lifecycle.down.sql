@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_api_keys_expires_at;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS rotated_from;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS last_used_at;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,4 @@
+ALTER TABLE organizations DROP COLUMN IF EXISTS require_two_factor;
+DROP TABLE IF EXISTS two_factor_challenges;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS user_two_factor;
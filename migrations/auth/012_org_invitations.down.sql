@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN IF EXISTS org_id;
+DROP TABLE IF EXISTS org_invitations;
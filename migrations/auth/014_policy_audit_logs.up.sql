@@ -0,0 +1,18 @@
+-- Migration: Every policy decision, allowed or denied, for compliance
+-- reviews. Decisions older than POLICY_AUDIT_RETENTION are pruned.
+CREATE TABLE IF NOT EXISTS policy_audit_logs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    user_id VARCHAR(255) NOT NULL DEFAULT '',
+    org_id VARCHAR(255) NOT NULL DEFAULT '',
+    zone_id VARCHAR(255) NOT NULL DEFAULT '',
+    roles JSONB NOT NULL DEFAULT '[]',
+    action VARCHAR(100) NOT NULL,
+    resource JSONB,
+    allowed BOOLEAN NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    rules JSONB NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX IF NOT EXISTS idx_policy_audit_logs_org ON policy_audit_logs (org_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_policy_audit_logs_created ON policy_audit_logs (created_at);
@@ -0,0 +1,12 @@
+-- Migration: Roles granted to users in single zones, on top of their
+-- organization role. Evaluated by the policy engines with the policies.
+CREATE TABLE IF NOT EXISTS zone_roles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id VARCHAR(255) NOT NULL,
+    zone_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    granted_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (zone_id, user_id, role)
+);
@@ -0,0 +1,3 @@
+-- Revert replay query indexes
+DROP INDEX IF EXISTS idx_events_zone_type_created;
+DROP INDEX IF EXISTS idx_events_zone_created_id;
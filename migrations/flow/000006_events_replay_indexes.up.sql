@@ -0,0 +1,3 @@
+-- Support filtered, cursor-paginated event replay queries
+CREATE INDEX IF NOT EXISTS idx_events_zone_created_id ON events(zone_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_events_zone_type_created ON events(zone_id, type, created_at DESC);
@@ -0,0 +1,16 @@
+-- Configurable fraud rules, reloaded by the fraud service without a restart.
+-- params holds the settings of the rule's type, e.g. {"limit": 1000000} for
+-- amount_threshold.
+CREATE TABLE IF NOT EXISTS fraud_rules (
+    name VARCHAR(100) PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    params JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- The rules the service used to hardcode
+INSERT INTO fraud_rules (name, type, params) VALUES
+    ('AmountRule', 'amount_threshold', '{"limit": 1000000}'),
+    ('VelocityRule', 'velocity', '{"window": "1m", "threshold": 5}')
+ON CONFLICT (name) DO NOTHING;
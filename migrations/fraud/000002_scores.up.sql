@@ -0,0 +1,28 @@
+-- Risk scores: each rule adds its weight to the score of the payments it
+-- flags, and zones decide from the score with their thresholds
+ALTER TABLE fraud_rules ADD COLUMN IF NOT EXISTS weight INT NOT NULL DEFAULT 50;
+
+-- Thresholds by zone; the 'default' row applies to zones without their own
+CREATE TABLE IF NOT EXISTS fraud_thresholds (
+    zone_id VARCHAR(255) PRIMARY KEY,
+    review_threshold INT NOT NULL,
+    decline_threshold INT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (review_threshold > 0 AND review_threshold <= decline_threshold AND decline_threshold <= 100)
+);
+
+INSERT INTO fraud_thresholds (zone_id, review_threshold, decline_threshold)
+VALUES ('default', 50, 80)
+ON CONFLICT (zone_id) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS fraud_scores (
+    payment_id VARCHAR(255) PRIMARY KEY,
+    zone_id VARCHAR(255) NOT NULL DEFAULT '',
+    user_id VARCHAR(255) NOT NULL DEFAULT '',
+    score INT NOT NULL,
+    decision VARCHAR(20) NOT NULL,
+    signals JSONB NOT NULL DEFAULT '[]',
+    scored_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_fraud_scores_zone_decision ON fraud_scores (zone_id, decision, scored_at DESC);
@@ -0,0 +1,3 @@
+-- Payments are scored before they are confirmed, or once they succeeded
+-- when they were not checked
+ALTER TABLE fraud_scores ADD COLUMN IF NOT EXISTS stage VARCHAR(30) NOT NULL DEFAULT 'post_payment';
@@ -0,0 +1,32 @@
+-- Review cases of the payments the fraud service sent for review or
+-- declined, one per payment
+CREATE TABLE IF NOT EXISTS fraud_cases (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_id VARCHAR(255) NOT NULL UNIQUE,
+    zone_id VARCHAR(255) NOT NULL DEFAULT '',
+    user_id VARCHAR(255) NOT NULL DEFAULT '',
+    score INT NOT NULL,
+    decision VARCHAR(20) NOT NULL,
+    signals JSONB NOT NULL DEFAULT '[]',
+    status VARCHAR(20) NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'reviewing', 'confirmed_fraud', 'cleared')),
+    assigned_to VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_fraud_cases_zone_status ON fraud_cases (zone_id, status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_fraud_cases_user ON fraud_cases (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_fraud_cases_assignee ON fraud_cases (assigned_to, status) WHERE assigned_to <> '';
+
+-- Reviewers' notes, and a record of every change of status or assignee
+CREATE TABLE IF NOT EXISTS fraud_case_notes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    case_id UUID NOT NULL REFERENCES fraud_cases(id) ON DELETE CASCADE,
+    author VARCHAR(255) NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_fraud_case_notes_case ON fraud_case_notes (case_id, created_at);
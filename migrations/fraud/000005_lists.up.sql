@@ -0,0 +1,19 @@
+-- Managed allowlists and blocklists. Entries without a zone apply to every
+-- zone; expired entries are ignored and kept for the record.
+CREATE TABLE IF NOT EXISTS fraud_list_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    zone_id VARCHAR(255) NOT NULL DEFAULT '',
+    list VARCHAR(10) NOT NULL CHECK (list IN ('allow', 'block')),
+    type VARCHAR(30) NOT NULL
+        CHECK (type IN ('user_id', 'email', 'card_fingerprint', 'ip', 'bin')),
+    value VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (zone_id, list, type, value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fraud_list_entries_zone ON fraud_list_entries (zone_id, list, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_fraud_list_entries_value ON fraud_list_entries (type, value);
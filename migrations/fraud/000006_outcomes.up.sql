@@ -0,0 +1,9 @@
+-- What became of scored payments, learnt from their disputes, to measure
+-- how well the rules predict chargebacks
+ALTER TABLE fraud_scores ADD COLUMN IF NOT EXISTS outcome VARCHAR(20)
+    CHECK (outcome IN ('disputed', 'chargeback', 'legitimate'));
+ALTER TABLE fraud_scores ADD COLUMN IF NOT EXISTS dispute_id VARCHAR(255);
+ALTER TABLE fraud_scores ADD COLUMN IF NOT EXISTS labeled_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_fraud_scores_scored_at ON fraud_scores (scored_at);
+CREATE INDEX IF NOT EXISTS idx_fraud_scores_signals ON fraud_scores USING GIN (signals jsonb_path_ops);
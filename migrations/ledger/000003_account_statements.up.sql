@@ -0,0 +1,2 @@
+-- Account statements page through entries in posting order
+CREATE INDEX IF NOT EXISTS idx_entries_account_created_at ON entries(account_id, created_at, id);
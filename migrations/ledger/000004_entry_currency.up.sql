@@ -0,0 +1,3 @@
+-- Accounts hold a balance per currency, so each entry records its own.
+-- NULL on entries written before: the account's currency.
+ALTER TABLE entries ADD COLUMN IF NOT EXISTS currency VARCHAR(3);
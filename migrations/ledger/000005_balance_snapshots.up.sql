@@ -0,0 +1,11 @@
+-- Balances as of a point in time, so reads only sum the entries since.
+-- Entries are immutable, so a snapshot never goes stale.
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    currency VARCHAR(3) NOT NULL,
+    as_of TIMESTAMP WITH TIME ZONE NOT NULL,
+    balance BIGINT NOT NULL,
+    PRIMARY KEY (account_id, currency, as_of)
+);
+
+CREATE INDEX IF NOT EXISTS idx_entries_created_at ON entries(created_at);
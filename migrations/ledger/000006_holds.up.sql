@@ -0,0 +1,18 @@
+-- Reservations of account balances, e.g. for card authorizations. Active
+-- holds count towards available balances until captured, released or expired.
+CREATE TABLE IF NOT EXISTS holds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    amount BIGINT NOT NULL CHECK (amount != 0), -- Signed like the entry capturing it posts
+    currency VARCHAR(3) NOT NULL,
+    reference_id VARCHAR(255) UNIQUE NOT NULL,
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'active', -- active, released, captured, expired
+    captured_amount BIGINT NOT NULL DEFAULT 0,
+    transaction_id UUID REFERENCES transactions(id),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_account_active ON holds(account_id, expires_at) WHERE status = 'active';
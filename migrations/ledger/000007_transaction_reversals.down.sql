@@ -0,0 +1,3 @@
+-- prevent_mutation() is left in place: the other immutable tables use it
+DROP TRIGGER IF EXISTS trg_immutable_transaction_reversals ON transaction_reversals;
+DROP TABLE IF EXISTS transaction_reversals;
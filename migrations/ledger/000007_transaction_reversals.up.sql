@@ -0,0 +1,21 @@
+-- Transactions are immutable, so reversing one records the link to its
+-- compensating transaction here. The primary key reverses a transaction once.
+CREATE TABLE IF NOT EXISTS transaction_reversals (
+    transaction_id UUID PRIMARY KEY REFERENCES transactions(id),
+    reversal_id UUID UNIQUE NOT NULL REFERENCES transactions(id),
+    reason TEXT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE OR REPLACE FUNCTION prevent_mutation()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'Financial records are immutable. Mutation of %% is forbidden.', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_immutable_transaction_reversals ON transaction_reversals;
+CREATE TRIGGER trg_immutable_transaction_reversals
+BEFORE UPDATE OR DELETE ON transaction_reversals
+FOR EACH ROW EXECUTE FUNCTION prevent_mutation();
@@ -0,0 +1,6 @@
+-- prevent_mutation() is left in place: the other immutable tables use it
+DROP TRIGGER IF EXISTS trg_immutable_accounting_periods ON accounting_periods;
+DROP TABLE IF EXISTS accounting_periods;
+
+-- Dropping a column rewrites no rows, so this passes the immutability trigger
+ALTER TABLE transactions DROP COLUMN IF EXISTS effective_at;
@@ -0,0 +1,24 @@
+-- The accounting date of backdated transactions, e.g. adjustments to a
+-- closed period. NULL means created_at.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS effective_at TIMESTAMP WITH TIME ZONE;
+
+-- Closed accounting periods (YYYY-MM, UTC). Transactions dated within one
+-- are refused unless they post to its adjustment account. Closing is final.
+CREATE TABLE IF NOT EXISTS accounting_periods (
+    period CHAR(7) PRIMARY KEY,
+    adjustment_account_id UUID REFERENCES accounts(id),
+    closed_by VARCHAR(255) NOT NULL,
+    closed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE OR REPLACE FUNCTION prevent_mutation()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'Financial records are immutable. Mutation of %% is forbidden.', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_immutable_accounting_periods ON accounting_periods;
+CREATE TRIGGER trg_immutable_accounting_periods
+BEFORE UPDATE OR DELETE ON accounting_periods
+FOR EACH ROW EXECUTE FUNCTION prevent_mutation();
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_accounts_zone_code;
+DROP INDEX IF EXISTS idx_accounts_parent_id;
+
+-- zone_id and mode stay: zone isolation owns them
+ALTER TABLE accounts DROP COLUMN IF EXISTS parent_id;
+ALTER TABLE accounts DROP COLUMN IF EXISTS code;
@@ -0,0 +1,10 @@
+-- The chart of accounts: codes such as 1000 and 1100, and parents whose
+-- balances roll up their sub-accounts'. Only accounts without sub-accounts
+-- are posted to. Codes are unique within a zone and mode.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS zone_id TEXT;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS mode TEXT;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS code VARCHAR(20);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES accounts(id);
+
+CREATE INDEX IF NOT EXISTS idx_accounts_parent_id ON accounts(parent_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_zone_code ON accounts(zone_id, mode, code) WHERE code IS NOT NULL;
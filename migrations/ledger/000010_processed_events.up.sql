@@ -0,0 +1,9 @@
+-- Consumed events, so an event is posted once however often it is
+-- delivered. Rows older than the retention are deleted.
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id VARCHAR(255) PRIMARY KEY,
+    transaction_id UUID NOT NULL REFERENCES transactions(id),
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at ON processed_events(processed_at);
@@ -0,0 +1,5 @@
+-- Drop soft-delete support from flows
+DROP INDEX IF EXISTS idx_flows_deleted_at;
+
+ALTER TABLE flows
+DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,5 @@
+-- Add soft-delete support to flows
+ALTER TABLE flows
+ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_flows_deleted_at ON flows(deleted_at) WHERE deleted_at IS NOT NULL;
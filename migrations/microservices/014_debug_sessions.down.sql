@@ -0,0 +1,5 @@
+-- Drop debug session persistence
+DROP INDEX IF EXISTS idx_debug_events_session_id;
+DROP INDEX IF EXISTS idx_debug_sessions_last_activity;
+DROP TABLE IF EXISTS debug_events;
+DROP TABLE IF EXISTS debug_sessions;
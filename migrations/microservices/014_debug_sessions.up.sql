@@ -0,0 +1,28 @@
+-- Persist debug sessions and their events across restarts
+CREATE TABLE IF NOT EXISTS debug_sessions (
+    id TEXT PRIMARY KEY,
+    flow_id TEXT NOT NULL,
+    zone_id TEXT NOT NULL,
+    level TEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    breakpoints JSONB NOT NULL DEFAULT '[]',
+    metadata JSONB NOT NULL DEFAULT '{}',
+    start_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_activity TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS debug_events (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL REFERENCES debug_sessions(id) ON DELETE CASCADE,
+    flow_id TEXT,
+    node_id TEXT,
+    level TEXT NOT NULL,
+    type TEXT NOT NULL,
+    message TEXT,
+    data JSONB,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_debug_sessions_last_activity ON debug_sessions(last_activity);
+CREATE INDEX IF NOT EXISTS idx_debug_events_session_id ON debug_events(session_id, timestamp);
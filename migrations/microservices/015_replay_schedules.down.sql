@@ -0,0 +1,4 @@
+-- Drop replay schedules
+DROP INDEX IF EXISTS idx_replay_schedules_due;
+DROP INDEX IF EXISTS idx_replay_schedules_zone_id;
+DROP TABLE IF EXISTS replay_schedules;
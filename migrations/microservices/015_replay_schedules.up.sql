@@ -0,0 +1,20 @@
+-- Scheduled (one-shot or recurring) event replay campaigns
+CREATE TABLE IF NOT EXISTS replay_schedules (
+    id TEXT PRIMARY KEY,
+    zone_id TEXT NOT NULL,
+    source_zone_id TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    lookback_seconds INT NOT NULL,
+    interval_seconds INT NOT NULL DEFAULT 0,
+    delay_ms INT NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    last_job_id TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_replay_schedules_zone_id ON replay_schedules(zone_id);
+CREATE INDEX IF NOT EXISTS idx_replay_schedules_due ON replay_schedules(next_run_at) WHERE enabled = TRUE;
@@ -0,0 +1,4 @@
+-- Drop per-flow execution timeouts
+ALTER TABLE flows
+DROP COLUMN IF EXISTS on_timeout_node_id,
+DROP COLUMN IF EXISTS max_duration_seconds;
@@ -0,0 +1,4 @@
+-- Add per-flow execution timeouts
+ALTER TABLE flows
+ADD COLUMN max_duration_seconds INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN on_timeout_node_id VARCHAR(255) NOT NULL DEFAULT '';
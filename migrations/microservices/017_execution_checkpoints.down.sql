@@ -0,0 +1,5 @@
+-- Drop execution checkpoint tracking
+DROP INDEX IF EXISTS idx_flow_executions_running;
+
+ALTER TABLE flow_executions
+DROP COLUMN IF EXISTS checkpointed_at;
@@ -0,0 +1,5 @@
+-- Track execution checkpoints so interrupted runs can be recovered
+ALTER TABLE flow_executions
+ADD COLUMN checkpointed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_flow_executions_running ON flow_executions(checkpointed_at) WHERE status = 'running';
@@ -0,0 +1,2 @@
+-- Drop inbound webhook endpoints
+DROP TABLE IF EXISTS webhook_hooks;
@@ -0,0 +1,2 @@
+-- Drop persistent schedule triggers
+DROP TABLE IF EXISTS flow_schedules;
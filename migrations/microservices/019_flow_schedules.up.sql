@@ -0,0 +1,15 @@
+-- Persistent schedule triggers, one per flow
+CREATE TABLE IF NOT EXISTS flow_schedules (
+    flow_id TEXT PRIMARY KEY REFERENCES flows(id) ON DELETE CASCADE,
+    zone_id TEXT NOT NULL,
+    cron_expression TEXT NOT NULL DEFAULT '',
+    interval_seconds INT NOT NULL DEFAULT 0,
+    timezone TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_flow_schedules_zone_id ON flow_schedules(zone_id);
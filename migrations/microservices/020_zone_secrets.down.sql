@@ -0,0 +1,2 @@
+-- Drop zone secrets
+DROP TABLE IF EXISTS zone_secrets;
@@ -0,0 +1,9 @@
+-- Encrypted credentials referenced by flow nodes as {{secrets.NAME}}
+CREATE TABLE IF NOT EXISTS zone_secrets (
+    zone_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    ciphertext BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (zone_id, name)
+);
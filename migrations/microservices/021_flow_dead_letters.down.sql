@@ -0,0 +1,3 @@
+-- Drop the dead-letter queue and retry policies
+DROP TABLE IF EXISTS flow_dead_letters;
+ALTER TABLE flows DROP COLUMN IF EXISTS retry_policy;
@@ -0,0 +1,20 @@
+-- Automatic retry policy of failed executions, per flow
+ALTER TABLE flows ADD COLUMN retry_policy JSONB;
+
+-- Failed executions kept for retries
+CREATE TABLE IF NOT EXISTS flow_dead_letters (
+    id TEXT PRIMARY KEY,
+    flow_id TEXT NOT NULL,
+    zone_id TEXT NOT NULL,
+    execution_id TEXT NOT NULL,
+    input JSONB,
+    error TEXT NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 1,
+    status TEXT NOT NULL,
+    next_retry_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_flow_dead_letters_zone_id ON flow_dead_letters(zone_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_flow_dead_letters_due ON flow_dead_letters(next_retry_at) WHERE status = 'scheduled';
@@ -0,0 +1,2 @@
+-- Drop recorded event replays
+DROP TABLE IF EXISTS replay_attempts;
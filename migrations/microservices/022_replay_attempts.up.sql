@@ -0,0 +1,15 @@
+-- Record event replays so repeated replays can be deduplicated
+CREATE TABLE IF NOT EXISTS replay_attempts (
+    id TEXT PRIMARY KEY,
+    zone_id TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    idempotency_key TEXT NOT NULL DEFAULT '',
+    job_id TEXT NOT NULL DEFAULT '',
+    replayed_event_id TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_replay_attempts_event ON replay_attempts(event_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_replay_attempts_dedupe ON replay_attempts(zone_id, event_id, created_at DESC) WHERE status = 'success';
@@ -0,0 +1,2 @@
+-- Drop archived webhook deliveries
+DROP TABLE IF EXISTS webhook_deliveries;
@@ -0,0 +1,22 @@
+-- Requests sent by webhook action nodes that capture deliveries, with their responses
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id TEXT PRIMARY KEY,
+    flow_id TEXT NOT NULL,
+    zone_id TEXT NOT NULL,
+    execution_id TEXT NOT NULL DEFAULT '',
+    node_id TEXT NOT NULL,
+    attempt INT NOT NULL DEFAULT 1,
+    method TEXT NOT NULL,
+    url TEXT NOT NULL,
+    request_headers JSONB,
+    request_body TEXT NOT NULL DEFAULT '',
+    status_code INT NOT NULL DEFAULT 0,
+    response_headers JSONB,
+    response_body TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    latency_ms BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_flow ON webhook_deliveries(flow_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_execution ON webhook_deliveries(execution_id);
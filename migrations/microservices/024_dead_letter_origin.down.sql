@@ -0,0 +1,2 @@
+-- Drop the dead letters' original execution
+ALTER TABLE flow_dead_letters DROP COLUMN IF EXISTS origin_execution_id;
@@ -0,0 +1,3 @@
+-- Execution a dead letter was created for, which its retries derive
+-- idempotency keys from
+ALTER TABLE flow_dead_letters ADD COLUMN IF NOT EXISTS origin_execution_id TEXT NOT NULL DEFAULT '';
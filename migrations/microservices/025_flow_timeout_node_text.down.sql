@@ -0,0 +1,2 @@
+-- Restore the length limit on timeout node IDs
+ALTER TABLE flows ALTER COLUMN on_timeout_node_id TYPE VARCHAR(255);
@@ -0,0 +1,2 @@
+-- Timeout nodes are flow node IDs, which are not limited in length
+ALTER TABLE flows ALTER COLUMN on_timeout_node_id TYPE TEXT;
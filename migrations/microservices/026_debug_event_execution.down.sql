@@ -0,0 +1,2 @@
+-- Drop the debug events' execution
+ALTER TABLE debug_events DROP COLUMN IF EXISTS execution_id;
@@ -0,0 +1,2 @@
+-- Execution a debug event belongs to, which is not its session
+ALTER TABLE debug_events ADD COLUMN IF NOT EXISTS execution_id TEXT NOT NULL DEFAULT '';
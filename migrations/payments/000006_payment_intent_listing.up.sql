@@ -0,0 +1,4 @@
+-- Support filtered, keyset-paginated listing of payment intents
+CREATE INDEX IF NOT EXISTS idx_payment_intents_created_id ON payment_intents(created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_payment_intents_zone_created ON payment_intents(zone_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_payment_intents_user_created ON payment_intents(user_id, created_at DESC, id DESC);
@@ -0,0 +1,15 @@
+-- Track how much of each payment has been refunded
+ALTER TABLE payment_intents ADD COLUMN IF NOT EXISTS amount_refunded BIGINT NOT NULL DEFAULT 0;
+UPDATE payment_intents SET amount_refunded = amount WHERE status = 'refunded';
+
+CREATE TABLE IF NOT EXISTS refunds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_intent_id UUID NOT NULL REFERENCES payment_intents(id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_payment_intent_id ON refunds(payment_intent_id, created_at);
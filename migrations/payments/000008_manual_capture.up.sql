@@ -0,0 +1,9 @@
+-- Authorization holds for manually captured payments
+ALTER TABLE payment_intents ADD COLUMN IF NOT EXISTS capture_method VARCHAR(20) NOT NULL DEFAULT 'automatic';
+ALTER TABLE payment_intents ADD COLUMN IF NOT EXISTS amount_captured BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE payment_intents ADD COLUMN IF NOT EXISTS authorization_id VARCHAR(255);
+ALTER TABLE payment_intents ADD COLUMN IF NOT EXISTS authorization_expires_at TIMESTAMP WITH TIME ZONE;
+UPDATE payment_intents SET amount_captured = amount WHERE status IN ('succeeded', 'partially_refunded', 'refunded');
+
+CREATE INDEX IF NOT EXISTS idx_payment_intents_authorization_expires_at
+    ON payment_intents(authorization_expires_at) WHERE status = 'requires_capture';
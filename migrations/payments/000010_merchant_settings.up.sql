@@ -0,0 +1,6 @@
+-- Per-merchant payment settings, e.g. the currency payments settle in
+CREATE TABLE IF NOT EXISTS merchant_settings (
+    user_id UUID PRIMARY KEY,
+    settlement_currency VARCHAR(3),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,20 @@
+-- Chargebacks of payments, opened by the customer's bank or by hand.
+-- Evidence is stored as metadata; the documents stay with the merchant.
+CREATE TABLE IF NOT EXISTS disputes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_intent_id UUID NOT NULL REFERENCES payment_intents(id),
+    user_id UUID NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    reason TEXT,
+    status VARCHAR(20) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    bank_reference VARCHAR(255),
+    evidence JSONB NOT NULL DEFAULT '[]',
+    evidence_due_by TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_disputes_user_id ON disputes(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_disputes_payment_intent_id ON disputes(payment_intent_id);
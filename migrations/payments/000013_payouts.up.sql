@@ -0,0 +1,29 @@
+-- Settlement batches moving captured payments from merchants' pending
+-- balances to their payable balances
+CREATE TABLE IF NOT EXISTS payout_batches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id VARCHAR(255) NOT NULL,
+    ledger_account VARCHAR(255) NOT NULL,
+    zone_id VARCHAR(255),
+    mode VARCHAR(10),
+    currency VARCHAR(3) NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    payment_count INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    cutoff_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    posted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_payout_batches_user_id ON payout_batches(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_payout_batches_pending ON payout_batches(created_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS payout_items (
+    payout_batch_id UUID NOT NULL REFERENCES payout_batches(id),
+    payment_intent_id UUID NOT NULL REFERENCES payment_intents(id),
+    amount BIGINT NOT NULL,
+    PRIMARY KEY (payout_batch_id, payment_intent_id)
+);
+
+ALTER TABLE payment_intents ADD COLUMN IF NOT EXISTS payout_batch_id UUID REFERENCES payout_batches(id);
+CREATE INDEX IF NOT EXISTS idx_payment_intents_unbatched ON payment_intents(created_at) WHERE payout_batch_id IS NULL;
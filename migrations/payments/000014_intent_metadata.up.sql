@@ -0,0 +1,6 @@
+-- Merchant metadata and the statement descriptor of payment intents
+ALTER TABLE payment_intents ADD COLUMN IF NOT EXISTS statement_descriptor VARCHAR(22);
+ALTER TABLE payment_intents ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+
+-- Serves the list endpoint's metadata[key]=value filters
+CREATE INDEX IF NOT EXISTS idx_payment_intents_metadata ON payment_intents USING GIN (metadata jsonb_path_ops);
@@ -0,0 +1,13 @@
+-- The bank transactions payments and refunds were made with, so refunds go
+-- through the adapter that collected the payment and pending refunds can be
+-- looked up
+ALTER TABLE payment_intents ADD COLUMN IF NOT EXISTS payment_method_type VARCHAR(20);
+ALTER TABLE payment_intents ADD COLUMN IF NOT EXISTS provider_transaction_id VARCHAR(255);
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS provider_refund_id VARCHAR(255);
+
+-- Manual captures kept the bank hold as their transaction
+UPDATE payment_intents SET payment_method_type = 'card', provider_transaction_id = authorization_id
+    WHERE authorization_id IS NOT NULL AND status IN ('succeeded', 'partially_refunded', 'refunded');
+
+CREATE INDEX IF NOT EXISTS idx_refunds_pending ON refunds(created_at)
+    WHERE status = 'pending' AND provider_refund_id IS NOT NULL;
@@ -33,3 +33,14 @@ func HashKey(key, secret string) string {
 func ValidateKeyFormat(key, expectedPrefix string) bool {
 	return strings.HasPrefix(key, expectedPrefix)
 }
+
+// Environment returns the environment a key was issued for, "test" or
+// "live", from its prefix (e.g. sk_live_...), or "" if it has none.
+func Environment(key string) string {
+	for _, env := range []string{"test", "live"} {
+		if strings.HasPrefix(key, "sk_"+env+"_") || strings.HasPrefix(key, "pk_"+env+"_") {
+			return env
+		}
+	}
+	return ""
+}
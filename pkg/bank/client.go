@@ -3,6 +3,7 @@ package bank
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -10,6 +11,7 @@ type Status string
 
 const (
 	StatusSuccess Status = "succeeded"
+	StatusPending Status = "pending"
 	StatusFailed  Status = "failed"
 )
 
@@ -20,9 +22,42 @@ type TransactionResult struct {
 	ErrorCode     string
 }
 
+// PaymentDetails are the raw details of a payment method. They are only
+// passed to the adapter that tokenizes them and are never stored.
+type PaymentDetails struct {
+	Type           string // card, bank_transfer or wallet
+	CardNumber     string
+	ExpMonth       int
+	ExpYear        int
+	CVC            string
+	AccountNumber  string
+	RoutingNumber  string
+	WalletProvider string
+	WalletToken    string
+}
+
+// Provider is the core of a bank or payment processor integration.
+type Provider interface {
+	// Authorize places a hold on the card without moving funds. The
+	// TransactionID of the result identifies the hold.
+	Authorize(ctx context.Context, amount int64, currency, cardToken string) (*TransactionResult, error)
+	// Capture collects up to the held amount and releases the rest
+	Capture(ctx context.Context, authorizationID string, amount int64) (*TransactionResult, error)
+	// Refund returns part or all of a collected transaction
+	Refund(ctx context.Context, transactionID string, amount int64) (*TransactionResult, error)
+	// GetStatus looks up the current state of a transaction
+	GetStatus(ctx context.Context, transactionID string) (*TransactionResult, error)
+}
+
 // Client defines the interface for communicating with a bank.
 type Client interface {
+	Provider
+	// Tokenize exchanges raw payment details for a token that Charge and
+	// Authorize accept in their place
+	Tokenize(ctx context.Context, details PaymentDetails) (string, error)
 	Charge(ctx context.Context, amount int64, currency, cardToken string) (*TransactionResult, error)
+	// Void releases a hold without collecting anything
+	Void(ctx context.Context, authorizationID string) error
 }
 
 // MockClient is a mock implementation of the Bank Client.
@@ -33,11 +68,42 @@ func NewMockClient() *MockClient {
 	return &MockClient{}
 }
 
+// Tokenize simulates a vault. The test card numbers 4242424242424242,
+// 5555555555554444 and 4000000000000002 map to tok_visa, tok_mastercard and
+// tok_declined; other details get a token that always succeeds.
+func (m *MockClient) Tokenize(ctx context.Context, details PaymentDetails) (string, error) {
+	switch details.Type {
+	case "card":
+		switch details.CardNumber {
+		case "4242424242424242":
+			return "tok_visa", nil
+		case "5555555555554444":
+			return "tok_mastercard", nil
+		case "4000000000000002":
+			return "tok_declined", nil
+		}
+		return "tok_card_" + GenerateRandomID(), nil
+	case "bank_transfer":
+		if details.AccountNumber == "" {
+			return "", errors.New("missing account number")
+		}
+		return "tok_bank_" + GenerateRandomID(), nil
+	case "wallet":
+		if details.WalletToken == "" {
+			return "", errors.New("missing wallet token")
+		}
+		return "tok_wallet_" + GenerateRandomID(), nil
+	default:
+		return "", errors.New("unsupported payment method type")
+	}
+}
+
 // Charge simulates a credit card charge.
 // Special logic for testing:
 // - specific amounts can trigger failures.
 // - cardToken "tok_visa" -> success
 // - cardToken "tok_mastercard" -> success
+// - tokens issued by Tokenize -> success
 // - other tokens -> failure
 func (m *MockClient) Charge(ctx context.Context, amount int64, currency, cardToken string) (*TransactionResult, error) {
 	// Simulate network latency
@@ -49,13 +115,14 @@ func (m *MockClient) Charge(ctx context.Context, amount int64, currency, cardTok
 	}
 
 	// Simulation logic
-	switch cardToken {
-	case "tok_visa", "tok_mastercard":
+	switch {
+	case cardToken == "tok_visa", cardToken == "tok_mastercard",
+		strings.HasPrefix(cardToken, "tok_card_"), strings.HasPrefix(cardToken, "tok_bank_"), strings.HasPrefix(cardToken, "tok_wallet_"):
 		return &TransactionResult{
 			TransactionID: "txn_" + GenerateRandomID(),
 			Status:        "succeeded",
 		}, nil
-	case "tok_declined":
+	case cardToken == "tok_declined":
 		return &TransactionResult{
 			Status:    "failed",
 			ErrorCode: "card_declined",
@@ -70,6 +137,62 @@ func (m *MockClient) Charge(ctx context.Context, amount int64, currency, cardTok
 	}
 }
 
+// Authorize simulates a hold, with the same card tokens as Charge
+func (m *MockClient) Authorize(ctx context.Context, amount int64, currency, cardToken string) (*TransactionResult, error) {
+	result, err := m.Charge(ctx, amount, currency, cardToken)
+	if err != nil || result.Status != StatusSuccess {
+		return result, err
+	}
+	result.TransactionID = "auth_" + GenerateRandomID()
+	return result, nil
+}
+
+// Capture simulates collecting a held amount
+func (m *MockClient) Capture(ctx context.Context, authorizationID string, amount int64) (*TransactionResult, error) {
+	time.Sleep(500 * time.Millisecond)
+
+	if authorizationID == "" {
+		return nil, errors.New("missing authorization")
+	}
+	if amount <= 0 {
+		return nil, errors.New("invalid amount")
+	}
+	return &TransactionResult{
+		TransactionID: "txn_" + GenerateRandomID(),
+		Status:        StatusSuccess,
+	}, nil
+}
+
+// Void simulates releasing a hold
+func (m *MockClient) Void(ctx context.Context, authorizationID string) error {
+	if authorizationID == "" {
+		return errors.New("missing authorization")
+	}
+	return nil
+}
+
+// Refund simulates returning funds
+func (m *MockClient) Refund(ctx context.Context, transactionID string, amount int64) (*TransactionResult, error) {
+	if transactionID == "" {
+		return nil, errors.New("missing transaction")
+	}
+	if amount <= 0 {
+		return nil, errors.New("invalid amount")
+	}
+	return &TransactionResult{
+		TransactionID: "re_" + GenerateRandomID(),
+		Status:        StatusSuccess,
+	}, nil
+}
+
+// GetStatus reports every transaction as succeeded
+func (m *MockClient) GetStatus(ctx context.Context, transactionID string) (*TransactionResult, error) {
+	if transactionID == "" {
+		return nil, errors.New("missing transaction")
+	}
+	return &TransactionResult{TransactionID: transactionID, Status: StatusSuccess}, nil
+}
+
 func GenerateRandomID() string {
 	// In real app use crypto/rand or uuid
 	return time.Now().Format("20060102150405")
@@ -0,0 +1,99 @@
+package bank
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config selects and configures the bank provider.
+type Config struct {
+	// Provider is the bank adapter: "mock", "sandbox" or "stripe".
+	Provider string `json:"provider" yaml:"provider"`
+
+	// Stripe configuration (when Provider is "stripe").
+	Stripe StripeConfig `json:"stripe" yaml:"stripe"`
+
+	// Sandbox configuration (when Provider is "sandbox").
+	Sandbox SandboxConfig `json:"sandbox" yaml:"sandbox"`
+}
+
+// LoadFromEnv loads bank configuration from environment variables.
+func LoadFromEnv() (*Config, error) {
+	cfg := &Config{Provider: getEnvOrDefault("BANK_PROVIDER", "mock")}
+
+	switch cfg.Provider {
+	case "stripe":
+		cfg.Stripe = StripeConfig{
+			APIKey:  os.Getenv("STRIPE_API_KEY"),
+			BaseURL: os.Getenv("STRIPE_API_URL"),
+		}
+	case "sandbox":
+		var err error
+		if cfg.Sandbox.DeclineRate, err = parseRate("BANK_SANDBOX_DECLINE_RATE"); err != nil {
+			return nil, err
+		}
+		if cfg.Sandbox.ErrorRate, err = parseRate("BANK_SANDBOX_ERROR_RATE"); err != nil {
+			return nil, err
+		}
+		if cfg.Sandbox.Latency, err = parseDuration("BANK_SANDBOX_LATENCY"); err != nil {
+			return nil, err
+		}
+		if cfg.Sandbox.Jitter, err = parseDuration("BANK_SANDBOX_JITTER"); err != nil {
+			return nil, err
+		}
+		if v := os.Getenv("BANK_SANDBOX_SEED"); v != "" {
+			if cfg.Sandbox.Seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid BANK_SANDBOX_SEED: %w", err)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// NewClientFromConfig creates the configured bank Client.
+func NewClientFromConfig(cfg *Config) (Client, error) {
+	switch cfg.Provider {
+	case "", "mock":
+		return NewMockClient(), nil
+	case "sandbox":
+		return NewSandboxClient(cfg.Sandbox), nil
+	case "stripe":
+		return NewStripeClient(cfg.Stripe)
+	default:
+		return nil, fmt.Errorf("unknown bank provider: %s", cfg.Provider)
+	}
+}
+
+func getEnvOrDefault(key, defaultValue string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return defaultValue
+}
+
+func parseRate(key string) (float64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+	rate, err := strconv.ParseFloat(v, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("invalid %s: must be between 0 and 1", key)
+	}
+	return rate, nil
+}
+
+func parseDuration(key string) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
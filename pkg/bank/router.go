@@ -0,0 +1,39 @@
+package bank
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrNoAdapter = errors.New("no bank adapter for payment method type")
+
+// Router selects the bank adapter that handles each payment method type, so
+// cards, bank transfers and wallets can be processed by different banks.
+type Router struct {
+	mu       sync.RWMutex
+	adapters map[string]Client
+}
+
+// NewRouter creates a Router without adapters.
+func NewRouter() *Router {
+	return &Router{adapters: make(map[string]Client)}
+}
+
+// Register makes client the adapter of a payment method type, replacing any
+// adapter registered before.
+func (r *Router) Register(methodType string, client Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.adapters[methodType] = client
+}
+
+// Adapter returns the adapter of a payment method type.
+func (r *Router) Adapter(methodType string) (Client, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	client, ok := r.adapters[methodType]
+	if !ok {
+		return nil, ErrNoAdapter
+	}
+	return client, nil
+}
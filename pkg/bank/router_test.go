@@ -0,0 +1,35 @@
+package bank
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRouter_Adapter(t *testing.T) {
+	cards := NewMockClient()
+	wallets := NewSandboxClient(SandboxConfig{Seed: 1})
+	router := NewRouter()
+	router.Register("card", cards)
+	router.Register("wallet", NewMockClient())
+	router.Register("wallet", wallets)
+
+	tests := []struct {
+		methodType string
+		want       Client
+		wantErr    error
+	}{
+		{"card", cards, nil},
+		{"wallet", wallets, nil}, // Registered last
+		{"bank_transfer", nil, ErrNoAdapter},
+		{"", nil, ErrNoAdapter},
+	}
+	for _, tt := range tests {
+		got, err := router.Adapter(tt.methodType)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%q: expected error %v, got %v", tt.methodType, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected adapter %T %p, got %T %p", tt.methodType, tt.want, tt.want, got, got)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package bank
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"sync"
+	"time"
+)
+
+// SandboxConfig sets how often and how slowly the sandbox bank fails.
+type SandboxConfig struct {
+	DeclineRate float64       `json:"decline_rate" yaml:"decline_rate"` // Share of charges and holds declined, 0 to 1
+	ErrorRate   float64       `json:"error_rate" yaml:"error_rate"`     // Share of calls failing as if the bank were unreachable, 0 to 1
+	Latency     time.Duration `json:"latency" yaml:"latency"`           // Added to every call
+	Jitter      time.Duration `json:"jitter" yaml:"jitter"`             // Random extra latency, up to this much
+	Seed        int64         `json:"seed" yaml:"seed"`                 // Makes failures reproducible; 0 seeds from the clock
+}
+
+var ErrSandboxUnavailable = errors.New("sandbox bank unavailable")
+
+// SandboxClient is an in-memory bank that keeps the transactions it makes,
+// so captures, refunds and status lookups behave like a real bank's, and
+// fails at configurable rates to exercise error handling.
+type SandboxClient struct {
+	cfg SandboxConfig
+
+	mu           sync.Mutex
+	rnd          *mrand.Rand
+	transactions map[string]*sandboxTransaction
+}
+
+type sandboxTransaction struct {
+	amount   int64 // Held or collected
+	refunded int64
+	status   Status
+	hold     bool // Authorized but not captured
+	refund   bool // Returns funds of another transaction
+}
+
+// NewSandboxClient creates a sandbox bank.
+func NewSandboxClient(cfg SandboxConfig) *SandboxClient {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &SandboxClient{
+		cfg:          cfg,
+		rnd:          mrand.New(mrand.NewSource(seed)),
+		transactions: make(map[string]*sandboxTransaction),
+	}
+}
+
+func (s *SandboxClient) Tokenize(ctx context.Context, details PaymentDetails) (string, error) {
+	if err := s.simulate(ctx); err != nil {
+		return "", err
+	}
+	switch details.Type {
+	case "card", "bank_transfer", "wallet":
+		return "tok_sandbox_" + sandboxID(), nil
+	default:
+		return "", fmt.Errorf("unsupported payment method type %s", details.Type)
+	}
+}
+
+func (s *SandboxClient) Charge(ctx context.Context, amount int64, currency, cardToken string) (*TransactionResult, error) {
+	return s.open(ctx, amount, "txn_", false)
+}
+
+func (s *SandboxClient) Authorize(ctx context.Context, amount int64, currency, cardToken string) (*TransactionResult, error) {
+	return s.open(ctx, amount, "auth_", true)
+}
+
+func (s *SandboxClient) Capture(ctx context.Context, authorizationID string, amount int64) (*TransactionResult, error) {
+	if err := s.simulate(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.transactions[authorizationID]
+	if !ok || !t.hold {
+		return nil, fmt.Errorf("no open hold %s", authorizationID)
+	}
+	if amount <= 0 || amount > t.amount {
+		return nil, errors.New("invalid amount")
+	}
+	t.amount = amount
+	t.hold = false
+	return &TransactionResult{TransactionID: authorizationID, Status: StatusSuccess}, nil
+}
+
+func (s *SandboxClient) Void(ctx context.Context, authorizationID string) error {
+	if err := s.simulate(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.transactions[authorizationID]
+	if !ok || !t.hold {
+		return fmt.Errorf("no open hold %s", authorizationID)
+	}
+	t.hold = false
+	t.status = StatusFailed
+	return nil
+}
+
+func (s *SandboxClient) Refund(ctx context.Context, transactionID string, amount int64) (*TransactionResult, error) {
+	if err := s.simulate(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.transactions[transactionID]
+	if !ok || t.hold || t.refund || t.status != StatusSuccess {
+		return nil, fmt.Errorf("no collected transaction %s", transactionID)
+	}
+	if amount <= 0 || t.refunded+amount > t.amount {
+		return nil, errors.New("invalid amount")
+	}
+	t.refunded += amount
+	id := "re_" + sandboxID()
+	s.transactions[id] = &sandboxTransaction{amount: amount, status: StatusSuccess, refund: true}
+	return &TransactionResult{TransactionID: id, Status: StatusSuccess}, nil
+}
+
+func (s *SandboxClient) GetStatus(ctx context.Context, transactionID string) (*TransactionResult, error) {
+	if err := s.simulate(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.transactions[transactionID]
+	if !ok {
+		return nil, fmt.Errorf("unknown transaction %s", transactionID)
+	}
+	return &TransactionResult{TransactionID: transactionID, Status: t.status}, nil
+}
+
+// open starts a charge or hold, declining it at the configured rate
+func (s *SandboxClient) open(ctx context.Context, amount int64, prefix string, hold bool) (*TransactionResult, error) {
+	if err := s.simulate(ctx); err != nil {
+		return nil, err
+	}
+	if amount <= 0 {
+		return nil, errors.New("invalid amount")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := prefix + sandboxID()
+	if s.rnd.Float64() < s.cfg.DeclineRate {
+		s.transactions[id] = &sandboxTransaction{amount: amount, status: StatusFailed}
+		return &TransactionResult{TransactionID: id, Status: StatusFailed, ErrorCode: "card_declined"}, nil
+	}
+	s.transactions[id] = &sandboxTransaction{amount: amount, status: StatusSuccess, hold: hold}
+	return &TransactionResult{TransactionID: id, Status: StatusSuccess}, nil
+}
+
+// simulate waits out the configured latency and fails at the error rate
+func (s *SandboxClient) simulate(ctx context.Context) error {
+	s.mu.Lock()
+	delay := s.cfg.Latency
+	if s.cfg.Jitter > 0 {
+		delay += time.Duration(s.rnd.Int63n(int64(s.cfg.Jitter)))
+	}
+	fail := s.rnd.Float64() < s.cfg.ErrorRate
+	s.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if fail {
+		return ErrSandboxUnavailable
+	}
+	return nil
+}
+
+func sandboxID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,131 @@
+package bank
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSandboxClient_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := NewSandboxClient(SandboxConfig{Seed: 1})
+
+	hold, err := s.Authorize(ctx, 1000, "USD", "tok_sandbox_1")
+	if err != nil || hold.Status != StatusSuccess {
+		t.Fatalf("Expected the hold placed, got %+v, %v", hold, err)
+	}
+	if _, err := s.Refund(ctx, hold.TransactionID, 100); err == nil {
+		t.Error("Expected a hold not to be refundable before capture")
+	}
+	if _, err := s.Capture(ctx, hold.TransactionID, 1500); err == nil {
+		t.Error("Expected a capture over the hold to fail")
+	}
+
+	captured, err := s.Capture(ctx, hold.TransactionID, 600)
+	if err != nil || captured.Status != StatusSuccess {
+		t.Fatalf("Expected the capture to succeed, got %+v, %v", captured, err)
+	}
+	if _, err := s.Capture(ctx, hold.TransactionID, 100); err == nil {
+		t.Error("Expected a second capture to fail")
+	}
+
+	refund, err := s.Refund(ctx, captured.TransactionID, 400)
+	if err != nil || refund.Status != StatusSuccess {
+		t.Fatalf("Expected the refund to succeed, got %+v, %v", refund, err)
+	}
+	if _, err := s.Refund(ctx, captured.TransactionID, 201); err == nil {
+		t.Error("Expected refunds over the captured amount to fail")
+	}
+	if _, err := s.Refund(ctx, refund.TransactionID, 100); err == nil {
+		t.Error("Expected a refund not to be refundable")
+	}
+
+	for _, id := range []string{captured.TransactionID, refund.TransactionID} {
+		status, err := s.GetStatus(ctx, id)
+		if err != nil || status.Status != StatusSuccess {
+			t.Errorf("Expected %s to have succeeded, got %+v, %v", id, status, err)
+		}
+	}
+	if _, err := s.GetStatus(ctx, "txn_unknown"); err == nil {
+		t.Error("Expected an unknown transaction to fail")
+	}
+
+	// A voided hold is released and its status failed
+	voided, _ := s.Authorize(ctx, 500, "USD", "tok_sandbox_1")
+	if err := s.Void(ctx, voided.TransactionID); err != nil {
+		t.Fatalf("Void failed: %v", err)
+	}
+	if status, _ := s.GetStatus(ctx, voided.TransactionID); status.Status != StatusFailed {
+		t.Errorf("Expected the voided hold to be failed, got %s", status.Status)
+	}
+}
+
+func TestSandboxClient_FailureRates(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		cfg        SandboxConfig
+		wantErr    error
+		wantStatus Status
+	}{
+		{"No failures", SandboxConfig{Seed: 1}, nil, StatusSuccess},
+		{"Every charge declined", SandboxConfig{DeclineRate: 1, Seed: 1}, nil, StatusFailed},
+		{"Bank unreachable", SandboxConfig{ErrorRate: 1, Seed: 1}, ErrSandboxUnavailable, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSandboxClient(tt.cfg)
+			result, err := s.Charge(ctx, 1000, "USD", "tok_sandbox_1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if result.Status != tt.wantStatus {
+				t.Errorf("Expected status %s, got %s", tt.wantStatus, result.Status)
+			}
+			if tt.wantStatus == StatusFailed && result.ErrorCode != "card_declined" {
+				t.Errorf("Expected a card_declined code, got %q", result.ErrorCode)
+			}
+		})
+	}
+
+	t.Run("Same seed, same declines", func(t *testing.T) {
+		outcomes := func() []Status {
+			s := NewSandboxClient(SandboxConfig{DeclineRate: 0.5, Seed: 42})
+			var statuses []Status
+			for i := 0; i < 20; i++ {
+				result, _ := s.Charge(ctx, 100, "USD", "tok_sandbox_1")
+				statuses = append(statuses, result.Status)
+			}
+			return statuses
+		}
+		first, second := outcomes(), outcomes()
+		for i := range first {
+			if first[i] != second[i] {
+				t.Fatalf("Expected the same outcomes for the same seed, got %v and %v", first, second)
+			}
+		}
+	})
+}
+
+func TestSandboxClient_Latency(t *testing.T) {
+	s := NewSandboxClient(SandboxConfig{Latency: 20 * time.Millisecond, Seed: 1})
+
+	start := time.Now()
+	if _, err := s.Charge(context.Background(), 1000, "USD", "tok_sandbox_1"); err != nil {
+		t.Fatalf("Charge failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected at least 20ms of latency, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Charge(ctx, 1000, "USD", "tok_sandbox_1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled call to give up, got %v", err)
+	}
+}
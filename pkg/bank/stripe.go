@@ -0,0 +1,215 @@
+package bank
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StripeConfig configures the Stripe adapter.
+type StripeConfig struct {
+	APIKey  string        `json:"api_key" yaml:"api_key"`
+	BaseURL string        `json:"base_url" yaml:"base_url"` // Default: https://api.stripe.com
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`   // Default: 30s
+}
+
+// StripeClient talks to the Stripe API, or any API that follows its
+// payment intent, payment method and refund resources.
+type StripeClient struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+// stripeObject holds the fields of Stripe resources the adapter reads
+type stripeObject struct {
+	ID               string       `json:"id"`
+	Status           string       `json:"status"`
+	LastPaymentError *stripeError `json:"last_payment_error"`
+	Error            *stripeError `json:"error"`
+}
+
+type stripeError struct {
+	Type        string `json:"type"`
+	Code        string `json:"code"`
+	DeclineCode string `json:"decline_code"`
+	Message     string `json:"message"`
+}
+
+// NewStripeClient creates a Stripe adapter.
+func NewStripeClient(cfg StripeConfig) (*StripeClient, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("stripe api key is required")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.stripe.com"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return &StripeClient{
+		apiKey:  cfg.APIKey,
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		client:  &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Tokenize creates a Stripe payment method from raw details.
+func (c *StripeClient) Tokenize(ctx context.Context, details PaymentDetails) (string, error) {
+	form := url.Values{}
+	switch details.Type {
+	case "card":
+		form.Set("type", "card")
+		form.Set("card[number]", details.CardNumber)
+		form.Set("card[exp_month]", strconv.Itoa(details.ExpMonth))
+		form.Set("card[exp_year]", strconv.Itoa(details.ExpYear))
+		if details.CVC != "" {
+			form.Set("card[cvc]", details.CVC)
+		}
+	case "bank_transfer":
+		form.Set("type", "us_bank_account")
+		form.Set("us_bank_account[account_number]", details.AccountNumber)
+		form.Set("us_bank_account[routing_number]", details.RoutingNumber)
+	case "wallet":
+		// Wallet tokens are card tokens issued by the wallet provider
+		form.Set("type", "card")
+		form.Set("card[token]", details.WalletToken)
+	default:
+		return "", fmt.Errorf("unsupported payment method type %s", details.Type)
+	}
+
+	obj, err := c.do(ctx, http.MethodPost, "/v1/payment_methods", form)
+	if err != nil {
+		return "", err
+	}
+	return obj.ID, nil
+}
+
+// Charge creates and confirms a payment intent that is captured at once.
+func (c *StripeClient) Charge(ctx context.Context, amount int64, currency, cardToken string) (*TransactionResult, error) {
+	return c.createPaymentIntent(ctx, amount, currency, cardToken, "automatic")
+}
+
+// Authorize creates and confirms a payment intent that is captured later.
+func (c *StripeClient) Authorize(ctx context.Context, amount int64, currency, cardToken string) (*TransactionResult, error) {
+	return c.createPaymentIntent(ctx, amount, currency, cardToken, "manual")
+}
+
+func (c *StripeClient) Capture(ctx context.Context, authorizationID string, amount int64) (*TransactionResult, error) {
+	form := url.Values{}
+	form.Set("amount_to_capture", strconv.FormatInt(amount, 10))
+	obj, err := c.do(ctx, http.MethodPost, "/v1/payment_intents/"+url.PathEscape(authorizationID)+"/capture", form)
+	if err != nil {
+		return nil, err
+	}
+	return stripeResult(obj), nil
+}
+
+func (c *StripeClient) Void(ctx context.Context, authorizationID string) error {
+	_, err := c.do(ctx, http.MethodPost, "/v1/payment_intents/"+url.PathEscape(authorizationID)+"/cancel", url.Values{})
+	return err
+}
+
+func (c *StripeClient) Refund(ctx context.Context, transactionID string, amount int64) (*TransactionResult, error) {
+	form := url.Values{}
+	form.Set("payment_intent", transactionID)
+	form.Set("amount", strconv.FormatInt(amount, 10))
+	obj, err := c.do(ctx, http.MethodPost, "/v1/refunds", form)
+	if err != nil {
+		return nil, err
+	}
+	return stripeResult(obj), nil
+}
+
+// GetStatus looks up a payment intent, or a refund for IDs with Stripe's
+// re_ prefix
+func (c *StripeClient) GetStatus(ctx context.Context, transactionID string) (*TransactionResult, error) {
+	resource := "/v1/payment_intents/"
+	if strings.HasPrefix(transactionID, "re_") {
+		resource = "/v1/refunds/"
+	}
+	obj, err := c.do(ctx, http.MethodGet, resource+url.PathEscape(transactionID), nil)
+	if err != nil {
+		return nil, err
+	}
+	return stripeResult(obj), nil
+}
+
+func (c *StripeClient) createPaymentIntent(ctx context.Context, amount int64, currency, cardToken, captureMethod string) (*TransactionResult, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(amount, 10))
+	form.Set("currency", strings.ToLower(currency))
+	form.Set("payment_method", cardToken)
+	form.Set("capture_method", captureMethod)
+	form.Set("confirm", "true")
+	obj, err := c.do(ctx, http.MethodPost, "/v1/payment_intents", form)
+	if err != nil {
+		return nil, err
+	}
+	return stripeResult(obj), nil
+}
+
+// do sends a form-encoded request. Card errors come back as a failed
+// result rather than an error, like the mock bank's declines.
+func (c *StripeClient) do(ctx context.Context, method, path string, form url.Values) (*stripeObject, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("stripe request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var obj stripeObject
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&obj); err != nil {
+		return nil, fmt.Errorf("failed to decode stripe response (status %d): %w", resp.StatusCode, err)
+	}
+	if obj.Error != nil {
+		if obj.Error.Type == "card_error" {
+			return &stripeObject{ID: obj.ID, Status: "failed", LastPaymentError: obj.Error}, nil
+		}
+		return nil, fmt.Errorf("stripe error %s: %s", obj.Error.Code, obj.Error.Message)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("stripe returned status %d", resp.StatusCode)
+	}
+	return &obj, nil
+}
+
+// stripeResult maps a Stripe payment intent or refund to a TransactionResult.
+// An intent that requires capture is a successful authorization.
+func stripeResult(obj *stripeObject) *TransactionResult {
+	result := &TransactionResult{TransactionID: obj.ID}
+	switch obj.Status {
+	case "succeeded", "requires_capture":
+		result.Status = StatusSuccess
+	case "processing", "pending", "requires_action":
+		result.Status = StatusPending
+	default:
+		result.Status = StatusFailed
+	}
+	if obj.LastPaymentError != nil {
+		result.ErrorCode = obj.LastPaymentError.Code
+		if obj.LastPaymentError.DeclineCode != "" {
+			result.ErrorCode = obj.LastPaymentError.DeclineCode
+		}
+	}
+	return result
+}
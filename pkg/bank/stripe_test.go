@@ -0,0 +1,175 @@
+package bank
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stripeServer answers the Stripe API calls of a test with the handler's
+// object and records the requests it got
+func stripeServer(t *testing.T, respond func(r *http.Request) (int, map[string]interface{})) (*StripeClient, *[]*http.Request) {
+	t.Helper()
+	var requests []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Failed to parse form: %v", err)
+		}
+		requests = append(requests, r)
+		status, body := respond(r)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := NewStripeClient(StripeConfig{APIKey: "sk_test_1", BaseURL: srv.URL + "/"})
+	if err != nil {
+		t.Fatalf("NewStripeClient failed: %v", err)
+	}
+	return client, &requests
+}
+
+func TestStripeClient_Requests(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		call       func(c *StripeClient) (*TransactionResult, error)
+		object     map[string]interface{}
+		wantMethod string
+		wantPath   string
+		wantForm   map[string]string
+		want       TransactionResult
+	}{
+		{
+			name:       "Charge",
+			call:       func(c *StripeClient) (*TransactionResult, error) { return c.Charge(ctx, 1000, "USD", "pm_1") },
+			object:     map[string]interface{}{"id": "pi_1", "status": "succeeded"},
+			wantMethod: http.MethodPost,
+			wantPath:   "/v1/payment_intents",
+			wantForm:   map[string]string{"amount": "1000", "currency": "usd", "payment_method": "pm_1", "capture_method": "automatic", "confirm": "true"},
+			want:       TransactionResult{TransactionID: "pi_1", Status: StatusSuccess},
+		},
+		{
+			name:       "Authorize",
+			call:       func(c *StripeClient) (*TransactionResult, error) { return c.Authorize(ctx, 1000, "EUR", "pm_1") },
+			object:     map[string]interface{}{"id": "pi_2", "status": "requires_capture"},
+			wantMethod: http.MethodPost,
+			wantPath:   "/v1/payment_intents",
+			wantForm:   map[string]string{"currency": "eur", "capture_method": "manual"},
+			want:       TransactionResult{TransactionID: "pi_2", Status: StatusSuccess},
+		},
+		{
+			name:       "Capture",
+			call:       func(c *StripeClient) (*TransactionResult, error) { return c.Capture(ctx, "pi_2", 600) },
+			object:     map[string]interface{}{"id": "pi_2", "status": "succeeded"},
+			wantMethod: http.MethodPost,
+			wantPath:   "/v1/payment_intents/pi_2/capture",
+			wantForm:   map[string]string{"amount_to_capture": "600"},
+			want:       TransactionResult{TransactionID: "pi_2", Status: StatusSuccess},
+		},
+		{
+			name:       "Refund",
+			call:       func(c *StripeClient) (*TransactionResult, error) { return c.Refund(ctx, "pi_1", 300) },
+			object:     map[string]interface{}{"id": "re_1", "status": "pending"},
+			wantMethod: http.MethodPost,
+			wantPath:   "/v1/refunds",
+			wantForm:   map[string]string{"payment_intent": "pi_1", "amount": "300"},
+			want:       TransactionResult{TransactionID: "re_1", Status: StatusPending},
+		},
+		{
+			name:       "Payment status",
+			call:       func(c *StripeClient) (*TransactionResult, error) { return c.GetStatus(ctx, "pi_1") },
+			object:     map[string]interface{}{"id": "pi_1", "status": "processing"},
+			wantMethod: http.MethodGet,
+			wantPath:   "/v1/payment_intents/pi_1",
+			want:       TransactionResult{TransactionID: "pi_1", Status: StatusPending},
+		},
+		{
+			name:       "Refund status",
+			call:       func(c *StripeClient) (*TransactionResult, error) { return c.GetStatus(ctx, "re_1") },
+			object:     map[string]interface{}{"id": "re_1", "status": "failed"},
+			wantMethod: http.MethodGet,
+			wantPath:   "/v1/refunds/re_1",
+			want:       TransactionResult{TransactionID: "re_1", Status: StatusFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, requests := stripeServer(t, func(r *http.Request) (int, map[string]interface{}) {
+				return http.StatusOK, tt.object
+			})
+
+			result, err := tt.call(client)
+			if err != nil {
+				t.Fatalf("Call failed: %v", err)
+			}
+			if *result != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, *result)
+			}
+
+			if len(*requests) != 1 {
+				t.Fatalf("Expected one request, got %d", len(*requests))
+			}
+			r := (*requests)[0]
+			if r.Method != tt.wantMethod || r.URL.Path != tt.wantPath {
+				t.Errorf("Expected %s %s, got %s %s", tt.wantMethod, tt.wantPath, r.Method, r.URL.Path)
+			}
+			if r.Header.Get("Authorization") != "Bearer sk_test_1" {
+				t.Errorf("Expected the API key as bearer token, got %q", r.Header.Get("Authorization"))
+			}
+			for key, want := range tt.wantForm {
+				if got := r.PostForm.Get(key); got != want {
+					t.Errorf("Expected %s=%s, got %q", key, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestStripeClient_Errors(t *testing.T) {
+	t.Run("Card error is a failed result", func(t *testing.T) {
+		client, _ := stripeServer(t, func(r *http.Request) (int, map[string]interface{}) {
+			return http.StatusPaymentRequired, map[string]interface{}{"error": map[string]string{
+				"type": "card_error", "code": "card_declined", "decline_code": "insufficient_funds",
+			}}
+		})
+		result, err := client.Charge(context.Background(), 1000, "USD", "pm_1")
+		if err != nil {
+			t.Fatalf("Expected a declined result, got error %v", err)
+		}
+		if result.Status != StatusFailed || result.ErrorCode != "insufficient_funds" {
+			t.Errorf("Expected a failed result with the decline code, got %+v", result)
+		}
+	})
+
+	t.Run("API error", func(t *testing.T) {
+		client, _ := stripeServer(t, func(r *http.Request) (int, map[string]interface{}) {
+			return http.StatusBadRequest, map[string]interface{}{"error": map[string]string{
+				"type": "invalid_request_error", "code": "resource_missing", "message": "No such payment_intent",
+			}}
+		})
+		if _, err := client.Refund(context.Background(), "pi_missing", 100); err == nil {
+			t.Error("Expected an error for an invalid request")
+		}
+	})
+
+	t.Run("Server error without an error object", func(t *testing.T) {
+		client, _ := stripeServer(t, func(r *http.Request) (int, map[string]interface{}) {
+			return http.StatusInternalServerError, map[string]interface{}{}
+		})
+		if _, err := client.GetStatus(context.Background(), "pi_1"); err == nil {
+			t.Error("Expected an error for status 500")
+		}
+	})
+
+	t.Run("API key required", func(t *testing.T) {
+		if _, err := NewStripeClient(StripeConfig{}); err == nil {
+			t.Error("Expected an error without an API key")
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package currency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrRateUnavailable = errors.New("exchange rate unavailable")
+
+// Converter converts amounts between currencies.
+type Converter interface {
+	// Rate returns how many units of to one unit of from is worth
+	Rate(ctx context.Context, from, to string) (float64, error)
+	// Convert converts an amount in the minor units of from to the minor
+	// units of to
+	Convert(ctx context.Context, amount int64, from, to string) (int64, error)
+}
+
+// RateSource fetches current exchange rates, e.g. from a rates provider.
+type RateSource interface {
+	FetchRate(ctx context.Context, from, to string) (float64, error)
+}
+
+// StaticRates is a RateSource of fixed rates against a base currency.
+// Cross rates go through the base.
+type StaticRates struct {
+	Base  string
+	Rates map[string]float64 // Units of each currency per unit of Base
+}
+
+// ParseStaticRates reads rates written as "EUR=0.92,GBP=0.79" against base.
+func ParseStaticRates(base, spec string) (*StaticRates, error) {
+	base, err := Normalize(base)
+	if err != nil {
+		return nil, err
+	}
+	rates := &StaticRates{Base: base, Rates: map[string]float64{base: 1}}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		code, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate %q", pair)
+		}
+		code, err := Normalize(strings.TrimSpace(code))
+		if err != nil {
+			return nil, err
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid rate %q", pair)
+		}
+		rates.Rates[code] = rate
+	}
+	return rates, nil
+}
+
+func (s *StaticRates) FetchRate(ctx context.Context, from, to string) (float64, error) {
+	fromRate, ok := s.Rates[from]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrRateUnavailable, from)
+	}
+	toRate, ok := s.Rates[to]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrRateUnavailable, to)
+	}
+	return toRate / fromRate, nil
+}
+
+// CachedConverter is a Converter that caches the rates of its source in
+// Redis, so every instance of a service converts at the same rate until it
+// expires.
+type CachedConverter struct {
+	source RateSource
+	rdb    *redis.Client // Rates are fetched on every call when nil
+	ttl    time.Duration
+}
+
+// NewCachedConverter creates a converter that caches rates for ttl
+// (default: 1h).
+func NewCachedConverter(source RateSource, rdb *redis.Client, ttl time.Duration) *CachedConverter {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &CachedConverter{source: source, rdb: rdb, ttl: ttl}
+}
+
+func (c *CachedConverter) Rate(ctx context.Context, from, to string) (float64, error) {
+	from, err := Normalize(from)
+	if err != nil {
+		return 0, err
+	}
+	to, err = Normalize(to)
+	if err != nil {
+		return 0, err
+	}
+	if from == to {
+		return 1, nil
+	}
+
+	key := fmt.Sprintf("fx:rate:%s:%s", from, to)
+	if c.rdb != nil {
+		if cached, err := c.rdb.Get(ctx, key).Float64(); err == nil {
+			return cached, nil
+		}
+	}
+
+	rate, err := c.source.FetchRate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	if c.rdb != nil {
+		// A cache failure only costs another fetch
+		c.rdb.Set(ctx, key, strconv.FormatFloat(rate, 'f', -1, 64), c.ttl)
+	}
+	return rate, nil
+}
+
+func (c *CachedConverter) Convert(ctx context.Context, amount int64, from, to string) (int64, error) {
+	rate, err := c.Rate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	fromCurrency, _ := Lookup(from)
+	toCurrency, _ := Lookup(to)
+	return ConvertMinor(amount, fromCurrency, toCurrency, rate), nil
+}
@@ -2,22 +2,79 @@ package currency
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 )
 
-var supportedCurrencies = map[string]bool{
-	"USD": true,
-	"EUR": true,
-	"GBP": true,
-	"JPY": true,
-	"EGP": true,
-	"CAD": true,
-	"AUD": true,
+// Currency is an ISO 4217 currency.
+type Currency struct {
+	Code       string // Alphabetic code, e.g. USD
+	Numeric    string // Numeric code, e.g. 840
+	MinorUnits int    // Digits after the decimal point; amounts are stored in these units
+	Name       string
+}
+
+// registry holds the supported ISO 4217 currencies by code.
+var registry = map[string]Currency{}
+
+func init() {
+	for _, c := range []Currency{
+		{"AED", "784", 2, "UAE Dirham"},
+		{"AUD", "036", 2, "Australian Dollar"},
+		{"BHD", "048", 3, "Bahraini Dinar"},
+		{"BRL", "986", 2, "Brazilian Real"},
+		{"CAD", "124", 2, "Canadian Dollar"},
+		{"CHF", "756", 2, "Swiss Franc"},
+		{"CLP", "152", 0, "Chilean Peso"},
+		{"CNY", "156", 2, "Yuan Renminbi"},
+		{"CZK", "203", 2, "Czech Koruna"},
+		{"DKK", "208", 2, "Danish Krone"},
+		{"EGP", "818", 2, "Egyptian Pound"},
+		{"EUR", "978", 2, "Euro"},
+		{"GBP", "826", 2, "Pound Sterling"},
+		{"HKD", "344", 2, "Hong Kong Dollar"},
+		{"HUF", "348", 2, "Forint"},
+		{"IDR", "360", 2, "Rupiah"},
+		{"ILS", "376", 2, "New Israeli Sheqel"},
+		{"INR", "356", 2, "Indian Rupee"},
+		{"JOD", "400", 3, "Jordanian Dinar"},
+		{"JPY", "392", 0, "Yen"},
+		{"KRW", "410", 0, "Won"},
+		{"KWD", "414", 3, "Kuwaiti Dinar"},
+		{"MAD", "504", 2, "Moroccan Dirham"},
+		{"MXN", "484", 2, "Mexican Peso"},
+		{"NGN", "566", 2, "Naira"},
+		{"NOK", "578", 2, "Norwegian Krone"},
+		{"NZD", "554", 2, "New Zealand Dollar"},
+		{"OMR", "512", 3, "Rial Omani"},
+		{"PHP", "608", 2, "Philippine Peso"},
+		{"PLN", "985", 2, "Zloty"},
+		{"QAR", "634", 2, "Qatari Rial"},
+		{"SAR", "682", 2, "Saudi Riyal"},
+		{"SEK", "752", 2, "Swedish Krona"},
+		{"SGD", "702", 2, "Singapore Dollar"},
+		{"THB", "764", 2, "Baht"},
+		{"TND", "788", 3, "Tunisian Dinar"},
+		{"TRY", "949", 2, "Turkish Lira"},
+		{"USD", "840", 2, "US Dollar"},
+		{"VND", "704", 0, "Dong"},
+		{"ZAR", "710", 2, "Rand"},
+	} {
+		registry[c.Code] = c
+	}
+}
+
+// Lookup returns the currency with the given code, in any case.
+func Lookup(code string) (Currency, bool) {
+	c, ok := registry[strings.ToUpper(code)]
+	return c, ok
 }
 
 // IsSupported checks if the currency code is supported.
 func IsSupported(code string) bool {
-	return supportedCurrencies[strings.ToUpper(code)]
+	_, ok := Lookup(code)
+	return ok
 }
 
 // Validate returns an error if the currency is not supported.
@@ -27,3 +84,68 @@ func Validate(code string) error {
 	}
 	return nil
 }
+
+// Normalize returns the canonical upper-case code of a supported currency.
+func Normalize(code string) (string, error) {
+	c, ok := Lookup(code)
+	if !ok {
+		return "", fmt.Errorf("unsupported currency: %s", code)
+	}
+	return c.Code, nil
+}
+
+// FormatAmount renders an amount in minor units as a decimal string, e.g.
+// 1050 USD as "10.50" and 1050 JPY as "1050".
+func FormatAmount(amount int64, code string) (string, error) {
+	c, ok := Lookup(code)
+	if !ok {
+		return "", fmt.Errorf("unsupported currency: %s", code)
+	}
+	if c.MinorUnits == 0 {
+		return strconv.FormatInt(amount, 10), nil
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	scale := pow10(c.MinorUnits)
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/scale, c.MinorUnits, amount%scale), nil
+}
+
+// ParseAmount converts a decimal string to minor units, rejecting more
+// decimals than the currency has.
+func ParseAmount(value, code string) (int64, error) {
+	c, ok := Lookup(code)
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency: %s", code)
+	}
+
+	whole, frac, _ := strings.Cut(strings.TrimSpace(value), ".")
+	if len(frac) > c.MinorUnits {
+		return 0, fmt.Errorf("%s has %d decimal places", c.Code, c.MinorUnits)
+	}
+	frac += strings.Repeat("0", c.MinorUnits-len(frac))
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", value)
+	}
+	return amount, nil
+}
+
+// ConvertMinor converts an amount in the minor units of one currency to
+// the minor units of another at the given rate, rounding half away from
+// zero.
+func ConvertMinor(amount int64, from, to Currency, rate float64) int64 {
+	scaled := float64(amount) * rate * math.Pow10(to.MinorUnits-from.MinorUnits)
+	return int64(math.Round(scaled))
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -15,6 +15,12 @@ var SecretKey = []byte("super-secret-key-change-me")
 type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
+	// Optional tenant claims for tokens scoped to an organization's zone
+	OrgID  string `json:"org_id,omitempty"`
+	ZoneID string `json:"zone_id,omitempty"`
+	Role   string `json:"role,omitempty"`
+	// The login session of the token, which revoking ends it early
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -35,6 +41,43 @@ func GenerateToken(userID, email string) (string, error) {
 	return token.SignedString(SecretKey)
 }
 
+// SessionTokenTTL is how long the access tokens of login sessions last.
+// They are refreshed with the session's refresh token rather than
+// outliving a revocation for long.
+const SessionTokenTTL = 15 * time.Minute
+
+// GenerateSessionToken creates a short-lived access token for a login
+// session.
+func GenerateSessionToken(userID, email, sessionID string) (string, error) {
+	return GenerateOrgSessionToken(userID, email, sessionID, "", "")
+}
+
+// GenerateOrgSessionToken creates a short-lived access token for a login
+// session acting for an organization, in which the user has the role.
+func GenerateOrgSessionToken(userID, email, sessionID, orgID, role string) (string, error) {
+	claims := &Claims{
+		UserID:    userID,
+		Email:     email,
+		OrgID:     orgID,
+		Role:      role,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(SessionTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "microservices-auth",
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(SecretKey)
+}
+
+// RevokedSessionKey is the Redis key marking a session revoked until its
+// access tokens have expired.
+func RevokedSessionKey(sessionID string) string {
+	return "auth:revoked_session:" + sessionID
+}
+
 // ValidateToken parses and validates a JWT token string.
 // It returns the claims if the token is valid, or an error otherwise.
 func ValidateToken(tokenString string) (*Claims, error) {
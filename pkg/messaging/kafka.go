@@ -8,8 +8,14 @@ import (
 	"github.com/segmentio/kafka-go"
 )
 
+// messageWriter is the part of *kafka.Writer the producer uses
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
 type KafkaProducer struct {
-	writer *kafka.Writer
+	writer messageWriter
 }
 
 func NewKafkaProducer(brokers []string, topic string) *KafkaProducer {
@@ -37,8 +43,17 @@ func (p *KafkaProducer) Close() error {
 	return p.writer.Close()
 }
 
+// messageReader is the part of *kafka.Reader the consumer uses
+type messageReader interface {
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Config() kafka.ReaderConfig
+	Close() error
+}
+
 type KafkaConsumer struct {
-	reader *kafka.Reader
+	reader messageReader
 }
 
 func NewKafkaConsumer(brokers []string, topic, groupID string) *KafkaConsumer {
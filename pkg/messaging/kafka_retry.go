@@ -0,0 +1,222 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// RetryPolicy bounds how many times a message is handled, with exponential
+// backoff between attempts, before it is dead-lettered
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+	}
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxBackoff)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error that retrying cannot fix, such as a
+// malformed message, so the message is dead-lettered straight away
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// DeadLetter is what is published to a dead-letter topic for a message that
+// failed every attempt: the message itself and where and why it failed
+type DeadLetter struct {
+	Topic         string    `json:"topic"`
+	Partition     int       `json:"partition"`
+	Offset        int64     `json:"offset"`
+	ConsumerGroup string    `json:"consumer_group"`
+	Key           string    `json:"key"`
+	Value         []byte    `json:"value"`
+	Error         string    `json:"error"`
+	Attempts      int       `json:"attempts"`
+	FailedAt      time.Time `json:"failed_at"`
+}
+
+// ConsumeWithDLQ handles messages one at a time, retrying failures per the
+// policy and publishing messages that still fail to dlq. Offsets are only
+// committed once a message is handled or dead-lettered, so a message is
+// never lost and a poison message never blocks its partition for long.
+// onDeadLetter, when given, is called for every dead-lettered message.
+func (c *KafkaConsumer) ConsumeWithDLQ(ctx context.Context, policy RetryPolicy, dlq *KafkaProducer, handler func(key string, value []byte) error, onDeadLetter func(DeadLetter)) {
+	config := c.reader.Config()
+	for {
+		m, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("error while fetching message from kafka: %v", err)
+			continue
+		}
+
+		attempts, err := handleWithRetry(ctx, policy, m, handler)
+		if ctx.Err() != nil {
+			// Left uncommitted, the message is redelivered
+			return
+		}
+		if err != nil {
+			letter := DeadLetter{
+				Topic:         m.Topic,
+				Partition:     m.Partition,
+				Offset:        m.Offset,
+				ConsumerGroup: config.GroupID,
+				Key:           string(m.Key),
+				Value:         m.Value,
+				Error:         err.Error(),
+				Attempts:      attempts,
+				FailedAt:      time.Now().UTC(),
+			}
+			if !publishDeadLetter(ctx, policy, dlq, letter) {
+				return
+			}
+			if onDeadLetter != nil {
+				onDeadLetter(letter)
+			}
+		}
+
+		if err := c.reader.CommitMessages(ctx, m); err != nil {
+			log.Printf("failed to commit offset %d of %s/%d: %v", m.Offset, m.Topic, m.Partition, err)
+		}
+	}
+}
+
+func handleWithRetry(ctx context.Context, policy RetryPolicy, m kafka.Message, handler func(key string, value []byte) error) (int, error) {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = handler(string(m.Key), m.Value); err == nil {
+			return attempt, nil
+		}
+		if IsPermanent(err) || attempt >= policy.MaxAttempts {
+			return attempt, err
+		}
+
+		log.Printf("error handling message at offset %d of %s/%d (attempt %d/%d): %v", m.Offset, m.Topic, m.Partition, attempt, policy.MaxAttempts, err)
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(policy.backoff(attempt)):
+		}
+	}
+}
+
+// publishDeadLetter publishes the letter until it succeeds, reporting false
+// if the context ends first
+func publishDeadLetter(ctx context.Context, policy RetryPolicy, dlq *KafkaProducer, letter DeadLetter) bool {
+	payload, err := json.Marshal(letter)
+	if err != nil {
+		log.Printf("failed to encode dead letter for offset %d of %s/%d: %v", letter.Offset, letter.Topic, letter.Partition, err)
+		return false
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := dlq.Publish(ctx, letter.Key, payload)
+		if err == nil {
+			log.Printf("dead-lettered message at offset %d of %s/%d after %d attempts: %s", letter.Offset, letter.Topic, letter.Partition, letter.Attempts, letter.Error)
+			return true
+		}
+		log.Printf("failed to dead-letter message at offset %d of %s/%d: %v", letter.Offset, letter.Topic, letter.Partition, err)
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(policy.backoff(attempt)):
+		}
+	}
+}
+
+// ConsumerLag returns how many messages of the topic the consumer group has
+// yet to commit, e.g. the depth of a dead-letter topic for the group
+// requeueing it
+func ConsumerLag(ctx context.Context, brokers []string, topic, groupID string) (int64, error) {
+	client := &kafka.Client{Addr: kafka.TCP(brokers...)}
+
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get metadata of %s: %w", topic, err)
+	}
+	var partitions []int
+	for _, t := range meta.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return 0, fmt.Errorf("failed to get metadata of %s: %w", topic, t.Error)
+		}
+		for _, p := range t.Partitions {
+			partitions = append(partitions, p.ID)
+		}
+	}
+	if len(partitions) == 0 {
+		return 0, nil
+	}
+
+	requests := make([]kafka.OffsetRequest, 0, 2*len(partitions))
+	for _, p := range partitions {
+		requests = append(requests, kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p))
+	}
+	offsets, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: requests}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list offsets of %s: %w", topic, err)
+	}
+	committed, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: groupID, Topics: map[string][]int{topic: partitions}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch offsets of %s for %s: %w", topic, groupID, err)
+	}
+	if committed.Error != nil {
+		return 0, fmt.Errorf("failed to fetch offsets of %s for %s: %w", topic, groupID, committed.Error)
+	}
+
+	committedOffsets := map[int]int64{}
+	for _, p := range committed.Topics[topic] {
+		committedOffsets[p.Partition] = p.CommittedOffset
+	}
+
+	var lag int64
+	for _, p := range offsets.Topics[topic] {
+		if p.Error != nil {
+			return 0, fmt.Errorf("failed to list offsets of %s/%d: %w", topic, p.Partition, p.Error)
+		}
+		// Without a commit the group starts from the first offset
+		from := max(committedOffsets[p.Partition], p.FirstOffset)
+		lag += max(p.LastOffset-from, 0)
+	}
+	return lag, nil
+}
@@ -0,0 +1,215 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeReader hands out its messages in order and records the offsets
+// committed. Once out of messages it calls done, so a test can end the
+// consumer there.
+type fakeReader struct {
+	messages  []kafka.Message
+	committed []int64
+	done      func()
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.messages) == 0 {
+		r.done()
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	m := r.messages[0]
+	r.messages = r.messages[1:]
+	return m, nil
+}
+
+func (r *fakeReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	return r.FetchMessage(ctx)
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, m := range msgs {
+		r.committed = append(r.committed, m.Offset)
+	}
+	return nil
+}
+
+func (r *fakeReader) Config() kafka.ReaderConfig {
+	return kafka.ReaderConfig{GroupID: "ledger-service"}
+}
+
+func (r *fakeReader) Close() error { return nil }
+
+// fakeWriter records the messages written, failing with err while it is set
+type fakeWriter struct {
+	written []kafka.Message
+	calls   int
+	err     error
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.calls++
+	if w.err != nil {
+		return w.err
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+var testPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		if got := policy.backoff(attempt); got != want {
+			t.Errorf("Attempt %d: expected %s, got %s", attempt, want, got)
+		}
+	}
+}
+
+func TestHandleWithRetry(t *testing.T) {
+	errTransient := errors.New("ledger unavailable")
+
+	tests := []struct {
+		name         string
+		failures     int // Calls failing before the handler succeeds
+		err          error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"Handled first time", 0, errTransient, 1, false},
+		{"Handled on a retry", 2, errTransient, 3, false},
+		{"Retries exhausted", 10, errTransient, 3, true},
+		{"Permanent error", 10, Permanent(errTransient), 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			handler := func(key string, value []byte) error {
+				calls++
+				if calls <= tt.failures {
+					return tt.err
+				}
+				return nil
+			}
+
+			attempts, err := handleWithRetry(context.Background(), testPolicy, kafka.Message{Key: []byte("pi_1")}, handler)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if attempts != tt.wantAttempts || calls != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d with %d calls", tt.wantAttempts, attempts, calls)
+			}
+		})
+	}
+
+	t.Run("Cancelled while backing off", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+		attempts, err := handleWithRetry(ctx, policy, kafka.Message{}, func(key string, value []byte) error { return errTransient })
+		if attempts != 1 || !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected to give up after 1 attempt, got %d, %v", attempts, err)
+		}
+	})
+}
+
+func TestConsumeWithDLQ(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader := &fakeReader{
+		messages: []kafka.Message{
+			{Topic: "payments", Partition: 1, Offset: 10, Key: []byte("pi_1"), Value: []byte("ok")},
+			{Topic: "payments", Partition: 1, Offset: 11, Key: []byte("pi_2"), Value: []byte("malformed")},
+			{Topic: "payments", Partition: 1, Offset: 12, Key: []byte("pi_3"), Value: []byte("unavailable")},
+		},
+		done: cancel,
+	}
+	dlq := &fakeWriter{}
+	consumer := &KafkaConsumer{reader: reader}
+
+	calls := map[string]int{}
+	handler := func(key string, value []byte) error {
+		calls[key]++
+		switch string(value) {
+		case "malformed":
+			return Permanent(errors.New("invalid payload"))
+		case "unavailable":
+			return errors.New("ledger unavailable")
+		}
+		return nil
+	}
+	var letters []DeadLetter
+	consumer.ConsumeWithDLQ(ctx, testPolicy, &KafkaProducer{writer: dlq}, handler, func(l DeadLetter) {
+		letters = append(letters, l)
+	})
+
+	if calls["pi_1"] != 1 || calls["pi_2"] != 1 || calls["pi_3"] != testPolicy.MaxAttempts {
+		t.Errorf("Expected the permanent failure tried once and the other %d times, got %v", testPolicy.MaxAttempts, calls)
+	}
+	if len(reader.committed) != 3 {
+		t.Fatalf("Expected every message committed, got offsets %v", reader.committed)
+	}
+	if len(dlq.written) != 2 || len(letters) != 2 {
+		t.Fatalf("Expected 2 dead letters, got %d published and %d reported", len(dlq.written), len(letters))
+	}
+
+	var letter DeadLetter
+	if err := json.Unmarshal(dlq.written[1].Value, &letter); err != nil {
+		t.Fatalf("Failed to decode dead letter: %v", err)
+	}
+	if string(dlq.written[1].Key) != "pi_3" || letter.Key != "pi_3" || string(letter.Value) != "unavailable" {
+		t.Errorf("Expected the dead letter to carry the message, got key %s and %+v", dlq.written[1].Key, letter)
+	}
+	if letter.Topic != "payments" || letter.Partition != 1 || letter.Offset != 12 || letter.ConsumerGroup != "ledger-service" {
+		t.Errorf("Expected where the message failed, got %+v", letter)
+	}
+	if letter.Attempts != testPolicy.MaxAttempts || letter.Error != "ledger unavailable" || letter.FailedAt.IsZero() {
+		t.Errorf("Expected why and when the message failed, got %+v", letter)
+	}
+	if letters[0].Attempts != 1 || letters[0].Offset != 11 {
+		t.Errorf("Expected the permanent failure dead-lettered after 1 attempt, got %+v", letters[0])
+	}
+}
+
+func TestConsumeWithDLQ_FailedDeadLetter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	reader := &fakeReader{
+		messages: []kafka.Message{{Topic: "payments", Offset: 10, Key: []byte("pi_1"), Value: []byte("malformed")}},
+		done:     func() { t.Error("Expected the consumer to stop at the message it could not dead-letter") },
+	}
+	dlq := &fakeWriter{err: errors.New("broker unavailable")}
+	consumer := &KafkaConsumer{reader: reader}
+
+	reported := false
+	consumer.ConsumeWithDLQ(ctx, testPolicy, &KafkaProducer{writer: dlq},
+		func(key string, value []byte) error { return Permanent(errors.New("invalid payload")) },
+		func(DeadLetter) { reported = true })
+
+	if len(reader.committed) != 0 {
+		t.Errorf("Expected the offset left uncommitted, got %v", reader.committed)
+	}
+	if dlq.calls < 2 {
+		t.Errorf("Expected the dead letter retried until the consumer stopped, got %d attempts", dlq.calls)
+	}
+	if reported {
+		t.Error("Expected no dead letter reported")
+	}
+}
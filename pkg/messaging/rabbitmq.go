@@ -3,6 +3,7 @@ package messaging
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -362,16 +363,7 @@ func (r *RabbitMQClient) ConsumeWithContext(ctx context.Context, queueName strin
 					// Channel closed (likely connection lost)
 					goto Reconnect
 				}
-				if err := handler(d.Body); err != nil {
-					log.Printf("error handling message: %v", err)
-					if nackErr := d.Nack(false, true); nackErr != nil {
-						log.Printf("failed to nack message: %v", nackErr)
-					}
-				} else {
-					if ackErr := d.Ack(false); ackErr != nil {
-						log.Printf("failed to ack message: %v", ackErr)
-					}
-				}
+				handleDelivery(d, handler)
 			}
 		}
 
@@ -381,6 +373,125 @@ func (r *RabbitMQClient) ConsumeWithContext(ctx context.Context, queueName strin
 	}
 }
 
+// ConsumeOptions bounds the messages a consumer works on at once
+type ConsumeOptions struct {
+	Concurrency int // Handlers running at once, 1 by default
+	Prefetch    int // Unacked messages held by the consumer, Concurrency by default
+}
+
+// ConsumeConcurrently runs up to opts.Concurrency handlers at once on a
+// channel of its own. When ctx is done it stops taking messages and returns
+// once the handlers in flight have acked theirs; messages prefetched but not
+// handled go back to the queue.
+func (r *RabbitMQClient) ConsumeConcurrently(ctx context.Context, queueName string, opts ConsumeOptions, handler func(body []byte) error) error {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Prefetch < opts.Concurrency {
+		opts.Prefetch = opts.Concurrency
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		ch, err := r.consumerChannel()
+		if err != nil {
+			time.Sleep(time.Second) // Wait for reconnection
+			continue
+		}
+		if err := ch.Qos(opts.Prefetch, 0, false); err != nil {
+			log.Printf("failed to set prefetch of %s: %v", queueName, err)
+			closeConsumerChannel(ch)
+			time.Sleep(2 * time.Second)
+			continue
+		}
+		msgs, err := ch.Consume(
+			queueName, // queue
+			"",        // consumer
+			false,     // auto-ack
+			false,     // exclusive
+			false,     // no-local
+			false,     // no-wait
+			nil,       // args
+		)
+		if err != nil {
+			log.Printf("failed to register a consumer: %v", err)
+			closeConsumerChannel(ch)
+			time.Sleep(2 * time.Second)
+			continue
+		}
+
+		serveDeliveries(ctx, msgs, opts.Concurrency, handler)
+
+		// Closing the channel requeues the messages it still holds
+		closeConsumerChannel(ch)
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("Consumer channel closed for %s, waiting for reconnection...", queueName)
+		time.Sleep(r.config.ReconnectDelay)
+	}
+}
+
+// serveDeliveries handles msgs with up to concurrency handlers at once. It
+// returns when ctx is done or msgs is closed, once the handlers in flight
+// have acked their messages.
+func serveDeliveries(ctx context.Context, msgs <-chan amqp.Delivery, concurrency int, handler func(body []byte) error) {
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				select {
+				case <-ctx.Done():
+				case d, ok := <-msgs:
+					if !ok {
+						return
+					}
+					handleDelivery(d, handler)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// consumerChannel opens a channel on the current connection, so that a
+// consumer's prefetch does not apply to the others
+func (r *RabbitMQClient) consumerChannel() (*amqp.Channel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.isReconnecting || r.conn == nil || r.conn.IsClosed() {
+		return nil, fmt.Errorf("connection is not available")
+	}
+	return r.conn.Channel()
+}
+
+func closeConsumerChannel(ch *amqp.Channel) {
+	if err := ch.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		log.Printf("Failed to close consumer channel: %v", err)
+	}
+}
+
+// handleDelivery acks a message its handler succeeded with and requeues the
+// others
+func handleDelivery(d amqp.Delivery, handler func(body []byte) error) {
+	if err := handler(d.Body); err != nil {
+		log.Printf("error handling message: %v", err)
+		if nackErr := d.Nack(false, true); nackErr != nil {
+			log.Printf("failed to nack message: %v", nackErr)
+		}
+	} else {
+		if ackErr := d.Ack(false); ackErr != nil {
+			log.Printf("failed to ack message: %v", ackErr)
+		}
+	}
+}
+
 func (r *RabbitMQClient) Close() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
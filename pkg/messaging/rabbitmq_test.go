@@ -0,0 +1,118 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// fakeAcknowledger records the delivery tags acked and requeued
+type fakeAcknowledger struct {
+	mu       sync.Mutex
+	acked    []uint64
+	requeued []uint64
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if requeue {
+		a.requeued = append(a.requeued, tag)
+	}
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func (a *fakeAcknowledger) counts() (acked, requeued int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.acked), len(a.requeued)
+}
+
+func deliveries(ack amqp.Acknowledger, bodies ...string) chan amqp.Delivery {
+	msgs := make(chan amqp.Delivery, len(bodies))
+	for i, body := range bodies {
+		msgs <- amqp.Delivery{Acknowledger: ack, DeliveryTag: uint64(i + 1), Body: []byte(body)}
+	}
+	return msgs
+}
+
+func TestServeDeliveries_BoundedConcurrency(t *testing.T) {
+	ack := &fakeAcknowledger{}
+	msgs := deliveries(ack, "1", "2", "3", "4", "5", "fail")
+	close(msgs)
+
+	var running, peak atomic.Int32
+	serveDeliveries(context.Background(), msgs, 2, func(body []byte) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		if string(body) == "fail" {
+			return errors.New("handler failed")
+		}
+		return nil
+	})
+
+	if p := peak.Load(); p != 2 {
+		t.Errorf("Expected at most 2 handlers at once, got %d", p)
+	}
+	if acked, requeued := ack.counts(); acked != 5 || requeued != 1 {
+		t.Errorf("Expected 5 acked and 1 requeued, got %d and %d", acked, requeued)
+	}
+}
+
+func TestServeDeliveries_ShutdownFinishesMessagesInFlight(t *testing.T) {
+	ack := &fakeAcknowledger{}
+	msgs := deliveries(ack, "1", "2", "3")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		serveDeliveries(ctx, msgs, 2, func(body []byte) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		})
+		close(stopped)
+	}()
+	<-started
+	<-started
+
+	cancel()
+	select {
+	case <-stopped:
+		t.Fatal("Expected the consumer to wait for the handlers in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	<-stopped
+	if acked, _ := ack.counts(); acked != 2 {
+		t.Errorf("Expected the 2 messages in flight to be acked, got %d", acked)
+	}
+	if len(msgs) != 1 {
+		t.Errorf("Expected the message not yet taken to be left for the broker, got %d left", len(msgs))
+	}
+}
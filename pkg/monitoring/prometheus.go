@@ -11,6 +11,11 @@ import (
 func StartMetricsServer(addr string) {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
+	// Services without an API of their own are health checked here
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"active"}`))
+	})
 
 	log.Printf("Monitoring server starting on %s", addr)
 	go func() {
@@ -15,6 +15,27 @@ const (
 	StateHalfOpen
 )
 
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	// ErrOpenState is returned while the breaker is open
+	ErrOpenState = errors.New("circuit breaker is open")
+	// ErrTooManyRequests is returned when a half-open breaker has let
+	// through as many trial requests as it allows
+	ErrTooManyRequests = errors.New("too many requests")
+)
+
 // CircuitBreaker prevents cascading failures by stopping requests to failing services.
 type CircuitBreaker struct {
 	name          string
@@ -42,21 +63,23 @@ type Counts struct {
 
 // Settings configures the circuit breaker.
 type Settings struct {
-	Name        string
-	MaxRequests uint32
-	Interval    time.Duration
-	Timeout     time.Duration
-	ReadyToTrip func(counts Counts) bool
+	Name          string
+	MaxRequests   uint32
+	Interval      time.Duration
+	Timeout       time.Duration
+	ReadyToTrip   func(counts Counts) bool
+	OnStateChange func(name string, from State, to State)
 }
 
 // NewCircuitBreaker creates a new circuit breaker.
 func NewCircuitBreaker(st Settings) *CircuitBreaker {
 	cb := &CircuitBreaker{
-		name:        st.Name,
-		maxRequests: st.MaxRequests,
-		interval:    st.Interval,
-		timeout:     st.Timeout,
-		readyToTrip: st.ReadyToTrip,
+		name:          st.Name,
+		maxRequests:   st.MaxRequests,
+		interval:      st.Interval,
+		timeout:       st.Timeout,
+		readyToTrip:   st.ReadyToTrip,
+		onStateChange: st.OnStateChange,
 	}
 
 	if cb.maxRequests == 0 {
@@ -101,6 +124,28 @@ func (cb *CircuitBreaker) Execute(req func() (interface{}, error)) (interface{},
 	return result, err
 }
 
+// Allow reports whether a request may go ahead, for callers that cannot
+// wrap it in Execute, such as a proxy. The returned done must be called
+// with the request's outcome.
+func (cb *CircuitBreaker) Allow() (done func(success bool), err error) {
+	generation, err := cb.beforeRequest()
+	if err != nil {
+		return nil, err
+	}
+	return func(success bool) {
+		cb.afterRequest(generation, success)
+	}, nil
+}
+
+// State returns the current state of the breaker
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	state, _ := cb.currentState(time.Now())
+	return state
+}
+
 func (cb *CircuitBreaker) beforeRequest() (uint64, error) {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
@@ -109,11 +154,11 @@ func (cb *CircuitBreaker) beforeRequest() (uint64, error) {
 	state, generation := cb.currentState(now)
 
 	if state == StateOpen {
-		return generation, errors.New("circuit breaker is open")
+		return generation, ErrOpenState
 	}
 
 	if state == StateHalfOpen && cb.counts.Requests >= cb.maxRequests {
-		return generation, errors.New("too many requests")
+		return generation, ErrTooManyRequests
 	}
 
 	cb.counts.Requests++
@@ -0,0 +1,114 @@
+package resilience
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_Allow(t *testing.T) {
+	var changes []string
+	cb := NewCircuitBreaker(Settings{
+		Name:    "payments",
+		Timeout: 50 * time.Millisecond,
+		ReadyToTrip: func(counts Counts) bool {
+			return counts.ConsecutiveFailures >= 2
+		},
+		OnStateChange: func(name string, from, to State) {
+			changes = append(changes, name+" "+from.String()+" -> "+to.String())
+		},
+	})
+
+	request := func(success bool) error {
+		done, err := cb.Allow()
+		if err != nil {
+			return err
+		}
+		done(success)
+		return nil
+	}
+
+	// A success between failures keeps it closed
+	for _, success := range []bool{false, true, false} {
+		if err := request(success); err != nil {
+			t.Fatalf("Expected the closed breaker to allow requests, got %v", err)
+		}
+	}
+	if cb.State() != StateClosed {
+		t.Fatalf("Expected the breaker closed, got %s", cb.State())
+	}
+
+	if err := request(false); err != nil {
+		t.Fatalf("Expected the request allowed, got %v", err)
+	}
+	if cb.State() != StateOpen {
+		t.Fatalf("Expected the breaker open after 2 failures in a row, got %s", cb.State())
+	}
+	if err := request(true); !errors.Is(err, ErrOpenState) {
+		t.Errorf("Expected the open breaker to fail fast, got %v", err)
+	}
+
+	// After the timeout one trial request goes through at a time
+	time.Sleep(60 * time.Millisecond)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("Expected the breaker half-open after its timeout, got %s", cb.State())
+	}
+	done, err := cb.Allow()
+	if err != nil {
+		t.Fatalf("Expected the trial request allowed, got %v", err)
+	}
+	if _, err := cb.Allow(); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("Expected a second trial request refused, got %v", err)
+	}
+	done(false)
+	if cb.State() != StateOpen {
+		t.Fatalf("Expected a failed trial to open the breaker again, got %s", cb.State())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := request(true); err != nil {
+		t.Fatalf("Expected the trial request allowed, got %v", err)
+	}
+	if cb.State() != StateClosed {
+		t.Errorf("Expected a successful trial to close the breaker, got %s", cb.State())
+	}
+
+	want := []string{
+		"payments closed -> open",
+		"payments open -> half-open",
+		"payments half-open -> open",
+		"payments open -> half-open",
+		"payments half-open -> closed",
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected state changes %v, got %v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("Change %d: expected %q, got %q", i+1, want[i], changes[i])
+		}
+	}
+}
+
+func TestCircuitBreaker_StaleOutcome(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Timeout:     time.Hour,
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures >= 1 },
+	})
+
+	slow, err := cb.Allow()
+	if err != nil {
+		t.Fatalf("Allow failed: %v", err)
+	}
+	fast, _ := cb.Allow()
+	fast(false)
+	if cb.State() != StateOpen {
+		t.Fatalf("Expected the breaker open, got %s", cb.State())
+	}
+
+	// A request started before the breaker opened does not count after
+	slow(true)
+	if cb.State() != StateOpen {
+		t.Errorf("Expected a stale success ignored, got %s", cb.State())
+	}
+}
@@ -0,0 +1,52 @@
+package scopes
+
+import (
+	"net/http"
+
+	"github.com/sapliy/fintech-ecosystem/pkg/jsonutil"
+)
+
+// Header carries the scopes of the caller from the gateway to services. It
+// is only set for scoped credentials, API keys and OAuth tokens; a user's
+// session acts for the user and is not restricted.
+const Header = "X-Scopes"
+
+// ErrorCode identifies insufficient scope errors to clients
+const ErrorCode = "insufficient_scope"
+
+// InsufficientScopeResponse is the body of the 403 answered to calls out of
+// the caller's scopes
+type InsufficientScopeResponse struct {
+	Error         string   `json:"error"`
+	Code          string   `json:"code"`
+	RequiredScope string   `json:"required_scope"`
+	GrantedScopes []string `json:"granted_scopes"`
+}
+
+// WriteInsufficientScope answers a call the granted scopes do not allow
+func WriteInsufficientScope(w http.ResponseWriter, required, granted string) {
+	grantedScopes := ParseScopes(granted)
+	if grantedScopes == nil {
+		grantedScopes = []string{}
+	}
+	jsonutil.WriteJSON(w, http.StatusForbidden, InsufficientScopeResponse{
+		Error:         "Insufficient scope",
+		Code:          ErrorCode,
+		RequiredScope: required,
+		GrantedScopes: grantedScopes,
+	})
+}
+
+// Require rejects requests whose caller was granted scopes by the gateway
+// that do not include scope. Requests without the header are not scoped.
+func Require(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if granted, scoped := r.Header[Header]; scoped && !HasScope(granted[0], scope) {
+				WriteInsufficientScope(w, scope, granted[0])
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -4,6 +4,14 @@ import (
 	"strings"
 )
 
+// Scopes are "resource:action". The actions are ranked: admin implies
+// write, which implies read.
+const (
+	ActionRead  = "read"
+	ActionWrite = "write"
+	ActionAdmin = "admin"
+)
+
 // Predefined API scopes
 const (
 	// Payment scopes
@@ -14,17 +22,71 @@ const (
 	LedgerRead  = "ledger:read"
 	LedgerWrite = "ledger:write"
 
+	// Wallet scopes
+	WalletsRead  = "wallets:read"
+	WalletsWrite = "wallets:write"
+
+	// Billing scopes, for plans and subscriptions
+	BillingRead  = "billing:read"
+	BillingWrite = "billing:write"
+
+	// Event scopes; emitting events is a write
+	EventsRead  = "events:read"
+	EventsWrite = "events:write"
+
+	// Flow scopes; admin covers deleting flows, secrets and debugging
+	FlowsRead  = "flows:read"
+	FlowsWrite = "flows:write"
+	FlowsAdmin = "flows:admin"
+
+	// Notification scopes
+	NotificationsRead  = "notifications:read"
+	NotificationsWrite = "notifications:write"
+
+	// Webhook scopes; admin covers managing webhook endpoints
+	WebhooksRead  = "webhooks:read"
+	WebhooksWrite = "webhooks:write"
+	WebhooksAdmin = "webhooks:admin"
+
+	// Fraud scopes
+	FraudRead  = "fraud:read"
+	FraudWrite = "fraud:write"
+
 	// Wildcard scope (full access)
 	All = "*"
 )
 
-// ValidScopes is the set of all valid scope strings.
+// ValidScopes is the set of all valid scope strings. "resource:*" is valid
+// for each resource too.
 var ValidScopes = map[string]bool{
-	PaymentsRead:  true,
-	PaymentsWrite: true,
-	LedgerRead:    true,
-	LedgerWrite:   true,
-	All:           true,
+	PaymentsRead:       true,
+	PaymentsWrite:      true,
+	LedgerRead:         true,
+	LedgerWrite:        true,
+	WalletsRead:        true,
+	WalletsWrite:       true,
+	BillingRead:        true,
+	BillingWrite:       true,
+	EventsRead:         true,
+	EventsWrite:        true,
+	FlowsRead:          true,
+	FlowsWrite:         true,
+	FlowsAdmin:         true,
+	NotificationsRead:  true,
+	NotificationsWrite: true,
+	WebhooksRead:       true,
+	WebhooksWrite:      true,
+	WebhooksAdmin:      true,
+	FraudRead:          true,
+	FraudWrite:         true,
+	All:                true,
+}
+
+// actionRanks orders the actions, a scope granting those ranked below it
+var actionRanks = map[string]int{
+	ActionRead:  1,
+	ActionWrite: 2,
+	ActionAdmin: 3,
 }
 
 // EndpointScope maps path prefixes and HTTP methods to required scopes.
@@ -34,19 +96,46 @@ type EndpointScope struct {
 	Scope      string
 }
 
-// EndpointScopes defines which scope is required for each endpoint.
-var EndpointScopes = []EndpointScope{
-	// Payments endpoints
-	{PathPrefix: "/payments", Method: "GET", Scope: PaymentsRead},
-	{PathPrefix: "/payments", Method: "POST", Scope: PaymentsWrite},
-	{PathPrefix: "/payments", Method: "PUT", Scope: PaymentsWrite},
-	{PathPrefix: "/payments", Method: "DELETE", Scope: PaymentsWrite},
-
-	// Ledger endpoints
-	{PathPrefix: "/ledger", Method: "GET", Scope: LedgerRead},
-	{PathPrefix: "/ledger", Method: "POST", Scope: LedgerWrite},
-	{PathPrefix: "/ledger", Method: "PUT", Scope: LedgerWrite},
-	{PathPrefix: "/ledger", Method: "DELETE", Scope: LedgerWrite},
+// resource requires resource:read to read the paths under prefix and
+// resource:write for anything else
+func resource(prefix, name string) []EndpointScope {
+	return []EndpointScope{
+		{PathPrefix: prefix, Method: "GET", Scope: name + ":" + ActionRead},
+		{PathPrefix: prefix, Method: "HEAD", Scope: name + ":" + ActionRead},
+		{PathPrefix: prefix, Method: "*", Scope: name + ":" + ActionWrite},
+	}
+}
+
+// EndpointScopes defines which scope is required for each endpoint, by the
+// gateway's paths without /v1. The first match applies, so longer prefixes
+// come first.
+var EndpointScopes = concat(
+	// Partners' webhook endpoints
+	[]EndpointScope{{PathPrefix: "/notifications/webhooks/endpoints", Method: "*", Scope: WebhooksAdmin}},
+	resource("/notifications/webhooks", "webhooks"),
+	resource("/webhooks", "webhooks"),
+	resource("/notifications", "notifications"),
+	resource("/payments", "payments"),
+	resource("/rpc/ledger", "ledger"),
+	resource("/ledger", "ledger"),
+	resource("/wallets", "wallets"),
+	resource("/billing", "billing"),
+	resource("/subscriptions", "billing"),
+	resource("/events", "events"),
+	// Debug sessions run flows step by step
+	[]EndpointScope{{PathPrefix: "/debug", Method: "*", Scope: FlowsAdmin}},
+	resource("/flows", "flows"),
+	resource("/executions", "flows"),
+	resource("/flow-templates", "flows"),
+	resource("/fraud", "fraud"),
+)
+
+func concat(groups ...[]EndpointScope) []EndpointScope {
+	var all []EndpointScope
+	for _, g := range groups {
+		all = append(all, g...)
+	}
+	return all
 }
 
 // GetRequiredScope returns the scope required for a given path and method.
@@ -63,11 +152,13 @@ func GetRequiredScope(path, method string) string {
 }
 
 // HasScope checks if the provided scopes include the required scope.
-// Supports wildcard (*) which grants all permissions.
+// Supports wildcard (*) which grants all permissions, "resource:*" which
+// grants all of a resource's, and higher ranked actions of the resource.
 func HasScope(scopes string, required string) bool {
 	if required == "" {
 		return true // No scope required
 	}
+	requiredResource, requiredAction, _ := strings.Cut(required, ":")
 
 	// Parse comma or space separated scopes
 	scopeList := ParseScopes(scopes)
@@ -76,12 +167,16 @@ func HasScope(scopes string, required string) bool {
 		if s == All || s == required {
 			return true
 		}
+		resource, action, ok := strings.Cut(s, ":")
+		if !ok || resource != requiredResource {
+			continue
+		}
 		// Check for prefix match (e.g., "payments:*" matches "payments:read")
-		if strings.HasSuffix(s, ":*") {
-			prefix := strings.TrimSuffix(s, ":*")
-			if strings.HasPrefix(required, prefix+":") {
-				return true
-			}
+		if action == "*" {
+			return true
+		}
+		if rank, ok := actionRanks[action]; ok && rank >= actionRanks[requiredAction] && actionRanks[requiredAction] > 0 {
+			return true
 		}
 	}
 	return false
@@ -107,13 +202,23 @@ func ParseScopes(scopes string) []string {
 	return result
 }
 
+// isValid reports whether a scope is known, or is the wildcard of a known
+// resource
+func isValid(scope string) bool {
+	if ValidScopes[scope] {
+		return true
+	}
+	resource, ok := strings.CutSuffix(scope, ":*")
+	return ok && ValidScopes[resource+":"+ActionRead]
+}
+
 // ValidateScopes checks if all scopes in the string are valid.
 func ValidateScopes(scopes string) ([]string, []string) {
 	scopeList := ParseScopes(scopes)
 	var valid, invalid []string
 
 	for _, s := range scopeList {
-		if ValidScopes[s] {
+		if isValid(s) {
 			valid = append(valid, s)
 		} else {
 			invalid = append(invalid, s)
@@ -0,0 +1,134 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238's test vectors, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeAt_RFC6238(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1. The RFC's codes have 8 digits; 6-digit
+	// codes are their last 6.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},          // 94287082
+		{1111111109, "081804"},  // 07081804
+		{1111111111, "050471"},  // 14050471
+		{1234567890, "005924"},  // 89005924
+		{2000000000, "279037"},  // 69279037
+		{20000000000, "353130"}, // 65353130
+	}
+
+	for _, tt := range tests {
+		got, err := CodeAt(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("CodeAt failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("At %d: expected %s, got %s", tt.unix, tt.want, got)
+		}
+	}
+
+	if got, _ := CodeAt(" gezdgnbvgy3tqojqgezdgnbvgy3tqojq ", 1); got != "287082" {
+		t.Errorf("Expected a lowercase secret with spaces around to work, got %s", got)
+	}
+	if _, err := CodeAt("not base32!", 1); err == nil {
+		t.Error("Expected an invalid secret to fail")
+	}
+}
+
+func TestValidate_Skew(t *testing.T) {
+	now := time.Unix(1111111111, 0) // Step 37037037
+	step := Step(now)
+	code := func(s int64) string {
+		c, err := CodeAt(rfcSecret, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantOK   bool
+		wantStep int64
+	}{
+		{"Current step", code(step), true, step},
+		{"Previous step", code(step - 1), true, step - 1},
+		{"Next step", code(step + 1), true, step + 1},
+		{"Two steps ago", code(step - 2), false, 0},
+		{"Two steps ahead", code(step + 2), false, 0},
+		{"With spaces", "050 471", true, step},
+		{"Too short", "50471", false, 0},
+		{"Too long", "0504710", false, 0},
+		{"Wrong code", "123456", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Validate(rfcSecret, tt.code, now)
+			if ok != tt.wantOK || got != tt.wantStep {
+				t.Errorf("Expected %v at step %d, got %v at step %d", tt.wantOK, tt.wantStep, ok, got)
+			}
+		})
+	}
+
+	t.Run("Window follows the clock", func(t *testing.T) {
+		// The last second of the step still accepts the previous step's code,
+		// the next second no longer does
+		end := time.Unix((step+1)*int64(Period/time.Second)-1, 0)
+		if _, ok := Validate(rfcSecret, code(step-1), end); !ok {
+			t.Error("Expected the previous step's code accepted until the step ends")
+		}
+		if _, ok := Validate(rfcSecret, code(step-1), end.Add(time.Second)); ok {
+			t.Error("Expected the code rejected two steps on")
+		}
+	})
+
+	if _, ok := Validate("not base32!", "050471", now); ok {
+		t.Error("Expected an invalid secret to reject every code")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret failed: %v", err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Errorf("Expected a base32 160-bit secret, got %q", secret)
+	}
+	if other, _ := GenerateSecret(); other == secret {
+		t.Error("Expected a new secret each time")
+	}
+
+	now := time.Now()
+	c, _ := CodeAt(secret, Step(now))
+	if _, ok := Validate(secret, c, now); !ok {
+		t.Error("Expected a generated secret's code to validate")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(ProvisioningURI(rfcSecret, "Sapliy", "dev@example.com"))
+	if err != nil {
+		t.Fatalf("Invalid URI: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Sapliy:dev@example.com" {
+		t.Errorf("Expected an otpauth://totp/ URI labelled issuer:account, got %s", uri)
+	}
+	q := uri.Query()
+	want := map[string]string{"secret": rfcSecret, "issuer": "Sapliy", "algorithm": "SHA1", "digits": "6", "period": "30"}
+	for key, value := range want {
+		if q.Get(key) != value {
+			t.Errorf("Expected %s=%s, got %q", key, value, q.Get(key))
+		}
+	}
+}
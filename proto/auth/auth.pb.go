@@ -1158,6 +1158,134 @@ func (x *ListTeamMembersResponse) GetMemberships() []*Membership {
 	return nil
 }
 
+type ResolveZoneRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	OrgId         string                 `protobuf:"bytes,2,opt,name=org_id,json=orgId,proto3" json:"org_id,omitempty"` // Organization of the session, if any; other organizations' zones are denied
+	ZoneId        string                 `protobuf:"bytes,3,opt,name=zone_id,json=zoneId,proto3" json:"zone_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveZoneRequest) Reset() {
+	*x = ResolveZoneRequest{}
+	mi := &file_proto_auth_auth_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveZoneRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveZoneRequest) ProtoMessage() {}
+
+func (x *ResolveZoneRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auth_auth_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveZoneRequest.ProtoReflect.Descriptor instead.
+func (*ResolveZoneRequest) Descriptor() ([]byte, []int) {
+	return file_proto_auth_auth_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ResolveZoneRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ResolveZoneRequest) GetOrgId() string {
+	if x != nil {
+		return x.OrgId
+	}
+	return ""
+}
+
+func (x *ResolveZoneRequest) GetZoneId() string {
+	if x != nil {
+		return x.ZoneId
+	}
+	return ""
+}
+
+type ResolveZoneResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Allowed       bool                   `protobuf:"varint,1,opt,name=allowed,proto3" json:"allowed,omitempty"`
+	OrgId         string                 `protobuf:"bytes,2,opt,name=org_id,json=orgId,proto3" json:"org_id,omitempty"`
+	Mode          string                 `protobuf:"bytes,3,opt,name=mode,proto3" json:"mode,omitempty"` // Mode of the zone, test or live
+	Role          string                 `protobuf:"bytes,4,opt,name=role,proto3" json:"role,omitempty"` // The user's role in the zone's organization
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveZoneResponse) Reset() {
+	*x = ResolveZoneResponse{}
+	mi := &file_proto_auth_auth_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveZoneResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveZoneResponse) ProtoMessage() {}
+
+func (x *ResolveZoneResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_auth_auth_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveZoneResponse.ProtoReflect.Descriptor instead.
+func (*ResolveZoneResponse) Descriptor() ([]byte, []int) {
+	return file_proto_auth_auth_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ResolveZoneResponse) GetAllowed() bool {
+	if x != nil {
+		return x.Allowed
+	}
+	return false
+}
+
+func (x *ResolveZoneResponse) GetOrgId() string {
+	if x != nil {
+		return x.OrgId
+	}
+	return ""
+}
+
+func (x *ResolveZoneResponse) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *ResolveZoneResponse) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
 var File_proto_auth_auth_proto protoreflect.FileDescriptor
 
 const file_proto_auth_auth_proto_rawDesc = "" +
@@ -1251,7 +1379,16 @@ const file_proto_auth_auth_proto_rawDesc = "" +
 	"\x16ListTeamMembersRequest\x12\x15\n" +
 	"\x06org_id\x18\x01 \x01(\tR\x05orgId\"M\n" +
 	"\x17ListTeamMembersResponse\x122\n" +
-	"\vmemberships\x18\x01 \x03(\v2\x10.auth.MembershipR\vmemberships2\xc7\a\n" +
+	"\vmemberships\x18\x01 \x03(\v2\x10.auth.MembershipR\vmemberships\"]\n" +
+	"\x12ResolveZoneRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x15\n" +
+	"\x06org_id\x18\x02 \x01(\tR\x05orgId\x12\x17\n" +
+	"\azone_id\x18\x03 \x01(\tR\x06zoneId\"n\n" +
+	"\x13ResolveZoneResponse\x12\x18\n" +
+	"\aallowed\x18\x01 \x01(\bR\aallowed\x12\x15\n" +
+	"\x06org_id\x18\x02 \x01(\tR\x05orgId\x12\x12\n" +
+	"\x04mode\x18\x03 \x01(\tR\x04mode\x12\x12\n" +
+	"\x04role\x18\x04 \x01(\tR\x04role2\x8b\b\n" +
 	"\vAuthService\x12`\n" +
 	"\vValidateKey\x12\x18.auth.ValidateKeyRequest\x1a\x19.auth.ValidateKeyResponse\"\x1c\x82\xd3\xe4\x93\x02\x16:\x01*\"\x11/v1/auth/validate\x12l\n" +
 	"\rValidateToken\x12\x1a.auth.ValidateTokenRequest\x1a\x1b.auth.ValidateTokenResponse\"\"\x82\xd3\xe4\x93\x02\x1c:\x01*\"\x17/v1/auth/validate_token\x12i\n" +
@@ -1261,7 +1398,8 @@ const file_proto_auth_auth_proto_rawDesc = "" +
 	"\fGetAuditLogs\x12\x19.auth.GetAuditLogsRequest\x1a\x1a.auth.GetAuditLogsResponse\"\x1b\x82\xd3\xe4\x93\x02\x15\x12\x13/v1/auth/audit_logs\x12`\n" +
 	"\rAddTeamMember\x12\x1a.auth.AddTeamMemberRequest\x1a\x10.auth.Membership\"!\x82\xd3\xe4\x93\x02\x1b:\x01*\"\x16/v1/auth/teams/members\x12q\n" +
 	"\x10RemoveTeamMember\x12\x1d.auth.RemoveTeamMemberRequest\x1a\x1e.auth.RemoveTeamMemberResponse\"\x1e\x82\xd3\xe4\x93\x02\x18*\x16/v1/auth/teams/members\x12w\n" +
-	"\x0fListTeamMembers\x12\x1c.auth.ListTeamMembersRequest\x1a\x1d.auth.ListTeamMembersResponse\"'\x82\xd3\xe4\x93\x02!\x12\x1f/v1/auth/teams/{org_id}/membersB0Z.github.com/sapliy/fintech-ecosystem/proto/authb\x06proto3"
+	"\x0fListTeamMembers\x12\x1c.auth.ListTeamMembersRequest\x1a\x1d.auth.ListTeamMembersResponse\"'\x82\xd3\xe4\x93\x02!\x12\x1f/v1/auth/teams/{org_id}/members\x12B\n" +
+	"\vResolveZone\x12\x18.auth.ResolveZoneRequest\x1a\x19.auth.ResolveZoneResponseB0Z.github.com/sapliy/fintech-ecosystem/proto/authb\x06proto3"
 
 var (
 	file_proto_auth_auth_proto_rawDescOnce sync.Once
@@ -1275,7 +1413,7 @@ func file_proto_auth_auth_proto_rawDescGZIP() []byte {
 	return file_proto_auth_auth_proto_rawDescData
 }
 
-var file_proto_auth_auth_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_proto_auth_auth_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
 var file_proto_auth_auth_proto_goTypes = []any{
 	(*ValidateKeyRequest)(nil),       // 0: auth.ValidateKeyRequest
 	(*ValidateKeyResponse)(nil),      // 1: auth.ValidateKeyResponse
@@ -1295,6 +1433,8 @@ var file_proto_auth_auth_proto_goTypes = []any{
 	(*RemoveTeamMemberResponse)(nil), // 15: auth.RemoveTeamMemberResponse
 	(*ListTeamMembersRequest)(nil),   // 16: auth.ListTeamMembersRequest
 	(*ListTeamMembersResponse)(nil),  // 17: auth.ListTeamMembersResponse
+	(*ResolveZoneRequest)(nil),       // 18: auth.ResolveZoneRequest
+	(*ResolveZoneResponse)(nil),      // 19: auth.ResolveZoneResponse
 }
 var file_proto_auth_auth_proto_depIdxs = []int32{
 	9,  // 0: auth.GetAuditLogsResponse.logs:type_name -> auth.AuditLog
@@ -1308,17 +1448,19 @@ var file_proto_auth_auth_proto_depIdxs = []int32{
 	13, // 8: auth.AuthService.AddTeamMember:input_type -> auth.AddTeamMemberRequest
 	14, // 9: auth.AuthService.RemoveTeamMember:input_type -> auth.RemoveTeamMemberRequest
 	16, // 10: auth.AuthService.ListTeamMembers:input_type -> auth.ListTeamMembersRequest
-	1,  // 11: auth.AuthService.ValidateKey:output_type -> auth.ValidateKeyResponse
-	3,  // 12: auth.AuthService.ValidateToken:output_type -> auth.ValidateTokenResponse
-	4,  // 13: auth.AuthService.CreateSSOProvider:output_type -> auth.SSOProvider
-	4,  // 14: auth.AuthService.GetSSOProvider:output_type -> auth.SSOProvider
-	8,  // 15: auth.AuthService.InitiateSSO:output_type -> auth.InitiateSSOResponse
-	11, // 16: auth.AuthService.GetAuditLogs:output_type -> auth.GetAuditLogsResponse
-	12, // 17: auth.AuthService.AddTeamMember:output_type -> auth.Membership
-	15, // 18: auth.AuthService.RemoveTeamMember:output_type -> auth.RemoveTeamMemberResponse
-	17, // 19: auth.AuthService.ListTeamMembers:output_type -> auth.ListTeamMembersResponse
-	11, // [11:20] is the sub-list for method output_type
-	2,  // [2:11] is the sub-list for method input_type
+	18, // 11: auth.AuthService.ResolveZone:input_type -> auth.ResolveZoneRequest
+	1,  // 12: auth.AuthService.ValidateKey:output_type -> auth.ValidateKeyResponse
+	3,  // 13: auth.AuthService.ValidateToken:output_type -> auth.ValidateTokenResponse
+	4,  // 14: auth.AuthService.CreateSSOProvider:output_type -> auth.SSOProvider
+	4,  // 15: auth.AuthService.GetSSOProvider:output_type -> auth.SSOProvider
+	8,  // 16: auth.AuthService.InitiateSSO:output_type -> auth.InitiateSSOResponse
+	11, // 17: auth.AuthService.GetAuditLogs:output_type -> auth.GetAuditLogsResponse
+	12, // 18: auth.AuthService.AddTeamMember:output_type -> auth.Membership
+	15, // 19: auth.AuthService.RemoveTeamMember:output_type -> auth.RemoveTeamMemberResponse
+	17, // 20: auth.AuthService.ListTeamMembers:output_type -> auth.ListTeamMembersResponse
+	19, // 21: auth.AuthService.ResolveZone:output_type -> auth.ResolveZoneResponse
+	12, // [12:22] is the sub-list for method output_type
+	2,  // [2:12] is the sub-list for method input_type
 	2,  // [2:2] is the sub-list for extension type_name
 	2,  // [2:2] is the sub-list for extension extendee
 	0,  // [0:2] is the sub-list for field type_name
@@ -1335,7 +1477,7 @@ func file_proto_auth_auth_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_auth_auth_proto_rawDesc), len(file_proto_auth_auth_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   18,
+			NumMessages:   20,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
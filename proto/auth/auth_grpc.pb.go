@@ -28,6 +28,7 @@ const (
 	AuthService_AddTeamMember_FullMethodName     = "/auth.AuthService/AddTeamMember"
 	AuthService_RemoveTeamMember_FullMethodName  = "/auth.AuthService/RemoveTeamMember"
 	AuthService_ListTeamMembers_FullMethodName   = "/auth.AuthService/ListTeamMembers"
+	AuthService_ResolveZone_FullMethodName       = "/auth.AuthService/ResolveZone"
 )
 
 // AuthServiceClient is the client API for AuthService service.
@@ -43,6 +44,10 @@ type AuthServiceClient interface {
 	AddTeamMember(ctx context.Context, in *AddTeamMemberRequest, opts ...grpc.CallOption) (*Membership, error)
 	RemoveTeamMember(ctx context.Context, in *RemoveTeamMemberRequest, opts ...grpc.CallOption) (*RemoveTeamMemberResponse, error)
 	ListTeamMembers(ctx context.Context, in *ListTeamMembersRequest, opts ...grpc.CallOption) (*ListTeamMembersResponse, error)
+	// ResolveZone checks a user may act in a zone through their membership of
+	// the zone's organization. Services call it for session tokens, which carry
+	// no zone; it is not exposed through the gateway.
+	ResolveZone(ctx context.Context, in *ResolveZoneRequest, opts ...grpc.CallOption) (*ResolveZoneResponse, error)
 }
 
 type authServiceClient struct {
@@ -143,6 +148,16 @@ func (c *authServiceClient) ListTeamMembers(ctx context.Context, in *ListTeamMem
 	return out, nil
 }
 
+func (c *authServiceClient) ResolveZone(ctx context.Context, in *ResolveZoneRequest, opts ...grpc.CallOption) (*ResolveZoneResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResolveZoneResponse)
+	err := c.cc.Invoke(ctx, AuthService_ResolveZone_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AuthServiceServer is the server API for AuthService service.
 // All implementations must embed UnimplementedAuthServiceServer
 // for forward compatibility.
@@ -156,6 +171,10 @@ type AuthServiceServer interface {
 	AddTeamMember(context.Context, *AddTeamMemberRequest) (*Membership, error)
 	RemoveTeamMember(context.Context, *RemoveTeamMemberRequest) (*RemoveTeamMemberResponse, error)
 	ListTeamMembers(context.Context, *ListTeamMembersRequest) (*ListTeamMembersResponse, error)
+	// ResolveZone checks a user may act in a zone through their membership of
+	// the zone's organization. Services call it for session tokens, which carry
+	// no zone; it is not exposed through the gateway.
+	ResolveZone(context.Context, *ResolveZoneRequest) (*ResolveZoneResponse, error)
 	mustEmbedUnimplementedAuthServiceServer()
 }
 
@@ -193,6 +212,9 @@ func (UnimplementedAuthServiceServer) RemoveTeamMember(context.Context, *RemoveT
 func (UnimplementedAuthServiceServer) ListTeamMembers(context.Context, *ListTeamMembersRequest) (*ListTeamMembersResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListTeamMembers not implemented")
 }
+func (UnimplementedAuthServiceServer) ResolveZone(context.Context, *ResolveZoneRequest) (*ResolveZoneResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResolveZone not implemented")
+}
 func (UnimplementedAuthServiceServer) mustEmbedUnimplementedAuthServiceServer() {}
 func (UnimplementedAuthServiceServer) testEmbeddedByValue()                     {}
 
@@ -376,6 +398,24 @@ func _AuthService_ListTeamMembers_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AuthService_ResolveZone_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResolveZoneRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).ResolveZone(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthService_ResolveZone_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).ResolveZone(ctx, req.(*ResolveZoneRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // AuthService_ServiceDesc is the grpc.ServiceDesc for AuthService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -419,6 +459,10 @@ var AuthService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListTeamMembers",
 			Handler:    _AuthService_ListTeamMembers_Handler,
 		},
+		{
+			MethodName: "ResolveZone",
+			Handler:    _AuthService_ResolveZone_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/auth/auth.proto",
@@ -152,14 +152,17 @@ func (x *CreateAccountResponse) GetStatus() string {
 }
 
 type RecordTransactionRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	AccountId     string                 `protobuf:"bytes,1,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
-	Amount        int64                  `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"` // In cents
-	Currency      string                 `protobuf:"bytes,3,opt,name=currency,proto3" json:"currency,omitempty"`
-	Description   string                 `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
-	ReferenceId   string                 `protobuf:"bytes,5,opt,name=reference_id,json=referenceId,proto3" json:"reference_id,omitempty"` // e.g. PaymentIntent ID
-	ZoneId        string                 `protobuf:"bytes,6,opt,name=zone_id,json=zoneId,proto3" json:"zone_id,omitempty"`
-	Mode          string                 `protobuf:"bytes,7,opt,name=mode,proto3" json:"mode,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	AccountId   string                 `protobuf:"bytes,1,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	Amount      int64                  `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"` // In cents
+	Currency    string                 `protobuf:"bytes,3,opt,name=currency,proto3" json:"currency,omitempty"`
+	Description string                 `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	ReferenceId string                 `protobuf:"bytes,5,opt,name=reference_id,json=referenceId,proto3" json:"reference_id,omitempty"` // e.g. PaymentIntent ID
+	ZoneId      string                 `protobuf:"bytes,6,opt,name=zone_id,json=zoneId,proto3" json:"zone_id,omitempty"`
+	Mode        string                 `protobuf:"bytes,7,opt,name=mode,proto3" json:"mode,omitempty"`
+	// Entries of one balanced transaction, recorded together instead of
+	// account_id against the system balancing account
+	Entries       []*EntryRequest `protobuf:"bytes,8,rep,name=entries,proto3" json:"entries,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -243,6 +246,81 @@ func (x *RecordTransactionRequest) GetMode() string {
 	return ""
 }
 
+func (x *RecordTransactionRequest) GetEntries() []*EntryRequest {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type EntryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AccountId     string                 `protobuf:"bytes,1,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	Amount        int64                  `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"`      // In cents, credits positive; a transaction's entries sum to zero
+	Currency      string                 `protobuf:"bytes,3,opt,name=currency,proto3" json:"currency,omitempty"`   // Defaults to the account's currency
+	Direction     string                 `protobuf:"bytes,4,opt,name=direction,proto3" json:"direction,omitempty"` // "credit" or "debit"; by the amount's sign if empty
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EntryRequest) Reset() {
+	*x = EntryRequest{}
+	mi := &file_proto_ledger_ledger_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EntryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EntryRequest) ProtoMessage() {}
+
+func (x *EntryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ledger_ledger_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EntryRequest.ProtoReflect.Descriptor instead.
+func (*EntryRequest) Descriptor() ([]byte, []int) {
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *EntryRequest) GetAccountId() string {
+	if x != nil {
+		return x.AccountId
+	}
+	return ""
+}
+
+func (x *EntryRequest) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *EntryRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *EntryRequest) GetDirection() string {
+	if x != nil {
+		return x.Direction
+	}
+	return ""
+}
+
 type RecordTransactionResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	TransactionId string                 `protobuf:"bytes,1,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
@@ -253,7 +331,7 @@ type RecordTransactionResponse struct {
 
 func (x *RecordTransactionResponse) Reset() {
 	*x = RecordTransactionResponse{}
-	mi := &file_proto_ledger_ledger_proto_msgTypes[3]
+	mi := &file_proto_ledger_ledger_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -265,7 +343,7 @@ func (x *RecordTransactionResponse) String() string {
 func (*RecordTransactionResponse) ProtoMessage() {}
 
 func (x *RecordTransactionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_ledger_ledger_proto_msgTypes[3]
+	mi := &file_proto_ledger_ledger_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -278,7 +356,7 @@ func (x *RecordTransactionResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RecordTransactionResponse.ProtoReflect.Descriptor instead.
 func (*RecordTransactionResponse) Descriptor() ([]byte, []int) {
-	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{3}
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *RecordTransactionResponse) GetTransactionId() string {
@@ -304,7 +382,7 @@ type BulkRecordRequest struct {
 
 func (x *BulkRecordRequest) Reset() {
 	*x = BulkRecordRequest{}
-	mi := &file_proto_ledger_ledger_proto_msgTypes[4]
+	mi := &file_proto_ledger_ledger_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -316,7 +394,7 @@ func (x *BulkRecordRequest) String() string {
 func (*BulkRecordRequest) ProtoMessage() {}
 
 func (x *BulkRecordRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_ledger_ledger_proto_msgTypes[4]
+	mi := &file_proto_ledger_ledger_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -329,7 +407,7 @@ func (x *BulkRecordRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BulkRecordRequest.ProtoReflect.Descriptor instead.
 func (*BulkRecordRequest) Descriptor() ([]byte, []int) {
-	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{4}
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *BulkRecordRequest) GetTransactions() []*RecordTransactionRequest {
@@ -348,7 +426,7 @@ type BulkRecordResponse struct {
 
 func (x *BulkRecordResponse) Reset() {
 	*x = BulkRecordResponse{}
-	mi := &file_proto_ledger_ledger_proto_msgTypes[5]
+	mi := &file_proto_ledger_ledger_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -360,7 +438,7 @@ func (x *BulkRecordResponse) String() string {
 func (*BulkRecordResponse) ProtoMessage() {}
 
 func (x *BulkRecordResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_ledger_ledger_proto_msgTypes[5]
+	mi := &file_proto_ledger_ledger_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -373,7 +451,7 @@ func (x *BulkRecordResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BulkRecordResponse.ProtoReflect.Descriptor instead.
 func (*BulkRecordResponse) Descriptor() ([]byte, []int) {
-	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{5}
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *BulkRecordResponse) GetResponses() []*RecordTransactionResponse {
@@ -392,7 +470,7 @@ type GetAccountRequest struct {
 
 func (x *GetAccountRequest) Reset() {
 	*x = GetAccountRequest{}
-	mi := &file_proto_ledger_ledger_proto_msgTypes[6]
+	mi := &file_proto_ledger_ledger_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -404,7 +482,7 @@ func (x *GetAccountRequest) String() string {
 func (*GetAccountRequest) ProtoMessage() {}
 
 func (x *GetAccountRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_ledger_ledger_proto_msgTypes[6]
+	mi := &file_proto_ledger_ledger_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -417,7 +495,7 @@ func (x *GetAccountRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetAccountRequest.ProtoReflect.Descriptor instead.
 func (*GetAccountRequest) Descriptor() ([]byte, []int) {
-	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{6}
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *GetAccountRequest) GetAccountId() string {
@@ -439,7 +517,7 @@ type GetAccountResponse struct {
 
 func (x *GetAccountResponse) Reset() {
 	*x = GetAccountResponse{}
-	mi := &file_proto_ledger_ledger_proto_msgTypes[7]
+	mi := &file_proto_ledger_ledger_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -451,7 +529,7 @@ func (x *GetAccountResponse) String() string {
 func (*GetAccountResponse) ProtoMessage() {}
 
 func (x *GetAccountResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_ledger_ledger_proto_msgTypes[7]
+	mi := &file_proto_ledger_ledger_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -464,7 +542,7 @@ func (x *GetAccountResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetAccountResponse.ProtoReflect.Descriptor instead.
 func (*GetAccountResponse) Descriptor() ([]byte, []int) {
-	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{7}
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *GetAccountResponse) GetAccountId() string {
@@ -495,53 +573,861 @@ func (x *GetAccountResponse) GetCreatedAt() *timestamppb.Timestamp {
 	return nil
 }
 
-var File_proto_ledger_ledger_proto protoreflect.FileDescriptor
+type Entry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	TransactionId string                 `protobuf:"bytes,2,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
+	AccountId     string                 `protobuf:"bytes,3,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	Amount        int64                  `protobuf:"varint,4,opt,name=amount,proto3" json:"amount,omitempty"` // In cents, debits positive
+	Currency      string                 `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`
+	Direction     string                 `protobuf:"bytes,6,opt,name=direction,proto3" json:"direction,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_proto_ledger_ledger_proto_rawDesc = "" +
-	"\n" +
-	"\x19proto/ledger/ledger.proto\x12\x06ledger\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x1cgoogle/api/annotations.proto\"\x87\x01\n" +
-	"\x14CreateAccountRequest\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
-	"\x04type\x18\x02 \x01(\tR\x04type\x12\x1a\n" +
-	"\bcurrency\x18\x03 \x01(\tR\bcurrency\x12\x17\n" +
-	"\azone_id\x18\x04 \x01(\tR\x06zoneId\x12\x12\n" +
-	"\x04mode\x18\x05 \x01(\tR\x04mode\"N\n" +
-	"\x15CreateAccountResponse\x12\x1d\n" +
-	"\n" +
-	"account_id\x18\x01 \x01(\tR\taccountId\x12\x16\n" +
-	"\x06status\x18\x02 \x01(\tR\x06status\"\xdf\x01\n" +
-	"\x18RecordTransactionRequest\x12\x1d\n" +
-	"\n" +
-	"account_id\x18\x01 \x01(\tR\taccountId\x12\x16\n" +
-	"\x06amount\x18\x02 \x01(\x03R\x06amount\x12\x1a\n" +
-	"\bcurrency\x18\x03 \x01(\tR\bcurrency\x12 \n" +
-	"\vdescription\x18\x04 \x01(\tR\vdescription\x12!\n" +
-	"\freference_id\x18\x05 \x01(\tR\vreferenceId\x12\x17\n" +
-	"\azone_id\x18\x06 \x01(\tR\x06zoneId\x12\x12\n" +
-	"\x04mode\x18\a \x01(\tR\x04mode\"Z\n" +
-	"\x19RecordTransactionResponse\x12%\n" +
-	"\x0etransaction_id\x18\x01 \x01(\tR\rtransactionId\x12\x16\n" +
-	"\x06status\x18\x02 \x01(\tR\x06status\"Y\n" +
-	"\x11BulkRecordRequest\x12D\n" +
-	"\ftransactions\x18\x01 \x03(\v2 .ledger.RecordTransactionRequestR\ftransactions\"U\n" +
-	"\x12BulkRecordResponse\x12?\n" +
-	"\tresponses\x18\x01 \x03(\v2!.ledger.RecordTransactionResponseR\tresponses\"2\n" +
-	"\x11GetAccountRequest\x12\x1d\n" +
-	"\n" +
-	"account_id\x18\x01 \x01(\tR\taccountId\"\xa4\x01\n" +
-	"\x12GetAccountResponse\x12\x1d\n" +
-	"\n" +
-	"account_id\x18\x01 \x01(\tR\taccountId\x12\x18\n" +
-	"\abalance\x18\x02 \x01(\x03R\abalance\x12\x1a\n" +
-	"\bcurrency\x18\x03 \x01(\tR\bcurrency\x129\n" +
+func (x *Entry) Reset() {
+	*x = Entry{}
+	mi := &file_proto_ledger_ledger_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Entry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Entry) ProtoMessage() {}
+
+func (x *Entry) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ledger_ledger_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Entry.ProtoReflect.Descriptor instead.
+func (*Entry) Descriptor() ([]byte, []int) {
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Entry) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Entry) GetTransactionId() string {
+	if x != nil {
+		return x.TransactionId
+	}
+	return ""
+}
+
+func (x *Entry) GetAccountId() string {
+	if x != nil {
+		return x.AccountId
+	}
+	return ""
+}
+
+func (x *Entry) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *Entry) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Entry) GetDirection() string {
+	if x != nil {
+		return x.Direction
+	}
+	return ""
+}
+
+func (x *Entry) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type Transaction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ReferenceId   string                 `protobuf:"bytes,2,opt,name=reference_id,json=referenceId,proto3" json:"reference_id,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	ZoneId        string                 `protobuf:"bytes,4,opt,name=zone_id,json=zoneId,proto3" json:"zone_id,omitempty"`
+	Mode          string                 `protobuf:"bytes,5,opt,name=mode,proto3" json:"mode,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Entries       []*Entry               `protobuf:"bytes,7,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_proto_ledger_ledger_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ledger_ledger_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Transaction) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Transaction) GetReferenceId() string {
+	if x != nil {
+		return x.ReferenceId
+	}
+	return ""
+}
+
+func (x *Transaction) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Transaction) GetZoneId() string {
+	if x != nil {
+		return x.ZoneId
+	}
+	return ""
+}
+
+func (x *Transaction) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *Transaction) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Transaction) GetEntries() []*Entry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type ListTransactionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ZoneId        string                 `protobuf:"bytes,1,opt,name=zone_id,json=zoneId,proto3" json:"zone_id,omitempty"`
+	Limit         int32                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"` // Defaults to 50
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTransactionsRequest) Reset() {
+	*x = ListTransactionsRequest{}
+	mi := &file_proto_ledger_ledger_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTransactionsRequest) ProtoMessage() {}
+
+func (x *ListTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ledger_ledger_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*ListTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListTransactionsRequest) GetZoneId() string {
+	if x != nil {
+		return x.ZoneId
+	}
+	return ""
+}
+
+func (x *ListTransactionsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ListTransactionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transactions  []*Transaction         `protobuf:"bytes,1,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTransactionsResponse) Reset() {
+	*x = ListTransactionsResponse{}
+	mi := &file_proto_ledger_ledger_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTransactionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTransactionsResponse) ProtoMessage() {}
+
+func (x *ListTransactionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ledger_ledger_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTransactionsResponse.ProtoReflect.Descriptor instead.
+func (*ListTransactionsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ListTransactionsResponse) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+type GetTransactionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransactionId string                 `protobuf:"bytes,1,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransactionRequest) Reset() {
+	*x = GetTransactionRequest{}
+	mi := &file_proto_ledger_ledger_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionRequest) ProtoMessage() {}
+
+func (x *GetTransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ledger_ledger_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionRequest.ProtoReflect.Descriptor instead.
+func (*GetTransactionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetTransactionRequest) GetTransactionId() string {
+	if x != nil {
+		return x.TransactionId
+	}
+	return ""
+}
+
+type GetTransactionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transaction   *Transaction           `protobuf:"bytes,1,opt,name=transaction,proto3" json:"transaction,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransactionResponse) Reset() {
+	*x = GetTransactionResponse{}
+	mi := &file_proto_ledger_ledger_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionResponse) ProtoMessage() {}
+
+func (x *GetTransactionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ledger_ledger_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionResponse.ProtoReflect.Descriptor instead.
+func (*GetTransactionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetTransactionResponse) GetTransaction() *Transaction {
+	if x != nil {
+		return x.Transaction
+	}
+	return nil
+}
+
+type GetAccountEntriesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AccountId     string                 `protobuf:"bytes,1,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	Currency      string                 `protobuf:"bytes,2,opt,name=currency,proto3" json:"currency,omitempty"` // Defaults to the account's currency
+	From          *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=to,proto3" json:"to,omitempty"`
+	Cursor        string                 `protobuf:"bytes,5,opt,name=cursor,proto3" json:"cursor,omitempty"` // next_cursor of the previous page
+	Limit         int32                  `protobuf:"varint,6,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAccountEntriesRequest) Reset() {
+	*x = GetAccountEntriesRequest{}
+	mi := &file_proto_ledger_ledger_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAccountEntriesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAccountEntriesRequest) ProtoMessage() {}
+
+func (x *GetAccountEntriesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ledger_ledger_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAccountEntriesRequest.ProtoReflect.Descriptor instead.
+func (*GetAccountEntriesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GetAccountEntriesRequest) GetAccountId() string {
+	if x != nil {
+		return x.AccountId
+	}
+	return ""
+}
+
+func (x *GetAccountEntriesRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *GetAccountEntriesRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *GetAccountEntriesRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *GetAccountEntriesRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *GetAccountEntriesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type StatementEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entry         *Entry                 `protobuf:"bytes,1,opt,name=entry,proto3" json:"entry,omitempty"`
+	ReferenceId   string                 `protobuf:"bytes,2,opt,name=reference_id,json=referenceId,proto3" json:"reference_id,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Balance       int64                  `protobuf:"varint,4,opt,name=balance,proto3" json:"balance,omitempty"` // Running balance after the entry
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatementEntry) Reset() {
+	*x = StatementEntry{}
+	mi := &file_proto_ledger_ledger_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatementEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatementEntry) ProtoMessage() {}
+
+func (x *StatementEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ledger_ledger_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatementEntry.ProtoReflect.Descriptor instead.
+func (*StatementEntry) Descriptor() ([]byte, []int) {
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *StatementEntry) GetEntry() *Entry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+func (x *StatementEntry) GetReferenceId() string {
+	if x != nil {
+		return x.ReferenceId
+	}
+	return ""
+}
+
+func (x *StatementEntry) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *StatementEntry) GetBalance() int64 {
+	if x != nil {
+		return x.Balance
+	}
+	return 0
+}
+
+type GetAccountEntriesResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	AccountId      string                 `protobuf:"bytes,1,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	Currency       string                 `protobuf:"bytes,2,opt,name=currency,proto3" json:"currency,omitempty"`
+	OpeningBalance int64                  `protobuf:"varint,3,opt,name=opening_balance,json=openingBalance,proto3" json:"opening_balance,omitempty"`
+	ClosingBalance int64                  `protobuf:"varint,4,opt,name=closing_balance,json=closingBalance,proto3" json:"closing_balance,omitempty"`
+	Entries        []*StatementEntry      `protobuf:"bytes,5,rep,name=entries,proto3" json:"entries,omitempty"`
+	NextCursor     string                 `protobuf:"bytes,6,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetAccountEntriesResponse) Reset() {
+	*x = GetAccountEntriesResponse{}
+	mi := &file_proto_ledger_ledger_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAccountEntriesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAccountEntriesResponse) ProtoMessage() {}
+
+func (x *GetAccountEntriesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ledger_ledger_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAccountEntriesResponse.ProtoReflect.Descriptor instead.
+func (*GetAccountEntriesResponse) Descriptor() ([]byte, []int) {
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *GetAccountEntriesResponse) GetAccountId() string {
+	if x != nil {
+		return x.AccountId
+	}
+	return ""
+}
+
+func (x *GetAccountEntriesResponse) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *GetAccountEntriesResponse) GetOpeningBalance() int64 {
+	if x != nil {
+		return x.OpeningBalance
+	}
+	return 0
+}
+
+func (x *GetAccountEntriesResponse) GetClosingBalance() int64 {
+	if x != nil {
+		return x.ClosingBalance
+	}
+	return 0
+}
+
+func (x *GetAccountEntriesResponse) GetEntries() []*StatementEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *GetAccountEntriesResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type WatchBalanceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AccountId     string                 `protobuf:"bytes,1,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchBalanceRequest) Reset() {
+	*x = WatchBalanceRequest{}
+	mi := &file_proto_ledger_ledger_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchBalanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchBalanceRequest) ProtoMessage() {}
+
+func (x *WatchBalanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ledger_ledger_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchBalanceRequest.ProtoReflect.Descriptor instead.
+func (*WatchBalanceRequest) Descriptor() ([]byte, []int) {
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *WatchBalanceRequest) GetAccountId() string {
+	if x != nil {
+		return x.AccountId
+	}
+	return ""
+}
+
+type BalanceUpdate struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	AccountId        string                 `protobuf:"bytes,1,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	Currency         string                 `protobuf:"bytes,2,opt,name=currency,proto3" json:"currency,omitempty"`
+	Balance          int64                  `protobuf:"varint,3,opt,name=balance,proto3" json:"balance,omitempty"`
+	AvailableBalance int64                  `protobuf:"varint,4,opt,name=available_balance,json=availableBalance,proto3" json:"available_balance,omitempty"`                                   // Balance less active holds
+	Balances         map[string]int64       `protobuf:"bytes,5,rep,name=balances,proto3" json:"balances,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"` // Per currency
+	UpdatedAt        *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *BalanceUpdate) Reset() {
+	*x = BalanceUpdate{}
+	mi := &file_proto_ledger_ledger_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BalanceUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BalanceUpdate) ProtoMessage() {}
+
+func (x *BalanceUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_ledger_ledger_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BalanceUpdate.ProtoReflect.Descriptor instead.
+func (*BalanceUpdate) Descriptor() ([]byte, []int) {
+	return file_proto_ledger_ledger_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *BalanceUpdate) GetAccountId() string {
+	if x != nil {
+		return x.AccountId
+	}
+	return ""
+}
+
+func (x *BalanceUpdate) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *BalanceUpdate) GetBalance() int64 {
+	if x != nil {
+		return x.Balance
+	}
+	return 0
+}
+
+func (x *BalanceUpdate) GetAvailableBalance() int64 {
+	if x != nil {
+		return x.AvailableBalance
+	}
+	return 0
+}
+
+func (x *BalanceUpdate) GetBalances() map[string]int64 {
+	if x != nil {
+		return x.Balances
+	}
+	return nil
+}
+
+func (x *BalanceUpdate) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+var File_proto_ledger_ledger_proto protoreflect.FileDescriptor
+
+const file_proto_ledger_ledger_proto_rawDesc = "" +
+	"\n" +
+	"\x19proto/ledger/ledger.proto\x12\x06ledger\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x1cgoogle/api/annotations.proto\"\x87\x01\n" +
+	"\x14CreateAccountRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x1a\n" +
+	"\bcurrency\x18\x03 \x01(\tR\bcurrency\x12\x17\n" +
+	"\azone_id\x18\x04 \x01(\tR\x06zoneId\x12\x12\n" +
+	"\x04mode\x18\x05 \x01(\tR\x04mode\"N\n" +
+	"\x15CreateAccountResponse\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\"\x8f\x02\n" +
+	"\x18RecordTransactionRequest\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x03R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x03 \x01(\tR\bcurrency\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\x12!\n" +
+	"\freference_id\x18\x05 \x01(\tR\vreferenceId\x12\x17\n" +
+	"\azone_id\x18\x06 \x01(\tR\x06zoneId\x12\x12\n" +
+	"\x04mode\x18\a \x01(\tR\x04mode\x12.\n" +
+	"\aentries\x18\b \x03(\v2\x14.ledger.EntryRequestR\aentries\"\x7f\n" +
+	"\fEntryRequest\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x03R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x03 \x01(\tR\bcurrency\x12\x1c\n" +
+	"\tdirection\x18\x04 \x01(\tR\tdirection\"Z\n" +
+	"\x19RecordTransactionResponse\x12%\n" +
+	"\x0etransaction_id\x18\x01 \x01(\tR\rtransactionId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\"Y\n" +
+	"\x11BulkRecordRequest\x12D\n" +
+	"\ftransactions\x18\x01 \x03(\v2 .ledger.RecordTransactionRequestR\ftransactions\"U\n" +
+	"\x12BulkRecordResponse\x12?\n" +
+	"\tresponses\x18\x01 \x03(\v2!.ledger.RecordTransactionResponseR\tresponses\"2\n" +
+	"\x11GetAccountRequest\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\"\xa4\x01\n" +
+	"\x12GetAccountResponse\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\x12\x18\n" +
+	"\abalance\x18\x02 \x01(\x03R\abalance\x12\x1a\n" +
+	"\bcurrency\x18\x03 \x01(\tR\bcurrency\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"\xea\x01\n" +
+	"\x05Entry\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12%\n" +
+	"\x0etransaction_id\x18\x02 \x01(\tR\rtransactionId\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x03 \x01(\tR\taccountId\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x03R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\x12\x1c\n" +
+	"\tdirection\x18\x06 \x01(\tR\tdirection\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"\xf3\x01\n" +
+	"\vTransaction\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12!\n" +
+	"\freference_id\x18\x02 \x01(\tR\vreferenceId\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x17\n" +
+	"\azone_id\x18\x04 \x01(\tR\x06zoneId\x12\x12\n" +
+	"\x04mode\x18\x05 \x01(\tR\x04mode\x129\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12'\n" +
+	"\aentries\x18\a \x03(\v2\r.ledger.EntryR\aentries\"H\n" +
+	"\x17ListTransactionsRequest\x12\x17\n" +
+	"\azone_id\x18\x01 \x01(\tR\x06zoneId\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\"S\n" +
+	"\x18ListTransactionsResponse\x127\n" +
+	"\ftransactions\x18\x01 \x03(\v2\x13.ledger.TransactionR\ftransactions\">\n" +
+	"\x15GetTransactionRequest\x12%\n" +
+	"\x0etransaction_id\x18\x01 \x01(\tR\rtransactionId\"O\n" +
+	"\x16GetTransactionResponse\x125\n" +
+	"\vtransaction\x18\x01 \x01(\v2\x13.ledger.TransactionR\vtransaction\"\xdf\x01\n" +
+	"\x18GetAccountEntriesRequest\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\x12\x1a\n" +
+	"\bcurrency\x18\x02 \x01(\tR\bcurrency\x12.\n" +
+	"\x04from\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12\x16\n" +
+	"\x06cursor\x18\x05 \x01(\tR\x06cursor\x12\x14\n" +
+	"\x05limit\x18\x06 \x01(\x05R\x05limit\"\x94\x01\n" +
+	"\x0eStatementEntry\x12#\n" +
+	"\x05entry\x18\x01 \x01(\v2\r.ledger.EntryR\x05entry\x12!\n" +
+	"\freference_id\x18\x02 \x01(\tR\vreferenceId\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x18\n" +
+	"\abalance\x18\x04 \x01(\x03R\abalance\"\xfb\x01\n" +
+	"\x19GetAccountEntriesResponse\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\x12\x1a\n" +
+	"\bcurrency\x18\x02 \x01(\tR\bcurrency\x12'\n" +
+	"\x0fopening_balance\x18\x03 \x01(\x03R\x0eopeningBalance\x12'\n" +
+	"\x0fclosing_balance\x18\x04 \x01(\x03R\x0eclosingBalance\x120\n" +
+	"\aentries\x18\x05 \x03(\v2\x16.ledger.StatementEntryR\aentries\x12\x1f\n" +
+	"\vnext_cursor\x18\x06 \x01(\tR\n" +
+	"nextCursor\"4\n" +
+	"\x13WatchBalanceRequest\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\"\xca\x02\n" +
+	"\rBalanceUpdate\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\x12\x1a\n" +
+	"\bcurrency\x18\x02 \x01(\tR\bcurrency\x12\x18\n" +
+	"\abalance\x18\x03 \x01(\x03R\abalance\x12+\n" +
+	"\x11available_balance\x18\x04 \x01(\x03R\x10availableBalance\x12?\n" +
+	"\bbalances\x18\x05 \x03(\v2#.ledger.BalanceUpdate.BalancesEntryR\bbalances\x129\n" +
 	"\n" +
-	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt2\xe4\x03\n" +
+	"updated_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x1a;\n" +
+	"\rBalancesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x012\xb3\a\n" +
 	"\rLedgerService\x12x\n" +
 	"\x16BulkRecordTransactions\x12\x19.ledger.BulkRecordRequest\x1a\x1a.ledger.BulkRecordResponse\"'\x82\xd3\xe4\x93\x02!:\x01*\"\x1c/v1/ledger/bulk-transactions\x12|\n" +
 	"\x11RecordTransaction\x12 .ledger.RecordTransactionRequest\x1a!.ledger.RecordTransactionResponse\"\"\x82\xd3\xe4\x93\x02\x1c:\x01*\"\x17/v1/ledger/transactions\x12l\n" +
 	"\rCreateAccount\x12\x1c.ledger.CreateAccountRequest\x1a\x1d.ledger.CreateAccountResponse\"\x1e\x82\xd3\xe4\x93\x02\x18:\x01*\"\x13/v1/ledger/accounts\x12m\n" +
 	"\n" +
-	"GetAccount\x12\x19.ledger.GetAccountRequest\x1a\x1a.ledger.GetAccountResponse\"(\x82\xd3\xe4\x93\x02\"\x12 /v1/ledger/accounts/{account_id}B2Z0github.com/sapliy/fintech-ecosystem/proto/ledgerb\x06proto3"
+	"GetAccount\x12\x19.ledger.GetAccountRequest\x1a\x1a.ledger.GetAccountResponse\"(\x82\xd3\xe4\x93\x02\"\x12 /v1/ledger/accounts/{account_id}\x12v\n" +
+	"\x10ListTransactions\x12\x1f.ledger.ListTransactionsRequest\x1a .ledger.ListTransactionsResponse\"\x1f\x82\xd3\xe4\x93\x02\x19\x12\x17/v1/ledger/transactions\x12\x81\x01\n" +
+	"\x0eGetTransaction\x12\x1d.ledger.GetTransactionRequest\x1a\x1e.ledger.GetTransactionResponse\"0\x82\xd3\xe4\x93\x02*\x12(/v1/ledger/transactions/{transaction_id}\x12\x8a\x01\n" +
+	"\x11GetAccountEntries\x12 .ledger.GetAccountEntriesRequest\x1a!.ledger.GetAccountEntriesResponse\"0\x82\xd3\xe4\x93\x02*\x12(/v1/ledger/accounts/{account_id}/entries\x12D\n" +
+	"\fWatchBalance\x12\x1b.ledger.WatchBalanceRequest\x1a\x15.ledger.BalanceUpdate0\x01B2Z0github.com/sapliy/fintech-ecosystem/proto/ledgerb\x06proto3"
 
 var (
 	file_proto_ledger_ledger_proto_rawDescOnce sync.Once
@@ -555,35 +1441,68 @@ func file_proto_ledger_ledger_proto_rawDescGZIP() []byte {
 	return file_proto_ledger_ledger_proto_rawDescData
 }
 
-var file_proto_ledger_ledger_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_proto_ledger_ledger_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
 var file_proto_ledger_ledger_proto_goTypes = []any{
 	(*CreateAccountRequest)(nil),      // 0: ledger.CreateAccountRequest
 	(*CreateAccountResponse)(nil),     // 1: ledger.CreateAccountResponse
 	(*RecordTransactionRequest)(nil),  // 2: ledger.RecordTransactionRequest
-	(*RecordTransactionResponse)(nil), // 3: ledger.RecordTransactionResponse
-	(*BulkRecordRequest)(nil),         // 4: ledger.BulkRecordRequest
-	(*BulkRecordResponse)(nil),        // 5: ledger.BulkRecordResponse
-	(*GetAccountRequest)(nil),         // 6: ledger.GetAccountRequest
-	(*GetAccountResponse)(nil),        // 7: ledger.GetAccountResponse
-	(*timestamppb.Timestamp)(nil),     // 8: google.protobuf.Timestamp
+	(*EntryRequest)(nil),              // 3: ledger.EntryRequest
+	(*RecordTransactionResponse)(nil), // 4: ledger.RecordTransactionResponse
+	(*BulkRecordRequest)(nil),         // 5: ledger.BulkRecordRequest
+	(*BulkRecordResponse)(nil),        // 6: ledger.BulkRecordResponse
+	(*GetAccountRequest)(nil),         // 7: ledger.GetAccountRequest
+	(*GetAccountResponse)(nil),        // 8: ledger.GetAccountResponse
+	(*Entry)(nil),                     // 9: ledger.Entry
+	(*Transaction)(nil),               // 10: ledger.Transaction
+	(*ListTransactionsRequest)(nil),   // 11: ledger.ListTransactionsRequest
+	(*ListTransactionsResponse)(nil),  // 12: ledger.ListTransactionsResponse
+	(*GetTransactionRequest)(nil),     // 13: ledger.GetTransactionRequest
+	(*GetTransactionResponse)(nil),    // 14: ledger.GetTransactionResponse
+	(*GetAccountEntriesRequest)(nil),  // 15: ledger.GetAccountEntriesRequest
+	(*StatementEntry)(nil),            // 16: ledger.StatementEntry
+	(*GetAccountEntriesResponse)(nil), // 17: ledger.GetAccountEntriesResponse
+	(*WatchBalanceRequest)(nil),       // 18: ledger.WatchBalanceRequest
+	(*BalanceUpdate)(nil),             // 19: ledger.BalanceUpdate
+	nil,                               // 20: ledger.BalanceUpdate.BalancesEntry
+	(*timestamppb.Timestamp)(nil),     // 21: google.protobuf.Timestamp
 }
 var file_proto_ledger_ledger_proto_depIdxs = []int32{
-	2, // 0: ledger.BulkRecordRequest.transactions:type_name -> ledger.RecordTransactionRequest
-	3, // 1: ledger.BulkRecordResponse.responses:type_name -> ledger.RecordTransactionResponse
-	8, // 2: ledger.GetAccountResponse.created_at:type_name -> google.protobuf.Timestamp
-	4, // 3: ledger.LedgerService.BulkRecordTransactions:input_type -> ledger.BulkRecordRequest
-	2, // 4: ledger.LedgerService.RecordTransaction:input_type -> ledger.RecordTransactionRequest
-	0, // 5: ledger.LedgerService.CreateAccount:input_type -> ledger.CreateAccountRequest
-	6, // 6: ledger.LedgerService.GetAccount:input_type -> ledger.GetAccountRequest
-	5, // 7: ledger.LedgerService.BulkRecordTransactions:output_type -> ledger.BulkRecordResponse
-	3, // 8: ledger.LedgerService.RecordTransaction:output_type -> ledger.RecordTransactionResponse
-	1, // 9: ledger.LedgerService.CreateAccount:output_type -> ledger.CreateAccountResponse
-	7, // 10: ledger.LedgerService.GetAccount:output_type -> ledger.GetAccountResponse
-	7, // [7:11] is the sub-list for method output_type
-	3, // [3:7] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	3,  // 0: ledger.RecordTransactionRequest.entries:type_name -> ledger.EntryRequest
+	2,  // 1: ledger.BulkRecordRequest.transactions:type_name -> ledger.RecordTransactionRequest
+	4,  // 2: ledger.BulkRecordResponse.responses:type_name -> ledger.RecordTransactionResponse
+	21, // 3: ledger.GetAccountResponse.created_at:type_name -> google.protobuf.Timestamp
+	21, // 4: ledger.Entry.created_at:type_name -> google.protobuf.Timestamp
+	21, // 5: ledger.Transaction.created_at:type_name -> google.protobuf.Timestamp
+	9,  // 6: ledger.Transaction.entries:type_name -> ledger.Entry
+	10, // 7: ledger.ListTransactionsResponse.transactions:type_name -> ledger.Transaction
+	10, // 8: ledger.GetTransactionResponse.transaction:type_name -> ledger.Transaction
+	21, // 9: ledger.GetAccountEntriesRequest.from:type_name -> google.protobuf.Timestamp
+	21, // 10: ledger.GetAccountEntriesRequest.to:type_name -> google.protobuf.Timestamp
+	9,  // 11: ledger.StatementEntry.entry:type_name -> ledger.Entry
+	16, // 12: ledger.GetAccountEntriesResponse.entries:type_name -> ledger.StatementEntry
+	20, // 13: ledger.BalanceUpdate.balances:type_name -> ledger.BalanceUpdate.BalancesEntry
+	21, // 14: ledger.BalanceUpdate.updated_at:type_name -> google.protobuf.Timestamp
+	5,  // 15: ledger.LedgerService.BulkRecordTransactions:input_type -> ledger.BulkRecordRequest
+	2,  // 16: ledger.LedgerService.RecordTransaction:input_type -> ledger.RecordTransactionRequest
+	0,  // 17: ledger.LedgerService.CreateAccount:input_type -> ledger.CreateAccountRequest
+	7,  // 18: ledger.LedgerService.GetAccount:input_type -> ledger.GetAccountRequest
+	11, // 19: ledger.LedgerService.ListTransactions:input_type -> ledger.ListTransactionsRequest
+	13, // 20: ledger.LedgerService.GetTransaction:input_type -> ledger.GetTransactionRequest
+	15, // 21: ledger.LedgerService.GetAccountEntries:input_type -> ledger.GetAccountEntriesRequest
+	18, // 22: ledger.LedgerService.WatchBalance:input_type -> ledger.WatchBalanceRequest
+	6,  // 23: ledger.LedgerService.BulkRecordTransactions:output_type -> ledger.BulkRecordResponse
+	4,  // 24: ledger.LedgerService.RecordTransaction:output_type -> ledger.RecordTransactionResponse
+	1,  // 25: ledger.LedgerService.CreateAccount:output_type -> ledger.CreateAccountResponse
+	8,  // 26: ledger.LedgerService.GetAccount:output_type -> ledger.GetAccountResponse
+	12, // 27: ledger.LedgerService.ListTransactions:output_type -> ledger.ListTransactionsResponse
+	14, // 28: ledger.LedgerService.GetTransaction:output_type -> ledger.GetTransactionResponse
+	17, // 29: ledger.LedgerService.GetAccountEntries:output_type -> ledger.GetAccountEntriesResponse
+	19, // 30: ledger.LedgerService.WatchBalance:output_type -> ledger.BalanceUpdate
+	23, // [23:31] is the sub-list for method output_type
+	15, // [15:23] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
 }
 
 func init() { file_proto_ledger_ledger_proto_init() }
@@ -597,7 +1516,7 @@ func file_proto_ledger_ledger_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_ledger_ledger_proto_rawDesc), len(file_proto_ledger_ledger_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   21,
 			NumExtensions: 0,
 			NumServices:   1,
 		},